@@ -13,22 +13,43 @@ set -e
 #
 # Strategy: detect the workspace owner and run the sidecar as that UID/GID.
 # This avoids changing host file ownership while giving the agent full access.
+#
+# The runtime can choose the user instead by setting WORKSPACE_UID and
+# WORKSPACE_GID, and set WORKSPACE_CHOWN=false to leave ownership alone.
+# An explicit WORKSPACE_UID=0 keeps the sidecar root; rootless Docker uses
+# it because container root is the host user there.
 
 if [ "$(id -u)" = "0" ]; then
-  WORKSPACE_UID=$(stat -c '%u' /workspace 2>/dev/null || echo 0)
-  WORKSPACE_GID=$(stat -c '%g' /workspace 2>/dev/null || echo 0)
+  if [ -n "$WORKSPACE_UID" ]; then
+    WORKSPACE_GID=${WORKSPACE_GID:-$WORKSPACE_UID}
+    if [ "$WORKSPACE_UID" = "0" ]; then
+      exec agent-sidecar "$@"
+    fi
+  else
+    WORKSPACE_UID=$(stat -c '%u' /workspace 2>/dev/null || echo 0)
+    WORKSPACE_GID=$(stat -c '%g' /workspace 2>/dev/null || echo 0)
+  fi
 
   # If workspace is owned by root (Docker volume or root-owned dir),
   # run as the agentcrew user and ensure it owns /workspace.
   if [ "$WORKSPACE_UID" = "0" ]; then
-    chown -R agentcrew:agentcrew /workspace
+    if [ "$WORKSPACE_CHOWN" != "false" ]; then
+      chown -R agentcrew:agentcrew /workspace
+    fi
     exec gosu agentcrew agent-sidecar "$@"
   fi
 
-  # Workspace is owned by a non-root host user. Ensure .claude config
-  # directories exist and are writable, then run as that user.
+  # Workspace is owned by a non-root host user (or the agent's run_as
+  # user). Ensure the generated config directories exist and are owned by
+  # that user, so it can edit them without sudo, then run as that user.
   mkdir -p /workspace/.claude/agents /workspace/.claude/skills
-  chown -R "$WORKSPACE_UID:$WORKSPACE_GID" /workspace/.claude
+  if [ "$WORKSPACE_CHOWN" != "false" ]; then
+    for dir in /workspace/.claude /workspace/.opencode /workspace/.agents /workspace/uploads; do
+      if [ -e "$dir" ]; then
+        chown -R "$WORKSPACE_UID:$WORKSPACE_GID" "$dir"
+      fi
+    done
+  fi
 
   exec gosu "$WORKSPACE_UID:$WORKSPACE_GID" agent-sidecar "$@"
 fi
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/helmcode/agent-crew/internal/api"
 	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/env"
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
 	"github.com/helmcode/agent-crew/internal/runtime"
 	"github.com/helmcode/agent-crew/internal/scheduler"
 )
@@ -34,22 +40,10 @@ func main() {
 	}
 
 	// Runtime.
-	var rt runtime.AgentRuntime
-	switch os.Getenv("RUNTIME") {
-	case "kubernetes":
-		slog.Info("initializing kubernetes runtime")
-		rt, err = runtime.NewK8sRuntime()
-		if err != nil {
-			slog.Error("failed to initialize kubernetes runtime", "error", err)
-			os.Exit(1)
-		}
-	default:
-		slog.Info("initializing docker runtime")
-		rt, err = runtime.NewDockerRuntime()
-		if err != nil {
-			slog.Error("failed to initialize docker runtime", "error", err)
-			os.Exit(1)
-		}
+	rt, err := runtime.OpenAll(os.Getenv("RUNTIME"), env.List("EXTRA_RUNTIMES"))
+	if err != nil {
+		slog.Error("failed to initialize runtime", "error", err)
+		os.Exit(1)
 	}
 
 	// HTTP server. PORT takes precedence, then LISTEN_ADDR, then default :8080.
@@ -90,14 +84,85 @@ func main() {
 		}
 	}
 
-	// Reconnect NATS relays for teams that were running before this restart.
-	srv.ReconnectRelays()
+	// Cache team rows and settings for the chat, activity and relay paths.
+	if v := os.Getenv("READ_CACHE_TTL_SECONDS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			srv.SetReadCacheTTL(time.Duration(n) * time.Second)
+		}
+	}
+
+	// Pull agent images on the Docker hosts or Kubernetes nodes ahead of
+	// deployments.
+	if v := os.Getenv("IMAGE_PREPULL_INTERVAL_MINUTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			srv.SetImagePrePullInterval(time.Duration(n) * time.Minute)
+		}
+	}
+
+	// Sign shareable run links with a stable secret so they survive restarts
+	// and open on every replica. Without SHARE_LINK_SECRET, it is derived
+	// from JWT_SECRET.
+	if v := os.Getenv("SHARE_LINK_SECRET"); v != "" {
+		srv.SetShareLinkSecret(v)
+	} else if v := os.Getenv("JWT_SECRET"); v != "" {
+		srv.SetShareLinkSecret("share-links:" + v)
+	}
+
+	// Space out prompts across all teams to stay within the provider's rate
+	// limits. The scheduler shares the same governor, and other replicas and
+	// relay workers share its limit through the database.
+	governor := ratelimit.New(db, env.Int("PROMPT_RATE_LIMIT_PER_MINUTE"), env.Int("PROMPT_RATE_LIMIT_BURST"))
+	srv.SetGovernor(governor)
+
+	// With RELAY_MODE=worker, relay workers (cmd/relay) relay team messages
+	// and this API only reads what they store.
+	if os.Getenv("RELAY_MODE") == "worker" {
+		srv.SetRelayWorkers()
+		slog.Info("team messages relayed by relay workers")
+	}
+
+	// Bring stored team slugs in line with the naming rules.
+	srv.SyncTeamSlugs()
+
+	// Deployments, leader restarts and agent upgrades run as background
+	// jobs, on whichever replica claims them.
+	srv.StartJobs(env.Int("JOB_WORKERS"))
 
 	// Start scheduler for cron-based schedule execution.
 	executor := scheduler.NewExecutor(db, rt)
 	executor.LoadSettingsEnvFunc = srv.LoadSettingsEnv
+	executor.LockTeamFunc = srv.LockTeam
+	executor.Governor = governor
+	executor.CheckPromptFunc = srv.CheckPromptPolicies
+	executor.Events = srv.Events()
 	sched := scheduler.New(db, executor.Execute, 0)
-	sched.Start()
+
+	// Relays, schedules and the background loops run on one replica at a
+	// time. With LEADER_ELECTION=true, replicas sharing the database elect
+	// that replica and hand the work over when it goes away; otherwise this
+	// replica runs it.
+	ctx, cancel := context.WithCancel(context.Background())
+	electionDone := make(chan struct{})
+	if os.Getenv("LEADER_ELECTION") == "true" {
+		srv.Standby()
+		elector := election.New(db, "api", electionHolder(),
+			time.Duration(env.Int("LEADER_ELECTION_TTL_SECONDS"))*time.Second)
+		slog.Info("leader election enabled", "holder", elector.Holder())
+		go func() {
+			defer close(electionDone)
+			elector.Run(ctx, func(ctx context.Context) {
+				srv.AcquireOwnership()
+				sched.Start()
+				<-ctx.Done()
+				sched.Stop()
+				srv.ReleaseOwnership()
+			})
+		}()
+	} else {
+		close(electionDone)
+		srv.AcquireOwnership()
+		sched.Start()
+	}
 
 	// Start server in background.
 	go func() {
@@ -112,8 +177,22 @@ func main() {
 	<-quit
 
 	slog.Info("shutting down orchestrator API")
+	// Hand the work over before the HTTP server stops.
+	cancel()
+	<-electionDone
 	sched.Stop()
 	if err := srv.Shutdown(); err != nil {
 		slog.Error("shutdown error", "error", err)
 	}
 }
+
+// electionHolder returns the name this replica holds the election lease
+// under: the pod name on Kubernetes, so that a restarted container takes its
+// lease back at once, or the host name and process ID.
+func electionHolder() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
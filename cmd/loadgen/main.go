@@ -0,0 +1,68 @@
+// Package main puts load on an AgentCrew installation: it deploys N teams,
+// sends each leader M chat messages a minute and prints a JSON report of
+// chat latency, relay lag, TaskLog write latency and dropped messages.
+// With -nats it also plays the teams' sidecars, answering every message;
+// run the API with RUNTIME=chaos and CHAOS_NATS_URL set to the same server.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/loadgen"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", os.Getenv("AGENTCREW_ENDPOINT"), "API URL, such as http://localhost:8080 (default $AGENTCREW_ENDPOINT)")
+	teams := flag.Int("teams", 5, "number of teams")
+	rate := flag.Int("rate", 60, "chat messages per minute and team")
+	duration := flag.Duration("duration", time.Minute, "how long to send messages")
+	drain := flag.Duration("drain", 30*time.Second, "how long to wait for responses after the last message")
+	natsURL := flag.String("nats", "", "NATS URL; enables the synthetic sidecar")
+	runtime := flag.String("runtime", "", "team runtime (default: the API's)")
+	image := flag.String("image", "", "agent image (default: the API's)")
+	keep := flag.Bool("keep", false, "keep the teams instead of removing them")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if *endpoint == "" {
+		fmt.Fprintln(os.Stderr, "loadgen: -endpoint or AGENTCREW_ENDPOINT is required")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	api := client.New(*endpoint, os.Getenv("AGENTCREW_TOKEN"))
+	slog.Info("running load", "endpoint", *endpoint, "teams", *teams, "rate", *rate, "duration", *duration, "sidecar", *natsURL != "")
+	report, err := loadgen.Run(ctx, api, loadgen.Options{
+		Teams:             *teams,
+		MessagesPerMinute: *rate,
+		Duration:          *duration,
+		Drain:             *drain,
+		NATSURL:           *natsURL,
+		NATSToken:         os.Getenv("NATS_AUTH_TOKEN"),
+		Runtime:           *runtime,
+		AgentImage:        *image,
+		Keep:              *keep,
+	})
+	if err != nil {
+		slog.Error("load run failed", "error", err)
+		os.Exit(1)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	for _, e := range report.Errors {
+		slog.Warn("load run error", "error", e)
+	}
+}
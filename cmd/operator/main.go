@@ -0,0 +1,69 @@
+// Package main implements the AgentCrew Kubernetes operator. It reconciles
+// AgentTeam custom resources into teams through the AgentCrew API, so that
+// teams can be managed with kubectl and GitOps tools.
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/operator"
+)
+
+func main() {
+	endpoint := os.Getenv("AGENTCREW_ENDPOINT")
+	if endpoint == "" {
+		log.Fatal("AGENTCREW_ENDPOINT is required")
+	}
+	namespace := os.Getenv("WATCH_NAMESPACE")
+	workers := envOrDefaultInt("WORKERS", 2)
+	resync := time.Duration(envOrDefaultInt("RESYNC_SECONDS", 30)) * time.Second
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		// Fall back to kubeconfig.
+		kubeconfigPath := os.Getenv("KUBECONFIG")
+		if kubeconfigPath == "" {
+			home, _ := os.UserHomeDir()
+			kubeconfigPath = filepath.Join(home, ".kube", "config")
+		}
+		config, err = clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+		if err != nil {
+			log.Fatalf("creating k8s config: %v", err)
+		}
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		log.Fatalf("creating k8s client: %v", err)
+	}
+
+	slog.Info("starting operator", "endpoint", endpoint, "namespace", namespace, "resync", resync)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	api := client.New(endpoint, os.Getenv("AGENTCREW_TOKEN"))
+	operator.NewController(dyn, api, namespace, resync).Run(ctx, workers)
+	slog.Info("operator stopped")
+}
+
+func envOrDefaultInt(key string, defaultVal int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultVal
+}
@@ -1,7 +1,7 @@
 // Package main implements the RAG MCP server for AgentCrew.
-// It exposes search_knowledge and list_documents tools via the MCP protocol
-// (Streamable HTTP transport) so that agent containers can query the
-// organization's knowledge base.
+// It exposes search_knowledge, search_team_knowledge and list_documents tools
+// via the MCP protocol (Streamable HTTP transport) so that agent containers
+// can query the organization's knowledge base and their team's own.
 package main
 
 import (
@@ -61,6 +61,26 @@ func main() {
 		makeSearchHandler(qdrantClient, embedder, defaultMinScore),
 	)
 
+	// Register search_team_knowledge tool.
+	mcpServer.AddTool(
+		mcp.NewTool("search_team_knowledge",
+			mcp.WithDescription("Search your team's own knowledge base: results of the team's past runs and documents uploaded for the team. Use it to recall earlier work, decisions and findings on long projects."),
+			mcp.WithString("query",
+				mcp.Description("The search query to find relevant past results and documents"),
+				mcp.Required(),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of results to return"),
+				mcp.DefaultNumber(5),
+			),
+			mcp.WithNumber("min_score",
+				mcp.Description("Minimum similarity score threshold (0.0 to 1.0)"),
+				mcp.DefaultNumber(defaultMinScore),
+			),
+		),
+		makeSearchTeamHandler(qdrantClient, embedder, defaultMinScore),
+	)
+
 	// Register list_documents tool.
 	mcpServer.AddTool(
 		mcp.NewTool("list_documents",
@@ -148,24 +168,88 @@ func makeSearchHandler(qdrant *rag.QdrantClient, embedder *rag.OllamaEmbedder, d
 			return mcp.NewToolResultText("No relevant documents found for the query."), nil
 		}
 
-		// Format results as readable text.
-		var output string
-		for i, r := range results {
-			content, _ := r.Payload["content"].(string)
-			docName, _ := r.Payload["doc_name"].(string)
-			fileName, _ := r.Payload["file_name"].(string)
-			chunkIdx := 0
-			if ci, ok := r.Payload["chunk_index"].(float64); ok {
-				chunkIdx = int(ci)
-			}
+		return mcp.NewToolResultText(formatResults(results)), nil
+	}
+}
 
-			output += fmt.Sprintf("--- Result %d (score: %.2f) ---\n", i+1, r.Score)
-			output += fmt.Sprintf("Source: %s (%s), chunk %d\n", docName, fileName, chunkIdx)
-			output += content + "\n\n"
+// makeSearchTeamHandler creates a tool handler for search_team_knowledge.
+func makeSearchTeamHandler(qdrant *rag.QdrantClient, embedder *rag.OllamaEmbedder, defaultMinScore float64) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		teamID := ""
+		if request.Header != nil {
+			teamID = request.Header.Get("X-Team-ID")
+		}
+		if teamID == "" {
+			return mcp.NewToolResultError("This team has no knowledge base. Enable knowledge_base on the team to use it."), nil
 		}
 
-		return mcp.NewToolResultText(output), nil
+		args := getArgs(request)
+
+		query, _ := args["query"].(string)
+		if query == "" {
+			return mcp.NewToolResultError("query parameter is required"), nil
+		}
+
+		limit := 5
+		if l, ok := args["limit"].(float64); ok && l > 0 {
+			limit = int(l)
+		}
+
+		minScore := defaultMinScore
+		if ms, ok := args["min_score"].(float64); ok {
+			minScore = ms
+		}
+
+		slog.Info("search_team_knowledge called", "team_id", teamID, "query", query, "limit", limit, "min_score", minScore)
+
+		// Nothing has been indexed for the team until its first run completes.
+		collection := rag.TeamCollectionName(teamID)
+		exists, err := qdrant.CollectionExists(ctx, collection)
+		if err != nil {
+			return mcp.NewToolResultError("Failed to check collection: " + err.Error()), nil
+		}
+		if !exists {
+			return mcp.NewToolResultText("The team's knowledge base is empty."), nil
+		}
+
+		vector, err := embedder.Embed(ctx, query)
+		if err != nil {
+			slog.Error("failed to embed query", "error", err)
+			return mcp.NewToolResultError("Failed to generate query embedding: " + err.Error()), nil
+		}
+
+		results, err := qdrant.Search(ctx, collection, vector, limit, minScore)
+		if err != nil {
+			slog.Error("failed to search qdrant", "error", err, "collection", collection)
+			return mcp.NewToolResultError("Failed to search team knowledge base: " + err.Error()), nil
+		}
+
+		slog.Info("search_team_knowledge results", "query", query, "results", len(results), "collection", collection)
+
+		if len(results) == 0 {
+			return mcp.NewToolResultText("No relevant team knowledge found for the query."), nil
+		}
+		return mcp.NewToolResultText(formatResults(results)), nil
+	}
+}
+
+// formatResults formats search hits as readable text.
+func formatResults(results []rag.SearchResult) string {
+	var output string
+	for i, r := range results {
+		content, _ := r.Payload["content"].(string)
+		docName, _ := r.Payload["doc_name"].(string)
+		fileName, _ := r.Payload["file_name"].(string)
+		chunkIdx := 0
+		if ci, ok := r.Payload["chunk_index"].(float64); ok {
+			chunkIdx = int(ci)
+		}
+
+		output += fmt.Sprintf("--- Result %d (score: %.2f) ---\n", i+1, r.Score)
+		output += fmt.Sprintf("Source: %s (%s), chunk %d\n", docName, fileName, chunkIdx)
+		output += content + "\n\n"
 	}
+	return output
 }
 
 // makeListDocumentsHandler creates a tool handler for list_documents.
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/helmcode/agent-crew/internal/api"
+	"github.com/helmcode/agent-crew/internal/env"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// The relay worker relays the messages of running teams into the database,
+// off the API. Run it next to API replicas started with RELAY_MODE=worker,
+// against the same database; several workers share the teams between them.
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	}))
+	slog.SetDefault(logger)
+
+	slog.Info("starting relay worker")
+
+	// Database.
+	dbPath := os.Getenv("DATABASE_PATH")
+	if dbPath == "" {
+		dbPath = "agentcrew.db"
+	}
+	db, err := models.InitDB(dbPath)
+	if err != nil {
+		slog.Error("failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+
+	// Runtime, to find each team's NATS.
+	rt, err := runtime.OpenAll(os.Getenv("RUNTIME"), env.List("EXTRA_RUNTIMES"))
+	if err != nil {
+		slog.Error("failed to initialize runtime", "error", err)
+		os.Exit(1)
+	}
+
+	worker := api.NewRelayWorker(db, rt, workerHolder())
+
+	// Queued chat messages are delivered by the worker once the leader is
+	// ready, within the same limits as the API. The prompt rate limit is
+	// shared with the API replicas through the database.
+	worker.SetGovernor(ratelimit.New(db, env.Int("PROMPT_RATE_LIMIT_PER_MINUTE"), env.Int("PROMPT_RATE_LIMIT_BURST")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		worker.Run(ctx)
+	}()
+
+	// Wait for shutdown signal.
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	slog.Info("shutting down relay worker")
+	// Hand the teams over to the other workers.
+	cancel()
+	<-done
+}
+
+// workerHolder returns the name this worker holds its leases under: the pod
+// name on Kubernetes, or the host name and process ID.
+func workerHolder() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+// bridgeStatusSource is the part of the bridge the admin endpoint reads.
+type bridgeStatusSource interface {
+	Status() agentNats.BridgeStatus
+}
+
+// adminServer serves the sidecar's local HTTP admin endpoint, which container
+// healthchecks and operators use to inspect the agent.
+type adminServer struct {
+	cfg       *AgentConfig
+	manager   provider.AgentManager
+	bridge    bridgeStatusSource
+	validate  func() []protocol.ValidationCheck
+	versions  protocol.ToolVersions
+	startedAt time.Time
+}
+
+// managerStatus describes the agent manager in a /status response.
+type managerStatus struct {
+	Status    string `json:"status"`
+	Running   bool   `json:"running"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// adminStatusResponse is the body of GET /status.
+type adminStatusResponse struct {
+	Agent         string                 `json:"agent"`
+	Team          string                 `json:"team"`
+	Role          string                 `json:"role"`
+	Provider      string                 `json:"provider"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Manager       managerStatus          `json:"manager"`
+	Bridge        agentNats.BridgeStatus `json:"bridge"`
+}
+
+// handler returns the admin routes.
+func (a *adminServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", a.handleHealthz)
+	mux.HandleFunc("GET /status", a.handleStatus)
+	mux.HandleFunc("GET /validate", a.handleValidate)
+	return mux
+}
+
+// handleHealthz reports 200 while the agent manager is running and 503
+// otherwise, so it can back a container healthcheck.
+func (a *adminServer) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	if !a.manager.IsRunning() {
+		writeAdminJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status":  "unhealthy",
+			"manager": a.manager.Status(),
+		})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleStatus reports the manager state, session ID, and message queue.
+func (a *adminServer) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	ms := managerStatus{
+		Status:  a.manager.Status(),
+		Running: a.manager.IsRunning(),
+	}
+	if r, ok := a.manager.(provider.Recoverable); ok {
+		ms.SessionID = r.SessionID()
+	}
+
+	writeAdminJSON(w, http.StatusOK, adminStatusResponse{
+		Agent:         a.cfg.Agent.Name,
+		Team:          a.cfg.Agent.Team,
+		Role:          a.cfg.Agent.Role,
+		Provider:      a.cfg.Agent.Provider,
+		UptimeSeconds: int64(time.Since(a.startedAt).Seconds()),
+		Manager:       ms,
+		Bridge:        a.bridge.Status(),
+	})
+}
+
+// handleValidate re-runs the container validation checks and reports the
+// tool versions collected at startup. It responds 503 when any check fails
+// so it can be used as a readiness probe.
+func (a *adminServer) handleValidate(w http.ResponseWriter, _ *http.Request) {
+	checks := a.validate()
+	summary, errCount := summarizeValidation(checks)
+
+	code := http.StatusOK
+	if errCount > 0 {
+		code = http.StatusServiceUnavailable
+	}
+	writeAdminJSON(w, code, protocol.ContainerValidationPayload{
+		AgentName: a.cfg.Agent.Name,
+		Checks:    checks,
+		Summary:   summary,
+		Versions:  &a.versions,
+	})
+}
+
+// writeAdminJSON writes v as a JSON response with the given status code.
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("failed to write admin response", "error", err)
+	}
+}
+
+// startAdminServer starts serving the admin endpoint on addr in the
+// background. It returns nil when the endpoint is disabled.
+func startAdminServer(addr string, a *adminServer) (*http.Server, error) {
+	if addr == adminDisabled {
+		return nil, nil
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Handler:           a.handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin server stopped", "error", err)
+		}
+	}()
+
+	slog.Info("admin endpoint listening", "addr", ln.Addr().String())
+	return srv, nil
+}
+
+// stopAdminServer shuts the admin endpoint down, if it was started.
+func stopAdminServer(srv *http.Server) {
+	if srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Debug("admin server shutdown", "error", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+// stubManager is an AgentManager with a fixed state.
+type stubManager struct {
+	status    string
+	sessionID string
+}
+
+func (m *stubManager) Start(context.Context) error             { return nil }
+func (m *stubManager) SendInput(string) error                  { return nil }
+func (m *stubManager) ReadEvents() <-chan provider.StreamEvent { return nil }
+func (m *stubManager) Restart(string) error                    { return nil }
+func (m *stubManager) Stop() error                             { return nil }
+func (m *stubManager) Status() string                          { return m.status }
+func (m *stubManager) IsRunning() bool                         { return m.status == "running" }
+func (m *stubManager) Recover(context.Context) error           { return nil }
+func (m *stubManager) SessionID() string                       { return m.sessionID }
+
+type stubBridge struct{ status agentNats.BridgeStatus }
+
+func (b stubBridge) Status() agentNats.BridgeStatus { return b.status }
+
+func newTestAdmin(mgr *stubManager, checks []protocol.ValidationCheck) http.Handler {
+	a := &adminServer{
+		cfg:       &AgentConfig{Agent: AgentSection{Name: "leader", Team: "myteam", Role: "leader", Provider: "claude"}},
+		manager:   mgr,
+		bridge:    stubBridge{status: agentNats.BridgeStatus{QueueDepth: 2, RunInFlight: true}},
+		validate:  func() []protocol.ValidationCheck { return checks },
+		versions:  protocol.ToolVersions{Claude: "1.0.83", Node: "20.11.1"},
+		startedAt: time.Now(),
+	}
+	return a.handler()
+}
+
+func serveAdmin(h http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestAdminHealthz(t *testing.T) {
+	if rec := serveAdmin(newTestAdmin(&stubManager{status: "running"}, nil), "/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("running manager: got %d, want 200", rec.Code)
+	}
+	if rec := serveAdmin(newTestAdmin(&stubManager{status: "error"}, nil), "/healthz"); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("failed manager: got %d, want 503", rec.Code)
+	}
+}
+
+func TestAdminStatus(t *testing.T) {
+	rec := serveAdmin(newTestAdmin(&stubManager{status: "running", sessionID: "sess-1"}, nil), "/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code: got %d", rec.Code)
+	}
+
+	var body adminStatusResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Agent != "leader" || body.Manager.Status != "running" || body.Manager.SessionID != "sess-1" {
+		t.Errorf("unexpected status: %+v", body)
+	}
+	if body.Bridge.QueueDepth != 2 || !body.Bridge.RunInFlight {
+		t.Errorf("bridge status: got %+v", body.Bridge)
+	}
+}
+
+func TestAdminValidate(t *testing.T) {
+	ok := []protocol.ValidationCheck{{Name: "workspace", Status: protocol.ValidationOK}}
+	if rec := serveAdmin(newTestAdmin(&stubManager{status: "running"}, ok), "/validate"); rec.Code != http.StatusOK {
+		t.Errorf("passing checks: got %d, want 200", rec.Code)
+	}
+
+	failing := append(ok, protocol.ValidationCheck{Name: "claude_md", Status: protocol.ValidationError})
+	rec := serveAdmin(newTestAdmin(&stubManager{status: "running"}, failing), "/validate")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("failing checks: got %d, want 503", rec.Code)
+	}
+	var body protocol.ContainerValidationPayload
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Summary != "1 ok, 0 warning(s), 1 error(s)" || len(body.Checks) != 2 {
+		t.Errorf("validation body: %+v", body)
+	}
+	if body.Versions == nil || body.Versions.Claude != "1.0.83" || body.Versions.Node != "20.11.1" {
+		t.Errorf("versions: got %+v", body.Versions)
+	}
+}
+
+func TestStartAdminServer_Disabled(t *testing.T) {
+	srv, err := startAdminServer(adminDisabled, &adminServer{})
+	if err != nil || srv != nil {
+		t.Errorf("disabled admin: got srv=%v err=%v", srv, err)
+	}
+	stopAdminServer(srv)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"time"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// maxBootstrapOutput is how much of the end of the bootstrap script's
+// output is kept and published.
+const maxBootstrapOutput = 16 * 1024
+
+// bootstrapWaitDelay bounds how long the script's output pipes are drained
+// after it is killed on timeout, in case it left children holding them.
+const bootstrapWaitDelay = 5 * time.Second
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written.
+type tailBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) >= t.max {
+		t.buf.Reset()
+		p = p[len(p)-t.max:]
+		t.truncated = true
+	} else if over := t.buf.Len() + len(p) - t.max; over > 0 {
+		t.buf.Next(over)
+		t.truncated = true
+	}
+	t.buf.Write(p)
+	return n, nil
+}
+
+// runBootstrap runs the bootstrap script with sh in workDir, killing it
+// when its timeout elapses.
+func runBootstrap(ctx context.Context, bootstrap protocol.BootstrapConfig, workDir string) protocol.BootstrapResult {
+	ctx, cancel := context.WithTimeout(ctx, bootstrap.Timeout())
+	defer cancel()
+
+	out := &tailBuffer{max: maxBootstrapOutput}
+	cmd := exec.CommandContext(ctx, "sh", "-c", bootstrap.Script)
+	cmd.Dir = workDir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.WaitDelay = bootstrapWaitDelay
+	killProcessGroupOnCancel(cmd)
+
+	start := time.Now()
+	err := cmd.Run()
+	result := protocol.BootstrapResult{
+		DurationMS: time.Since(start).Milliseconds(),
+		TimedOut:   errors.Is(ctx.Err(), context.DeadlineExceeded),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && exitErr.ExitCode() >= 0:
+		result.ExitCode = exitErr.ExitCode()
+	default:
+		// Killed by a signal or never started.
+		result.ExitCode = -1
+		if !result.TimedOut {
+			fmt.Fprintf(out, "\n%v\n", err)
+		}
+	}
+	result.Output = out.buf.String()
+	result.Truncated = out.truncated
+	return result
+}
+
+// bootstrapCheck turns a bootstrap run into a validation check. A failed
+// script is an error under the block failure policy and a warning otherwise.
+func bootstrapCheck(bootstrap protocol.BootstrapConfig, result protocol.BootstrapResult) protocol.ValidationCheck {
+	check := protocol.ValidationCheck{Name: "bootstrap"}
+	switch {
+	case result.TimedOut:
+		check.Message = fmt.Sprintf("bootstrap script timed out after %s", bootstrap.Timeout())
+	case result.ExitCode != 0:
+		check.Message = fmt.Sprintf("bootstrap script exited with code %d", result.ExitCode)
+	default:
+		check.Status = protocol.ValidationOK
+		check.Message = fmt.Sprintf("bootstrap script completed in %s", time.Duration(result.DurationMS)*time.Millisecond)
+		return check
+	}
+	if bootstrap.Blocks() {
+		check.Status = protocol.ValidationError
+	} else {
+		check.Status = protocol.ValidationWarning
+	}
+	return check
+}
+
+// runConfiguredBootstrap runs the configured bootstrap script (agent.bootstrap
+// or AGENT_BOOTSTRAP), if any, and publishes its output as a "bootstrap"
+// activity event. It returns the check to include in the container
+// validation, or nil when no script is configured, and an error when the
+// script failed under the block failure policy.
+func runConfiguredBootstrap(ctx context.Context, natsClient *agentNats.Client, cfg *AgentConfig) (*protocol.ValidationCheck, error) {
+	bootstrap := cfg.Agent.Bootstrap
+	if bootstrap.Script == "" {
+		return nil, nil
+	}
+
+	slog.Info("running bootstrap script", "timeout", bootstrap.Timeout())
+	result := runBootstrap(ctx, bootstrap, cfg.Agent.Workspace.Path)
+	check := bootstrapCheck(bootstrap, result)
+	slog.Info("bootstrap script finished", "exit_code", result.ExitCode, "timed_out", result.TimedOut,
+		"duration_ms", result.DurationMS)
+	publishBootstrapEvent(natsClient, cfg.Agent.Name, cfg.Agent.Team, check.Message, result)
+
+	if check.Status == protocol.ValidationError {
+		return &check, fmt.Errorf("bootstrap failed: %s", check.Message)
+	}
+	return &check, nil
+}
+
+// publishBootstrapEvent publishes a bootstrap run to the team activity NATS
+// channel so the API relay saves it as a TaskLog.
+func publishBootstrapEvent(client *agentNats.Client, agentName, teamName, action string, result protocol.BootstrapResult) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("failed to marshal bootstrap result", "error", err)
+		return
+	}
+	payload := protocol.ActivityEventPayload{
+		EventType: "bootstrap",
+		AgentName: agentName,
+		Action:    action,
+		Payload:   data,
+	}
+
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeActivityEvent, payload)
+	if err != nil {
+		slog.Error("failed to create bootstrap event message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for bootstrap event", "error", err)
+		return
+	}
+
+	if err := client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish bootstrap event", "error", err)
+	}
+}
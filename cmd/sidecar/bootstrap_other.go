@@ -0,0 +1,9 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// killProcessGroupOnCancel is a no-op: process groups are not supported on
+// this platform, so only the shell is killed when the script times out.
+func killProcessGroupOnCancel(_ *exec.Cmd) {}
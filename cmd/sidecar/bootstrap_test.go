@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestRunBootstrap_Success(t *testing.T) {
+	dir := t.TempDir()
+	bootstrap := protocol.BootstrapConfig{Script: "echo installing; echo oops >&2; touch ready"}
+
+	result := runBootstrap(context.Background(), bootstrap, dir)
+	if result.ExitCode != 0 || result.TimedOut {
+		t.Fatalf("result = %+v, want success", result)
+	}
+	if !strings.Contains(result.Output, "installing") || !strings.Contains(result.Output, "oops") {
+		t.Errorf("output %q missing stdout or stderr", result.Output)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ready")); err != nil {
+		t.Errorf("script did not run in the workspace: %v", err)
+	}
+	if check := bootstrapCheck(bootstrap, result); check.Status != protocol.ValidationOK {
+		t.Errorf("check = %+v, want ok", check)
+	}
+}
+
+func TestRunBootstrap_FailurePolicy(t *testing.T) {
+	warn := protocol.BootstrapConfig{Script: "exit 3"}
+	result := runBootstrap(context.Background(), warn, t.TempDir())
+	if result.ExitCode != 3 {
+		t.Fatalf("exit code = %d, want 3", result.ExitCode)
+	}
+	if check := bootstrapCheck(warn, result); check.Status != protocol.ValidationWarning {
+		t.Errorf("warn policy check = %+v, want warning", check)
+	}
+
+	block := protocol.BootstrapConfig{Script: "exit 3", FailurePolicy: protocol.BootstrapFailureBlock}
+	check := bootstrapCheck(block, result)
+	if check.Status != protocol.ValidationError || !strings.Contains(check.Message, "code 3") {
+		t.Errorf("block policy check = %+v, want error naming the exit code", check)
+	}
+}
+
+func TestRunBootstrap_Timeout(t *testing.T) {
+	bootstrap := protocol.BootstrapConfig{Script: "echo started; sleep 10", TimeoutSeconds: 1}
+
+	result := runBootstrap(context.Background(), bootstrap, t.TempDir())
+	if !result.TimedOut {
+		t.Fatalf("result = %+v, want timed out", result)
+	}
+	if !strings.Contains(result.Output, "started") {
+		t.Errorf("output %q missing output written before the timeout", result.Output)
+	}
+	if check := bootstrapCheck(bootstrap, result); check.Status != protocol.ValidationWarning || !strings.Contains(check.Message, "timed out") {
+		t.Errorf("check = %+v, want timed out warning", check)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 5}
+	b.Write([]byte("abc"))
+	b.Write([]byte("defg"))
+	if got := b.buf.String(); got != "cdefg" || !b.truncated {
+		t.Errorf("tail = %q (truncated %v), want %q", got, b.truncated, "cdefg")
+	}
+	b.Write([]byte("0123456789"))
+	if got := b.buf.String(); got != "56789" {
+		t.Errorf("tail = %q, want %q", got, "56789")
+	}
+}
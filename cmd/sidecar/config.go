@@ -1,35 +1,122 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
+// ConfigVersion is the sidecar config schema version this binary understands.
+// Files without a version are treated as version 1.
+const ConfigVersion = 1
+
+// defaultShutdownGracePeriod is how long the sidecar waits for an in-flight
+// run on SIGTERM. It stays below the runtimes' 30s stop timeout so the
+// sidecar exits cleanly before it is killed.
+const defaultShutdownGracePeriod = 25 * time.Second
+
+// defaultHealthCheckInterval is how often the sidecar checks that its CLI,
+// credentials and disk still work.
+const defaultHealthCheckInterval = 6 * time.Hour
+
+// defaultAdminListen is the admin endpoint address. It binds to localhost so
+// only in-container healthchecks reach it unless configured otherwise.
+const defaultAdminListen = "127.0.0.1:9091"
+
+// adminDisabled turns the admin endpoint off when used as its listen address.
+const adminDisabled = "off"
+
+// redactedValue replaces secrets when the effective config is printed.
+const redactedValue = "<redacted>"
+
 // AgentConfig holds the full configuration for the agent sidecar.
 // Values are loaded from a YAML file and can be overridden by environment variables.
 type AgentConfig struct {
-	Agent AgentSection `yaml:"agent"`
+	Version int          `yaml:"version"`
+	Agent   AgentSection `yaml:"agent"`
 }
 
 // AgentSection contains agent-specific configuration.
 type AgentSection struct {
-	Name         string            `yaml:"name"`
-	Team         string            `yaml:"team"`
-	Role         string            `yaml:"role"`
-	Provider     string            `yaml:"provider"`       // "claude" (default) or "opencode"
-	OpenCodeModel string           `yaml:"opencode_model"` // Model ID for OpenCode provider (e.g. "anthropic/claude-sonnet-4-20250514").
-	ClaudeModel   string           `yaml:"claude_model"`   // Full model ID for Claude provider (e.g. "claude-sonnet-4-20250514").
-	SystemPrompt string            `yaml:"system_prompt"`
-	NATS         NATSSection       `yaml:"nats"`
-	Permissions  PermissionsSection `yaml:"permissions"`
-	Resources    ResourcesSection  `yaml:"resources"`
+	Name          string             `yaml:"name"`
+	Team          string             `yaml:"team"`
+	Role          string             `yaml:"role"`
+	Provider      string             `yaml:"provider"`       // "claude" (default) or "opencode"
+	OpenCodeModel string             `yaml:"opencode_model"` // Model ID for OpenCode provider (e.g. "anthropic/claude-sonnet-4-20250514").
+	ClaudeModel   string             `yaml:"claude_model"`   // Full model ID for Claude provider (e.g. "claude-sonnet-4-20250514").
+	// ClaudePersistent keeps one Claude CLI process running and feeds it
+	// each message on stdin, instead of starting a process per message.
+	ClaudePersistent bool `yaml:"claude_persistent"`
+	// ClaudeExtraArgs are appended to every Claude CLI invocation. Only the
+	// flags protocol.ValidateClaudeExtraArgs allows are accepted.
+	ClaudeExtraArgs []string `yaml:"claude_extra_args"`
+	// Transcripts writes the raw output of each Claude run to the workspace.
+	Transcripts TranscriptsSection `yaml:"transcripts"`
+	SystemPrompt  string             `yaml:"system_prompt"`
+	NATS          NATSSection        `yaml:"nats"`
+	Permissions   PermissionsSection `yaml:"permissions"`
+	Resources     ResourcesSection   `yaml:"resources"`
+	Skills        SkillsSection      `yaml:"skills"`
+	// Bootstrap is the environment bootstrap script run in the workspace
+	// before the agent starts; empty Script means none.
+	Bootstrap protocol.BootstrapConfig `yaml:"bootstrap"`
+	// Scaffold is the starting structure written into the workspace
+	// while it is empty; nil means none.
+	Scaffold *protocol.WorkspaceScaffold `yaml:"scaffold"`
+	// MaxConcurrentRuns caps the user messages taken on at once, running or
+	// waiting to run; 0 means no cap. Messages over the cap wait in the team
+	// stream until a run finishes.
+	MaxConcurrentRuns int `yaml:"max_concurrent_runs"`
+	// PlanApproval makes chat runs stop at a plan, made with read-only
+	// tools, until the user approves it.
+	PlanApproval bool `yaml:"plan_approval"`
+	// Memory makes the agent summarize what to remember when its team
+	// stops; MemoryContext is the summary from the previous deployment.
+	Memory        bool             `yaml:"memory"`
+	MemoryContext string           `yaml:"memory_context"`
+	Workspace     WorkspaceSection `yaml:"workspace"`
+	Telemetry     TelemetrySection `yaml:"telemetry"`
+	Shutdown      ShutdownSection  `yaml:"shutdown"`
+	Admin         AdminSection     `yaml:"admin"`
+	// HealthCheck controls the periodic self-check of the CLI, credentials
+	// and disk, which reports degradation before chats start failing.
+	HealthCheck HealthCheckSection `yaml:"health_check"`
+	// Restart controls the wait of a sidecar whose container was restarted
+	// after exiting.
+	Restart RestartSection `yaml:"restart"`
 }
 
 // NATSSection holds NATS connection settings.
 type NATSSection struct {
-	URL string `yaml:"url"`
+	URL   string `yaml:"url"`
+	Token string `yaml:"token,omitempty"` // must match the NATS server --auth flag
+	// Stream bounds the team's JetStream stream, which the sidecar creates.
+	Stream StreamSection `yaml:"stream,omitempty"`
+}
+
+// StreamSection holds the retention limits of the team's JetStream stream.
+// Zero values keep the defaults of the nats package.
+type StreamSection struct {
+	MaxAge   time.Duration `yaml:"max_age,omitempty"`
+	MaxBytes int64         `yaml:"max_bytes,omitempty"`
+	MaxMsgs  int64         `yaml:"max_msgs,omitempty"`
 }
 
 // PermissionsSection maps to the permission gate configuration.
@@ -37,7 +124,9 @@ type PermissionsSection struct {
 	AllowedTools    []string `yaml:"allowed_tools"`
 	AllowedCommands []string `yaml:"allowed_commands"`
 	DeniedCommands  []string `yaml:"denied_commands"`
-	FilesystemScope string   `yaml:"filesystem_scope"`
+	// FilesystemScope accepts a list of {path, mode} entries or, for backwards
+	// compatibility, a single path string.
+	FilesystemScope permissions.FilesystemScope `yaml:"filesystem_scope"`
 }
 
 // ResourcesSection holds resource limits for the agent.
@@ -47,8 +136,98 @@ type ResourcesSection struct {
 	Memory         string `yaml:"memory"`
 }
 
+// SkillsSection lists the skills installed before the agent starts.
+type SkillsSection struct {
+	Install []protocol.SkillConfig `yaml:"install"`
+}
+
+// WorkspaceSection holds the agent's workspace settings.
+type WorkspaceSection struct {
+	Path string `yaml:"path"`
+	// ExtraDirs are further workspace roots, such as the team's extra
+	// workspaces. The agent gets access to them and they are added to the
+	// filesystem scope.
+	ExtraDirs permissions.FilesystemScope `yaml:"extra_dirs"`
+}
+
+// TelemetrySection controls the sidecar's own logging.
+type TelemetrySection struct {
+	LogLevel  string `yaml:"log_level"`  // debug, info (default), warn, error
+	LogFormat string `yaml:"log_format"` // json (default) or text
+	// ForwardLevel is the minimum level of sidecar logs forwarded to the API
+	// as agent_log messages: warn (default), error, or off.
+	ForwardLevel string `yaml:"forward_level"`
+	// Level selects which activity events the agent publishes: minimal,
+	// normal (default) or verbose (see protocol.TelemetryNormal).
+	Level string `yaml:"level"`
+}
+
+// TranscriptsSection controls the debug transcripts of the Claude CLI's raw
+// output, written to protocol.TranscriptDir in the workspace.
+type TranscriptsSection struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxFileBytes caps each run's transcript and MaxTotalBytes all of them;
+	// the oldest are deleted first. Zero selects the defaults.
+	MaxFileBytes  int64 `yaml:"max_file_bytes"`
+	MaxTotalBytes int64 `yaml:"max_total_bytes"`
+}
+
+// ShutdownSection controls how the sidecar drains on SIGTERM.
+type ShutdownSection struct {
+	// GracePeriod bounds the wait for the current run to finish (e.g. "25s").
+	// Zero selects the default.
+	GracePeriod time.Duration `yaml:"grace_period"`
+}
+
+// HealthCheckSection controls the sidecar's periodic self-check.
+type HealthCheckSection struct {
+	// Interval is the time between checks (e.g. "6h"). Zero selects the
+	// default.
+	Interval time.Duration `yaml:"interval"`
+	Disabled bool          `yaml:"disabled"`
+}
+
+// RestartSection controls the backoff of a restarted container.
+type RestartSection struct {
+	// Backoff is how long the sidecar waits before starting after its
+	// container's first restart, doubled on each restart after that (e.g.
+	// "10s"). Zero starts at once.
+	Backoff time.Duration `yaml:"backoff"`
+}
+
+// AdminSection configures the sidecar's local HTTP admin endpoint.
+type AdminSection struct {
+	// Listen is the host:port to serve on. Use 0.0.0.0:<port> to expose it on
+	// the team network, or "off" to disable it.
+	Listen string `yaml:"listen"`
+}
+
+// Allowed values for enumerated config fields.
+var (
+	validConfigRoles      = []string{"leader", "worker"}
+	validConfigProviders  = []string{"claude", "opencode"}
+	validConfigLogLevels  = []string{"debug", "info", "warn", "error"}
+	validConfigLogFormats = []string{"json", "text"}
+	validForwardLevels    = []string{"warn", "error", "off"}
+)
+
+// configFilePath returns AGENT_CONFIG_PATH (default /etc/agentcrew/agent.yaml),
+// or "" when the file does not exist so that config comes entirely from
+// env vars.
+func configFilePath() string {
+	configPath := os.Getenv("AGENT_CONFIG_PATH")
+	if configPath == "" {
+		configPath = "/etc/agentcrew/agent.yaml"
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return ""
+	}
+	return configPath
+}
+
 // LoadConfig reads a YAML config file and applies environment variable overrides.
-// Environment variables take precedence over YAML values.
+// Environment variables take precedence over YAML values. The merged result
+// is defaulted and validated; all validation problems are reported together.
 func LoadConfig(path string) (*AgentConfig, error) {
 	cfg := &AgentConfig{}
 
@@ -58,12 +237,35 @@ func LoadConfig(path string) (*AgentConfig, error) {
 		if err != nil {
 			return nil, fmt.Errorf("reading config file %s: %w", path, err)
 		}
-		if err := yaml.Unmarshal(data, cfg); err != nil {
+		if err := decodeConfig(data, cfg); err != nil {
 			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
 		}
 	}
 
-	// Environment variable overrides.
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeConfig strictly decodes YAML into cfg, rejecting unknown keys so that
+// typos are reported instead of silently ignored.
+func decodeConfig(data []byte, cfg *AgentConfig) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv overlays environment variables set by the container runtime.
+func (cfg *AgentConfig) applyEnv() error {
 	if v := os.Getenv("AGENT_NAME"); v != "" {
 		cfg.Agent.Name = v
 	}
@@ -79,6 +281,30 @@ func LoadConfig(path string) (*AgentConfig, error) {
 	if v := os.Getenv("NATS_URL"); v != "" {
 		cfg.Agent.NATS.URL = v
 	}
+	if v := os.Getenv("NATS_AUTH_TOKEN"); v != "" {
+		cfg.Agent.NATS.Token = v
+	}
+	if v := os.Getenv("NATS_STREAM_MAX_AGE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("parsing NATS_STREAM_MAX_AGE: %w", err)
+		}
+		cfg.Agent.NATS.Stream.MaxAge = d
+	}
+	if v := os.Getenv("NATS_STREAM_MAX_BYTES"); v != "" {
+		n, err := agentNats.ParseSize(v)
+		if err != nil {
+			return fmt.Errorf("parsing NATS_STREAM_MAX_BYTES: %w", err)
+		}
+		cfg.Agent.NATS.Stream.MaxBytes = n
+	}
+	if v := os.Getenv("NATS_STREAM_MAX_MSGS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("parsing NATS_STREAM_MAX_MSGS: %w", err)
+		}
+		cfg.Agent.NATS.Stream.MaxMsgs = n
+	}
 	if v := os.Getenv("AGENT_PROVIDER"); v != "" {
 		cfg.Agent.Provider = v
 	}
@@ -88,8 +314,128 @@ func LoadConfig(path string) (*AgentConfig, error) {
 	if v := os.Getenv("CLAUDE_MODEL"); v != "" {
 		cfg.Agent.ClaudeModel = v
 	}
+	if v := os.Getenv("AGENT_TRANSCRIPTS"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_TRANSCRIPTS: %w", err)
+		}
+		cfg.Agent.Transcripts.Enabled = enabled
+	}
+	if v := os.Getenv("CLAUDE_PERSISTENT"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("parsing CLAUDE_PERSISTENT: %w", err)
+		}
+		cfg.Agent.ClaudePersistent = enabled
+	}
+	if v := os.Getenv("CLAUDE_EXTRA_ARGS"); v != "" {
+		var args []string
+		if err := json.Unmarshal([]byte(v), &args); err != nil {
+			return fmt.Errorf("parsing CLAUDE_EXTRA_ARGS: expected a JSON list of strings: %w", err)
+		}
+		cfg.Agent.ClaudeExtraArgs = args
+	}
+	if v := os.Getenv("WORKSPACE_PATH"); v != "" {
+		cfg.Agent.Workspace.Path = v
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		cfg.Agent.Telemetry.LogLevel = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		cfg.Agent.Telemetry.LogFormat = v
+	}
+	if v := os.Getenv("LOG_FORWARD_LEVEL"); v != "" {
+		cfg.Agent.Telemetry.ForwardLevel = v
+	}
+	if v := os.Getenv("AGENT_TELEMETRY_LEVEL"); v != "" {
+		cfg.Agent.Telemetry.Level = v
+	}
+	if v := os.Getenv("AGENT_ADMIN_LISTEN"); v != "" {
+		cfg.Agent.Admin.Listen = v
+	}
+	if v := os.Getenv("SHUTDOWN_GRACE_PERIOD"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("parsing SHUTDOWN_GRACE_PERIOD: %w", err)
+		}
+		cfg.Agent.Shutdown.GracePeriod = d
+	}
+	if v := os.Getenv("AGENT_HEALTH_CHECK_INTERVAL"); v != "" {
+		if v == "off" {
+			cfg.Agent.HealthCheck.Disabled = true
+		} else {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("parsing AGENT_HEALTH_CHECK_INTERVAL: %w", err)
+			}
+			cfg.Agent.HealthCheck.Interval = d
+		}
+	}
+	if v := os.Getenv("AGENT_RESTART_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_RESTART_BACKOFF: %w", err)
+		}
+		cfg.Agent.Restart.Backoff = d
+	}
+	if v := os.Getenv("AGENT_EXTRA_WORKSPACES"); v != "" {
+		dirs, err := permissions.ParseFilesystemScope(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_EXTRA_WORKSPACES: %w", err)
+		}
+		cfg.Agent.Workspace.ExtraDirs = dirs
+	}
 	if v := os.Getenv("AGENT_FILESYSTEM_SCOPE"); v != "" {
-		cfg.Agent.Permissions.FilesystemScope = v
+		scope, err := permissions.ParseFilesystemScope(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_FILESYSTEM_SCOPE: %w", err)
+		}
+		cfg.Agent.Permissions.FilesystemScope = scope
+	}
+	if v := os.Getenv("AGENT_SKILLS_INSTALL"); v != "" {
+		var skills []protocol.SkillConfig
+		if err := json.Unmarshal([]byte(v), &skills); err != nil {
+			return fmt.Errorf("parsing AGENT_SKILLS_INSTALL: expected a JSON list of {repo_url, skill_name}: %w", err)
+		}
+		cfg.Agent.Skills.Install = skills
+	}
+	if v := os.Getenv("AGENT_MAX_CONCURRENT_RUNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_MAX_CONCURRENT_RUNS: %w", err)
+		}
+		cfg.Agent.MaxConcurrentRuns = n
+	}
+	if v := os.Getenv("AGENT_PLAN_APPROVAL"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_PLAN_APPROVAL: %w", err)
+		}
+		cfg.Agent.PlanApproval = enabled
+	}
+	if v := os.Getenv("AGENT_MEMORY"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("parsing AGENT_MEMORY: %w", err)
+		}
+		cfg.Agent.Memory = enabled
+	}
+	if v := os.Getenv("AGENT_MEMORY_CONTEXT"); v != "" {
+		cfg.Agent.MemoryContext = v
+	}
+	if v := os.Getenv("AGENT_BOOTSTRAP"); v != "" {
+		var bootstrap protocol.BootstrapConfig
+		if err := json.Unmarshal([]byte(v), &bootstrap); err != nil {
+			return fmt.Errorf("parsing AGENT_BOOTSTRAP: expected a JSON object {script, timeout_seconds, failure_policy}: %w", err)
+		}
+		cfg.Agent.Bootstrap = bootstrap
+	}
+	if v := os.Getenv("AGENT_WORKSPACE_SCAFFOLD"); v != "" {
+		var scaffold protocol.WorkspaceScaffold
+		if err := json.Unmarshal([]byte(v), &scaffold); err != nil {
+			return fmt.Errorf("parsing AGENT_WORKSPACE_SCAFFOLD: expected a JSON object {template, dirs, files, git_init}: %w", err)
+		}
+		cfg.Agent.Scaffold = &scaffold
 	}
 
 	// Parse JSON permissions from env if provided (set by Docker runtime).
@@ -97,43 +443,251 @@ func LoadConfig(path string) (*AgentConfig, error) {
 		// AGENT_PERMISSIONS is JSON from the runtime; parse into struct fields.
 		// This is a simplified overlay — the YAML values are the primary source.
 		var perms PermissionsSection
-		if err := yaml.Unmarshal([]byte(v), &perms); err == nil {
-			if len(perms.AllowedTools) > 0 {
-				cfg.Agent.Permissions.AllowedTools = perms.AllowedTools
-			}
-			if len(perms.AllowedCommands) > 0 {
-				cfg.Agent.Permissions.AllowedCommands = perms.AllowedCommands
-			}
-			if len(perms.DeniedCommands) > 0 {
-				cfg.Agent.Permissions.DeniedCommands = perms.DeniedCommands
-			}
-			if perms.FilesystemScope != "" {
-				cfg.Agent.Permissions.FilesystemScope = perms.FilesystemScope
-			}
+		if err := yaml.Unmarshal([]byte(v), &perms); err != nil {
+			return fmt.Errorf("parsing AGENT_PERMISSIONS: %w", err)
+		}
+		if len(perms.AllowedTools) > 0 {
+			cfg.Agent.Permissions.AllowedTools = perms.AllowedTools
+		}
+		if len(perms.AllowedCommands) > 0 {
+			cfg.Agent.Permissions.AllowedCommands = perms.AllowedCommands
+		}
+		if len(perms.DeniedCommands) > 0 {
+			cfg.Agent.Permissions.DeniedCommands = perms.DeniedCommands
+		}
+		if len(perms.FilesystemScope) > 0 {
+			cfg.Agent.Permissions.FilesystemScope = perms.FilesystemScope
 		}
 	}
+	return nil
+}
 
-	// Validate required fields.
-	if cfg.Agent.Name == "" {
-		return nil, fmt.Errorf("agent name is required (set via config file or AGENT_NAME env)")
-	}
-	if cfg.Agent.Team == "" {
-		return nil, fmt.Errorf("team name is required (set via config file or TEAM_NAME env)")
-	}
-	if cfg.Agent.NATS.URL == "" {
-		return nil, fmt.Errorf("NATS URL is required (set via config file or NATS_URL env)")
+// applyDefaults fills in optional fields left empty by the file and env.
+func (cfg *AgentConfig) applyDefaults() {
+	if cfg.Version == 0 {
+		cfg.Version = ConfigVersion
 	}
-
-	// Defaults.
 	if cfg.Agent.Provider == "" {
 		cfg.Agent.Provider = "claude"
 	}
 	if cfg.Agent.Role == "" {
 		cfg.Agent.Role = "leader"
 	}
-	if cfg.Agent.Permissions.FilesystemScope == "" {
-		cfg.Agent.Permissions.FilesystemScope = "/workspace"
+	if cfg.Agent.Workspace.Path == "" {
+		cfg.Agent.Workspace.Path = "/workspace"
+	}
+	if len(cfg.Agent.Permissions.FilesystemScope) == 0 {
+		cfg.Agent.Permissions.FilesystemScope = permissions.FilesystemScope{
+			{Path: cfg.Agent.Workspace.Path, Mode: permissions.ModeReadWrite},
+		}
+	}
+	// The extra workspace roots are always in scope, in their own mode.
+	for _, dir := range cfg.Agent.Workspace.ExtraDirs {
+		if !slices.ContainsFunc(cfg.Agent.Permissions.FilesystemScope, func(e permissions.ScopeEntry) bool {
+			return filepath.Clean(e.Path) == filepath.Clean(dir.Path)
+		}) {
+			cfg.Agent.Permissions.FilesystemScope = append(cfg.Agent.Permissions.FilesystemScope, dir)
+		}
+	}
+	if cfg.Agent.Telemetry.LogLevel == "" {
+		cfg.Agent.Telemetry.LogLevel = "info"
+	}
+	if cfg.Agent.Telemetry.LogFormat == "" {
+		cfg.Agent.Telemetry.LogFormat = "json"
+	}
+	if cfg.Agent.Telemetry.ForwardLevel == "" {
+		cfg.Agent.Telemetry.ForwardLevel = "warn"
+	}
+	if cfg.Agent.Telemetry.Level == "" {
+		cfg.Agent.Telemetry.Level = protocol.DefaultTelemetryLevel
+	}
+	if cfg.Agent.Shutdown.GracePeriod == 0 {
+		cfg.Agent.Shutdown.GracePeriod = defaultShutdownGracePeriod
+	}
+	if cfg.Agent.Admin.Listen == "" {
+		cfg.Agent.Admin.Listen = defaultAdminListen
 	}
+	if cfg.Agent.HealthCheck.Interval == 0 {
+		cfg.Agent.HealthCheck.Interval = defaultHealthCheckInterval
+	}
+}
 
-	return cfg, nil
+// Validate checks the effective configuration and returns every problem
+// found, each naming the offending field and where it can be set.
+func (cfg *AgentConfig) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	a := cfg.Agent
+
+	if cfg.Version != ConfigVersion {
+		add("version: unsupported config version %d (this sidecar supports %d)", cfg.Version, ConfigVersion)
+	}
+
+	if a.Name == "" {
+		add("agent.name is required (set via config file or AGENT_NAME env)")
+	} else if err := protocol.ValidateSubjectToken(a.Name); err != nil {
+		add("agent.name: %v", err)
+	}
+	if a.Team == "" {
+		add("agent.team is required (set via config file or TEAM_NAME env)")
+	} else if err := protocol.ValidateSubjectToken(a.Team); err != nil {
+		add("agent.team: %v", err)
+	}
+	if !containsString(validConfigRoles, a.Role) {
+		add("agent.role: %q is not one of %s", a.Role, strings.Join(validConfigRoles, ", "))
+	}
+	if !containsString(validConfigProviders, a.Provider) {
+		add("agent.provider: %q is not one of %s", a.Provider, strings.Join(validConfigProviders, ", "))
+	}
+
+	if err := protocol.ValidateClaudeExtraArgs(a.ClaudeExtraArgs); err != nil {
+		add("agent.claude_extra_args: %v", err)
+	}
+
+	if a.NATS.URL == "" {
+		add("agent.nats.url is required (set via config file or NATS_URL env)")
+	} else if u, err := url.Parse(a.NATS.URL); err != nil || u.Host == "" {
+		add("agent.nats.url: %q is not a valid URL (expected e.g. nats://host:4222)", a.NATS.URL)
+	} else if u.Scheme != "nats" && u.Scheme != "tls" {
+		add("agent.nats.url: scheme %q is not supported (use nats:// or tls://)", u.Scheme)
+	}
+	if st := a.NATS.Stream; st.MaxAge < 0 || st.MaxBytes < 0 || st.MaxMsgs < 0 {
+		add("agent.nats.stream: limits must not be negative")
+	}
+
+	if err := a.Permissions.FilesystemScope.Validate(); err != nil {
+		add("agent.permissions.filesystem_scope: %v", err)
+	}
+	if err := a.Workspace.ExtraDirs.Validate(); err != nil {
+		add("agent.workspace.extra_dirs: %v", err)
+	}
+
+	if a.Resources.TimeoutSeconds < 0 {
+		add("agent.resources.timeout_seconds must not be negative")
+	}
+
+	for i, s := range a.Skills.Install {
+		if s.RepoURL == "" || s.SkillName == "" {
+			add("agent.skills.install[%d]: repo_url and skill_name are required", i)
+		}
+	}
+
+	if a.Bootstrap.Script != "" {
+		if err := a.Bootstrap.Validate(); err != nil {
+			add("agent.bootstrap: %v", err)
+		}
+	}
+
+	if a.Scaffold != nil {
+		if err := a.Scaffold.Validate(); err != nil {
+			add("agent.scaffold: %v", err)
+		}
+	}
+
+	if a.MaxConcurrentRuns < 0 || a.MaxConcurrentRuns > protocol.MaxConcurrentRunsLimit {
+		add("agent.max_concurrent_runs must be between 1 and %d, or 0 for no limit", protocol.MaxConcurrentRunsLimit)
+	}
+
+	if a.Transcripts.MaxFileBytes < 0 || a.Transcripts.MaxTotalBytes < 0 {
+		add("agent.transcripts: max_file_bytes and max_total_bytes must not be negative")
+	}
+
+	if !filepath.IsAbs(a.Workspace.Path) {
+		add("agent.workspace.path: %q must be an absolute path", a.Workspace.Path)
+	}
+
+	if !containsString(validConfigLogLevels, a.Telemetry.LogLevel) {
+		add("agent.telemetry.log_level: %q is not one of %s", a.Telemetry.LogLevel, strings.Join(validConfigLogLevels, ", "))
+	}
+	if !containsString(validConfigLogFormats, a.Telemetry.LogFormat) {
+		add("agent.telemetry.log_format: %q is not one of %s", a.Telemetry.LogFormat, strings.Join(validConfigLogFormats, ", "))
+	}
+	if !containsString(validForwardLevels, a.Telemetry.ForwardLevel) {
+		add("agent.telemetry.forward_level: %q is not one of %s", a.Telemetry.ForwardLevel, strings.Join(validForwardLevels, ", "))
+	}
+	if !protocol.ValidTelemetryLevel(a.Telemetry.Level) {
+		add("agent.telemetry.level: %q is not one of %s, %s, %s", a.Telemetry.Level,
+			protocol.TelemetryMinimal, protocol.TelemetryNormal, protocol.TelemetryVerbose)
+	}
+
+	if a.Shutdown.GracePeriod < 0 {
+		add("agent.shutdown.grace_period must not be negative")
+	}
+
+	if !a.HealthCheck.Disabled && a.HealthCheck.Interval < time.Minute {
+		add("agent.health_check.interval must be at least 1m")
+	}
+
+	if a.Restart.Backoff < 0 || a.Restart.Backoff > protocol.MaxRestartBackoff {
+		add("agent.restart.backoff must be between 0 and %s", protocol.MaxRestartBackoff)
+	}
+
+	if a.Admin.Listen != adminDisabled {
+		if _, port, err := net.SplitHostPort(a.Admin.Listen); err != nil || port == "" {
+			add("agent.admin.listen: %q is not a host:port address (or %q)", a.Admin.Listen, adminDisabled)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid sidecar config:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// Redacted returns a copy of the config with secrets masked, for display.
+func (cfg *AgentConfig) Redacted() *AgentConfig {
+	out := *cfg
+	if out.Agent.NATS.Token != "" {
+		out.Agent.NATS.Token = redactedValue
+	}
+	return &out
+}
+
+// WriteEffective writes the effective configuration as YAML with secrets
+// masked. It backs the --print-config flag.
+func (cfg *AgentConfig) WriteEffective(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	return enc.Close()
+}
+
+// NewLogger builds the sidecar logger from the telemetry settings.
+func (t TelemetrySection) NewLogger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(t.LogLevel)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if t.LogFormat == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// ForwardSlogLevel returns the slog level for ForwardLevel and false when
+// forwarding is off.
+func (t TelemetrySection) ForwardSlogLevel() (slog.Level, bool) {
+	switch t.ForwardLevel {
+	case "off":
+		return 0, false
+	case "error":
+		return slog.LevelError, true
+	default:
+		return slog.LevelWarn, true
+	}
+}
+
+// containsString reports whether s is one of values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// clearConfigEnv unsets every env var LoadConfig reads so tests start clean.
+func clearConfigEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range []string{
+		"AGENT_NAME", "TEAM_NAME", "AGENT_ROLE", "AGENT_SYSTEM_PROMPT", "NATS_URL",
+		"NATS_AUTH_TOKEN", "AGENT_PROVIDER", "OPENCODE_MODEL", "CLAUDE_MODEL", "CLAUDE_PERSISTENT", "CLAUDE_EXTRA_ARGS", "AGENT_EXTRA_WORKSPACES", "AGENT_TRANSCRIPTS",
+		"WORKSPACE_PATH", "LOG_LEVEL", "LOG_FORMAT", "AGENT_FILESYSTEM_SCOPE",
+		"AGENT_SKILLS_INSTALL", "AGENT_BOOTSTRAP", "AGENT_WORKSPACE_SCAFFOLD", "AGENT_PERMISSIONS", "AGENT_MAX_CONCURRENT_RUNS", "AGENT_PLAN_APPROVAL", "AGENT_MEMORY", "AGENT_MEMORY_CONTEXT", "SHUTDOWN_GRACE_PERIOD", "AGENT_HEALTH_CHECK_INTERVAL", "AGENT_RESTART_BACKOFF", "AGENT_ADMIN_LISTEN", "LOG_FORWARD_LEVEL", "AGENT_TELEMETRY_LEVEL",
+		"NATS_STREAM_MAX_AGE", "NATS_STREAM_MAX_BYTES", "NATS_STREAM_MAX_MSGS",
+	} {
+		t.Setenv(k, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig_YAMLWithEnvOverrides(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+version: 1
+agent:
+  name: leader
+  team: myteam
+  nats:
+    url: nats://nats:4222
+  skills:
+    install:
+      - repo_url: https://github.com/org/skills
+        skill_name: review
+  workspace:
+    path: /srv/work
+  telemetry:
+    log_level: debug
+`)
+	t.Setenv("TEAM_NAME", "otherteam")
+	t.Setenv("NATS_AUTH_TOKEN", "secret")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Team != "otherteam" {
+		t.Errorf("team: got %q, want env override 'otherteam'", cfg.Agent.Team)
+	}
+	if cfg.Agent.NATS.Token != "secret" {
+		t.Errorf("nats token: got %q", cfg.Agent.NATS.Token)
+	}
+	if len(cfg.Agent.Skills.Install) != 1 || cfg.Agent.Skills.Install[0].SkillName != "review" {
+		t.Errorf("skills: got %+v", cfg.Agent.Skills.Install)
+	}
+	if cfg.Agent.Telemetry.LogLevel != "debug" || cfg.Agent.Telemetry.LogFormat != "json" {
+		t.Errorf("telemetry: got %+v", cfg.Agent.Telemetry)
+	}
+	// The default filesystem scope follows the workspace path.
+	want := permissions.FilesystemScope{{Path: "/srv/work", Mode: permissions.ModeReadWrite}}
+	if len(cfg.Agent.Permissions.FilesystemScope) != 1 || cfg.Agent.Permissions.FilesystemScope[0] != want[0] {
+		t.Errorf("filesystem scope: got %+v", cfg.Agent.Permissions.FilesystemScope)
+	}
+}
+
+func TestLoadConfig_EnvOnlyDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Version != ConfigVersion {
+		t.Errorf("version: got %d, want %d", cfg.Version, ConfigVersion)
+	}
+	if cfg.Agent.Provider != "claude" || cfg.Agent.Role != "leader" {
+		t.Errorf("defaults: provider=%q role=%q", cfg.Agent.Provider, cfg.Agent.Role)
+	}
+	if cfg.Agent.Workspace.Path != "/workspace" {
+		t.Errorf("workspace path: got %q", cfg.Agent.Workspace.Path)
+	}
+	if cfg.Agent.Shutdown.GracePeriod != defaultShutdownGracePeriod {
+		t.Errorf("grace period: got %v", cfg.Agent.Shutdown.GracePeriod)
+	}
+	if cfg.Agent.Admin.Listen != defaultAdminListen {
+		t.Errorf("admin listen: got %q", cfg.Agent.Admin.Listen)
+	}
+}
+
+func TestLoadConfig_ShutdownGracePeriod(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+agent:
+  name: leader
+  team: myteam
+  nats:
+    url: nats://nats:4222
+  shutdown:
+    grace_period: 40s
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Shutdown.GracePeriod != 40*time.Second {
+		t.Errorf("grace period from file: got %v", cfg.Agent.Shutdown.GracePeriod)
+	}
+
+	t.Setenv("SHUTDOWN_GRACE_PERIOD", "5s")
+	if cfg, err = LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Shutdown.GracePeriod != 5*time.Second {
+		t.Errorf("grace period from env: got %v", cfg.Agent.Shutdown.GracePeriod)
+	}
+
+	t.Setenv("SHUTDOWN_GRACE_PERIOD", "soon")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "SHUTDOWN_GRACE_PERIOD") {
+		t.Errorf("expected SHUTDOWN_GRACE_PERIOD error, got %v", err)
+	}
+}
+
+func TestLoadConfig_HealthCheck(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+agent:
+  name: leader
+  team: myteam
+  nats:
+    url: nats://nats:4222
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.HealthCheck.Interval != defaultHealthCheckInterval || cfg.Agent.HealthCheck.Disabled {
+		t.Errorf("default health check: got %+v", cfg.Agent.HealthCheck)
+	}
+
+	t.Setenv("AGENT_HEALTH_CHECK_INTERVAL", "30m")
+	if cfg, err = LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.HealthCheck.Interval != 30*time.Minute {
+		t.Errorf("interval from env: got %v", cfg.Agent.HealthCheck.Interval)
+	}
+
+	t.Setenv("AGENT_HEALTH_CHECK_INTERVAL", "off")
+	if cfg, err = LoadConfig(path); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Agent.HealthCheck.Disabled {
+		t.Error("AGENT_HEALTH_CHECK_INTERVAL=off should disable the health check")
+	}
+
+	t.Setenv("AGENT_HEALTH_CHECK_INTERVAL", "10s")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "agent.health_check.interval") {
+		t.Errorf("expected interval error, got %v", err)
+	}
+}
+
+func TestLoadConfig_RejectsUnknownKeys(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+agent:
+  name: leader
+  team: myteam
+  nats:
+    url: nats://nats:4222
+  permisions:
+    allowed_tools: [Read]
+`)
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+	if !strings.Contains(err.Error(), "permisions") {
+		t.Errorf("error should name the unknown key, got: %v", err)
+	}
+}
+
+func TestLoadConfig_ReportsAllProblems(t *testing.T) {
+	clearConfigEnv(t)
+	path := writeConfigFile(t, `
+version: 2
+agent:
+  team: my.team
+  role: boss
+  provider: gpt
+  nats:
+    url: http://nats:4222
+  workspace:
+    path: relative/dir
+  telemetry:
+    log_level: verbose
+    forward_level: info
+  admin:
+    listen: "9091"
+  skills:
+    install:
+      - repo_url: https://github.com/org/skills
+`)
+
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"unsupported config version 2",
+		"agent.name is required",
+		"agent.team",
+		"agent.role",
+		"agent.provider",
+		"agent.nats.url: scheme \"http\"",
+		"agent.workspace.path",
+		"agent.telemetry.log_level",
+		"agent.telemetry.forward_level",
+		"agent.admin.listen",
+		"agent.skills.install[0]",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfig_InvalidSkillsEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_SKILLS_INSTALL", "not json")
+
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_SKILLS_INSTALL") {
+		t.Errorf("expected AGENT_SKILLS_INSTALL error, got %v", err)
+	}
+}
+
+func TestLoadConfig_BootstrapEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_BOOTSTRAP", `{"script":"npm ci","timeout_seconds":120,"failure_policy":"block"}`)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := protocol.BootstrapConfig{Script: "npm ci", TimeoutSeconds: 120, FailurePolicy: "block"}
+	if cfg.Agent.Bootstrap != want {
+		t.Errorf("bootstrap = %+v, want %+v", cfg.Agent.Bootstrap, want)
+	}
+
+	t.Setenv("AGENT_BOOTSTRAP", `{"script":"npm ci","failure_policy":"ignore"}`)
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.bootstrap") {
+		t.Errorf("expected agent.bootstrap error, got %v", err)
+	}
+
+	t.Setenv("AGENT_BOOTSTRAP", "not json")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_BOOTSTRAP") {
+		t.Errorf("expected AGENT_BOOTSTRAP error, got %v", err)
+	}
+}
+
+func TestLoadConfig_WorkspaceScaffoldEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_WORKSPACE_SCAFFOLD", `{"template":"git-repo","files":[{"path":"README.md","content":"# myteam\n"}],"git_init":true}`)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if s := cfg.Agent.Scaffold; s == nil || s.Template != "git-repo" || !s.GitInit || len(s.Files) != 1 {
+		t.Errorf("scaffold = %+v", s)
+	}
+
+	t.Setenv("AGENT_WORKSPACE_SCAFFOLD", `{"template":"git-repo","files":[{"path":"../escape","content":""}]}`)
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.scaffold") {
+		t.Errorf("expected agent.scaffold error, got %v", err)
+	}
+}
+
+func TestLoadConfig_RestartBackoffEnv(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_RESTART_BACKOFF", "15s")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Restart.Backoff != 15*time.Second {
+		t.Errorf("restart backoff = %s, want 15s", cfg.Agent.Restart.Backoff)
+	}
+
+	t.Setenv("AGENT_RESTART_BACKOFF", "1h")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.restart.backoff") {
+		t.Errorf("expected agent.restart.backoff error, got %v", err)
+	}
+}
+
+func TestLoadConfig_MaxConcurrentRuns(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.MaxConcurrentRuns != 0 {
+		t.Errorf("default max_concurrent_runs = %d, want 0 (no limit)", cfg.Agent.MaxConcurrentRuns)
+	}
+
+	t.Setenv("AGENT_MAX_CONCURRENT_RUNS", "3")
+	cfg, err = LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.MaxConcurrentRuns != 3 {
+		t.Errorf("max_concurrent_runs = %d, want 3", cfg.Agent.MaxConcurrentRuns)
+	}
+
+	t.Setenv("AGENT_MAX_CONCURRENT_RUNS", "1000")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.max_concurrent_runs") {
+		t.Errorf("expected agent.max_concurrent_runs error, got %v", err)
+	}
+
+	t.Setenv("AGENT_MAX_CONCURRENT_RUNS", "many")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_MAX_CONCURRENT_RUNS") {
+		t.Errorf("expected AGENT_MAX_CONCURRENT_RUNS error, got %v", err)
+	}
+}
+
+func TestLoadConfig_TelemetryLevel(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Telemetry.Level != protocol.TelemetryNormal {
+		t.Errorf("default telemetry level = %q, want normal", cfg.Agent.Telemetry.Level)
+	}
+
+	t.Setenv("AGENT_TELEMETRY_LEVEL", "minimal")
+	if cfg, err = LoadConfig(""); err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Agent.Telemetry.Level != protocol.TelemetryMinimal {
+		t.Errorf("telemetry level = %q, want minimal", cfg.Agent.Telemetry.Level)
+	}
+
+	t.Setenv("AGENT_TELEMETRY_LEVEL", "chatty")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.telemetry.level") {
+		t.Errorf("expected agent.telemetry.level error, got %v", err)
+	}
+}
+
+func TestLoadConfig_PlanApproval(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_PLAN_APPROVAL", "true")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Agent.PlanApproval {
+		t.Error("plan_approval should be enabled")
+	}
+
+	t.Setenv("AGENT_PLAN_APPROVAL", "sometimes")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_PLAN_APPROVAL") {
+		t.Errorf("expected AGENT_PLAN_APPROVAL error, got %v", err)
+	}
+}
+
+func TestLoadConfig_ClaudePersistent(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("CLAUDE_PERSISTENT", "true")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Agent.ClaudePersistent {
+		t.Error("claude_persistent should be enabled")
+	}
+
+	t.Setenv("CLAUDE_PERSISTENT", "always")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "CLAUDE_PERSISTENT") {
+		t.Errorf("expected CLAUDE_PERSISTENT error, got %v", err)
+	}
+}
+
+func TestLoadConfig_ClaudeExtraArgs(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("CLAUDE_EXTRA_ARGS", `["--max-thinking-tokens","8000","--add-dir=/data"]`)
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if got := strings.Join(cfg.Agent.ClaudeExtraArgs, " "); got != "--max-thinking-tokens 8000 --add-dir=/data" {
+		t.Errorf("claude_extra_args: got %q", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	cfg.Agent.ClaudeExtraArgs = []string{"--permission-mode", "default"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "agent.claude_extra_args") {
+		t.Errorf("expected agent.claude_extra_args error, got %v", err)
+	}
+
+	t.Setenv("CLAUDE_EXTRA_ARGS", "--max-turns 5")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "CLAUDE_EXTRA_ARGS") {
+		t.Errorf("expected CLAUDE_EXTRA_ARGS error, got %v", err)
+	}
+}
+
+func TestLoadConfig_ExtraWorkspaces(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_EXTRA_WORKSPACES", "/workspaces/docs:ro,/workspaces/lib:rw")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if len(cfg.Agent.Workspace.ExtraDirs) != 2 {
+		t.Fatalf("extra_dirs: got %+v", cfg.Agent.Workspace.ExtraDirs)
+	}
+	gate := permissions.NewGate(permissions.PermissionConfig{
+		AllowedTools:    []string{"Read", "Write"},
+		FilesystemScope: cfg.Agent.Permissions.FilesystemScope,
+	})
+	for _, tc := range []struct {
+		tool, path string
+		allowed    bool
+	}{
+		{"Write", "/workspace/main.go", true},
+		{"Read", "/workspaces/docs/guide.md", true},
+		{"Write", "/workspaces/docs/guide.md", false},
+		{"Write", "/workspaces/lib/lib.go", true},
+		{"Read", "/workspaces/other/x", false},
+	} {
+		if d := gate.Evaluate(tc.tool, "", []string{tc.path}); d.Allowed != tc.allowed {
+			t.Errorf("%s %s: allowed %v, want %v (%s)", tc.tool, tc.path, d.Allowed, tc.allowed, d.Reason)
+		}
+	}
+
+	t.Setenv("AGENT_EXTRA_WORKSPACES", "docs:ro")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_EXTRA_WORKSPACES") {
+		t.Errorf("expected AGENT_EXTRA_WORKSPACES error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Transcripts(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_TRANSCRIPTS", "1")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Agent.Transcripts.Enabled {
+		t.Error("transcripts should be enabled")
+	}
+
+	cfg.Agent.Transcripts.MaxFileBytes = -1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "agent.transcripts") {
+		t.Errorf("expected agent.transcripts error, got %v", err)
+	}
+}
+
+func TestLoadConfig_NATSStream(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("NATS_STREAM_MAX_AGE", "6h")
+	t.Setenv("NATS_STREAM_MAX_BYTES", "512MB")
+	t.Setenv("NATS_STREAM_MAX_MSGS", "10000")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := StreamSection{MaxAge: 6 * time.Hour, MaxBytes: 512 << 20, MaxMsgs: 10000}
+	if cfg.Agent.NATS.Stream != want {
+		t.Errorf("stream = %+v, want %+v", cfg.Agent.NATS.Stream, want)
+	}
+
+	t.Setenv("NATS_STREAM_MAX_MSGS", "-1")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "agent.nats.stream") {
+		t.Errorf("expected agent.nats.stream error, got %v", err)
+	}
+	t.Setenv("NATS_STREAM_MAX_BYTES", "lots")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "NATS_STREAM_MAX_BYTES") {
+		t.Errorf("expected NATS_STREAM_MAX_BYTES error, got %v", err)
+	}
+}
+
+func TestLoadConfig_Memory(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("AGENT_MEMORY", "1")
+	t.Setenv("AGENT_MEMORY_CONTEXT", "The API is in Go.")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if !cfg.Agent.Memory || cfg.Agent.MemoryContext != "The API is in Go." {
+		t.Errorf("memory: got %v, %q", cfg.Agent.Memory, cfg.Agent.MemoryContext)
+	}
+
+	t.Setenv("AGENT_MEMORY", "maybe")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "AGENT_MEMORY") {
+		t.Errorf("expected AGENT_MEMORY error, got %v", err)
+	}
+}
+
+func TestWriteEffective_RedactsSecrets(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("AGENT_NAME", "leader")
+	t.Setenv("TEAM_NAME", "myteam")
+	t.Setenv("NATS_URL", "nats://nats:4222")
+	t.Setenv("NATS_AUTH_TOKEN", "supersecret")
+
+	cfg, err := LoadConfig("")
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	var b strings.Builder
+	if err := cfg.WriteEffective(&b); err != nil {
+		t.Fatalf("WriteEffective: %v", err)
+	}
+	out := b.String()
+	if strings.Contains(out, "supersecret") {
+		t.Error("printed config must not contain the NATS token")
+	}
+	if !strings.Contains(out, redactedValue) {
+		t.Error("printed config should show the token as redacted")
+	}
+	if cfg.Agent.NATS.Token != "supersecret" {
+		t.Error("redaction must not modify the loaded config")
+	}
+
+	// The printed config is itself a valid config file.
+	var round AgentConfig
+	if err := yaml.Unmarshal([]byte(out), &round); err != nil {
+		t.Fatalf("printed config is not valid YAML: %v", err)
+	}
+	if round.Agent.Name != "leader" || round.Version != ConfigVersion {
+		t.Errorf("round trip: got %+v", round)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// writeConfigFiles writes the files of a config_update message into the
+// workspace. Every path is validated before anything is written, so a bad
+// update leaves the workspace untouched.
+func writeConfigFiles(workDir string, files []protocol.ConfigFile) error {
+	for _, f := range files {
+		if err := protocol.ValidateConfigFilePath(f.Path); err != nil {
+			return err
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(workDir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(path, []byte(f.Content), 0644); err != nil {
+			return fmt.Errorf("writing %s: %w", f.Path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestWriteConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := []protocol.ConfigFile{
+		{Path: ".claude/CLAUDE.md", Content: "# Leader"},
+		{Path: ".claude/agents/backend.md", Content: "# Backend"},
+	}
+	if err := writeConfigFiles(dir, files); err != nil {
+		t.Fatalf("writeConfigFiles: %v", err)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(dir, f.Path))
+		if err != nil {
+			t.Fatalf("reading %s: %v", f.Path, err)
+		}
+		if string(data) != f.Content {
+			t.Errorf("%s = %q, want %q", f.Path, data, f.Content)
+		}
+	}
+}
+
+func TestWriteConfigFiles_RejectsPath(t *testing.T) {
+	dir := t.TempDir()
+	files := []protocol.ConfigFile{
+		{Path: ".claude/agents/backend.md", Content: "# Backend"},
+		{Path: ".claude/agents/../../.mcp.json", Content: "{}"},
+	}
+	if err := writeConfigFiles(dir, files); err == nil {
+		t.Fatal("expected an error for a path outside the instructions files")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".claude", "agents", "backend.md")); !os.IsNotExist(err) {
+		t.Errorf("valid file written despite the rejected update: %v", err)
+	}
+}
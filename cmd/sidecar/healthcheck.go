@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Free disk space, as a percentage of the workspace's filesystem, under which
+// the health check warns and fails.
+const (
+	diskWarningPercent = 10
+	diskErrorPercent   = 2
+)
+
+// defaultAnthropicBaseURL is the API the auth check calls when
+// ANTHROPIC_BASE_URL is not set.
+const defaultAnthropicBaseURL = "https://api.anthropic.com"
+
+// healthChecker periodically checks that the agent's CLI still runs, its
+// credentials are still accepted and its workspace disk is not full, so that
+// a long-running team reports drift before chats start failing.
+type healthChecker struct {
+	provider string
+	workDir  string
+	// baseURL, apiKey and oauthToken are the Anthropic credentials the
+	// auth check verifies.
+	baseURL    string
+	apiKey     string
+	oauthToken string
+	httpClient *http.Client
+	publish    func(protocol.HealthCheckPayload) error
+	agentName  string
+
+	degraded bool
+}
+
+// newHealthChecker returns a healthChecker for the agent of cfg that
+// publishes its results with publish.
+func newHealthChecker(cfg *AgentConfig, publish func(protocol.HealthCheckPayload) error) *healthChecker {
+	baseURL := os.Getenv("ANTHROPIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	return &healthChecker{
+		provider:   cfg.Agent.Provider,
+		workDir:    cfg.Agent.Workspace.Path,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     os.Getenv("ANTHROPIC_API_KEY"),
+		oauthToken: os.Getenv("CLAUDE_CODE_OAUTH_TOKEN"),
+		httpClient: &http.Client{Timeout: versionCommandTimeout},
+		publish:    publish,
+		agentName:  cfg.Agent.Name,
+	}
+}
+
+// run checks every interval until ctx is done. The first check waits a full
+// interval, since container validation covers the agent's startup.
+func (h *healthChecker) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.report(ctx)
+		}
+	}
+}
+
+// report runs the checks and publishes them when the agent is degraded, or
+// when it just recovered.
+func (h *healthChecker) report(ctx context.Context) {
+	checks := h.checks(ctx)
+	summary, errCount := summarizeValidation(checks)
+	degraded := errCount > 0
+	recovered := h.degraded && !degraded
+	h.degraded = degraded
+
+	switch {
+	case degraded:
+		slog.Warn("health check found the agent degraded", "summary", summary)
+	case recovered:
+		slog.Info("health check passed again", "summary", summary)
+	default:
+		slog.Debug("health check passed", "summary", summary)
+		return
+	}
+	for _, c := range checks {
+		slog.Info("health check", "name", c.Name, "status", c.Status, "message", c.Message)
+	}
+	if err := h.publish(protocol.HealthCheckPayload{
+		AgentName: h.agentName,
+		Checks:    checks,
+		Summary:   summary,
+		Degraded:  degraded,
+	}); err != nil {
+		slog.Error("failed to publish health check", "error", err)
+	}
+}
+
+// checks runs the health checks of the agent's provider.
+func (h *healthChecker) checks(ctx context.Context) []protocol.ValidationCheck {
+	cli := "claude"
+	if h.provider == "opencode" {
+		cli = "opencode"
+	}
+	checks := []protocol.ValidationCheck{checkCLI(ctx, cli)}
+	if h.provider != "opencode" {
+		checks = append(checks, h.checkAuth(ctx))
+	}
+	return append(checks, checkDisk(h.workDir))
+}
+
+// checkCLI checks that the agent's CLI is installed and still executes.
+func checkCLI(ctx context.Context, name string) protocol.ValidationCheck {
+	check := protocol.ValidationCheck{Name: "cli"}
+	if _, err := exec.LookPath(name); err != nil {
+		check.Status = protocol.ValidationError
+		check.Message = fmt.Sprintf("%s is not installed: %v", name, err)
+		return check
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, "--version").CombinedOutput()
+	if err != nil {
+		check.Status = protocol.ValidationError
+		check.Message = fmt.Sprintf("%s --version failed: %v: %s", name, err, truncateTail(string(out), 200))
+		return check
+	}
+	check.Status = protocol.ValidationOK
+	check.Message = fmt.Sprintf("%s %s runs", name, parseToolVersion(string(out)))
+	return check
+}
+
+// checkAuth checks that the Anthropic API still accepts the agent's API key.
+// An OAuth token cannot be verified without spending tokens, so it is only
+// checked to be set. Failures to reach the API are warnings, since the key
+// may still be valid.
+func (h *healthChecker) checkAuth(ctx context.Context) protocol.ValidationCheck {
+	check := protocol.ValidationCheck{Name: "auth"}
+	switch {
+	case h.apiKey == "" && h.oauthToken == "":
+		check.Status = protocol.ValidationError
+		check.Message = "neither ANTHROPIC_API_KEY nor CLAUDE_CODE_OAUTH_TOKEN is set"
+		return check
+	case h.apiKey == "":
+		check.Status = protocol.ValidationOK
+		check.Message = "OAuth token set (not verified)"
+		return check
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+"/v1/models?limit=1", nil)
+	if err != nil {
+		check.Status = protocol.ValidationWarning
+		check.Message = fmt.Sprintf("could not verify the API key: %v", err)
+		return check
+	}
+	req.Header.Set("x-api-key", h.apiKey)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	resp, err := h.httpClient.Do(req)
+	if err != nil {
+		check.Status = protocol.ValidationWarning
+		check.Message = fmt.Sprintf("could not verify the API key: %v", err)
+		return check
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		check.Status = protocol.ValidationError
+		check.Message = fmt.Sprintf("the API key was rejected (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		check.Status = protocol.ValidationWarning
+		check.Message = fmt.Sprintf("could not verify the API key (HTTP %d)", resp.StatusCode)
+	default:
+		check.Status = protocol.ValidationOK
+		check.Message = "API key accepted"
+	}
+	return check
+}
+
+// checkDisk checks the free space of the filesystem holding the workspace.
+func checkDisk(path string) protocol.ValidationCheck {
+	check := protocol.ValidationCheck{Name: "disk"}
+	free, total, err := diskSpace(path)
+	if err != nil {
+		check.Status = protocol.ValidationWarning
+		check.Message = fmt.Sprintf("could not read free disk space: %v", err)
+		return check
+	}
+	if total == 0 {
+		check.Status = protocol.ValidationWarning
+		check.Message = "could not read free disk space: empty filesystem"
+		return check
+	}
+	percent := float64(free) / float64(total) * 100
+	check.Message = fmt.Sprintf("%.1f GiB free of %.1f GiB (%.0f%%)", float64(free)/(1<<30), float64(total)/(1<<30), percent)
+	switch {
+	case percent < diskErrorPercent:
+		check.Status = protocol.ValidationError
+	case percent < diskWarningPercent:
+		check.Status = protocol.ValidationWarning
+	default:
+		check.Status = protocol.ValidationOK
+	}
+	return check
+}
+
+// publishHealthCheck publishes a health check's results to the team's
+// activity channel.
+func publishHealthCheck(client *agentNats.Client, agentName, teamName string, payload protocol.HealthCheckPayload) error {
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeHealthCheck, payload)
+	if err != nil {
+		return err
+	}
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		return err
+	}
+	return client.Publish(subject, msg)
+}
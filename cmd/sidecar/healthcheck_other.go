@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "errors"
+
+// diskSpace is not supported on this platform, so the disk check only warns.
+func diskSpace(_ string) (free, total uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// useFakeCLI puts a claude script running body first in PATH.
+func useFakeCLI(t *testing.T, body string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "claude"), []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestCheckCLI(t *testing.T) {
+	useFakeCLI(t, `echo "1.0.83 (Claude Code)"`)
+	if c := checkCLI(t.Context(), "claude"); c.Status != protocol.ValidationOK || c.Message != "claude 1.0.83 runs" {
+		t.Errorf("working CLI: got %+v", c)
+	}
+
+	useFakeCLI(t, `echo "cannot find module" >&2; exit 1`)
+	if c := checkCLI(t.Context(), "claude"); c.Status != protocol.ValidationError {
+		t.Errorf("broken CLI: got %+v", c)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if c := checkCLI(t.Context(), "claude"); c.Status != protocol.ValidationError {
+		t.Errorf("missing CLI: got %+v", c)
+	}
+}
+
+func TestCheckAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("x-api-key") {
+		case "good":
+			w.WriteHeader(http.StatusOK)
+		case "flaky":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		oauthToken string
+		want       protocol.ValidationCheckStatus
+	}{
+		{"valid key", "good", "", protocol.ValidationOK},
+		{"revoked key", "revoked", "", protocol.ValidationError},
+		{"api unavailable", "flaky", "", protocol.ValidationWarning},
+		{"oauth token", "", "token", protocol.ValidationOK},
+		{"no credentials", "", "", protocol.ValidationError},
+	}
+	for _, tt := range tests {
+		h := &healthChecker{baseURL: srv.URL, apiKey: tt.apiKey, oauthToken: tt.oauthToken, httpClient: srv.Client()}
+		if c := h.checkAuth(t.Context()); c.Status != tt.want {
+			t.Errorf("%s: got %+v, want %s", tt.name, c, tt.want)
+		}
+	}
+}
+
+func TestCheckDisk(t *testing.T) {
+	if c := checkDisk(t.TempDir()); c.Status == "" || c.Message == "" {
+		t.Errorf("workspace disk: got %+v", c)
+	}
+	if c := checkDisk(filepath.Join(t.TempDir(), "missing")); c.Status != protocol.ValidationWarning {
+		t.Errorf("missing path: got %+v", c)
+	}
+}
+
+func TestHealthChecker_PublishesDegradationAndRecovery(t *testing.T) {
+	useFakeCLI(t, `echo "1.0.83"`)
+	var published []protocol.HealthCheckPayload
+	h := &healthChecker{
+		provider:   "claude",
+		workDir:    t.TempDir(),
+		oauthToken: "token",
+		agentName:  "leader",
+		publish: func(p protocol.HealthCheckPayload) error {
+			published = append(published, p)
+			return nil
+		},
+	}
+	ctx := context.Background()
+
+	h.report(ctx) // Healthy: nothing to report.
+	h.oauthToken = ""
+	h.report(ctx) // Lost its credentials.
+	h.report(ctx) // Still degraded, reported again.
+	h.oauthToken = "token"
+	h.report(ctx) // Recovered.
+	h.report(ctx)
+
+	if len(published) != 3 {
+		t.Fatalf("published: got %d, want 3: %+v", len(published), published)
+	}
+	if !published[0].Degraded || !published[1].Degraded || published[2].Degraded {
+		t.Errorf("degraded flags: got %v, %v, %v", published[0].Degraded, published[1].Degraded, published[2].Degraded)
+	}
+	if published[0].AgentName != "leader" || len(published[0].Checks) != 3 {
+		t.Errorf("payload: got %+v", published[0])
+	}
+}
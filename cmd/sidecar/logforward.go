@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Log forwarding limits: records are batched for logForwardInterval and at
+// most logForwardMaxPerBatch are sent per batch; the rest are counted as
+// dropped so a log storm cannot flood NATS or the Activity panel.
+const (
+	logForwardInterval    = 2 * time.Second
+	logForwardMaxPerBatch = 50
+)
+
+// logSink buffers forwarded log records and publishes them in batches.
+type logSink struct {
+	agentName string
+	publish   func(protocol.AgentLogPayload) error
+
+	mu      sync.Mutex
+	entries []protocol.AgentLogEntry
+	dropped int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newLogSink creates a sink that publishes batches through publish.
+func newLogSink(agentName string, publish func(protocol.AgentLogPayload) error) *logSink {
+	return &logSink{
+		agentName: agentName,
+		publish:   publish,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// add buffers an entry, or counts it as dropped when the batch is full.
+func (s *logSink) add(e protocol.AgentLogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= logForwardMaxPerBatch {
+		s.dropped++
+		return
+	}
+	s.entries = append(s.entries, e)
+}
+
+// flush publishes the buffered batch, if any. Publish errors are not logged
+// through slog, which would feed them back into the sink.
+func (s *logSink) flush() {
+	s.mu.Lock()
+	entries, dropped := s.entries, s.dropped
+	s.entries, s.dropped = nil, 0
+	s.mu.Unlock()
+
+	if len(entries) == 0 && dropped == 0 {
+		return
+	}
+	_ = s.publish(protocol.AgentLogPayload{
+		AgentName: s.agentName,
+		Entries:   entries,
+		Dropped:   dropped,
+	})
+}
+
+// run flushes the sink every logForwardInterval until Close is called.
+func (s *logSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(logForwardInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// Close stops the flush loop after sending any remaining records.
+func (s *logSink) Close() {
+	close(s.stop)
+	<-s.done
+}
+
+// logForwarder is a slog.Handler that passes every record to the wrapped
+// handler and additionally forwards records at or above level to a logSink.
+type logForwarder struct {
+	inner  slog.Handler
+	level  slog.Level
+	sink   *logSink
+	attrs  []slog.Attr // from WithAttrs, keys already qualified
+	groups []string
+}
+
+// newLogForwarder wraps inner so records at or above level are forwarded.
+func newLogForwarder(inner slog.Handler, level slog.Level, sink *logSink) *logForwarder {
+	return &logForwarder{inner: inner, level: level, sink: sink}
+}
+
+// Enabled implements slog.Handler.
+func (h *logForwarder) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level || h.inner.Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *logForwarder) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.level {
+		h.sink.add(h.entry(r))
+	}
+	if h.inner.Enabled(ctx, r.Level) {
+		return h.inner.Handle(ctx, r)
+	}
+	return nil
+}
+
+// entry converts a record, including attributes from WithAttrs, into a
+// forwarded log entry.
+func (h *logForwarder) entry(r slog.Record) protocol.AgentLogEntry {
+	e := protocol.AgentLogEntry{
+		Time:    r.Time,
+		Level:   r.Level.String(),
+		Message: r.Message,
+	}
+	add := func(a slog.Attr) {
+		if e.Attrs == nil {
+			e.Attrs = make(map[string]interface{})
+		}
+		v := a.Value.Resolve()
+		if err, ok := v.Any().(error); ok {
+			e.Attrs[a.Key] = err.Error()
+			return
+		}
+		e.Attrs[a.Key] = v.Any()
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		add(h.qualify(a))
+		return true
+	})
+	return e
+}
+
+// qualify prefixes an attribute key with the open groups, e.g. "skill.name".
+func (h *logForwarder) qualify(a slog.Attr) slog.Attr {
+	if len(h.groups) == 0 {
+		return a
+	}
+	return slog.Attr{Key: strings.Join(h.groups, ".") + "." + a.Key, Value: a.Value}
+}
+
+// WithAttrs implements slog.Handler.
+func (h *logForwarder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := *h
+	out.inner = h.inner.WithAttrs(attrs)
+	out.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		out.attrs = append(out.attrs, h.qualify(a))
+	}
+	return &out
+}
+
+// WithGroup implements slog.Handler.
+func (h *logForwarder) WithGroup(name string) slog.Handler {
+	out := *h
+	out.inner = h.inner.WithGroup(name)
+	out.groups = append(append([]string{}, h.groups...), name)
+	return &out
+}
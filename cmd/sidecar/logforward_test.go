@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// capturedLogs records the batches published by a logSink.
+type capturedLogs struct {
+	mu      sync.Mutex
+	batches []protocol.AgentLogPayload
+}
+
+func (c *capturedLogs) publish(p protocol.AgentLogPayload) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, p)
+	return nil
+}
+
+func newTestForwarder(level slog.Level) (*slog.Logger, *logSink, *capturedLogs, *bytes.Buffer) {
+	captured := &capturedLogs{}
+	sink := newLogSink("leader", captured.publish)
+	var out bytes.Buffer
+	inner := slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})
+	return slog.New(newLogForwarder(inner, level, sink)), sink, captured, &out
+}
+
+func TestLogForwarder_ForwardsWarnAndAbove(t *testing.T) {
+	logger, sink, captured, out := newTestForwarder(slog.LevelWarn)
+
+	logger.Info("skills installed")
+	logger.With("component", "skills").WithGroup("skill").Warn("skill install failed",
+		"name", "review", "error", errors.New("exit status 1"))
+	logger.Error("bridge stopped")
+	sink.flush()
+
+	// Everything still reaches the container log.
+	if n := strings.Count(out.String(), "\n"); n != 3 {
+		t.Errorf("container log lines: got %d, want 3", n)
+	}
+
+	if len(captured.batches) != 1 {
+		t.Fatalf("batches: got %d, want 1", len(captured.batches))
+	}
+	b := captured.batches[0]
+	if b.AgentName != "leader" || len(b.Entries) != 2 {
+		t.Fatalf("batch: got %+v", b)
+	}
+	warn := b.Entries[0]
+	if warn.Level != "WARN" || warn.Message != "skill install failed" {
+		t.Errorf("entry: got %+v", warn)
+	}
+	if warn.Attrs["component"] != "skills" || warn.Attrs["skill.name"] != "review" || warn.Attrs["skill.error"] != "exit status 1" {
+		t.Errorf("attrs: got %v", warn.Attrs)
+	}
+	if b.Entries[1].Level != "ERROR" {
+		t.Errorf("second entry level: got %q", b.Entries[1].Level)
+	}
+}
+
+func TestLogForwarder_ErrorLevelOnly(t *testing.T) {
+	logger, sink, captured, _ := newTestForwarder(slog.LevelError)
+	logger.Warn("not forwarded")
+	sink.flush()
+	if len(captured.batches) != 0 {
+		t.Errorf("expected no batch for WARN at error level, got %+v", captured.batches)
+	}
+}
+
+func TestLogSink_RateLimitCountsDropped(t *testing.T) {
+	logger, sink, captured, _ := newTestForwarder(slog.LevelWarn)
+	for i := 0; i < logForwardMaxPerBatch+7; i++ {
+		logger.Warn("storm")
+	}
+	sink.flush()
+	sink.flush() // an empty sink publishes nothing
+
+	if len(captured.batches) != 1 {
+		t.Fatalf("batches: got %d, want 1", len(captured.batches))
+	}
+	if got := captured.batches[0]; len(got.Entries) != logForwardMaxPerBatch || got.Dropped != 7 {
+		t.Errorf("batch: %d entries, %d dropped", len(got.Entries), got.Dropped)
+	}
+}
+
+func TestLogSink_CloseFlushes(t *testing.T) {
+	logger, sink, captured, _ := newTestForwarder(slog.LevelWarn)
+	go sink.run()
+	logger.Warn("last words")
+	sink.Close()
+
+	if len(captured.batches) != 1 || captured.batches[0].Entries[0].Message != "last words" {
+		t.Errorf("expected final flush on Close, got %+v", captured.batches)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/helmcode/agent-crew/internal/claude"
 	agentNats "github.com/helmcode/agent-crew/internal/nats"
@@ -23,30 +25,42 @@ import (
 )
 
 func main() {
+	printConfig := flag.Bool("print-config", false, "print the effective merged configuration (secrets redacted) and exit")
+	flag.Parse()
+
+	if flag.Arg(0) == toolsMcpCommand {
+		runToolsMcp()
+		return
+	}
+
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 	slog.SetDefault(logger)
 
-	slog.Info("starting agent sidecar")
+	startedAt := time.Now()
 
 	// 1. Load config.
-	configPath := os.Getenv("AGENT_CONFIG_PATH")
-	if configPath == "" {
-		configPath = "/etc/agentcrew/agent.yaml"
-	}
-
-	// If config file doesn't exist, rely entirely on env vars.
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		configPath = ""
+	cfg, err := LoadConfig(configFilePath())
+	if *printConfig {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := cfg.WriteEffective(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
 	}
-
-	cfg, err := LoadConfig(configPath)
 	if err != nil {
 		slog.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
 
+	slog.SetDefault(cfg.Agent.Telemetry.NewLogger(os.Stdout))
+	slog.Info("starting agent sidecar", "config_version", cfg.Version)
+
 	slog.Info("config loaded",
 		"agent", cfg.Agent.Name,
 		"team", cfg.Agent.Team,
@@ -55,12 +69,19 @@ func main() {
 		"nats_url", cfg.Agent.NATS.URL,
 	)
 
+	// A container restarted after exiting waits before starting again, so
+	// that a crash loop does not hammer NATS and the model API.
+	if delay := restartDelay(protocol.RestartStateDir, cfg.Agent.Restart.Backoff, time.Now()); delay > 0 {
+		slog.Info("container restarted, backing off", "delay", delay)
+		time.Sleep(delay)
+	}
+
 	// 2. Connect to NATS.
 	natsConfig := agentNats.DefaultConfig(
 		cfg.Agent.NATS.URL,
 		cfg.Agent.Team+"-"+cfg.Agent.Name,
 	)
-	natsConfig.Token = os.Getenv("NATS_AUTH_TOKEN")
+	natsConfig.Token = cfg.Agent.NATS.Token
 	natsClient, err := agentNats.Connect(natsConfig)
 	if err != nil {
 		slog.Error("failed to connect to nats", "error", err)
@@ -68,25 +89,35 @@ func main() {
 	}
 	defer natsClient.Close()
 
+	// Forward WARN/ERROR sidecar logs to the Activity panel.
+	var logs *logSink
+	if level, ok := cfg.Agent.Telemetry.ForwardSlogLevel(); ok {
+		logs = newLogSink(cfg.Agent.Name, func(p protocol.AgentLogPayload) error {
+			return publishAgentLog(natsClient, cfg.Agent.Name, cfg.Agent.Team, p)
+		})
+		go logs.run()
+		slog.SetDefault(slog.New(newLogForwarder(slog.Default().Handler(), level, logs)))
+	}
+
 	// Ensure JetStream stream for the team.
 	ctx := context.Background()
-	if err := natsClient.EnsureStream(ctx, cfg.Agent.Team); err != nil {
+	stream := cfg.Agent.NATS.Stream
+	limits := agentNats.StreamLimits{MaxAge: stream.MaxAge, MaxBytes: stream.MaxBytes, MaxMsgs: stream.MaxMsgs}
+	if err := natsClient.EnsureStream(ctx, cfg.Agent.Team, limits); err != nil {
 		slog.Warn("failed to ensure jetstream stream (non-fatal)", "error", err)
 	}
 
 	// 3. Initialize Permission Gate.
-	gate := permissions.NewGate(permissions.PermissionConfig{
-		AllowedTools:    cfg.Agent.Permissions.AllowedTools,
-		AllowedCommands: cfg.Agent.Permissions.AllowedCommands,
-		DeniedCommands:  cfg.Agent.Permissions.DeniedCommands,
-		FilesystemScope: cfg.Agent.Permissions.FilesystemScope,
-	})
+	// Rules that can never take effect are logged; the skills are checked
+	// once installed, by the container validation.
+	gateConfig := permissionConfig(cfg)
+	gate := permissions.NewGate(gateConfig)
+	for _, f := range permissions.Analyze(gateConfig, permissions.Requirements{Workspace: cfg.Agent.Workspace.Path}) {
+		slog.Warn("permission config contradiction", "kind", f.Kind, "message", f.Message)
+	}
 
 	// 4. Write workspace config files and start the agent manager.
-	workDir := os.Getenv("WORKSPACE_PATH")
-	if workDir == "" {
-		workDir = "/workspace"
-	}
+	workDir := cfg.Agent.Workspace.Path
 
 	// Create a cancelable context for child processes (e.g. opencode serve).
 	// Cancelling this context kills spawned processes on shutdown.
@@ -94,14 +125,26 @@ func main() {
 	defer sidecarCancel()
 
 	var manager provider.AgentManager
-	var opencodeCmd *exec.Cmd // non-nil when provider=opencode
+	var opencodeCmd *exec.Cmd         // non-nil when provider=opencode
+	var transcript *claude.Transcript // non-nil when transcripts are enabled
+
+	// Reported with the validation results so the API can track outdated
+	// agents.
+	versions := collectToolVersions(ctx)
 
 	switch cfg.Agent.Provider {
 	case "opencode":
-		manager, opencodeCmd, err = startOpenCode(sidecarCtx, cfg, workDir, natsClient)
+		manager, opencodeCmd, err = startOpenCode(sidecarCtx, cfg, workDir, natsClient, versions)
 	default:
 		// "claude" or any unrecognized value defaults to Claude.
-		manager, err = startClaude(ctx, cfg, workDir, natsClient)
+		if cfg.Agent.Transcripts.Enabled {
+			transcript, err = claude.NewTranscript(workDir, cfg.Agent.Transcripts.MaxFileBytes, cfg.Agent.Transcripts.MaxTotalBytes)
+			if err != nil {
+				slog.Warn("transcripts disabled", "error", err)
+				transcript, err = nil, nil
+			}
+		}
+		manager, err = startClaude(ctx, cfg, workDir, natsClient, versions, transcript)
 	}
 
 	if err != nil {
@@ -115,6 +158,19 @@ func main() {
 		TeamName:  cfg.Agent.Team,
 		Role:      cfg.Agent.Role,
 		Gate:      gate,
+
+		MaxConcurrentRuns: cfg.Agent.MaxConcurrentRuns,
+		TelemetryLevel:    cfg.Agent.Telemetry.Level,
+		PlanApproval:      cfg.Agent.PlanApproval,
+		MemoryContext:     cfg.Agent.MemoryContext,
+		InboxStartTime:    startedAt,
+		OnConfigUpdate: func(files []protocol.ConfigFile) error {
+			return writeConfigFiles(workDir, files)
+		},
+	}
+	if transcript != nil {
+		bridgeCfg.OnRunStart = transcript.Begin
+		defer transcript.Close()
 	}
 
 	bridge := agentNats.NewBridge(bridgeCfg, natsClient, manager)
@@ -124,6 +180,30 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Local admin endpoint for container healthchecks.
+	adminSrv, err := startAdminServer(cfg.Agent.Admin.Listen, &adminServer{
+		cfg:       cfg,
+		manager:   manager,
+		bridge:    bridge,
+		validate:  func() []protocol.ValidationCheck { return containerChecks(cfg) },
+		versions:  versions,
+		startedAt: startedAt,
+	})
+	if err != nil {
+		// Non-fatal: the agent works without it, only healthchecks fail.
+		slog.Error("failed to start admin endpoint", "addr", cfg.Agent.Admin.Listen, "error", err)
+	}
+
+	// Periodic self-check of the CLI, credentials and disk.
+	if !cfg.Agent.HealthCheck.Disabled {
+		checker := newHealthChecker(cfg, func(p protocol.HealthCheckPayload) error {
+			return publishHealthCheck(natsClient, cfg.Agent.Name, cfg.Agent.Team, p)
+		})
+		go checker.run(sidecarCtx, cfg.Agent.HealthCheck.Interval)
+	}
+
+	publishAgentReady(natsClient, cfg.Agent.Name, cfg.Agent.Team, cfg.Agent.Role, cfg.Agent.Provider)
+
 	slog.Info("agent sidecar ready",
 		"agent", cfg.Agent.Name,
 		"team", cfg.Agent.Team,
@@ -136,7 +216,28 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	slog.Info("shutting down agent sidecar")
+	grace := cfg.Agent.Shutdown.GracePeriod
+	slog.Info("shutting down agent sidecar", "grace_period", grace)
+
+	// Drain: stop accepting messages and let the current run finish. With
+	// memory enabled, the rest of the grace period is spent summarizing
+	// what to remember for the next deployment.
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), grace)
+	drained := bridge.Drain(drainCtx)
+	if !drained {
+		slog.Warn("grace period elapsed with a run in flight", "grace_period", grace)
+	} else if cfg.Agent.Memory {
+		if summary, err := bridge.Summarize(drainCtx); err != nil {
+			slog.Warn("failed to summarize memory", "error", err)
+		} else {
+			publishMemorySummary(natsClient, cfg.Agent.Name, cfg.Agent.Team, summary)
+		}
+	}
+	drainCancel()
+	publishAgentStatus(natsClient, cfg.Agent.Name, cfg.Agent.Team, protocol.AgentStatusShuttingDown, drained)
+	if err := natsClient.Flush(); err != nil {
+		slog.Debug("failed to flush nats before shutdown", "error", err)
+	}
 
 	// Graceful shutdown in reverse order.
 	bridge.Stop()
@@ -149,29 +250,47 @@ func main() {
 		_ = opencodeCmd.Wait()
 		slog.Info("opencode serve process stopped")
 	}
+	stopAdminServer(adminSrv)
+	if logs != nil {
+		logs.Close()
+	}
 	natsClient.Close()
 
 	slog.Info("agent sidecar stopped")
 }
 
 // startClaude handles the Claude Code provider startup flow.
-// Writes .claude/CLAUDE.md and .claude/agents/*.md, installs skills,
-// validates container files, then starts the Claude process.
-func startClaude(ctx context.Context, cfg *AgentConfig, workDir string, natsClient *agentNats.Client) (provider.AgentManager, error) {
+// Scaffolds the workspace template, writes .claude/CLAUDE.md and
+// .claude/agents/*.md, installs skills, runs the bootstrap script, validates
+// container files, then starts the Claude process.
+func startClaude(ctx context.Context, cfg *AgentConfig, workDir string, natsClient *agentNats.Client, versions protocol.ToolVersions, transcript *claude.Transcript) (provider.AgentManager, error) {
 	claudeDir := workDir + "/.claude"
 
+	// Scaffold the workspace template while the workspace is still empty.
+	scaffold := runConfiguredScaffold(ctx, cfg, workDir)
+
 	// Write workspace config files from env vars.
 	writeClaudeWorkspace(claudeDir)
 
 	// Install skills.
-	installSkillsFromEnv(natsClient, cfg)
+	installConfiguredSkills(natsClient, cfg)
 
 	// Write MCP config file.
 	writeMcpConfig(workDir, "claude", natsClient, cfg.Agent.Name, cfg.Agent.Team)
 
-	// Container validation.
-	checks := runContainerValidation(workDir, claudeDir, os.Getenv("AGENT_SKILLS_INSTALL") != "", os.Getenv("AGENT_SUB_AGENT_FILES") != "")
-	publishValidationResults(natsClient, cfg.Agent.Name, cfg.Agent.Team, checks)
+	// Environment bootstrap, then container validation.
+	bootstrap, bootstrapErr := runConfiguredBootstrap(ctx, natsClient, cfg)
+	checks := containerChecks(cfg)
+	if scaffold != nil {
+		checks = append(checks, *scaffold)
+	}
+	if bootstrap != nil {
+		checks = append(checks, *bootstrap)
+	}
+	publishValidationResults(natsClient, cfg.Agent.Name, cfg.Agent.Team, checks, versions)
+	if bootstrapErr != nil {
+		return nil, bootstrapErr
+	}
 
 	// Start Claude Manager.
 	processCfg := claude.ProcessConfig{
@@ -179,6 +298,14 @@ func startClaude(ctx context.Context, cfg *AgentConfig, workDir string, natsClie
 		AllowedTools: cfg.Agent.Permissions.AllowedTools,
 		WorkDir:      workDir,
 		Model:        cfg.Agent.ClaudeModel,
+		Persistent:   cfg.Agent.ClaudePersistent,
+		ExtraArgs:    cfg.Agent.ClaudeExtraArgs,
+	}
+	for _, dir := range cfg.Agent.Workspace.ExtraDirs {
+		processCfg.AddDirs = append(processCfg.AddDirs, dir.Path)
+	}
+	if transcript != nil {
+		processCfg.Transcript = transcript
 	}
 
 	claudeManager := claude.NewManager(processCfg)
@@ -195,14 +322,15 @@ func startClaude(ctx context.Context, cfg *AgentConfig, workDir string, natsClie
 // then creates an OpenCode Manager (which handles health check internally).
 // Returns the manager and the exec.Cmd for the opencode serve process so the
 // caller can kill it on shutdown.
-func startOpenCode(ctx context.Context, cfg *AgentConfig, workDir string, natsClient *agentNats.Client) (provider.AgentManager, *exec.Cmd, error) {
-	claudeDir := workDir + "/.claude"
+func startOpenCode(ctx context.Context, cfg *AgentConfig, workDir string, natsClient *agentNats.Client, versions protocol.ToolVersions) (provider.AgentManager, *exec.Cmd, error) {
+	// Scaffold the workspace template while the workspace is still empty.
+	scaffold := runConfiguredScaffold(ctx, cfg, workDir)
 
 	// Write OpenCode workspace files from env vars.
 	writeOpenCodeWorkspace(workDir)
 
 	// Skills are always installed to .claude/skills/ — OpenCode reads them natively.
-	installSkillsFromEnv(natsClient, cfg)
+	installConfiguredSkills(natsClient, cfg)
 
 	// Write MCP config file.
 	writeMcpConfig(workDir, "opencode", natsClient, cfg.Agent.Name, cfg.Agent.Team)
@@ -211,9 +339,19 @@ func startOpenCode(ctx context.Context, cfg *AgentConfig, workDir string, natsCl
 	// can discover and use the local Ollama instance via @ai-sdk/openai-compatible.
 	writeOllamaProviderConfig(workDir)
 
-	// Container validation for OpenCode layout.
-	checks := runOpenCodeContainerValidation(workDir, claudeDir, os.Getenv("AGENT_SKILLS_INSTALL") != "", os.Getenv("AGENT_SUB_AGENT_FILES") != "")
-	publishValidationResults(natsClient, cfg.Agent.Name, cfg.Agent.Team, checks)
+	// Environment bootstrap, then container validation for OpenCode layout.
+	bootstrap, bootstrapErr := runConfiguredBootstrap(ctx, natsClient, cfg)
+	checks := containerChecks(cfg)
+	if scaffold != nil {
+		checks = append(checks, *scaffold)
+	}
+	if bootstrap != nil {
+		checks = append(checks, *bootstrap)
+	}
+	publishValidationResults(natsClient, cfg.Agent.Name, cfg.Agent.Team, checks, versions)
+	if bootstrapErr != nil {
+		return nil, nil, bootstrapErr
+	}
 
 	// Generate a secure random password for the OpenCode server.
 	password, err := generateSecurePassword(32)
@@ -360,20 +498,14 @@ func writeSubAgentFiles(claudeDir string) {
 	}
 }
 
-// installSkillsFromEnv reads AGENT_SKILLS_INSTALL and installs skills.
-func installSkillsFromEnv(natsClient *agentNats.Client, cfg *AgentConfig) {
-	skillsEnv := os.Getenv("AGENT_SKILLS_INSTALL")
-	if skillsEnv == "" {
+// installConfiguredSkills installs the skills listed in the config
+// (agent.skills.install or AGENT_SKILLS_INSTALL).
+func installConfiguredSkills(natsClient *agentNats.Client, cfg *AgentConfig) {
+	if len(cfg.Agent.Skills.Install) == 0 {
 		return
 	}
 
-	var skills []protocol.SkillConfig
-	if err := json.Unmarshal([]byte(skillsEnv), &skills); err != nil {
-		slog.Warn("failed to parse AGENT_SKILLS_INSTALL", "error", err)
-		return
-	}
-
-	results := installSkills(skills)
+	results := installSkills(cfg.Agent.Skills.Install)
 	publishSkillStatus(natsClient, cfg.Agent.Name, cfg.Agent.Team, results)
 }
 
@@ -633,9 +765,86 @@ func buildInitialMcpStatus(mcpServersJSON, agentName string) (protocol.McpStatus
 	}, true
 }
 
-// publishValidationResults publishes validation check results to the team
-// activity NATS channel so the API relay can save them as TaskLogs.
-func publishValidationResults(client *agentNats.Client, agentName, teamName string, checks []protocol.ValidationCheck) {
+// publishAgentReady tells the API relay that the bridge is subscribed, so
+// messages queued while the team was deploying can be delivered.
+func publishAgentReady(client *agentNats.Client, agentName, teamName, role, provider string) {
+	payload := protocol.AgentReadyPayload{
+		AgentName: agentName,
+		Role:      role,
+		Provider:  provider,
+	}
+
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeAgentReady, payload)
+	if err != nil {
+		slog.Error("failed to create agent ready message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for agent ready", "error", err)
+		return
+	}
+
+	if err := client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish agent ready", "error", err)
+	}
+}
+
+// publishAgentStatus reports a sidecar lifecycle change to the team activity
+// channel so the API relay can record it.
+func publishAgentStatus(client *agentNats.Client, agentName, teamName, status string, drained bool) {
+	payload := protocol.AgentStatusPayload{
+		AgentName: agentName,
+		Status:    status,
+		Drained:   drained,
+	}
+
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeAgentStatus, payload)
+	if err != nil {
+		slog.Error("failed to create agent status message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for agent status", "error", err)
+		return
+	}
+
+	if err := client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish agent status", "error", err)
+	}
+}
+
+// publishMemorySummary sends the summary the agent wrote on shutdown to
+// the API, which keeps it for the team's next deployment.
+func publishMemorySummary(client *agentNats.Client, agentName, teamName, summary string) {
+	payload := protocol.MemorySummaryPayload{
+		AgentName: agentName,
+		Summary:   summary,
+	}
+
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeMemorySummary, payload)
+	if err != nil {
+		slog.Error("failed to create memory summary message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for memory summary", "error", err)
+		return
+	}
+
+	if err := client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish memory summary", "error", err)
+	}
+}
+
+// summarizeValidation returns a summary line for validation checks
+// (e.g. "3 ok, 1 warning(s), 0 error(s)") and the number of errors.
+func summarizeValidation(checks []protocol.ValidationCheck) (string, int) {
 	okCount, warnCount, errCount := 0, 0, 0
 	for _, c := range checks {
 		switch c.Status {
@@ -647,7 +856,82 @@ func publishValidationResults(client *agentNats.Client, agentName, teamName stri
 			errCount++
 		}
 	}
-	summary := fmt.Sprintf("%d ok, %d warning(s), %d error(s)", okCount, warnCount, errCount)
+	return fmt.Sprintf("%d ok, %d warning(s), %d error(s)", okCount, warnCount, errCount), errCount
+}
+
+// containerChecks runs the container validation checks for the configured
+// provider's workspace layout.
+func containerChecks(cfg *AgentConfig) []protocol.ValidationCheck {
+	workDir := cfg.Agent.Workspace.Path
+	claudeDir := workDir + "/.claude"
+	skillsConfigured := len(cfg.Agent.Skills.Install) > 0
+	subAgentsConfigured := os.Getenv("AGENT_SUB_AGENT_FILES") != ""
+	var checks []protocol.ValidationCheck
+	if cfg.Agent.Provider == "opencode" {
+		checks = runOpenCodeContainerValidation(workDir, claudeDir, skillsConfigured, subAgentsConfigured)
+	} else {
+		checks = runContainerValidation(workDir, claudeDir, skillsConfigured, subAgentsConfigured)
+	}
+	return append(checks, checkPermissions(permissionConfig(cfg), workDir, claudeDir))
+}
+
+// permissionConfig returns the configuration of the agent's permission
+// gate.
+func permissionConfig(cfg *AgentConfig) permissions.PermissionConfig {
+	return permissions.PermissionConfig{
+		AllowedTools:    cfg.Agent.Permissions.AllowedTools,
+		AllowedCommands: cfg.Agent.Permissions.AllowedCommands,
+		DeniedCommands:  cfg.Agent.Permissions.DeniedCommands,
+		FilesystemScope: cfg.Agent.Permissions.FilesystemScope,
+	}
+}
+
+// checkPermissions reports the rules of the agent's permissions that can
+// never take effect or that keep it from working, including tools the
+// installed skills need that are not allowed, as a warning.
+func checkPermissions(config permissions.PermissionConfig, workDir, claudeDir string) protocol.ValidationCheck {
+	findings := permissions.Analyze(config, permissions.Requirements{
+		Workspace:  workDir,
+		SkillTools: installedSkillTools(claudeDir),
+	})
+	if len(findings) == 0 {
+		return protocol.ValidationCheck{
+			Name:    "permissions",
+			Status:  protocol.ValidationOK,
+			Message: "permissions are consistent",
+		}
+	}
+	messages := make([]string, len(findings))
+	for i, f := range findings {
+		messages[i] = f.Message
+	}
+	return protocol.ValidationCheck{
+		Name:    "permissions",
+		Status:  protocol.ValidationWarning,
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// publishAgentLog sends a batch of forwarded sidecar logs to the team
+// activity channel. Errors are returned rather than logged, since logging
+// them would be forwarded again.
+func publishAgentLog(client *agentNats.Client, agentName, teamName string, payload protocol.AgentLogPayload) error {
+	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeAgentLog, payload)
+	if err != nil {
+		return err
+	}
+	subject, err := protocol.TeamActivityChannel(teamName)
+	if err != nil {
+		return err
+	}
+	return client.Publish(subject, msg)
+}
+
+// publishValidationResults publishes validation check results to the team
+// activity NATS channel so the API relay can save them as TaskLogs, along
+// with the container's tool versions.
+func publishValidationResults(client *agentNats.Client, agentName, teamName string, checks []protocol.ValidationCheck, versions protocol.ToolVersions) {
+	summary, _ := summarizeValidation(checks)
 
 	slog.Info("container validation complete", "summary", summary)
 	for _, c := range checks {
@@ -658,6 +942,7 @@ func publishValidationResults(client *agentNats.Client, agentName, teamName stri
 		AgentName: agentName,
 		Checks:    checks,
 		Summary:   summary,
+		Versions:  &versions,
 	}
 
 	msg, err := protocol.NewMessage(agentName, "system", protocol.TypeContainerValidation, payload)
@@ -21,20 +21,19 @@ import (
 
 // writeMcpConfig reads AGENT_MCP_SERVERS env var, validates the servers,
 // generates the provider-specific MCP config file, writes it to disk,
-// and publishes an mcp_status message via NATS.
+// and publishes an mcp_status message via NATS. When AGENT_CUSTOM_TOOLS is
+// set, the sidecar's own tools MCP server is added to the file.
 func writeMcpConfig(workDir, providerName string, natsClient *agentNats.Client, agentName, teamName string) {
-	serversEnv := os.Getenv("AGENT_MCP_SERVERS")
-	if serversEnv == "" {
-		return
-	}
-
 	var servers []protocol.McpServerConfig
-	if err := json.Unmarshal([]byte(serversEnv), &servers); err != nil {
-		slog.Warn("failed to parse AGENT_MCP_SERVERS", "error", err)
-		return
+	if serversEnv := os.Getenv("AGENT_MCP_SERVERS"); serversEnv != "" {
+		if err := json.Unmarshal([]byte(serversEnv), &servers); err != nil {
+			slog.Warn("failed to parse AGENT_MCP_SERVERS", "error", err)
+			servers = nil
+		}
 	}
+	toolsServer, hasTools := customToolsServer()
 
-	if len(servers) == 0 {
+	if len(servers) == 0 && !hasTools {
 		return
 	}
 
@@ -58,12 +57,23 @@ func writeMcpConfig(workDir, providerName string, natsClient *agentNats.Client,
 		})
 	}
 
-	if len(validServers) == 0 {
+	if len(validServers) == 0 && !hasTools {
 		slog.Warn("no valid MCP servers after validation")
 		publishMcpStatus(natsClient, agentName, teamName, statuses)
 		return
 	}
 
+	// The tools server is generated by the sidecar itself, so it skips
+	// validation and pre-warming.
+	configServers := validServers
+	if hasTools {
+		configServers = append(configServers[:len(configServers):len(configServers)], toolsServer)
+		statuses = append(statuses, protocol.McpServerStatus{
+			Name:   toolsServer.Name,
+			Status: "configured",
+		})
+	}
+
 	// Generate and write config file based on provider.
 	var configPath string
 	var content []byte
@@ -71,10 +81,10 @@ func writeMcpConfig(workDir, providerName string, natsClient *agentNats.Client,
 	switch providerName {
 	case "opencode":
 		configPath = filepath.Join(workDir, "opencode.json")
-		content = generateOpenCodeMcpConfig(configPath, validServers)
+		content = generateOpenCodeMcpConfig(configPath, configServers)
 	default:
 		configPath = filepath.Join(workDir, ".mcp.json")
-		content = generateClaudeMcpConfig(validServers)
+		content = generateClaudeMcpConfig(configServers)
 	}
 
 	if err := os.WriteFile(configPath, content, 0644); err != nil {
@@ -87,7 +97,7 @@ func writeMcpConfig(workDir, providerName string, natsClient *agentNats.Client,
 			}
 		}
 	} else {
-		slog.Info("wrote MCP config file", "path", configPath, "servers", len(validServers))
+		slog.Info("wrote MCP config file", "path", configPath, "servers", len(configServers))
 
 		// Pre-warm stdio MCP servers so package managers (uvx, npx) cache
 		// dependencies before the agent CLI starts. Without this, the first
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// restartStateFile holds the number of consecutive starts of the sidecar's
+// container and the time of the last one.
+const restartStateFile = "starts"
+
+// restartBackoffReset is how long a container has to have been up for its
+// next start to count as a first start again.
+const restartBackoffReset = 2 * protocol.MaxRestartBackoff
+
+// restartDelay records a start of the sidecar in dir and returns how long
+// to wait before going on: nothing on the container's first start, backoff
+// after its first restart, and twice as long after each restart after
+// that, up to protocol.MaxRestartBackoff. Nothing is recorded without a
+// backoff.
+func restartDelay(dir string, backoff time.Duration, now time.Time) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	path := filepath.Join(dir, restartStateFile)
+
+	var starts int
+	var last int64
+	if data, err := os.ReadFile(path); err == nil {
+		if _, err := fmt.Sscan(string(data), &starts, &last); err != nil || now.Sub(time.Unix(0, last)) > restartBackoffReset {
+			starts = 0
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err == nil {
+		_ = os.WriteFile(path, []byte(fmt.Sprintf("%d %d\n", starts+1, now.UnixNano())), 0o644)
+	}
+
+	if starts == 0 {
+		return 0
+	}
+	delay := backoff
+	for i := 1; i < starts && delay < protocol.MaxRestartBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, protocol.MaxRestartBackoff)
+}
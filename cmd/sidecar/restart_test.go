@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestRestartDelay(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	var delays []time.Duration
+	for i := range 6 {
+		delays = append(delays, restartDelay(dir, 10*time.Second, now.Add(time.Duration(i)*time.Minute)))
+	}
+	want := []time.Duration{0, 10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second, protocol.MaxRestartBackoff}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Errorf("start %d: delay = %s, want %s", i, delays[i], want[i])
+		}
+	}
+
+	// A container that stayed up long enough starts over.
+	if d := restartDelay(dir, 10*time.Second, now.Add(time.Hour)); d != 0 {
+		t.Errorf("after a long run: delay = %s, want 0", d)
+	}
+	if d := restartDelay(dir, 10*time.Second, now.Add(time.Hour+time.Second)); d != 10*time.Second {
+		t.Errorf("restart after a long run: delay = %s, want 10s", d)
+	}
+}
+
+func TestRestartDelay_NoBackoff(t *testing.T) {
+	dir := t.TempDir()
+	for range 3 {
+		if d := restartDelay(dir, 0, time.Now()); d != 0 {
+			t.Fatalf("delay = %s, want 0", d)
+		}
+	}
+}
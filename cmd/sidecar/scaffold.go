@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// workspaceIsEmpty reports whether workDir has no entries besides hidden
+// ones, such as the agents' generated .claude config, and the lost+found
+// directory of a fresh volume.
+func workspaceIsEmpty(workDir string) (bool, error) {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), ".") && e.Name() != "lost+found" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// scaffoldWorkspace writes the scaffold's directories and files into
+// workDir, skipping files that exist, and makes it a git repository if
+// asked to. It returns the number of files written.
+func scaffoldWorkspace(ctx context.Context, scaffold protocol.WorkspaceScaffold, workDir string) (int, error) {
+	for _, dir := range scaffold.Dirs {
+		if err := os.MkdirAll(filepath.Join(workDir, dir), 0o755); err != nil {
+			return 0, err
+		}
+	}
+	written := 0
+	for _, f := range scaffold.Files {
+		path := filepath.Join(workDir, f.Path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return written, err
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+		_, err = file.WriteString(f.Content)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, err
+		}
+		written++
+	}
+	if scaffold.GitInit {
+		if _, err := os.Stat(filepath.Join(workDir, ".git")); errors.Is(err, fs.ErrNotExist) {
+			cmd := exec.CommandContext(ctx, "git", "init", "-q")
+			cmd.Dir = workDir
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return written, fmt.Errorf("git init: %v: %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+	return written, nil
+}
+
+// runConfiguredScaffold scaffolds the configured workspace template
+// (agent.scaffold or AGENT_WORKSPACE_SCAFFOLD) into workDir if it is empty. It returns the check to include in the container validation, or
+// nil when no template is configured. A failed scaffold is a warning: the
+// agent starts anyway.
+func runConfiguredScaffold(ctx context.Context, cfg *AgentConfig, workDir string) *protocol.ValidationCheck {
+	scaffold := cfg.Agent.Scaffold
+	if scaffold == nil {
+		return nil
+	}
+	check := protocol.ValidationCheck{Name: "workspace_scaffold", Status: protocol.ValidationOK}
+
+	empty, err := workspaceIsEmpty(workDir)
+	switch {
+	case err != nil:
+		check.Status = protocol.ValidationWarning
+		check.Message = fmt.Sprintf("workspace template %s not scaffolded: %v", scaffold.Template, err)
+	case !empty:
+		check.Message = fmt.Sprintf("workspace is not empty; template %s not scaffolded", scaffold.Template)
+	default:
+		written, err := scaffoldWorkspace(ctx, *scaffold, workDir)
+		if err != nil {
+			check.Status = protocol.ValidationWarning
+			check.Message = fmt.Sprintf("workspace template %s partly scaffolded: %v", scaffold.Template, err)
+		} else {
+			check.Message = fmt.Sprintf("workspace template %s scaffolded: %d file(s) written", scaffold.Template, written)
+		}
+	}
+	slog.Info("workspace scaffold", "template", scaffold.Template, "status", check.Status, "message", check.Message)
+	return &check
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestRunConfiguredScaffold(t *testing.T) {
+	scaffold := &protocol.WorkspaceScaffold{
+		Template: "git-repo",
+		Dirs:     []string{"apps"},
+		Files: []protocol.ScaffoldFile{
+			{Path: "README.md", Content: "# crew\n"},
+			{Path: "docs/index.md", Content: "# docs\n"},
+		},
+	}
+	cfg := &AgentConfig{}
+	cfg.Agent.Scaffold = scaffold
+
+	// Hidden entries and lost+found do not make the workspace non-empty.
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".claude"), 0o755)
+	os.MkdirAll(filepath.Join(dir, "lost+found"), 0o755)
+	check := runConfiguredScaffold(context.Background(), cfg, dir)
+	if check == nil || check.Status != protocol.ValidationOK || !strings.Contains(check.Message, "2 file(s) written") {
+		t.Fatalf("check = %+v, want 2 files written", check)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "docs/index.md")); err != nil || string(data) != "# docs\n" {
+		t.Errorf("docs/index.md = %q, %v", data, err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "apps")); err != nil || !info.IsDir() {
+		t.Errorf("apps dir not created: %v", err)
+	}
+
+	// A workspace with files is left alone.
+	os.WriteFile(filepath.Join(dir, "README.md"), []byte("mine"), 0o644)
+	check = runConfiguredScaffold(context.Background(), cfg, dir)
+	if check.Status != protocol.ValidationOK || !strings.Contains(check.Message, "not empty") {
+		t.Errorf("check = %+v, want skipped", check)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "README.md")); string(data) != "mine" {
+		t.Errorf("README.md overwritten: %q", data)
+	}
+
+	if check := runConfiguredScaffold(context.Background(), &AgentConfig{}, dir); check != nil {
+		t.Errorf("no scaffold: check = %+v, want nil", check)
+	}
+}
+
+func TestScaffoldWorkspace_KeepsExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("custom\n"), 0o644)
+
+	written, err := scaffoldWorkspace(context.Background(), protocol.WorkspaceScaffold{
+		Files: []protocol.ScaffoldFile{{Path: ".gitignore", Content: "node_modules/\n"}, {Path: "Makefile", Content: "all:\n"}},
+	}, dir)
+	if err != nil || written != 1 {
+		t.Fatalf("scaffoldWorkspace = %d, %v, want 1 file written", written, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, ".gitignore")); string(data) != "custom\n" {
+		t.Errorf(".gitignore overwritten: %q", data)
+	}
+}
@@ -6,9 +6,12 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 
+	"gopkg.in/yaml.v3"
+
 	agentNats "github.com/helmcode/agent-crew/internal/nats"
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
@@ -83,9 +86,15 @@ func installSkills(skills []protocol.SkillConfig) []protocol.SkillInstallResult
 			})
 		} else {
 			slog.Info("skill installed", "repo_url", cfg.RepoURL, "skill_name", cfg.SkillName)
+			// Reported so that the API learns the tools the skill needs.
+			var tools []string
+			if data, err := os.ReadFile(filepath.Join(cmd.Dir, ".claude", "skills", cfg.SkillName, "SKILL.md")); err == nil {
+				_, tools = parseSkillTools(data)
+			}
 			results = append(results, protocol.SkillInstallResult{
-				Package: pkg,
-				Status:  "installed",
+				Package:       pkg,
+				Status:        "installed",
+				RequiredTools: tools,
 			})
 		}
 	}
@@ -130,3 +139,90 @@ func publishSkillStatus(client *agentNats.Client, agentName, teamName string, re
 		slog.Error("failed to publish skill status", "error", err)
 	}
 }
+
+// skillFrontmatter is the part of a SKILL.md front matter the sidecar reads.
+type skillFrontmatter struct {
+	Name         string    `yaml:"name"`
+	AllowedTools yaml.Node `yaml:"allowed-tools"`
+}
+
+// installedSkillTools returns the tools each installed skill in
+// <claudeDir>/skills declares in the allowed-tools field of its SKILL.md,
+// by skill name. Skills without the field are left out.
+func installedSkillTools(claudeDir string) map[string][]string {
+	entries, err := os.ReadDir(filepath.Join(claudeDir, "skills"))
+	if err != nil {
+		return nil
+	}
+	tools := make(map[string][]string)
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join(claudeDir, "skills", e.Name(), "SKILL.md"))
+		if err != nil {
+			continue
+		}
+		name, allowed := parseSkillTools(data)
+		if name == "" {
+			name = e.Name()
+		}
+		if len(allowed) > 0 {
+			tools[name] = allowed
+		}
+	}
+	return tools
+}
+
+// parseSkillTools returns the name and allowed tools of a SKILL.md. The
+// allowed-tools field is either a YAML list or a string of tools separated
+// by commas or spaces, such as "Read, Grep, Bash(git add:*)".
+func parseSkillTools(skillMD []byte) (string, []string) {
+	content := strings.TrimPrefix(string(skillMD), "\ufeff")
+	if !strings.HasPrefix(content, "---") {
+		return "", nil
+	}
+	end := strings.Index(content[3:], "\n---")
+	if end < 0 {
+		return "", nil
+	}
+	var fm skillFrontmatter
+	if err := yaml.Unmarshal([]byte(content[3:3+end]), &fm); err != nil {
+		return "", nil
+	}
+	var tools []string
+	switch fm.AllowedTools.Kind {
+	case yaml.SequenceNode:
+		for _, n := range fm.AllowedTools.Content {
+			tools = append(tools, splitToolRules(n.Value)...)
+		}
+	case yaml.ScalarNode:
+		tools = splitToolRules(fm.AllowedTools.Value)
+	}
+	return fm.Name, tools
+}
+
+// splitToolRules splits a list of tool rules on the commas and spaces that
+// are not inside a rule's parentheses.
+func splitToolRules(s string) []string {
+	var rules []string
+	var cur strings.Builder
+	depth := 0
+	flush := func() {
+		if rule := strings.TrimSpace(cur.String()); rule != "" {
+			rules = append(rules, rule)
+		}
+		cur.Reset()
+	}
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0 && (r == ',' || r == ' ' || r == '\t' || r == '\n'):
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return rules
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/helmcode/agent-crew/internal/protocol"
@@ -125,3 +126,40 @@ func TestInstallSkills_MixedInput(t *testing.T) {
 	}
 }
 
+func TestParseSkillTools(t *testing.T) {
+	tests := []struct {
+		name      string
+		skillMD   string
+		wantName  string
+		wantTools []string
+	}{
+		{
+			name:      "string",
+			skillMD:   "---\nname: commit\nallowed-tools: Read, Grep, Bash(git add:*)\n---\n# Commit\n",
+			wantName:  "commit",
+			wantTools: []string{"Read", "Grep", "Bash(git add:*)"},
+		},
+		{
+			name:      "spaces",
+			skillMD:   "\ufeff---\nname: lint\nallowed-tools: Read Bash(npm run lint:*)\n---\n",
+			wantName:  "lint",
+			wantTools: []string{"Read", "Bash(npm run lint:*)"},
+		},
+		{
+			name:      "list",
+			skillMD:   "---\nname: docs\nallowed-tools:\n  - Write\n  - Edit\n---\n",
+			wantName:  "docs",
+			wantTools: []string{"Write", "Edit"},
+		},
+		{name: "no tools", skillMD: "---\nname: plain\n---\n", wantName: "plain"},
+		{name: "no front matter", skillMD: "# Just markdown\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, tools := parseSkillTools([]byte(tt.skillMD))
+			if name != tt.wantName || !reflect.DeepEqual(tools, tt.wantTools) {
+				t.Errorf("got %q %q, want %q %q", name, tools, tt.wantName, tt.wantTools)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// toolsMcpCommand is the sidecar subcommand that serves the organization's
+// custom tools to the agent CLI as a stdio MCP server.
+const toolsMcpCommand = "tools-mcp"
+
+// sidecarBinary is used when the running executable cannot be resolved.
+const sidecarBinary = "/usr/local/bin/agent-sidecar"
+
+// toolRequestTimeout bounds a single tool call. It is just above the API's
+// 300s cap on a tool's timeout_seconds.
+const toolRequestTimeout = 310 * time.Second
+
+// parseCustomTools reads the AGENT_CUSTOM_TOOLS JSON set by the API.
+func parseCustomTools(env string) ([]protocol.CustomToolSpec, error) {
+	if env == "" {
+		return nil, nil
+	}
+	var specs []protocol.CustomToolSpec
+	if err := json.Unmarshal([]byte(env), &specs); err != nil {
+		return nil, fmt.Errorf("parsing AGENT_CUSTOM_TOOLS: %w", err)
+	}
+	return specs, nil
+}
+
+// customToolsServer returns the MCP server entry that runs this binary in
+// tools-mcp mode, or false when the organization has no custom tools.
+func customToolsServer() (protocol.McpServerConfig, bool) {
+	specs, err := parseCustomTools(os.Getenv("AGENT_CUSTOM_TOOLS"))
+	if err != nil {
+		slog.Warn("ignoring custom tools", "error", err)
+		return protocol.McpServerConfig{}, false
+	}
+	if len(specs) == 0 {
+		return protocol.McpServerConfig{}, false
+	}
+	bin, err := os.Executable()
+	if err != nil {
+		bin = sidecarBinary
+	}
+	return protocol.McpServerConfig{
+		Name:      protocol.CustomToolsServerName,
+		Transport: "stdio",
+		Command:   bin,
+		Args:      []string{toolsMcpCommand},
+	}, true
+}
+
+// toolInvoker forwards tool calls to the API over the team's NATS tools
+// subject after checking the permission gate.
+type toolInvoker struct {
+	gate      *permissions.Gate
+	agentName string
+	subject   string
+	request   func(ctx context.Context, subject string, msg *protocol.Message) (*protocol.Message, error)
+}
+
+// handler returns the MCP handler for one tool.
+func (ti *toolInvoker) handler(name string) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if decision := ti.gate.Evaluate(protocol.CustomToolPermission(name), "", nil); !decision.Allowed {
+			slog.Warn("custom tool denied by permission gate", "tool", name, "reason", decision.Reason)
+			return mcp.NewToolResultError("Permission denied: " + decision.Reason), nil
+		}
+
+		args, err := json.Marshal(req.GetRawArguments())
+		if err != nil {
+			return mcp.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		msg, err := protocol.NewMessage(ti.agentName, "api", protocol.TypeToolInvocation, protocol.ToolInvocationPayload{
+			AgentName: ti.agentName,
+			Tool:      name,
+			Arguments: args,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, toolRequestTimeout)
+		defer cancel()
+		reply, err := ti.request(ctx, ti.subject, msg)
+		if err != nil {
+			return mcp.NewToolResultError("tool proxy unavailable: " + err.Error()), nil
+		}
+		result, err := protocol.ParsePayload[protocol.ToolResultPayload](reply)
+		if err != nil {
+			return mcp.NewToolResultError("invalid tool result: " + err.Error()), nil
+		}
+		if result.IsError {
+			text := result.Result
+			if result.StatusCode != 0 {
+				text = fmt.Sprintf("HTTP %d: %s", result.StatusCode, result.Result)
+			}
+			return mcp.NewToolResultError(text), nil
+		}
+		return mcp.NewToolResultText(result.Result), nil
+	}
+}
+
+// newToolsMcpServer registers every custom tool on an MCP server.
+func newToolsMcpServer(specs []protocol.CustomToolSpec, ti *toolInvoker) *server.MCPServer {
+	s := server.NewMCPServer(protocol.CustomToolsServerName, "1.0.0", server.WithToolCapabilities(false))
+	for _, spec := range specs {
+		schema := spec.InputSchema
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		s.AddTool(mcp.NewToolWithRawSchema(spec.Name, spec.Description, schema), ti.handler(spec.Name))
+	}
+	return s
+}
+
+// runToolsMcp serves the custom tools over stdio. It is started by the agent
+// CLI from the generated MCP config, inherits the sidecar's environment, and
+// logs to stderr because stdout carries the MCP protocol.
+func runToolsMcp() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	cfg, err := LoadConfig(configFilePath())
+	if err != nil {
+		slog.Error("failed to load config", "error", err)
+		os.Exit(1)
+	}
+	specs, err := parseCustomTools(os.Getenv("AGENT_CUSTOM_TOOLS"))
+	if err != nil {
+		slog.Error("failed to load custom tools", "error", err)
+		os.Exit(1)
+	}
+	subject, err := protocol.TeamToolsChannel(cfg.Agent.Team)
+	if err != nil {
+		slog.Error("failed to build tools channel", "error", err)
+		os.Exit(1)
+	}
+
+	natsConfig := agentNats.DefaultConfig(cfg.Agent.NATS.URL, cfg.Agent.Team+"-"+cfg.Agent.Name+"-tools")
+	natsConfig.Token = cfg.Agent.NATS.Token
+	natsConfig.JetStreamEnabled = false
+	natsClient, err := agentNats.Connect(natsConfig)
+	if err != nil {
+		slog.Error("failed to connect to nats", "error", err)
+		os.Exit(1)
+	}
+	defer natsClient.Close()
+
+	ti := &toolInvoker{
+		gate:      permissions.NewGate(permissionConfig(cfg)),
+		agentName: cfg.Agent.Name,
+		subject:   subject,
+		request:   natsClient.Request,
+	}
+	if err := server.ServeStdio(newToolsMcpServer(specs, ti)); err != nil {
+		slog.Error("tools MCP server stopped", "error", err)
+	}
+}
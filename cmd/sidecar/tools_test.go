@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestCustomToolsServer(t *testing.T) {
+	t.Setenv("AGENT_CUSTOM_TOOLS", "")
+	if _, ok := customToolsServer(); ok {
+		t.Error("expected no tools server without AGENT_CUSTOM_TOOLS")
+	}
+
+	t.Setenv("AGENT_CUSTOM_TOOLS", `[{"name":"lookup","description":"Look up"}]`)
+	srv, ok := customToolsServer()
+	if !ok {
+		t.Fatal("expected tools server")
+	}
+	if srv.Name != protocol.CustomToolsServerName || srv.Transport != "stdio" || len(srv.Args) != 1 || srv.Args[0] != toolsMcpCommand {
+		t.Errorf("server: got %+v", srv)
+	}
+
+	var cfg struct {
+		McpServers map[string]struct {
+			Command string   `json:"command"`
+			Args    []string `json:"args"`
+		} `json:"mcpServers"`
+	}
+	if err := json.Unmarshal(generateClaudeMcpConfig([]protocol.McpServerConfig{srv}), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := cfg.McpServers[protocol.CustomToolsServerName]; !ok || entry.Args[0] != toolsMcpCommand {
+		t.Errorf(".mcp.json entry: got %+v", cfg.McpServers)
+	}
+
+	t.Setenv("AGENT_CUSTOM_TOOLS", `not json`)
+	if _, ok := customToolsServer(); ok {
+		t.Error("expected invalid AGENT_CUSTOM_TOOLS to be ignored")
+	}
+}
+
+func callTool(t *testing.T, ti *toolInvoker, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	res, err := ti.handler(name)(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	return res
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	if len(res.Content) == 0 {
+		return ""
+	}
+	if text, ok := res.Content[0].(mcp.TextContent); ok {
+		return text.Text
+	}
+	return ""
+}
+
+func TestToolInvoker(t *testing.T) {
+	var sent *protocol.ToolInvocationPayload
+	var sentSubject string
+	ti := &toolInvoker{
+		gate:      permissions.NewGate(permissions.PermissionConfig{AllowedTools: []string{protocol.CustomToolPermission("lookup")}}),
+		agentName: "leader",
+		subject:   "tools.team.invoke",
+		request: func(_ context.Context, subject string, msg *protocol.Message) (*protocol.Message, error) {
+			sentSubject = subject
+			sent, _ = protocol.ParsePayload[protocol.ToolInvocationPayload](msg)
+			if sent.Tool == "broken" {
+				return protocol.NewMessage("api", "leader", protocol.TypeToolResult, protocol.ToolResultPayload{
+					Tool: sent.Tool, StatusCode: 500, Result: "boom", IsError: true,
+				})
+			}
+			return protocol.NewMessage("api", "leader", protocol.TypeToolResult, protocol.ToolResultPayload{
+				Tool: sent.Tool, StatusCode: 200, Result: `{"id":7}`,
+			})
+		},
+	}
+
+	res := callTool(t, ti, "lookup", map[string]any{"email": "a@b.c"})
+	if res.IsError || resultText(res) != `{"id":7}` {
+		t.Errorf("lookup: got error=%v text=%q", res.IsError, resultText(res))
+	}
+	if sentSubject != "tools.team.invoke" || sent.AgentName != "leader" || string(sent.Arguments) != `{"email":"a@b.c"}` {
+		t.Errorf("request: subject=%q payload=%+v", sentSubject, sent)
+	}
+
+	sent = nil
+	res = callTool(t, ti, "delete_everything", nil)
+	if !res.IsError || !strings.Contains(resultText(res), "Permission denied") {
+		t.Errorf("denied tool: got error=%v text=%q", res.IsError, resultText(res))
+	}
+	if sent != nil {
+		t.Error("denied tool must not reach the API")
+	}
+
+	ti.gate = permissions.NewGate(permissions.PermissionConfig{AllowedTools: []string{protocol.CustomToolPermission("broken")}})
+	res = callTool(t, ti, "broken", nil)
+	if !res.IsError || resultText(res) != "HTTP 500: boom" {
+		t.Errorf("broken tool: got error=%v text=%q", res.IsError, resultText(res))
+	}
+
+	ti.request = func(context.Context, string, *protocol.Message) (*protocol.Message, error) {
+		return nil, errors.New("no responders")
+	}
+	res = callTool(t, ti, "broken", nil)
+	if !res.IsError || !strings.Contains(resultText(res), "tool proxy unavailable") {
+		t.Errorf("no relay: got error=%v text=%q", res.IsError, resultText(res))
+	}
+}
+
+func TestNewToolsMcpServer(t *testing.T) {
+	specs := []protocol.CustomToolSpec{
+		{Name: "lookup", Description: "Look up", InputSchema: json.RawMessage(`{"type":"object","properties":{"q":{"type":"string"}}}`)},
+		{Name: "ping", Description: "Ping"},
+	}
+	s := newToolsMcpServer(specs, &toolInvoker{})
+	tools := s.ListTools()
+	if len(tools) != 2 {
+		t.Fatalf("got %d tools, want 2", len(tools))
+	}
+	if tool := tools["lookup"]; !strings.Contains(string(tool.Tool.RawInputSchema), `"q"`) {
+		t.Errorf("lookup schema: got %s", tool.Tool.RawInputSchema)
+	}
+}
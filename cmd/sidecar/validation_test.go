@@ -3,8 +3,10 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/helmcode/agent-crew/internal/permissions"
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
@@ -303,3 +305,34 @@ func TestGenerateSecurePassword(t *testing.T) {
 		t.Errorf("password length %d seems too short for 32 bytes of randomness", len(p1))
 	}
 }
+
+func TestCheckPermissions(t *testing.T) {
+	claudeDir := filepath.Join(t.TempDir(), ".claude")
+	skillDir := filepath.Join(claudeDir, "skills", "release")
+	if err := os.MkdirAll(skillDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	skillMD := "---\nname: release\nallowed-tools: Read, Bash(git tag:*)\n---\n"
+	if err := os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte(skillMD), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := permissions.PermissionConfig{
+		AllowedTools:    []string{"Read"},
+		FilesystemScope: permissions.FilesystemScope{{Path: "/workspace", Mode: permissions.ModeReadWrite}},
+	}
+	check := checkPermissions(config, "/workspace", claudeDir)
+	if check.Name != "permissions" || check.Status != protocol.ValidationWarning ||
+		!strings.Contains(check.Message, "skill release needs tools missing from allowed_tools: Bash") {
+		t.Errorf("missing skill tool: got %+v", check)
+	}
+
+	config.AllowedTools = append(config.AllowedTools, "Bash")
+	if check := checkPermissions(config, "/workspace", claudeDir); check.Status != protocol.ValidationOK {
+		t.Errorf("consistent permissions: got %+v", check)
+	}
+	if check := checkPermissions(config, "/srv/app", claudeDir); check.Status != protocol.ValidationWarning ||
+		!strings.Contains(check.Message, "/srv/app") {
+		t.Errorf("workspace out of scope: got %+v", check)
+	}
+}
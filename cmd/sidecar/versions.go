@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// versionCommandTimeout bounds each version probe, since npm can be slow to
+// start on a cold container.
+const versionCommandTimeout = 15 * time.Second
+
+var (
+	// versionRe matches the first dotted version in a tool's output, such as
+	// "1.0.83" in "1.0.83 (Claude Code)" or "20.11.1" in "v20.11.1".
+	versionRe = regexp.MustCompile(`\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.-]+)?`)
+	// skillsPackageRe matches the skills package in `npm ls -g` output.
+	skillsPackageRe = regexp.MustCompile(`\bskills@(\d[^\s]*)`)
+)
+
+// collectToolVersions reports the versions of the CLIs installed in the
+// container. Tools that are missing or fail to report are left empty. The
+// probes run concurrently, so startup waits for the slowest one only.
+func collectToolVersions(ctx context.Context) protocol.ToolVersions {
+	var v protocol.ToolVersions
+	var wg sync.WaitGroup
+	probe := func(field *string, parse func(string) string, name string, args ...string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			*field = parse(runVersionCommand(ctx, name, args...))
+		}()
+	}
+	probe(&v.Claude, parseToolVersion, "claude", "--version")
+	probe(&v.OpenCode, parseToolVersion, "opencode", "--version")
+	probe(&v.Node, parseToolVersion, "node", "--version")
+	probe(&v.Skills, parseSkillsVersion, "npm", "ls", "-g", "skills", "--depth=0")
+	wg.Wait()
+	slog.Info("tool versions", "claude", v.Claude, "opencode", v.OpenCode, "node", v.Node, "skills", v.Skills)
+	return v
+}
+
+// runVersionCommand runs a version probe and returns its output, or "" if
+// the command is missing or fails.
+func runVersionCommand(ctx context.Context, name string, args ...string) string {
+	if _, err := exec.LookPath(name); err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(ctx, versionCommandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		slog.Debug("version probe failed", "command", name, "error", err)
+		return ""
+	}
+	return string(out)
+}
+
+// parseToolVersion extracts the version from a --version output.
+func parseToolVersion(out string) string {
+	return versionRe.FindString(out)
+}
+
+// parseSkillsVersion extracts the skills package version from
+// `npm ls -g skills` output.
+func parseSkillsVersion(out string) string {
+	if m := skillsPackageRe.FindStringSubmatch(out); m != nil {
+		return m[1]
+	}
+	return ""
+}
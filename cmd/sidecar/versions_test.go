@@ -0,0 +1,28 @@
+package main
+
+import "testing"
+
+func TestParseToolVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.0.83 (Claude Code)\n": "1.0.83",
+		"v20.11.1\n":             "20.11.1",
+		"opencode 0.5.2-beta.1":  "0.5.2-beta.1",
+		"":                       "",
+		"command not found":      "",
+	}
+	for in, want := range tests {
+		if got := parseToolVersion(in); got != want {
+			t.Errorf("parseToolVersion(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseSkillsVersion(t *testing.T) {
+	out := "/root/.nvm/versions/node/v20.11.1/lib\n└── skills@1.2.3\n"
+	if got := parseSkillsVersion(out); got != "1.2.3" {
+		t.Errorf("parseSkillsVersion = %q, want 1.2.3", got)
+	}
+	if got := parseSkillsVersion("/usr/local/lib\n└── (empty)\n"); got != "" {
+		t.Errorf("parseSkillsVersion without skills = %q, want empty", got)
+	}
+}
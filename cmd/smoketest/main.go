@@ -0,0 +1,64 @@
+// Package main runs an end-to-end smoke test against a live AgentCrew
+// installation: it creates a throwaway team, deploys it, chats with its
+// leader, checks the container validation and removes the team. The report
+// is printed as JSON, and the exit status is 1 if any step failed, so CI/CD
+// pipelines can verify an installation.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/smoketest"
+)
+
+func main() {
+	endpoint := flag.String("endpoint", os.Getenv("AGENTCREW_ENDPOINT"), "API URL, such as http://localhost:8080 (default $AGENTCREW_ENDPOINT)")
+	timeout := flag.Duration("timeout", 10*time.Minute, "time the smoke test may take, teardown excluded")
+	message := flag.String("message", smoketest.DefaultMessage, "chat message sent to the leader")
+	runtime := flag.String("runtime", "", "team runtime, docker or kubernetes (default: the API's)")
+	image := flag.String("image", "", "agent image (default: the API's)")
+	keep := flag.Bool("keep", false, "keep the team instead of removing it")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	if *endpoint == "" {
+		fmt.Fprintln(os.Stderr, "smoketest: -endpoint or AGENTCREW_ENDPOINT is required")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+
+	api := client.New(*endpoint, os.Getenv("AGENTCREW_TOKEN"))
+	slog.Info("running smoke test", "endpoint", *endpoint)
+	report := smoketest.Run(ctx, api, smoketest.Options{
+		Message:    *message,
+		Runtime:    *runtime,
+		AgentImage: *image,
+		Keep:       *keep,
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if !report.OK {
+		for _, s := range report.Steps {
+			if !s.OK {
+				slog.Error("smoke test step failed", "step", s.Name, "error", s.Error)
+			}
+		}
+		os.Exit(1)
+	}
+	slog.Info("smoke test passed", "team", report.TeamName, "duration_ms", report.DurationMs)
+}
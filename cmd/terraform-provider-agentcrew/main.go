@@ -0,0 +1,29 @@
+// Command terraform-provider-agentcrew is the Terraform provider for
+// AgentCrew teams, agents, schedules and webhooks.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+
+	"github.com/helmcode/agent-crew/internal/tfprovider"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+func main() {
+	debug := flag.Bool("debug", false, "run the provider with support for debuggers like delve")
+	flag.Parse()
+
+	err := providerserver.Serve(context.Background(), tfprovider.New(version), providerserver.ServeOpts{
+		Address: "registry.terraform.io/helmcode/agentcrew",
+		Debug:   *debug,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
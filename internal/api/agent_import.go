@@ -0,0 +1,300 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// maxAgentProfileSize caps the size of an imported agent profile.
+const maxAgentProfileSize = 256 * 1024
+
+// maxAgentProfileRedirects caps the redirects followed to download a
+// profile.
+const maxAgentProfileRedirects = 5
+
+// errProfileFetch is returned for every failed download, so that an import
+// does not reveal what the profile URL's host answered.
+var errProfileFetch = fiber.NewError(fiber.StatusBadGateway, "failed to fetch the profile")
+
+// agentProfileClient downloads agent profiles. It follows redirects to https
+// URLs only, and does not connect to loopback, private or link-local
+// addresses, so that imports cannot reach services on the API's network.
+var agentProfileClient = &http.Client{
+	Timeout:       15 * time.Second,
+	CheckRedirect: checkProfileRedirect,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: refuseInternalAddress,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		ForceAttemptHTTP2:   true,
+	},
+}
+
+// checkProfileRedirect validates each redirect of a profile download like
+// the URL it started from.
+func checkProfileRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxAgentProfileRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxAgentProfileRedirects)
+	}
+	if req.URL.Scheme != "https" || req.URL.Host == "" {
+		return fmt.Errorf("redirect to %s is not an https URL", req.URL.Redacted())
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which
+// netip does not count as private but cloud providers use internally.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// refuseInternalAddress is the dialer control of agentProfileClient. It
+// checks the resolved address, so that a host name cannot point at an
+// internal one.
+func refuseInternalAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("address %s is internal", ip)
+	}
+	return nil
+}
+
+// agentProfile is an agent definition in the format of a Claude sub-agent
+// file: YAML frontmatter followed by the agent's CLAUDE.md instructions.
+// role and specialty are AgentCrew extensions; other keys are ignored.
+type agentProfile struct {
+	Name           string   `yaml:"name"`
+	Role           string   `yaml:"role"`
+	Specialty      string   `yaml:"specialty"`
+	Description    string   `yaml:"description"`
+	Model          string   `yaml:"model"`
+	Background     *bool    `yaml:"background"`
+	Isolation      string   `yaml:"isolation"`
+	PermissionMode string   `yaml:"permissionMode"`
+	Skills         []string `yaml:"skills"`
+
+	Instructions string `yaml:"-"`
+}
+
+// parseAgentProfile splits a profile into its frontmatter and instructions.
+// A profile without frontmatter is all instructions.
+func parseAgentProfile(content string) (agentProfile, error) {
+	var p agentProfile
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		p.Instructions = strings.TrimSpace(content)
+		return p, nil
+	}
+	front, body, ok := strings.Cut(content[len("---\n"):], "\n---")
+	if !ok {
+		return p, fmt.Errorf("frontmatter is not closed with ---")
+	}
+	if err := yaml.Unmarshal([]byte(front), &p); err != nil {
+		return p, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	p.Instructions = strings.TrimSpace(body)
+	return p, nil
+}
+
+// skillConfigs converts the profile's skills, written as in a sub-agent
+// file ("owner/repo:skill" for GitHub, or a full repository URL followed
+// by ":skill"), to sub_agent_skills entries.
+func (p agentProfile) skillConfigs() ([]map[string]string, error) {
+	var configs []map[string]string
+	for _, sk := range p.Skills {
+		i := strings.LastIndex(sk, ":")
+		if i <= 0 || strings.Contains(sk[i+1:], "/") {
+			return nil, fmt.Errorf("skills: %q must be owner/repo:skill", sk)
+		}
+		repo := sk[:i]
+		if !strings.Contains(repo, "://") {
+			repo = "https://github.com/" + repo
+		}
+		configs = append(configs, map[string]string{"repo_url": repo, "skill_name": sk[i+1:]})
+	}
+	return configs, nil
+}
+
+// resolveProfileURL returns the URL to download a profile from. GitHub file
+// pages and gists are rewritten to their raw content.
+func resolveProfileURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("url must be an absolute https URL")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch u.Host {
+	case "github.com":
+		// github.com/<owner>/<repo>/blob/<ref>/<path>
+		if len(parts) >= 5 && parts[2] == "blob" {
+			return "https://raw.githubusercontent.com/" + parts[0] + "/" + parts[1] + "/" + strings.Join(parts[3:], "/"), nil
+		}
+	case "gist.github.com":
+		// gist.github.com/<user>/<id> serves the gist's first file raw.
+		if len(parts) == 2 {
+			return "https://gist.githubusercontent.com/" + parts[0] + "/" + parts[1] + "/raw", nil
+		}
+	}
+	return u.String(), nil
+}
+
+// fetchAgentProfile downloads a profile. Errors are fiber errors ready to
+// return from a handler.
+func fetchAgentProfile(c *fiber.Ctx, profileURL string) (string, error) {
+	req, err := http.NewRequestWithContext(c.Context(), http.MethodGet, profileURL, nil)
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, "invalid url")
+	}
+	resp, err := agentProfileClient.Do(req)
+	if err != nil {
+		slog.Warn("agent import: fetching profile failed", "url", profileURL, "error", err)
+		return "", errProfileFetch
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		slog.Warn("agent import: fetching profile failed", "url", profileURL, "status", resp.StatusCode)
+		return "", errProfileFetch
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAgentProfileSize+1))
+	if err != nil {
+		slog.Warn("agent import: reading profile failed", "url", profileURL, "error", err)
+		return "", errProfileFetch
+	}
+	if len(data) > maxAgentProfileSize {
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("profile exceeds maximum size of %d bytes", maxAgentProfileSize))
+	}
+	if !utf8.Valid(data) {
+		return "", fiber.NewError(fiber.StatusBadRequest, "profile is not UTF-8 text")
+	}
+	return string(data), nil
+}
+
+// ImportAgent handles POST /api/agents/import. It downloads an agent profile
+// and adds the agent it defines to a team, validated like an agent created
+// through the API, and records where the profile came from.
+func (s *Server) ImportAgent(c *fiber.Ctx) error {
+	var req ImportAgentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if req.URL == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "url is required")
+	}
+	if req.TeamID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "team_id is required")
+	}
+	resolved, err := resolveProfileURL(req.URL)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", req.TeamID).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "team_id references a non-existent team")
+	}
+
+	content, err := fetchAgentProfile(c, resolved)
+	if err != nil {
+		return err
+	}
+	profile, err := parseAgentProfile(content)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid profile: "+err.Error())
+	}
+	if len(profile.Instructions) > maxInstructionsSize {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("profile instructions exceed maximum size of %d bytes", maxInstructionsSize))
+	}
+	skills, err := profile.skillConfigs()
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid profile: "+err.Error())
+	}
+
+	agentReq := CreateAgentRequest{
+		Name:                   profile.Name,
+		Role:                   profile.Role,
+		Specialty:              profile.Specialty,
+		InstructionsMD:         profile.Instructions,
+		SubAgentDescription:    profile.Description,
+		SubAgentModel:          profile.Model,
+		SubAgentBackground:     profile.Background,
+		SubAgentIsolation:      profile.Isolation,
+		SubAgentPermissionMode: profile.PermissionMode,
+	}
+	if skills != nil {
+		agentReq.SubAgentSkills = skills
+	}
+	if req.Name != "" {
+		agentReq.Name = req.Name
+	}
+	if req.Role != "" {
+		agentReq.Role = req.Role
+	}
+	if agentReq.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required: set it in the request or the profile's frontmatter")
+	}
+
+	agent, err := s.createAgent(c, team, agentReq)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256([]byte(content))
+	provenance := models.AgentProvenance{
+		ID:            uuid.New().String(),
+		OrgID:         GetOrgID(c),
+		AgentID:       agent.ID,
+		SourceURL:     req.URL,
+		ResolvedURL:   resolved,
+		ContentSHA256: hex.EncodeToString(sum[:]),
+		ImportedBy:    GetUserID(c),
+	}
+	if err := s.db.Create(&provenance).Error; err != nil {
+		slog.Error("failed to record agent provenance", "agent", agent.Name, "error", err)
+	}
+
+	slog.Info("agent imported", "agent", agent.Name, "team", team.Name, "url", resolved)
+	return c.Status(fiber.StatusCreated).JSON(ImportAgentResponse{Agent: agent, Provenance: provenance})
+}
+
+// GetAgentProvenance returns where an imported agent's definition came from.
+func (s *Server) GetAgentProvenance(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	var agent models.Agent
+	if err := s.db.Where("id = ? AND team_id = ?", c.Params("agentId"), team.ID).First(&agent).Error; err != nil {
+		return newAPIError(CodeAgentNotFound, "agent not found")
+	}
+	var provenance models.AgentProvenance
+	if err := s.db.First(&provenance, "agent_id = ?", agent.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "agent was not imported")
+	}
+	return c.JSON(provenance)
+}
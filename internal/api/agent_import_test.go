@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestResolveProfileURL(t *testing.T) {
+	cases := map[string]string{
+		"https://github.com/acme/agents/blob/main/reviewer.md": "https://raw.githubusercontent.com/acme/agents/main/reviewer.md",
+		"https://gist.github.com/alice/0123abcd":               "https://gist.githubusercontent.com/alice/0123abcd/raw",
+		"https://example.com/profiles/reviewer.md":             "https://example.com/profiles/reviewer.md",
+	}
+	for in, want := range cases {
+		if got, err := resolveProfileURL(in); err != nil || got != want {
+			t.Errorf("%s: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"http://example.com/a.md", "example.com/a.md", "file:///etc/passwd"} {
+		if _, err := resolveProfileURL(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestParseAgentProfile(t *testing.T) {
+	p, err := parseAgentProfile("---\r\nname: reviewer\r\nmodel: opus\r\nskills:\r\n  - acme/skills:lint\r\ntools: Read, Grep\r\n---\r\n\r\n# Reviewer\r\nReview diffs.\r\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if p.Name != "reviewer" || p.Model != "opus" || p.Instructions != "# Reviewer\nReview diffs." {
+		t.Errorf("profile: got %+v", p)
+	}
+	skills, err := p.skillConfigs()
+	if err != nil || len(skills) != 1 || skills[0]["repo_url"] != "https://github.com/acme/skills" || skills[0]["skill_name"] != "lint" {
+		t.Errorf("skills: got %v, %v", skills, err)
+	}
+
+	if p, err := parseAgentProfile("Just instructions."); err != nil || p.Instructions != "Just instructions." || p.Name != "" {
+		t.Errorf("plain profile: got %+v, %v", p, err)
+	}
+	if _, err := parseAgentProfile("---\nname: x\n"); err == nil {
+		t.Error("expected an error for unclosed frontmatter")
+	}
+	if _, err := (agentProfile{Skills: []string{"https://github.com/acme/skills"}}).skillConfigs(); err == nil {
+		t.Error("expected an error for a skill without a name")
+	}
+}
+
+func TestImportAgent(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "import-team", nil)
+
+	profile := "---\nname: reviewer\ndescription: Reviews pull requests\nmodel: sonnet\nisolation: none\nskills:\n  - acme/skills:lint\n---\n\nReview every diff.\n"
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/reviewer.md":
+			w.Write([]byte(profile))
+		case "/bad-isolation.md":
+			w.Write([]byte("---\nname: bad\nisolation: container\n---\nx"))
+		case "/huge.md":
+			w.Write([]byte(strings.Repeat("x", maxAgentProfileSize+1)))
+		case "/moved.md":
+			http.Redirect(w, r, "/reviewer.md", http.StatusFound)
+		case "/insecure.md":
+			http.Redirect(w, r, "http://"+r.Host+"/reviewer.md", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	// The test server listens on loopback, which agentProfileClient refuses.
+	saved := agentProfileClient
+	agentProfileClient = ts.Client()
+	agentProfileClient.CheckRedirect = checkProfileRedirect
+	t.Cleanup(func() { agentProfileClient = saved })
+
+	tests := []struct {
+		name string
+		req  ImportAgentRequest
+		want int
+	}{
+		{"missing url", ImportAgentRequest{TeamID: team.ID}, 400},
+		{"plain http", ImportAgentRequest{URL: "http://example.com/a.md", TeamID: team.ID}, 400},
+		{"unknown team", ImportAgentRequest{URL: ts.URL + "/reviewer.md", TeamID: "missing"}, 400},
+		{"not found", ImportAgentRequest{URL: ts.URL + "/missing.md", TeamID: team.ID}, 502},
+		{"invalid frontmatter value", ImportAgentRequest{URL: ts.URL + "/bad-isolation.md", TeamID: team.ID}, 400},
+		{"too large", ImportAgentRequest{URL: ts.URL + "/huge.md", TeamID: team.ID}, 400},
+		{"redirect to http", ImportAgentRequest{URL: ts.URL + "/insecure.md", TeamID: team.ID}, 502},
+	}
+	for _, tt := range tests {
+		rec := doRequest(srv, "POST", "/api/agents/import", tt.req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d\nbody: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+		// Failed downloads do not say what the host answered.
+		if rec.Code == 502 && strings.Contains(rec.Body.String(), ts.URL) {
+			t.Errorf("%s: error leaks the upstream: %s", tt.name, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(srv, "POST", "/api/agents/import", ImportAgentRequest{URL: ts.URL + "/reviewer.md", TeamID: team.ID})
+	if rec.Code != 201 {
+		t.Fatalf("import: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var resp ImportAgentResponse
+	parseJSON(t, rec, &resp)
+	agent := resp.Agent
+	if agent.Name != "reviewer" || agent.Role != models.AgentRoleWorker || agent.InstructionsMD != "Review every diff." ||
+		agent.SubAgentDescription != "Reviews pull requests" || agent.SubAgentModel != "sonnet" || agent.SubAgentIsolation != "none" {
+		t.Errorf("imported agent: got %+v", agent)
+	}
+	var skills []map[string]string
+	json.Unmarshal(agent.SubAgentSkills, &skills)
+	if len(skills) != 1 || skills[0]["skill_name"] != "lint" {
+		t.Errorf("imported skills: got %s", agent.SubAgentSkills)
+	}
+	if resp.Provenance.SourceURL != ts.URL+"/reviewer.md" || len(resp.Provenance.ContentSHA256) != 64 {
+		t.Errorf("provenance: got %+v", resp.Provenance)
+	}
+
+	if rec := doRequest(srv, "POST", "/api/agents/import", ImportAgentRequest{URL: ts.URL + "/moved.md", TeamID: team.ID, Name: "moved"}); rec.Code != 201 {
+		t.Errorf("https redirect: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// The same profile cannot be imported twice under the same name.
+	if rec := doRequest(srv, "POST", "/api/agents/import", ImportAgentRequest{URL: ts.URL + "/reviewer.md", TeamID: team.ID}); rec.Code != 409 {
+		t.Errorf("duplicate name: got %d, want 409", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/agents/import", ImportAgentRequest{URL: ts.URL + "/reviewer.md", TeamID: team.ID, Name: "reviewer-2"}); rec.Code != 201 {
+		t.Errorf("renamed import: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/agents/"+agent.ID+"/provenance", nil)
+	var provenance models.AgentProvenance
+	parseJSON(t, rec, &provenance)
+	if provenance.ID != resp.Provenance.ID {
+		t.Errorf("get provenance: got %+v", provenance)
+	}
+}
+
+func TestAgentProfileClient_RefusesInternalAddresses(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer ts.Close()
+
+	resp, err := agentProfileClient.Get(ts.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("fetched a profile from a loopback address")
+	}
+	if !strings.Contains(err.Error(), "is internal") {
+		t.Errorf("error: got %v, want the address refused", err)
+	}
+
+	for _, addr := range []string{"127.0.0.1:443", "[::1]:443", "10.0.0.8:443", "192.168.1.1:443", "169.254.169.254:80", "[fe80::1]:443", "0.0.0.0:443", "[::ffff:10.0.0.1]:443", "100.64.0.1:443", "100.127.255.254:443"} {
+		if err := refuseInternalAddress("tcp", addr, nil); err == nil {
+			t.Errorf("%s: allowed", addr)
+		}
+	}
+	for _, addr := range []string{"140.82.112.3:443", "100.128.0.1:443"} {
+		if err := refuseInternalAddress("tcp", addr, nil); err != nil {
+			t.Errorf("public address %s: %v", addr, err)
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/models"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// Limits of agent preview sessions.
+const (
+	// previewIdleTimeout destroys a session that received no message for
+	// that long, and previewMaxLifetime any session that old.
+	previewIdleTimeout = 10 * time.Minute
+	previewMaxLifetime = 30 * time.Minute
+
+	previewStartTimeout = 3 * time.Minute
+	previewReplyTimeout = 3 * time.Minute
+
+	maxPreviewSessionsPerOrg = 3
+
+	// The preview agent's container is capped below a team leader's.
+	previewCPU    = "1"
+	previewMemory = "1g"
+)
+
+// previewSlugPrefix starts the team slug of preview sessions' resources.
+const previewSlugPrefix = "preview-"
+
+// errPreviewStart wraps the errors of starting a preview session's agent.
+var errPreviewStart = errors.New("failed to start the preview agent")
+
+// PreviewChatRequest is the payload for POST /api/agents/preview-chat.
+type PreviewChatRequest struct {
+	// SessionID continues a session started by a previous request. A new
+	// session is started when it is empty or the persona changed.
+	SessionID string `json:"session_id"`
+	// The candidate persona. At least one of SystemPrompt and
+	// InstructionsMD is required.
+	Name           string `json:"name"`
+	Specialty      string `json:"specialty"`
+	SystemPrompt   string `json:"system_prompt"`
+	InstructionsMD string `json:"instructions_md"`
+	Model          string `json:"model"` // sonnet, opus or haiku; the default model when empty
+	Message        string `json:"message"`
+}
+
+// PreviewChatResponse is the response of POST /api/agents/preview-chat.
+type PreviewChatResponse struct {
+	SessionID string    `json:"session_id"`
+	Status    string    `json:"status"` // completed or failed
+	Response  string    `json:"response,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// previewExpiryInterval is how often the owning replica ends the preview
+// sessions that expired or idled out.
+var previewExpiryInterval = time.Minute
+
+// A request using a preview session's agent holds the lease
+// previewLeasePrefix+ID, so that one message at a time is in flight per
+// session across replicas, and so that ending the session waits for it. The
+// lease outlasts starting the agent and waiting for its reply.
+const (
+	previewLeasePrefix = "preview/"
+	previewLeaseTTL    = previewStartTimeout + previewReplyTimeout
+)
+
+// previewPersona is the agent a preview session runs.
+type previewPersona struct {
+	Name           string
+	Specialty      string
+	SystemPrompt   string
+	InstructionsMD string
+	Model          string
+}
+
+// personaOf returns the persona session runs.
+func personaOf(session *models.PreviewSession) previewPersona {
+	return previewPersona{
+		Name:           session.Name,
+		Specialty:      session.Specialty,
+		SystemPrompt:   session.SystemPrompt,
+		InstructionsMD: session.InstructionsMD,
+		Model:          session.Model,
+	}
+}
+
+// previewTeam returns the team a preview session's agent runs as, which is
+// not saved.
+func previewTeam(session *models.PreviewSession) models.Team {
+	return models.Team{ID: session.ID, OrgID: session.OrgID, Name: session.Slug, Slug: session.Slug, Provider: models.ProviderClaude}
+}
+
+// findPreview returns the caller's live preview session id, or nil if it
+// does not exist, belongs to someone else, or expired.
+func (s *Server) findPreview(c *fiber.Ctx, id string) (*models.PreviewSession, error) {
+	now := time.Now().UTC()
+	var sessions []models.PreviewSession
+	err := s.db.Where("id = ? AND org_id = ? AND user_id = ? AND idle_until > ? AND expires_at > ?",
+		id, GetOrgID(c), GetUserID(c), now, now).Limit(1).Find(&sessions).Error
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load preview session")
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	return &sessions[0], nil
+}
+
+// isLivePreview reports whether resources named after slug belong to a
+// preview session that has not expired.
+func (s *Server) isLivePreview(slug string) (bool, error) {
+	now := time.Now().UTC()
+	var n int64
+	err := s.db.Model(&models.PreviewSession{}).
+		Where("slug = ? AND idle_until > ? AND expires_at > ?", slug, now, now).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// PreviewChat handles POST /api/agents/preview-chat. It sends a message to
+// an agent running a candidate persona, so that its system prompt and
+// CLAUDE.md can be tried before the agent is added to a team. The first
+// message starts a Claude agent without a workspace, with capped
+// resources; later messages with the returned session_id continue the
+// conversation, through any API replica. The session is destroyed after
+// previewIdleTimeout without messages, after previewMaxLifetime, or by
+// DELETE.
+func (s *Server) PreviewChat(c *fiber.Ctx) error {
+	var req PreviewChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "message is required")
+	}
+	if len(req.SystemPrompt) > maxInstructionsSize || len(req.InstructionsMD) > maxInstructionsSize {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("system_prompt and instructions_md may not exceed %d bytes", maxInstructionsSize))
+	}
+	if req.Model != "" && claudeModelID(req.Model) == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "model must be sonnet, opus or haiku")
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+	persona := previewPersona{
+		Name:           req.Name,
+		Specialty:      req.Specialty,
+		SystemPrompt:   req.SystemPrompt,
+		InstructionsMD: req.InstructionsMD,
+		Model:          req.Model,
+	}
+
+	// holder holds the session's lease for this request.
+	holder := uuid.New().String()
+	var session *models.PreviewSession
+	if req.SessionID != "" {
+		var err error
+		if session, err = s.findPreview(c, req.SessionID); err != nil {
+			return err
+		}
+		if session == nil {
+			return fiber.NewError(fiber.StatusNotFound, "preview session not found or expired")
+		}
+		if persona != (previewPersona{}) && persona != personaOf(session) {
+			// A changed persona needs a new agent.
+			s.endPreview(session, holder, "persona changed")
+			session = nil
+		}
+	}
+	if session == nil {
+		if persona.SystemPrompt == "" && persona.InstructionsMD == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "system_prompt or instructions_md is required")
+		}
+		var err error
+		session, err = s.startPreview(c, persona, holder)
+		if errors.Is(err, errPreviewStart) {
+			return c.Status(fiber.StatusBadGateway).JSON(PreviewChatResponse{Status: "failed", Error: err.Error()})
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		ok, err := election.Acquire(s.db, previewLeasePrefix+session.ID, holder, previewLeaseTTL, time.Now().UTC())
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to claim preview session")
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusConflict, "the preview agent is still answering the previous message")
+		}
+	}
+	defer election.Release(s.db, previewLeasePrefix+session.ID, holder)
+
+	// The session may have ended before its lease was claimed.
+	var live int64
+	if err := s.db.Model(&models.PreviewSession{}).Where("id = ?", session.ID).Count(&live).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load preview session")
+	}
+	if live == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "preview session not found or expired")
+	}
+
+	resp := PreviewChatResponse{SessionID: session.ID, ExpiresAt: session.ExpiresAt}
+	ctx, cancel := context.WithTimeout(context.Background(), previewReplyTimeout)
+	defer cancel()
+	reply, err := s.promptLeader(ctx, previewTeam(session), req.Message, uuid.New().String())
+	switch {
+	case err != nil:
+		resp.Status = "failed"
+		resp.Error = fmt.Sprintf("no response from the preview agent: %v", err)
+	case strings.HasPrefix(reply, leaderErrorPrefix):
+		resp.Status = "failed"
+		resp.Error = strings.TrimPrefix(reply, leaderErrorPrefix)
+	default:
+		resp.Status = "completed"
+		resp.Response = reply
+	}
+	idleUntil := time.Now().UTC().Add(previewIdleTimeout)
+	if idleUntil.After(session.ExpiresAt) {
+		idleUntil = session.ExpiresAt
+	}
+	s.db.Model(&models.PreviewSession{}).Where("id = ?", session.ID).Update("idle_until", idleUntil)
+	return c.JSON(resp)
+}
+
+// EndPreviewChat handles DELETE /api/agents/preview-chat/:id. It destroys
+// the preview session's agent.
+func (s *Server) EndPreviewChat(c *fiber.Ctx) error {
+	session, err := s.findPreview(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return fiber.NewError(fiber.StatusNotFound, "preview session not found or expired")
+	}
+	go s.endPreview(session, uuid.New().String(), "ended by the user")
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// startPreview saves a new preview session and starts its agent, holding
+// the session's lease as holder. Failures to start the agent wrap
+// errPreviewStart.
+func (s *Server) startPreview(c *fiber.Ctx, persona previewPersona, holder string) (*models.PreviewSession, error) {
+	id := uuid.New().String()
+	now := time.Now().UTC()
+	session := &models.PreviewSession{
+		ID:             id,
+		OrgID:          GetOrgID(c),
+		UserID:         GetUserID(c),
+		Slug:           previewSlugPrefix + id[:8],
+		Name:           persona.Name,
+		Specialty:      persona.Specialty,
+		SystemPrompt:   persona.SystemPrompt,
+		InstructionsMD: persona.InstructionsMD,
+		Model:          persona.Model,
+		IdleUntil:      now.Add(previewIdleTimeout),
+		ExpiresAt:      now.Add(previewMaxLifetime),
+	}
+	// Claimed before the session is saved, so that it cannot be ended
+	// half-started.
+	if ok, err := election.Acquire(s.db, previewLeasePrefix+id, holder, previewLeaseTTL, now); err != nil || !ok {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to start preview session")
+	}
+	full := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var n int64
+		if err := tx.Model(&models.PreviewSession{}).
+			Where("org_id = ? AND idle_until > ? AND expires_at > ?", session.OrgID, now, now).
+			Count(&n).Error; err != nil {
+			return err
+		}
+		if n >= maxPreviewSessionsPerOrg {
+			full = true
+			return nil
+		}
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		election.Release(s.db, previewLeasePrefix+id, holder)
+		slog.Error("failed to save preview session", "error", err)
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to start preview session")
+	}
+	if full {
+		election.Release(s.db, previewLeasePrefix+id, holder)
+		return nil, newAPIError(CodeRateLimited, fmt.Sprintf("the organization already has %d preview sessions; end one first", maxPreviewSessionsPerOrg))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), previewStartTimeout)
+	defer cancel()
+	if err := s.deployPreview(ctx, session); err != nil {
+		slog.Error("failed to start preview agent", "session", session.ID, "error", err)
+		s.endPreview(session, holder, "failed to start")
+		return nil, fmt.Errorf("%w: %v", errPreviewStart, err)
+	}
+
+	slog.Info("preview session started", "session", session.ID, "slug", session.Slug, "by", GetEmail(c))
+	return session, nil
+}
+
+// deployPreview deploys the NATS server and Claude agent of a preview
+// session, without a workspace and with capped resources.
+func (s *Server) deployPreview(ctx context.Context, session *models.PreviewSession) error {
+	team := previewTeam(session)
+	env := s.LoadSettingsEnv(team.OrgID)
+	natsCfg, err := runtime.NATSConfigFromEnv(env)
+	if err != nil {
+		return fmt.Errorf("invalid NATS settings: %w", err)
+	}
+	if err := s.runtime.DeployInfra(ctx, runtime.InfraConfig{
+		TeamName:    team.Name,
+		TeamID:      team.ID,
+		NATSEnabled: true,
+		NATS:        natsCfg,
+	}); err != nil {
+		return err
+	}
+	limits, err := agentNats.StreamLimitsFromEnv(env)
+	if err != nil {
+		return fmt.Errorf("invalid NATS settings: %w", err)
+	}
+	s.ensureTeamStream(ctx, team, limits)
+
+	leader := models.Agent{
+		Name:           "leader",
+		Role:           models.AgentRoleLeader,
+		Specialty:      session.Specialty,
+		SystemPrompt:   session.SystemPrompt,
+		InstructionsMD: session.InstructionsMD,
+	}
+	if session.Name != "" {
+		leader.Name = session.Name
+	}
+	if model := claudeModelID(session.Model); model != "" {
+		env["CLAUDE_MODEL"] = model
+	}
+	_, err = s.runtime.DeployAgent(ctx, runtime.AgentConfig{
+		Name:         leader.Name,
+		TeamName:     team.Name,
+		Role:         leader.Role,
+		Provider:     models.ProviderClaude,
+		SystemPrompt: leader.SystemPrompt,
+		ClaudeMD:     leaderInstructions(team, models.ProviderClaude, &leader, nil),
+		Resources: runtime.ResourceConfig{
+			CPU:     previewCPU,
+			Memory:  previewMemory,
+			Timeout: int(previewReplyTimeout.Seconds()),
+		},
+		NATSUrl: s.runtime.GetNATSURL(team.Name),
+		Env:     env,
+	})
+	return err
+}
+
+// endPreview destroys a preview session's agent and resources, once the
+// message in flight, if any, is answered. holder claims the session's
+// lease, which the caller may already hold as holder.
+func (s *Server) endPreview(session *models.PreviewSession, holder, reason string) {
+	lease := previewLeasePrefix + session.ID
+	for {
+		ok, err := election.Acquire(s.db, lease, holder, previewLeaseTTL, time.Now().UTC())
+		if err != nil {
+			slog.Error("failed to claim preview session", "session", session.ID, "error", err)
+			return
+		}
+		if ok {
+			break
+		}
+		time.Sleep(teamOpPollInterval)
+	}
+	defer s.db.Delete(&models.Lease{}, "name = ?", lease)
+
+	res := s.db.Delete(&models.PreviewSession{}, "id = ?", session.ID)
+	if res.Error != nil {
+		slog.Error("failed to end preview session", "session", session.ID, "error", res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		// Already ended.
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := s.runtime.TeardownInfra(ctx, session.Slug, runtime.TeardownOptions{}); err != nil {
+		// The garbage collector removes what is left, now that no session
+		// claims it.
+		slog.Error("failed to tear down preview session", "session", session.ID, "error", err)
+	}
+	slog.Info("preview session ended", "session", session.ID, "reason", reason)
+}
+
+// startPreviewExpiry starts the loop that ends expired preview sessions. It
+// runs on the owning replica, since the replica that started a session may
+// be gone.
+func (s *Server) startPreviewExpiry() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.previewExpiryCancel = cancel
+	s.previewExpiryWg.Add(1)
+	go func() {
+		defer s.previewExpiryWg.Done()
+		ticker := time.NewTicker(previewExpiryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.endExpiredPreviews()
+			}
+		}
+	}()
+}
+
+// stopPreviewExpiry stops the preview expiry loop, if running, and waits
+// for it.
+func (s *Server) stopPreviewExpiry() {
+	if s.previewExpiryCancel != nil {
+		s.previewExpiryCancel()
+	}
+	s.previewExpiryWg.Wait()
+}
+
+// endExpiredPreviews ends the preview sessions that idled out or reached
+// previewMaxLifetime.
+func (s *Server) endExpiredPreviews() {
+	now := time.Now().UTC()
+	var expired []models.PreviewSession
+	if err := s.db.Where("idle_until <= ? OR expires_at <= ?", now, now).Find(&expired).Error; err != nil {
+		slog.Error("failed to list expired preview sessions", "error", err)
+		return
+	}
+	for i := range expired {
+		s.endPreview(&expired[i], uuid.New().String(), "expired")
+	}
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// setupPreviewServer returns a test server whose leaders answer prompts
+// with "echo: <prompt>", and the teams prompted.
+func setupPreviewServer(t *testing.T) (*Server, *mockRuntime, func() []models.Team) {
+	t.Helper()
+	srv, mock := setupTestServer(t)
+	var mu sync.Mutex
+	var prompted []models.Team
+	srv.promptLeader = func(_ context.Context, team models.Team, prompt, _ string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		prompted = append(prompted, team)
+		return "echo: " + prompt, nil
+	}
+	return srv, mock, func() []models.Team {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]models.Team(nil), prompted...)
+	}
+}
+
+// waitTornDown waits until the runtime tore down the infrastructure of
+// slug.
+func waitTornDown(t *testing.T, mock *mockRuntime, slug string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		mock.mu.Lock()
+		for _, name := range mock.tornDown {
+			if name == slug {
+				mock.mu.Unlock()
+				return
+			}
+		}
+		mock.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("infrastructure of %s was not torn down", slug)
+}
+
+func TestPreviewChat_SessionLifecycle(t *testing.T) {
+	srv, mock, prompted := setupPreviewServer(t)
+
+	persona := PreviewChatRequest{
+		Name:         "reviewer",
+		SystemPrompt: "You are a terse code reviewer.",
+		Model:        "haiku",
+		Message:      "hello",
+	}
+	rec := doRequest(srv, "POST", "/api/agents/preview-chat", persona)
+	if rec.Code != 200 {
+		t.Fatalf("start: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var first PreviewChatResponse
+	parseJSON(t, rec, &first)
+	if first.SessionID == "" || first.Status != "completed" || first.Response != "echo: hello" || first.ExpiresAt.IsZero() {
+		t.Fatalf("first reply: got %+v", first)
+	}
+
+	infra, agent := mock.lastInfraConfig, mock.lastAgentConfig
+	if infra == nil || infra.WorkspacePath != "" || !strings.HasPrefix(infra.TeamName, previewSlugPrefix) {
+		t.Errorf("infra config: got %+v", infra)
+	}
+	if agent == nil || agent.Name != "reviewer" || agent.Resources.CPU != previewCPU || agent.Resources.Memory != previewMemory ||
+		agent.WorkspacePath != "" || agent.Env["CLAUDE_MODEL"] != claudeModelID("haiku") ||
+		!strings.Contains(agent.ClaudeMD, "terse code reviewer") {
+		t.Errorf("agent config: got %+v", agent)
+	}
+	slug := infra.TeamName
+
+	// The same session answers the next message.
+	rec = doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SessionID: first.SessionID, Message: "again"})
+	var second PreviewChatResponse
+	parseJSON(t, rec, &second)
+	if rec.Code != 200 || second.SessionID != first.SessionID || second.Response != "echo: again" {
+		t.Errorf("second reply: got %d %+v", rec.Code, second)
+	}
+	if teams := prompted(); len(teams) != 2 || teams[1].Name != slug || len(mock.deployedAgents) != 1 {
+		t.Errorf("prompted teams %+v, deployed agents %v", teams, mock.deployedAgents)
+	}
+
+	// A changed persona replaces the session.
+	persona.SessionID = first.SessionID
+	persona.SystemPrompt = "You are a chatty code reviewer."
+	rec = doRequest(srv, "POST", "/api/agents/preview-chat", persona)
+	var third PreviewChatResponse
+	parseJSON(t, rec, &third)
+	if rec.Code != 200 || third.SessionID == first.SessionID {
+		t.Fatalf("changed persona: got %d %+v", rec.Code, third)
+	}
+	waitTornDown(t, mock, slug)
+	if rec := doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SessionID: first.SessionID, Message: "hi"}); rec.Code != 404 {
+		t.Errorf("ended session: got %d, want 404", rec.Code)
+	}
+
+	if rec := doRequest(srv, "DELETE", "/api/agents/preview-chat/"+third.SessionID, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("end: got %d", rec.Code)
+	}
+	waitTornDown(t, mock, mock.lastInfraConfig.TeamName)
+}
+
+func TestPreviewChat_Validation(t *testing.T) {
+	srv, _, _ := setupPreviewServer(t)
+
+	for name, req := range map[string]PreviewChatRequest{
+		"no message": {SystemPrompt: "You are helpful."},
+		"no persona": {Message: "hello"},
+		"bad model":  {SystemPrompt: "You are helpful.", Model: "gpt-4", Message: "hello"},
+		"too large":  {InstructionsMD: strings.Repeat("x", maxInstructionsSize+1), Message: "hello"},
+	} {
+		if rec := doRequest(srv, "POST", "/api/agents/preview-chat", req); rec.Code != 400 {
+			t.Errorf("%s: got %d, want 400", name, rec.Code)
+		}
+	}
+	if rec := doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SessionID: "missing", Message: "hello"}); rec.Code != 404 {
+		t.Errorf("unknown session: got %d, want 404", rec.Code)
+	}
+}
+
+func TestPreviewChat_SessionLimit(t *testing.T) {
+	srv, _, _ := setupPreviewServer(t)
+
+	req := PreviewChatRequest{SystemPrompt: "You are helpful.", Message: "hello"}
+	for i := 0; i < maxPreviewSessionsPerOrg; i++ {
+		if rec := doRequest(srv, "POST", "/api/agents/preview-chat", req); rec.Code != 200 {
+			t.Fatalf("session %d: got %d, body: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+	rec := doRequest(srv, "POST", "/api/agents/preview-chat", req)
+	var resp ErrorResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != http.StatusTooManyRequests || resp.Code != CodeRateLimited {
+		t.Errorf("over the limit: got %d %+v", rec.Code, resp)
+	}
+}
+
+func TestPreviewChat_StartFailure(t *testing.T) {
+	srv, mock, _ := setupPreviewServer(t)
+	mock.deployAgentErr = errors.New("image not found")
+
+	rec := doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SystemPrompt: "You are helpful.", Message: "hello"})
+	var resp PreviewChatResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != http.StatusBadGateway || resp.Status != "failed" || !strings.Contains(resp.Error, "image not found") {
+		t.Errorf("start failure: got %d %+v", rec.Code, resp)
+	}
+	var sessions int64
+	srv.db.Model(&models.PreviewSession{}).Count(&sessions)
+	if !mock.teardownCalled || sessions != 0 {
+		t.Errorf("failed session was not cleaned up: teardown %v, sessions %d", mock.teardownCalled, sessions)
+	}
+}
+
+func TestPreviewChat_Expiry(t *testing.T) {
+	srv, mock, _ := setupPreviewServer(t)
+
+	rec := doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SystemPrompt: "You are helpful.", Message: "hello"})
+	var resp PreviewChatResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != 200 {
+		t.Fatalf("start: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	slug := mock.lastInfraConfig.TeamName
+	mock.teamInfra = []runtime.TeamInfra{
+		{Slug: slug, TeamID: resp.SessionID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceNetwork, Name: "team-" + slug},
+		}},
+	}
+
+	// The garbage collector leaves a live session's resources alone.
+	report, err := srv.collectInfraGarbage(context.Background(), true)
+	if err != nil || len(report.Orphans) != 0 {
+		t.Fatalf("live session: got orphans %+v, error %v", report, err)
+	}
+
+	srv.db.Model(&models.PreviewSession{}).Where("id = ?", resp.SessionID).
+		Update("idle_until", time.Now().UTC().Add(-time.Second))
+	report, err = srv.collectInfraGarbage(context.Background(), true)
+	if err != nil || len(report.Orphans) != 1 || report.Orphans[0].Reason != orphanTeamDeleted {
+		t.Fatalf("idle session: got orphans %+v, error %v", report, err)
+	}
+	if rec := doRequest(srv, "POST", "/api/agents/preview-chat", PreviewChatRequest{SessionID: resp.SessionID, Message: "hi"}); rec.Code != 404 {
+		t.Errorf("idle session: got %d, want 404", rec.Code)
+	}
+
+	srv.endExpiredPreviews()
+	waitTornDown(t, mock, slug)
+	var sessions int64
+	srv.db.Model(&models.PreviewSession{}).Count(&sessions)
+	if sessions != 0 {
+		t.Errorf("expired session was not deleted: %d sessions", sessions)
+	}
+}
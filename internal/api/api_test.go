@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/helmcode/agent-crew/internal/auth"
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
 	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
@@ -24,8 +26,26 @@ type mockRuntime struct {
 	removeAgentErr  error
 	teardownErr     error
 	deployedAgents  []string
+	removedAgents   []string
 	teardownCalled  bool
+	tornDown        []string            // team names passed to TeardownInfra
+	teardownOpts    runtime.TeardownOptions
+	preserved       []string // team names torn down with PreserveWorkspace
+	deletedWorkspaces []string          // team names passed to DeleteWorkspace
+	teamInfra       []runtime.TeamInfra // reported by ListTeamInfra
+	prePulled       [][]string          // images passed to PrePullImages
+	teamHost        string              // reported by TeamHost
 	lastAgentConfig *runtime.AgentConfig
+	lastInfraConfig *runtime.InfraConfig
+	agentStatus     string // status reported by GetStatus; "running" when empty
+	statusErr       error  // returned by GetStatus
+	restartCount    int    // reported by GetStatus
+	stoppedAgents   []string // ids passed to StopAgent
+	failImage       string // DeployAgent fails for agents on this image
+	execErr         error             // returned by ExecInContainer
+	execOutput      string            // returned by ExecInContainer; "mock exec output" when empty
+	execCommands    [][]string        // commands passed to ExecInContainer
+	copiedFiles     map[string][]byte // contents passed to CopyToContainer, by path
 
 	// Ollama mock state.
 	ensureOllamaErr        error
@@ -36,7 +56,10 @@ type mockRuntime struct {
 	ollamaRunning          bool
 }
 
-func (m *mockRuntime) DeployInfra(_ context.Context, _ runtime.InfraConfig) error {
+func (m *mockRuntime) DeployInfra(_ context.Context, cfg runtime.InfraConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastInfraConfig = &cfg
 	return m.deployInfraErr
 }
 
@@ -46,6 +69,9 @@ func (m *mockRuntime) DeployAgent(_ context.Context, cfg runtime.AgentConfig) (*
 	if m.deployAgentErr != nil {
 		return nil, m.deployAgentErr
 	}
+	if m.failImage != "" && cfg.Image == m.failImage {
+		return nil, fmt.Errorf("pulling image %s: not found", cfg.Image)
+	}
 	m.deployedAgents = append(m.deployedAgents, cfg.Name)
 	m.lastAgentConfig = &cfg
 	return &runtime.AgentInstance{
@@ -55,27 +81,77 @@ func (m *mockRuntime) DeployAgent(_ context.Context, cfg runtime.AgentConfig) (*
 	}, nil
 }
 
-func (m *mockRuntime) StopAgent(_ context.Context, _ string) error {
+func (m *mockRuntime) StopAgent(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stoppedAgents = append(m.stoppedAgents, id)
 	return m.stopAgentErr
 }
 
-func (m *mockRuntime) RemoveAgent(_ context.Context, _ string) error {
+func (m *mockRuntime) RemoveAgent(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removedAgents = append(m.removedAgents, id)
 	return m.removeAgentErr
 }
 
 func (m *mockRuntime) GetStatus(_ context.Context, id string) (*runtime.AgentStatus, error) {
-	return &runtime.AgentStatus{ID: id, Name: "test", Status: "running"}, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.statusErr != nil {
+		return nil, m.statusErr
+	}
+	status := m.agentStatus
+	if status == "" {
+		status = "running"
+	}
+	return &runtime.AgentStatus{ID: id, Name: "test", Status: status, RestartCount: m.restartCount}, nil
+}
+
+func (m *mockRuntime) ImageDigest(_ context.Context, id string) (string, error) {
+	return "ghcr.io/helmcode/agent_crew_agent@sha256:" + id, nil
 }
 
 func (m *mockRuntime) StreamLogs(_ context.Context, _ string) (io.ReadCloser, error) {
 	return io.NopCloser(strings.NewReader("log line")), nil
 }
 
-func (m *mockRuntime) TeardownInfra(_ context.Context, _ string) error {
+func (m *mockRuntime) TeardownInfra(_ context.Context, teamName string, opts runtime.TeardownOptions) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.teardownCalled = true
+	m.tornDown = append(m.tornDown, teamName)
+	m.teardownOpts = opts
+	if opts.PreserveWorkspace {
+		m.preserved = append(m.preserved, teamName)
+	}
 	return m.teardownErr
 }
 
+func (m *mockRuntime) DeleteWorkspace(_ context.Context, teamName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deletedWorkspaces = append(m.deletedWorkspaces, teamName)
+	return nil
+}
+
+func (m *mockRuntime) ListTeamInfra(_ context.Context) ([]runtime.TeamInfra, error) {
+	return m.teamInfra, nil
+}
+
+func (m *mockRuntime) PrePullImages(_ context.Context, images []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prePulled = append(m.prePulled, images)
+	return nil
+}
+
+func (m *mockRuntime) TeamHost(_ string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.teamHost
+}
+
 func (m *mockRuntime) GetNATSURL(teamName string) string {
 	return "nats://team-" + teamName + "-nats:4222"
 }
@@ -84,8 +160,14 @@ func (m *mockRuntime) GetNATSConnectURL(_ context.Context, teamName string) (str
 	return "nats://127.0.0.1:14222", nil
 }
 
-func (m *mockRuntime) ExecInContainer(_ context.Context, _ string, _ []string) (string, error) {
-	return "mock exec output", nil
+func (m *mockRuntime) ExecInContainer(_ context.Context, _ string, cmd []string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.execCommands = append(m.execCommands, cmd)
+	if m.execOutput != "" {
+		return m.execOutput, m.execErr
+	}
+	return "mock exec output", m.execErr
 }
 
 func (m *mockRuntime) ReadFile(_ context.Context, _ string, path string) ([]byte, error) {
@@ -99,7 +181,13 @@ func (m *mockRuntime) WriteFile(_ context.Context, _ string, path string, _ []by
 	return runtime.ValidateAgentFilePath(path)
 }
 
-func (m *mockRuntime) CopyToContainer(_ context.Context, _ string, _ string, _ []byte) error {
+func (m *mockRuntime) CopyToContainer(_ context.Context, _ string, path string, content []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.copiedFiles == nil {
+		m.copiedFiles = make(map[string][]byte)
+	}
+	m.copiedFiles[path] = content
 	return nil
 }
 
@@ -168,6 +256,8 @@ func setupTestServer(t *testing.T) (*Server, *mockRuntime) {
 		t.Fatalf("NewNoopProvider: %v", err)
 	}
 	srv := NewServer(db, mock, noopAuth)
+	srv.StartJobs(0)
+	t.Cleanup(srv.StopJobs)
 	return srv, mock
 }
 
@@ -200,6 +290,42 @@ func parseJSON(t *testing.T, rec *httptest.ResponseRecorder, target interface{})
 	}
 }
 
+// parseList unmarshals a list response envelope and returns its items.
+func parseList[T any](t *testing.T, rec *httptest.ResponseRecorder) []T {
+	t.Helper()
+	var resp ListResponse[T]
+	parseJSON(t, rec, &resp)
+	return resp.Items
+}
+
+// createTestTeam creates a team with a leader agent through the API.
+func createTestTeam(t *testing.T, srv *Server, name string) models.Team {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   name,
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create team: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	return team
+}
+
+// createRunningTeam creates a team with createTestTeam and marks it as
+// running, with its leader up in containerID.
+func createRunningTeam(t *testing.T, srv *Server, name, containerID string) models.Team {
+	t.Helper()
+	team := createTestTeam(t, srv, name)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	srv.db.Model(&models.Agent{}).Where("team_id = ?", team.ID).Updates(map[string]interface{}{
+		"container_id":     containerID,
+		"container_status": models.ContainerStatusRunning,
+	})
+	return team
+}
+
 // --- Team CRUD ---
 
 func TestCreateTeam(t *testing.T) {
@@ -253,33 +379,6 @@ func TestCreateTeam_HumanFriendlyName(t *testing.T) {
 	}
 }
 
-func TestSanitizeName(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"Test", "test"},
-		{"My Team", "my-team"},
-		{"Agent Squad!", "agent-squad"},
-		{"  Hello World  ", "hello-world"},
-		{"UPPERCASE", "uppercase"},
-		{"with---multiple---dashes", "with-multiple-dashes"},
-		{"special@#chars$%", "specialchars"},
-		{"already-valid", "already-valid"},
-		{"under_scores", "under_scores"},
-		{"123numeric", "123numeric"},
-		{"   ", "team"},       // all whitespace -> fallback
-		{"@#$%", "team"},     // all invalid -> fallback
-	}
-
-	for _, tt := range tests {
-		got := SanitizeName(tt.input)
-		if got != tt.expected {
-			t.Errorf("SanitizeName(%q) = %q, want %q", tt.input, got, tt.expected)
-		}
-	}
-}
-
 func TestCreateTeam_MissingName(t *testing.T) {
 	srv, _ := setupTestServer(t)
 
@@ -348,8 +447,7 @@ func TestListTeams(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var teams []models.Team
-	parseJSON(t, rec, &teams)
+	teams := parseList[models.Team](t, rec)
 	if len(teams) != 2 {
 		t.Fatalf("teams: got %d, want 2", len(teams))
 	}
@@ -363,8 +461,7 @@ func TestListTeams_Empty(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var teams []models.Team
-	parseJSON(t, rec, &teams)
+	teams := parseList[models.Team](t, rec)
 	if len(teams) != 0 {
 		t.Fatalf("teams: got %d, want 0", len(teams))
 	}
@@ -564,8 +661,7 @@ func TestListAgents(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var agents []models.Agent
-	parseJSON(t, rec, &agents)
+	agents := parseList[models.Agent](t, rec)
 	if len(agents) != 3 {
 		t.Fatalf("agents: got %d, want 3", len(agents))
 	}
@@ -685,6 +781,218 @@ func TestUpdateAgent_RenameConflict(t *testing.T) {
 	}
 }
 
+func TestAgentRunAs(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "run-as-team", WorkspacePath: t.TempDir()})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	uid, gid, negative, root := 1000, 1001, -5, 0
+	for _, req := range []CreateAgentRequest{
+		{Name: "bad-uid", RunAsUID: &negative},
+		{Name: "root-uid", RunAsUID: &root},
+		{Name: "gid-only", RunAsGID: &gid},
+	} {
+		rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", req)
+		if rec.Code != 400 {
+			t.Errorf("%s: got %d, want 400", req.Name, rec.Code)
+		}
+	}
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", CreateAgentRequest{
+		Name: "leader", Role: "leader", RunAsUID: &uid, RunAsGID: &gid, SkipWorkspaceChown: true,
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var agent models.Agent
+	parseJSON(t, rec, &agent)
+	if agent.RunAsUID == nil || *agent.RunAsUID != 1000 || agent.RunAsGID == nil || *agent.RunAsGID != 1001 {
+		t.Errorf("run_as: got %v/%v", agent.RunAsUID, agent.RunAsGID)
+	}
+
+	parseJSON(t, doRequest(srv, "GET", "/api/teams/"+team.ID, nil), &team)
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if cfg := mock.lastAgentConfig; cfg.RunAsUID == nil || *cfg.RunAsUID != 1000 || *cfg.RunAsGID != 1001 {
+		t.Errorf("runtime config run_as: got %v/%v", cfg.RunAsUID, cfg.RunAsGID)
+	}
+	if !mock.lastAgentConfig.SkipWorkspaceChown {
+		t.Error("runtime config should skip the workspace chown")
+	}
+
+	// Clearing the UID while the GID stays set is rejected.
+	clear := -1
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+agent.ID, UpdateAgentRequest{RunAsUID: &clear})
+	if rec.Code != 400 {
+		t.Errorf("clear uid only: got %d, want 400", rec.Code)
+	}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+agent.ID, UpdateAgentRequest{RunAsUID: &clear, RunAsGID: &clear})
+	if rec.Code != 200 {
+		t.Fatalf("clear: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var cleared models.Agent
+	parseJSON(t, rec, &cleared)
+	if cleared.RunAsUID != nil || cleared.RunAsGID != nil {
+		t.Errorf("run_as after clear: got %v/%v", cleared.RunAsUID, cleared.RunAsGID)
+	}
+
+	chown := false
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+agent.ID, UpdateAgentRequest{SkipWorkspaceChown: &chown})
+	if rec.Code != 200 {
+		t.Fatalf("update skip_workspace_chown: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &cleared)
+	if cleared.SkipWorkspaceChown {
+		t.Error("skip_workspace_chown should be cleared")
+	}
+}
+
+func TestAgentExtraClaudeArgs(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "extra-args-bad",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader", ExtraClaudeArgs: []string{"--dangerously-skip-permissions"}}},
+	})
+	if rec.Code != 400 {
+		t.Errorf("create team with disallowed flag: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "extra-args-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader", ExtraClaudeArgs: []string{"--max-thinking-tokens", "8000"}}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	leader := team.Agents[0]
+	if string(leader.ExtraClaudeArgs) != `["--max-thinking-tokens","8000"]` {
+		t.Errorf("extra_claude_args: got %s", leader.ExtraClaudeArgs)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if got := mock.lastAgentConfig.Env["CLAUDE_EXTRA_ARGS"]; got != `["--max-thinking-tokens","8000"]` {
+		t.Errorf("CLAUDE_EXTRA_ARGS: got %q", got)
+	}
+
+	for _, args := range [][]string{{"--max-turns"}, {"--resume", "sess-1"}} {
+		rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+leader.ID, UpdateAgentRequest{ExtraClaudeArgs: &args})
+		if rec.Code != 400 {
+			t.Errorf("update to %q: got %d, want 400", args, rec.Code)
+		}
+	}
+	args := []string{"--add-dir", "/data"}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+leader.ID, UpdateAgentRequest{ExtraClaudeArgs: &args})
+	var updated models.Agent
+	parseJSON(t, rec, &updated)
+	if string(updated.ExtraClaudeArgs) != `["--add-dir","/data"]` {
+		t.Errorf("updated extra_claude_args: got %s", updated.ExtraClaudeArgs)
+	}
+
+	args = []string{}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+leader.ID, UpdateAgentRequest{ExtraClaudeArgs: &args})
+	parseJSON(t, rec, &updated)
+	if hasExtraClaudeArgs(updated) {
+		t.Errorf("cleared extra_claude_args: got %s", updated.ExtraClaudeArgs)
+	}
+	parseJSON(t, doRequest(srv, "GET", "/api/teams/"+team.ID, nil), &team)
+	srv.deployTeamAsync(t.Context(), team)
+	if got, ok := mock.lastAgentConfig.Env["CLAUDE_EXTRA_ARGS"]; ok {
+		t.Errorf("CLAUDE_EXTRA_ARGS after clear: got %q", got)
+	}
+}
+
+func TestRestartAgent(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "restart-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}, {Name: "worker"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	var leader, worker models.Agent
+	for _, a := range team.Agents {
+		if a.Role == models.AgentRoleLeader {
+			leader = a
+		} else {
+			worker = a
+		}
+	}
+	base := "/api/teams/" + team.ID + "/agents/"
+
+	rec := doRequest(srv, "POST", base+leader.ID+"/restart", nil)
+	if rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	srv.db.Model(&leader).Updates(map[string]interface{}{
+		"container_id":     "old-container",
+		"container_status": models.ContainerStatusRunning,
+	})
+
+	rec = doRequest(srv, "POST", base+worker.ID+"/restart", nil)
+	if rec.Code != 400 {
+		t.Errorf("worker: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", base+leader.ID+"/restart", RestartAgentRequest{PullImage: true})
+	if rec.Code != 202 {
+		t.Fatalf("restart: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// The job finishes once the restart and its events are recorded.
+	waitForJob(t, srv, team.ID, jobLeaderRestart, models.JobStatusSucceeded)
+	var current models.Team
+	srv.db.First(&current, "id = ?", team.ID)
+	if current.Status != models.TeamStatusRunning {
+		t.Fatalf("team status: got %q (%s), want running", current.Status, current.StatusMessage)
+	}
+	if current.ConversationID == team.ConversationID {
+		t.Error("expected a new conversation after restart")
+	}
+
+	mock.mu.Lock()
+	removed := mock.removedAgents
+	cfg := mock.lastAgentConfig
+	mock.mu.Unlock()
+	if len(removed) != 1 || removed[0] != "old-container" {
+		t.Errorf("removed containers: got %v, want [old-container]", removed)
+	}
+	if cfg == nil || !cfg.PullImage {
+		t.Errorf("expected the leader to be redeployed with PullImage, got %+v", cfg)
+	}
+
+	var restarted models.Agent
+	srv.db.First(&restarted, "id = ?", leader.ID)
+	if restarted.ContainerID != "container-leader" || restarted.ContainerStatus != models.ContainerStatusRunning {
+		t.Errorf("leader container: got %q/%q", restarted.ContainerID, restarted.ContainerStatus)
+	}
+
+	var events []models.TaskLog
+	srv.db.Where("team_id = ? AND message_type = ?", team.ID, string(protocol.TypeDeploymentEvent)).
+		Order("created_at, id").Find(&events)
+	statuses := map[string]bool{}
+	for _, e := range events {
+		var p protocol.DeploymentEventPayload
+		json.Unmarshal(e.Payload, &p)
+		if p.Action != protocol.DeploymentActionRestart || p.AgentName != "leader" {
+			t.Errorf("unexpected event payload: %+v", p)
+		}
+		statuses[p.Status] = true
+	}
+	if len(events) != 2 || !statuses[protocol.DeploymentStatusStarted] || !statuses[protocol.DeploymentStatusSucceeded] {
+		t.Errorf("deployment events: got %d (%v)", len(events), statuses)
+	}
+}
+
 func TestDeleteAgent(t *testing.T) {
 	srv, _ := setupTestServer(t)
 
@@ -775,6 +1083,25 @@ func TestUpdateSettings_MissingKey(t *testing.T) {
 	}
 }
 
+func TestUpdateSettings_ValidatesNATSLimits(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	for key, value := range map[string]string{
+		"NATS_JETSTREAM_MAX_STORE": "lots",
+		"NATS_STREAM_MAX_AGE":      "a day",
+		"NATS_STREAM_MAX_MSGS":     "many",
+	} {
+		rec := doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: key, Value: value})
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), key) {
+			t.Errorf("%s=%q: got %d %s, want 400", key, value, rec.Code, rec.Body.String())
+		}
+	}
+	rec := doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: "NATS_JETSTREAM_MAX_STORE", Value: "2GB"})
+	if rec.Code != 200 {
+		t.Errorf("valid size: got %d %s, want 200", rec.Code, rec.Body.String())
+	}
+}
+
 func TestGetSettings_AfterCreation(t *testing.T) {
 	srv, _ := setupTestServer(t)
 
@@ -846,8 +1173,7 @@ func TestGetMessages(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 1 {
 		t.Fatalf("messages: got %d, want 1", len(logs))
 	}
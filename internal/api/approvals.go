@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/events"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// approvalListOptions configures GET /api/approvals: newest first,
+// filterable by status, action and team.
+var approvalListOptions = listOptions{
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status", "action": "action", "team_id": "team_id"},
+}
+
+func approvalKey(a models.Approval) (time.Time, string) { return a.CreatedAt, a.ID }
+
+// migrateApprovalParams are the Params of a migration's approval request.
+type migrateApprovalParams struct {
+	Target string `json:"target"`
+}
+
+// restartApprovalParams are the Params of a leader restart's approval
+// request.
+type restartApprovalParams struct {
+	AgentID   string `json:"agent_id"`
+	PullImage bool   `json:"pull_image"`
+}
+
+// approvalTeamOps are the team operations that approved requests run as.
+var approvalTeamOps = map[string]string{
+	models.ApprovalActionDeploy:  teamOpDeploy,
+	models.ApprovalActionMigrate: teamOpMigrate,
+	models.ApprovalActionRestart: teamOpRestart,
+}
+
+// requestApproval records a member's request for an operation on the team
+// that needs an admin's approval, and responds 202 with it. params, if not
+// nil, are the operation's arguments. A pending request for the same
+// operation and arguments is returned instead of a new one.
+func (s *Server) requestApproval(c *fiber.Ctx, team models.Team, action string, params interface{}) error {
+	var req RequestApprovalRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	var raw models.JSON
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to encode approval request")
+		}
+		raw = models.JSON(data)
+	}
+
+	var approval models.Approval
+	query := s.db.Where("team_id = ? AND action = ? AND status = ?", team.ID, action, models.ApprovalStatusPending)
+	if raw != nil {
+		query = query.Where("params = ?", string(raw))
+	}
+	if err := query.First(&approval).Error; err == nil {
+		return c.Status(fiber.StatusAccepted).JSON(approval)
+	}
+
+	approval = models.Approval{
+		ID:               uuid.New().String(),
+		OrgID:            team.OrgID,
+		TeamID:           team.ID,
+		Action:           action,
+		Status:           models.ApprovalStatusPending,
+		Params:           raw,
+		Reason:           req.Reason,
+		RequestedBy:      GetUserID(c),
+		RequestedByEmail: GetEmail(c),
+	}
+	if err := s.db.Create(&approval).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create approval request")
+	}
+	slog.Info("approval requested", "team", team.Name, "action", action,
+		"approval_id", approval.ID, "requested_by", approval.RequestedByEmail)
+	s.events.Publish(events.ApprovalRequested{
+		ApprovalID:       approval.ID,
+		OrgID:            approval.OrgID,
+		TeamID:           team.ID,
+		TeamName:         team.Name,
+		Action:           action,
+		RequestedByEmail: approval.RequestedByEmail,
+		Reason:           approval.Reason,
+	})
+	return c.Status(fiber.StatusAccepted).JSON(approval)
+}
+
+// ListApprovals returns the organization's approval requests.
+func (s *Server) ListApprovals(c *fiber.Ctx) error {
+	q, err := parseListQuery(c, approvalListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Scopes(OrgScope(c)), q, approvalKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list approvals")
+	}
+	return c.JSON(resp)
+}
+
+// GetApproval returns an approval request.
+func (s *Server) GetApproval(c *fiber.Ctx) error {
+	var approval models.Approval
+	if err := s.db.Scopes(OrgScope(c)).First(&approval, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "approval not found")
+	}
+	return c.JSON(approval)
+}
+
+// ApproveApproval handles POST /api/approvals/:id/approve (admin only). It
+// claims the pending approval, so that no other admin decides it meanwhile,
+// then runs the approved operation and records its job. If the operation
+// cannot start, the approval is pending again.
+func (s *Server) ApproveApproval(c *fiber.Ctx) error {
+	approval, req, err := s.loadPendingApproval(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	opName, ok := approvalTeamOps[approval.Action]
+	if !ok {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("unknown approval action %q", approval.Action))
+	}
+	team, op, err := s.lockTeamID(c, approval.TeamID, opName)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(team.ID, op)
+
+	if err := s.claimApproval(c, &approval, models.ApprovalStatusApproved, req.Note); err != nil {
+		return err
+	}
+
+	var job *models.Job
+	switch approval.Action {
+	case models.ApprovalActionDeploy:
+		if team.Status == models.TeamStatusRunning {
+			err = fiber.NewError(fiber.StatusConflict, "team is already running")
+		} else if err = s.checkDeployable(&team); err == nil {
+			job, err = s.startDeploy(&team, deploymentTrigger{Trigger: models.DeploymentTriggerApproval, TriggeredBy: approval.RequestedBy})
+		}
+	case models.ApprovalActionMigrate:
+		job, err = s.approveMigration(op.ctx, &team, approval)
+	case models.ApprovalActionRestart:
+		job, err = s.approveLeaderRestart(&team, approval)
+	}
+	if err != nil {
+		s.releaseApproval(approval)
+		return err
+	}
+
+	approval.JobID = job.ID
+	if err := s.db.Model(&models.Approval{}).Where("id = ?", approval.ID).Update("job_id", job.ID).Error; err != nil {
+		slog.Error("failed to record approval job", "approval_id", approval.ID, "job_id", job.ID, "error", err)
+	}
+	return s.announceDecision(c, team, approval)
+}
+
+// approveMigration starts the migration of an approved request, after
+// checking again that the team can move to the requested target.
+func (s *Server) approveMigration(ctx context.Context, team *models.Team, approval models.Approval) (*models.Job, error) {
+	m, ok := s.runtime.(*runtime.MultiRuntime)
+	if !ok {
+		return nil, newAPIError(CodeNotImplemented, "only one runtime is configured; list the others in EXTRA_RUNTIMES")
+	}
+	var params migrateApprovalParams
+	if err := json.Unmarshal(approval.Params, &params); err != nil || !m.Has(params.Target) {
+		return nil, fiber.NewError(fiber.StatusConflict, "approval request has no valid target runtime")
+	}
+	source := migrationSource(m, *team)
+	if source == params.Target {
+		return nil, newAPIError(CodeBadRequest, "team already runs on "+params.Target)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	for _, check := range s.migrationChecks(ctx, m, *team, params.Target) {
+		if check.Status == protocol.ValidationError {
+			return nil, fiber.NewError(fiber.StatusConflict, "team cannot be migrated: "+check.Message)
+		}
+	}
+	return s.startMigration(team, source, params.Target, approval.RequestedBy)
+}
+
+// approveLeaderRestart starts the leader restart of an approved request,
+// after checking again that the team is running.
+func (s *Server) approveLeaderRestart(team *models.Team, approval models.Approval) (*models.Job, error) {
+	var params restartApprovalParams
+	if err := json.Unmarshal(approval.Params, &params); err != nil {
+		return nil, fiber.NewError(fiber.StatusConflict, "approval request has no valid agent")
+	}
+	var agent models.Agent
+	if err := s.db.Where("id = ? AND team_id = ? AND role = ?", params.AgentID, team.ID, models.AgentRoleLeader).
+		First(&agent).Error; err != nil {
+		return nil, newAPIError(CodeAgentNotFound, "leader not found")
+	}
+	if team.Status != models.TeamStatusRunning && team.Status != models.TeamStatusError {
+		return nil, newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	return s.startLeaderRestart(team, agent, params.PullImage, approval.RequestedBy)
+}
+
+// RejectApproval handles POST /api/approvals/:id/reject (admin only).
+func (s *Server) RejectApproval(c *fiber.Ctx) error {
+	approval, req, err := s.loadPendingApproval(c)
+	if err != nil {
+		return err
+	}
+	var team models.Team
+	s.db.Select("id", "name").First(&team, "id = ?", approval.TeamID)
+	if err := s.claimApproval(c, &approval, models.ApprovalStatusRejected, req.Note); err != nil {
+		return err
+	}
+	return s.announceDecision(c, team, approval)
+}
+
+// loadPendingApproval checks that the user is an admin and loads the
+// pending approval of the request with the decision's payload.
+func (s *Server) loadPendingApproval(c *fiber.Ctx) (models.Approval, DecideApprovalRequest, error) {
+	var approval models.Approval
+	var req DecideApprovalRequest
+	if !IsAdmin(c) {
+		return approval, req, newAPIError(CodeAdminRequired, "only admins can decide approval requests")
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return approval, req, newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+	if err := s.db.Scopes(OrgScope(c)).First(&approval, "id = ?", c.Params("id")).Error; err != nil {
+		return approval, req, fiber.NewError(fiber.StatusNotFound, "approval not found")
+	}
+	if approval.Status != models.ApprovalStatusPending {
+		return approval, req, fiber.NewError(fiber.StatusConflict, "approval request is already "+approval.Status)
+	}
+	return approval, req, nil
+}
+
+// claimApproval records the decision on a pending approval. A request
+// decided concurrently by another admin fails with 409.
+func (s *Server) claimApproval(c *fiber.Ctx, approval *models.Approval, status, note string) error {
+	now := time.Now()
+	res := s.db.Model(&models.Approval{}).
+		Where("id = ? AND status = ?", approval.ID, models.ApprovalStatusPending).
+		Updates(map[string]interface{}{
+			"status":           status,
+			"decided_by":       GetUserID(c),
+			"decided_by_email": GetEmail(c),
+			"decided_at":       now,
+			"note":             note,
+		})
+	if res.Error != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to record decision")
+	}
+	if res.RowsAffected == 0 {
+		return fiber.NewError(fiber.StatusConflict, "approval request was already decided")
+	}
+	approval.Status = status
+	approval.DecidedBy = GetUserID(c)
+	approval.DecidedByEmail = GetEmail(c)
+	approval.DecidedAt = &now
+	approval.Note = note
+	return nil
+}
+
+// releaseApproval makes an approval claimed by ApproveApproval pending
+// again, when its operation could not start.
+func (s *Server) releaseApproval(approval models.Approval) {
+	err := s.db.Model(&models.Approval{}).
+		Where("id = ? AND status = ?", approval.ID, approval.Status).
+		Updates(map[string]interface{}{
+			"status":           models.ApprovalStatusPending,
+			"decided_by":       "",
+			"decided_by_email": "",
+			"decided_at":       nil,
+			"note":             "",
+		}).Error
+	if err != nil {
+		slog.Error("failed to release approval", "approval_id", approval.ID, "error", err)
+	}
+}
+
+// announceDecision logs and publishes a recorded decision, and responds
+// with the approval.
+func (s *Server) announceDecision(c *fiber.Ctx, team models.Team, approval models.Approval) error {
+	slog.Info("approval decided", "team", team.Name, "action", approval.Action,
+		"approval_id", approval.ID, "status", approval.Status, "decided_by", approval.DecidedByEmail)
+	s.events.Publish(events.ApprovalDecided{
+		ApprovalID:     approval.ID,
+		OrgID:          approval.OrgID,
+		TeamID:         approval.TeamID,
+		TeamName:       team.Name,
+		Action:         approval.Action,
+		Status:         approval.Status,
+		DecidedByEmail: approval.DecidedByEmail,
+		Note:           approval.Note,
+	})
+	return c.JSON(approval)
+}
+
+// notifyApprovalRequested notifies the alert integrations of a pending
+// approval request.
+func (s *Server) notifyApprovalRequested(ev events.ApprovalRequested) {
+	approval := models.Approval{
+		ID:               ev.ApprovalID,
+		OrgID:            ev.OrgID,
+		TeamID:           ev.TeamID,
+		Action:           ev.Action,
+		Reason:           ev.Reason,
+		RequestedByEmail: ev.RequestedByEmail,
+	}
+	go s.alertMonitor.NotifyApprovalRequested(context.Background(), approval, ev.TeamName)
+}
+
+// resolveApprovalNotice resolves the alert integrations' notification of a
+// decided approval request.
+func (s *Server) resolveApprovalNotice(ev events.ApprovalDecided) {
+	approval := models.Approval{ID: ev.ApprovalID, OrgID: ev.OrgID, TeamID: ev.TeamID}
+	note := fmt.Sprintf("%s %s by %s", ev.Action, ev.Status, ev.DecidedByEmail)
+	go s.alertMonitor.ResolveApproval(context.Background(), approval, note)
+}
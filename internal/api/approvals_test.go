@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// memberAuth wraps the noop provider so that requests authenticate as a
+// member of the default organization.
+type memberAuth struct {
+	auth.AuthProvider
+}
+
+func (a memberAuth) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := a.AuthProvider.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	member := *claims
+	member.UserID = "member-1"
+	member.Email = "member@example.com"
+	member.Role = models.UserRoleMember
+	return &member, nil
+}
+
+// setupApprovalTeam creates a team that requires deploy approval and
+// returns the admin server, a member's server and the team.
+func setupApprovalTeam(t *testing.T) (*Server, *Server, models.Team) {
+	t.Helper()
+	admin, _ := setupTestServer(t)
+	rec := doRequest(admin, "POST", "/api/teams", CreateTeamRequest{
+		Name:                   "prod-team",
+		RequiresDeployApproval: true,
+		Agents:                 []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create team: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if !team.RequiresDeployApproval {
+		t.Fatal("requires_deploy_approval was not saved")
+	}
+	member := NewServer(admin.db, &mockRuntime{}, memberAuth{admin.authProvider})
+	return admin, member, team
+}
+
+// requestDeploy deploys the team as a member and returns the pending
+// approval.
+func requestDeploy(t *testing.T, member *Server, team models.Team) models.Approval {
+	t.Helper()
+	rec := doRequest(member, "POST", "/api/teams/"+team.ID+"/deploy", RequestApprovalRequest{Reason: "release 1.2"})
+	if rec.Code != 202 {
+		t.Fatalf("member deploy: got %d, want 202, body: %s", rec.Code, rec.Body.String())
+	}
+	var approval models.Approval
+	parseJSON(t, rec, &approval)
+	if approval.Status != models.ApprovalStatusPending || approval.Action != models.ApprovalActionDeploy ||
+		approval.RequestedByEmail != "member@example.com" || approval.Reason != "release 1.2" {
+		t.Fatalf("approval: got %+v", approval)
+	}
+	return approval
+}
+
+func TestDeployApproval_MemberRequestAdminApproves(t *testing.T) {
+	admin, member, team := setupApprovalTeam(t)
+
+	approval := requestDeploy(t, member, team)
+	var stored models.Team
+	admin.db.First(&stored, "id = ?", team.ID)
+	if stored.Status != models.TeamStatusStopped {
+		t.Errorf("team status before approval: got %s, want stopped", stored.Status)
+	}
+
+	// A second request returns the pending one.
+	if again := requestDeploy(t, member, team); again.ID != approval.ID {
+		t.Errorf("second request: got approval %s, want %s", again.ID, approval.ID)
+	}
+
+	if rec := doRequest(member, "POST", "/api/approvals/"+approval.ID+"/approve", nil); rec.Code != 403 {
+		t.Errorf("member approve: got %d, want 403", rec.Code)
+	}
+
+	rec := doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/approve", DecideApprovalRequest{Note: "go ahead"})
+	if rec.Code != 200 {
+		t.Fatalf("approve: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var decided models.Approval
+	parseJSON(t, rec, &decided)
+	if decided.Status != models.ApprovalStatusApproved || decided.DecidedAt == nil ||
+		decided.Note != "go ahead" || decided.JobID == "" {
+		t.Errorf("decided approval: got %+v", decided)
+	}
+	job := waitForJob(t, admin, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+	if job.ID != decided.JobID {
+		t.Errorf("deploy job: got %s, want %s", job.ID, decided.JobID)
+	}
+
+	if rec := doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/reject", nil); rec.Code != 409 {
+		t.Errorf("reject after approval: got %d, want 409", rec.Code)
+	}
+}
+
+func TestDeployApproval_FailedStartKeepsItPending(t *testing.T) {
+	admin, member, team := setupApprovalTeam(t)
+	approval := requestDeploy(t, member, team)
+
+	// The approval is claimed before the deploy starts; a deploy that
+	// cannot start leaves it pending for another decision.
+	admin.db.Model(&models.Team{}).Where("id = ?", team.ID).Update("status", models.TeamStatusRunning)
+	if rec := doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/approve", nil); rec.Code != 409 {
+		t.Fatalf("approve running team: got %d, want 409", rec.Code)
+	}
+	var stored models.Approval
+	admin.db.First(&stored, "id = ?", approval.ID)
+	if stored.Status != models.ApprovalStatusPending || stored.DecidedAt != nil || stored.DecidedBy != "" {
+		t.Errorf("approval after failed start: got %+v", stored)
+	}
+
+	admin.db.Model(&models.Team{}).Where("id = ?", team.ID).Update("status", models.TeamStatusStopped)
+	if rec := doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/approve", nil); rec.Code != 200 {
+		t.Fatalf("approve: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, admin, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+	admin.db.First(&stored, "id = ?", approval.ID)
+	if stored.Status != models.ApprovalStatusApproved || stored.JobID == "" {
+		t.Errorf("approved approval: got %+v", stored)
+	}
+}
+
+func TestDeployApproval_Reject(t *testing.T) {
+	admin, member, team := setupApprovalTeam(t)
+	approval := requestDeploy(t, member, team)
+
+	rec := doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/reject", DecideApprovalRequest{Note: "not today"})
+	if rec.Code != 200 {
+		t.Fatalf("reject: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var stored models.Approval
+	admin.db.First(&stored, "id = ?", approval.ID)
+	if stored.Status != models.ApprovalStatusRejected || stored.Note != "not today" || stored.JobID != "" {
+		t.Errorf("rejected approval: got %+v", stored)
+	}
+	var jobCount int64
+	admin.db.Model(&models.Job{}).Where("team_id = ?", team.ID).Count(&jobCount)
+	if jobCount != 0 {
+		t.Errorf("jobs after rejection: got %d, want 0", jobCount)
+	}
+
+	var page struct {
+		Items []models.Approval `json:"items"`
+	}
+	parseJSON(t, doRequest(member, "GET", "/api/approvals?status=rejected&team_id="+team.ID, nil), &page)
+	if len(page.Items) != 1 || page.Items[0].ID != approval.ID {
+		t.Errorf("list rejected approvals: got %+v", page.Items)
+	}
+}
+
+func TestDeployApproval_AdminDeploysDirectly(t *testing.T) {
+	admin, _, team := setupApprovalTeam(t)
+
+	rec := doRequest(admin, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	if rec.Code != 200 {
+		t.Fatalf("admin deploy: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, admin, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+}
+
+func TestDeployApproval_OnlyAdminsTurnItOff(t *testing.T) {
+	admin, member, team := setupApprovalTeam(t)
+
+	off := false
+	rec := doRequest(member, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{RequiresDeployApproval: &off})
+	if rec.Code != 403 {
+		t.Errorf("member turning it off: got %d, want 403", rec.Code)
+	}
+	rec = doRequest(admin, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{RequiresDeployApproval: &off})
+	if rec.Code != 200 {
+		t.Fatalf("admin turning it off: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(member, "POST", "/api/teams/"+team.ID+"/deploy", nil); rec.Code != 200 {
+		t.Errorf("member deploy without approval: got %d, want 200", rec.Code)
+	}
+}
+
+func TestLeaderRestartApproval(t *testing.T) {
+	admin, member, team := setupApprovalTeam(t)
+	leader := team.Agents[0]
+	admin.db.Model(&team).Update("status", models.TeamStatusRunning)
+	admin.db.Model(&leader).Updates(map[string]interface{}{
+		"container_id":     "old-container",
+		"container_status": models.ContainerStatusRunning,
+	})
+
+	path := "/api/teams/" + team.ID + "/agents/" + leader.ID + "/restart"
+	rec := doRequest(member, "POST", path, RestartAgentRequest{PullImage: true})
+	if rec.Code != 202 {
+		t.Fatalf("member restart: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var approval models.Approval
+	parseJSON(t, rec, &approval)
+	if approval.Action != models.ApprovalActionRestart || approval.Status != models.ApprovalStatusPending {
+		t.Fatalf("approval: got %+v", approval)
+	}
+	var stored models.Team
+	admin.db.First(&stored, "id = ?", team.ID)
+	if stored.Status != models.TeamStatusRunning {
+		t.Errorf("team status before approval: got %s, want running", stored.Status)
+	}
+
+	rec = doRequest(admin, "POST", "/api/approvals/"+approval.ID+"/approve", nil)
+	if rec.Code != 200 {
+		t.Fatalf("approve: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &approval)
+	job := waitForJob(t, admin, team.ID, jobLeaderRestart, models.JobStatusSucceeded)
+	if job.ID != approval.JobID {
+		t.Errorf("restart job: got %s, want %s", job.ID, approval.JobID)
+	}
+	var payload leaderRestartPayload
+	if err := jobs.Decode(&job, &payload); err != nil || payload.AgentID != leader.ID || !payload.PullImage || payload.RequestedBy != "member-1" {
+		t.Errorf("restart payload: got %+v, %v", payload, err)
+	}
+}
@@ -0,0 +1,394 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/guardrails"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// responseStatusBlocked is the status a leader_response is persisted with
+// when an output content policy blocked it.
+const responseStatusBlocked = "blocked"
+
+// contentPolicyCheckTimeout bounds checking one text against an
+// organization's policies, including moderation model calls.
+const contentPolicyCheckTimeout = 30 * time.Second
+
+var policyViolationListOptions = listOptions{
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Newest:       true,
+	Filters: map[string]string{
+		"team_id":   "team_id",
+		"policy_id": "policy_id",
+		"direction": "direction",
+		"kind":      "kind",
+	},
+}
+
+func policyViolationKey(v models.PolicyViolation) (time.Time, string) { return v.CreatedAt, v.ID }
+
+// contentPolicyBlock is the policy and rule that blocked a text.
+type contentPolicyBlock struct {
+	Policy    models.ContentPolicy
+	Violation guardrails.Violation
+}
+
+// policyRules converts a stored policy to the rules the guardrails check.
+func policyRules(p models.ContentPolicy) guardrails.Rules {
+	rules := guardrails.Rules{Moderation: p.Moderation}
+	json.Unmarshal(p.DenyTerms, &rules.DenyTerms)
+	json.Unmarshal(p.Patterns, &rules.Patterns)
+	return rules
+}
+
+// openAIModerator returns the moderator of an organization, authenticated
+// with its OPENAI_API_KEY setting. It is the default Server.moderator.
+func (s *Server) openAIModerator(orgID string) guardrails.Moderator {
+	return guardrails.NewOpenAIModerator(s.LoadSettingsEnv(orgID)["OPENAI_API_KEY"])
+}
+
+// checkContentPolicies checks text against the organization's enabled
+// policies for direction and returns the first block, or nil. Policies fail
+// closed: a moderation call that errors blocks the text, and an error
+// loading the policies is returned for the caller to refuse the text.
+func (s *Server) checkContentPolicies(ctx context.Context, orgID, direction, text string) (*contentPolicyBlock, error) {
+	var policies []models.ContentPolicy
+	if err := s.db.Where("org_id = ? AND enabled = ? AND direction IN ?", orgID, true,
+		[]string{direction, guardrails.DirectionBoth}).Order("name").Find(&policies).Error; err != nil {
+		slog.Error("content policies: failed to load policies", "org_id", orgID, "error", err)
+		return nil, err
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, contentPolicyCheckTimeout)
+	defer cancel()
+	var moderator guardrails.Moderator
+	for _, p := range policies {
+		rules := policyRules(p)
+		if rules.Moderation && moderator == nil {
+			moderator = s.moderator(orgID)
+		}
+		v, err := rules.Check(ctx, text, moderator)
+		if err != nil {
+			slog.Error("content policies: check failed, blocking", "policy", p.Name, "direction", direction, "error", err)
+			v = &guardrails.Violation{Kind: guardrails.KindModeration, Rule: "check failed: " + err.Error()}
+		}
+		if v != nil {
+			return &contentPolicyBlock{Policy: p, Violation: *v}, nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckPromptPolicies checks a prompt sent to team's leader outside of
+// chat, by a webhook, issue comment or schedule, against the organization's
+// input policies. A blocked prompt is recorded as a violation of runID, if
+// set, and refused with a 422; policies that cannot be loaded refuse it
+// with a 500.
+func (s *Server) CheckPromptPolicies(ctx context.Context, team models.Team, runID, prompt string) error {
+	block, err := s.checkContentPolicies(ctx, team.OrgID, guardrails.DirectionInput, prompt)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check content policies")
+	}
+	if block == nil {
+		return nil
+	}
+	slog.Warn("prompt blocked by content policy", "team", team.Name, "policy", block.Policy.Name)
+	s.recordPolicyViolation(team.ID, guardrails.DirectionInput, runID, block)
+	return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("Prompt blocked by content policy %q", block.Policy.Name))
+}
+
+// recordPolicyViolation saves a violation record for a blocked message.
+func (s *Server) recordPolicyViolation(teamID, direction, messageID string, block *contentPolicyBlock) {
+	violation := models.PolicyViolation{
+		ID:         uuid.New().String(),
+		OrgID:      block.Policy.OrgID,
+		TeamID:     teamID,
+		PolicyID:   block.Policy.ID,
+		PolicyName: block.Policy.Name,
+		Direction:  direction,
+		Kind:       block.Violation.Kind,
+		Rule:       block.Violation.Rule,
+		MessageID:  messageID,
+	}
+	if err := s.db.Create(&violation).Error; err != nil {
+		slog.Error("content policies: failed to record violation", "policy", block.Policy.Name, "error", err)
+	}
+}
+
+// blockedChat saves a user message an input policy blocked, without its
+// content, and tells the user which policy blocked it.
+func (s *Server) blockedChat(c *fiber.Ctx, team models.Team, block *contentPolicyBlock) error {
+	text := fmt.Sprintf("Message blocked by content policy %q", block.Policy.Name)
+	content, _ := json.Marshal(map[string]interface{}{"content": text, "policy": block.Policy.Name})
+	taskLog := models.TaskLog{
+		ID:             uuid.New().String(),
+		TeamID:         team.ID,
+		ConversationID: team.ConversationID,
+		FromAgent:      "user",
+		ToAgent:        "leader",
+		MessageType:    "user_message",
+		Payload:        models.JSON(content),
+		DeliveryStatus: models.ChatDeliveryBlocked,
+	}
+	if err := s.taskLogs.Create(&taskLog); err != nil {
+		slog.Error("chat: failed to save task log", "team", team.Name, "error", err)
+	}
+	s.recordPolicyViolation(team.ID, guardrails.DirectionInput, taskLog.ID, block)
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"status":  models.ChatDeliveryBlocked,
+		"message": text,
+		"id":      taskLog.ID,
+		"policy":  block.Policy.Name,
+	})
+}
+
+// applyOutputPolicies checks a leader_response against the team's output
+// policies. A blocked response keeps its run IDs but is persisted with the
+// blocked status and without its result or plan.
+func (s *Server) applyOutputPolicies(teamID, teamName string, msg *protocol.Message) {
+	var payload protocol.LeaderResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return
+	}
+	text := payload.Result
+	if payload.Plan != nil {
+		text = strings.TrimSpace(text + "\n" + payload.Plan.Text)
+	}
+	if text == "" {
+		return
+	}
+	var team models.Team
+	if err := s.db.Select("id", "org_id").First(&team, "id = ?", teamID).Error; err != nil {
+		return
+	}
+	block, err := s.checkContentPolicies(context.Background(), team.OrgID, guardrails.DirectionOutput, text)
+	switch {
+	case err != nil:
+		// Fail closed, like a moderation call that errors.
+		payload.Error = "Response blocked: content policies could not be checked"
+	case block != nil:
+		slog.Warn("relay: leader response blocked by content policy", "team", teamName, "policy", block.Policy.Name)
+		s.recordPolicyViolation(teamID, guardrails.DirectionOutput, msg.MessageID, block)
+		payload.Error = fmt.Sprintf("Response blocked by content policy %q", block.Policy.Name)
+	default:
+		return
+	}
+	payload.Status = responseStatusBlocked
+	payload.Result = ""
+	payload.Plan = nil
+	payload.ErrorCode = ""
+	msg.Payload, _ = json.Marshal(payload)
+}
+
+// ListContentPolicies returns the organization's content policies.
+func (s *Server) ListContentPolicies(c *fiber.Ctx) error {
+	var list []models.ContentPolicy
+	if err := s.db.Scopes(OrgScope(c)).Order("name").Find(&list).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list content policies")
+	}
+	return c.JSON(list)
+}
+
+// GetContentPolicy returns a single content policy by ID.
+func (s *Server) GetContentPolicy(c *fiber.Ctx) error {
+	var policy models.ContentPolicy
+	if err := s.db.Scopes(OrgScope(c)).First(&policy, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "content policy not found")
+	}
+	return c.JSON(policy)
+}
+
+// CreateContentPolicy creates a content policy (admin only). It applies to
+// the next message or response checked.
+func (s *Server) CreateContentPolicy(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage content policies")
+	}
+	var req CreateContentPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if err := validateContentPolicyName(req.Name); err != nil {
+		return err
+	}
+	if !guardrails.ValidDirection(req.Direction) {
+		return fiber.NewError(fiber.StatusBadRequest, "direction must be one of input, output, both")
+	}
+	rules := guardrails.Rules{DenyTerms: req.DenyTerms, Patterns: req.Patterns, Moderation: req.Moderation}
+	if err := rules.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	if err := s.checkContentPolicyNameFree(c, req.Name, ""); err != nil {
+		return err
+	}
+
+	denyTerms, _ := json.Marshal(nonNilStrings(req.DenyTerms))
+	patterns, _ := json.Marshal(nonNilStrings(req.Patterns))
+	policy := models.ContentPolicy{
+		ID:         uuid.New().String(),
+		OrgID:      GetOrgID(c),
+		Name:       req.Name,
+		Direction:  req.Direction,
+		DenyTerms:  models.JSON(denyTerms),
+		Patterns:   models.JSON(patterns),
+		Moderation: req.Moderation,
+		Enabled:    enabled,
+	}
+	if err := s.db.Create(&policy).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create content policy")
+	}
+	return c.Status(fiber.StatusCreated).JSON(policy)
+}
+
+// UpdateContentPolicy updates a content policy's fields (admin only).
+func (s *Server) UpdateContentPolicy(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage content policies")
+	}
+	id := c.Params("id")
+	var policy models.ContentPolicy
+	if err := s.db.Scopes(OrgScope(c)).First(&policy, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "content policy not found")
+	}
+	var req UpdateContentPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if err := validateContentPolicyName(*req.Name); err != nil {
+			return err
+		}
+		if err := s.checkContentPolicyNameFree(c, *req.Name, policy.ID); err != nil {
+			return err
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Direction != nil {
+		if !guardrails.ValidDirection(*req.Direction) {
+			return fiber.NewError(fiber.StatusBadRequest, "direction must be one of input, output, both")
+		}
+		updates["direction"] = *req.Direction
+	}
+	rules := policyRules(policy)
+	if req.DenyTerms != nil {
+		rules.DenyTerms = *req.DenyTerms
+	}
+	if req.Patterns != nil {
+		rules.Patterns = *req.Patterns
+	}
+	if req.Moderation != nil {
+		rules.Moderation = *req.Moderation
+		updates["moderation"] = *req.Moderation
+	}
+	if err := rules.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.DenyTerms != nil {
+		denyTerms, _ := json.Marshal(nonNilStrings(*req.DenyTerms))
+		updates["deny_terms"] = models.JSON(denyTerms)
+	}
+	if req.Patterns != nil {
+		patterns, _ := json.Marshal(nonNilStrings(*req.Patterns))
+		updates["patterns"] = models.JSON(patterns)
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&policy).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update content policy")
+		}
+	}
+	s.db.Scopes(OrgScope(c)).First(&policy, "id = ?", id)
+	return c.JSON(policy)
+}
+
+// DeleteContentPolicy removes a content policy (admin only). Its violation
+// records are kept.
+func (s *Server) DeleteContentPolicy(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage content policies")
+	}
+	var policy models.ContentPolicy
+	if err := s.db.Scopes(OrgScope(c)).First(&policy, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "content policy not found")
+	}
+	if err := s.db.Delete(&policy).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete content policy")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListPolicyViolations handles GET /api/content-policies/violations (admin
+// only). It returns the organization's violation records, newest first.
+func (s *Server) ListPolicyViolations(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view policy violations")
+	}
+	q, err := parseListQuery(c, policyViolationListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Scopes(OrgScope(c)), q, policyViolationKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list policy violations")
+	}
+	return c.JSON(resp)
+}
+
+func validateContentPolicyName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+	}
+	if len(name) > 255 {
+		return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+	}
+	return nil
+}
+
+// checkContentPolicyNameFree returns a 409 if another policy in the
+// organization already uses name.
+func (s *Server) checkContentPolicyNameFree(c *fiber.Ctx, name, exceptID string) error {
+	var count int64
+	q := s.db.Model(&models.ContentPolicy{}).Scopes(OrgScope(c)).Where("name = ?", name)
+	if exceptID != "" {
+		q = q.Where("id <> ?", exceptID)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check content policy name")
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, "a content policy with this name already exists")
+	}
+	return nil
+}
+
+// nonNilStrings returns list, or an empty list if it is nil, so it is
+// stored as [] rather than null.
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -19,6 +19,12 @@ func GetUserID(c *fiber.Ctx) string {
 	return v
 }
 
+// GetEmail extracts the user's email from the request context.
+func GetEmail(c *fiber.Ctx) string {
+	v, _ := c.Locals("email").(string)
+	return v
+}
+
 // GetRole extracts the user role from the request context.
 func GetRole(c *fiber.Ctx) string {
 	v, _ := c.Locals("role").(string)
@@ -30,6 +36,12 @@ func IsAdmin(c *fiber.Ctx) bool {
 	return GetRole(c) == models.UserRoleAdmin
 }
 
+// IsObserver returns true if the authenticated user has the read-only
+// observer role.
+func IsObserver(c *fiber.Ctx) bool {
+	return GetRole(c) == models.UserRoleObserver
+}
+
 // OrgScope returns a GORM scope that filters queries by the request's org_id.
 func OrgScope(c *fiber.Ctx) func(db *gorm.DB) *gorm.DB {
 	return func(db *gorm.DB) *gorm.DB {
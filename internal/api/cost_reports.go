@@ -0,0 +1,199 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"gorm.io/gorm/clause"
+)
+
+// Team label limits.
+const (
+	maxTeamLabels           = 20
+	maxTeamLabelValueLength = 256
+)
+
+// defaultCostReportDays is the range of a cost report without from.
+const defaultCostReportDays = 30
+
+// teamLabelKeyPattern matches label keys such as "project" or
+// "example.com/cost-center".
+var teamLabelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]{0,62}$`)
+
+// validateTeamLabels checks the number of labels and the format of their
+// keys and values.
+func validateTeamLabels(labels map[string]string) error {
+	if len(labels) > maxTeamLabels {
+		return fmt.Errorf("labels: at most %d labels are allowed", maxTeamLabels)
+	}
+	for k, v := range labels {
+		if !teamLabelKeyPattern.MatchString(k) {
+			return fmt.Errorf("labels: invalid key %q: must be 1-63 letters, digits, '.', '_', '-' or '/', starting with a letter or digit", k)
+		}
+		if len(v) > maxTeamLabelValueLength {
+			return fmt.Errorf("labels: value of %q exceeds %d characters", k, maxTeamLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// recordUsage saves the usage reported by a team's sidecar, along with the
+// team's current name and labels, as record id. A record already saved with
+// that id is left alone (see relayRecordID).
+func (s *Server) recordUsage(teamID, id string, usage protocol.UsagePayload) error {
+	var team models.Team
+	if err := s.db.Select("id", "org_id", "name", "labels", "conversation_id").First(&team, "id = ?", teamID).Error; err != nil {
+		slog.Warn("relay: dropping usage of unknown team", "team_id", teamID, "error", err)
+		return nil
+	}
+	record := models.UsageRecord{
+		ID:               id,
+		OrgID:            team.OrgID,
+		TeamID:           team.ID,
+		TeamName:         team.Name,
+		TeamLabels:       team.Labels,
+		ConversationID:   team.ConversationID,
+		AgentName:        usage.AgentName,
+		CostUSD:          usage.CostUSD,
+		InputTokens:      usage.InputTokens,
+		OutputTokens:     usage.OutputTokens,
+		CacheReadTokens:  usage.CacheReadTokens,
+		CacheWriteTokens: usage.CacheWriteTokens,
+		// SQLite compares times as text, so keep them all in UTC.
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		slog.Error("relay: failed to save usage", "team", team.Name, "error", err)
+		return err
+	}
+	return nil
+}
+
+// parseReportTime parses a report bound given as a date (2006-01-02) or an
+// RFC 3339 time. A date used as the end of the range includes that whole day.
+func parseReportTime(v string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// costReportRow is the usage of one team with one set of labels.
+type costReportRow struct {
+	TeamID           string
+	TeamName         string
+	TeamLabels       models.JSON
+	CostUSD          float64
+	InputTokens      int64
+	OutputTokens     int64
+	CacheReadTokens  int64
+	CacheWriteTokens int64
+	Turns            int64
+}
+
+// GetCostReport handles GET /api/reports/cost. It sums the organization's
+// usage between from (inclusive) and to (exclusive; a date includes that
+// day), grouped by team (group_by=team, the default) or by the value of a
+// team label (group_by=label:<key>). Usage of teams without the label is
+// grouped under an empty key.
+func (s *Server) GetCostReport(c *fiber.Ctx) error {
+	groupBy := c.Query("group_by", "team")
+	labelKey, byLabel := strings.CutPrefix(groupBy, "label:")
+	if byLabel && !teamLabelKeyPattern.MatchString(labelKey) {
+		return fiber.NewError(fiber.StatusBadRequest, "group_by label key is invalid")
+	}
+	if !byLabel && groupBy != "team" {
+		return fiber.NewError(fiber.StatusBadRequest, "group_by must be 'team' or 'label:<key>'")
+	}
+
+	to := time.Now().UTC()
+	if v := c.Query("to"); v != "" {
+		t, err := parseReportTime(v, true)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "to must be a date (YYYY-MM-DD) or an RFC 3339 time")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultCostReportDays)
+	if v := c.Query("from"); v != "" {
+		t, err := parseReportTime(v, false)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "from must be a date (YYYY-MM-DD) or an RFC 3339 time")
+		}
+		from = t
+	}
+	if !from.Before(to) {
+		return fiber.NewError(fiber.StatusBadRequest, "from must be before to")
+	}
+
+	var rows []costReportRow
+	if err := s.db.Model(&models.UsageRecord{}).Scopes(OrgScope(c)).
+		Select("team_id, team_name, team_labels, SUM(cost_usd) AS cost_usd, SUM(input_tokens) AS input_tokens, "+
+			"SUM(output_tokens) AS output_tokens, SUM(cache_read_tokens) AS cache_read_tokens, "+
+			"SUM(cache_write_tokens) AS cache_write_tokens, COUNT(*) AS turns").
+		Where("created_at >= ? AND created_at < ?", from.UTC(), to.UTC()).
+		Group("team_id, team_name, team_labels").
+		Scan(&rows).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to build cost report")
+	}
+
+	report := CostReport{GroupBy: groupBy, From: from, To: to, Groups: []CostReportGroup{}}
+	groups := make(map[string]*CostReportGroup)
+	for _, r := range rows {
+		key := r.TeamName
+		if byLabel {
+			var labels map[string]string
+			_ = json.Unmarshal(r.TeamLabels, &labels)
+			key = labels[labelKey]
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &CostReportGroup{Key: key}
+			groups[key] = g
+		}
+		g.add(r)
+		if !slices.Contains(g.Teams, r.TeamName) {
+			g.Teams = append(g.Teams, r.TeamName)
+		}
+		report.Total.add(r)
+	}
+	for _, g := range groups {
+		sort.Strings(g.Teams)
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		return a.Key < b.Key
+	})
+	return c.JSON(report)
+}
+
+// add sums a row into the group.
+func (g *CostReportGroup) add(r costReportRow) {
+	g.CostUSD += r.CostUSD
+	g.InputTokens += r.InputTokens
+	g.OutputTokens += r.OutputTokens
+	g.CacheReadTokens += r.CacheReadTokens
+	g.CacheWriteTokens += r.CacheWriteTokens
+	g.Turns += r.Turns
+}
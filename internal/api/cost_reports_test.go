@@ -0,0 +1,148 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// createLabeledTeam creates a team with the given labels and returns it.
+func createLabeledTeam(t *testing.T, srv *Server, name string, labels map[string]string) models.Team {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: name, Labels: labels})
+	if rec.Code != 201 {
+		t.Fatalf("create team %q: got %d, body: %s", name, rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	return team
+}
+
+// relayUsage feeds a usage message for the team through the relay.
+func relayUsage(t *testing.T, srv *Server, team models.Team, costUSD float64, inputTokens int64) {
+	t.Helper()
+	data := buildRelayPayload(t, protocol.TypeUsage, "leader", "system",
+		protocol.UsagePayload{AgentName: "leader", CostUSD: costUSD, InputTokens: inputTokens, OutputTokens: 10})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+}
+
+func TestTeamLabels(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "bad-labels", Labels: map[string]string{"-project": "x"}})
+	if rec.Code != 400 {
+		t.Errorf("invalid key: got %d, want 400", rec.Code)
+	}
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "long-label", Labels: map[string]string{"project": strings.Repeat("x", maxTeamLabelValueLength+1)}})
+	if rec.Code != 400 {
+		t.Errorf("long value: got %d, want 400", rec.Code)
+	}
+
+	team := createLabeledTeam(t, srv, "labeled", map[string]string{"project": "apollo"})
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{Labels: map[string]string{"project": "gemini", "cost-center": "eng"}})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var updated struct {
+		Labels map[string]string `json:"labels"`
+	}
+	parseJSON(t, rec, &updated)
+	if updated.Labels["project"] != "gemini" || updated.Labels["cost-center"] != "eng" {
+		t.Errorf("labels: got %v", updated.Labels)
+	}
+
+	// An empty object removes the labels.
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{Labels: map[string]string{}})
+	updated.Labels = nil
+	parseJSON(t, rec, &updated)
+	if len(updated.Labels) != 0 {
+		t.Errorf("labels after clearing: got %v", updated.Labels)
+	}
+}
+
+func TestProcessRelayMessage_Usage(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "usage-team", map[string]string{"project": "apollo"})
+
+	relayUsage(t, srv, team, 0.25, 1000)
+
+	if n := countRelayLogs(t, srv, team.ID); n != 0 {
+		t.Errorf("usage should not be saved as a task log, got %d logs", n)
+	}
+	var record models.UsageRecord
+	if err := srv.db.First(&record, "team_id = ?", team.ID).Error; err != nil {
+		t.Fatalf("usage record: %v", err)
+	}
+	if record.OrgID != team.OrgID || record.TeamName != "usage-team" || record.CostUSD != 0.25 ||
+		record.InputTokens != 1000 || !strings.Contains(string(record.TeamLabels), "apollo") {
+		t.Errorf("usage record: got %+v", record)
+	}
+}
+
+func TestGetCostReport(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	apollo1 := createLabeledTeam(t, srv, "apollo-api", map[string]string{"project": "apollo"})
+	apollo2 := createLabeledTeam(t, srv, "apollo-web", map[string]string{"project": "apollo"})
+	gemini := createLabeledTeam(t, srv, "gemini", map[string]string{"project": "gemini"})
+	unlabeled := createLabeledTeam(t, srv, "scratch", nil)
+
+	relayUsage(t, srv, apollo1, 1.0, 100)
+	relayUsage(t, srv, apollo1, 0.5, 100)
+	relayUsage(t, srv, apollo2, 2.0, 100)
+	relayUsage(t, srv, gemini, 0.75, 100)
+	relayUsage(t, srv, unlabeled, 0.1, 100)
+
+	// Relabeling a team does not move the usage it already reported.
+	doRequest(srv, "PUT", "/api/teams/"+gemini.ID, UpdateTeamRequest{Labels: map[string]string{"project": "apollo"}})
+
+	rec := doRequest(srv, "GET", "/api/reports/cost?group_by=label:project", nil)
+	if rec.Code != 200 {
+		t.Fatalf("report: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var report CostReport
+	parseJSON(t, rec, &report)
+	if len(report.Groups) != 3 {
+		t.Fatalf("groups: got %+v", report.Groups)
+	}
+	apollo := report.Groups[0]
+	if apollo.Key != "apollo" || apollo.CostUSD != 3.5 || apollo.Turns != 3 || apollo.InputTokens != 300 ||
+		len(apollo.Teams) != 2 || apollo.Teams[0] != "apollo-api" {
+		t.Errorf("apollo group: got %+v", apollo)
+	}
+	if report.Groups[1].Key != "gemini" || report.Groups[2].Key != "" {
+		t.Errorf("group order: got %q, %q", report.Groups[1].Key, report.Groups[2].Key)
+	}
+	if report.Total.Turns != 5 || report.Total.CostUSD < 4.349 || report.Total.CostUSD > 4.351 {
+		t.Errorf("total: got %+v", report.Total)
+	}
+
+	rec = doRequest(srv, "GET", "/api/reports/cost", nil)
+	parseJSON(t, rec, &report)
+	if report.GroupBy != "team" || len(report.Groups) != 4 || report.Groups[0].Key != "apollo-web" {
+		t.Errorf("report by team: got %+v", report.Groups)
+	}
+
+	// A range before the usage was recorded is empty.
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(time.DateOnly)
+	rec = doRequest(srv, "GET", "/api/reports/cost?from=2020-01-01&to="+time.Now().UTC().AddDate(0, 0, -2).Format(time.DateOnly), nil)
+	parseJSON(t, rec, &report)
+	if len(report.Groups) != 0 {
+		t.Errorf("past range: got %+v", report.Groups)
+	}
+	rec = doRequest(srv, "GET", "/api/reports/cost?from="+yesterday, nil)
+	parseJSON(t, rec, &report)
+	if report.Total.Turns != 5 {
+		t.Errorf("range from yesterday: got %d turns, want 5", report.Total.Turns)
+	}
+
+	for _, q := range []string{"group_by=owner", "group_by=label:", "from=yesterday", "from=2030-01-02&to=2030-01-01"} {
+		if rec := doRequest(srv, "GET", "/api/reports/cost?"+q, nil); rec.Code != 400 {
+			t.Errorf("%s: got %d, want 400", q, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Dead-letter retry policy: pending letters are retried on every tick once
+// due, with exponential backoff from deadLetterBaseDelay up to
+// deadLetterMaxDelay, and marked failed after deadLetterMaxAttempts.
+var (
+	deadLetterRetryInterval = 30 * time.Second
+	deadLetterBaseDelay     = 30 * time.Second
+	deadLetterMaxDelay      = 30 * time.Minute
+)
+
+const deadLetterMaxAttempts = 8
+
+// errMalformedRelayMessage marks relay messages that can never be persisted,
+// so they are dead-lettered as failed instead of being retried.
+var errMalformedRelayMessage = errors.New("malformed relay message")
+
+// deadLetterDelay returns the wait before retry number attempt (1-based).
+func deadLetterDelay(attempt int) time.Duration {
+	d := deadLetterBaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= deadLetterMaxDelay {
+			return deadLetterMaxDelay
+		}
+	}
+	return d
+}
+
+// recordDeadLetter stores a relay message that processRelayMessage failed to
+// persist. The letter goes to the same database, so when that is down the
+// insert fails too; the raw message is then logged so it is not dropped
+// silently.
+func (s *Server) recordDeadLetter(teamID, teamName, subject string, data []byte, cause error) {
+	letter := models.DeadLetter{
+		ID:       uuid.New().String(),
+		TeamID:   teamID,
+		TeamName: teamName,
+		Subject:  subject,
+		Data:     string(data),
+		Error:    cause.Error(),
+		Status:   models.DeadLetterStatusPending,
+	}
+	if errors.Is(cause, errMalformedRelayMessage) {
+		letter.Status = models.DeadLetterStatusFailed
+	} else {
+		next := time.Now().Add(deadLetterDelay(1))
+		letter.NextAttemptAt = &next
+	}
+
+	if err := s.db.Create(&letter).Error; err != nil {
+		slog.Error("relay: failed to dead-letter message", "team", teamName,
+			"subject", subject, "cause", cause, "error", err, "data", string(data))
+		return
+	}
+	slog.Warn("relay: message dead-lettered", "team", teamName, "id", letter.ID,
+		"status", letter.Status, "error", cause)
+}
+
+// StartDeadLetterRetrier starts the background loop that redelivers pending
+// dead letters.
+func (s *Server) StartDeadLetterRetrier() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.deadLetterCancel = cancel
+	s.deadLetterWg.Add(1)
+	go func() {
+		defer s.deadLetterWg.Done()
+		ticker := time.NewTicker(deadLetterRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.retryDeadLetters(ctx)
+			}
+		}
+	}()
+	slog.Info("dead-letter retrier started", "interval", deadLetterRetryInterval.String())
+}
+
+// stopDeadLetterRetrier stops the retry loop, if running, and waits for it.
+func (s *Server) stopDeadLetterRetrier() {
+	if s.deadLetterCancel != nil {
+		s.deadLetterCancel()
+	}
+	s.deadLetterWg.Wait()
+}
+
+// retryDeadLetters redelivers every pending dead letter that is due.
+func (s *Server) retryDeadLetters(ctx context.Context) {
+	var letters []models.DeadLetter
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.DeadLetterStatusPending, time.Now()).
+		Order("created_at ASC").Find(&letters).Error; err != nil {
+		slog.Error("dead-letter: failed to load pending letters", "error", err)
+		return
+	}
+	for i := range letters {
+		if ctx.Err() != nil {
+			return
+		}
+		s.redeliverDeadLetter(&letters[i])
+	}
+}
+
+// redeliverDeadLetter replays a dead letter through processRelayMessage and
+// records the outcome on it.
+func (s *Server) redeliverDeadLetter(letter *models.DeadLetter) {
+	attempts := letter.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts}
+
+	err := s.processRelayMessage(letter.TeamID, letter.TeamName, []byte(letter.Data))
+	switch {
+	case err == nil:
+		updates["status"] = models.DeadLetterStatusDelivered
+		updates["next_attempt_at"] = nil
+		slog.Info("dead-letter: redelivered", "team", letter.TeamName, "id", letter.ID, "attempts", attempts)
+	case errors.Is(err, errMalformedRelayMessage) || attempts >= deadLetterMaxAttempts:
+		updates["status"] = models.DeadLetterStatusFailed
+		updates["next_attempt_at"] = nil
+		updates["error"] = err.Error()
+		slog.Error("dead-letter: giving up", "team", letter.TeamName, "id", letter.ID, "attempts", attempts, "error", err)
+	default:
+		updates["next_attempt_at"] = time.Now().Add(deadLetterDelay(attempts + 1))
+		updates["error"] = err.Error()
+		slog.Warn("dead-letter: retry failed", "team", letter.TeamName, "id", letter.ID, "attempts", attempts, "error", err)
+	}
+
+	if err := s.db.Model(letter).Updates(updates).Error; err != nil {
+		slog.Error("dead-letter: failed to update letter", "id", letter.ID, "error", err)
+	}
+}
+
+// deadLetterListOptions configures GET /api/admin/dead-letters: newest first,
+// filterable by status and team.
+var deadLetterListOptions = listOptions{
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status", "team_id": "team_id"},
+}
+
+func deadLetterKey(l models.DeadLetter) (time.Time, string) { return l.CreatedAt, l.ID }
+
+// orgDeadLetters returns a query for the dead letters of the request's
+// organization's teams.
+func (s *Server) orgDeadLetters(c *fiber.Ctx) *gorm.DB {
+	teamIDs := s.db.Model(&models.Team{}).Scopes(OrgScope(c)).Select("id")
+	return s.db.Where("team_id IN (?)", teamIDs)
+}
+
+// ListDeadLetters returns relay messages that could not be persisted (admin only).
+func (s *Server) ListDeadLetters(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view dead letters")
+	}
+	q, err := parseListQuery(c, deadLetterListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.orgDeadLetters(c), q, deadLetterKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list dead letters")
+	}
+	return c.JSON(resp)
+}
+
+// RetryDeadLetter redelivers a dead letter immediately, including failed
+// ones (admin only).
+func (s *Server) RetryDeadLetter(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can retry dead letters")
+	}
+	var letter models.DeadLetter
+	if err := s.orgDeadLetters(c).First(&letter, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "dead letter not found")
+	}
+	if letter.Status == models.DeadLetterStatusDelivered {
+		return fiber.NewError(fiber.StatusConflict, "dead letter already delivered")
+	}
+
+	s.redeliverDeadLetter(&letter)
+	s.db.First(&letter, "id = ?", letter.ID)
+	return c.JSON(letter)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestDeadLetterDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, deadLetterBaseDelay},
+		{2, 2 * deadLetterBaseDelay},
+		{3, 4 * deadLetterBaseDelay},
+		{20, deadLetterMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := deadLetterDelay(tt.attempt); got != tt.want {
+			t.Errorf("deadLetterDelay(%d): got %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestRecordDeadLetter_MalformedIsFailed(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "dl-malformed-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := []byte("not json")
+	err := srv.processRelayMessage(team.ID, team.Name, data)
+	if !errors.Is(err, errMalformedRelayMessage) {
+		t.Fatalf("expected errMalformedRelayMessage, got %v", err)
+	}
+	srv.recordDeadLetter(team.ID, team.Name, "team.x.leader", data, err)
+
+	var letter models.DeadLetter
+	if err := srv.db.First(&letter, "team_id = ?", team.ID).Error; err != nil {
+		t.Fatalf("dead letter not stored: %v", err)
+	}
+	if letter.Status != models.DeadLetterStatusFailed {
+		t.Errorf("status: got %q, want %q", letter.Status, models.DeadLetterStatusFailed)
+	}
+	if letter.NextAttemptAt != nil {
+		t.Error("failed letter should not be scheduled for retry")
+	}
+}
+
+func TestRetryDeadLetters_Redelivers(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "dl-retry-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+	srv.recordDeadLetter(team.ID, team.Name, "team.x.leader", data, errors.New("database is locked"))
+
+	// Make the letter due now.
+	srv.db.Model(&models.DeadLetter{}).Where("team_id = ?", team.ID).
+		Update("next_attempt_at", time.Now().Add(-time.Second))
+
+	srv.retryDeadLetters(t.Context())
+
+	var letter models.DeadLetter
+	srv.db.First(&letter, "team_id = ?", team.ID)
+	if letter.Status != models.DeadLetterStatusDelivered {
+		t.Errorf("status: got %q, want %q", letter.Status, models.DeadLetterStatusDelivered)
+	}
+	if letter.Attempts != 1 {
+		t.Errorf("attempts: got %d, want 1", letter.Attempts)
+	}
+	if count := countRelayLogs(t, srv, team.ID); count != 1 {
+		t.Errorf("task logs: got %d, want 1", count)
+	}
+}
+
+func TestRetryDeadLetters_SkipsNotDue(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "dl-notdue-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+	srv.recordDeadLetter(team.ID, team.Name, "team.x.leader", data, errors.New("database is locked"))
+
+	srv.retryDeadLetters(t.Context())
+
+	var letter models.DeadLetter
+	srv.db.First(&letter, "team_id = ?", team.ID)
+	if letter.Status != models.DeadLetterStatusPending || letter.Attempts != 0 {
+		t.Errorf("letter should be untouched, got status %q attempts %d", letter.Status, letter.Attempts)
+	}
+}
+
+func TestListAndRetryDeadLetters(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "dl-api-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+	srv.recordDeadLetter(team.ID, team.Name, "team.x.leader", data, errors.New("database is locked"))
+
+	rec := doRequest(srv, "GET", "/api/admin/dead-letters?status=pending", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	letters := parseList[models.DeadLetter](t, rec)
+	if len(letters) != 1 {
+		t.Fatalf("letters: got %d, want 1", len(letters))
+	}
+
+	rec = doRequest(srv, "POST", "/api/admin/dead-letters/"+letters[0].ID+"/retry", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var letter models.DeadLetter
+	parseJSON(t, rec, &letter)
+	if letter.Status != models.DeadLetterStatusDelivered {
+		t.Errorf("status: got %q, want %q", letter.Status, models.DeadLetterStatusDelivered)
+	}
+
+	rec = doRequest(srv, "POST", "/api/admin/dead-letters/"+letters[0].ID+"/retry", nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("second retry: got %d, want 409", rec.Code)
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+const (
+	// deployLogLines bounds the lines kept per deployment; the oldest are
+	// dropped first.
+	deployLogLines = 500
+	// deployLogRetention is how long the log of a finished deployment can
+	// still be streamed.
+	deployLogRetention = 15 * time.Minute
+	// deployLogKeepAlive is how often an idle stream sends a comment, which
+	// also notices clients that went away.
+	deployLogKeepAlive = 15 * time.Second
+)
+
+// deployLogPoll is how often a stream checks a deployment it has no log
+// for, such as one running on another replica.
+var deployLogPoll = time.Second
+
+// deployLogLine is a progress line of a deployment.
+type deployLogLine struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	// Pull is set on the lines of an image pull.
+	Pull *runtime.PullProgress `json:"pull,omitempty"`
+}
+
+// deployLog collects the progress lines of a team's deployment.
+type deployLog struct {
+	mu      sync.Mutex
+	lines   []deployLogLine
+	dropped int // lines dropped from the start of lines
+	done    bool
+	ended   time.Time
+	// changed is closed, and replaced, when a line is added or the
+	// deployment ends.
+	changed chan struct{}
+}
+
+// add appends a line, unless it repeats the last one.
+func (l *deployLog) add(p runtime.Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done || (len(l.lines) > 0 && l.lines[len(l.lines)-1].Message == p.Message) {
+		return
+	}
+	l.lines = append(l.lines, deployLogLine{Time: time.Now().UTC(), Message: p.Message, Pull: p.Pull})
+	if len(l.lines) > deployLogLines {
+		l.lines = l.lines[1:]
+		l.dropped++
+	}
+	l.notify()
+}
+
+// finish marks the deployment as ended.
+func (l *deployLog) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done = true
+	l.ended = time.Now()
+	l.notify()
+}
+
+func (l *deployLog) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// since returns the lines added after the first n, the number of lines
+// added so far, whether the deployment ended, and a channel closed on the
+// next change.
+func (l *deployLog) since(n int) ([]deployLogLine, int, bool, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	start := max(n-l.dropped, 0)
+	lines := append([]deployLogLine(nil), l.lines[start:]...)
+	return lines, l.dropped + len(l.lines), l.done, l.changed
+}
+
+// deployLogs holds the log of the last deployment of each team run by
+// this replica.
+type deployLogs struct {
+	mu   sync.Mutex
+	logs map[string]*deployLog
+}
+
+// start replaces the team's log with an empty one, and forgets the logs
+// of deployments that ended over deployLogRetention ago.
+func (d *deployLogs) start(teamID string) *deployLog {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.logs == nil {
+		d.logs = make(map[string]*deployLog)
+	}
+	for id, l := range d.logs {
+		l.mu.Lock()
+		expired := l.done && time.Since(l.ended) > deployLogRetention
+		l.mu.Unlock()
+		if expired {
+			delete(d.logs, id)
+		}
+	}
+	l := &deployLog{changed: make(chan struct{})}
+	d.logs[teamID] = l
+	return l
+}
+
+// get returns the team's log, or nil.
+func (d *deployLogs) get(teamID string) *deployLog {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.logs[teamID]
+}
+
+// StreamDeployLogs handles GET /api/teams/:id/deploy/logs. It streams the
+// progress of the team's deployment as server-sent events: a "log" event
+// per line, then a "done" event with the team's status once the
+// deployment ends. The lines of a deployment that ended recently are
+// replayed. The lines are kept by the replica that runs the deployment;
+// other replicas stream the changes of the team's status message instead.
+func (s *Server) StreamDeployLogs(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).Select("id", "status").First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	// The log of a previous deployment is stale once a new one is queued.
+	var stale *deployLog
+	if l := s.deployLogs.get(team.ID); l != nil && team.Status == models.TeamStatusDeploying {
+		if _, _, done, _ := l.since(0); done {
+			stale = l
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		s.writeDeployLog(w, team.ID, stale)
+	})
+	return nil
+}
+
+// writeDeployLog writes the events of StreamDeployLogs until the
+// deployment ends or the client goes away.
+func (s *Server) writeDeployLog(w *bufio.Writer, teamID string, stale *deployLog) {
+	ticker := time.NewTicker(deployLogPoll)
+	defer ticker.Stop()
+	var (
+		current    *deployLog
+		next       int
+		statusLine string
+		lastWrite  = time.Now()
+	)
+	for {
+		var changed <-chan struct{}
+		wrote := false
+		if l := s.deployLogs.get(teamID); l != nil && l != stale {
+			if l != current {
+				current, next = l, 0
+			}
+			lines, n, done, ch := l.since(next)
+			next, changed = n, ch
+			for _, line := range lines {
+				writeSSE(w, "log", line)
+				wrote = true
+			}
+			if done {
+				s.writeDeployDone(w, teamID)
+				return
+			}
+		} else {
+			var team models.Team
+			if err := s.db.Select("id", "status", "status_message").First(&team, "id = ?", teamID).Error; err != nil {
+				return
+			}
+			if team.Status != models.TeamStatusDeploying {
+				s.writeDeployDone(w, teamID)
+				return
+			}
+			if team.StatusMessage != "" && team.StatusMessage != statusLine {
+				statusLine = team.StatusMessage
+				writeSSE(w, "log", deployLogLine{Time: time.Now().UTC(), Message: statusLine})
+				wrote = true
+			}
+		}
+
+		if !wrote && time.Since(lastWrite) >= deployLogKeepAlive {
+			_, _ = w.WriteString(": keep-alive\n\n")
+			wrote = true
+		}
+		if wrote {
+			if err := w.Flush(); err != nil {
+				return
+			}
+			lastWrite = time.Now()
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeDeployDone writes the "done" event with the status the team's
+// deployment ended in.
+func (s *Server) writeDeployDone(w *bufio.Writer, teamID string) {
+	var team models.Team
+	s.db.Select("id", "status", "status_message").First(&team, "id = ?", teamID)
+	writeSSE(w, "done", fiber.Map{"status": team.Status, "status_message": team.StatusMessage})
+	_ = w.Flush()
+}
+
+// writeSSE writes a server-sent event with a JSON payload.
+func writeSSE(w *bufio.Writer, event string, data any) {
+	payload, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func TestStreamDeployLogs(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "deploy-logs-team",
+		Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.deployTeamAsync(t.Context(), team)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/deploy/logs", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`"message":"Deploying team infrastructure"`,
+		`"message":"Deploying leader boss"`,
+		`"message":"Team deployed"`,
+		"event: done\ndata: {\"status\":\"running\"",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, "Deploying leader") > strings.Index(body, "Team deployed") {
+		t.Errorf("lines out of order:\n%s", body)
+	}
+
+	if rec := doRequest(srv, "GET", "/api/teams/missing/deploy/logs", nil); rec.Code != 404 {
+		t.Errorf("unknown team: got %d, want 404", rec.Code)
+	}
+}
+
+func TestStreamDeployLogs_PullProgress(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "deploy-pull-team",
+		Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	log := srv.deployLogs.start(team.ID)
+	log.add(runtime.Progress{
+		Message: "Pulling image agent:1: 1/4 layers, 50% of 2.0 MB",
+		Pull:    &runtime.PullProgress{Image: "agent:1", Layers: 4, LayersDone: 1, DownloadedBytes: 1e6, SizeBytes: 2e6, Percent: 50},
+	})
+	log.finish()
+
+	body := doRequest(srv, "GET", "/api/teams/"+team.ID+"/deploy/logs", nil).Body.String()
+	want := `"pull":{"image":"agent:1","layers":4,"layers_done":1,"downloaded_bytes":1000000,"size_bytes":2000000,"percent":50}`
+	if !strings.Contains(body, want) {
+		t.Errorf("body lacks %s:\n%s", want, body)
+	}
+}
+
+func TestStreamDeployLogs_FollowsStatusWithoutLog(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	poll := deployLogPoll
+	deployLogPoll = 10 * time.Millisecond
+	t.Cleanup(func() { deployLogPoll = poll })
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "deploy-elsewhere-team",
+		Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.deployTeamAsync(t.Context(), team)
+
+	// A new deployment is queued, to run on another replica: the log of
+	// the previous one must not be replayed.
+	srv.db.Model(&team).Updates(map[string]interface{}{
+		"status":         models.TeamStatusDeploying,
+		"status_message": "Pulling model: 40%",
+	})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		srv.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": "Failed to pull Ollama model",
+		})
+	}()
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/deploy/logs", nil)
+	body := rec.Body.String()
+	if strings.Contains(body, "Team deployed") {
+		t.Errorf("replayed the previous deployment:\n%s", body)
+	}
+	if !strings.Contains(body, `"message":"Pulling model: 40%"`) {
+		t.Errorf("body lacks the status message:\n%s", body)
+	}
+	if !strings.Contains(body, `"status":"error","status_message":"Failed to pull Ollama model"`) {
+		t.Errorf("body lacks the final status:\n%s", body)
+	}
+}
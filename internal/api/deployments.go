@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// deploymentListOptions configures GET /api/teams/:id/deployments: newest
+// first, filterable by status and trigger.
+var deploymentListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status", "trigger": "trigger"},
+}
+
+func deploymentKey(d models.Deployment) (time.Time, string) { return d.CreatedAt, d.ID }
+
+// deploymentTrigger is why, and by which user, a deployment was started.
+// Jobs carry it in their payload to the deployment they run.
+type deploymentTrigger struct {
+	Trigger     string `json:"trigger,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+}
+
+type deploymentTriggerKey struct{}
+
+// withDeploymentTrigger returns a copy of ctx recording the trigger of the
+// deployments made with it.
+func withDeploymentTrigger(ctx context.Context, trigger deploymentTrigger) context.Context {
+	return context.WithValue(ctx, deploymentTriggerKey{}, trigger)
+}
+
+// deploymentTriggerFrom returns the trigger recorded in ctx. Its Trigger
+// defaults to fallback.
+func deploymentTriggerFrom(ctx context.Context, fallback string) deploymentTrigger {
+	trigger, _ := ctx.Value(deploymentTriggerKey{}).(deploymentTrigger)
+	if trigger.Trigger == "" {
+		trigger.Trigger = fallback
+	}
+	return trigger
+}
+
+// startDeployment records the start of a deployment of team. A deployment
+// that cannot be recorded goes on: nil is returned, which the other
+// deployment helpers ignore.
+func (s *Server) startDeployment(team models.Team, trigger deploymentTrigger) *models.Deployment {
+	d, err := models.StartDeployment(s.db, team, trigger.Trigger, trigger.TriggeredBy)
+	if err != nil {
+		slog.Error("failed to record deployment", "team", team.Name, "error", err)
+	}
+	return d
+}
+
+// recordDeployedContainer records the container an agent of the deployment
+// runs in, with the digest of its image when the runtime can report it.
+func (s *Server) recordDeployedContainer(ctx context.Context, d *models.Deployment, agentName, image, containerID string) {
+	if d == nil {
+		return
+	}
+	var digest string
+	if digester, ok := s.runtime.(runtime.ImageDigester); ok {
+		var err error
+		if digest, err = digester.ImageDigest(ctx, containerID); err != nil {
+			slog.Warn("failed to read image digest", "agent", agentName, "error", err)
+		}
+	}
+	d.SetContainer(agentName, containerID, image, digest)
+}
+
+// finishDeployment records the outcome of a deployment from the status it
+// left the team in: it succeeded if the team is running, and the team's
+// config revision is the one it runs.
+func (s *Server) finishDeployment(d *models.Deployment) {
+	if d == nil {
+		return
+	}
+	var team models.Team
+	if err := s.db.Select("id", "status", "status_message", "config_revision").First(&team, "id = ?", d.TeamID).Error; err != nil {
+		return
+	}
+	var deployErr string
+	if team.Status == models.TeamStatusRunning {
+		d.TeamRevision = team.ConfigRevision
+	} else if deployErr = team.StatusMessage; deployErr == "" {
+		deployErr = "deployment ended with the team " + team.Status
+	}
+	if err := models.FinishDeployment(s.db, d, deployErr); err != nil {
+		slog.Error("failed to record deployment outcome", "team_id", d.TeamID, "error", err)
+	}
+}
+
+// ListTeamDeployments handles GET /api/teams/:id/deployments. It returns
+// the team's deployments, newest first.
+func (s *Server) ListTeamDeployments(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	q, err := parseListQuery(c, deploymentListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", team.ID), q, deploymentKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list deployments")
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestListTeamDeployments(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "history-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}, {Name: "dev", Role: "worker"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil); rec.Code != 200 {
+		t.Fatalf("deploy: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+
+	// A failed leader restart is recorded with the status message it left.
+	srv.db.Preload("Agents").First(&team, "id = ?", team.ID)
+	leader, _ := teamLeader(team)
+	mock.mu.Lock()
+	mock.deployAgentErr = errors.New("no capacity")
+	mock.mu.Unlock()
+	if err := srv.restartLeader(t.Context(), team, leader, protocol.DeploymentActionRestart, false); err == nil {
+		t.Fatal("restart: want error")
+	}
+
+	var page ListResponse[models.Deployment]
+	parseJSON(t, doRequest(srv, "GET", "/api/teams/"+team.ID+"/deployments", nil), &page)
+	if len(page.Items) != 2 {
+		t.Fatalf("deployments: got %d, want 2", len(page.Items))
+	}
+	restart, deploy := page.Items[0], page.Items[1]
+
+	if deploy.Trigger != models.DeploymentTriggerManual || deploy.Status != models.DeploymentStatusSucceeded ||
+		deploy.TeamRevision != 1 || deploy.FinishedAt == nil || deploy.Error != "" {
+		t.Errorf("deploy: got %+v", deploy)
+	}
+	var agents []models.DeploymentAgent
+	json.Unmarshal(deploy.Agents, &agents)
+	if len(agents) != 2 || agents[0].ContainerID != "container-leader" ||
+		agents[0].ImageDigest != "ghcr.io/helmcode/agent_crew_agent@sha256:container-leader" || agents[1].ContainerID != "" {
+		t.Errorf("deploy agents: got %+v", agents)
+	}
+
+	if restart.Trigger != protocol.DeploymentActionRestart || restart.Status != models.DeploymentStatusFailed ||
+		restart.Error != "Failed to restart leader: no capacity" || restart.TeamRevision != 0 {
+		t.Errorf("restart: got %+v", restart)
+	}
+
+	parseJSON(t, doRequest(srv, "GET", "/api/teams/"+team.ID+"/deployments?status=succeeded", nil), &page)
+	if len(page.Items) != 1 || page.Items[0].ID != deploy.ID {
+		t.Errorf("succeeded deployments: got %+v", page.Items)
+	}
+	if rec := doRequest(srv, "GET", "/api/teams/missing/deployments", nil); rec.Code != 404 {
+		t.Errorf("unknown team: got %d, want 404", rec.Code)
+	}
+}
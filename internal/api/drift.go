@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// driftCheckInterval is how often the leaders of running teams are compared
+// with their containers.
+var driftCheckInterval = time.Minute
+
+// fleetDriftWindow is how far back the fleet overview lists drift.
+const fleetDriftWindow = 24 * time.Hour
+
+// StartDriftReconciler starts the background loop that moves running teams
+// whose leader container was removed or stopped outside the API, such as
+// with docker rm -f, out of the running status.
+func (s *Server) StartDriftReconciler() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.driftCancel = cancel
+	s.driftWg.Add(1)
+	go func() {
+		defer s.driftWg.Done()
+		ticker := time.NewTicker(driftCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, runCancel := context.WithTimeout(ctx, driftCheckInterval)
+				s.reconcileDrift(runCtx)
+				runCancel()
+			}
+		}
+	}()
+}
+
+// stopDriftReconciler stops the drift loop, if running, and waits for it.
+func (s *Server) stopDriftReconciler() {
+	if s.driftCancel != nil {
+		s.driftCancel()
+	}
+	s.driftWg.Wait()
+}
+
+// reconcileDrift compares the leader of each running team with the status
+// the runtime reports for its container, and marks the teams whose
+// container is missing, stopped, failed, or restarted more often than the
+// team's restart policy allows. It returns their IDs. Lookups that fail for
+// another reason are left for the next round.
+func (s *Server) reconcileDrift(ctx context.Context) []string {
+	var teams []models.Team
+	if err := s.db.Preload("Agents", "role = ? AND container_id <> ''", models.AgentRoleLeader).
+		Where("status = ?", models.TeamStatusRunning).Find(&teams).Error; err != nil {
+		slog.Error("drift: failed to list running teams", "error", err)
+		return nil
+	}
+	var drifted []string
+	for _, team := range teams {
+		restart := teamRestartPolicy(team)
+		for _, leader := range team.Agents {
+			statusCtx, cancel := context.WithTimeout(ctx, containerStatusTimeout)
+			st, err := s.runtime.GetStatus(statusCtx, leader.ContainerID)
+			cancel()
+
+			var observed string
+			switch {
+			case errors.Is(err, runtime.ErrAgentNotFound):
+				observed = protocol.DriftObservedMissing
+			case err != nil:
+				slog.Debug("drift: failed to get leader status", "team", team.Name, "error", err)
+				continue
+			case st.Status == runtime.StatusStopped || st.Status == runtime.StatusError:
+				observed = st.Status
+			case restart.RetriesExhausted(st.RestartCount):
+				// Docker stops restarting on its own; Kubernetes
+				// restarts the pod for as long as it exists.
+				observed = protocol.DriftObservedRestartLimit
+			default:
+				continue
+			}
+			if s.markDrift(ctx, team.ID, leader.ID, leader.ContainerID, observed, st) {
+				drifted = append(drifted, team.ID)
+			}
+		}
+	}
+	return drifted
+}
+
+// markDrift moves a running team out of the running status because its
+// leader's container is no longer running, and records a drift_detected
+// log. A missing container leaves the team stopped if none of its
+// infrastructure is left, and in error otherwise. A container past its
+// restart limit is stopped. st is the container's status, nil if it is
+// missing. It returns false if the team is busy, no longer running, or
+// has since moved to another container.
+func (s *Server) markDrift(ctx context.Context, teamID, agentID, containerID, observed string, st *runtime.AgentStatus) bool {
+	op, err := s.beginTeamOp(teamID, teamOpCleanup, false)
+	if err != nil {
+		return false
+	}
+	defer s.endTeamOp(teamID, op)
+
+	var team models.Team
+	if err := s.db.First(&team, "id = ?", teamID).Error; err != nil || team.Status != models.TeamStatusRunning {
+		return false
+	}
+	var leader models.Agent
+	if err := s.db.First(&leader, "id = ? AND container_id = ?", agentID, containerID).Error; err != nil {
+		return false
+	}
+
+	status, containerStatus := models.TeamStatusError, models.ContainerStatusError
+	msg := "Leader container stopped outside the API; stop or redeploy the team"
+	agentUpdates := map[string]interface{}{}
+	switch observed {
+	case protocol.DriftObservedMissing:
+		msg = "Leader container no longer exists; stop or redeploy the team"
+		if s.teamInfraGone(ctx, team) {
+			status, containerStatus = models.TeamStatusStopped, models.ContainerStatusStopped
+			msg = "Team infrastructure was removed outside the API"
+			agentUpdates["container_id"] = ""
+		}
+	case protocol.DriftObservedRestartLimit:
+		msg = fmt.Sprintf("Leader container restarted more than %d times; fix it and redeploy the team",
+			teamRestartPolicy(team).MaxRetries)
+		if err := s.runtime.StopAgent(ctx, containerID); err != nil {
+			slog.Error("failed to stop crash-looping leader", "team", team.Name, "container", containerID, "error", err)
+		}
+	}
+	agentUpdates["container_status"] = containerStatus
+	s.db.Model(&leader).Updates(agentUpdates)
+	s.db.Model(&team).Updates(map[string]interface{}{
+		"status":         status,
+		"status_message": msg,
+	})
+	slog.Warn("leader container drifted", "team", team.Name, "team_id", team.ID, "container", containerID,
+		"observed", observed, "status", status)
+
+	drift := protocol.DriftDetectedPayload{
+		AgentName:   leader.Name,
+		ContainerID: containerID,
+		Expected:    models.ContainerStatusRunning,
+		Observed:    observed,
+		TeamStatus:  status,
+		Message:     msg,
+	}
+	if st != nil {
+		drift.RestartCount = st.RestartCount
+	}
+	payload, _ := json.Marshal(drift)
+	log := models.TaskLog{
+		ID:             uuid.New().String(),
+		TeamID:         team.ID,
+		ConversationID: team.ConversationID,
+		FromAgent:      "system",
+		ToAgent:        leader.Name,
+		MessageType:    string(protocol.TypeDriftDetected),
+		Payload:        models.JSON(payload),
+	}
+	if err := s.taskLogs.Create(&log); err != nil {
+		slog.Error("failed to record drift", "team", team.Name, "error", err)
+	}
+	return true
+}
+
+// teamInfraGone reports whether the runtime lists no active infrastructure
+// for team. Runtimes that cannot list infrastructure report false.
+func (s *Server) teamInfraGone(ctx context.Context, team models.Team) bool {
+	lister, ok := s.runtime.(runtime.InfraLister)
+	if !ok {
+		return false
+	}
+	infra, err := lister.ListTeamInfra(ctx)
+	if err != nil {
+		return false
+	}
+	slug := team.Slug
+	if slug == "" {
+		slug = naming.Slug(team.Name)
+	}
+	for _, ti := range infra {
+		if (ti.TeamID == team.ID || ti.Slug == slug) && ti.HasActiveResources() {
+			return false
+		}
+	}
+	return true
+}
+
+// GetFleetOverview reports how many of the organization's teams and leader
+// containers are in each status, and the drift detected in the last
+// fleetDriftWindow, newest first (admin only).
+func (s *Server) GetFleetOverview(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view the fleet overview")
+	}
+
+	overview := FleetOverview{Teams: map[string]int{}, Containers: map[string]int{}, Drift: []FleetDrift{}}
+	var teams []models.Team
+	if err := s.db.Scopes(OrgScope(c)).Select("id", "name", "status").Find(&teams).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	names := make(map[string]string, len(teams))
+	for _, t := range teams {
+		overview.Teams[t.Status]++
+		names[t.ID] = t.Name
+	}
+
+	teamIDs := s.db.Model(&models.Team{}).Scopes(OrgScope(c)).Select("id")
+	var leaders []models.Agent
+	if err := s.db.Select("container_status").
+		Where("team_id IN (?) AND role = ? AND container_id <> ''", teamIDs, models.AgentRoleLeader).
+		Find(&leaders).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list agents")
+	}
+	for _, a := range leaders {
+		overview.Containers[a.ContainerStatus]++
+	}
+
+	var logs []models.TaskLog
+	if err := s.db.Where("team_id IN (?) AND message_type = ? AND created_at >= ?",
+		teamIDs, string(protocol.TypeDriftDetected), time.Now().Add(-fleetDriftWindow)).
+		Order("created_at DESC").Find(&logs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list drift")
+	}
+	for _, l := range logs {
+		var p protocol.DriftDetectedPayload
+		if err := json.Unmarshal(l.Payload, &p); err != nil {
+			continue
+		}
+		overview.Drift = append(overview.Drift, FleetDrift{
+			TeamID:               l.TeamID,
+			TeamName:             names[l.TeamID],
+			DetectedAt:           l.CreatedAt,
+			DriftDetectedPayload: p,
+		})
+	}
+	return c.JSON(overview)
+}
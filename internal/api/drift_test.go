@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func driftLogs(t *testing.T, srv *Server, teamID string) []protocol.DriftDetectedPayload {
+	t.Helper()
+	var logs []models.TaskLog
+	srv.db.Where("team_id = ? AND message_type = ?", teamID, string(protocol.TypeDriftDetected)).Find(&logs)
+	payloads := make([]protocol.DriftDetectedPayload, len(logs))
+	for i, l := range logs {
+		if err := json.Unmarshal(l.Payload, &payloads[i]); err != nil {
+			t.Fatalf("drift payload: %v", err)
+		}
+	}
+	return payloads
+}
+
+func TestReconcileDrift_MissingContainer(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "drifted", "c-drifted")
+	mock.statusErr = fmt.Errorf("inspecting container c-drifted: %w", runtime.ErrAgentNotFound)
+	mock.teamInfra = []runtime.TeamInfra{
+		{Slug: "drifted", TeamID: team.ID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceContainer, Name: "team-drifted-nats", ID: "c-nats"},
+		}},
+	}
+
+	if drifted := srv.reconcileDrift(t.Context()); len(drifted) != 1 || drifted[0] != team.ID {
+		t.Fatalf("drifted = %v, want %s", drifted, team.ID)
+	}
+
+	var got models.Team
+	srv.db.Preload("Agents").First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusError {
+		t.Errorf("team status = %q, want error", got.Status)
+	}
+	if got.Agents[0].ContainerStatus != models.ContainerStatusError {
+		t.Errorf("container status = %q, want error", got.Agents[0].ContainerStatus)
+	}
+	logs := driftLogs(t, srv, team.ID)
+	if len(logs) != 1 {
+		t.Fatalf("drift logs = %+v, want 1", logs)
+	}
+	if p := logs[0]; p.ContainerID != "c-drifted" || p.Observed != protocol.DriftObservedMissing || p.TeamStatus != models.TeamStatusError {
+		t.Errorf("drift = %+v", p)
+	}
+
+	// The team is no longer running: nothing more is reported.
+	if drifted := srv.reconcileDrift(t.Context()); len(drifted) != 0 {
+		t.Errorf("second round drifted = %v, want none", drifted)
+	}
+}
+
+func TestReconcileDrift_InfrastructureRemoved(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "removed", "c-removed")
+	mock.statusErr = runtime.ErrAgentNotFound
+
+	srv.reconcileDrift(t.Context())
+
+	var got models.Team
+	srv.db.Preload("Agents").First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusStopped {
+		t.Errorf("team status = %q, want stopped", got.Status)
+	}
+	if a := got.Agents[0]; a.ContainerID != "" || a.ContainerStatus != models.ContainerStatusStopped {
+		t.Errorf("leader = %q/%q, want no container and stopped", a.ContainerID, a.ContainerStatus)
+	}
+	if logs := driftLogs(t, srv, team.ID); len(logs) != 1 || logs[0].TeamStatus != models.TeamStatusStopped {
+		t.Errorf("drift logs = %+v", logs)
+	}
+}
+
+func TestReconcileDrift_StoppedContainer(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "exited", "c-exited")
+	healthy := createTestTeam(t, srv, "healthy")
+	mock.agentStatus = runtime.StatusStopped
+
+	srv.reconcileDrift(t.Context())
+
+	var got models.Team
+	srv.db.First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusError {
+		t.Errorf("team status = %q, want error", got.Status)
+	}
+	if logs := driftLogs(t, srv, team.ID); len(logs) != 1 || logs[0].Observed != runtime.StatusStopped {
+		t.Errorf("drift logs = %+v", logs)
+	}
+	if logs := driftLogs(t, srv, healthy.ID); len(logs) != 0 {
+		t.Errorf("team that is not running: drift logs = %+v", logs)
+	}
+}
+
+func TestReconcileDrift_SkipsUnknownErrorsAndRunning(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "steady", "c-steady")
+
+	srv.reconcileDrift(t.Context())
+	mock.statusErr = fmt.Errorf("docker daemon unreachable")
+	srv.reconcileDrift(t.Context())
+
+	var got models.Team
+	srv.db.First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusRunning {
+		t.Errorf("team status = %q, want running", got.Status)
+	}
+	if logs := driftLogs(t, srv, team.ID); len(logs) != 0 {
+		t.Errorf("drift logs = %+v, want none", logs)
+	}
+}
+
+func TestReconcileDrift_RestartLimit(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "looping", "c-looping")
+	srv.db.Model(&team).Update("restart_policy", models.JSON(`{"mode":"on-failure","max_retries":3}`))
+
+	mock.restartCount = 3
+	if drifted := srv.reconcileDrift(t.Context()); len(drifted) != 0 {
+		t.Fatalf("within the limit: drifted = %v, want none", drifted)
+	}
+
+	mock.restartCount = 4
+	if drifted := srv.reconcileDrift(t.Context()); len(drifted) != 1 {
+		t.Fatalf("past the limit: drifted = %v, want %s", drifted, team.ID)
+	}
+	var got models.Team
+	srv.db.First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusError {
+		t.Errorf("team status = %q, want error", got.Status)
+	}
+	if len(mock.stoppedAgents) != 1 || mock.stoppedAgents[0] != "c-looping" {
+		t.Errorf("stopped agents = %v, want c-looping", mock.stoppedAgents)
+	}
+	logs := driftLogs(t, srv, team.ID)
+	if len(logs) != 1 || logs[0].Observed != protocol.DriftObservedRestartLimit || logs[0].RestartCount != 4 {
+		t.Errorf("drift logs = %+v", logs)
+	}
+}
+
+func TestGetFleetOverview(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	drifted := createRunningTeam(t, srv, "drifted", "c-drifted")
+	createTestTeam(t, srv, "idle")
+	mock.agentStatus = runtime.StatusError
+	srv.reconcileDrift(t.Context())
+
+	rec := doRequest(srv, "GET", "/api/admin/fleet", nil)
+	if rec.Code != 200 {
+		t.Fatalf("got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var overview FleetOverview
+	parseJSON(t, rec, &overview)
+	if overview.Teams[models.TeamStatusError] != 1 || overview.Teams[models.TeamStatusStopped] != 1 {
+		t.Errorf("teams = %v, want 1 error and 1 stopped", overview.Teams)
+	}
+	if overview.Containers[models.ContainerStatusError] != 1 {
+		t.Errorf("containers = %v, want 1 error", overview.Containers)
+	}
+	if len(overview.Drift) != 1 {
+		t.Fatalf("drift = %+v, want 1", overview.Drift)
+	}
+	if d := overview.Drift[0]; d.TeamID != drifted.ID || d.TeamName != "drifted" || d.Observed != runtime.StatusError {
+		t.Errorf("drift = %+v", d)
+	}
+
+	member := NewServer(srv.db, &mockRuntime{}, memberAuth{srv.authProvider})
+	if rec := doRequest(member, "GET", "/api/admin/fleet", nil); rec.Code != 403 {
+		t.Errorf("member: got %d, want 403", rec.Code)
+	}
+}
@@ -10,9 +10,17 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/helmcode/agent-crew/internal/evaluation"
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/resources"
+	"github.com/helmcode/agent-crew/internal/runtime"
+	"github.com/helmcode/agent-crew/internal/workhours"
 )
 
 // CreateTeamRequest is the payload for POST /api/teams.
@@ -24,6 +32,22 @@ type CreateTeamRequest struct {
 	ModelProvider string              `json:"model_provider"`
 	WorkspacePath string              `json:"workspace_path"`
 	AgentImage    string              `json:"agent_image"`
+	ConfigDirMode string              `json:"config_dir_mode"`
+	ResourcePreset string             `json:"resource_preset"`
+	NamespaceConfig *runtime.NamespaceConfig `json:"namespace_config"`
+	RestartPolicy *runtime.RestartPolicy `json:"restart_policy"`
+	Bootstrap     *protocol.BootstrapConfig `json:"bootstrap"`
+	ExtraWorkspaces []protocol.ExtraWorkspace `json:"extra_workspaces"`
+	WorkspaceTemplate string          `json:"workspace_template"`
+	HostSelector  map[string]string   `json:"host_selector"`
+	Labels        map[string]string   `json:"labels"`
+	MaxConcurrentRuns int             `json:"max_concurrent_runs"`
+	TelemetryLevel string             `json:"telemetry_level"`
+	PlanApproval  bool                `json:"plan_approval"`
+	Memory        bool                `json:"memory"`
+	KnowledgeBase bool                `json:"knowledge_base"`
+	WorkingHours  *workhours.Policy   `json:"working_hours"`
+	RequiresDeployApproval bool       `json:"requires_deploy_approval"`
 	Agents        []CreateAgentInput  `json:"agents"`
 	McpServers    interface{}         `json:"mcp_servers"`
 }
@@ -36,6 +60,40 @@ type UpdateTeamRequest struct {
 	ModelProvider *string     `json:"model_provider"`
 	WorkspacePath *string     `json:"workspace_path"`
 	AgentImage    *string     `json:"agent_image"`
+	ConfigDirMode *string     `json:"config_dir_mode"`
+	ResourcePreset *string    `json:"resource_preset"`
+	NamespaceConfig *runtime.NamespaceConfig `json:"namespace_config"`
+	// RestartPolicy replaces the restart policy; an empty object stops
+	// restarts. It applies at the next deploy.
+	RestartPolicy *runtime.RestartPolicy `json:"restart_policy"`
+	// Bootstrap replaces the bootstrap script; an empty script removes it.
+	Bootstrap     *protocol.BootstrapConfig `json:"bootstrap"`
+	// ExtraWorkspaces replaces the extra workspaces; an empty list removes
+	// them. They are mounted at the next deploy.
+	ExtraWorkspaces *[]protocol.ExtraWorkspace `json:"extra_workspaces"`
+	// WorkspaceTemplate changes the workspace template; an empty value
+	// removes it. It is scaffolded at the next deploy, if the workspace is
+	// still empty.
+	WorkspaceTemplate *string `json:"workspace_template"`
+	// HostSelector replaces the labels a Docker host needs; an empty object
+	// removes them. It only applies until the team is first deployed.
+	HostSelector  map[string]string `json:"host_selector"`
+	// Labels replaces the team's labels when set; an empty object removes them.
+	Labels        map[string]string `json:"labels"`
+	// MaxConcurrentRuns sets the run queue limit; 0 removes it.
+	MaxConcurrentRuns *int          `json:"max_concurrent_runs"`
+	// TelemetryLevel changes the activity events published; a running
+	// team's leader picks it up at once. Empty restores the default.
+	TelemetryLevel *string          `json:"telemetry_level"`
+	PlanApproval  *bool             `json:"plan_approval"`
+	Memory        *bool             `json:"memory"`
+	KnowledgeBase *bool             `json:"knowledge_base"`
+	// WorkingHours replaces the team's working-hours policy; an empty
+	// object removes it.
+	WorkingHours  *workhours.Policy `json:"working_hours"`
+	// RequiresDeployApproval holds members' deploys, migrations and leader
+	// restarts for an admin's approval. Only admins can turn it off.
+	RequiresDeployApproval *bool    `json:"requires_deploy_approval"`
 	McpServers    interface{} `json:"mcp_servers"`
 }
 
@@ -54,6 +112,13 @@ type CreateAgentInput struct {
 	SubAgentInstructions string      `json:"sub_agent_instructions"`
 	SubAgentModel        string      `json:"sub_agent_model"`
 	SubAgentSkills       interface{} `json:"sub_agent_skills"`
+	SubAgentBackground     *bool       `json:"sub_agent_background"`
+	SubAgentIsolation      string      `json:"sub_agent_isolation"`
+	SubAgentPermissionMode string      `json:"sub_agent_permission_mode"`
+	RunAsUID             *int        `json:"run_as_uid"`
+	RunAsGID             *int        `json:"run_as_gid"`
+	SkipWorkspaceChown   bool        `json:"skip_workspace_chown"`
+	ExtraClaudeArgs      []string    `json:"extra_claude_args"`
 }
 
 // CreateAgentRequest is the payload for POST /api/teams/:id/agents.
@@ -71,6 +136,30 @@ type CreateAgentRequest struct {
 	SubAgentInstructions string      `json:"sub_agent_instructions"`
 	SubAgentModel        string      `json:"sub_agent_model"`
 	SubAgentSkills       interface{} `json:"sub_agent_skills"`
+	SubAgentBackground     *bool       `json:"sub_agent_background"`
+	SubAgentIsolation      string      `json:"sub_agent_isolation"`
+	SubAgentPermissionMode string      `json:"sub_agent_permission_mode"`
+	RunAsUID             *int        `json:"run_as_uid"`
+	RunAsGID             *int        `json:"run_as_gid"`
+	SkipWorkspaceChown   bool        `json:"skip_workspace_chown"`
+	ExtraClaudeArgs      []string    `json:"extra_claude_args"`
+}
+
+// ImportAgentRequest is the payload for POST /api/agents/import.
+type ImportAgentRequest struct {
+	// URL locates the agent profile: a Markdown file with optional YAML
+	// frontmatter. GitHub file and gist page URLs are fetched raw.
+	URL    string `json:"url"`
+	TeamID string `json:"team_id"`
+	// Name and Role override the profile's.
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// ImportAgentResponse is the response for POST /api/agents/import.
+type ImportAgentResponse struct {
+	Agent      models.Agent           `json:"agent"`
+	Provenance models.AgentProvenance `json:"provenance"`
 }
 
 // UpdateAgentRequest is the payload for PUT /api/teams/:id/agents/:agentId.
@@ -88,11 +177,130 @@ type UpdateAgentRequest struct {
 	SubAgentInstructions *string     `json:"sub_agent_instructions"`
 	SubAgentModel        *string     `json:"sub_agent_model"`
 	SubAgentSkills       interface{} `json:"sub_agent_skills"`
+	SubAgentBackground     *bool       `json:"sub_agent_background"`
+	SubAgentIsolation      *string     `json:"sub_agent_isolation"`
+	SubAgentPermissionMode *string     `json:"sub_agent_permission_mode"`
+	// RunAsUID and RunAsGID set the agent's user; -1 clears the value.
+	RunAsUID *int `json:"run_as_uid"`
+	RunAsGID *int `json:"run_as_gid"`
+	// SkipWorkspaceChown stops the agent's container from chowning a
+	// root-owned workspace to the agent user.
+	SkipWorkspaceChown *bool `json:"skip_workspace_chown"`
+	// ExtraClaudeArgs replaces the agent's extra claude flags; an empty
+	// list clears them.
+	ExtraClaudeArgs *[]string `json:"extra_claude_args"`
+}
+
+// RestartAgentRequest is the optional payload for
+// POST /api/teams/:id/agents/:agentId/restart.
+type RestartAgentRequest struct {
+	// PullImage pulls the agent image before recreating the container, to
+	// pick up a newer build of the same tag.
+	PullImage bool `json:"pull_image"`
+}
+
+// AgentVersionsReport is the response of GET /api/admin/agent-versions.
+type AgentVersionsReport struct {
+	// Latest is the newest version of each tool reported across the fleet.
+	Latest        protocol.ToolVersions `json:"latest"`
+	Agents        []AgentVersionsEntry  `json:"agents"`
+	OutdatedCount int                   `json:"outdated_count"`
+}
+
+// AgentVersionsEntry is one agent container in an AgentVersionsReport.
+type AgentVersionsEntry struct {
+	AgentID    string                 `json:"agent_id"`
+	AgentName  string                 `json:"agent_name"`
+	TeamID     string                 `json:"team_id"`
+	TeamName   string                 `json:"team_name"`
+	Provider   string                 `json:"provider"`
+	Versions   *protocol.ToolVersions `json:"versions"`
+	ReportedAt *time.Time             `json:"reported_at"`
+	// Outdated lists the tools older than the fleet's latest version.
+	Outdated []string `json:"outdated"`
+}
+
+// InfraGCReport is the response of GET /api/admin/infra-gc: what the
+// infrastructure garbage collector would remove or flag.
+type InfraGCReport struct {
+	// DryRun is true when nothing was changed.
+	DryRun            bool               `json:"dry_run"`
+	Orphans           []OrphanedInfra    `json:"orphans"`
+	MissingContainers []MissingContainer `json:"missing_containers"`
+}
+
+// OrphanedInfra is the infrastructure of a team that was deleted or is
+// stopped.
+type OrphanedInfra struct {
+	TeamSlug  string                  `json:"team_slug"`
+	TeamID    string                  `json:"team_id,omitempty"`
+	Reason    string                  `json:"reason"`
+	Resources []runtime.InfraResource `json:"resources"`
+	Removed   bool                    `json:"removed"`
+}
+
+// MissingContainer is the leader of a running team whose container no
+// longer exists.
+type MissingContainer struct {
+	TeamID      string `json:"team_id"`
+	TeamName    string `json:"team_name"`
+	AgentID     string `json:"agent_id"`
+	AgentName   string `json:"agent_name"`
+	ContainerID string `json:"container_id"`
+	Flagged     bool   `json:"flagged"`
+}
+
+// FleetOverview is the response of GET /api/admin/fleet. Teams and
+// Containers count the teams and leader containers by status.
+type FleetOverview struct {
+	Teams      map[string]int `json:"teams"`
+	Containers map[string]int `json:"containers"`
+	Drift      []FleetDrift   `json:"drift"`
+}
+
+// FleetDrift is a leader container found changed outside the API.
+type FleetDrift struct {
+	TeamID     string    `json:"team_id"`
+	TeamName   string    `json:"team_name"`
+	DetectedAt time.Time `json:"detected_at"`
+	protocol.DriftDetectedPayload
+}
+
+// UpgradeAgentsRequest is the payload for POST /api/admin/upgrade-agents.
+type UpgradeAgentsRequest struct {
+	// Image is the agent image the leaders are restarted on.
+	Image string `json:"image"`
+	// Provider selects the teams to upgrade ("claude" or "opencode"), since
+	// each provider runs its own image. Defaults to "claude".
+	Provider string `json:"provider"`
+	// Concurrency is the number of leaders restarted at once. Defaults to 1.
+	Concurrency int `json:"concurrency"`
+	// CanaryPercent is the share of teams upgraded first; the rest are only
+	// upgraded if every canary succeeds.
+	CanaryPercent int `json:"canary_percent"`
 }
 
 // ChatRequest is the payload for POST /api/teams/:id/chat.
+// Either Message or TemplateID must be set; when TemplateID is given the
+// message is rendered from the referenced PromptTemplate using Variables.
+// Queue opts in to holding the message while the team is deploying; it is
+// delivered in order once the leader reports ready.
 type ChatRequest struct {
-	Message string `json:"message" validate:"required"`
+	Message    string            `json:"message"`
+	TemplateID string            `json:"template_id"`
+	Variables  map[string]string `json:"variables"`
+	Queue      bool              `json:"queue"`
+	// Model and MaxThinkingTokens override the leader's settings for this
+	// message only (Claude teams). Model takes "sonnet", "opus", "haiku" or
+	// a full model ID.
+	Model             string `json:"model"`
+	MaxThinkingTokens int    `json:"max_thinking_tokens"`
+}
+
+// UpdateResourcePresetsRequest is the payload for PUT /api/resource-presets.
+type UpdateResourcePresetsRequest struct {
+	Presets map[string]resources.Limits `json:"presets"`
+	Ceiling resources.Limits            `json:"ceiling"`
 }
 
 // UpdateSettingsRequest is the payload for PUT /api/settings.
@@ -104,28 +312,53 @@ type UpdateSettingsRequest struct {
 
 // ErrorResponse is a standard error response.
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Details string `json:"details,omitempty"`
+	Error   string    `json:"error"`
+	Code    ErrorCode `json:"code,omitempty"`
+	Details string    `json:"details,omitempty"`
 }
 
 // CreateScheduleRequest is the payload for POST /api/schedules.
+// Either Prompt or PromptTemplateID must be set.
 type CreateScheduleRequest struct {
-	Name           string `json:"name" validate:"required"`
-	TeamID         string `json:"team_id" validate:"required"`
-	Prompt         string `json:"prompt" validate:"required"`
-	CronExpression string `json:"cron_expression" validate:"required"`
-	Timezone       string `json:"timezone"`
-	Enabled        *bool  `json:"enabled"`
+	Name             string            `json:"name" validate:"required"`
+	TeamID           string            `json:"team_id" validate:"required"`
+	Prompt           string            `json:"prompt"`
+	PromptTemplateID *string           `json:"prompt_template_id"`
+	PromptVariables  map[string]string `json:"prompt_variables"`
+	CronExpression   string            `json:"cron_expression" validate:"required"`
+	Timezone         string            `json:"timezone"`
+	Enabled          *bool             `json:"enabled"`
+	// WorkingHours overrides the team's working-hours action for the
+	// schedule's runs: "run", "skip" or "defer". Empty follows the team.
+	WorkingHours     string            `json:"working_hours"`
 }
 
 // UpdateScheduleRequest is the payload for PUT /api/schedules/:id.
+// An empty PromptTemplateID detaches the schedule from its template.
 type UpdateScheduleRequest struct {
-	Name           *string `json:"name"`
-	TeamID         *string `json:"team_id"`
-	Prompt         *string `json:"prompt"`
-	CronExpression *string `json:"cron_expression"`
-	Timezone       *string `json:"timezone"`
-	Enabled        *bool   `json:"enabled"`
+	Name             *string            `json:"name"`
+	TeamID           *string            `json:"team_id"`
+	Prompt           *string            `json:"prompt"`
+	PromptTemplateID *string            `json:"prompt_template_id"`
+	PromptVariables  *map[string]string `json:"prompt_variables"`
+	CronExpression   *string            `json:"cron_expression"`
+	Timezone         *string            `json:"timezone"`
+	Enabled          *bool              `json:"enabled"`
+	WorkingHours     *string            `json:"working_hours"`
+}
+
+// CreatePromptTemplateRequest is the payload for POST /api/prompt-templates.
+type CreatePromptTemplateRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Content     string `json:"content" validate:"required"`
+}
+
+// UpdatePromptTemplateRequest is the payload for PUT /api/prompt-templates/:id.
+type UpdatePromptTemplateRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Content     *string `json:"content"`
 }
 
 // CreateWebhookRequest is the payload for POST /api/webhooks.
@@ -161,6 +394,64 @@ type TriggerWebhookResponse struct {
 	DurationMs int64  `json:"duration_ms,omitempty"`
 }
 
+// CreateIssueIntegrationRequest is the payload for POST /api/issue-integrations.
+type CreateIssueIntegrationRequest struct {
+	Name          string            `json:"name"`
+	TeamID        string            `json:"team_id"`
+	Provider      string            `json:"provider"`
+	BaseURL       string            `json:"base_url"`
+	Project       string            `json:"project"`
+	IssueType     string            `json:"issue_type"`
+	Labels        []string          `json:"labels"`
+	Credentials   map[string]string `json:"credentials"`
+	SyncResults   *bool             `json:"sync_results"`
+	SyncMode      string            `json:"sync_mode"`
+	TriggerOn     string            `json:"trigger_on"`
+	CommandPrefix string            `json:"command_prefix"`
+	Enabled       *bool             `json:"enabled"`
+}
+
+// UpdateIssueIntegrationRequest is the payload for PUT /api/issue-integrations/:id.
+type UpdateIssueIntegrationRequest struct {
+	Name          *string            `json:"name"`
+	BaseURL       *string            `json:"base_url"`
+	Project       *string            `json:"project"`
+	IssueType     *string            `json:"issue_type"`
+	Labels        *[]string          `json:"labels"`
+	Credentials   *map[string]string `json:"credentials"`
+	SyncResults   *bool              `json:"sync_results"`
+	SyncMode      *string            `json:"sync_mode"`
+	TriggerOn     *string            `json:"trigger_on"`
+	CommandPrefix *string            `json:"command_prefix"`
+	Enabled       *bool              `json:"enabled"`
+}
+
+// CreateAlertIntegrationRequest is the payload for POST /api/alert-integrations.
+type CreateAlertIntegrationRequest struct {
+	Name                    string            `json:"name"`
+	TeamID                  string            `json:"team_id"`
+	Provider                string            `json:"provider"`
+	BaseURL                 string            `json:"base_url"`
+	Credentials             map[string]string `json:"credentials"`
+	Severity                string            `json:"severity"`
+	AlertOnError            *bool             `json:"alert_on_error"`
+	DeployFailureThreshold  *int              `json:"deploy_failure_threshold"`
+	HeartbeatTimeoutSeconds *int              `json:"heartbeat_timeout_seconds"`
+	Enabled                 *bool             `json:"enabled"`
+}
+
+// UpdateAlertIntegrationRequest is the payload for PUT /api/alert-integrations/:id.
+type UpdateAlertIntegrationRequest struct {
+	Name                    *string            `json:"name"`
+	BaseURL                 *string            `json:"base_url"`
+	Credentials             *map[string]string `json:"credentials"`
+	Severity                *string            `json:"severity"`
+	AlertOnError            *bool              `json:"alert_on_error"`
+	DeployFailureThreshold  *int               `json:"deploy_failure_threshold"`
+	HeartbeatTimeoutSeconds *int               `json:"heartbeat_timeout_seconds"`
+	Enabled                 *bool              `json:"enabled"`
+}
+
 // InstallSkillRequest is the payload for POST /api/teams/:id/agents/:agentId/skills/install.
 type InstallSkillRequest struct {
 	RepoURL   string `json:"repo_url"`
@@ -203,6 +494,134 @@ type InstructionsResponse struct {
 	Path    string `json:"path"`
 }
 
+// PreviewInstructionsResponse is the response for
+// POST /api/teams/:id/agents/:agentId/preview-claude-md.
+type PreviewInstructionsResponse struct {
+	Content  string   `json:"content"`
+	Path     string   `json:"path"`
+	Warnings []string `json:"warnings"`
+}
+
+// RegenerateFilesResponse is the response for
+// POST /api/teams/:id/agents/:agentId/regenerate-files. At most one of
+// WrittenToWorkspace and PushedToAgent is set; with neither, the content is
+// applied on the next deploy.
+type RegenerateFilesResponse struct {
+	Content            string `json:"content"`
+	Path               string `json:"path"`
+	WrittenToWorkspace bool   `json:"written_to_workspace"`
+	PushedToAgent      bool   `json:"pushed_to_agent"`
+}
+
+// ConversationSummary is an item in GET /api/teams/:id/conversations.
+type ConversationSummary struct {
+	ID            string    `json:"id"`
+	StartedAt     time.Time `json:"started_at"`
+	LastMessageAt time.Time `json:"last_message_at"`
+	MessageCount  int64     `json:"message_count"`
+	Current       bool      `json:"current"`
+}
+
+// ConversationTranscript is the JSON export of a leader conversation.
+type ConversationTranscript struct {
+	TeamID         string            `json:"team_id"`
+	TeamName       string            `json:"team_name"`
+	ConversationID string            `json:"conversation_id"`
+	StartedAt      time.Time         `json:"started_at"`
+	EndedAt        time.Time         `json:"ended_at"`
+	Entries        []TranscriptEntry `json:"entries"`
+}
+
+// TranscriptEntry is a single user message or leader response in a transcript.
+// Actions lists the tool calls made before a leader response.
+type TranscriptEntry struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Actions   []string  `json:"actions,omitempty"`
+	Files     []string  `json:"files,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ShareRunRequest is the payload for POST /api/runs/:id/share.
+type ShareRunRequest struct {
+	// ExpiresInHours is how long the link stays valid; zero means
+	// defaultShareLinkHours.
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+// ShareRunResponse is the response for POST /api/runs/:id/share. URL is the
+// path of the public transcript endpoint.
+type ShareRunResponse struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SharedRunTranscript is the read-only transcript of a schedule or webhook
+// run served by GET /api/shared/:token. Entries hold the prompt and the
+// leader's response.
+type SharedRunTranscript struct {
+	RunID      string            `json:"run_id"`
+	Kind       string            `json:"kind"`
+	Name       string            `json:"name"`
+	TeamName   string            `json:"team_name"`
+	Status     string            `json:"status"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at"`
+	ExpiresAt  time.Time         `json:"expires_at"`
+	Entries    []TranscriptEntry `json:"entries"`
+}
+
+// ReplayRunSummary is the outcome of a schedule or webhook run, in the form
+// GET /api/runs/:id/replays compares with its replays. CostUSD is the usage
+// the team recorded while the run was in progress.
+type ReplayRunSummary struct {
+	RunID        string     `json:"run_id"`
+	Kind         string     `json:"kind"`
+	TeamID       string     `json:"team_id"`
+	TeamRevision int        `json:"team_revision"`
+	Prompt       string     `json:"prompt"`
+	Status       string     `json:"status"`
+	Response     string     `json:"response"`
+	Error        string     `json:"error"`
+	DurationMs   int64      `json:"duration_ms"`
+	CostUSD      float64    `json:"cost_usd"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+}
+
+// RunReplaysResponse is the response for GET /api/runs/:id/replays: the
+// original run side by side with its replays, newest first.
+type RunReplaysResponse struct {
+	Original ReplayRunSummary   `json:"original"`
+	Replays  []models.RunReplay `json:"replays"`
+}
+
+// CostReport is the response for GET /api/reports/cost.
+type CostReport struct {
+	GroupBy string            `json:"group_by"`
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	Groups  []CostReportGroup `json:"groups"`
+	Total   CostReportGroup   `json:"total"`
+}
+
+// CostReportGroup is the usage of the teams sharing a team name or label
+// value. Turns counts the agent turns the usage was reported for.
+type CostReportGroup struct {
+	Key              string   `json:"key"`
+	CostUSD          float64  `json:"cost_usd"`
+	InputTokens      int64    `json:"input_tokens"`
+	OutputTokens     int64    `json:"output_tokens"`
+	CacheReadTokens  int64    `json:"cache_read_tokens"`
+	CacheWriteTokens int64    `json:"cache_write_tokens"`
+	Turns            int64    `json:"turns"`
+	Teams            []string `json:"teams,omitempty"`
+}
+
 // UpdateInstructionsRequest is the payload for PUT /api/teams/:id/agents/:agentId/instructions.
 type UpdateInstructionsRequest struct {
 	Content string `json:"content"`
@@ -225,9 +644,6 @@ type UploadDocumentResponse struct {
 	Message  string          `json:"message"`
 }
 
-// invalidSlugChars matches any character that is not lowercase alphanumeric, hyphen, or underscore.
-var invalidSlugChars = regexp.MustCompile(`[^a-z0-9_-]`)
-
 // validateName checks that a name is a non-empty string of at most 255 characters.
 // Any human-friendly name is accepted; infrastructure-safe slugs are produced by SanitizeName.
 func validateName(name string) error {
@@ -240,28 +656,6 @@ func validateName(name string) error {
 	return nil
 }
 
-// SanitizeName converts a human-friendly display name into a Docker/K8s-safe slug.
-// It lowercases the string, replaces spaces with hyphens, strips invalid characters,
-// collapses consecutive hyphens, trims leading/trailing hyphens, and truncates to 62 chars.
-func SanitizeName(name string) string {
-	s := strings.ToLower(strings.TrimSpace(name))
-	s = strings.ReplaceAll(s, " ", "-")
-	s = invalidSlugChars.ReplaceAllString(s, "")
-	// Collapse consecutive hyphens.
-	for strings.Contains(s, "--") {
-		s = strings.ReplaceAll(s, "--", "-")
-	}
-	s = strings.Trim(s, "-")
-	if len(s) > 62 {
-		s = s[:62]
-		s = strings.TrimRight(s, "-")
-	}
-	if s == "" {
-		s = "team"
-	}
-	return s
-}
-
 // validSkillNameRe matches safe skill names: alphanumeric, hyphens, underscores, dots, @, forward slashes.
 var validSkillNameRe = regexp.MustCompile(`^[a-zA-Z0-9@/_.-]+$`)
 
@@ -416,6 +810,101 @@ type UpdateBindingRequest struct {
 	Enabled      *bool   `json:"enabled"`
 }
 
+// CreateSkillCatalogEntryRequest is the payload for POST /api/skills/catalog.
+type CreateSkillCatalogEntryRequest struct {
+	RepoURL     string `json:"repo_url"`
+	SkillName   string `json:"skill_name"`
+	Description string `json:"description"`
+	// RequiredTools lists the tools the skill needs.
+	RequiredTools []string `json:"required_tools"`
+}
+
+// CreateCustomToolRequest is the payload for POST /api/tools.
+type CreateCustomToolRequest struct {
+	Name               string            `json:"name"`
+	Description        string            `json:"description"`
+	Method             string            `json:"method"`
+	URL                string            `json:"url"`
+	AuthType           string            `json:"auth_type"`
+	AuthConfig         map[string]string `json:"auth_config"`
+	InputSchema        json.RawMessage   `json:"input_schema"`
+	RateLimitPerMinute *int              `json:"rate_limit_per_minute"`
+	TimeoutSeconds     *int              `json:"timeout_seconds"`
+	Enabled            *bool             `json:"enabled"`
+}
+
+// UpdateCustomToolRequest is the payload for PUT /api/tools/:id.
+type UpdateCustomToolRequest struct {
+	Name               *string           `json:"name"`
+	Description        *string           `json:"description"`
+	Method             *string           `json:"method"`
+	URL                *string           `json:"url"`
+	AuthType           *string           `json:"auth_type"`
+	AuthConfig         map[string]string `json:"auth_config"`
+	InputSchema        json.RawMessage   `json:"input_schema"`
+	RateLimitPerMinute *int              `json:"rate_limit_per_minute"`
+	TimeoutSeconds     *int              `json:"timeout_seconds"`
+	Enabled            *bool             `json:"enabled"`
+}
+
+// CreateContentPolicyRequest is the payload for POST /api/content-policies.
+type CreateContentPolicyRequest struct {
+	Name       string   `json:"name"`
+	Direction  string   `json:"direction"`
+	DenyTerms  []string `json:"deny_terms"`
+	Patterns   []string `json:"patterns"`
+	Moderation bool     `json:"moderation"`
+	Enabled    *bool    `json:"enabled"`
+}
+
+// UpdateContentPolicyRequest is the payload for PUT /api/content-policies/:id.
+type UpdateContentPolicyRequest struct {
+	Name       *string   `json:"name"`
+	Direction  *string   `json:"direction"`
+	DenyTerms  *[]string `json:"deny_terms"`
+	Patterns   *[]string `json:"patterns"`
+	Moderation *bool     `json:"moderation"`
+	Enabled    *bool     `json:"enabled"`
+}
+
+// CreateEvaluationRequest is the payload for POST /api/teams/:id/evaluations.
+type CreateEvaluationRequest struct {
+	Name       string                 `json:"name"`
+	Prompt     string                 `json:"prompt"`
+	Assertions []evaluation.Assertion `json:"assertions"`
+	// TimeoutSeconds bounds the wait for the team's response. Defaults to 600.
+	TimeoutSeconds *int `json:"timeout_seconds"`
+}
+
+// UpdateEvaluationRequest is the payload for PUT
+// /api/teams/:id/evaluations/:evalId. Assertions replaces the whole list.
+type UpdateEvaluationRequest struct {
+	Name           *string                `json:"name"`
+	Prompt         *string                `json:"prompt"`
+	Assertions     []evaluation.Assertion `json:"assertions"`
+	TimeoutSeconds *int                   `json:"timeout_seconds"`
+}
+
+// RunEvaluationsRequest is the payload for POST
+// /api/teams/:id/evaluations/run. Both fields are optional.
+type RunEvaluationsRequest struct {
+	// EvaluationIDs limits the run to these evaluations. Defaults to all of
+	// the team's evaluations.
+	EvaluationIDs []string `json:"evaluation_ids"`
+	// GraderTeamID is a running team that grades llm assertions. Defaults
+	// to the evaluated team.
+	GraderTeamID string `json:"grader_team_id"`
+}
+
+// WorkspaceSyncRequest is the payload for POST
+// /api/teams/:id/workspace/push and /pull.
+type WorkspaceSyncRequest struct {
+	// URL is the S3 prefix, as s3://bucket/prefix.
+	URL string `json:"url"`
+	// Delete removes files of the destination that the source lacks.
+	Delete bool `json:"delete"`
+}
+
 // PostActionBindingResponse enriches a binding with the trigger's display name.
 type PostActionBindingResponse struct {
 	models.PostActionBinding
@@ -542,6 +1031,101 @@ func validateMcpServers(raw interface{}) error {
 	return nil
 }
 
+// marshalPermissions serializes an agent permissions payload, converting a
+// legacy single-string filesystem_scope into the {path, mode} list form and
+// validating its entries.
+func marshalPermissions(perms interface{}) (models.JSON, error) {
+	raw, _ := json.Marshal(perms)
+	normalized, err := permissions.NormalizePermissionsJSON(raw)
+	if err != nil {
+		return nil, fmt.Errorf("permissions: %w", err)
+	}
+	return models.JSON(normalized), nil
+}
+
+// validateConfigDirMode checks that config_dir_mode is one of the supported
+// modes. An empty string is valid (means "inline").
+func validateConfigDirMode(mode string) error {
+	switch mode {
+	case "", models.ConfigDirModeInline, models.ConfigDirModeGitignore, models.ConfigDirModeSeparate, models.ConfigDirModeShared:
+		return nil
+	}
+	return fmt.Errorf("invalid config_dir_mode %q: must be one of inline, gitignore, separate, shared", mode)
+}
+
+// validateSubAgentIsolation checks sub_agent_isolation. An empty string is
+// valid (means "worktree").
+func validateSubAgentIsolation(isolation string) error {
+	switch isolation {
+	case "", models.SubAgentIsolationWorktree, models.SubAgentIsolationNone:
+		return nil
+	}
+	return fmt.Errorf("invalid sub_agent_isolation %q: must be one of worktree, none", isolation)
+}
+
+// validateSubAgentPermissionMode checks sub_agent_permission_mode. An empty
+// string is valid (means models.DefaultSubAgentPermissionMode).
+func validateSubAgentPermissionMode(mode string) error {
+	if mode == "" || slices.Contains(models.SubAgentPermissionModes, mode) {
+		return nil
+	}
+	return fmt.Errorf("invalid sub_agent_permission_mode %q: must be one of %s", mode, strings.Join(models.SubAgentPermissionModes, ", "))
+}
+
+// marshalExtraClaudeArgs validates extra_claude_args and returns them as
+// stored on the agent. No arguments are stored as null.
+func marshalExtraClaudeArgs(args []string) (models.JSON, error) {
+	if err := protocol.ValidateClaudeExtraArgs(args); err != nil {
+		return nil, fmt.Errorf("invalid extra_claude_args: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, nil
+	}
+	raw, _ := json.Marshal(args)
+	return models.JSON(raw), nil
+}
+
+// hasExtraClaudeArgs reports whether agent has extra claude flags to pass to
+// its sidecar.
+func hasExtraClaudeArgs(agent models.Agent) bool {
+	return len(agent.ExtraClaudeArgs) > 0 && string(agent.ExtraClaudeArgs) != "null"
+}
+
+// subAgentFrontmatter returns the sub-agent frontmatter flags to store for a
+// new agent, with unset values replaced by their defaults.
+func subAgentFrontmatter(background *bool, isolation, permissionMode string) (*bool, string, string) {
+	if background == nil {
+		background = new(bool)
+		*background = true
+	}
+	if isolation == "" {
+		isolation = models.SubAgentIsolationWorktree
+	}
+	if permissionMode == "" {
+		permissionMode = models.DefaultSubAgentPermissionMode
+	}
+	return background, isolation, permissionMode
+}
+
+// maxRunAsID is the largest UID or GID accepted for run_as_uid/run_as_gid.
+const maxRunAsID = 1<<31 - 2
+
+// validateRunAs checks an agent's run_as_uid and run_as_gid. A GID needs a
+// UID; a UID alone runs with the group of the same number. The UID cannot
+// be 0: the agent CLI refuses to skip its permission prompts as root.
+func validateRunAs(uid, gid *int) error {
+	if uid != nil && (*uid < 1 || *uid > maxRunAsID) {
+		return fmt.Errorf("run_as_uid must be between 1 and %d", maxRunAsID)
+	}
+	if gid != nil && (*gid < 0 || *gid > maxRunAsID) {
+		return fmt.Errorf("run_as_gid must be between 0 and %d", maxRunAsID)
+	}
+	if gid != nil && uid == nil {
+		return fmt.Errorf("run_as_gid requires run_as_uid")
+	}
+	return nil
+}
+
 // validateModelProvider checks that the model_provider is valid for the given provider.
 // For "opencode" teams, model_provider must be one of the valid values or empty.
 // For "claude" teams, model_provider is ignored (always Anthropic).
@@ -581,3 +1165,17 @@ func validateAgentModelConsistency(modelProvider string, agents []CreateAgentInp
 	}
 	return nil
 }
+
+// RequestApprovalRequest is the optional payload of an operation that needs
+// an admin's approval, such as POST /api/teams/:id/deploy of a team that
+// requires deploy approval.
+type RequestApprovalRequest struct {
+	// Reason tells the approvers why the operation is needed.
+	Reason string `json:"reason"`
+}
+
+// DecideApprovalRequest is the optional payload for POST
+// /api/approvals/:id/approve and /reject.
+type DecideApprovalRequest struct {
+	Note string `json:"note"`
+}
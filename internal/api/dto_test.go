@@ -170,3 +170,14 @@ func TestValidateAgentModelConsistency(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateConfigDirMode(t *testing.T) {
+	for _, mode := range []string{"", "inline", "gitignore", "separate", "shared"} {
+		if err := validateConfigDirMode(mode); err != nil {
+			t.Errorf("validateConfigDirMode(%q) = %v, want nil", mode, err)
+		}
+	}
+	if err := validateConfigDirMode("overlay"); err == nil {
+		t.Error("validateConfigDirMode(\"overlay\") = nil, want error")
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorCode is a stable, machine-readable identifier returned in the code
+// field of every error response, so clients can branch on it instead of
+// the message.
+type ErrorCode string
+
+// Error codes. Errors without a specific code carry the generic code of
+// their status.
+const (
+	CodeBadRequest       ErrorCode = "BAD_REQUEST"
+	CodeInvalidBody      ErrorCode = "INVALID_BODY"
+	CodeAuthMissing      ErrorCode = "AUTH_MISSING"
+	CodeAuthInvalid      ErrorCode = "AUTH_INVALID"
+	CodeUnauthorized     ErrorCode = "UNAUTHORIZED"
+	CodeForbidden        ErrorCode = "FORBIDDEN"
+	CodeAdminRequired    ErrorCode = "ADMIN_REQUIRED"
+	CodeReadOnly         ErrorCode = "READ_ONLY"
+	CodeNotFound         ErrorCode = "NOT_FOUND"
+	CodeTeamNotFound     ErrorCode = "TEAM_NOT_FOUND"
+	CodeAgentNotFound    ErrorCode = "AGENT_NOT_FOUND"
+	CodeLeaderNotFound   ErrorCode = "LEADER_NOT_FOUND"
+	CodePatchNotFound    ErrorCode = "PATCH_NOT_FOUND"
+	CodeConflict         ErrorCode = "CONFLICT"
+	CodeTeamNotRunning   ErrorCode = "TEAM_NOT_RUNNING"
+	CodeTeamDeploying    ErrorCode = "TEAM_DEPLOYING"
+	CodeTeamBusy         ErrorCode = "TEAM_BUSY"
+	CodeNameConflict     ErrorCode = "NAME_CONFLICT"
+	CodePatchState       ErrorCode = "PATCH_STATE"
+	CodeNoLeader         ErrorCode = "NO_LEADER"
+	CodeWorkspaceInvalid ErrorCode = "WORKSPACE_INVALID"
+	CodeWorkspaceInUse   ErrorCode = "WORKSPACE_IN_USE"
+	CodeGone             ErrorCode = "GONE"
+	CodePayloadTooLarge  ErrorCode = "PAYLOAD_TOO_LARGE"
+	CodeRateLimited      ErrorCode = "RATE_LIMITED"
+	CodeInternal         ErrorCode = "INTERNAL"
+	CodeNotImplemented   ErrorCode = "NOT_IMPLEMENTED"
+	CodeUpstream         ErrorCode = "UPSTREAM_ERROR"
+	CodeUnavailable      ErrorCode = "UNAVAILABLE"
+	CodeMaintenance      ErrorCode = "MAINTENANCE"
+)
+
+// ErrorCodeInfo documents an error code and the status it is returned with.
+type ErrorCodeInfo struct {
+	Code        ErrorCode `json:"code"`
+	Status      int       `json:"status"`
+	Description string    `json:"description"`
+}
+
+// errorCatalog lists every error code, as served by GET /api/errors.
+var errorCatalog = []ErrorCodeInfo{
+	{CodeBadRequest, fiber.StatusBadRequest, "The request is invalid; the message names the field."},
+	{CodeInvalidBody, fiber.StatusBadRequest, "The request body is not valid JSON for the endpoint."},
+	{CodeWorkspaceInvalid, fiber.StatusBadRequest, "The team's workspace settings cannot be deployed."},
+	{CodeAuthMissing, fiber.StatusUnauthorized, "No credentials were sent."},
+	{CodeAuthInvalid, fiber.StatusUnauthorized, "The credentials are malformed, invalid or expired."},
+	{CodeUnauthorized, fiber.StatusUnauthorized, "The request is not authenticated."},
+	{CodeForbidden, fiber.StatusForbidden, "The caller may not perform the request."},
+	{CodeAdminRequired, fiber.StatusForbidden, "The request requires an organization admin."},
+	{CodeReadOnly, fiber.StatusForbidden, "Observers can only read."},
+	{CodeNotFound, fiber.StatusNotFound, "The resource does not exist or belongs to another organization."},
+	{CodeTeamNotFound, fiber.StatusNotFound, "The team does not exist or belongs to another organization."},
+	{CodeAgentNotFound, fiber.StatusNotFound, "The agent does not exist in the team."},
+	{CodeLeaderNotFound, fiber.StatusNotFound, "The team has no leader agent or leader container."},
+	{CodePatchNotFound, fiber.StatusNotFound, "The proposed patch does not exist in the team."},
+	{CodeConflict, fiber.StatusConflict, "The request conflicts with the resource's current state."},
+	{CodeTeamNotRunning, fiber.StatusConflict, "The team must be running; deploy it first."},
+	{CodeTeamDeploying, fiber.StatusConflict, "The team is still deploying."},
+	{CodeTeamBusy, fiber.StatusConflict, "Another operation or job holds the team; retry when it finishes or pass force=true."},
+	{CodeNameConflict, fiber.StatusConflict, "The team name becomes the same slug as another team's."},
+	{CodePatchState, fiber.StatusConflict, "The proposed patch was already applied, rejected or is being applied."},
+	{CodeNoLeader, fiber.StatusConflict, "The team's leader agent is not running."},
+	{CodeWorkspaceInUse, fiber.StatusConflict, "Another running team mounts the same workspace."},
+	{CodeGone, fiber.StatusGone, "The resource is no longer available."},
+	{CodePayloadTooLarge, fiber.StatusRequestEntityTooLarge, "The request body exceeds the size limit."},
+	{CodeRateLimited, fiber.StatusTooManyRequests, "Too many requests; retry later."},
+	{CodeInternal, fiber.StatusInternalServerError, "The server failed; details are in its logs."},
+	{CodeNotImplemented, fiber.StatusNotImplemented, "The runtime or provider does not support the request."},
+	{CodeUpstream, fiber.StatusBadGateway, "A service the API depends on failed."},
+	{CodeUnavailable, fiber.StatusServiceUnavailable, "The API or a dependency is temporarily unavailable."},
+	{CodeMaintenance, fiber.StatusServiceUnavailable, "The organization is in maintenance mode; deploys and chat messages are blocked."},
+}
+
+// errorStatus maps each error code to its status.
+var errorStatus = func() map[ErrorCode]int {
+	m := make(map[ErrorCode]int, len(errorCatalog))
+	for _, info := range errorCatalog {
+		m[info.Code] = info.Status
+	}
+	return m
+}()
+
+// APIError is an error response with a specific error code. Handlers
+// return it in place of a fiber.Error where clients need to tell the
+// error apart.
+type APIError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError returns an error with code, answered with the code's status.
+func newAPIError(code ErrorCode, message string) *APIError {
+	status, ok := errorStatus[code]
+	if !ok {
+		status = fiber.StatusInternalServerError
+	}
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// codeForStatus returns the generic error code of an HTTP status.
+func codeForStatus(status int) ErrorCode {
+	switch status {
+	case fiber.StatusBadRequest:
+		return CodeBadRequest
+	case fiber.StatusUnauthorized:
+		return CodeUnauthorized
+	case fiber.StatusForbidden:
+		return CodeForbidden
+	case fiber.StatusNotFound:
+		return CodeNotFound
+	case fiber.StatusConflict:
+		return CodeConflict
+	case fiber.StatusGone:
+		return CodeGone
+	case fiber.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case fiber.StatusTooManyRequests:
+		return CodeRateLimited
+	case fiber.StatusNotImplemented:
+		return CodeNotImplemented
+	case fiber.StatusBadGateway, fiber.StatusGatewayTimeout:
+		return CodeUpstream
+	case fiber.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status < 500 {
+		return CodeBadRequest
+	}
+	return CodeInternal
+}
+
+// ListErrorCodes returns the error code catalog.
+func (s *Server) ListErrorCodes(c *fiber.Ctx) error {
+	return c.JSON(errorCatalog)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestListErrorCodes(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/errors", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d", rec.Code)
+	}
+	var catalog []ErrorCodeInfo
+	parseJSON(t, rec, &catalog)
+	seen := make(map[ErrorCode]int)
+	for _, info := range catalog {
+		if _, dup := seen[info.Code]; dup {
+			t.Errorf("duplicate code %s", info.Code)
+		}
+		seen[info.Code] = info.Status
+		if info.Description == "" {
+			t.Errorf("%s has no description", info.Code)
+		}
+	}
+	for code, status := range map[ErrorCode]int{
+		CodeTeamNotRunning: http.StatusConflict,
+		CodeNoLeader:       http.StatusConflict,
+		CodeTeamBusy:       http.StatusConflict,
+		CodeNameConflict:   http.StatusConflict,
+		CodeAuthMissing:    http.StatusUnauthorized,
+		CodeInternal:       http.StatusInternalServerError,
+	} {
+		if seen[code] != status {
+			t.Errorf("%s: got status %d, want %d", code, seen[code], status)
+		}
+	}
+}
+
+func TestErrorResponses_CarryCodes(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	tests := []struct {
+		name, method, path string
+		body               any
+		status             int
+		code               ErrorCode
+	}{
+		{"specific code", "GET", "/api/teams/missing", nil, http.StatusNotFound, CodeTeamNotFound},
+		{"invalid body", "POST", "/api/teams", "not an object", http.StatusBadRequest, CodeInvalidBody},
+		{"generic code from status", "GET", "/api/schedules/missing", nil, http.StatusNotFound, CodeNotFound},
+		{"unknown route", "GET", "/api/no-such-route", nil, http.StatusNotFound, CodeNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(srv, tt.method, tt.path, tt.body)
+			var resp ErrorResponse
+			parseJSON(t, rec, &resp)
+			if rec.Code != tt.status || resp.Code != tt.code || resp.Error == "" {
+				t.Errorf("got %d %+v, want %d %s", rec.Code, resp, tt.status, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// weakETag returns a weak entity tag hashing the given version parts.
+func weakETag(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return `W/"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// notModified sets the ETag header and reports whether the request's
+// If-None-Match already names it, in which case the handler should respond
+// 304 without a body. Weak comparison is used, as RFC 9110 requires for
+// If-None-Match.
+func notModified(c *fiber.Ctx, etag string) bool {
+	c.Set(fiber.HeaderETag, etag)
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// teamETag versions a team response by its own and its agents' updated_at
+// and container status, which refreshContainerStatuses may change without
+// touching the team row.
+func teamETag(team models.Team) string {
+	parts := []string{team.ID, team.UpdatedAt.UTC().Format(time.RFC3339Nano)}
+	for _, a := range team.Agents {
+		parts = append(parts, a.ID, a.UpdatedAt.UTC().Format(time.RFC3339Nano), a.ContainerStatus)
+	}
+	return weakETag(parts...)
+}
+
+// listETag versions a list response by the number of matching rows, their
+// latest created_at and their latest updated_at, scoped to the request's
+// query string so pages and filters get distinct tags. It suits tables such
+// as task_logs, where a new row changes one of the first two and an update
+// in place, such as a chat message's delivery status, the last. The count
+// is returned so the caller can reuse it as the page total.
+func listETag(c *fiber.Ctx, base *gorm.DB, model interface{}, q listQuery) (string, int64, error) {
+	filtered := q.where(base.Session(&gorm.Session{})).Model(model)
+
+	var stats struct {
+		Count   int64
+		Updated sql.NullString
+	}
+	if err := filtered.Session(&gorm.Session{}).
+		Select("COUNT(*) AS count, MAX(updated_at) AS updated").Scan(&stats).Error; err != nil {
+		return "", 0, err
+	}
+	// Ordering with LIMIT 1 seeks the (…, created_at) index instead of
+	// scanning every matching row as MAX(created_at) would.
+	var latest sql.NullString
+	if stats.Count > 0 {
+		err := filtered.Session(&gorm.Session{}).Select("created_at").
+			Order("created_at DESC").Limit(1).Scan(&latest).Error
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return weakETag(c.Path(), string(c.Request().URI().QueryString()),
+		fmt.Sprint(stats.Count), latest.String, stats.Updated.String), stats.Count, nil
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// conditionalGet issues a GET with the given If-None-Match header and returns
+// the status code and ETag of the response.
+func conditionalGet(t *testing.T, srv *Server, path, ifNoneMatch string) (int, string) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := srv.App.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode, resp.Header.Get("ETag")
+}
+
+func TestNotModified(t *testing.T) {
+	etag := weakETag("a", "b")
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{etag, true},
+		{`W/"other", ` + etag, true},
+		{etag[2:], true}, // strong form of the same tag
+		{"*", true},
+		{`W/"other"`, false},
+	}
+	for _, tt := range tests {
+		srv, _ := setupTestServer(t)
+		srv.App.Get("/etag-test", func(c *fiber.Ctx) error {
+			if notModified(c, etag) {
+				return c.SendStatus(http.StatusNotModified)
+			}
+			return c.SendString("body")
+		})
+		code, _ := conditionalGet(t, srv, "/etag-test", tt.header)
+		if got := code == http.StatusNotModified; got != tt.want {
+			t.Errorf("If-None-Match %q: not modified = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGetTeam_ETag(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "etag-team")
+	path := "/api/teams/" + teamID
+
+	code, etag := conditionalGet(t, srv, path, "")
+	if code != http.StatusOK || etag == "" {
+		t.Fatalf("first GET: status %d, etag %q", code, etag)
+	}
+
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusNotModified {
+		t.Fatalf("unchanged team: got %d, want 304", code)
+	}
+
+	desc := "changed"
+	rec := doRequest(srv, "PUT", path, UpdateTeamRequest{Description: &desc})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update team: %d %s", rec.Code, rec.Body.String())
+	}
+	code, newETag := conditionalGet(t, srv, path, etag)
+	if code != http.StatusOK || newETag == etag {
+		t.Fatalf("changed team: status %d, etag %q (old %q), want 200 and new etag", code, newETag, etag)
+	}
+}
+
+func TestGetActivity_ETag(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "etag-activity")
+	path := "/api/teams/" + teamID + "/activity"
+
+	insertTaskLog(t, srv, "et-1", teamID, "leader", "user", "leader_response", map[string]string{"result": "a"})
+	code, etag := conditionalGet(t, srv, path, "")
+	if code != http.StatusOK || etag == "" {
+		t.Fatalf("first GET: status %d, etag %q", code, etag)
+	}
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusNotModified {
+		t.Fatalf("unchanged activity: got %d, want 304", code)
+	}
+
+	// A different page or filter must not reuse the tag.
+	if code, _ := conditionalGet(t, srv, path+"?limit=1", etag); code != http.StatusOK {
+		t.Errorf("different query: got %d, want 200", code)
+	}
+
+	insertTaskLog(t, srv, "et-2", teamID, "leader", "user", "leader_response", map[string]string{"result": "b"})
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusOK {
+		t.Fatalf("new activity: got %d, want 200", code)
+	}
+}
+
+func TestGetMessages_ETag(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "etag-messages")
+	path := "/api/teams/" + teamID + "/messages"
+
+	insertTaskLog(t, srv, "em-1", teamID, "user", "leader", "user_message", map[string]string{"content": "hi"})
+	_, etag := conditionalGet(t, srv, path, "")
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusNotModified {
+		t.Fatalf("unchanged messages: got %d, want 304", code)
+	}
+
+	// Entries hidden by the default type filter do not change the tag.
+	insertTaskLog(t, srv, "em-2", teamID, "leader", "worker", "task_assignment", map[string]string{"task": "x"})
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusNotModified {
+		t.Errorf("filtered-out entry: got %d, want 304", code)
+	}
+
+	var count int64
+	srv.db.Model(&models.TaskLog{}).Where("team_id = ?", teamID).Count(&count)
+	if count != 2 {
+		t.Fatalf("task logs: got %d, want 2", count)
+	}
+
+	// A message whose delivery status changed does.
+	time.Sleep(time.Millisecond)
+	srv.db.Model(&models.TaskLog{}).Where("id = ?", "em-1").Update("delivery_status", models.ChatDeliveryFailed)
+	if code, _ := conditionalGet(t, srv, path, etag); code != http.StatusOK {
+		t.Errorf("updated message: got %d, want 200", code)
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/events"
+	"github.com/helmcode/agent-crew/internal/integrations/issues"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/postaction"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// eventCounter counts the domain events published since the process started.
+type eventCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *eventCounter) observe(ev events.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[ev.EventName()]++
+}
+
+func (c *eventCounter) snapshot() map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := make(map[string]int64, len(c.counts))
+	for name, n := range c.counts {
+		counts[name] = n
+	}
+	return counts
+}
+
+// registerEventHandlers subscribes the modules that react to domain events.
+func (s *Server) registerEventHandlers() {
+	s.events.SubscribeAll(s.eventCounts.observe)
+	events.Subscribe(s.events, s.recordDeployOutcome)
+	events.Subscribe(s.events, s.firePostActions)
+	events.Subscribe(s.events, s.syncRunToIssues)
+	events.Subscribe(s.events, s.notifyApprovalRequested)
+	events.Subscribe(s.events, s.resolveApprovalNotice)
+}
+
+// Events returns the bus domain events are published on, so that modules
+// outside the API, such as the scheduler, publish theirs to the same
+// subscribers.
+func (s *Server) Events() *events.Bus {
+	return s.events
+}
+
+// publishDeployOutcome publishes TeamDeployed with the status a deployment
+// or leader restart left the team in. Nothing is published if it is neither
+// running nor in error, such as after a forced stop.
+func (s *Server) publishDeployOutcome(teamID string) {
+	var team models.Team
+	if err := s.db.Select("id", "org_id", "name", "status", "status_message").
+		First(&team, "id = ?", teamID).Error; err != nil {
+		return
+	}
+	if team.Status != models.TeamStatusRunning && team.Status != models.TeamStatusError {
+		return
+	}
+	s.events.Publish(events.TeamDeployed{
+		OrgID:    team.OrgID,
+		TeamID:   team.ID,
+		TeamName: team.Name,
+		Status:   team.Status,
+		Message:  team.StatusMessage,
+	})
+}
+
+// recordDeployOutcome updates a team's consecutive deploy failure count from
+// the status a deployment ended in. Alert integrations use the count to detect
+// repeatedly failing deployments. Chats queued during a failed deployment are
+// marked as failed.
+func (s *Server) recordDeployOutcome(ev events.TeamDeployed) {
+	switch ev.Status {
+	case models.TeamStatusError:
+		s.db.Model(&models.Team{}).Where("id = ?", ev.TeamID).
+			Update("deploy_failures", gorm.Expr("deploy_failures + 1"))
+		s.failQueuedChats(ev.TeamID)
+	case models.TeamStatusRunning:
+		s.db.Model(&models.Team{}).Where("id = ?", ev.TeamID).Update("deploy_failures", 0)
+	}
+}
+
+// firePostActions runs the post-actions bound to a finished run's trigger.
+func (s *Server) firePostActions(run events.RunCompleted) {
+	s.postActionExec.ExecutePostActions(postaction.PostActionContext{
+		SourceType:  run.SourceType,
+		TriggerID:   run.TriggerID,
+		RunID:       run.RunID,
+		Status:      run.Status,
+		Response:    run.Response,
+		Error:       run.Error,
+		TriggerName: run.TriggerName,
+		TeamName:    run.TeamName,
+		Prompt:      run.Prompt,
+		StartedAt:   run.StartedAt.Format(time.RFC3339),
+		FinishedAt:  run.FinishedAt.Format(time.RFC3339),
+	})
+}
+
+// syncRunToIssues reports a finished run to the team's issue trackers.
+func (s *Server) syncRunToIssues(run events.RunCompleted) {
+	s.issueNotifier.NotifyRunCompleted(issues.RunResult{
+		SourceType:  run.SourceType,
+		TriggerID:   run.TriggerID,
+		TriggerName: run.TriggerName,
+		RunID:       run.RunID,
+		TeamID:      run.TeamID,
+		TeamName:    run.TeamName,
+		Status:      run.Status,
+		Response:    run.Response,
+		Error:       run.Error,
+		Prompt:      run.Prompt,
+		StartedAt:   run.StartedAt,
+		FinishedAt:  run.FinishedAt,
+	})
+}
+
+// publishPermissionDenied publishes PermissionDenied for the denials agents
+// report as activity events.
+func (s *Server) publishPermissionDenied(teamID, teamName string, msg protocol.Message) {
+	var activity protocol.ActivityEventPayload
+	if err := json.Unmarshal(msg.Payload, &activity); err != nil || activity.EventType != protocol.ActivityPermissionDenied {
+		return
+	}
+	var denied protocol.PermissionDeniedPayload
+	json.Unmarshal(activity.Payload, &denied)
+	s.events.Publish(events.PermissionDenied{
+		TeamID:    teamID,
+		TeamName:  teamName,
+		AgentName: activity.AgentName,
+		Tool:      activity.ToolName,
+		Command:   denied.Command,
+		Reason:    denied.Reason,
+	})
+}
+
+// publishAgentDegraded publishes AgentDegraded for the health checks that
+// found an agent degraded. Recoveries are only saved as activity.
+func (s *Server) publishAgentDegraded(teamID, teamName string, msg protocol.Message) {
+	var health protocol.HealthCheckPayload
+	if err := json.Unmarshal(msg.Payload, &health); err != nil || !health.Degraded {
+		return
+	}
+	var problems []string
+	for _, c := range health.Checks {
+		if c.Status != protocol.ValidationOK {
+			problems = append(problems, c.Name+": "+c.Message)
+		}
+	}
+	slog.Warn("agent degraded", "team", teamName, "agent", health.AgentName, "summary", health.Summary)
+	s.events.Publish(events.AgentDegraded{
+		TeamID:    teamID,
+		TeamName:  teamName,
+		AgentName: health.AgentName,
+		Summary:   health.Summary,
+		Problems:  problems,
+	})
+}
+
+// GetEventCounts returns the number of domain events of each kind this
+// replica published since it started (admin only).
+func (s *Server) GetEventCounts(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view event counts")
+	}
+	return c.JSON(fiber.Map{"counts": s.eventCounts.snapshot()})
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/events"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestDeployTeam_PublishesTeamDeployed(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	deployed := make(chan events.TeamDeployed, 1)
+	events.Subscribe(srv.Events(), func(ev events.TeamDeployed) { deployed <- ev })
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "event-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil); rec.Code != 200 {
+		t.Fatalf("deploy: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case ev := <-deployed:
+		if ev.TeamID != team.ID || ev.OrgID != team.OrgID || ev.Status != models.TeamStatusRunning {
+			t.Errorf("event: got %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no TeamDeployed event")
+	}
+}
+
+func TestProcessRelayMessage_PublishesPermissionDenied(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "denied-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	var denied []events.PermissionDenied
+	events.Subscribe(srv.Events(), func(ev events.PermissionDenied) { denied = append(denied, ev) })
+
+	raw, _ := json.Marshal(protocol.PermissionDeniedPayload{Command: "rm -rf /", Reason: "tool not allowed: Bash"})
+	data := buildRelayPayload(t, protocol.TypeActivityEvent, "leader", "system", protocol.ActivityEventPayload{
+		EventType: protocol.ActivityPermissionDenied,
+		AgentName: "leader",
+		ToolName:  "Bash",
+		Payload:   raw,
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	// Other activity events are not denials.
+	data = buildRelayPayload(t, protocol.TypeActivityEvent, "leader", "system", protocol.ActivityEventPayload{
+		EventType: "tool_use",
+		AgentName: "leader",
+		ToolName:  "Read",
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	if len(denied) != 1 {
+		t.Fatalf("PermissionDenied events: got %d, want 1", len(denied))
+	}
+	if ev := denied[0]; ev.TeamID != team.ID || ev.AgentName != "leader" || ev.Tool != "Bash" ||
+		ev.Command != "rm -rf /" || ev.Reason != "tool not allowed: Bash" {
+		t.Errorf("event: got %+v", ev)
+	}
+
+	var counts struct {
+		Counts map[string]int64 `json:"counts"`
+	}
+	parseJSON(t, doRequest(srv, "GET", "/api/admin/events", nil), &counts)
+	if counts.Counts[events.NamePermissionDenied] != 1 {
+		t.Errorf("event counts: got %v", counts.Counts)
+	}
+}
+
+func TestProcessRelayMessage_HealthCheck(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "health-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	var degraded []events.AgentDegraded
+	events.Subscribe(srv.Events(), func(ev events.AgentDegraded) { degraded = append(degraded, ev) })
+
+	checks := []protocol.ValidationCheck{
+		{Name: "cli", Status: protocol.ValidationOK, Message: "claude 1.0.83 runs"},
+		{Name: "auth", Status: protocol.ValidationError, Message: "the API key was rejected (HTTP 401)"},
+	}
+	for _, isDegraded := range []bool{true, false} {
+		data := buildRelayPayload(t, protocol.TypeHealthCheck, "leader", "system", protocol.HealthCheckPayload{
+			AgentName: "leader",
+			Checks:    checks,
+			Summary:   "1 ok, 0 warning(s), 1 error(s)",
+			Degraded:  isDegraded,
+		})
+		if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+
+	if len(degraded) != 1 {
+		t.Fatalf("AgentDegraded events: got %d, want 1", len(degraded))
+	}
+	if ev := degraded[0]; ev.TeamID != team.ID || ev.AgentName != "leader" ||
+		len(ev.Problems) != 1 || ev.Problems[0] != "auth: the API key was rejected (HTTP 401)" {
+		t.Errorf("event: got %+v", ev)
+	}
+
+	var logs []models.TaskLog
+	srv.db.Where("team_id = ? AND message_type = ?", team.ID, string(protocol.TypeHealthCheck)).Find(&logs)
+	if len(logs) != 2 {
+		t.Errorf("health check task logs: got %d, want 2", len(logs))
+	}
+}
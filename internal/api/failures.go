@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/failures"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// requestLocale returns the locale of the request's failure messages: the
+// locale query parameter if supported, otherwise the Accept-Language header.
+func requestLocale(c *fiber.Ctx) string {
+	c.Vary(fiber.HeaderAcceptLanguage)
+	return failures.Negotiate(c.Query("locale"), c.Get(fiber.HeaderAcceptLanguage))
+}
+
+// localeETag varies etag by locale, leaving the default locale's tags as
+// they were.
+func localeETag(etag, locale string) string {
+	if locale == failures.DefaultLocale {
+		return etag
+	}
+	return weakETag(etag, locale)
+}
+
+// explainTeamFailure sets the localized explanation of a failed deployment.
+func explainTeamFailure(team *models.Team, locale string) {
+	if team.Status == models.TeamStatusError && team.StatusMessage != "" {
+		team.StatusFailure = failures.Deploy(team.StatusMessage, locale)
+	}
+}
+
+// explainLogFailures sets the localized explanation of failed leader
+// responses.
+func explainLogFailures(logs []models.TaskLog, locale string) {
+	for i := range logs {
+		if logs[i].MessageType != string(protocol.TypeLeaderResponse) {
+			continue
+		}
+		var payload protocol.LeaderResponsePayload
+		if json.Unmarshal(logs[i].Payload, &payload) != nil {
+			continue
+		}
+		if payload.Status != "failed" && payload.ErrorCode == "" {
+			continue
+		}
+		msg := payload.Error
+		if msg == "" {
+			msg = payload.Result
+		}
+		logs[i].Failure = failures.Agent(payload.ErrorCode, msg, locale)
+	}
+}
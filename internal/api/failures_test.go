@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/failures"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// getWithLanguage issues a GET with an Accept-Language header and decodes
+// the response into out.
+func getWithLanguage(t *testing.T, srv *Server, path, acceptLanguage string, out any) {
+	t.Helper()
+	req := httptest.NewRequest("GET", path, nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	resp, err := srv.App.Test(req, -1)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("decoding %s: %v", path, err)
+	}
+}
+
+func TestGetTeam_ExplainsDeployFailure(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var team models.Team
+	parseJSON(t, doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "failing-team"}), &team)
+	srv.db.Model(&models.Team{}).Where("id = ?", team.ID).Updates(map[string]interface{}{
+		"status":         models.TeamStatusError,
+		"status_message": "pull access denied for ghcr.io/acme/agent",
+	})
+
+	var got models.Team
+	getWithLanguage(t, srv, "/api/teams/"+team.ID, "es-ES,es;q=0.9", &got)
+	f := got.StatusFailure
+	if f == nil || f.Code != failures.DeployImagePull || f.Locale != "es" || f.Detail != "pull access denied for ghcr.io/acme/agent" {
+		t.Fatalf("status_failure: got %+v", f)
+	}
+
+	var page struct {
+		Items []models.Team `json:"items"`
+	}
+	getWithLanguage(t, srv, "/api/teams?locale=en", "es", &page)
+	if len(page.Items) != 1 || page.Items[0].StatusFailure == nil || page.Items[0].StatusFailure.Locale != "en" {
+		t.Errorf("list: got %+v", page.Items)
+	}
+}
+
+func TestGetTeam_ETagVariesByLocale(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var team models.Team
+	parseJSON(t, doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "etag-locale-team"}), &team)
+
+	_, en := conditionalGet(t, srv, "/api/teams/"+team.ID, "")
+	if status, _ := conditionalGet(t, srv, "/api/teams/"+team.ID+"?locale=es", en); status == 304 {
+		t.Error("Spanish request answered 304 with the English ETag")
+	}
+}
+
+func TestGetMessages_ExplainsFailedLeaderResponse(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var team models.Team
+	parseJSON(t, doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "failed-response-team"}), &team)
+
+	payload := func(p protocol.LeaderResponsePayload) models.JSON {
+		data, _ := json.Marshal(p)
+		return data
+	}
+	srv.db.Create(&[]models.TaskLog{
+		{ID: "ok", TeamID: team.ID, MessageType: "leader_response", Payload: payload(protocol.LeaderResponsePayload{Status: "completed", Result: "done"})},
+		{ID: "failed", TeamID: team.ID, MessageType: "leader_response", Payload: payload(protocol.LeaderResponsePayload{
+			Status: "failed", Error: "Your API key has insufficient credits.", ErrorCode: "billing_error",
+		})},
+	})
+
+	var page struct {
+		Items []models.TaskLog `json:"items"`
+	}
+	getWithLanguage(t, srv, "/api/teams/"+team.ID+"/messages?locale=es", "", &page)
+	byID := make(map[string]models.TaskLog)
+	for _, l := range page.Items {
+		byID[l.ID] = l
+	}
+	if byID["ok"].Failure != nil {
+		t.Errorf("completed response: got failure %+v", byID["ok"].Failure)
+	}
+	f := byID["failed"].Failure
+	if f == nil || f.Code != failures.AgentBilling || f.Locale != "es" || len(f.Remediation) == 0 {
+		t.Errorf("failed response: got %+v", f)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// benchTaskLogRows is the number of task logs seeded for the benchmarked
+// team. Override with AGENTCREW_BENCH_TASKLOG_ROWS for quicker runs.
+func benchTaskLogRows(b *testing.B) int {
+	if v := os.Getenv("AGENTCREW_BENCH_TASKLOG_ROWS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			b.Fatalf("invalid AGENTCREW_BENCH_TASKLOG_ROWS %q", v)
+		}
+		return n
+	}
+	return 1_000_000
+}
+
+// seedTaskLogs bulk-inserts n task logs for teamID, one second apart. One in
+// twenty is a leader response, so GetMessages has to skip activity events.
+func seedTaskLogs(b *testing.B, srv *Server, teamID string, n int) {
+	b.Helper()
+	sqlDB, err := srv.db.DB()
+	if err != nil {
+		b.Fatalf("getting sql.DB: %v", err)
+	}
+	tx, err := sqlDB.Begin()
+	if err != nil {
+		b.Fatalf("begin: %v", err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO task_logs
+		(id, team_id, message_id, conversation_id, from_agent, to_agent, message_type, payload, delivery_status, sequence, created_at)
+		VALUES (?, ?, '', '', ?, ?, ?, ?, '', 0, ?)`)
+	if err != nil {
+		b.Fatalf("prepare: %v", err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		from, to, msgType := "leader", "worker", string(protocol.TypeActivityEvent)
+		if i%20 == 0 {
+			from, to, msgType = "leader", "user", string(protocol.TypeLeaderResponse)
+		}
+		if _, err := stmt.Exec(fmt.Sprintf("%s-%08d", teamID[:8], i), teamID, from, to, msgType,
+			`{"status":"completed","result":"benchmark payload"}`, start.Add(time.Duration(i)*time.Second)); err != nil {
+			b.Fatalf("insert: %v", err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		b.Fatalf("commit: %v", err)
+	}
+	if _, err := sqlDB.Exec("ANALYZE"); err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+}
+
+// setupBenchServer creates a Server on a file-backed database, like
+// production, with one team of seeded task logs next to a small second team.
+func setupBenchServer(b *testing.B) (*Server, string) {
+	b.Helper()
+	db, err := models.InitDB(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("InitDB: %v", err)
+	}
+	noopAuth, err := auth.NewNoopProvider(db)
+	if err != nil {
+		b.Fatalf("NewNoopProvider: %v", err)
+	}
+	srv := NewServer(db, &mockRuntime{}, noopAuth)
+	b.Cleanup(func() { srv.taskLogs.Stop() })
+
+	var teamIDs []string
+	for _, name := range []string{"bench-big", "bench-small"} {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: name})
+		var team models.Team
+		if err := json.Unmarshal(rec.Body.Bytes(), &team); err != nil || team.ID == "" {
+			b.Fatalf("creating team %q: status %d, body: %s", name, rec.Code, rec.Body.String())
+		}
+		teamIDs = append(teamIDs, team.ID)
+	}
+
+	rows := benchTaskLogRows(b)
+	seedStart := time.Now()
+	seedTaskLogs(b, srv, teamIDs[0], rows)
+	seedTaskLogs(b, srv, teamIDs[1], 1000)
+	b.Logf("seeded %d task logs in %s", rows, time.Since(seedStart).Round(time.Millisecond))
+	return srv, teamIDs[0]
+}
+
+// benchGet issues a GET and fails the benchmark on an unexpected status.
+func benchGet(b *testing.B, srv *Server, path, ifNoneMatch string, want int) string {
+	req := httptest.NewRequest("GET", path, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := srv.App.Test(req, -1)
+	if err != nil {
+		b.Fatalf("GET %s: %v", path, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != want {
+		b.Fatalf("GET %s: status %d, want %d", path, resp.StatusCode, want)
+	}
+	return resp.Header.Get("ETag")
+}
+
+// BenchmarkTaskLogQueries measures the activity and messages endpoints
+// against a team with 1M task logs. Pages, cursors and type filters should
+// stay in single-digit milliseconds at any depth; unfiltered activity is
+// bounded by counting the team's rows for "total" (an index-only scan, on
+// the order of 100ms at 1M rows).
+//
+//	go test ./internal/api -run '^$' -bench TaskLogQueries -benchtime 50x
+func BenchmarkTaskLogQueries(b *testing.B) {
+	srv, teamID := setupBenchServer(b)
+	base := "/api/teams/" + teamID
+
+	// A cursor halfway through the history, for deep pagination.
+	midCursor := encodeCursor(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).
+		Add(time.Duration(benchTaskLogRows(b)/2)*time.Second), fmt.Sprintf("%s-%08d", teamID[:8], benchTaskLogRows(b)/2))
+
+	cases := []struct {
+		name string
+		path string
+	}{
+		{"ActivityFirstPage", base + "/activity"},
+		{"ActivityDeepCursor", base + "/activity?cursor=" + midCursor},
+		{"ActivityByType", base + "/activity?type=" + string(protocol.TypeLeaderResponse)},
+		{"ActivitySinceRange", base + "/activity?since=2026-01-05T00:00:00Z&until=2026-01-06T00:00:00Z"},
+		{"MessagesFirstPage", base + "/messages"},
+		{"MessagesDeepCursor", base + "/messages?cursor=" + midCursor},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				benchGet(b, srv, tc.path, "", 200)
+			}
+		})
+	}
+
+	b.Run("ActivityNotModified", func(b *testing.B) {
+		etag := benchGet(b, srv, base+"/activity", "", 200)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			benchGet(b, srv, base+"/activity", etag, 304)
+		}
+	})
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/helmcode/agent-crew/internal/models"
 	"github.com/helmcode/agent-crew/internal/protocol"
@@ -58,8 +60,7 @@ func TestGetActivity_AllTypes(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 2 {
 		t.Fatalf("activity entries: got %d, want 2", len(logs))
 	}
@@ -98,8 +99,7 @@ func TestGetActivity_LeaderResponsePayload(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 2 {
 		t.Fatalf("entries: got %d, want 2", len(logs))
 	}
@@ -152,8 +152,7 @@ func TestGetActivity_PreservesAgentNames(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 3 {
 		t.Fatalf("entries: got %d, want 3", len(logs))
 	}
@@ -193,8 +192,7 @@ func TestGetActivity_CursorPagination(t *testing.T) {
 
 	// Get all messages first.
 	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity", nil)
-	var allLogs []models.TaskLog
-	parseJSON(t, rec, &allLogs)
+	allLogs := parseList[models.TaskLog](t, rec)
 	if len(allLogs) != 5 {
 		t.Fatalf("expected 5 activity entries, got %d", len(allLogs))
 	}
@@ -207,13 +205,56 @@ func TestGetActivity_CursorPagination(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec2.Code)
 	}
 
-	var olderLogs []models.TaskLog
-	parseJSON(t, rec2, &olderLogs)
+	olderLogs := parseList[models.TaskLog](t, rec2)
 	if len(olderLogs) != 0 {
 		t.Fatalf("expected 0 entries before oldest, got %d", len(olderLogs))
 	}
 }
 
+func TestGetActivity_FilterByAgentToolAndSince(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "activity-filters")
+
+	now := time.Now().UTC()
+	for _, log := range []models.TaskLog{
+		{ID: "af-bash", FromAgent: "leader", EventType: "tool_use", ToolName: "Bash", CreatedAt: now.Add(-10 * time.Minute)},
+		{ID: "af-old-bash", FromAgent: "leader", EventType: "tool_use", ToolName: "Bash", CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: "af-read", FromAgent: "leader", EventType: "tool_use", ToolName: "Read", CreatedAt: now.Add(-5 * time.Minute)},
+		{ID: "af-worker-bash", FromAgent: "worker", EventType: "tool_use", ToolName: "Bash", CreatedAt: now.Add(-5 * time.Minute)},
+		{ID: "af-assistant", FromAgent: "leader", EventType: "assistant", CreatedAt: now.Add(-5 * time.Minute)},
+	} {
+		log.TeamID = teamID
+		log.MessageType = string(protocol.TypeActivityEvent)
+		log.Payload = models.JSON(`{}`)
+		if err := srv.db.Create(&log).Error; err != nil {
+			t.Fatalf("inserting task log: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"from_agent=leader&tool=Bash&since=" + now.Add(-time.Hour).Format(time.RFC3339), []string{"af-bash"}},
+		{"tool=Bash", []string{"af-worker-bash", "af-bash", "af-old-bash"}},
+		{"tool=Bash,Read&from_agent=leader", []string{"af-read", "af-bash", "af-old-bash"}},
+		{"event_type=assistant", []string{"af-assistant"}},
+	}
+	for _, tt := range tests {
+		rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity?"+tt.query, nil)
+		if rec.Code != 200 {
+			t.Fatalf("%s: status %d", tt.query, rec.Code)
+		}
+		var got []string
+		for _, log := range parseList[models.TaskLog](t, rec) {
+			got = append(got, log.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
 func TestGetActivity_LimitParameter(t *testing.T) {
 	srv, _ := setupTestServer(t)
 	teamID := createTeamForActivity(t, srv, "activity-limit")
@@ -229,8 +270,7 @@ func TestGetActivity_LimitParameter(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 3 {
 		t.Fatalf("entries with limit=3: got %d, want 3", len(logs))
 	}
@@ -262,8 +302,7 @@ func TestGetActivity_OrderDescending(t *testing.T) {
 		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
 
 	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity", nil)
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 
 	if len(logs) < 2 {
 		t.Fatalf("expected at least 2 entries, got %d", len(logs))
@@ -296,8 +335,7 @@ func TestGetMessages_ExcludesNonChatTypes(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 
 	if len(logs) != 2 {
 		t.Fatalf("filtered messages: got %d, want 2 (user_message + leader_response)", len(logs))
@@ -325,8 +363,7 @@ func TestGetMessages_CustomFilterIncludesSpecificType(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 1 {
 		t.Fatalf("custom type filter: got %d, want 1", len(logs))
 	}
@@ -352,8 +389,7 @@ func TestGetMessages_MultipleCustomTypes(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 2 {
 		t.Fatalf("multi-type filter: got %d, want 2", len(logs))
 	}
@@ -379,8 +415,7 @@ func TestGetActivity_EmptyTeam(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 0 {
 		t.Fatalf("entries for empty team: got %d, want 0", len(logs))
 	}
@@ -413,16 +448,14 @@ func TestGetActivity_IsolationBetweenTeams(t *testing.T) {
 
 	// Team 1 activity should only see 2 entries.
 	rec1 := doRequest(srv, "GET", "/api/teams/"+team1ID+"/activity", nil)
-	var logs1 []models.TaskLog
-	parseJSON(t, rec1, &logs1)
+	logs1 := parseList[models.TaskLog](t, rec1)
 	if len(logs1) != 2 {
 		t.Fatalf("team 1 activity: got %d, want 2", len(logs1))
 	}
 
 	// Team 2 activity should only see 1 entry.
 	rec2 := doRequest(srv, "GET", "/api/teams/"+team2ID+"/activity", nil)
-	var logs2 []models.TaskLog
-	parseJSON(t, rec2, &logs2)
+	logs2 := parseList[models.TaskLog](t, rec2)
 	if len(logs2) != 1 {
 		t.Fatalf("team 2 activity: got %d, want 1", len(logs2))
 	}
@@ -445,8 +478,7 @@ func TestGetActivity_ArbitraryPayloadPreserved(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 1 {
 		t.Fatalf("entries: got %d, want 1", len(logs))
 	}
@@ -484,16 +516,14 @@ func TestGetActivity_MixedWorkflow(t *testing.T) {
 
 	// GetActivity should return all 2 entries (1 user_message + 1 leader_response).
 	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity", nil)
-	var activityLogs []models.TaskLog
-	parseJSON(t, rec, &activityLogs)
+	activityLogs := parseList[models.TaskLog](t, rec)
 	if len(activityLogs) != 2 {
 		t.Fatalf("activity: got %d, want 2", len(activityLogs))
 	}
 
 	// GetMessages (default filter) should also return both — they are chat types.
 	rec2 := doRequest(srv, "GET", "/api/teams/"+teamID+"/messages", nil)
-	var chatLogs []models.TaskLog
-	parseJSON(t, rec2, &chatLogs)
+	chatLogs := parseList[models.TaskLog](t, rec2)
 	if len(chatLogs) != 2 {
 		t.Fatalf("chat messages: got %d, want 2", len(chatLogs))
 	}
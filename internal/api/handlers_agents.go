@@ -1,34 +1,53 @@
 package api
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
 	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
-// ListAgents returns all agents for a team.
+// agentListOptions configures GET /api/teams/:id/agents: oldest first,
+// filterable by container status and role.
+var agentListOptions = listOptions{
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	Filters:      map[string]string{"status": "container_status", "role": "role"},
+}
+
+// ListAgents returns the agents of a team, paginated.
 func (s *Server) ListAgents(c *fiber.Ctx) error {
 	teamID := c.Params("id")
 
 	// Verify team exists and belongs to org.
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
-	var agents []models.Agent
-	if err := s.db.Where("team_id = ?", teamID).Find(&agents).Error; err != nil {
+	q, err := parseListQuery(c, agentListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", teamID), q, agentKey)
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to list agents")
 	}
-	return c.JSON(agents)
+	return c.JSON(resp)
 }
 
 // GetAgent returns a single agent.
@@ -39,42 +58,52 @@ func (s *Server) GetAgent(c *fiber.Ctx) error {
 	// Verify team belongs to org.
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 	return c.JSON(agent)
 }
 
 // CreateAgent adds a new agent to a team.
 func (s *Server) CreateAgent(c *fiber.Ctx) error {
-	teamID := c.Params("id")
-
 	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var req CreateAgentRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
+	agent, err := s.createAgent(c, team, req)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusCreated).JSON(agent)
+}
+
+// createAgent validates req and adds the agent it describes to team. Errors
+// are fiber errors ready to return from a handler.
+func (s *Server) createAgent(c *fiber.Ctx, team models.Team, req CreateAgentRequest) (models.Agent, error) {
+	teamID := team.ID
+
 	if req.Name == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, "name is required")
 	}
 	if err := validateName(req.Name); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
 	// Check for duplicate agent name within the team.
 	var count int64
 	s.db.Model(&models.Agent{}).Where("team_id = ? AND LOWER(name) = LOWER(?)", teamID, req.Name).Count(&count)
 	if count > 0 {
-		return fiber.NewError(fiber.StatusConflict, "agent name already exists in this team: "+req.Name)
+		return models.Agent{}, fiber.NewError(fiber.StatusConflict, "agent name already exists in this team: "+req.Name)
 	}
 
 	role := req.Role
@@ -82,13 +111,13 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 		role = models.AgentRoleWorker
 	}
 	if role != models.AgentRoleLeader && role != models.AgentRoleWorker {
-		return fiber.NewError(fiber.StatusBadRequest, "role must be 'leader' or 'worker'")
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, "role must be 'leader' or 'worker'")
 	}
 
 	if req.SubAgentModel != "" && !isValidSubAgentModel(req.SubAgentModel) {
 		// For OpenCode teams, allow provider/model format if it matches team's model_provider.
 		if team.Provider != models.ProviderOpenCode || !isValidOpenCodeModel(req.SubAgentModel, team.ModelProvider) {
-			return fiber.NewError(fiber.StatusBadRequest, "sub_agent_model must be one of: inherit, sonnet, opus, haiku")
+			return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, "sub_agent_model must be one of: inherit, sonnet, opus, haiku")
 		}
 	}
 
@@ -96,25 +125,48 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 	if team.ModelProvider != "" && req.SubAgentModel != "" && req.SubAgentModel != "inherit" {
 		agentInput := CreateAgentInput{Name: req.Name, SubAgentModel: req.SubAgentModel}
 		if err := validateAgentModelConsistency(team.ModelProvider, []CreateAgentInput{agentInput}); err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 	}
 
 	if len(req.SubAgentDescription) > maxDescriptionSize {
-		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sub_agent_description exceeds maximum size of %d bytes", maxDescriptionSize))
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sub_agent_description exceeds maximum size of %d bytes", maxDescriptionSize))
 	}
 	if len(req.SubAgentInstructions) > maxInstructionsSize {
-		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sub_agent_instructions exceeds maximum size of %d bytes", maxInstructionsSize))
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sub_agent_instructions exceeds maximum size of %d bytes", maxInstructionsSize))
 	}
 
 	if req.SubAgentSkills != nil {
 		if err := validateSubAgentSkills(req.SubAgentSkills); err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 	}
+	if err := validateSubAgentIsolation(req.SubAgentIsolation); err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := validateSubAgentPermissionMode(req.SubAgentPermissionMode); err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 
 	skills, _ := json.Marshal(req.Skills)
-	perms, _ := json.Marshal(req.Permissions)
+	perms, err := marshalPermissions(req.Permissions)
+	if err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	policy, err := s.loadResourcePolicy(c)
+	if err != nil {
+		return models.Agent{}, err
+	}
+	if err := policy.CheckOverride(req.Resources); err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := validateRunAs(req.RunAsUID, req.RunAsGID); err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	extraClaudeArgs, err := marshalExtraClaudeArgs(req.ExtraClaudeArgs)
+	if err != nil {
+		return models.Agent{}, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
 	resources, _ := json.Marshal(req.Resources)
 	subAgentSkills, _ := json.Marshal(req.SubAgentSkills)
 
@@ -122,6 +174,7 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 	if subAgentModel == "" {
 		subAgentModel = "inherit"
 	}
+	background, isolation, permissionMode := subAgentFrontmatter(req.SubAgentBackground, req.SubAgentIsolation, req.SubAgentPermissionMode)
 
 	// Backward compat: accept claude_md as alias for instructions_md.
 	instructionsMD := req.InstructionsMD
@@ -139,24 +192,31 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 		SystemPrompt:        req.SystemPrompt,
 		InstructionsMD:      instructionsMD,
 		Skills:              models.JSON(skills),
-		Permissions:         models.JSON(perms),
+		Permissions:         perms,
 		Resources:           models.JSON(resources),
 		SubAgentDescription:  req.SubAgentDescription,
 		SubAgentInstructions: req.SubAgentInstructions,
 		SubAgentModel:        subAgentModel,
 		SubAgentSkills:       models.JSON(subAgentSkills),
+		SubAgentBackground:     background,
+		SubAgentIsolation:      isolation,
+		SubAgentPermissionMode: permissionMode,
+		RunAsUID:             req.RunAsUID,
+		RunAsGID:             req.RunAsGID,
+		SkipWorkspaceChown:   req.SkipWorkspaceChown,
+		ExtraClaudeArgs:      extraClaudeArgs,
 	}
 
 	if err := s.db.Create(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "failed to create agent")
+		return models.Agent{}, fiber.NewError(fiber.StatusInternalServerError, "failed to create agent")
 	}
 
 	// If the team is running and the new agent is a worker, create the .md file
 	// in the leader's container so it's immediately available.
 	if team.Status == models.TeamStatusRunning && agent.Role == models.AgentRoleWorker {
 		var leader models.Agent
-		if err := s.db.Where("team_id = ? AND role = ? AND container_status = ?",
-			teamID, models.AgentRoleLeader, models.ContainerStatusRunning).First(&leader).Error; err == nil {
+		if err := s.db.Where("team_id = ? AND role = ? AND container_status IN ?",
+			teamID, models.AgentRoleLeader, models.ContainerUpStatuses).First(&leader).Error; err == nil {
 
 			// Include leader's global skills in the new subagent's .md file.
 			var globalSkills json.RawMessage
@@ -165,13 +225,16 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 			}
 
 			subInfo := runtime.SubAgentInfo{
-				Name:         agent.Name,
-				Description:  agent.SubAgentDescription,
-				Instructions: agent.SubAgentInstructions,
-				Model:        agent.SubAgentModel,
-				Skills:       json.RawMessage(agent.SubAgentSkills),
-				GlobalSkills: globalSkills,
-				ClaudeMD:     agent.InstructionsMD,
+				Name:           agent.Name,
+				Description:    agent.SubAgentDescription,
+				Instructions:   agent.SubAgentInstructions,
+				Model:          agent.SubAgentModel,
+				Skills:         json.RawMessage(agent.SubAgentSkills),
+				GlobalSkills:   globalSkills,
+				ClaudeMD:       agent.InstructionsMD,
+				Background:     agent.SubAgentBackground,
+				Isolation:      agent.SubAgentIsolation,
+				PermissionMode: agent.SubAgentPermissionMode,
 			}
 			content := runtime.GenerateSubAgentContent(subInfo)
 
@@ -190,7 +253,7 @@ func (s *Server) CreateAgent(c *fiber.Ctx) error {
 		}
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(agent)
+	return agent, nil
 }
 
 // UpdateAgent updates an agent's configuration.
@@ -201,17 +264,17 @@ func (s *Server) UpdateAgent(c *fiber.Ctx) error {
 	// Verify team belongs to org.
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 
 	var req UpdateAgentRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -247,13 +310,53 @@ func (s *Server) UpdateAgent(c *fiber.Ctx) error {
 		updates["skills"] = models.JSON(raw)
 	}
 	if req.Permissions != nil {
-		raw, _ := json.Marshal(req.Permissions)
-		updates["permissions"] = models.JSON(raw)
+		perms, err := marshalPermissions(req.Permissions)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["permissions"] = perms
 	}
 	if req.Resources != nil {
+		policy, err := s.loadResourcePolicy(c)
+		if err != nil {
+			return err
+		}
+		if err := policy.CheckOverride(req.Resources); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
 		raw, _ := json.Marshal(req.Resources)
 		updates["resources"] = models.JSON(raw)
 	}
+	if req.RunAsUID != nil || req.RunAsGID != nil {
+		uid, gid := agent.RunAsUID, agent.RunAsGID
+		if req.RunAsUID != nil {
+			uid = req.RunAsUID
+			if *uid == -1 {
+				uid = nil
+			}
+			updates["run_as_uid"] = uid
+		}
+		if req.RunAsGID != nil {
+			gid = req.RunAsGID
+			if *gid == -1 {
+				gid = nil
+			}
+			updates["run_as_gid"] = gid
+		}
+		if err := validateRunAs(uid, gid); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+	}
+	if req.SkipWorkspaceChown != nil {
+		updates["skip_workspace_chown"] = *req.SkipWorkspaceChown
+	}
+	if req.ExtraClaudeArgs != nil {
+		extraClaudeArgs, err := marshalExtraClaudeArgs(*req.ExtraClaudeArgs)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["extra_claude_args"] = extraClaudeArgs
+	}
 	if req.SubAgentDescription != nil {
 		if len(*req.SubAgentDescription) > maxDescriptionSize {
 			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("sub_agent_description exceeds maximum size of %d bytes", maxDescriptionSize))
@@ -293,6 +396,29 @@ func (s *Server) UpdateAgent(c *fiber.Ctx) error {
 		raw, _ := json.Marshal(req.SubAgentSkills)
 		updates["sub_agent_skills"] = models.JSON(raw)
 	}
+	if req.SubAgentBackground != nil {
+		updates["sub_agent_background"] = *req.SubAgentBackground
+	}
+	if req.SubAgentIsolation != nil {
+		if err := validateSubAgentIsolation(*req.SubAgentIsolation); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		isolation := *req.SubAgentIsolation
+		if isolation == "" {
+			isolation = models.SubAgentIsolationWorktree
+		}
+		updates["sub_agent_isolation"] = isolation
+	}
+	if req.SubAgentPermissionMode != nil {
+		if err := validateSubAgentPermissionMode(*req.SubAgentPermissionMode); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		permissionMode := *req.SubAgentPermissionMode
+		if permissionMode == "" {
+			permissionMode = models.DefaultSubAgentPermissionMode
+		}
+		updates["sub_agent_permission_mode"] = permissionMode
+	}
 
 	if len(updates) > 0 {
 		if err := s.db.Model(&agent).Updates(updates).Error; err != nil {
@@ -336,28 +462,28 @@ func (s *Server) InstallAgentSkill(c *fiber.Ctx) error {
 	// Find team and verify it's running.
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 	if team.Status != models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+		return newAPIError(CodeTeamNotRunning, "team is not running")
 	}
 
 	// Find the target agent.
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 
 	// Find the leader agent (the one with a running container) to exec into.
 	var leader models.Agent
-	if err := s.db.Where("team_id = ? AND role = ? AND container_status = ?",
-		teamID, models.AgentRoleLeader, models.ContainerStatusRunning).First(&leader).Error; err != nil {
-		return fiber.NewError(fiber.StatusConflict, "no running leader agent found for this team")
+	if err := s.db.Where("team_id = ? AND role = ? AND container_status IN ?",
+		teamID, models.AgentRoleLeader, models.ContainerUpStatuses).First(&leader).Error; err != nil {
+		return newAPIError(CodeNoLeader, "no running leader agent found for this team")
 	}
 
 	var req InstallSkillRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if err := validateSingleSkillConfig(req.RepoURL, req.SkillName); err != nil {
@@ -440,13 +566,16 @@ func (s *Server) InstallAgentSkill(c *fiber.Ctx) error {
 		}
 
 		subInfo := runtime.SubAgentInfo{
-			Name:         agent.Name,
-			Description:  agent.SubAgentDescription,
-			Instructions: agent.SubAgentInstructions,
-			Model:        agent.SubAgentModel,
-			Skills:       json.RawMessage(updatedSkillsJSON),
-			GlobalSkills: workerLeaderSkills,
-			ClaudeMD:     agent.InstructionsMD,
+			Name:           agent.Name,
+			Description:    agent.SubAgentDescription,
+			Instructions:   agent.SubAgentInstructions,
+			Model:          agent.SubAgentModel,
+			Skills:         json.RawMessage(updatedSkillsJSON),
+			GlobalSkills:   workerLeaderSkills,
+			ClaudeMD:       agent.InstructionsMD,
+			Background:     agent.SubAgentBackground,
+			Isolation:      agent.SubAgentIsolation,
+			PermissionMode: agent.SubAgentPermissionMode,
 		}
 		content := runtime.GenerateSubAgentContent(subInfo)
 
@@ -476,13 +605,16 @@ func (s *Server) InstallAgentSkill(c *fiber.Ctx) error {
 
 		for _, w := range workers {
 			subInfo := runtime.SubAgentInfo{
-				Name:         w.Name,
-				Description:  w.SubAgentDescription,
-				Instructions: w.SubAgentInstructions,
-				Model:        w.SubAgentModel,
-				Skills:       json.RawMessage(w.SubAgentSkills),
-				GlobalSkills: globalSkills,
-				ClaudeMD:     w.InstructionsMD,
+				Name:           w.Name,
+				Description:    w.SubAgentDescription,
+				Instructions:   w.SubAgentInstructions,
+				Model:          w.SubAgentModel,
+				Skills:         json.RawMessage(w.SubAgentSkills),
+				GlobalSkills:   globalSkills,
+				ClaudeMD:       w.InstructionsMD,
+				Background:     w.SubAgentBackground,
+				Isolation:      w.SubAgentIsolation,
+				PermissionMode: w.SubAgentPermissionMode,
 			}
 			content := runtime.GenerateSubAgentContent(subInfo)
 			encoded := base64.StdEncoding.EncodeToString([]byte(content))
@@ -517,15 +649,15 @@ func (s *Server) GetInstructions(c *fiber.Ctx) error {
 
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 	if team.Status != models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+		return newAPIError(CodeTeamNotRunning, "team is not running")
 	}
 
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 
 	containerID, err := s.resolveAgentContainerID(teamID, agent)
@@ -553,15 +685,15 @@ func (s *Server) UpdateInstructions(c *fiber.Ctx) error {
 
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 	if team.Status != models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+		return newAPIError(CodeTeamNotRunning, "team is not running")
 	}
 
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 
 	containerID, err := s.resolveAgentContainerID(teamID, agent)
@@ -571,7 +703,7 @@ func (s *Server) UpdateInstructions(c *fiber.Ctx) error {
 
 	var req UpdateInstructionsRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if strings.TrimSpace(req.Content) == "" {
@@ -602,12 +734,164 @@ func (s *Server) UpdateInstructions(c *fiber.Ctx) error {
 	})
 }
 
+// previewWarnSize is the instructions size above which the preview endpoint
+// warns that the generated file may crowd out the agent's context window.
+const previewWarnSize = 40 * 1024
+
+// PreviewInstructions returns the CLAUDE.md (leader) or sub-agent file
+// (worker) content that a deploy would generate for the agent, together with
+// lint warnings. Nothing is written to any container or workspace.
+func (s *Server) PreviewInstructions(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+	agentID := c.Params("agentId")
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", teamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	var agent *models.Agent
+	for i := range team.Agents {
+		if team.Agents[i].ID == agentID {
+			agent = &team.Agents[i]
+			break
+		}
+	}
+	if agent == nil {
+		return newAPIError(CodeAgentNotFound, "agent not found")
+	}
+
+	provider := team.Provider
+	if provider == "" {
+		provider = models.ProviderClaude
+	}
+
+	warnings := []string{}
+	content := agentInstructionsContent(team, provider, agent)
+	if agent.Role == models.AgentRoleLeader {
+		if agent.InstructionsMD != "" {
+			warnings = append(warnings, "custom instructions_md is set; the generated team roster and delegation protocol are not included")
+		}
+		if strings.TrimSpace(agent.Specialty) == "" {
+			warnings = append(warnings, "leader has no specialty; its instructions will lack a role description")
+		}
+		if len(team.Agents) < 2 {
+			warnings = append(warnings, "team has no workers; the leader has nobody to delegate to")
+		}
+	} else if strings.TrimSpace(agent.SubAgentDescription) == "" {
+		warnings = append(warnings, "sub_agent_description is empty; the leader cannot tell when to delegate to this agent")
+	}
+
+	if len(content) > maxInstructionsSize {
+		warnings = append(warnings, fmt.Sprintf("content is %d bytes and exceeds the maximum of %d bytes accepted by the instructions editor", len(content), maxInstructionsSize))
+	} else if len(content) > previewWarnSize {
+		warnings = append(warnings, fmt.Sprintf("content is %d bytes; large instructions consume context on every turn", len(content)))
+	}
+
+	_, relPath := agentInstructionsPath(*agent, provider)
+
+	return c.JSON(PreviewInstructionsResponse{
+		Content:  content,
+		Path:     relPath,
+		Warnings: warnings,
+	})
+}
+
+// RegenerateAgentFiles rebuilds the agent's CLAUDE.md (leader) or sub-agent
+// file (worker) from its current DB fields and applies it. Teams with a host
+// workspace get the file written there, where running agents see it through
+// the bind mount. Otherwise, if the team is running, the file is pushed to the
+// leader's sidecar as a config_update message, since worker files live in the
+// leader's workspace. A stopped team without a host workspace picks up the
+// content on its next deploy. The generated content is returned for review.
+func (s *Server) RegenerateAgentFiles(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+	agentID := c.Params("agentId")
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", teamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	var agent, leader *models.Agent
+	for i := range team.Agents {
+		if team.Agents[i].ID == agentID {
+			agent = &team.Agents[i]
+		}
+		if team.Agents[i].Role == models.AgentRoleLeader {
+			leader = &team.Agents[i]
+		}
+	}
+	if agent == nil {
+		return newAPIError(CodeAgentNotFound, "agent not found")
+	}
+
+	provider := team.Provider
+	if provider == "" {
+		provider = models.ProviderClaude
+	}
+	content := agentInstructionsContent(team, provider, agent)
+	_, relPath := agentInstructionsPath(*agent, provider)
+	resp := RegenerateFilesResponse{Content: content, Path: relPath}
+
+	switch {
+	case team.WorkspacePath != "" && team.ConfigDirMode != models.ConfigDirModeSeparate:
+		configRoot := team.WorkspacePath
+		if team.ConfigDirMode == models.ConfigDirModeShared {
+			worktree, err := runtime.PrepareSharedWorkspace(c.Context(), team.WorkspacePath, team.Name)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to prepare shared workspace: "+err.Error())
+			}
+			configRoot = worktree
+		}
+		path := filepath.Join(configRoot, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to write "+relPath+": "+err.Error())
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to write "+relPath+": "+err.Error())
+		}
+		resp.WrittenToWorkspace = true
+
+	case team.Status == models.TeamStatusRunning:
+		if leader == nil || !models.ContainerIsUp(leader.ContainerStatus) {
+			return newAPIError(CodeNoLeader, "team leader is not running")
+		}
+		msg, err := protocol.NewMessage("api", "leader", protocol.TypeConfigUpdate, protocol.ConfigUpdatePayload{
+			Files: []protocol.ConfigFile{{Path: relPath, Content: content}},
+		})
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to build config update: "+err.Error())
+		}
+		if err := s.publishLeaderMessage(naming.Slug(team.Name), msg.MessageID, msg); err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, "failed to push config update: "+err.Error())
+		}
+		resp.PushedToAgent = true
+	}
+
+	slog.Info("agent files regenerated", "agent", agent.Name, "team", team.Name, "path", relPath,
+		"written", resp.WrittenToWorkspace, "pushed", resp.PushedToAgent)
+	return c.JSON(resp)
+}
+
+// agentInstructionsContent generates the instructions file of an agent from
+// its current DB fields, as deploy writes it: CLAUDE.md or AGENTS.MD for the
+// leader, a sub-agent markdown file for a worker.
+func agentInstructionsContent(team models.Team, provider string, agent *models.Agent) string {
+	if agent.Role == models.AgentRoleLeader {
+		return leaderInstructions(team, provider, agent, teamMemberInfos(team.Agents))
+	}
+	leaderSkills, leaderSkillConfigs := leaderGlobalSkills(team.Agents)
+	_, content := workerSubAgentFile(provider, agent, leaderSkills, leaderSkillConfigs)
+	return content
+}
+
 // resolveAgentContainerID returns the container ID to use for file operations.
 // Leaders use their own container; workers use the leader's container since
 // worker agent files live in the leader's shared workspace.
 func (s *Server) resolveAgentContainerID(teamID string, agent models.Agent) (string, error) {
 	if agent.Role == models.AgentRoleLeader {
-		if agent.ContainerStatus != models.ContainerStatusRunning {
+		if !models.ContainerIsUp(agent.ContainerStatus) {
 			return "", fiber.NewError(fiber.StatusConflict, "agent is not running")
 		}
 		return agent.ContainerID, nil
@@ -616,10 +900,10 @@ func (s *Server) resolveAgentContainerID(teamID string, agent models.Agent) (str
 	// Workers live inside the leader's container workspace.
 	var leader models.Agent
 	if err := s.db.Where("team_id = ? AND role = ?", teamID, models.AgentRoleLeader).First(&leader).Error; err != nil {
-		return "", fiber.NewError(fiber.StatusNotFound, "team leader not found")
+		return "", newAPIError(CodeLeaderNotFound, "team leader not found")
 	}
-	if leader.ContainerStatus != models.ContainerStatusRunning {
-		return "", fiber.NewError(fiber.StatusConflict, "team leader is not running")
+	if !models.ContainerIsUp(leader.ContainerStatus) {
+		return "", newAPIError(CodeNoLeader, "team leader is not running")
 	}
 	return leader.ContainerID, nil
 }
@@ -660,15 +944,15 @@ func (s *Server) DeleteAgent(c *fiber.Ctx) error {
 	// Verify team belongs to org.
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var agent models.Agent
 	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "agent not found")
+		return newAPIError(CodeAgentNotFound, "agent not found")
 	}
 
-	if agent.ContainerStatus == models.ContainerStatusRunning {
+	if models.ContainerIsUp(agent.ContainerStatus) {
 		return fiber.NewError(fiber.StatusConflict, "stop the agent before deleting")
 	}
 
@@ -678,3 +962,183 @@ func (s *Server) DeleteAgent(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// RestartAgent stops and recreates the leader container of a running team,
+// optionally pulling a newer image first. The team's NATS and workspace
+// volume are left in place. The restart runs in the background and is
+// recorded as deployment events in the team's activity.
+func (s *Server) RestartAgent(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+	agentID := c.Params("agentId")
+
+	var req RestartAgentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	team, op, err := s.lockTeam(c, teamOpRestart)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(team.ID, op)
+
+	var agent models.Agent
+	if err := s.db.Where("id = ? AND team_id = ?", agentID, teamID).First(&agent).Error; err != nil {
+		return newAPIError(CodeAgentNotFound, "agent not found")
+	}
+	if agent.Role != models.AgentRoleLeader {
+		return fiber.NewError(fiber.StatusBadRequest, "only the leader agent runs in a container")
+	}
+	if team.Status != models.TeamStatusRunning && team.Status != models.TeamStatusError {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	// Members' leader restarts of a team that requires approval wait for an
+	// admin, like its deploys.
+	if team.RequiresDeployApproval && !IsAdmin(c) {
+		return s.requestApproval(c, team, models.ApprovalActionRestart,
+			restartApprovalParams{AgentID: agent.ID, PullImage: req.PullImage})
+	}
+
+	if _, err := s.startLeaderRestart(&team, agent, req.PullImage, GetUserID(c)); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(agent)
+}
+
+// startLeaderRestart marks team restarting and queues the restart of its
+// leader. The caller must hold the team.
+func (s *Server) startLeaderRestart(team *models.Team, leader models.Agent, pullImage bool, requestedBy string) (*models.Job, error) {
+	s.markLeaderRestarting(team)
+
+	// Restart in a background job, which claims the team once the caller
+	// releases it.
+	payload := leaderRestartPayload{AgentID: leader.ID, PullImage: pullImage, RequestedBy: requestedBy}
+	job, err := s.enqueueTeamJob(jobLeaderRestart, *team, payload)
+	if err != nil {
+		slog.Error("failed to queue leader restart", "team", team.Name, "error", err)
+		s.db.Model(team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": "Failed to queue leader restart",
+		})
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to queue leader restart")
+	}
+	return job, nil
+}
+
+// markLeaderRestarting sets team to deploying ahead of a leader restart.
+// The new container has no prior session, so a new conversation starts, as
+// on deploy.
+func (s *Server) markLeaderRestarting(team *models.Team) {
+	conversationID := uuid.New().String()
+	s.db.Model(team).Updates(map[string]interface{}{
+		"status":          models.TeamStatusDeploying,
+		"status_message":  "Restarting leader...",
+		"conversation_id": conversationID,
+		"chat_sequence":   0,
+	})
+	team.Status = models.TeamStatusDeploying
+	team.StatusMessage = "Restarting leader..."
+	team.ConversationID = conversationID
+}
+
+// restartLeaderAsync runs restartLeader for a leader restart job.
+func (s *Server) restartLeaderAsync(ctx context.Context, team models.Team, leader models.Agent, pullImage bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	return s.restartLeader(ctx, team, leader, protocol.DeploymentActionRestart, pullImage)
+}
+
+// restartLeader replaces the leader's container and updates the team and
+// agent state to match. action labels the recorded deployment events. The
+// returned error carries the status message the team was left with.
+func (s *Server) restartLeader(ctx context.Context, team models.Team, leader models.Agent, action string, pullImage bool) error {
+	defer s.publishDeployOutcome(team.ID)
+	deployment := s.startDeployment(team, deploymentTriggerFrom(ctx, action))
+	defer s.finishDeployment(deployment)
+
+	event := protocol.DeploymentEventPayload{
+		AgentName: leader.Name,
+		Action:    action,
+		Status:    protocol.DeploymentStatusStarted,
+		Image:     team.AgentImage,
+		PullImage: pullImage,
+	}
+	s.recordDeploymentEvent(team, event)
+
+	fail := func(msg string, err error) error {
+		slog.Error("failed to restart leader", "team", team.Name, "agent", leader.Name, "error", err)
+		s.db.Model(&models.Agent{}).Where("id = ?", leader.ID).
+			Update("container_status", models.ContainerStatusError)
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": msg,
+		})
+		event.Status = protocol.DeploymentStatusFailed
+		event.Error = msg
+		s.recordDeploymentEvent(team, event)
+		return errors.New(msg)
+	}
+
+	_, agentCfg, err := s.leaderAgentConfig(ctx, &team, s.LoadSettingsEnv(team.OrgID), false)
+	if err != nil {
+		return fail(err.Error(), err)
+	}
+	agentCfg.PullImage = pullImage
+
+	if leader.ContainerID != "" {
+		if err := s.runtime.StopAgent(ctx, leader.ContainerID); err != nil {
+			slog.Warn("failed to stop leader container, removing it", "team", team.Name, "container", leader.ContainerID, "error", err)
+		}
+		if err := s.runtime.RemoveAgent(ctx, leader.ContainerID); err != nil {
+			slog.Warn("failed to remove leader container", "team", team.Name, "container", leader.ContainerID, "error", err)
+		}
+	}
+
+	instance, err := s.runtime.DeployAgent(ctx, agentCfg)
+	if err != nil {
+		return fail("Failed to restart leader: "+err.Error(), err)
+	}
+
+	s.db.Model(&models.Agent{}).Where("id = ?", leader.ID).Updates(map[string]interface{}{
+		"container_id":     instance.ID,
+		"container_status": models.ContainerStatusRunning,
+	})
+	s.recordDeployedContainer(ctx, deployment, leader.Name, agentCfg.Image, instance.ID)
+	s.db.Model(&team).Updates(map[string]interface{}{
+		"status":         models.TeamStatusRunning,
+		"status_message": "",
+	})
+	slog.Info("leader restarted", "team", team.Name, "agent", leader.Name, "container", instance.ID)
+
+	event.Status = protocol.DeploymentStatusSucceeded
+	event.ContainerID = instance.ID
+	s.recordDeploymentEvent(team, event)
+
+	// Restart the relay so queued chats wait for the new leader's
+	// agent_ready.
+	s.startTeamRelay(team.ID, team.Name)
+	return nil
+}
+
+// recordDeploymentEvent stores a deployment event in the team's activity.
+func (s *Server) recordDeploymentEvent(team models.Team, event protocol.DeploymentEventPayload) {
+	payload, _ := json.Marshal(event)
+	log := models.TaskLog{
+		ID:             uuid.New().String(),
+		TeamID:         team.ID,
+		ConversationID: team.ConversationID,
+		FromAgent:      "system",
+		ToAgent:        event.AgentName,
+		MessageType:    string(protocol.TypeDeploymentEvent),
+		Payload:        models.JSON(payload),
+	}
+	if err := s.taskLogs.Create(&log); err != nil {
+		slog.Error("failed to record deployment event", "team", team.Name, "error", err)
+	}
+}
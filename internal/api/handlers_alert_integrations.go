@@ -0,0 +1,284 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// validAlertProviders is the set of supported alerting providers.
+var validAlertProviders = map[string]bool{
+	models.AlertProviderPagerDuty: true,
+	models.AlertProviderOpsgenie:  true,
+}
+
+// validAlertSeverities is the set of allowed alert severities.
+var validAlertSeverities = map[string]bool{
+	models.AlertSeverityCritical: true,
+	models.AlertSeverityError:    true,
+	models.AlertSeverityWarning:  true,
+	models.AlertSeverityInfo:     true,
+}
+
+// ListAlertIntegrations returns all alert integrations.
+func (s *Server) ListAlertIntegrations(c *fiber.Ctx) error {
+	var integrations []models.AlertIntegration
+	if err := s.db.Scopes(OrgScope(c)).Find(&integrations).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list alert integrations")
+	}
+	return c.JSON(integrations)
+}
+
+// GetAlertIntegration returns a single alert integration by ID.
+func (s *Server) GetAlertIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.AlertIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "alert integration not found")
+	}
+	return c.JSON(integration)
+}
+
+// CreateAlertIntegration creates a PagerDuty or Opsgenie alert integration.
+func (s *Server) CreateAlertIntegration(c *fiber.Ctx) error {
+	var req CreateAlertIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	if req.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+	}
+	if len(req.Name) > 255 {
+		return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+	}
+	if !validAlertProviders[req.Provider] {
+		return fiber.NewError(fiber.StatusBadRequest, "provider must be one of pagerduty, opsgenie")
+	}
+	if err := validateIssueBaseURL(req.BaseURL); err != nil {
+		return err
+	}
+	if err := validateAlertCredentials(req.Provider, req.Credentials); err != nil {
+		return err
+	}
+
+	severity := models.AlertSeverityCritical
+	if req.Severity != "" {
+		if !validAlertSeverities[req.Severity] {
+			return fiber.NewError(fiber.StatusBadRequest, "severity must be one of critical, error, warning, info")
+		}
+		severity = req.Severity
+	}
+	alertOnError := true
+	if req.AlertOnError != nil {
+		alertOnError = *req.AlertOnError
+	}
+	deployFailureThreshold := 3
+	if req.DeployFailureThreshold != nil {
+		deployFailureThreshold = *req.DeployFailureThreshold
+	}
+	heartbeatTimeout := 300
+	if req.HeartbeatTimeoutSeconds != nil {
+		heartbeatTimeout = *req.HeartbeatTimeoutSeconds
+	}
+	if err := validateAlertThresholds(deployFailureThreshold, heartbeatTimeout); err != nil {
+		return err
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	if req.TeamID != "" {
+		var team models.Team
+		if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", req.TeamID).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "team_id references a non-existent team")
+		}
+	}
+
+	credsJSON, err := encryptAuthConfig(req.Credentials)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to process credentials")
+	}
+
+	integration := models.AlertIntegration{
+		ID:                      uuid.New().String(),
+		OrgID:                   GetOrgID(c),
+		Name:                    req.Name,
+		TeamID:                  req.TeamID,
+		Provider:                req.Provider,
+		BaseURL:                 req.BaseURL,
+		Credentials:             models.JSON(credsJSON),
+		Severity:                severity,
+		AlertOnError:            alertOnError,
+		DeployFailureThreshold:  deployFailureThreshold,
+		HeartbeatTimeoutSeconds: heartbeatTimeout,
+		Enabled:                 enabled,
+	}
+
+	if err := s.db.Create(&integration).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create alert integration")
+	}
+	// GORM skips zero values that have a default, so persist them explicitly.
+	s.db.Model(&integration).Updates(map[string]interface{}{
+		"alert_on_error":            alertOnError,
+		"deploy_failure_threshold":  deployFailureThreshold,
+		"heartbeat_timeout_seconds": heartbeatTimeout,
+		"enabled":                   enabled,
+	})
+
+	s.db.First(&integration, "id = ?", integration.ID)
+	return c.Status(fiber.StatusCreated).JSON(integration)
+}
+
+// UpdateAlertIntegration updates an alert integration's fields.
+func (s *Server) UpdateAlertIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.AlertIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "alert integration not found")
+	}
+
+	var req UpdateAlertIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "name cannot be empty")
+		}
+		if len(*req.Name) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.BaseURL != nil {
+		if err := validateIssueBaseURL(*req.BaseURL); err != nil {
+			return err
+		}
+		updates["base_url"] = *req.BaseURL
+	}
+	if req.Credentials != nil {
+		if err := validateAlertCredentials(integration.Provider, *req.Credentials); err != nil {
+			return err
+		}
+		credsJSON, err := encryptAuthConfig(*req.Credentials)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to process credentials")
+		}
+		updates["credentials"] = models.JSON(credsJSON)
+	}
+	if req.Severity != nil {
+		if !validAlertSeverities[*req.Severity] {
+			return fiber.NewError(fiber.StatusBadRequest, "severity must be one of critical, error, warning, info")
+		}
+		updates["severity"] = *req.Severity
+	}
+	if req.AlertOnError != nil {
+		updates["alert_on_error"] = *req.AlertOnError
+	}
+
+	deployFailureThreshold := integration.DeployFailureThreshold
+	if req.DeployFailureThreshold != nil {
+		deployFailureThreshold = *req.DeployFailureThreshold
+		updates["deploy_failure_threshold"] = deployFailureThreshold
+	}
+	heartbeatTimeout := integration.HeartbeatTimeoutSeconds
+	if req.HeartbeatTimeoutSeconds != nil {
+		heartbeatTimeout = *req.HeartbeatTimeoutSeconds
+		updates["heartbeat_timeout_seconds"] = heartbeatTimeout
+	}
+	if err := validateAlertThresholds(deployFailureThreshold, heartbeatTimeout); err != nil {
+		return err
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&integration).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update alert integration")
+		}
+	}
+
+	s.db.First(&integration, "id = ?", id)
+	return c.JSON(integration)
+}
+
+// DeleteAlertIntegration resolves the integration's open incidents and removes it.
+func (s *Server) DeleteAlertIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.AlertIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "alert integration not found")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.alertMonitor.ResolveAll(ctx, integration); err != nil {
+		slog.Warn("failed to resolve open alerts before deleting integration",
+			"integration_id", id, "error", err)
+	}
+
+	s.db.Where("integration_id = ?", id).Delete(&models.TeamAlert{})
+	if err := s.db.Delete(&integration).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete alert integration")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ListTeamAlerts returns the incidents opened by an alert integration, newest first.
+func (s *Server) ListTeamAlerts(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.AlertIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "alert integration not found")
+	}
+
+	query := s.db.Where("integration_id = ?", id)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var alerts []models.TeamAlert
+	if err := query.Order("opened_at DESC").Limit(100).Find(&alerts).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list alerts")
+	}
+	return c.JSON(alerts)
+}
+
+// validateAlertCredentials checks that the provider's required credential keys are present.
+func validateAlertCredentials(provider string, creds map[string]string) error {
+	switch provider {
+	case models.AlertProviderPagerDuty:
+		if creds["routing_key"] == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "pagerduty credentials require 'routing_key'")
+		}
+	case models.AlertProviderOpsgenie:
+		if creds["api_key"] == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "opsgenie credentials require 'api_key'")
+		}
+	}
+	return nil
+}
+
+// validateAlertThresholds checks the alert trigger thresholds. Zero disables
+// the corresponding check.
+func validateAlertThresholds(deployFailureThreshold, heartbeatTimeoutSeconds int) error {
+	if deployFailureThreshold < 0 || deployFailureThreshold > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "deploy_failure_threshold must be between 0 and 100")
+	}
+	if heartbeatTimeoutSeconds != 0 && (heartbeatTimeoutSeconds < 60 || heartbeatTimeoutSeconds > 86400) {
+		return fiber.NewError(fiber.StatusBadRequest, "heartbeat_timeout_seconds must be 0 or between 60 and 86400")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestAlertIntegrationCRUD(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	zero := 0
+	rec := doRequest(srv, "POST", "/api/alert-integrations", CreateAlertIntegrationRequest{
+		Name:                    "pagerduty",
+		Provider:                models.AlertProviderPagerDuty,
+		Credentials:             map[string]string{"routing_key": "rk"},
+		HeartbeatTimeoutSeconds: &zero,
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var integration models.AlertIntegration
+	parseJSON(t, rec, &integration)
+	if integration.Severity != models.AlertSeverityCritical || integration.DeployFailureThreshold != 3 {
+		t.Errorf("defaults: got severity %q threshold %d", integration.Severity, integration.DeployFailureThreshold)
+	}
+	if integration.HeartbeatTimeoutSeconds != 0 {
+		t.Errorf("heartbeat_timeout_seconds: got %d, want 0 (disabled)", integration.HeartbeatTimeoutSeconds)
+	}
+
+	severity := models.AlertSeverityWarning
+	rec = doRequest(srv, "PUT", "/api/alert-integrations/"+integration.ID, UpdateAlertIntegrationRequest{Severity: &severity})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &integration)
+	if integration.Severity != severity {
+		t.Errorf("severity: got %q, want %q", integration.Severity, severity)
+	}
+
+	rec = doRequest(srv, "GET", "/api/alert-integrations/"+integration.ID+"/alerts", nil)
+	if rec.Code != 200 {
+		t.Fatalf("list alerts: got %d, want 200", rec.Code)
+	}
+
+	rec = doRequest(srv, "DELETE", "/api/alert-integrations/"+integration.ID, nil)
+	if rec.Code != 204 {
+		t.Fatalf("delete: got %d, want 204", rec.Code)
+	}
+}
+
+func TestCreateAlertIntegration_Validation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	tooShort := 10
+	pdCreds := map[string]string{"routing_key": "rk"}
+	tests := []struct {
+		name string
+		body CreateAlertIntegrationRequest
+	}{
+		{"missing name", CreateAlertIntegrationRequest{Provider: "pagerduty", Credentials: pdCreds}},
+		{"unknown provider", CreateAlertIntegrationRequest{Name: "x", Provider: "slack"}},
+		{"missing routing key", CreateAlertIntegrationRequest{Name: "x", Provider: "pagerduty"}},
+		{"missing opsgenie key", CreateAlertIntegrationRequest{Name: "x", Provider: "opsgenie"}},
+		{"invalid severity", CreateAlertIntegrationRequest{Name: "x", Provider: "pagerduty", Credentials: pdCreds, Severity: "sev1"}},
+		{"heartbeat too short", CreateAlertIntegrationRequest{Name: "x", Provider: "pagerduty", Credentials: pdCreds, HeartbeatTimeoutSeconds: &tooShort}},
+		{"unknown team", CreateAlertIntegrationRequest{Name: "x", Provider: "pagerduty", Credentials: pdCreds, TeamID: "missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(srv, "POST", "/api/alert-integrations", tt.body)
+			if rec.Code != 400 {
+				t.Errorf("got %d, want 400\nbody: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRecordDeployOutcome(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "deploy-outcome"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	srv.db.Model(&team).Update("status", models.TeamStatusError)
+	srv.publishDeployOutcome(team.ID)
+	srv.publishDeployOutcome(team.ID)
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.DeployFailures != 2 {
+		t.Errorf("after failures: got %d, want 2", team.DeployFailures)
+	}
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	srv.publishDeployOutcome(team.ID)
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.DeployFailures != 0 {
+		t.Errorf("after success: got %d, want 0", team.DeployFailures)
+	}
+}
@@ -52,7 +52,7 @@ type LoginRequest struct {
 func (s *Server) Login(c *fiber.Ctx) error {
 	var req LoginRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
@@ -118,7 +118,7 @@ func (s *Server) Register(c *fiber.Ctx) error {
 
 	var req RegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
@@ -175,7 +175,7 @@ type InviteRegisterRequest struct {
 func (s *Server) RegisterWithInvite(c *fiber.Ctx) error {
 	var req InviteRegisterRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
@@ -234,7 +234,7 @@ type RefreshRequest struct {
 func (s *Server) RefreshToken(c *fiber.Ctx) error {
 	var req RefreshRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.RefreshToken == "" {
@@ -297,7 +297,7 @@ func (s *Server) UpdateMe(c *fiber.Ctx) error {
 
 	var req UpdateMeRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.Name == "" {
@@ -338,7 +338,7 @@ func (s *Server) ChangePassword(c *fiber.Ctx) error {
 
 	var req ChangePasswordRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.CurrentPassword == "" || req.NewPassword == "" {
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -10,14 +11,19 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"gorm.io/gorm"
 
+	"github.com/helmcode/agent-crew/internal/guardrails"
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
@@ -37,17 +43,27 @@ var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
 func (s *Server) SendChat(c *fiber.Ctx) error {
 	teamID := c.Params("id")
 
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	team, err := s.findTeamCached(GetOrgID(c), teamID)
+	if err != nil {
+		return err
 	}
 
-	if team.Status != models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+	deploying := team.Status == models.TeamStatusDeploying
+	if team.Status != models.TeamStatusRunning && !deploying {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
 	}
 
+	// Messages are queued while the team deploys, and also while earlier
+	// queued messages are still pending so that delivery order is preserved.
+	queueing := deploying || s.hasQueuedChats(teamID)
+
 	var message string
 	var fileRefs []protocol.FileRef
+	var queueRequested bool
+	var overrides protocol.RunOverrides
 
 	contentType := string(c.Request().Header.ContentType())
 	mediaType, _, _ := mime.ParseMediaType(contentType)
@@ -55,6 +71,28 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 	if mediaType == "multipart/form-data" {
 		// Parse multipart form.
 		message = c.FormValue("message")
+		queueRequested = c.FormValue("queue") == "true"
+		overrides.Model = c.FormValue("model")
+		if raw := c.FormValue("max_thinking_tokens"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "max_thinking_tokens must be an integer")
+			}
+			overrides.MaxThinkingTokens = n
+		}
+		if templateID := c.FormValue("template_id"); templateID != "" {
+			var vars map[string]string
+			if raw := c.FormValue("variables"); raw != "" {
+				if err := json.Unmarshal([]byte(raw), &vars); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "variables must be a JSON object of strings")
+				}
+			}
+			rendered, err := s.renderPromptTemplateByID(c, "template_id", templateID, vars)
+			if err != nil {
+				return err
+			}
+			message = rendered
+		}
 		if message == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "message is required")
 		}
@@ -70,12 +108,16 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("maximum %d files allowed", maxFileCount))
 		}
 
+		if len(files) > 0 && queueing {
+			return fiber.NewError(fiber.StatusConflict, "file uploads cannot be queued until the team leader is ready")
+		}
+
 		if len(files) > 0 {
 			// Find the leader container to write files into.
 			var leader models.Agent
-			if err := s.db.Where("team_id = ? AND role = ? AND container_status = ?",
-				teamID, models.AgentRoleLeader, models.ContainerStatusRunning).First(&leader).Error; err != nil {
-				return fiber.NewError(fiber.StatusConflict, "no running leader agent found for file upload")
+			if err := s.db.Where("team_id = ? AND role = ? AND container_status IN ?",
+				teamID, models.AgentRoleLeader, models.ContainerUpStatuses).First(&leader).Error; err != nil {
+				return newAPIError(CodeNoLeader, "no running leader agent found for file upload")
 			}
 
 			timestamp := time.Now().Unix()
@@ -116,11 +158,12 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 				}
 
 				// Fix ownership: CopyToContainer creates files as root, but the
-				// agent process runs as the workspace owner (non-root via gosu).
-				// Detect the workspace owner UID:GID and chown the uploads dir
-				// and file to match so the agent can read/edit/delete them.
+				// agent process runs as the workspace owner (non-root via gosu),
+				// or as the agent's run_as user when WORKSPACE_UID is set. Chown
+				// the uploads dir and file to match so the agent can
+				// read/edit/delete them.
 				fixPermsCmd := []string{"sh", "-c", fmt.Sprintf(
-					"owner=$(stat -c '%%u:%%g' /workspace) && chown \"$owner\" /workspace/uploads && chown \"$owner\" '%s'",
+					"if [ -n \"$WORKSPACE_UID\" ]; then owner=\"$WORKSPACE_UID:${WORKSPACE_GID:-$WORKSPACE_UID}\"; else owner=$(stat -c '%%u:%%g' /workspace); fi && chown \"$owner\" /workspace/uploads && chown \"$owner\" '%s'",
 					containerPath,
 				)}
 				if _, err := s.runtime.ExecInContainer(c.Context(), leader.ContainerID, fixPermsCmd); err != nil {
@@ -140,12 +183,51 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 		// Fallback: JSON body (backward compatible).
 		var req ChatRequest
 		if err := c.BodyParser(&req); err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+			return newAPIError(CodeInvalidBody, "invalid request body")
 		}
-		if req.Message == "" {
+		message = req.Message
+		queueRequested = req.Queue
+		overrides = protocol.RunOverrides{Model: req.Model, MaxThinkingTokens: req.MaxThinkingTokens}
+		if req.TemplateID != "" {
+			rendered, err := s.renderPromptTemplateByID(c, "template_id", req.TemplateID, req.Variables)
+			if err != nil {
+				return err
+			}
+			message = rendered
+		}
+		if message == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "message is required")
 		}
-		message = req.Message
+	}
+
+	runOverrides, err := chatRunOverrides(team, overrides)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	block, err := s.checkContentPolicies(c.Context(), team.OrgID, guardrails.DirectionInput, message)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check content policies")
+	}
+	if block != nil {
+		return s.blockedChat(c, team, block)
+	}
+
+	if deploying && !queueRequested {
+		return newAPIError(CodeTeamDeploying, "team is deploying; set queue to true to deliver the message once the leader is ready")
+	}
+
+	// Over the rate limit, the message waits its turn in the queue instead.
+	rateLimited := !queueing && !s.governor.TryAcquire()
+	if rateLimited {
+		queueing = true
+	}
+
+	// The sequence number travels with the message so the sidecar can drop
+	// duplicate deliveries.
+	sequence, err := s.nextChatSequence(teamID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to assign message sequence")
 	}
 
 	// Log to task log for persistence and Activity panel.
@@ -153,34 +235,73 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 	if len(fileRefs) > 0 {
 		logPayload["files"] = fileRefs
 	}
+	if runOverrides != nil {
+		// Kept with the message so that queued delivery applies them too.
+		logPayload["overrides"] = runOverrides
+	}
 	content, _ := json.Marshal(logPayload)
 	taskLog := models.TaskLog{
-		ID:          uuid.New().String(),
-		TeamID:      teamID,
-		FromAgent:   "user",
-		ToAgent:     "leader",
-		MessageType: "user_message",
-		Payload:     models.JSON(content),
+		ID:             uuid.New().String(),
+		TeamID:         teamID,
+		ConversationID: team.ConversationID,
+		FromAgent:      "user",
+		ToAgent:        "leader",
+		MessageType:    "user_message",
+		Payload:        models.JSON(content),
+		Sequence:       sequence,
+	}
+	if queueing {
+		taskLog.DeliveryStatus = models.ChatDeliveryQueued
+	}
+	if err := s.taskLogs.Create(&taskLog); err != nil {
+		slog.Error("chat: failed to save task log", "team", team.Name, "error", err)
+	}
+
+	if queueing {
+		// Read before the flush below joins the queue.
+		position := s.governor.Len() + 1
+		// The message is persisted first so that a flush triggered by the
+		// leader's ready signal in the meantime still picks it up.
+		if s.isLeaderReady(teamID) {
+			go s.flushQueuedChats(teamID, team.Name)
+		}
+		if rateLimited {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"status":         models.ChatDeliveryQueued,
+				"message":        "Message queued by the rate limiter",
+				"id":             taskLog.ID,
+				"queue_position": position,
+			})
+		}
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"status":  models.ChatDeliveryQueued,
+			"message": "Message queued until the team leader is ready",
+			"id":      taskLog.ID,
+		})
 	}
-	s.db.Create(&taskLog)
 
 	// Publish to NATS leader channel so the agent actually receives the message.
-	sanitizedName := SanitizeName(team.Name)
+	sanitizedName := naming.Slug(team.Name)
 	payload := protocol.UserMessagePayload{
-		Content: message,
-		Files:   fileRefs,
+		Content:        message,
+		Files:          fileRefs,
+		ConversationID: team.ConversationID,
+		Sequence:       sequence,
+		Overrides:      runOverrides,
 	}
-	if err := s.publishToTeamNATS(sanitizedName, payload); err != nil {
+	if err := s.publishToTeamNATS(sanitizedName, taskLog.ID, payload); err != nil {
 		slog.Error("failed to publish chat to NATS", "team", team.Name, "error", err)
 		return c.JSON(fiber.Map{
 			"status":  "queued",
 			"message": "Message logged but NATS delivery failed: " + err.Error(),
+			"id":      taskLog.ID,
 		})
 	}
 
 	response := fiber.Map{
 		"status":  "sent",
 		"message": "Message sent to team leader",
+		"id":      taskLog.ID,
 	}
 	if len(fileRefs) > 0 {
 		response["files"] = fileRefs
@@ -188,18 +309,159 @@ func (s *Server) SendChat(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// chatRunOverrides validates the run overrides of a chat message and maps
+// short model names to full model IDs, as for the leader's own model. It
+// returns nil when nothing is overridden.
+func chatRunOverrides(team models.Team, overrides protocol.RunOverrides) (*protocol.RunOverrides, error) {
+	if overrides.IsZero() {
+		return nil, nil
+	}
+	if team.Provider == models.ProviderOpenCode {
+		return nil, fmt.Errorf("model and max_thinking_tokens can only be set on Claude teams")
+	}
+	if err := overrides.Validate(); err != nil {
+		return nil, err
+	}
+	if fullModel := claudeModelID(overrides.Model); fullModel != "" {
+		overrides.Model = fullModel
+	}
+	return &overrides, nil
+}
+
+// nextChatSequence atomically increments and returns the team's chat
+// sequence number for the current conversation.
+func (s *Server) nextChatSequence(teamID string) (int64, error) {
+	var sequence int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Team{}).Where("id = ?", teamID).
+			Update("chat_sequence", gorm.Expr("chat_sequence + 1")).Error; err != nil {
+			return err
+		}
+		var team models.Team
+		if err := tx.Select("chat_sequence").First(&team, "id = ?", teamID).Error; err != nil {
+			return err
+		}
+		sequence = team.ChatSequence
+		return nil
+	})
+	return sequence, err
+}
+
 // publishToTeamNATS connects to the team's NATS, publishes a user_message to
 // the leader channel, and disconnects. The connection is short-lived on purpose
 // to avoid managing per-team NATS connections in the API server.
 // It retries up to 3 times to handle cases where the NATS container was just
 // recreated (e.g. after port binding fix).
-func (s *Server) publishToTeamNATS(teamName string, payload protocol.UserMessagePayload) error {
+//
+// The message is published through JetStream with msgID as its deduplication
+// ID, so retried publishes of the same chat message are stored only once.
+// Without a team stream it falls back to a core NATS publish.
+func (s *Server) publishToTeamNATS(teamName, msgID string, payload protocol.UserMessagePayload) error {
+	msg, err := protocol.NewMessage("user", "leader", protocol.TypeUserMessage, payload)
+	if err != nil {
+		return fmt.Errorf("building protocol message: %w", err)
+	}
+	// The sidecar reports its run queue by message ID, so use the TaskLog ID.
+	if msgID != "" {
+		msg.MessageID = msgID
+	}
+	return s.publishLeaderMessage(teamName, msgID, msg)
+}
+
+// publishLeaderMessage publishes msg to the team's leader channel over a
+// short-lived NATS connection, as described on publishToTeamNATS. msgID, if
+// set, deduplicates retried publishes.
+func (s *Server) publishLeaderMessage(teamName, msgID string, msg *protocol.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	nc, err := s.connectTeamNATS(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %w", err)
+	}
+
+	// Publish to the leader channel.
+	subject, err := protocol.TeamLeaderChannel(teamName)
+	if err != nil {
+		return fmt.Errorf("building leader channel: %w", err)
+	}
+
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return fmt.Errorf("creating jetstream context: %w", err)
+	}
+	var pubOpts []jetstream.PublishOpt
+	if msgID != "" {
+		pubOpts = append(pubOpts, jetstream.WithMsgID(msgID))
+	}
+	ack, err := js.Publish(ctx, subject, data, pubOpts...)
+	if errors.Is(err, jetstream.ErrNoStreamResponse) {
+		slog.Warn("no JetStream stream for team, publishing with core NATS", "team", teamName)
+		if err := nc.Publish(subject, data); err != nil {
+			return fmt.Errorf("publishing to %s: %w", subject, err)
+		}
+		if err := nc.Flush(); err != nil {
+			return fmt.Errorf("flushing NATS: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("publishing to %s: %w", subject, err)
+	}
+
+	slog.Info("message published to NATS", "team", teamName, "type", msg.Type, "subject", subject,
+		"stream_seq", ack.Sequence, "duplicate", ack.Duplicate)
+	return nil
+}
+
+// publishControlMessage sends msg, a system command, to the team leader's
+// sidecar on the team's control channel over core NATS, so that it is not
+// held behind the user messages queued in the leader's inbox. It is not
+// stored: a sidecar that is not connected misses it.
+func (s *Server) publishControlMessage(teamName string, msg *protocol.Message) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
+	nc, err := s.connectTeamNATS(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	defer nc.Close()
+	return publishControl(nc, teamName, msg)
+}
+
+// publishControl publishes msg on the team's control channel over nc and
+// flushes it.
+func publishControl(nc *nats.Conn, teamName string, msg *protocol.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %w", err)
+	}
+	subject, err := protocol.TeamControlChannel(teamName)
+	if err != nil {
+		return fmt.Errorf("building control channel: %w", err)
+	}
+	if err := nc.Publish(subject, data); err != nil {
+		return fmt.Errorf("publishing to %s: %w", subject, err)
+	}
+	if err := nc.Flush(); err != nil {
+		return fmt.Errorf("flushing NATS: %w", err)
+	}
+	return nil
+}
+
+// connectTeamNATS opens a connection to the team's NATS server. It retries
+// up to 3 times, since the NATS container may have just been recreated.
+func (s *Server) connectTeamNATS(ctx context.Context, teamName string) (*nats.Conn, error) {
 	natsURL, err := s.runtime.GetNATSConnectURL(ctx, teamName)
 	if err != nil {
-		return fmt.Errorf("resolving NATS URL: %w", err)
+		return nil, fmt.Errorf("resolving NATS URL: %w", err)
 	}
 
 	// Build NATS connection options.
@@ -234,43 +496,15 @@ func (s *Server) publishToTeamNATS(teamName string, payload protocol.UserMessage
 		if attempt < 3 {
 			select {
 			case <-ctx.Done():
-				return fmt.Errorf("context cancelled waiting for NATS: %w", ctx.Err())
+				return nil, fmt.Errorf("context cancelled waiting for NATS: %w", ctx.Err())
 			case <-time.After(time.Duration(attempt) * time.Second):
 			}
 		}
 	}
 	if err != nil {
-		return fmt.Errorf("connecting to NATS at %s (auth=%t): %w", natsURL, token != "", err)
-	}
-	defer nc.Close()
-
-	// Build the protocol message.
-	msg, err := protocol.NewMessage("user", "leader", protocol.TypeUserMessage, payload)
-	if err != nil {
-		return fmt.Errorf("building protocol message: %w", err)
-	}
-
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("marshaling message: %w", err)
+		return nil, fmt.Errorf("connecting to NATS at %s (auth=%t): %w", natsURL, token != "", err)
 	}
-
-	// Publish to the leader channel.
-	subject, err := protocol.TeamLeaderChannel(teamName)
-	if err != nil {
-		return fmt.Errorf("building leader channel: %w", err)
-	}
-
-	if err := nc.Publish(subject, data); err != nil {
-		return fmt.Errorf("publishing to %s: %w", subject, err)
-	}
-
-	if err := nc.Flush(); err != nil {
-		return fmt.Errorf("flushing NATS: %w", err)
-	}
-
-	slog.Info("chat message published to NATS", "team", teamName, "subject", subject)
-	return nil
+	return nc, nil
 }
 
 // chatMessageTypes are the message types that represent actual conversation
@@ -283,20 +517,40 @@ var chatMessageTypes = []string{
 	"task_result", // backward compat: records stored before relay fix
 }
 
+// Message and activity lists are newest first and filterable by sender.
+var (
+	messageListOptions = listOptions{
+		DefaultLimit: 100,
+		MaxLimit:     500,
+		Newest:       true,
+		Filters:      map[string]string{"from_agent": "from_agent"},
+	}
+	activityListOptions = listOptions{
+		DefaultLimit: 50,
+		MaxLimit:     200,
+		Newest:       true,
+		Filters: map[string]string{
+			"from_agent": "from_agent",
+			"type":       "message_type",
+			"event_type": "event_type",
+			"tool":       "tool_name",
+		},
+	}
+)
+
 // GetMessages returns chat messages for a team, filtered to conversation-relevant
 // types by default. Use the "types" query parameter to override (comma-separated).
-// Supports cursor-based pagination via the "before" query parameter (RFC3339 timestamp).
+// Results are paginated newest first via the "cursor" query parameter.
 func (s *Server) GetMessages(c *fiber.Ctx) error {
 	teamID := c.Params("id")
 
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	if _, err := s.findTeamCached(GetOrgID(c), teamID); err != nil {
+		return err
 	}
 
-	limit := c.QueryInt("limit", 100)
-	if limit > 500 {
-		limit = 500
+	q, err := parseListQuery(c, messageListOptions)
+	if err != nil {
+		return err
 	}
 
 	query := s.db.Where("team_id = ?", teamID)
@@ -309,23 +563,26 @@ func (s *Server) GetMessages(c *fiber.Ctx) error {
 		query = query.Where("message_type IN ?", chatMessageTypes)
 	}
 
-	// Cursor-based pagination: load messages older than the given timestamp.
-	if before := c.Query("before"); before != "" {
-		t, err := time.Parse(time.RFC3339Nano, before)
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid 'before' timestamp, use RFC3339 format")
-		}
-		query = query.Where("created_at < ?", t)
+	etag, total, err := listETag(c, query, &models.TaskLog{}, q)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list messages")
+	}
+	// Queued positions change without new messages.
+	if waiting := s.runQueueWaiting(teamID); len(waiting) > 0 {
+		etag = weakETag(append([]string{etag}, waiting...)...)
+	}
+	locale := requestLocale(c)
+	if notModified(c, localeETag(etag, locale)) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	var logs []models.TaskLog
-	if err := query.Order("created_at DESC").
-		Limit(limit).
-		Find(&logs).Error; err != nil {
+	resp, err := findPageWithTotal(query, q, total, taskLogKey)
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to list messages")
 	}
-
-	return c.JSON(logs)
+	s.markQueuedPositions(teamID, resp.Items)
+	explainLogFailures(resp.Items, locale)
+	return c.JSON(resp)
 }
 
 // GetActivity returns all task log entries for a team (including status updates,
@@ -334,34 +591,31 @@ func (s *Server) GetMessages(c *fiber.Ctx) error {
 func (s *Server) GetActivity(c *fiber.Ctx) error {
 	teamID := c.Params("id")
 
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	if _, err := s.findTeamCached(GetOrgID(c), teamID); err != nil {
+		return err
 	}
 
-	limit := c.QueryInt("limit", 50)
-	if limit > 200 {
-		limit = 200
+	q, err := parseListQuery(c, activityListOptions)
+	if err != nil {
+		return err
 	}
 
 	query := s.db.Where("team_id = ?", teamID)
-
-	if before := c.Query("before"); before != "" {
-		t, err := time.Parse(time.RFC3339Nano, before)
-		if err != nil {
-			return fiber.NewError(fiber.StatusBadRequest, "invalid 'before' timestamp, use RFC3339 format")
-		}
-		query = query.Where("created_at < ?", t)
+	etag, total, err := listETag(c, query, &models.TaskLog{}, q)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list activity")
+	}
+	locale := requestLocale(c)
+	if notModified(c, localeETag(etag, locale)) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
-	var logs []models.TaskLog
-	if err := query.Order("created_at DESC").
-		Limit(limit).
-		Find(&logs).Error; err != nil {
+	resp, err := findPageWithTotal(query, q, total, taskLogKey)
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to list activity")
 	}
-
-	return c.JSON(logs)
+	explainLogFailures(resp.Items, locale)
+	return c.JSON(resp)
 }
 
 // splitCSV splits a comma-separated string into trimmed, non-empty parts.
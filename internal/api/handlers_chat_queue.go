@@ -0,0 +1,174 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+)
+
+// leaderReadyTimeout is how long the relay waits for an agent_ready message
+// before assuming the leader is ready anyway. Sidecars built before the
+// ready signal existed never send one.
+var leaderReadyTimeout = 90 * time.Second
+
+// hasQueuedChats reports whether a team has user messages waiting for the
+// leader to become ready.
+func (s *Server) hasQueuedChats(teamID string) bool {
+	var count int64
+	s.db.Model(&models.TaskLog{}).
+		Where("team_id = ? AND message_type = ? AND delivery_status = ?",
+			teamID, string(protocol.TypeUserMessage), models.ChatDeliveryQueued).
+		Count(&count)
+	return count > 0
+}
+
+// isLeaderReady reports whether the team's leader has signalled that it can
+// accept messages since the relay was (re)started.
+func (s *Server) isLeaderReady(teamID string) bool {
+	if s.sharedRelayState {
+		return s.loadRelayState(teamID).LeaderReady
+	}
+	s.relaysMu.Lock()
+	defer s.relaysMu.Unlock()
+	return s.leaderReady[teamID]
+}
+
+// markLeaderReady records that the team's leader is ready and delivers any
+// messages queued while the team was deploying.
+func (s *Server) markLeaderReady(teamID, teamName string) {
+	s.relaysMu.Lock()
+	s.leaderReady[teamID] = true
+	s.relaysMu.Unlock()
+	if s.sharedRelayState {
+		s.saveRelayState(models.RelayState{TeamID: teamID, LeaderReady: true}, "leader_ready")
+	}
+
+	s.flushQueuedChats(teamID, teamName)
+}
+
+// chatQueueLock returns the mutex that serializes delivery of a team's
+// queued chat messages.
+func (s *Server) chatQueueLock(teamID string) *sync.Mutex {
+	mu, _ := s.chatQueueLocks.LoadOrStore(teamID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// flushQueuedChats publishes queued user messages to the team leader in the
+// order they were received, each after waiting its turn with the rate
+// limiter. Delivery stops at the first failure, or when the server shuts
+// down, so that the remaining messages stay queued, in order, for the next
+// attempt.
+func (s *Server) flushQueuedChats(teamID, teamName string) {
+	mu := s.chatQueueLock(teamID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	var logs []models.TaskLog
+	if err := s.db.Where("team_id = ? AND message_type = ? AND delivery_status = ?",
+		teamID, string(protocol.TypeUserMessage), models.ChatDeliveryQueued).
+		Order("created_at ASC").
+		Find(&logs).Error; err != nil {
+		slog.Error("failed to load queued chat messages", "team", teamName, "error", err)
+		return
+	}
+
+	var orgID string
+	s.db.Model(&models.Team{}).Where("id = ?", teamID).Pluck("org_id", &orgID)
+
+	sanitizedName := naming.Slug(teamName)
+	for _, l := range logs {
+		var payload protocol.UserMessagePayload
+		if err := json.Unmarshal(l.Payload, &payload); err != nil {
+			slog.Error("dropping unreadable queued chat message", "team", teamName, "id", l.ID, "error", err)
+			s.db.Model(&l).Update("delivery_status", models.ChatDeliveryFailed)
+			continue
+		}
+		// Waiting only fails when the server shuts down; the message stays
+		// queued for whoever relays the team next.
+		if err := s.governor.Wait(s.ctx, ratelimit.Request{
+			ID: l.ID, OrgID: orgID, TeamID: teamID, TeamName: teamName, Source: "chat",
+		}); err != nil {
+			return
+		}
+		// Claim the message before publishing it. It may have been failed
+		// because the team stopped while it waited, or delivered by another
+		// process: the API and relay workers both flush queues.
+		claim := s.db.Model(&models.TaskLog{}).
+			Where("id = ? AND delivery_status = ?", l.ID, models.ChatDeliveryQueued).
+			Update("delivery_status", models.ChatDeliverySent)
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+		payload.ConversationID = l.ConversationID
+		payload.Sequence = l.Sequence
+		if err := s.publishToTeamNATS(sanitizedName, l.ID, payload); err != nil {
+			slog.Error("failed to deliver queued chat message", "team", teamName, "id", l.ID, "error", err)
+			s.db.Model(&l).Update("delivery_status", models.ChatDeliveryQueued)
+			return
+		}
+	}
+
+	if len(logs) > 0 {
+		slog.Info("delivered queued chat messages", "team", teamName, "count", len(logs))
+	}
+}
+
+// failQueuedChats marks a team's queued messages as failed. It is called when
+// a deployment fails or the team is stopped, since the leader that would have
+// received them is gone.
+func (s *Server) failQueuedChats(teamID string) {
+	s.db.Model(&models.TaskLog{}).
+		Where("team_id = ? AND message_type = ? AND delivery_status = ?",
+			teamID, string(protocol.TypeUserMessage), models.ChatDeliveryQueued).
+		Update("delivery_status", models.ChatDeliveryFailed)
+}
+
+// setRunQueue records the run queue reported by a team's leader sidecar.
+func (s *Server) setRunQueue(teamID string, queue protocol.RunQueuePayload) {
+	s.relaysMu.Lock()
+	s.runQueues[teamID] = queue
+	s.relaysMu.Unlock()
+	if s.sharedRelayState {
+		data, _ := json.Marshal(queue)
+		s.saveRelayState(models.RelayState{TeamID: teamID, RunQueue: models.JSON(data)}, "run_queue")
+	}
+}
+
+// runQueueWaiting returns the IDs of the messages waiting in the team
+// leader's run queue, in order.
+func (s *Server) runQueueWaiting(teamID string) []string {
+	if s.sharedRelayState {
+		var queue protocol.RunQueuePayload
+		if state := s.loadRelayState(teamID); len(state.RunQueue) > 0 {
+			json.Unmarshal(state.RunQueue, &queue)
+		}
+		return queue.Waiting
+	}
+	s.relaysMu.Lock()
+	defer s.relaysMu.Unlock()
+	return s.runQueues[teamID].Waiting
+}
+
+// markQueuedPositions sets QueuedPosition on the team's user messages that
+// are waiting in the leader's run queue.
+func (s *Server) markQueuedPositions(teamID string, logs []models.TaskLog) {
+	waiting := s.runQueueWaiting(teamID)
+	if len(waiting) == 0 {
+		return
+	}
+	positions := make(map[string]int, len(waiting))
+	for i, id := range waiting {
+		positions[id] = i + 1
+	}
+	for i := range logs {
+		if logs[i].MessageType == string(protocol.TypeUserMessage) {
+			logs[i].QueuedPosition = positions[logs[i].ID]
+		}
+	}
+}
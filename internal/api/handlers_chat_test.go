@@ -110,16 +110,14 @@ func TestGetMessages_Pagination(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 2 {
 		t.Fatalf("messages with limit=2: got %d, want 2", len(logs))
 	}
 
 	// Request without limit should return all (default 50).
 	rec2 := doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
-	var allLogs []models.TaskLog
-	parseJSON(t, rec2, &allLogs)
+	allLogs := parseList[models.TaskLog](t, rec2)
 	if len(allLogs) != 5 {
 		t.Fatalf("messages without limit: got %d, want 5", len(allLogs))
 	}
@@ -154,8 +152,7 @@ func TestGetMessages_OrderDescending(t *testing.T) {
 	doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "second"})
 
 	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 
 	if len(logs) < 2 {
 		t.Fatalf("expected at least 2 messages, got %d", len(logs))
@@ -221,8 +218,7 @@ func TestGetMessages_FiltersOutStatusUpdates(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 
 	// Should get: 2 user_message + 1 leader_response + 1 task_result = 4
 	if len(logs) != 4 {
@@ -263,8 +259,7 @@ func TestGetMessages_CustomTypesFilter(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 1 {
 		t.Fatalf("custom type filter: got %d, want 1", len(logs))
 	}
@@ -289,8 +284,7 @@ func TestGetMessages_CursorPagination(t *testing.T) {
 
 	// Get all messages to find a cursor.
 	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
-	var allLogs []models.TaskLog
-	parseJSON(t, rec, &allLogs)
+	allLogs := parseList[models.TaskLog](t, rec)
 	if len(allLogs) != 3 {
 		t.Fatalf("expected 3 messages, got %d", len(allLogs))
 	}
@@ -303,8 +297,7 @@ func TestGetMessages_CursorPagination(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec2.Code)
 	}
 
-	var olderLogs []models.TaskLog
-	parseJSON(t, rec2, &olderLogs)
+	olderLogs := parseList[models.TaskLog](t, rec2)
 	if len(olderLogs) != 0 {
 		t.Fatalf("expected 0 messages before oldest, got %d", len(olderLogs))
 	}
@@ -349,8 +342,7 @@ func TestGetActivity(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 3 {
 		t.Fatalf("activity entries: got %d, want 3", len(logs))
 	}
@@ -389,8 +381,7 @@ func TestGetMessages_IncludesRelayedLeaderResponses(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var logs []models.TaskLog
-	parseJSON(t, rec, &logs)
+	logs := parseList[models.TaskLog](t, rec)
 	if len(logs) != 2 {
 		t.Fatalf("messages: got %d, want 2 (user_message + leader_response)", len(logs))
 	}
@@ -459,3 +450,177 @@ func TestSplitCSV(t *testing.T) {
 		}
 	}
 }
+
+func TestSendChat_DeployingWithoutQueueRejected(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-deploying-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "hello"})
+	if rec.Code != 409 {
+		t.Fatalf("status: got %d, want 409\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if n := countRelayLogs(t, srv, team.ID); n != 0 {
+		t.Errorf("task logs: got %d, want 0", n)
+	}
+}
+
+func TestSendChat_QueuesWhileDeploying(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-queue-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	for _, msg := range []string{"first", "second"} {
+		rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: msg, Queue: true})
+		if rec.Code != 202 {
+			t.Fatalf("status: got %d, want 202\nbody: %s", rec.Code, rec.Body.String())
+		}
+		var resp map[string]string
+		parseJSON(t, rec, &resp)
+		if resp["status"] != models.ChatDeliveryQueued {
+			t.Errorf("response status: got %q, want %q", resp["status"], models.ChatDeliveryQueued)
+		}
+	}
+
+	// Once running, later messages still queue behind the pending ones so
+	// that delivery order is preserved, even without opting in.
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "third"})
+	if rec.Code != 202 {
+		t.Fatalf("status: got %d, want 202\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	var logs []models.TaskLog
+	srv.db.Where("team_id = ?", team.ID).Order("created_at ASC").Find(&logs)
+	if len(logs) != 3 {
+		t.Fatalf("task logs: got %d, want 3", len(logs))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		var p protocol.UserMessagePayload
+		json.Unmarshal(logs[i].Payload, &p)
+		if p.Content != want {
+			t.Errorf("log %d content: got %q, want %q", i, p.Content, want)
+		}
+		if logs[i].DeliveryStatus != models.ChatDeliveryQueued {
+			t.Errorf("log %d delivery_status: got %q, want %q", i, logs[i].DeliveryStatus, models.ChatDeliveryQueued)
+		}
+	}
+}
+
+func TestStopTeam_FailsQueuedChats(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-queue-stop-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "pending", Queue: true})
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/stop", nil); rec.Code != 200 {
+		t.Fatalf("stop status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	var log models.TaskLog
+	srv.db.Where("team_id = ? AND message_type = ?", team.ID, "user_message").First(&log)
+	if log.DeliveryStatus != models.ChatDeliveryFailed {
+		t.Errorf("delivery_status: got %q, want %q", log.DeliveryStatus, models.ChatDeliveryFailed)
+	}
+	if srv.hasQueuedChats(team.ID) {
+		t.Error("expected no queued chats after stop")
+	}
+}
+
+func TestNextChatSequence_IncrementsAndResetsOnDeploy(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-sequence-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	for want := int64(1); want <= 3; want++ {
+		got, err := srv.nextChatSequence(team.ID)
+		if err != nil {
+			t.Fatalf("nextChatSequence: %v", err)
+		}
+		if got != want {
+			t.Errorf("sequence: got %d, want %d", got, want)
+		}
+	}
+
+	// Deploying starts a new conversation, which restarts the sequence.
+	doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	got, err := srv.nextChatSequence(team.ID)
+	if err != nil {
+		t.Fatalf("nextChatSequence: %v", err)
+	}
+	if got != 1 {
+		t.Errorf("sequence after deploy: got %d, want 1", got)
+	}
+}
+
+func TestSendChat_StampsSequence(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-stamp-seq-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	for _, msg := range []string{"one", "two"} {
+		doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: msg, Queue: true})
+	}
+
+	var logs []models.TaskLog
+	srv.db.Where("team_id = ?", team.ID).Order("created_at ASC").Find(&logs)
+	if len(logs) != 2 {
+		t.Fatalf("task logs: got %d, want 2", len(logs))
+	}
+	for i, l := range logs {
+		if l.Sequence != int64(i+1) {
+			t.Errorf("log %d sequence: got %d, want %d", i, l.Sequence, i+1)
+		}
+	}
+}
+
+func TestSendChat_RunOverrides(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-overrides-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "try on opus", Model: "opus", MaxThinkingTokens: 8000})
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var log models.TaskLog
+	srv.db.Where("team_id = ?", team.ID).First(&log)
+	var p protocol.UserMessagePayload
+	json.Unmarshal(log.Payload, &p)
+	if p.Overrides == nil || p.Overrides.Model != claudeModelID("opus") || p.Overrides.MaxThinkingTokens != 8000 {
+		t.Errorf("overrides: got %+v", p.Overrides)
+	}
+
+	for _, req := range []ChatRequest{
+		{Message: "bad", Model: "--dangerously-skip-permissions"},
+		{Message: "bad", MaxThinkingTokens: -1},
+	} {
+		if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", req); rec.Code != 400 {
+			t.Errorf("%+v: got %d, want 400", req, rec.Code)
+		}
+	}
+
+	srv.db.Model(&team).Update("provider", models.ProviderOpenCode)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "hi", Model: "opus"}); rec.Code != 400 {
+		t.Errorf("opencode team: got %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// maxTranscriptLogs caps the number of task logs loaded for a single export.
+const maxTranscriptLogs = 5000
+
+// maxActionLength truncates long tool action summaries in transcripts.
+const maxActionLength = 200
+
+// transcriptMessageTypes are the task log types that make up a transcript.
+var transcriptMessageTypes = []string{"user_message", string(protocol.TypeLeaderResponse), "activity_event"}
+
+// ListConversations returns the conversations recorded for a team, newest first.
+func (s *Server) ListConversations(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	var rows []struct {
+		ConversationID string
+		StartedAt      string
+		LastMessageAt  string
+		MessageCount   int64
+	}
+	if err := s.db.Model(&models.TaskLog{}).
+		Select("conversation_id, MIN(created_at) AS started_at, MAX(created_at) AS last_message_at, COUNT(*) AS message_count").
+		Where("team_id = ? AND conversation_id <> '' AND message_type IN ?", teamID, chatMessageTypes).
+		Group("conversation_id").
+		Order("last_message_at DESC").
+		Scan(&rows).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list conversations")
+	}
+
+	conversations := make([]ConversationSummary, 0, len(rows))
+	for _, r := range rows {
+		conversations = append(conversations, ConversationSummary{
+			ID:            r.ConversationID,
+			StartedAt:     parseSQLiteTime(r.StartedAt),
+			LastMessageAt: parseSQLiteTime(r.LastMessageAt),
+			MessageCount:  r.MessageCount,
+			Current:       r.ConversationID == team.ConversationID,
+		})
+	}
+	return c.JSON(conversations)
+}
+
+// ExportConversation returns a shareable transcript of one conversation as
+// Markdown (format=md, default) or JSON (format=json). Tool activity between
+// a user message and the leader's reply is collapsed into a list of actions.
+func (s *Server) ExportConversation(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+	conversationID := c.Params("cid")
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	format := c.Query("format", "md")
+	if format != "md" && format != "json" {
+		return fiber.NewError(fiber.StatusBadRequest, "format must be 'md' or 'json'")
+	}
+
+	var logs []models.TaskLog
+	if err := s.db.Where("team_id = ? AND conversation_id = ? AND message_type IN ?",
+		teamID, conversationID, transcriptMessageTypes).
+		Order("created_at ASC").
+		Limit(maxTranscriptLogs).
+		Find(&logs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load conversation")
+	}
+	if len(logs) == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "conversation not found")
+	}
+
+	transcript := buildTranscript(team, conversationID, logs)
+	filename := fmt.Sprintf("%s-%s.%s", naming.Slug(team.Name), shortID(conversationID), format)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		return c.JSON(transcript)
+	}
+	c.Set(fiber.HeaderContentType, "text/markdown; charset=utf-8")
+	return c.SendString(renderTranscriptMarkdown(transcript))
+}
+
+// buildTranscript converts ordered task logs into transcript entries.
+func buildTranscript(team models.Team, conversationID string, logs []models.TaskLog) ConversationTranscript {
+	t := ConversationTranscript{
+		TeamID:         team.ID,
+		TeamName:       team.Name,
+		ConversationID: conversationID,
+		StartedAt:      logs[0].CreatedAt,
+		EndedAt:        logs[len(logs)-1].CreatedAt,
+		Entries:        []TranscriptEntry{},
+	}
+
+	var pending []string
+	for _, l := range logs {
+		switch l.MessageType {
+		case "user_message":
+			var p protocol.UserMessagePayload
+			_ = json.Unmarshal(l.Payload, &p)
+			entry := TranscriptEntry{Role: "user", Content: p.Content, Timestamp: l.CreatedAt}
+			for _, f := range p.Files {
+				entry.Files = append(entry.Files, f.Name)
+			}
+			t.Entries = append(t.Entries, entry)
+			pending = nil
+
+		case "activity_event":
+			var p protocol.ActivityEventPayload
+			if err := json.Unmarshal(l.Payload, &p); err != nil || p.EventType != "tool_use" || p.Action == "" {
+				continue
+			}
+			action := p.Action
+			if r := []rune(action); len(r) > maxActionLength {
+				action = string(r[:maxActionLength]) + "…"
+			}
+			pending = append(pending, action)
+
+		case string(protocol.TypeLeaderResponse):
+			var p protocol.LeaderResponsePayload
+			_ = json.Unmarshal(l.Payload, &p)
+			t.Entries = append(t.Entries, TranscriptEntry{
+				Role:      "leader",
+				Content:   p.Result,
+				Status:    p.Status,
+				Error:     p.Error,
+				Actions:   collapseActions(pending),
+				Timestamp: l.CreatedAt,
+			})
+			pending = nil
+		}
+	}
+	return t
+}
+
+// collapseActions merges consecutive identical actions into "action (xN)".
+func collapseActions(actions []string) []string {
+	var out []string
+	for i := 0; i < len(actions); {
+		j := i + 1
+		for j < len(actions) && actions[j] == actions[i] {
+			j++
+		}
+		if n := j - i; n > 1 {
+			out = append(out, fmt.Sprintf("%s (x%d)", actions[i], n))
+		} else {
+			out = append(out, actions[i])
+		}
+		i = j
+	}
+	return out
+}
+
+// renderTranscriptMarkdown formats a transcript as Markdown for sharing.
+func renderTranscriptMarkdown(t ConversationTranscript) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s — conversation transcript\n\n", t.TeamName)
+	fmt.Fprintf(&b, "_Conversation `%s` · %s – %s_\n",
+		t.ConversationID, t.StartedAt.UTC().Format(time.RFC3339), t.EndedAt.UTC().Format(time.RFC3339))
+
+	for _, e := range t.Entries {
+		ts := e.Timestamp.UTC().Format(time.RFC3339)
+		if e.Role == "user" {
+			fmt.Fprintf(&b, "\n## User · %s\n\n", ts)
+		} else {
+			fmt.Fprintf(&b, "\n## Leader · %s\n\n", ts)
+		}
+
+		if len(e.Actions) > 0 {
+			fmt.Fprintf(&b, "<details>\n<summary>%d tool action(s)</summary>\n\n", len(e.Actions))
+			for _, a := range e.Actions {
+				fmt.Fprintf(&b, "- `%s`\n", strings.ReplaceAll(a, "`", "'"))
+			}
+			b.WriteString("\n</details>\n\n")
+		}
+
+		if len(e.Files) > 0 {
+			fmt.Fprintf(&b, "_Attachments: %s_\n\n", strings.Join(e.Files, ", "))
+		}
+
+		b.WriteString(strings.TrimSpace(e.Content))
+		b.WriteString("\n")
+
+		if e.Error != "" {
+			fmt.Fprintf(&b, "\n> **%s:** %s\n", e.Status, e.Error)
+		}
+	}
+	return b.String()
+}
+
+// parseSQLiteTime parses a timestamp returned by an SQLite aggregate, which
+// comes back as text rather than a time.Time.
+func parseSQLiteTime(s string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// shortID returns the first 8 characters of an ID for use in filenames.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func seedConversation(t *testing.T, srv *Server) (models.Team, string) {
+	t.Helper()
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "export-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	cid := uuid.New().String()
+	srv.db.Model(&team).Update("conversation_id", cid)
+
+	base := time.Now().Add(-time.Minute)
+	logs := []struct {
+		msgType string
+		payload string
+	}{
+		{"user_message", `{"content":"Fix the build"}`},
+		{"activity_event", `{"event_type":"tool_use","action":"Bash: go build ./..."}`},
+		{"activity_event", `{"event_type":"tool_use","action":"Bash: go build ./..."}`},
+		{"activity_event", `{"event_type":"assistant","action":"thinking"}`},
+		{"activity_event", `{"event_type":"tool_use","action":"Edit: main.go"}`},
+		{"leader_response", `{"status":"completed","result":"Build fixed."}`},
+	}
+	for i, l := range logs {
+		srv.db.Create(&models.TaskLog{
+			ID:             uuid.New().String(),
+			TeamID:         team.ID,
+			ConversationID: cid,
+			FromAgent:      "leader",
+			MessageType:    l.msgType,
+			Payload:        models.JSON(l.payload),
+			CreatedAt:      base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return team, cid
+}
+
+func TestExportConversation_Markdown(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team, cid := seedConversation(t, srv)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/conversations/"+cid+"/export", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	for _, want := range []string{"## User", "Fix the build", "## Leader", "Build fixed.", "Bash: go build ./... (x2)", "Edit: main.go"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected markdown to contain %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "thinking") {
+		t.Error("non-tool activity should not appear in transcript")
+	}
+}
+
+func TestExportConversation_JSON(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team, cid := seedConversation(t, srv)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/conversations/"+cid+"/export?format=json", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var transcript ConversationTranscript
+	if err := json.Unmarshal(rec.Body.Bytes(), &transcript); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(transcript.Entries) != 2 {
+		t.Fatalf("entries: got %d, want 2", len(transcript.Entries))
+	}
+	leader := transcript.Entries[1]
+	if leader.Role != "leader" || len(leader.Actions) != 2 {
+		t.Errorf("leader entry: got role %q with %d actions", leader.Role, len(leader.Actions))
+	}
+}
+
+func TestExportConversation_Errors(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team, cid := seedConversation(t, srv)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/conversations/"+cid+"/export?format=pdf", nil)
+	if rec.Code != 400 {
+		t.Errorf("invalid format: got %d, want 400", rec.Code)
+	}
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/conversations/missing/export", nil)
+	if rec.Code != 404 {
+		t.Errorf("unknown conversation: got %d, want 404", rec.Code)
+	}
+}
+
+func TestListConversations(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team, cid := seedConversation(t, srv)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/conversations", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200", rec.Code)
+	}
+	var list []ConversationSummary
+	parseJSON(t, rec, &list)
+	if len(list) != 1 || list[0].ID != cid || !list[0].Current || list[0].MessageCount != 2 {
+		t.Errorf("got %+v", list)
+	}
+}
+
+func TestBuildTranscript_TruncatesActionsByRune(t *testing.T) {
+	action := strings.Repeat("é", maxActionLength+10)
+	payload, _ := json.Marshal(map[string]string{"event_type": "tool_use", "action": action})
+	logs := []models.TaskLog{
+		{MessageType: "activity_event", Payload: models.JSON(payload)},
+		{MessageType: "leader_response", Payload: models.JSON(`{"status":"completed","result":"done"}`)},
+	}
+
+	transcript := buildTranscript(models.Team{}, "cid", logs)
+	if len(transcript.Entries) != 1 || len(transcript.Entries[0].Actions) != 1 {
+		t.Fatalf("got %+v", transcript.Entries)
+	}
+	got := transcript.Entries[0].Actions[0]
+	if want := strings.Repeat("é", maxActionLength) + "…"; got != want {
+		t.Errorf("action: got %d runes, want %d", len([]rune(got)), maxActionLength+1)
+	}
+}
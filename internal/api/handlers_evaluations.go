@@ -0,0 +1,500 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/evaluation"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Limits for evaluations.
+const (
+	maxEvaluationAssertions     = 20
+	defaultEvaluationTimeout    = 600
+	maxEvaluationTimeoutSeconds = 3600
+)
+
+// An evaluation run runs on the replica that started it, which holds the
+// lease evaluationRunLeasePrefix+ID until the run finishes and renews it
+// every third of evaluationRunLeaseTTL. A run whose lease expired was
+// interrupted.
+const evaluationRunLeasePrefix = "evaluation-run/"
+
+var evaluationRunLeaseTTL = election.DefaultTTL
+
+// leaderErrorPrefix marks a failed run in the text sendWebhookPromptAndWait
+// returns.
+const leaderErrorPrefix = "Error: "
+
+// evaluationRunListOptions configures GET /api/teams/:id/evaluations/runs:
+// newest first, filterable by status.
+var evaluationRunListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status"},
+}
+
+func evaluationRunKey(r models.EvaluationRun) (time.Time, string) { return r.CreatedAt, r.ID }
+
+// findEvaluationTeam loads the team in the URL, scoped to the caller's
+// organization.
+func (s *Server) findEvaluationTeam(c *fiber.Ctx) (models.Team, error) {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return team, newAPIError(CodeTeamNotFound, "team not found")
+	}
+	return team, nil
+}
+
+// validateAssertions checks the assertions of an evaluation and returns them
+// encoded for storage.
+func validateAssertions(assertions []evaluation.Assertion) (models.JSON, error) {
+	if len(assertions) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "at least one assertion is required")
+	}
+	if len(assertions) > maxEvaluationAssertions {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("at most %d assertions are allowed", maxEvaluationAssertions))
+	}
+	for i, a := range assertions {
+		if err := a.Validate(); err != nil {
+			return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("assertions[%d]: %s", i, err))
+		}
+	}
+	data, err := json.Marshal(assertions)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "invalid assertions")
+	}
+	return models.JSON(data), nil
+}
+
+// validateEvaluationTimeout checks an evaluation's timeout_seconds.
+func validateEvaluationTimeout(seconds int) error {
+	if seconds < 1 || seconds > maxEvaluationTimeoutSeconds {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("timeout_seconds must be between 1 and %d", maxEvaluationTimeoutSeconds))
+	}
+	return nil
+}
+
+// ListEvaluations returns a team's evaluations, sorted by name.
+func (s *Server) ListEvaluations(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	var evals []models.Evaluation
+	if err := s.db.Where("team_id = ?", team.ID).Order("name ASC").Find(&evals).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list evaluations")
+	}
+	return c.JSON(evals)
+}
+
+// CreateEvaluation adds a test prompt and its assertions to a team.
+func (s *Server) CreateEvaluation(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+
+	var req CreateEvaluationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if req.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+	}
+	if len(req.Name) > 255 {
+		return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+	}
+	if req.Prompt == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "prompt is required")
+	}
+	if len(req.Prompt) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt exceeds maximum length of %d characters", maxPromptLength))
+	}
+	assertions, err := validateAssertions(req.Assertions)
+	if err != nil {
+		return err
+	}
+	timeout := defaultEvaluationTimeout
+	if req.TimeoutSeconds != nil {
+		timeout = *req.TimeoutSeconds
+	}
+	if err := validateEvaluationTimeout(timeout); err != nil {
+		return err
+	}
+
+	eval := models.Evaluation{
+		ID:             uuid.New().String(),
+		TeamID:         team.ID,
+		Name:           req.Name,
+		Prompt:         req.Prompt,
+		Assertions:     assertions,
+		TimeoutSeconds: timeout,
+	}
+	if err := s.db.Create(&eval).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create evaluation")
+	}
+	return c.Status(fiber.StatusCreated).JSON(eval)
+}
+
+// UpdateEvaluation updates an evaluation's fields. Past results keep the
+// prompt and assertions they ran with.
+func (s *Server) UpdateEvaluation(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	var eval models.Evaluation
+	if err := s.db.First(&eval, "id = ? AND team_id = ?", c.Params("evalId"), team.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "evaluation not found")
+	}
+
+	var req UpdateEvaluationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "name cannot be empty")
+		}
+		if len(*req.Name) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Prompt != nil {
+		if *req.Prompt == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "prompt cannot be empty")
+		}
+		if len(*req.Prompt) > maxPromptLength {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt exceeds maximum length of %d characters", maxPromptLength))
+		}
+		updates["prompt"] = *req.Prompt
+	}
+	if req.Assertions != nil {
+		assertions, err := validateAssertions(req.Assertions)
+		if err != nil {
+			return err
+		}
+		updates["assertions"] = assertions
+	}
+	if req.TimeoutSeconds != nil {
+		if err := validateEvaluationTimeout(*req.TimeoutSeconds); err != nil {
+			return err
+		}
+		updates["timeout_seconds"] = *req.TimeoutSeconds
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&eval).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update evaluation")
+		}
+	}
+	s.db.First(&eval, "id = ?", eval.ID)
+	return c.JSON(eval)
+}
+
+// DeleteEvaluation removes an evaluation. Its past results are kept.
+func (s *Server) DeleteEvaluation(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	var eval models.Evaluation
+	if err := s.db.First(&eval, "id = ? AND team_id = ?", c.Params("evalId"), team.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "evaluation not found")
+	}
+	if err := s.db.Delete(&eval).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete evaluation")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RunEvaluations handles POST /api/teams/:id/evaluations/run. It sends each
+// evaluation's prompt to the team's leader in turn, checks the response
+// against the evaluation's assertions and records the outcome. The run
+// continues in the background; poll GET /evaluations/runs/:runId for results.
+func (s *Server) RunEvaluations(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	var req RunEvaluationsRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+
+	grader := team
+	if req.GraderTeamID != "" && req.GraderTeamID != team.ID {
+		var other models.Team
+		if err := s.db.Scopes(OrgScope(c)).First(&other, "id = ?", req.GraderTeamID).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "grader_team_id references a non-existent team")
+		}
+		if other.Status != models.TeamStatusRunning {
+			return fiber.NewError(fiber.StatusConflict, "grader team is not running")
+		}
+		grader = other
+	}
+
+	query := s.db.Where("team_id = ?", team.ID)
+	if len(req.EvaluationIDs) > 0 {
+		query = query.Where("id IN ?", req.EvaluationIDs)
+	}
+	var evals []models.Evaluation
+	if err := query.Order("name ASC").Find(&evals).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load evaluations")
+	}
+	if len(req.EvaluationIDs) > 0 && len(evals) != len(req.EvaluationIDs) {
+		return fiber.NewError(fiber.StatusBadRequest, "evaluation_ids references an evaluation not in this team")
+	}
+	if len(evals) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "team has no evaluations")
+	}
+
+	var active int64
+	s.db.Model(&models.EvaluationRun{}).
+		Where("team_id = ? AND status = ?", team.ID, models.EvaluationRunStatusRunning).
+		Count(&active)
+	if active > 0 {
+		return fiber.NewError(fiber.StatusConflict, "an evaluation run is already in progress for this team")
+	}
+
+	run := models.EvaluationRun{
+		ID:        uuid.New().String(),
+		TeamID:    team.ID,
+		Status:    models.EvaluationRunStatusRunning,
+		Total:     len(evals),
+		CreatedBy: GetUserID(c),
+	}
+	if grader.ID != team.ID {
+		run.GraderTeamID = grader.ID
+	}
+	for _, eval := range evals {
+		run.Results = append(run.Results, models.EvaluationResult{
+			ID:           uuid.New().String(),
+			EvaluationID: eval.ID,
+			Name:         eval.Name,
+			Prompt:       eval.Prompt,
+			Assertions:   eval.Assertions,
+			Status:       models.EvaluationResultPending,
+		})
+	}
+	// Claimed before the run is saved, so that it is never seen running
+	// without a holder.
+	if _, err := election.Acquire(s.db, evaluationRunLeasePrefix+run.ID, run.ID, evaluationRunLeaseTTL, time.Now().UTC()); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create evaluation run")
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		election.Release(s.db, evaluationRunLeasePrefix+run.ID, run.ID)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create evaluation run")
+	}
+
+	slog.Info("evaluation run started", "id", run.ID, "team", team.Name, "evaluations", len(evals))
+	go s.runEvaluations(run, team, grader, evals)
+
+	return c.Status(fiber.StatusAccepted).JSON(run)
+}
+
+// ListEvaluationRuns returns a team's evaluation runs, without their
+// results.
+func (s *Server) ListEvaluationRuns(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	q, err := parseListQuery(c, evaluationRunListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", team.ID), q, evaluationRunKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list evaluation runs")
+	}
+	return c.JSON(resp)
+}
+
+// GetEvaluationRun returns an evaluation run with its results.
+func (s *Server) GetEvaluationRun(c *fiber.Ctx) error {
+	team, err := s.findEvaluationTeam(c)
+	if err != nil {
+		return err
+	}
+	var run models.EvaluationRun
+	if err := s.db.Preload("Results", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name ASC")
+	}).First(&run, "id = ? AND team_id = ?", c.Params("runId"), team.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "evaluation run not found")
+	}
+	return c.JSON(run)
+}
+
+// FailInterruptedEvaluationRuns marks evaluation runs left running by a
+// previous API process or a replica that is gone as failed, so that new
+// runs can start. Runs whose replica still holds their lease are left
+// alone.
+func (s *Server) FailInterruptedEvaluationRuns() {
+	var running []string
+	s.db.Model(&models.EvaluationRun{}).
+		Where("status = ?", models.EvaluationRunStatusRunning).
+		Pluck("id", &running)
+	now := time.Now().UTC()
+	var runIDs []string
+	for _, id := range running {
+		if lease, err := election.Lookup(s.db, evaluationRunLeasePrefix+id, now); err != nil || lease != nil {
+			continue
+		}
+		runIDs = append(runIDs, id)
+	}
+	if len(runIDs) == 0 {
+		return
+	}
+	s.db.Model(&models.EvaluationResult{}).
+		Where("run_id IN ? AND status = ?", runIDs, models.EvaluationResultPending).
+		Updates(map[string]interface{}{
+			"status":      models.EvaluationResultError,
+			"error":       "interrupted by an API restart",
+			"finished_at": time.Now(),
+		})
+	for _, id := range runIDs {
+		s.finishEvaluationRun(id)
+	}
+	slog.Warn("failed interrupted evaluation runs", "count", len(runIDs))
+}
+
+// runEvaluations runs each evaluation against team in turn and records the
+// results, renewing the run's lease until it finishes. llm assertions are
+// graded by grader.
+func (s *Server) runEvaluations(run models.EvaluationRun, team, grader models.Team, evals []models.Evaluation) {
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		election.Release(s.db, evaluationRunLeasePrefix+run.ID, run.ID)
+	}()
+	go func() {
+		ticker := time.NewTicker(evaluationRunLeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if _, err := election.Acquire(s.db, evaluationRunLeasePrefix+run.ID, run.ID, evaluationRunLeaseTTL, time.Now().UTC()); err != nil {
+				slog.Warn("failed to renew evaluation run", "id", run.ID, "error", err)
+			}
+		}
+	}()
+
+	for i, eval := range evals {
+		s.runEvaluation(run.Results[i], eval, team, grader)
+	}
+	s.finishEvaluationRun(run.ID)
+
+	var finished models.EvaluationRun
+	s.db.First(&finished, "id = ?", run.ID)
+	slog.Info("evaluation run finished", "id", run.ID, "team", team.Name,
+		"status", finished.Status, "passed", finished.Passed, "failed", finished.Failed)
+}
+
+// runEvaluation sends one evaluation's prompt and checks the response.
+func (s *Server) runEvaluation(result models.EvaluationResult, eval models.Evaluation, team, grader models.Team) {
+	timeout := time.Duration(eval.TimeoutSeconds) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	updates := map[string]interface{}{}
+	response, err := s.promptLeader(ctx, team, eval.Prompt, result.ID)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		updates["status"] = models.EvaluationResultError
+		updates["error"] = fmt.Sprintf("no response within %ds", eval.TimeoutSeconds)
+	case err != nil:
+		updates["status"] = models.EvaluationResultError
+		updates["error"] = err.Error()
+	case strings.HasPrefix(response, leaderErrorPrefix):
+		updates["status"] = models.EvaluationResultError
+		updates["error"] = strings.TrimPrefix(response, leaderErrorPrefix)
+	default:
+		var assertions []evaluation.Assertion
+		json.Unmarshal(eval.Assertions, &assertions)
+		// Grading gets its own timeout, so a slow answer leaves time to grade it.
+		gradeCtx, cancelGrade := context.WithTimeout(context.Background(), timeout)
+		results := evaluation.Check(gradeCtx, assertions, eval.Prompt, response, leaderGrader{s: s, team: grader})
+		cancelGrade()
+		data, _ := json.Marshal(results)
+		updates["response"] = response
+		updates["assertions"] = models.JSON(data)
+		updates["status"] = models.EvaluationResultFailed
+		if evaluation.Passed(results) {
+			updates["status"] = models.EvaluationResultPassed
+		}
+	}
+	now := time.Now()
+	updates["duration_ms"] = now.Sub(start).Milliseconds()
+	updates["finished_at"] = now
+	if err := s.db.Model(&models.EvaluationResult{}).Where("id = ?", result.ID).Updates(updates).Error; err != nil {
+		slog.Error("evaluation: failed to save result", "run_id", result.RunID, "evaluation", eval.Name, "error", err)
+	}
+}
+
+// finishEvaluationRun counts a run's results and sets its final status.
+func (s *Server) finishEvaluationRun(runID string) {
+	var passed, total int64
+	s.db.Model(&models.EvaluationResult{}).Where("run_id = ?", runID).Count(&total)
+	s.db.Model(&models.EvaluationResult{}).
+		Where("run_id = ? AND status = ?", runID, models.EvaluationResultPassed).
+		Count(&passed)
+
+	status := models.EvaluationRunStatusFailed
+	if passed == total {
+		status = models.EvaluationRunStatusPassed
+	}
+	s.db.Model(&models.EvaluationRun{}).Where("id = ?", runID).Updates(map[string]interface{}{
+		"status":      status,
+		"passed":      passed,
+		"failed":      total - passed,
+		"finished_at": time.Now(),
+	})
+}
+
+// leaderGrader grades llm assertions by asking a team's leader.
+type leaderGrader struct {
+	s    *Server
+	team models.Team
+}
+
+func (g leaderGrader) Grade(ctx context.Context, prompt, response, criteria string) (bool, string, error) {
+	reply, err := g.s.promptLeader(ctx, g.team, evaluation.GradingPrompt(prompt, response, criteria), uuid.New().String())
+	if err != nil {
+		return false, "", err
+	}
+	if strings.HasPrefix(reply, leaderErrorPrefix) {
+		return false, "", errors.New(strings.TrimPrefix(reply, leaderErrorPrefix))
+	}
+	return evaluation.ParseVerdict(reply)
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/evaluation"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// waitForEvaluationRun polls the run until it is no longer running.
+func waitForEvaluationRun(t *testing.T, srv *Server, teamID, runID string) models.EvaluationRun {
+	t.Helper()
+	var run models.EvaluationRun
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/evaluations/runs/"+runID, nil)
+		parseJSON(t, rec, &run)
+		if run.Status != models.EvaluationRunStatusRunning {
+			return run
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("evaluation run %s still running", runID)
+	return run
+}
+
+func TestEvaluations_CRUD(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "eval-team", nil)
+	base := "/api/teams/" + team.ID + "/evaluations"
+
+	invalid := []CreateEvaluationRequest{
+		{Prompt: "p", Assertions: []evaluation.Assertion{{Type: "regex", Pattern: "x"}}},
+		{Name: "n", Assertions: []evaluation.Assertion{{Type: "regex", Pattern: "x"}}},
+		{Name: "n", Prompt: "p"},
+		{Name: "n", Prompt: "p", Assertions: []evaluation.Assertion{{Type: "regex", Pattern: "("}}},
+		{Name: "n", Prompt: "p", Assertions: []evaluation.Assertion{{Type: "contains"}}},
+	}
+	for i, req := range invalid {
+		if rec := doRequest(srv, "POST", base, req); rec.Code != 400 {
+			t.Errorf("invalid[%d]: got %d, want 400", i, rec.Code)
+		}
+	}
+	zero := 0
+	if rec := doRequest(srv, "POST", base, CreateEvaluationRequest{
+		Name: "n", Prompt: "p", TimeoutSeconds: &zero,
+		Assertions: []evaluation.Assertion{{Type: "regex", Pattern: "x"}},
+	}); rec.Code != 400 {
+		t.Errorf("zero timeout: got %d, want 400", rec.Code)
+	}
+
+	rec := doRequest(srv, "POST", base, CreateEvaluationRequest{
+		Name:       "greets",
+		Prompt:     "Say hello",
+		Assertions: []evaluation.Assertion{{Type: "regex", Pattern: "(?i)hello"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var eval models.Evaluation
+	parseJSON(t, rec, &eval)
+	if eval.TeamID != team.ID || eval.TimeoutSeconds != defaultEvaluationTimeout {
+		t.Errorf("created evaluation: got %+v", eval)
+	}
+
+	name := "says hello"
+	rec = doRequest(srv, "PUT", base+"/"+eval.ID, UpdateEvaluationRequest{Name: &name})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &eval)
+	if eval.Name != name || eval.Prompt != "Say hello" {
+		t.Errorf("updated evaluation: got %+v", eval)
+	}
+	if rec := doRequest(srv, "PUT", base+"/"+eval.ID, UpdateEvaluationRequest{Assertions: []evaluation.Assertion{}}); rec.Code != 400 {
+		t.Errorf("empty assertions: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "GET", base, nil)
+	var evals []models.Evaluation
+	parseJSON(t, rec, &evals)
+	if len(evals) != 1 || evals[0].ID != eval.ID {
+		t.Errorf("list: got %+v", evals)
+	}
+
+	other := createLabeledTeam(t, srv, "other-team", nil)
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+other.ID+"/evaluations/"+eval.ID, nil); rec.Code != 404 {
+		t.Errorf("delete through another team: got %d, want 404", rec.Code)
+	}
+	if rec := doRequest(srv, "DELETE", base+"/"+eval.ID, nil); rec.Code != 204 {
+		t.Errorf("delete: got %d, want 204", rec.Code)
+	}
+}
+
+func TestRunEvaluations(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "eval-run-team", nil)
+	grader := createLabeledTeam(t, srv, "grader-team", nil)
+	base := "/api/teams/" + team.ID + "/evaluations"
+
+	if rec := doRequest(srv, "POST", base+"/run", nil); rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+	srv.db.Model(&models.Team{}).Where("id IN ?", []string{team.ID, grader.ID}).
+		Update("status", models.TeamStatusRunning)
+	if rec := doRequest(srv, "POST", base+"/run", nil); rec.Code != 400 {
+		t.Errorf("no evaluations: got %d, want 400", rec.Code)
+	}
+
+	for _, req := range []CreateEvaluationRequest{
+		{Name: "a-json", Prompt: "Report status as JSON", Assertions: []evaluation.Assertion{
+			{Type: evaluation.AssertionJSONPath, Path: "$.status", Equals: json.RawMessage(`"ok"`)},
+			{Type: evaluation.AssertionLLM, Criteria: "reports a status"},
+		}},
+		{Name: "b-greeting", Prompt: "Say hello", Assertions: []evaluation.Assertion{
+			{Type: evaluation.AssertionRegex, Pattern: "(?i)hello"},
+		}},
+		{Name: "c-broken", Prompt: "Crash", Assertions: []evaluation.Assertion{
+			{Type: evaluation.AssertionRegex, Pattern: "."},
+		}},
+	} {
+		if rec := doRequest(srv, "POST", base, req); rec.Code != 201 {
+			t.Fatalf("create %s: got %d, body: %s", req.Name, rec.Code, rec.Body.String())
+		}
+	}
+
+	var mu sync.Mutex
+	prompted := map[string][]string{}
+	srv.promptLeader = func(_ context.Context, team models.Team, prompt, _ string) (string, error) {
+		mu.Lock()
+		prompted[team.ID] = append(prompted[team.ID], prompt)
+		mu.Unlock()
+		switch {
+		case strings.Contains(prompt, "<criteria>"):
+			return "PASS\nIt reports ok.", nil
+		case prompt == "Report status as JSON":
+			return "```json\n{\"status\": \"ok\"}\n```", nil
+		case prompt == "Say hello":
+			return "Goodbye", nil
+		}
+		return "", errors.New("leader unreachable")
+	}
+
+	if rec := doRequest(srv, "POST", base+"/run", RunEvaluationsRequest{GraderTeamID: "missing"}); rec.Code != 400 {
+		t.Errorf("unknown grader team: got %d, want 400", rec.Code)
+	}
+	rec := doRequest(srv, "POST", base+"/run", RunEvaluationsRequest{GraderTeamID: grader.ID})
+	if rec.Code != 202 {
+		t.Fatalf("run: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var run models.EvaluationRun
+	parseJSON(t, rec, &run)
+	if run.Total != 3 || len(run.Results) != 3 {
+		t.Fatalf("started run: got %+v", run)
+	}
+
+	run = waitForEvaluationRun(t, srv, team.ID, run.ID)
+	if run.Status != models.EvaluationRunStatusFailed || run.Passed != 1 || run.Failed != 2 || run.FinishedAt == nil {
+		t.Errorf("finished run: got %+v", run)
+	}
+	want := []string{models.EvaluationResultPassed, models.EvaluationResultFailed, models.EvaluationResultError}
+	for i, r := range run.Results {
+		if r.Status != want[i] {
+			t.Errorf("%s: status %q, want %q (error %q)", r.Name, r.Status, want[i], r.Error)
+		}
+	}
+	if got := run.Results[2].Error; got != "leader unreachable" {
+		t.Errorf("error result: got %q", got)
+	}
+	var checks []evaluation.Result
+	json.Unmarshal(run.Results[1].Assertions, &checks)
+	if len(checks) != 1 || checks[0].Passed || checks[0].Message == "" {
+		t.Errorf("failed assertion: got %+v", checks)
+	}
+	if len(prompted[grader.ID]) != 1 || len(prompted[team.ID]) != 3 {
+		t.Errorf("prompts: got %d to the grader, %d to the team", len(prompted[grader.ID]), len(prompted[team.ID]))
+	}
+
+	// A single evaluation can be rerun; history keeps both runs.
+	evalID := run.Results[1].EvaluationID
+	rec = doRequest(srv, "POST", base+"/run", RunEvaluationsRequest{EvaluationIDs: []string{evalID}})
+	if rec.Code != 202 {
+		t.Fatalf("rerun: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &run)
+	waitForEvaluationRun(t, srv, team.ID, run.ID)
+
+	runs := parseList[models.EvaluationRun](t, doRequest(srv, "GET", base+"/runs", nil))
+	if len(runs) != 2 || runs[0].ID != run.ID || runs[0].Total != 1 {
+		t.Errorf("run history: got %+v", runs)
+	}
+	runs = parseList[models.EvaluationRun](t, doRequest(srv, "GET", base+"/runs?status=passed", nil))
+	if len(runs) != 0 {
+		t.Errorf("passed runs: got %+v", runs)
+	}
+}
+
+func TestFailInterruptedEvaluationRuns(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "eval-restart-team", nil)
+	run := models.EvaluationRun{
+		ID: "run-1", TeamID: team.ID, Status: models.EvaluationRunStatusRunning, Total: 2,
+		Results: []models.EvaluationResult{
+			{ID: "r-1", Name: "a", Status: models.EvaluationResultPassed},
+			{ID: "r-2", Name: "b", Status: models.EvaluationResultPending},
+		},
+	}
+	if err := srv.db.Create(&run).Error; err != nil {
+		t.Fatalf("create run: %v", err)
+	}
+
+	// A run another replica still holds is left alone.
+	election.Acquire(srv.db, evaluationRunLeasePrefix+run.ID, run.ID, time.Minute, time.Now().UTC())
+	srv.FailInterruptedEvaluationRuns()
+	srv.db.First(&run, "id = ?", run.ID)
+	if run.Status != models.EvaluationRunStatusRunning {
+		t.Fatalf("held run: got status %q", run.Status)
+	}
+
+	election.Release(srv.db, evaluationRunLeasePrefix+run.ID, run.ID)
+	srv.FailInterruptedEvaluationRuns()
+
+	srv.db.Preload("Results").First(&run, "id = ?", run.ID)
+	if run.Status != models.EvaluationRunStatusFailed || run.Passed != 1 || run.Failed != 1 {
+		t.Errorf("interrupted run: got %+v", run)
+	}
+	for _, r := range run.Results {
+		if r.ID == "r-2" && (r.Status != models.EvaluationResultError || r.Error == "") {
+			t.Errorf("pending result: got %+v", r)
+		}
+	}
+}
@@ -2,8 +2,25 @@ package api
 
 import (
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
 )
 
+// DBStatsResponse reports SQLite configuration, connection pool usage,
+// TaskLog writer activity and read cache hits.
+type DBStatsResponse struct {
+	JournalMode        string             `json:"journal_mode"`
+	BusyTimeoutMs      int                `json:"busy_timeout_ms"`
+	OpenConnections    int                `json:"open_connections"`
+	InUse              int                `json:"in_use"`
+	Idle               int                `json:"idle"`
+	WaitCount          int64              `json:"wait_count"`
+	WaitDurationMs     int64              `json:"wait_duration_ms"`
+	TaskLogWriter      TaskLogWriterStats `json:"task_log_writer"`
+	PendingDeadLetters int64              `json:"pending_dead_letters"`
+	ReadCache          ReadCacheStats     `json:"read_cache"`
+}
+
 // HealthCheck verifies API and database connectivity.
 func (s *Server) HealthCheck(c *fiber.Ctx) error {
 	var errors []string
@@ -22,3 +39,27 @@ func (s *Server) HealthCheck(c *fiber.Ctx) error {
 
 	return c.JSON(fiber.Map{"status": "ok"})
 }
+
+// GetDBStats returns database health metrics (admin only).
+func (s *Server) GetDBStats(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view database stats")
+	}
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to access database")
+	}
+
+	resp := DBStatsResponse{TaskLogWriter: s.taskLogs.Stats(), ReadCache: s.readCache.Stats()}
+	s.db.Raw("PRAGMA journal_mode").Scan(&resp.JournalMode)
+	s.db.Raw("PRAGMA busy_timeout").Scan(&resp.BusyTimeoutMs)
+	s.db.Model(&models.DeadLetter{}).Where("status = ?", models.DeadLetterStatusPending).Count(&resp.PendingDeadLetters)
+
+	stats := sqlDB.Stats()
+	resp.OpenConnections = stats.OpenConnections
+	resp.InUse = stats.InUse
+	resp.Idle = stats.Idle
+	resp.WaitCount = stats.WaitCount
+	resp.WaitDurationMs = stats.WaitDuration.Milliseconds()
+	return c.JSON(resp)
+}
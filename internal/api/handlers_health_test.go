@@ -50,3 +50,21 @@ func TestHealthCheck_Unhealthy(t *testing.T) {
 		t.Errorf("expected non-empty errors array, got %v", resp["errors"])
 	}
 }
+
+func TestGetDBStats(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/admin/db", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d, want %d\nbody: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var resp DBStatsResponse
+	parseJSON(t, rec, &resp)
+	if resp.JournalMode == "" {
+		t.Error("expected journal_mode to be reported")
+	}
+	if resp.BusyTimeoutMs <= 0 {
+		t.Errorf("busy_timeout_ms: got %d, want > 0", resp.BusyTimeoutMs)
+	}
+}
@@ -0,0 +1,437 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/integrations/issues"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// issueRunTimeout bounds a run started from a tracker comment.
+const issueRunTimeout = time.Hour
+
+// validIssueProviders is the set of supported issue trackers.
+var validIssueProviders = map[string]bool{
+	models.IssueProviderJira:   true,
+	models.IssueProviderLinear: true,
+}
+
+// validIssueSyncModes is the set of allowed sync modes.
+var validIssueSyncModes = map[string]bool{
+	models.IssueSyncModeCreate: true,
+	models.IssueSyncModeUpdate: true,
+}
+
+// ListIssueIntegrations returns all issue tracker integrations.
+func (s *Server) ListIssueIntegrations(c *fiber.Ctx) error {
+	var integrations []models.IssueIntegration
+	if err := s.db.Scopes(OrgScope(c)).Preload("Team").Find(&integrations).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list issue integrations")
+	}
+	return c.JSON(integrations)
+}
+
+// GetIssueIntegration returns a single issue tracker integration by ID.
+func (s *Server) GetIssueIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.IssueIntegration
+	if err := s.db.Scopes(OrgScope(c)).Preload("Team").First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "issue integration not found")
+	}
+	return c.JSON(integration)
+}
+
+// CreateIssueIntegration creates an issue tracker integration and returns it
+// with the inbound token used to deliver tracker comment webhooks.
+func (s *Server) CreateIssueIntegration(c *fiber.Ctx) error {
+	var req CreateIssueIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	if req.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+	}
+	if len(req.Name) > 255 {
+		return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+	}
+	if req.TeamID == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "team_id is required")
+	}
+	if !validIssueProviders[req.Provider] {
+		return fiber.NewError(fiber.StatusBadRequest, "provider must be one of jira, linear")
+	}
+	if req.Project == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "project is required")
+	}
+	if req.Provider == models.IssueProviderJira && req.BaseURL == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "base_url is required for jira")
+	}
+	if err := validateIssueBaseURL(req.BaseURL); err != nil {
+		return err
+	}
+	if err := validateIssueCredentials(req.Provider, req.Credentials); err != nil {
+		return err
+	}
+
+	syncMode := models.IssueSyncModeCreate
+	if req.SyncMode != "" {
+		if !validIssueSyncModes[req.SyncMode] {
+			return fiber.NewError(fiber.StatusBadRequest, "sync_mode must be one of create, update")
+		}
+		syncMode = req.SyncMode
+	}
+	triggerOn := models.PostActionTriggerOnAny
+	if req.TriggerOn != "" {
+		if !validTriggerOnValues[req.TriggerOn] {
+			return fiber.NewError(fiber.StatusBadRequest, "trigger_on must be one of success, failure, any")
+		}
+		triggerOn = req.TriggerOn
+	}
+	issueType := "Task"
+	if req.IssueType != "" {
+		issueType = req.IssueType
+	}
+	commandPrefix := "/agentcrew"
+	if req.CommandPrefix != "" {
+		commandPrefix = req.CommandPrefix
+	}
+	syncResults := true
+	if req.SyncResults != nil {
+		syncResults = *req.SyncResults
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", req.TeamID).Error; err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "team_id references a non-existent team")
+	}
+
+	labelsJSON, err := json.Marshal(req.Labels)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid labels format")
+	}
+	credsJSON, err := encryptAuthConfig(req.Credentials)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to process credentials")
+	}
+
+	token, hash, prefix, err := generateWebhookToken()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to generate inbound token")
+	}
+
+	integration := models.IssueIntegration{
+		ID:                 uuid.New().String(),
+		OrgID:              GetOrgID(c),
+		Name:               req.Name,
+		TeamID:             req.TeamID,
+		Provider:           req.Provider,
+		BaseURL:            req.BaseURL,
+		Project:            req.Project,
+		IssueType:          issueType,
+		Labels:             models.JSON(labelsJSON),
+		Credentials:        models.JSON(credsJSON),
+		SyncResults:        syncResults,
+		SyncMode:           syncMode,
+		TriggerOn:          triggerOn,
+		CommandPrefix:      commandPrefix,
+		InboundTokenHash:   hash,
+		InboundTokenPrefix: prefix,
+		Enabled:            enabled,
+	}
+
+	if err := s.db.Create(&integration).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create issue integration")
+	}
+	// GORM skips zero values that have a default, so persist explicit false.
+	if !syncResults || !enabled {
+		s.db.Model(&integration).Updates(map[string]interface{}{
+			"sync_results": syncResults,
+			"enabled":      enabled,
+		})
+	}
+
+	s.db.Preload("Team").First(&integration, "id = ?", integration.ID)
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"integration": integration,
+		"token":       token,
+	})
+}
+
+// UpdateIssueIntegration updates an issue tracker integration's fields.
+func (s *Server) UpdateIssueIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.IssueIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "issue integration not found")
+	}
+
+	var req UpdateIssueIntegrationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "name cannot be empty")
+		}
+		if len(*req.Name) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.BaseURL != nil {
+		if *req.BaseURL == "" && integration.Provider == models.IssueProviderJira {
+			return fiber.NewError(fiber.StatusBadRequest, "base_url is required for jira")
+		}
+		if err := validateIssueBaseURL(*req.BaseURL); err != nil {
+			return err
+		}
+		updates["base_url"] = *req.BaseURL
+	}
+	if req.Project != nil {
+		if *req.Project == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "project cannot be empty")
+		}
+		updates["project"] = *req.Project
+	}
+	if req.IssueType != nil {
+		updates["issue_type"] = *req.IssueType
+	}
+	if req.Labels != nil {
+		labelsJSON, err := json.Marshal(*req.Labels)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid labels format")
+		}
+		updates["labels"] = models.JSON(labelsJSON)
+	}
+	if req.Credentials != nil {
+		if err := validateIssueCredentials(integration.Provider, *req.Credentials); err != nil {
+			return err
+		}
+		credsJSON, err := encryptAuthConfig(*req.Credentials)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to process credentials")
+		}
+		updates["credentials"] = models.JSON(credsJSON)
+	}
+	if req.SyncResults != nil {
+		updates["sync_results"] = *req.SyncResults
+	}
+	if req.SyncMode != nil {
+		if !validIssueSyncModes[*req.SyncMode] {
+			return fiber.NewError(fiber.StatusBadRequest, "sync_mode must be one of create, update")
+		}
+		updates["sync_mode"] = *req.SyncMode
+	}
+	if req.TriggerOn != nil {
+		if !validTriggerOnValues[*req.TriggerOn] {
+			return fiber.NewError(fiber.StatusBadRequest, "trigger_on must be one of success, failure, any")
+		}
+		updates["trigger_on"] = *req.TriggerOn
+	}
+	if req.CommandPrefix != nil {
+		if *req.CommandPrefix == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "command_prefix cannot be empty")
+		}
+		updates["command_prefix"] = *req.CommandPrefix
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&integration).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update issue integration")
+		}
+	}
+
+	s.db.Preload("Team").First(&integration, "id = ?", id)
+	return c.JSON(integration)
+}
+
+// DeleteIssueIntegration removes an issue tracker integration and its issue links.
+func (s *Server) DeleteIssueIntegration(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.IssueIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "issue integration not found")
+	}
+
+	s.db.Where("integration_id = ?", id).Delete(&models.IssueLink{})
+	if err := s.db.Delete(&integration).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete issue integration")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RegenerateIssueIntegrationToken generates a new inbound token.
+func (s *Server) RegenerateIssueIntegrationToken(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var integration models.IssueIntegration
+	if err := s.db.Scopes(OrgScope(c)).First(&integration, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "issue integration not found")
+	}
+
+	token, hash, prefix, err := generateWebhookToken()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to generate inbound token")
+	}
+
+	if err := s.db.Model(&integration).Updates(map[string]interface{}{
+		"inbound_token_hash":   hash,
+		"inbound_token_prefix": prefix,
+	}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to update inbound token")
+	}
+
+	s.db.Preload("Team").First(&integration, "id = ?", id)
+	return c.JSON(fiber.Map{
+		"integration": integration,
+		"token":       token,
+	})
+}
+
+// ReceiveIssueEvent handles POST /integrations/issues/:token — a tracker
+// webhook for new comments. Comments starting with the integration's command
+// prefix start a run on the team; the result is posted back as a comment.
+func (s *Server) ReceiveIssueEvent(c *fiber.Ctx) error {
+	token := c.Params("token")
+	if token == "" {
+		return newAPIError(CodeAuthMissing, "missing token")
+	}
+
+	h := sha256.Sum256([]byte(token))
+	tokenHash := hex.EncodeToString(h[:])
+
+	var integration models.IssueIntegration
+	if err := s.db.First(&integration, "inbound_token_hash = ?", tokenHash).Error; err != nil {
+		return newAPIError(CodeAuthInvalid, "invalid token")
+	}
+	if !integration.Enabled {
+		return fiber.NewError(fiber.StatusForbidden, "issue integration is disabled")
+	}
+
+	event, ok, err := issues.ParseCommentEvent(integration.Provider, c.Body())
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if !ok {
+		return c.JSON(fiber.Map{"status": "ignored", "reason": "not a new comment"})
+	}
+	command, ok := event.Command(integration.CommandPrefix)
+	if !ok {
+		return c.JSON(fiber.Map{"status": "ignored", "reason": "comment does not start with command prefix"})
+	}
+
+	prompt := event.Prompt(command)
+	if len(prompt) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt exceeds maximum length of %d characters", maxPromptLength))
+	}
+
+	var team models.Team
+	if err := s.db.First(&team, "id = ?", integration.TeamID).Error; err != nil {
+		return fiber.NewError(fiber.StatusConflict, "team not found")
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	if err := s.checkOrgMaintenance(team.OrgID); err != nil {
+		return err
+	}
+	// Input content policies apply as they do to chat messages.
+	if err := s.CheckPromptPolicies(c.Context(), team, "", prompt); err != nil {
+		return err
+	}
+
+	runID := uuid.New().String()
+	s.executeIssueRunAsync(integration, event, team, prompt, runID)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"run_id": runID,
+		"status": "running",
+	})
+}
+
+// executeIssueRunAsync sends a comment-triggered prompt to the team leader in
+// a background goroutine and replies on the originating issue with the result.
+func (s *Server) executeIssueRunAsync(integration models.IssueIntegration, event issues.CommentEvent, team models.Team, prompt, runID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), issueRunTimeout)
+		defer cancel()
+
+		started := time.Now()
+		slog.Info("issue comment run started",
+			"integration_id", integration.ID, "issue", event.IssueRef, "run_id", runID)
+
+		responseText, err := s.sendWebhookPromptAndWait(ctx, team, prompt, runID)
+
+		result := issues.RunResult{
+			SourceType: "issue",
+			TriggerID:  integration.ID,
+			RunID:      runID,
+			TeamID:     team.ID,
+			TeamName:   team.Name,
+			Prompt:     prompt,
+			StartedAt:  started,
+			FinishedAt: time.Now(),
+		}
+		switch {
+		case err == nil:
+			result.Status = models.WebhookRunStatusSuccess
+			result.Response = responseText
+		case ctx.Err() == context.DeadlineExceeded:
+			result.Status = models.WebhookRunStatusTimeout
+			result.Error = fmt.Sprintf("execution timed out after %s", issueRunTimeout)
+		default:
+			result.Status = models.WebhookRunStatusFailed
+			result.Error = err.Error()
+		}
+
+		s.issueNotifier.ReplyToComment(integration, event.IssueKey, result)
+	}()
+}
+
+// validateIssueBaseURL checks that a tracker base URL, if set, is absolute.
+func validateIssueBaseURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if u, err := url.ParseRequestURI(raw); err != nil || u.Host == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "base_url is not a valid URL")
+	}
+	return nil
+}
+
+// validateIssueCredentials checks that the provider's required credential keys are present.
+func validateIssueCredentials(provider string, creds map[string]string) error {
+	switch provider {
+	case models.IssueProviderJira:
+		if creds["email"] == "" || creds["api_token"] == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "jira credentials require 'email' and 'api_token'")
+		}
+	case models.IssueProviderLinear:
+		if creds["api_key"] == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "linear credentials require 'api_key'")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+type issueIntegrationCreated struct {
+	Integration models.IssueIntegration `json:"integration"`
+	Token       string                  `json:"token"`
+}
+
+func createIssueIntegration(t *testing.T, srv *Server, teamID string) issueIntegrationCreated {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/issue-integrations", CreateIssueIntegrationRequest{
+		Name:        "ops-jira",
+		TeamID:      teamID,
+		Provider:    models.IssueProviderJira,
+		BaseURL:     "https://example.atlassian.net",
+		Project:     "OPS",
+		Labels:      []string{"agentcrew"},
+		Credentials: map[string]string{"email": "bot@example.com", "api_token": "secret"},
+		SyncMode:    models.IssueSyncModeUpdate,
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var created issueIntegrationCreated
+	parseJSON(t, rec, &created)
+	return created
+}
+
+// postIssueEvent posts a raw tracker payload to the inbound endpoint.
+func postIssueEvent(srv *Server, token, body string) (int, string) {
+	req := httptest.NewRequest("POST", "/integrations/issues/"+token, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := srv.App.Test(req, -1)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, string(data)
+}
+
+func TestIssueIntegrationCRUD(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "issue-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	created := createIssueIntegration(t, srv, team.ID)
+	if !strings.HasPrefix(created.Token, "whk_") {
+		t.Errorf("token: got %q", created.Token)
+	}
+	if created.Integration.CommandPrefix != "/agentcrew" || created.Integration.TriggerOn != "any" {
+		t.Errorf("defaults: got prefix %q trigger_on %q", created.Integration.CommandPrefix, created.Integration.TriggerOn)
+	}
+
+	rec := doRequest(srv, "GET", "/api/issue-integrations/"+created.Integration.ID, nil)
+	if rec.Code != 200 {
+		t.Fatalf("get: got %d, want 200", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "secret") {
+		t.Error("credentials must not be returned by the API")
+	}
+
+	onFailure := "failure"
+	rec = doRequest(srv, "PUT", "/api/issue-integrations/"+created.Integration.ID, UpdateIssueIntegrationRequest{TriggerOn: &onFailure})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var updated models.IssueIntegration
+	parseJSON(t, rec, &updated)
+	if updated.TriggerOn != "failure" {
+		t.Errorf("trigger_on: got %q, want failure", updated.TriggerOn)
+	}
+
+	rec = doRequest(srv, "DELETE", "/api/issue-integrations/"+created.Integration.ID, nil)
+	if rec.Code != 204 {
+		t.Fatalf("delete: got %d, want 204", rec.Code)
+	}
+}
+
+func TestCreateIssueIntegration_Validation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "issue-team-validation"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	jiraCreds := map[string]string{"email": "a@b.c", "api_token": "t"}
+	tests := []struct {
+		name string
+		body CreateIssueIntegrationRequest
+	}{
+		{"unknown provider", CreateIssueIntegrationRequest{Name: "x", TeamID: team.ID, Provider: "github", Project: "P"}},
+		{"jira without base_url", CreateIssueIntegrationRequest{Name: "x", TeamID: team.ID, Provider: "jira", Project: "P", Credentials: jiraCreds}},
+		{"jira missing credentials", CreateIssueIntegrationRequest{Name: "x", TeamID: team.ID, Provider: "jira", Project: "P", BaseURL: "https://x.atlassian.net"}},
+		{"linear missing api_key", CreateIssueIntegrationRequest{Name: "x", TeamID: team.ID, Provider: "linear", Project: "P"}},
+		{"invalid sync_mode", CreateIssueIntegrationRequest{Name: "x", TeamID: team.ID, Provider: "linear", Project: "P", Credentials: map[string]string{"api_key": "k"}, SyncMode: "merge"}},
+		{"unknown team", CreateIssueIntegrationRequest{Name: "x", TeamID: "missing", Provider: "linear", Project: "P", Credentials: map[string]string{"api_key": "k"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(srv, "POST", "/api/issue-integrations", tt.body)
+			if rec.Code != 400 {
+				t.Errorf("got %d, want 400\nbody: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestReceiveIssueEvent(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "issue-team-inbound"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	created := createIssueIntegration(t, srv, team.ID)
+
+	commentEvent := `{"webhookEvent":"comment_created","issue":{"key":"OPS-3","fields":{"summary":"x"}},"comment":{"body":"%s"}}`
+
+	if code, _ := postIssueEvent(srv, "whk_invalid", `{}`); code != 401 {
+		t.Errorf("invalid token: got %d, want 401", code)
+	}
+
+	code, body := postIssueEvent(srv, created.Token, strings.Replace(commentEvent, "%s", "thanks!", 1))
+	if code != 200 || !strings.Contains(body, "ignored") {
+		t.Errorf("comment without prefix: got %d %s", code, body)
+	}
+
+	code, _ = postIssueEvent(srv, created.Token, strings.Replace(commentEvent, "%s", "/agentcrew investigate", 1))
+	if code != 409 {
+		t.Errorf("team not running: got %d, want 409", code)
+	}
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -46,7 +47,7 @@ func (s *Server) GetKnowledgeStatus(c *fiber.Ctx) error {
 	}
 
 	// Check Qdrant status.
-	if qm, ok := s.runtime.(runtime.QdrantManager); ok {
+	if qm, ok := s.sharedRuntime().(runtime.QdrantManager); ok {
 		running, err := qm.IsQdrantRunning(c.Context())
 		if err != nil {
 			slog.Error("failed to check qdrant status", "error", err)
@@ -76,12 +77,17 @@ func (s *Server) GetKnowledgeStatus(c *fiber.Ctx) error {
 	return c.JSON(resp)
 }
 
-// ListDocuments returns all documents for the current organization.
+// ListDocuments returns all documents for the current organization, or
+// those of one team with ?team_id=.
 func (s *Server) ListDocuments(c *fiber.Ctx) error {
 	orgID := GetOrgID(c)
 
+	query := s.db.Where("org_id = ?", orgID)
+	if teamID := c.Query("team_id"); teamID != "" {
+		query = query.Where("team_id = ?", teamID)
+	}
 	var docs []models.Document
-	if err := s.db.Where("org_id = ?", orgID).Order("created_at DESC").Find(&docs).Error; err != nil {
+	if err := query.Order("created_at DESC").Find(&docs).Error; err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to list documents")
 	}
 
@@ -89,9 +95,19 @@ func (s *Server) ListDocuments(c *fiber.Ctx) error {
 }
 
 // UploadDocument handles multipart file upload for knowledge base documents.
+// With a team_id form field the document goes to that team's knowledge
+// base instead of the organization's.
 func (s *Server) UploadDocument(c *fiber.Ctx) error {
 	orgID := GetOrgID(c)
 
+	teamID := c.FormValue("team_id")
+	if teamID != "" {
+		var team models.Team
+		if err := s.db.Scopes(OrgScope(c)).Select("id").First(&team, "id = ?", teamID).Error; err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "team_id references a non-existent team")
+		}
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "file is required")
@@ -160,6 +176,7 @@ func (s *Server) UploadDocument(c *fiber.Ctx) error {
 	doc := models.Document{
 		ID:          docID,
 		OrgID:       orgID,
+		TeamID:      teamID,
 		Name:        displayName,
 		FileName:    sanitizedName,
 		FileSize:    file.Size,
@@ -207,10 +224,10 @@ func (s *Server) DeleteDocument(c *fiber.Ctx) error {
 	}
 
 	// Delete vectors from Qdrant (best effort — Qdrant may not be running).
-	if qm, ok := s.runtime.(runtime.QdrantManager); ok {
+	if qm, ok := s.sharedRuntime().(runtime.QdrantManager); ok {
 		running, _ := qm.IsQdrantRunning(c.Context())
 		if running {
-			collection := rag.CollectionName(orgID)
+			collection := rag.DocumentCollection(doc)
 			qdrantClient := rag.NewQdrantClient(runtime.QdrantInternalURL)
 			if err := qdrantClient.DeleteByDocID(c.Context(), collection, docID); err != nil {
 				slog.Error("failed to delete vectors from qdrant", "doc_id", docID, "error", err)
@@ -239,76 +256,18 @@ func (s *Server) DeleteDocument(c *fiber.Ctx) error {
 func (s *Server) processDocumentAsync(doc models.Document) {
 	ctx := context.Background()
 
-	// Ensure Qdrant is running.
-	if qm, ok := s.runtime.(runtime.QdrantManager); ok {
-		s.db.Model(&doc).Update("status_message", "Starting Qdrant...")
-		if _, err := qm.EnsureQdrant(ctx); err != nil {
-			slog.Error("failed to ensure qdrant for document processing", "doc_id", doc.ID, "error", err)
-			s.db.Model(&doc).Updates(map[string]interface{}{
-				"status":         models.DocStatusError,
-				"status_message": "Failed to start Qdrant: " + err.Error(),
-			})
-			return
-		}
-
-		// Connect Qdrant to the knowledge network.
-		if err := ensureKnowledgeNetwork(ctx, s.runtime); err != nil {
-			slog.Error("failed to ensure knowledge network", "error", err)
-		}
-		if err := qm.ConnectQdrantToNetwork(ctx, KnowledgeNetworkName); err != nil {
-			slog.Error("failed to connect qdrant to knowledge network", "error", err)
-		}
-	} else {
-		slog.Error("runtime does not support QdrantManager", "doc_id", doc.ID)
-		s.db.Model(&doc).Updates(map[string]interface{}{
-			"status":         models.DocStatusError,
-			"status_message": "Runtime does not support Qdrant",
-		})
-		return
-	}
-
-	// Ensure Ollama is running and pull embedding model.
-	if om, ok := s.runtime.(runtime.OllamaManager); ok {
-		s.db.Model(&doc).Update("status_message", "Starting Ollama...")
-		if _, err := om.EnsureOllama(ctx); err != nil {
-			slog.Error("failed to ensure ollama for document processing", "doc_id", doc.ID, "error", err)
-			s.db.Model(&doc).Updates(map[string]interface{}{
-				"status":         models.DocStatusError,
-				"status_message": "Failed to start Ollama: " + err.Error(),
-			})
-			return
-		}
-
-		// Connect Ollama to the knowledge network.
-		if err := om.ConnectOllamaToNetwork(ctx, KnowledgeNetworkName); err != nil {
-			slog.Error("failed to connect ollama to knowledge network", "error", err)
-		}
-
-		s.db.Model(&doc).Update("status_message", "Pulling embedding model...")
-		if err := om.PullOllamaModel(ctx, "nomic-embed-text", func(status string) {
-			s.db.Model(&doc).Update("status_message", "Pulling model: "+status)
-		}); err != nil {
-			slog.Error("failed to pull embedding model", "doc_id", doc.ID, "error", err)
-			s.db.Model(&doc).Updates(map[string]interface{}{
-				"status":         models.DocStatusError,
-				"status_message": "Failed to pull embedding model: " + err.Error(),
-			})
-			return
-		}
-	} else {
-		slog.Error("runtime does not support OllamaManager", "doc_id", doc.ID)
+	processor, err := s.knowledgeProcessor(ctx, func(status string) {
+		s.db.Model(&doc).Update("status_message", status)
+	})
+	if err != nil {
+		slog.Error("failed to prepare knowledge base for document processing", "doc_id", doc.ID, "error", err)
 		s.db.Model(&doc).Updates(map[string]interface{}{
 			"status":         models.DocStatusError,
-			"status_message": "Runtime does not support Ollama",
+			"status_message": "Failed to prepare knowledge base: " + err.Error(),
 		})
 		return
 	}
 
-	// Create RAG processor and process the document.
-	qdrantClient := rag.NewQdrantClient(runtime.QdrantInternalURL)
-	embedder := rag.NewOllamaEmbedder(runtime.OllamaInternalURL, "nomic-embed-text")
-	processor := rag.NewProcessor(s.db, qdrantClient, embedder)
-
 	if err := processor.ProcessDocument(ctx, doc); err != nil {
 		slog.Error("document processing failed", "doc_id", doc.ID, "error", err)
 		// Error status is set by the processor itself.
@@ -318,6 +277,50 @@ func (s *Server) processDocumentAsync(doc models.Document) {
 	slog.Info("document processed successfully", "doc_id", doc.ID, "name", doc.Name)
 }
 
+// knowledgeProcessor starts Qdrant and Ollama if needed, pulls the embedding
+// model and returns a RAG processor using them. progress receives status
+// updates while the services start.
+func (s *Server) knowledgeProcessor(ctx context.Context, progress func(status string)) (*rag.Processor, error) {
+	qm, ok := s.sharedRuntime().(runtime.QdrantManager)
+	if !ok {
+		return nil, errors.New("runtime does not support Qdrant")
+	}
+	progress("Starting Qdrant...")
+	if _, err := qm.EnsureQdrant(ctx); err != nil {
+		return nil, fmt.Errorf("starting Qdrant: %w", err)
+	}
+	// Connect Qdrant to the knowledge network.
+	if err := ensureKnowledgeNetwork(ctx, s.sharedRuntime()); err != nil {
+		slog.Error("failed to ensure knowledge network", "error", err)
+	}
+	if err := qm.ConnectQdrantToNetwork(ctx, KnowledgeNetworkName); err != nil {
+		slog.Error("failed to connect qdrant to knowledge network", "error", err)
+	}
+
+	om, ok := s.sharedRuntime().(runtime.OllamaManager)
+	if !ok {
+		return nil, errors.New("runtime does not support Ollama")
+	}
+	progress("Starting Ollama...")
+	if _, err := om.EnsureOllama(ctx); err != nil {
+		return nil, fmt.Errorf("starting Ollama: %w", err)
+	}
+	// Connect Ollama to the knowledge network.
+	if err := om.ConnectOllamaToNetwork(ctx, KnowledgeNetworkName); err != nil {
+		slog.Error("failed to connect ollama to knowledge network", "error", err)
+	}
+	progress("Pulling embedding model...")
+	if err := om.PullOllamaModel(ctx, "nomic-embed-text", func(status string) {
+		progress("Pulling model: " + status)
+	}); err != nil {
+		return nil, fmt.Errorf("pulling embedding model: %w", err)
+	}
+
+	qdrantClient := rag.NewQdrantClient(runtime.QdrantInternalURL)
+	embedder := rag.NewOllamaEmbedder(runtime.OllamaInternalURL, "nomic-embed-text")
+	return rag.NewProcessor(s.db, qdrantClient, embedder), nil
+}
+
 // ensureKnowledgeNetwork creates the dedicated Docker network for RAG infra
 // and connects the API container to it so it can reach Qdrant/Ollama via DNS.
 func ensureKnowledgeNetwork(ctx context.Context, rt runtime.AgentRuntime) error {
@@ -15,12 +15,14 @@ import (
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
-// GetMcpConfig reads the MCP config file from a running team's leader container.
+// GetMcpConfig reads the MCP config file from a running team's leader
+// container. Observers get it with the servers' env and headers masked, as
+// they often hold credentials.
 func (s *Server) GetMcpConfig(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	if team.Status != models.TeamStatusRunning {
@@ -28,8 +30,12 @@ func (s *Server) GetMcpConfig(c *fiber.Ctx) error {
 		if len(team.McpServers) == 0 || string(team.McpServers) == "null" || string(team.McpServers) == "[]" {
 			return c.JSON(McpConfigResponse{Content: "[]", Path: "", Provider: team.Provider})
 		}
+		content := string(team.McpServers)
+		if IsObserver(c) {
+			content = maskMcpSecrets(content)
+		}
 		return c.JSON(McpConfigResponse{
-			Content:  string(team.McpServers),
+			Content:  content,
 			Path:     "",
 			Provider: team.Provider,
 		})
@@ -44,7 +50,7 @@ func (s *Server) GetMcpConfig(c *fiber.Ctx) error {
 		}
 	}
 	if leader == nil || leader.ContainerID == "" {
-		return fiber.NewError(fiber.StatusNotFound, "leader container not found")
+		return newAPIError(CodeLeaderNotFound, "leader container not found")
 	}
 
 	// Determine config file path based on provider.
@@ -68,24 +74,66 @@ func (s *Server) GetMcpConfig(c *fiber.Ctx) error {
 		})
 	}
 
+	content := strings.TrimSpace(output)
+	if IsObserver(c) {
+		content = maskMcpSecrets(content)
+	}
 	return c.JSON(McpConfigResponse{
-		Content:  strings.TrimSpace(output),
+		Content:  content,
 		Path:     configPath,
 		Provider: team.Provider,
 	})
 }
 
+// mcpSecretKeys are the keys of an MCP config, in any of the formats it is
+// stored or written in, whose values are masked for observers.
+var mcpSecretKeys = map[string]bool{"env": true, "environment": true, "headers": true}
+
+// maskMcpSecrets masks the values of every env and headers object in an MCP
+// config. Content that is not JSON is withheld entirely.
+func maskMcpSecrets(content string) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		return ""
+	}
+	var mask func(v interface{})
+	mask = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for k, val := range v {
+				if vars, ok := val.(map[string]interface{}); ok && mcpSecretKeys[k] {
+					for name := range vars {
+						vars[name] = maskedValue
+					}
+					continue
+				}
+				mask(val)
+			}
+		case []interface{}:
+			for _, val := range v {
+				mask(val)
+			}
+		}
+	}
+	mask(v)
+	masked, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(masked)
+}
+
 // UpdateMcpConfig writes raw JSON to the MCP config file in a running container.
 func (s *Server) UpdateMcpConfig(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var req UpdateMcpConfigRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	// Validate that content is valid JSON.
@@ -113,7 +161,7 @@ func (s *Server) UpdateMcpConfig(c *fiber.Ctx) error {
 		}
 	}
 	if leader == nil || leader.ContainerID == "" {
-		return fiber.NewError(fiber.StatusNotFound, "leader container not found")
+		return newAPIError(CodeLeaderNotFound, "leader container not found")
 	}
 
 	var configPath string
@@ -140,12 +188,12 @@ func (s *Server) AddMcpServer(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var req AddMcpServerRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	// Validate via the common validator.
@@ -204,7 +252,7 @@ func (s *Server) RemoveMcpServer(c *fiber.Ctx) error {
 
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var existing []protocol.McpServerConfig
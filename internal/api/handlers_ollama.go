@@ -28,7 +28,7 @@ func (s *Server) GetOllamaStatus(c *fiber.Ctx) error {
 	}
 
 	// Check if the container is actually running (no ref counting needed).
-	if om, ok := s.runtime.(runtime.OllamaManager); ok {
+	if om, ok := s.sharedRuntime().(runtime.OllamaManager); ok {
 		running, err := om.IsOllamaRunning(c.Context())
 		if err != nil {
 			slog.Error("failed to check ollama status", "error", err)
@@ -34,12 +34,12 @@ func (s *Server) UpdateOrg(c *fiber.Ctx) error {
 	orgID := GetOrgID(c)
 
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can update the organization")
+		return newAPIError(CodeAdminRequired, "only admins can update the organization")
 	}
 
 	var req UpdateOrgRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 	if req.Name == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "name is required")
@@ -97,7 +97,7 @@ func (s *Server) RemoveMember(c *fiber.Ctx) error {
 	targetID := c.Params("id")
 
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can remove members")
+		return newAPIError(CodeAdminRequired, "only admins can remove members")
 	}
 
 	if targetID == userID {
@@ -128,14 +128,14 @@ type CreateInviteRequest struct {
 // CreateInvite creates a new invite for the organization (admin only).
 func (s *Server) CreateInvite(c *fiber.Ctx) error {
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can create invites")
+		return newAPIError(CodeAdminRequired, "only admins can create invites")
 	}
 
 	orgID := GetOrgID(c)
 
 	var req CreateInviteRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
 
@@ -194,7 +194,7 @@ func (s *Server) CreateInvite(c *fiber.Ctx) error {
 // ListInvites returns all invites for the organization (admin only).
 func (s *Server) ListInvites(c *fiber.Ctx) error {
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can list invites")
+		return newAPIError(CodeAdminRequired, "only admins can list invites")
 	}
 
 	orgID := GetOrgID(c)
@@ -236,7 +236,7 @@ func (s *Server) ListInvites(c *fiber.Ctx) error {
 // DeleteInvite removes an invite (admin only).
 func (s *Server) DeleteInvite(c *fiber.Ctx) error {
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can delete invites")
+		return newAPIError(CodeAdminRequired, "only admins can delete invites")
 	}
 
 	orgID := GetOrgID(c)
@@ -265,15 +265,17 @@ func (s *Server) UpdateMemberRole(c *fiber.Ctx) error {
 	targetID := c.Params("id")
 
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can change member roles")
+		return newAPIError(CodeAdminRequired, "only admins can change member roles")
 	}
 
 	var req UpdateMemberRoleRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
-	if req.Role != models.UserRoleAdmin && req.Role != models.UserRoleMember {
-		return fiber.NewError(fiber.StatusBadRequest, "role must be 'admin' or 'member'")
+	switch req.Role {
+	case models.UserRoleAdmin, models.UserRoleMember, models.UserRoleObserver:
+	default:
+		return fiber.NewError(fiber.StatusBadRequest, "role must be 'admin', 'member' or 'observer'")
 	}
 
 	var target models.User
@@ -282,12 +284,12 @@ func (s *Server) UpdateMemberRole(c *fiber.Ctx) error {
 	}
 
 	// Cannot downgrade the owner.
-	if target.IsOwner && req.Role == models.UserRoleMember {
+	if target.IsOwner && req.Role != models.UserRoleAdmin {
 		return fiber.NewError(fiber.StatusForbidden, "cannot change the owner's role")
 	}
 
 	// Cannot downgrade if this is the last admin.
-	if target.Role == models.UserRoleAdmin && req.Role == models.UserRoleMember {
+	if target.Role == models.UserRoleAdmin && req.Role != models.UserRoleAdmin {
 		var adminCount int64
 		s.db.Model(&models.User{}).Where("org_id = ? AND role = ?", orgID, models.UserRoleAdmin).Count(&adminCount)
 		if adminCount <= 1 {
@@ -316,7 +318,7 @@ func (s *Server) ResetMemberPassword(c *fiber.Ctx) error {
 	targetID := c.Params("id")
 
 	if !IsAdmin(c) {
-		return fiber.NewError(fiber.StatusForbidden, "only admins can reset passwords")
+		return newAPIError(CodeAdminRequired, "only admins can reset passwords")
 	}
 
 	if targetID == userID {
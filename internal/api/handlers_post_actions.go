@@ -72,7 +72,7 @@ func (s *Server) ListPostActions(c *fiber.Ctx) error {
 func (s *Server) CreatePostAction(c *fiber.Ctx) error {
 	var req CreatePostActionRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	// Validate required fields.
@@ -202,7 +202,7 @@ func (s *Server) UpdatePostAction(c *fiber.Ctx) error {
 
 	var req UpdatePostActionRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -310,7 +310,7 @@ func (s *Server) CreateBinding(c *fiber.Ctx) error {
 
 	var req CreateBindingRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	// Validate trigger_type.
@@ -386,7 +386,7 @@ func (s *Server) UpdateBinding(c *fiber.Ctx) error {
 
 	var req UpdateBindingRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// maxPromptLength is the maximum number of characters allowed in a prompt or
+// prompt template, for templates, schedules, webhooks and evaluations alike.
+const maxPromptLength = 50000
+
+// ListPromptTemplates returns all prompt templates for the organization.
+func (s *Server) ListPromptTemplates(c *fiber.Ctx) error {
+	var templates []models.PromptTemplate
+	if err := s.db.Scopes(OrgScope(c)).Order("name ASC").Find(&templates).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list prompt templates")
+	}
+	return c.JSON(templates)
+}
+
+// GetPromptTemplate returns a single prompt template by ID.
+func (s *Server) GetPromptTemplate(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var tmpl models.PromptTemplate
+	if err := s.db.Scopes(OrgScope(c)).First(&tmpl, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "prompt template not found")
+	}
+	return c.JSON(tmpl)
+}
+
+// CreatePromptTemplate creates a new prompt template.
+func (s *Server) CreatePromptTemplate(c *fiber.Ctx) error {
+	var req CreatePromptTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	if req.Name == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "name is required")
+	}
+	if len(req.Name) > 255 {
+		return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+	}
+	if req.Content == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "content is required")
+	}
+	if len(req.Content) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("content exceeds maximum length of %d characters", maxPromptLength))
+	}
+
+	tmpl := models.PromptTemplate{
+		ID:          uuid.New().String(),
+		OrgID:       GetOrgID(c),
+		Name:        req.Name,
+		Description: req.Description,
+		Content:     req.Content,
+	}
+
+	if err := s.db.Create(&tmpl).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create prompt template")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(tmpl)
+}
+
+// UpdatePromptTemplate updates a prompt template's fields. Schedules that
+// reference the template pick up the new content on their next run.
+func (s *Server) UpdatePromptTemplate(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var tmpl models.PromptTemplate
+	if err := s.db.Scopes(OrgScope(c)).First(&tmpl, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "prompt template not found")
+	}
+
+	var req UpdatePromptTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "name cannot be empty")
+		}
+		if len(*req.Name) > 255 {
+			return fiber.NewError(fiber.StatusBadRequest, "name must be at most 255 characters")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Content != nil {
+		if *req.Content == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "content cannot be empty")
+		}
+		if len(*req.Content) > maxPromptLength {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("content exceeds maximum length of %d characters", maxPromptLength))
+		}
+		updates["content"] = *req.Content
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&tmpl).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update prompt template")
+		}
+	}
+
+	s.db.First(&tmpl, "id = ?", id)
+	return c.JSON(tmpl)
+}
+
+// DeletePromptTemplate removes a prompt template. Schedules that referenced it
+// are detached and keep running with their last rendered prompt.
+func (s *Server) DeletePromptTemplate(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var tmpl models.PromptTemplate
+	if err := s.db.Scopes(OrgScope(c)).First(&tmpl, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "prompt template not found")
+	}
+
+	s.db.Model(&models.Schedule{}).Where("prompt_template_id = ?", id).
+		Update("prompt_template_id", nil)
+
+	if err := s.db.Delete(&tmpl).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete prompt template")
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// renderPromptTemplateByID loads an org-scoped prompt template and renders it
+// with the given variables. field names the request field holding the ID and
+// is used in error messages, which are fiber errors ready to return from a
+// handler.
+func (s *Server) renderPromptTemplateByID(c *fiber.Ctx, field, id string, vars map[string]string) (string, error) {
+	var tmpl models.PromptTemplate
+	if err := s.db.Scopes(OrgScope(c)).First(&tmpl, "id = ?", id).Error; err != nil {
+		return "", fiber.NewError(fiber.StatusBadRequest, field+" references a non-existent prompt template")
+	}
+	rendered := tmpl.Render(vars)
+	if len(rendered) > maxPromptLength {
+		return "", fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("rendered prompt exceeds maximum length of %d characters", maxPromptLength))
+	}
+	return rendered, nil
+}
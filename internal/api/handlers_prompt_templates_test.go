@@ -0,0 +1,174 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func createPromptTemplate(t *testing.T, srv *Server, name, content string) models.PromptTemplate {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/prompt-templates", CreatePromptTemplateRequest{
+		Name:    name,
+		Content: content,
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create template: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var tmpl models.PromptTemplate
+	parseJSON(t, rec, &tmpl)
+	return tmpl
+}
+
+func TestPromptTemplateCRUD(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	tmpl := createPromptTemplate(t, srv, "triage", "Triage issue {{issue}} in {{repo}}")
+	if tmpl.ID == "" {
+		t.Fatal("expected non-empty ID")
+	}
+
+	rec := doRequest(srv, "GET", "/api/prompt-templates", nil)
+	var list []models.PromptTemplate
+	parseJSON(t, rec, &list)
+	if len(list) != 1 {
+		t.Fatalf("list: got %d templates, want 1", len(list))
+	}
+
+	content := "Review {{pr}}"
+	rec = doRequest(srv, "PUT", "/api/prompt-templates/"+tmpl.ID, UpdatePromptTemplateRequest{Content: &content})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var updated models.PromptTemplate
+	parseJSON(t, rec, &updated)
+	if updated.Content != content {
+		t.Errorf("content: got %q, want %q", updated.Content, content)
+	}
+
+	rec = doRequest(srv, "DELETE", "/api/prompt-templates/"+tmpl.ID, nil)
+	if rec.Code != 204 {
+		t.Fatalf("delete: got %d, want 204", rec.Code)
+	}
+	rec = doRequest(srv, "GET", "/api/prompt-templates/"+tmpl.ID, nil)
+	if rec.Code != 404 {
+		t.Errorf("get after delete: got %d, want 404", rec.Code)
+	}
+}
+
+func TestCreatePromptTemplate_Validation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	tests := []struct {
+		name string
+		body CreatePromptTemplateRequest
+	}{
+		{"missing name", CreatePromptTemplateRequest{Content: "x"}},
+		{"missing content", CreatePromptTemplateRequest{Name: "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(srv, "POST", "/api/prompt-templates", tt.body)
+			if rec.Code != 400 {
+				t.Errorf("got %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestSendChat_WithTemplate(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "tmpl-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	tmpl := createPromptTemplate(t, srv, "greet", "Hello {{name}}")
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{
+		TemplateID: tmpl.ID,
+		Variables:  map[string]string{"name": "world"},
+	})
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	var taskLog models.TaskLog
+	if err := srv.db.Where("team_id = ? AND message_type = ?", team.ID, "user_message").First(&taskLog).Error; err != nil {
+		t.Fatalf("expected task log: %v", err)
+	}
+	if got := string(taskLog.Payload); got != `{"content":"Hello world"}` {
+		t.Errorf("payload: got %s", got)
+	}
+}
+
+func TestSendChat_UnknownTemplate(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "tmpl-team-404"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{TemplateID: "missing"})
+	if rec.Code != 400 {
+		t.Errorf("status: got %d, want 400", rec.Code)
+	}
+}
+
+func TestCreateSchedule_WithTemplate(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "sched-tmpl-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	tmpl := createPromptTemplate(t, srv, "report", "Report for {{env}}")
+
+	rec := doRequest(srv, "POST", "/api/schedules", CreateScheduleRequest{
+		Name:             "nightly",
+		TeamID:           team.ID,
+		PromptTemplateID: &tmpl.ID,
+		PromptVariables:  map[string]string{"env": "prod"},
+		CronExpression:   "0 0 * * *",
+	})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	var schedule models.Schedule
+	parseJSON(t, rec, &schedule)
+	if schedule.Prompt != "Report for prod" {
+		t.Errorf("prompt: got %q, want 'Report for prod'", schedule.Prompt)
+	}
+	if schedule.PromptTemplateID == nil || *schedule.PromptTemplateID != tmpl.ID {
+		t.Errorf("prompt_template_id: got %v, want %q", schedule.PromptTemplateID, tmpl.ID)
+	}
+
+	// Detach the template.
+	empty := ""
+	rec = doRequest(srv, "PUT", "/api/schedules/"+schedule.ID, UpdateScheduleRequest{PromptTemplateID: &empty})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &schedule)
+	if schedule.PromptTemplateID != nil {
+		t.Errorf("expected prompt_template_id to be cleared, got %q", *schedule.PromptTemplateID)
+	}
+}
+
+func TestPromptTemplateRender(t *testing.T) {
+	tmpl := models.PromptTemplate{Content: "{{a}} and {{b}} and {{c}}"}
+	got := tmpl.Render(map[string]string{"a": "1", "b": "2"})
+	if got != "1 and 2 and {{c}}" {
+		t.Errorf("got %q", got)
+	}
+
+	// Values are inserted as is, whatever order the variables come in.
+	tmpl = models.PromptTemplate{Content: "{{a}} then {{b}}"}
+	for i := 0; i < 20; i++ {
+		got := tmpl.Render(map[string]string{"a": "{{b}}", "b": "{{a}}"})
+		if got != "{{b}} then {{a}}" {
+			t.Fatalf("got %q", got)
+		}
+	}
+}
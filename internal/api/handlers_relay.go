@@ -3,6 +3,8 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"strings"
@@ -10,15 +12,32 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/segments"
 )
 
 // startTeamRelay starts a goroutine that subscribes to the team's NATS and
 // saves agent messages as TaskLogs in the DB. The StreamActivity WebSocket
 // handler polls the DB, so messages appear in the frontend automatically.
+// Only the owning replica relays (see AcquireOwnership), and only when relay
+// workers do not; otherwise this only forgets the leader's state, and the
+// owner or a relay worker picks the team up on its next sync.
 func (s *Server) startTeamRelay(teamID, teamName string) {
+	if s.sharedRelayState {
+		s.resetRelayState(teamID)
+	}
+	if !s.owner.Load() || s.externalRelays {
+		return
+	}
+	s.relayTeam(teamID, teamName)
+}
+
+// relayTeam starts the relay goroutine for a team, replacing any running.
+func (s *Server) relayTeam(teamID, teamName string) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	s.relaysMu.Lock()
@@ -26,32 +45,51 @@ func (s *Server) startTeamRelay(teamID, teamName string) {
 		existing()
 	}
 	s.relays[teamID] = cancel
+	delete(s.leaderReady, teamID)
+	delete(s.runQueues, teamID)
 	s.relaysMu.Unlock()
 
 	go func() {
 		defer func() {
+			// A cancelled relay was stopped or replaced, and whoever did so
+			// already updated the map.
 			s.relaysMu.Lock()
-			delete(s.relays, teamID)
+			if ctx.Err() == nil {
+				delete(s.relays, teamID)
+			}
 			s.relaysMu.Unlock()
 		}()
 		s.runTeamRelay(ctx, teamID, teamName)
 	}()
 }
 
-// stopTeamRelay cancels the relay goroutine for a team.
+// stopTeamRelay cancels the relay goroutine for a team and forgets the
+// leader's state.
 func (s *Server) stopTeamRelay(teamID string) {
+	s.cancelTeamRelay(teamID)
+	if s.sharedRelayState {
+		s.resetRelayState(teamID)
+	}
+}
+
+// cancelTeamRelay cancels the relay goroutine for a team. State shared in
+// the database is kept for whoever relays the team next.
+func (s *Server) cancelTeamRelay(teamID string) {
 	s.relaysMu.Lock()
 	defer s.relaysMu.Unlock()
 	if cancel, ok := s.relays[teamID]; ok {
 		cancel()
 		delete(s.relays, teamID)
 	}
+	delete(s.leaderReady, teamID)
+	delete(s.runQueues, teamID)
+	s.relayLags.forget(teamID)
 }
 
-// runTeamRelay connects to the team's NATS, subscribes to all team subjects,
-// and saves incoming agent messages as TaskLogs.
+// runTeamRelay connects to the team's NATS, reads all team subjects from the
+// team's JetStream stream, and saves incoming agent messages as TaskLogs.
 func (s *Server) runTeamRelay(ctx context.Context, teamID, teamName string) {
-	sanitized := SanitizeName(teamName)
+	sanitized := naming.Slug(teamName)
 
 	// Retry getting the NATS URL up to 5 times (team NATS may still be starting).
 	var natsURL string
@@ -92,73 +130,354 @@ func (s *Server) runTeamRelay(ctx context.Context, teamID, teamName string) {
 	defer nc.Close()
 
 	subject := "team." + sanitized + ".>"
-	sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
-		if err := s.processRelayMessage(teamID, teamName, msg.Data); err != nil {
-			slog.Error("relay: failed to process message", "team", teamName, "error", err)
+	stop, err := s.consumeTeamStream(ctx, nc, sanitized, subject, teamID, teamName)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		slog.Warn("relay: team stream unavailable, subscribing without replay", "team", teamName, "error", err)
+		sub, err := nc.Subscribe(subject, func(msg *nats.Msg) {
+			if err := s.processRelayMessage(teamID, teamName, msg.Data); err != nil {
+				s.recordDeadLetter(teamID, teamName, msg.Subject, msg.Data, err)
+			}
+		})
+		if err != nil {
+			slog.Error("relay: failed to subscribe to team NATS", "team", teamName, "error", err)
+			return
+		}
+		stop = func() { sub.Unsubscribe() }
+	}
+	defer stop()
+
+	// Custom tool calls are request/reply; each is answered in its own
+	// goroutine so a slow tool does not hold up the team's other calls. Up
+	// to maxToolInvocations run at once; calls beyond that are turned away
+	// at once rather than left to pile up.
+	toolsSubject, _ := protocol.TeamToolsChannel(sanitized)
+	toolSlots := make(chan struct{}, maxToolInvocations)
+	respond := func(msg *nats.Msg, reply *protocol.Message, err error) {
+		if err != nil {
+			slog.Warn("relay: invalid tool invocation", "team", teamName, "error", err)
+			return
+		}
+		data, err := json.Marshal(reply)
+		if err != nil {
+			return
+		}
+		if err := msg.Respond(data); err != nil {
+			slog.Warn("relay: failed to answer tool invocation", "team", teamName, "error", err)
+		}
+	}
+	toolsSub, err := nc.Subscribe(toolsSubject, func(msg *nats.Msg) {
+		select {
+		case toolSlots <- struct{}{}:
+			go func() {
+				defer func() { <-toolSlots }()
+				reply, err := s.handleToolInvocation(ctx, teamID, msg.Data)
+				respond(msg, reply, err)
+			}()
+		default:
+			slog.Warn("relay: too many tool invocations in progress", "team", teamName)
+			reply, err := toolBusyReply(msg.Data)
+			respond(msg, reply, err)
 		}
 	})
 	if err != nil {
-		slog.Error("relay: failed to subscribe to team NATS", "team", teamName, "error", err)
+		slog.Error("relay: failed to subscribe to team tools", "team", teamName, "error", err)
 		return
 	}
-	defer sub.Unsubscribe()
+	defer toolsSub.Unsubscribe()
 
 	slog.Info("relay: watching team NATS", "team", teamName, "subject", subject)
+
+	// Fall back to treating the leader as ready if it never says so, so that
+	// queued chat messages are not held forever.
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-time.After(leaderReadyTimeout):
+			if !s.isLeaderReady(teamID) {
+				slog.Warn("relay: no agent_ready received, assuming leader is ready", "team", teamName)
+				s.markLeaderReady(teamID, teamName)
+			}
+		}
+	}()
+
 	<-ctx.Done()
 	slog.Info("relay: stopped", "team", teamName)
 }
 
-// processRelayMessage parses a raw NATS payload and saves it as a TaskLog.
-// It is extracted from the inline callback so it can be unit-tested without
-// a real NATS server.
+// relayConsumer is the durable JetStream consumer relays read a team's
+// stream through. A relay that takes over a team, after a restart or on
+// another replica or relay worker, resumes from the last message the
+// previous one acknowledged.
+const relayConsumer = "agentcrew-relay"
+
+// maxToolInvocations is how many custom tool calls of one team a relay runs
+// at once.
+const maxToolInvocations = 16
+
+// relayStreamWait is how long a relay waits for the team's sidecar to create
+// the team's JetStream stream.
+var relayStreamWait = 30 * time.Second
+
+// consumeTeamStream relays the messages of the team's JetStream stream, in
+// order, through the durable relay consumer. It returns a function that
+// stops consuming.
+func (s *Server) consumeTeamStream(ctx context.Context, nc *nats.Conn, sanitized, subject, teamID, teamName string) (func(), error) {
+	js, err := jetstream.New(nc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The leader's sidecar creates the stream when it starts, which may be
+	// after the relay connects.
+	name := "TEAM_" + sanitized
+	var stream jetstream.Stream
+	deadline := time.Now().Add(relayStreamWait)
+	for {
+		stream, err = js.Stream(ctx, name)
+		if err == nil || !errors.Is(err, jetstream.ErrStreamNotFound) || time.Now().After(deadline) {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding stream %s: %w", name, err)
+	}
+
+	cons, err := stream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       relayConsumer,
+		FilterSubject: subject,
+		DeliverPolicy: jetstream.DeliverAllPolicy,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       30 * time.Second,
+		MaxAckPending: relayMaxAckPending,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating relay consumer: %w", err)
+	}
+	// Messages are handled one at a time, in order. Activity events are
+	// acknowledged once their batch commits, while the next messages are
+	// already being handled.
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		s.relayLags.received(teamID, teamName)
+		s.relayMessage(teamID, teamName, msg.Data(), func(err error) {
+			if err != nil {
+				s.recordDeadLetter(teamID, teamName, msg.Subject(), msg.Data(), err)
+			}
+			if err := msg.Ack(); err != nil {
+				slog.Warn("relay: failed to acknowledge message", "team", teamName, "error", err)
+			}
+			var stored time.Time
+			var pending uint64
+			if meta, err := msg.Metadata(); err == nil {
+				stored, pending = meta.Timestamp, meta.NumPending
+			}
+			if level := s.relayLags.committed(teamID, stored, pending); level != "" {
+				go s.sendThrottle(nc, sanitized, teamName, level)
+			}
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("consuming stream %s: %w", name, err)
+	}
+	return cc.Stop, nil
+}
+
+// relayRecordID returns the ID of the kind of record saved for message
+// messageID of a team. It is derived from the message ID, so that a message
+// JetStream delivers again, after an ack timeout or a relay restart, maps to
+// the records already saved for it, which are inserted with
+// ON CONFLICT DO NOTHING. Messages without an ID get a random one.
+func relayRecordID(teamID, messageID, kind string) string {
+	if messageID == "" {
+		return uuid.New().String()
+	}
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(teamID+"/"+kind+"/"+messageID)).String()
+}
+
+// processRelayMessage parses a raw NATS payload and saves it as a TaskLog,
+// returning once it is committed. It is extracted from the inline callback
+// so it can be unit-tested without a real NATS server.
 func (s *Server) processRelayMessage(teamID, teamName string, data []byte) error {
+	errc := make(chan error, 1)
+	s.relayMessage(teamID, teamName, data, func(err error) { errc <- err })
+	return <-errc
+}
+
+// relayMessage processes a raw NATS payload like processRelayMessage and
+// reports the result to done. Activity events, which have nothing left to
+// do once saved, are queued for the TaskLog writer's next batch, and done
+// is called when that batch commits. Every other message is processed
+// before relayMessage returns. The writer commits rows in order, so a
+// team's messages are saved in the order they were relayed.
+func (s *Server) relayMessage(teamID, teamName string, data []byte, done func(error)) {
+	log, protoMsg, err := s.relayTaskLog(teamID, teamName, data)
+	if err != nil || log == nil {
+		done(err)
+		return
+	}
+	if protoMsg.Type == protocol.TypeActivityEvent {
+		s.taskLogs.CreateAsync(log, func(err error) {
+			if err != nil {
+				slog.Error("relay: failed to save task log", "team", teamName, "error", err)
+			}
+			done(err)
+		})
+		return
+	}
+	done(s.saveRelayTaskLog(teamID, teamName, protoMsg, log))
+}
+
+// relayTaskLog parses a raw NATS payload and returns the TaskLog it is
+// saved as, or nil for messages that are not saved as one.
+func (s *Server) relayTaskLog(teamID, teamName string, data []byte) (*models.TaskLog, protocol.Message, error) {
 	var protoMsg protocol.Message
 	if err := json.Unmarshal(data, &protoMsg); err != nil {
-		return err
+		return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
 	}
 	// Only save leader responses and activity events — user messages are
 	// saved by the chat handler and system commands are internal control messages.
 	var messageType string
+	var activity protocol.ActivityEventPayload
+	logID := relayRecordID(teamID, protoMsg.MessageID, "task_log")
 	switch protoMsg.Type {
 	case protocol.TypeLeaderResponse:
+		// A response delivered again has had its effects already.
+		var seen int64
+		if err := s.db.Model(&models.TaskLog{}).Where("id = ?", logID).Count(&seen).Error; err != nil {
+			return nil, protoMsg, err
+		}
+		if seen > 0 {
+			slog.Info("relay: skipping redelivered leader response", "team", teamName, "message_id", protoMsg.MessageID)
+			return nil, protoMsg, nil
+		}
 		messageType = string(protocol.TypeLeaderResponse)
+		s.applyOutputPolicies(teamID, teamName, &protoMsg)
+		s.observeRateLimitError(teamID, teamName, protoMsg)
+		s.recordRunPlan(teamID, teamName, protoMsg)
+		s.recordRunKnowledge(teamID, teamName, protoMsg)
 	case protocol.TypeActivityEvent:
 		messageType = "activity_event"
+		// A malformed payload is still saved; it just cannot be filtered on.
+		json.Unmarshal(protoMsg.Payload, &activity)
+		s.publishPermissionDenied(teamID, teamName, protoMsg)
 	case protocol.TypeContainerValidation:
 		messageType = "container_validation"
 	case protocol.TypeSkillStatus:
 		messageType = string(protocol.TypeSkillStatus)
 	case protocol.TypeMcpStatus:
 		messageType = string(protocol.TypeMcpStatus)
+	case protocol.TypeAgentStatus:
+		messageType = string(protocol.TypeAgentStatus)
+	case protocol.TypeAgentLog:
+		messageType = string(protocol.TypeAgentLog)
+	case protocol.TypeHealthCheck:
+		messageType = string(protocol.TypeHealthCheck)
+		s.publishAgentDegraded(teamID, teamName, protoMsg)
+	case protocol.TypeUsage:
+		// Usage is kept for cost reports rather than as an activity entry.
+		var usage protocol.UsagePayload
+		if err := json.Unmarshal(protoMsg.Payload, &usage); err != nil {
+			return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
+		}
+		return nil, protoMsg, s.recordUsage(teamID, relayRecordID(teamID, protoMsg.MessageID, "usage"), usage)
+	case protocol.TypeQuota:
+		// Rate limit states are kept as quota events.
+		var quota protocol.QuotaPayload
+		if err := json.Unmarshal(protoMsg.Payload, &quota); err != nil {
+			return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
+		}
+		return nil, protoMsg, s.recordQuotaEvent(teamID, relayRecordID(teamID, protoMsg.MessageID, "quota"), quota)
+	case protocol.TypeRunQueue:
+		// The run queue is only kept in memory, to report queued positions.
+		var queue protocol.RunQueuePayload
+		if err := json.Unmarshal(protoMsg.Payload, &queue); err != nil {
+			return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
+		}
+		s.setRunQueue(teamID, queue)
+		return nil, protoMsg, nil
+	case protocol.TypeMemorySummary:
+		// Kept for the team's next deployment rather than as an activity entry.
+		var memory protocol.MemorySummaryPayload
+		if err := json.Unmarshal(protoMsg.Payload, &memory); err != nil {
+			return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
+		}
+		return nil, protoMsg, s.saveTeamMemory(teamID, teamName, memory.AgentName, memory.Summary)
+	case protocol.TypeAgentReady:
+		// Readiness is a control signal, not an activity entry. Only the
+		// leader's bridge receives user messages.
+		var ready protocol.AgentReadyPayload
+		if err := json.Unmarshal(protoMsg.Payload, &ready); err != nil {
+			return nil, protoMsg, fmt.Errorf("%w: %v", errMalformedRelayMessage, err)
+		}
+		if ready.Role == models.AgentRoleLeader {
+			slog.Info("relay: leader ready", "team", teamName, "agent", ready.AgentName)
+			go s.markLeaderReady(teamID, teamName)
+		}
+		return nil, protoMsg, nil
 	default:
-		return nil
+		return nil, protoMsg, nil
 	}
 
+	// Stamp the log with the team's current conversation.
+	team, _ := s.cachedTeam(teamID)
+
 	log := models.TaskLog{
-		ID:          uuid.New().String(),
-		TeamID:      teamID,
-		MessageID:   protoMsg.MessageID,
-		FromAgent:   protoMsg.From,
-		ToAgent:     protoMsg.To,
-		MessageType: messageType,
-		Payload:     models.JSON(protoMsg.Payload),
-	}
-	if err := s.db.Create(&log).Error; err != nil {
+		ID:             logID,
+		TeamID:         teamID,
+		ConversationID: team.ConversationID,
+		MessageID:      protoMsg.MessageID,
+		FromAgent:      protoMsg.From,
+		ToAgent:        protoMsg.To,
+		MessageType:    messageType,
+		Payload:        models.JSON(protoMsg.Payload),
+		EventType:      activity.EventType,
+		ToolName:       activity.ToolName,
+	}
+	if protoMsg.Type == protocol.TypeLeaderResponse {
+		// Parsed after the output policies, which may have changed the result.
+		var response protocol.LeaderResponsePayload
+		if json.Unmarshal(protoMsg.Payload, &response) == nil {
+			log.Segments = segments.Parse(response.Result)
+		}
+	}
+	return &log, protoMsg, nil
+}
+
+// saveRelayTaskLog saves the TaskLog of a relayed message and applies the
+// message's effects on the team's other records.
+func (s *Server) saveRelayTaskLog(teamID, teamName string, protoMsg protocol.Message, log *models.TaskLog) error {
+	if err := s.taskLogs.Create(log); err != nil {
 		slog.Error("relay: failed to save task log", "team", teamName, "error", err)
 		return err
 	}
 	slog.Info("relay: saved agent message", "team", teamName, "type", protoMsg.Type, "from", protoMsg.From)
 
+	if protoMsg.Type == protocol.TypeLeaderResponse {
+		s.recordProposedPatch(teamName, *log)
+	}
+
 	// Persist skill installation results on the agent record so that
 	// GET /api/teams/:id returns skill_statuses for each agent.
 	if protoMsg.Type == protocol.TypeSkillStatus {
 		s.persistSkillStatuses(teamID, protoMsg)
+		s.recordSkillToolRequirements(teamID, protoMsg)
 	}
 
 	if protoMsg.Type == protocol.TypeMcpStatus {
 		s.persistMcpStatuses(teamID, protoMsg)
 	}
 
+	if protoMsg.Type == protocol.TypeContainerValidation {
+		s.persistToolVersions(teamID, protoMsg)
+	}
+
 	return nil
 }
 
@@ -281,3 +600,42 @@ func (s *Server) persistMcpStatuses(teamID string, msg protocol.Message) {
 		slog.Info("relay: updated team mcp_statuses", "team", teamID, "servers", len(payload.Servers))
 	}
 }
+
+// persistToolVersions stores the tool versions reported with a
+// container_validation message on the reporting agent. Older sidecars do not
+// report versions.
+func (s *Server) persistToolVersions(teamID string, msg protocol.Message) {
+	var payload protocol.ContainerValidationPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		slog.Error("relay: failed to parse container_validation payload", "error", err)
+		return
+	}
+	if payload.Versions == nil {
+		return
+	}
+	data, err := json.Marshal(payload.Versions)
+	if err != nil {
+		slog.Error("relay: failed to marshal tool versions", "error", err)
+		return
+	}
+
+	var agents []models.Agent
+	if err := s.db.Select("id", "name").Where("team_id = ?", teamID).Find(&agents).Error; err != nil {
+		slog.Error("relay: failed to load team agents for tool versions", "team", teamID, "error", err)
+		return
+	}
+	for _, agent := range agents {
+		if naming.Slug(agent.Name) != payload.AgentName {
+			continue
+		}
+		now := time.Now()
+		if err := s.db.Model(&agent).Updates(map[string]interface{}{
+			"versions":             models.JSON(data),
+			"versions_reported_at": now,
+		}).Error; err != nil {
+			slog.Error("relay: failed to persist tool versions", "agent", agent.Name, "error", err)
+		}
+		return
+	}
+	slog.Warn("relay: tool versions from unknown agent", "team", teamID, "agent", payload.AgentName)
+}
@@ -2,15 +2,20 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/helmcode/agent-crew/internal/models"
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 // buildRelayPayload marshals a protocol.Message into raw bytes suitable for
-// processRelayMessage, mirroring what a NATS subscriber would receive.
+// processRelayMessage, mirroring what a NATS subscriber would receive. Each
+// payload gets its own message ID; pass the same bytes twice to redeliver.
 func buildRelayPayload(t *testing.T, msgType protocol.MessageType, from, to string, payload interface{}) []byte {
 	t.Helper()
 	rawPayload, err := json.Marshal(payload)
@@ -18,7 +23,7 @@ func buildRelayPayload(t *testing.T, msgType protocol.MessageType, from, to stri
 		t.Fatalf("failed to marshal payload: %v", err)
 	}
 	msg := protocol.Message{
-		MessageID: "msg-" + string(msgType),
+		MessageID: uuid.New().String(),
 		From:      from,
 		To:        to,
 		Type:      msgType,
@@ -80,6 +85,39 @@ func TestProcessRelayMessage_LeaderResponse(t *testing.T) {
 	}
 }
 
+func TestProcessRelayMessage_Redelivery(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-redeliver-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	// JetStream delivers a message again when its ack times out or the relay
+	// restarts; each message must be recorded once.
+	messages := [][]byte{
+		buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+			protocol.LeaderResponsePayload{Status: "completed", Result: "done"}),
+		buildRelayPayload(t, protocol.TypeAgentLog, "leader", "", map[string]string{"line": "working"}),
+		buildRelayPayload(t, protocol.TypeUsage, "leader", "system",
+			protocol.UsagePayload{AgentName: "leader", CostUSD: 0.5, InputTokens: 100, OutputTokens: 10}),
+	}
+	for i := 0; i < 2; i++ {
+		for _, data := range messages {
+			if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+				t.Fatalf("processRelayMessage (delivery %d): %v", i+1, err)
+			}
+		}
+	}
+
+	if count := countRelayLogs(t, srv, team.ID); count != 2 {
+		t.Errorf("task logs: got %d, want 2", count)
+	}
+	var usage int64
+	srv.db.Model(&models.UsageRecord{}).Where("team_id = ?", team.ID).Count(&usage)
+	if usage != 1 {
+		t.Errorf("usage records: got %d, want 1", usage)
+	}
+}
+
 func TestProcessRelayMessage_SkipsUserMessage(t *testing.T) {
 	srv, _ := setupTestServer(t)
 	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-skip-user"})
@@ -571,3 +609,238 @@ func TestProcessRelayMessage_PayloadPreserved(t *testing.T) {
 		t.Errorf("payload result: got %q, want 'the final answer'", respPayload.Result)
 	}
 }
+
+func TestProcessRelayMessage_AgentReady(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-ready-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	worker := buildRelayPayload(t, protocol.TypeAgentReady, "worker-1", "system",
+		protocol.AgentReadyPayload{AgentName: "worker-1", Role: models.AgentRoleWorker})
+	if err := srv.processRelayMessage(team.ID, team.Name, worker); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	leader := buildRelayPayload(t, protocol.TypeAgentReady, "leader", "system",
+		protocol.AgentReadyPayload{AgentName: "leader", Role: models.AgentRoleLeader})
+	if err := srv.processRelayMessage(team.ID, team.Name, leader); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !srv.isLeaderReady(team.ID) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !srv.isLeaderReady(team.ID) {
+		t.Error("expected leader to be marked ready")
+	}
+
+	// The ready signal is not persisted as a task log.
+	if n := countRelayLogs(t, srv, team.ID); n != 0 {
+		t.Errorf("task logs: got %d, want 0", n)
+	}
+
+	srv.stopTeamRelay(team.ID)
+	if srv.isLeaderReady(team.ID) {
+		t.Error("expected ready state to be cleared when the relay stops")
+	}
+}
+
+func TestProcessRelayMessage_AgentStatus(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-status-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeAgentStatus, "leader", "system",
+		protocol.AgentStatusPayload{AgentName: "leader", Status: protocol.AgentStatusShuttingDown, Drained: true})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	var log models.TaskLog
+	if err := srv.db.Where("team_id = ?", team.ID).First(&log).Error; err != nil {
+		t.Fatalf("expected task log: %v", err)
+	}
+	if log.MessageType != string(protocol.TypeAgentStatus) {
+		t.Errorf("message type: got %q", log.MessageType)
+	}
+}
+
+func TestProcessRelayMessage_AgentLog(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-log-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeAgentLog, "leader", "system", protocol.AgentLogPayload{
+		AgentName: "leader",
+		Entries:   []protocol.AgentLogEntry{{Level: "WARN", Message: "skill install failed"}},
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	var log models.TaskLog
+	if err := srv.db.Where("team_id = ?", team.ID).First(&log).Error; err != nil {
+		t.Fatalf("expected task log: %v", err)
+	}
+	if log.MessageType != string(protocol.TypeAgentLog) {
+		t.Errorf("message type: got %q", log.MessageType)
+	}
+}
+
+func TestProcessRelayMessage_ActivityEventColumns(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-activity-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	data := buildRelayPayload(t, protocol.TypeActivityEvent, "leader", "system", protocol.ActivityEventPayload{
+		EventType: "tool_use",
+		AgentName: "leader",
+		ToolName:  "Bash",
+		Action:    "ls",
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	var log models.TaskLog
+	if err := srv.db.Where("team_id = ?", team.ID).First(&log).Error; err != nil {
+		t.Fatalf("expected task log: %v", err)
+	}
+	if log.EventType != "tool_use" || log.ToolName != "Bash" {
+		t.Errorf("event type, tool name: got %q, %q", log.EventType, log.ToolName)
+	}
+}
+
+func TestProcessRelayMessage_LeaderResponseSegments(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-segments-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	for _, result := range []string{"## Fix\n```go\nx := 1\n```", "plain answer"} {
+		data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+			protocol.LeaderResponsePayload{Status: "completed", Result: result})
+		if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
+	logs := parseList[models.TaskLog](t, rec)
+	if len(logs) != 2 {
+		t.Fatalf("messages: got %d, want 2", len(logs))
+	}
+	// Both may be saved in one batch, with the same created_at.
+	plain, rich := logs[0], logs[1]
+	if plain.Segments != nil {
+		plain, rich = rich, plain
+	}
+	if plain.Segments != nil {
+		t.Errorf("plain response segments: got %+v", plain.Segments)
+	}
+	segs := rich.Segments
+	if len(segs) != 2 || segs[0].Type != "heading" || segs[1].Type != "code" || segs[1].Language != "go" || segs[1].Content != "x := 1" {
+		t.Errorf("segments: got %+v", segs)
+	}
+}
+
+func TestRelayMessage_ActivityEventsQueued(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "relay-async-team")
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, tool := range []string{"Read", "Edit", "Bash"} {
+		data := buildRelayPayload(t, protocol.TypeActivityEvent, "leader", "",
+			protocol.ActivityEventPayload{EventType: "tool_use", ToolName: tool})
+		srv.relayMessage(teamID, "relay-async-team", data, func(err error) {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+		})
+	}
+	// The leader response is saved after the activity queued before it.
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+	if err := srv.processRelayMessage(teamID, "relay-async-team", data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 3 {
+		t.Fatalf("completed activity events: got %d, want 3", len(errs))
+	}
+	for _, err := range errs {
+		if err != nil {
+			t.Errorf("activity event: %v", err)
+		}
+	}
+	if count := countRelayLogs(t, srv, teamID); count != 4 {
+		t.Errorf("task logs: got %d, want 4", count)
+	}
+	if got := srv.taskLogs.Stats().AsyncRows; got != 3 {
+		t.Errorf("async rows: got %d, want 3", got)
+	}
+}
+
+func TestRelayMessage_MalformedReportedAtOnce(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var got error
+	called := false
+	srv.relayMessage("team", "team", []byte("{not json"), func(err error) { got, called = err, true })
+	if !called || !errors.Is(got, errMalformedRelayMessage) {
+		t.Errorf("done: called %v with %v", called, got)
+	}
+}
+
+func TestProcessRelayMessage_ConversationFromReadCache(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-conv-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("conversation_id", "conv-1")
+
+	relayLog := func() error {
+		data := buildRelayPayload(t, protocol.TypeAgentLog, "leader", "", map[string]string{"line": "working"})
+		return srv.processRelayMessage(team.ID, team.Name, data)
+	}
+	before := srv.readCache.Stats().Teams
+	for i := 0; i < 5; i++ {
+		if err := relayLog(); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+	// Relayed messages read the conversation from the cache, not from the
+	// teams table each time.
+	if misses := srv.readCache.Stats().Teams.Misses - before.Misses; misses > 1 {
+		t.Errorf("team cache misses for 5 messages: got %d, want at most 1", misses)
+	}
+
+	// A new conversation invalidates the cached team.
+	srv.db.Model(&team).Update("conversation_id", "conv-2")
+	if err := relayLog(); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	var counts []struct {
+		ConversationID string
+		N              int
+	}
+	srv.db.Model(&models.TaskLog{}).Select("conversation_id, count(*) as n").
+		Where("team_id = ?", team.ID).Group("conversation_id").Order("conversation_id").Scan(&counts)
+	if len(counts) != 2 || counts[0].ConversationID != "conv-1" || counts[0].N != 5 || counts[1].ConversationID != "conv-2" || counts[1].N != 1 {
+		t.Errorf("logs per conversation: got %+v", counts)
+	}
+}
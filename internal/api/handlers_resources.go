@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/resources"
+)
+
+// GetResourcePresets returns the organization's resource presets and ceiling.
+func (s *Server) GetResourcePresets(c *fiber.Ctx) error {
+	policy, err := resources.Load(s.db, GetOrgID(c))
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load resource presets")
+	}
+	return c.JSON(policy)
+}
+
+// UpdateResourcePresets replaces the organization's resource presets and
+// ceiling (admin only). Omitting presets restores the built-in ones.
+// Existing agent overrides above a lowered ceiling are clamped at deploy.
+func (s *Server) UpdateResourcePresets(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage resource presets")
+	}
+	var req UpdateResourcePresetsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	policy := resources.Policy{Presets: req.Presets, Ceiling: req.Ceiling}
+	if policy.Presets == nil {
+		policy.Presets = resources.DefaultPresets
+	}
+	if err := policy.Validate(); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if err := resources.Save(s.db, GetOrgID(c), policy); err != nil {
+		slog.Error("failed to save resource presets", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to save resource presets")
+	}
+	return c.JSON(policy)
+}
+
+// loadResourcePolicy loads the request organization's resource policy for
+// validating team presets and agent overrides.
+func (s *Server) loadResourcePolicy(c *fiber.Ctx) (resources.Policy, error) {
+	policy, err := resources.Load(s.db, GetOrgID(c))
+	if err != nil {
+		slog.Error("failed to load resource policy", "error", err)
+		return policy, fiber.NewError(fiber.StatusInternalServerError, "failed to load resource presets")
+	}
+	return policy, nil
+}
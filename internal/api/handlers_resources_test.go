@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/resources"
+)
+
+func TestResourcePresets_DefaultsAndUpdate(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/resource-presets", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var policy resources.Policy
+	parseJSON(t, rec, &policy)
+	for _, name := range []string{"small", "medium", "large"} {
+		if _, ok := policy.Presets[name]; !ok {
+			t.Errorf("missing default preset %q", name)
+		}
+	}
+
+	rec = doRequest(srv, "PUT", "/api/resource-presets", UpdateResourcePresetsRequest{
+		Presets: map[string]resources.Limits{"large": {CPU: "16", Memory: "64g"}},
+		Ceiling: resources.Limits{CPU: "8"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("preset above ceiling: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "PUT", "/api/resource-presets", UpdateResourcePresetsRequest{
+		Presets: map[string]resources.Limits{"tiny": {CPU: "0.5", Memory: "512m"}},
+		Ceiling: resources.Limits{CPU: "2", Memory: "4g"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(srv, "GET", "/api/resource-presets", nil)
+	var updated resources.Policy
+	parseJSON(t, rec, &updated)
+	if len(updated.Presets) != 1 || updated.Ceiling.Memory != "4g" {
+		t.Errorf("updated policy: got %+v", updated)
+	}
+}
+
+func TestCreateTeam_ResourcePreset(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "preset-bad", ResourcePreset: "huge"})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown preset: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:           "preset-team",
+		ResourcePreset: "medium",
+		Agents:         []CreateAgentInput{{Name: "leader", Role: "leader", Resources: map[string]string{"memory": "6g"}}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.ResourcePreset != "medium" {
+		t.Errorf("resource_preset: got %q, want medium", team.ResourcePreset)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected leader to be deployed")
+	}
+	res := mock.lastAgentConfig.Resources
+	if res.CPU != "2" || res.Memory != "6g" {
+		t.Errorf("leader resources: got cpu=%q memory=%q, want cpu=2 memory=6g", res.CPU, res.Memory)
+	}
+
+	preset := "small"
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{ResourcePreset: &preset})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+	if team.ResourcePreset != "small" {
+		t.Errorf("updated resource_preset: got %q, want small", team.ResourcePreset)
+	}
+}
+
+func TestAgentResources_CeilingEnforced(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	rec := doRequest(srv, "PUT", "/api/resource-presets", UpdateResourcePresetsRequest{
+		Presets: map[string]resources.Limits{"small": {CPU: "1", Memory: "2g"}},
+		Ceiling: resources.Limits{CPU: "2", Memory: "4g"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update presets: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "ceiling-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader", Resources: map[string]string{"cpu": "4"}}},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("team agent above ceiling: got %d, want 400", rec.Code)
+	}
+
+	teamID := createTeamForActivity(t, srv, "ceiling-team-2")
+	rec = doRequest(srv, "POST", "/api/teams/"+teamID+"/agents", CreateAgentRequest{
+		Name: "worker", Resources: map[string]string{"memory": "8g"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("agent above ceiling: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+teamID+"/agents", CreateAgentRequest{
+		Name: "worker", Resources: map[string]string{"memory": "4g"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("agent within ceiling: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var agent models.Agent
+	parseJSON(t, rec, &agent)
+
+	rec = doRequest(srv, "PUT", "/api/teams/"+teamID+"/agents/"+agent.ID, UpdateAgentRequest{
+		Resources: map[string]string{"cpu": "3"},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("agent update above ceiling: got %d, want 400", rec.Code)
+	}
+}
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"strconv"
 	"time"
@@ -9,6 +11,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
 )
 
 // GetScheduleConfig returns the schedule configuration visible to the frontend.
@@ -45,7 +48,7 @@ func (s *Server) GetSchedule(c *fiber.Ctx) error {
 func (s *Server) CreateSchedule(c *fiber.Ctx) error {
 	var req CreateScheduleRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.Name == "" {
@@ -54,11 +57,25 @@ func (s *Server) CreateSchedule(c *fiber.Ctx) error {
 	if req.TeamID == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "team_id is required")
 	}
+	// When a template is referenced, the rendered content is stored as the
+	// prompt snapshot; the executor re-renders it on every run.
+	var templateID *string
+	var promptVars models.JSON
+	if req.PromptTemplateID != nil && *req.PromptTemplateID != "" {
+		rendered, err := s.renderPromptTemplateByID(c, "prompt_template_id", *req.PromptTemplateID, req.PromptVariables)
+		if err != nil {
+			return err
+		}
+		req.Prompt = rendered
+		templateID = req.PromptTemplateID
+		varsJSON, _ := json.Marshal(req.PromptVariables)
+		promptVars = models.JSON(varsJSON)
+	}
 	if req.Prompt == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "prompt is required")
 	}
-	if len(req.Prompt) > 50000 {
-		return fiber.NewError(fiber.StatusBadRequest, "prompt exceeds maximum length of 50000 characters")
+	if len(req.Prompt) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt exceeds maximum length of %d characters", maxPromptLength))
 	}
 	if req.CronExpression == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "cron_expression is required")
@@ -84,6 +101,10 @@ func (s *Server) CreateSchedule(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "invalid timezone: "+err.Error())
 	}
 
+	if err := validateScheduleWorkingHours(req.WorkingHours); err != nil {
+		return err
+	}
+
 	enabled := true
 	if req.Enabled != nil {
 		enabled = *req.Enabled
@@ -93,16 +114,19 @@ func (s *Server) CreateSchedule(c *fiber.Ctx) error {
 	nextRun := calculateNextRun(req.CronExpression, tz)
 
 	schedule := models.Schedule{
-		ID:             uuid.New().String(),
-		OrgID:          GetOrgID(c),
-		Name:           req.Name,
-		TeamID:         req.TeamID,
-		Prompt:         req.Prompt,
-		CronExpression: req.CronExpression,
-		Timezone:       tz,
-		Enabled:        enabled,
-		NextRunAt:      nextRun,
-		Status:         models.ScheduleStatusIdle,
+		ID:               uuid.New().String(),
+		OrgID:            GetOrgID(c),
+		Name:             req.Name,
+		TeamID:           req.TeamID,
+		Prompt:           req.Prompt,
+		PromptTemplateID: templateID,
+		PromptVariables:  promptVars,
+		CronExpression:   req.CronExpression,
+		Timezone:         tz,
+		Enabled:          enabled,
+		WorkingHours:     req.WorkingHours,
+		NextRunAt:        nextRun,
+		Status:           models.ScheduleStatusIdle,
 	}
 
 	if err := s.db.Create(&schedule).Error; err != nil {
@@ -124,7 +148,7 @@ func (s *Server) UpdateSchedule(c *fiber.Ctx) error {
 
 	var req UpdateScheduleRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -146,12 +170,44 @@ func (s *Server) UpdateSchedule(c *fiber.Ctx) error {
 		if *req.Prompt == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "prompt cannot be empty")
 		}
-		if len(*req.Prompt) > 50000 {
-			return fiber.NewError(fiber.StatusBadRequest, "prompt exceeds maximum length of 50000 characters")
+		if len(*req.Prompt) > maxPromptLength {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt exceeds maximum length of %d characters", maxPromptLength))
 		}
 		updates["prompt"] = *req.Prompt
 	}
 
+	// Re-render the prompt snapshot when the template reference or its
+	// variables change. An empty prompt_template_id detaches the template.
+	if req.PromptTemplateID != nil || req.PromptVariables != nil {
+		templateID := schedule.PromptTemplateID
+		if req.PromptTemplateID != nil {
+			if *req.PromptTemplateID == "" {
+				templateID = nil
+			} else {
+				templateID = req.PromptTemplateID
+			}
+			updates["prompt_template_id"] = templateID
+		}
+
+		vars := map[string]string{}
+		if len(schedule.PromptVariables) > 0 {
+			_ = json.Unmarshal(schedule.PromptVariables, &vars)
+		}
+		if req.PromptVariables != nil {
+			vars = *req.PromptVariables
+			varsJSON, _ := json.Marshal(vars)
+			updates["prompt_variables"] = models.JSON(varsJSON)
+		}
+
+		if templateID != nil {
+			rendered, err := s.renderPromptTemplateByID(c, "prompt_template_id", *templateID, vars)
+			if err != nil {
+				return err
+			}
+			updates["prompt"] = rendered
+		}
+	}
+
 	// Track whether cron or timezone changed so we recalculate next_run_at.
 	cronChanged := false
 	newCron := schedule.CronExpression
@@ -176,6 +232,13 @@ func (s *Server) UpdateSchedule(c *fiber.Ctx) error {
 	if req.Enabled != nil {
 		updates["enabled"] = *req.Enabled
 	}
+	if req.WorkingHours != nil {
+		if err := validateScheduleWorkingHours(*req.WorkingHours); err != nil {
+			return err
+		}
+		updates["working_hours"] = *req.WorkingHours
+		updates["deferred_until"] = nil
+	}
 
 	if cronChanged {
 		updates["next_run_at"] = calculateNextRun(newCron, newTZ)
@@ -329,6 +392,17 @@ func validateTimezone(tz string) error {
 	return nil
 }
 
+// validateScheduleWorkingHours checks a schedule's working-hours override.
+func validateScheduleWorkingHours(action string) error {
+	switch action {
+	case "", workhours.ActionRun, workhours.ActionSkip, workhours.ActionDefer:
+		return nil
+	}
+	return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf(
+		"invalid working_hours %q: use %s, %s or %s, or leave it empty to follow the team",
+		action, workhours.ActionRun, workhours.ActionSkip, workhours.ActionDefer))
+}
+
 // calculateNextRun computes the next run time for a cron expression in the given timezone.
 // Returns nil if the cron expression or timezone is invalid.
 func calculateNextRun(cronExpr, tz string) *time.Time {
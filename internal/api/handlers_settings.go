@@ -1,12 +1,15 @@
 package api
 
 import (
+	"fmt"
 	"log/slog"
 
 	"github.com/gofiber/fiber/v2"
 
 	"github.com/helmcode/agent-crew/internal/crypto"
 	"github.com/helmcode/agent-crew/internal/models"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
 const maskedValue = "********"
@@ -54,12 +57,15 @@ func (s *Server) GetSettings(c *fiber.Ctx) error {
 func (s *Server) UpdateSettings(c *fiber.Ctx) error {
 	var req UpdateSettingsRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.Key == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "key is required")
 	}
+	if err := validateNATSSetting(req.Key, req.Value); err != nil {
+		return newAPIError(CodeBadRequest, err.Error())
+	}
 
 	isSecret := false
 	if req.IsSecret != nil {
@@ -119,3 +125,26 @@ func (s *Server) DeleteSetting(c *fiber.Ctx) error {
 	}
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// validateNATSSetting checks the value of a setting that configures the
+// team's NATS server or JetStream stream, which would otherwise only fail
+// at the next deploy.
+func validateNATSSetting(key, value string) error {
+	if value == "" {
+		return nil
+	}
+	var err error
+	switch key {
+	case runtime.SettingJetStreamMaxMemory, runtime.SettingJetStreamMaxStore:
+		_, err = agentNats.ParseSize(value)
+	case agentNats.SettingStreamMaxAge, agentNats.SettingStreamMaxBytes, agentNats.SettingStreamMaxMsgs:
+		// The error names the setting.
+		if _, err := agentNats.StreamLimitsFromEnv(map[string]string{key: value}); err != nil {
+			return fmt.Errorf("invalid %w", err)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return nil
+}
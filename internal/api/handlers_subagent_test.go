@@ -2,6 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/helmcode/agent-crew/internal/models"
@@ -95,6 +98,107 @@ func TestCreateAgent_SubAgentModelDefault(t *testing.T) {
 	}
 }
 
+func TestCreateAgent_SubAgentFrontmatter(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "frontmatter-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", CreateAgentRequest{Name: "defaults"})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var agent models.Agent
+	parseJSON(t, rec, &agent)
+	if agent.SubAgentBackground == nil || !*agent.SubAgentBackground ||
+		agent.SubAgentIsolation != "worktree" || agent.SubAgentPermissionMode != "bypassPermissions" {
+		t.Errorf("defaults: background=%v isolation=%q permission_mode=%q",
+			agent.SubAgentBackground, agent.SubAgentIsolation, agent.SubAgentPermissionMode)
+	}
+
+	background := false
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", CreateAgentRequest{
+		Name:                   "foreground",
+		SubAgentBackground:     &background,
+		SubAgentIsolation:      "none",
+		SubAgentPermissionMode: "plan",
+	})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	agent = models.Agent{}
+	parseJSON(t, rec, &agent)
+	if agent.SubAgentBackground == nil || *agent.SubAgentBackground ||
+		agent.SubAgentIsolation != "none" || agent.SubAgentPermissionMode != "plan" {
+		t.Errorf("overrides: background=%v isolation=%q permission_mode=%q",
+			agent.SubAgentBackground, agent.SubAgentIsolation, agent.SubAgentPermissionMode)
+	}
+
+	for _, req := range []CreateAgentRequest{
+		{Name: "bad-isolation", SubAgentIsolation: "container"},
+		{Name: "bad-mode", SubAgentPermissionMode: "yolo"},
+	} {
+		rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", req)
+		if rec.Code != 400 {
+			t.Errorf("%s: got %d, want 400", req.Name, rec.Code)
+		}
+	}
+}
+
+func TestUpdateAgent_SubAgentFrontmatter(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name: "upd-frontmatter-team",
+		Agents: []CreateAgentInput{
+			{Name: "lead", Role: "leader"},
+			{Name: "helper", Role: "worker"},
+		},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	var worker models.Agent
+	for _, a := range team.Agents {
+		if a.Role == "worker" {
+			worker = a
+		}
+	}
+
+	background, isolation, mode := false, "none", "acceptEdits"
+	rec := doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+worker.ID, UpdateAgentRequest{
+		SubAgentBackground:     &background,
+		SubAgentIsolation:      &isolation,
+		SubAgentPermissionMode: &mode,
+	})
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+worker.ID+"/preview-claude-md", nil)
+	var preview PreviewInstructionsResponse
+	parseJSON(t, rec, &preview)
+	if strings.Contains(preview.Content, "background:") || strings.Contains(preview.Content, "isolation:") ||
+		!strings.Contains(preview.Content, "permissionMode: acceptEdits") {
+		t.Errorf("frontmatter not updated:\n%s", preview.Content)
+	}
+
+	// An empty value restores the default.
+	mode = ""
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+worker.ID, UpdateAgentRequest{SubAgentPermissionMode: &mode})
+	var agent models.Agent
+	parseJSON(t, rec, &agent)
+	if agent.SubAgentPermissionMode != "bypassPermissions" {
+		t.Errorf("permission_mode: got %q, want bypassPermissions", agent.SubAgentPermissionMode)
+	}
+
+	mode = "yolo"
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID+"/agents/"+worker.ID, UpdateAgentRequest{SubAgentPermissionMode: &mode})
+	if rec.Code != 400 {
+		t.Errorf("invalid permission mode: got %d, want 400", rec.Code)
+	}
+}
+
 func TestUpdateAgent_SubAgentFields(t *testing.T) {
 	srv, _ := setupTestServer(t)
 
@@ -526,3 +630,155 @@ func TestCreateTeam_RejectsOversizedAgentDescription(t *testing.T) {
 		t.Fatalf("status: got %d, want 400 for oversized description in team create\nbody: %s", rec.Code, rec.Body.String())
 	}
 }
+
+// --- Instructions preview ---
+
+func TestPreviewInstructions(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name: "team-preview",
+		Agents: []CreateAgentInput{
+			{Name: "lead", Role: "leader", Specialty: "Coordinates work"},
+			{Name: "helper", Role: "worker", SubAgentInstructions: "Fix bugs."},
+		},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	var leader, worker models.Agent
+	for _, a := range team.Agents {
+		if a.Role == "leader" {
+			leader = a
+		} else {
+			worker = a
+		}
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+leader.ID+"/preview-claude-md", nil)
+	if rec.Code != 200 {
+		t.Fatalf("leader preview: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var preview PreviewInstructionsResponse
+	parseJSON(t, rec, &preview)
+	if preview.Path != ".claude/CLAUDE.md" {
+		t.Errorf("path: got %q", preview.Path)
+	}
+	if !strings.Contains(preview.Content, "helper") {
+		t.Error("expected leader preview to include the team roster")
+	}
+	if len(preview.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", preview.Warnings)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+worker.ID+"/preview-claude-md", nil)
+	if rec.Code != 200 {
+		t.Fatalf("worker preview: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	preview = PreviewInstructionsResponse{}
+	parseJSON(t, rec, &preview)
+	if preview.Path != ".claude/agents/helper.md" {
+		t.Errorf("path: got %q", preview.Path)
+	}
+	if !strings.Contains(preview.Content, "Fix bugs.") {
+		t.Error("expected worker preview to include sub-agent instructions")
+	}
+	if len(preview.Warnings) != 1 || !strings.Contains(preview.Warnings[0], "sub_agent_description") {
+		t.Errorf("expected missing description warning, got %v", preview.Warnings)
+	}
+}
+
+func TestPreviewInstructions_AgentNotFound(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "team-preview-404"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/missing/preview-claude-md", nil)
+	if rec.Code != 404 {
+		t.Errorf("status: got %d, want 404", rec.Code)
+	}
+}
+
+// --- Regenerating instruction files ---
+
+func TestRegenerateAgentFiles_WritesWorkspace(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	workspace := t.TempDir()
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name: "team-regen",
+		Agents: []CreateAgentInput{
+			{Name: "lead", Role: "leader", Specialty: "Coordinates work"},
+			{Name: "helper", Role: "worker", SubAgentInstructions: "Fix bugs."},
+		},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	srv.db.Model(&team).Update("workspace_path", workspace)
+
+	var worker models.Agent
+	for _, a := range team.Agents {
+		if a.Role == "worker" {
+			worker = a
+		}
+	}
+	srv.db.Model(&worker).Update("sub_agent_instructions", "Fix bugs and add tests.")
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+worker.ID+"/regenerate-files", nil)
+	if rec.Code != 200 {
+		t.Fatalf("regenerate: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp RegenerateFilesResponse
+	parseJSON(t, rec, &resp)
+	if resp.Path != ".claude/agents/helper.md" || !resp.WrittenToWorkspace || resp.PushedToAgent {
+		t.Errorf("response: %+v", resp)
+	}
+	if !strings.Contains(resp.Content, "Fix bugs and add tests.") {
+		t.Error("expected content generated from the current sub-agent instructions")
+	}
+	data, err := os.ReadFile(filepath.Join(workspace, ".claude", "agents", "helper.md"))
+	if err != nil {
+		t.Fatalf("reading sub-agent file: %v", err)
+	}
+	if string(data) != resp.Content {
+		t.Errorf("file content differs from the returned content")
+	}
+}
+
+func TestRegenerateAgentFiles_WithoutWorkspace(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "team-regen-remote",
+		Agents: []CreateAgentInput{{Name: "lead", Role: "leader", Specialty: "Coordinates work"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	leader := team.Agents[0]
+
+	// A stopped team only gets the content back.
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+leader.ID+"/regenerate-files", nil)
+	if rec.Code != 200 {
+		t.Fatalf("regenerate: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp RegenerateFilesResponse
+	parseJSON(t, rec, &resp)
+	if resp.Path != ".claude/CLAUDE.md" || resp.Content == "" || resp.WrittenToWorkspace || resp.PushedToAgent {
+		t.Errorf("response: %+v", resp)
+	}
+
+	// A running team whose leader is down cannot receive the update.
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents/"+leader.ID+"/regenerate-files", nil)
+	if rec.Code != 409 {
+		t.Errorf("status: got %d, want 409\nbody: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -3,8 +3,11 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,17 +16,37 @@ import (
 
 	"github.com/helmcode/agent-crew/internal/crypto"
 	"github.com/helmcode/agent-crew/internal/models"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/resources"
 	"github.com/helmcode/agent-crew/internal/runtime"
+	"github.com/helmcode/agent-crew/internal/tools"
 )
 
-// ListTeams returns all teams for the current organization.
+// teamListOptions configures GET /api/teams: oldest first, filterable by
+// status, runtime and slug.
+var teamListOptions = listOptions{
+	DefaultLimit: 100,
+	MaxLimit:     500,
+	Filters:      map[string]string{"status": "status", "runtime": "runtime", "slug": "slug"},
+}
+
+// ListTeams returns the teams of the current organization, paginated.
 func (s *Server) ListTeams(c *fiber.Ctx) error {
-	var teams []models.Team
-	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").Find(&teams).Error; err != nil {
+	q, err := parseListQuery(c, teamListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Scopes(OrgScope(c)), q, teamKey, "Agents")
+	if err != nil {
 		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
 	}
-	return c.JSON(teams)
+	locale := requestLocale(c)
+	for i := range resp.Items {
+		explainTeamFailure(&resp.Items[i], locale)
+	}
+	return c.JSON(resp)
 }
 
 // GetTeam returns a single team by ID.
@@ -31,16 +54,67 @@ func (s *Server) GetTeam(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	s.refreshContainerStatuses(c.Context(), &team)
+	locale := requestLocale(c)
+	if notModified(c, localeETag(teamETag(team), locale)) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
+	explainTeamFailure(&team, locale)
 	return c.JSON(team)
 }
 
+// containerStatusTimeout bounds the runtime status lookup done per agent when
+// a team is fetched.
+const containerStatusTimeout = 3 * time.Second
+
+// containerStatusRefreshInterval is how often fetching a team looks its
+// containers' status up in the runtime. Fetches in between, such as clients
+// polling with If-None-Match, answer from the stored status.
+var containerStatusRefreshInterval = 10 * time.Second
+
+// refreshContainerStatuses updates the container_status of a running team's
+// agents from the runtime, so that a container failing its sidecar
+// healthcheck shows as unhealthy. Lookup failures keep the stored status.
+func (s *Server) refreshContainerStatuses(ctx context.Context, team *models.Team) {
+	if team.Status != models.TeamStatusRunning {
+		return
+	}
+	now := time.Now()
+	if last, ok := s.statusRefreshes.Load(team.ID); ok && now.Sub(last.(time.Time)) < containerStatusRefreshInterval {
+		return
+	}
+	s.statusRefreshes.Store(team.ID, now)
+	for i := range team.Agents {
+		agent := &team.Agents[i]
+		if agent.ContainerID == "" || !models.ContainerIsUp(agent.ContainerStatus) {
+			continue
+		}
+
+		statusCtx, cancel := context.WithTimeout(ctx, containerStatusTimeout)
+		st, err := s.runtime.GetStatus(statusCtx, agent.ContainerID)
+		cancel()
+		if err != nil {
+			slog.Debug("failed to refresh container status", "agent", agent.Name, "error", err)
+			continue
+		}
+		if st.Status == agent.ContainerStatus {
+			continue
+		}
+
+		slog.Info("container status changed", "team", team.Name, "agent", agent.Name,
+			"from", agent.ContainerStatus, "to", st.Status)
+		s.db.Model(agent).Update("container_status", st.Status)
+		agent.ContainerStatus = st.Status
+	}
+}
+
 // CreateTeam creates a new team with optional agents.
 func (s *Server) CreateTeam(c *fiber.Ctx) error {
 	var req CreateTeamRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.Name == "" {
@@ -52,7 +126,10 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 
 	rt := req.Runtime
 	if rt == "" {
-		rt = "docker"
+		rt = s.runtimeName
+	}
+	if m, ok := s.runtime.(*runtime.MultiRuntime); ok && !m.Has(rt) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("runtime must be one of %s", strings.Join(m.Names(), ", ")))
 	}
 
 	prov := req.Provider
@@ -77,10 +154,32 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, err.Error())
 	}
 
+	if err := validateConfigDirMode(req.ConfigDirMode); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	configDirMode := req.ConfigDirMode
+	if configDirMode == "" {
+		configDirMode = models.ConfigDirModeInline
+	}
+
+	policy, err := s.loadResourcePolicy(c)
+	if err != nil {
+		return err
+	}
+	if err := policy.CheckPreset(req.ResourcePreset); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	slug, err := s.checkTeamSlug(GetOrgID(c), req.Name, "")
+	if err != nil {
+		return err
+	}
+
 	team := models.Team{
 		ID:            uuid.New().String(),
 		OrgID:         GetOrgID(c),
 		Name:          req.Name,
+		Slug:          slug,
 		Description:   req.Description,
 		Status:        models.TeamStatusStopped,
 		Runtime:       rt,
@@ -88,8 +187,76 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 		ModelProvider: req.ModelProvider,
 		WorkspacePath: req.WorkspacePath,
 		AgentImage:    req.AgentImage,
+		ConfigDirMode: configDirMode,
+		ResourcePreset: req.ResourcePreset,
 	}
 
+	if req.NamespaceConfig != nil {
+		if err := req.NamespaceConfig.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "namespace_config: "+err.Error())
+		}
+		nsData, _ := json.Marshal(req.NamespaceConfig)
+		team.NamespaceConfig = models.JSON(nsData)
+	}
+	if req.RestartPolicy != nil {
+		if err := req.RestartPolicy.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "restart_policy: "+err.Error())
+		}
+		restartData, _ := json.Marshal(req.RestartPolicy)
+		team.RestartPolicy = models.JSON(restartData)
+	}
+	if len(req.Labels) > 0 {
+		if err := validateTeamLabels(req.Labels); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		labelsData, _ := json.Marshal(req.Labels)
+		team.Labels = models.JSON(labelsData)
+	}
+	if req.Bootstrap != nil && req.Bootstrap.Script != "" {
+		if err := req.Bootstrap.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "bootstrap: "+err.Error())
+		}
+		bootstrapData, _ := json.Marshal(req.Bootstrap)
+		team.Bootstrap = models.JSON(bootstrapData)
+	}
+	if len(req.ExtraWorkspaces) > 0 {
+		if err := protocol.ValidateExtraWorkspaces(req.ExtraWorkspaces); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		workspacesData, _ := json.Marshal(req.ExtraWorkspaces)
+		team.ExtraWorkspaces = models.JSON(workspacesData)
+	}
+	if err := runtime.ValidateWorkspaceTemplate(req.WorkspaceTemplate); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	team.WorkspaceTemplate = req.WorkspaceTemplate
+	if len(req.HostSelector) > 0 {
+		if err := validateHostSelector(req.HostSelector); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		selectorData, _ := json.Marshal(req.HostSelector)
+		team.HostSelector = models.JSON(selectorData)
+	}
+	if err := validateMaxConcurrentRuns(req.MaxConcurrentRuns); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	team.MaxConcurrentRuns = req.MaxConcurrentRuns
+	if err := validateTelemetryLevel(req.TelemetryLevel); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	team.TelemetryLevel = req.TelemetryLevel
+	team.PlanApproval = req.PlanApproval
+	team.Memory = req.Memory
+	team.KnowledgeBase = req.KnowledgeBase
+	if req.WorkingHours != nil && !req.WorkingHours.IsZero() {
+		if err := req.WorkingHours.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "working_hours: "+err.Error())
+		}
+		hoursData, _ := json.Marshal(req.WorkingHours)
+		team.WorkingHours = models.JSON(hoursData)
+	}
+	team.RequiresDeployApproval = req.RequiresDeployApproval
+
 	// Validate and serialize MCP servers.
 	if req.McpServers != nil {
 		if err := validateMcpServers(req.McpServers); err != nil {
@@ -112,15 +279,24 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 	}
 
 	// Create agents if provided.
-	for _, a := range req.Agents {
-		if a.Name != "" {
-			if err := validateName(a.Name); err != nil {
-				return fiber.NewError(fiber.StatusBadRequest, "agent "+a.Name+": "+err.Error())
-			}
+	for i, a := range req.Agents {
+		if a.Name == "" {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("agents[%d]: name is required", i))
+		}
+		if err := validateName(a.Name); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+a.Name+": "+err.Error())
 		}
 		agentLabel := a.Name
-		if agentLabel == "" {
-			agentLabel = "(unnamed)"
+		if len(a.SystemPrompt) > maxInstructionsSize {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("agent %s: system_prompt exceeds maximum size of %d bytes", agentLabel, maxInstructionsSize))
+		}
+		// Backward compat: accept claude_md as alias for instructions_md.
+		instructionsMD := a.InstructionsMD
+		if instructionsMD == "" && a.ClaudeMD != "" {
+			instructionsMD = a.ClaudeMD
+		}
+		if len(instructionsMD) > maxInstructionsSize {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("agent %s: instructions_md exceeds maximum size of %d bytes", agentLabel, maxInstructionsSize))
 		}
 		if len(a.SubAgentDescription) > maxDescriptionSize {
 			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("agent %s: sub_agent_description exceeds maximum size of %d bytes", agentLabel, maxDescriptionSize))
@@ -138,7 +314,26 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 			role = models.AgentRoleWorker
 		}
 		skills, _ := json.Marshal(a.Skills)
-		perms, _ := json.Marshal(a.Permissions)
+		perms, err := marshalPermissions(a.Permissions)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
+		if err := policy.CheckOverride(a.Resources); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
+		if err := validateRunAs(a.RunAsUID, a.RunAsGID); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
+		if err := validateSubAgentIsolation(a.SubAgentIsolation); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
+		if err := validateSubAgentPermissionMode(a.SubAgentPermissionMode); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
+		extraClaudeArgs, err := marshalExtraClaudeArgs(a.ExtraClaudeArgs)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "agent "+agentLabel+": "+err.Error())
+		}
 		resources, _ := json.Marshal(a.Resources)
 		subAgentSkills, _ := json.Marshal(a.SubAgentSkills)
 
@@ -146,12 +341,7 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 		if subAgentModel == "" {
 			subAgentModel = "inherit"
 		}
-
-		// Backward compat: accept claude_md as alias for instructions_md.
-		instructionsMD := a.InstructionsMD
-		if instructionsMD == "" && a.ClaudeMD != "" {
-			instructionsMD = a.ClaudeMD
-		}
+		background, isolation, permissionMode := subAgentFrontmatter(a.SubAgentBackground, a.SubAgentIsolation, a.SubAgentPermissionMode)
 
 		team.Agents = append(team.Agents, models.Agent{
 			ID:                  uuid.New().String(),
@@ -161,12 +351,19 @@ func (s *Server) CreateTeam(c *fiber.Ctx) error {
 			SystemPrompt:        a.SystemPrompt,
 			InstructionsMD:      instructionsMD,
 			Skills:              models.JSON(skills),
-			Permissions:         models.JSON(perms),
+			Permissions:         perms,
 			Resources:           models.JSON(resources),
 			SubAgentDescription:  a.SubAgentDescription,
 			SubAgentInstructions: a.SubAgentInstructions,
 			SubAgentModel:        subAgentModel,
 			SubAgentSkills:       models.JSON(subAgentSkills),
+			SubAgentBackground:     background,
+			SubAgentIsolation:      isolation,
+			SubAgentPermissionMode: permissionMode,
+			RunAsUID:             a.RunAsUID,
+			RunAsGID:             a.RunAsGID,
+			SkipWorkspaceChown:   a.SkipWorkspaceChown,
+			ExtraClaudeArgs:      extraClaudeArgs,
 		})
 	}
 
@@ -182,12 +379,12 @@ func (s *Server) UpdateTeam(c *fiber.Ctx) error {
 	id := c.Params("id")
 	var team models.Team
 	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
 
 	var req UpdateTeamRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -195,7 +392,15 @@ func (s *Server) UpdateTeam(c *fiber.Ctx) error {
 		if err := validateName(*req.Name); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
+		slug, err := s.checkTeamSlug(team.OrgID, *req.Name, team.ID)
+		if err != nil {
+			return err
+		}
+		if slug != team.Slug && isTeamActive(team.Status) {
+			return fiber.NewError(fiber.StatusConflict, "stop the team before renaming it: its containers and NATS subjects are named after it")
+		}
 		updates["name"] = *req.Name
+		updates["slug"] = slug
 	}
 	if req.Description != nil {
 		updates["description"] = *req.Description
@@ -237,6 +442,129 @@ func (s *Server) UpdateTeam(c *fiber.Ctx) error {
 		}
 		updates["agent_image"] = *req.AgentImage
 	}
+	if req.ConfigDirMode != nil {
+		if err := validateConfigDirMode(*req.ConfigDirMode); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		mode := *req.ConfigDirMode
+		if mode == "" {
+			mode = models.ConfigDirModeInline
+		}
+		updates["config_dir_mode"] = mode
+	}
+	if req.ResourcePreset != nil {
+		policy, err := s.loadResourcePolicy(c)
+		if err != nil {
+			return err
+		}
+		if err := policy.CheckPreset(*req.ResourcePreset); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["resource_preset"] = *req.ResourcePreset
+	}
+	if req.NamespaceConfig != nil {
+		if err := req.NamespaceConfig.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "namespace_config: "+err.Error())
+		}
+		nsData, _ := json.Marshal(req.NamespaceConfig)
+		updates["namespace_config"] = models.JSON(nsData)
+	}
+	if req.RestartPolicy != nil {
+		if err := req.RestartPolicy.Validate(); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "restart_policy: "+err.Error())
+		}
+		restartData, _ := json.Marshal(req.RestartPolicy)
+		updates["restart_policy"] = models.JSON(restartData)
+	}
+	if req.Labels != nil {
+		if err := validateTeamLabels(req.Labels); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(req.Labels) == 0 {
+			updates["labels"] = models.JSON(nil)
+		} else {
+			labelsData, _ := json.Marshal(req.Labels)
+			updates["labels"] = models.JSON(labelsData)
+		}
+	}
+	if req.Bootstrap != nil {
+		if req.Bootstrap.Script == "" {
+			updates["bootstrap"] = models.JSON(nil)
+		} else {
+			if err := req.Bootstrap.Validate(); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "bootstrap: "+err.Error())
+			}
+			bootstrapData, _ := json.Marshal(req.Bootstrap)
+			updates["bootstrap"] = models.JSON(bootstrapData)
+		}
+	}
+	if req.ExtraWorkspaces != nil {
+		if len(*req.ExtraWorkspaces) == 0 {
+			updates["extra_workspaces"] = models.JSON(nil)
+		} else {
+			if err := protocol.ValidateExtraWorkspaces(*req.ExtraWorkspaces); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			workspacesData, _ := json.Marshal(*req.ExtraWorkspaces)
+			updates["extra_workspaces"] = models.JSON(workspacesData)
+		}
+	}
+	if req.WorkspaceTemplate != nil {
+		if err := runtime.ValidateWorkspaceTemplate(*req.WorkspaceTemplate); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["workspace_template"] = *req.WorkspaceTemplate
+	}
+	if req.HostSelector != nil {
+		if err := validateHostSelector(req.HostSelector); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		if len(req.HostSelector) == 0 {
+			updates["host_selector"] = models.JSON(nil)
+		} else {
+			selectorData, _ := json.Marshal(req.HostSelector)
+			updates["host_selector"] = models.JSON(selectorData)
+		}
+	}
+	if req.MaxConcurrentRuns != nil {
+		if err := validateMaxConcurrentRuns(*req.MaxConcurrentRuns); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["max_concurrent_runs"] = *req.MaxConcurrentRuns
+	}
+	if req.TelemetryLevel != nil {
+		if err := validateTelemetryLevel(*req.TelemetryLevel); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		updates["telemetry_level"] = *req.TelemetryLevel
+	}
+	telemetryChanged := req.TelemetryLevel != nil && *req.TelemetryLevel != team.TelemetryLevel
+	if req.PlanApproval != nil {
+		updates["plan_approval"] = *req.PlanApproval
+	}
+	if req.Memory != nil {
+		updates["memory"] = *req.Memory
+	}
+	if req.KnowledgeBase != nil {
+		updates["knowledge_base"] = *req.KnowledgeBase
+	}
+	if req.WorkingHours != nil {
+		if req.WorkingHours.IsZero() {
+			updates["working_hours"] = models.JSON(nil)
+		} else {
+			if err := req.WorkingHours.Validate(); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "working_hours: "+err.Error())
+			}
+			hoursData, _ := json.Marshal(req.WorkingHours)
+			updates["working_hours"] = models.JSON(hoursData)
+		}
+	}
+	if req.RequiresDeployApproval != nil {
+		if team.RequiresDeployApproval && !*req.RequiresDeployApproval && !IsAdmin(c) {
+			return newAPIError(CodeAdminRequired, "only admins can turn off deploy approval")
+		}
+		updates["requires_deploy_approval"] = *req.RequiresDeployApproval
+	}
 	if req.McpServers != nil {
 		if err := validateMcpServers(req.McpServers); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, err.Error())
@@ -252,19 +580,68 @@ func (s *Server) UpdateTeam(c *fiber.Ctx) error {
 	}
 
 	s.db.Preload("Agents").First(&team, "id = ?", id)
+	if telemetryChanged {
+		s.pushTelemetryLevel(team)
+	}
 	return c.JSON(team)
 }
 
-// DeleteTeam removes a team and cascades to agents.
+// pushTelemetryLevel sends a running team's leader its telemetry level, so
+// that a change applies without a redeploy. Failures are only logged: the
+// stored level still applies when the team is next deployed.
+func (s *Server) pushTelemetryLevel(team models.Team) {
+	if team.Status != models.TeamStatusRunning {
+		return
+	}
+	leader, ok := teamLeader(team)
+	if !ok || !models.ContainerIsUp(leader.ContainerStatus) {
+		return
+	}
+	level := team.TelemetryLevel
+	if level == "" {
+		level = protocol.DefaultTelemetryLevel
+	}
+	msg, err := protocol.NewMessage("api", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{
+		Command: protocol.CommandSetTelemetryLevel,
+		Args:    map[string]string{"level": level},
+	})
+	if err != nil {
+		slog.Error("failed to build telemetry level command", "team", team.Name, "error", err)
+		return
+	}
+	if err := s.publishControlMessage(naming.Slug(team.Name), msg); err != nil {
+		slog.Warn("failed to send telemetry level to the leader", "team", team.Name, "level", level, "error", err)
+		return
+	}
+	slog.Info("telemetry level sent to the leader", "team", team.Name, "level", level)
+}
+
+// DeleteTeam removes a team and cascades to agents, deleting its workspace
+// unless preserve_workspace is set. A running team must be stopped first
+// unless force is set, in which case it is stopped as part of the deletion.
 func (s *Server) DeleteTeam(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	team, op, err := s.lockTeam(c, teamOpDelete)
+	if err != nil {
+		return err
 	}
+	defer s.endTeamOp(team.ID, op)
 
-	if team.Status == models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "stop the team before deleting")
+	if isTeamActive(team.Status) && !c.QueryBool("force") {
+		return fiber.NewError(fiber.StatusConflict, "stop the team before deleting, or pass force=true to stop and delete it")
+	}
+
+	preserveWorkspace := c.QueryBool("preserve_workspace", false)
+	ctx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
+	defer cancel()
+	switch {
+	case team.Status != models.TeamStatusStopped:
+		// A team that failed to deploy may have left infrastructure behind.
+		s.stopTeam(ctx, &team, preserveWorkspace)
+	case !preserveWorkspace:
+		if err := s.runtime.DeleteWorkspace(ctx, team.Name); err != nil {
+			slog.Error("failed to delete workspace", "team", team.Name, "error", err)
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to delete team workspace")
+		}
 	}
 
 	if err := s.db.Select("Agents").Delete(&team).Error; err != nil {
@@ -274,39 +651,130 @@ func (s *Server) DeleteTeam(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// DeleteTeamWorkspace deletes the workspace a stopped team kept, and the
+// agents' work in it. The next deploy starts with an empty workspace.
+func (s *Server) DeleteTeamWorkspace(c *fiber.Ctx) error {
+	team, op, err := s.lockTeam(c, teamOpWorkspace)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(team.ID, op)
+
+	if team.Status != models.TeamStatusStopped {
+		return fiber.NewError(fiber.StatusConflict, "stop the team before deleting its workspace")
+	}
+	if team.WorkspacePath != "" {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf(
+			"the team's workspace is the host directory %s; delete its files on the host", team.WorkspacePath))
+	}
+
+	ctx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
+	defer cancel()
+	if err := s.runtime.DeleteWorkspace(ctx, team.Name); err != nil {
+		slog.Error("failed to delete workspace", "team", team.Name, "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete team workspace")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
 // DeployTeam deploys team infrastructure and all agents.
 func (s *Server) DeployTeam(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	team, op, err := s.lockTeam(c, teamOpDeploy)
+	if err != nil {
+		return err
 	}
+	defer s.endTeamOp(team.ID, op)
 
 	if team.Status == models.TeamStatusRunning {
 		return fiber.NewError(fiber.StatusConflict, "team is already running")
 	}
 
-	// Update status to deploying and clear any previous error message.
-	s.db.Model(&team).Updates(map[string]interface{}{
-		"status":         models.TeamStatusDeploying,
-		"status_message": "",
-	})
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+	if err := s.checkDeployable(&team); err != nil {
+		return err
+	}
+
+	// Members' deploys of a team that requires approval wait for an admin.
+	if team.RequiresDeployApproval && !IsAdmin(c) {
+		return s.requestApproval(c, team, models.ApprovalActionDeploy, nil)
+	}
+
+	trigger := deploymentTrigger{Trigger: models.DeploymentTriggerManual, TriggeredBy: GetUserID(c)}
+	if _, err := s.startDeploy(&team, trigger); err != nil {
+		return err
+	}
+	return c.JSON(team)
+}
 
-	// Deep copy agents for the background goroutine to avoid data races
-	// with the JSON serialization of the response below.
-	asyncTeam := team
-	asyncTeam.Agents = make([]models.Agent, len(team.Agents))
-	copy(asyncTeam.Agents, team.Agents)
+// checkDeployable checks that the team can be deployed: its workspace is
+// not shared unsafely and its skills are in the catalog.
+func (s *Server) checkDeployable(team *models.Team) error {
+	// Pick up a slug changed by the naming rules; teams whose slug collided
+	// when slugs were synced must be renamed.
+	if team.Slug != naming.Slug(team.Name) {
+		slug, err := s.checkTeamSlug(team.OrgID, team.Name, team.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.db.Model(team).Update("slug", slug).Error; err != nil {
+			return fiber.NewError(fiber.StatusConflict, "team name conflicts with another team")
+		}
+		team.Slug = slug
+	}
+
+	if err := s.checkWorkspaceSharing(*team); err != nil {
+		return err
+	}
+	return s.checkSkillsCatalog(*team)
+}
 
-	// Deploy in background.
-	go s.deployTeamAsync(asyncTeam)
+// startDeploy marks the team deploying and queues its deployment job, which
+// records trigger on the deployment. The caller must hold the team's deploy
+// operation.
+func (s *Server) startDeploy(team *models.Team, trigger deploymentTrigger) (*models.Job, error) {
+	// Update status to deploying, clear any previous error message, and start
+	// a new conversation: the fresh leader container has no prior session.
+	conversationID := uuid.New().String()
+	s.db.Model(team).Updates(map[string]interface{}{
+		"status":          models.TeamStatusDeploying,
+		"status_message":  "",
+		"conversation_id": conversationID,
+		"chat_sequence":   0,
+	})
 
 	team.Status = models.TeamStatusDeploying
 	team.StatusMessage = ""
-	return c.JSON(team)
+	team.ConversationID = conversationID
+
+	// Deploy in a background job, which claims the team once this request
+	// releases it.
+	job, err := s.enqueueTeamJob(jobTeamDeploy, *team, trigger)
+	if err != nil {
+		slog.Error("failed to queue deployment", "team", team.Name, "error", err)
+		s.db.Model(team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": "Failed to queue deployment",
+		})
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to queue deployment")
+	}
+	return job, nil
 }
 
-func (s *Server) deployTeamAsync(team models.Team) {
+// deployTeamAsync deploys the team's infrastructure and leader. Cancelling
+// ctx aborts the deployment and leaves the team in error.
+func (s *Server) deployTeamAsync(ctx context.Context, team models.Team) {
+	// The log ends after everything else, once the final status is set.
+	deployLog := s.deployLogs.start(team.ID)
+	defer deployLog.finish()
+	ctx = runtime.WithProgress(ctx, deployLog.add)
+
+	// Registered first so they run last, after a recovered panic has set the
+	// final status.
+	defer s.publishDeployOutcome(team.ID)
+	deployment := s.startDeployment(team, deploymentTriggerFrom(ctx, models.DeploymentTriggerManual))
+	defer s.finishDeployment(deployment)
 	defer func() {
 		if r := recover(); r != nil {
 			slog.Error("panic in deployTeamAsync", "team", team.Name, "panic", r)
@@ -317,39 +785,61 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		}
 	}()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
 	// Load settings from DB to pass as environment variables to agent containers.
 	envFromSettings := s.LoadSettingsEnv(team.OrgID)
 
+	natsCfg, err := runtime.NATSConfigFromEnv(envFromSettings)
+	var streamLimits agentNats.StreamLimits
+	if err == nil {
+		streamLimits, err = agentNats.StreamLimitsFromEnv(envFromSettings)
+	}
+	if err != nil {
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": "Invalid NATS settings: " + err.Error(),
+		})
+		return
+	}
+
 	// Deploy infrastructure.
 	infraCfg := runtime.InfraConfig{
 		TeamName:      team.Name,
+		TeamID:        team.ID,
 		NATSEnabled:   true,
+		NATS:          natsCfg,
 		WorkspacePath: team.WorkspacePath,
+		DockerHost:    team.DockerHost,
+	}
+	if len(team.NamespaceConfig) > 0 {
+		_ = json.Unmarshal(team.NamespaceConfig, &infraCfg.Namespace)
+	}
+	infraCfg.Restart = teamRestartPolicy(team)
+	if len(team.HostSelector) > 0 {
+		_ = json.Unmarshal(team.HostSelector, &infraCfg.HostSelector)
 	}
 
+	runtime.ReportProgress(ctx, "Deploying team infrastructure")
 	if err := s.runtime.DeployInfra(ctx, infraCfg); err != nil {
 		slog.Error("failed to deploy infrastructure", "team", team.Name, "error", err)
 		s.db.Model(&team).Updates(map[string]interface{}{
 			"status":         models.TeamStatusError,
 			"status_message": "Failed to deploy infrastructure: " + err.Error(),
 		})
+		runtime.ReportProgress(ctx, "Failed to deploy infrastructure: %v", err)
 		return
 	}
-
-	natsURL := s.runtime.GetNATSURL(team.Name)
-	provider := team.Provider
-	if provider == "" {
-		provider = models.ProviderClaude
-	}
+	s.recordDockerHost(team)
+	s.ensureTeamStream(ctx, team, streamLimits)
 
 	// If the team uses Ollama, set up the shared Ollama container.
 	var ollamaSetupDone bool
 	if team.ModelProvider == models.ModelProviderOllama {
-		if om, ok := s.runtime.(runtime.OllamaManager); ok {
+		if om, ok := s.sharedRuntime().(runtime.OllamaManager); ok {
 			s.db.Model(&team).Update("status_message", "Starting Ollama container...")
+			runtime.ReportProgress(ctx, "Starting Ollama container")
 
 			containerID, err := om.EnsureOllama(ctx)
 			if err != nil {
@@ -362,7 +852,7 @@ func (s *Server) deployTeamAsync(team models.Team) {
 			}
 
 			// Connect Ollama to team network so agent containers can resolve it.
-			teamNetName := runtime.TeamNetworkName(SanitizeName(team.Name))
+			teamNetName := runtime.TeamNetworkName(naming.Slug(team.Name))
 			if err := om.ConnectOllamaToNetwork(ctx, teamNetName); err != nil {
 				slog.Error("failed to connect ollama to network", "team", team.Name, "error", err)
 				s.db.Model(&team).Updates(map[string]interface{}{
@@ -390,6 +880,7 @@ func (s *Server) deployTeamAsync(team models.Team) {
 
 				if err := om.PullOllamaModel(ctx, ollamaModel, func(status string) {
 					s.db.Model(&team).Update("status_message", "Pulling model: "+status)
+					runtime.ReportProgress(ctx, "Pulling model %s: %s", ollamaModel, status)
 				}); err != nil {
 					slog.Error("failed to pull ollama model", "team", team.Name, "model", ollamaModel, "error", err)
 					s.db.Model(&team).Updates(map[string]interface{}{
@@ -403,6 +894,7 @@ func (s *Server) deployTeamAsync(team models.Team) {
 				// weights into RAM during deploy, avoiding a multi-minute cold start
 				// when the user sends their first chat message.
 				s.db.Model(&team).Update("status_message", "Warming up model: "+ollamaModel+"...")
+				runtime.ReportProgress(ctx, "Warming up model %s", ollamaModel)
 				if err := om.WarmUpOllamaModel(ctx, ollamaModel); err != nil {
 					slog.Warn("ollama model warm-up failed (non-fatal)", "team", team.Name, "model", ollamaModel, "error", err)
 					// Non-fatal: the model will load on first request (slower).
@@ -416,29 +908,85 @@ func (s *Server) deployTeamAsync(team models.Team) {
 			slog.Warn("runtime does not support Ollama management", "team", team.Name)
 		}
 	}
-	_ = ollamaSetupDone // used for env injection below
 
-	// Build team member list for the leader's instructions.
-	var teamMembers []runtime.TeamMemberInfo
-	for _, a := range team.Agents {
-		teamMembers = append(teamMembers, runtime.TeamMemberInfo{
-			Name:      SanitizeName(a.Name),
-			Role:      a.Role,
-			Specialty: a.Specialty,
+	leader, agentCfg, err := s.leaderAgentConfig(ctx, &team, envFromSettings, ollamaSetupDone)
+	if err != nil {
+		slog.Error("failed to prepare leader agent", "team", team.Name, "error", err)
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": err.Error(),
 		})
+		return
 	}
 
+	runtime.ReportProgress(ctx, "Deploying leader %s", leader.Name)
+	instance, err := s.runtime.DeployAgent(ctx, agentCfg)
+	if err != nil {
+		slog.Error("failed to deploy leader agent", "agent", leader.Name, "error", err)
+		s.db.Model(leader).Updates(map[string]interface{}{
+			"container_status": models.ContainerStatusError,
+		})
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusError,
+			"status_message": err.Error(),
+		})
+		runtime.ReportProgress(ctx, "Failed to deploy leader %s: %v", leader.Name, err)
+		return
+	}
+
+	s.db.Model(leader).Updates(map[string]interface{}{
+		"container_id":     instance.ID,
+		"container_status": models.ContainerStatusRunning,
+	})
+	s.recordDeployedContainer(ctx, deployment, leader.Name, agentCfg.Image, instance.ID)
+
+	s.db.Model(&team).Update("status", models.TeamStatusRunning)
+	if _, err := models.RecordTeamRevision(s.db, team.ID); err != nil {
+		slog.Error("failed to record team revision", "team", team.Name, "error", err)
+	}
+	slog.Info("team deployed successfully", "team", team.Name)
+	runtime.ReportProgress(ctx, "Team deployed")
+
+	// Start relay goroutine: subscribes to team NATS and saves agent
+	// responses as TaskLogs so StreamActivity WebSocket delivers them to UI.
+	s.startTeamRelay(team.ID, team.Name)
+}
+
+// leaderAgentConfig writes the team's generated workspace files and builds
+// the runtime configuration of its leader container. It ensures the shared
+// knowledge-base services the leader needs are running, but leaves the
+// team's NATS and workspace volume alone. ollamaSetupDone reports whether
+// deployment already started Ollama. Errors are user-facing status messages.
+func (s *Server) leaderAgentConfig(ctx context.Context, team *models.Team, envFromSettings map[string]string, ollamaSetupDone bool) (*models.Agent, runtime.AgentConfig, error) {
+	natsURL := s.runtime.GetNATSURL(team.Name)
+	provider := team.Provider
+	if provider == "" {
+		provider = models.ProviderClaude
+	}
+
+	// Build team member list for the leader's instructions.
+	teamMembers := teamMemberInfos(team.Agents)
+
 	// Find the leader agent and extract its skills before building sub-agent files.
-	var leaderSkills json.RawMessage
-	var leaderSkillConfigs []protocol.SkillConfig
-	for _, a := range team.Agents {
-		if a.Role == models.AgentRoleLeader {
-			if len(a.SubAgentSkills) > 0 && string(a.SubAgentSkills) != "null" {
-				leaderSkills = json.RawMessage(a.SubAgentSkills)
-				_ = json.Unmarshal(a.SubAgentSkills, &leaderSkillConfigs)
-			}
-			break
+	leaderSkills, leaderSkillConfigs := leaderGlobalSkills(team.Agents)
+
+	// In "separate" mode generated config lives on dedicated mounts, so
+	// nothing is written into the host workspace; the sidecar writes it instead.
+	// In "shared" mode it is written into the team's worktree.
+	writeHostConfig := team.WorkspacePath != "" && team.ConfigDirMode != models.ConfigDirModeSeparate
+	if team.WorkspacePath != "" && team.ConfigDirMode == models.ConfigDirModeGitignore {
+		if err := runtime.EnsureWorkspaceGitignore(team.WorkspacePath); err != nil {
+			slog.Error("failed to update workspace .gitignore", "team", team.Name, "error", err)
+		}
+	}
+	configRoot := team.WorkspacePath
+	if team.WorkspacePath != "" && team.ConfigDirMode == models.ConfigDirModeShared {
+		worktree, err := runtime.PrepareSharedWorkspace(ctx, team.WorkspacePath, team.Name)
+		if err != nil {
+			slog.Error("failed to prepare shared workspace", "team", team.Name, "error", err)
+			return nil, runtime.AgentConfig{}, fmt.Errorf("Failed to prepare shared workspace: %w", err)
 		}
+		configRoot = worktree
 	}
 
 	// Setup workspace files for all agents and deploy only the leader container.
@@ -449,52 +997,20 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		agent := &team.Agents[i]
 
 		if agent.Role != models.AgentRoleLeader {
+			subInfo, content := workerSubAgentFile(provider, agent, leaderSkills, leaderSkillConfigs)
+			filename := runtime.SubAgentFileName(agent.Name)
+			subAgentFiles[filename] = content
 			if provider == models.ProviderOpenCode {
 				// OpenCode sub-agent files go to .opencode/agents/
-				subInfo := runtime.SubAgentInfo{
-					Name:         agent.Name,
-					Description:  agent.SubAgentDescription,
-					Instructions: agent.SubAgentInstructions,
-					Model:        agent.SubAgentModel,
-					Skills:       json.RawMessage(agent.SubAgentSkills),
-					ClaudeMD:     agent.InstructionsMD,
-				}
-				filename := runtime.SubAgentFileName(agent.Name)
-				subAgentFiles[filename] = runtime.GenerateOpenCodeSubAgentContent(subInfo, leaderSkillConfigs)
 				openCodeWorkers = append(openCodeWorkers, subInfo)
-			} else {
+			} else if writeHostConfig {
 				// Claude sub-agent files go to .claude/agents/
-				info := runtime.AgentWorkspaceInfo{
-					Name:         agent.Name,
-					Role:         agent.Role,
-					Specialty:    agent.Specialty,
-					SystemPrompt: agent.SystemPrompt,
-					ClaudeMD:     agent.InstructionsMD,
-					Skills:       json.RawMessage(agent.Skills),
-				}
-				subInfo := runtime.SubAgentInfo{
-					Name:         agent.Name,
-					Description:  agent.SubAgentDescription,
-					Instructions: agent.SubAgentInstructions,
-					Model:        agent.SubAgentModel,
-					Skills:       json.RawMessage(agent.SubAgentSkills),
-					GlobalSkills: leaderSkills,
-					ClaudeMD:     agent.InstructionsMD,
-				}
-				if subInfo.ClaudeMD == "" {
-					subInfo.ClaudeMD = runtime.GenerateClaudeMD(info)
-				}
-				filename := runtime.SubAgentFileName(agent.Name)
-				subAgentFiles[filename] = runtime.GenerateSubAgentContent(subInfo)
-
-				if team.WorkspacePath != "" {
-					if _, err := runtime.SetupSubAgentFile(team.WorkspacePath, subInfo); err != nil {
-						slog.Error("failed to setup sub-agent file", "agent", agent.Name, "error", err)
-					}
+				if _, err := runtime.SetupSubAgentFile(configRoot, subInfo); err != nil {
+					slog.Error("failed to setup sub-agent file", "agent", agent.Name, "error", err)
 				}
 			}
 		} else {
-			if team.WorkspacePath != "" && provider == models.ProviderClaude {
+			if writeHostConfig && provider == models.ProviderClaude {
 				info := runtime.AgentWorkspaceInfo{
 					Name:         agent.Name,
 					Role:         agent.Role,
@@ -504,7 +1020,7 @@ func (s *Server) deployTeamAsync(team models.Team) {
 					Skills:       json.RawMessage(agent.Skills),
 					TeamMembers:  teamMembers,
 				}
-				if _, err := runtime.SetupAgentWorkspace(team.WorkspacePath, info); err != nil {
+				if _, err := runtime.SetupAgentWorkspace(configRoot, info); err != nil {
 					slog.Error("failed to setup agent workspace", "agent", agent.Name, "error", err)
 				}
 			}
@@ -514,119 +1030,61 @@ func (s *Server) deployTeamAsync(team models.Team) {
 
 	// Write OpenCode workspace to host path so the directory exists before
 	// Docker tries to bind-mount it (os.Stat in DeployAgent would fail otherwise).
-	if team.WorkspacePath != "" && provider == models.ProviderOpenCode && leader != nil {
+	if writeHostConfig && provider == models.ProviderOpenCode && leader != nil {
 		leaderSub := runtime.SubAgentInfo{
 			Name:        leader.Name,
 			Description: leader.Specialty,
 			Skills:      json.RawMessage(leader.Skills),
 			ClaudeMD:    leader.InstructionsMD,
 		}
-		if err := runtime.SetupOpenCodeWorkspace(team.WorkspacePath, team.Name, leaderSub, openCodeWorkers, leaderSkillConfigs); err != nil {
+		if err := runtime.SetupOpenCodeWorkspace(configRoot, team.Name, leaderSub, openCodeWorkers, leaderSkillConfigs); err != nil {
 			slog.Error("failed to setup opencode workspace", "team", team.Name, "error", err)
 		}
 	}
 
 	if leader == nil {
-		slog.Error("no leader agent found in team", "team", team.Name)
-		s.db.Model(&team).Updates(map[string]interface{}{
-			"status":         models.TeamStatusError,
-			"status_message": "No leader agent found in team configuration",
-		})
-		return
+		return nil, runtime.AgentConfig{}, errors.New("No leader agent found in team configuration")
 	}
 
-	var res runtime.ResourceConfig
-	if len(leader.Resources) > 0 {
-		_ = json.Unmarshal(leader.Resources, &res)
-	}
+	res := resources.ForLeader(s.db, *team, *leader)
 
 	// Generate leader instructions content based on provider.
-	var instructionsMDContent string
-	if provider == models.ProviderOpenCode {
-		if leader.InstructionsMD != "" {
-			instructionsMDContent = leader.InstructionsMD
-		} else {
-			leaderSubInfo := runtime.SubAgentInfo{
-				Name:        leader.Name,
-				Description: leader.Specialty,
-				Skills:      json.RawMessage(leader.Skills),
-				ClaudeMD:    leader.InstructionsMD,
-			}
-			workers := make([]runtime.SubAgentInfo, 0)
-			for _, a := range team.Agents {
-				if a.Role != models.AgentRoleLeader {
-					workers = append(workers, runtime.SubAgentInfo{
-						Name:        a.Name,
-						Description: a.SubAgentDescription,
-					})
-				}
-			}
-			instructionsMDContent = runtime.GenerateOpenCodeAgentsMD(team.Name, leaderSubInfo, workers)
-		}
-	} else {
-		leaderInfo := runtime.AgentWorkspaceInfo{
-			Name:         leader.Name,
-			Role:         leader.Role,
-			Specialty:    leader.Specialty,
-			SystemPrompt: leader.SystemPrompt,
-			ClaudeMD:     leader.InstructionsMD,
-			Skills:       json.RawMessage(leader.Skills),
-			TeamMembers:  teamMembers,
-		}
-		instructionsMDContent = leader.InstructionsMD
-		if instructionsMDContent == "" {
-			instructionsMDContent = runtime.GenerateClaudeMD(leaderInfo)
-		}
-	}
+	instructionsMDContent := leaderInstructions(*team, provider, leader, teamMembers)
 
 	// Collect all unique skills from all agents for sidecar installation.
-	type skillKey struct{ RepoURL, SkillName string }
-	skillsSet := map[skillKey]struct{}{}
-	var allSkills []protocol.SkillConfig
-	for _, a := range team.Agents {
-		var agentSkills []protocol.SkillConfig
-		if err := json.Unmarshal(a.SubAgentSkills, &agentSkills); err == nil {
-			for _, s := range agentSkills {
-				key := skillKey{s.RepoURL, s.SkillName}
-				if s.RepoURL != "" && s.SkillName != "" {
-					if _, exists := skillsSet[key]; !exists {
-						skillsSet[key] = struct{}{}
-						allSkills = append(allSkills, s)
-					}
-				}
-			}
-		} else {
-			var strSkills []string
-			if err := json.Unmarshal(a.SubAgentSkills, &strSkills); err == nil {
-				for _, s := range strSkills {
-					idx := strings.LastIndex(s, ":")
-					if idx <= 0 || idx == len(s)-1 {
-						continue
-					}
-					repoURL := s[:idx]
-					skillName := s[idx+1:]
-					if repoURL == "" || skillName == "" {
-						continue
-					}
-					if !strings.HasPrefix(repoURL, "https://") {
-						repoURL = "https://github.com/" + repoURL
-					}
-					cfg := protocol.SkillConfig{RepoURL: repoURL, SkillName: skillName}
-					key := skillKey{cfg.RepoURL, cfg.SkillName}
-					if _, exists := skillsSet[key]; !exists {
-						skillsSet[key] = struct{}{}
-						allSkills = append(allSkills, cfg)
-					}
-				}
-			}
-		}
-	}
+	allSkills := teamSkillConfigs(team.Agents)
 	skillsJSON, _ := json.Marshal(allSkills)
 
 	agentEnv := envFromSettings
 	if len(allSkills) > 0 {
 		agentEnv["AGENT_SKILLS_INSTALL"] = string(skillsJSON)
 	}
+	if bootstrap := teamBootstrap(*team); bootstrap != "" {
+		agentEnv["AGENT_BOOTSTRAP"] = bootstrap
+	}
+	scaffold, err := teamWorkspaceScaffold(*team)
+	if err != nil {
+		slog.Error("failed to render workspace template", "team", team.Name, "error", err)
+		return nil, runtime.AgentConfig{}, fmt.Errorf("Failed to render workspace template: %w", err)
+	}
+	if scaffold != "" {
+		agentEnv["AGENT_WORKSPACE_SCAFFOLD"] = scaffold
+	}
+	if team.MaxConcurrentRuns > 0 {
+		agentEnv["AGENT_MAX_CONCURRENT_RUNS"] = strconv.Itoa(team.MaxConcurrentRuns)
+	}
+	if team.TelemetryLevel != "" {
+		agentEnv["AGENT_TELEMETRY_LEVEL"] = team.TelemetryLevel
+	}
+	if team.PlanApproval {
+		agentEnv["AGENT_PLAN_APPROVAL"] = "true"
+	}
+	if team.Memory {
+		agentEnv["AGENT_MEMORY"] = "true"
+		if memory := models.LatestTeamMemory(s.db, team.ID); memory != "" {
+			agentEnv["AGENT_MEMORY_CONTEXT"] = memory
+		}
+	}
 
 	// When model_provider is set, only inject the relevant API key to the container
 	// instead of passing all provider keys. This prevents leaking unnecessary credentials.
@@ -645,37 +1103,38 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		agentEnv["AGENT_MCP_SERVERS"] = string(team.McpServers)
 	}
 
-	// Auto-inject RAG MCP server if the org has ready knowledge base documents.
+	// Describe the org's custom tools; the sidecar exposes them to the agent
+	// through its tools MCP server and proxies calls back to the API.
+	if specs, err := tools.Specs(s.db, team.OrgID); err != nil {
+		slog.Error("failed to load custom tools", "team", team.Name, "error", err)
+	} else if len(specs) > 0 {
+		specsJSON, _ := json.Marshal(specs)
+		agentEnv["AGENT_CUSTOM_TOOLS"] = string(specsJSON)
+	}
+
+	// Auto-inject RAG MCP server if the org has ready knowledge base documents
+	// or the team has its own knowledge base.
 	var ragDocCount int64
 	s.db.Model(&models.Document{}).Where("org_id = ? AND status = ?", team.OrgID, models.DocStatusReady).Count(&ragDocCount)
 
-	if ragDocCount > 0 {
-		ragNetName := runtime.TeamNetworkName(SanitizeName(team.Name))
-		s.db.Model(&team).Update("status_message", "Setting up knowledge base...")
+	if ragDocCount > 0 || team.KnowledgeBase {
+		ragNetName := runtime.TeamNetworkName(naming.Slug(team.Name))
+		s.db.Model(team).Update("status_message", "Setting up knowledge base...")
+		runtime.ReportProgress(ctx, "Setting up knowledge base")
 
 		// Ensure Qdrant is running and connected to the team network.
-		if qm, ok := s.runtime.(runtime.QdrantManager); ok {
+		if qm, ok := s.sharedRuntime().(runtime.QdrantManager); ok {
 			if _, err := qm.EnsureQdrant(ctx); err != nil {
-				slog.Error("failed to start qdrant for RAG", "team", team.Name, "error", err)
-				s.db.Model(&team).Updates(map[string]interface{}{
-					"status":         models.TeamStatusError,
-					"status_message": "Failed to start Qdrant: " + err.Error(),
-				})
-				return
+				return nil, runtime.AgentConfig{}, fmt.Errorf("Failed to start Qdrant: %w", err)
 			}
 			if err := qm.ConnectQdrantToNetwork(ctx, ragNetName); err != nil {
-				slog.Error("failed to connect qdrant to network", "team", team.Name, "error", err)
-				s.db.Model(&team).Updates(map[string]interface{}{
-					"status":         models.TeamStatusError,
-					"status_message": "Failed to connect Qdrant to network: " + err.Error(),
-				})
-				return
+				return nil, runtime.AgentConfig{}, fmt.Errorf("Failed to connect Qdrant to network: %w", err)
 			}
 		}
 
 		// Ensure Ollama is running for query-time embeddings (may already be set up for Ollama provider).
 		if !ollamaSetupDone {
-			if om, ok := s.runtime.(runtime.OllamaManager); ok {
+			if om, ok := s.sharedRuntime().(runtime.OllamaManager); ok {
 				if _, err := om.EnsureOllama(ctx); err != nil {
 					slog.Error("failed to start ollama for RAG embeddings", "team", team.Name, "error", err)
 					// Non-fatal: search will fail but team can still deploy.
@@ -686,14 +1145,9 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		}
 
 		// Ensure RAG MCP server is running and connected.
-		if rm, ok := s.runtime.(runtime.RagMcpManager); ok {
+		if rm, ok := s.sharedRuntime().(runtime.RagMcpManager); ok {
 			if _, err := rm.EnsureRagMcp(ctx); err != nil {
-				slog.Error("failed to start rag-mcp", "team", team.Name, "error", err)
-				s.db.Model(&team).Updates(map[string]interface{}{
-					"status":         models.TeamStatusError,
-					"status_message": "Failed to start RAG MCP server: " + err.Error(),
-				})
-				return
+				return nil, runtime.AgentConfig{}, fmt.Errorf("Failed to start RAG MCP server: %w", err)
 			}
 			if err := rm.ConnectRagMcpToNetwork(ctx, ragNetName); err != nil {
 				slog.Error("failed to connect rag-mcp to network", "team", team.Name, "error", err)
@@ -701,11 +1155,16 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		}
 
 		// Inject knowledge-base MCP server config into AGENT_MCP_SERVERS (in-memory only).
+		ragHeaders := map[string]string{"X-Org-ID": team.OrgID}
+		if team.KnowledgeBase {
+			// Enables search_team_knowledge on the team's own collection.
+			ragHeaders["X-Team-ID"] = team.ID
+		}
 		ragMcpEntry := map[string]interface{}{
 			"name":      "knowledge-base",
 			"transport": "http",
 			"url":       runtime.RagMcpInternalURL + "/mcp",
-			"headers":   map[string]string{"X-Org-ID": team.OrgID},
+			"headers":   ragHeaders,
 		}
 
 		var mcpServers []interface{}
@@ -740,6 +1199,9 @@ func (s *Server) deployTeamAsync(team models.Team) {
 			agentEnv["OPENCODE_MODEL"] = m
 		}
 	}
+	if provider != models.ProviderOpenCode && hasExtraClaudeArgs(*leader) {
+		agentEnv["CLAUDE_EXTRA_ARGS"] = string(leader.ExtraClaudeArgs)
+	}
 
 	agentCfg := runtime.AgentConfig{
 		Name:          leader.Name,
@@ -754,32 +1216,234 @@ func (s *Server) deployTeamAsync(team models.Team) {
 		WorkspacePath: team.WorkspacePath,
 		SubAgentFiles: subAgentFiles,
 		Env:           agentEnv,
-	}
+		ConfigDirMode: team.ConfigDirMode,
+		RunAsUID:      leader.RunAsUID,
+		RunAsGID:      leader.RunAsGID,
+	}
+	agentCfg.SkipWorkspaceChown = leader.SkipWorkspaceChown
+	agentCfg.ExtraWorkspaces = teamExtraWorkspaces(*team)
+	agentCfg.Restart = teamRestartPolicy(*team)
+	return leader, agentCfg, nil
+}
 
-	instance, err := s.runtime.DeployAgent(ctx, agentCfg)
-	if err != nil {
-		slog.Error("failed to deploy leader agent", "agent", leader.Name, "error", err)
-		s.db.Model(leader).Updates(map[string]interface{}{
-			"container_status": models.ContainerStatusError,
-		})
-		s.db.Model(&team).Updates(map[string]interface{}{
-			"status":         models.TeamStatusError,
-			"status_message": err.Error(),
+// teamMemberInfos builds the team roster included in the leader's instructions.
+func teamMemberInfos(agents []models.Agent) []runtime.TeamMemberInfo {
+	var members []runtime.TeamMemberInfo
+	for _, a := range agents {
+		members = append(members, runtime.TeamMemberInfo{
+			Name:      naming.Slug(a.Name),
+			Role:      a.Role,
+			Specialty: a.Specialty,
 		})
+	}
+	return members
+}
+
+// leaderGlobalSkills returns the leader's sub-agent skills, which are shared
+// with every worker, both as raw JSON and as parsed skill configs.
+func leaderGlobalSkills(agents []models.Agent) (json.RawMessage, []protocol.SkillConfig) {
+	var raw json.RawMessage
+	var configs []protocol.SkillConfig
+	for _, a := range agents {
+		if a.Role == models.AgentRoleLeader {
+			if len(a.SubAgentSkills) > 0 && string(a.SubAgentSkills) != "null" {
+				raw = json.RawMessage(a.SubAgentSkills)
+				_ = json.Unmarshal(a.SubAgentSkills, &configs)
+			}
+			break
+		}
+	}
+	return raw, configs
+}
+
+// teamBootstrap returns the team's bootstrap config as JSON for the
+// AGENT_BOOTSTRAP env var, or "" when the team has no bootstrap script.
+func teamBootstrap(team models.Team) string {
+	if len(team.Bootstrap) == 0 {
+		return ""
+	}
+	var bootstrap protocol.BootstrapConfig
+	if err := json.Unmarshal(team.Bootstrap, &bootstrap); err != nil || bootstrap.Script == "" {
+		return ""
+	}
+	return string(team.Bootstrap)
+}
+
+// teamWorkspaceScaffold renders the team's workspace template as JSON for
+// the AGENT_WORKSPACE_SCAFFOLD env var, or "" when the team has none.
+func teamWorkspaceScaffold(team models.Team) (string, error) {
+	data := runtime.WorkspaceTemplateData{
+		Team:        team.Name,
+		Description: team.Description,
+		Agents:      teamMemberInfos(team.Agents),
+	}
+	if leader, ok := teamLeader(team); ok {
+		data.Leader = naming.Slug(leader.Name)
+	}
+	scaffold, err := runtime.RenderWorkspaceTemplate(team.WorkspaceTemplate, data)
+	if err != nil || scaffold == nil {
+		return "", err
+	}
+	raw, _ := json.Marshal(scaffold)
+	return string(raw), nil
+}
+
+// validateHostSelector checks a team's host_selector, which uses the same
+// keys and values as team labels.
+func validateHostSelector(selector map[string]string) error {
+	for k, v := range selector {
+		if !teamLabelKeyPattern.MatchString(k) {
+			return fmt.Errorf("host_selector: invalid key %q: must be 1-63 letters, digits, '.', '_', '-' or '/', starting with a letter or digit", k)
+		}
+		if len(v) > maxTeamLabelValueLength {
+			return fmt.Errorf("host_selector: value of %q exceeds %d characters", k, maxTeamLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// recordDockerHost saves the host the runtime placed team on, so that later
+// deploys go back to the host with its workspace volume.
+func (s *Server) recordDockerHost(team models.Team) {
+	hp, ok := s.runtime.(runtime.HostPlacer)
+	if !ok {
 		return
 	}
+	if host := hp.TeamHost(team.Name); host != "" && host != team.DockerHost {
+		s.db.Model(&team).Update("docker_host", host)
+	}
+}
 
-	s.db.Model(leader).Updates(map[string]interface{}{
-		"container_id":     instance.ID,
-		"container_status": models.ContainerStatusRunning,
-	})
+// teamExtraWorkspaces returns the team's extra workspaces, if any.
+func teamExtraWorkspaces(team models.Team) []protocol.ExtraWorkspace {
+	var workspaces []protocol.ExtraWorkspace
+	if len(team.ExtraWorkspaces) > 0 {
+		_ = json.Unmarshal(team.ExtraWorkspaces, &workspaces)
+	}
+	return workspaces
+}
 
-	s.db.Model(&team).Update("status", models.TeamStatusRunning)
-	slog.Info("team deployed successfully", "team", team.Name)
+// teamRestartPolicy returns the team's restart policy, the zero policy if
+// it has none.
+func teamRestartPolicy(team models.Team) runtime.RestartPolicy {
+	var policy runtime.RestartPolicy
+	if len(team.RestartPolicy) > 0 {
+		_ = json.Unmarshal(team.RestartPolicy, &policy)
+	}
+	return policy
+}
 
-	// Start relay goroutine: subscribes to team NATS and saves agent
-	// responses as TaskLogs so StreamActivity WebSocket delivers them to UI.
-	s.startTeamRelay(team.ID, team.Name)
+// validateTelemetryLevel checks a team's telemetry_level, where "" selects
+// the default.
+func validateTelemetryLevel(level string) error {
+	if level != "" && !protocol.ValidTelemetryLevel(level) {
+		return fmt.Errorf("telemetry_level must be %s, %s or %s", protocol.TelemetryMinimal, protocol.TelemetryNormal, protocol.TelemetryVerbose)
+	}
+	return nil
+}
+
+// validateMaxConcurrentRuns checks a team's max_concurrent_runs, where 0
+// means no limit.
+func validateMaxConcurrentRuns(n int) error {
+	if n < 0 || n > protocol.MaxConcurrentRunsLimit {
+		return fmt.Errorf("max_concurrent_runs must be between 1 and %d, or 0 for no limit", protocol.MaxConcurrentRunsLimit)
+	}
+	return nil
+}
+
+// teamSkillConfigs returns the unique installable skills configured in the
+// sub_agent_skills of the agents, accepting both {repo_url, skill_name}
+// objects and legacy "repo:skill" strings.
+func teamSkillConfigs(agents []models.Agent) []protocol.SkillConfig {
+	seen := map[protocol.SkillConfig]bool{}
+	var allSkills []protocol.SkillConfig
+	for _, a := range agents {
+		for _, s := range protocol.ParseSkillConfigs(json.RawMessage(a.SubAgentSkills)) {
+			if !seen[s] {
+				seen[s] = true
+				allSkills = append(allSkills, s)
+			}
+		}
+	}
+	return allSkills
+}
+
+// workerSubAgentFile generates the sub-agent file content for a worker agent
+// in the given provider's format.
+func workerSubAgentFile(provider string, agent *models.Agent, leaderSkills json.RawMessage, leaderSkillConfigs []protocol.SkillConfig) (runtime.SubAgentInfo, string) {
+	if provider == models.ProviderOpenCode {
+		subInfo := runtime.SubAgentInfo{
+			Name:         agent.Name,
+			Description:  agent.SubAgentDescription,
+			Instructions: agent.SubAgentInstructions,
+			Model:        agent.SubAgentModel,
+			Skills:       json.RawMessage(agent.SubAgentSkills),
+			ClaudeMD:     agent.InstructionsMD,
+		}
+		return subInfo, runtime.GenerateOpenCodeSubAgentContent(subInfo, leaderSkillConfigs)
+	}
+
+	info := runtime.AgentWorkspaceInfo{
+		Name:         agent.Name,
+		Role:         agent.Role,
+		Specialty:    agent.Specialty,
+		SystemPrompt: agent.SystemPrompt,
+		ClaudeMD:     agent.InstructionsMD,
+		Skills:       json.RawMessage(agent.Skills),
+	}
+	subInfo := runtime.SubAgentInfo{
+		Name:           agent.Name,
+		Description:    agent.SubAgentDescription,
+		Instructions:   agent.SubAgentInstructions,
+		Model:          agent.SubAgentModel,
+		Skills:         json.RawMessage(agent.SubAgentSkills),
+		GlobalSkills:   leaderSkills,
+		ClaudeMD:       agent.InstructionsMD,
+		Background:     agent.SubAgentBackground,
+		Isolation:      agent.SubAgentIsolation,
+		PermissionMode: agent.SubAgentPermissionMode,
+	}
+	if subInfo.ClaudeMD == "" {
+		subInfo.ClaudeMD = runtime.GenerateClaudeMD(info)
+	}
+	return subInfo, runtime.GenerateSubAgentContent(subInfo)
+}
+
+// leaderInstructions generates the leader's CLAUDE.md (Claude) or AGENTS.md
+// (OpenCode) content, honouring a custom InstructionsMD when one is set.
+func leaderInstructions(team models.Team, provider string, leader *models.Agent, teamMembers []runtime.TeamMemberInfo) string {
+	if leader.InstructionsMD != "" {
+		return leader.InstructionsMD
+	}
+
+	if provider == models.ProviderOpenCode {
+		leaderSubInfo := runtime.SubAgentInfo{
+			Name:        leader.Name,
+			Description: leader.Specialty,
+			Skills:      json.RawMessage(leader.Skills),
+			ClaudeMD:    leader.InstructionsMD,
+		}
+		workers := make([]runtime.SubAgentInfo, 0)
+		for _, a := range team.Agents {
+			if a.Role != models.AgentRoleLeader {
+				workers = append(workers, runtime.SubAgentInfo{
+					Name:        a.Name,
+					Description: a.SubAgentDescription,
+				})
+			}
+		}
+		return runtime.GenerateOpenCodeAgentsMD(team.Name, leaderSubInfo, workers)
+	}
+
+	return runtime.GenerateClaudeMD(runtime.AgentWorkspaceInfo{
+		Name:         leader.Name,
+		Role:         leader.Role,
+		Specialty:    leader.Specialty,
+		SystemPrompt: leader.SystemPrompt,
+		ClaudeMD:     leader.InstructionsMD,
+		Skills:       json.RawMessage(leader.Skills),
+		TeamMembers:  teamMembers,
+	})
 }
 
 // apiKeysByProvider maps model_provider values to the env var names that hold their API keys.
@@ -855,14 +1519,26 @@ func claudeModelID(short string) string {
 
 // LoadSettingsEnv reads settings from the database for the given org and returns
 // them as a string map suitable for passing to AgentConfig.Env. Secret values
-// are decrypted so agent containers receive the real values.
+// are decrypted so agent containers receive the real values. The settings are
+// served from the read cache; callers get their own copy of the map.
 func (s *Server) LoadSettingsEnv(orgID string) map[string]string {
+	env, err := s.readCache.settings.get(orgID, func() (map[string]string, error) {
+		return s.loadSettingsEnv(orgID)
+	})
+	if err != nil {
+		slog.Error("failed to load settings for env", "org_id", orgID, "error", err)
+		return make(map[string]string)
+	}
+	return maps.Clone(env)
+}
+
+// loadSettingsEnv reads and decrypts the settings of LoadSettingsEnv.
+func (s *Server) loadSettingsEnv(orgID string) (map[string]string, error) {
 	env := make(map[string]string)
 
 	var settings []models.Settings
 	if err := s.db.Where("org_id = ?", orgID).Find(&settings).Error; err != nil {
-		slog.Error("failed to load settings for env", "org_id", orgID, "error", err)
-		return env
+		return nil, err
 	}
 
 	for _, setting := range settings {
@@ -894,51 +1570,75 @@ func (s *Server) LoadSettingsEnv(orgID string) map[string]string {
 		}
 	}
 
-	return env
+	return env, nil
 }
 
-// StopTeam tears down all team infrastructure.
+// StopTeam tears down all team infrastructure. The workspace is kept for
+// the next deploy unless preserve_workspace=false.
 func (s *Server) StopTeam(c *fiber.Ctx) error {
-	id := c.Params("id")
-	var team models.Team
-	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
-		return fiber.NewError(fiber.StatusNotFound, "team not found")
+	team, op, err := s.lockTeam(c, teamOpStop)
+	if err != nil {
+		return err
 	}
+	defer s.endTeamOp(team.ID, op)
 
-	if team.Status != models.TeamStatusRunning && team.Status != models.TeamStatusError {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+	// A team still marked deploying holds no operation: its deployment was
+	// interrupted or cancelled, and may have left infrastructure behind.
+	if team.Status == models.TeamStatusStopped {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	ctx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
 	defer cancel()
+	s.stopTeam(ctx, &team, c.QueryBool("preserve_workspace", true))
 
-	// Disconnect shared infrastructure from team network BEFORE TeardownInfra
-	// removes the network. Shared containers stay running (lazy+persistent lifecycle).
-	teamNetName := runtime.TeamNetworkName(SanitizeName(team.Name))
+	return c.JSON(team)
+}
 
-	if team.ModelProvider == models.ModelProviderOllama {
-		if om, ok := s.runtime.(runtime.OllamaManager); ok {
-			if err := om.DisconnectOllamaFromNetwork(ctx, teamNetName); err != nil {
-				slog.Error("failed to disconnect ollama from network", "team", team.Name, "error", err)
-			}
-		}
+// InterruptTeam handles POST /api/teams/:id/interrupt. It tells the leader's
+// sidecar to kill the run in progress, like pressing Esc in an interactive
+// session. The run ends with an interrupted leader_response and the session
+// stays available for the next message. Queued messages are not affected:
+// the command goes on the control channel, not behind them in the inbox.
+func (s *Server) InterruptTeam(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).Preload("Agents").First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
 	}
-
-	// Disconnect RAG infrastructure (always try — methods handle not-connected gracefully).
-	if qm, ok := s.runtime.(runtime.QdrantManager); ok {
-		if err := qm.DisconnectQdrantFromNetwork(ctx, teamNetName); err != nil {
-			slog.Error("failed to disconnect qdrant from network", "team", team.Name, "error", err)
-		}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
 	}
-	if rm, ok := s.runtime.(runtime.RagMcpManager); ok {
-		if err := rm.DisconnectRagMcpFromNetwork(ctx, teamNetName); err != nil {
-			slog.Error("failed to disconnect rag-mcp from network", "team", team.Name, "error", err)
+	var leader *models.Agent
+	for i := range team.Agents {
+		if team.Agents[i].Role == models.AgentRoleLeader {
+			leader = &team.Agents[i]
+			break
 		}
 	}
+	if leader == nil || !models.ContainerIsUp(leader.ContainerStatus) {
+		return newAPIError(CodeNoLeader, "team leader is not running")
+	}
 
-	if err := s.runtime.TeardownInfra(ctx, team.Name); err != nil {
-		slog.Error("failed to teardown infrastructure", "team", team.Name, "error", err)
+	msg, err := protocol.NewMessage("api", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{
+		Command: "interrupt",
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to build interrupt command: "+err.Error())
 	}
+	if err := s.publishControlMessage(naming.Slug(team.Name), msg); err != nil {
+		return fiber.NewError(fiber.StatusBadGateway, "failed to send interrupt: "+err.Error())
+	}
+
+	slog.Info("team run interrupt requested", "team", team.Name)
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"status": "interrupt_sent",
+	})
+}
+
+// stopTeam tears down the team's infrastructure and marks it stopped.
+func (s *Server) stopTeam(ctx context.Context, team *models.Team, preserveWorkspace bool) {
+	s.teardownTeamInfra(ctx, team.Name, team.ModelProvider == models.ModelProviderOllama,
+		runtime.TeardownOptions{PreserveWorkspace: preserveWorkspace})
 
 	// Clear container state for the leader agent only (non-leaders have no containers).
 	for i := range team.Agents {
@@ -953,12 +1653,12 @@ func (s *Server) StopTeam(c *fiber.Ctx) error {
 
 	// Stop the relay goroutine for this team.
 	s.stopTeamRelay(team.ID)
+	s.failQueuedChats(team.ID)
 
-	s.db.Model(&team).Updates(map[string]interface{}{
+	s.db.Model(team).Updates(map[string]interface{}{
 		"status":         models.TeamStatusStopped,
 		"status_message": "",
 	})
 	team.Status = models.TeamStatusStopped
 	team.StatusMessage = ""
-	return c.JSON(team)
 }
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
 	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
@@ -118,6 +119,61 @@ func TestStopTeam_ClearsLeaderContainerOnly(t *testing.T) {
 	}
 }
 
+func TestGetTeam_RefreshesUnhealthyContainerStatus(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "health-team",
+		Agents: []CreateAgentInput{{Name: "a1", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	srv.db.Model(&team.Agents[0]).Updates(map[string]interface{}{
+		"container_id":     "container-a1",
+		"container_status": models.ContainerStatusRunning,
+	})
+
+	mock.mu.Lock()
+	mock.agentStatus = models.ContainerStatusUnhealthy
+	mock.mu.Unlock()
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID, nil)
+	var got models.Team
+	parseJSON(t, rec, &got)
+	if got.Agents[0].ContainerStatus != models.ContainerStatusUnhealthy {
+		t.Errorf("response container_status: got %q, want unhealthy", got.Agents[0].ContainerStatus)
+	}
+
+	var stored models.Agent
+	srv.db.First(&stored, "id = ?", team.Agents[0].ID)
+	if stored.ContainerStatus != models.ContainerStatusUnhealthy {
+		t.Errorf("stored container_status: got %q, want unhealthy", stored.ContainerStatus)
+	}
+
+	// An unhealthy leader container is still up and can be exec'd into.
+	if _, err := srv.resolveAgentContainerID(team.ID, stored); err != nil {
+		t.Errorf("resolveAgentContainerID on unhealthy leader: %v", err)
+	}
+
+	// Recovery is picked up on the first fetch after the refresh interval.
+	mock.mu.Lock()
+	mock.agentStatus = ""
+	mock.mu.Unlock()
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID, nil)
+	parseJSON(t, rec, &got)
+	if got.Agents[0].ContainerStatus != models.ContainerStatusUnhealthy {
+		t.Errorf("within the refresh interval: got %q, want the stored unhealthy", got.Agents[0].ContainerStatus)
+	}
+	srv.statusRefreshes.Delete(team.ID)
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID, nil)
+	parseJSON(t, rec, &got)
+	if got.Agents[0].ContainerStatus != models.ContainerStatusRunning {
+		t.Errorf("after recovery: got %q, want running", got.Agents[0].ContainerStatus)
+	}
+}
+
 func TestDeployTeamAsync_OnlyDeploysLeader(t *testing.T) {
 	srv, mock := setupTestServer(t)
 
@@ -133,7 +189,7 @@ func TestDeployTeamAsync_OnlyDeploysLeader(t *testing.T) {
 	parseJSON(t, teamRec, &team)
 
 	// Call deployTeamAsync synchronously.
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// Only the leader should have been deployed as a container.
 	if len(mock.deployedAgents) != 1 {
@@ -184,7 +240,7 @@ func TestDeployTeamAsync_NoLeader_SetsError(t *testing.T) {
 	parseJSON(t, teamRec, &team)
 
 	// Call deployTeamAsync synchronously.
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// No containers should have been deployed.
 	if len(mock.deployedAgents) != 0 {
@@ -440,7 +496,7 @@ func TestDeployTeamAsync_LegacySkillsWithFullURL(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// Verify skills were correctly parsed and passed to the agent config.
 	if mock.lastAgentConfig == nil {
@@ -499,7 +555,7 @@ func TestDeployTeamAsync_SkillConfigFormat(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	if mock.lastAgentConfig == nil {
 		t.Fatal("expected lastAgentConfig to be set")
@@ -552,7 +608,7 @@ func TestDeployTeamAsync_DeduplicatesSkills(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	if mock.lastAgentConfig == nil {
 		t.Fatal("expected lastAgentConfig to be set")
@@ -733,7 +789,7 @@ func TestDeployTeamAsync_OpenCodeProvider_GeneratesOpenCodeFiles(t *testing.T) {
 		t.Fatalf("provider: got %q, want 'opencode'", team.Provider)
 	}
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// Only the leader should have been deployed.
 	if len(mock.deployedAgents) != 1 {
@@ -806,7 +862,7 @@ func TestDeployTeamAsync_OpenCodeProvider_ForwardsOpenCodeEnvVars(t *testing.T)
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -836,7 +892,7 @@ func TestDeployTeamAsync_ClaudeProvider_DoesNotForwardOpenCodeModel(t *testing.T
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -888,7 +944,7 @@ func TestDeployTeamAsync_OpenCodeProvider_WithInstructionsMD(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -928,7 +984,7 @@ func TestDeployTeamAsync_OpenCodeProvider_WithSkills(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -981,7 +1037,7 @@ func TestDeployTeamAsync_ClaudeProvider_GeneratesClaudeFiles(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1035,7 +1091,7 @@ func TestDeployTeamAsync_OpenCodeProvider_MultipleWorkers(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1103,7 +1159,7 @@ func TestDeployTeamAsync_ProviderForwardedInAgentConfig(t *testing.T) {
 			var team models.Team
 			parseJSON(t, teamRec, &team)
 
-			srv.deployTeamAsync(team)
+			srv.deployTeamAsync(t.Context(), team)
 
 			cfg := mock.lastAgentConfig
 			if cfg == nil {
@@ -1132,7 +1188,7 @@ func TestDeployTeamAsync_ClaudeProvider_DoesNotGenerateOpenCodeFormat(t *testing
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1171,7 +1227,7 @@ func TestDeployTeamAsync_OpenCodeProvider_NoSubAgentNameField(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1221,7 +1277,7 @@ func TestDeployTeamAsync_ClaudeProvider_LeaderModelPassedAsEnvVar(t *testing.T)
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1249,7 +1305,7 @@ func TestDeployTeamAsync_ClaudeProvider_LeaderModelOpus(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1277,7 +1333,7 @@ func TestDeployTeamAsync_ClaudeProvider_LeaderModelHaiku(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1305,7 +1361,7 @@ func TestDeployTeamAsync_ClaudeProvider_LeaderModelInherit_NoEnvVar(t *testing.T
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1335,7 +1391,7 @@ func TestDeployTeamAsync_OpenCodeProvider_LeaderModelOverridesSettings(t *testin
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1365,7 +1421,7 @@ func TestDeployTeamAsync_OpenCodeProvider_LeaderModelInherit_FallsBackToSettings
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1401,7 +1457,7 @@ func TestDeployTeamAsync_OpenCodeProvider_WritesWorkspaceToHost(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// Verify the leader was deployed.
 	if len(mock.deployedAgents) != 1 {
@@ -1477,7 +1533,7 @@ func TestDeployTeamAsync_OpenCodeProvider_NoWorkspacePath_SkipsHostWrite(t *test
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	// Deploy should succeed.
 	if len(mock.deployedAgents) != 1 {
@@ -1554,7 +1610,7 @@ func TestDeployTeamAsync_OpenCodeProvider_LeaderInherit_ModelProviderFallback(t
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	cfg := mock.lastAgentConfig
 	if cfg == nil {
@@ -1660,7 +1716,7 @@ func TestStatusMessage_PopulatedOnNoLeader(t *testing.T) {
 	parseJSON(t, teamRec, &team)
 
 	// Call deployTeamAsync synchronously (no leader → error).
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	var updated models.Team
 	srv.db.First(&updated, "id = ?", team.ID)
@@ -1684,7 +1740,7 @@ func TestStatusMessage_PopulatedOnInfraDeployFailure(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	var updated models.Team
 	srv.db.First(&updated, "id = ?", team.ID)
@@ -1709,7 +1765,7 @@ func TestStatusMessage_PopulatedOnAgentDeployFailure(t *testing.T) {
 	var team models.Team
 	parseJSON(t, teamRec, &team)
 
-	srv.deployTeamAsync(team)
+	srv.deployTeamAsync(t.Context(), team)
 
 	var updated models.Team
 	srv.db.First(&updated, "id = ?", team.ID)
@@ -1778,8 +1834,7 @@ func TestStatusMessage_ReturnedInListTeams(t *testing.T) {
 		t.Fatalf("status: got %d, want 200", rec.Code)
 	}
 
-	var teams []models.Team
-	parseJSON(t, rec, &teams)
+	teams := parseList[models.Team](t, rec)
 	if len(teams) != 1 {
 		t.Fatalf("teams: got %d, want 1", len(teams))
 	}
@@ -2239,3 +2294,391 @@ func TestStopTeam_NonOllama_SkipsOllamaCleanup(t *testing.T) {
 		t.Error("non-ollama team should not stop ollama")
 	}
 }
+
+func TestCreateTeam_ConfigDirMode(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "cfg-default"})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.ConfigDirMode != models.ConfigDirModeInline {
+		t.Errorf("config_dir_mode: got %q, want %q", team.ConfigDirMode, models.ConfigDirModeInline)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "cfg-bad", ConfigDirMode: "overlay"})
+	if rec.Code != 400 {
+		t.Errorf("invalid mode: got %d, want 400", rec.Code)
+	}
+
+	mode := models.ConfigDirModeSeparate
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{ConfigDirMode: &mode})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+	if team.ConfigDirMode != models.ConfigDirModeSeparate {
+		t.Errorf("config_dir_mode after update: got %q", team.ConfigDirMode)
+	}
+}
+
+func TestCreateAgent_FilesystemScopeNormalized(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "fs-scope-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", CreateAgentRequest{
+		Name:        "legacy-scope",
+		Permissions: map[string]interface{}{"filesystem_scope": "/workspace"},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("status: got %d, want 201\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var agent models.Agent
+	parseJSON(t, rec, &agent)
+	if got := string(agent.Permissions); got != `{"filesystem_scope":[{"path":"/workspace","mode":"rw"}]}` {
+		t.Errorf("permissions: got %s", got)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/agents", CreateAgentRequest{
+		Name: "bad-scope",
+		Permissions: map[string]interface{}{
+			"filesystem_scope": []map[string]string{{"path": "/workspace", "mode": "write"}},
+		},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid mode: got %d, want 400", rec.Code)
+	}
+}
+
+func TestTeamNamespaceConfig(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:            "ns-reserved",
+		NamespaceConfig: &runtime.NamespaceConfig{Labels: map[string]string{"agentcrew.team": "other"}},
+	})
+	if rec.Code != 400 {
+		t.Errorf("reserved label: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:            "ns-bad-quota",
+		NamespaceConfig: &runtime.NamespaceConfig{Quota: runtime.QuotaConfig{Memory: "lots"}},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid quota: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name: "ns-team",
+		NamespaceConfig: &runtime.NamespaceConfig{
+			Labels:      map[string]string{"cost-center": "finance"},
+			Annotations: map[string]string{"example.com/owner": "billing"},
+			Quota:       runtime.QuotaConfig{CPU: "4", Memory: "8g"},
+		},
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{
+		NamespaceConfig: &runtime.NamespaceConfig{
+			Labels: map[string]string{"cost-center": "sales"},
+			Quota:  runtime.QuotaConfig{Pods: 10},
+		},
+	})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+
+	srv.deployTeamAsync(t.Context(), team)
+
+	if mock.lastInfraConfig == nil {
+		t.Fatal("expected DeployInfra to be called")
+	}
+	ns := mock.lastInfraConfig.Namespace
+	if ns.Labels["cost-center"] != "sales" || ns.Quota.Pods != 10 || ns.Quota.CPU != "" {
+		t.Errorf("namespace config passed to runtime: got %+v", ns)
+	}
+}
+
+func TestTeamRestartPolicy(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:          "restart-bad",
+		RestartPolicy: &runtime.RestartPolicy{Mode: runtime.RestartAlways, MaxRetries: 3},
+	})
+	if rec.Code != 400 {
+		t.Errorf("max_retries when always: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:          "restart-team",
+		RestartPolicy: &runtime.RestartPolicy{Mode: runtime.RestartAlways},
+		Agents:        []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{
+		RestartPolicy: &runtime.RestartPolicy{Mode: "sometimes"},
+	})
+	if rec.Code != 400 {
+		t.Errorf("unknown mode: got %d, want 400", rec.Code)
+	}
+	want := runtime.RestartPolicy{Mode: runtime.RestartOnFailure, MaxRetries: 3, BackoffSeconds: 20}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{RestartPolicy: &want})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+
+	srv.deployTeamAsync(t.Context(), team)
+
+	if mock.lastInfraConfig == nil || mock.lastInfraConfig.Restart != want {
+		t.Errorf("infra restart policy: got %+v, want %+v", mock.lastInfraConfig, want)
+	}
+	if mock.lastAgentConfig == nil || mock.lastAgentConfig.Restart != want {
+		t.Errorf("leader restart policy: got %+v, want %+v", mock.lastAgentConfig, want)
+	}
+}
+
+func TestTeamDockerHostPlacement(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:         "placed-bad",
+		HostSelector: map[string]string{"-gpu": "true"},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid host selector: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:         "placed",
+		HostSelector: map[string]string{"gpu": "true"},
+		Agents:       []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	mock.teamHost = "gpu-1"
+	srv.deployTeamAsync(t.Context(), team)
+	if cfg := mock.lastInfraConfig; cfg == nil || cfg.HostSelector["gpu"] != "true" || cfg.DockerHost != "" {
+		t.Fatalf("first deploy infra config: got %+v", cfg)
+	}
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.DockerHost != "gpu-1" {
+		t.Fatalf("docker_host: got %q, want gpu-1", team.DockerHost)
+	}
+
+	// Later deploys go back to the same host.
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastInfraConfig.DockerHost != "gpu-1" {
+		t.Errorf("redeploy docker host: got %q, want gpu-1", mock.lastInfraConfig.DockerHost)
+	}
+}
+
+func TestTeamBootstrap(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:      "bootstrap-bad-policy",
+		Bootstrap: &protocol.BootstrapConfig{Script: "npm ci", FailurePolicy: "ignore"},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid failure policy: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:      "bootstrap-team",
+		Bootstrap: &protocol.BootstrapConfig{Script: "npm ci", TimeoutSeconds: 300, FailurePolicy: protocol.BootstrapFailureBlock},
+		Agents:    []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{
+		Bootstrap: &protocol.BootstrapConfig{Script: "npm ci", TimeoutSeconds: 7200},
+	})
+	if rec.Code != 400 {
+		t.Errorf("timeout over limit: got %d, want 400", rec.Code)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	var bootstrap protocol.BootstrapConfig
+	if err := json.Unmarshal([]byte(mock.lastAgentConfig.Env["AGENT_BOOTSTRAP"]), &bootstrap); err != nil {
+		t.Fatalf("AGENT_BOOTSTRAP: %v", err)
+	}
+	if bootstrap.Script != "npm ci" || bootstrap.TimeoutSeconds != 300 || !bootstrap.Blocks() {
+		t.Errorf("AGENT_BOOTSTRAP: got %+v", bootstrap)
+	}
+
+	// An empty script removes the bootstrap config.
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{Bootstrap: &protocol.BootstrapConfig{}})
+	if rec.Code != 200 {
+		t.Fatalf("clear: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+
+	mock.lastAgentConfig = nil
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if v, ok := mock.lastAgentConfig.Env["AGENT_BOOTSTRAP"]; ok {
+		t.Errorf("AGENT_BOOTSTRAP should be unset after clearing, got %q", v)
+	}
+}
+
+func TestInterruptTeam(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams/missing/interrupt", nil)
+	if rec.Code != 404 {
+		t.Errorf("missing team: got %d, want 404", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "interrupt-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/interrupt", nil)
+	if rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+
+	// A running team whose leader container is down has nothing to interrupt.
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/interrupt", nil)
+	if rec.Code != 409 {
+		t.Errorf("leader down: got %d, want 409\nbody: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestTeamTelemetryLevel(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "too-loud", TelemetryLevel: "debug"})
+	if rec.Code != 400 {
+		t.Errorf("unknown level: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:           "quiet",
+		TelemetryLevel: protocol.TelemetryMinimal,
+		Agents:         []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.TelemetryLevel != protocol.TelemetryMinimal {
+		t.Errorf("telemetry_level: got %q, want minimal", team.TelemetryLevel)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if got := mock.lastAgentConfig.Env["AGENT_TELEMETRY_LEVEL"]; got != protocol.TelemetryMinimal {
+		t.Errorf("AGENT_TELEMETRY_LEVEL: got %q, want minimal", got)
+	}
+
+	bogus := "loud"
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{TelemetryLevel: &bogus})
+	if rec.Code != 400 {
+		t.Errorf("update to unknown level: got %d, want 400", rec.Code)
+	}
+
+	// Empty restores the sidecar's default. The team is running now, and the
+	// update succeeds even though its leader cannot be reached to apply it.
+	empty := ""
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{TelemetryLevel: &empty})
+	if rec.Code != 200 {
+		t.Fatalf("reset: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+
+	mock.lastAgentConfig = nil
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if _, ok := mock.lastAgentConfig.Env["AGENT_TELEMETRY_LEVEL"]; ok {
+		t.Error("AGENT_TELEMETRY_LEVEL should not be set for the default")
+	}
+}
+
+func TestTeamExtraWorkspaces(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:            "bad-roots",
+		ExtraWorkspaces: []protocol.ExtraWorkspace{{Name: "../docs", HostPath: "/srv/docs"}},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid name: got %d, want 400", rec.Code)
+	}
+
+	docs := protocol.ExtraWorkspace{Name: "docs", HostPath: "/srv/docs", ReadOnly: true}
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:            "multi-root",
+		ExtraWorkspaces: []protocol.ExtraWorkspace{docs},
+		Agents:          []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if got := teamExtraWorkspaces(team); len(got) != 1 || got[0] != docs {
+		t.Errorf("extra_workspaces: got %s", team.ExtraWorkspaces)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if got := mock.lastAgentConfig.ExtraWorkspaces; len(got) != 1 || got[0] != docs {
+		t.Errorf("runtime extra workspaces: got %+v", got)
+	}
+
+	dup := []protocol.ExtraWorkspace{docs, docs}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{ExtraWorkspaces: &dup})
+	if rec.Code != 400 {
+		t.Errorf("duplicate names: got %d, want 400", rec.Code)
+	}
+	none := []protocol.ExtraWorkspace{}
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{ExtraWorkspaces: &none})
+	if rec.Code != 200 {
+		t.Fatalf("clear: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+	if got := teamExtraWorkspaces(team); len(got) != 0 {
+		t.Errorf("extra_workspaces after clear: got %+v", got)
+	}
+}
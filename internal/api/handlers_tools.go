@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/tools"
+)
+
+// customToolNameRe restricts tool names to what MCP clients accept in a
+// tool name and keeps the agent-facing mcp__agentcrew-tools__<name> stable.
+var customToolNameRe = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// defaultToolInputSchema accepts any object of arguments.
+const defaultToolInputSchema = `{"type":"object"}`
+
+// ListCustomTools returns the organization's custom tools. Credentials are
+// never included.
+func (s *Server) ListCustomTools(c *fiber.Ctx) error {
+	var list []models.CustomTool
+	if err := s.db.Scopes(OrgScope(c)).Order("name").Find(&list).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list tools")
+	}
+	return c.JSON(list)
+}
+
+// GetCustomTool returns a single custom tool by ID.
+func (s *Server) GetCustomTool(c *fiber.Ctx) error {
+	var tool models.CustomTool
+	if err := s.db.Scopes(OrgScope(c)).First(&tool, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "tool not found")
+	}
+	return c.JSON(tool)
+}
+
+// CreateCustomTool registers an HTTP endpoint as a tool (admin only). Teams
+// see new and changed tools the next time they are deployed.
+func (s *Server) CreateCustomTool(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage tools")
+	}
+	var req CreateCustomToolRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	if !customToolNameRe.MatchString(req.Name) {
+		return fiber.NewError(fiber.StatusBadRequest, "name must start with a lowercase letter and contain only lowercase letters, digits and '_' (max 64)")
+	}
+	if len(req.Description) > 1024 {
+		return fiber.NewError(fiber.StatusBadRequest, "description must be at most 1024 characters")
+	}
+	if !validPostActionMethods[req.Method] {
+		return fiber.NewError(fiber.StatusBadRequest, "method must be one of GET, POST, PUT, PATCH, DELETE")
+	}
+	if err := validateToolURL(req.URL); err != nil {
+		return err
+	}
+	authType := models.PostActionAuthNone
+	if req.AuthType != "" {
+		if !validPostActionAuthTypes[req.AuthType] {
+			return fiber.NewError(fiber.StatusBadRequest, "auth_type must be one of none, bearer, basic, header")
+		}
+		authType = req.AuthType
+	}
+	schema := json.RawMessage(defaultToolInputSchema)
+	if len(req.InputSchema) > 0 && string(req.InputSchema) != "null" {
+		if err := validateToolInputSchema(req.InputSchema); err != nil {
+			return err
+		}
+		schema = req.InputSchema
+	}
+	rateLimit := tools.DefaultRateLimitPerMinute
+	if req.RateLimitPerMinute != nil {
+		if err := validateToolRateLimit(*req.RateLimitPerMinute); err != nil {
+			return err
+		}
+		rateLimit = *req.RateLimitPerMinute
+	}
+	timeoutSeconds := 30
+	if req.TimeoutSeconds != nil {
+		if *req.TimeoutSeconds < 1 || *req.TimeoutSeconds > 300 {
+			return fiber.NewError(fiber.StatusBadRequest, "timeout_seconds must be between 1 and 300")
+		}
+		timeoutSeconds = *req.TimeoutSeconds
+	}
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	if err := s.checkToolNameFree(c, req.Name, ""); err != nil {
+		return err
+	}
+
+	authConfigJSON, err := encryptAuthConfig(req.AuthConfig)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to process auth_config")
+	}
+
+	tool := models.CustomTool{
+		ID:                 uuid.New().String(),
+		OrgID:              GetOrgID(c),
+		Name:               req.Name,
+		Description:        req.Description,
+		Method:             req.Method,
+		URL:                req.URL,
+		AuthType:           authType,
+		AuthConfig:         models.JSON(authConfigJSON),
+		InputSchema:        models.JSON(schema),
+		RateLimitPerMinute: rateLimit,
+		TimeoutSeconds:     timeoutSeconds,
+		Enabled:            enabled,
+	}
+	if err := s.db.Create(&tool).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create tool")
+	}
+	return c.Status(fiber.StatusCreated).JSON(tool)
+}
+
+// UpdateCustomTool updates a custom tool's fields (admin only).
+func (s *Server) UpdateCustomTool(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage tools")
+	}
+	id := c.Params("id")
+	var tool models.CustomTool
+	if err := s.db.Scopes(OrgScope(c)).First(&tool, "id = ?", id).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "tool not found")
+	}
+	var req UpdateCustomToolRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil && *req.Name != tool.Name {
+		if !customToolNameRe.MatchString(*req.Name) {
+			return fiber.NewError(fiber.StatusBadRequest, "name must start with a lowercase letter and contain only lowercase letters, digits and '_' (max 64)")
+		}
+		if err := s.checkToolNameFree(c, *req.Name, tool.ID); err != nil {
+			return err
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		if len(*req.Description) > 1024 {
+			return fiber.NewError(fiber.StatusBadRequest, "description must be at most 1024 characters")
+		}
+		updates["description"] = *req.Description
+	}
+	if req.Method != nil {
+		if !validPostActionMethods[*req.Method] {
+			return fiber.NewError(fiber.StatusBadRequest, "method must be one of GET, POST, PUT, PATCH, DELETE")
+		}
+		updates["method"] = *req.Method
+	}
+	if req.URL != nil {
+		if err := validateToolURL(*req.URL); err != nil {
+			return err
+		}
+		updates["url"] = *req.URL
+	}
+	if req.AuthType != nil {
+		if !validPostActionAuthTypes[*req.AuthType] {
+			return fiber.NewError(fiber.StatusBadRequest, "auth_type must be one of none, bearer, basic, header")
+		}
+		updates["auth_type"] = *req.AuthType
+	}
+	if req.AuthConfig != nil {
+		authConfigJSON, err := encryptAuthConfig(req.AuthConfig)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to process auth_config")
+		}
+		updates["auth_config"] = string(authConfigJSON)
+	}
+	if len(req.InputSchema) > 0 {
+		schema := req.InputSchema
+		if string(schema) == "null" {
+			schema = json.RawMessage(defaultToolInputSchema)
+		} else if err := validateToolInputSchema(schema); err != nil {
+			return err
+		}
+		updates["input_schema"] = string(schema)
+	}
+	if req.RateLimitPerMinute != nil {
+		if err := validateToolRateLimit(*req.RateLimitPerMinute); err != nil {
+			return err
+		}
+		updates["rate_limit_per_minute"] = *req.RateLimitPerMinute
+	}
+	if req.TimeoutSeconds != nil {
+		if *req.TimeoutSeconds < 1 || *req.TimeoutSeconds > 300 {
+			return fiber.NewError(fiber.StatusBadRequest, "timeout_seconds must be between 1 and 300")
+		}
+		updates["timeout_seconds"] = *req.TimeoutSeconds
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(&tool).Updates(updates).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update tool")
+		}
+	}
+	s.db.Scopes(OrgScope(c)).First(&tool, "id = ?", id)
+	return c.JSON(tool)
+}
+
+// DeleteCustomTool removes a custom tool (admin only). Running agents that
+// still list it get an "unknown tool" error when they call it.
+func (s *Server) DeleteCustomTool(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage tools")
+	}
+	var tool models.CustomTool
+	if err := s.db.Scopes(OrgScope(c)).First(&tool, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "tool not found")
+	}
+	if err := s.db.Delete(&tool).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete tool")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkToolNameFree returns a 409 if another tool in the organization
+// already uses name.
+func (s *Server) checkToolNameFree(c *fiber.Ctx, name, exceptID string) error {
+	var count int64
+	q := s.db.Model(&models.CustomTool{}).Scopes(OrgScope(c)).Where("name = ?", name)
+	if exceptID != "" {
+		q = q.Where("id <> ?", exceptID)
+	}
+	if err := q.Count(&count).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check tool name")
+	}
+	if count > 0 {
+		return fiber.NewError(fiber.StatusConflict, "a tool with this name already exists")
+	}
+	return nil
+}
+
+func validateToolURL(raw string) error {
+	if raw == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "url is required")
+	}
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+func validateToolInputSchema(schema json.RawMessage) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(schema, &obj); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "input_schema must be a JSON object")
+	}
+	if t, ok := obj["type"]; ok && t != "object" {
+		return fiber.NewError(fiber.StatusBadRequest, `input_schema type must be "object"`)
+	}
+	return nil
+}
+
+func validateToolRateLimit(n int) error {
+	if n < 1 || n > 10000 {
+		return fiber.NewError(fiber.StatusBadRequest, "rate_limit_per_minute must be between 1 and 10000")
+	}
+	return nil
+}
+
+// handleToolInvocation answers a tool request published by a team's
+// sidecar. The tool must belong to the team's organization and be enabled,
+// and the calling agent's allowed_tools must list it. The sidecar checks
+// the same permission, but anything that can publish on the team's NATS
+// could skip that check.
+func (s *Server) handleToolInvocation(ctx context.Context, teamID string, data []byte) (*protocol.Message, error) {
+	msg, inv, err := parseToolInvocation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := protocol.ToolResultPayload{Tool: inv.Tool}
+	var team models.Team
+	var agent models.Agent
+	var tool models.CustomTool
+	if err := s.db.Select("id", "org_id", "name").First(&team, "id = ?", teamID).Error; err != nil {
+		result.IsError = true
+		result.Result = "team not found"
+	} else if err := s.db.Select("id", "permissions").
+		First(&agent, "team_id = ? AND name = ?", teamID, inv.AgentName).Error; err != nil {
+		result.IsError = true
+		result.Result = "agent not found: " + inv.AgentName
+	} else if decision := toolGate(agent).Evaluate(protocol.CustomToolPermission(inv.Tool), "", nil); !decision.Allowed {
+		slog.Warn("custom tool denied by permission gate", "team", team.Name, "agent", inv.AgentName,
+			"tool", inv.Tool, "reason", decision.Reason)
+		result.IsError = true
+		result.Result = "Permission denied: " + decision.Reason
+	} else if err := s.db.Where("org_id = ? AND name = ? AND enabled = ?", team.OrgID, inv.Tool, true).
+		First(&tool).Error; err != nil {
+		result.IsError = true
+		result.Result = "unknown or disabled tool: " + inv.Tool
+	} else {
+		result = s.toolProxy.Invoke(ctx, tool, inv.Arguments)
+		slog.Info("custom tool invoked", "team", team.Name, "agent", inv.AgentName,
+			"tool", inv.Tool, "status_code", result.StatusCode, "is_error", result.IsError)
+	}
+
+	return toolResultReply(msg, result)
+}
+
+// toolBusyReply answers a tool request the relay has no room to run
+// because too many of the team's tool calls are in progress.
+func toolBusyReply(data []byte) (*protocol.Message, error) {
+	msg, inv, err := parseToolInvocation(data)
+	if err != nil {
+		return nil, err
+	}
+	return toolResultReply(msg, protocol.ToolResultPayload{
+		Tool:    inv.Tool,
+		Result:  "too many tool calls in progress, try again shortly",
+		IsError: true,
+	})
+}
+
+func parseToolInvocation(data []byte) (*protocol.Message, *protocol.ToolInvocationPayload, error) {
+	var msg protocol.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, nil, err
+	}
+	if msg.Type != protocol.TypeToolInvocation {
+		return nil, nil, errors.New("unexpected message type " + string(msg.Type))
+	}
+	inv, err := protocol.ParsePayload[protocol.ToolInvocationPayload](&msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &msg, inv, nil
+}
+
+func toolResultReply(msg *protocol.Message, result protocol.ToolResultPayload) (*protocol.Message, error) {
+	reply, err := protocol.NewMessage("api", msg.From, protocol.TypeToolResult, result)
+	if err != nil {
+		return nil, err
+	}
+	reply.RefMessageID = msg.MessageID
+	return reply, nil
+}
+
+// toolGate returns the permission gate of an agent's stored permissions.
+// Permissions that cannot be parsed allow no tools.
+func toolGate(agent models.Agent) *permissions.Gate {
+	var config permissions.PermissionConfig
+	if err := json.Unmarshal(agent.Permissions, &config); err != nil {
+		config = permissions.PermissionConfig{}
+	}
+	return permissions.NewGate(config)
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestCustomTools_CRUD(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/tools", CreateCustomToolRequest{
+		Name:       "lookup_customer",
+		Method:     "GET",
+		URL:        "https://crm.internal/api/customers",
+		AuthType:   "bearer",
+		AuthConfig: map[string]string{"token": "s3cret"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "s3cret") || strings.Contains(rec.Body.String(), "auth_config") {
+		t.Errorf("response leaks credentials: %s", rec.Body.String())
+	}
+	var tool models.CustomTool
+	parseJSON(t, rec, &tool)
+	if tool.RateLimitPerMinute != 60 || tool.TimeoutSeconds != 30 || !tool.Enabled {
+		t.Errorf("defaults: got %+v", tool)
+	}
+	if string(tool.InputSchema) != defaultToolInputSchema {
+		t.Errorf("input_schema: got %s", tool.InputSchema)
+	}
+
+	rec = doRequest(srv, "POST", "/api/tools", CreateCustomToolRequest{
+		Name: "lookup_customer", Method: "GET", URL: "https://crm.internal/other",
+	})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("duplicate name: got %d, want 409", rec.Code)
+	}
+
+	limit := 5
+	rec = doRequest(srv, "PUT", "/api/tools/"+tool.ID, UpdateCustomToolRequest{RateLimitPerMinute: &limit})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &tool)
+	if tool.RateLimitPerMinute != 5 {
+		t.Errorf("rate_limit_per_minute: got %d, want 5", tool.RateLimitPerMinute)
+	}
+
+	rec = doRequest(srv, "GET", "/api/tools", nil)
+	var list []models.CustomTool
+	parseJSON(t, rec, &list)
+	if len(list) != 1 {
+		t.Errorf("list: got %d tools, want 1", len(list))
+	}
+
+	rec = doRequest(srv, "DELETE", "/api/tools/"+tool.ID, nil)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("delete: got %d", rec.Code)
+	}
+	rec = doRequest(srv, "GET", "/api/tools/"+tool.ID, nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("get after delete: got %d, want 404", rec.Code)
+	}
+}
+
+func TestCreateCustomTool_Validation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	zero := 0
+	tests := []struct {
+		name string
+		req  CreateCustomToolRequest
+	}{
+		{"bad name", CreateCustomToolRequest{Name: "Lookup-Customer", Method: "GET", URL: "https://x.internal"}},
+		{"bad method", CreateCustomToolRequest{Name: "t", Method: "TRACE", URL: "https://x.internal"}},
+		{"relative url", CreateCustomToolRequest{Name: "t", Method: "GET", URL: "/api/x"}},
+		{"non-http url", CreateCustomToolRequest{Name: "t", Method: "GET", URL: "file:///etc/passwd"}},
+		{"bad auth type", CreateCustomToolRequest{Name: "t", Method: "GET", URL: "https://x.internal", AuthType: "oauth"}},
+		{"schema not object", CreateCustomToolRequest{Name: "t", Method: "GET", URL: "https://x.internal", InputSchema: json.RawMessage(`{"type":"string"}`)}},
+		{"zero rate limit", CreateCustomToolRequest{Name: "t", Method: "GET", URL: "https://x.internal", RateLimitPerMinute: &zero}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(srv, "POST", "/api/tools", tt.req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("got %d, want 400; body: %s", rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestDeploy_InjectsCustomTools(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	rec := doRequest(srv, "POST", "/api/tools", CreateCustomToolRequest{
+		Name: "search_docs", Description: "Search the wiki", Method: "GET", URL: "https://wiki.internal/search",
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create tool: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "tools-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create team: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected leader to be deployed")
+	}
+
+	var specs []protocol.CustomToolSpec
+	if err := json.Unmarshal([]byte(mock.lastAgentConfig.Env["AGENT_CUSTOM_TOOLS"]), &specs); err != nil {
+		t.Fatalf("AGENT_CUSTOM_TOOLS: %v", err)
+	}
+	if len(specs) != 1 || specs[0].Name != "search_docs" || specs[0].Description != "Search the wiki" {
+		t.Errorf("specs: got %+v", specs)
+	}
+	if strings.Contains(mock.lastAgentConfig.Env["AGENT_CUSTOM_TOOLS"], "wiki.internal") {
+		t.Error("AGENT_CUSTOM_TOOLS must not expose the endpoint")
+	}
+}
+
+func TestHandleToolInvocation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.URL.Query().Get("who")))
+	}))
+	defer endpoint.Close()
+
+	team := models.Team{ID: "team-tools", OrgID: "00000000-0000-0000-0000-000000000000", Name: "tools"}
+	srv.db.Create(&team)
+	srv.db.Create(&models.CustomTool{ID: "tool-1", OrgID: team.OrgID, Name: "greet", Method: "GET", URL: endpoint.URL, Enabled: true})
+	srv.db.Create(&models.CustomTool{ID: "tool-2", OrgID: "other-org", Name: "secret", Method: "GET", URL: endpoint.URL, Enabled: true})
+	srv.db.Create(&models.CustomTool{ID: "tool-3", OrgID: team.OrgID, Name: "admin", Method: "GET", URL: endpoint.URL, Enabled: true})
+	perms, _ := json.Marshal(map[string][]string{"allowed_tools": {
+		protocol.CustomToolPermission("greet"),
+		protocol.CustomToolPermission("secret"),
+		protocol.CustomToolPermission("missing"),
+	}})
+	srv.db.Create(&models.Agent{ID: "agent-leader", TeamID: team.ID, Name: "leader", Role: "leader", Permissions: models.JSON(perms)})
+
+	invoke := func(agent, tool string) protocol.ToolResultPayload {
+		t.Helper()
+		msg, _ := protocol.NewMessage(agent, "api", protocol.TypeToolInvocation, protocol.ToolInvocationPayload{
+			AgentName: agent, Tool: tool, Arguments: json.RawMessage(`{"who":"crew"}`),
+		})
+		data, _ := json.Marshal(msg)
+		reply, err := srv.handleToolInvocation(context.Background(), team.ID, data)
+		if err != nil {
+			t.Fatalf("handleToolInvocation: %v", err)
+		}
+		if reply.Type != protocol.TypeToolResult || reply.RefMessageID != msg.MessageID || reply.To != agent {
+			t.Errorf("reply envelope: got %+v", reply)
+		}
+		result, err := protocol.ParsePayload[protocol.ToolResultPayload](reply)
+		if err != nil {
+			t.Fatalf("parse result: %v", err)
+		}
+		return *result
+	}
+
+	if res := invoke("leader", "greet"); res.IsError || res.Result != "hello crew" {
+		t.Errorf("greet: got %+v", res)
+	}
+	if res := invoke("leader", "secret"); !res.IsError {
+		t.Errorf("tool of another org must not be callable: got %+v", res)
+	}
+	if res := invoke("leader", "missing"); !res.IsError {
+		t.Errorf("unknown tool: got %+v", res)
+	}
+	if res := invoke("leader", "admin"); !res.IsError || !strings.HasPrefix(res.Result, "Permission denied") {
+		t.Errorf("tool missing from allowed_tools must not be callable: got %+v", res)
+	}
+	if res := invoke("intruder", "greet"); !res.IsError {
+		t.Errorf("unknown agent: got %+v", res)
+	}
+}
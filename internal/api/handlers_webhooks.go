@@ -17,9 +17,11 @@ import (
 	"github.com/google/uuid"
 	"github.com/nats-io/nats.go"
 
+	"github.com/helmcode/agent-crew/internal/events"
 	"github.com/helmcode/agent-crew/internal/models"
-	"github.com/helmcode/agent-crew/internal/postaction"
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
 )
 
 // generateWebhookToken creates a new webhook token with its hash and prefix.
@@ -68,7 +70,7 @@ func (s *Server) GetWebhook(c *fiber.Ctx) error {
 func (s *Server) CreateWebhook(c *fiber.Ctx) error {
 	var req CreateWebhookRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	if req.Name == "" {
@@ -83,8 +85,8 @@ func (s *Server) CreateWebhook(c *fiber.Ctx) error {
 	if req.PromptTemplate == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "prompt_template is required")
 	}
-	if len(req.PromptTemplate) > 50000 {
-		return fiber.NewError(fiber.StatusBadRequest, "prompt_template exceeds maximum length of 50000 characters")
+	if len(req.PromptTemplate) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt_template exceeds maximum length of %d characters", maxPromptLength))
 	}
 
 	// Validate team exists and belongs to org.
@@ -149,7 +151,7 @@ func (s *Server) UpdateWebhook(c *fiber.Ctx) error {
 
 	var req UpdateWebhookRequest
 	if err := c.BodyParser(&req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		return newAPIError(CodeInvalidBody, "invalid request body")
 	}
 
 	updates := map[string]interface{}{}
@@ -167,8 +169,8 @@ func (s *Server) UpdateWebhook(c *fiber.Ctx) error {
 		if *req.PromptTemplate == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "prompt_template cannot be empty")
 		}
-		if len(*req.PromptTemplate) > 50000 {
-			return fiber.NewError(fiber.StatusBadRequest, "prompt_template exceeds maximum length of 50000 characters")
+		if len(*req.PromptTemplate) > maxPromptLength {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("prompt_template exceeds maximum length of %d characters", maxPromptLength))
 		}
 		updates["prompt_template"] = *req.PromptTemplate
 	}
@@ -314,7 +316,7 @@ func (s *Server) GetWebhookRun(c *fiber.Ctx) error {
 func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 	token := c.Params("token")
 	if token == "" {
-		return fiber.NewError(fiber.StatusUnauthorized, "missing token")
+		return newAPIError(CodeAuthMissing, "missing token")
 	}
 
 	// Hash the provided token and look up the webhook.
@@ -323,7 +325,7 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 
 	var webhook models.Webhook
 	if err := s.db.First(&webhook, "secret_token_hash = ?", tokenHash).Error; err != nil {
-		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+		return newAPIError(CodeAuthInvalid, "invalid token")
 	}
 
 	if !webhook.Enabled {
@@ -336,7 +338,10 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusConflict, "team not found")
 	}
 	if team.Status != models.TeamStatusRunning {
-		return fiber.NewError(fiber.StatusConflict, "team is not running")
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	if err := s.checkOrgMaintenance(team.OrgID); err != nil {
+		return err
 	}
 
 	// Check per-webhook concurrency.
@@ -377,8 +382,12 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 
 	// Render prompt template.
 	prompt := renderPromptTemplate(webhook.PromptTemplate, req.Variables)
-	if len(prompt) > 50000 {
-		return fiber.NewError(fiber.StatusBadRequest, "rendered prompt exceeds maximum length of 50000 characters")
+	if len(prompt) > maxPromptLength {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("rendered prompt exceeds maximum length of %d characters", maxPromptLength))
+	}
+	// Input content policies apply as they do to chat messages.
+	if err := s.CheckPromptPolicies(c.Context(), team, "", prompt); err != nil {
+		return err
 	}
 
 	// Serialize request payload.
@@ -394,6 +403,7 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 		PromptSent:     prompt,
 		RequestPayload: string(payloadJSON),
 		CallerIP:       c.IP(),
+		TeamRevision:   team.ConfigRevision,
 	}
 
 	if err := s.db.Create(&run).Error; err != nil {
@@ -414,7 +424,7 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 		defer cancel()
 
 		start := time.Now()
-		responseText, err := s.sendWebhookPromptAndWait(ctx, SanitizeName(team.Name), prompt, run.ID)
+		responseText, err := s.sendWebhookPromptAndWait(ctx, team, prompt, run.ID)
 		durationMs := time.Since(start).Milliseconds()
 
 		finished := time.Now()
@@ -445,23 +455,10 @@ func (s *Server) TriggerWebhook(c *fiber.Ctx) error {
 		s.db.Model(&models.WebhookRun{}).Where("id = ?", run.ID).Updates(updates)
 		s.updateWebhookIdleStatus(webhook.ID)
 
-		// Fire post-actions (fire-and-forget, uses goroutine internally).
-		runStatus := updates["status"].(string)
+		// Post-actions and issue trackers react to the finished run.
 		runError, _ := updates["error"].(string)
 		runResponse, _ := updates["response_received"].(string)
-		s.postActionExec.ExecutePostActions(postaction.PostActionContext{
-			SourceType:  "webhook",
-			TriggerID:   webhook.ID,
-			RunID:       run.ID,
-			Status:      runStatus,
-			Response:    runResponse,
-			Error:       runError,
-			TriggerName: webhook.Name,
-			TeamName:    team.Name,
-			Prompt:      prompt,
-			StartedAt:   run.StartedAt.Format(time.RFC3339),
-			FinishedAt:  finished.Format(time.RFC3339),
-		})
+		s.events.Publish(webhookRunCompleted(webhook, run, team, prompt, updates["status"].(string), runResponse, runError, finished))
 
 		return c.JSON(resp)
 	}
@@ -482,7 +479,7 @@ func (s *Server) executeWebhookAsync(webhook models.Webhook, run models.WebhookR
 		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 
-		responseText, err := s.sendWebhookPromptAndWait(ctx, SanitizeName(team.Name), prompt, run.ID)
+		responseText, err := s.sendWebhookPromptAndWait(ctx, team, prompt, run.ID)
 
 		finished := time.Now()
 		updates := map[string]interface{}{"finished_at": finished}
@@ -503,26 +500,31 @@ func (s *Server) executeWebhookAsync(webhook models.Webhook, run models.WebhookR
 		s.db.Model(&models.WebhookRun{}).Where("id = ?", run.ID).Updates(updates)
 		s.updateWebhookIdleStatus(webhook.ID)
 
-		// Fire post-actions (fire-and-forget).
-		runStatus := updates["status"].(string)
+		// Post-actions and issue trackers react to the finished run.
 		runError, _ := updates["error"].(string)
 		runResponse, _ := updates["response_received"].(string)
-		s.postActionExec.ExecutePostActions(postaction.PostActionContext{
-			SourceType:  "webhook",
-			TriggerID:   webhook.ID,
-			RunID:       run.ID,
-			Status:      runStatus,
-			Response:    runResponse,
-			Error:       runError,
-			TriggerName: webhook.Name,
-			TeamName:    team.Name,
-			Prompt:      prompt,
-			StartedAt:   run.StartedAt.Format(time.RFC3339),
-			FinishedAt:  finished.Format(time.RFC3339),
-		})
+		s.events.Publish(webhookRunCompleted(webhook, run, team, prompt, updates["status"].(string), runResponse, runError, finished))
 	}()
 }
 
+// webhookRunCompleted builds the event published when a webhook run finishes.
+func webhookRunCompleted(webhook models.Webhook, run models.WebhookRun, team models.Team, prompt, status, response, runError string, finished time.Time) events.RunCompleted {
+	return events.RunCompleted{
+		SourceType:  models.PostActionTriggerWebhook,
+		TriggerID:   webhook.ID,
+		TriggerName: webhook.Name,
+		RunID:       run.ID,
+		TeamID:      team.ID,
+		TeamName:    team.Name,
+		Status:      status,
+		Response:    response,
+		Error:       runError,
+		Prompt:      prompt,
+		StartedAt:   run.StartedAt,
+		FinishedAt:  finished,
+	}
+}
+
 // updateWebhookIdleStatus resets a webhook's status to idle if no more runs are active.
 func (s *Server) updateWebhookIdleStatus(webhookID string) {
 	var runningCount int64
@@ -533,7 +535,15 @@ func (s *Server) updateWebhookIdleStatus(webhookID string) {
 }
 
 // sendWebhookPromptAndWait connects to NATS, sends a prompt, and waits for the leader response.
-func (s *Server) sendWebhookPromptAndWait(ctx context.Context, teamName, prompt, runID string) (string, error) {
+// The prompt first waits its turn with the rate limiter.
+func (s *Server) sendWebhookPromptAndWait(ctx context.Context, team models.Team, prompt, runID string) (string, error) {
+	if err := s.governor.Wait(ctx, ratelimit.Request{
+		ID: runID, OrgID: team.OrgID, TeamID: team.ID, TeamName: team.Name, Source: "webhook",
+	}); err != nil {
+		return "", fmt.Errorf("waiting for rate limiter: %w", err)
+	}
+
+	teamName := naming.Slug(team.Name)
 	natsURL, err := s.runtime.GetNATSConnectURL(ctx, teamName)
 	if err != nil {
 		return "", fmt.Errorf("resolving NATS URL: %w", err)
@@ -8,7 +8,9 @@ import (
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
 
+	"github.com/helmcode/agent-crew/internal/failures"
 	"github.com/helmcode/agent-crew/internal/models"
 )
 
@@ -20,14 +22,14 @@ func (s *Server) StreamLogs(c *websocket.Conn) {
 
 	var team models.Team
 	if err := s.db.Where("org_id = ?", orgID).Preload("Agents").First(&team, "id = ?", teamID).Error; err != nil {
-		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"team not found"}`))
+		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"team not found","code":"TEAM_NOT_FOUND"}`))
 		return
 	}
 
 	// Find a running agent to stream logs from (prefer leader).
 	var containerID string
 	for _, agent := range team.Agents {
-		if agent.ContainerStatus == models.ContainerStatusRunning {
+		if models.ContainerIsUp(agent.ContainerStatus) {
 			containerID = agent.ContainerID
 			if agent.Role == models.AgentRoleLeader {
 				break
@@ -36,7 +38,7 @@ func (s *Server) StreamLogs(c *websocket.Conn) {
 	}
 
 	if containerID == "" {
-		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"no running agents"}`))
+		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"no running agents","code":"TEAM_NOT_RUNNING"}`))
 		return
 	}
 
@@ -45,7 +47,7 @@ func (s *Server) StreamLogs(c *websocket.Conn) {
 
 	reader, err := s.runtime.StreamLogs(ctx, containerID)
 	if err != nil {
-		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"failed to stream logs"}`))
+		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"failed to stream logs","code":"INTERNAL"}`))
 		return
 	}
 	defer reader.Close()
@@ -68,7 +70,10 @@ func (s *Server) StreamLogs(c *websocket.Conn) {
 	}
 }
 
-// StreamActivity streams team activity updates via WebSocket.
+// StreamActivity streams team activity updates via WebSocket. It polls the
+// database rather than listening to the team's relay, so any API replica can
+// serve it; load balancers only need to keep each socket on the replica it
+// was opened on, which they do for the lifetime of a connection.
 func (s *Server) StreamActivity(c *websocket.Conn) {
 	teamID := c.Params("id")
 	orgID, _ := c.Locals("org_id").(string)
@@ -76,7 +81,7 @@ func (s *Server) StreamActivity(c *websocket.Conn) {
 
 	var team models.Team
 	if err := s.db.Where("org_id = ?", orgID).First(&team, "id = ?", teamID).Error; err != nil {
-		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"team not found"}`))
+		_ = c.WriteMessage(websocket.TextMessage, []byte(`{"error":"team not found","code":"TEAM_NOT_FOUND"}`))
 		return
 	}
 
@@ -94,6 +99,7 @@ func (s *Server) StreamActivity(c *websocket.Conn) {
 		lastCreatedAt = seedMsg.CreatedAt
 	}
 
+	locale := failures.Negotiate(c.Query("locale"), c.Headers(fiber.HeaderAcceptLanguage))
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
@@ -133,6 +139,7 @@ func (s *Server) StreamActivity(c *websocket.Conn) {
 				query = query.Where("created_at > ?", lastCreatedAt)
 			}
 			query.Find(&logs)
+			explainLogFailures(logs, locale)
 
 			for _, log := range logs {
 				data, _ := json.Marshal(log)
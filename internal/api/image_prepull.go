@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// defaultImagePrePullInterval is how often agent images are pulled ahead of
+// deployments, unless SetImagePrePullInterval changes it.
+const defaultImagePrePullInterval = time.Hour
+
+// maxPrePullImages caps how many images a pre-pull fetches, so that teams
+// with one-off images do not fill the hosts' disks.
+const maxPrePullImages = 10
+
+// ImagePrePullReport describes the last pre-pull of agent images.
+type ImagePrePullReport struct {
+	Images     []string  `json:"images"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// SetImagePrePullInterval sets how often agent images are pre-pulled. Zero
+// disables the pre-pull. Call it before AcquireOwnership.
+func (s *Server) SetImagePrePullInterval(d time.Duration) {
+	s.imagePrePullInterval = d
+}
+
+// StartImagePrePull starts the background loop that pulls the agent images
+// in use on every Docker host or Kubernetes node, once at start and then
+// every interval, so that deployments do not wait for a pull.
+func (s *Server) StartImagePrePull() {
+	if _, ok := s.runtime.(runtime.ImagePrePuller); !ok {
+		slog.Info("image pre-pull disabled: runtime cannot pre-pull images")
+		return
+	}
+	interval := s.imagePrePullInterval
+	if interval <= 0 {
+		slog.Info("image pre-pull disabled")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.imagePrePullCancel = cancel
+	s.imagePrePullWg.Add(1)
+	go func() {
+		defer s.imagePrePullWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runCtx, runCancel := context.WithTimeout(ctx, 30*time.Minute)
+			s.prePullImages(runCtx)
+			runCancel()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	slog.Info("image pre-pull started", "interval", interval.String())
+}
+
+// stopImagePrePull stops the pre-pull loop, if running, and waits for it.
+func (s *Server) stopImagePrePull() {
+	if s.imagePrePullCancel != nil {
+		s.imagePrePullCancel()
+	}
+	s.imagePrePullWg.Wait()
+}
+
+// prePullImages pulls the images of prePullImageList and records the
+// outcome for GetImagePrePullReport.
+func (s *Server) prePullImages(ctx context.Context) {
+	puller, ok := s.runtime.(runtime.ImagePrePuller)
+	if !ok {
+		return
+	}
+	report := &ImagePrePullReport{StartedAt: time.Now()}
+	images, err := s.prePullImageList()
+	if err == nil {
+		report.Images = images
+		err = puller.PrePullImages(ctx, images)
+	}
+	report.FinishedAt = time.Now()
+	if err != nil {
+		report.Error = err.Error()
+		slog.Error("image pre-pull failed", "error", err)
+	} else {
+		slog.Info("agent images pre-pulled", "images", len(images), "duration", report.FinishedAt.Sub(report.StartedAt).String())
+	}
+
+	s.imagePrePullMu.Lock()
+	s.imagePrePullReport = report
+	s.imagePrePullMu.Unlock()
+}
+
+// prePullImageList returns the default agent images and the images of the
+// teams, those used by the most teams first, up to maxPrePullImages.
+func (s *Server) prePullImageList() ([]string, error) {
+	var rows []struct {
+		AgentImage string
+		Provider   string
+		Teams      int
+	}
+	if err := s.db.Model(&models.Team{}).
+		Select("agent_image, provider, count(*) AS teams").
+		Group("agent_image, provider").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	teams := map[string]int{runtime.DefaultAgentImage: 0}
+	for _, r := range rows {
+		teams[teamAgentImage(r.AgentImage, r.Provider)] += r.Teams
+	}
+	images := make([]string, 0, len(teams))
+	for img := range teams {
+		images = append(images, img)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if teams[images[i]] != teams[images[j]] {
+			return teams[images[i]] > teams[images[j]]
+		}
+		return images[i] < images[j]
+	})
+	if len(images) > maxPrePullImages {
+		images = images[:maxPrePullImages]
+	}
+	return images, nil
+}
+
+// teamAgentImage returns the image a team's agents run: its own, or the
+// default of its provider.
+func teamAgentImage(image, provider string) string {
+	switch {
+	case image != "":
+		return image
+	case provider == models.ProviderOpenCode:
+		return runtime.DefaultOpenCodeAgentImage
+	default:
+		return runtime.DefaultAgentImage
+	}
+}
+
+// GetImagePrePullReport handles GET /api/admin/image-prepull. It returns the
+// last pre-pull of agent images. In multi-tenant mode, only the default
+// images and those of the caller's organization are listed.
+func (s *Server) GetImagePrePullReport(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view the image pre-pull report")
+	}
+	if _, ok := s.runtime.(runtime.ImagePrePuller); !ok {
+		return fiber.NewError(fiber.StatusNotImplemented, "the runtime cannot pre-pull images")
+	}
+
+	s.imagePrePullMu.Lock()
+	last := s.imagePrePullReport
+	s.imagePrePullMu.Unlock()
+	if last == nil {
+		return fiber.NewError(fiber.StatusNotFound, "no image pre-pull has run yet")
+	}
+	report := *last
+	if !s.multiTenant {
+		return c.JSON(report)
+	}
+
+	var rows []models.Team
+	if err := s.db.Select("agent_image", "provider").Scopes(OrgScope(c)).Find(&rows).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	visible := map[string]bool{
+		runtime.DefaultAgentImage:         true,
+		runtime.DefaultOpenCodeAgentImage: true,
+	}
+	for _, t := range rows {
+		visible[teamAgentImage(t.AgentImage, t.Provider)] = true
+	}
+	report.Images = nil
+	for _, img := range last.Images {
+		if visible[img] {
+			report.Images = append(report.Images, img)
+		}
+	}
+	if report.Images == nil {
+		report.Images = []string{}
+	}
+	return c.JSON(report)
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func TestPrePullImages(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/admin/image-prepull", nil)
+	if rec.Code != 404 {
+		t.Fatalf("before any pre-pull: got %d, want 404\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, tc := range []struct{ name, image string }{
+		{"custom-a", "registry.example.com/agent:1.2.3"},
+		{"custom-b", "registry.example.com/agent:1.2.3"},
+		{"plain", ""},
+		{"other", "registry.example.com/other:1.0"},
+	} {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:   tc.name,
+			Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+		})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		srv.db.Model(&team).Update("agent_image", tc.image)
+	}
+
+	srv.prePullImages(context.Background())
+	want := []string{"registry.example.com/agent:1.2.3", runtime.DefaultAgentImage, "registry.example.com/other:1.0"}
+	if len(mock.prePulled) != 1 || !slices.Equal(mock.prePulled[0], want) {
+		t.Fatalf("pre-pulled: got %v, want %v", mock.prePulled, want)
+	}
+
+	rec = doRequest(srv, "GET", "/api/admin/image-prepull", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var report ImagePrePullReport
+	parseJSON(t, rec, &report)
+	if !slices.Equal(report.Images, want) || report.Error != "" || report.FinishedAt.IsZero() {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestPrePullImageList_Capped(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	for i := range maxPrePullImages + 5 {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:   fmt.Sprintf("team-%d", i),
+			Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+		})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		srv.db.Model(&team).Update("agent_image", fmt.Sprintf("registry.example.com/agent:%d", i))
+	}
+	images, err := srv.prePullImageList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != maxPrePullImages {
+		t.Errorf("images: got %d, want %d", len(images), maxPrePullImages)
+	}
+}
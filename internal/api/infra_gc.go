@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// infraGCInterval is how often the infrastructure garbage collector runs.
+const infraGCInterval = 10 * time.Minute
+
+// Reasons team infrastructure is orphaned.
+const (
+	orphanTeamDeleted = "team deleted"
+	orphanTeamStopped = "team stopped"
+)
+
+// errInfraGCUnsupported is returned when the runtime cannot list team
+// infrastructure.
+var errInfraGCUnsupported = errors.New("runtime cannot list team infrastructure")
+
+// StartInfraGC starts the background loop that removes orphaned team
+// infrastructure and flags running teams whose leader container vanished.
+func (s *Server) StartInfraGC() {
+	if _, ok := s.runtime.(runtime.InfraLister); !ok {
+		slog.Info("infrastructure garbage collector disabled: runtime cannot list team infrastructure")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.infraGCCancel = cancel
+	s.infraGCWg.Add(1)
+	go func() {
+		defer s.infraGCWg.Done()
+		ticker := time.NewTicker(infraGCInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runCtx, runCancel := context.WithTimeout(ctx, 5*time.Minute)
+				if _, err := s.collectInfraGarbage(runCtx, false); err != nil {
+					slog.Error("infrastructure garbage collection failed", "error", err)
+				}
+				runCancel()
+			}
+		}
+	}()
+	slog.Info("infrastructure garbage collector started", "interval", infraGCInterval.String())
+}
+
+// stopInfraGC stops the garbage collector loop, if running, and waits for
+// it.
+func (s *Server) stopInfraGC() {
+	if s.infraGCCancel != nil {
+		s.infraGCCancel()
+	}
+	s.infraGCWg.Wait()
+}
+
+// collectInfraGarbage compares the runtime's team infrastructure with the
+// database. It reports infrastructure whose team was deleted or is
+// stopped, and running teams whose leader container no longer exists.
+// Unless dryRun is set, it removes the orphaned infrastructure and marks
+// those teams as failed. Workspaces are only removed with a deleted team;
+// a stopped team keeps its workspace for the next deploy. Teams busy with
+// another operation are left for the next run.
+func (s *Server) collectInfraGarbage(ctx context.Context, dryRun bool) (*InfraGCReport, error) {
+	lister, ok := s.runtime.(runtime.InfraLister)
+	if !ok {
+		return nil, errInfraGCUnsupported
+	}
+	infra, err := lister.ListTeamInfra(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &InfraGCReport{DryRun: dryRun, Orphans: []OrphanedInfra{}, MissingContainers: []MissingContainer{}}
+	for _, ti := range infra {
+		reason, teamID, err := s.orphanReason(ti)
+		if err != nil {
+			return nil, fmt.Errorf("checking team %s: %w", ti.Slug, err)
+		}
+		if reason == "" {
+			continue
+		}
+		if !ti.HasActiveResources() && !removesWorkspace(ti, reason) {
+			// Only a workspace is left, which is kept for the next deploy
+			// while its team exists.
+			continue
+		}
+		entry := OrphanedInfra{TeamSlug: ti.Slug, TeamID: teamID, Reason: reason, Resources: ti.Resources}
+		if entry.Resources == nil {
+			entry.Resources = []runtime.InfraResource{}
+		}
+		if !dryRun {
+			entry.Removed = s.removeOrphanedInfra(ctx, ti)
+		}
+		report.Orphans = append(report.Orphans, entry)
+	}
+
+	missing, err := s.missingLeaders(infra)
+	if err != nil {
+		return nil, fmt.Errorf("checking running teams: %w", err)
+	}
+	for _, m := range missing {
+		if !dryRun {
+			m.Flagged = s.markDrift(ctx, m.TeamID, m.AgentID, m.ContainerID, protocol.DriftObservedMissing, nil)
+		}
+		report.MissingContainers = append(report.MissingContainers, m)
+	}
+	return report, nil
+}
+
+// orphanReason returns why ti has no team that may be using it, or "" if
+// it has one or belongs to an agent preview session that has not expired.
+// teamID is the team the infrastructure belonged to, if known.
+func (s *Server) orphanReason(ti runtime.TeamInfra) (reason, teamID string, err error) {
+	if live, err := s.isLivePreview(ti.Slug); err != nil || live {
+		// A preview session's resources are destroyed with it.
+		return "", "", err
+	}
+	if ti.TeamID != "" {
+		var owners []models.Team
+		if err := s.db.Select("id", "status").Where("id = ?", ti.TeamID).Limit(1).Find(&owners).Error; err != nil {
+			return "", "", err
+		}
+		if len(owners) > 0 {
+			if owners[0].Status == models.TeamStatusStopped {
+				return orphanTeamStopped, ti.TeamID, nil
+			}
+			return "", ti.TeamID, nil
+		}
+	}
+
+	// Unlabeled infrastructure, or a deleted owner: any team using the slug
+	// keeps it.
+	var teams []models.Team
+	if err := s.db.Select("id", "status").Where("slug = ?", ti.Slug).Find(&teams).Error; err != nil {
+		return "", "", err
+	}
+	for _, t := range teams {
+		if t.Status != models.TeamStatusStopped {
+			return "", t.ID, nil
+		}
+	}
+	if ti.TeamID == "" && len(teams) > 0 {
+		return orphanTeamStopped, teams[0].ID, nil
+	}
+	return orphanTeamDeleted, ti.TeamID, nil
+}
+
+// removesWorkspace reports whether tearing down ti, orphaned for reason,
+// removes its workspace too: only when its labels name a team that was
+// deleted. Unlabeled infrastructure predates the labels, so its workspace
+// may still be claimed by a team created with its slug.
+func removesWorkspace(ti runtime.TeamInfra, reason string) bool {
+	return reason == orphanTeamDeleted && ti.TeamID != ""
+}
+
+// removeOrphanedInfra tears down ti if it is still orphaned. A stopped
+// team is claimed first, so that a deploy cannot start meanwhile, and keeps
+// its workspace; a deleted team's workspace is removed too.
+func (s *Server) removeOrphanedInfra(ctx context.Context, ti runtime.TeamInfra) bool {
+	reason, teamID, err := s.orphanReason(ti)
+	if err != nil || reason == "" {
+		return false
+	}
+	if reason == orphanTeamStopped {
+		op, err := s.beginTeamOp(teamID, teamOpCleanup, false)
+		if err != nil {
+			slog.Info("team busy; leaving its infrastructure for the next run", "team", ti.Slug, "team_id", teamID)
+			return false
+		}
+		defer s.endTeamOp(teamID, op)
+		if reason, _, err := s.orphanReason(ti); err != nil || reason == "" {
+			return false
+		}
+	}
+
+	slog.Warn("removing orphaned team infrastructure", "team", ti.Slug, "team_id", teamID, "reason", reason, "resources", len(ti.Resources))
+	s.teardownTeamInfra(ctx, ti.Slug, true, runtime.TeardownOptions{PreserveWorkspace: !removesWorkspace(ti, reason)})
+	return true
+}
+
+// missingLeaders returns the leaders of running teams whose container is
+// not in infra.
+func (s *Server) missingLeaders(infra []runtime.TeamInfra) ([]MissingContainer, error) {
+	bySlug := make(map[string]runtime.TeamInfra, len(infra))
+	for _, ti := range infra {
+		bySlug[ti.Slug] = ti
+	}
+
+	var teams []models.Team
+	if err := s.db.Preload("Agents", "role = ? AND container_id <> ''", models.AgentRoleLeader).
+		Where("status = ?", models.TeamStatusRunning).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	var missing []MissingContainer
+	for _, team := range teams {
+		slug := team.Slug
+		if slug == "" {
+			slug = naming.Slug(team.Name)
+		}
+		for _, leader := range team.Agents {
+			if bySlug[slug].HasAgent(leader.ContainerID) {
+				continue
+			}
+			missing = append(missing, MissingContainer{
+				TeamID:      team.ID,
+				TeamName:    team.Name,
+				AgentID:     leader.ID,
+				AgentName:   leader.Name,
+				ContainerID: leader.ContainerID,
+			})
+		}
+	}
+	return missing, nil
+}
+
+// GetInfraGCReport reports what the infrastructure garbage collector would
+// remove or flag, without changing anything (admin only). Teams of other
+// organizations are omitted, and so is the infrastructure of deleted teams
+// in multi-tenant mode, since it cannot be attributed to an organization.
+func (s *Server) GetInfraGCReport(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view the infrastructure report")
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), time.Minute)
+	defer cancel()
+	report, err := s.collectInfraGarbage(ctx, true)
+	if errors.Is(err, errInfraGCUnsupported) {
+		return fiber.NewError(fiber.StatusNotImplemented, "the runtime cannot list team infrastructure")
+	}
+	if err != nil {
+		slog.Error("failed to build infrastructure report", "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list team infrastructure")
+	}
+
+	var teamIDs []string
+	if err := s.db.Model(&models.Team{}).Scopes(OrgScope(c)).Pluck("id", &teamIDs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	inOrg := make(map[string]bool, len(teamIDs))
+	for _, id := range teamIDs {
+		inOrg[id] = true
+	}
+
+	orphans := report.Orphans[:0]
+	for _, o := range report.Orphans {
+		if inOrg[o.TeamID] || (o.Reason == orphanTeamDeleted && !s.multiTenant) {
+			orphans = append(orphans, o)
+		}
+	}
+	report.Orphans = orphans
+	missing := report.MissingContainers[:0]
+	for _, m := range report.MissingContainers {
+		if inOrg[m.TeamID] {
+			missing = append(missing, m)
+		}
+	}
+	report.MissingContainers = missing
+	return c.JSON(report)
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// setupInfraGC creates a healthy running team, a running team whose leader
+// container vanished, a stopped team with leftover infrastructure and a
+// stopped team with only its kept workspace, and reports them, plus the
+// infrastructure of a deleted team and the workspace of another deleted
+// team, from the mock runtime.
+func setupInfraGC(t *testing.T) (*Server, *mockRuntime, map[string]models.Team) {
+	t.Helper()
+	srv, mock := setupTestServer(t)
+
+	teams := map[string]models.Team{}
+	for _, tc := range []struct{ name, status, container string }{
+		{"healthy", models.TeamStatusRunning, "c-healthy"},
+		{"vanished", models.TeamStatusRunning, "c-vanished"},
+		{"stopped", models.TeamStatusStopped, ""},
+		{"parked", models.TeamStatusStopped, ""},
+	} {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:   tc.name,
+			Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+		})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		srv.db.Model(&team).Update("status", tc.status)
+		srv.db.Model(&models.Agent{}).Where("team_id = ?", team.ID).Updates(map[string]interface{}{
+			"container_id":     tc.container,
+			"container_status": models.ContainerStatusRunning,
+		})
+		teams[tc.name] = team
+	}
+
+	mock.teamInfra = []runtime.TeamInfra{
+		{Slug: "deleted", TeamID: "gone", Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceNetwork, Name: "team-deleted"},
+			{Kind: runtime.ResourceVolume, Name: "team-deleted-workspace"},
+		}},
+		{Slug: "emptied", TeamID: "gone-too", Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceVolume, Name: "team-emptied-workspace"},
+		}},
+		{Slug: "parked", TeamID: teams["parked"].ID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceVolume, Name: "team-parked-workspace"},
+		}},
+		{Slug: "healthy", TeamID: teams["healthy"].ID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceContainer, Name: "team-healthy-leader", ID: "c-healthy"},
+		}},
+		{Slug: "stopped", TeamID: teams["stopped"].ID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceNetwork, Name: "team-stopped"},
+		}},
+		{Slug: "vanished", TeamID: teams["vanished"].ID, Resources: []runtime.InfraResource{
+			{Kind: runtime.ResourceContainer, Name: "team-vanished-nats", ID: "c-nats"},
+		}},
+	}
+	return srv, mock, teams
+}
+
+func TestCollectInfraGarbage_DryRun(t *testing.T) {
+	srv, mock, teams := setupInfraGC(t)
+
+	report, err := srv.collectInfraGarbage(t.Context(), true)
+	if err != nil {
+		t.Fatalf("collectInfraGarbage: %v", err)
+	}
+
+	if len(report.Orphans) != 3 {
+		t.Fatalf("orphans = %+v, want deleted, emptied and stopped", report.Orphans)
+	}
+	if o := report.Orphans[0]; o.TeamSlug != "deleted" || o.Reason != orphanTeamDeleted || len(o.Resources) != 2 || o.Removed {
+		t.Errorf("orphans[0] = %+v", o)
+	}
+	if o := report.Orphans[1]; o.TeamSlug != "emptied" || o.Reason != orphanTeamDeleted || len(o.Resources) != 1 {
+		t.Errorf("orphans[1] = %+v", o)
+	}
+	if o := report.Orphans[2]; o.TeamID != teams["stopped"].ID || o.Reason != orphanTeamStopped {
+		t.Errorf("orphans[2] = %+v", o)
+	}
+	if len(report.MissingContainers) != 1 || report.MissingContainers[0].ContainerID != "c-vanished" {
+		t.Errorf("missing containers = %+v, want c-vanished", report.MissingContainers)
+	}
+
+	if len(mock.tornDown) != 0 {
+		t.Errorf("dry run tore down %v", mock.tornDown)
+	}
+	var team models.Team
+	srv.db.First(&team, "id = ?", teams["vanished"].ID)
+	if team.Status != models.TeamStatusRunning {
+		t.Errorf("dry run changed team status to %q", team.Status)
+	}
+}
+
+func TestCollectInfraGarbage(t *testing.T) {
+	srv, mock, teams := setupInfraGC(t)
+
+	report, err := srv.collectInfraGarbage(t.Context(), false)
+	if err != nil {
+		t.Fatalf("collectInfraGarbage: %v", err)
+	}
+	for _, o := range report.Orphans {
+		if !o.Removed {
+			t.Errorf("orphan %s not removed", o.TeamSlug)
+		}
+	}
+	if strings.Join(mock.tornDown, ",") != "deleted,emptied,stopped" {
+		t.Errorf("torn down %v, want deleted, emptied and stopped", mock.tornDown)
+	}
+	// Deleted teams lose their workspace, including one left on its own;
+	// stopped teams keep theirs.
+	if strings.Join(mock.preserved, ",") != "stopped" {
+		t.Errorf("workspaces preserved for %v, want only the stopped team", mock.preserved)
+	}
+
+	if len(report.MissingContainers) != 1 || !report.MissingContainers[0].Flagged {
+		t.Fatalf("missing containers = %+v", report.MissingContainers)
+	}
+	var team models.Team
+	srv.db.Preload("Agents").First(&team, "id = ?", teams["vanished"].ID)
+	if team.Status != models.TeamStatusError || team.StatusMessage == "" {
+		t.Errorf("vanished team: status %q, message %q", team.Status, team.StatusMessage)
+	}
+	if team.Agents[0].ContainerStatus != models.ContainerStatusError {
+		t.Errorf("leader container status = %q, want error", team.Agents[0].ContainerStatus)
+	}
+	var healthy models.Team
+	srv.db.First(&healthy, "id = ?", teams["healthy"].ID)
+	if healthy.Status != models.TeamStatusRunning {
+		t.Errorf("healthy team status = %q", healthy.Status)
+	}
+}
+
+func TestCollectInfraGarbage_SkipsBusyTeams(t *testing.T) {
+	srv, mock, teams := setupInfraGC(t)
+
+	for _, name := range []string{"stopped", "vanished"} {
+		op, err := srv.beginTeamOp(teams[name].ID, teamOpDeploy, false)
+		if err != nil {
+			t.Fatalf("beginTeamOp: %v", err)
+		}
+		defer srv.endTeamOp(teams[name].ID, op)
+	}
+
+	report, err := srv.collectInfraGarbage(t.Context(), false)
+	if err != nil {
+		t.Fatalf("collectInfraGarbage: %v", err)
+	}
+	if strings.Join(mock.tornDown, ",") != "deleted,emptied" {
+		t.Errorf("torn down %v, want only the deleted teams", mock.tornDown)
+	}
+	if report.Orphans[2].Removed || report.MissingContainers[0].Flagged {
+		t.Errorf("busy teams were changed: %+v", report)
+	}
+}
+
+func TestGetInfraGCReport(t *testing.T) {
+	srv, mock, _ := setupInfraGC(t)
+
+	rec := doRequest(srv, "GET", "/api/admin/infra-gc", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var report InfraGCReport
+	parseJSON(t, rec, &report)
+	if !report.DryRun || len(report.Orphans) != 3 || len(report.MissingContainers) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(mock.tornDown) != 0 {
+		t.Errorf("report endpoint tore down %v", mock.tornDown)
+	}
+
+	// Deleted teams cannot be attributed to an organization in
+	// multi-tenant mode.
+	srv.SetMultiTenant(true)
+	rec = doRequest(srv, "GET", "/api/admin/infra-gc", nil)
+	parseJSON(t, rec, &report)
+	if len(report.Orphans) != 1 || report.Orphans[0].TeamSlug != "stopped" {
+		t.Errorf("multi-tenant orphans = %+v, want only the stopped team", report.Orphans)
+	}
+}
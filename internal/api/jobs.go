@@ -0,0 +1,298 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Kinds of the background jobs the API runs.
+const (
+	jobTeamDeploy    = "team.deploy"
+	jobTeamStop      = "team.stop"
+	jobLeaderRestart = "team.restart_leader"
+	jobTeamMigrate   = "team.migrate"
+	jobWorkspaceSync = "team.workspace_sync"
+	jobAgentUpgrade  = "agent_upgrade.run"
+	jobAgentRollback = "agent_upgrade.rollback"
+)
+
+// Attempts per job. Team jobs are retried while another operation holds
+// the team. Upgrade jobs restart leaders, so an interrupted one is not run
+// again.
+const (
+	teamJobAttempts    = 5
+	upgradeJobAttempts = 1
+)
+
+// jobListOptions configures GET /api/jobs: newest first, filterable by
+// status, kind and team.
+var jobListOptions = listOptions{
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status", "kind": "kind", "team_id": "team_id"},
+}
+
+func jobKey(j models.Job) (time.Time, string) { return j.CreatedAt, j.ID }
+
+// leaderRestartPayload is the payload of a jobLeaderRestart job.
+type leaderRestartPayload struct {
+	AgentID     string `json:"agent_id"`
+	PullImage   bool   `json:"pull_image"`
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// agentUpgradePayload is the payload of jobAgentUpgrade and
+// jobAgentRollback jobs.
+type agentUpgradePayload struct {
+	UpgradeID string `json:"upgrade_id"`
+}
+
+// registerJobs registers the handlers of the API's background jobs.
+func (s *Server) registerJobs() {
+	s.jobs.Register(jobTeamDeploy, s.runDeployJob)
+	s.jobs.Register(jobTeamStop, s.runStopJob)
+	s.jobs.Register(jobLeaderRestart, s.runLeaderRestartJob)
+	s.jobs.Register(jobTeamMigrate, s.runMigrateJob)
+	s.jobs.Register(jobWorkspaceSync, s.runWorkspaceSyncJob)
+	s.jobs.Register(jobAgentUpgrade, s.runAgentUpgradeJob)
+	s.jobs.Register(jobAgentRollback, s.runAgentRollbackJob)
+}
+
+// StartJobs starts the workers running background jobs, at most workers at
+// a time, or jobs.DefaultWorkers if workers is not positive. Every replica
+// runs them, whichever one enqueued a job.
+func (s *Server) StartJobs(workers int) {
+	s.jobs.Start(workers)
+}
+
+// StopJobs stops the background job workers. Jobs still running are
+// queued again for another replica, or the next start, to pick up.
+func (s *Server) StopJobs() {
+	s.jobs.Stop()
+}
+
+// enqueueTeamJob queues a job operating on team's infrastructure. The
+// operation is keyed by the team's conversation, which each deploy and
+// leader restart renews, so that it is only queued once.
+func (s *Server) enqueueTeamJob(kind string, team models.Team, payload interface{}) (*models.Job, error) {
+	job, err := s.jobs.Enqueue(jobs.Options{
+		Kind:           kind,
+		OrgID:          team.OrgID,
+		TeamID:         team.ID,
+		IdempotencyKey: kind + "/" + team.ID + "/" + team.ConversationID,
+		Payload:        payload,
+		MaxAttempts:    teamJobAttempts,
+	})
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return job, nil
+	}
+	return job, err
+}
+
+// teamJobWait bounds how long a job waits for its team to be released
+// before it is retried. The request that queued the job holds the team
+// until it responds, so the job usually finds it free within moments.
+var teamJobWait = 5 * time.Second
+
+// beginTeamJob claims the job's team for the operation name. The operation
+// is cancelled along with ctx. While another operation holds the team the
+// job waits up to teamJobWait, then is retried; on its last attempt, fail is
+// called with the reason, and the job fails.
+func (s *Server) beginTeamJob(ctx context.Context, job *models.Job, name string, fail func(msg string)) (*teamOp, func(), error) {
+	deadline := time.Now().Add(teamJobWait)
+	op, err := s.beginTeamOp(job.TeamID, name, false)
+	for err != nil && time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(teamOpPollInterval):
+		}
+		op, err = s.beginTeamOp(job.TeamID, name, false)
+	}
+	if err != nil {
+		if job.Attempts >= job.MaxAttempts {
+			fail(err.Error())
+			return nil, nil, jobs.Permanent(err)
+		}
+		return nil, nil, err
+	}
+	stop := context.AfterFunc(ctx, op.cancel)
+	return op, func() {
+		stop()
+		s.endTeamOp(job.TeamID, op)
+	}, nil
+}
+
+// runDeployJob deploys the job's team, unless it was stopped since the
+// deployment was queued. A deployment interrupted by the queue stopping is
+// resumed by the requeued job.
+func (s *Server) runDeployJob(ctx context.Context, job *models.Job) error {
+	var trigger deploymentTrigger
+	if err := jobs.Decode(job, &trigger); err != nil {
+		return err
+	}
+	op, end, err := s.beginTeamJob(ctx, job, teamOpDeploy, func(msg string) {
+		s.db.Model(&models.Team{}).Where("id = ? AND status = ?", job.TeamID, models.TeamStatusDeploying).
+			Updates(map[string]interface{}{
+				"status":         models.TeamStatusError,
+				"status_message": "Deployment could not start: " + msg,
+			})
+	})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", job.TeamID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	if team.Status != models.TeamStatusDeploying {
+		return jobs.Skip("team is no longer deploying")
+	}
+
+	s.deployTeamAsync(withDeploymentTrigger(op.ctx, trigger), team)
+
+	// A deployment cut short by the queue stopping, rather than by a forced
+	// operation, is not a failure: the team stays deploying so that the
+	// requeued job deploys it again.
+	if ctx.Err() != nil {
+		s.db.Model(&models.Team{}).Where("id = ? AND status = ?", team.ID, models.TeamStatusError).
+			Updates(map[string]interface{}{
+				"status":         models.TeamStatusDeploying,
+				"status_message": "Deployment interrupted; it resumes when the job runs again",
+			})
+		return ctx.Err()
+	}
+
+	s.db.Select("status", "status_message").First(&team, "id = ?", team.ID)
+	if team.Status == models.TeamStatusError {
+		return jobs.Permanent(errors.New(team.StatusMessage))
+	}
+	return nil
+}
+
+// runLeaderRestartJob restarts the job's team leader, unless the team was
+// stopped since the restart was queued.
+func (s *Server) runLeaderRestartJob(ctx context.Context, job *models.Job) error {
+	var p leaderRestartPayload
+	if err := jobs.Decode(job, &p); err != nil {
+		return err
+	}
+	op, end, err := s.beginTeamJob(ctx, job, teamOpRestart, func(msg string) {
+		s.db.Model(&models.Team{}).Where("id = ? AND status = ?", job.TeamID, models.TeamStatusDeploying).
+			Updates(map[string]interface{}{
+				"status":         models.TeamStatusError,
+				"status_message": "Leader restart could not start: " + msg,
+			})
+	})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", job.TeamID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	if team.Status != models.TeamStatusDeploying {
+		return jobs.Skip("team is no longer restarting")
+	}
+	var agent models.Agent
+	if err := s.db.Where("id = ? AND team_id = ?", p.AgentID, team.ID).First(&agent).Error; err != nil {
+		return jobs.Skip("agent not found")
+	}
+
+	ctx = withDeploymentTrigger(op.ctx, deploymentTrigger{TriggeredBy: p.RequestedBy})
+	if err := s.restartLeaderAsync(ctx, team, agent, p.PullImage); err != nil {
+		return jobs.Permanent(err)
+	}
+	return nil
+}
+
+// runAgentUpgradeJob runs an agent upgrade that is still in progress. The
+// job fails if the upgrade halts.
+func (s *Server) runAgentUpgradeJob(ctx context.Context, job *models.Job) error {
+	upgrade, err := s.loadJobUpgrade(job, models.AgentUpgradeStatusRunning)
+	if err != nil {
+		return err
+	}
+	s.runAgentUpgrade(upgrade)
+
+	s.db.Select("status", "error").First(&upgrade, "id = ?", upgrade.ID)
+	if upgrade.Status == models.AgentUpgradeStatusHalted {
+		return jobs.Permanent(errors.New(upgrade.Error))
+	}
+	return nil
+}
+
+// runAgentRollbackJob runs the rollback of an agent upgrade.
+func (s *Server) runAgentRollbackJob(ctx context.Context, job *models.Job) error {
+	upgrade, err := s.loadJobUpgrade(job, models.AgentUpgradeStatusRollingBack)
+	if err != nil {
+		return err
+	}
+	s.runAgentRollback(upgrade)
+	return nil
+}
+
+// loadJobUpgrade loads the agent upgrade of the job with its results. The
+// job is skipped unless the upgrade has status.
+func (s *Server) loadJobUpgrade(job *models.Job, status string) (models.AgentUpgrade, error) {
+	var p agentUpgradePayload
+	var upgrade models.AgentUpgrade
+	if err := jobs.Decode(job, &p); err != nil {
+		return upgrade, err
+	}
+	if err := s.db.Preload("Results").First(&upgrade, "id = ?", p.UpgradeID).Error; err != nil {
+		return upgrade, jobs.Skip("agent upgrade not found")
+	}
+	if upgrade.Status != status {
+		return upgrade, jobs.Skip("agent upgrade is " + upgrade.Status)
+	}
+	return upgrade, nil
+}
+
+// enqueueUpgradeJob queues a job running or rolling back an agent upgrade.
+func (s *Server) enqueueUpgradeJob(kind string, upgrade models.AgentUpgrade) error {
+	_, err := s.jobs.Enqueue(jobs.Options{
+		Kind:           kind,
+		OrgID:          upgrade.OrgID,
+		IdempotencyKey: kind + "/" + upgrade.ID,
+		Payload:        agentUpgradePayload{UpgradeID: upgrade.ID},
+		MaxAttempts:    upgradeJobAttempts,
+	})
+	if errors.Is(err, jobs.ErrDuplicate) {
+		return nil
+	}
+	return err
+}
+
+// ListJobs returns the organization's background jobs.
+func (s *Server) ListJobs(c *fiber.Ctx) error {
+	q, err := parseListQuery(c, jobListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Scopes(OrgScope(c)), q, jobKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list jobs")
+	}
+	return c.JSON(resp)
+}
+
+// GetJob returns a background job.
+func (s *Server) GetJob(c *fiber.Ctx) error {
+	var job models.Job
+	if err := s.db.Scopes(OrgScope(c)).First(&job, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "job not found")
+	}
+	return c.JSON(job)
+}
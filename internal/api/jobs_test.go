@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// waitForJob polls the team's job of kind until it reaches status.
+func waitForJob(t *testing.T, srv *Server, teamID, kind, status string) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var page struct {
+			Items []models.Job `json:"items"`
+		}
+		rec := doRequest(srv, "GET", "/api/jobs?team_id="+teamID+"&kind="+kind, nil)
+		parseJSON(t, rec, &page)
+		if len(page.Items) > 0 && page.Items[0].Status == status {
+			return page.Items[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s job of team %s: got %+v, want %s", kind, teamID, page.Items, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeployTeam_RunsAsJob(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "job-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	if rec.Code != 200 {
+		t.Fatalf("deploy: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	job := waitForJob(t, srv, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+	if job.Attempts != 1 || job.OrgID != team.OrgID {
+		t.Errorf("job: attempts %d, org %q", job.Attempts, job.OrgID)
+	}
+	var deployed models.Team
+	srv.db.First(&deployed, "id = ?", team.ID)
+	if deployed.Status != models.TeamStatusRunning {
+		t.Errorf("team status: got %q, want running", deployed.Status)
+	}
+
+	rec = doRequest(srv, "GET", "/api/jobs/"+job.ID, nil)
+	if rec.Code != 200 {
+		t.Errorf("get job: got %d, want 200", rec.Code)
+	}
+}
+
+func TestDeployTeam_QueuedJobHoldsTeam(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	// No workers: the deployment stays queued.
+	srv.StopJobs()
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "queued-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil); rec.Code != 200 {
+		t.Fatalf("deploy: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	if rec.Code != 409 || !strings.Contains(rec.Body.String(), jobTeamDeploy) {
+		t.Errorf("second deploy: got %d, want 409 naming the queued job\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	// force stops the team and cancels the queued deployment.
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/stop?force=true", nil)
+	if rec.Code != 200 {
+		t.Fatalf("forced stop: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if !mock.teardownCalled {
+		t.Error("expected TeardownInfra to be called")
+	}
+	var job models.Job
+	srv.db.First(&job, "team_id = ? AND kind = ?", team.ID, jobTeamDeploy)
+	if job.Status != models.JobStatusCancelled {
+		t.Errorf("job status: got %q, want cancelled", job.Status)
+	}
+}
+
+func TestDeployJob_SkipsStoppedTeam(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	srv.StopJobs()
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "stopped-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	if _, err := srv.enqueueTeamJob(jobTeamDeploy, team, nil); err != nil {
+		t.Fatalf("enqueueTeamJob: %v", err)
+	}
+	srv.StartJobs(1)
+
+	job := waitForJob(t, srv, team.ID, jobTeamDeploy, models.JobStatusCancelled)
+	if job.Error != "team is no longer deploying" {
+		t.Errorf("job error: got %q", job.Error)
+	}
+	if mock.lastInfraConfig != nil {
+		t.Error("stopped team was deployed")
+	}
+}
+
+// blockingInfraRuntime is a mockRuntime whose first DeployInfra blocks until
+// its context is cancelled.
+type blockingInfraRuntime struct {
+	*mockRuntime
+	started chan struct{}
+	once    sync.Once
+}
+
+func (r *blockingInfraRuntime) DeployInfra(ctx context.Context, cfg runtime.InfraConfig) error {
+	blocked := false
+	r.once.Do(func() { blocked = true })
+	if !blocked {
+		return r.mockRuntime.DeployInfra(ctx, cfg)
+	}
+	close(r.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDeployJob_ResumesAfterQueueStop(t *testing.T) {
+	base, _ := setupTestServer(t)
+	base.StopJobs()
+	rt := &blockingInfraRuntime{mockRuntime: &mockRuntime{}, started: make(chan struct{})}
+	srv := NewServer(base.db, rt, base.authProvider)
+
+	team := createTestTeam(t, srv, "resumed")
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+	if _, err := srv.enqueueTeamJob(jobTeamDeploy, team, deploymentTrigger{}); err != nil {
+		t.Fatalf("enqueueTeamJob: %v", err)
+	}
+	srv.StartJobs(1)
+	<-rt.started
+	srv.StopJobs()
+
+	var got models.Team
+	srv.db.First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusDeploying {
+		t.Fatalf("after queue stop: team status %q (%s), want deploying", got.Status, got.StatusMessage)
+	}
+	waitForJob(t, srv, team.ID, jobTeamDeploy, models.JobStatusQueued)
+
+	srv.StartJobs(1)
+	t.Cleanup(srv.StopJobs)
+	waitForJob(t, srv, team.ID, jobTeamDeploy, models.JobStatusSucceeded)
+	srv.db.First(&got, "id = ?", team.ID)
+	if got.Status != models.TeamStatusRunning {
+		t.Errorf("resumed deployment: team status %q, want running", got.Status)
+	}
+}
+
+func TestListJobs_ScopedToOrg(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.StopJobs()
+	srv.db.Create(&models.Job{ID: "other", OrgID: "other-org", Kind: jobTeamDeploy, Status: models.JobStatusQueued})
+
+	var page struct {
+		Items []models.Job `json:"items"`
+	}
+	parseJSON(t, doRequest(srv, "GET", "/api/jobs", nil), &page)
+	for _, job := range page.Items {
+		if job.ID == "other" {
+			t.Error("listed another organization's job")
+		}
+	}
+	if rec := doRequest(srv, "GET", "/api/jobs/other", nil); rec.Code != 404 {
+		t.Errorf("get other org's job: got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Default messages of maintenance mode.
+const (
+	defaultMaintenanceMessage = "The platform is under maintenance; try again later"
+	defaultKillSwitchMessage  = "All teams were stopped by an administrator"
+)
+
+// stoppedByKillSwitch is the status message of a team stopped by the kill
+// switch.
+const stoppedByKillSwitch = "Stopped by the kill switch"
+
+// MaintenanceRequest is the payload for POST /api/admin/maintenance.
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+	// Message is returned to the deploys, runs and chat messages refused
+	// while maintenance is on.
+	Message string `json:"message"`
+}
+
+// KillSwitchRequest is the optional payload for POST /api/admin/kill-switch.
+type KillSwitchRequest struct {
+	// Message is the maintenance message set along with the kill switch.
+	Message string `json:"message"`
+}
+
+// teamStopPayload is the payload of a jobTeamStop job.
+type teamStopPayload struct {
+	// Reason is the status message of the stopped team.
+	Reason string `json:"reason"`
+}
+
+// checkMaintenance fails with CodeMaintenance while the organization of the
+// request is in maintenance mode.
+func (s *Server) checkMaintenance(c *fiber.Ctx) error {
+	return s.checkOrgMaintenance(GetOrgID(c))
+}
+
+// checkOrgMaintenance fails with CodeMaintenance while organization orgID is
+// in maintenance mode. Webhook and issue runs, which have no user, check the
+// organization of their team with it.
+func (s *Server) checkOrgMaintenance(orgID string) error {
+	var org models.Organization
+	if err := s.db.Select("id", "maintenance", "maintenance_message").
+		First(&org, "id = ?", orgID).Error; err != nil || !org.Maintenance {
+		return nil
+	}
+	return newAPIError(CodeMaintenance, org.MaintenanceMessage)
+}
+
+// GetMaintenance returns the organization's maintenance mode (admin only).
+func (s *Server) GetMaintenance(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view maintenance mode")
+	}
+	var org models.Organization
+	if err := s.db.First(&org, "id = ?", GetOrgID(c)).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "organization not found")
+	}
+	return c.JSON(maintenanceStatus(org))
+}
+
+// SetMaintenance turns the organization's maintenance mode on or off
+// (admin only). While it is on, new deploys and chat messages are refused
+// with its message; running teams are left alone.
+func (s *Server) SetMaintenance(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can change maintenance mode")
+	}
+	var req MaintenanceRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if req.Message == "" {
+		req.Message = defaultMaintenanceMessage
+	}
+	org, err := s.setMaintenance(c, req.Enabled, req.Message)
+	if err != nil {
+		return err
+	}
+	return c.JSON(maintenanceStatus(org))
+}
+
+// KillSwitch puts the organization in maintenance mode and stops every
+// running or deploying team (admin only). Each team is stopped by a
+// background job, keeping its workspace, so the job workers stop a batch
+// at a time. Maintenance stays on until an admin turns it off.
+func (s *Server) KillSwitch(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can use the kill switch")
+	}
+	var req KillSwitchRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+	if req.Message == "" {
+		req.Message = defaultKillSwitchMessage
+	}
+	org, err := s.setMaintenance(c, true, req.Message)
+	if err != nil {
+		return err
+	}
+
+	var teams []models.Team
+	if err := s.db.Scopes(OrgScope(c)).
+		Where("status IN ?", []string{models.TeamStatusRunning, models.TeamStatusDeploying}).
+		Find(&teams).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	jobIDs := make([]string, 0, len(teams))
+	for _, team := range teams {
+		// Queued deployments would start the team again.
+		if _, err := s.jobs.CancelQueued(team.ID, "cancelled by the kill switch"); err != nil {
+			slog.Error("kill switch: failed to cancel queued team jobs", "team", team.Name, "error", err)
+		}
+		job, err := s.enqueueTeamJob(jobTeamStop, team, teamStopPayload{Reason: stoppedByKillSwitch})
+		if err != nil {
+			slog.Error("kill switch: failed to queue team stop", "team", team.Name, "error", err)
+			continue
+		}
+		jobIDs = append(jobIDs, job.ID)
+	}
+
+	slog.Warn("kill switch engaged", "org_id", org.ID, "by", GetEmail(c), "teams", len(teams))
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"maintenance": maintenanceStatus(org),
+		"teams":       len(teams),
+		"job_ids":     jobIDs,
+	})
+}
+
+// setMaintenance turns the maintenance mode of the request's organization
+// on or off, and returns the organization.
+func (s *Server) setMaintenance(c *fiber.Ctx, enabled bool, message string) (models.Organization, error) {
+	var org models.Organization
+	if err := s.db.First(&org, "id = ?", GetOrgID(c)).Error; err != nil {
+		return org, fiber.NewError(fiber.StatusNotFound, "organization not found")
+	}
+	updates := map[string]interface{}{
+		"maintenance":         false,
+		"maintenance_message": "",
+		"maintenance_since":   nil,
+		"maintenance_by":      "",
+	}
+	if enabled {
+		since := time.Now()
+		if org.Maintenance && org.MaintenanceSince != nil {
+			since = *org.MaintenanceSince
+		}
+		updates = map[string]interface{}{
+			"maintenance":         true,
+			"maintenance_message": message,
+			"maintenance_since":   since,
+			"maintenance_by":      GetEmail(c),
+		}
+	}
+	if err := s.db.Model(&org).Updates(updates).Error; err != nil {
+		return org, fiber.NewError(fiber.StatusInternalServerError, "failed to update maintenance mode")
+	}
+	if enabled != org.Maintenance {
+		slog.Info("maintenance mode changed", "org_id", org.ID, "enabled", enabled, "by", GetEmail(c))
+	}
+	var updated models.Organization
+	s.db.First(&updated, "id = ?", org.ID)
+	return updated, nil
+}
+
+// maintenanceStatus is the maintenance mode of org as returned by the API.
+func maintenanceStatus(org models.Organization) fiber.Map {
+	return fiber.Map{
+		"enabled": org.Maintenance,
+		"message": org.MaintenanceMessage,
+		"since":   org.MaintenanceSince,
+		"by":      org.MaintenanceBy,
+	}
+}
+
+// runStopJob stops the job's team, keeping its workspace, unless it was
+// stopped since the job was queued. An operation in progress on the team,
+// such as a deployment, is cancelled.
+func (s *Server) runStopJob(ctx context.Context, job *models.Job) error {
+	var p teamStopPayload
+	if err := jobs.Decode(job, &p); err != nil {
+		return err
+	}
+	// Like a forced stop, cancel the operation in progress on the team.
+	op, err := s.beginTeamOp(job.TeamID, teamOpStop, true)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(job.TeamID, op)
+	defer context.AfterFunc(ctx, op.cancel)()
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", job.TeamID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	if team.Status == models.TeamStatusStopped {
+		return jobs.Skip("team is already stopped")
+	}
+
+	stopCtx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
+	defer cancel()
+	s.stopTeam(stopCtx, &team, true)
+	if p.Reason != "" {
+		s.db.Model(&team).Update("status_message", p.Reason)
+	}
+	slog.Info("team stopped by job", "team", team.Name, "reason", p.Reason)
+	return nil
+}
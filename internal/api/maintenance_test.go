@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestMaintenance_BlocksDeploysAndChats(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createTestTeam(t, srv, "maintained-team")
+
+	rec := doRequest(srv, "POST", "/api/admin/maintenance", MaintenanceRequest{Enabled: true, Message: "Upgrading the cluster"})
+	if rec.Code != 200 {
+		t.Fatalf("enable maintenance: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var org models.Organization
+	parseJSON(t, doRequest(srv, "GET", "/api/org", nil), &org)
+	if !org.Maintenance || org.MaintenanceSince == nil {
+		t.Errorf("organization: got %+v", org)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	var resp ErrorResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != http.StatusServiceUnavailable || resp.Code != CodeMaintenance || resp.Error != "Upgrading the cluster" {
+		t.Errorf("deploy in maintenance: got %d %+v", rec.Code, resp)
+	}
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", map[string]string{"message": "hello"}); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("chat in maintenance: got %d, want 503", rec.Code)
+	}
+	srv.db.Model(&team).Update("status", models.TeamStatusStopped)
+
+	if rec := doRequest(srv, "POST", "/api/admin/maintenance", MaintenanceRequest{Enabled: false}); rec.Code != 200 {
+		t.Fatalf("disable maintenance: got %d", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil); rec.Code != 200 {
+		t.Errorf("deploy after maintenance: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMaintenance_BlocksRunsAndTeamOperations(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createTestTeam(t, srv, "maintained-runs")
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	leader := team.Agents[0]
+	srv.db.Model(&leader).Update("container_id", "leader-container")
+
+	token := sha256.Sum256([]byte("maintenance-token"))
+	srv.db.Create(&models.Webhook{
+		ID: "wh-maintenance", OrgID: team.OrgID, Name: "hook", TeamID: team.ID,
+		PromptTemplate: "run", SecretTokenHash: hex.EncodeToString(token[:]),
+		Enabled: true, TimeoutSeconds: 60, MaxConcurrent: 1,
+	})
+	srv.db.Create(&models.RunPlan{ID: "run-maintenance", TeamID: team.ID, Status: models.RunPlanStatusPending})
+
+	if rec := doRequest(srv, "POST", "/api/admin/maintenance", MaintenanceRequest{Enabled: true}); rec.Code != 200 {
+		t.Fatalf("enable maintenance: got %d", rec.Code)
+	}
+	for _, req := range []struct{ name, path string }{
+		{"webhook", "/webhook/trigger/maintenance-token"},
+		{"plan approval", "/api/teams/" + team.ID + "/runs/run-maintenance/approve-plan"},
+		{"leader restart", "/api/teams/" + team.ID + "/agents/" + leader.ID + "/restart"},
+		{"evaluations", "/api/teams/" + team.ID + "/evaluations/run"},
+	} {
+		rec := doRequest(srv, "POST", req.path, nil)
+		var resp ErrorResponse
+		parseJSON(t, rec, &resp)
+		if rec.Code != http.StatusServiceUnavailable || resp.Code != CodeMaintenance {
+			t.Errorf("%s in maintenance: got %d %+v", req.name, rec.Code, resp)
+		}
+	}
+
+	var runs, evalRuns int64
+	srv.db.Model(&models.WebhookRun{}).Count(&runs)
+	srv.db.Model(&models.EvaluationRun{}).Count(&evalRuns)
+	var plan models.RunPlan
+	srv.db.First(&plan, "id = ?", "run-maintenance")
+	var got models.Team
+	srv.db.First(&got, "id = ?", team.ID)
+	if runs != 0 || evalRuns != 0 || plan.Status != models.RunPlanStatusPending || got.Status != models.TeamStatusRunning {
+		t.Errorf("after refused requests: %d webhook runs, %d evaluation runs, plan %q, team %q", runs, evalRuns, plan.Status, got.Status)
+	}
+}
+
+func TestMaintenance_AdminOnly(t *testing.T) {
+	admin, _ := setupTestServer(t)
+	member := NewServer(admin.db, &mockRuntime{}, memberAuth{admin.authProvider})
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/api/admin/maintenance"},
+		{"POST", "/api/admin/maintenance"},
+		{"POST", "/api/admin/kill-switch"},
+	} {
+		if rec := doRequest(member, req.method, req.path, MaintenanceRequest{Enabled: true}); rec.Code != 403 {
+			t.Errorf("%s %s: got %d, want 403", req.method, req.path, rec.Code)
+		}
+	}
+}
+
+func TestKillSwitch_StopsRunningTeams(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	running := createTestTeam(t, srv, "running-team")
+	stopped := createTestTeam(t, srv, "stopped-team")
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+running.ID+"/deploy", nil); rec.Code != 200 {
+		t.Fatalf("deploy: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, running.ID, jobTeamDeploy, models.JobStatusSucceeded)
+
+	rec := doRequest(srv, "POST", "/api/admin/kill-switch", KillSwitchRequest{Message: "Cost incident"})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("kill switch: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Teams  int      `json:"teams"`
+		JobIDs []string `json:"job_ids"`
+	}
+	parseJSON(t, rec, &resp)
+	if resp.Teams != 1 || len(resp.JobIDs) != 1 {
+		t.Fatalf("kill switch response: got %+v", resp)
+	}
+
+	waitForJob(t, srv, running.ID, jobTeamStop, models.JobStatusSucceeded)
+	var team models.Team
+	srv.db.First(&team, "id = ?", running.ID)
+	if team.Status != models.TeamStatusStopped || team.StatusMessage != stoppedByKillSwitch {
+		t.Errorf("team after kill switch: got %s %q", team.Status, team.StatusMessage)
+	}
+	if !mock.teardownCalled {
+		t.Error("team infrastructure was not torn down")
+	}
+	var other models.Job
+	if err := srv.db.First(&other, "team_id = ? AND kind = ?", stopped.ID, jobTeamStop).Error; err == nil {
+		t.Errorf("stopped team got a stop job: %+v", other)
+	}
+
+	var org models.Organization
+	srv.db.First(&org, "id = ?", team.OrgID)
+	if !org.Maintenance || org.MaintenanceMessage != "Cost incident" {
+		t.Errorf("maintenance after kill switch: got %v %q", org.Maintenance, org.MaintenanceMessage)
+	}
+}
@@ -1,13 +1,16 @@
 package api
 
 import (
+	"errors"
 	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 
 	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
 )
 
 // requestLogger returns a middleware that logs each request.
@@ -27,9 +30,11 @@ func requestLogger() fiber.Handler {
 }
 
 // authMiddleware validates the JWT token and injects user/org claims into
-// the request context. For the noop provider, it injects default claims
-// without requiring an Authorization header.
-func authMiddleware(provider auth.AuthProvider) fiber.Handler {
+// the request context. The role is read from the database rather than the
+// token, so that a role change applies to tokens issued before it. For the
+// noop provider, it injects default claims without requiring an
+// Authorization header.
+func authMiddleware(provider auth.AuthProvider, db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Noop provider: inject default claims, no token required.
 		if provider.ProviderName() == "noop" {
@@ -45,47 +50,97 @@ func authMiddleware(provider auth.AuthProvider) fiber.Handler {
 		// Extract Bearer token from Authorization header.
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return fiber.NewError(fiber.StatusUnauthorized, "missing authorization header")
+			return newAPIError(CodeAuthMissing, "missing authorization header")
 		}
 
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 		if token == authHeader {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid authorization format")
+			return newAPIError(CodeAuthInvalid, "invalid authorization format")
 		}
 
 		claims, err := provider.ValidateToken(c.Context(), token)
 		if err != nil {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
+			return newAPIError(CodeAuthInvalid, "invalid or expired token")
+		}
+		role, err := currentRole(db, claims)
+		if err != nil {
+			return err
 		}
 
 		c.Locals("user_id", claims.UserID)
 		c.Locals("org_id", claims.OrgID)
 		c.Locals("email", claims.Email)
 		c.Locals("name", claims.Name)
-		c.Locals("role", claims.Role)
+		c.Locals("role", role)
 		return c.Next()
 	}
 }
 
+// currentRole returns the role the user a token was issued to has now. A
+// user who was deleted or moved to another organization since is no longer
+// authenticated by the token.
+func currentRole(db *gorm.DB, claims *auth.Claims) (string, error) {
+	var user models.User
+	err := db.Select("role").First(&user, "id = ? AND org_id = ?", claims.UserID, claims.OrgID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", newAPIError(CodeAuthInvalid, "invalid or expired token")
+	}
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, "failed to load user")
+	}
+	return user.Role, nil
+}
+
+// observerWritablePaths are the non-read requests observers may still make:
+// editing their own profile and password.
+var observerWritablePaths = map[string]bool{
+	"/api/auth/me":          true,
+	"/api/auth/me/password": true,
+}
+
+// observerGuard rejects every request of an observer that is not a read
+// (GET or HEAD), so observers can watch teams without sending chats,
+// deploying or changing configuration.
+func observerGuard() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !IsObserver(c) {
+			return c.Next()
+		}
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead:
+			return c.Next()
+		}
+		if observerWritablePaths[strings.TrimSuffix(c.Path(), "/")] {
+			return c.Next()
+		}
+		return newAPIError(CodeReadOnly, "observers have read-only access")
+	}
+}
+
 // globalErrorHandler handles unhandled errors and returns JSON.
 // Internal errors (5xx) return a generic message to avoid leaking implementation details.
 func globalErrorHandler(c *fiber.Ctx, err error) error {
-	code := fiber.StatusInternalServerError
+	status := fiber.StatusInternalServerError
+	code := CodeInternal
 	msg := "internal server error"
 
-	if e, ok := err.(*fiber.Error); ok {
-		code = e.Code
-		// Only expose error messages for client errors (4xx).
-		if code < 500 {
-			msg = e.Message
-		} else {
-			slog.Error("internal error", "error", e.Message, "path", c.Path())
-		}
-	} else {
+	var apiErr *APIError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &apiErr):
+		status, code, msg = apiErr.Status, apiErr.Code, apiErr.Message
+	case errors.As(err, &fiberErr):
+		status, code, msg = fiberErr.Code, codeForStatus(fiberErr.Code), fiberErr.Message
+	default:
 		slog.Error("unhandled error", "error", err.Error(), "path", c.Path())
+		return c.Status(status).JSON(ErrorResponse{Error: msg, Code: code})
 	}
 
-	return c.Status(code).JSON(ErrorResponse{
-		Error: msg,
-	})
+	// Only expose error messages for client errors (4xx), and the
+	// maintenance message an admin wrote for clients.
+	if status >= 500 && code != CodeMaintenance {
+		slog.Error("internal error", "error", msg, "path", c.Path())
+		msg = "internal server error"
+	}
+	return c.Status(status).JSON(ErrorResponse{Error: msg, Code: code})
 }
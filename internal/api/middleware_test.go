@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// observerAuth wraps the noop provider so that requests authenticate as an
+// observer of the default organization.
+type observerAuth struct {
+	auth.AuthProvider
+}
+
+func (a observerAuth) ValidateToken(ctx context.Context, token string) (*auth.Claims, error) {
+	claims, err := a.AuthProvider.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	observer := *claims
+	observer.Role = models.UserRoleObserver
+	return &observer, nil
+}
+
+func TestObserverGuard(t *testing.T) {
+	admin, _ := setupTestServer(t)
+	rec := doRequest(admin, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "watched-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create team: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	srv := NewServer(admin.db, &mockRuntime{}, observerAuth{admin.authProvider})
+
+	for _, path := range []string{
+		"/api/teams",
+		"/api/teams/" + team.ID,
+		"/api/teams/" + team.ID + "/messages",
+		"/api/teams/" + team.ID + "/activity",
+	} {
+		if rec := doRequest(srv, "GET", path, nil); rec.Code != 200 {
+			t.Errorf("GET %s: got %d, want 200, body: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	for _, req := range []struct{ method, path string }{
+		{"POST", "/api/teams/" + team.ID + "/chat"},
+		{"POST", "/api/teams/" + team.ID + "/deploy"},
+		{"POST", "/api/teams/" + team.ID + "/stop"},
+		{"PUT", "/api/teams/" + team.ID},
+		{"DELETE", "/api/teams/" + team.ID},
+		{"POST", "/api/teams"},
+		{"POST", "/api/schedules"},
+	} {
+		rec := doRequest(srv, req.method, req.path, map[string]string{"message": "hello"})
+		if rec.Code != 403 {
+			t.Errorf("%s %s: got %d, want 403", req.method, req.path, rec.Code)
+		}
+	}
+
+	// Observers can still edit their own profile.
+	if rec := doRequest(srv, "PUT", "/api/auth/me", map[string]string{"name": "Watcher"}); rec.Code == 403 {
+		t.Errorf("PUT /api/auth/me: got 403, body: %s", rec.Body.String())
+	}
+}
+
+func TestUpdateMemberRole_Observer(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var owner models.User
+	if err := srv.db.Where("is_owner = ?", true).First(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	member := models.User{ID: "member-1", OrgID: owner.OrgID, Email: "member@example.com", Name: "Member", Role: models.UserRoleMember}
+	if err := srv.db.Create(&member).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(srv, "PUT", "/api/org/members/"+member.ID+"/role", UpdateMemberRoleRequest{Role: models.UserRoleObserver})
+	if rec.Code != 200 {
+		t.Fatalf("make observer: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	srv.db.First(&member, "id = ?", member.ID)
+	if member.Role != models.UserRoleObserver {
+		t.Errorf("role: got %q, want observer", member.Role)
+	}
+
+	rec = doRequest(srv, "PUT", "/api/org/members/"+owner.ID+"/role", UpdateMemberRoleRequest{Role: models.UserRoleObserver})
+	if rec.Code != 403 {
+		t.Errorf("owner to observer: got %d, want 403", rec.Code)
+	}
+	rec = doRequest(srv, "PUT", "/api/org/members/"+member.ID+"/role", UpdateMemberRoleRequest{Role: "viewer"})
+	if rec.Code != 400 {
+		t.Errorf("unknown role: got %d, want 400", rec.Code)
+	}
+}
+
+// tokenAuth wraps the noop provider as a token-based provider whose tokens
+// carry the admin role, as a token issued before a role change would.
+type tokenAuth struct {
+	auth.AuthProvider
+}
+
+func (a tokenAuth) ProviderName() string { return "local" }
+
+func TestAuthMiddleware_RoleFromDatabase(t *testing.T) {
+	admin, _ := setupTestServer(t)
+	var owner models.User
+	if err := admin.db.Where("is_owner = ?", true).First(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(admin.db, &mockRuntime{}, tokenAuth{admin.authProvider})
+	request := func(method, path string) int {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"name":"role-team"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := srv.App.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	admin.db.Model(&owner).Update("role", models.UserRoleObserver)
+	if code := request("POST", "/api/teams"); code != 403 {
+		t.Errorf("demoted user: got %d, want 403", code)
+	}
+	if code := request("GET", "/api/teams"); code != 200 {
+		t.Errorf("demoted user reading: got %d, want 200", code)
+	}
+
+	admin.db.Delete(&owner)
+	if code := request("GET", "/api/teams"); code != 401 {
+		t.Errorf("deleted user: got %d, want 401", code)
+	}
+}
+
+func TestGetMcpConfig_ObserverMasksSecrets(t *testing.T) {
+	admin, _ := setupTestServer(t)
+	servers := `[{"name":"github","transport":"stdio","command":"gh-mcp","env":{"GITHUB_TOKEN":"ghp_secret"}},` +
+		`{"name":"docs","transport":"http","url":"https://docs.example.com","headers":{"Authorization":"Bearer secret"}}]`
+	team := models.Team{ID: "mcp-team", OrgID: "00000000-0000-0000-0000-000000000000", Name: "mcp-team", McpServers: models.JSON(servers)}
+	admin.db.Create(&team)
+
+	rec := doRequest(admin, "GET", "/api/teams/"+team.ID+"/mcp", nil)
+	var cfg McpConfigResponse
+	parseJSON(t, rec, &cfg)
+	if !strings.Contains(cfg.Content, "ghp_secret") {
+		t.Errorf("admin content: got %s", cfg.Content)
+	}
+
+	srv := NewServer(admin.db, &mockRuntime{}, observerAuth{admin.authProvider})
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/mcp", nil)
+	if rec.Code != 200 {
+		t.Fatalf("observer: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &cfg)
+	if strings.Contains(cfg.Content, "secret") {
+		t.Errorf("observer content leaks secrets: %s", cfg.Content)
+	}
+	if !strings.Contains(cfg.Content, "GITHUB_TOKEN") || !strings.Contains(cfg.Content, "gh-mcp") {
+		t.Errorf("observer content: got %s", cfg.Content)
+	}
+}
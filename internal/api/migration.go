@@ -0,0 +1,366 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// maxMigrationWorkspaceBytes caps the workspace a migration copies, since
+// the snapshot is held in memory on its way to the target runtime.
+const maxMigrationWorkspaceBytes = 512 << 20
+
+// migrationArchive is where the workspace snapshot is written in the
+// source leader and copied to in the target one.
+const migrationArchive = "/tmp/agentcrew-migration.tgz"
+
+// migratePayload is the payload of a jobTeamMigrate job.
+type migratePayload struct {
+	Source      string `json:"source"`
+	Target      string `json:"target"`
+	RequestedBy string `json:"requested_by,omitempty"`
+}
+
+// MigrationReport is the compatibility report of a team migration. The
+// team can be migrated unless a check is in error.
+type MigrationReport struct {
+	TeamID     string                     `json:"team_id"`
+	Source     string                     `json:"source"`
+	Target     string                     `json:"target"`
+	DryRun     bool                       `json:"dry_run"`
+	Compatible bool                       `json:"compatible"`
+	Checks     []protocol.ValidationCheck `json:"checks"`
+	JobID      string                     `json:"job_id,omitempty"`
+}
+
+// MigrateTeam handles POST /api/teams/:id/migrate?target=<runtime>. It moves
+// a running team to another runtime: the team is stopped, its workspace
+// snapshot copied, and it is deployed on the target, in a background job.
+// With dry_run=true, only the compatibility report is returned; an
+// incompatible team is refused with the report and a 409.
+func (s *Server) MigrateTeam(c *fiber.Ctx) error {
+	m, ok := s.runtime.(*runtime.MultiRuntime)
+	if !ok {
+		return newAPIError(CodeNotImplemented, "only one runtime is configured; list the others in EXTRA_RUNTIMES")
+	}
+	target := c.Query("target")
+	if target == "" {
+		return newAPIError(CodeBadRequest, "target is required")
+	}
+	if !m.Has(target) {
+		return newAPIError(CodeBadRequest, fmt.Sprintf("target must be one of %s", strings.Join(m.Names(), ", ")))
+	}
+
+	team, op, err := s.lockTeam(c, teamOpMigrate)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(team.ID, op)
+
+	source := migrationSource(m, team)
+	if source == target {
+		return newAPIError(CodeBadRequest, "team already runs on "+target)
+	}
+
+	ctx, cancel := context.WithTimeout(op.ctx, time.Minute)
+	defer cancel()
+	report := MigrationReport{
+		TeamID: team.ID,
+		Source: source,
+		Target: target,
+		DryRun: c.QueryBool("dry_run"),
+		Checks: s.migrationChecks(ctx, m, team, target),
+	}
+	report.Compatible = true
+	for _, check := range report.Checks {
+		if check.Status == protocol.ValidationError {
+			report.Compatible = false
+		}
+	}
+	if report.DryRun {
+		return c.JSON(report)
+	}
+	if !report.Compatible {
+		return c.Status(fiber.StatusConflict).JSON(report)
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	// Members' migrations of a team that requires approval wait for an
+	// admin, like its deploys.
+	if team.RequiresDeployApproval && !IsAdmin(c) {
+		return s.requestApproval(c, team, models.ApprovalActionMigrate, migrateApprovalParams{Target: target})
+	}
+
+	job, err := s.startMigration(&team, source, target, GetUserID(c))
+	if err != nil {
+		return err
+	}
+	report.JobID = job.ID
+	return c.Status(fiber.StatusAccepted).JSON(report)
+}
+
+// migrationSource returns the runtime team runs on: its own, or the
+// default runtime for a team whose runtime is not configured.
+func migrationSource(m *runtime.MultiRuntime, team models.Team) string {
+	if m.Has(team.Runtime) {
+		return team.Runtime
+	}
+	return m.DefaultName()
+}
+
+// startMigration sets team to deploying and queues its migration from
+// source to target. The caller must hold the team. source is recorded as
+// the team's runtime, which a team created before its runtime was recorded
+// leaves empty, so that the job finds the team where it was resolved to run.
+func (s *Server) startMigration(team *models.Team, source, target, requestedBy string) (*models.Job, error) {
+	// Like a deploy, the migration starts a new conversation: the leader on
+	// the target has no prior session.
+	conversationID := uuid.New().String()
+	s.db.Model(team).Updates(map[string]interface{}{
+		"runtime":         source,
+		"status":          models.TeamStatusDeploying,
+		"status_message":  "Migrating to " + target + "...",
+		"conversation_id": conversationID,
+		"chat_sequence":   0,
+	})
+	team.Runtime = source
+	team.Status = models.TeamStatusDeploying
+	team.ConversationID = conversationID
+
+	job, err := s.enqueueTeamJob(jobTeamMigrate, *team, migratePayload{Source: source, Target: target, RequestedBy: requestedBy})
+	if err != nil {
+		slog.Error("failed to queue migration", "team", team.Name, "error", err)
+		s.db.Model(team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusRunning,
+			"status_message": "Failed to queue migration",
+		})
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to queue migration")
+	}
+	return job, nil
+}
+
+// migrationChecks reports whether team can move to the target runtime. The
+// team must be loaded with its agents.
+func (s *Server) migrationChecks(ctx context.Context, m *runtime.MultiRuntime, team models.Team, target string) []protocol.ValidationCheck {
+	var checks []protocol.ValidationCheck
+	add := func(name string, status protocol.ValidationCheckStatus, msg string) {
+		checks = append(checks, protocol.ValidationCheck{Name: name, Status: status, Message: msg})
+	}
+
+	leader, hasLeader := teamLeader(team)
+	running := team.Status == models.TeamStatusRunning && hasLeader && leader.ContainerID != ""
+	if running {
+		add("status", protocol.ValidationOK, "team is running")
+	} else {
+		add("status", protocol.ValidationError, "team is not running; deploy it first so its workspace can be copied")
+	}
+
+	switch {
+	case team.WorkspacePath != "":
+		add("workspace", protocol.ValidationWarning, fmt.Sprintf(
+			"the workspace is the host directory %s, which is not copied; the target must mount the same path", team.WorkspacePath))
+	case running:
+		size, err := s.workspaceSize(ctx, leader.ContainerID)
+		switch {
+		case err != nil:
+			add("workspace", protocol.ValidationError, "failed to measure the workspace: "+err.Error())
+		case size > maxMigrationWorkspaceBytes:
+			add("workspace", protocol.ValidationError, fmt.Sprintf(
+				"the workspace is %d MiB, over the %d MiB a migration copies", size>>20, maxMigrationWorkspaceBytes>>20))
+		default:
+			add("workspace", protocol.ValidationOK, fmt.Sprintf("the workspace (%d MiB) is copied", size>>20))
+		}
+	}
+	if len(teamExtraWorkspaces(team)) > 0 {
+		add("extra_workspaces", protocol.ValidationWarning, "extra workspaces are host directories, which are not copied")
+	}
+
+	// Shared services run on the default runtime only, and are reached over
+	// the team's network there.
+	onShared := target == m.DefaultName()
+	_, ollama := s.sharedRuntime().(runtime.OllamaManager)
+	if team.ModelProvider == models.ModelProviderOllama && ollama && !onShared {
+		add("ollama", protocol.ValidationError, "Ollama only runs on the "+m.DefaultName()+" runtime")
+	}
+	var docs int64
+	s.db.Model(&models.Document{}).Where("org_id = ? AND status = ?", team.OrgID, models.DocStatusReady).Count(&docs)
+	_, qdrant := s.sharedRuntime().(runtime.QdrantManager)
+	if (team.KnowledgeBase || docs > 0) && qdrant && !onShared {
+		add("knowledge_base", protocol.ValidationError, "the knowledge base only runs on the "+m.DefaultName()+" runtime")
+	}
+
+	if len(team.NamespaceConfig) > 0 && target != runtime.NameKubernetes {
+		add("namespace_config", protocol.ValidationWarning, "the namespace configuration only applies on Kubernetes and is ignored")
+	}
+	if len(team.HostSelector) > 0 && target != runtime.NameDocker {
+		add("host_selector", protocol.ValidationWarning, "the host selector only applies on Docker and is ignored")
+	}
+	add("conversation", protocol.ValidationWarning, "the leader restarts on the target with a new conversation")
+	return checks
+}
+
+// workspaceSize returns the size in bytes of the workspace of the agent.
+func (s *Server) workspaceSize(ctx context.Context, containerID string) (int64, error) {
+	out, err := s.runtime.ExecInContainer(ctx, containerID, []string{"sh", "-c", "du -sk /workspace 2>/dev/null | cut -f1"})
+	if err != nil {
+		return 0, err
+	}
+	kb, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected du output %q", strings.TrimSpace(out))
+	}
+	return kb << 10, nil
+}
+
+// runMigrateJob moves the job's team to the target runtime, unless it was
+// stopped since the migration was queued. If the team cannot be deployed
+// on the target, it is moved back to the source, stopped, with its
+// workspace kept there.
+func (s *Server) runMigrateJob(ctx context.Context, job *models.Job) error {
+	var p migratePayload
+	if err := jobs.Decode(job, &p); err != nil {
+		return err
+	}
+	op, end, err := s.beginTeamJob(ctx, job, teamOpMigrate, func(msg string) {
+		s.db.Model(&models.Team{}).Where("id = ? AND status = ?", job.TeamID, models.TeamStatusDeploying).
+			Updates(map[string]interface{}{
+				"status":         models.TeamStatusRunning,
+				"status_message": "Migration could not start: " + msg,
+			})
+	})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", job.TeamID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	if team.Status != models.TeamStatusDeploying {
+		return jobs.Skip("team is no longer migrating")
+	}
+	if team.Runtime != p.Source {
+		// Do not leave the team deploying for a migration that never ran.
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         models.TeamStatusRunning,
+			"status_message": "Migration skipped: the team no longer runs on " + p.Source,
+		})
+		return jobs.Skip("team no longer runs on " + p.Source)
+	}
+	fail := func(status, msg string) error {
+		s.db.Model(&team).Updates(map[string]interface{}{
+			"status":         status,
+			"status_message": msg,
+		})
+		return jobs.Permanent(errors.New(msg))
+	}
+
+	// A host directory workspace is not copied.
+	var snapshot []byte
+	if leader, ok := teamLeader(team); ok && leader.ContainerID != "" && team.WorkspacePath == "" {
+		snapshot, err = s.snapshotWorkspace(op.ctx, leader.ContainerID)
+		if err != nil {
+			slog.Error("failed to snapshot workspace", "team", team.Name, "error", err)
+			return fail(models.TeamStatusRunning, fmt.Sprintf("Migration to %s failed: %v", p.Target, err))
+		}
+	}
+
+	stopCtx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
+	s.stopTeam(stopCtx, &team, true)
+	cancel()
+
+	s.db.Model(&team).Updates(map[string]interface{}{
+		"runtime":        p.Target,
+		"docker_host":    "",
+		"status":         models.TeamStatusDeploying,
+		"status_message": "Deploying on " + p.Target + "...",
+	})
+	team.Runtime = p.Target
+	team.DockerHost = ""
+	s.deployTeamAsync(withDeploymentTrigger(op.ctx, deploymentTrigger{Trigger: models.DeploymentTriggerMigration, TriggeredBy: p.RequestedBy}), team)
+
+	if err := s.db.Preload("Agents").First(&team, "id = ?", team.ID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	if team.Status != models.TeamStatusRunning {
+		msg := team.StatusMessage
+		slog.Error("migration failed, moving the team back", "team", team.Name, "target", p.Target, "error", msg)
+		teardownCtx, cancel := context.WithTimeout(op.ctx, 2*time.Minute)
+		s.stopTeam(teardownCtx, &team, false)
+		cancel()
+		s.db.Model(&team).Update("runtime", p.Source)
+		return fail(models.TeamStatusStopped, fmt.Sprintf(
+			"Migration to %s failed: %s. The team was moved back to %s, stopped, with its workspace kept", p.Target, msg, p.Source))
+	}
+
+	if snapshot != nil {
+		leader, _ := teamLeader(team)
+		if err := s.restoreWorkspace(op.ctx, leader.ContainerID, snapshot); err != nil {
+			slog.Error("failed to restore workspace", "team", team.Name, "error", err)
+			s.db.Model(&team).Update("status_message", fmt.Sprintf(
+				"Migrated to %s, but the workspace could not be copied: %v. It was kept on %s", p.Target, err, p.Source))
+			return nil
+		}
+		// The workspace now lives on the target; the copy on the source
+		// would only be found again by migrating back.
+		if m, ok := s.runtime.(*runtime.MultiRuntime); ok {
+			if src := m.Runtime(p.Source); src != nil {
+				if err := src.DeleteWorkspace(op.ctx, team.Name); err != nil {
+					slog.Warn("failed to delete the workspace on the source runtime", "team", team.Name, "runtime", p.Source, "error", err)
+				}
+			}
+		}
+	}
+	slog.Info("team migrated", "team", team.Name, "source", p.Source, "target", p.Target)
+	return nil
+}
+
+// snapshotWorkspace returns a gzipped tar archive of the agent's workspace.
+func (s *Server) snapshotWorkspace(ctx context.Context, containerID string) ([]byte, error) {
+	// tar exits with 1 when files changed while being read, which a live
+	// workspace allows. The archive is base64-encoded since exec output is
+	// text.
+	cmd := fmt.Sprintf("tar -C /workspace -czf %[1]s . 2>/dev/null; rc=$?; "+
+		"if [ $rc -le 1 ]; then base64 -w0 %[1]s; rc=$?; fi; rm -f %[1]s; exit $rc", migrationArchive)
+	out, err := s.runtime.ExecInContainer(ctx, containerID, []string{"sh", "-c", cmd})
+	if err != nil {
+		return nil, fmt.Errorf("archiving the workspace: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(out))
+	if err != nil {
+		return nil, fmt.Errorf("reading the workspace archive: %w", err)
+	}
+	return data, nil
+}
+
+// restoreWorkspace extracts a snapshotWorkspace archive into the agent's
+// workspace. Files the deployment just generated are kept, and the
+// extracted ones are owned by the workspace owner.
+func (s *Server) restoreWorkspace(ctx context.Context, containerID string, snapshot []byte) error {
+	if err := s.runtime.CopyToContainer(ctx, containerID, migrationArchive, snapshot); err != nil {
+		return fmt.Errorf("copying the workspace archive: %w", err)
+	}
+	cmd := fmt.Sprintf("tar -C /workspace --skip-old-files -xzf %[1]s; rc=$?; rm -f %[1]s; [ $rc -eq 0 ] || exit $rc; "+
+		"if [ -n \"$WORKSPACE_UID\" ]; then owner=\"$WORKSPACE_UID:${WORKSPACE_GID:-$WORKSPACE_UID}\"; else owner=$(stat -c '%%u:%%g' /workspace); fi && chown -R \"$owner\" /workspace",
+		migrationArchive)
+	if _, err := s.runtime.ExecInContainer(ctx, containerID, []string{"sh", "-c", cmd}); err != nil {
+		return fmt.Errorf("extracting the workspace archive: %w", err)
+	}
+	return nil
+}
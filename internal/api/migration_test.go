@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// setupMultiRuntimeServer creates a test server running teams on docker,
+// by default, or kubernetes.
+func setupMultiRuntimeServer(t *testing.T) (*Server, *mockRuntime, *mockRuntime) {
+	t.Helper()
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	docker, k8s := &mockRuntime{}, &mockRuntime{}
+	m, err := runtime.NewMultiRuntime(runtime.NameDocker, map[string]runtime.AgentRuntime{
+		runtime.NameDocker:     docker,
+		runtime.NameKubernetes: k8s,
+	})
+	if err != nil {
+		t.Fatalf("NewMultiRuntime: %v", err)
+	}
+	noopAuth, err := auth.NewNoopProvider(db)
+	if err != nil {
+		t.Fatalf("NewNoopProvider: %v", err)
+	}
+	srv := NewServer(db, m, noopAuth)
+	srv.StartJobs(0)
+	t.Cleanup(srv.StopJobs)
+	return srv, docker, k8s
+}
+
+func TestMigrateTeam(t *testing.T) {
+	srv, docker, k8s := setupMultiRuntimeServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "movers",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.Runtime != runtime.NameDocker {
+		t.Fatalf("runtime: got %q, want docker", team.Runtime)
+	}
+	if rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "nowhere", Runtime: "nomad"}); rec.Code != 400 {
+		t.Errorf("unknown runtime: got %d, want 400", rec.Code)
+	}
+
+	var report MigrationReport
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes&dry_run=true", nil)
+	parseJSON(t, rec, &report)
+	if rec.Code != 200 || report.Compatible {
+		t.Fatalf("stopped team dry run: got %d %+v, want an incompatible report", rec.Code, report)
+	}
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil); rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+
+	srv.db.Preload("Agents").First(&team, "id = ?", team.ID)
+	srv.deployTeamAsync(t.Context(), team)
+
+	// "1234" is both the workspace size in KiB and a valid base64 archive.
+	docker.execOutput = "1234"
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes&dry_run=true", nil)
+	report = MigrationReport{}
+	parseJSON(t, rec, &report)
+	if rec.Code != 200 || !report.Compatible || report.Source != "docker" || report.Target != "kubernetes" {
+		t.Fatalf("dry run: got %d %+v", rec.Code, report)
+	}
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=docker", nil); rec.Code != 400 {
+		t.Errorf("same runtime: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil)
+	report = MigrationReport{}
+	parseJSON(t, rec, &report)
+	if rec.Code != 202 || report.JobID == "" {
+		t.Fatalf("migrate: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobTeamMigrate, models.JobStatusSucceeded)
+
+	srv.db.Preload("Agents").First(&team, "id = ?", team.ID)
+	if team.Runtime != runtime.NameKubernetes || team.Status != models.TeamStatusRunning {
+		t.Fatalf("migrated team: runtime %q, status %q (%s)", team.Runtime, team.Status, team.StatusMessage)
+	}
+	if leader, _ := teamLeader(team); !strings.HasPrefix(leader.ContainerID, "kubernetes:") {
+		t.Errorf("leader container: got %q, want it on kubernetes", leader.ContainerID)
+	}
+	if !docker.teardownOpts.PreserveWorkspace || len(docker.tornDown) == 0 {
+		t.Errorf("source teardown: got %v %+v", docker.tornDown, docker.teardownOpts)
+	}
+	want, _ := base64.StdEncoding.DecodeString("1234")
+	if got := k8s.copiedFiles[migrationArchive]; !bytes.Equal(got, want) {
+		t.Errorf("restored archive: got %v, want %v", got, want)
+	}
+	if len(docker.deletedWorkspaces) != 1 || len(k8s.deletedWorkspaces) != 0 {
+		t.Errorf("deleted workspaces: docker %v, kubernetes %v", docker.deletedWorkspaces, k8s.deletedWorkspaces)
+	}
+}
+
+func TestMigrateTeam_MovesBackOnFailure(t *testing.T) {
+	srv, docker, k8s := setupMultiRuntimeServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "stuck",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	srv.db.Preload("Agents").First(&team, "id = ?", team.ID)
+	srv.deployTeamAsync(t.Context(), team)
+
+	docker.execOutput = "1234"
+	k8s.deployInfraErr = errors.New("cluster unreachable")
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil)
+	if rec.Code != 202 {
+		t.Fatalf("migrate: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobTeamMigrate, models.JobStatusFailed)
+
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.Runtime != runtime.NameDocker || team.Status != models.TeamStatusStopped ||
+		!strings.Contains(team.StatusMessage, "moved back to docker") {
+		t.Errorf("team: runtime %q, status %q (%s)", team.Runtime, team.Status, team.StatusMessage)
+	}
+	if len(docker.deletedWorkspaces) != 0 {
+		t.Errorf("source workspace deleted: %v", docker.deletedWorkspaces)
+	}
+}
+
+func TestMigrateTeam_WithoutRecordedRuntime(t *testing.T) {
+	srv, docker, _ := setupMultiRuntimeServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "legacy",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	// Teams created before runtimes were recorded run on the default one.
+	srv.db.Model(&team).Update("runtime", "")
+	srv.db.Preload("Agents").First(&team, "id = ?", team.ID)
+	srv.deployTeamAsync(t.Context(), team)
+
+	docker.execOutput = "1234"
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil)
+	if rec.Code != 202 {
+		t.Fatalf("migrate: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobTeamMigrate, models.JobStatusSucceeded)
+
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.Runtime != runtime.NameKubernetes || team.Status != models.TeamStatusRunning {
+		t.Errorf("team: runtime %q, status %q (%s)", team.Runtime, team.Status, team.StatusMessage)
+	}
+}
+
+func TestMigrateTeam_SingleRuntime(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "alone"})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil); rec.Code != 501 {
+		t.Errorf("got %d, want 501", rec.Code)
+	}
+}
+
+func TestMigrateTeam_RequiresApproval(t *testing.T) {
+	srv, docker, _ := setupMultiRuntimeServer(t)
+	member := NewServer(srv.db, srv.runtime, memberAuth{srv.authProvider})
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:                   "guarded-movers",
+		RequiresDeployApproval: true,
+		Agents:                 []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	srv.deployTeamAsync(t.Context(), team)
+	docker.execOutput = "1234"
+
+	rec = doRequest(member, "POST", "/api/teams/"+team.ID+"/migrate?target=kubernetes", nil)
+	if rec.Code != 202 {
+		t.Fatalf("member migrate: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var approval models.Approval
+	parseJSON(t, rec, &approval)
+	if approval.Action != models.ApprovalActionMigrate || approval.Status != models.ApprovalStatusPending {
+		t.Fatalf("approval: got %+v", approval)
+	}
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.Status != models.TeamStatusRunning || team.Runtime != runtime.NameDocker {
+		t.Errorf("team before approval: status %q, runtime %q", team.Status, team.Runtime)
+	}
+
+	rec = doRequest(srv, "POST", "/api/approvals/"+approval.ID+"/approve", nil)
+	if rec.Code != 200 {
+		t.Fatalf("approve: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobTeamMigrate, models.JobStatusSucceeded)
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.Runtime != runtime.NameKubernetes || team.Status != models.TeamStatusRunning {
+		t.Errorf("migrated team: runtime %q, status %q (%s)", team.Runtime, team.Status, team.StatusMessage)
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// natsStreamTimeout bounds a stream operation on a team's NATS server,
+// connecting included.
+const natsStreamTimeout = 15 * time.Second
+
+// teamStreams manages the JetStream streams of a team's NATS server. It is
+// implemented by *agentNats.Client.
+type teamStreams interface {
+	Streams(ctx context.Context) ([]agentNats.StreamState, error)
+	StreamState(ctx context.Context, name string) (agentNats.StreamState, error)
+	PurgeStream(ctx context.Context, name string) (agentNats.StreamState, error)
+	EnsureStream(ctx context.Context, teamName string, limits agentNats.StreamLimits) error
+	Close()
+}
+
+// connectTeamStreams connects to the NATS server of the team with the
+// given slug.
+func (s *Server) connectTeamStreams(ctx context.Context, teamSlug string) (teamStreams, error) {
+	natsURL, err := s.runtime.GetNATSConnectURL(ctx, teamSlug)
+	if err != nil {
+		return nil, fmt.Errorf("resolving NATS URL: %w", err)
+	}
+	cfg := agentNats.DefaultConfig(natsURL, "agentcrew-api")
+	cfg.Token = os.Getenv("NATS_AUTH_TOKEN")
+	return agentNats.Connect(cfg)
+}
+
+// ensureTeamStream creates the team's JetStream stream, or updates its
+// limits, before the sidecars start. The leader's sidecar creates the
+// stream as well, so a failure is only reported.
+func (s *Server) ensureTeamStream(ctx context.Context, team models.Team, limits agentNats.StreamLimits) {
+	ctx, cancel := context.WithTimeout(ctx, natsStreamTimeout)
+	defer cancel()
+	slug := naming.Slug(team.Name)
+	streams, err := s.openTeamStreams(ctx, slug)
+	if err == nil {
+		defer streams.Close()
+		err = streams.EnsureStream(ctx, slug, limits)
+	}
+	if err != nil {
+		slog.Warn("failed to configure team stream", "team", team.Name, "error", err)
+		runtime.ReportProgress(ctx, "Could not configure the message stream, the leader will create it: %v", err)
+		return
+	}
+	runtime.ReportProgress(ctx, "Message stream configured")
+}
+
+// withTeamStreams looks up the running team of the request, connects to
+// its NATS server and calls fn.
+func (s *Server) withTeamStreams(c *fiber.Ctx, fn func(ctx context.Context, team models.Team, streams teamStreams) error) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), natsStreamTimeout)
+	defer cancel()
+	streams, err := s.openTeamStreams(ctx, naming.Slug(team.Name))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadGateway, "connecting to team NATS: "+err.Error())
+	}
+	defer streams.Close()
+	return fn(ctx, team, streams)
+}
+
+// ListTeamStreams handles GET /api/teams/:id/nats/streams. It returns the
+// JetStream streams of the team's NATS server with their messages, size,
+// limits and consumers.
+func (s *Server) ListTeamStreams(c *fiber.Ctx) error {
+	return s.withTeamStreams(c, func(ctx context.Context, _ models.Team, streams teamStreams) error {
+		states, err := streams.Streams(ctx)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, "listing streams: "+err.Error())
+		}
+		return c.JSON(fiber.Map{"streams": states})
+	})
+}
+
+// PurgeTeamStream handles POST /api/teams/:id/nats/streams/:stream/purge.
+// It removes every message of the stream; consumers continue from the next
+// message published.
+func (s *Server) PurgeTeamStream(c *fiber.Ctx) error {
+	return s.withTeamStreams(c, func(ctx context.Context, team models.Team, streams teamStreams) error {
+		name := c.Params("stream")
+		state, err := streams.PurgeStream(ctx, name)
+		if errors.Is(err, agentNats.ErrStreamNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "stream not found")
+		}
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, "purging stream: "+err.Error())
+		}
+		slog.Info("team stream purged", "team", team.Name, "stream", name)
+		return c.JSON(state)
+	})
+}
+
+// RepairTeamStream handles POST /api/teams/:id/nats/streams/repair. It
+// creates the team's stream if it is missing and applies the stream limits
+// of the organization's settings, then returns the stream.
+func (s *Server) RepairTeamStream(c *fiber.Ctx) error {
+	return s.withTeamStreams(c, func(ctx context.Context, team models.Team, streams teamStreams) error {
+		limits, err := agentNats.StreamLimitsFromEnv(s.LoadSettingsEnv(team.OrgID))
+		if err != nil {
+			return newAPIError(CodeBadRequest, "invalid stream settings: "+err.Error())
+		}
+		slug := naming.Slug(team.Name)
+		if err := streams.EnsureStream(ctx, slug, limits); err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, err.Error())
+		}
+		state, err := streams.StreamState(ctx, "TEAM_"+slug)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadGateway, "reading stream: "+err.Error())
+		}
+		slog.Info("team stream repaired", "team", team.Name, "stream", state.Name)
+		return c.JSON(state)
+	})
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+)
+
+// fakeStreams is a teamStreams holding streams in memory.
+type fakeStreams struct {
+	streams map[string]*agentNats.StreamState
+	ensured []agentNats.StreamLimits
+	closed  bool
+}
+
+func (f *fakeStreams) Streams(context.Context) ([]agentNats.StreamState, error) {
+	var states []agentNats.StreamState
+	for _, s := range f.streams {
+		states = append(states, *s)
+	}
+	return states, nil
+}
+
+func (f *fakeStreams) StreamState(_ context.Context, name string) (agentNats.StreamState, error) {
+	s, ok := f.streams[name]
+	if !ok {
+		return agentNats.StreamState{}, fmt.Errorf("finding stream %s: %w", name, agentNats.ErrStreamNotFound)
+	}
+	return *s, nil
+}
+
+func (f *fakeStreams) PurgeStream(ctx context.Context, name string) (agentNats.StreamState, error) {
+	if s, ok := f.streams[name]; ok {
+		s.Messages, s.Bytes, s.FirstSeq = 0, 0, s.LastSeq+1
+	}
+	return f.StreamState(ctx, name)
+}
+
+func (f *fakeStreams) EnsureStream(_ context.Context, teamName string, limits agentNats.StreamLimits) error {
+	f.ensured = append(f.ensured, limits)
+	name := "TEAM_" + teamName
+	if _, ok := f.streams[name]; !ok {
+		f.streams[name] = &agentNats.StreamState{Name: name, Subjects: []string{"team." + teamName + ".>"}}
+	}
+	f.streams[name].MaxBytes = limits.MaxBytes
+	return nil
+}
+
+func (f *fakeStreams) Close() { f.closed = true }
+
+func TestTeamStreams(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	fake := &fakeStreams{streams: map[string]*agentNats.StreamState{
+		"TEAM_stream-team": {Name: "TEAM_stream-team", Messages: 12, Bytes: 3400, FirstSeq: 1, LastSeq: 12,
+			Consumers: []agentNats.ConsumerState{{Name: relayConsumer, Pending: 2}}},
+	}}
+	var slugs []string
+	srv.openTeamStreams = func(_ context.Context, slug string) (teamStreams, error) {
+		slugs = append(slugs, slug)
+		return fake, nil
+	}
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "Stream Team",
+		Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	base := "/api/teams/" + team.ID + "/nats/streams"
+
+	if rec := doRequest(srv, "GET", base, nil); rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+
+	rec := doRequest(srv, "GET", base, nil)
+	var list struct {
+		Streams []agentNats.StreamState `json:"streams"`
+	}
+	parseJSON(t, rec, &list)
+	if len(list.Streams) != 1 || list.Streams[0].Messages != 12 || list.Streams[0].Consumers[0].Pending != 2 {
+		t.Errorf("streams: got %+v", list.Streams)
+	}
+	if len(slugs) != 1 || slugs[0] != "stream-team" || !fake.closed {
+		t.Errorf("connection: got slugs %v, closed %v", slugs, fake.closed)
+	}
+
+	rec = doRequest(srv, "POST", base+"/TEAM_stream-team/purge", nil)
+	var purged agentNats.StreamState
+	parseJSON(t, rec, &purged)
+	if purged.Messages != 0 || purged.FirstSeq != 13 {
+		t.Errorf("purged: got %+v", purged)
+	}
+	if rec := doRequest(srv, "POST", base+"/TEAM_other/purge", nil); rec.Code != 404 {
+		t.Errorf("unknown stream: got %d, want 404", rec.Code)
+	}
+
+	doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: agentNats.SettingStreamMaxBytes, Value: "1MB"})
+	delete(fake.streams, "TEAM_stream-team")
+	rec = doRequest(srv, "POST", base+"/repair", nil)
+	var repaired agentNats.StreamState
+	parseJSON(t, rec, &repaired)
+	if repaired.Name != "TEAM_stream-team" || repaired.MaxBytes != 1<<20 {
+		t.Errorf("repaired: got %+v", repaired)
+	}
+
+	if rec := doRequest(srv, "GET", "/api/teams/missing/nats/streams", nil); rec.Code != 404 {
+		t.Errorf("unknown team: got %d, want 404", rec.Code)
+	}
+	srv.openTeamStreams = func(context.Context, string) (teamStreams, error) {
+		return nil, errors.New("connection refused")
+	}
+	if rec := doRequest(srv, "GET", base, nil); rec.Code != 502 {
+		t.Errorf("unreachable NATS: got %d, want 502", rec.Code)
+	}
+}
+
+func TestDeployTeam_ConfiguresStream(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	fake := &fakeStreams{streams: map[string]*agentNats.StreamState{}}
+	srv.openTeamStreams = func(context.Context, string) (teamStreams, error) { return fake, nil }
+	doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: agentNats.SettingStreamMaxAge, Value: "2h"})
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "deploy-stream-team",
+		Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.deployTeamAsync(t.Context(), team)
+
+	if len(fake.ensured) != 1 || fake.ensured[0].MaxAge != 2*time.Hour {
+		t.Fatalf("ensured: got %+v, want one stream with a 2h max age", fake.ensured)
+	}
+	body := doRequest(srv, "GET", "/api/teams/"+team.ID+"/deploy/logs", nil).Body.String()
+	if !strings.Contains(body, "Message stream configured") {
+		t.Errorf("deploy log lacks the stream line:\n%s", body)
+	}
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// relaySyncInterval is how often the owning replica reconciles its relays
+// with the teams that are running.
+var relaySyncInterval = 10 * time.Second
+
+// Several API replicas can serve requests against the same database, but
+// only one of them, the owner, relays team messages and runs the background
+// loops: two relays for a team would save each message twice. The replicas
+// elect the owner (see the election package) and hand ownership over with
+// AcquireOwnership and ReleaseOwnership. A server owns everything until
+// Standby is called, which is what a single replica needs.
+//
+// Requests are served by any replica. A team deployed or stopped through
+// another replica is picked up by the owner's relay sync. Activity
+// WebSockets poll the database, so they do not need to reach the owner; a
+// socket closed by a replica going away is reopened by the client on any
+// other replica and resumes from the newest message.
+//
+// The run queue positions are kept in memory, so each replica only knows
+// its own; the rate limiter is shared through the database. With relay
+// workers (see SetRelayWorkers), the workers relay the teams instead of the
+// owner, and the leader readiness and run queue positions they learn are
+// shared through the database.
+
+// Standby marks the server as not owning relays and background loops until
+// AcquireOwnership is called. Call it before serving requests on replicas
+// that take part in an election.
+func (s *Server) Standby() {
+	s.owner.Store(false)
+}
+
+// AcquireOwnership makes this replica the one that relays team messages and
+// runs the background loops. It first recovers work interrupted by a
+// restart or by the failure of the previous owner: deployments and upgrades
+// that no operation or job holds any more, and evaluation runs whose
+// replica no longer holds them, are marked as failed. Work that another
+// replica still runs is left alone.
+func (s *Server) AcquireOwnership() {
+	s.owner.Store(true)
+	slog.Info("acquired ownership of relays and background loops")
+
+	// Fail deployments interrupted by the restart, leaving those another
+	// replica or a job still runs, and remove infrastructure that no team
+	// owns.
+	s.ReconcileTeams()
+
+	// Reconnect NATS relays for teams that were running before this restart,
+	// unless relay workers relay them.
+	if !s.externalRelays {
+		s.ReconnectRelays()
+	}
+
+	// Free the slot of agent upgrades whose job is gone.
+	s.HaltInterruptedUpgrades()
+
+	// Evaluation runs do not survive the replica running them.
+	s.FailInterruptedEvaluationRuns()
+
+	// Retry relay messages that failed to persist.
+	s.StartDeadLetterRetrier()
+
+	// Periodically remove infrastructure that no team owns.
+	s.StartInfraGC()
+
+	// Move teams whose leader container changed outside the API out of the
+	// running status.
+	s.StartDriftReconciler()
+
+	// Pull the agent images in use before teams are deployed with them.
+	s.StartImagePrePull()
+
+	// Suspend teams left running outside their working hours.
+	s.StartWorkingHoursMonitor()
+
+	// End agent preview sessions that expired, whichever replica started
+	// them.
+	s.startPreviewExpiry()
+
+	// Start team health checks for alert integrations.
+	s.StartAlertMonitor()
+
+	// Follow teams deployed and stopped through other replicas.
+	if !s.externalRelays {
+		s.startRelaySync()
+	}
+}
+
+// ReleaseOwnership stops the relays and background loops started by
+// AcquireOwnership, so that another replica can take them over.
+func (s *Server) ReleaseOwnership() {
+	if !s.owner.Swap(false) {
+		return
+	}
+	s.stopRelaySync()
+	s.stopAllRelays()
+	s.stopDeadLetterRetrier()
+	s.stopInfraGC()
+	s.stopDriftReconciler()
+	s.stopImagePrePull()
+	s.stopWorkingHoursMonitor()
+	s.stopPreviewExpiry()
+	s.alertMonitor.Stop()
+	slog.Info("released ownership of relays and background loops")
+}
+
+// IsOwner reports whether this replica relays team messages and runs the
+// background loops.
+func (s *Server) IsOwner() bool {
+	return s.owner.Load()
+}
+
+// startRelaySync starts the loop that keeps the owner's relays in line with
+// the running teams.
+func (s *Server) startRelaySync() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.relaySyncCancel = cancel
+	s.relaySyncWg.Add(1)
+	go func() {
+		defer s.relaySyncWg.Done()
+		ticker := time.NewTicker(relaySyncInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.syncRelays()
+			}
+		}
+	}()
+}
+
+// stopRelaySync stops the relay sync loop, if running, and waits for it.
+func (s *Server) stopRelaySync() {
+	if s.relaySyncCancel != nil {
+		s.relaySyncCancel()
+	}
+	s.relaySyncWg.Wait()
+}
+
+// syncRelays starts relays for running teams that have none, such as teams
+// deployed through another replica, and stops the relays of teams that were
+// stopped or deleted. It also delivers chat messages another replica queued
+// for a team whose leader is ready, since only the owner learns that.
+func (s *Server) syncRelays() {
+	var teams []models.Team
+	if err := s.db.Select("id", "name", "status").Where("status != ?", models.TeamStatusStopped).Find(&teams).Error; err != nil {
+		slog.Error("relay sync: failed to query teams", "error", err)
+		return
+	}
+
+	active := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		active[team.ID] = true
+		if team.Status != models.TeamStatusRunning {
+			continue
+		}
+		s.relaysMu.Lock()
+		_, relayed := s.relays[team.ID]
+		ready := s.leaderReady[team.ID]
+		s.relaysMu.Unlock()
+
+		switch {
+		case !relayed:
+			slog.Info("relay sync: starting relay for running team", "team", team.Name, "id", team.ID)
+			s.startTeamRelay(team.ID, team.Name)
+		case ready && s.hasQueuedChats(team.ID):
+			go s.flushQueuedChats(team.ID, team.Name)
+		}
+	}
+
+	s.relaysMu.Lock()
+	var stale []string
+	for teamID := range s.relays {
+		if !active[teamID] {
+			stale = append(stale, teamID)
+		}
+	}
+	s.relaysMu.Unlock()
+	for _, teamID := range stale {
+		slog.Info("relay sync: stopping relay for stopped team", "id", teamID)
+		s.stopTeamRelay(teamID)
+	}
+}
+
+// stopAllRelays stops the relays of all teams.
+func (s *Server) stopAllRelays() {
+	s.relaysMu.Lock()
+	defer s.relaysMu.Unlock()
+	for teamID, cancel := range s.relays {
+		cancel()
+		delete(s.relays, teamID)
+	}
+	clear(s.leaderReady)
+	clear(s.runQueues)
+}
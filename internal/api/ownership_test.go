@@ -0,0 +1,108 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// fakeRelay registers a relay for teamID without connecting to NATS and
+// reports whether it was cancelled.
+func fakeRelay(srv *Server, teamID string) *bool {
+	cancelled := new(bool)
+	srv.relaysMu.Lock()
+	srv.relays[teamID] = func() { *cancelled = true }
+	srv.relaysMu.Unlock()
+	return cancelled
+}
+
+func hasRelay(srv *Server, teamID string) bool {
+	srv.relaysMu.Lock()
+	defer srv.relaysMu.Unlock()
+	_, ok := srv.relays[teamID]
+	return ok
+}
+
+func TestStartTeamRelay_SkippedOnStandby(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.Standby()
+
+	srv.startTeamRelay("t1", "platform")
+	if hasRelay(srv, "t1") {
+		t.Error("standby replica started a relay")
+	}
+	if srv.IsOwner() {
+		t.Error("IsOwner: got true on standby")
+	}
+}
+
+func TestSyncRelays_StopsRelaysOfStoppedTeams(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	stopped := models.Team{ID: "t-stopped", Name: "stopped", Status: models.TeamStatusStopped}
+	deploying := models.Team{ID: "t-deploying", Name: "deploying", Status: models.TeamStatusDeploying}
+	srv.db.Create(&stopped)
+	srv.db.Create(&deploying)
+
+	stoppedCancelled := fakeRelay(srv, stopped.ID)
+	deployingCancelled := fakeRelay(srv, deploying.ID)
+	deletedCancelled := fakeRelay(srv, "t-deleted")
+
+	srv.syncRelays()
+
+	if !*stoppedCancelled || hasRelay(srv, stopped.ID) {
+		t.Error("relay of stopped team was kept")
+	}
+	if !*deletedCancelled || hasRelay(srv, "t-deleted") {
+		t.Error("relay of deleted team was kept")
+	}
+	// A leader restart keeps the relay while the team is deploying.
+	if *deployingCancelled || !hasRelay(srv, deploying.ID) {
+		t.Error("relay of deploying team was stopped")
+	}
+}
+
+func TestSyncRelays_FlushesChatsQueuedOnOtherReplicas(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := models.Team{ID: "t1", Name: "platform", Status: models.TeamStatusRunning}
+	srv.db.Create(&team)
+	fakeRelay(srv, team.ID)
+	srv.relaysMu.Lock()
+	srv.leaderReady[team.ID] = true
+	srv.relaysMu.Unlock()
+
+	// Queued by another replica while the team was deploying. The payload
+	// is unreadable, so the flush marks it failed without publishing.
+	queued := models.TaskLog{
+		ID: "m1", TeamID: team.ID, FromAgent: "user", ToAgent: "leader",
+		MessageType: "user_message", Payload: models.JSON(`not json`),
+		DeliveryStatus: models.ChatDeliveryQueued,
+	}
+	srv.db.Create(&queued)
+
+	srv.syncRelays()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.hasQueuedChats(team.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("queued chat was not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReleaseOwnership_StopsRelays(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	cancelled := fakeRelay(srv, "t1")
+
+	srv.ReleaseOwnership()
+	if srv.IsOwner() {
+		t.Error("IsOwner: got true after ReleaseOwnership")
+	}
+	if !*cancelled || hasRelay(srv, "t1") {
+		t.Error("relay was not stopped")
+	}
+
+	// Releasing again does nothing.
+	srv.ReleaseOwnership()
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/segments"
+)
+
+var patchListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status"},
+}
+
+// patchClaimTTL bounds how long applying a patch may take. A patch left
+// applying for longer, by a process that died before recording the outcome,
+// can be applied or rejected again.
+const patchClaimTTL = 5 * time.Minute
+
+// patchDecidable selects patches that can still be applied or rejected:
+// proposed, failed, or claimed by an apply that outlived patchClaimTTL.
+func patchDecidable(now time.Time) (string, []interface{}) {
+	return "(status IN ? OR (status = ? AND decided_at < ?))", []interface{}{
+		[]string{models.PatchStatusProposed, models.PatchStatusFailed},
+		models.PatchStatusApplying, now.Add(-patchClaimTTL),
+	}
+}
+
+// isPatchDecidable reports whether p can still be applied or rejected.
+func isPatchDecidable(p models.ProposedPatch, now time.Time) bool {
+	switch p.Status {
+	case models.PatchStatusProposed, models.PatchStatusFailed:
+		return true
+	case models.PatchStatusApplying:
+		return p.DecidedAt != nil && p.DecidedAt.Before(now.Add(-patchClaimTTL))
+	}
+	return false
+}
+
+func patchKey(p models.ProposedPatch) (time.Time, string) { return p.CreatedAt, p.ID }
+
+// applyPatchScript applies the patch file given as $1 to the workspace and
+// removes it. git runs as the workspace owner, like the agent, so that the
+// files it writes stay editable and git trusts the repository.
+const applyPatchScript = `if [ -n "$WORKSPACE_UID" ]; then owner="$WORKSPACE_UID:${WORKSPACE_GID:-$WORKSPACE_UID}"; else owner=$(stat -c '%u:%g' /workspace); fi
+if [ "$(id -u)" = 0 ] && command -v gosu >/dev/null 2>&1; then
+	chown "$owner" "$1"
+	gosu "$owner" git -C /workspace apply --whitespace=nowarn "$1" 2>&1
+else
+	git -C /workspace apply --whitespace=nowarn "$1" 2>&1
+fi
+status=$?
+rm -f "$1"
+exit $status`
+
+// recordProposedPatch saves the diffs of a leader response as a patch
+// awaiting approval. Responses without a diff propose nothing.
+func (s *Server) recordProposedPatch(teamName string, log models.TaskLog) {
+	var (
+		diffs []string
+		files []string
+	)
+	for _, seg := range log.Segments {
+		if seg.Type != segments.TypeDiff {
+			continue
+		}
+		diffs = append(diffs, strings.TrimRight(seg.Content, "\n"))
+		if seg.Path != "" {
+			files = append(files, seg.Path)
+		}
+	}
+	if len(diffs) == 0 {
+		return
+	}
+	filesJSON, _ := json.Marshal(files)
+	patch := models.ProposedPatch{
+		ID:             uuid.New().String(),
+		TeamID:         log.TeamID,
+		MessageID:      log.MessageID,
+		TaskLogID:      log.ID,
+		ConversationID: log.ConversationID,
+		Files:          models.JSON(filesJSON),
+		// git apply needs the last line of the patch terminated.
+		Diff:   strings.Join(diffs, "\n") + "\n",
+		Status: models.PatchStatusProposed,
+	}
+	// A redelivered response must not propose the same patch twice.
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&patch).Error; err != nil {
+		slog.Error("relay: failed to save proposed patch", "team", teamName, "error", err)
+		return
+	}
+	slog.Info("relay: patch awaiting approval", "team", teamName, "files", len(files))
+}
+
+// ListPatches handles GET /api/teams/:id/patches. It returns the patches
+// the leader proposed, newest first.
+func (s *Server) ListPatches(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	q, err := parseListQuery(c, patchListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", team.ID), q, patchKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list patches")
+	}
+	return c.JSON(resp)
+}
+
+// GetPatch handles GET /api/teams/:id/patches/:patchId.
+func (s *Server) GetPatch(c *fiber.Ctx) error {
+	_, patch, err := s.findPatch(c)
+	if err != nil {
+		return err
+	}
+	return c.JSON(patch)
+}
+
+// ApplyPatch handles POST /api/teams/:id/patches/:patchId/apply. It applies
+// a proposed patch to the workspace in the leader's container with git
+// apply, which changes nothing unless every hunk applies. A patch that
+// failed to apply can be applied again, and so can one whose apply was
+// interrupted for longer than patchClaimTTL.
+func (s *Server) ApplyPatch(c *fiber.Ctx) error {
+	team, patch, err := s.findPatch(c)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	if !isPatchDecidable(patch, now) {
+		return newAPIError(CodePatchState, "patch is already "+patch.Status)
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	var leader models.Agent
+	if err := s.db.Where("team_id = ? AND role = ? AND container_status IN ?",
+		team.ID, models.AgentRoleLeader, models.ContainerUpStatuses).First(&leader).Error; err != nil {
+		return newAPIError(CodeNoLeader, "no running leader agent found for this team")
+	}
+
+	// Claim the patch first, so that concurrent approvals apply it once. The
+	// claim is stamped with decided_at and the apply is bounded by
+	// patchClaimTTL, so a claim is only taken over once its apply is over.
+	query, args := patchDecidable(now)
+	res := s.db.Model(&models.ProposedPatch{}).
+		Where("id = ?", patch.ID).Where(query, args...).
+		Updates(map[string]interface{}{
+			"status":     models.PatchStatusApplying,
+			"error":      "",
+			"decided_by": GetUserID(c),
+			"decided_at": now,
+		})
+	if res.Error != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to apply patch")
+	}
+	if res.RowsAffected == 0 {
+		return newAPIError(CodePatchState, "patch is already being applied")
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), patchClaimTTL)
+	defer cancel()
+	status, errMsg := models.PatchStatusApplied, ""
+	path := fmt.Sprintf("/tmp/agentcrew-patch-%s.diff", patch.ID)
+	if err := s.runtime.CopyToContainer(ctx, leader.ContainerID, path, []byte(patch.Diff)); err != nil {
+		status, errMsg = models.PatchStatusFailed, "copying patch to container: "+err.Error()
+	} else if output, err := s.runtime.ExecInContainer(ctx, leader.ContainerID,
+		[]string{"sh", "-c", applyPatchScript, "sh", path}); err != nil {
+		status, errMsg = models.PatchStatusFailed, strings.TrimSpace(output)
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+	}
+	s.db.Model(&models.ProposedPatch{}).Where("id = ?", patch.ID).
+		Updates(map[string]interface{}{"status": status, "error": errMsg})
+	s.db.First(&patch, "id = ?", patch.ID)
+
+	if status == models.PatchStatusFailed {
+		slog.Warn("patch failed to apply", "team", team.Name, "patch", patch.ID, "error", errMsg)
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(patch)
+	}
+	slog.Info("patch applied", "team", team.Name, "patch", patch.ID)
+	return c.JSON(patch)
+}
+
+// RejectPatch handles POST /api/teams/:id/patches/:patchId/reject. A
+// rejected patch can no longer be applied.
+func (s *Server) RejectPatch(c *fiber.Ctx) error {
+	_, patch, err := s.findPatch(c)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	query, args := patchDecidable(now)
+	res := s.db.Model(&models.ProposedPatch{}).
+		Where("id = ?", patch.ID).Where(query, args...).
+		Updates(map[string]interface{}{
+			"status":     models.PatchStatusRejected,
+			"decided_by": GetUserID(c),
+			"decided_at": now,
+		})
+	if res.Error != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to reject patch")
+	}
+	if res.RowsAffected == 0 {
+		return newAPIError(CodePatchState, "patch is already "+patch.Status)
+	}
+	s.db.First(&patch, "id = ?", patch.ID)
+	return c.JSON(patch)
+}
+
+// findPatch loads the team and patch a patch route refers to.
+func (s *Server) findPatch(c *fiber.Ctx) (models.Team, models.ProposedPatch, error) {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return team, models.ProposedPatch{}, newAPIError(CodeTeamNotFound, "team not found")
+	}
+	var patch models.ProposedPatch
+	if err := s.db.First(&patch, "id = ? AND team_id = ?", c.Params("patchId"), team.ID).Error; err != nil {
+		return team, patch, newAPIError(CodePatchNotFound, "patch not found")
+	}
+	return team, patch, nil
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+const proposedDiff = "Here is the fix:\n\n```diff\n" +
+	"diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n" +
+	"diff --git a/README.md b/README.md\n--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-a\n+b\n" +
+	"```\n"
+
+// proposePatch creates a running team with a running leader and relays a
+// leader response proposing a diff of two files. It returns the team and
+// the proposed patch.
+func proposePatch(t *testing.T, srv *Server, name string) (models.Team, models.ProposedPatch) {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   name,
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	srv.db.Model(&models.Agent{}).Where("team_id = ?", team.ID).
+		Updates(map[string]interface{}{"container_id": "leader-container", "container_status": models.ContainerStatusRunning})
+
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: proposedDiff})
+	// Redelivered responses propose the patch once.
+	for i := 0; i < 2; i++ {
+		if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/patches", nil)
+	patches := parseList[models.ProposedPatch](t, rec)
+	if len(patches) != 1 {
+		t.Fatalf("patches: got %d, want 1", len(patches))
+	}
+	return team, patches[0]
+}
+
+func TestProposedPatch_FromLeaderResponse(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-propose")
+
+	if patch.Status != models.PatchStatusProposed || patch.MessageID == "" || patch.TaskLogID == "" {
+		t.Errorf("patch: got %+v", patch)
+	}
+	if string(patch.Files) != `["main.go","README.md"]` {
+		t.Errorf("files: got %s", patch.Files)
+	}
+	if !strings.HasPrefix(patch.Diff, "diff --git a/main.go") || !strings.HasSuffix(patch.Diff, "+b\n") ||
+		!strings.Contains(patch.Diff, "+new\ndiff --git a/README.md") {
+		t.Errorf("diff: got %q", patch.Diff)
+	}
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/patches/"+patch.ID, nil)
+	if rec.Code != 200 {
+		t.Errorf("get: got %d", rec.Code)
+	}
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/patches/missing", nil)
+	var errResp ErrorResponse
+	parseJSON(t, rec, &errResp)
+	if rec.Code != 404 || errResp.Code != CodePatchNotFound {
+		t.Errorf("get missing: got %d %s, want 404 %s", rec.Code, errResp.Code, CodePatchNotFound)
+	}
+}
+
+func TestProposedPatch_NotForPlainResponses(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "patch-plain")
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "completed", Result: "## Done\n```go\nx := 1\n```"})
+	if err := srv.processRelayMessage(teamID, "patch-plain", data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	var count int64
+	srv.db.Model(&models.ProposedPatch{}).Count(&count)
+	if count != 0 {
+		t.Errorf("patches: got %d, want 0", count)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-apply")
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	if rec.Code != 200 {
+		t.Fatalf("apply: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var applied models.ProposedPatch
+	parseJSON(t, rec, &applied)
+	if applied.Status != models.PatchStatusApplied || applied.DecidedAt == nil {
+		t.Errorf("applied patch: got %+v", applied)
+	}
+
+	path := "/tmp/agentcrew-patch-" + patch.ID + ".diff"
+	if string(mock.copiedFiles[path]) != patch.Diff {
+		t.Errorf("copied patch: got %q", mock.copiedFiles[path])
+	}
+	if len(mock.execCommands) != 1 {
+		t.Fatalf("exec commands: got %d, want 1", len(mock.execCommands))
+	}
+	cmd := mock.execCommands[0]
+	if cmd[len(cmd)-1] != path || !strings.Contains(cmd[2], "git -C /workspace apply") {
+		t.Errorf("exec command: got %q", cmd)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	var errResp ErrorResponse
+	parseJSON(t, rec, &errResp)
+	if rec.Code != 409 || errResp.Code != CodePatchState {
+		t.Errorf("apply again: got %d %s, want 409 %s", rec.Code, errResp.Code, CodePatchState)
+	}
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/reject", nil)
+	if rec.Code != 409 {
+		t.Errorf("reject applied: got %d, want 409", rec.Code)
+	}
+}
+
+func TestApplyPatch_StaleClaim(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-stale")
+
+	// A claim still within patchClaimTTL blocks approvals and rejections.
+	recent := time.Now().UTC().Add(-time.Minute)
+	srv.db.Model(&patch).Updates(map[string]interface{}{"status": models.PatchStatusApplying, "decided_at": recent})
+	for _, action := range []string{"apply", "reject"} {
+		rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/"+action, nil)
+		if rec.Code != 409 {
+			t.Errorf("%s during apply: got %d, want 409", action, rec.Code)
+		}
+	}
+
+	// A claim left behind by a process that died can be taken over.
+	stale := time.Now().UTC().Add(-patchClaimTTL - time.Minute)
+	srv.db.Model(&patch).Update("decided_at", stale)
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	var applied models.ProposedPatch
+	parseJSON(t, rec, &applied)
+	if rec.Code != 200 || applied.Status != models.PatchStatusApplied {
+		t.Errorf("apply stale claim: got %d %+v", rec.Code, applied)
+	}
+	if len(mock.execCommands) != 1 {
+		t.Errorf("exec commands: got %d, want 1", len(mock.execCommands))
+	}
+}
+
+func TestApplyPatch_Fails(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-conflict")
+
+	mock.execOutput = "error: patch failed: main.go:1\nerror: main.go: patch does not apply"
+	mock.execErr = errors.New("exit code 1")
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	if rec.Code != 422 {
+		t.Fatalf("apply: got %d, want 422", rec.Code)
+	}
+	var failed models.ProposedPatch
+	parseJSON(t, rec, &failed)
+	if failed.Status != models.PatchStatusFailed || !strings.Contains(failed.Error, "patch does not apply") {
+		t.Errorf("failed patch: got %+v", failed)
+	}
+
+	// A failed patch can be retried once the workspace is fixed.
+	mock.execOutput, mock.execErr = "", nil
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	var applied models.ProposedPatch
+	parseJSON(t, rec, &applied)
+	if rec.Code != 200 || applied.Status != models.PatchStatusApplied || applied.Error != "" {
+		t.Errorf("retry: got %d %+v", rec.Code, applied)
+	}
+}
+
+func TestApplyPatch_TeamNotRunning(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-stopped")
+	srv.db.Model(&team).Update("status", models.TeamStatusStopped)
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	if rec.Code != 409 {
+		t.Errorf("apply: got %d, want 409", rec.Code)
+	}
+	if len(mock.execCommands) != 0 {
+		t.Errorf("exec commands: got %d, want 0", len(mock.execCommands))
+	}
+}
+
+func TestRejectPatch(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team, patch := proposePatch(t, srv, "patch-reject")
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/reject", nil)
+	var rejected models.ProposedPatch
+	parseJSON(t, rec, &rejected)
+	if rec.Code != 200 || rejected.Status != models.PatchStatusRejected {
+		t.Errorf("reject: got %d %+v", rec.Code, rejected)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/patches/"+patch.ID+"/apply", nil)
+	if rec.Code != 409 {
+		t.Errorf("apply rejected: got %d, want 409", rec.Code)
+	}
+	if len(mock.execCommands) != 0 {
+		t.Errorf("exec commands: got %d, want 0", len(mock.execCommands))
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/patches?status=proposed", nil)
+	if patches := parseList[models.ProposedPatch](t, rec); len(patches) != 0 {
+		t.Errorf("proposed patches: got %d, want 0", len(patches))
+	}
+}
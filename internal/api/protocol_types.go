@@ -0,0 +1,27 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// ProtocolTypesResponse documents the protocol exchanged with agents: the
+// message envelope and the payload of each message type, as JSON schemas.
+type ProtocolTypesResponse struct {
+	Message  *protocol.Schema         `json:"message"`
+	Payloads []protocol.PayloadSchema `json:"payloads"`
+}
+
+// protocolTypes is built once; the schemas only change with the code.
+var protocolTypes = sync.OnceValue(func() ProtocolTypesResponse {
+	return ProtocolTypesResponse{Message: protocol.MessageSchema(), Payloads: protocol.PayloadSchemas()}
+})
+
+// ListProtocolTypes returns the JSON schemas of the protocol messages, so
+// clients can validate and render message payloads.
+func (s *Server) ListProtocolTypes(c *fiber.Ctx) error {
+	return c.JSON(protocolTypes())
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestListProtocolTypes(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/protocol/types", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: got %d", rec.Code)
+	}
+	var resp ProtocolTypesResponse
+	parseJSON(t, rec, &resp)
+	if resp.Message == nil || resp.Message.Properties["payload"] == nil {
+		t.Errorf("message schema: got %+v", resp.Message)
+	}
+
+	var leader *protocol.Schema
+	for _, p := range resp.Payloads {
+		if p.Type == protocol.TypeLeaderResponse {
+			leader = p.Schema
+		}
+	}
+	if leader == nil {
+		t.Fatal("no leader_response schema")
+	}
+	if leader.Properties["status"].Type != "string" || leader.Properties["plan"].Type != "object" {
+		t.Errorf("leader_response schema: got %+v", leader.Properties)
+	}
+}
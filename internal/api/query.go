@@ -0,0 +1,191 @@
+package api
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// ListResponse is the envelope returned by list endpoints. NextCursor is set
+// when more items follow; pass it back as the "cursor" query parameter. Total
+// counts every item matching the filters, across all pages.
+type ListResponse[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+// listOptions describes the pagination and filters a list endpoint supports.
+type listOptions struct {
+	DefaultLimit int
+	MaxLimit     int
+	// Newest lists the most recently created items first.
+	Newest bool
+	// Filters maps a query parameter to the column it filters. Values are
+	// comma-separated and matched with IN.
+	Filters map[string]string
+}
+
+// listQuery is a parsed list request: page size, position, and filters.
+type listQuery struct {
+	opts    listOptions
+	limit   int
+	cursor  *listCursor
+	filters map[string][]string
+	since   *time.Time
+	until   *time.Time
+}
+
+// listCursor is the keyset position of the last item on the previous page.
+type listCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// encodeCursor returns an opaque cursor for the item at (createdAt, id).
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor produced by encodeCursor.
+func decodeCursor(s string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, fmt.Errorf("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return nil, err
+	}
+	return &listCursor{CreatedAt: t, ID: id}, nil
+}
+
+// parseListQuery reads limit, cursor, since/until and the endpoint's filters
+// from the request. "before" is accepted as an alias of "until" for clients
+// written against the earlier timestamp pagination.
+func parseListQuery(c *fiber.Ctx, opts listOptions) (listQuery, error) {
+	q := listQuery{opts: opts, limit: c.QueryInt("limit", opts.DefaultLimit)}
+	if q.limit <= 0 {
+		q.limit = opts.DefaultLimit
+	}
+	if q.limit > opts.MaxLimit {
+		q.limit = opts.MaxLimit
+	}
+
+	if v := c.Query("cursor"); v != "" {
+		cur, err := decodeCursor(v)
+		if err != nil {
+			return q, fiber.NewError(fiber.StatusBadRequest, "invalid cursor")
+		}
+		q.cursor = cur
+	}
+
+	for _, name := range []string{"since", "until", "before"} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return q, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid '%s' timestamp, use RFC3339 format", name))
+		}
+		if name == "since" {
+			q.since = &t
+		} else {
+			q.until = &t
+		}
+	}
+
+	for param := range opts.Filters {
+		if values := splitCSV(c.Query(param)); len(values) > 0 {
+			if q.filters == nil {
+				q.filters = make(map[string][]string)
+			}
+			q.filters[param] = values
+		}
+	}
+	return q, nil
+}
+
+// where applies the filters and date range to db.
+func (q listQuery) where(db *gorm.DB) *gorm.DB {
+	for param, values := range q.filters {
+		db = db.Where(q.opts.Filters[param]+" IN ?", values)
+	}
+	if q.since != nil {
+		db = db.Where("created_at >= ?", *q.since)
+	}
+	if q.until != nil {
+		db = db.Where("created_at < ?", *q.until)
+	}
+	return db
+}
+
+// page applies the cursor, ordering, and limit to db. One extra row is
+// requested to detect whether a next page exists.
+func (q listQuery) page(db *gorm.DB) *gorm.DB {
+	cmp, dir := ">", "ASC"
+	if q.opts.Newest {
+		cmp, dir = "<", "DESC"
+	}
+	if q.cursor != nil {
+		// Equivalent to "created_at < ? OR (created_at = ? AND id < ?)", but
+		// the separate bound on created_at lets SQLite seek the index to the
+		// cursor instead of scanning from the first row.
+		db = db.Where("created_at "+cmp+"= ?", q.cursor.CreatedAt).
+			Where("created_at "+cmp+" ? OR id "+cmp+" ?", q.cursor.CreatedAt, q.cursor.ID)
+	}
+	return db.Order("created_at " + dir).Order("id " + dir).Limit(q.limit + 1)
+}
+
+// findPage runs a filtered, paginated list query. base carries the
+// endpoint's own conditions (org or team scope); key returns the cursor
+// position of an item. preloads are applied to the page query only.
+func findPage[T any](base *gorm.DB, q listQuery, key func(T) (time.Time, string), preloads ...string) (ListResponse[T], error) {
+	var total int64
+	if err := q.where(base.Session(&gorm.Session{})).Model(new(T)).Count(&total).Error; err != nil {
+		return ListResponse[T]{Items: []T{}}, err
+	}
+	return findPageWithTotal(base, q, total, key, preloads...)
+}
+
+// findPageWithTotal is findPage for callers that already counted the
+// matching rows, such as handlers that computed a listETag.
+func findPageWithTotal[T any](base *gorm.DB, q listQuery, total int64, key func(T) (time.Time, string), preloads ...string) (ListResponse[T], error) {
+	resp := ListResponse[T]{Items: []T{}, Total: total}
+
+	pageQuery := q.page(q.where(base.Session(&gorm.Session{})))
+	for _, p := range preloads {
+		pageQuery = pageQuery.Preload(p)
+	}
+	var items []T
+	if err := pageQuery.Find(&items).Error; err != nil {
+		return resp, err
+	}
+
+	if len(items) > q.limit {
+		items = items[:q.limit]
+		createdAt, id := key(items[len(items)-1])
+		resp.NextCursor = encodeCursor(createdAt, id)
+	}
+	if items != nil {
+		resp.Items = items
+	}
+	return resp, nil
+}
+
+// Cursor keys for the paginated models.
+func teamKey(t models.Team) (time.Time, string)       { return t.CreatedAt, t.ID }
+func agentKey(a models.Agent) (time.Time, string)     { return a.CreatedAt, a.ID }
+func taskLogKey(l models.TaskLog) (time.Time, string) { return l.CreatedAt, l.ID }
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	at := time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	cur, err := decodeCursor(encodeCursor(at, "log-1"))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if !cur.CreatedAt.Equal(at) || cur.ID != "log-1" {
+		t.Fatalf("cursor: got %v/%s, want %v/log-1", cur.CreatedAt, cur.ID, at)
+	}
+
+	for _, bad := range []string{"!!!", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		if _, err := decodeCursor(bad); err == nil {
+			t.Errorf("decodeCursor(%q): expected error", bad)
+		}
+	}
+}
+
+func TestListTeams_Envelope(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	for i := 0; i < 5; i++ {
+		doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: fmt.Sprintf("page-team-%d", i)})
+	}
+
+	var seen []string
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		path := "/api/teams?limit=2"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		rec := doRequest(srv, "GET", path, nil)
+		if rec.Code != 200 {
+			t.Fatalf("status: got %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+		}
+		var resp ListResponse[models.Team]
+		parseJSON(t, rec, &resp)
+		if resp.Total != 5 {
+			t.Errorf("total: got %d, want 5", resp.Total)
+		}
+		for _, team := range resp.Items {
+			seen = append(seen, team.Name)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+
+	if len(seen) != 5 {
+		t.Fatalf("teams across pages: got %v, want 5", seen)
+	}
+	for i, name := range seen {
+		if want := fmt.Sprintf("page-team-%d", i); name != want {
+			t.Errorf("team %d: got %q, want %q", i, name, want)
+		}
+	}
+}
+
+func TestListTeams_Filters(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "filter-a"})
+	doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "filter-b"})
+	doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "filter-c"})
+	srv.db.Model(&models.Team{}).Where("name = ?", "filter-a").Update("status", models.TeamStatusRunning)
+	srv.db.Model(&models.Team{}).Where("name = ?", "filter-b").Update("runtime", "kubernetes")
+
+	rec := doRequest(srv, "GET", "/api/teams?status="+models.TeamStatusRunning, nil)
+	teams := parseList[models.Team](t, rec)
+	if len(teams) != 1 || teams[0].Name != "filter-a" {
+		t.Errorf("status filter: got %+v, want only filter-a", teams)
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams?runtime=kubernetes", nil)
+	teams = parseList[models.Team](t, rec)
+	if len(teams) != 1 || teams[0].Name != "filter-b" {
+		t.Errorf("runtime filter: got %+v, want only filter-b", teams)
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams?status="+models.TeamStatusRunning+","+models.TeamStatusStopped, nil)
+	var resp ListResponse[models.Team]
+	parseJSON(t, rec, &resp)
+	if resp.Total != 3 {
+		t.Errorf("multi-value status filter total: got %d, want 3", resp.Total)
+	}
+}
+
+func TestGetActivity_PaginatesNewestFirst(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "activity-paging")
+
+	base := time.Now().Add(-time.Hour).UTC()
+	for i := 0; i < 3; i++ {
+		log := insertTaskLog(t, srv, fmt.Sprintf("pg-%d", i), teamID, "leader", "user", "leader_response",
+			map[string]string{"n": fmt.Sprint(i)})
+		srv.db.Model(&log).Update("created_at", base.Add(time.Duration(i)*time.Minute))
+	}
+
+	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity?limit=2", nil)
+	var first ListResponse[models.TaskLog]
+	parseJSON(t, rec, &first)
+	if len(first.Items) != 2 || first.Items[0].ID != "pg-2" || first.Items[1].ID != "pg-1" {
+		t.Fatalf("first page: got %+v, want pg-2, pg-1", first.Items)
+	}
+	if first.NextCursor == "" || first.Total != 3 {
+		t.Fatalf("first page: next_cursor=%q total=%d, want cursor and total 3", first.NextCursor, first.Total)
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/"+teamID+"/activity?limit=2&cursor="+url.QueryEscape(first.NextCursor), nil)
+	var second ListResponse[models.TaskLog]
+	parseJSON(t, rec, &second)
+	if len(second.Items) != 1 || second.Items[0].ID != "pg-0" {
+		t.Fatalf("second page: got %+v, want pg-0", second.Items)
+	}
+	if second.NextCursor != "" {
+		t.Errorf("second page: unexpected next_cursor %q", second.NextCursor)
+	}
+
+	since := url.QueryEscape(base.Add(30 * time.Second).Format(time.RFC3339Nano))
+	rec = doRequest(srv, "GET", "/api/teams/"+teamID+"/activity?since="+since, nil)
+	if logs := parseList[models.TaskLog](t, rec); len(logs) != 2 {
+		t.Errorf("since filter: got %d entries, want 2", len(logs))
+	}
+}
+
+func TestGetMessages_FromAgentFilter(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "messages-from-agent")
+
+	insertTaskLog(t, srv, "fa-1", teamID, "user", "leader", "user_message", map[string]string{"content": "hi"})
+	insertTaskLog(t, srv, "fa-2", teamID, "leader", "user", "leader_response", map[string]string{"result": "hello"})
+
+	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/messages?from_agent=leader", nil)
+	logs := parseList[models.TaskLog](t, rec)
+	if len(logs) != 1 || logs[0].ID != "fa-2" {
+		t.Errorf("from_agent filter: got %+v, want only fa-2", logs)
+	}
+}
+
+func TestListQuery_InvalidParams(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "invalid-params")
+
+	for _, path := range []string{
+		"/api/teams?cursor=not-a-cursor",
+		"/api/teams?since=yesterday",
+		"/api/teams/" + teamID + "/activity?until=tomorrow",
+		"/api/teams/" + teamID + "/messages?before=nope",
+	} {
+		if rec := doRequest(srv, "GET", path, nil); rec.Code != 400 {
+			t.Errorf("%s: got %d, want 400", path, rec.Code)
+		}
+	}
+}
+
+// explainTaskLogQuery returns SQLite's query plan for the task log query
+// built by fn.
+func explainTaskLogQuery(t *testing.T, srv *Server, fn func(tx *gorm.DB) *gorm.DB) string {
+	t.Helper()
+	sql := srv.db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var logs []models.TaskLog
+		return fn(tx.Model(&models.TaskLog{})).Find(&logs)
+	})
+	rows, err := srv.db.Raw("EXPLAIN QUERY PLAN " + sql).Rows()
+	if err != nil {
+		t.Fatalf("explain %s: %v", sql, err)
+	}
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, notUsed int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notUsed, &detail); err != nil {
+			t.Fatalf("scanning plan: %v", err)
+		}
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "; ")
+}
+
+func TestTaskLogPageQueries_UseIndexes(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	cursor := &listCursor{CreatedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), ID: "log-1"}
+
+	tests := []struct {
+		name      string
+		q         listQuery
+		types     []string
+		wantIndex string
+		wantSeek  bool
+	}{
+		{"activity", listQuery{opts: activityListOptions, limit: 50}, nil, "idx_tasklog_team_created_type", false},
+		{"activity cursor", listQuery{opts: activityListOptions, limit: 50, cursor: cursor}, nil, "idx_tasklog_team_created_type", true},
+		{"messages cursor", listQuery{opts: messageListOptions, limit: 50, cursor: cursor}, chatMessageTypes, "idx_tasklog_team_created_type", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := explainTaskLogQuery(t, srv, func(tx *gorm.DB) *gorm.DB {
+				tx = tx.Where("team_id = ?", "team-1")
+				if tt.types != nil {
+					tx = tx.Where("message_type IN ?", tt.types)
+				}
+				return tt.q.page(tt.q.where(tx))
+			})
+			if !strings.Contains(plan, tt.wantIndex) {
+				t.Errorf("plan does not use %s: %s", tt.wantIndex, plan)
+			}
+			if strings.Contains(plan, "TEMP B-TREE") {
+				t.Errorf("plan sorts instead of walking the index: %s", plan)
+			}
+			if tt.wantSeek && !strings.Contains(plan, "created_at<?") {
+				t.Errorf("plan does not seek to the cursor: %s", plan)
+			}
+		})
+	}
+
+	countPlan := explainTaskLogQuery(t, srv, func(tx *gorm.DB) *gorm.DB {
+		return tx.Select("COUNT(*)").Where("team_id = ? AND message_type IN ?", "team-1", chatMessageTypes)
+	})
+	if !strings.Contains(countPlan, "COVERING INDEX idx_tasklog_team_type_created") {
+		t.Errorf("message count is not a covering index scan: %s", countPlan)
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"gorm.io/gorm/clause"
+)
+
+// quotaRetention is how long quota events are kept.
+const quotaRetention = 7 * 24 * time.Hour
+
+// maxQuotaEvents caps the recent events returned by GET /api/teams/:id/quota.
+const maxQuotaEvents = 50
+
+// QuotaLimit is the latest known state of one of the provider's rate
+// limits for a team.
+type QuotaLimit struct {
+	LimitType   string     `json:"limit_type"`
+	Status      string     `json:"status"`
+	AgentName   string     `json:"agent_name"`
+	Utilization *float64   `json:"utilization"`
+	ResetsAt    *time.Time `json:"resets_at"`
+	// Headroom is the estimated fraction of the limit left: 1 - utilization,
+	// 0 while rejected, and 1 once the limit reset. It is null when the
+	// provider reported no utilization.
+	Headroom  *float64  `json:"headroom"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TeamQuotaResponse is the response of GET /api/teams/:id/quota.
+type TeamQuotaResponse struct {
+	TeamID string `json:"team_id"`
+	// RateLimitedLastHour and RateLimitedLastDay count the requests the
+	// provider rejected for exceeding the rate limit (HTTP 429).
+	RateLimitedLastHour int64      `json:"rate_limited_last_hour"`
+	RateLimitedLastDay  int64      `json:"rate_limited_last_24h"`
+	LastRateLimitedAt   *time.Time `json:"last_rate_limited_at"`
+	// ThrottledUntil is when the last of the rejected limits resets, while
+	// one is still rejected.
+	ThrottledUntil *time.Time          `json:"throttled_until"`
+	Limits         []QuotaLimit        `json:"limits"`
+	Events         []models.QuotaEvent `json:"events"`
+}
+
+// recordQuotaEvent saves a rate limit state reported by one of the team's
+// agents as event id, unless it was saved already (see relayRecordID).
+func (s *Server) recordQuotaEvent(teamID, id string, quota protocol.QuotaPayload) error {
+	event := models.QuotaEvent{
+		ID:          id,
+		AgentName:   quota.AgentName,
+		Status:      quota.Status,
+		LimitType:   quota.LimitType,
+		Utilization: quota.Utilization,
+		ResetsAt:    quota.ResetsAt,
+	}
+	return s.saveQuotaEvent(teamID, event)
+}
+
+// recordRateLimitError saves a request the provider rejected for exceeding
+// the rate limit, as reported in the agent's failed leader response.
+func (s *Server) recordRateLimitError(teamID, agentName string, resp protocol.LeaderResponsePayload) {
+	event := models.QuotaEvent{
+		AgentName: agentName,
+		Status:    models.QuotaStatusRejected,
+		ErrorCode: resp.ErrorCode,
+		Message:   resp.Error,
+	}
+	s.saveQuotaEvent(teamID, event)
+}
+
+// saveQuotaEvent saves event for the team, and drops the team's events
+// older than quotaRetention.
+func (s *Server) saveQuotaEvent(teamID string, event models.QuotaEvent) error {
+	var team models.Team
+	if err := s.db.Select("id", "org_id", "name").First(&team, "id = ?", teamID).Error; err != nil {
+		slog.Warn("relay: dropping quota event of unknown team", "team_id", teamID, "error", err)
+		return nil
+	}
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	event.OrgID = team.OrgID
+	event.TeamID = team.ID
+	// SQLite compares times as text, so keep them all in UTC.
+	event.CreatedAt = time.Now().UTC()
+	if event.ResetsAt != nil {
+		resetsAt := event.ResetsAt.UTC()
+		event.ResetsAt = &resetsAt
+	}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&event).Error; err != nil {
+		slog.Error("relay: failed to save quota event", "team", team.Name, "error", err)
+		return err
+	}
+	s.db.Where("team_id = ? AND created_at < ?", team.ID, event.CreatedAt.Add(-quotaRetention)).
+		Delete(&models.QuotaEvent{})
+	if event.Status != models.QuotaStatusAllowed {
+		slog.Info("relay: provider rate limit state", "team", team.Name, "agent", event.AgentName,
+			"status", event.Status, "limit_type", event.LimitType, "error_code", event.ErrorCode)
+	}
+	return nil
+}
+
+// GetTeamQuota handles GET /api/teams/:id/quota. It returns the requests
+// the provider rejected for exceeding its rate limit, the latest state of
+// each of its limits with an estimate of the headroom left, and the team's
+// quota events of the last 24 hours, newest first.
+func (s *Server) GetTeamQuota(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	now := time.Now().UTC()
+	var events []models.QuotaEvent
+	if err := s.db.Where("team_id = ? AND created_at >= ?", team.ID, now.Add(-quotaRetention)).
+		Order("created_at DESC").Find(&events).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to load quota events")
+	}
+
+	resp := TeamQuotaResponse{
+		TeamID: team.ID,
+		Limits: []QuotaLimit{},
+		Events: []models.QuotaEvent{},
+	}
+	seen := make(map[string]bool)
+	for _, e := range events {
+		if e.CreatedAt.After(now.Add(-24*time.Hour)) && len(resp.Events) < maxQuotaEvents {
+			resp.Events = append(resp.Events, e)
+		}
+		if e.ErrorCode != "" {
+			// Rejected requests count as 429s; they carry no limit state.
+			if resp.LastRateLimitedAt == nil {
+				at := e.CreatedAt
+				resp.LastRateLimitedAt = &at
+			}
+			if e.CreatedAt.After(now.Add(-time.Hour)) {
+				resp.RateLimitedLastHour++
+			}
+			if e.CreatedAt.After(now.Add(-24 * time.Hour)) {
+				resp.RateLimitedLastDay++
+			}
+			continue
+		}
+		if seen[e.LimitType] {
+			continue
+		}
+		seen[e.LimitType] = true
+		limit := quotaLimit(e, now)
+		if limit.Status == models.QuotaStatusRejected && limit.ResetsAt != nil &&
+			(resp.ThrottledUntil == nil || limit.ResetsAt.After(*resp.ThrottledUntil)) {
+			resp.ThrottledUntil = limit.ResetsAt
+		}
+		resp.Limits = append(resp.Limits, limit)
+	}
+	return c.JSON(resp)
+}
+
+// quotaLimit returns the limit state reported by e, with its headroom
+// estimated at now.
+func quotaLimit(e models.QuotaEvent, now time.Time) QuotaLimit {
+	limit := QuotaLimit{
+		LimitType:   e.LimitType,
+		Status:      e.Status,
+		AgentName:   e.AgentName,
+		Utilization: e.Utilization,
+		ResetsAt:    e.ResetsAt,
+		UpdatedAt:   e.CreatedAt,
+	}
+	var headroom float64
+	switch {
+	case e.ResetsAt != nil && !e.ResetsAt.After(now):
+		// The limit reset since it was reported.
+		headroom = 1
+		limit.Status = models.QuotaStatusAllowed
+	case e.Status == models.QuotaStatusRejected:
+		headroom = 0
+	case e.Utilization != nil:
+		headroom = max(0, min(1, 1-*e.Utilization))
+	default:
+		return limit
+	}
+	limit.Headroom = &headroom
+	return limit
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// relayQuota feeds a quota message for the team through the relay.
+func relayQuota(t *testing.T, srv *Server, team models.Team, quota protocol.QuotaPayload) {
+	t.Helper()
+	data := buildRelayPayload(t, protocol.TypeQuota, quota.AgentName, "system", quota)
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+}
+
+func TestTeamQuota(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createTestTeam(t, srv, "quota-team")
+
+	utilization := 0.8
+	weekReset := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+	relayQuota(t, srv, team, protocol.QuotaPayload{
+		AgentName: "leader", Status: protocol.QuotaWarning, LimitType: "seven_day",
+		Utilization: &utilization, ResetsAt: &weekReset,
+	})
+	hourReset := time.Now().Add(30 * time.Minute).UTC().Truncate(time.Second)
+	relayQuota(t, srv, team, protocol.QuotaPayload{
+		AgentName: "leader", Status: protocol.QuotaRejected, LimitType: "five_hour", ResetsAt: &hourReset,
+	})
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user", protocol.LeaderResponsePayload{
+		Status: "failed", Error: "The AI provider's rate limit was reached.", ErrorCode: "rate_limit_error",
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	// Events older than the retention are dropped on the next save.
+	old := models.QuotaEvent{ID: "old", OrgID: team.OrgID, TeamID: team.ID, Status: models.QuotaStatusAllowed,
+		LimitType: "five_hour", CreatedAt: time.Now().Add(-8 * 24 * time.Hour).UTC()}
+	srv.db.Create(&old)
+	relayQuota(t, srv, team, protocol.QuotaPayload{AgentName: "leader", Status: protocol.QuotaAllowed, LimitType: "opus"})
+	if err := srv.db.First(&models.QuotaEvent{}, "id = ?", "old").Error; err == nil {
+		t.Error("expired quota event was not dropped")
+	}
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/quota", nil)
+	if rec.Code != 200 {
+		t.Fatalf("get quota: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var resp TeamQuotaResponse
+	parseJSON(t, rec, &resp)
+	if resp.RateLimitedLastHour != 1 || resp.RateLimitedLastDay != 1 || resp.LastRateLimitedAt == nil {
+		t.Errorf("rate limited counts: got %+v", resp)
+	}
+	if resp.ThrottledUntil == nil || !resp.ThrottledUntil.Equal(hourReset) {
+		t.Errorf("throttled_until: got %v, want %v", resp.ThrottledUntil, hourReset)
+	}
+	if len(resp.Events) != 4 || resp.Events[0].LimitType != "opus" || resp.Events[1].ErrorCode != "rate_limit_error" {
+		t.Errorf("events: got %+v", resp.Events)
+	}
+
+	limits := make(map[string]QuotaLimit)
+	for _, l := range resp.Limits {
+		limits[l.LimitType] = l
+	}
+	if len(limits) != 3 {
+		t.Fatalf("limits: got %+v", resp.Limits)
+	}
+	if l := limits["seven_day"]; l.Headroom == nil || *l.Headroom < 0.19 || *l.Headroom > 0.21 {
+		t.Errorf("seven_day limit: got %+v", l)
+	}
+	if l := limits["five_hour"]; l.Status != protocol.QuotaRejected || l.Headroom == nil || *l.Headroom != 0 {
+		t.Errorf("five_hour limit: got %+v", l)
+	}
+	if l := limits["opus"]; l.Headroom != nil {
+		t.Errorf("opus limit without utilization: got %+v", l)
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/missing/quota", nil)
+	var errResp ErrorResponse
+	parseJSON(t, rec, &errResp)
+	if rec.Code != 404 || errResp.Code != CodeTeamNotFound {
+		t.Errorf("unknown team: got %d %+v, want 404 %s", rec.Code, errResp, CodeTeamNotFound)
+	}
+}
+
+func TestQuotaLimit_Reset(t *testing.T) {
+	now := time.Now()
+	resetsAt := now.Add(-time.Minute)
+	limit := quotaLimit(models.QuotaEvent{Status: models.QuotaStatusRejected, LimitType: "five_hour", ResetsAt: &resetsAt}, now)
+	if limit.Status != models.QuotaStatusAllowed || limit.Headroom == nil || *limit.Headroom != 1 {
+		t.Errorf("reset limit: got %+v", limit)
+	}
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+)
+
+// SetGovernor sets the rate limiter that chat messages, webhook runs and
+// issue runs wait for before they reach a team leader. The scheduler should
+// share the same governor so that all prompts count against one limit,
+// which other replicas and relay workers share through the database.
+func (s *Server) SetGovernor(g *ratelimit.Governor) {
+	s.governor = g
+}
+
+// GetRateLimitStatus handles GET /api/rate-limit. It returns the limiter's
+// configuration and the caller's organization's queued prompts. Positions
+// count prompts of all organizations, since they share the limit.
+func (s *Server) GetRateLimitStatus(c *fiber.Ctx) error {
+	status := s.governor.Status()
+	orgID := GetOrgID(c)
+	queue := make([]ratelimit.Entry, 0, len(status.Queue))
+	for _, e := range status.Queue {
+		if e.OrgID == orgID {
+			queue = append(queue, e)
+		}
+	}
+	status.Queue = queue
+	return c.JSON(status)
+}
+
+// observeRateLimitError pauses the governor when a leader reports that the
+// provider rejected its request for exceeding the rate limit, so that queued
+// prompts do not fail the same way. The rejection is kept as one of the
+// team's quota events.
+func (s *Server) observeRateLimitError(teamID, teamName string, msg protocol.Message) {
+	var payload protocol.LeaderResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || !protocol.IsRateLimitError(payload.ErrorCode) {
+		return
+	}
+	slog.Warn("relay: provider rate limit reached, pausing prompts",
+		"team", teamName, "backoff", ratelimit.DefaultBackoff)
+	s.governor.Backoff(ratelimit.DefaultBackoff)
+	s.recordRateLimitError(teamID, msg.From, payload)
+}
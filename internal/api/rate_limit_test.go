@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+)
+
+func TestSendChat_RateLimited(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.SetGovernor(ratelimit.New(srv.db, 1, 1))
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "chat-limited-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "first"}); rec.Code != 200 {
+		t.Fatalf("first message: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "second"})
+	if rec.Code != 202 {
+		t.Fatalf("second message: got %d, want 202\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Status        string `json:"status"`
+		ID            string `json:"id"`
+		QueuePosition int    `json:"queue_position"`
+	}
+	parseJSON(t, rec, &resp)
+	if resp.Status != models.ChatDeliveryQueued || resp.QueuePosition != 1 {
+		t.Errorf("response: got %+v", resp)
+	}
+
+	var log models.TaskLog
+	srv.db.First(&log, "id = ?", resp.ID)
+	if log.DeliveryStatus != models.ChatDeliveryQueued {
+		t.Errorf("delivery_status: got %q, want %q", log.DeliveryStatus, models.ChatDeliveryQueued)
+	}
+}
+
+func TestFlushQueuedChats_StopsOnShutdown(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	g := ratelimit.New(srv.db, 1, 1)
+	srv.SetGovernor(g)
+	g.TryAcquire()
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "flush-shutdown-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/chat", ChatRequest{Message: "queued"})
+	if rec.Code != 202 {
+		t.Fatalf("message: got %d, want 202\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		ID string `json:"id"`
+	}
+	parseJSON(t, rec, &resp)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.flushQueuedChats(team.ID, team.Name)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for g.Len() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	srv.stop()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("flush still waiting for the rate limiter after shutdown")
+	}
+	var log models.TaskLog
+	srv.db.First(&log, "id = ?", resp.ID)
+	if log.DeliveryStatus != models.ChatDeliveryQueued {
+		t.Errorf("delivery_status: got %q, want %q", log.DeliveryStatus, models.ChatDeliveryQueued)
+	}
+}
+
+func TestGetRateLimitStatus(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "GET", "/api/rate-limit", nil)
+	var status ratelimit.Status
+	parseJSON(t, rec, &status)
+	if status.Enabled {
+		t.Errorf("rate limit should be disabled by default, got %+v", status)
+	}
+
+	g := ratelimit.New(srv.db, 1, 1)
+	srv.SetGovernor(g)
+	g.TryAcquire()
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "limited-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for _, req := range []ratelimit.Request{
+		{ID: "other-run", OrgID: "other-org", TeamName: "other", Source: "webhook"},
+		{ID: "my-run", OrgID: team.OrgID, TeamID: team.ID, TeamName: team.Name, Source: "scheduler"},
+	} {
+		want := g.Len() + 1
+		go g.Wait(ctx, req)
+		deadline := time.Now().Add(2 * time.Second)
+		for g.Len() != want && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	rec = doRequest(srv, "GET", "/api/rate-limit", nil)
+	parseJSON(t, rec, &status)
+	if !status.Enabled || status.PerMinute != 1 {
+		t.Errorf("status: got %+v", status)
+	}
+	if len(status.Queue) != 1 || status.Queue[0].ID != "my-run" || status.Queue[0].Position != 2 {
+		t.Errorf("queue: got %+v", status.Queue)
+	}
+}
+
+func TestProcessRelayMessage_RateLimitErrorPausesGovernor(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	g := ratelimit.New(srv.db, 60, 5)
+	srv.SetGovernor(g)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "relay-limited-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	failed := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "failed", Error: "boom", ErrorCode: "billing_error"})
+	if err := srv.processRelayMessage(team.ID, team.Name, failed); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	if g.Status().PausedUntil != nil {
+		t.Error("other errors should not pause the governor")
+	}
+
+	limited := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user",
+		protocol.LeaderResponsePayload{Status: "failed", Error: "slow down", ErrorCode: "rate_limit_error"})
+	if err := srv.processRelayMessage(team.ID, team.Name, limited); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	if g.Status().PausedUntil == nil {
+		t.Error("a rate limit error should pause the governor")
+	}
+	if g.TryAcquire() {
+		t.Error("TryAcquire should fail while paused")
+	}
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// defaultReadCacheTTL bounds how long a cached team row or settings map is
+// served. Writes through this API's database handle invalidate entries at
+// once; the TTL covers writes made by other replicas and relay workers.
+const defaultReadCacheTTL = 5 * time.Second
+
+// readCache keeps the team rows and organization settings that every chat,
+// activity and relayed message looks up, so that polling clients and busy
+// relays do not read them from SQLite each time.
+type readCache struct {
+	teams    *ttlCache[models.Team]
+	settings *ttlCache[map[string]string]
+}
+
+// ReadCacheStats reports the activity of the read cache.
+type ReadCacheStats struct {
+	TTLSeconds float64    `json:"ttl_seconds"`
+	Teams      CacheStats `json:"teams"`
+	Settings   CacheStats `json:"settings"`
+}
+
+// CacheStats reports the activity of one cached table.
+type CacheStats struct {
+	Entries       int   `json:"entries"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+func newReadCache(ttl time.Duration) *readCache {
+	return &readCache{
+		teams:    newTTLCache[models.Team](ttl),
+		settings: newTTLCache[map[string]string](ttl),
+	}
+}
+
+// setTTL changes the TTL of both tables and drops their entries. A TTL of
+// zero disables caching.
+func (rc *readCache) setTTL(ttl time.Duration) {
+	rc.teams.setTTL(ttl)
+	rc.settings.setTTL(ttl)
+}
+
+// Stats returns the cache's counters.
+func (rc *readCache) Stats() ReadCacheStats {
+	return ReadCacheStats{
+		TTLSeconds: rc.teams.getTTL().Seconds(),
+		Teams:      rc.teams.stats(),
+		Settings:   rc.settings.stats(),
+	}
+}
+
+// invalidateOnWrite registers GORM callbacks that drop the cached teams or
+// settings after any write to their table through db, including raw SQL.
+// Which rows an update touches is not always known, so the whole table is
+// dropped; reads far outnumber writes to either.
+func (rc *readCache) invalidateOnWrite(db *gorm.DB) {
+	// Servers sharing a database handle, such as relay workers, each
+	// register their own callback; GORM runs one callback per name.
+	name := fmt.Sprintf("agentcrew:read_cache:%p", rc)
+	invalidate := func(tx *gorm.DB) {
+		table := tx.Statement.Table
+		if table == "" {
+			// Raw SQL: look for the table names in the statement.
+			sql := strings.ToLower(tx.Statement.SQL.String())
+			if strings.Contains(sql, "teams") {
+				rc.teams.invalidate()
+			}
+			if strings.Contains(sql, "settings") {
+				rc.settings.invalidate()
+			}
+			return
+		}
+		switch table {
+		case "teams":
+			rc.teams.invalidate()
+		case "settings":
+			rc.settings.invalidate()
+		}
+	}
+	cb := db.Callback()
+	cb.Create().After("gorm:create").Register(name, invalidate)
+	cb.Update().After("gorm:update").Register(name, invalidate)
+	cb.Delete().After("gorm:delete").Register(name, invalidate)
+	cb.Raw().After("gorm:raw").Register(name, invalidate)
+}
+
+// ttlCache is a map of values that expire after a TTL. Each invalidation
+// bumps a generation, so that a value loaded before a write is not stored
+// after it.
+type ttlCache[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	gen     uint64
+	entries map[string]ttlEntry[V]
+	now     func() time.Time
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	invalidations atomic.Int64
+}
+
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+func newTTLCache[V any](ttl time.Duration) *ttlCache[V] {
+	return &ttlCache[V]{ttl: ttl, entries: make(map[string]ttlEntry[V]), now: time.Now}
+}
+
+// get returns the cached value of key, or calls load and caches its value.
+// Load errors are not cached.
+func (c *ttlCache[V]) get(key string, load func() (V, error)) (V, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && c.now().Before(e.expires) {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return e.value, nil
+	}
+	gen, ttl := c.gen, c.ttl
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	value, err := load()
+	if err != nil || ttl <= 0 {
+		return value, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.entries[key] = ttlEntry[V]{value: value, expires: c.now().Add(ttl)}
+	}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// invalidate drops every entry.
+func (c *ttlCache[V]) invalidate() {
+	c.mu.Lock()
+	c.gen++
+	clear(c.entries)
+	c.mu.Unlock()
+	c.invalidations.Add(1)
+}
+
+func (c *ttlCache[V]) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	c.ttl = ttl
+	c.gen++
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+func (c *ttlCache[V]) getTTL() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl
+}
+
+func (c *ttlCache[V]) stats() CacheStats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	return CacheStats{
+		Entries:       entries,
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+	}
+}
+
+// cachedTeam returns the team row with the given ID, from the read cache
+// when it holds it. It does not preload the team's agents.
+func (s *Server) cachedTeam(teamID string) (models.Team, error) {
+	return s.readCache.teams.get(teamID, func() (models.Team, error) {
+		var team models.Team
+		err := s.db.First(&team, "id = ?", teamID).Error
+		return team, err
+	})
+}
+
+// findTeamCached is the cached form of the OrgScope lookup of a team that
+// handlers start with. It returns the API error for a missing team.
+func (s *Server) findTeamCached(orgID, teamID string) (models.Team, error) {
+	team, err := s.cachedTeam(teamID)
+	if err != nil || team.OrgID != orgID {
+		return models.Team{}, newAPIError(CodeTeamNotFound, "team not found")
+	}
+	return team, nil
+}
+
+// SetReadCacheTTL sets how long team rows and settings are cached. Zero
+// disables the cache.
+func (s *Server) SetReadCacheTTL(ttl time.Duration) {
+	s.readCache.setTTL(ttl)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestTTLCache_HitsMissesAndExpiry(t *testing.T) {
+	now := time.Now()
+	c := newTTLCache[int](time.Minute)
+	c.now = func() time.Time { return now }
+
+	loads := 0
+	load := func() (int, error) { loads++; return loads, nil }
+	for i := 0; i < 3; i++ {
+		if v, _ := c.get("k", load); v != 1 {
+			t.Fatalf("get %d: got %d, want 1", i, v)
+		}
+	}
+	now = now.Add(time.Minute)
+	if v, _ := c.get("k", load); v != 2 {
+		t.Errorf("after TTL: got %d, want 2", v)
+	}
+
+	stats := c.stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("stats: got %+v", stats)
+	}
+}
+
+func TestTTLCache_DoesNotCacheErrors(t *testing.T) {
+	c := newTTLCache[int](time.Minute)
+	if _, err := c.get("k", func() (int, error) { return 0, errors.New("boom") }); err == nil {
+		t.Fatal("expected the load error")
+	}
+	if v, _ := c.get("k", func() (int, error) { return 7, nil }); v != 7 {
+		t.Errorf("get after error: got %d, want 7", v)
+	}
+}
+
+func TestTTLCache_InvalidationDuringLoad(t *testing.T) {
+	c := newTTLCache[int](time.Minute)
+	// A write committed while the value loads makes it stale.
+	c.get("k", func() (int, error) { c.invalidate(); return 1, nil })
+	if v, _ := c.get("k", func() (int, error) { return 2, nil }); v != 2 {
+		t.Errorf("stale value cached: got %d, want 2", v)
+	}
+}
+
+func TestTTLCache_ZeroTTLDisables(t *testing.T) {
+	c := newTTLCache[int](0)
+	loads := 0
+	for i := 0; i < 2; i++ {
+		c.get("k", func() (int, error) { loads++; return loads, nil })
+	}
+	if loads != 2 {
+		t.Errorf("loads: got %d, want 2", loads)
+	}
+}
+
+func TestReadCache_TeamInvalidatedOnWrite(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "cache-team")
+
+	for i := 0; i < 3; i++ {
+		if rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity", nil); rec.Code != 200 {
+			t.Fatalf("activity: got %d", rec.Code)
+		}
+	}
+	if stats := srv.readCache.Stats().Teams; stats.Hits < 2 {
+		t.Errorf("team cache hits: got %+v, want at least 2", stats)
+	}
+
+	srv.db.Model(&models.Team{}).Where("id = ?", teamID).Update("status", models.TeamStatusRunning)
+	if team, _ := srv.cachedTeam(teamID); team.Status != models.TeamStatusRunning {
+		t.Errorf("status after update: got %q", team.Status)
+	}
+	srv.db.Exec("UPDATE teams SET status = ? WHERE id = ?", models.TeamStatusError, teamID)
+	if team, _ := srv.cachedTeam(teamID); team.Status != models.TeamStatusError {
+		t.Errorf("status after raw update: got %q", team.Status)
+	}
+
+	srv.db.Delete(&models.Team{}, "id = ?", teamID)
+	if rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/activity", nil); rec.Code != 404 {
+		t.Errorf("activity of deleted team: got %d, want 404", rec.Code)
+	}
+}
+
+func TestReadCache_TeamOrgScoped(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "cache-org")
+	if _, err := srv.cachedTeam(teamID); err != nil {
+		t.Fatalf("cachedTeam: %v", err)
+	}
+	if _, err := srv.findTeamCached("other-org", teamID); err == nil {
+		t.Error("team of another organization should not be found")
+	}
+}
+
+func TestReadCache_SettingsInvalidatedOnWrite(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	orgID := auth.DefaultOrgID()
+
+	putSetting(t, srv, "OPENAI_API_KEY", "sk-one")
+	env := srv.LoadSettingsEnv(orgID)
+	env["OPENAI_API_KEY"] = "changed by caller"
+	if got := srv.LoadSettingsEnv(orgID)["OPENAI_API_KEY"]; got != "sk-one" {
+		t.Errorf("cached settings: got %q, want sk-one", got)
+	}
+
+	putSetting(t, srv, "OPENAI_API_KEY", "sk-two")
+	if got := srv.LoadSettingsEnv(orgID)["OPENAI_API_KEY"]; got != "sk-two" {
+		t.Errorf("settings after update: got %q, want sk-two", got)
+	}
+
+	rec := doRequest(srv, "GET", "/api/admin/db", nil)
+	var resp DBStatsResponse
+	parseJSON(t, rec, &resp)
+	if resp.ReadCache.Settings.Hits == 0 || resp.ReadCache.Settings.Invalidations == 0 || resp.ReadCache.TTLSeconds != defaultReadCacheTTL.Seconds() {
+		t.Errorf("read cache stats: got %+v", resp.ReadCache)
+	}
+}
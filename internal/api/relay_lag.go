@@ -0,0 +1,173 @@
+package api
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/nats-io/nats.go"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// relayMaxAckPending is how many messages of a team's stream a relay holds
+// unacknowledged. Activity events are acknowledged once the TaskLog writer
+// commits their batch, so this bounds how many of them can share a batch.
+const relayMaxAckPending = taskLogBatchSize
+
+// A relay is under pressure when the messages it commits were stored in the
+// stream relayPressureLag ago or more, or relayPressurePending messages are
+// still waiting to be delivered. It then asks the leader's sidecar to trim
+// its activity events, and lets it go back to normal once the lag is below
+// relayRelievedLag and fewer than relayRelievedPending messages wait.
+var (
+	relayPressureLag     = 5 * time.Second
+	relayPressurePending = uint64(500)
+	relayRelievedLag     = time.Second
+	relayRelievedPending = uint64(50)
+)
+
+// relayThrottleTTL is how long a throttle command lasts. The relay renews
+// it halfway through while the pressure lasts.
+const relayThrottleTTL = time.Minute
+
+// RelayLagStats reports how far a team's relay is behind its stream.
+type RelayLagStats struct {
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
+	// Relayed counts the messages committed since the relay started, and
+	// InFlight those received but not committed yet.
+	Relayed  int64 `json:"relayed"`
+	InFlight int64 `json:"in_flight"`
+	// Pending is the number of messages in the stream not yet delivered to
+	// the relay, as of the last committed message.
+	Pending uint64 `json:"pending"`
+	// LagMs is the time from the stream storing the last message to its
+	// commit, and MaxLagMs the longest since the relay started.
+	LagMs         float64    `json:"lag_ms"`
+	MaxLagMs      float64    `json:"max_lag_ms"`
+	LastRelayedAt *time.Time `json:"last_relayed_at,omitempty"`
+	// Throttled is set while the leader's sidecar has been asked to trim
+	// its activity events.
+	Throttled bool `json:"throttled"`
+
+	throttleSentAt time.Time
+}
+
+// relayLagTracker keeps the RelayLagStats of the teams this process relays
+// from their JetStream stream.
+type relayLagTracker struct {
+	mu    sync.Mutex
+	teams map[string]*RelayLagStats
+}
+
+// received records that a message was delivered to the team's relay.
+func (r *relayLagTracker) received(teamID, teamName string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.teams == nil {
+		r.teams = make(map[string]*RelayLagStats)
+	}
+	stats, ok := r.teams[teamID]
+	if !ok {
+		stats = &RelayLagStats{TeamID: teamID, TeamName: teamName}
+		r.teams[teamID] = stats
+	}
+	stats.InFlight++
+}
+
+// committed records that a message the stream stored at stored was saved,
+// with pending messages still to be delivered. It returns the throttle
+// level to send the leader's sidecar, or "" if there is nothing to send.
+func (r *relayLagTracker) committed(teamID string, stored time.Time, pending uint64) string {
+	now := time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Writes queued by a relay that has since stopped still commit.
+	stats, ok := r.teams[teamID]
+	if !ok {
+		return ""
+	}
+	if stats.InFlight > 0 {
+		stats.InFlight--
+	}
+	stats.Relayed++
+	stats.Pending = pending
+	stats.LastRelayedAt = &now
+	var lag time.Duration
+	if !stored.IsZero() {
+		lag = now.Sub(stored)
+		stats.LagMs = float64(lag) / float64(time.Millisecond)
+		stats.MaxLagMs = max(stats.MaxLagMs, stats.LagMs)
+	}
+
+	pressured := lag >= relayPressureLag || pending >= relayPressurePending
+	switch {
+	case pressured && (!stats.Throttled || now.Sub(stats.throttleSentAt) >= relayThrottleTTL/2):
+		stats.Throttled = true
+		stats.throttleSentAt = now
+		return protocol.ThrottleReduced
+	case stats.Throttled && !pressured && lag < relayRelievedLag && pending < relayRelievedPending:
+		stats.Throttled = false
+		return protocol.ThrottleOff
+	}
+	return ""
+}
+
+// forget drops the stats of a team whose relay stopped.
+func (r *relayLagTracker) forget(teamID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.teams, teamID)
+}
+
+// snapshot returns the stats of every relayed team, most lagging first.
+func (r *relayLagTracker) snapshot() []RelayLagStats {
+	r.mu.Lock()
+	list := make([]RelayLagStats, 0, len(r.teams))
+	for _, stats := range r.teams {
+		list = append(list, *stats)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].LagMs != list[j].LagMs {
+			return list[i].LagMs > list[j].LagMs
+		}
+		return list[i].TeamName < list[j].TeamName
+	})
+	return list
+}
+
+// sendThrottle sends a throttle command to the leader of the team, on the
+// relay's own connection. It goes on the team's control channel, so it is
+// not held behind the user messages queued in the leader's inbox.
+func (s *Server) sendThrottle(nc *nats.Conn, sanitized, teamName, level string) {
+	msg, err := protocol.NewMessage("relay", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{
+		Command: protocol.CommandThrottle,
+		Args: map[string]string{
+			"level":       level,
+			"ttl_seconds": strconv.Itoa(int(relayThrottleTTL.Seconds())),
+		},
+	})
+	if err != nil {
+		slog.Error("relay: failed to build throttle command", "team", teamName, "error", err)
+		return
+	}
+	if err := publishControl(nc, sanitized, msg); err != nil {
+		slog.Warn("relay: failed to send throttle command", "team", teamName, "level", level, "error", err)
+		return
+	}
+	slog.Info("relay: sent throttle command", "team", teamName, "level", level)
+}
+
+// GetRelayLag returns how far behind each team relayed by this replica is
+// (admin only).
+func (s *Server) GetRelayLag(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view relay lag")
+	}
+	return c.JSON(fiber.Map{"relays": s.relayLags.snapshot()})
+}
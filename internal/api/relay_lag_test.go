@@ -0,0 +1,79 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestRelayLagTracker(t *testing.T) {
+	var lags relayLagTracker
+	lags.received("t1", "alpha")
+	lags.received("t1", "alpha")
+	lags.received("t2", "beta")
+	lags.committed("t1", time.Now().Add(-2*time.Second), 5)
+	lags.committed("t2", time.Now().Add(-100*time.Millisecond), 0)
+
+	list := lags.snapshot()
+	if len(list) != 2 || list[0].TeamID != "t1" {
+		t.Fatalf("snapshot: got %+v", list)
+	}
+	alpha := list[0]
+	if alpha.Relayed != 1 || alpha.InFlight != 1 || alpha.Pending != 5 || alpha.LagMs < 2000 || alpha.MaxLagMs != alpha.LagMs || alpha.LastRelayedAt == nil {
+		t.Errorf("alpha: got %+v", alpha)
+	}
+
+	// A write committed after its relay stopped is not counted.
+	lags.forget("t1")
+	lags.committed("t1", time.Now(), 0)
+	if list := lags.snapshot(); len(list) != 1 || list[0].TeamID != "t2" {
+		t.Errorf("after forget: got %+v", list)
+	}
+}
+
+func TestGetRelayLag(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.relayLags.received("t1", "alpha")
+
+	rec := doRequest(srv, "GET", "/api/admin/relays", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200", rec.Code)
+	}
+	var resp struct {
+		Relays []RelayLagStats `json:"relays"`
+	}
+	parseJSON(t, rec, &resp)
+	if len(resp.Relays) != 1 || resp.Relays[0].TeamName != "alpha" || resp.Relays[0].InFlight != 1 {
+		t.Errorf("relays: got %+v", resp.Relays)
+	}
+}
+
+func TestRelayLagTracker_Throttle(t *testing.T) {
+	var lags relayLagTracker
+	commit := func(lag time.Duration, pending uint64) string {
+		lags.received("t1", "alpha")
+		return lags.committed("t1", time.Now().Add(-lag), pending)
+	}
+
+	if got := commit(100*time.Millisecond, 10); got != "" {
+		t.Errorf("no pressure: got %q", got)
+	}
+	if got := commit(10*time.Millisecond, relayPressurePending); got != protocol.ThrottleReduced {
+		t.Errorf("pending backlog: got %q, want reduced", got)
+	}
+	// Not resent while the pressure lasts, until it is due for renewal.
+	if got := commit(relayPressureLag, 0); got != "" {
+		t.Errorf("still under pressure: got %q, want nothing", got)
+	}
+	// Between the thresholds the throttle stays on.
+	if got := commit(2*time.Second, 0); got != "" {
+		t.Errorf("draining: got %q, want nothing", got)
+	}
+	if list := lags.snapshot(); !list[0].Throttled {
+		t.Error("stats should report the team throttled")
+	}
+	if got := commit(10*time.Millisecond, 0); got != protocol.ThrottleOff {
+		t.Errorf("relieved: got %q, want off", got)
+	}
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"log/slog"
+
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// loadRelayState returns the team's shared relay state, which is empty
+// when the team's relay has not reported any.
+func (s *Server) loadRelayState(teamID string) models.RelayState {
+	var state models.RelayState
+	s.db.Where("team_id = ?", teamID).Limit(1).Find(&state)
+	return state
+}
+
+// saveRelayState stores the team's shared relay state, updating only the
+// given columns if it exists.
+func (s *Server) saveRelayState(state models.RelayState, columns ...string) {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns(append(columns, "updated_at")),
+	}).Create(&state).Error
+	if err != nil {
+		slog.Error("failed to save relay state", "team_id", state.TeamID, "error", err)
+	}
+}
+
+// resetRelayState clears the team's shared relay state.
+func (s *Server) resetRelayState(teamID string) {
+	if err := s.db.Where("team_id = ?", teamID).Delete(&models.RelayState{}).Error; err != nil {
+		slog.Error("failed to reset relay state", "team_id", teamID, "error", err)
+	}
+}
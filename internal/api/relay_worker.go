@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// relayLeaseTTL is how long a relay worker keeps a team, and counts as
+// alive, without renewing its leases.
+var relayLeaseTTL = election.DefaultTTL
+
+// Lease name prefixes: one lease per live relay worker, and one per team
+// relayed by a worker.
+const (
+	relayWorkerLeasePrefix = "relay-worker/"
+	relayTeamLeasePrefix   = "relay/"
+)
+
+// SetRelayWorkers leaves relaying team messages to relay workers (see
+// RelayWorker) instead of the owning replica. Leader readiness and run
+// queues are then read from the database, where the workers keep them.
+// Call it before AcquireOwnership.
+func (s *Server) SetRelayWorkers() {
+	s.externalRelays = true
+	s.sharedRelayState = true
+}
+
+// RelayWorker relays the messages of running teams into the database in a
+// process of its own, so that busy teams do not slow down API requests.
+// Workers share the teams through leases in the database: each takes its
+// fair share of the running teams, and the teams of a worker that stops
+// renewing its leases are taken over by the others.
+type RelayWorker struct {
+	srv    *Server
+	holder string
+	// teams maps the IDs of the teams this worker holds to their names.
+	teams map[string]string
+}
+
+// NewRelayWorker returns a relay worker holding its leases as holder, which
+// must be unique across workers.
+func NewRelayWorker(db *gorm.DB, rt runtime.AgentRuntime, holder string) *RelayWorker {
+	srv := NewServer(db, rt, nil)
+	srv.sharedRelayState = true
+	return &RelayWorker{srv: srv, holder: holder, teams: make(map[string]string)}
+}
+
+// SetGovernor sets the rate limiter applied to the chat messages the worker
+// delivers once a leader is ready.
+func (w *RelayWorker) SetGovernor(g *ratelimit.Governor) {
+	w.srv.SetGovernor(g)
+}
+
+// Run relays teams until ctx is cancelled. It then stops its relays and
+// releases its teams, so that the other workers take them over at once.
+func (w *RelayWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(relayLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		w.sync(time.Now().UTC())
+		select {
+		case <-ctx.Done():
+			w.srv.stop()
+			w.releaseAll()
+			w.srv.taskLogs.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sync renews the worker's leases and brings the teams it relays in line
+// with its share of the running teams.
+func (w *RelayWorker) sync(now time.Time) {
+	db := w.srv.db
+	if _, err := election.Acquire(db, relayWorkerLeasePrefix+w.holder, w.holder, relayLeaseTTL, now); err != nil {
+		slog.Warn("relay worker: failed to renew worker lease", "error", err)
+	}
+	workers, err := election.Active(db, relayWorkerLeasePrefix, now)
+	if err != nil || workers < 1 {
+		workers = 1
+	}
+
+	var teams []models.Team
+	if err := db.Select("id", "name", "status").Where("status != ?", models.TeamStatusStopped).Order("id").Find(&teams).Error; err != nil {
+		slog.Error("relay worker: failed to query teams", "error", err)
+		return
+	}
+	active := make(map[string]bool, len(teams))
+	var running []models.Team
+	for _, team := range teams {
+		active[team.ID] = true
+		if team.Status == models.TeamStatusRunning {
+			running = append(running, team)
+		}
+	}
+
+	// Renew the teams this worker holds. Teams that were stopped or
+	// deleted are let go, and so are teams another worker took over after
+	// a renewal came too late. A leader restart keeps the relay while the
+	// team is deploying.
+	for teamID := range w.teams {
+		if active[teamID] {
+			ok, err := election.Acquire(db, relayTeamLeasePrefix+teamID, w.holder, relayLeaseTTL, now)
+			if err != nil {
+				slog.Warn("relay worker: failed to renew team lease", "id", teamID, "error", err)
+				continue
+			}
+			if ok {
+				continue
+			}
+			slog.Warn("relay worker: team taken over by another worker", "id", teamID)
+			w.srv.cancelTeamRelay(teamID)
+		} else {
+			slog.Info("relay worker: releasing stopped team", "id", teamID)
+			w.srv.stopTeamRelay(teamID)
+			election.Release(db, relayTeamLeasePrefix+teamID, w.holder)
+		}
+		delete(w.teams, teamID)
+	}
+
+	// Hand back the teams above this worker's share, such as after another
+	// worker joined, and take free teams up to it.
+	share := (len(running) + workers - 1) / workers
+	held := 0
+	for _, team := range running {
+		if _, ok := w.teams[team.ID]; ok {
+			held++
+		}
+	}
+	for i := len(running) - 1; i >= 0 && held > share; i-- {
+		teamID := running[i].ID
+		if _, ok := w.teams[teamID]; !ok {
+			continue
+		}
+		slog.Info("relay worker: handing team over", "team", running[i].Name, "id", teamID)
+		w.srv.cancelTeamRelay(teamID)
+		election.Release(db, relayTeamLeasePrefix+teamID, w.holder)
+		delete(w.teams, teamID)
+		held--
+	}
+	for _, team := range running {
+		if held >= share {
+			break
+		}
+		if _, ok := w.teams[team.ID]; ok {
+			continue
+		}
+		ok, err := election.Acquire(db, relayTeamLeasePrefix+team.ID, w.holder, relayLeaseTTL, now)
+		if err != nil {
+			slog.Warn("relay worker: failed to acquire team lease", "id", team.ID, "error", err)
+			continue
+		}
+		if ok {
+			slog.Info("relay worker: took team", "team", team.Name, "id", team.ID)
+			w.teams[team.ID] = team.Name
+			held++
+		}
+	}
+
+	// Start relays for the teams taken, restart relays that gave up, and
+	// deliver chat messages queued while the leader was busy. The leader's
+	// state is left as the previous worker reported it.
+	for _, team := range running {
+		if _, ok := w.teams[team.ID]; !ok {
+			continue
+		}
+		w.srv.relaysMu.Lock()
+		_, relayed := w.srv.relays[team.ID]
+		w.srv.relaysMu.Unlock()
+
+		switch {
+		case !relayed:
+			w.srv.relayTeam(team.ID, team.Name)
+		case w.srv.isLeaderReady(team.ID) && w.srv.hasQueuedChats(team.ID):
+			go w.srv.flushQueuedChats(team.ID, team.Name)
+		}
+	}
+}
+
+// releaseAll stops the worker's relays and releases its leases.
+func (w *RelayWorker) releaseAll() {
+	w.srv.stopAllRelays()
+	for teamID := range w.teams {
+		election.Release(w.srv.db, relayTeamLeasePrefix+teamID, w.holder)
+		delete(w.teams, teamID)
+	}
+	election.Release(w.srv.db, relayWorkerLeasePrefix+w.holder, w.holder)
+	slog.Info("relay worker: released all teams", "holder", w.holder)
+}
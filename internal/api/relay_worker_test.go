@@ -0,0 +1,143 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// setupRelayWorkers returns relay workers sharing a database file, so that
+// every pooled connection sees the same leases.
+func setupRelayWorkers(t *testing.T, holders ...string) (*gorm.DB, []*RelayWorker) {
+	t.Helper()
+	db, err := models.InitDB(filepath.Join(t.TempDir(), "relay.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	var workers []*RelayWorker
+	for _, holder := range holders {
+		w := NewRelayWorker(db, &mockRuntime{}, holder)
+		t.Cleanup(w.releaseAll)
+		workers = append(workers, w)
+	}
+	return db, workers
+}
+
+func createTeams(t *testing.T, db *gorm.DB, status string, ids ...string) {
+	t.Helper()
+	for _, id := range ids {
+		if err := db.Create(&models.Team{ID: id, Name: id, Status: status}).Error; err != nil {
+			t.Fatalf("create team %s: %v", id, err)
+		}
+	}
+}
+
+func TestRelayWorkers_ShareRunningTeams(t *testing.T) {
+	db, workers := setupRelayWorkers(t, "a", "b")
+	a, b := workers[0], workers[1]
+	createTeams(t, db, models.TeamStatusRunning, "t1", "t2", "t3", "t4")
+	createTeams(t, db, models.TeamStatusStopped, "t5")
+	now := time.Now().UTC()
+
+	a.sync(now)
+	if len(a.teams) != 4 {
+		t.Fatalf("a alone: got %d teams, want 4", len(a.teams))
+	}
+
+	// b joins: a hands half of the teams back and b takes them.
+	b.sync(now)
+	a.sync(now)
+	b.sync(now)
+	if len(a.teams) != 2 || len(b.teams) != 2 {
+		t.Fatalf("shared: a has %d teams, b has %d, want 2 each", len(a.teams), len(b.teams))
+	}
+	for id := range a.teams {
+		if _, ok := b.teams[id]; ok {
+			t.Errorf("team %s held by both workers", id)
+		}
+	}
+	if _, ok := a.teams["t5"]; ok {
+		t.Error("a holds a stopped team")
+	}
+}
+
+func TestRelayWorkers_TakeOverFromStoppedWorker(t *testing.T) {
+	db, workers := setupRelayWorkers(t, "a", "b")
+	a, b := workers[0], workers[1]
+	createTeams(t, db, models.TeamStatusRunning, "t1", "t2")
+	now := time.Now().UTC()
+
+	a.sync(now)
+	b.sync(now)
+	if len(b.teams) != 0 {
+		t.Fatalf("b took %d teams held by a", len(b.teams))
+	}
+
+	// a stops renewing; once its leases expire, b takes every team.
+	later := now.Add(2 * relayLeaseTTL)
+	b.sync(later)
+	if len(b.teams) != 2 {
+		t.Fatalf("b after a expired: got %d teams, want 2", len(b.teams))
+	}
+
+	// a comes back and finds its teams taken.
+	a.sync(later.Add(time.Second))
+	if len(a.teams) != 0 {
+		t.Errorf("a kept %d teams taken over by b", len(a.teams))
+	}
+}
+
+func TestRelayWorker_ReleasesStoppedTeams(t *testing.T) {
+	db, workers := setupRelayWorkers(t, "a")
+	a := workers[0]
+	createTeams(t, db, models.TeamStatusRunning, "t1")
+	now := time.Now().UTC()
+
+	a.sync(now)
+	if _, ok := a.teams["t1"]; !ok {
+		t.Fatal("a did not take t1")
+	}
+
+	db.Model(&models.Team{}).Where("id = ?", "t1").Update("status", models.TeamStatusStopped)
+	a.sync(now.Add(time.Second))
+	if _, ok := a.teams["t1"]; ok {
+		t.Error("a kept a stopped team")
+	}
+	if ok, err := election.Acquire(db, relayTeamLeasePrefix+"t1", "b", relayLeaseTTL, now.Add(time.Second)); err != nil || !ok {
+		t.Errorf("lease of stopped team not released: got %t, %v", ok, err)
+	}
+}
+
+func TestSharedRelayState_SeenByAPI(t *testing.T) {
+	db, workers := setupRelayWorkers(t, "a")
+	worker := workers[0].srv
+	createTeams(t, db, models.TeamStatusRunning, "t1")
+
+	srv := NewServer(db, &mockRuntime{}, nil)
+	srv.SetRelayWorkers()
+
+	worker.markLeaderReady("t1", "t1")
+	worker.setRunQueue("t1", protocol.RunQueuePayload{Waiting: []string{"m1", "m2"}})
+	if !srv.isLeaderReady("t1") {
+		t.Error("API does not see the leader ready")
+	}
+	if got := srv.runQueueWaiting("t1"); len(got) != 2 || got[0] != "m1" {
+		t.Errorf("API run queue: got %v, want [m1 m2]", got)
+	}
+
+	// A deployment through the API forgets the previous leader, without
+	// starting a relay of its own.
+	srv.startTeamRelay("t1", "t1")
+	if hasRelay(srv, "t1") {
+		t.Error("API started a relay with relay workers")
+	}
+	if srv.isLeaderReady("t1") || len(srv.runQueueWaiting("t1")) != 0 {
+		t.Error("relay state kept across a deployment")
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// defaultReplayTimeout bounds replays of schedule runs, which have no
+// timeout of their own; webhook runs are replayed with the webhook's.
+const defaultReplayTimeout = time.Hour
+
+// findReplaySource loads the schedule or webhook run with the given ID, if
+// it belongs to the caller's organization. The second result is how long a
+// replay of the run may take.
+func (s *Server) findReplaySource(c *fiber.Ctx, runID string) (ReplayRunSummary, time.Duration, error) {
+	var scheduleRun models.ScheduleRun
+	if err := s.db.First(&scheduleRun, "id = ?", runID).Error; err == nil {
+		var schedule models.Schedule
+		if err := s.db.Scopes(OrgScope(c)).Select("id", "team_id").First(&schedule, "id = ?", scheduleRun.ScheduleID).Error; err == nil {
+			return ReplayRunSummary{
+				RunID:        scheduleRun.ID,
+				Kind:         sharedRunSchedule,
+				TeamID:       schedule.TeamID,
+				TeamRevision: scheduleRun.TeamRevision,
+				Prompt:       scheduleRun.PromptSent,
+				Status:       scheduleRun.Status,
+				Response:     scheduleRun.ResponseReceived,
+				Error:        scheduleRun.Error,
+				StartedAt:    scheduleRun.StartedAt,
+				FinishedAt:   scheduleRun.FinishedAt,
+			}, defaultReplayTimeout, nil
+		}
+	}
+
+	var webhookRun models.WebhookRun
+	if err := s.db.First(&webhookRun, "id = ?", runID).Error; err == nil {
+		var webhook models.Webhook
+		if err := s.db.Scopes(OrgScope(c)).Select("id", "team_id", "timeout_seconds").First(&webhook, "id = ?", webhookRun.WebhookID).Error; err == nil {
+			return ReplayRunSummary{
+				RunID:        webhookRun.ID,
+				Kind:         sharedRunWebhook,
+				TeamID:       webhook.TeamID,
+				TeamRevision: webhookRun.TeamRevision,
+				Prompt:       webhookRun.PromptSent,
+				Status:       webhookRun.Status,
+				Response:     webhookRun.ResponseReceived,
+				Error:        webhookRun.Error,
+				StartedAt:    webhookRun.StartedAt,
+				FinishedAt:   webhookRun.FinishedAt,
+			}, time.Duration(webhook.TimeoutSeconds) * time.Second, nil
+		}
+	}
+
+	return ReplayRunSummary{}, 0, fiber.NewError(fiber.StatusNotFound, "run not found")
+}
+
+// usageCost returns the cost of the usage a team recorded between from and
+// to. Runs of a team do not overlap, so this is the cost of the run that
+// spanned the interval, unless chat messages were answered meanwhile.
+func (s *Server) usageCost(teamID string, from, to time.Time) float64 {
+	var cost float64
+	s.db.Model(&models.UsageRecord{}).
+		Where("team_id = ? AND created_at >= ? AND created_at <= ?", teamID, from.UTC(), to.UTC()).
+		Select("COALESCE(SUM(cost_usd), 0)").Scan(&cost)
+	return cost
+}
+
+// ReplayRun handles POST /api/runs/:id/replay?team_config_rev=N. It sends
+// the prompt of a schedule or webhook run to its team again, so the result,
+// duration and cost can be compared with a team configuration deployed
+// since. team_config_rev, when set, must be the revision the team is
+// running. The replay continues in the background.
+func (s *Server) ReplayRun(c *fiber.Ctx) error {
+	source, timeout, err := s.findReplaySource(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if source.Prompt == "" {
+		return fiber.NewError(fiber.StatusConflict, "run has no prompt to replay")
+	}
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", source.TeamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	if v := c.Query("team_config_rev"); v != "" {
+		rev, err := strconv.Atoi(v)
+		if err != nil || rev < 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "team_config_rev must be a positive integer")
+		}
+		var count int64
+		s.db.Model(&models.TeamRevision{}).Where("team_id = ? AND revision = ?", team.ID, rev).Count(&count)
+		if count == 0 {
+			return fiber.NewError(fiber.StatusNotFound, "team config revision not found")
+		}
+		if team.ConfigRevision != rev {
+			return fiber.NewError(fiber.StatusConflict,
+				fmt.Sprintf("team is deployed from config revision %d; deploy revision %d to replay against it", team.ConfigRevision, rev))
+		}
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+
+	replay := models.RunReplay{
+		ID:           uuid.New().String(),
+		OrgID:        team.OrgID,
+		TeamID:       team.ID,
+		SourceKind:   source.Kind,
+		SourceRunID:  source.RunID,
+		TeamRevision: team.ConfigRevision,
+		Prompt:       source.Prompt,
+		Status:       models.RunReplayStatusRunning,
+		CreatedBy:    GetUserID(c),
+		StartedAt:    time.Now().UTC(),
+	}
+	if err := s.db.Create(&replay).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create replay")
+	}
+
+	slog.Info("run replay started", "id", replay.ID, "source_run_id", source.RunID,
+		"team", team.Name, "revision", replay.TeamRevision)
+	go s.executeReplay(replay, team, timeout)
+
+	return c.Status(fiber.StatusAccepted).JSON(replay)
+}
+
+// executeReplay sends the replayed prompt and records the outcome.
+func (s *Server) executeReplay(replay models.RunReplay, team models.Team, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response, err := s.promptLeader(ctx, team, replay.Prompt, replay.ID)
+
+	finished := time.Now().UTC()
+	updates := map[string]interface{}{
+		"finished_at": finished,
+		"duration_ms": finished.Sub(replay.StartedAt).Milliseconds(),
+		"cost_usd":    s.usageCost(team.ID, replay.StartedAt, finished),
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		updates["status"] = models.RunReplayStatusTimeout
+		updates["error"] = fmt.Sprintf("execution timed out after %s", timeout)
+	case err != nil:
+		updates["status"] = models.RunReplayStatusFailed
+		updates["error"] = err.Error()
+	case strings.HasPrefix(response, leaderErrorPrefix):
+		updates["status"] = models.RunReplayStatusFailed
+		updates["error"] = strings.TrimPrefix(response, leaderErrorPrefix)
+	default:
+		updates["status"] = models.RunReplayStatusSuccess
+		updates["response"] = response
+	}
+	if err := s.db.Model(&models.RunReplay{}).Where("id = ?", replay.ID).Updates(updates).Error; err != nil {
+		slog.Error("replay: failed to save result", "id", replay.ID, "error", err)
+	}
+	slog.Info("run replay finished", "id", replay.ID, "status", updates["status"])
+}
+
+// ListRunReplays handles GET /api/runs/:id/replays. It returns a schedule
+// or webhook run next to its replays, for comparing their results,
+// durations and costs.
+func (s *Server) ListRunReplays(c *fiber.Ctx) error {
+	source, _, err := s.findReplaySource(c, c.Params("id"))
+	if err != nil {
+		return err
+	}
+	if source.FinishedAt != nil {
+		source.DurationMs = source.FinishedAt.Sub(source.StartedAt).Milliseconds()
+		source.CostUSD = s.usageCost(source.TeamID, source.StartedAt, *source.FinishedAt)
+	}
+
+	replays := []models.RunReplay{}
+	if err := s.db.Where("source_run_id = ?", source.RunID).
+		Order("created_at DESC").Find(&replays).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list replays")
+	}
+	return c.JSON(RunReplaysResponse{Original: source, Replays: replays})
+}
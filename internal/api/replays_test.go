@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestReplayRun(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "replay-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader", InstructionsMD: "v1"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create team: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	// The first deploy records revision 1; the run below ran on it.
+	srv.deployTeamAsync(t.Context(), team)
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.Status != models.TeamStatusRunning || team.ConfigRevision != 1 {
+		t.Fatalf("deployed team: status %q, config_revision %d", team.Status, team.ConfigRevision)
+	}
+
+	rec = doRequest(srv, "POST", "/api/schedules", CreateScheduleRequest{
+		Name: "nightly", TeamID: team.ID, Prompt: "summarize", CronExpression: "0 * * * *",
+	})
+	var schedule models.Schedule
+	parseJSON(t, rec, &schedule)
+	started := time.Now().UTC().Add(-2 * time.Minute)
+	finished := started.Add(time.Minute)
+	srv.db.Create(&models.ScheduleRun{
+		ID: "run-1", ScheduleID: schedule.ID, Status: "success", StartedAt: started, FinishedAt: &finished,
+		PromptSent: "summarize the day", ResponseReceived: "All green.", TeamRevision: 1,
+	})
+	srv.db.Create(&models.UsageRecord{ID: "usage-1", TeamID: team.ID, CostUSD: 0.25, CreatedAt: started.Add(30 * time.Second)})
+
+	// Change the leader's instructions and redeploy: revision 2.
+	var leader models.Agent
+	srv.db.First(&leader, "team_id = ?", team.ID)
+	srv.db.Model(&leader).Update("instructions_md", "v2")
+	srv.deployTeamAsync(t.Context(), team)
+	srv.db.First(&team, "id = ?", team.ID)
+	if team.ConfigRevision != 2 {
+		t.Fatalf("redeployed team: config_revision %d, want 2", team.ConfigRevision)
+	}
+
+	prompts := make(chan string, 1)
+	srv.promptLeader = func(_ context.Context, _ models.Team, prompt, _ string) (string, error) {
+		prompts <- prompt
+		return "Two warnings.", nil
+	}
+
+	if rec := doRequest(srv, "POST", "/api/runs/missing/replay", nil); rec.Code != 404 {
+		t.Errorf("unknown run: got %d, want 404", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/runs/run-1/replay?team_config_rev=x", nil); rec.Code != 400 {
+		t.Errorf("invalid revision: got %d, want 400", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/runs/run-1/replay?team_config_rev=7", nil); rec.Code != 404 {
+		t.Errorf("unknown revision: got %d, want 404", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/runs/run-1/replay?team_config_rev=1", nil); rec.Code != 409 {
+		t.Errorf("revision not deployed: got %d, want 409", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/runs/run-1/replay?team_config_rev=2", nil)
+	if rec.Code != 202 {
+		t.Fatalf("replay: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var replay models.RunReplay
+	parseJSON(t, rec, &replay)
+	if replay.SourceRunID != "run-1" || replay.SourceKind != sharedRunSchedule || replay.TeamRevision != 2 {
+		t.Errorf("replay: got %+v", replay)
+	}
+	select {
+	case p := <-prompts:
+		if p != "summarize the day" {
+			t.Errorf("replayed prompt: got %q", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("prompt was not replayed")
+	}
+
+	var resp RunReplaysResponse
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		parseJSON(t, doRequest(srv, "GET", "/api/runs/run-1/replays", nil), &resp)
+		if len(resp.Replays) == 1 && resp.Replays[0].Status != models.RunReplayStatusRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if resp.Original.TeamRevision != 1 || resp.Original.DurationMs != time.Minute.Milliseconds() || resp.Original.CostUSD != 0.25 {
+		t.Errorf("original: got %+v", resp.Original)
+	}
+	if len(resp.Replays) != 1 || resp.Replays[0].Status != models.RunReplayStatusSuccess ||
+		resp.Replays[0].Response != "Two warnings." || resp.Replays[0].FinishedAt == nil {
+		t.Errorf("replays: got %+v", resp.Replays)
+	}
+
+	srv.db.Model(&team).Update("status", models.TeamStatusStopped)
+	if rec := doRequest(srv, "POST", "/api/runs/run-1/replay", nil); rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+}
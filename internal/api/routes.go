@@ -9,9 +9,15 @@ func (s *Server) registerRoutes() {
 	// Health check (public).
 	s.App.Get("/health", s.HealthCheck)
 
+	// Web dashboard (public, read-only files).
+	s.registerUI()
+
 	// Webhook trigger (public, token-authenticated).
 	s.App.Post("/webhook/trigger/:token", s.TriggerWebhook)
 
+	// Issue tracker comment webhooks (public, token-authenticated).
+	s.App.Post("/integrations/issues/:token", s.ReceiveIssueEvent)
+
 	api := s.App.Group("/api")
 
 	// Auth (public endpoints — no JWT required).
@@ -23,8 +29,18 @@ func (s *Server) registerRoutes() {
 	authGroup.Post("/refresh", s.RefreshToken)
 	authGroup.Get("/invite/:token", s.GetInviteInfo)
 
+	// Shared run transcripts (public, token-authenticated).
+	api.Get("/shared/:token", s.GetSharedRun)
+
+	// Error code catalog (public).
+	api.Get("/errors", s.ListErrorCodes)
+
+	// Protocol message schemas (public).
+	api.Get("/protocol/types", s.ListProtocolTypes)
+
 	// --- All routes below require authentication ---
-	api.Use(authMiddleware(s.authProvider))
+	api.Use(authMiddleware(s.authProvider, s.db))
+	api.Use(observerGuard())
 
 	// Auth (authenticated endpoints).
 	authGroup.Get("/me", s.GetMe)
@@ -35,13 +51,44 @@ func (s *Server) registerRoutes() {
 	teams := api.Group("/teams")
 	teams.Get("/", s.ListTeams)
 	teams.Post("/", s.CreateTeam)
+	teams.Post("/validate", s.ValidateTeam)
 	teams.Get("/:id", s.GetTeam)
 	teams.Put("/:id", s.UpdateTeam)
 	teams.Delete("/:id", s.DeleteTeam)
 
 	// Team lifecycle.
 	teams.Post("/:id/deploy", s.DeployTeam)
+	teams.Get("/:id/deploy/logs", s.StreamDeployLogs)
+	teams.Get("/:id/deployments", s.ListTeamDeployments)
 	teams.Post("/:id/stop", s.StopTeam)
+	teams.Post("/:id/interrupt", s.InterruptTeam)
+	teams.Post("/:id/migrate", s.MigrateTeam)
+	teams.Get("/:id/nats/streams", s.ListTeamStreams)
+	teams.Post("/:id/nats/streams/repair", s.RepairTeamStream)
+	teams.Post("/:id/nats/streams/:stream/purge", s.PurgeTeamStream)
+	teams.Post("/:id/runs/:runId/approve-plan", s.ApprovePlan)
+	teams.Get("/:id/runs/:runId/raw", s.GetRunTranscript)
+	teams.Get("/:id/quota", s.GetTeamQuota)
+	teams.Get("/:id/patches", s.ListPatches)
+	teams.Get("/:id/patches/:patchId", s.GetPatch)
+	teams.Post("/:id/patches/:patchId/apply", s.ApplyPatch)
+	teams.Post("/:id/patches/:patchId/reject", s.RejectPatch)
+	teams.Delete("/:id/workspace", s.DeleteTeamWorkspace)
+	teams.Post("/:id/workspace/push", s.PushTeamWorkspace)
+	teams.Post("/:id/workspace/pull", s.PullTeamWorkspace)
+	teams.Get("/:id/memories", s.ListTeamMemories)
+	teams.Delete("/:id/memories", s.DeleteTeamMemories)
+	teams.Get("/:id/knowledge", s.ListKnowledgeEntries)
+	teams.Delete("/:id/knowledge/:entryId", s.DeleteKnowledgeEntry)
+
+	// Evaluations.
+	teams.Get("/:id/evaluations", s.ListEvaluations)
+	teams.Post("/:id/evaluations", s.CreateEvaluation)
+	teams.Post("/:id/evaluations/run", s.RunEvaluations)
+	teams.Get("/:id/evaluations/runs", s.ListEvaluationRuns)
+	teams.Get("/:id/evaluations/runs/:runId", s.GetEvaluationRun)
+	teams.Put("/:id/evaluations/:evalId", s.UpdateEvaluation)
+	teams.Delete("/:id/evaluations/:evalId", s.DeleteEvaluation)
 
 	// Agents (nested under teams).
 	teams.Get("/:id/agents", s.ListAgents)
@@ -49,9 +96,16 @@ func (s *Server) registerRoutes() {
 	teams.Get("/:id/agents/:agentId", s.GetAgent)
 	teams.Put("/:id/agents/:agentId", s.UpdateAgent)
 	teams.Delete("/:id/agents/:agentId", s.DeleteAgent)
+	teams.Post("/:id/agents/:agentId/restart", s.RestartAgent)
 	teams.Get("/:id/agents/:agentId/instructions", s.GetInstructions)
 	teams.Put("/:id/agents/:agentId/instructions", s.UpdateInstructions)
+	teams.Post("/:id/agents/:agentId/preview-claude-md", s.PreviewInstructions)
+	teams.Post("/:id/agents/:agentId/regenerate-files", s.RegenerateAgentFiles)
 	teams.Post("/:id/agents/:agentId/skills/install", s.InstallAgentSkill)
+	teams.Get("/:id/agents/:agentId/provenance", s.GetAgentProvenance)
+	api.Post("/agents/import", s.ImportAgent)
+	api.Post("/agents/preview-chat", s.PreviewChat)
+	api.Delete("/agents/preview-chat/:id", s.EndPreviewChat)
 
 	// MCP server management (team-level).
 	teams.Get("/:id/mcp", s.GetMcpConfig)
@@ -63,6 +117,9 @@ func (s *Server) registerRoutes() {
 	teams.Post("/:id/chat", s.SendChat)
 	teams.Get("/:id/messages", s.GetMessages)
 	teams.Get("/:id/activity", s.GetActivity)
+	teams.Get("/:id/timeline", s.GetTimeline)
+	teams.Get("/:id/conversations", s.ListConversations)
+	teams.Get("/:id/conversations/:cid/export", s.ExportConversation)
 
 	// Schedules.
 	schedules := api.Group("/schedules")
@@ -92,6 +149,55 @@ func (s *Server) registerRoutes() {
 	webhooks.Get("/:id/post-actions", s.GetWebhookPostActions)
 	schedules.Get("/:id/post-actions", s.GetSchedulePostActions)
 
+	// Shareable links to schedule and webhook runs.
+	api.Post("/runs/:id/share", s.ShareRun)
+	api.Delete("/runs/:id/share", s.RevokeRunShares)
+
+	// Replays of schedule and webhook runs.
+	api.Post("/runs/:id/replay", s.ReplayRun)
+	api.Get("/runs/:id/replays", s.ListRunReplays)
+
+	// Background jobs.
+	api.Get("/jobs", s.ListJobs)
+	api.Get("/jobs/:id", s.GetJob)
+
+	// Approval requests.
+	approvals := api.Group("/approvals")
+	approvals.Get("/", s.ListApprovals)
+	approvals.Get("/:id", s.GetApproval)
+	approvals.Post("/:id/approve", s.ApproveApproval)
+	approvals.Post("/:id/reject", s.RejectApproval)
+
+	// Reports.
+	api.Get("/reports/cost", s.GetCostReport)
+	api.Get("/rate-limit", s.GetRateLimitStatus)
+
+	// Prompt templates.
+	promptTemplates := api.Group("/prompt-templates")
+	promptTemplates.Get("/", s.ListPromptTemplates)
+	promptTemplates.Post("/", s.CreatePromptTemplate)
+	promptTemplates.Get("/:id", s.GetPromptTemplate)
+	promptTemplates.Put("/:id", s.UpdatePromptTemplate)
+	promptTemplates.Delete("/:id", s.DeletePromptTemplate)
+
+	// Issue tracker integrations.
+	issueIntegrations := api.Group("/issue-integrations")
+	issueIntegrations.Get("/", s.ListIssueIntegrations)
+	issueIntegrations.Post("/", s.CreateIssueIntegration)
+	issueIntegrations.Get("/:id", s.GetIssueIntegration)
+	issueIntegrations.Put("/:id", s.UpdateIssueIntegration)
+	issueIntegrations.Delete("/:id", s.DeleteIssueIntegration)
+	issueIntegrations.Post("/:id/regenerate", s.RegenerateIssueIntegrationToken)
+
+	// Alert integrations.
+	alertIntegrations := api.Group("/alert-integrations")
+	alertIntegrations.Get("/", s.ListAlertIntegrations)
+	alertIntegrations.Post("/", s.CreateAlertIntegration)
+	alertIntegrations.Get("/:id", s.GetAlertIntegration)
+	alertIntegrations.Put("/:id", s.UpdateAlertIntegration)
+	alertIntegrations.Delete("/:id", s.DeleteAlertIntegration)
+	alertIntegrations.Get("/:id/alerts", s.ListTeamAlerts)
+
 	// Post-Actions.
 	postActions := api.Group("/post-actions")
 	postActions.Get("/", s.ListPostActions)
@@ -104,14 +210,65 @@ func (s *Server) registerRoutes() {
 	postActions.Delete("/:id/bindings/:bid", s.DeleteBinding)
 	postActions.Get("/:id/runs", s.ListPostActionRuns)
 
+	// Custom tools exposed to agents.
+	customTools := api.Group("/tools")
+	customTools.Get("/", s.ListCustomTools)
+	customTools.Post("/", s.CreateCustomTool)
+	customTools.Get("/:id", s.GetCustomTool)
+	customTools.Put("/:id", s.UpdateCustomTool)
+	customTools.Delete("/:id", s.DeleteCustomTool)
+
+	// Content policies applied to chat messages and leader responses.
+	contentPolicies := api.Group("/content-policies")
+	contentPolicies.Get("/", s.ListContentPolicies)
+	contentPolicies.Post("/", s.CreateContentPolicy)
+	contentPolicies.Get("/violations", s.ListPolicyViolations)
+	contentPolicies.Get("/:id", s.GetContentPolicy)
+	contentPolicies.Put("/:id", s.UpdateContentPolicy)
+	contentPolicies.Delete("/:id", s.DeleteContentPolicy)
+
+	// Skills catalog searched by the Team Builder.
+	skillsCatalog := api.Group("/skills/catalog")
+	skillsCatalog.Get("/", s.ListSkillCatalog)
+	skillsCatalog.Post("/", s.CreateSkillCatalogEntry)
+	skillsCatalog.Delete("/:id", s.DeleteSkillCatalogEntry)
+	api.Post("/skills/permissions", s.SuggestSkillPermissions)
+
+	// Workspace templates teams can be scaffolded from.
+	api.Get("/workspace-templates", s.ListWorkspaceTemplates)
+
 	// Ollama (infrastructure-level, no team context needed).
 	api.Get("/ollama/status", s.GetOllamaStatus)
 
+	// Resource presets.
+	api.Get("/resource-presets", s.GetResourcePresets)
+	api.Put("/resource-presets", s.UpdateResourcePresets)
+
 	// Settings.
 	api.Get("/settings", s.GetSettings)
 	api.Put("/settings", s.UpdateSettings)
+	api.Post("/settings/test", s.CheckSettings)
 	api.Delete("/settings/:key", s.DeleteSetting)
 
+	// Administration.
+	admin := api.Group("/admin")
+	admin.Get("/db", s.GetDBStats)
+	admin.Get("/events", s.GetEventCounts)
+	admin.Get("/relays", s.GetRelayLag)
+	admin.Get("/dead-letters", s.ListDeadLetters)
+	admin.Post("/dead-letters/:id/retry", s.RetryDeadLetter)
+	admin.Get("/agent-versions", s.GetAgentVersions)
+	admin.Get("/infra-gc", s.GetInfraGCReport)
+	admin.Get("/fleet", s.GetFleetOverview)
+	admin.Get("/image-prepull", s.GetImagePrePullReport)
+	admin.Post("/upgrade-agents", s.UpgradeAgents)
+	admin.Get("/upgrade-agents", s.ListAgentUpgrades)
+	admin.Get("/upgrade-agents/:id", s.GetAgentUpgrade)
+	admin.Post("/upgrade-agents/:id/rollback", s.RollbackAgentUpgrade)
+	admin.Get("/maintenance", s.GetMaintenance)
+	admin.Post("/maintenance", s.SetMaintenance)
+	admin.Post("/kill-switch", s.KillSwitch)
+
 	// Organization management.
 	org := api.Group("/org")
 	org.Get("/", s.GetOrg)
@@ -150,15 +307,19 @@ func (s *Server) registerRoutes() {
 		// For other providers, require token as query param.
 		token := c.Query("token")
 		if token == "" {
-			return fiber.NewError(fiber.StatusUnauthorized, "missing token query parameter")
+			return newAPIError(CodeAuthMissing, "missing token query parameter")
 		}
 		claims, err := s.authProvider.ValidateToken(c.Context(), token)
 		if err != nil {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid or expired token")
+			return newAPIError(CodeAuthInvalid, "invalid or expired token")
+		}
+		role, err := currentRole(s.db, claims)
+		if err != nil {
+			return err
 		}
 		c.Locals("user_id", claims.UserID)
 		c.Locals("org_id", claims.OrgID)
-		c.Locals("role", claims.Role)
+		c.Locals("role", role)
 		return c.Next()
 	})
 	s.App.Get("/ws/teams/:id/logs", websocket.New(s.StreamLogs))
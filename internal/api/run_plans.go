@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// approvePlanMessage is the chat message that tells the leader to carry out
+// its approved plan.
+const approvePlanMessage = "The plan is approved. Carry it out now."
+
+// recordRunPlan saves the plan of a leader_response that awaits approval, so
+// that it can be approved through the API.
+func (s *Server) recordRunPlan(teamID, teamName string, msg protocol.Message) {
+	var payload protocol.LeaderResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil || payload.Plan == nil || payload.Plan.RunID == "" {
+		return
+	}
+	plan := models.RunPlan{
+		ID:     payload.Plan.RunID,
+		TeamID: teamID,
+		Plan:   payload.Plan.Text,
+		Status: models.RunPlanStatusPending,
+	}
+	// A redelivered response must not reopen an approved plan.
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&plan).Error; err != nil {
+		slog.Error("relay: failed to save run plan", "team", teamName, "run_id", plan.ID, "error", err)
+		return
+	}
+	slog.Info("relay: plan awaiting approval", "team", teamName, "run_id", plan.ID)
+}
+
+// ApprovePlan handles POST /api/teams/:id/runs/:runId/approve-plan. It
+// approves the plan the leader returned for a chat run, identified by the
+// ID of the run's user message, and sends the leader a chat message telling
+// it to carry the plan out. The sidecar lifts the read-only restriction for
+// that message only.
+func (s *Server) ApprovePlan(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	var plan models.RunPlan
+	if err := s.db.First(&plan, "id = ? AND team_id = ?", c.Params("runId"), team.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "plan not found")
+	}
+	if plan.Status != models.RunPlanStatusPending {
+		return fiber.NewError(fiber.StatusConflict, "plan is already approved")
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	if !s.governor.TryAcquire() {
+		return fiber.NewError(fiber.StatusTooManyRequests, "prompt rate limit reached; try again shortly")
+	}
+
+	// Claim the plan first, so that concurrent approvals send one message.
+	now := time.Now().UTC()
+	res := s.db.Model(&models.RunPlan{}).
+		Where("id = ? AND status = ?", plan.ID, models.RunPlanStatusPending).
+		Updates(map[string]interface{}{
+			"status":      models.RunPlanStatusApproved,
+			"approved_by": GetUserID(c),
+			"approved_at": now,
+		})
+	if res.Error != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to approve plan")
+	}
+	if res.RowsAffected == 0 {
+		return fiber.NewError(fiber.StatusConflict, "plan is already approved")
+	}
+
+	sequence, err := s.nextChatSequence(team.ID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to assign message sequence")
+	}
+	content, _ := json.Marshal(map[string]interface{}{
+		"content":              approvePlanMessage,
+		"approved_plan_run_id": plan.ID,
+	})
+	taskLog := models.TaskLog{
+		ID:             uuid.New().String(),
+		TeamID:         team.ID,
+		ConversationID: team.ConversationID,
+		FromAgent:      "user",
+		ToAgent:        "leader",
+		MessageType:    string(protocol.TypeUserMessage),
+		Payload:        models.JSON(content),
+		Sequence:       sequence,
+	}
+	if err := s.taskLogs.Create(&taskLog); err != nil {
+		slog.Error("approve plan: failed to save task log", "team", team.Name, "error", err)
+	}
+
+	payload := protocol.UserMessagePayload{
+		Content:           approvePlanMessage,
+		Source:            "chat",
+		ConversationID:    team.ConversationID,
+		Sequence:          sequence,
+		ApprovedPlanRunID: plan.ID,
+	}
+	if err := s.publishToTeamNATS(naming.Slug(team.Name), taskLog.ID, payload); err != nil {
+		// Reopen the plan so the approval can be retried.
+		s.db.Model(&models.RunPlan{}).Where("id = ?", plan.ID).Updates(map[string]interface{}{
+			"status":      models.RunPlanStatusPending,
+			"approved_by": "",
+			"approved_at": nil,
+		})
+		s.db.Model(&taskLog).Update("delivery_status", models.ChatDeliveryFailed)
+		return fiber.NewError(fiber.StatusBadGateway, "failed to send approval: "+err.Error())
+	}
+
+	slog.Info("plan approved", "team", team.Name, "run_id", plan.ID)
+	s.db.First(&plan, "id = ?", plan.ID)
+	return c.Status(fiber.StatusAccepted).JSON(plan)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestApprovePlan(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:         "plan-team",
+		PlanApproval: true,
+		Agents:       []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if !team.PlanApproval {
+		t.Error("plan_approval should be set")
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if got := mock.lastAgentConfig.Env["AGENT_PLAN_APPROVAL"]; got != "true" {
+		t.Errorf("AGENT_PLAN_APPROVAL: got %q, want true", got)
+	}
+
+	data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user", protocol.LeaderResponsePayload{
+		Status: protocol.StatusAwaitingApproval,
+		Result: "1. Fix the bug",
+		Plan:   &protocol.RunPlan{RunID: "run-1", Text: "1. Fix the bug"},
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	var plan models.RunPlan
+	if err := srv.db.First(&plan, "id = ?", "run-1").Error; err != nil {
+		t.Fatalf("run plan: %v", err)
+	}
+	if plan.TeamID != team.ID || plan.Status != models.RunPlanStatusPending || plan.Plan != "1. Fix the bug" {
+		t.Errorf("run plan: got %+v", plan)
+	}
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/runs/unknown/approve-plan", nil); rec.Code != 404 {
+		t.Errorf("unknown run: got %d, want 404", rec.Code)
+	}
+	srv.db.Model(&team).Update("status", models.TeamStatusStopped)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/runs/run-1/approve-plan", nil); rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+
+	// Without a reachable leader the approval fails and can be retried.
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/runs/run-1/approve-plan", nil); rec.Code != 502 {
+		t.Errorf("unreachable leader: got %d, want 502\nbody: %s", rec.Code, rec.Body.String())
+	}
+	srv.db.First(&plan, "id = ?", "run-1")
+	if plan.Status != models.RunPlanStatusPending || plan.ApprovedAt != nil {
+		t.Errorf("plan after failed approval: got %+v", plan)
+	}
+
+	// An approved plan cannot be approved again, even when the response is
+	// redelivered.
+	srv.db.Model(&plan).Update("status", models.RunPlanStatusApproved)
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/runs/run-1/approve-plan", nil); rec.Code != 409 {
+		t.Errorf("approved plan: got %d, want 409", rec.Code)
+	}
+
+	off := false
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{PlanApproval: &off})
+	parseJSON(t, rec, &team)
+	if team.PlanApproval {
+		t.Error("plan_approval should be cleared")
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestTeamMaxConcurrentRuns(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "too-many", MaxConcurrentRuns: protocol.MaxConcurrentRunsLimit + 1})
+	if rec.Code != 400 {
+		t.Errorf("over limit: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:              "limited",
+		MaxConcurrentRuns: 2,
+		Agents:            []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.MaxConcurrentRuns != 2 {
+		t.Errorf("max_concurrent_runs: got %d, want 2", team.MaxConcurrentRuns)
+	}
+
+	negative := -1
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{MaxConcurrentRuns: &negative})
+	if rec.Code != 400 {
+		t.Errorf("negative: got %d, want 400", rec.Code)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if got := mock.lastAgentConfig.Env["AGENT_MAX_CONCURRENT_RUNS"]; got != "2" {
+		t.Errorf("AGENT_MAX_CONCURRENT_RUNS: got %q, want 2", got)
+	}
+
+	// Zero removes the limit.
+	zero := 0
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{MaxConcurrentRuns: &zero})
+	if rec.Code != 200 {
+		t.Fatalf("reset: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, rec, &team)
+
+	mock.lastAgentConfig = nil
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	if _, ok := mock.lastAgentConfig.Env["AGENT_MAX_CONCURRENT_RUNS"]; ok {
+		t.Error("AGENT_MAX_CONCURRENT_RUNS should not be set for the default")
+	}
+}
+
+func TestGetMessages_QueuedPosition(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createLabeledTeam(t, srv, "queued-team", nil)
+
+	for _, id := range []string{"msg-a", "msg-b", "msg-c"} {
+		content, _ := json.Marshal(map[string]string{"content": id})
+		srv.db.Create(&models.TaskLog{
+			ID:          id,
+			TeamID:      team.ID,
+			FromAgent:   "user",
+			ToAgent:     "leader",
+			MessageType: string(protocol.TypeUserMessage),
+			Payload:     models.JSON(content),
+		})
+	}
+
+	data := buildRelayPayload(t, protocol.TypeRunQueue, "leader", "system", protocol.RunQueuePayload{
+		AgentName: "leader", MaxConcurrentRuns: 3, Running: "msg-a", Waiting: []string{"msg-b", "msg-c"},
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	if n := countRelayLogs(t, srv, team.ID); n != 3 {
+		t.Errorf("run queue should not be saved as a task log, got %d logs", n)
+	}
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
+	positions := map[string]int{}
+	for _, l := range parseList[models.TaskLog](t, rec) {
+		positions[l.ID] = l.QueuedPosition
+	}
+	if positions["msg-a"] != 0 || positions["msg-b"] != 1 || positions["msg-c"] != 2 {
+		t.Errorf("queued positions: got %v", positions)
+	}
+
+	// The run queue is forgotten when the relay stops.
+	srv.stopTeamRelay(team.ID)
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/messages", nil)
+	for _, l := range parseList[models.TaskLog](t, rec) {
+		if l.QueuedPosition != 0 {
+			t.Errorf("%s: queued position %d after relay stopped", l.ID, l.QueuedPosition)
+		}
+	}
+}
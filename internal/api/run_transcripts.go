@@ -0,0 +1,38 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// GetRunTranscript handles GET /api/teams/:id/runs/:runId/raw. It returns
+// the raw stream-json output the leader's claude CLI wrote during a chat
+// run, identified by the ID of the run's user message. Transcripts are only
+// written by sidecars started with AGENT_TRANSCRIPTS=true.
+func (s *Server) GetRunTranscript(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	name, err := protocol.TranscriptFile(c.Params("runId"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if team.Status != models.TeamStatusRunning {
+		return newAPIError(CodeTeamNotRunning, "team is not running")
+	}
+	var leader models.Agent
+	if err := s.db.Where("team_id = ? AND role = ? AND container_status IN ?",
+		team.ID, models.AgentRoleLeader, models.ContainerUpStatuses).First(&leader).Error; err != nil {
+		return newAPIError(CodeNoLeader, "no running leader agent found for this team")
+	}
+
+	output, err := s.runtime.ExecInContainer(c.Context(), leader.ContainerID, []string{"cat", "/workspace/" + name})
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "transcript not found")
+	}
+	c.Set(fiber.HeaderContentType, "application/x-ndjson")
+	return c.SendString(output)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestGetRunTranscript(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "transcript-team", "leader-container")
+
+	mock.execOutput = `{"type":"assistant"}` + "\n" + `{"type":"result"}` + "\n"
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/runs/msg-1/raw", nil)
+	if rec.Code != 200 {
+		t.Fatalf("raw: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != mock.execOutput {
+		t.Errorf("body: got %q", rec.Body.String())
+	}
+	if len(mock.execCommands) != 1 ||
+		strings.Join(mock.execCommands[0], " ") != "cat /workspace/.agentcrew/transcripts/msg-1.jsonl" {
+		t.Errorf("exec commands: got %q", mock.execCommands)
+	}
+}
+
+func TestGetRunTranscript_Errors(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	team := createRunningTeam(t, srv, "transcript-errors", "leader-container")
+
+	rec := doRequest(srv, "GET", "/api/teams/"+team.ID+"/runs/..%2Fsecrets/raw", nil)
+	if rec.Code != 400 {
+		t.Errorf("invalid run ID: got %d, want 400", rec.Code)
+	}
+
+	mock.execErr = errors.New("exit code 1")
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/runs/msg-1/raw", nil)
+	if rec.Code != 404 {
+		t.Errorf("missing transcript: got %d, want 404", rec.Code)
+	}
+
+	srv.db.Model(&team).Update("status", models.TeamStatusStopped)
+	rec = doRequest(srv, "GET", "/api/teams/"+team.ID+"/runs/msg-1/raw", nil)
+	if rec.Code != 409 {
+		t.Errorf("stopped team: got %d, want 409", rec.Code)
+	}
+	if len(mock.execCommands) != 1 {
+		t.Errorf("exec commands: got %d, want 1", len(mock.execCommands))
+	}
+}
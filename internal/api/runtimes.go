@@ -0,0 +1,75 @@
+package api
+
+import (
+	"log/slog"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// runtimeName returns the name teams record for rt: the default runtime of
+// a MultiRuntime, or the runtime itself.
+func runtimeName(rt runtime.AgentRuntime) string {
+	switch r := rt.(type) {
+	case *runtime.MultiRuntime:
+		return r.DefaultName()
+	case *runtime.K8sRuntime:
+		return runtime.NameKubernetes
+	case *runtime.ChaosRuntime:
+		return runtime.NameChaos
+	default:
+		return runtime.NameDocker
+	}
+}
+
+// sharedRuntime returns the runtime of the services teams share: Ollama,
+// Qdrant, the RAG MCP server and their network. With several runtimes, it
+// is the default one.
+func (s *Server) sharedRuntime() runtime.AgentRuntime {
+	if m, ok := s.runtime.(*runtime.MultiRuntime); ok {
+		return m.Default()
+	}
+	return s.runtime
+}
+
+// teamRuntime returns the runtime team runs on: with several runtimes,
+// the one its runtime field names, or the default.
+func (s *Server) teamRuntime(team models.Team) runtime.AgentRuntime {
+	if m, ok := s.runtime.(*runtime.MultiRuntime); ok {
+		if rt := m.Runtime(team.Runtime); rt != nil {
+			return rt
+		}
+		return m.Default()
+	}
+	return s.runtime
+}
+
+// teamRuntimeName returns the runtime the team with the given slug runs
+// on. It is a MultiRuntime's team lookup.
+func (s *Server) teamRuntimeName(slug string) string {
+	var teams []models.Team
+	s.db.Select("runtime").Where("slug = ?", slug).Limit(1).Find(&teams)
+	if len(teams) == 0 {
+		return ""
+	}
+	return teams[0].Runtime
+}
+
+// fixTeamRuntimes records the server's runtime on teams that name another
+// one. With a single runtime, every team runs on it whatever it was
+// created with; with several, teams naming none of them run on the
+// default.
+func (s *Server) fixTeamRuntimes() {
+	q := s.db.Model(&models.Team{})
+	if m, ok := s.runtime.(*runtime.MultiRuntime); ok {
+		q = q.Where("runtime NOT IN ?", m.Names())
+	} else {
+		q = q.Where("runtime <> ?", s.runtimeName)
+	}
+	res := q.Update("runtime", s.runtimeName)
+	if res.Error != nil {
+		slog.Error("failed to update the runtime of teams", "error", res.Error)
+	} else if res.RowsAffected > 0 {
+		slog.Info("recorded the runtime teams run on", "runtime", s.runtimeName, "count", res.RowsAffected)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"log/slog"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -12,9 +14,17 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/events"
+	"github.com/helmcode/agent-crew/internal/guardrails"
+	"github.com/helmcode/agent-crew/internal/integrations/alerting"
+	"github.com/helmcode/agent-crew/internal/integrations/issues"
+	"github.com/helmcode/agent-crew/internal/jobs"
 	"github.com/helmcode/agent-crew/internal/models"
 	"github.com/helmcode/agent-crew/internal/postaction"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
 	"github.com/helmcode/agent-crew/internal/runtime"
+	"github.com/helmcode/agent-crew/internal/tools"
 )
 
 // Server holds dependencies for the HTTP API.
@@ -22,6 +32,8 @@ type Server struct {
 	App     *fiber.App
 	db      *gorm.DB
 	runtime runtime.AgentRuntime
+	// runtimeName is the runtime new teams run on (see runtimeName).
+	runtimeName string
 
 	// authProvider is the pluggable authentication backend.
 	authProvider auth.AuthProvider
@@ -29,16 +41,151 @@ type Server struct {
 	// multiTenant enables unlimited organizations and public registration.
 	multiTenant bool
 
+	// ctx is cancelled by Shutdown, ending the background work that waits
+	// on it, such as queued chat deliveries waiting for the rate limiter.
+	ctx  context.Context
+	stop context.CancelFunc
+
 	// relays tracks active NATS relay goroutines per team ID.
 	// The cancel function stops the relay when the team is stopped.
 	relaysMu sync.Mutex
 	relays   map[string]context.CancelFunc
 
+	// owner is set while this replica relays team messages and runs the
+	// background loops (see AcquireOwnership).
+	owner atomic.Bool
+
+	// relaySyncCancel stops the relay sync loop started by
+	// AcquireOwnership.
+	relaySyncCancel context.CancelFunc
+	relaySyncWg     sync.WaitGroup
+
+	// externalRelays is set when relay workers (cmd/relay) relay team
+	// messages instead of this API (see SetRelayWorkers).
+	externalRelays bool
+
+	// sharedRelayState keeps the leader readiness and run queues reported
+	// through relays in the database, so that the API and relay workers
+	// see the same state.
+	sharedRelayState bool
+
+	// leaderReady records, per team ID, whether the leader has reported
+	// ready since its relay started. Guarded by relaysMu.
+	leaderReady map[string]bool
+
+	// runQueues holds, per team ID, the latest run queue reported by the
+	// leader's sidecar. Guarded by relaysMu.
+	runQueues map[string]protocol.RunQueuePayload
+
+	// teamOps tracks the team operations this replica runs, by operation
+	// ID, so that a forced operation can cancel them at once. Operations
+	// exclude each other through leases (see beginTeamOp).
+	teamOpsMu sync.Mutex
+	teamOps   map[string]*teamOp
+
+	// deployLogs keeps the progress lines of the deployments this replica
+	// runs (see StreamDeployLogs).
+	deployLogs deployLogs
+
+	// chatQueueLocks holds a *sync.Mutex per team ID that serializes
+	// delivery of the team's queued chat messages.
+	chatQueueLocks sync.Map
+
+	// statusRefreshes holds, per team ID, when refreshContainerStatuses last
+	// looked the team's containers up.
+	statusRefreshes sync.Map
+
+	// governor spaces out prompts sent to leaders across all teams and
+	// replicas (see SetGovernor). Nil means no limit.
+	governor *ratelimit.Governor
+
 	// webhookMaxConcurrent is the global limit of concurrent webhook runs.
 	webhookMaxConcurrent int
 
+	// events delivers domain events, such as finished runs, to the modules
+	// that react to them (see registerEventHandlers).
+	events      *events.Bus
+	eventCounts eventCounter
+
 	// postActionExec fires post-actions after webhook/schedule runs complete.
 	postActionExec *postaction.Executor
+
+	// toolProxy calls custom tools on behalf of agents.
+	toolProxy *tools.Proxy
+
+	// issueNotifier syncs run results to Jira/Linear integrations.
+	issueNotifier *issues.Notifier
+
+	// alertMonitor opens PagerDuty/Opsgenie incidents for unhealthy teams.
+	alertMonitor *alerting.Monitor
+
+	// deadLetterCancel stops the dead-letter retry loop started by
+	// StartDeadLetterRetrier.
+	deadLetterCancel context.CancelFunc
+	deadLetterWg     sync.WaitGroup
+
+	// infraGCCancel stops the infrastructure garbage collector started by
+	// StartInfraGC.
+	infraGCCancel context.CancelFunc
+	infraGCWg     sync.WaitGroup
+
+	// driftCancel stops the drift reconciler started by
+	// StartDriftReconciler.
+	driftCancel context.CancelFunc
+	driftWg     sync.WaitGroup
+
+	// workingHoursCancel stops the loop started by
+	// StartWorkingHoursMonitor.
+	workingHoursCancel context.CancelFunc
+	workingHoursWg     sync.WaitGroup
+	// imagePrePullCancel stops the image pre-pull loop started by
+	// StartImagePrePull, which runs every imagePrePullInterval.
+	imagePrePullInterval time.Duration
+	imagePrePullCancel   context.CancelFunc
+	imagePrePullWg       sync.WaitGroup
+	// imagePrePullReport is the outcome of the last pre-pull, guarded by
+	// imagePrePullMu.
+	imagePrePullMu     sync.Mutex
+	imagePrePullReport *ImagePrePullReport
+
+	// taskLogs serializes TaskLog inserts from relays and chat handlers.
+	taskLogs *taskLogWriter
+
+	// relayLags tracks how far behind their streams team relays are.
+	relayLags relayLagTracker
+
+	// readCache holds team rows and settings for the hot read paths (see
+	// SetReadCacheTTL).
+	readCache *readCache
+
+	// jobs runs deployments, leader restarts and agent upgrades in the
+	// background (see StartJobs).
+	jobs *jobs.Queue
+
+	// shareKey signs shareable run links (see SetShareLinkSecret).
+	shareKey []byte
+
+	// previewExpiryCancel stops the loop that ends expired agent preview
+	// sessions (see PreviewChat).
+	previewExpiryCancel context.CancelFunc
+	previewExpiryWg     sync.WaitGroup
+
+	// promptLeader sends a prompt to a team's leader and waits for its
+	// response. It is sendWebhookPromptAndWait outside of tests.
+	promptLeader func(ctx context.Context, team models.Team, prompt, runID string) (string, error)
+
+	// indexKnowledge stores a run result in its team's knowledge base and
+	// returns the number of chunks stored. It is indexKnowledgeEntry
+	// outside of tests.
+	indexKnowledge func(ctx context.Context, entry models.KnowledgeEntry) (int, error)
+
+	// moderator returns the moderation model content policies call for an
+	// organization. It is openAIModerator outside of tests.
+	moderator func(orgID string) guardrails.Moderator
+
+	// openTeamStreams connects to the NATS server of the team with the
+	// given slug. It is connectTeamStreams outside of tests.
+	openTeamStreams func(ctx context.Context, teamSlug string) (teamStreams, error)
 }
 
 // NewServer creates a Fiber app with middleware and registers all routes.
@@ -53,9 +200,10 @@ func NewServer(db *gorm.DB, rt runtime.AgentRuntime, ap auth.AuthProvider) *Serv
 	app.Use(recover.New())
 	app.Use(requestid.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,If-None-Match",
+		ExposeHeaders: "ETag",
 	}))
 	app.Use(requestLogger())
 
@@ -63,12 +211,38 @@ func NewServer(db *gorm.DB, rt runtime.AgentRuntime, ap auth.AuthProvider) *Serv
 		App:                  app,
 		db:                   db,
 		runtime:              rt,
+		runtimeName:          runtimeName(rt),
 		authProvider:         ap,
 		relays:               make(map[string]context.CancelFunc),
+		leaderReady:          make(map[string]bool),
+		runQueues:            make(map[string]protocol.RunQueuePayload),
+		teamOps:              make(map[string]*teamOp),
 		webhookMaxConcurrent: 20,
+		events:               events.New(),
 		postActionExec:       postaction.NewExecutor(db),
+		toolProxy:            tools.NewProxy(),
+		issueNotifier:        issues.NewNotifier(db),
+		alertMonitor:         alerting.NewMonitor(db, rt, 0),
+		taskLogs:             newTaskLogWriter(db),
+		readCache:            newReadCache(defaultReadCacheTTL),
+		imagePrePullInterval: defaultImagePrePullInterval,
+		jobs:                 jobs.New(db, ""),
+		shareKey:             randomShareKey(),
 	}
 
+	s.ctx, s.stop = context.WithCancel(context.Background())
+	s.owner.Store(true)
+	s.readCache.invalidateOnWrite(db)
+	if m, ok := rt.(*runtime.MultiRuntime); ok {
+		m.SetTeamLookup(s.teamRuntimeName)
+	}
+	s.promptLeader = s.sendWebhookPromptAndWait
+	s.indexKnowledge = s.indexKnowledgeEntry
+	s.moderator = s.openAIModerator
+	s.openTeamStreams = s.connectTeamStreams
+	s.registerJobs()
+	s.registerEventHandlers()
+
 	s.registerRoutes()
 	return s
 }
@@ -79,10 +253,21 @@ func (s *Server) Listen(addr string) error {
 	return s.App.Listen(addr)
 }
 
-// Shutdown gracefully stops the HTTP server.
+// Shutdown gracefully stops the HTTP server and the background loops.
 func (s *Server) Shutdown() error {
 	slog.Info("shutting down HTTP server")
-	return s.App.Shutdown()
+	s.stop()
+	s.ReleaseOwnership()
+	err := s.App.Shutdown()
+	s.StopJobs()
+	s.taskLogs.Stop()
+	return err
+}
+
+// StartAlertMonitor starts the background team health checks that drive
+// PagerDuty/Opsgenie alert integrations.
+func (s *Server) StartAlertMonitor() {
+	s.alertMonitor.Start()
 }
 
 // SetWebhookMaxConcurrent sets the global limit for concurrent webhook runs.
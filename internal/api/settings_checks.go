@@ -0,0 +1,288 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/crypto"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// Outcomes of a settings check.
+const (
+	SettingsCheckPass    = "pass"
+	SettingsCheckFail    = "fail"
+	SettingsCheckSkipped = "skipped"
+)
+
+// settingsCheckTimeout bounds each connectivity check.
+const settingsCheckTimeout = 15 * time.Second
+
+// Provider endpoints pinged by the settings checks. Tests point them at
+// local servers.
+var (
+	anthropicAPIURL = "https://api.anthropic.com"
+	openAIAPIURL    = "https://api.openai.com"
+	googleAPIURL    = "https://generativelanguage.googleapis.com"
+	registryAPIURL  = func(host string) string {
+		if host == "docker.io" {
+			host = "registry-1.docker.io"
+		}
+		return "https://" + host
+	}
+)
+
+var settingsCheckClient = &http.Client{Timeout: settingsCheckTimeout}
+
+// SettingsCheck is the outcome of one settings check.
+type SettingsCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// SettingsTestResponse is the response of POST /api/settings/test. OK is
+// false if any check failed; skipped checks are not configured.
+type SettingsTestResponse struct {
+	OK     bool            `json:"ok"`
+	Checks []SettingsCheck `json:"checks"`
+}
+
+// CheckSettings checks that the organization's secret settings decrypt and
+// that the configured model provider keys and the agent images' registry
+// credentials are accepted, so misconfiguration shows before a deploy.
+func (s *Server) CheckSettings(c *fiber.Ctx) error {
+	orgID, _ := c.Locals("org_id").(string)
+	env := s.LoadSettingsEnv(orgID)
+
+	type check struct {
+		name string
+		run  func(ctx context.Context) (string, string)
+	}
+	checks := []check{
+		{"secrets", func(context.Context) (string, string) { return s.checkSecretSettings(orgID) }},
+		{"anthropic", func(ctx context.Context) (string, string) { return checkAnthropic(ctx, env) }},
+		{"openai", func(ctx context.Context) (string, string) {
+			return checkProviderKey(ctx, env["OPENAI_API_KEY"], openAIAPIURL+"/v1/models", func(r *http.Request, key string) {
+				r.Header.Set("Authorization", "Bearer "+key)
+			})
+		}},
+		{"google", func(ctx context.Context) (string, string) {
+			key := firstNonEmpty(env["GEMINI_API_KEY"], env["GOOGLE_GENERATIVE_AI_API_KEY"], env["GOOGLE_API_KEY"])
+			return checkProviderKey(ctx, key, googleAPIURL+"/v1beta/models?pageSize=1", func(r *http.Request, key string) {
+				r.Header.Set("x-goog-api-key", key)
+			})
+		}},
+	}
+	for _, registry := range s.agentImageRegistries(orgID) {
+		checks = append(checks, check{"registry:" + registry.host, func(ctx context.Context) (string, string) {
+			return checkRegistry(ctx, registry.host, registry.image)
+		}})
+	}
+
+	resp := SettingsTestResponse{OK: true, Checks: make([]SettingsCheck, len(checks))}
+	var wg sync.WaitGroup
+	for i, ch := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Context(), settingsCheckTimeout)
+			defer cancel()
+			start := time.Now()
+			status, msg := ch.run(ctx)
+			resp.Checks[i] = SettingsCheck{Name: ch.name, Status: status, Message: msg, DurationMs: time.Since(start).Milliseconds()}
+		}()
+	}
+	wg.Wait()
+	for _, ch := range resp.Checks {
+		if ch.Status == SettingsCheckFail {
+			resp.OK = false
+		}
+	}
+	return c.JSON(resp)
+}
+
+// checkSecretSettings fails if a secret setting cannot be decrypted, which
+// happens when ENCRYPTION_KEY changed since it was saved. Deploys skip such
+// settings.
+func (s *Server) checkSecretSettings(orgID string) (string, string) {
+	var settings []models.Settings
+	if err := s.db.Where("org_id = ? AND is_secret = ?", orgID, true).Find(&settings).Error; err != nil {
+		return SettingsCheckFail, "failed to load settings"
+	}
+	var broken []string
+	for _, setting := range settings {
+		if setting.Value == "" {
+			continue
+		}
+		if _, err := crypto.Decrypt(setting.Value); err != nil {
+			broken = append(broken, setting.Key)
+		}
+	}
+	if len(broken) > 0 {
+		return SettingsCheckFail, "cannot decrypt " + strings.Join(broken, ", ") + "; save them again"
+	}
+	return SettingsCheckPass, fmt.Sprintf("%d secret settings decrypt", len(settings))
+}
+
+// checkAnthropic lists models with the API key, or else the OAuth token.
+func checkAnthropic(ctx context.Context, env map[string]string) (string, string) {
+	apiKey, oauthToken := env["ANTHROPIC_API_KEY"], env["CLAUDE_CODE_OAUTH_TOKEN"]
+	if apiKey == "" && oauthToken == "" {
+		return SettingsCheckSkipped, "neither ANTHROPIC_API_KEY nor CLAUDE_CODE_OAUTH_TOKEN is set"
+	}
+	return checkProviderKey(ctx, firstNonEmpty(apiKey, oauthToken), anthropicAPIURL+"/v1/models?limit=1", func(r *http.Request, key string) {
+		r.Header.Set("anthropic-version", "2023-06-01")
+		if apiKey != "" {
+			r.Header.Set("x-api-key", key)
+			return
+		}
+		r.Header.Set("Authorization", "Bearer "+key)
+		r.Header.Set("anthropic-beta", "oauth-2025-04-20")
+	})
+}
+
+// checkProviderKey calls a cheap, read-only endpoint of a model provider
+// with key, passing if it answers 2xx.
+func checkProviderKey(ctx context.Context, key, endpoint string, authorize func(*http.Request, string)) (string, string) {
+	if key == "" {
+		return SettingsCheckSkipped, "no API key is set"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return SettingsCheckFail, err.Error()
+	}
+	authorize(req, key)
+	resp, err := settingsCheckClient.Do(req)
+	if err != nil {
+		return SettingsCheckFail, "provider unreachable: " + err.Error()
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return SettingsCheckPass, "API key accepted"
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return SettingsCheckFail, fmt.Sprintf("API key rejected (%d)", resp.StatusCode)
+	default:
+		return SettingsCheckFail, fmt.Sprintf("provider returned %d", resp.StatusCode)
+	}
+}
+
+type imageRegistry struct {
+	host  string
+	image string
+}
+
+// agentImageRegistries returns the registries of the default agent images
+// and of the organization's teams' images, one image each.
+func (s *Server) agentImageRegistries(orgID string) []imageRegistry {
+	images := []string{runtime.DefaultAgentImage, runtime.DefaultOpenCodeAgentImage}
+	var teamImages []string
+	s.db.Model(&models.Team{}).Where("org_id = ? AND agent_image <> ''", orgID).Distinct().Pluck("agent_image", &teamImages)
+	images = append(images, teamImages...)
+
+	byHost := make(map[string]string)
+	for _, img := range images {
+		host, _, _, _ := runtime.RegistryCredentials(img)
+		if _, ok := byHost[host]; !ok {
+			byHost[host] = img
+		}
+	}
+	registries := make([]imageRegistry, 0, len(byHost))
+	for host, img := range byHost {
+		registries = append(registries, imageRegistry{host: host, image: img})
+	}
+	sort.Slice(registries, func(i, j int) bool { return registries[i].host < registries[j].host })
+	return registries
+}
+
+// checkRegistry logs in to a registry with the credentials the Docker
+// runtime pulls image with: the registry API's base endpoint, or its token
+// service when the registry answers with a bearer challenge.
+func checkRegistry(ctx context.Context, host, image string) (string, string) {
+	_, username, password, ok := runtime.RegistryCredentials(image)
+	if !ok {
+		return SettingsCheckSkipped, "no credentials in the Docker config; images are pulled anonymously"
+	}
+	status, challenge, err := registryGet(ctx, registryAPIURL(host)+"/v2/", username, password)
+	if err != nil {
+		return SettingsCheckFail, "registry unreachable: " + err.Error()
+	}
+	if status == http.StatusUnauthorized && strings.HasPrefix(challenge, "Bearer ") {
+		tokenURL, err := bearerTokenURL(challenge, username)
+		if err != nil {
+			return SettingsCheckFail, err.Error()
+		}
+		if status, _, err = registryGet(ctx, tokenURL, username, password); err != nil {
+			return SettingsCheckFail, "token service unreachable: " + err.Error()
+		}
+	}
+	switch {
+	case status < 300:
+		return SettingsCheckPass, "credentials for " + username + " accepted"
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return SettingsCheckFail, fmt.Sprintf("credentials for %s rejected (%d)", username, status)
+	default:
+		return SettingsCheckFail, fmt.Sprintf("registry returned %d", status)
+	}
+}
+
+// registryGet sends an authenticated GET and returns its status and
+// WWW-Authenticate challenge.
+func registryGet(ctx context.Context, endpoint, username, password string) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.SetBasicAuth(username, password)
+	resp, err := settingsCheckClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	return resp.StatusCode, resp.Header.Get("WWW-Authenticate"), nil
+}
+
+// bearerTokenURL builds the token request of a Bearer challenge such as
+// Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func bearerTokenURL(challenge, account string) (string, error) {
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[k] = strings.Trim(v, `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return "", fmt.Errorf("registry sent an invalid token realm %q", params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("account", account)
+	realm.RawQuery = q.Encode()
+	return realm.String(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
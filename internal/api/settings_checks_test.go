@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/crypto"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// stubProviders points the provider endpoints at a local server accepting
+// the key "good", and the registry endpoints at http://<host>.
+func stubProviders(t *testing.T) {
+	t.Helper()
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("x-api-key") + r.Header.Get("x-goog-api-key") + strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key != "good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	t.Cleanup(provider.Close)
+
+	prevAnthropic, prevOpenAI, prevGoogle, prevRegistry := anthropicAPIURL, openAIAPIURL, googleAPIURL, registryAPIURL
+	anthropicAPIURL, openAIAPIURL, googleAPIURL = provider.URL, provider.URL, provider.URL
+	registryAPIURL = func(host string) string { return "http://" + host }
+	t.Cleanup(func() {
+		anthropicAPIURL, openAIAPIURL, googleAPIURL, registryAPIURL = prevAnthropic, prevOpenAI, prevGoogle, prevRegistry
+	})
+	// No Docker credentials unless a test writes them.
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+}
+
+func checkSettings(t *testing.T, srv *Server) (SettingsTestResponse, map[string]SettingsCheck) {
+	t.Helper()
+	rec := doRequest(srv, "POST", "/api/settings/test", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp SettingsTestResponse
+	parseJSON(t, rec, &resp)
+	byName := make(map[string]SettingsCheck, len(resp.Checks))
+	for _, ch := range resp.Checks {
+		byName[ch.Name] = ch
+	}
+	return resp, byName
+}
+
+func putSetting(t *testing.T, srv *Server, key, value string) {
+	t.Helper()
+	secret := true
+	rec := doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: key, Value: value, IsSecret: &secret})
+	if rec.Code != 200 {
+		t.Fatalf("PUT %s: got %d\nbody: %s", key, rec.Code, rec.Body.String())
+	}
+}
+
+func TestCheckSettings_NothingConfigured(t *testing.T) {
+	stubProviders(t)
+	srv, _ := setupTestServer(t)
+
+	resp, checks := checkSettings(t, srv)
+	if !resp.OK {
+		t.Errorf("ok: got false, want true: %+v", resp.Checks)
+	}
+	if checks["secrets"].Status != SettingsCheckPass {
+		t.Errorf("secrets: got %+v", checks["secrets"])
+	}
+	for _, name := range []string{"anthropic", "openai", "google", "registry:ghcr.io"} {
+		if checks[name].Status != SettingsCheckSkipped {
+			t.Errorf("%s: got %+v, want skipped", name, checks[name])
+		}
+	}
+}
+
+func TestCheckSettings_ProviderKeys(t *testing.T) {
+	stubProviders(t)
+	srv, _ := setupTestServer(t)
+	putSetting(t, srv, "ANTHROPIC_API_KEY", "good")
+	putSetting(t, srv, "OPENAI_API_KEY", "bad")
+	putSetting(t, srv, "GEMINI_API_KEY", "good")
+
+	resp, checks := checkSettings(t, srv)
+	if resp.OK {
+		t.Error("ok: got true, want false with a rejected key")
+	}
+	if checks["anthropic"].Status != SettingsCheckPass || checks["google"].Status != SettingsCheckPass {
+		t.Errorf("anthropic, google: got %+v, %+v", checks["anthropic"], checks["google"])
+	}
+	if ch := checks["openai"]; ch.Status != SettingsCheckFail || !strings.Contains(ch.Message, "rejected (401)") {
+		t.Errorf("openai: got %+v", ch)
+	}
+}
+
+func TestCheckSettings_AnthropicOAuthToken(t *testing.T) {
+	stubProviders(t)
+	srv, _ := setupTestServer(t)
+	putSetting(t, srv, "CLAUDE_CODE_OAUTH_TOKEN", "good")
+
+	_, checks := checkSettings(t, srv)
+	if checks["anthropic"].Status != SettingsCheckPass {
+		t.Errorf("anthropic: got %+v", checks["anthropic"])
+	}
+}
+
+func TestCheckSettings_UndecryptableSecret(t *testing.T) {
+	stubProviders(t)
+	t.Setenv(crypto.EnvEncryptionKey, "current-key")
+	srv, _ := setupTestServer(t)
+	srv.db.Create(&models.Settings{OrgID: auth.DefaultOrgID(), Key: "STALE_TOKEN", Value: crypto.EncryptedPrefix + "bm90LWVuY3J5cHRlZA==", IsSecret: true})
+
+	resp, checks := checkSettings(t, srv)
+	if resp.OK {
+		t.Error("ok: got true, want false")
+	}
+	if ch := checks["secrets"]; ch.Status != SettingsCheckFail || !strings.Contains(ch.Message, "STALE_TOKEN") {
+		t.Errorf("secrets: got %+v", ch)
+	}
+}
+
+func TestCheckSettings_RegistryBearerChallenge(t *testing.T) {
+	stubProviders(t)
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/token",service="test-registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/token":
+			user, pass, _ := r.BasicAuth()
+			if user != "deployer" || pass != "s3cret" || r.URL.Query().Get("service") != "test-registry" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"token":"t"}`))
+		}
+	}))
+	defer registry.Close()
+	host := strings.TrimPrefix(registry.URL, "http://")
+
+	configDir := os.Getenv("DOCKER_CONFIG")
+	creds := base64.StdEncoding.EncodeToString([]byte("deployer:s3cret"))
+	config := `{"auths":{"` + host + `":{"auth":"` + creds + `"}}}`
+	if err := os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, _ := setupTestServer(t)
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "custom-image", AgentImage: host + "/team/agent:latest"})
+	if rec.Code != 201 {
+		t.Fatalf("create team: got %d\nbody: %s", rec.Code, rec.Body.String())
+	}
+
+	_, checks := checkSettings(t, srv)
+	if ch := checks["registry:"+host]; ch.Status != SettingsCheckPass || !strings.Contains(ch.Message, "deployer") {
+		t.Errorf("registry: got %+v", ch)
+	}
+
+	creds = base64.StdEncoding.EncodeToString([]byte("deployer:wrong"))
+	config = `{"auths":{"` + host + `":{"auth":"` + creds + `"}}}`
+	os.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0o600)
+	_, checks = checkSettings(t, srv)
+	if ch := checks["registry:"+host]; ch.Status != SettingsCheckFail {
+		t.Errorf("registry with a wrong password: got %+v", ch)
+	}
+}
+
+func TestBearerTokenURL(t *testing.T) {
+	got, err := bearerTokenURL(`Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`, "alice")
+	if err != nil {
+		t.Fatalf("bearerTokenURL: %v", err)
+	}
+	if want := "https://auth.docker.io/token?account=alice&service=registry.docker.io"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := bearerTokenURL(`Bearer service="x"`, "alice"); err == nil {
+		t.Error("want an error without a realm")
+	}
+}
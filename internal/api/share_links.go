@@ -0,0 +1,255 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Kinds of runs that can be shared.
+const (
+	sharedRunSchedule = "schedule"
+	sharedRunWebhook  = "webhook"
+)
+
+// Lifetime of shareable run links, in hours.
+const (
+	defaultShareLinkHours = 72
+	maxShareLinkHours     = 30 * 24
+)
+
+var (
+	errInvalidShareToken = errors.New("invalid share token")
+	errExpiredShareToken = errors.New("share token expired")
+)
+
+// shareClaims is what a share token grants: read access to a run until
+// ExpiresAt, for as long as the ShareLink record LinkID exists.
+type shareClaims struct {
+	LinkID    string
+	Kind      string
+	RunID     string
+	ExpiresAt time.Time
+}
+
+// randomShareKey returns a random key for signing share links, used until
+// SetShareLinkSecret is called.
+func randomShareKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("generating share link key: %v", err))
+	}
+	return key
+}
+
+// SetShareLinkSecret sets the secret that signs shareable run links. Without
+// it links are signed with a random key and stop working on restart.
+func (s *Server) SetShareLinkSecret(secret string) {
+	key := sha256.Sum256([]byte(secret))
+	s.shareKey = key[:]
+}
+
+// signShareToken returns a token carrying claims, followed by an HMAC-SHA256
+// signature.
+func (s *Server) signShareToken(claims shareClaims) string {
+	payload := strings.Join([]string{
+		claims.LinkID, claims.Kind, claims.RunID, strconv.FormatInt(claims.ExpiresAt.Unix(), 10),
+	}, ":")
+	mac := hmac.New(sha256.New, s.shareKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseShareToken verifies a token made by signShareToken and returns its
+// claims. It does not check that the link was not revoked.
+func (s *Server) parseShareToken(token string, now time.Time) (shareClaims, error) {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return shareClaims{}, errInvalidShareToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return shareClaims{}, errInvalidShareToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return shareClaims{}, errInvalidShareToken
+	}
+	mac := hmac.New(sha256.New, s.shareKey)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return shareClaims{}, errInvalidShareToken
+	}
+
+	parts := strings.Split(string(payload), ":")
+	if len(parts) != 4 {
+		return shareClaims{}, errInvalidShareToken
+	}
+	exp, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return shareClaims{}, errInvalidShareToken
+	}
+	claims := shareClaims{LinkID: parts[0], Kind: parts[1], RunID: parts[2], ExpiresAt: time.Unix(exp, 0).UTC()}
+	if !now.Before(claims.ExpiresAt) {
+		return shareClaims{}, errExpiredShareToken
+	}
+	return claims, nil
+}
+
+// shareableRun returns the kind of the run runID of the caller's
+// organization, or "" when there is no such run.
+func (s *Server) shareableRun(c *fiber.Ctx, runID string) string {
+	var scheduleRun models.ScheduleRun
+	var webhookRun models.WebhookRun
+	if err := s.db.First(&scheduleRun, "id = ?", runID).Error; err == nil {
+		var schedule models.Schedule
+		if err := s.db.Scopes(OrgScope(c)).Select("id").First(&schedule, "id = ?", scheduleRun.ScheduleID).Error; err == nil {
+			return sharedRunSchedule
+		}
+	} else if err := s.db.First(&webhookRun, "id = ?", runID).Error; err == nil {
+		var webhook models.Webhook
+		if err := s.db.Scopes(OrgScope(c)).Select("id").First(&webhook, "id = ?", webhookRun.WebhookID).Error; err == nil {
+			return sharedRunWebhook
+		}
+	}
+	return ""
+}
+
+// ShareRun handles POST /api/runs/:id/share. It returns a signed, expiring
+// link to a read-only transcript of a schedule or webhook run of the
+// caller's organization, which can be opened without an account.
+func (s *Server) ShareRun(c *fiber.Ctx) error {
+	var req ShareRunRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return newAPIError(CodeInvalidBody, "invalid request body")
+		}
+	}
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = defaultShareLinkHours
+	}
+	if hours < 1 || hours > maxShareLinkHours {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("expires_in_hours must be between 1 and %d", maxShareLinkHours))
+	}
+
+	runID := c.Params("id")
+	kind := s.shareableRun(c, runID)
+	if kind == "" {
+		return fiber.NewError(fiber.StatusNotFound, "run not found")
+	}
+
+	now := time.Now().UTC()
+	link := models.ShareLink{
+		ID:        uuid.New().String(),
+		OrgID:     GetOrgID(c),
+		RunID:     runID,
+		Kind:      kind,
+		CreatedBy: GetUserID(c),
+		ExpiresAt: now.Add(time.Duration(hours) * time.Hour).Truncate(time.Second),
+	}
+	s.db.Where("expires_at < ?", now).Delete(&models.ShareLink{})
+	if err := s.db.Create(&link).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create share link")
+	}
+	token := s.signShareToken(shareClaims{LinkID: link.ID, Kind: kind, RunID: runID, ExpiresAt: link.ExpiresAt})
+	return c.Status(fiber.StatusCreated).JSON(ShareRunResponse{
+		ID:        link.ID,
+		Token:     token,
+		URL:       "/api/shared/" + token,
+		ExpiresAt: link.ExpiresAt,
+	})
+}
+
+// RevokeRunShares handles DELETE /api/runs/:id/share. It revokes every link
+// shared for a run of the caller's organization.
+func (s *Server) RevokeRunShares(c *fiber.Ctx) error {
+	runID := c.Params("id")
+	if s.shareableRun(c, runID) == "" {
+		return fiber.NewError(fiber.StatusNotFound, "run not found")
+	}
+	if err := s.db.Scopes(OrgScope(c)).Where("run_id = ?", runID).Delete(&models.ShareLink{}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke share links")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetSharedRun handles GET /api/shared/:token (public). It serves the
+// transcript of the run the token was issued for, until the token expires.
+func (s *Server) GetSharedRun(c *fiber.Ctx) error {
+	claims, err := s.parseShareToken(c.Params("token"), time.Now())
+	if errors.Is(err, errExpiredShareToken) {
+		return fiber.NewError(fiber.StatusGone, "share link expired")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "shared run not found")
+	}
+	var link models.ShareLink
+	if err := s.db.Select("id").First(&link, "id = ? AND run_id = ?", claims.LinkID, claims.RunID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "shared run not found")
+	}
+
+	kind, runID := claims.Kind, claims.RunID
+	t := SharedRunTranscript{RunID: runID, Kind: kind, ExpiresAt: claims.ExpiresAt}
+	var teamID, prompt, response, runErr string
+	switch kind {
+	case sharedRunSchedule:
+		var run models.ScheduleRun
+		if err := s.db.Preload("Schedule").First(&run, "id = ?", runID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "shared run not found")
+		}
+		t.Name, teamID = run.Schedule.Name, run.Schedule.TeamID
+		t.Status, t.StartedAt, t.FinishedAt = run.Status, run.StartedAt, run.FinishedAt
+		prompt, response, runErr = run.PromptSent, run.ResponseReceived, run.Error
+	case sharedRunWebhook:
+		var run models.WebhookRun
+		if err := s.db.First(&run, "id = ?", runID).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "shared run not found")
+		}
+		var webhook models.Webhook
+		s.db.Select("name", "team_id").First(&webhook, "id = ?", run.WebhookID)
+		t.Name, teamID = webhook.Name, webhook.TeamID
+		t.Status, t.StartedAt, t.FinishedAt = run.Status, run.StartedAt, run.FinishedAt
+		prompt, response, runErr = run.PromptSent, run.ResponseReceived, run.Error
+	default:
+		return fiber.NewError(fiber.StatusNotFound, "shared run not found")
+	}
+
+	var team models.Team
+	if teamID != "" {
+		s.db.Select("name").First(&team, "id = ?", teamID)
+	}
+	t.TeamName = team.Name
+
+	t.Entries = []TranscriptEntry{{Role: "user", Content: prompt, Timestamp: t.StartedAt}}
+	if response != "" || runErr != "" {
+		finished := t.StartedAt
+		if t.FinishedAt != nil {
+			finished = *t.FinishedAt
+		}
+		t.Entries = append(t.Entries, TranscriptEntry{
+			Role:      "leader",
+			Content:   response,
+			Status:    t.Status,
+			Error:     runErr,
+			Timestamp: finished,
+		})
+	}
+
+	// Keep shared transcripts out of shared caches, which could serve them
+	// after the link expires.
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.JSON(t)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestShareToken(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	now := time.Now()
+	claims := shareClaims{LinkID: "link-1", Kind: sharedRunWebhook, RunID: "run-1", ExpiresAt: now.Add(time.Hour)}
+	token := srv.signShareToken(claims)
+
+	got, err := srv.parseShareToken(token, now)
+	if err != nil || got.LinkID != "link-1" || got.Kind != sharedRunWebhook || got.RunID != "run-1" {
+		t.Fatalf("parse: got (%+v, %v)", got, err)
+	}
+	if _, err := srv.parseShareToken(token, now.Add(2*time.Hour)); !errors.Is(err, errExpiredShareToken) {
+		t.Errorf("expired token: got %v, want errExpiredShareToken", err)
+	}
+
+	// Tampering with the payload invalidates the signature.
+	claims.RunID = "run-2"
+	forged := srv.signShareToken(claims)
+	tampered := forged[:len(forged)/2] + token[len(token)/2:]
+	if _, err := srv.parseShareToken(tampered, now); !errors.Is(err, errInvalidShareToken) {
+		t.Errorf("tampered token: got %v, want errInvalidShareToken", err)
+	}
+
+	// A token signed with another secret is rejected.
+	other, _ := setupTestServer(t)
+	other.SetShareLinkSecret("another-secret")
+	if _, err := other.parseShareToken(token, now); !errors.Is(err, errInvalidShareToken) {
+		t.Errorf("foreign token: got %v, want errInvalidShareToken", err)
+	}
+}
+
+func TestShareRun(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "share-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	createRec := doRequest(srv, "POST", "/api/schedules", CreateScheduleRequest{
+		Name: "nightly-report", TeamID: team.ID, Prompt: "summarize", CronExpression: "0 * * * *",
+	})
+	var schedule models.Schedule
+	parseJSON(t, createRec, &schedule)
+
+	finished := time.Now()
+	srv.db.Create(&models.ScheduleRun{
+		ID: "share-run", ScheduleID: schedule.ID, Status: "success", StartedAt: finished.Add(-time.Minute),
+		FinishedAt: &finished, PromptSent: "summarize the day", ResponseReceived: "All green.",
+	})
+
+	if rec := doRequest(srv, "POST", "/api/runs/missing/share", nil); rec.Code != 404 {
+		t.Errorf("unknown run: got %d, want 404", rec.Code)
+	}
+	if rec := doRequest(srv, "POST", "/api/runs/share-run/share", ShareRunRequest{ExpiresInHours: maxShareLinkHours + 1}); rec.Code != 400 {
+		t.Errorf("expiry over limit: got %d, want 400", rec.Code)
+	}
+
+	rec := doRequest(srv, "POST", "/api/runs/share-run/share", ShareRunRequest{ExpiresInHours: 2})
+	if rec.Code != 201 {
+		t.Fatalf("share: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var link ShareRunResponse
+	parseJSON(t, rec, &link)
+	if until := time.Until(link.ExpiresAt); until < time.Hour || until > 2*time.Hour {
+		t.Errorf("expires_at %v is not about 2 hours from now", link.ExpiresAt)
+	}
+
+	rec = doRequest(srv, "GET", link.URL, nil)
+	if rec.Code != 200 {
+		t.Fatalf("shared run: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var transcript SharedRunTranscript
+	parseJSON(t, rec, &transcript)
+	if transcript.Kind != sharedRunSchedule || transcript.Name != "nightly-report" || transcript.TeamName != "share-team" {
+		t.Errorf("transcript header: got %+v", transcript)
+	}
+	if len(transcript.Entries) != 2 || transcript.Entries[0].Content != "summarize the day" ||
+		transcript.Entries[1].Content != "All green." || transcript.Entries[1].Status != "success" {
+		t.Errorf("transcript entries: got %+v", transcript.Entries)
+	}
+
+	if rec := doRequest(srv, "GET", "/api/shared/not-a-token", nil); rec.Code != 404 {
+		t.Errorf("invalid token: got %d, want 404", rec.Code)
+	}
+	expired := srv.signShareToken(shareClaims{LinkID: link.ID, Kind: sharedRunSchedule, RunID: "share-run", ExpiresAt: time.Now().Add(-time.Minute)})
+	if rec := doRequest(srv, "GET", "/api/shared/"+expired, nil); rec.Code != 410 {
+		t.Errorf("expired token: got %d, want 410", rec.Code)
+	}
+
+	// A token whose link was never recorded does not open the run.
+	unknown := srv.signShareToken(shareClaims{LinkID: "unknown", Kind: sharedRunSchedule, RunID: "share-run", ExpiresAt: link.ExpiresAt})
+	if rec := doRequest(srv, "GET", "/api/shared/"+unknown, nil); rec.Code != 404 {
+		t.Errorf("unrecorded link: got %d, want 404", rec.Code)
+	}
+
+	// Revoking the run's links closes them before they expire.
+	if rec := doRequest(srv, "DELETE", "/api/runs/missing/share", nil); rec.Code != 404 {
+		t.Errorf("revoke unknown run: got %d, want 404", rec.Code)
+	}
+	if rec := doRequest(srv, "DELETE", "/api/runs/share-run/share", nil); rec.Code != 204 {
+		t.Fatalf("revoke: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(srv, "GET", link.URL, nil); rec.Code != 404 {
+		t.Errorf("revoked link: got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Sources of a skill's required tools.
+const (
+	// SkillToolsCatalog is the required_tools of the skills catalog entry.
+	SkillToolsCatalog = "catalog"
+	// SkillToolsReported is the allowed-tools of the skill's SKILL.md, as
+	// reported by an agent that installed it.
+	SkillToolsReported = "reported"
+	// SkillToolsUnknown is a skill whose required tools are not known yet.
+	SkillToolsUnknown = "unknown"
+)
+
+// SuggestSkillPermissionsRequest is the payload for
+// POST /api/skills/permissions.
+type SuggestSkillPermissionsRequest struct {
+	// Skills uses the formats of an agent's sub_agent_skills.
+	Skills      interface{} `json:"skills"`
+	Permissions interface{} `json:"permissions"`
+}
+
+// SkillTools is the tools a skill needs.
+type SkillTools struct {
+	Skill         string   `json:"skill"`
+	RequiredTools []string `json:"required_tools"`
+	Source        string   `json:"source"`
+}
+
+// SuggestSkillPermissionsResponse is the response of
+// POST /api/skills/permissions.
+type SuggestSkillPermissionsResponse struct {
+	Skills []SkillTools `json:"skills"`
+	permissions.Suggestion
+}
+
+// recordSkillToolRequirements saves the required tools that a skill_status
+// message reports for the skills the agent installed.
+func (s *Server) recordSkillToolRequirements(teamID string, msg protocol.Message) {
+	var payload protocol.SkillStatusPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return
+	}
+	var team models.Team
+	if err := s.db.Select("id", "org_id").First(&team, "id = ?", teamID).Error; err != nil {
+		return
+	}
+	for _, result := range payload.Skills {
+		idx := strings.LastIndex(result.Package, ":")
+		if result.Status != "installed" || len(result.RequiredTools) == 0 || idx <= 0 {
+			continue
+		}
+		tools, _ := json.Marshal(result.RequiredTools)
+		req := models.SkillToolRequirement{
+			OrgID:     team.OrgID,
+			RepoURL:   result.Package[:idx],
+			SkillName: result.Package[idx+1:],
+			Tools:     models.JSON(tools),
+			UpdatedAt: time.Now(),
+		}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "org_id"}, {Name: "repo_url"}, {Name: "skill_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"tools", "updated_at"}),
+		}).Create(&req).Error; err != nil {
+			slog.Error("relay: failed to save skill tool requirements", "package", result.Package, "error", err)
+		}
+	}
+}
+
+// skillToolRequirements returns the tools the skills known to the
+// organization need, by "repo_url:skill_name": the required_tools of its
+// skills catalog, or else the tools its agents reported.
+func (s *Server) skillToolRequirements(orgID string) (map[string]SkillTools, error) {
+	tools := make(map[string]SkillTools)
+	var reported []models.SkillToolRequirement
+	if err := s.db.Where("org_id = ?", orgID).Find(&reported).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load skill requirements")
+	}
+	for _, r := range reported {
+		var required []string
+		if json.Unmarshal(r.Tools, &required) == nil && len(required) > 0 {
+			key := r.RepoURL + ":" + r.SkillName
+			tools[key] = SkillTools{Skill: key, RequiredTools: required, Source: SkillToolsReported}
+		}
+	}
+
+	var catalog []models.SkillCatalogEntry
+	if err := s.db.Select("repo_url", "skill_name", "required_tools").Where("org_id = ?", orgID).
+		Find(&catalog).Error; err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to load skills catalog")
+	}
+	for _, e := range catalog {
+		var required []string
+		if json.Unmarshal(e.RequiredTools, &required) == nil && len(required) > 0 {
+			key := e.RepoURL + ":" + e.SkillName
+			tools[key] = SkillTools{Skill: key, RequiredTools: required, Source: SkillToolsCatalog}
+		}
+	}
+	return tools, nil
+}
+
+// agentSkillTools returns the tools each of an agent's skills needs, by
+// "repo_url:skill_name". Skills whose tools are unknown are listed with
+// SkillToolsUnknown.
+func agentSkillTools(subAgentSkills models.JSON, known map[string]SkillTools) []SkillTools {
+	var tools []SkillTools
+	for _, skill := range teamSkillConfigs([]models.Agent{{SubAgentSkills: subAgentSkills}}) {
+		key := skill.RepoURL + ":" + skill.SkillName
+		st, ok := known[key]
+		if !ok {
+			st = SkillTools{Skill: key, RequiredTools: []string{}, Source: SkillToolsUnknown}
+		}
+		tools = append(tools, st)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Skill < tools[j].Skill })
+	return tools
+}
+
+// SuggestSkillPermissions handles POST /api/skills/permissions. It returns
+// the tools the given skills need and the smallest change to the given
+// permissions that allows them: the tools and commands to add and the
+// merged permissions. Nothing is saved; the client confirms the
+// suggestion by saving the merged permissions on the agent.
+func (s *Server) SuggestSkillPermissions(c *fiber.Ctx) error {
+	var req SuggestSkillPermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if err := validateSubAgentSkills(req.Skills); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	perms, err := marshalPermissions(req.Permissions)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	var config permissions.PermissionConfig
+	if string(perms) != "null" {
+		if err := json.Unmarshal(perms, &config); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "permissions: "+err.Error())
+		}
+	}
+
+	known, err := s.skillToolRequirements(GetOrgID(c))
+	if err != nil {
+		return err
+	}
+	skills, _ := json.Marshal(req.Skills)
+	resp := SuggestSkillPermissionsResponse{Skills: agentSkillTools(models.JSON(skills), known)}
+	if resp.Skills == nil {
+		resp.Skills = []SkillTools{}
+	}
+	var rules []string
+	for _, st := range resp.Skills {
+		rules = append(rules, st.RequiredTools...)
+	}
+	resp.Suggestion = permissions.Suggest(config, rules)
+	return c.JSON(resp)
+}
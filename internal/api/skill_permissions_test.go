@@ -0,0 +1,68 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestSuggestSkillPermissions(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	team := createTestTeam(t, srv, "skills-team")
+
+	doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{
+		RepoURL: "https://github.com/acme/skills", SkillName: "release", RequiredTools: []string{"Read", "Bash(git tag:*)"},
+	})
+	// An agent reports the allowed-tools of the skills it installed.
+	data := buildRelayPayload(t, protocol.TypeSkillStatus, "leader", "system", protocol.SkillStatusPayload{
+		AgentName: "leader",
+		Skills: []protocol.SkillInstallResult{
+			{Package: "https://github.com/acme/skills:docs", Status: "installed", RequiredTools: []string{"Write", "Edit"}},
+			{Package: "https://github.com/acme/skills:release", Status: "installed", RequiredTools: []string{"Bash"}},
+			{Package: "https://github.com/acme/skills:broken", Status: "failed", RequiredTools: []string{"WebFetch"}},
+		},
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+
+	rec := doRequest(srv, "POST", "/api/skills/permissions", SuggestSkillPermissionsRequest{
+		Skills: []string{"acme/skills:release", "acme/skills:docs", "acme/skills:broken"},
+		Permissions: map[string]interface{}{
+			"allowed_tools":    []string{"Read"},
+			"allowed_commands": []string{"npm *"},
+		},
+	})
+	if rec.Code != 200 {
+		t.Fatalf("suggest: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var resp SuggestSkillPermissionsResponse
+	parseJSON(t, rec, &resp)
+
+	sources := make(map[string]string)
+	for _, st := range resp.Skills {
+		sources[st.Skill] = st.Source
+	}
+	want := map[string]string{
+		"https://github.com/acme/skills:broken":  SkillToolsUnknown,
+		"https://github.com/acme/skills:docs":    SkillToolsReported,
+		"https://github.com/acme/skills:release": SkillToolsCatalog,
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("skill sources: got %v, want %v", sources, want)
+	}
+	if want := []string{"Write", "Edit", "Bash"}; !reflect.DeepEqual(resp.AddTools, want) {
+		t.Errorf("add_tools: got %q, want %q", resp.AddTools, want)
+	}
+	if want := []string{"git tag*"}; !reflect.DeepEqual(resp.AddCommands, want) {
+		t.Errorf("add_commands: got %q, want %q", resp.AddCommands, want)
+	}
+	if want := []string{"Read", "Write", "Edit", "Bash"}; !reflect.DeepEqual(resp.Config.AllowedTools, want) || len(resp.Findings) != 0 {
+		t.Errorf("merged permissions: got %+v, findings %+v", resp.Config, resp.Findings)
+	}
+
+	if rec := doRequest(srv, "POST", "/api/skills/permissions", SuggestSkillPermissionsRequest{Skills: []string{"bad;skill:x"}}); rec.Code != 400 {
+		t.Errorf("invalid skills: got %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Page sizes of GET /api/skills/catalog, which serves autocomplete.
+const (
+	defaultSkillCatalogLimit = 20
+	maxSkillCatalogLimit     = 100
+)
+
+// likeEscaper escapes the LIKE wildcards in a search term.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListSkillCatalog searches the organization's skills catalog. The q query
+// parameter matches skill names, repository URLs and descriptions, ignoring
+// case; limit caps the number of results.
+func (s *Server) ListSkillCatalog(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", defaultSkillCatalogLimit)
+	if limit < 1 || limit > maxSkillCatalogLimit {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxSkillCatalogLimit))
+	}
+
+	query := s.db.Scopes(OrgScope(c))
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(q)) + "%"
+		query = query.Where(`LOWER(skill_name) LIKE ? ESCAPE '\' OR LOWER(repo_url) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\'`,
+			pattern, pattern, pattern)
+	}
+
+	list := []models.SkillCatalogEntry{}
+	if err := query.Order("skill_name, repo_url").Limit(limit).Find(&list).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to search skills catalog")
+	}
+	return c.JSON(list)
+}
+
+// CreateSkillCatalogEntry adds a skill to the catalog, or updates the
+// description and required tools of a skill already in it (admin only).
+func (s *Server) CreateSkillCatalogEntry(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage the skills catalog")
+	}
+	var req CreateSkillCatalogEntryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if err := validateSingleSkillConfig(req.RepoURL, req.SkillName); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if len(req.Description) > 1024 {
+		return fiber.NewError(fiber.StatusBadRequest, "description must be at most 1024 characters")
+	}
+	for _, tool := range req.RequiredTools {
+		if permissions.ToolName(tool) == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "required_tools must not contain empty tool names")
+		}
+	}
+	requiredTools, _ := json.Marshal(req.RequiredTools)
+
+	entry := models.SkillCatalogEntry{
+		ID:            uuid.New().String(),
+		OrgID:         GetOrgID(c),
+		RepoURL:       req.RepoURL,
+		SkillName:     req.SkillName,
+		Description:   req.Description,
+		RequiredTools: models.JSON(requiredTools),
+	}
+	if err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}, {Name: "repo_url"}, {Name: "skill_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description", "required_tools", "updated_at"}),
+	}).Create(&entry).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to save skill")
+	}
+
+	// The ID differs from the generated one when an existing entry was updated.
+	var saved models.SkillCatalogEntry
+	if err := s.db.Scopes(OrgScope(c)).Where("repo_url = ? AND skill_name = ?", entry.RepoURL, entry.SkillName).
+		First(&saved).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to save skill")
+	}
+	return c.Status(fiber.StatusCreated).JSON(saved)
+}
+
+// DeleteSkillCatalogEntry removes a skill from the catalog (admin only).
+func (s *Server) DeleteSkillCatalogEntry(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can manage the skills catalog")
+	}
+	result := s.db.Scopes(OrgScope(c)).Delete(&models.SkillCatalogEntry{}, "id = ?", c.Params("id"))
+	if result.Error != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete skill")
+	}
+	if result.RowsAffected == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "skill not found")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// checkSkillsCatalog returns a 400 error naming the skills of the team that
+// are missing from its organization's skills catalog. Organizations with an
+// empty catalog can deploy any skill.
+func (s *Server) checkSkillsCatalog(team models.Team) error {
+	skills := teamSkillConfigs(team.Agents)
+	if len(skills) == 0 {
+		return nil
+	}
+	var catalog []models.SkillCatalogEntry
+	if err := s.db.Select("repo_url", "skill_name").Where("org_id = ?", team.OrgID).Find(&catalog).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check skills catalog")
+	}
+	if len(catalog) == 0 {
+		return nil
+	}
+
+	known := make(map[protocol.SkillConfig]bool, len(catalog))
+	for _, e := range catalog {
+		known[protocol.SkillConfig{RepoURL: e.RepoURL, SkillName: e.SkillName}] = true
+	}
+	var missing []string
+	for _, skill := range skills {
+		if !known[skill] {
+			missing = append(missing, skill.RepoURL+":"+skill.SkillName)
+		}
+	}
+	if len(missing) > 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "skills not found in the skills catalog: "+strings.Join(missing, ", "))
+	}
+	return nil
+}
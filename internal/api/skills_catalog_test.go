@@ -0,0 +1,125 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestSkillCatalog(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	for _, req := range []CreateSkillCatalogEntryRequest{
+		{RepoURL: "https://github.com/acme/skills", SkillName: "pdf-tools", Description: "Read and fill PDF forms"},
+		{RepoURL: "https://github.com/acme/skills", SkillName: "changelog", Description: "Write release notes"},
+		{RepoURL: "https://github.com/other/web", SkillName: "frontend-design"},
+	} {
+		rec := doRequest(srv, "POST", "/api/skills/catalog", req)
+		if rec.Code != 201 {
+			t.Fatalf("create %s: got %d, want 201\nbody: %s", req.SkillName, rec.Code, rec.Body.String())
+		}
+	}
+
+	search := func(query string) []models.SkillCatalogEntry {
+		t.Helper()
+		rec := doRequest(srv, "GET", "/api/skills/catalog"+query, nil)
+		if rec.Code != 200 {
+			t.Fatalf("search %q: got %d, want 200\nbody: %s", query, rec.Code, rec.Body.String())
+		}
+		var list []models.SkillCatalogEntry
+		parseJSON(t, rec, &list)
+		return list
+	}
+
+	if got := search(""); len(got) != 3 || got[0].SkillName != "changelog" {
+		t.Errorf("full catalog: %+v", got)
+	}
+	if got := search("?q=PDF"); len(got) != 1 || got[0].SkillName != "pdf-tools" {
+		t.Errorf("search by description: %+v", got)
+	}
+	if got := search("?q=acme"); len(got) != 2 {
+		t.Errorf("search by repository: %+v", got)
+	}
+	if got := search("?q=%25"); len(got) != 0 {
+		t.Errorf("wildcards are matched literally, got %+v", got)
+	}
+	if got := search("?limit=1"); len(got) != 1 {
+		t.Errorf("limit: %+v", got)
+	}
+	if rec := doRequest(srv, "GET", "/api/skills/catalog?limit=500", nil); rec.Code != 400 {
+		t.Errorf("limit too large: got %d, want 400", rec.Code)
+	}
+
+	// Adding a skill again updates its description and required tools.
+	rec := doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{
+		RepoURL: "https://github.com/other/web", SkillName: "frontend-design", Description: "Polished UIs",
+		RequiredTools: []string{"Write", "Bash(npm:*)"},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("upsert: got %d, want 201", rec.Code)
+	}
+	var entry models.SkillCatalogEntry
+	parseJSON(t, rec, &entry)
+	if entry.Description != "Polished UIs" || string(entry.RequiredTools) != `["Write","Bash(npm:*)"]` || len(search("")) != 3 {
+		t.Errorf("upsert: %+v", entry)
+	}
+
+	rec = doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{RepoURL: "http://github.com/a/b", SkillName: "x"})
+	if rec.Code != 400 {
+		t.Errorf("invalid repo_url: got %d, want 400", rec.Code)
+	}
+	rec = doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{
+		RepoURL: "https://github.com/a/b", SkillName: "x", RequiredTools: []string{" "},
+	})
+	if rec.Code != 400 {
+		t.Errorf("empty required tool: got %d, want 400", rec.Code)
+	}
+
+	if rec := doRequest(srv, "DELETE", "/api/skills/catalog/"+entry.ID, nil); rec.Code != 204 {
+		t.Errorf("delete: got %d, want 204", rec.Code)
+	}
+	if rec := doRequest(srv, "DELETE", "/api/skills/catalog/"+entry.ID, nil); rec.Code != 404 {
+		t.Errorf("delete again: got %d, want 404", rec.Code)
+	}
+}
+
+func TestDeployTeam_SkillsCatalog(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name: "catalog-team",
+		Agents: []CreateAgentInput{
+			{Name: "lead", Role: "leader"},
+			{Name: "writer", SubAgentSkills: []map[string]string{
+				{"repo_url": "https://github.com/acme/skills", "skill_name": "changelog"},
+			}},
+			{Name: "legacy", SubAgentSkills: []string{"Read", "acme/skills:pdf-tools"}},
+		},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	// Without a catalog any skill can be deployed.
+	if err := srv.checkSkillsCatalog(team); err != nil {
+		t.Fatalf("empty catalog: %v", err)
+	}
+
+	doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{
+		RepoURL: "https://github.com/acme/skills", SkillName: "changelog",
+	})
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/deploy", nil)
+	if rec.Code != 400 {
+		t.Fatalf("status: got %d, want 400\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if body := rec.Body.String(); !strings.Contains(body, "https://github.com/acme/skills:pdf-tools") || strings.Contains(body, "changelog") {
+		t.Errorf("error should name only the missing skill: %s", body)
+	}
+
+	doRequest(srv, "POST", "/api/skills/catalog", CreateSkillCatalogEntryRequest{
+		RepoURL: "https://github.com/acme/skills", SkillName: "pdf-tools",
+	})
+	if err := srv.checkSkillsCatalog(team); err != nil {
+		t.Errorf("all skills in catalog: %v", err)
+	}
+}
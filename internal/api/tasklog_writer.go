@@ -0,0 +1,244 @@
+package api
+
+import (
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// taskLogBatchSize caps how many queued TaskLog rows are committed in one
+// transaction.
+const taskLogBatchSize = 100
+
+// taskLogFlushInterval is how long a batch of asynchronous inserts waits for
+// more rows before it is committed. A blocking insert commits its batch at
+// once.
+var taskLogFlushInterval = 25 * time.Millisecond
+
+// taskLogWrite is a TaskLog insert waiting for the writer, with the function
+// its result is reported to. Blocking inserts wait for that result.
+type taskLogWrite struct {
+	log      *models.TaskLog
+	done     func(error)
+	blocking bool
+}
+
+// taskLogWriter serializes TaskLog inserts through a single goroutine.
+// SQLite allows one writer at a time, so funnelling relay and chat inserts
+// through one connection avoids "database is locked" errors under load.
+// Inserts that queue up while a batch is being written are committed
+// together in the next transaction. An idle writer commits a blocking
+// insert immediately; asynchronous inserts, such as the activity events of
+// a verbose tool-use stream, wait up to taskLogFlushInterval to fill a
+// batch. Rows are committed in the order they were queued.
+type taskLogWriter struct {
+	db    *gorm.DB
+	queue chan taskLogWrite
+
+	// mu guards stopped: Create holds it for reading while enqueueing so
+	// that nothing is queued after the loop has drained and exited.
+	mu      sync.RWMutex
+	stopped bool
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	batches    atomic.Int64
+	rows       atomic.Int64
+	asyncRows  atomic.Int64
+	errors     atomic.Int64
+	writeNanos atomic.Int64
+	maxNanos   atomic.Int64
+}
+
+// TaskLogWriterStats reports the activity of the TaskLog writer.
+type TaskLogWriterStats struct {
+	Queued  int   `json:"queued"`
+	Batches int64 `json:"batches"`
+	Rows    int64 `json:"rows"`
+	// AsyncRows counts the rows queued without waiting for their commit.
+	AsyncRows int64 `json:"async_rows"`
+	Errors    int64 `json:"errors"`
+	// WriteMs is the time spent committing batches, and MaxBatchMs the
+	// longest commit of one batch.
+	WriteMs    float64 `json:"write_ms"`
+	MaxBatchMs float64 `json:"max_batch_ms"`
+}
+
+// newTaskLogWriter starts the writer goroutine.
+func newTaskLogWriter(db *gorm.DB) *taskLogWriter {
+	w := &taskLogWriter{
+		db:    db,
+		queue: make(chan taskLogWrite, taskLogBatchSize*4),
+		stop:  make(chan struct{}),
+	}
+	w.wg.Add(1)
+	go w.loop()
+	return w
+}
+
+// Create inserts log and blocks until it is committed, returning the insert
+// error. It falls back to a direct insert once the writer is stopped.
+func (w *taskLogWriter) Create(log *models.TaskLog) error {
+	errc := make(chan error, 1)
+	w.enqueue(taskLogWrite{log: log, done: func(err error) { errc <- err }, blocking: true})
+	return <-errc
+}
+
+// CreateAsync queues log for insertion and returns at once. done is called
+// with the insert error once the row's batch is committed, on the writer's
+// goroutine, so it must not queue another insert.
+func (w *taskLogWriter) CreateAsync(log *models.TaskLog, done func(error)) {
+	w.asyncRows.Add(1)
+	w.enqueue(taskLogWrite{log: log, done: done})
+}
+
+func (w *taskLogWriter) enqueue(req taskLogWrite) {
+	w.mu.RLock()
+	if w.stopped {
+		w.mu.RUnlock()
+		req.done(createTaskLog(w.db, req.log))
+		return
+	}
+	w.queue <- req
+	w.mu.RUnlock()
+}
+
+// Stop finishes pending writes and stops the writer goroutine.
+func (w *taskLogWriter) Stop() {
+	w.mu.Lock()
+	if !w.stopped {
+		w.stopped = true
+		close(w.stop)
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+}
+
+// Stats returns the writer's counters.
+func (w *taskLogWriter) Stats() TaskLogWriterStats {
+	return TaskLogWriterStats{
+		Queued:  len(w.queue),
+		Batches: w.batches.Load(),
+		Rows:    w.rows.Load(),
+		Errors:  w.errors.Load(),
+
+		AsyncRows: w.asyncRows.Load(),
+
+		WriteMs:    float64(w.writeNanos.Load()) / float64(time.Millisecond),
+		MaxBatchMs: float64(w.maxNanos.Load()) / float64(time.Millisecond),
+	}
+}
+
+func (w *taskLogWriter) loop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case req := <-w.queue:
+			w.write(w.drain(req))
+		case <-w.stop:
+			// Requests queued before Stop are still waiting.
+			for {
+				select {
+				case req := <-w.queue:
+					w.write(w.drain(req))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// drain collects first plus whatever else is already queued, up to
+// taskLogBatchSize. A batch of only asynchronous inserts then waits up to
+// taskLogFlushInterval for more, until a blocking insert arrives or the
+// writer stops.
+func (w *taskLogWriter) drain(first taskLogWrite) []taskLogWrite {
+	batch := []taskLogWrite{first}
+	blocking := first.blocking
+	var linger <-chan time.Time
+	for len(batch) < taskLogBatchSize {
+		select {
+		case req := <-w.queue:
+			batch = append(batch, req)
+			blocking = blocking || req.blocking
+			continue
+		default:
+		}
+		if blocking {
+			return batch
+		}
+		if linger == nil {
+			timer := time.NewTimer(taskLogFlushInterval)
+			defer timer.Stop()
+			linger = timer.C
+		}
+		select {
+		case req := <-w.queue:
+			batch = append(batch, req)
+			blocking = req.blocking
+		case <-linger:
+			return batch
+		case <-w.stop:
+			return batch
+		}
+	}
+	return batch
+}
+
+// write commits batch in one transaction. If the transaction fails, each row
+// is retried on its own so one bad row does not fail its neighbours.
+func (w *taskLogWriter) write(batch []taskLogWrite) {
+	w.batches.Add(1)
+	start := time.Now()
+	defer func() {
+		d := int64(time.Since(start))
+		w.writeNanos.Add(d)
+		if d > w.maxNanos.Load() {
+			w.maxNanos.Store(d)
+		}
+	}()
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		for _, req := range batch {
+			if err := createTaskLog(tx, req.log); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		w.rows.Add(int64(len(batch)))
+		for _, req := range batch {
+			req.done(nil)
+		}
+		return
+	}
+	if len(batch) == 1 {
+		w.errors.Add(1)
+		batch[0].done(err)
+		return
+	}
+	slog.Warn("task log writer: batch failed, retrying rows individually", "rows", len(batch), "error", err)
+
+	for _, req := range batch {
+		err := createTaskLog(w.db, req.log)
+		if err != nil {
+			w.errors.Add(1)
+		} else {
+			w.rows.Add(1)
+		}
+		req.done(err)
+	}
+}
+
+// createTaskLog inserts log, unless a row with its ID exists: relayed
+// messages delivered again map to the ID of their first delivery.
+func createTaskLog(db *gorm.DB, log *models.TaskLog) error {
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(log).Error
+}
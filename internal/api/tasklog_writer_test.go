@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestTaskLogWriter_ConcurrentCreates(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "writer-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- srv.taskLogs.Create(&models.TaskLog{
+				ID:          uuid.New().String(),
+				TeamID:      team.ID,
+				MessageType: "leader_response",
+				Payload:     models.JSON(fmt.Sprintf(`{"n":%d}`, i)),
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	if count := countRelayLogs(t, srv, team.ID); count != n {
+		t.Errorf("task logs: got %d, want %d", count, n)
+	}
+	stats := srv.taskLogs.Stats()
+	if stats.Rows != n {
+		t.Errorf("rows: got %d, want %d", stats.Rows, n)
+	}
+	if stats.Batches < 1 || stats.Batches > n {
+		t.Errorf("batches: got %d, want between 1 and %d", stats.Batches, n)
+	}
+	if stats.WriteMs <= 0 || stats.MaxBatchMs <= 0 || stats.MaxBatchMs > stats.WriteMs {
+		t.Errorf("write time: got %.3fms, longest batch %.3fms", stats.WriteMs, stats.MaxBatchMs)
+	}
+}
+
+func TestTaskLogWriter_ReportsRowError(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "writer-err-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	// A log with an ID already saved is skipped, as relayed messages
+	// delivered again are; a row the database rejects is reported.
+	log := models.TaskLog{ID: uuid.New().String(), TeamID: team.ID, MessageType: "leader_response"}
+	if err := srv.taskLogs.Create(&log); err != nil {
+		t.Fatalf("first Create: %v", err)
+	}
+	dup := models.TaskLog{ID: log.ID, TeamID: team.ID, MessageType: "leader_response"}
+	if err := srv.taskLogs.Create(&dup); err != nil {
+		t.Fatalf("duplicate Create: %v", err)
+	}
+	srv.db.Migrator().DropTable(&models.TaskLog{})
+	bad := models.TaskLog{ID: uuid.New().String(), TeamID: team.ID, MessageType: "leader_response"}
+	if err := srv.taskLogs.Create(&bad); err == nil {
+		t.Fatal("expected missing table error")
+	}
+	if got := srv.taskLogs.Stats().Errors; got != 1 {
+		t.Errorf("errors: got %d, want 1", got)
+	}
+}
+
+func TestTaskLogWriter_CreateAfterStop(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "writer-stop-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	srv.taskLogs.Stop()
+	srv.taskLogs.Stop()
+
+	log := models.TaskLog{ID: uuid.New().String(), TeamID: team.ID, MessageType: "leader_response"}
+	if err := srv.taskLogs.Create(&log); err != nil {
+		t.Fatalf("Create after Stop: %v", err)
+	}
+	if count := countRelayLogs(t, srv, team.ID); count != 1 {
+		t.Errorf("task logs: got %d, want 1", count)
+	}
+}
+
+func TestTaskLogWriter_AsyncBatching(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "writer-async-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	// Asynchronous inserts queued one at a time still share a batch.
+	const n = 20
+	var (
+		mu   sync.Mutex
+		done []int
+	)
+	for i := 0; i < n; i++ {
+		srv.taskLogs.CreateAsync(&models.TaskLog{
+			ID:          uuid.New().String(),
+			TeamID:      team.ID,
+			MessageType: "activity_event",
+		}, func(err error) {
+			if err != nil {
+				t.Errorf("CreateAsync %d: %v", i, err)
+			}
+			mu.Lock()
+			done = append(done, i)
+			mu.Unlock()
+		})
+	}
+	// A blocking insert commits after the rows queued before it.
+	if err := srv.taskLogs.Create(&models.TaskLog{ID: uuid.New().String(), TeamID: team.ID, MessageType: "leader_response"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(done) != n {
+		t.Fatalf("completed async inserts: got %d, want %d", len(done), n)
+	}
+	for i, got := range done {
+		if got != i {
+			t.Fatalf("completion order: got %v", done)
+		}
+	}
+	if count := countRelayLogs(t, srv, team.ID); count != n+1 {
+		t.Errorf("task logs: got %d, want %d", count, n+1)
+	}
+	stats := srv.taskLogs.Stats()
+	if stats.AsyncRows != n || stats.Batches >= n {
+		t.Errorf("stats: got %d async rows in %d batches, want %d in fewer batches", stats.AsyncRows, stats.Batches, n)
+	}
+}
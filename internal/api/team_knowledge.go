@@ -0,0 +1,150 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/rag"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// minKnowledgeResultSize is the shortest run result indexed in a team's
+// knowledge base; shorter ones are acknowledgements, not knowledge.
+const minKnowledgeResultSize = 200
+
+// knowledgeIndexTimeout bounds indexing one run result, including starting
+// Qdrant and Ollama and pulling the embedding model.
+const knowledgeIndexTimeout = 15 * time.Minute
+
+var knowledgeEntryListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status"},
+}
+
+func knowledgeEntryKey(e models.KnowledgeEntry) (time.Time, string) { return e.CreatedAt, e.ID }
+
+// recordRunKnowledge indexes the result of a completed run in its team's
+// knowledge base, if the team has one.
+func (s *Server) recordRunKnowledge(teamID, teamName string, msg protocol.Message) {
+	var payload protocol.LeaderResponsePayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil ||
+		payload.Status != "completed" || len(payload.Result) < minKnowledgeResultSize {
+		return
+	}
+	var team models.Team
+	if err := s.db.Select("id", "org_id", "knowledge_base").First(&team, "id = ?", teamID).Error; err != nil || !team.KnowledgeBase {
+		return
+	}
+
+	runID := payload.ScheduledRunID
+	if runID == "" {
+		runID = msg.MessageID
+	}
+	entry := models.KnowledgeEntry{
+		ID:      uuid.New().String(),
+		OrgID:   team.OrgID,
+		TeamID:  teamID,
+		RunID:   runID,
+		Content: payload.Result,
+		Status:  models.DocStatusPending,
+	}
+	if err := s.db.Create(&entry).Error; err != nil {
+		slog.Error("relay: failed to record knowledge entry", "team", teamName, "error", err)
+		return
+	}
+	go s.indexKnowledgeEntryAsync(entry)
+}
+
+// indexKnowledgeEntryAsync indexes a knowledge entry and records the outcome.
+func (s *Server) indexKnowledgeEntryAsync(entry models.KnowledgeEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), knowledgeIndexTimeout)
+	defer cancel()
+
+	s.db.Model(&entry).Update("status", models.DocStatusProcessing)
+	chunks, err := s.indexKnowledge(ctx, entry)
+	if err != nil {
+		slog.Error("knowledge entry indexing failed", "id", entry.ID, "team_id", entry.TeamID, "error", err)
+		s.db.Model(&entry).Updates(map[string]interface{}{
+			"status":         models.DocStatusError,
+			"status_message": err.Error(),
+		})
+		return
+	}
+	s.db.Model(&entry).Updates(map[string]interface{}{
+		"status":         models.DocStatusReady,
+		"status_message": "",
+		"chunk_count":    chunks,
+	})
+}
+
+// indexKnowledgeEntry stores a knowledge entry's content in its team's
+// collection. It is the default Server.indexKnowledge.
+func (s *Server) indexKnowledgeEntry(ctx context.Context, entry models.KnowledgeEntry) (int, error) {
+	processor, err := s.knowledgeProcessor(ctx, func(string) {})
+	if err != nil {
+		return 0, err
+	}
+	return processor.IndexText(ctx, rag.TeamCollectionName(entry.TeamID), entry.Content, map[string]string{
+		"doc_id":    entry.ID,
+		"doc_name":  "Run " + entry.RunID,
+		"file_name": "run result",
+		"org_id":    entry.OrgID,
+		"team_id":   entry.TeamID,
+		"run_id":    entry.RunID,
+	})
+}
+
+// ListKnowledgeEntries handles GET /api/teams/:id/knowledge. It returns the
+// run results indexed in the team's knowledge base, newest first.
+func (s *Server) ListKnowledgeEntries(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	q, err := parseListQuery(c, knowledgeEntryListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", team.ID), q, knowledgeEntryKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list knowledge entries")
+	}
+	return c.JSON(resp)
+}
+
+// DeleteKnowledgeEntry handles DELETE /api/teams/:id/knowledge/:entryId. It
+// removes a run result from the team's knowledge base.
+func (s *Server) DeleteKnowledgeEntry(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	var entry models.KnowledgeEntry
+	if err := s.db.First(&entry, "id = ? AND team_id = ?", c.Params("entryId"), team.ID).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "knowledge entry not found")
+	}
+
+	// Delete vectors from Qdrant (best effort — Qdrant may not be running).
+	if qm, ok := s.sharedRuntime().(runtime.QdrantManager); ok {
+		if running, _ := qm.IsQdrantRunning(c.Context()); running {
+			qdrantClient := rag.NewQdrantClient(runtime.QdrantInternalURL)
+			if err := qdrantClient.DeleteByDocID(c.Context(), rag.TeamCollectionName(team.ID), entry.ID); err != nil {
+				slog.Error("failed to delete knowledge vectors from qdrant", "id", entry.ID, "error", err)
+			}
+		}
+	}
+
+	if err := s.db.Delete(&entry).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete knowledge entry")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestTeamKnowledge(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:          "knowledge-team",
+		KnowledgeBase: true,
+		Agents:        []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	other := createLabeledTeam(t, srv, "plain-team", nil)
+
+	// The leader gets the knowledge-base MCP server, scoped to the team.
+	srv.deployTeamAsync(t.Context(), team)
+	if mcp := mock.lastAgentConfig.Env["AGENT_MCP_SERVERS"]; !strings.Contains(mcp, `"X-Team-ID":"`+team.ID+`"`) {
+		t.Errorf("AGENT_MCP_SERVERS: got %s", mcp)
+	}
+
+	indexed := make(chan models.KnowledgeEntry, 4)
+	fail := false
+	srv.indexKnowledge = func(_ context.Context, entry models.KnowledgeEntry) (int, error) {
+		indexed <- entry
+		if fail {
+			return 0, errors.New("qdrant unavailable")
+		}
+		return 3, nil
+	}
+	result := strings.Repeat("The staging deploy needs the VPN. ", 10)
+	send := func(teamID, status, result, runID string) {
+		t.Helper()
+		data := buildRelayPayload(t, protocol.TypeLeaderResponse, "leader", "user", protocol.LeaderResponsePayload{
+			Status: status, Result: result, ScheduledRunID: runID,
+		})
+		if err := srv.processRelayMessage(teamID, "knowledge-team", data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+	waitEntry := func(status string) models.KnowledgeEntry {
+		t.Helper()
+		var entry models.KnowledgeEntry
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			srv.db.Order("created_at DESC").First(&entry, "team_id = ?", team.ID)
+			if entry.Status == status {
+				return entry
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("knowledge entry: got status %q, want %q", entry.Status, status)
+		return entry
+	}
+
+	// Short, failed and other teams' results are not indexed.
+	send(team.ID, "completed", "Done.", "")
+	send(team.ID, "failed", result, "")
+	send(other.ID, "completed", result, "")
+
+	send(team.ID, "completed", result, "run-1")
+	entry := waitEntry(models.DocStatusReady)
+	if entry.RunID != "run-1" || entry.ChunkCount != 3 || entry.Content != result {
+		t.Errorf("entry: got %+v", entry)
+	}
+	if got := <-indexed; got.ID != entry.ID {
+		t.Errorf("indexed entry: got %s, want %s", got.ID, entry.ID)
+	}
+
+	fail = true
+	send(team.ID, "completed", result, "run-2")
+	if failed := waitEntry(models.DocStatusError); failed.StatusMessage != "qdrant unavailable" {
+		t.Errorf("failed entry: got %+v", failed)
+	}
+
+	entries := parseList[models.KnowledgeEntry](t, doRequest(srv, "GET", "/api/teams/"+team.ID+"/knowledge?status=ready", nil))
+	if len(entries) != 1 || entries[0].ID != entry.ID {
+		t.Errorf("entries: got %+v", entries)
+	}
+	var count int64
+	srv.db.Model(&models.KnowledgeEntry{}).Count(&count)
+	if count != 2 {
+		t.Errorf("knowledge entries: got %d, want 2", count)
+	}
+
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/knowledge/"+entry.ID, nil); rec.Code != 204 {
+		t.Fatalf("delete: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/knowledge/"+entry.ID, nil); rec.Code != 404 {
+		t.Errorf("delete again: got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// maxTeamMemorySize caps a stored memory summary. The summary is passed to
+// the next deployment in an environment variable.
+const maxTeamMemorySize = 32 * 1024
+
+var teamMemoryListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+}
+
+func teamMemoryKey(m models.TeamMemory) (time.Time, string) { return m.CreatedAt, m.ID }
+
+// saveTeamMemory stores the summary a team's leader wrote as the team
+// stopped, for the team's next deployment.
+func (s *Server) saveTeamMemory(teamID, teamName, agentName, summary string) error {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return nil
+	}
+	if len(summary) > maxTeamMemorySize {
+		slog.Warn("relay: memory summary truncated", "team", teamName, "size", len(summary))
+		summary = strings.ToValidUTF8(summary[:maxTeamMemorySize], "")
+	}
+
+	team, _ := s.cachedTeam(teamID)
+
+	memory := models.TeamMemory{
+		ID:             uuid.New().String(),
+		TeamID:         teamID,
+		ConversationID: team.ConversationID,
+		AgentName:      agentName,
+		Summary:        summary,
+	}
+	if err := s.db.Create(&memory).Error; err != nil {
+		slog.Error("relay: failed to save memory summary", "team", teamName, "error", err)
+		return err
+	}
+	slog.Info("relay: saved memory summary", "team", teamName, "size", len(summary))
+	return nil
+}
+
+// ListTeamMemories handles GET /api/teams/:id/memories. It returns the
+// summaries the team's leader wrote when the team stopped, newest first;
+// the newest is given to the leader on the next deploy.
+func (s *Server) ListTeamMemories(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	q, err := parseListQuery(c, teamMemoryListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Where("team_id = ?", team.ID), q, teamMemoryKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list memories")
+	}
+	return c.JSON(resp)
+}
+
+// DeleteTeamMemories handles DELETE /api/teams/:id/memories. The team's
+// next deployment starts without memory.
+func (s *Server) DeleteTeamMemories(c *fiber.Ctx) error {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", c.Params("id")).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+	if err := s.db.Where("team_id = ?", team.ID).Delete(&models.TeamMemory{}).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to delete memories")
+	}
+	slog.Info("team memory cleared", "team", team.Name)
+	return c.SendStatus(fiber.StatusNoContent)
+}
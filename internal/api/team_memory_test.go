@@ -0,0 +1,76 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestTeamMemory(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "memory-team",
+		Memory: true,
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	if rec.Code != 201 {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	// The first deploy has nothing to remember yet.
+	srv.deployTeamAsync(t.Context(), team)
+	if got := mock.lastAgentConfig.Env["AGENT_MEMORY"]; got != "true" {
+		t.Errorf("AGENT_MEMORY: got %q, want true", got)
+	}
+	if _, ok := mock.lastAgentConfig.Env["AGENT_MEMORY_CONTEXT"]; ok {
+		t.Error("AGENT_MEMORY_CONTEXT should not be set without a memory")
+	}
+
+	for _, summary := range []string{"Old summary.", "The API is written in Go.", "   "} {
+		data := buildRelayPayload(t, protocol.TypeMemorySummary, "leader", "system", protocol.MemorySummaryPayload{
+			AgentName: "leader",
+			Summary:   summary,
+		})
+		if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+	memories := parseList[models.TeamMemory](t, doRequest(srv, "GET", "/api/teams/"+team.ID+"/memories", nil))
+	if len(memories) != 2 || memories[0].Summary != "The API is written in Go." || memories[0].AgentName != "leader" {
+		t.Errorf("memories: got %+v", memories)
+	}
+
+	// The next deploy gives the leader the latest summary.
+	srv.deployTeamAsync(t.Context(), team)
+	if got := mock.lastAgentConfig.Env["AGENT_MEMORY_CONTEXT"]; got != "The API is written in Go." {
+		t.Errorf("AGENT_MEMORY_CONTEXT: got %q", got)
+	}
+
+	// Oversized summaries are cut to the size limit.
+	data := buildRelayPayload(t, protocol.TypeMemorySummary, "leader", "system", protocol.MemorySummaryPayload{
+		Summary: strings.Repeat("x", maxTeamMemorySize+10),
+	})
+	if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+		t.Fatalf("processRelayMessage: %v", err)
+	}
+	if got := models.LatestTeamMemory(srv.db, team.ID); len(got) != maxTeamMemorySize {
+		t.Errorf("oversized summary: got %d bytes", len(got))
+	}
+
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/memories", nil); rec.Code != 204 {
+		t.Fatalf("delete: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	srv.db.Model(&team).Update("memory", false)
+	srv.deployTeamAsync(t.Context(), team)
+	if _, ok := mock.lastAgentConfig.Env["AGENT_MEMORY"]; ok {
+		t.Error("AGENT_MEMORY should not be set with memory disabled")
+	}
+	if rec := doRequest(srv, "GET", "/api/teams/missing/memories", nil); rec.Code != 404 {
+		t.Errorf("unknown team: got %d, want 404", rec.Code)
+	}
+}
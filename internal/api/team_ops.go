@@ -0,0 +1,334 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// Operations that change a team's infrastructure. Only one runs per team at
+// a time.
+const (
+	teamOpDeploy    = "deploy"
+	teamOpStop      = "stop"
+	teamOpDelete    = "delete"
+	teamOpRestart   = "restart"
+	teamOpUpgrade   = "upgrade"
+	teamOpRollback  = "rollback"
+	teamOpCleanup   = "infrastructure cleanup"
+	teamOpWorkspace = "workspace deletion"
+	teamOpMigrate   = "migration"
+	teamOpSync      = "workspace sync"
+)
+
+// teamOpForceWait bounds how long a forced operation waits for the
+// operation it cancelled to finish before taking over the team anyway.
+var teamOpForceWait = 30 * time.Second
+
+// Team operations hold a lease on the team in the database, so that they
+// also exclude each other across API replicas. The holder renews it every
+// third of teamOpLeaseTTL; a forced operation waiting for the team checks
+// whether it was freed every teamOpPollInterval.
+var (
+	teamOpLeaseTTL     = election.DefaultTTL
+	teamOpPollInterval = 250 * time.Millisecond
+)
+
+// Prefixes of the leases held by team operations, and of the leases through
+// which a forced operation asks another replica to cancel its operation.
+const (
+	teamOpLeasePrefix       = "team-op/"
+	teamOpCancelLeasePrefix = "team-op-cancel/"
+)
+
+// teamOp is an operation in progress on a team. Its context is cancelled
+// when a forced operation takes over the team, from this replica or
+// another one.
+type teamOp struct {
+	id      string
+	name    string
+	started time.Time
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// note describes op on its lease, for the operations it keeps waiting.
+func (op *teamOp) note() string {
+	return fmt.Sprintf("%s in progress since %s", op.name, op.started.UTC().Format(time.RFC3339))
+}
+
+// beginTeamOp claims teamID for the operation name. If another operation
+// holds the team it returns a 409 naming it, or, with force, cancels it and
+// waits up to teamOpForceWait for it to finish. The caller must call
+// endTeamOp when the operation is over.
+func (s *Server) beginTeamOp(teamID, name string, force bool) (*teamOp, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	op := &teamOp{id: uuid.New().String(), name: name, started: time.Now(), ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	lease := teamOpLeasePrefix + teamID
+
+	var deadline time.Time
+	for {
+		now := time.Now().UTC()
+		ok, err := election.Acquire(s.db, lease, op.id, teamOpLeaseTTL, now)
+		if err != nil {
+			cancel()
+			slog.Error("failed to claim team", "team_id", teamID, "operation", name, "error", err)
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to claim team")
+		}
+		if ok {
+			break
+		}
+		cur, err := election.Lookup(s.db, lease, now)
+		if err != nil || cur == nil {
+			// Freed, or unreadable: try again.
+			time.Sleep(teamOpPollInterval)
+			continue
+		}
+
+		if !force {
+			cancel()
+			busy := cur.Note
+			if busy == "" {
+				busy = "another operation in progress"
+			}
+			return nil, newAPIError(CodeTeamBusy, fmt.Sprintf(
+				"team is busy: %s; retry when it finishes or pass force=true to cancel it", busy))
+		}
+
+		if deadline.IsZero() {
+			slog.Warn("cancelling team operation", "team_id", teamID, "operation", cur.Note, "by", name)
+			deadline = time.Now().Add(teamOpForceWait)
+			s.cancelTeamOp(teamID, cur.Holder, op.id)
+			defer election.Release(s.db, teamOpCancelLeasePrefix+teamID, op.id)
+		}
+		if time.Now().After(deadline) {
+			slog.Warn("team operation did not stop; taking over", "team_id", teamID, "operation", cur.Note, "by", name)
+			err := s.db.Model(&models.Lease{}).Where("name = ?", lease).
+				Updates(map[string]interface{}{"holder": op.id, "expires_at": now.Add(teamOpLeaseTTL)}).Error
+			if err != nil {
+				cancel()
+				slog.Error("failed to take over team", "team_id", teamID, "operation", name, "error", err)
+				return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to claim team")
+			}
+			break
+		}
+		time.Sleep(teamOpPollInterval)
+	}
+
+	s.db.Model(&models.Lease{}).Where("name = ? AND holder = ?", lease, op.id).Update("note", op.note())
+	s.teamOpsMu.Lock()
+	s.teamOps[op.id] = op
+	s.teamOpsMu.Unlock()
+	go s.keepTeamOp(teamID, op)
+	return op, nil
+}
+
+// cancelTeamOp cancels the operation holding teamID as holder on behalf of
+// the forced operation by. An operation of this replica is cancelled at
+// once; one of another replica is asked to cancel through a lease, which it
+// checks when it next renews its own.
+func (s *Server) cancelTeamOp(teamID, holder, by string) {
+	s.teamOpsMu.Lock()
+	cur := s.teamOps[holder]
+	s.teamOpsMu.Unlock()
+	if cur != nil {
+		cur.cancel()
+		return
+	}
+	if _, err := election.Acquire(s.db, teamOpCancelLeasePrefix+teamID, by, teamOpForceWait+teamOpLeaseTTL, time.Now().UTC()); err != nil {
+		slog.Error("failed to request team operation cancellation", "team_id", teamID, "error", err)
+	}
+}
+
+// keepTeamOp renews the lease of op until it ends. The operation is
+// cancelled when a forced operation of another replica asks for it, or
+// when it loses the lease.
+func (s *Server) keepTeamOp(teamID string, op *teamOp) {
+	ticker := time.NewTicker(teamOpLeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-op.done:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now().UTC()
+		ok, err := election.Acquire(s.db, teamOpLeasePrefix+teamID, op.id, teamOpLeaseTTL, now)
+		if err != nil {
+			slog.Warn("failed to renew team operation", "team_id", teamID, "operation", op.name, "error", err)
+			continue
+		}
+		if !ok {
+			slog.Warn("team operation lost the team", "team_id", teamID, "operation", op.name)
+			op.cancel()
+			return
+		}
+		if req, err := election.Lookup(s.db, teamOpCancelLeasePrefix+teamID, now); err == nil && req != nil && req.Holder != op.id && op.ctx.Err() == nil {
+			slog.Warn("team operation cancelled by another replica", "team_id", teamID, "operation", op.name)
+			op.cancel()
+		}
+	}
+}
+
+// endTeamOp releases the team claimed by op.
+func (s *Server) endTeamOp(teamID string, op *teamOp) {
+	s.teamOpsMu.Lock()
+	delete(s.teamOps, op.id)
+	s.teamOpsMu.Unlock()
+	op.cancel()
+	close(op.done)
+	election.Release(s.db, teamOpLeasePrefix+teamID, op.id)
+}
+
+// teamOpHeld reports whether an operation of any replica holds teamID.
+func (s *Server) teamOpHeld(teamID string) bool {
+	lease, err := election.Lookup(s.db, teamOpLeasePrefix+teamID, time.Now().UTC())
+	return err != nil || lease != nil
+}
+
+// lockTeam loads the team of the request and claims it for the operation
+// name, honoring the force query parameter. The team is reloaded once
+// claimed, so its status reflects any operation that just finished.
+func (s *Server) lockTeam(c *fiber.Ctx, name string) (models.Team, *teamOp, error) {
+	return s.lockTeamID(c, c.Params("id"), name)
+}
+
+// lockTeamID is lockTeam for the team with the given ID.
+func (s *Server) lockTeamID(c *fiber.Ctx, id, name string) (models.Team, *teamOp, error) {
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", id).Error; err != nil {
+		return team, nil, newAPIError(CodeTeamNotFound, "team not found")
+	}
+	force := c.QueryBool("force")
+	op, err := s.beginTeamOp(team.ID, name, force)
+	if err != nil {
+		return team, nil, err
+	}
+	if err := s.checkTeamJobs(team.ID, name, force); err != nil {
+		s.endTeamOp(team.ID, op)
+		return team, nil, err
+	}
+	if err := s.db.Preload("Agents").First(&team, "id = ?", id).Error; err != nil {
+		s.endTeamOp(team.ID, op)
+		return team, nil, newAPIError(CodeTeamNotFound, "team not found")
+	}
+	return team, op, nil
+}
+
+// checkTeamJobs fails with a 409 if a job, such as a deployment, is queued
+// for the team, or running on another replica. With force, queued jobs are
+// cancelled instead; a running job holds the team, so beginTeamOp already
+// cancelled it.
+func (s *Server) checkTeamJobs(teamID, name string, force bool) error {
+	job, err := s.jobs.Pending(teamID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check team jobs")
+	}
+	if job == nil {
+		return nil
+	}
+	if !force {
+		return newAPIError(CodeTeamBusy, fmt.Sprintf(
+			"team is busy: %s %s since %s; retry when it finishes or pass force=true to cancel it",
+			job.Kind, job.Status, job.CreatedAt.UTC().Format(time.RFC3339)))
+	}
+	if n, err := s.jobs.CancelQueued(teamID, "cancelled by a forced "+name); err != nil {
+		slog.Error("failed to cancel queued team jobs", "team_id", teamID, "error", err)
+	} else if n > 0 {
+		slog.Warn("cancelled queued team jobs", "team_id", teamID, "count", n, "by", name)
+	}
+	return nil
+}
+
+// LockTeam claims a team for an operation run outside the API handlers,
+// such as a scheduled run. It fails if another operation holds the team.
+// The returned function releases it.
+func (s *Server) LockTeam(teamID, name string) (func(), error) {
+	op, err := s.beginTeamOp(teamID, name, false)
+	if err != nil {
+		return nil, err
+	}
+	return func() { s.endTeamOp(teamID, op) }, nil
+}
+
+// ReconcileTeams repairs team state left behind by a previous API process
+// or owning replica: teams whose deployment or restart was interrupted are
+// marked as failed, then the infrastructure garbage collector runs once. A
+// deployment still held by an operation, or with a job queued or running,
+// is in progress rather than interrupted, and is left alone.
+func (s *Server) ReconcileTeams() {
+	s.fixTeamRuntimes()
+
+	var deploying []models.Team
+	if err := s.db.Select("id", "name").Where("status = ?", models.TeamStatusDeploying).Find(&deploying).Error; err != nil {
+		slog.Error("failed to list deploying teams", "error", err)
+	}
+	for _, team := range deploying {
+		if s.teamOpHeld(team.ID) {
+			continue
+		}
+		if job, err := s.jobs.Pending(team.ID); err != nil || job != nil {
+			continue
+		}
+		res := s.db.Model(&models.Team{}).
+			Where("id = ? AND status = ?", team.ID, models.TeamStatusDeploying).
+			Updates(map[string]interface{}{
+				"status":         models.TeamStatusError,
+				"status_message": "Deployment interrupted by an API restart; stop or redeploy the team",
+			})
+		if res.Error != nil {
+			slog.Error("failed to mark interrupted deployment", "team", team.Name, "error", res.Error)
+		} else if res.RowsAffected > 0 {
+			slog.Warn("marked interrupted deployment as failed", "team", team.Name)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if _, err := s.collectInfraGarbage(ctx, false); err != nil && !errors.Is(err, errInfraGCUnsupported) {
+		slog.Error("failed to collect orphaned infrastructure", "error", err)
+	}
+}
+
+// teardownTeamInfra disconnects the shared Ollama, Qdrant and RAG MCP
+// containers from the team network, which must happen first, and removes
+// the team's infrastructure. Shared containers stay running. Failures are
+// logged.
+func (s *Server) teardownTeamInfra(ctx context.Context, teamName string, ollama bool, opts runtime.TeardownOptions) {
+	teamNetName := runtime.TeamNetworkName(naming.Slug(teamName))
+
+	if ollama {
+		if om, ok := s.sharedRuntime().(runtime.OllamaManager); ok {
+			if err := om.DisconnectOllamaFromNetwork(ctx, teamNetName); err != nil {
+				slog.Error("failed to disconnect ollama from network", "team", teamName, "error", err)
+			}
+		}
+	}
+
+	// Always try: the methods handle not-connected gracefully.
+	if qm, ok := s.sharedRuntime().(runtime.QdrantManager); ok {
+		if err := qm.DisconnectQdrantFromNetwork(ctx, teamNetName); err != nil {
+			slog.Error("failed to disconnect qdrant from network", "team", teamName, "error", err)
+		}
+	}
+	if rm, ok := s.sharedRuntime().(runtime.RagMcpManager); ok {
+		if err := rm.DisconnectRagMcpFromNetwork(ctx, teamNetName); err != nil {
+			slog.Error("failed to disconnect rag-mcp from network", "team", teamName, "error", err)
+		}
+	}
+
+	if err := s.runtime.TeardownInfra(ctx, teamName, opts); err != nil {
+		slog.Error("failed to teardown infrastructure", "team", teamName, "error", err)
+	}
+}
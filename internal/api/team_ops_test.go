@@ -0,0 +1,402 @@
+package api
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/election"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func TestBeginTeamOp_Conflict(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	op, err := srv.beginTeamOp("team-1", teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+
+	_, err = srv.beginTeamOp("team-1", teamOpStop, false)
+	if err == nil || !strings.Contains(err.Error(), "deploy in progress") {
+		t.Fatalf("expected busy error naming the deploy, got %v", err)
+	}
+
+	// Other teams are independent.
+	other, err := srv.beginTeamOp("team-2", teamOpStop, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp for another team: %v", err)
+	}
+	srv.endTeamOp("team-2", other)
+
+	srv.endTeamOp("team-1", op)
+	op, err = srv.beginTeamOp("team-1", teamOpStop, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp after release: %v", err)
+	}
+	srv.endTeamOp("team-1", op)
+}
+
+func TestBeginTeamOp_ForceCancels(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	deploy, err := srv.beginTeamOp("team-1", teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+	go func() {
+		<-deploy.ctx.Done()
+		srv.endTeamOp("team-1", deploy)
+	}()
+
+	stop, err := srv.beginTeamOp("team-1", teamOpStop, true)
+	if err != nil {
+		t.Fatalf("forced beginTeamOp: %v", err)
+	}
+	defer srv.endTeamOp("team-1", stop)
+	select {
+	case <-deploy.done:
+	default:
+		t.Error("forced operation started before the cancelled one finished")
+	}
+}
+
+func TestBeginTeamOp_ForceTakesOverStuckOperation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamOpForceWait = 10 * time.Millisecond
+	t.Cleanup(func() { teamOpForceWait = 30 * time.Second })
+
+	stuck, err := srv.beginTeamOp("team-1", teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+	stop, err := srv.beginTeamOp("team-1", teamOpStop, true)
+	if err != nil {
+		t.Fatalf("forced beginTeamOp: %v", err)
+	}
+
+	// The stuck operation finishing late does not release the new one.
+	srv.endTeamOp("team-1", stuck)
+	if _, err := srv.beginTeamOp("team-1", teamOpDelete, false); err == nil {
+		t.Fatal("expected the team to still be held by the forced operation")
+	}
+	srv.endTeamOp("team-1", stop)
+}
+
+func TestBeginTeamOp_AcrossReplicas(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	replica := NewServer(srv.db, &mockRuntime{}, srv.authProvider)
+	teamOpLeaseTTL = 300 * time.Millisecond
+	t.Cleanup(func() { teamOpLeaseTTL = election.DefaultTTL })
+
+	deploy, err := srv.beginTeamOp("team-1", teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+	_, err = replica.beginTeamOp("team-1", teamOpStop, false)
+	if err == nil || !strings.Contains(err.Error(), "deploy in progress") {
+		t.Fatalf("expected busy error from the other replica, got %v", err)
+	}
+
+	// The other replica cancels the deployment through the database and
+	// waits for it to release the team.
+	go func() {
+		<-deploy.ctx.Done()
+		srv.endTeamOp("team-1", deploy)
+	}()
+	stop, err := replica.beginTeamOp("team-1", teamOpStop, true)
+	if err != nil {
+		t.Fatalf("forced beginTeamOp: %v", err)
+	}
+	select {
+	case <-deploy.done:
+	default:
+		t.Error("forced operation started before the cancelled one finished")
+	}
+
+	// The lease outlives its TTL while the operation runs.
+	time.Sleep(2 * teamOpLeaseTTL)
+	if _, err := srv.beginTeamOp("team-1", teamOpDelete, false); err == nil {
+		t.Fatal("expected the team to still be held by the other replica")
+	}
+	if stop.ctx.Err() != nil {
+		t.Error("forced operation was cancelled")
+	}
+	replica.endTeamOp("team-1", stop)
+}
+
+func TestStopTeam_DuringOperation(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:   "busy-team",
+		Agents: []CreateAgentInput{{Name: "leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	deploy, err := srv.beginTeamOp(team.ID, teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+
+	for _, path := range []string{"/stop", "/deploy", "/agents/" + team.Agents[0].ID + "/restart"} {
+		rec := doRequest(srv, "POST", "/api/teams/"+team.ID+path, nil)
+		if rec.Code != 409 {
+			t.Errorf("%s: got %d, want 409", path, rec.Code)
+		}
+	}
+	rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID, nil)
+	if rec.Code != 409 {
+		t.Errorf("delete: got %d, want 409", rec.Code)
+	}
+	if mock.teardownCalled {
+		t.Fatal("infrastructure torn down during another operation")
+	}
+
+	// force cancels the deployment, waits for it, then stops the team.
+	go func() {
+		<-deploy.ctx.Done()
+		srv.db.Model(&models.Team{}).Where("id = ?", team.ID).Update("status", models.TeamStatusError)
+		srv.endTeamOp(team.ID, deploy)
+	}()
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/stop?force=true", nil)
+	if rec.Code != 200 {
+		t.Fatalf("forced stop: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var stopped models.Team
+	parseJSON(t, rec, &stopped)
+	if stopped.Status != models.TeamStatusStopped {
+		t.Errorf("status = %q, want stopped", stopped.Status)
+	}
+	if !mock.teardownCalled {
+		t.Error("expected TeardownInfra to be called")
+	}
+
+	// The team is released once the stop returns.
+	if op, err := srv.beginTeamOp(team.ID, teamOpDeploy, false); err != nil {
+		t.Errorf("team still held after stop: %v", err)
+	} else {
+		srv.endTeamOp(team.ID, op)
+	}
+}
+
+func TestStopTeam_InterruptedDeploy(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "stale-deploying"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusDeploying)
+
+	// No operation holds the team, so the deployment is not in flight.
+	rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/stop", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if !mock.teardownCalled {
+		t.Error("expected TeardownInfra to be called")
+	}
+}
+
+func TestDeleteTeam_Force(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "force-delete"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+
+	rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"?force=true", nil)
+	if rec.Code != 204 {
+		t.Fatalf("status: got %d, want 204\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if !mock.teardownCalled {
+		t.Error("expected the running team to be torn down")
+	}
+	var count int64
+	srv.db.Model(&models.Team{}).Where("id = ?", team.ID).Count(&count)
+	if count != 0 {
+		t.Error("team was not deleted")
+	}
+}
+
+func TestDeleteTeam_ErrorStatusTearsDown(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "failed-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+	srv.db.Model(&team).Update("status", models.TeamStatusError)
+
+	rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID, nil)
+	if rec.Code != 204 {
+		t.Fatalf("status: got %d, want 204\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if !mock.teardownCalled {
+		t.Error("expected leftover infrastructure of a failed team to be torn down")
+	}
+}
+
+func TestReconcileTeams(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	create := func(name, status string) models.Team {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: name})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		srv.db.Model(&team).Update("status", status)
+		return team
+	}
+	running := create("running", models.TeamStatusRunning)
+	stopped := create("stopped", models.TeamStatusStopped)
+	deploying := create("deploying", models.TeamStatusDeploying)
+	create("legacy", models.TeamStatusRunning)
+	held := create("held", models.TeamStatusDeploying)
+	queued := create("queued", models.TeamStatusDeploying)
+
+	// A deployment in progress elsewhere is not interrupted.
+	op, err := srv.beginTeamOp(held.ID, teamOpDeploy, false)
+	if err != nil {
+		t.Fatalf("beginTeamOp: %v", err)
+	}
+	defer srv.endTeamOp(held.ID, op)
+	srv.StopJobs()
+	if _, err := srv.enqueueTeamJob(jobTeamDeploy, queued, deploymentTrigger{}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	network := func(slug string) []runtime.InfraResource {
+		return []runtime.InfraResource{{Kind: runtime.ResourceNetwork, Name: "team-" + slug}}
+	}
+	mock.teamInfra = []runtime.TeamInfra{
+		{Slug: "deleted", TeamID: "gone", Resources: network("deleted")},
+		{Slug: "deploying", TeamID: deploying.ID, Resources: network("deploying")},
+		{Slug: "legacy", Resources: network("legacy")},
+		{Slug: "running", TeamID: running.ID, Resources: network("running")},
+		{Slug: "stopped", TeamID: stopped.ID, Resources: network("stopped")},
+		{Slug: "unknown", Resources: network("unknown")},
+	}
+
+	srv.ReconcileTeams()
+
+	want := []string{"deleted", "stopped", "unknown"}
+	if strings.Join(mock.tornDown, ",") != strings.Join(want, ",") {
+		t.Errorf("torn down %v, want %v", mock.tornDown, want)
+	}
+	// Only the workspace of a team known to be deleted is removed.
+	if want := []string{"stopped", "unknown"}; strings.Join(mock.preserved, ",") != strings.Join(want, ",") {
+		t.Errorf("preserved workspaces %v, want %v", mock.preserved, want)
+	}
+
+	var got models.Team
+	srv.db.First(&got, "id = ?", deploying.ID)
+	if got.Status != models.TeamStatusError || got.StatusMessage == "" {
+		t.Errorf("interrupted deployment: status %q, message %q", got.Status, got.StatusMessage)
+	}
+	for _, team := range []models.Team{running, held, queued} {
+		var kept models.Team
+		srv.db.First(&kept, "id = ?", team.ID)
+		if kept.Status != team.Status {
+			t.Errorf("%s team status = %q, want %q", team.Name, kept.Status, team.Status)
+		}
+	}
+}
+
+func TestStopTeam_PreservesWorkspace(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "keep-workspace"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	for _, tc := range []struct {
+		query    string
+		preserve bool
+	}{
+		{"", true},
+		{"?preserve_workspace=false", false},
+	} {
+		srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+		rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/stop"+tc.query, nil)
+		if rec.Code != 200 {
+			t.Fatalf("stop%s: got %d, want 200\nbody: %s", tc.query, rec.Code, rec.Body.String())
+		}
+		if mock.teardownOpts.PreserveWorkspace != tc.preserve {
+			t.Errorf("stop%s: PreserveWorkspace = %v, want %v", tc.query, mock.teardownOpts.PreserveWorkspace, tc.preserve)
+		}
+	}
+}
+
+func TestDeleteTeam_DeletesWorkspace(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	create := func(name, status string) models.Team {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: name})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		srv.db.Model(&team).Update("status", status)
+		return team
+	}
+
+	// A stopped team only has its workspace left.
+	stopped := create("stopped-team", models.TeamStatusStopped)
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+stopped.ID, nil); rec.Code != 204 {
+		t.Fatalf("delete: got %d, want 204\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Join(mock.deletedWorkspaces, ",") != "stopped-team" {
+		t.Errorf("deleted workspaces %v, want stopped-team", mock.deletedWorkspaces)
+	}
+
+	kept := create("kept-team", models.TeamStatusStopped)
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+kept.ID+"?preserve_workspace=true", nil); rec.Code != 204 {
+		t.Fatalf("delete preserving workspace: got %d, want 204", rec.Code)
+	}
+	if len(mock.deletedWorkspaces) != 1 {
+		t.Errorf("workspace deleted despite preserve_workspace: %v", mock.deletedWorkspaces)
+	}
+
+	// A failed team is torn down along with its workspace.
+	failed := create("failed-team", models.TeamStatusError)
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+failed.ID, nil); rec.Code != 204 {
+		t.Fatalf("delete failed team: got %d, want 204", rec.Code)
+	}
+	if mock.teardownOpts.PreserveWorkspace {
+		t.Error("delete preserved the workspace of a failed team")
+	}
+}
+
+func TestDeleteTeamWorkspace(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "workspace-team"})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	srv.db.Model(&team).Update("status", models.TeamStatusRunning)
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/workspace", nil); rec.Code != 409 {
+		t.Errorf("running team: got %d, want 409", rec.Code)
+	}
+
+	srv.db.Model(&team).Updates(map[string]interface{}{
+		"status":         models.TeamStatusStopped,
+		"workspace_path": "/srv/work",
+	})
+	if rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/workspace", nil); rec.Code != 409 {
+		t.Errorf("host workspace: got %d, want 409", rec.Code)
+	}
+	if len(mock.deletedWorkspaces) != 0 {
+		t.Fatalf("deleted workspaces %v, want none", mock.deletedWorkspaces)
+	}
+
+	srv.db.Model(&team).Update("workspace_path", "")
+	rec := doRequest(srv, "DELETE", "/api/teams/"+team.ID+"/workspace", nil)
+	if rec.Code != 204 {
+		t.Fatalf("got %d, want 204\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if strings.Join(mock.deletedWorkspaces, ",") != "workspace-team" {
+		t.Errorf("deleted workspaces %v, want workspace-team", mock.deletedWorkspaces)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// slugOwner returns the team other than exceptID that holds slug, or nil.
+func (s *Server) slugOwner(slug, exceptID string) (*models.Team, error) {
+	var owner models.Team
+	q := s.db.Select("id", "org_id", "name").Where("slug = ?", slug)
+	if exceptID != "" {
+		q = q.Where("id <> ?", exceptID)
+	}
+	if err := q.Limit(1).Find(&owner).Error; err != nil {
+		return nil, err
+	}
+	if owner.ID == "" {
+		return nil, nil
+	}
+	return &owner, nil
+}
+
+// checkTeamSlug returns the slug of name, or a 409 explaining the conflict
+// if another team already uses it. Teams of other organizations are not
+// named.
+func (s *Server) checkTeamSlug(orgID, name, exceptID string) (string, error) {
+	slug := naming.Slug(name)
+	owner, err := s.slugOwner(slug, exceptID)
+	if err != nil {
+		return "", fiber.NewError(fiber.StatusInternalServerError, "failed to check team name")
+	}
+	if owner == nil {
+		return slug, nil
+	}
+	if owner.OrgID == orgID {
+		return "", newAPIError(CodeNameConflict, fmt.Sprintf(
+			"team name %q conflicts with team %q: both become %q in container names and NATS subjects; choose a different name",
+			name, owner.Name, slug))
+	}
+	return "", newAPIError(CodeNameConflict, fmt.Sprintf(
+		"team name %q conflicts with a team in another organization: both become %q in container names and NATS subjects; choose a different name",
+		name, slug))
+}
+
+// SyncTeamSlugs brings stored slugs in line with naming.Slug, oldest team
+// first: it fills in teams created before slugs were stored and updates
+// teams whose slug changed with the naming rules. A team whose new slug is
+// already taken keeps an empty slug and is logged; it cannot be deployed
+// until it is renamed. Running teams keep their slug, which their
+// infrastructure is named after, until they are next deployed.
+func (s *Server) SyncTeamSlugs() {
+	var teams []models.Team
+	if err := s.db.Select("id", "name", "slug", "status").Order("created_at").Find(&teams).Error; err != nil {
+		slog.Error("failed to load team slugs", "error", err)
+		return
+	}
+	for _, team := range teams {
+		slug := naming.Slug(team.Name)
+		if slug == team.Slug {
+			continue
+		}
+		if team.Slug != "" && isTeamActive(team.Status) {
+			slog.Warn("team slug changed; it takes effect on the next deploy",
+				"team", team.Name, "team_id", team.ID, "slug", team.Slug, "new_slug", slug)
+			continue
+		}
+		owner, err := s.slugOwner(slug, team.ID)
+		if err != nil {
+			slog.Error("failed to check team slug", "team", team.Name, "error", err)
+			continue
+		}
+		if owner != nil {
+			slog.Warn("team name collides with another team; rename it before deploying",
+				"team", team.Name, "team_id", team.ID, "slug", slug, "other_team_id", owner.ID)
+			slug = ""
+		}
+		if err := s.db.Model(&models.Team{}).Where("id = ?", team.ID).Update("slug", slug).Error; err != nil {
+			slog.Error("failed to store team slug", "team", team.Name, "error", err)
+		}
+	}
+}
+
+// isTeamActive reports whether a team has (or is creating) infrastructure
+// named after its slug.
+func isTeamActive(status string) bool {
+	return status == models.TeamStatusRunning || status == models.TeamStatusDeploying
+}
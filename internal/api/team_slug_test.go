@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestCreateTeam_SlugCollision(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "Data Team"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.Slug != "data-team" {
+		t.Errorf("slug: got %q, want data-team", team.Slug)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "data-team"})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("colliding create: got %d, want 409", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `team \"Data Team\"`) || !strings.Contains(rec.Body.String(), "data-team") {
+		t.Errorf("conflict message should name the other team and the slug: %s", rec.Body.String())
+	}
+	var errResp ErrorResponse
+	parseJSON(t, rec, &errResp)
+	if errResp.Code != CodeNameConflict {
+		t.Errorf("code: got %s, want %s", errResp.Code, CodeNameConflict)
+	}
+
+	// A team of another organization is not named.
+	srv.db.Create(&models.Team{ID: "other-org-team", OrgID: "other-org", Name: "Ops", Slug: "ops"})
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "OPS"})
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "another organization") {
+		t.Errorf("cross-org collision: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	// The unique index backs the check.
+	err := srv.db.Create(&models.Team{ID: "dup", OrgID: "other-org", Name: "Data  Team", Slug: "data-team"}).Error
+	if err == nil {
+		t.Error("expected unique index violation for duplicate slug")
+	}
+}
+
+func TestUpdateTeam_SlugCollision(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var a, b models.Team
+	parseJSON(t, doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "alpha"}), &a)
+	parseJSON(t, doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "beta"}), &b)
+
+	name := "Alpha"
+	rec := doRequest(srv, "PUT", "/api/teams/"+b.ID, UpdateTeamRequest{Name: &name})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rename onto existing slug: got %d, want 409", rec.Code)
+	}
+
+	// Changing only the case keeps the team's own slug.
+	rec = doRequest(srv, "PUT", "/api/teams/"+a.ID, UpdateTeamRequest{Name: &name})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rename to own slug: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+
+	srv.db.Model(&models.Team{}).Where("id = ?", b.ID).Update("status", models.TeamStatusRunning)
+	name = "gamma"
+	rec = doRequest(srv, "PUT", "/api/teams/"+b.ID, UpdateTeamRequest{Name: &name})
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rename while running: got %d, want 409", rec.Code)
+	}
+}
+
+func TestSyncTeamSlugs(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.db.Create(&models.Team{ID: "t1", OrgID: "o1", Name: "Blue Team"})
+	srv.db.Create(&models.Team{ID: "t2", OrgID: "00000000-0000-0000-0000-000000000000", Name: "blue-team"})
+	srv.db.Create(&models.Team{ID: "t3", OrgID: "o1", Name: "Red"})
+	// Stale slugs are updated unless the team is running.
+	srv.db.Create(&models.Team{ID: "t4", OrgID: "o1", Name: "Green", Slug: "old-green"})
+	srv.db.Create(&models.Team{ID: "t5", OrgID: "o1", Name: "Gold", Slug: "old-gold", Status: models.TeamStatusRunning})
+
+	srv.SyncTeamSlugs()
+
+	slugs := map[string]string{}
+	var teams []models.Team
+	srv.db.Find(&teams)
+	for _, team := range teams {
+		slugs[team.ID] = team.Slug
+	}
+	if slugs["t1"] != "blue-team" || slugs["t2"] != "" || slugs["t3"] != "red" ||
+		slugs["t4"] != "green" || slugs["t5"] != "old-gold" {
+		t.Errorf("slugs: got %v", slugs)
+	}
+
+	// The colliding team cannot be deployed until renamed.
+	rec := doRequest(srv, "POST", "/api/teams/t2/deploy", nil)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("deploy colliding team: got %d, want 409", rec.Code)
+	}
+}
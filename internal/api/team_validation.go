@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// agentWorkspacePath is where an agent's container mounts the workspace.
+const agentWorkspacePath = "/workspace"
+
+// Severities of a ValidationFinding.
+const (
+	// FindingError fails team creation or deployment.
+	FindingError = "error"
+	// FindingWarning is accepted but likely a mistake.
+	FindingWarning = "warning"
+)
+
+// ValidationFinding is one problem of a team definition.
+type ValidationFinding struct {
+	// Field is the request field at fault, such as "name" or
+	// "agents[1].permissions"; empty for the team as a whole.
+	Field    string `json:"field"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// TeamValidationResponse is the response of POST /api/teams/validate.
+type TeamValidationResponse struct {
+	// Valid is set when no finding is an error.
+	Valid    bool                `json:"valid"`
+	Findings []ValidationFinding `json:"findings"`
+}
+
+// teamValidation collects the findings of a team definition.
+type teamValidation struct {
+	findings []ValidationFinding
+}
+
+func (v *teamValidation) add(severity, field string, err error) {
+	if err == nil {
+		return
+	}
+	v.findings = append(v.findings, ValidationFinding{Field: field, Severity: severity, Message: err.Error()})
+}
+
+func (v *teamValidation) error(field string, err error) { v.add(FindingError, field, err) }
+
+func (v *teamValidation) warn(field, msg string) { v.add(FindingWarning, field, errors.New(msg)) }
+
+// ValidateTeam handles POST /api/teams/validate. It checks a
+// CreateTeamRequest without creating the team and reports every problem
+// found, so that a UI can show them all at once: the checks of team
+// creation, the ones deployment makes (a leader, workspace sharing, the
+// skills catalog), oversized prompts and the permissions findings of
+// permissions.Analyze. The response is 200 whether or not the team is valid.
+func (s *Server) ValidateTeam(c *fiber.Ctx) error {
+	var req CreateTeamRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	findings, err := s.validateCreateTeam(c, req)
+	if err != nil {
+		return err
+	}
+	resp := TeamValidationResponse{Valid: true, Findings: findings}
+	for _, f := range findings {
+		if f.Severity == FindingError {
+			resp.Valid = false
+		}
+	}
+	return c.JSON(resp)
+}
+
+// validateCreateTeam returns the findings of a team definition. The error
+// is set when a check could not run.
+func (s *Server) validateCreateTeam(c *fiber.Ctx, req CreateTeamRequest) ([]ValidationFinding, error) {
+	v := &teamValidation{findings: []ValidationFinding{}}
+
+	if req.Name == "" {
+		v.error("name", errors.New("name is required"))
+	} else if err := validateName(req.Name); err != nil {
+		v.error("name", err)
+	} else if _, err := s.checkTeamSlug(GetOrgID(c), req.Name, ""); err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) && fiberErr.Code >= 500 {
+			return nil, err
+		}
+		v.error("name", errors.New(apiErrorMessage(err)))
+	}
+
+	rt := req.Runtime
+	if rt == "" {
+		rt = s.runtimeName
+	}
+	if m, ok := s.runtime.(*runtime.MultiRuntime); ok && !m.Has(rt) {
+		v.error("runtime", fmt.Errorf("runtime must be one of %s", strings.Join(m.Names(), ", ")))
+	}
+	prov := req.Provider
+	if prov == "" {
+		prov = models.ProviderClaude
+	}
+	if prov != models.ProviderClaude && prov != models.ProviderOpenCode {
+		v.error("provider", errors.New("provider must be 'claude' or 'opencode'"))
+	} else {
+		v.error("model_provider", validateModelProvider(prov, req.ModelProvider))
+	}
+	v.error("agents", validateAgentModelConsistency(req.ModelProvider, req.Agents))
+	v.error("agent_image", validateAgentImage(req.AgentImage))
+	v.error("config_dir_mode", validateConfigDirMode(req.ConfigDirMode))
+
+	policy, err := s.loadResourcePolicy(c)
+	if err != nil {
+		return nil, err
+	}
+	v.error("resource_preset", policy.CheckPreset(req.ResourcePreset))
+
+	if req.NamespaceConfig != nil {
+		v.error("namespace_config", req.NamespaceConfig.Validate())
+	}
+	if req.RestartPolicy != nil {
+		v.error("restart_policy", req.RestartPolicy.Validate())
+	}
+	if len(req.Labels) > 0 {
+		v.error("labels", validateTeamLabels(req.Labels))
+	}
+	if req.Bootstrap != nil && req.Bootstrap.Script != "" {
+		v.error("bootstrap", req.Bootstrap.Validate())
+	}
+	if len(req.ExtraWorkspaces) > 0 {
+		v.error("extra_workspaces", protocol.ValidateExtraWorkspaces(req.ExtraWorkspaces))
+	}
+	if len(req.HostSelector) > 0 {
+		v.error("host_selector", validateHostSelector(req.HostSelector))
+	}
+	v.error("workspace_template", runtime.ValidateWorkspaceTemplate(req.WorkspaceTemplate))
+	v.error("max_concurrent_runs", validateMaxConcurrentRuns(req.MaxConcurrentRuns))
+	v.error("telemetry_level", validateTelemetryLevel(req.TelemetryLevel))
+	if req.WorkingHours != nil && !req.WorkingHours.IsZero() {
+		v.error("working_hours", req.WorkingHours.Validate())
+	}
+	if req.McpServers != nil {
+		v.error("mcp_servers", validateMcpServers(req.McpServers))
+	}
+
+	knownSkillTools, err := s.skillToolRequirements(GetOrgID(c))
+	if err != nil {
+		return nil, err
+	}
+	team := models.Team{
+		OrgID:         GetOrgID(c),
+		Name:          req.Name,
+		WorkspacePath: req.WorkspacePath,
+		ConfigDirMode: req.ConfigDirMode,
+	}
+	leaders := 0
+	seen := map[string]bool{}
+	for i, a := range req.Agents {
+		field := fmt.Sprintf("agents[%d]", i)
+		if a.Name == "" {
+			v.error(field+".name", errors.New("name is required"))
+		} else if err := validateName(a.Name); err != nil {
+			v.error(field+".name", err)
+		} else if seen[strings.ToLower(a.Name)] {
+			v.error(field+".name", errors.New("duplicate agent name: "+a.Name))
+		}
+		seen[strings.ToLower(a.Name)] = true
+
+		switch a.Role {
+		case models.AgentRoleLeader:
+			leaders++
+		case "", models.AgentRoleWorker:
+		default:
+			v.warn(field+".role", fmt.Sprintf("unknown role %q; the agent runs as a worker", a.Role))
+		}
+
+		v.agentPrompts(field, a)
+		if len(a.SubAgentDescription) > maxDescriptionSize {
+			v.error(field+".sub_agent_description", fmt.Errorf("sub_agent_description exceeds maximum size of %d bytes", maxDescriptionSize))
+		}
+		if len(a.SubAgentInstructions) > maxInstructionsSize {
+			v.error(field+".sub_agent_instructions", fmt.Errorf("sub_agent_instructions exceeds maximum size of %d bytes", maxInstructionsSize))
+		}
+		var skills models.JSON
+		if a.SubAgentSkills != nil {
+			if err := validateSubAgentSkills(a.SubAgentSkills); err != nil {
+				v.error(field+".sub_agent_skills", err)
+			} else {
+				skills, _ = json.Marshal(a.SubAgentSkills)
+				team.Agents = append(team.Agents, models.Agent{SubAgentSkills: skills})
+			}
+		}
+		skillTools := make(map[string][]string)
+		for _, st := range agentSkillTools(skills, knownSkillTools) {
+			skillTools[st.Skill] = st.RequiredTools
+		}
+		v.agentPermissions(field, a.Permissions, skillTools)
+		v.error(field+".resources", policy.CheckOverride(a.Resources))
+		v.error(field, validateRunAs(a.RunAsUID, a.RunAsGID))
+		v.error(field+".sub_agent_isolation", validateSubAgentIsolation(a.SubAgentIsolation))
+		v.error(field+".sub_agent_permission_mode", validateSubAgentPermissionMode(a.SubAgentPermissionMode))
+		if _, err := marshalExtraClaudeArgs(a.ExtraClaudeArgs); err != nil {
+			v.error(field+".extra_claude_args", err)
+		}
+	}
+
+	switch {
+	case leaders == 0:
+		v.error("agents", errors.New("the team has no leader; it cannot be deployed"))
+	case leaders > 1:
+		v.error("agents", fmt.Errorf("the team has %d leaders; it may have only one", leaders))
+	}
+
+	// The checks deployment makes.
+	if err := s.checkWorkspaceSharing(team); err != nil {
+		v.error("workspace_path", errors.New(apiErrorMessage(err)))
+	}
+	if err := s.checkSkillsCatalog(team); err != nil {
+		v.error("agents", errors.New(apiErrorMessage(err)))
+	}
+	return v.findings, nil
+}
+
+// agentPrompts checks the sizes of an agent's system prompt and CLAUDE.md.
+func (v *teamValidation) agentPrompts(field string, a CreateAgentInput) {
+	instructions, instructionsField := a.InstructionsMD, field+".instructions_md"
+	if instructions == "" && a.ClaudeMD != "" {
+		instructions, instructionsField = a.ClaudeMD, field+".claude_md"
+	}
+	for _, p := range []struct{ field, text string }{
+		{field + ".system_prompt", a.SystemPrompt},
+		{instructionsField, instructions},
+	} {
+		name := p.field[strings.LastIndex(p.field, ".")+1:]
+		switch {
+		case len(p.text) > maxInstructionsSize:
+			v.error(p.field, fmt.Errorf("%s exceeds maximum size of %d bytes", name, maxInstructionsSize))
+		case len(p.text) > previewWarnSize:
+			v.warn(p.field, fmt.Sprintf("%s is %d KB; large instructions use up the model's context on every turn", name, len(p.text)/1024))
+		}
+	}
+}
+
+// agentPermissions checks an agent's permissions and reports the rules
+// that can never take effect or that keep it from working, given the tools
+// its skills need.
+func (v *teamValidation) agentPermissions(field string, raw interface{}, skillTools map[string][]string) {
+	field += ".permissions"
+	perms, err := marshalPermissions(raw)
+	if err != nil {
+		v.error(field, err)
+		return
+	}
+	var config permissions.PermissionConfig
+	if err := json.Unmarshal(perms, &config); err != nil {
+		return
+	}
+	for _, f := range permissions.Analyze(config, permissions.Requirements{Workspace: agentWorkspacePath, SkillTools: skillTools}) {
+		v.warn(field, f.Message)
+	}
+}
+
+// apiErrorMessage returns the client message of an error returned by the
+// handlers' helpers.
+func apiErrorMessage(err error) string {
+	var apiErr *APIError
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Message
+	case errors.As(err, &fiberErr):
+		return fiberErr.Message
+	}
+	return err.Error()
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// findingsByField indexes the findings of a validation response by field.
+func findingsByField(resp TeamValidationResponse) map[string]ValidationFinding {
+	byField := make(map[string]ValidationFinding, len(resp.Findings))
+	for _, f := range resp.Findings {
+		byField[f.Field] = f
+	}
+	return byField
+}
+
+func TestValidateTeam_ReportsAllFindings(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	existing := createTestTeam(t, srv, "taken")
+	srv.db.Create(&models.SkillCatalogEntry{ID: "skill-1", OrgID: existing.OrgID, RepoURL: "https://github.com/acme/skills", SkillName: "lint",
+		RequiredTools: models.JSON(`["Bash(npm run lint:*)", "Read"]`)})
+
+	rec := doRequest(srv, "POST", "/api/teams/validate", CreateTeamRequest{
+		Name:           "Taken",
+		TelemetryLevel: "loud",
+		Agents: []CreateAgentInput{
+			{
+				Name:           "writer",
+				SystemPrompt:   strings.Repeat("x", previewWarnSize+1),
+				Permissions:    map[string]interface{}{"allowed_tools": []string{"Read"}},
+				SubAgentSkills: []string{"acme/skills:lint"},
+			},
+			{
+				Name:           "reviewer",
+				InstructionsMD: strings.Repeat("x", maxInstructionsSize+1),
+				Permissions: map[string]interface{}{
+					"allowed_tools":    []string{"Read"},
+					"allowed_commands": []string{"git push origin main"},
+					"denied_commands":  []string{"git push *"},
+				},
+				SubAgentSkills: []string{"acme/skills:deploy"},
+			},
+			{Name: "Writer"},
+		},
+	})
+	if rec.Code != 200 {
+		t.Fatalf("validate: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var resp TeamValidationResponse
+	parseJSON(t, rec, &resp)
+	if resp.Valid {
+		t.Error("invalid team reported as valid")
+	}
+
+	byField := findingsByField(resp)
+	for field, want := range map[string]string{
+		"name":                      FindingError,
+		"telemetry_level":           FindingError,
+		"agents[0].system_prompt":   FindingWarning,
+		"agents[1].instructions_md": FindingError,
+		"agents[1].permissions":     FindingWarning,
+		"agents[0].permissions":     FindingWarning,
+		"agents[2].name":            FindingError,
+	} {
+		if f, ok := byField[field]; !ok || f.Severity != want {
+			t.Errorf("%s: got %+v, want a finding with severity %s", field, f, want)
+		}
+	}
+	var noLeader, missingSkill bool
+	for _, f := range resp.Findings {
+		if f.Field != "agents" {
+			continue
+		}
+		noLeader = noLeader || strings.Contains(f.Message, "no leader")
+		missingSkill = missingSkill || strings.Contains(f.Message, "acme/skills:deploy")
+	}
+	if !noLeader || !missingSkill {
+		t.Errorf("team findings: got %+v", resp.Findings)
+	}
+	var perms int
+	for _, f := range resp.Findings {
+		if f.Field == "agents[1].permissions" {
+			perms++
+		}
+	}
+	if f := byField["agents[0].permissions"]; !strings.Contains(f.Message, "skill https://github.com/acme/skills:lint needs tools missing from allowed_tools: Bash") {
+		t.Errorf("skill tools: got %+v", f)
+	}
+	if perms != 2 {
+		t.Errorf("permission findings: got %d, want 2 in %+v", perms, resp.Findings)
+	}
+}
+
+func TestValidateTeam_Valid(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams/validate", CreateTeamRequest{
+		Name:   "fresh",
+		Agents: []CreateAgentInput{{Name: "leader", Role: models.AgentRoleLeader}, {Name: "helper"}},
+	})
+	var resp TeamValidationResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != 200 || !resp.Valid || len(resp.Findings) != 0 {
+		t.Errorf("valid team: got %d %+v", rec.Code, resp)
+	}
+	var count int64
+	srv.db.Model(&models.Team{}).Count(&count)
+	if count != 0 {
+		t.Errorf("validation created %d teams", count)
+	}
+}
+
+func TestCreateTeam_RejectsValidationErrors(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	// What the validation reports as errors, team creation refuses.
+	for name, agent := range map[string]CreateAgentInput{
+		"unnamed agent":       {Role: models.AgentRoleLeader},
+		"large system prompt": {Name: "leader", SystemPrompt: strings.Repeat("x", maxInstructionsSize+1)},
+		"large instructions":  {Name: "leader", InstructionsMD: strings.Repeat("x", maxInstructionsSize+1)},
+		"large claude_md":     {Name: "leader", ClaudeMD: strings.Repeat("x", maxInstructionsSize+1)},
+	} {
+		req := CreateTeamRequest{Name: "rejected", Agents: []CreateAgentInput{agent}}
+		var resp TeamValidationResponse
+		parseJSON(t, doRequest(srv, "POST", "/api/teams/validate", req), &resp)
+		if resp.Valid {
+			t.Errorf("%s: validation found no error", name)
+		}
+		if rec := doRequest(srv, "POST", "/api/teams", req); rec.Code != 400 {
+			t.Errorf("%s: create got %d, want 400", name, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/failures"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Types of timeline entries.
+const (
+	TimelineDeploy      = "deploy"
+	TimelineValidation  = "validation"
+	TimelineRun         = "run"
+	TimelineError       = "error"
+	TimelineScheduleRun = "schedule_run"
+)
+
+var timelineTypes = []string{TimelineDeploy, TimelineValidation, TimelineRun, TimelineError, TimelineScheduleRun}
+
+// timelineListOptions configures GET /api/teams/:id/timeline: newest first.
+// The type filter is applied per source rather than to a column.
+var timelineListOptions = listOptions{
+	DefaultLimit: 50,
+	MaxLimit:     200,
+	Newest:       true,
+}
+
+// timelineSummaryLen caps the run results quoted in timeline summaries.
+const timelineSummaryLen = 200
+
+// TimelineEntry is one event in a team's history. Source names the kind of
+// record it was built from, whose ID it has: task_log, job or schedule_run.
+type TimelineEntry struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Time      time.Time         `json:"time"`
+	Status    string            `json:"status,omitempty"`
+	AgentName string            `json:"agent_name,omitempty"`
+	Summary   string            `json:"summary"`
+	Failure   *failures.Failure `json:"failure,omitempty"`
+	Source    string            `json:"source"`
+	Details   models.JSON       `json:"details,omitempty"`
+}
+
+// timelineSource is one kind of record merged into the timeline. query
+// returns its rows for the team; table and column locate its id and time.
+type timelineSource struct {
+	entryType string
+	table     string
+	column    string
+	query     func() *gorm.DB
+	entries   func(db *gorm.DB, locale string) ([]TimelineEntry, error)
+}
+
+// GetTimeline returns a team's deployments, validation results, runs, run
+// errors and schedule runs as a single feed, newest first. The "type" query
+// parameter restricts it to some entry types (comma-separated); since,
+// until, limit and cursor work as on other lists.
+func (s *Server) GetTimeline(c *fiber.Ctx) error {
+	teamID := c.Params("id")
+
+	var team models.Team
+	if err := s.db.Scopes(OrgScope(c)).First(&team, "id = ?", teamID).Error; err != nil {
+		return newAPIError(CodeTeamNotFound, "team not found")
+	}
+
+	q, err := parseListQuery(c, timelineListOptions)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]bool)
+	for _, t := range splitCSV(c.Query("type")) {
+		if !slices.Contains(timelineTypes, t) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid type %q, use one of %s", t, strings.Join(timelineTypes, ", ")))
+		}
+		wanted[t] = true
+	}
+	locale := requestLocale(c)
+
+	resp := ListResponse[TimelineEntry]{Items: []TimelineEntry{}}
+	var entries []TimelineEntry
+	for _, src := range s.timelineSources(teamID) {
+		if len(wanted) > 0 && !wanted[src.entryType] {
+			continue
+		}
+		var count int64
+		if err := timelineRange(src.query(), q, src.table, src.column).Count(&count).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to load timeline")
+		}
+		resp.Total += count
+		if count == 0 {
+			continue
+		}
+		page, err := src.entries(timelinePage(src.query(), q, src.table, src.column), locale)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to load timeline")
+		}
+		entries = append(entries, page...)
+	}
+
+	// Each source returned up to limit+1 entries past the cursor, so the
+	// newest limit of the merged entries are the page.
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.After(entries[j].Time)
+		}
+		return entries[i].ID > entries[j].ID
+	})
+	if len(entries) > q.limit {
+		entries = entries[:q.limit]
+		last := entries[len(entries)-1]
+		resp.NextCursor = encodeCursor(last.Time, last.ID)
+	}
+	if entries != nil {
+		resp.Items = entries
+	}
+	return c.JSON(resp)
+}
+
+// timelineRange applies q's date range to a timeline source.
+func timelineRange(db *gorm.DB, q listQuery, table, column string) *gorm.DB {
+	col := table + "." + column
+	if q.since != nil {
+		db = db.Where(col+" >= ?", *q.since)
+	}
+	if q.until != nil {
+		db = db.Where(col+" < ?", *q.until)
+	}
+	return db
+}
+
+// timelinePage applies q's date range, cursor and limit to a timeline
+// source, newest first. Entry IDs are those of the source rows, so the
+// cursor orders rows of every source the same way.
+func timelinePage(db *gorm.DB, q listQuery, table, column string) *gorm.DB {
+	col, id := table+"."+column, table+".id"
+	db = timelineRange(db, q, table, column)
+	if q.cursor != nil {
+		db = db.Where(col+" <= ?", q.cursor.CreatedAt).
+			Where(col+" < ? OR "+id+" < ?", q.cursor.CreatedAt, q.cursor.ID)
+	}
+	return db.Order(col + " DESC").Order(id + " DESC").Limit(q.limit + 1)
+}
+
+// timelineSources lists the records merged into a team's timeline.
+func (s *Server) timelineSources(teamID string) []timelineSource {
+	logs := func(messageType string) func() *gorm.DB {
+		return func() *gorm.DB {
+			return s.db.Model(&models.TaskLog{}).Where("team_id = ? AND message_type = ?", teamID, messageType)
+		}
+	}
+	// Run logs are leader responses; failed ones are listed as errors.
+	runs := func(failed bool) func() *gorm.DB {
+		return func() *gorm.DB {
+			cond := "COALESCE(json_extract(payload, '$.status'), '') <> 'failed'"
+			if failed {
+				cond = "json_extract(payload, '$.status') = 'failed'"
+			}
+			return logs(string(protocol.TypeLeaderResponse))().Where(cond)
+		}
+	}
+	return []timelineSource{
+		{TimelineDeploy, "jobs", "created_at", func() *gorm.DB {
+			return s.db.Model(&models.Job{}).Where("team_id = ? AND kind = ?", teamID, jobTeamDeploy)
+		}, deployJobEntries},
+		{TimelineDeploy, "task_logs", "created_at", logs(string(protocol.TypeDeploymentEvent)), taskLogEntries(deploymentEventEntry)},
+		{TimelineValidation, "task_logs", "created_at", logs(string(protocol.TypeContainerValidation)), taskLogEntries(validationEntry)},
+		{TimelineRun, "task_logs", "created_at", runs(false), taskLogEntries(runEntry)},
+		{TimelineError, "task_logs", "created_at", runs(true), taskLogEntries(runEntry)},
+		{TimelineScheduleRun, "schedule_runs", "started_at", func() *gorm.DB {
+			return s.db.Model(&models.ScheduleRun{}).
+				Joins("JOIN schedules ON schedules.id = schedule_runs.schedule_id").
+				Where("schedules.team_id = ?", teamID)
+		}, scheduleRunEntries},
+	}
+}
+
+func deployJobEntries(db *gorm.DB, locale string) ([]TimelineEntry, error) {
+	var deploys []models.Job
+	if err := db.Find(&deploys).Error; err != nil {
+		return nil, err
+	}
+	entries := make([]TimelineEntry, len(deploys))
+	for i, job := range deploys {
+		entries[i] = TimelineEntry{
+			ID: job.ID, Type: TimelineDeploy, Time: job.CreatedAt, Status: job.Status,
+			Summary: "Team deployment " + job.Status, Source: "job",
+		}
+		if job.Status == models.JobStatusFailed && job.Error != "" {
+			entries[i].Summary = "Team deployment failed: " + job.Error
+			entries[i].Failure = failures.Deploy(job.Error, locale)
+		}
+	}
+	return entries, nil
+}
+
+func scheduleRunEntries(db *gorm.DB, _ string) ([]TimelineEntry, error) {
+	var runs []models.ScheduleRun
+	if err := db.Select("schedule_runs.*").Preload("Schedule").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	entries := make([]TimelineEntry, len(runs))
+	for i, run := range runs {
+		summary := fmt.Sprintf("Schedule %q %s", run.Schedule.Name, run.Status)
+		if run.Error != "" {
+			summary += ": " + run.Error
+		}
+		entries[i] = TimelineEntry{
+			ID: run.ID, Type: TimelineScheduleRun, Time: run.StartedAt, Status: run.Status,
+			Summary: summary, Source: "schedule_run",
+		}
+	}
+	return entries, nil
+}
+
+// taskLogEntries builds timeline entries from task logs with entry, which
+// fills in the type, status, agent and summary from the log's payload.
+func taskLogEntries(entry func(log models.TaskLog, e *TimelineEntry, locale string)) func(*gorm.DB, string) ([]TimelineEntry, error) {
+	return func(db *gorm.DB, locale string) ([]TimelineEntry, error) {
+		var logs []models.TaskLog
+		if err := db.Find(&logs).Error; err != nil {
+			return nil, err
+		}
+		entries := make([]TimelineEntry, len(logs))
+		for i, log := range logs {
+			entries[i] = TimelineEntry{
+				ID: log.ID, Time: log.CreatedAt, AgentName: log.FromAgent,
+				Source: "task_log", Details: log.Payload,
+			}
+			entry(log, &entries[i], locale)
+		}
+		return entries, nil
+	}
+}
+
+func deploymentEventEntry(log models.TaskLog, e *TimelineEntry, _ string) {
+	var event protocol.DeploymentEventPayload
+	json.Unmarshal(log.Payload, &event)
+	e.Type, e.Status, e.AgentName = TimelineDeploy, event.Status, event.AgentName
+	e.Summary = fmt.Sprintf("%s of %s %s", capitalize(event.Action), event.AgentName, event.Status)
+	if event.Error != "" {
+		e.Summary += ": " + event.Error
+	}
+}
+
+func validationEntry(log models.TaskLog, e *TimelineEntry, _ string) {
+	var validation protocol.ContainerValidationPayload
+	json.Unmarshal(log.Payload, &validation)
+	e.Type, e.Summary = TimelineValidation, validation.Summary
+	if validation.AgentName != "" {
+		e.AgentName = validation.AgentName
+	}
+	// The status is the worst of the checks: error, warning or ok.
+	e.Status = string(protocol.ValidationOK)
+	for _, check := range validation.Checks {
+		switch check.Status {
+		case protocol.ValidationError:
+			e.Status = string(protocol.ValidationError)
+		case protocol.ValidationWarning:
+			if e.Status != string(protocol.ValidationError) {
+				e.Status = string(protocol.ValidationWarning)
+			}
+		}
+	}
+	if e.Summary == "" {
+		e.Summary = "Container validation " + e.Status
+	}
+}
+
+func runEntry(log models.TaskLog, e *TimelineEntry, locale string) {
+	var response protocol.LeaderResponsePayload
+	json.Unmarshal(log.Payload, &response)
+	e.Type, e.Status, e.Summary = TimelineRun, response.Status, truncateSummary(response.Result)
+	if response.Status == "failed" {
+		e.Type = TimelineError
+		msg := firstNonEmpty(response.Error, response.Result)
+		e.Summary = truncateSummary(msg)
+		e.Failure = failures.Agent(response.ErrorCode, msg, locale)
+	}
+}
+
+// truncateSummary shortens s to timelineSummaryLen runes.
+func truncateSummary(s string) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > timelineSummaryLen {
+		return string(r[:timelineSummaryLen]) + "…"
+	}
+	return s
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
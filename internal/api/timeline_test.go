@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// seedTimeline stores one record of each timeline source for teamID, a
+// minute apart, and returns their IDs newest first.
+func seedTimeline(t *testing.T, srv *Server, teamID string) []string {
+	t.Helper()
+	base := time.Now().UTC().Add(-time.Hour)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	payload := func(v interface{}) models.JSON {
+		data, _ := json.Marshal(v)
+		return models.JSON(data)
+	}
+
+	records := []interface{}{
+		&models.Job{ID: "tl-deploy", TeamID: teamID, Kind: jobTeamDeploy, Status: models.JobStatusFailed,
+			Error: "pulling image ghcr.io/acme/agent:latest: manifest unknown", CreatedAt: at(1)},
+		&models.TaskLog{ID: "tl-validation", TeamID: teamID, FromAgent: "leader", CreatedAt: at(2),
+			MessageType: string(protocol.TypeContainerValidation), Payload: payload(protocol.ContainerValidationPayload{
+				AgentName: "leader", Summary: "1 ok, 1 warning, 0 errors",
+				Checks: []protocol.ValidationCheck{{Name: "claude_md", Status: protocol.ValidationOK}, {Name: "skills", Status: protocol.ValidationWarning}},
+			})},
+		&models.TaskLog{ID: "tl-run", TeamID: teamID, FromAgent: "leader", CreatedAt: at(3),
+			MessageType: string(protocol.TypeLeaderResponse), Payload: payload(protocol.LeaderResponsePayload{Status: "completed", Result: "Deployed the fix"})},
+		&models.TaskLog{ID: "tl-error", TeamID: teamID, FromAgent: "leader", CreatedAt: at(4),
+			MessageType: string(protocol.TypeLeaderResponse), Payload: payload(protocol.LeaderResponsePayload{Status: "failed", Error: "rate limit exceeded", ErrorCode: "rate_limit_error"})},
+		&models.Schedule{ID: "tl-schedule", TeamID: teamID, Name: "nightly", Prompt: "run", CronExpression: "0 1 * * *"},
+		&models.ScheduleRun{ID: "tl-schedule-run", ScheduleID: "tl-schedule", Status: "success", StartedAt: at(5)},
+		&models.TaskLog{ID: "tl-restart", TeamID: teamID, FromAgent: "system", CreatedAt: at(6),
+			MessageType: string(protocol.TypeDeploymentEvent), Payload: payload(protocol.DeploymentEventPayload{
+				AgentName: "leader", Action: protocol.DeploymentActionRestart, Status: protocol.DeploymentStatusSucceeded,
+			})},
+		// Not part of the timeline.
+		&models.TaskLog{ID: "tl-user", TeamID: teamID, FromAgent: "user", CreatedAt: at(7),
+			MessageType: string(protocol.TypeUserMessage), Payload: payload(protocol.UserMessagePayload{Content: "hi"})},
+	}
+	for _, r := range records {
+		if err := srv.db.Create(r).Error; err != nil {
+			t.Fatalf("seeding timeline: %v", err)
+		}
+	}
+	return []string{"tl-restart", "tl-schedule-run", "tl-error", "tl-run", "tl-validation", "tl-deploy"}
+}
+
+func TestGetTimeline(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "timeline-team")
+	want := seedTimeline(t, srv, teamID)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/timeline", nil)
+	if rec.Code != 200 {
+		t.Fatalf("status: got %d, want 200\nbody: %s", rec.Code, rec.Body.String())
+	}
+	var resp ListResponse[TimelineEntry]
+	parseJSON(t, rec, &resp)
+	if resp.Total != int64(len(want)) || len(resp.Items) != len(want) {
+		t.Fatalf("entries: got %d of %d, want %d", len(resp.Items), resp.Total, len(want))
+	}
+
+	byID := make(map[string]TimelineEntry)
+	for i, e := range resp.Items {
+		if e.ID != want[i] {
+			t.Errorf("entry %d: got %s, want %s", i, e.ID, want[i])
+		}
+		byID[e.ID] = e
+	}
+	checks := []struct {
+		id, typ, status, summary string
+	}{
+		{"tl-restart", TimelineDeploy, "succeeded", "Restart of leader succeeded"},
+		{"tl-schedule-run", TimelineScheduleRun, "success", `Schedule "nightly" success`},
+		{"tl-error", TimelineError, "failed", "rate limit exceeded"},
+		{"tl-run", TimelineRun, "completed", "Deployed the fix"},
+		{"tl-validation", TimelineValidation, "warning", "1 ok, 1 warning, 0 errors"},
+		{"tl-deploy", TimelineDeploy, models.JobStatusFailed, "Team deployment failed: pulling image ghcr.io/acme/agent:latest: manifest unknown"},
+	}
+	for _, c := range checks {
+		e := byID[c.id]
+		if e.Type != c.typ || e.Status != c.status || e.Summary != c.summary {
+			t.Errorf("%s: got type %q status %q summary %q, want %q %q %q", c.id, e.Type, e.Status, e.Summary, c.typ, c.status, c.summary)
+		}
+	}
+	if f := byID["tl-error"].Failure; f == nil || f.Code != "agent_rate_limit" {
+		t.Errorf("run error failure: got %+v", f)
+	}
+	if f := byID["tl-deploy"].Failure; f == nil || f.Code != "deploy_image_pull" {
+		t.Errorf("deploy failure: got %+v", f)
+	}
+}
+
+func TestGetTimeline_TypeFilter(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "timeline-filter")
+	seedTimeline(t, srv, teamID)
+
+	rec := doRequest(srv, "GET", "/api/teams/"+teamID+"/timeline?type=deploy,error", nil)
+	var resp ListResponse[TimelineEntry]
+	parseJSON(t, rec, &resp)
+	var got []string
+	for _, e := range resp.Items {
+		got = append(got, e.ID)
+	}
+	if len(got) != 3 || got[0] != "tl-restart" || got[1] != "tl-error" || got[2] != "tl-deploy" {
+		t.Errorf("filtered entries: got %v", got)
+	}
+
+	rec = doRequest(srv, "GET", "/api/teams/"+teamID+"/timeline?type=bogus", nil)
+	if rec.Code != 400 {
+		t.Errorf("invalid type: got %d, want 400", rec.Code)
+	}
+}
+
+func TestGetTimeline_CursorPagination(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	teamID := createTeamForActivity(t, srv, "timeline-pages")
+	want := seedTimeline(t, srv, teamID)
+
+	var got []string
+	path := "/api/teams/" + teamID + "/timeline?limit=4"
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination did not end")
+		}
+		rec := doRequest(srv, "GET", path, nil)
+		var resp ListResponse[TimelineEntry]
+		parseJSON(t, rec, &resp)
+		for _, e := range resp.Items {
+			got = append(got, e.ID)
+		}
+		if resp.NextCursor == "" {
+			break
+		}
+		path = "/api/teams/" + teamID + "/timeline?limit=4&cursor=" + resp.NextCursor
+	}
+	if len(got) != len(want) {
+		t.Fatalf("paged entries: got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: got %s, want %s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestGetTimeline_TeamNotFound(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	rec := doRequest(srv, "GET", "/api/teams/missing/timeline", nil)
+	if rec.Code != 404 {
+		t.Errorf("status: got %d, want 404", rec.Code)
+	}
+}
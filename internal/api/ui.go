@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/filesystem"
+
+	"github.com/helmcode/agent-crew/internal/ui"
+)
+
+// uiContentSecurityPolicy only lets the dashboard load its own files and
+// call the API it is served by.
+const uiContentSecurityPolicy = "default-src 'self'; connect-src 'self' ws: wss:; img-src 'self' data:; " +
+	"object-src 'none'; base-uri 'none'; frame-ancestors 'none'"
+
+// registerUI serves the embedded dashboard under /ui. Its files are public
+// and read-only; the dashboard signs in through /api/auth like any client.
+func (s *Server) registerUI() {
+	s.App.Use("/ui", func(c *fiber.Ctx) error {
+		// The page loads its files relative to /ui/.
+		if c.Path() == "/ui" {
+			return c.Redirect("/ui/", fiber.StatusMovedPermanently)
+		}
+		c.Set(fiber.HeaderContentSecurityPolicy, uiContentSecurityPolicy)
+		c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+		return c.Next()
+	}, filesystem.New(filesystem.Config{
+		Root:   http.FS(ui.Assets()),
+		Index:  "/index.html",
+		MaxAge: 300,
+	}))
+}
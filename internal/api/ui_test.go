@@ -0,0 +1,48 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeUI(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	get := func(method, path string) (*http.Response, string) {
+		t.Helper()
+		resp, err := srv.App.Test(httptest.NewRequest(method, path, nil), -1)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, _ := get("GET", "/ui")
+	if resp.StatusCode != 301 || resp.Header.Get("Location") != "/ui/" {
+		t.Errorf("/ui: got %d to %q, want a redirect to /ui/", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	resp, body := get("GET", "/ui/")
+	if resp.StatusCode != 200 || !strings.Contains(body, "<title>AgentCrew</title>") {
+		t.Fatalf("index: got %d, body: %.200s", resp.StatusCode, body)
+	}
+	if csp := resp.Header.Get("Content-Security-Policy"); !strings.Contains(csp, "default-src 'self'") {
+		t.Errorf("Content-Security-Policy: got %q", csp)
+	}
+
+	resp, _ = get("GET", "/ui/app.js")
+	if resp.StatusCode != 200 || !strings.Contains(resp.Header.Get("Content-Type"), "javascript") {
+		t.Errorf("app.js: got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if resp, _ := get("GET", "/ui/missing.js"); resp.StatusCode != 404 {
+		t.Errorf("missing file: got %d, want 404", resp.StatusCode)
+	}
+	if resp, _ := get("POST", "/ui/app.js"); resp.StatusCode == 200 {
+		t.Error("POST: the dashboard files must be read-only")
+	}
+}
@@ -0,0 +1,443 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// maxUpgradeConcurrency caps how many leaders an upgrade restarts at once.
+const maxUpgradeConcurrency = 20
+
+// upgradeTeamTimeout bounds the restart of a single team's leader.
+var upgradeTeamTimeout = 5 * time.Minute
+
+// agentUpgradeListOptions configures GET /api/admin/upgrade-agents: newest
+// first, filterable by status.
+var agentUpgradeListOptions = listOptions{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Newest:       true,
+	Filters:      map[string]string{"status": "status"},
+}
+
+func agentUpgradeKey(u models.AgentUpgrade) (time.Time, string) { return u.CreatedAt, u.ID }
+
+// UpgradeAgents starts a rolling restart of the organization's running team
+// leaders onto a new agent image (admin only). Canary teams go first; the
+// upgrade halts if any of them fails.
+func (s *Server) UpgradeAgents(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can upgrade agents")
+	}
+
+	var req UpgradeAgentsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if req.Image == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "image is required")
+	}
+	if err := validateAgentImage(req.Image); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if req.Provider == "" {
+		req.Provider = "claude"
+	}
+	if req.Provider != "claude" && req.Provider != "opencode" {
+		return fiber.NewError(fiber.StatusBadRequest, "provider must be 'claude' or 'opencode'")
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+	if req.Concurrency < 1 || req.Concurrency > maxUpgradeConcurrency {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("concurrency must be between 1 and %d", maxUpgradeConcurrency))
+	}
+	if req.CanaryPercent < 0 || req.CanaryPercent > 100 {
+		return fiber.NewError(fiber.StatusBadRequest, "canary_percent must be between 0 and 100")
+	}
+
+	orgID := GetOrgID(c)
+	var active int64
+	s.db.Model(&models.AgentUpgrade{}).Scopes(OrgScope(c)).
+		Where("status IN ?", []string{models.AgentUpgradeStatusRunning, models.AgentUpgradeStatusRollingBack}).
+		Count(&active)
+	if active > 0 {
+		return fiber.NewError(fiber.StatusConflict, "another agent upgrade is in progress")
+	}
+
+	var teams []models.Team
+	if err := s.db.Scopes(OrgScope(c)).
+		Where("status = ? AND provider = ?", models.TeamStatusRunning, req.Provider).
+		Order("created_at ASC").Find(&teams).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	if len(teams) == 0 {
+		return fiber.NewError(fiber.StatusConflict, "no running "+req.Provider+" teams to upgrade")
+	}
+
+	upgrade := models.AgentUpgrade{
+		ID:            uuid.New().String(),
+		OrgID:         orgID,
+		Provider:      req.Provider,
+		Image:         req.Image,
+		Concurrency:   req.Concurrency,
+		CanaryPercent: req.CanaryPercent,
+		Status:        models.AgentUpgradeStatusRunning,
+	}
+	canaries := canaryCount(len(teams), req.CanaryPercent)
+	for i, team := range teams {
+		upgrade.Results = append(upgrade.Results, models.AgentUpgradeResult{
+			ID:            uuid.New().String(),
+			TeamID:        team.ID,
+			TeamName:      team.Name,
+			Canary:        i < canaries,
+			PreviousImage: team.AgentImage,
+			Status:        models.AgentUpgradeResultPending,
+		})
+	}
+	if err := s.db.Create(&upgrade).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to create agent upgrade")
+	}
+
+	if err := s.enqueueUpgradeJob(jobAgentUpgrade, upgrade); err != nil {
+		slog.Error("failed to queue agent upgrade", "id", upgrade.ID, "error", err)
+		s.db.Model(&upgrade).Updates(map[string]interface{}{
+			"status":      models.AgentUpgradeStatusHalted,
+			"error":       "failed to queue the upgrade",
+			"finished_at": time.Now(),
+		})
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to queue agent upgrade")
+	}
+	slog.Info("agent upgrade started", "id", upgrade.ID, "image", upgrade.Image,
+		"teams", len(teams), "canaries", canaries, "concurrency", upgrade.Concurrency)
+
+	return c.Status(fiber.StatusAccepted).JSON(upgrade)
+}
+
+// ListAgentUpgrades returns the organization's agent upgrades, without
+// their per-team results (admin only).
+func (s *Server) ListAgentUpgrades(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view agent upgrades")
+	}
+	q, err := parseListQuery(c, agentUpgradeListOptions)
+	if err != nil {
+		return err
+	}
+	resp, err := findPage(s.db.Scopes(OrgScope(c)), q, agentUpgradeKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list agent upgrades")
+	}
+	return c.JSON(resp)
+}
+
+// GetAgentUpgrade returns an agent upgrade with its per-team results
+// (admin only).
+func (s *Server) GetAgentUpgrade(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view agent upgrades")
+	}
+	var upgrade models.AgentUpgrade
+	if err := s.db.Scopes(OrgScope(c)).Preload("Results", func(db *gorm.DB) *gorm.DB {
+		return db.Order("canary DESC, team_name ASC")
+	}).First(&upgrade, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "agent upgrade not found")
+	}
+	return c.JSON(upgrade)
+}
+
+// RollbackAgentUpgrade restarts every team the upgrade touched on the image
+// its leader ran before, pinned by digest when known (admin only).
+func (s *Server) RollbackAgentUpgrade(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can roll back agent upgrades")
+	}
+	var upgrade models.AgentUpgrade
+	if err := s.db.Scopes(OrgScope(c)).Preload("Results").
+		First(&upgrade, "id = ?", c.Params("id")).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "agent upgrade not found")
+	}
+	switch upgrade.Status {
+	case models.AgentUpgradeStatusRunning, models.AgentUpgradeStatusRollingBack:
+		return fiber.NewError(fiber.StatusConflict, "agent upgrade is still in progress")
+	case models.AgentUpgradeStatusRolledBack:
+		return fiber.NewError(fiber.StatusConflict, "agent upgrade already rolled back")
+	}
+
+	previous := upgrade.Status
+	s.db.Model(&upgrade).Update("status", models.AgentUpgradeStatusRollingBack)
+	upgrade.Status = models.AgentUpgradeStatusRollingBack
+
+	if err := s.enqueueUpgradeJob(jobAgentRollback, upgrade); err != nil {
+		slog.Error("failed to queue agent upgrade rollback", "id", upgrade.ID, "error", err)
+		s.db.Model(&upgrade).Update("status", previous)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to queue agent upgrade rollback")
+	}
+	slog.Info("agent upgrade rollback started", "id", upgrade.ID)
+
+	return c.Status(fiber.StatusAccepted).JSON(upgrade)
+}
+
+// HaltInterruptedUpgrades marks upgrades left running by a previous API
+// process or owning replica as halted, so that new upgrades can start. An
+// upgrade or rollback with a job queued or running is in progress, on this
+// replica or another one, rather than interrupted, and is left alone.
+func (s *Server) HaltInterruptedUpgrades() {
+	var upgradeIDs []string
+	if err := s.db.Model(&models.AgentUpgrade{}).
+		Where("status IN ?", []string{models.AgentUpgradeStatusRunning, models.AgentUpgradeStatusRollingBack}).
+		Pluck("id", &upgradeIDs).Error; err != nil {
+		slog.Error("failed to list running agent upgrades", "error", err)
+		return
+	}
+	var interrupted []string
+	for _, id := range upgradeIDs {
+		if job, err := s.jobs.PendingKey(jobAgentUpgrade+"/"+id, jobAgentRollback+"/"+id); err != nil || job != nil {
+			continue
+		}
+		interrupted = append(interrupted, id)
+	}
+	if len(interrupted) == 0 {
+		return
+	}
+
+	now := time.Now()
+	res := s.db.Model(&models.AgentUpgrade{}).
+		Where("id IN ? AND status IN ?", interrupted, []string{models.AgentUpgradeStatusRunning, models.AgentUpgradeStatusRollingBack}).
+		Updates(map[string]interface{}{
+			"status":      models.AgentUpgradeStatusHalted,
+			"error":       "interrupted by an API restart",
+			"finished_at": now,
+		})
+	if res.Error != nil {
+		slog.Error("failed to halt interrupted agent upgrades", "error", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		slog.Warn("halted interrupted agent upgrades", "count", res.RowsAffected)
+	}
+}
+
+// canaryCount returns how many of n teams are canaries: percent of n,
+// rounded up, so any non-zero percentage upgrades at least one team first.
+func canaryCount(n, percent int) int {
+	return (n*percent + 99) / 100
+}
+
+// runAgentUpgrade upgrades the canary teams and then, if all succeeded, the
+// rest, and records the outcome on the upgrade.
+func (s *Server) runAgentUpgrade(upgrade models.AgentUpgrade) {
+	var canaries, rest []models.AgentUpgradeResult
+	for _, r := range upgrade.Results {
+		if r.Canary {
+			canaries = append(canaries, r)
+		} else {
+			rest = append(rest, r)
+		}
+	}
+
+	status, msg := models.AgentUpgradeStatusCompleted, ""
+	upgradeAll(canaries, upgrade.Concurrency, func(r *models.AgentUpgradeResult) {
+		s.upgradeTeam(upgrade, r)
+	})
+	for _, r := range canaries {
+		if r.Status == models.AgentUpgradeResultFailed {
+			status, msg = models.AgentUpgradeStatusHalted, "canary upgrade failed for team "+r.TeamName
+			break
+		}
+	}
+
+	if status == models.AgentUpgradeStatusHalted {
+		ids := make([]string, 0, len(rest))
+		for _, r := range rest {
+			ids = append(ids, r.ID)
+		}
+		if len(ids) > 0 {
+			s.db.Model(&models.AgentUpgradeResult{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+				"status": models.AgentUpgradeResultSkipped,
+				"error":  "upgrade halted: " + msg,
+			})
+		}
+	} else {
+		upgradeAll(rest, upgrade.Concurrency, func(r *models.AgentUpgradeResult) {
+			s.upgradeTeam(upgrade, r)
+		})
+	}
+
+	now := time.Now()
+	s.db.Model(&upgrade).Updates(map[string]interface{}{
+		"status":      status,
+		"error":       msg,
+		"finished_at": now,
+	})
+	slog.Info("agent upgrade finished", "id", upgrade.ID, "status", status)
+}
+
+// upgradeTeam restarts one team's leader on the upgrade's image, recording
+// the image it ran before.
+func (s *Server) upgradeTeam(upgrade models.AgentUpgrade, result *models.AgentUpgradeResult) {
+	op, err := s.beginTeamOp(result.TeamID, teamOpUpgrade, false)
+	if err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultSkipped, "another operation is in progress on the team")
+		return
+	}
+	defer s.endTeamOp(result.TeamID, op)
+
+	ctx, cancel := context.WithTimeout(op.ctx, upgradeTeamTimeout)
+	defer cancel()
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", result.TeamID).Error; err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultSkipped, "team not found")
+		return
+	}
+	if team.Status != models.TeamStatusRunning {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultSkipped, "team is not running")
+		return
+	}
+	leader, ok := teamLeader(team)
+	if !ok {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultSkipped, "team has no leader agent")
+		return
+	}
+
+	result.PreviousImage = team.AgentImage
+	if digester, ok := s.runtime.(runtime.ImageDigester); ok && leader.ContainerID != "" {
+		digest, err := digester.ImageDigest(ctx, leader.ContainerID)
+		if err != nil {
+			slog.Warn("failed to read leader image digest", "team", team.Name, "error", err)
+		}
+		result.PreviousDigest = digest
+	}
+	s.db.Model(result).Updates(map[string]interface{}{
+		"previous_image":  result.PreviousImage,
+		"previous_digest": result.PreviousDigest,
+	})
+
+	s.db.Model(&team).Update("agent_image", upgrade.Image)
+	team.AgentImage = upgrade.Image
+	s.markLeaderRestarting(&team)
+
+	if err := s.restartLeader(ctx, team, leader, protocol.DeploymentActionUpgrade, true); err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultFailed, err.Error())
+		return
+	}
+	s.finishUpgradeResult(result, models.AgentUpgradeResultSucceeded, "")
+}
+
+// runAgentRollback restores the previous image of every team the upgrade
+// changed and restarts the leaders that are still deployed.
+func (s *Server) runAgentRollback(upgrade models.AgentUpgrade) {
+	var changed []models.AgentUpgradeResult
+	for _, r := range upgrade.Results {
+		if r.Status == models.AgentUpgradeResultSucceeded || r.Status == models.AgentUpgradeResultFailed {
+			changed = append(changed, r)
+		}
+	}
+
+	upgradeAll(changed, upgrade.Concurrency, s.rollbackTeam)
+
+	now := time.Now()
+	s.db.Model(&upgrade).Updates(map[string]interface{}{
+		"status":      models.AgentUpgradeStatusRolledBack,
+		"finished_at": now,
+	})
+	slog.Info("agent upgrade rolled back", "id", upgrade.ID, "teams", len(changed))
+}
+
+// rollbackTeam sets the team's agent image back to the previous digest, or
+// the previous image when the digest is unknown, and restarts its leader
+// if the team is deployed.
+func (s *Server) rollbackTeam(result *models.AgentUpgradeResult) {
+	op, err := s.beginTeamOp(result.TeamID, teamOpRollback, false)
+	if err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultRollbackFailed, "another operation is in progress on the team")
+		return
+	}
+	defer s.endTeamOp(result.TeamID, op)
+
+	ctx, cancel := context.WithTimeout(op.ctx, upgradeTeamTimeout)
+	defer cancel()
+
+	image := result.PreviousImage
+	if result.PreviousDigest != "" {
+		image = result.PreviousDigest
+	}
+
+	var team models.Team
+	if err := s.db.Preload("Agents").First(&team, "id = ?", result.TeamID).Error; err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultRollbackFailed, "team not found")
+		return
+	}
+	s.db.Model(&team).Update("agent_image", image)
+	team.AgentImage = image
+
+	leader, ok := teamLeader(team)
+	if !ok || (team.Status != models.TeamStatusRunning && team.Status != models.TeamStatusError) {
+		// Not deployed: the next deploy picks up the restored image.
+		s.finishUpgradeResult(result, models.AgentUpgradeResultRolledBack, "")
+		return
+	}
+
+	s.markLeaderRestarting(&team)
+	// A digest never changes, so only pull when rolling back to one.
+	if err := s.restartLeader(ctx, team, leader, protocol.DeploymentActionRollback, result.PreviousDigest != ""); err != nil {
+		s.finishUpgradeResult(result, models.AgentUpgradeResultRollbackFailed, err.Error())
+		return
+	}
+	s.finishUpgradeResult(result, models.AgentUpgradeResultRolledBack, "")
+}
+
+// finishUpgradeResult records a team's upgrade or rollback outcome.
+func (s *Server) finishUpgradeResult(result *models.AgentUpgradeResult, status, msg string) {
+	now := time.Now()
+	result.Status = status
+	result.Error = msg
+	result.FinishedAt = &now
+	if err := s.db.Model(result).Updates(map[string]interface{}{
+		"status":      status,
+		"error":       msg,
+		"finished_at": now,
+	}).Error; err != nil {
+		slog.Error("failed to record agent upgrade result", "team", result.TeamName, "error", err)
+	}
+}
+
+// upgradeAll runs fn on every result, at most concurrency at a time, and
+// waits for them to finish.
+func upgradeAll(results []models.AgentUpgradeResult, concurrency int, fn func(*models.AgentUpgradeResult)) {
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *models.AgentUpgradeResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(r)
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// teamLeader returns the team's leader agent; team must be loaded with its
+// agents.
+func teamLeader(team models.Team) (models.Agent, bool) {
+	for _, a := range team.Agents {
+		if a.Role == models.AgentRoleLeader {
+			return a, true
+		}
+	}
+	return models.Agent{}, false
+}
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestCanaryCount(t *testing.T) {
+	tests := []struct{ n, percent, want int }{
+		{10, 0, 0},
+		{10, 10, 1},
+		{3, 30, 1},
+		{3, 34, 2},
+		{1, 1, 1},
+		{5, 100, 5},
+	}
+	for _, tt := range tests {
+		if got := canaryCount(tt.n, tt.percent); got != tt.want {
+			t.Errorf("canaryCount(%d, %d) = %d, want %d", tt.n, tt.percent, got, tt.want)
+		}
+	}
+}
+
+// waitForUpgrade polls the upgrade until it leaves the given status.
+func waitForUpgrade(t *testing.T, srv *Server, id, status string) models.AgentUpgrade {
+	t.Helper()
+	var upgrade models.AgentUpgrade
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		rec := doRequest(srv, "GET", "/api/admin/upgrade-agents/"+id, nil)
+		parseJSON(t, rec, &upgrade)
+		if upgrade.Status != status {
+			return upgrade
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("upgrade %s still %s", id, status)
+	return upgrade
+}
+
+func TestUpgradeAgents_Validation(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	tests := []struct {
+		name string
+		req  UpgradeAgentsRequest
+		want int
+	}{
+		{"missing image", UpgradeAgentsRequest{}, 400},
+		{"bare image", UpgradeAgentsRequest{Image: "agent"}, 400},
+		{"bad provider", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:2", Provider: "gpt"}, 400},
+		{"concurrency", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:2", Concurrency: maxUpgradeConcurrency + 1}, 400},
+		{"canary", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:2", CanaryPercent: 101}, 400},
+		{"no running teams", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:2"}, 409},
+	}
+	for _, tt := range tests {
+		rec := doRequest(srv, "POST", "/api/admin/upgrade-agents", tt.req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d (%s)", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestUpgradeAgents_UpgradeAndRollback(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	var teams []models.Team
+	for _, name := range []string{"upgrade-a", "upgrade-b", "upgrade-c"} {
+		teams = append(teams, createRunningTeam(t, srv, name, "old-"+name))
+	}
+
+	rec := doRequest(srv, "POST", "/api/admin/upgrade-agents", UpgradeAgentsRequest{
+		Image:         "ghcr.io/acme/agent:2",
+		Concurrency:   2,
+		CanaryPercent: 30,
+	})
+	if rec.Code != 202 {
+		t.Fatalf("upgrade: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var upgrade models.AgentUpgrade
+	parseJSON(t, rec, &upgrade)
+	if len(upgrade.Results) != 3 {
+		t.Fatalf("results: got %d, want 3", len(upgrade.Results))
+	}
+
+	upgrade = waitForUpgrade(t, srv, upgrade.ID, models.AgentUpgradeStatusRunning)
+	if upgrade.Status != models.AgentUpgradeStatusCompleted {
+		t.Fatalf("upgrade status: got %q (%s), want completed", upgrade.Status, upgrade.Error)
+	}
+	canaries := 0
+	for _, r := range upgrade.Results {
+		if r.Status != models.AgentUpgradeResultSucceeded {
+			t.Errorf("team %s: got %q (%s), want succeeded", r.TeamName, r.Status, r.Error)
+		}
+		if r.PreviousDigest != "ghcr.io/helmcode/agent_crew_agent@sha256:old-"+r.TeamName {
+			t.Errorf("team %s: previous digest %q", r.TeamName, r.PreviousDigest)
+		}
+		if r.Canary {
+			canaries++
+		}
+	}
+	if canaries != 1 {
+		t.Errorf("canaries: got %d, want 1", canaries)
+	}
+	for _, team := range teams {
+		var current models.Team
+		srv.db.First(&current, "id = ?", team.ID)
+		if current.AgentImage != "ghcr.io/acme/agent:2" || current.Status != models.TeamStatusRunning {
+			t.Errorf("team %s: image %q, status %q", team.Name, current.AgentImage, current.Status)
+		}
+	}
+
+	rec = doRequest(srv, "POST", "/api/admin/upgrade-agents/"+upgrade.ID+"/rollback", nil)
+	if rec.Code != 202 {
+		t.Fatalf("rollback: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	upgrade = waitForUpgrade(t, srv, upgrade.ID, models.AgentUpgradeStatusRollingBack)
+	if upgrade.Status != models.AgentUpgradeStatusRolledBack {
+		t.Fatalf("rollback status: got %q", upgrade.Status)
+	}
+	for _, r := range upgrade.Results {
+		if r.Status != models.AgentUpgradeResultRolledBack {
+			t.Errorf("team %s: got %q (%s), want rolled_back", r.TeamName, r.Status, r.Error)
+		}
+		var current models.Team
+		srv.db.First(&current, "id = ?", r.TeamID)
+		if current.AgentImage != r.PreviousDigest {
+			t.Errorf("team %s: image %q, want %q", r.TeamName, current.AgentImage, r.PreviousDigest)
+		}
+	}
+
+	rec = doRequest(srv, "POST", "/api/admin/upgrade-agents/"+upgrade.ID+"/rollback", nil)
+	if rec.Code != 409 {
+		t.Errorf("second rollback: got %d, want 409", rec.Code)
+	}
+}
+
+func TestUpgradeAgents_CanaryFailureHalts(t *testing.T) {
+	srv, mock := setupTestServer(t)
+	for _, name := range []string{"canary-a", "canary-b", "canary-c"} {
+		createRunningTeam(t, srv, name, "old-"+name)
+	}
+	mock.failImage = "ghcr.io/acme/agent:broken"
+
+	rec := doRequest(srv, "POST", "/api/admin/upgrade-agents", UpgradeAgentsRequest{
+		Image:         "ghcr.io/acme/agent:broken",
+		CanaryPercent: 10,
+	})
+	if rec.Code != 202 {
+		t.Fatalf("upgrade: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var upgrade models.AgentUpgrade
+	parseJSON(t, rec, &upgrade)
+
+	upgrade = waitForUpgrade(t, srv, upgrade.ID, models.AgentUpgradeStatusRunning)
+	if upgrade.Status != models.AgentUpgradeStatusHalted {
+		t.Fatalf("upgrade status: got %q, want halted", upgrade.Status)
+	}
+	statuses := map[string]int{}
+	for _, r := range upgrade.Results {
+		statuses[r.Status]++
+		if !r.Canary {
+			var current models.Team
+			srv.db.First(&current, "id = ?", r.TeamID)
+			if current.AgentImage != "" {
+				t.Errorf("skipped team %s was changed to %q", r.TeamName, current.AgentImage)
+			}
+		}
+	}
+	if statuses[models.AgentUpgradeResultFailed] != 1 || statuses[models.AgentUpgradeResultSkipped] != 2 {
+		t.Errorf("result statuses: got %v, want 1 failed and 2 skipped", statuses)
+	}
+
+	// Rolling back restores the failed canary.
+	rec = doRequest(srv, "POST", "/api/admin/upgrade-agents/"+upgrade.ID+"/rollback", nil)
+	if rec.Code != 202 {
+		t.Fatalf("rollback: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	upgrade = waitForUpgrade(t, srv, upgrade.ID, models.AgentUpgradeStatusRollingBack)
+	for _, r := range upgrade.Results {
+		if !r.Canary {
+			continue
+		}
+		var current models.Team
+		srv.db.First(&current, "id = ?", r.TeamID)
+		if r.Status != models.AgentUpgradeResultRolledBack || current.Status != models.TeamStatusRunning {
+			t.Errorf("canary %s: result %q (%s), team %q", r.TeamName, r.Status, r.Error, current.Status)
+		}
+	}
+}
+
+func TestHaltInterruptedUpgrades(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	createRunningTeam(t, srv, "interrupted-team", "old-interrupted-team")
+	var org models.Organization
+	srv.db.First(&org)
+	upgrade := models.AgentUpgrade{ID: "interrupted", OrgID: org.ID, Image: "ghcr.io/acme/agent:2", Status: models.AgentUpgradeStatusRunning}
+	srv.db.Create(&upgrade)
+
+	rec := doRequest(srv, "POST", "/api/admin/upgrade-agents", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:3"})
+	if rec.Code != 409 {
+		t.Errorf("upgrade while another runs: got %d, want 409", rec.Code)
+	}
+
+	// An upgrade whose job is still queued is in progress.
+	job, _ := srv.jobs.Enqueue(jobs.Options{Kind: "other", IdempotencyKey: jobAgentUpgrade + "/" + upgrade.ID})
+	srv.HaltInterruptedUpgrades()
+	srv.db.First(&upgrade, "id = ?", upgrade.ID)
+	if upgrade.Status != models.AgentUpgradeStatusRunning {
+		t.Fatalf("upgrade with a queued job: got status %q", upgrade.Status)
+	}
+
+	srv.db.Model(job).Update("status", models.JobStatusFailed)
+	srv.HaltInterruptedUpgrades()
+
+	srv.db.First(&upgrade, "id = ?", upgrade.ID)
+	if upgrade.Status != models.AgentUpgradeStatusHalted || upgrade.FinishedAt == nil {
+		t.Errorf("got status %q, finished_at %v", upgrade.Status, upgrade.FinishedAt)
+	}
+	rec = doRequest(srv, "POST", "/api/admin/upgrade-agents", UpgradeAgentsRequest{Image: "ghcr.io/acme/agent:3"})
+	if rec.Code != 202 {
+		t.Errorf("upgrade after halting: got %d, want 202", rec.Code)
+	}
+	var next models.AgentUpgrade
+	parseJSON(t, rec, &next)
+	waitForUpgrade(t, srv, next.ID, models.AgentUpgradeStatusRunning)
+}
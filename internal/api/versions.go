@@ -0,0 +1,154 @@
+package api
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// GetAgentVersions reports the tool versions of the organization's agent
+// containers and flags those behind the newest version seen across the
+// fleet (admin only). ?outdated=true lists only outdated agents.
+func (s *Server) GetAgentVersions(c *fiber.Ctx) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can view agent versions")
+	}
+
+	var teams []models.Team
+	if err := s.db.Scopes(OrgScope(c)).Select("id", "name", "provider").Find(&teams).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to list teams")
+	}
+	teamsByID := make(map[string]models.Team, len(teams))
+	teamIDs := make([]string, 0, len(teams))
+	for _, t := range teams {
+		teamsByID[t.ID] = t
+		teamIDs = append(teamIDs, t.ID)
+	}
+	// Only leaders run a container; workers are sub-agents inside it.
+	var agents []models.Agent
+	if len(teamIDs) > 0 {
+		if err := s.db.Where("team_id IN ? AND role = ?", teamIDs, models.AgentRoleLeader).
+			Order("created_at ASC").Find(&agents).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to list agents")
+		}
+	}
+
+	report := AgentVersionsReport{Agents: []AgentVersionsEntry{}}
+	entries := make([]AgentVersionsEntry, 0, len(agents))
+	for _, a := range agents {
+		team := teamsByID[a.TeamID]
+		entry := AgentVersionsEntry{
+			AgentID:    a.ID,
+			AgentName:  a.Name,
+			TeamID:     a.TeamID,
+			TeamName:   team.Name,
+			Provider:   team.Provider,
+			ReportedAt: a.VersionsReportedAt,
+			Outdated:   []string{},
+		}
+		if a.VersionsReportedAt != nil && len(a.Versions) > 0 {
+			var v protocol.ToolVersions
+			if err := json.Unmarshal(a.Versions, &v); err == nil {
+				entry.Versions = &v
+				report.Latest = newerToolVersions(report.Latest, v)
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	onlyOutdated := c.QueryBool("outdated")
+	for _, entry := range entries {
+		if entry.Versions != nil {
+			entry.Outdated = outdatedTools(*entry.Versions, report.Latest)
+		}
+		if len(entry.Outdated) > 0 {
+			report.OutdatedCount++
+		} else if onlyOutdated {
+			continue
+		}
+		report.Agents = append(report.Agents, entry)
+	}
+	return c.JSON(report)
+}
+
+// newerToolVersions returns, per tool, the newer of the versions in a and b.
+func newerToolVersions(a, b protocol.ToolVersions) protocol.ToolVersions {
+	newer := func(x, y string) string {
+		if compareVersions(y, x) > 0 {
+			return y
+		}
+		return x
+	}
+	return protocol.ToolVersions{
+		Claude:   newer(a.Claude, b.Claude),
+		OpenCode: newer(a.OpenCode, b.OpenCode),
+		Node:     newer(a.Node, b.Node),
+		Skills:   newer(a.Skills, b.Skills),
+	}
+}
+
+// outdatedTools returns the tools whose version in v is older than in
+// latest. Tools v does not report are not outdated: they are not installed.
+func outdatedTools(v, latest protocol.ToolVersions) []string {
+	outdated := []string{}
+	check := func(name, have, want string) {
+		if have != "" && compareVersions(have, want) < 0 {
+			outdated = append(outdated, name)
+		}
+	}
+	check("claude", v.Claude, latest.Claude)
+	check("opencode", v.OpenCode, latest.OpenCode)
+	check("node", v.Node, latest.Node)
+	check("skills", v.Skills, latest.Skills)
+	return outdated
+}
+
+// compareVersions compares dotted versions such as "1.0.83" numerically,
+// returning -1, 0 or 1. A pre-release ("1.1.0-beta.1") sorts before its
+// release, and an empty version before any other.
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+	if b == "" {
+		return 1
+	}
+	aCore, aPre, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	bCore, bPre, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+	aParts, bParts := strings.Split(aCore, "."), strings.Split(bCore, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(bParts[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	case aPre < bPre:
+		return -1
+	default:
+		return 1
+	}
+}
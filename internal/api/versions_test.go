@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.83", "1.0.83", 0},
+		{"1.0.83", "1.0.100", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"20.11", "20.11.0", 0},
+		{"v20.11.1", "20.11.0", 1},
+		{"1.1.0-beta.1", "1.1.0", -1},
+		{"1.1.0-beta.2", "1.1.0-beta.1", 1},
+		{"", "1.0.0", -1},
+		{"1.0.0", "", 1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAgentVersions(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	report := func(teamName, agentName string, versions *protocol.ToolVersions) {
+		t.Helper()
+		var team models.Team
+		srv.db.First(&team, "name = ?", teamName)
+		data := buildRelayPayload(t, protocol.TypeContainerValidation, agentName, "system",
+			protocol.ContainerValidationPayload{AgentName: agentName, Summary: "1 ok", Versions: versions})
+		if err := srv.processRelayMessage(team.ID, team.Name, data); err != nil {
+			t.Fatalf("processRelayMessage: %v", err)
+		}
+	}
+
+	for _, name := range []string{"versions-new", "versions-old", "versions-silent"} {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:   name,
+			Agents: []CreateAgentInput{{Name: "Team Leader", Role: "leader"}, {Name: "worker"}},
+		})
+		if rec.Code != 201 {
+			t.Fatalf("create team %s: %d %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	report("versions-new", "team-leader", &protocol.ToolVersions{Claude: "1.0.90", Node: "20.11.1", Skills: "1.2.0"})
+	report("versions-old", "team-leader", &protocol.ToolVersions{Claude: "1.0.83", Node: "20.11.1", Skills: "1.2.0"})
+	// Older sidecars send no versions.
+	report("versions-silent", "team-leader", nil)
+
+	rec := doRequest(srv, "GET", "/api/admin/agent-versions", nil)
+	if rec.Code != 200 {
+		t.Fatalf("agent versions: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	var body AgentVersionsReport
+	parseJSON(t, rec, &body)
+
+	if body.Latest.Claude != "1.0.90" || body.Latest.Node != "20.11.1" {
+		t.Errorf("latest: got %+v", body.Latest)
+	}
+	if len(body.Agents) != 3 || body.OutdatedCount != 1 {
+		t.Fatalf("got %d agents, %d outdated; want 3 leaders, 1 outdated", len(body.Agents), body.OutdatedCount)
+	}
+	for _, a := range body.Agents {
+		switch a.TeamName {
+		case "versions-old":
+			if len(a.Outdated) != 1 || a.Outdated[0] != "claude" {
+				t.Errorf("old agent outdated: got %v, want [claude]", a.Outdated)
+			}
+		case "versions-new":
+			if a.Versions == nil || a.ReportedAt == nil || len(a.Outdated) != 0 {
+				t.Errorf("new agent: got %+v", a)
+			}
+		case "versions-silent":
+			if a.Versions != nil || a.ReportedAt != nil {
+				t.Errorf("silent agent should have no versions: got %+v", a)
+			}
+		}
+	}
+
+	rec = doRequest(srv, "GET", "/api/admin/agent-versions?outdated=true", nil)
+	parseJSON(t, rec, &body)
+	if len(body.Agents) != 1 || body.Agents[0].TeamName != "versions-old" {
+		t.Errorf("outdated filter: got %+v", body.Agents)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
+)
+
+// workingHoursInterval is how often teams left running outside their
+// working hours are looked for.
+const workingHoursInterval = time.Minute
+
+// suspendedOutsideWorkingHours is the status message of a team suspended
+// outside its working hours.
+const suspendedOutsideWorkingHours = "Suspended outside working hours"
+
+// StartWorkingHoursMonitor starts the background loop that stops the teams
+// whose working-hours policy asks for auto-suspend once the window closes.
+func (s *Server) StartWorkingHoursMonitor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.workingHoursCancel = cancel
+	s.workingHoursWg.Add(1)
+	go func() {
+		defer s.workingHoursWg.Done()
+		ticker := time.NewTicker(workingHoursInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.suspendOutsideWorkingHours(ctx, time.Now())
+			}
+		}
+	}()
+}
+
+// stopWorkingHoursMonitor stops the working-hours loop, if running, and
+// waits for it.
+func (s *Server) stopWorkingHoursMonitor() {
+	if s.workingHoursCancel != nil {
+		s.workingHoursCancel()
+	}
+	s.workingHoursWg.Wait()
+}
+
+// suspendOutsideWorkingHours stops the running teams with auto-suspend
+// that are outside their working hours at now, and returns their IDs.
+// Teams running a schedule or webhook, or busy with another operation, are
+// left for the next round.
+func (s *Server) suspendOutsideWorkingHours(ctx context.Context, now time.Time) []string {
+	var teams []models.Team
+	if err := s.db.Preload("Agents").
+		Where("status = ? AND working_hours IS NOT NULL AND working_hours NOT IN ('', 'null')", models.TeamStatusRunning).
+		Find(&teams).Error; err != nil {
+		slog.Error("working hours: failed to list teams", "error", err)
+		return nil
+	}
+
+	var suspended []string
+	for i := range teams {
+		team := &teams[i]
+		policy, err := workhours.Parse(team.WorkingHours)
+		if err != nil || policy == nil || !policy.AutoSuspend || policy.Contains(now) {
+			continue
+		}
+		if s.teamHasActiveRuns(team.ID) {
+			continue
+		}
+		op, err := s.beginTeamOp(team.ID, teamOpStop, false)
+		if err != nil {
+			continue
+		}
+		stopCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		s.stopTeam(stopCtx, team, true)
+		cancel()
+		s.endTeamOp(team.ID, op)
+		s.db.Model(team).Update("status_message", suspendedOutsideWorkingHours)
+		slog.Info("team suspended outside working hours", "team", team.Name)
+		suspended = append(suspended, team.ID)
+	}
+	return suspended
+}
+
+// teamHasActiveRuns reports whether a schedule or webhook is running on the
+// team.
+func (s *Server) teamHasActiveRuns(teamID string) bool {
+	var schedules int64
+	s.db.Model(&models.Schedule{}).
+		Where("team_id = ? AND status = ?", teamID, models.ScheduleStatusRunning).
+		Count(&schedules)
+	if schedules > 0 {
+		return true
+	}
+	var webhookRuns int64
+	s.db.Model(&models.WebhookRun{}).
+		Joins("JOIN webhooks ON webhooks.id = webhook_runs.webhook_id").
+		Where("webhooks.team_id = ? AND webhook_runs.status = ?", teamID, models.WebhookRunStatusRunning).
+		Count(&webhookRuns)
+	return webhookRuns > 0
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
+)
+
+func TestTeamWorkingHours(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:         "hours-team",
+		WorkingHours: &workhours.Policy{Start: "9am", End: "18:00"},
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid policy: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:         "hours-team",
+		WorkingHours: &workhours.Policy{Timezone: "Europe/Madrid", Start: "09:00", End: "18:00", AutoSuspend: true},
+		Agents:       []CreateAgentInput{{Name: "boss", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if p, err := workhours.Parse(team.WorkingHours); err != nil || p == nil || !p.AutoSuspend {
+		t.Fatalf("working_hours: got %s", team.WorkingHours)
+	}
+
+	rec = doRequest(srv, "POST", "/api/schedules", CreateScheduleRequest{
+		Name: "nightly", TeamID: team.ID, Prompt: "report", CronExpression: "0 0 * * *", WorkingHours: "later",
+	})
+	if rec.Code != 400 {
+		t.Errorf("invalid schedule override: got %d, want 400", rec.Code)
+	}
+	rec = doRequest(srv, "POST", "/api/schedules", CreateScheduleRequest{
+		Name: "nightly", TeamID: team.ID, Prompt: "report", CronExpression: "0 0 * * *", WorkingHours: workhours.ActionDefer,
+	})
+	var schedule models.Schedule
+	parseJSON(t, rec, &schedule)
+	if schedule.WorkingHours != workhours.ActionDefer {
+		t.Errorf("schedule working_hours: got %q, want %q", schedule.WorkingHours, workhours.ActionDefer)
+	}
+
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{WorkingHours: &workhours.Policy{}})
+	parseJSON(t, rec, &team)
+	if p, _ := workhours.Parse(team.WorkingHours); p != nil {
+		t.Errorf("working_hours after removal: got %s", team.WorkingHours)
+	}
+}
+
+func TestSuspendOutsideWorkingHours(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	policy := `{"days":["mon","tue","wed","thu","fri"],"start":"09:00","end":"18:00","auto_suspend":true}`
+	teams := map[string]models.Team{}
+	for _, name := range []string{"suspend-me", "keep-scheduled", "keep-no-suspend"} {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:   name,
+			Agents: []CreateAgentInput{{Name: "boss", Role: "leader"}},
+		})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		hours := policy
+		if name == "keep-no-suspend" {
+			hours = `{"start":"09:00","end":"18:00"}`
+		}
+		srv.db.Model(&team).Updates(map[string]interface{}{
+			"status":        models.TeamStatusRunning,
+			"working_hours": models.JSON(hours),
+		})
+		teams[name] = team
+	}
+	srv.db.Create(&models.Schedule{
+		ID: "running-schedule", Name: "running", TeamID: teams["keep-scheduled"].ID, Prompt: "p",
+		CronExpression: "* * * * *", Timezone: "UTC", Enabled: true, Status: models.ScheduleStatusRunning,
+	})
+
+	// Within the working hours nothing is suspended.
+	if got := srv.suspendOutsideWorkingHours(t.Context(), time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)); len(got) != 0 {
+		t.Errorf("within working hours: suspended %v", got)
+	}
+
+	saturday := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	got := srv.suspendOutsideWorkingHours(t.Context(), saturday)
+	if len(got) != 1 || got[0] != teams["suspend-me"].ID {
+		t.Fatalf("suspended: got %v, want only suspend-me", got)
+	}
+	var team models.Team
+	srv.db.First(&team, "id = ?", teams["suspend-me"].ID)
+	if team.Status != models.TeamStatusStopped || team.StatusMessage != suspendedOutsideWorkingHours {
+		t.Errorf("suspended team: got %q %q", team.Status, team.StatusMessage)
+	}
+	if !mock.teardownCalled {
+		t.Error("expected the team's infrastructure to be torn down")
+	}
+	var scheduled models.Team
+	srv.db.First(&scheduled, "id = ?", teams["keep-scheduled"].ID)
+	if scheduled.Status != models.TeamStatusRunning {
+		t.Errorf("team running a schedule: got %q, want running", scheduled.Status)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// checkWorkspaceSharing returns an error if team cannot be deployed because
+// another running team mounts the same host workspace and they do not both
+// use config_dir_mode "shared". Teams of other organizations are not named.
+func (s *Server) checkWorkspaceSharing(team models.Team) error {
+	if team.ConfigDirMode == models.ConfigDirModeShared && team.WorkspacePath == "" {
+		return newAPIError(CodeWorkspaceInvalid, `config_dir_mode "shared" requires a workspace_path`)
+	}
+	if team.WorkspacePath == "" {
+		return nil
+	}
+
+	var others []models.Team
+	if err := s.db.Select("id", "org_id", "name", "workspace_path", "config_dir_mode").
+		Where("id <> ? AND workspace_path <> '' AND status IN ?", team.ID,
+			[]string{models.TeamStatusRunning, models.TeamStatusDeploying}).
+		Find(&others).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to check workspace")
+	}
+	for _, other := range others {
+		if !models.WorkspaceClashes(team, other) {
+			continue
+		}
+		owner := "a team in another organization"
+		if other.OrgID == team.OrgID {
+			owner = fmt.Sprintf("team %q", other.Name)
+		}
+		return newAPIError(CodeWorkspaceInUse, fmt.Sprintf(
+			"workspace %s is in use by %s; teams can share a workspace only when all of them use config_dir_mode \"shared\"",
+			team.WorkspacePath, owner))
+	}
+	return nil
+}
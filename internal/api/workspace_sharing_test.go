@@ -0,0 +1,94 @@
+package api
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestDeployTeam_WorkspaceSharing(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	create := func(name, mode, path string) models.Team {
+		rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+			Name:          name,
+			WorkspacePath: path,
+			ConfigDirMode: mode,
+			Agents:        []CreateAgentInput{{Name: "leader", Role: "leader"}},
+		})
+		var team models.Team
+		parseJSON(t, rec, &team)
+		return team
+	}
+	running := create("running-inline", models.ConfigDirModeInline, "/srv/repo")
+	srv.db.Model(&running).Update("status", models.TeamStatusRunning)
+
+	shared := create("shared", models.ConfigDirModeShared, "/srv/repo/")
+	rec := doRequest(srv, "POST", "/api/teams/"+shared.ID+"/deploy", nil)
+	if rec.Code != 409 {
+		t.Fatalf("status: got %d, want 409\nbody: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `running-inline`) {
+		t.Errorf("error does not name the running team: %s", rec.Body.String())
+	}
+
+	// Teams that all use the shared mode can run on the same workspace.
+	srv.db.Model(&running).Update("config_dir_mode", models.ConfigDirModeShared)
+	if err := srv.checkWorkspaceSharing(shared); err != nil {
+		t.Errorf("checkWorkspaceSharing: %v", err)
+	}
+
+	noPath := create("shared-no-path", models.ConfigDirModeShared, "")
+	rec = doRequest(srv, "POST", "/api/teams/"+noPath.ID+"/deploy", nil)
+	if rec.Code != 400 {
+		t.Errorf("shared mode without workspace: got %d, want 400", rec.Code)
+	}
+}
+
+func TestDeployTeamAsync_SharedWorkspace(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	srv, mock := setupTestServer(t)
+
+	repo := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	teamRec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:          "shared-team",
+		WorkspacePath: repo,
+		ConfigDirMode: models.ConfigDirModeShared,
+		Agents:        []CreateAgentInput{{Name: "the-leader", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, teamRec, &team)
+
+	srv.deployTeamAsync(t.Context(), team)
+
+	var deployed models.Team
+	srv.db.First(&deployed, "id = ?", team.ID)
+	if deployed.Status != models.TeamStatusRunning {
+		t.Fatalf("status = %q (%s), want running", deployed.Status, deployed.StatusMessage)
+	}
+	worktree := filepath.Join(repo, ".agentcrew", "shared-team", "worktree")
+	if _, err := os.Stat(filepath.Join(worktree, ".claude", "CLAUDE.md")); err != nil {
+		t.Errorf("CLAUDE.md not written into the team worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, ".claude")); !os.IsNotExist(err) {
+		t.Errorf("config written into the shared workspace root: %v", err)
+	}
+	if cfg := mock.lastAgentConfig; cfg == nil || cfg.ConfigDirMode != models.ConfigDirModeShared || cfg.WorkspacePath != repo {
+		t.Errorf("agent config = %+v", cfg)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/jobs"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// workspaceSyncTimeout bounds a workspace sync, including the wait for its
+// Job to be scheduled.
+const workspaceSyncTimeout = 30 * time.Minute
+
+// workspaceSyncPayload is the payload of a jobWorkspaceSync job.
+type workspaceSyncPayload struct {
+	Direction string `json:"direction"`
+	URL       string `json:"url"`
+	Delete    bool   `json:"delete"`
+}
+
+// PushTeamWorkspace handles POST /api/teams/:id/workspace/push. It copies
+// the team's workspace to an S3 prefix in a background job.
+func (s *Server) PushTeamWorkspace(c *fiber.Ctx) error {
+	return s.syncTeamWorkspace(c, runtime.WorkspacePush)
+}
+
+// PullTeamWorkspace handles POST /api/teams/:id/workspace/pull. It copies
+// the files of an S3 prefix into the team's workspace in a background job.
+func (s *Server) PullTeamWorkspace(c *fiber.Ctx) error {
+	return s.syncTeamWorkspace(c, runtime.WorkspacePull)
+}
+
+// syncTeamWorkspace queues a workspace sync in direction (admin only). The
+// job runs once the request releases the team, with the S3 credentials of
+// the organization's settings, so it may reach any bucket they can.
+func (s *Server) syncTeamWorkspace(c *fiber.Ctx, direction string) error {
+	if !IsAdmin(c) {
+		return newAPIError(CodeAdminRequired, "only admins can sync team workspaces")
+	}
+	var req WorkspaceSyncRequest
+	if err := c.BodyParser(&req); err != nil {
+		return newAPIError(CodeInvalidBody, "invalid request body")
+	}
+	if err := runtime.ValidateWorkspaceSync(runtime.WorkspaceSync{Direction: direction, URL: req.URL}); err != nil {
+		return newAPIError(CodeBadRequest, err.Error())
+	}
+
+	team, op, err := s.lockTeam(c, teamOpSync)
+	if err != nil {
+		return err
+	}
+	defer s.endTeamOp(team.ID, op)
+
+	if _, ok := s.teamRuntime(team).(runtime.WorkspaceSyncer); !ok {
+		return newAPIError(CodeNotImplemented, "workspace sync needs the Kubernetes runtime")
+	}
+	if team.WorkspacePath != "" {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf(
+			"the team's workspace is the host directory %s; copy its files on the host", team.WorkspacePath))
+	}
+	if team.Status == models.TeamStatusDeploying {
+		return newAPIError(CodeTeamDeploying, "team is deploying")
+	}
+	if err := s.checkMaintenance(c); err != nil {
+		return err
+	}
+
+	job, err := s.jobs.Enqueue(jobs.Options{
+		Kind:        jobWorkspaceSync,
+		OrgID:       team.OrgID,
+		TeamID:      team.ID,
+		Payload:     workspaceSyncPayload{Direction: direction, URL: req.URL, Delete: req.Delete},
+		MaxAttempts: teamJobAttempts,
+	})
+	if err != nil {
+		slog.Error("failed to queue workspace sync", "team", team.Name, "error", err)
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to queue workspace sync")
+	}
+	return c.Status(fiber.StatusAccepted).JSON(job)
+}
+
+// runWorkspaceSyncJob copies files between the job's team workspace and S3.
+func (s *Server) runWorkspaceSyncJob(ctx context.Context, job *models.Job) error {
+	var p workspaceSyncPayload
+	if err := jobs.Decode(job, &p); err != nil {
+		return err
+	}
+	op, end, err := s.beginTeamJob(ctx, job, teamOpSync, func(string) {})
+	if err != nil {
+		return err
+	}
+	defer end()
+
+	var team models.Team
+	if err := s.db.First(&team, "id = ?", job.TeamID).Error; err != nil {
+		return jobs.Skip("team not found")
+	}
+	syncer, ok := s.teamRuntime(team).(runtime.WorkspaceSyncer)
+	if !ok {
+		return jobs.Skip("team no longer runs on a runtime that can sync its workspace")
+	}
+
+	ctx, cancel := context.WithTimeout(op.ctx, workspaceSyncTimeout)
+	defer cancel()
+	output, err := syncer.SyncWorkspace(ctx, team.Name, runtime.WorkspaceSync{
+		Direction: p.Direction,
+		URL:       p.URL,
+		Delete:    p.Delete,
+		Env:       s.LoadSettingsEnv(team.OrgID),
+	})
+	if err != nil {
+		slog.Error("workspace sync failed", "team", team.Name, "direction", p.Direction, "url", p.URL, "error", err)
+		return jobs.Permanent(err)
+	}
+	slog.Info("workspace synced", "team", team.Name, "direction", p.Direction, "url", p.URL, "output", output)
+	return nil
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/auth"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// syncingRuntime is a mockRuntime that can sync workspaces.
+type syncingRuntime struct {
+	*mockRuntime
+
+	mu    sync.Mutex
+	syncs []runtime.WorkspaceSync
+}
+
+func (r *syncingRuntime) SyncWorkspace(_ context.Context, _ string, sync runtime.WorkspaceSync) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.syncs = append(r.syncs, sync)
+	return "upload: ./notes.md to s3://bucket/notes.md", nil
+}
+
+func TestSyncTeamWorkspace(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	rt := &syncingRuntime{mockRuntime: &mockRuntime{}}
+	noopAuth, err := auth.NewNoopProvider(db)
+	if err != nil {
+		t.Fatalf("NewNoopProvider: %v", err)
+	}
+	srv := NewServer(db, rt, noopAuth)
+	srv.StartJobs(0)
+	t.Cleanup(srv.StopJobs)
+
+	doRequest(srv, "PUT", "/api/settings", UpdateSettingsRequest{Key: "AWS_ACCESS_KEY_ID", Value: "AKIA"})
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "synced"})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	if rec := doRequest(srv, "POST", "/api/teams/"+team.ID+"/workspace/push", WorkspaceSyncRequest{URL: "https://bucket"}); rec.Code != 400 {
+		t.Errorf("bad url: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/workspace/pull", WorkspaceSyncRequest{URL: "s3://seed-bucket/synced", Delete: true})
+	if rec.Code != 202 {
+		t.Fatalf("pull: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	waitForJob(t, srv, team.ID, jobWorkspaceSync, models.JobStatusSucceeded)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if len(rt.syncs) != 1 {
+		t.Fatalf("syncs: got %d, want 1", len(rt.syncs))
+	}
+	got := rt.syncs[0]
+	if got.Direction != runtime.WorkspacePull || got.URL != "s3://seed-bucket/synced" || !got.Delete || got.Env["AWS_ACCESS_KEY_ID"] != "AKIA" {
+		t.Errorf("sync: got %+v", got)
+	}
+}
+
+func TestSyncTeamWorkspace_Unsupported(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "docker-team"})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/workspace/push", WorkspaceSyncRequest{URL: "s3://bucket/docker-team"})
+	if rec.Code != 501 {
+		t.Errorf("got %d, want 501", rec.Code)
+	}
+}
+
+func TestSyncTeamWorkspace_AdminOnlyAndMaintenance(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	rt := &syncingRuntime{mockRuntime: &mockRuntime{}}
+	noopAuth, err := auth.NewNoopProvider(db)
+	if err != nil {
+		t.Fatalf("NewNoopProvider: %v", err)
+	}
+	srv := NewServer(db, rt, noopAuth)
+	member := NewServer(db, rt, memberAuth{noopAuth})
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "guarded-sync"})
+	var team models.Team
+	parseJSON(t, rec, &team)
+
+	// The job runs with the organization's credentials.
+	rec = doRequest(member, "POST", "/api/teams/"+team.ID+"/workspace/push", WorkspaceSyncRequest{URL: "s3://elsewhere/exfil"})
+	var resp ErrorResponse
+	parseJSON(t, rec, &resp)
+	if rec.Code != 403 || resp.Code != CodeAdminRequired {
+		t.Errorf("member push: got %d %+v, want 403 %s", rec.Code, resp, CodeAdminRequired)
+	}
+
+	doRequest(srv, "POST", "/api/admin/maintenance", MaintenanceRequest{Enabled: true})
+	rec = doRequest(srv, "POST", "/api/teams/"+team.ID+"/workspace/pull", WorkspaceSyncRequest{URL: "s3://seed-bucket/synced"})
+	parseJSON(t, rec, &resp)
+	if rec.Code != 503 || resp.Code != CodeMaintenance {
+		t.Errorf("pull in maintenance: got %d %+v, want 503 %s", rec.Code, resp, CodeMaintenance)
+	}
+
+	var queued int64
+	db.Model(&models.Job{}).Where("kind = ?", jobWorkspaceSync).Count(&queued)
+	if queued != 0 {
+		t.Errorf("refused syncs queued %d jobs", queued)
+	}
+}
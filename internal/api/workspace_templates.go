@@ -0,0 +1,14 @@
+package api
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// ListWorkspaceTemplates handles GET /api/workspace-templates. It returns
+// the workspace templates a team can select, with the directories and
+// files each one scaffolds.
+func (s *Server) ListWorkspaceTemplates(c *fiber.Ctx) error {
+	return c.JSON(runtime.WorkspaceTemplates())
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func TestTeamWorkspaceTemplate(t *testing.T) {
+	srv, mock := setupTestServer(t)
+
+	var templates []runtime.WorkspaceTemplate
+	parseJSON(t, doRequest(srv, "GET", "/api/workspace-templates", nil), &templates)
+	if len(templates) != 4 || templates[0].Name != runtime.WorkspaceTemplateEmpty {
+		t.Fatalf("templates: got %+v", templates)
+	}
+
+	rec := doRequest(srv, "POST", "/api/teams", CreateTeamRequest{Name: "tmpl-bad", WorkspaceTemplate: "rails-app"})
+	if rec.Code != 400 {
+		t.Errorf("create with unknown template: got %d, want 400", rec.Code)
+	}
+
+	rec = doRequest(srv, "POST", "/api/teams", CreateTeamRequest{
+		Name:              "tmpl-team",
+		WorkspaceTemplate: runtime.WorkspaceTemplateMonorepo,
+		Agents:            []CreateAgentInput{{Name: "Lead Dev", Role: "leader"}},
+	})
+	var team models.Team
+	parseJSON(t, rec, &team)
+	if team.WorkspaceTemplate != runtime.WorkspaceTemplateMonorepo {
+		t.Fatalf("workspace_template: got %q", team.WorkspaceTemplate)
+	}
+
+	srv.deployTeamAsync(t.Context(), team)
+	if mock.lastAgentConfig == nil {
+		t.Fatal("expected lastAgentConfig to be set")
+	}
+	var scaffold protocol.WorkspaceScaffold
+	if err := json.Unmarshal([]byte(mock.lastAgentConfig.Env["AGENT_WORKSPACE_SCAFFOLD"]), &scaffold); err != nil {
+		t.Fatalf("AGENT_WORKSPACE_SCAFFOLD: %v", err)
+	}
+	if scaffold.Template != runtime.WorkspaceTemplateMonorepo || !scaffold.GitInit || len(scaffold.Dirs) != 4 {
+		t.Errorf("scaffold: got %+v", scaffold)
+	}
+
+	empty := runtime.WorkspaceTemplateEmpty
+	rec = doRequest(srv, "PUT", "/api/teams/"+team.ID, UpdateTeamRequest{WorkspaceTemplate: &empty})
+	if rec.Code != 200 {
+		t.Fatalf("update: got %d, body: %s", rec.Code, rec.Body.String())
+	}
+	parseJSON(t, doRequest(srv, "GET", "/api/teams/"+team.ID, nil), &team)
+	srv.deployTeamAsync(t.Context(), team)
+	if got, ok := mock.lastAgentConfig.Env["AGENT_WORKSPACE_SCAFFOLD"]; ok {
+		t.Errorf("AGENT_WORKSPACE_SCAFFOLD with the empty template: got %q", got)
+	}
+}
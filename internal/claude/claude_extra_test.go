@@ -2,8 +2,14 @@ package claude
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExtractToolCommand_InvalidJSON(t *testing.T) {
@@ -137,3 +143,193 @@ func TestExtractToolCommand_GlobWithPattern(t *testing.T) {
 		t.Errorf("paths: got %v, want empty", paths)
 	}
 }
+
+func TestRecover_KeepsSessionID(t *testing.T) {
+	m := NewManager(ProcessConfig{SystemPrompt: "You are a test agent"})
+	m.sessionID = "sess-123"
+	m.status = "error"
+
+	if err := m.Recover(context.Background()); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if !m.IsRunning() {
+		t.Error("manager should be running after Recover")
+	}
+	if m.SessionID() != "sess-123" {
+		t.Errorf("session ID: got %q, want 'sess-123'", m.SessionID())
+	}
+}
+
+func TestInterrupt_KillsRunAndKeepsSession(t *testing.T) {
+	// A stand-in claude CLI that never finishes its run.
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "claude"), []byte("#!/bin/sh\nexec sleep 30\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	m := NewManager(ProcessConfig{})
+	m.sessionID = "sess-123"
+	m.status = "running"
+
+	if m.Interrupt() {
+		t.Error("Interrupt with no run in progress should report false")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- m.SendInput("long task") }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.Interrupt() {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Errorf("SendInput: got %v, want ErrInterrupted", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendInput did not return after Interrupt")
+	}
+	if !m.IsRunning() || m.SessionID() != "sess-123" {
+		t.Errorf("after interrupt: status %q, session %q", m.Status(), m.SessionID())
+	}
+}
+
+func TestParseStreamEvent_Unknown(t *testing.T) {
+	line := `{"type":"rate_limit_event","rate_limit":{"status":"allowed"}}`
+	event, err := ParseStreamEvent([]byte(line))
+	if err != nil {
+		t.Fatalf("ParseStreamEvent: %v", err)
+	}
+	if !event.Unknown || event.Type != "rate_limit_event" || string(event.Raw) != line {
+		t.Errorf("unknown event: got %+v", event)
+	}
+
+	// A known type whose field changed type is kept, raw.
+	line = `{"type":"result","is_error":true,"error":{"type":"overloaded_error"},"session_id":"sess-1"}`
+	if event, err = ParseStreamEvent([]byte(line)); err != nil {
+		t.Fatalf("ParseStreamEvent: %v", err)
+	}
+	if !event.Unknown || event.Type != "result" || event.SessionID != "sess-1" || string(event.Raw) != line {
+		t.Errorf("undecodable result: got %+v", event)
+	}
+
+	if event, _ = ParseStreamEvent([]byte(`{"type":"tool_use","name":"Bash"}`)); event.Unknown || event.Raw != nil {
+		t.Errorf("known event: got %+v", event)
+	}
+	for _, line := range []string{`{"type":5}`, `{"name":"Bash"}`, `[1,2]`} {
+		if _, err := ParseStreamEvent([]byte(line)); err == nil {
+			t.Errorf("%s: expected an error", line)
+		}
+	}
+}
+
+func TestParseStreamOutput_Fixtures(t *testing.T) {
+	tests := []struct {
+		file        string
+		wantVersion string
+		wantTypes   []string
+		wantUnknown map[string]int
+		wantSession string
+	}{
+		{
+			file:        "stream-legacy.jsonl",
+			wantTypes:   []string{"system", "assistant", "tool_use", "tool_result", "result"},
+			wantSession: "sess-legacy",
+		},
+		{
+			file:        "stream-v1.jsonl",
+			wantVersion: "1.0.72",
+			wantTypes:   []string{"system", "assistant", "tool_use", "user", "tool_result", "result"},
+			wantUnknown: map[string]int{"user": 1},
+			wantSession: "sess-v1",
+		},
+		{
+			file:        "stream-v2.jsonl",
+			wantVersion: "2.0.14",
+			wantTypes:   []string{"system", "stream_event", "assistant", "rate_limit_event", "stream_event", "tool_use", "system", "result"},
+			wantUnknown: map[string]int{"stream_event": 2, "rate_limit_event": 1, "result": 1},
+			wantSession: "sess-v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var parser StreamParser
+			var types []string
+			unknown := map[string]int{}
+			for _, line := range bytes.Split(data, []byte("\n")) {
+				event, err := parser.Parse(line)
+				if err != nil {
+					continue
+				}
+				types = append(types, event.Type)
+				if event.Unknown {
+					unknown[event.Type]++
+					if !json.Valid(event.Raw) {
+						t.Errorf("%s: raw line is not JSON: %q", event.Type, event.Raw)
+					}
+				}
+			}
+			if strings.Join(types, ",") != strings.Join(tt.wantTypes, ",") {
+				t.Errorf("types: got %v, want %v", types, tt.wantTypes)
+			}
+			if len(unknown) != len(tt.wantUnknown) {
+				t.Errorf("unknown events: got %v, want %v", unknown, tt.wantUnknown)
+			}
+			for typ, n := range tt.wantUnknown {
+				if unknown[typ] != n || parser.UnknownEvents()[typ] != n {
+					t.Errorf("unknown %s events: got %d, want %d", typ, unknown[typ], n)
+				}
+			}
+			if parser.CLIVersion() != tt.wantVersion {
+				t.Errorf("CLI version: got %q, want %q", parser.CLIVersion(), tt.wantVersion)
+			}
+
+			// ParseStreamOutput passes every event on, unknown ones included.
+			ch := make(chan StreamEvent, 20)
+			sessionID := ParseStreamOutput(bytes.NewReader(data), ch)
+			close(ch)
+			if len(ch) != len(tt.wantTypes) {
+				t.Errorf("events sent: got %d, want %d", len(ch), len(tt.wantTypes))
+			}
+			if sessionID != tt.wantSession {
+				t.Errorf("session_id: got %q, want %q", sessionID, tt.wantSession)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitEvent(t *testing.T) {
+	line := `{"type":"rate_limit_event","rate_limit_info":{"status":"allowed_warning","rateLimitType":"five_hour","utilization":0.92,"resetsAt":1760000000}}`
+	info, ok := ParseRateLimitEvent([]byte(line))
+	if !ok || info.Status != "allowed_warning" || info.Type != "five_hour" ||
+		info.Utilization == nil || *info.Utilization != 0.92 || info.ResetsAt.Unix() != 1760000000 {
+		t.Errorf("rate_limit_info: got %+v, ok %v", info, ok)
+	}
+
+	// Older CLI versions.
+	info, ok = ParseRateLimitEvent([]byte(`{"type":"rate_limit_event","rate_limit":{"status":"rejected","resets_at":1760000000}}`))
+	if !ok || info.Status != "rejected" || info.Utilization != nil || info.ResetsAt.Unix() != 1760000000 {
+		t.Errorf("rate_limit: got %+v, ok %v", info, ok)
+	}
+
+	for _, line := range []string{
+		`{"type":"result","rate_limit":{"status":"allowed"}}`,
+		`{"type":"rate_limit_event"}`,
+		`not json`,
+	} {
+		if info, ok := ParseRateLimitEvent([]byte(line)); ok {
+			t.Errorf("%s: got %+v", line, info)
+		}
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"sync"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 // ProcessConfig holds the configuration for spawning a Claude Code process.
@@ -19,17 +23,50 @@ type ProcessConfig struct {
 	WorkDir      string
 	MaxTokens    int
 	Model        string // Full Claude model ID (e.g. "claude-sonnet-4-20250514"). Empty uses CLI default.
+	// Persistent keeps one claude process running for the session and feeds
+	// it each input as stream-json on stdin, instead of spawning a process
+	// per input. The manager falls back to a process per input when the CLI
+	// does not support it.
+	Persistent bool
+	// AddDirs are directories outside WorkDir the CLI may access, such as
+	// the roots of a multi-root workspace. Each is passed with --add-dir.
+	AddDirs []string
+	// ExtraArgs are appended to every claude invocation. They are checked
+	// with protocol.ValidateClaudeExtraArgs before they get here.
+	ExtraArgs []string
+	// Transcript, if set, receives the raw stdout of every stream-json
+	// invocation (see Transcript).
+	Transcript io.Writer
 }
 
+// ErrInvocationCrashed is returned by SendInput when the claude process exits
+// with an error before emitting a result event.
+var ErrInvocationCrashed = errors.New("claude invocation crashed")
+
+// ErrInterrupted is returned by SendInput when Interrupt killed the claude
+// process before it emitted a result event.
+var ErrInterrupted = errors.New("claude invocation interrupted")
+
 // Manager manages the lifecycle of Claude Code CLI invocations.
-// Each SendInput call spawns a new `claude -p` process. Conversation continuity
-// is maintained via --resume <session_id>.
+// Each SendInput call spawns a new `claude -p` process, unless
+// ProcessConfig.Persistent is set. Conversation continuity is maintained via
+// --resume <session_id>.
 type Manager struct {
 	config    ProcessConfig
 	sessionID string           // captured from the first invocation
 	events    chan StreamEvent  // bridge reads from this
 	status    string
 	mu        sync.RWMutex
+
+	// cancelRun kills the claude process of the SendInput call in progress;
+	// interrupted records that Interrupt did so.
+	cancelRun   context.CancelFunc
+	interrupted bool
+
+	// proc is the long-lived claude process in persistent mode, and
+	// persistentUnsupported is set once the CLI turned that mode down.
+	proc                  *persistentProcess
+	persistentUnsupported bool
 }
 
 // NewManager creates a new Manager with the given config.
@@ -125,9 +162,18 @@ func (m *Manager) runInitialPrompt(ctx context.Context, prompt string) (string,
 	return result.SessionID, nil
 }
 
-// SendInput sends a message to Claude by spawning a new process with --resume.
+// SendInput sends a message to Claude, as a turn of the long-lived process in
+// persistent mode and otherwise by spawning a new process with --resume.
 // Stream events are emitted to the events channel for the bridge to consume.
 func (m *Manager) SendInput(input string) error {
+	return m.SendInputWithOverrides(input, protocol.RunOverrides{})
+}
+
+// SendInputWithOverrides is SendInput with the model and generation settings
+// of overrides applied to this input only. In persistent mode, such an input
+// runs in a process of its own; the long-lived process is stopped first and
+// the next input starts a new one that resumes the session.
+func (m *Manager) SendInputWithOverrides(input string, overrides protocol.RunOverrides) error {
 	m.mu.Lock()
 	if m.status != "running" {
 		m.mu.Unlock()
@@ -135,32 +181,73 @@ func (m *Manager) SendInput(input string) error {
 	}
 
 	sessionID := m.sessionID
+	persistent := m.config.Persistent && !m.persistentUnsupported
+	var stale *persistentProcess
+	if persistent && !overrides.IsZero() {
+		persistent = false
+		stale, m.proc = m.proc, nil
+	}
 	m.mu.Unlock()
+	if stale != nil {
+		stale.kill()
+		<-stale.done
+	}
 
 	slog.Info("sending input to claude",
 		"input_length", len(input),
 		"has_session", sessionID != "",
 		"session_id", sessionID,
+		"persistent", persistent,
+		"overrides", !overrides.IsZero(),
 	)
 
-	// Build args for this invocation.
-	args := []string{
-		"-p", input,
-		"--output-format", "stream-json",
-		"--verbose",
-		"--dangerously-skip-permissions",
+	if persistent {
+		return m.sendPersistent(input, sessionID)
 	}
-	if m.config.Model != "" {
-		args = append(args, "--model", m.config.Model)
+	return m.sendInvocation(input, sessionID, overrides)
+}
+
+// invocationArgs returns the claude flags shared by every invocation, with
+// overrides applied. A read-only run keeps claude's permission checks and
+// does not pre-approve the agent's allowed tools, so it cannot edit files.
+func (m *Manager) invocationArgs(sessionID string, overrides protocol.RunOverrides) []string {
+	args := []string{"--verbose"}
+	if !overrides.ReadOnly {
+		args = append(args, "--dangerously-skip-permissions")
+	}
+	model := m.config.Model
+	if overrides.Model != "" {
+		model = overrides.Model
+	}
+	if model != "" {
+		args = append(args, "--model", model)
 	}
 	if sessionID != "" {
 		args = append(args, "--resume", sessionID)
 	}
-	for _, tool := range m.config.AllowedTools {
-		args = append(args, "--allowedTools", tool)
+	if !overrides.ReadOnly {
+		for _, tool := range m.config.AllowedTools {
+			args = append(args, "--allowedTools", tool)
+		}
 	}
+	for _, dir := range m.config.AddDirs {
+		args = append(args, "--add-dir", dir)
+	}
+	args = append(args, m.config.ExtraArgs...)
+	// Last, so that they win over the same flags in ExtraArgs.
+	return append(args, overrides.Args()...)
+}
+
+// sendInvocation runs input in a claude process of its own.
+func (m *Manager) sendInvocation(input, sessionID string, overrides protocol.RunOverrides) error {
+	args := append([]string{
+		"-p", input,
+		"--output-format", "stream-json",
+	}, m.invocationArgs(sessionID, overrides)...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	ctx := context.Background()
 	cmd := exec.CommandContext(ctx, "claude", args...)
 	cmd.Dir = m.config.WorkDir
 	cmd.Env = m.buildEnv()
@@ -185,10 +272,21 @@ func (m *Manager) SendInput(input string) error {
 
 	slog.Info("claude process started", "pid", cmd.Process.Pid)
 
+	// From here on Interrupt can kill the process.
+	m.mu.Lock()
+	m.cancelRun = cancel
+	m.interrupted = false
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.cancelRun = nil
+		m.mu.Unlock()
+	}()
+
 	// Parse stream output in current goroutine — SendInput blocks until done.
 	// This is intentional: the bridge calls SendInput from handleUserMessage
 	// and the events channel delivers events to forwardEvents.
-	resultSessionID := ParseStreamOutput(stdout, m.events)
+	resultSessionID := ParseStreamOutput(m.teeTranscript(stdout), m.events)
 
 	// Wait for process to finish.
 	if err := cmd.Wait(); err != nil {
@@ -202,8 +300,17 @@ func (m *Manager) SendInput(input string) error {
 			"exit_code", exitCode,
 			"stderr", truncate(stderrStr, 1000),
 		)
-		// Don't return error — the stream events already went through.
-		// The bridge handles result/error events.
+		// Don't return error when a result came through — the bridge handles
+		// result/error events. Without one the run produced no response.
+		m.mu.RLock()
+		interrupted := m.interrupted
+		m.mu.RUnlock()
+		if resultSessionID == "" && interrupted {
+			return ErrInterrupted
+		}
+		if resultSessionID == "" {
+			return fmt.Errorf("%w: exit code %d: %v", ErrInvocationCrashed, exitCode, err)
+		}
 	} else {
 		exitCode := 0
 		if cmd.ProcessState != nil {
@@ -217,22 +324,35 @@ func (m *Manager) SendInput(input string) error {
 		slog.Info("claude stderr output", "stderr", truncate(stderrStr, 2000))
 	}
 
-	// Capture session_id from the stream result event. This ensures conversation
-	// continuity even when the manager started without a system prompt (no
-	// initial session_id). It also handles session rotation by Claude CLI.
-	if resultSessionID != "" {
-		m.mu.Lock()
-		if m.sessionID != resultSessionID {
-			slog.Info("session_id updated from stream result",
-				"old_session_id", m.sessionID,
-				"new_session_id", resultSessionID,
-			)
-			m.sessionID = resultSessionID
-		}
-		m.mu.Unlock()
+	m.updateSessionID(resultSessionID)
+	return nil
+}
+
+// updateSessionID captures the session_id of a stream result event. This
+// ensures conversation continuity even when the manager started without a
+// system prompt (no initial session_id). It also handles session rotation by
+// Claude CLI.
+func (m *Manager) updateSessionID(resultSessionID string) {
+	if resultSessionID == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.sessionID != resultSessionID {
+		slog.Info("session_id updated from stream result",
+			"old_session_id", m.sessionID,
+			"new_session_id", resultSessionID,
+		)
+		m.sessionID = resultSessionID
 	}
+}
 
-	return nil
+// teeTranscript returns stdout, copied to the transcript if one is set.
+func (m *Manager) teeTranscript(stdout io.Reader) io.Reader {
+	if m.config.Transcript == nil {
+		return stdout
+	}
+	return io.TeeReader(stdout, m.config.Transcript)
 }
 
 // ReadEvents returns a read-only channel that emits parsed stdout events.
@@ -267,13 +387,63 @@ func (m *Manager) Restart(resumePrompt string) error {
 	return m.Start(context.Background())
 }
 
-// Stop marks the manager as stopped.
+// Recover brings the manager back to running after a crashed invocation. The
+// session ID is kept so the next SendInput resumes the same conversation; the
+// system prompt is only rerun when no session had been established yet.
+func (m *Manager) Recover(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status = "running"
+	if m.sessionID != "" || m.config.SystemPrompt == "" {
+		slog.Info("claude manager recovered", "session_id", m.sessionID)
+		return nil
+	}
+
+	sessionID, err := m.runInitialPrompt(ctx, m.config.SystemPrompt)
+	if err != nil {
+		m.status = "error"
+		return fmt.Errorf("re-initializing claude session: %w", err)
+	}
+	m.sessionID = sessionID
+	slog.Info("claude manager recovered with new session", "session_id", m.sessionID)
+	return nil
+}
+
+// Interrupt kills the claude process of the run in progress, like pressing
+// Esc in an interactive session. The session is kept, so the next SendInput
+// resumes the same conversation. Reports whether a run was in progress.
+func (m *Manager) Interrupt() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancelRun == nil {
+		return false
+	}
+	slog.Info("interrupting claude run", "session_id", m.sessionID)
+	m.interrupted = true
+	m.cancelRun()
+	return true
+}
+
+// SessionID returns the conversation session ID used for --resume.
+func (m *Manager) SessionID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessionID
+}
+
+// Stop marks the manager as stopped and ends the long-lived claude process,
+// if any.
 func (m *Manager) Stop() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	slog.Info("stopping claude manager", "session_id", m.sessionID)
 	m.status = "stopped"
+	if m.proc != nil {
+		m.proc.kill()
+		m.proc = nil
+	}
 	return nil
 }
 
@@ -0,0 +1,279 @@
+package claude
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+	"sync"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// errProcessStopped is returned by SendInput when Stop killed the long-lived
+// claude process before it answered.
+var errProcessStopped = errors.New("claude process stopped")
+
+// persistentProcess is a claude process started with --input-format
+// stream-json. It takes one user message per stdin line and stays up
+// between turns, so only the first input pays for the CLI's startup.
+type persistentProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	cancel context.CancelFunc
+	stderr bytes.Buffer // Read only once done is closed.
+
+	mu sync.Mutex
+	// waiters holds one channel per input written, in order; each result
+	// event completes the oldest with the turn's session ID.
+	waiters []chan string
+	events  int  // Events read from stdout.
+	killed  bool // Set by kill, so that its exit is not taken for a crash.
+
+	done    chan struct{} // Closed once the process exited.
+	exitErr error
+}
+
+// userInputMessage is a stream-json stdin line carrying one user message.
+type userInputMessage struct {
+	Type    string `json:"type"`
+	Message struct {
+		Role    string             `json:"role"`
+		Content []userInputContent `json:"content"`
+	} `json:"message"`
+}
+
+type userInputContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// startPersistent starts a long-lived claude process resuming sessionID, or
+// a new session when it is empty. Its events are sent to m.events.
+func (m *Manager) startPersistent(sessionID string) (*persistentProcess, error) {
+	args := []string{
+		"-p",
+		"--input-format", "stream-json",
+		"--output-format", "stream-json",
+	}
+	args = append(args, m.invocationArgs(sessionID, protocol.RunOverrides{})...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &persistentProcess{cancel: cancel, done: make(chan struct{})}
+	p.cmd = exec.CommandContext(ctx, "claude", args...)
+	p.cmd.Dir = m.config.WorkDir
+	p.cmd.Env = m.buildEnv()
+	p.cmd.Stderr = &p.stderr
+
+	stdin, err := p.cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("creating stdin pipe: %w", err)
+	}
+	stdout, err := p.cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("creating stdout pipe: %w", err)
+	}
+	p.stdin = stdin
+
+	slog.Info("starting long-lived claude process", "command", "claude", "args", args)
+	if err := p.cmd.Start(); err != nil {
+		cancel()
+		return nil, fmt.Errorf("starting claude process: %w", err)
+	}
+	slog.Info("claude process started", "pid", p.cmd.Process.Pid, "mode", "persistent")
+
+	go p.read(m.teeTranscript(stdout), m.events)
+	return p, nil
+}
+
+// read forwards the process's events until its stdout closes, completing a
+// waiter on each result event, and then reaps the process.
+func (p *persistentProcess) read(stdout io.Reader, events chan<- StreamEvent) {
+	scanner := newStreamScanner(stdout)
+	var parser StreamParser
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		event, err := parser.Parse(line)
+		if err != nil {
+			slog.Debug("skipping unparseable line", "error", err, "line", string(line))
+			continue
+		}
+
+		p.mu.Lock()
+		p.events++
+		p.mu.Unlock()
+		select {
+		case events <- *event:
+		default:
+			slog.Warn("event channel full, dropping event", "type", event.Type)
+		}
+
+		if event.Type == "result" {
+			p.finishTurn(event.SessionID)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		slog.Error("error reading stream", "error", err)
+	}
+
+	p.exitErr = p.cmd.Wait()
+	close(p.done)
+}
+
+// send writes input to the process's stdin and returns the channel that
+// receives the session ID once its turn ends.
+func (p *persistentProcess) send(input string) (<-chan string, error) {
+	msg := userInputMessage{Type: "user"}
+	msg.Message.Role = "user"
+	msg.Message.Content = []userInputContent{{Type: "text", Text: input}}
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("encoding input: %w", err)
+	}
+
+	turn := make(chan string, 1)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Written under mu, so that waiters stay in the order of the inputs.
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		return nil, fmt.Errorf("writing to claude stdin: %w", err)
+	}
+	p.waiters = append(p.waiters, turn)
+	return turn, nil
+}
+
+// finishTurn completes the oldest waiter.
+func (p *persistentProcess) finishTurn(sessionID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.waiters) == 0 {
+		return
+	}
+	p.waiters[0] <- sessionID
+	p.waiters = p.waiters[1:]
+}
+
+// kill ends the process. Turns waiting for a result see it exit.
+func (p *persistentProcess) kill() {
+	p.mu.Lock()
+	p.killed = true
+	p.mu.Unlock()
+	p.stdin.Close()
+	p.cancel()
+}
+
+// exited reports whether the process has exited.
+func (p *persistentProcess) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// silent reports whether the process exited with an error before writing a
+// single event, which is how CLIs without stream-json input turn it down.
+func (p *persistentProcess) silent() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.events == 0 && !p.killed && p.exitErr != nil
+}
+
+// sendPersistent runs input as a turn of the long-lived claude process,
+// starting it if needed. When the CLI does not support stream-json input it
+// switches the manager to one process per input and runs input that way.
+func (m *Manager) sendPersistent(input, sessionID string) error {
+	m.mu.Lock()
+	p := m.proc
+	if p == nil || p.exited() {
+		var err error
+		if p, err = m.startPersistent(sessionID); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		m.proc = p
+	}
+	m.mu.Unlock()
+
+	turn, err := p.send(input)
+	if err != nil {
+		p.kill()
+		m.dropProcess(p)
+		return fmt.Errorf("%w: %v", ErrInvocationCrashed, err)
+	}
+
+	// From here on Interrupt can kill the process.
+	m.mu.Lock()
+	m.cancelRun = p.kill
+	m.interrupted = false
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.cancelRun = nil
+		m.mu.Unlock()
+	}()
+
+	select {
+	case resultSessionID := <-turn:
+		m.updateSessionID(resultSessionID)
+		return nil
+	case <-p.done:
+	}
+	// The result may have been read just before the process exited.
+	select {
+	case resultSessionID := <-turn:
+		m.updateSessionID(resultSessionID)
+		return nil
+	default:
+	}
+	m.dropProcess(p)
+
+	exitCode := -1
+	if p.cmd.ProcessState != nil {
+		exitCode = p.cmd.ProcessState.ExitCode()
+	}
+	stderr := p.stderr.String()
+	m.mu.RLock()
+	interrupted := m.interrupted
+	m.mu.RUnlock()
+	switch {
+	case interrupted:
+		return ErrInterrupted
+	case p.silent():
+		slog.Warn("claude CLI does not support stream-json input, spawning a process per input",
+			"exit_code", exitCode, "stderr", truncate(stderr, 1000))
+		m.mu.Lock()
+		m.persistentUnsupported = true
+		m.mu.Unlock()
+		return m.sendInvocation(input, sessionID, protocol.RunOverrides{})
+	}
+	p.mu.Lock()
+	killed := p.killed
+	p.mu.Unlock()
+	if killed {
+		return errProcessStopped
+	}
+	slog.Error("claude process exited with error",
+		"error", p.exitErr, "exit_code", exitCode, "stderr", truncate(stderr, 1000))
+	return fmt.Errorf("%w: exit code %d: %v", ErrInvocationCrashed, exitCode, p.exitErr)
+}
+
+// dropProcess forgets p if it is still the manager's long-lived process, so
+// that the next input starts a new one.
+func (m *Manager) dropProcess(p *persistentProcess) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.proc == p {
+		m.proc = nil
+	}
+}
@@ -0,0 +1,264 @@
+package claude
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// fakeClaudeScript stands in for the claude CLI. Each start is appended to
+// $CLAUDE_STARTS with its arguments. It sleeps $CLAUDE_STARTUP seconds to
+// mimic the CLI's startup, and with --input-format answers each stdin line
+// with a result, or exits as $CLAUDE_STDIN_MODE says.
+const fakeClaudeScript = `#!/bin/sh
+echo "$*" >> "$CLAUDE_STARTS"
+sleep "${CLAUDE_STARTUP:-0}"
+case " $* " in
+*" --input-format "*)
+	case "$CLAUDE_STDIN_MODE" in
+	unsupported)
+		echo "error: unknown option '--input-format'" >&2
+		exit 1 ;;
+	hang)
+		exec sleep 30 ;;
+	crash)
+		read -r line
+		echo '{"type":"assistant","message":{"type":"text","text":"working"}}'
+		exit 2 ;;
+	esac
+	while read -r line; do
+		echo '{"type":"assistant","message":{"type":"text","text":"working"}}'
+		echo '{"type":"result","message":{"type":"text","text":"done"},"session_id":"sess-persistent"}'
+	done ;;
+*)
+	echo '{"type":"result","message":{"type":"text","text":"done"},"session_id":"sess-invocation"}' ;;
+esac
+`
+
+// useFakeClaude puts the fake CLI first in PATH and returns a function that
+// reads the arguments of each start.
+func useFakeClaude(tb testing.TB, stdinMode string) func() []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "claude"), []byte(fakeClaudeScript), 0755); err != nil {
+		tb.Fatal(err)
+	}
+	starts := filepath.Join(dir, "starts")
+	tb.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	tb.Setenv("CLAUDE_STARTS", starts)
+	tb.Setenv("CLAUDE_STDIN_MODE", stdinMode)
+	return func() []string {
+		data, _ := os.ReadFile(starts)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+// newRunningManager returns a running manager in the given mode whose
+// events are drained in the background.
+func newRunningManager(tb testing.TB, persistent bool) *Manager {
+	tb.Helper()
+	m := NewManager(ProcessConfig{WorkDir: tb.TempDir(), Persistent: persistent})
+	m.status = "running"
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-m.events:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	tb.Cleanup(func() {
+		m.Stop()
+		close(stop)
+	})
+	return m
+}
+
+func TestPersistent_ReusesProcess(t *testing.T) {
+	starts := useFakeClaude(t, "")
+	m := newRunningManager(t, true)
+
+	for i := 0; i < 3; i++ {
+		if err := m.SendInput("message"); err != nil {
+			t.Fatalf("SendInput %d: %v", i, err)
+		}
+	}
+	if got := starts(); len(got) != 1 || !strings.Contains(got[0], "--input-format stream-json") {
+		t.Errorf("starts: got %q, want one stream-json input process", got)
+	}
+	if m.SessionID() != "sess-persistent" {
+		t.Errorf("session ID: got %q", m.SessionID())
+	}
+
+	// A process started after Stop resumes the session.
+	m.Stop()
+	m.status = "running"
+	if err := m.SendInput("again"); err != nil {
+		t.Fatalf("SendInput after stop: %v", err)
+	}
+	if got := starts(); len(got) != 2 || !strings.Contains(got[1], "--resume sess-persistent") {
+		t.Errorf("starts after stop: got %q", got)
+	}
+}
+
+func TestPersistent_FallsBackWhenUnsupported(t *testing.T) {
+	starts := useFakeClaude(t, "unsupported")
+	m := newRunningManager(t, true)
+
+	for i := 0; i < 2; i++ {
+		if err := m.SendInput("message"); err != nil {
+			t.Fatalf("SendInput %d: %v", i, err)
+		}
+	}
+	got := starts()
+	if len(got) != 3 || strings.Contains(got[1], "--input-format") || strings.Contains(got[2], "--input-format") {
+		t.Errorf("starts: got %q, want one rejected stream-json process and two per-input ones", got)
+	}
+	if m.SessionID() != "sess-invocation" {
+		t.Errorf("session ID: got %q", m.SessionID())
+	}
+}
+
+func TestPersistent_InterruptKeepsSession(t *testing.T) {
+	starts := useFakeClaude(t, "hang")
+	m := newRunningManager(t, true)
+	m.sessionID = "sess-123"
+
+	done := make(chan error, 1)
+	go func() { done <- m.SendInput("long task") }()
+	deadline := time.Now().Add(5 * time.Second)
+	for !m.Interrupt() {
+		if time.Now().After(deadline) {
+			t.Fatal("run did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrInterrupted) {
+			t.Errorf("SendInput: got %v, want ErrInterrupted", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SendInput did not return after Interrupt")
+	}
+	if m.SessionID() != "sess-123" || m.persistentUnsupported {
+		t.Errorf("after interrupt: session %q, unsupported %v", m.SessionID(), m.persistentUnsupported)
+	}
+	if got := starts(); len(got) != 1 || !strings.Contains(got[0], "--resume sess-123") {
+		t.Errorf("starts: got %q", got)
+	}
+}
+
+func TestPersistent_CrashIsNotFallback(t *testing.T) {
+	useFakeClaude(t, "crash")
+	m := newRunningManager(t, true)
+
+	if err := m.SendInput("message"); !errors.Is(err, ErrInvocationCrashed) {
+		t.Fatalf("SendInput: got %v, want ErrInvocationCrashed", err)
+	}
+	if m.persistentUnsupported {
+		t.Error("a process that answered should not turn persistent mode off")
+	}
+}
+
+func TestSendInput_AddDirsAndExtraArgs(t *testing.T) {
+	for _, persistent := range []bool{false, true} {
+		starts := useFakeClaude(t, "")
+		m := newRunningManager(t, persistent)
+		m.config.AddDirs = []string{"/workspaces/docs"}
+		m.config.ExtraArgs = []string{"--max-thinking-tokens", "8000", "--add-dir=/data"}
+
+		if err := m.SendInput("message"); err != nil {
+			t.Fatalf("SendInput (persistent %v): %v", persistent, err)
+		}
+		if got := starts(); len(got) != 1 || !strings.HasSuffix(got[0], "--add-dir /workspaces/docs --max-thinking-tokens 8000 --add-dir=/data") {
+			t.Errorf("starts (persistent %v): got %q", persistent, got)
+		}
+	}
+}
+
+func TestSendInputWithOverrides(t *testing.T) {
+	starts := useFakeClaude(t, "")
+	m := newRunningManager(t, true)
+	m.config.Model = "claude-sonnet-4-20250514"
+	m.config.ExtraArgs = []string{"--max-thinking-tokens", "1000"}
+
+	if err := m.SendInput("first"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+	overrides := protocol.RunOverrides{Model: "claude-opus-4-20250514", MaxThinkingTokens: 8000}
+	if err := m.SendInputWithOverrides("on opus", overrides); err != nil {
+		t.Fatalf("SendInputWithOverrides: %v", err)
+	}
+	if err := m.SendInput("back to sonnet"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+
+	got := starts()
+	if len(got) != 3 {
+		t.Fatalf("starts: got %q, want a long-lived process, one for the override and a new long-lived one", got)
+	}
+	if strings.Contains(got[1], "--input-format") || !strings.Contains(got[1], "--model claude-opus-4-20250514") ||
+		!strings.HasSuffix(got[1], "--max-thinking-tokens 1000 --max-thinking-tokens 8000") {
+		t.Errorf("override invocation: got %q", got[1])
+	}
+	if !strings.Contains(got[2], "--input-format") || !strings.Contains(got[2], "--model claude-sonnet-4-20250514") ||
+		!strings.Contains(got[2], "--resume sess-invocation") {
+		t.Errorf("next long-lived process: got %q", got[2])
+	}
+}
+
+func TestSendInputWithOverrides_ReadOnly(t *testing.T) {
+	starts := useFakeClaude(t, "")
+	m := newRunningManager(t, true)
+	m.config.AllowedTools = []string{"Edit"}
+
+	if err := m.SendInputWithOverrides("plan it", protocol.RunOverrides{ReadOnly: true}); err != nil {
+		t.Fatalf("SendInputWithOverrides: %v", err)
+	}
+	if err := m.SendInput("do it"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+
+	got := starts()
+	if len(got) != 2 {
+		t.Fatalf("starts: got %q, want the planning run and a long-lived process", got)
+	}
+	if !strings.HasSuffix(got[0], "--permission-mode plan") ||
+		strings.Contains(got[0], "--dangerously-skip-permissions") || strings.Contains(got[0], "--allowedTools") {
+		t.Errorf("planning invocation: got %q", got[0])
+	}
+	if strings.Contains(got[1], "--permission-mode") || !strings.Contains(got[1], "--dangerously-skip-permissions") ||
+		!strings.Contains(got[1], "--allowedTools Edit") {
+		t.Errorf("next long-lived process: got %q", got[1])
+	}
+}
+
+// BenchmarkSendInput compares a process per input with the long-lived
+// process, for a CLI that takes 50ms to start.
+func BenchmarkSendInput(b *testing.B) {
+	for _, mode := range []struct {
+		name       string
+		persistent bool
+	}{{"invocation", false}, {"persistent", true}} {
+		b.Run(mode.name, func(b *testing.B) {
+			useFakeClaude(b, "")
+			b.Setenv("CLAUDE_STARTUP", "0.05")
+			m := newRunningManager(b, mode.persistent)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := m.SendInput("message"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
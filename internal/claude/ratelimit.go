@@ -0,0 +1,68 @@
+package claude
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RateLimitEventType is the type of the stream-json events the CLI emits
+// when the provider reports the account's rate limit state. The parser
+// passes them on as Unknown; ParseRateLimitEvent reads them.
+const RateLimitEventType = "rate_limit_event"
+
+// RateLimitInfo is the rate limit state reported by a rate_limit_event.
+type RateLimitInfo struct {
+	// Status is "allowed", "allowed_warning" or "rejected".
+	Status string
+	// Type is the limit the state is about, such as "five_hour" or
+	// "seven_day". Older CLI versions leave it empty.
+	Type string
+	// Utilization is the fraction of the limit used, when reported.
+	Utilization *float64
+	// ResetsAt is when the limit resets; zero when not reported.
+	ResetsAt time.Time
+}
+
+// rateLimitFields decodes the rate limit object of both the current
+// (camelCase) and older (snake_case) CLI versions.
+type rateLimitFields struct {
+	Status           string   `json:"status"`
+	RateLimitType    string   `json:"rateLimitType"`
+	RateLimitTypeOld string   `json:"rate_limit_type"`
+	Utilization      *float64 `json:"utilization"`
+	ResetsAt         int64    `json:"resetsAt"`
+	ResetsAtOld      int64    `json:"resets_at"`
+}
+
+// ParseRateLimitEvent reads the rate limit state of a rate_limit_event
+// line. ok is false for other events and for ones without a status.
+func ParseRateLimitEvent(raw []byte) (info RateLimitInfo, ok bool) {
+	var event struct {
+		Type          string           `json:"type"`
+		RateLimitInfo *rateLimitFields `json:"rate_limit_info"`
+		RateLimit     *rateLimitFields `json:"rate_limit"`
+	}
+	if err := json.Unmarshal(raw, &event); err != nil || event.Type != RateLimitEventType {
+		return info, false
+	}
+	f := event.RateLimitInfo
+	if f == nil {
+		f = event.RateLimit
+	}
+	if f == nil || f.Status == "" {
+		return info, false
+	}
+
+	info = RateLimitInfo{Status: f.Status, Type: f.RateLimitType, Utilization: f.Utilization}
+	if info.Type == "" {
+		info.Type = f.RateLimitTypeOld
+	}
+	resetsAt := f.ResetsAt
+	if resetsAt == 0 {
+		resetsAt = f.ResetsAtOld
+	}
+	if resetsAt > 0 {
+		info.ResetsAt = time.Unix(resetsAt, 0).UTC()
+	}
+	return info, true
+}
@@ -3,6 +3,7 @@ package claude
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"strings"
@@ -20,6 +21,36 @@ type StreamEvent struct {
 	ErrorCode  string          `json:"error,omitempty"`      // Machine-readable error code (e.g. "billing_error")
 	SessionID  string          `json:"session_id,omitempty"` // Session ID for conversation continuity (in result events)
 	MCPServers json.RawMessage `json:"mcp_servers,omitempty"` // MCP server statuses (for system/init events)
+	// TotalCostUSD and Usage report what the turn cost (in result events).
+	TotalCostUSD float64     `json:"total_cost_usd,omitempty"`
+	Usage        *TokenUsage `json:"usage,omitempty"`
+	// CLIVersion is the version of the Claude Code CLI (in system/init events).
+	CLIVersion string `json:"claude_code_version,omitempty"`
+	// Unknown is set on events this parser does not understand: those of a
+	// type not in KnownEventTypes, and those whose fields did not decode.
+	// Raw then holds the line as read, so the event can be passed on as is.
+	Unknown bool            `json:"unknown_event,omitempty"`
+	Raw     json.RawMessage `json:"-"`
+}
+
+// KnownEventTypes are the stream-json event types the bridge acts on. New
+// CLI versions add types; events of any other type are parsed as Unknown.
+var KnownEventTypes = map[string]bool{
+	"assistant":   true,
+	"reasoning":   true,
+	"tool_use":    true,
+	"tool_result": true,
+	"result":      true,
+	"error":       true,
+	"system":      true,
+}
+
+// TokenUsage is the token usage reported in result events.
+type TokenUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens,omitempty"`
 }
 
 // FriendlyError returns a user-facing message for known Claude CLI error codes.
@@ -34,6 +65,8 @@ func (e *StreamEvent) FriendlyError() string {
 		return "Your API key has insufficient credits. Please add credits or update your key in Settings."
 	case "authentication_error":
 		return "API key is invalid or expired. Please update it in Settings."
+	case "rate_limit", "rate_limit_error":
+		return "The AI provider's rate limit was reached. Please try again in a minute."
 	case "APIError":
 		if e.Result != "" {
 			return "API error: " + e.Result
@@ -54,15 +87,79 @@ type ToolUseInput struct {
 	Pattern  string `json:"pattern,omitempty"`   // For Glob/Grep tools
 }
 
-// ParseStreamEvent parses a single JSON line into a StreamEvent.
+// errNoEventType is returned for JSON lines without an event type.
+var errNoEventType = errors.New("stream event has no type")
+
+// ParseStreamEvent parses a single JSON line into a StreamEvent. It only
+// fails on lines that are not a JSON object with a string type: an event of
+// an unknown type, or one whose fields changed type in a newer CLI, is
+// returned as Unknown with its raw line.
 func ParseStreamEvent(line []byte) (*StreamEvent, error) {
 	var event StreamEvent
 	if err := json.Unmarshal(line, &event); err != nil {
-		return nil, err
+		// Keep what identifies the event and the session it belongs to.
+		var head struct {
+			Type      string `json:"type"`
+			Subtype   string `json:"subtype"`
+			SessionID string `json:"session_id"`
+		}
+		if json.Unmarshal(line, &head) != nil || head.Type == "" {
+			return nil, err
+		}
+		event = StreamEvent{Type: head.Type, Subtype: head.Subtype, SessionID: head.SessionID, Unknown: true}
+	} else if event.Type == "" {
+		return nil, errNoEventType
+	} else if !KnownEventTypes[event.Type] {
+		event.Unknown = true
+	}
+	if event.Unknown {
+		event.Raw = append(json.RawMessage(nil), line...)
 	}
 	return &event, nil
 }
 
+// StreamParser parses the stream-json output of one CLI invocation. It
+// detects the CLI version from the system/init event and logs the first
+// unknown event of each type together with it, since unknown events usually
+// mean the CLI is newer than this parser.
+type StreamParser struct {
+	cliVersion string
+	unknown    map[string]int
+}
+
+// Parse parses one line of output (see ParseStreamEvent).
+func (p *StreamParser) Parse(line []byte) (*StreamEvent, error) {
+	event, err := ParseStreamEvent(line)
+	if err != nil {
+		return nil, err
+	}
+	if event.CLIVersion != "" && event.CLIVersion != p.cliVersion {
+		p.cliVersion = event.CLIVersion
+		slog.Debug("detected claude CLI version", "version", p.cliVersion)
+	}
+	if event.Unknown {
+		if p.unknown == nil {
+			p.unknown = make(map[string]int)
+		}
+		if p.unknown[event.Type] == 0 {
+			slog.Info("passing on unknown stream event", "type", event.Type, "cli_version", p.cliVersion)
+		}
+		p.unknown[event.Type]++
+	}
+	return event, nil
+}
+
+// CLIVersion returns the CLI version seen in the output so far, or "" if
+// the CLI did not report one.
+func (p *StreamParser) CLIVersion() string {
+	return p.cliVersion
+}
+
+// UnknownEvents returns how many unknown events of each type were parsed.
+func (p *StreamParser) UnknownEvents() map[string]int {
+	return p.unknown
+}
+
 // ExtractToolCommand extracts the tool name, command, and filesystem paths
 // from a tool_use StreamEvent for permission evaluation.
 func ExtractToolCommand(event *StreamEvent) (toolName string, command string, paths []string) {
@@ -98,18 +195,26 @@ func FormatToolResult(output string, isError bool) string {
 	return string(data)
 }
 
-// ParseStreamOutput reads lines from r and sends parsed events to the channel.
-// Returns the last session_id seen in result events (empty if none found).
-// Uses non-blocking sends to prevent goroutine leaks if the channel buffer is full.
-func ParseStreamOutput(r io.Reader, ch chan<- StreamEvent) string {
+// newStreamScanner returns a scanner of the lines of stream-json output.
+func newStreamScanner(r io.Reader) *bufio.Scanner {
 	scanner := bufio.NewScanner(r)
 	// Allow large lines — Claude can produce verbose JSON when tool results
 	// contain bulk data (e.g. large SQL query outputs, file contents).
 	// 16MB should handle even very large tool results.
 	const maxTokenSize = 16 * 1024 * 1024
 	scanner.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+	return scanner
+}
+
+// ParseStreamOutput reads lines from r and sends parsed events to the channel,
+// including unknown ones (see StreamEvent.Unknown).
+// Returns the last session_id seen in result events (empty if none found).
+// Uses non-blocking sends to prevent goroutine leaks if the channel buffer is full.
+func ParseStreamOutput(r io.Reader, ch chan<- StreamEvent) string {
+	scanner := newStreamScanner(r)
 
 	var lastSessionID string
+	var parser StreamParser
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
@@ -117,7 +222,7 @@ func ParseStreamOutput(r io.Reader, ch chan<- StreamEvent) string {
 			continue
 		}
 
-		event, err := ParseStreamEvent(line)
+		event, err := parser.Parse(line)
 		if err != nil {
 			slog.Debug("skipping unparseable line", "error", err, "line", string(line))
 			continue
@@ -0,0 +1,164 @@
+package claude
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Default limits of a Transcript.
+const (
+	DefaultTranscriptMaxFileBytes  = 5 << 20
+	DefaultTranscriptMaxTotalBytes = 50 << 20
+)
+
+// transcriptTruncated is the line that ends a transcript cut at its size cap.
+const transcriptTruncated = "\n{\"type\":\"transcript_truncated\"}\n"
+
+// Transcript writes the raw stream-json output of the claude CLI to one file
+// per run, to diagnose parsing problems or the model's behavior. Each file
+// stops growing at maxFileBytes, and the oldest files are deleted once the
+// directory holds more than maxTotalBytes. Output written before the first
+// run or after Begin("") is discarded.
+type Transcript struct {
+	dir           string
+	maxFileBytes  int64
+	maxTotalBytes int64
+
+	mu        sync.Mutex
+	file      *os.File
+	written   int64
+	truncated bool
+}
+
+// NewTranscript returns a Transcript writing the files of the runs to
+// protocol.TranscriptDir in workDir. Zero limits select the defaults.
+func NewTranscript(workDir string, maxFileBytes, maxTotalBytes int64) (*Transcript, error) {
+	if maxFileBytes <= 0 {
+		maxFileBytes = DefaultTranscriptMaxFileBytes
+	}
+	if maxTotalBytes <= 0 {
+		maxTotalBytes = DefaultTranscriptMaxTotalBytes
+	}
+	dir := filepath.Join(workDir, filepath.FromSlash(protocol.TranscriptDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// Keep transcripts out of the workspace's git repository.
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0644); err != nil {
+		return nil, err
+	}
+	return &Transcript{dir: dir, maxFileBytes: maxFileBytes, maxTotalBytes: maxTotalBytes}, nil
+}
+
+// Begin directs the output that follows to the transcript of runID, and
+// deletes the oldest transcripts if they take too much space.
+func (t *Transcript) Begin(runID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+	if runID == "" {
+		return
+	}
+	name, err := protocol.TranscriptFile(runID)
+	if err != nil {
+		slog.Warn("not writing transcript", "run_id", runID, "error", err)
+		return
+	}
+	path := filepath.Join(t.dir, filepath.Base(name))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		slog.Warn("failed to open transcript", "path", path, "error", err)
+		return
+	}
+	var size int64
+	if info, err := f.Stat(); err == nil {
+		size = info.Size()
+	}
+	t.file, t.written, t.truncated = f, size, size >= t.maxFileBytes
+	t.pruneLocked(path)
+}
+
+// Write appends p to the current run's transcript. It never fails, so that
+// it can tee the CLI's output without disturbing its parsing.
+func (t *Transcript) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file == nil || t.truncated {
+		return len(p), nil
+	}
+	chunk := p
+	if room := t.maxFileBytes - t.written; int64(len(chunk)) > room {
+		chunk = chunk[:max(room, 0)]
+		t.truncated = true
+	}
+	if _, err := t.file.Write(chunk); err != nil {
+		slog.Warn("failed to write transcript", "path", t.file.Name(), "error", err)
+		t.closeLocked()
+		return len(p), nil
+	}
+	t.written += int64(len(chunk))
+	if t.truncated {
+		t.file.WriteString(transcriptTruncated)
+	}
+	return len(p), nil
+}
+
+// Close closes the current run's transcript.
+func (t *Transcript) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closeLocked()
+	return nil
+}
+
+func (t *Transcript) closeLocked() {
+	if t.file != nil {
+		t.file.Close()
+		t.file = nil
+	}
+}
+
+// pruneLocked deletes the oldest transcripts, other than current, until the
+// directory holds at most maxTotalBytes of them.
+func (t *Transcript) pruneLocked(current string) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return
+	}
+	type transcriptFile struct {
+		path  string
+		size  int64
+		mtime int64
+	}
+	var files []transcriptFile
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, transcriptFile{filepath.Join(t.dir, e.Name()), info.Size(), info.ModTime().UnixNano()})
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime < files[j].mtime })
+	for _, f := range files {
+		if total <= t.maxTotalBytes {
+			break
+		}
+		if f.path == current {
+			continue
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+}
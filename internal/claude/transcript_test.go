@@ -0,0 +1,118 @@
+package claude
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func readTranscript(t *testing.T, workDir, runID string) string {
+	t.Helper()
+	name, err := protocol.TranscriptFile(runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(workDir, name))
+	if err != nil {
+		t.Fatalf("reading transcript of %s: %v", runID, err)
+	}
+	return string(data)
+}
+
+func TestTranscript_OneFilePerRun(t *testing.T) {
+	workDir := t.TempDir()
+	tr, err := NewTranscript(workDir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	tr.Write([]byte("before any run\n"))
+	tr.Begin("run-1")
+	tr.Write([]byte(`{"type":"assistant"}` + "\n"))
+	tr.Begin("run-2")
+	tr.Write([]byte(`{"type":"result"}` + "\n"))
+	tr.Begin("../escape")
+	tr.Write([]byte("dropped\n"))
+	tr.Close()
+
+	if got := readTranscript(t, workDir, "run-1"); got != `{"type":"assistant"}`+"\n" {
+		t.Errorf("run-1: got %q", got)
+	}
+	if got := readTranscript(t, workDir, "run-2"); got != `{"type":"result"}`+"\n" {
+		t.Errorf("run-2: got %q", got)
+	}
+	entries, _ := os.ReadDir(filepath.Join(workDir, protocol.TranscriptDir))
+	if len(entries) != 3 { // .gitignore and the two runs
+		t.Errorf("transcript dir: got %d entries, want 3", len(entries))
+	}
+}
+
+func TestTranscript_CapsFileSize(t *testing.T) {
+	workDir := t.TempDir()
+	tr, err := NewTranscript(workDir, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Begin("run-1")
+	if n, err := tr.Write([]byte("0123456789abcdef")); n != 16 || err != nil {
+		t.Errorf("Write: got %d, %v; it must never fail", n, err)
+	}
+	tr.Write([]byte("more"))
+	tr.Close()
+
+	if got := readTranscript(t, workDir, "run-1"); got != "0123456789"+transcriptTruncated {
+		t.Errorf("capped transcript: got %q", got)
+	}
+}
+
+func TestTranscript_DeletesOldestOverTotal(t *testing.T) {
+	workDir := t.TempDir()
+	tr, err := NewTranscript(workDir, 0, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	base := time.Now().Add(-time.Hour)
+	for i, runID := range []string{"run-1", "run-2", "run-3"} {
+		tr.Begin(runID)
+		tr.Write([]byte("0123456789"))
+		tr.Begin("")
+		name, _ := protocol.TranscriptFile(runID)
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(filepath.Join(workDir, name), mtime, mtime)
+	}
+	tr.Begin("run-4")
+
+	for runID, want := range map[string]bool{"run-1": false, "run-2": false, "run-3": true, "run-4": true} {
+		name, _ := protocol.TranscriptFile(runID)
+		_, err := os.Stat(filepath.Join(workDir, name))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists: got %v, want %v", runID, exists, want)
+		}
+	}
+}
+
+func TestManager_WritesTranscript(t *testing.T) {
+	useFakeClaude(t, "")
+	m := newRunningManager(t, false)
+	tr, err := NewTranscript(m.config.WorkDir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	m.config.Transcript = tr
+
+	tr.Begin("run-1")
+	if err := m.SendInput("message"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+	tr.Close()
+	if got := readTranscript(t, m.config.WorkDir, "run-1"); !strings.Contains(got, `"session_id":"sess-invocation"`) {
+		t.Errorf("transcript: got %q", got)
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// DBStats is the API's database health report. Reading it requires an
+// admin token.
+type DBStats struct {
+	JournalMode        string `json:"journal_mode"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDurationMs     int64  `json:"wait_duration_ms"`
+	PendingDeadLetters int64  `json:"pending_dead_letters"`
+	TaskLogWriter      struct {
+		Queued     int     `json:"queued"`
+		Batches    int64   `json:"batches"`
+		Rows       int64   `json:"rows"`
+		Errors     int64   `json:"errors"`
+		WriteMs    float64 `json:"write_ms"`
+		MaxBatchMs float64 `json:"max_batch_ms"`
+	} `json:"task_log_writer"`
+}
+
+// DBStats returns the API's database health report.
+func (c *Client) DBStats(ctx context.Context) (*DBStats, error) {
+	var stats DBStats
+	if err := c.do(ctx, http.MethodGet, "/api/admin/db", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// ChatResult is the API's answer to a chat message. Status is sent, or
+// queued while the team deploys or the rate limiter holds the message.
+type ChatResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// ID is the ID of the stored user message.
+	ID string `json:"id"`
+}
+
+// SendChat sends a message to a running team's leader.
+func (c *Client) SendChat(ctx context.Context, teamID, message string) (*ChatResult, error) {
+	var result ChatResult
+	body := map[string]string{"message": message}
+	if err := c.do(ctx, http.MethodPost, "/api/teams/"+escape(teamID)+"/chat", body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListMessages returns up to 500 of a team's newest messages, newest first.
+// Types limits them to those message types, such as leader_response; by
+// default only user messages and leader responses are returned.
+func (c *Client) ListMessages(ctx context.Context, teamID string, types ...string) ([]models.TaskLog, error) {
+	q := url.Values{"limit": {"500"}}
+	if len(types) > 0 {
+		q.Set("types", strings.Join(types, ","))
+	}
+	var page struct {
+		Items []models.TaskLog `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/teams/"+escape(teamID)+"/messages?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	type request struct {
+		method, path, auth string
+		body               map[string]any
+	}
+	var got []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := request{method: r.Method, path: r.URL.RequestURI(), auth: r.Header.Get("Authorization")}
+		json.NewDecoder(r.Body).Decode(&req.body)
+		got = append(got, req)
+		switch {
+		case r.URL.Path == "/api/teams/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"team not found","code":"TEAM_NOT_FOUND"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/teams":
+			w.Write([]byte(`{"items":[{"id":"t1","name":"platform"}]}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/api/webhooks":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"webhook":{"id":"w1","name":"deploys"},"token":"whk_secret"}`))
+		default:
+			w.Write([]byte(`{"id":"t1","name":"platform","labels":{"env":"prod"}}`))
+		}
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+	c := New(srv.URL+"/", "tok")
+
+	team, err := c.CreateTeam(ctx, TeamInput{Name: "platform", Runtime: "docker", Labels: map[string]string{"env": "prod"}})
+	if err != nil || team.ID != "t1" || string(team.Labels) != `{"env":"prod"}` {
+		t.Fatalf("CreateTeam: got %+v, %v", team, err)
+	}
+	if _, err := c.UpdateTeam(ctx, "t1", TeamInput{Name: "platform", Runtime: "docker"}); err != nil {
+		t.Fatalf("UpdateTeam: %v", err)
+	}
+	if err := c.DeleteTeam(ctx, "t1", DeleteTeamOptions{Force: true}); err != nil {
+		t.Fatalf("DeleteTeam: %v", err)
+	}
+	created, err := c.CreateWebhook(ctx, WebhookInput{Name: "deploys", TeamID: "t1", PromptTemplate: "Deploy {{ref}}"})
+	if err != nil || created.Webhook.ID != "w1" || created.Token != "whk_secret" {
+		t.Fatalf("CreateWebhook: got %+v, %v", created, err)
+	}
+
+	_, err = c.GetTeam(ctx, "missing")
+	if !IsNotFound(err) || err.Error() != "agentcrew api: 404: team not found" {
+		t.Errorf("GetTeam missing: got %v", err)
+	}
+	if apiErr, ok := err.(*Error); !ok || apiErr.Code != "TEAM_NOT_FOUND" {
+		t.Errorf("GetTeam missing code: got %+v", err)
+	}
+
+	found, err := c.FindTeam(ctx, "platform")
+	if err != nil || found == nil || found.ID != "t1" {
+		t.Errorf("FindTeam: got %+v, %v", found, err)
+	}
+
+	if got[0].method != "POST" || got[0].path != "/api/teams" || got[0].auth != "Bearer tok" || got[0].body["runtime"] != "docker" {
+		t.Errorf("create request: got %+v", got[0])
+	}
+	if _, ok := got[1].body["runtime"]; ok || got[1].path != "/api/teams/t1" {
+		t.Errorf("update request: got %+v", got[1])
+	}
+	if got[2].path != "/api/teams/t1?force=true" {
+		t.Errorf("delete request: got %+v", got[2])
+	}
+	if last := got[len(got)-1]; last.method != "GET" || last.path != "/api/teams?limit=1&slug=platform" {
+		t.Errorf("find request: got %+v", last)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// ScheduleInput is the configuration of a schedule, used to create and
+// update it. Either Prompt or PromptTemplateID must be set; with a
+// template, the API renders the prompt. On update, an empty
+// PromptTemplateID detaches the schedule from its template.
+type ScheduleInput struct {
+	Name             string            `json:"name"`
+	TeamID           string            `json:"team_id"`
+	Prompt           string            `json:"prompt,omitempty"`
+	PromptTemplateID *string           `json:"prompt_template_id,omitempty"`
+	PromptVariables  map[string]string `json:"prompt_variables,omitempty"`
+	CronExpression   string            `json:"cron_expression"`
+	Timezone         string            `json:"timezone,omitempty"`
+	Enabled          *bool             `json:"enabled,omitempty"`
+}
+
+// GetSchedule returns a schedule.
+func (c *Client) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := c.do(ctx, http.MethodGet, "/api/schedules/"+escape(id), nil, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// CreateSchedule creates a schedule.
+func (c *Client) CreateSchedule(ctx context.Context, in ScheduleInput) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := c.do(ctx, http.MethodPost, "/api/schedules", in, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// UpdateSchedule replaces a schedule's configuration.
+func (c *Client) UpdateSchedule(ctx context.Context, id string, in ScheduleInput) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := c.do(ctx, http.MethodPut, "/api/schedules/"+escape(id), in, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteSchedule deletes a schedule and its runs.
+func (c *Client) DeleteSchedule(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/schedules/"+escape(id), nil, nil)
+}
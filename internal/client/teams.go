@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// TeamInput is the configuration of a team, used to create and update it.
+// Runtime is only used on create. Empty Provider and ConfigDirMode keep
+// the API's defaults.
+type TeamInput struct {
+	Name              string            `json:"name"`
+	Description       string            `json:"description"`
+	Runtime           string            `json:"runtime,omitempty"`
+	Provider          string            `json:"provider,omitempty"`
+	ModelProvider     string            `json:"model_provider"`
+	WorkspacePath     string            `json:"workspace_path"`
+	AgentImage        string            `json:"agent_image"`
+	ConfigDirMode     string            `json:"config_dir_mode,omitempty"`
+	ResourcePreset    string            `json:"resource_preset"`
+	Labels            map[string]string `json:"labels"`
+	MaxConcurrentRuns int               `json:"max_concurrent_runs"`
+	TelemetryLevel    string            `json:"telemetry_level"`
+	PlanApproval      bool              `json:"plan_approval"`
+	Memory            bool              `json:"memory"`
+	KnowledgeBase     bool              `json:"knowledge_base"`
+}
+
+// DeleteTeamOptions are the options of DeleteTeam.
+type DeleteTeamOptions struct {
+	// Force stops a running team before deleting it.
+	Force bool
+	// PreserveWorkspace keeps the team's workspace.
+	PreserveWorkspace bool
+}
+
+// GetTeam returns a team with its agents.
+func (c *Client) GetTeam(ctx context.Context, id string) (*models.Team, error) {
+	var team models.Team
+	if err := c.do(ctx, http.MethodGet, "/api/teams/"+escape(id), nil, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// FindTeam returns the team with the given slug, with its agents, or nil
+// if there is none.
+func (c *Client) FindTeam(ctx context.Context, slug string) (*models.Team, error) {
+	q := url.Values{"slug": {slug}, "limit": {"1"}}
+	var page struct {
+		Items []models.Team `json:"items"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/teams?"+q.Encode(), nil, &page); err != nil {
+		return nil, err
+	}
+	if len(page.Items) == 0 {
+		return nil, nil
+	}
+	return &page.Items[0], nil
+}
+
+// CreateTeam creates a stopped team without agents.
+func (c *Client) CreateTeam(ctx context.Context, in TeamInput) (*models.Team, error) {
+	var team models.Team
+	if err := c.do(ctx, http.MethodPost, "/api/teams", in, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// UpdateTeam replaces a team's configuration.
+func (c *Client) UpdateTeam(ctx context.Context, id string, in TeamInput) (*models.Team, error) {
+	in.Runtime = ""
+	var team models.Team
+	if err := c.do(ctx, http.MethodPut, "/api/teams/"+escape(id), in, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// DeleteTeam deletes a team and its agents.
+func (c *Client) DeleteTeam(ctx context.Context, id string, opts DeleteTeamOptions) error {
+	q := url.Values{}
+	if opts.Force {
+		q.Set("force", "true")
+	}
+	if opts.PreserveWorkspace {
+		q.Set("preserve_workspace", "true")
+	}
+	path := "/api/teams/" + escape(id)
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	return c.do(ctx, http.MethodDelete, path, nil, nil)
+}
+
+// DeployTeam starts deploying a team. The team's status is deploying until
+// its agents are up.
+func (c *Client) DeployTeam(ctx context.Context, id string) (*models.Team, error) {
+	var team models.Team
+	if err := c.do(ctx, http.MethodPost, "/api/teams/"+escape(id)+"/deploy", nil, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// StopTeam stops a team's agents and removes its infrastructure.
+func (c *Client) StopTeam(ctx context.Context, id string) (*models.Team, error) {
+	var team models.Team
+	if err := c.do(ctx, http.MethodPost, "/api/teams/"+escape(id)+"/stop", nil, &team); err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// AgentInput is the configuration of an agent, used to create and update
+// it. Skills, Permissions, Resources, SubAgentSkills and ExtraClaudeArgs are
+// JSON documents in the formats the API accepts; nil leaves them unset. Empty
+// SubAgentModel, SubAgentIsolation and SubAgentPermissionMode keep the
+// API's defaults.
+type AgentInput struct {
+	Name                   string          `json:"name"`
+	Role                   string          `json:"role,omitempty"`
+	Specialty              string          `json:"specialty"`
+	SystemPrompt           string          `json:"system_prompt"`
+	InstructionsMD         string          `json:"instructions_md"`
+	Skills                 json.RawMessage `json:"skills,omitempty"`
+	Permissions            json.RawMessage `json:"permissions,omitempty"`
+	Resources              json.RawMessage `json:"resources,omitempty"`
+	SubAgentDescription    string          `json:"sub_agent_description"`
+	SubAgentInstructions   string          `json:"sub_agent_instructions"`
+	SubAgentModel          string          `json:"sub_agent_model,omitempty"`
+	SubAgentSkills         json.RawMessage `json:"sub_agent_skills,omitempty"`
+	SubAgentBackground     *bool           `json:"sub_agent_background,omitempty"`
+	SubAgentIsolation      string          `json:"sub_agent_isolation,omitempty"`
+	SubAgentPermissionMode string          `json:"sub_agent_permission_mode,omitempty"`
+	ExtraClaudeArgs        json.RawMessage `json:"extra_claude_args,omitempty"`
+}
+
+func agentPath(teamID, agentID string) string {
+	return "/api/teams/" + escape(teamID) + "/agents/" + escape(agentID)
+}
+
+// GetAgent returns one of a team's agents.
+func (c *Client) GetAgent(ctx context.Context, teamID, agentID string) (*models.Agent, error) {
+	var agent models.Agent
+	if err := c.do(ctx, http.MethodGet, agentPath(teamID, agentID), nil, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// CreateAgent adds an agent to a team.
+func (c *Client) CreateAgent(ctx context.Context, teamID string, in AgentInput) (*models.Agent, error) {
+	var agent models.Agent
+	if err := c.do(ctx, http.MethodPost, "/api/teams/"+escape(teamID)+"/agents", in, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// UpdateAgent replaces an agent's configuration.
+func (c *Client) UpdateAgent(ctx context.Context, teamID, agentID string, in AgentInput) (*models.Agent, error) {
+	var agent models.Agent
+	if err := c.do(ctx, http.MethodPut, agentPath(teamID, agentID), in, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// DeleteAgent removes an agent from a team.
+func (c *Client) DeleteAgent(ctx context.Context, teamID, agentID string) error {
+	return c.do(ctx, http.MethodDelete, agentPath(teamID, agentID), nil, nil)
+}
@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// WebhookInput is the configuration of a webhook, used to create and update
+// it. TeamID is only used on create.
+type WebhookInput struct {
+	Name           string `json:"name"`
+	TeamID         string `json:"team_id,omitempty"`
+	PromptTemplate string `json:"prompt_template"`
+	TimeoutSeconds *int   `json:"timeout_seconds,omitempty"`
+	MaxConcurrent  *int   `json:"max_concurrent,omitempty"`
+	Enabled        *bool  `json:"enabled,omitempty"`
+}
+
+// CreatedWebhook is a new webhook with its trigger token, which the API
+// returns only once.
+type CreatedWebhook struct {
+	Webhook models.Webhook `json:"webhook"`
+	Token   string         `json:"token"`
+}
+
+// GetWebhook returns a webhook.
+func (c *Client) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := c.do(ctx, http.MethodGet, "/api/webhooks/"+escape(id), nil, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// CreateWebhook creates a webhook.
+func (c *Client) CreateWebhook(ctx context.Context, in WebhookInput) (*CreatedWebhook, error) {
+	var created CreatedWebhook
+	if err := c.do(ctx, http.MethodPost, "/api/webhooks", in, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateWebhook replaces a webhook's configuration.
+func (c *Client) UpdateWebhook(ctx context.Context, id string, in WebhookInput) (*models.Webhook, error) {
+	in.TeamID = ""
+	var webhook models.Webhook
+	if err := c.do(ctx, http.MethodPut, "/api/webhooks/"+escape(id), in, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook deletes a webhook and its runs.
+func (c *Client) DeleteWebhook(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/webhooks/"+escape(id), nil, nil)
+}
@@ -0,0 +1,175 @@
+// Package election coordinates processes that share the database through
+// leases stored in it. An Elector picks one API replica to do the work only
+// one replica may do, such as running schedules: replicas compete for its
+// lease, the holder renews it while it runs, and another replica takes over
+// once it expires. Acquire and Release share out many leases, such as the
+// teams relay workers relay.
+package election
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// DefaultTTL is how long a lease lasts without being renewed.
+const DefaultTTL = 15 * time.Second
+
+// Elector competes for one lease on behalf of this replica.
+type Elector struct {
+	db     *gorm.DB
+	name   string
+	holder string
+	ttl    time.Duration
+	held   atomic.Bool
+}
+
+// New returns an elector for the lease name, held as holder, which must be
+// unique across replicas. A ttl of zero uses DefaultTTL.
+func New(db *gorm.DB, name, holder string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Elector{db: db, name: name, holder: holder, ttl: ttl}
+}
+
+// Holder returns the name this replica holds the lease under.
+func (e *Elector) Holder() string { return e.holder }
+
+// IsHeld reports whether this replica holds the lease.
+func (e *Elector) IsHeld() bool { return e.held.Load() }
+
+// Run competes for the lease until ctx is cancelled. Each time the lease is
+// acquired, lead is called with a context that is cancelled when the lease
+// is lost, and Run waits for lead to return before competing again. Run
+// renews the lease every third of its TTL and gives it up on return, so
+// that another replica can take over at once.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	interval := e.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Wait until the lease is ours.
+		for {
+			if ok, err := e.acquire(time.Now().UTC()); err != nil {
+				slog.Warn("election: failed to acquire lease", "lease", e.name, "error", err)
+			} else if ok {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		slog.Info("election: acquired lease", "lease", e.name, "holder", e.holder)
+		e.held.Store(true)
+		deadline := time.Now().Add(e.ttl)
+		leadCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			lead(leadCtx)
+		}()
+
+		// Renew until the lease is lost or ctx is cancelled. A renewal that
+		// fails with an error is retried until the lease would have
+		// expired, since another replica may take it from then on.
+	renew:
+		for {
+			select {
+			case <-ctx.Done():
+				break renew
+			case <-ticker.C:
+			}
+			ok, err := e.acquire(time.Now().UTC())
+			if err != nil {
+				slog.Warn("election: failed to renew lease", "lease", e.name, "error", err)
+				if time.Now().Before(deadline) {
+					continue
+				}
+				break renew
+			}
+			if !ok {
+				break renew
+			}
+			deadline = time.Now().Add(e.ttl)
+		}
+
+		cancel()
+		<-done
+		e.held.Store(false)
+		if ctx.Err() != nil {
+			e.release()
+			slog.Info("election: released lease", "lease", e.name, "holder", e.holder)
+			return
+		}
+		slog.Warn("election: lost lease", "lease", e.name, "holder", e.holder)
+	}
+}
+
+// acquire takes or renews the elector's lease.
+func (e *Elector) acquire(now time.Time) (bool, error) {
+	return Acquire(e.db, e.name, e.holder, e.ttl, now)
+}
+
+// release gives up the elector's lease.
+func (e *Elector) release() {
+	Release(e.db, e.name, e.holder)
+}
+
+// Acquire takes or renews the lease name for holder until now plus ttl. It
+// succeeds when the lease is free, expired, or already held by holder.
+func Acquire(db *gorm.DB, name, holder string, ttl time.Duration, now time.Time) (bool, error) {
+	expires := now.Add(ttl)
+	res := db.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, holder, now).
+		Updates(map[string]interface{}{"holder": holder, "expires_at": expires})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+	res = db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Lease{Name: name, Holder: holder, ExpiresAt: expires})
+	return res.RowsAffected > 0, res.Error
+}
+
+// Release expires the lease name if holder holds it, so that another holder
+// can take it at once.
+func Release(db *gorm.DB, name, holder string) {
+	db.Model(&models.Lease{}).
+		Where("name = ? AND holder = ?", name, holder).
+		Update("expires_at", time.Now().UTC().Add(-time.Second))
+}
+
+// Lookup returns the lease name, or nil if it is free or expired.
+func Lookup(db *gorm.DB, name string, now time.Time) (*models.Lease, error) {
+	var leases []models.Lease
+	if err := db.Where("name = ? AND expires_at >= ?", name, now).Limit(1).Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	if len(leases) == 0 {
+		return nil, nil
+	}
+	return &leases[0], nil
+}
+
+// Active returns the number of unexpired leases whose name starts with
+// prefix.
+func Active(db *gorm.DB, prefix string, now time.Time) (int, error) {
+	var n int64
+	err := db.Model(&models.Lease{}).
+		Where("name LIKE ? AND expires_at >= ?", prefix+"%", now).
+		Count(&n).Error
+	return int(n), err
+}
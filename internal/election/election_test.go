@@ -0,0 +1,145 @@
+package election
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	// A file, not :memory:, so that every pooled connection sees the lease.
+	db, err := models.InitDB(filepath.Join(t.TempDir(), "election.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return db
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAcquire(t *testing.T) {
+	db := setupDB(t)
+	a := New(db, "api", "a", time.Minute)
+	b := New(db, "api", "b", time.Minute)
+	now := time.Now().UTC()
+
+	if ok, err := a.acquire(now); err != nil || !ok {
+		t.Fatalf("a acquire: got %t, %v", ok, err)
+	}
+	if ok, err := a.acquire(now.Add(time.Second)); err != nil || !ok {
+		t.Fatalf("a renew: got %t, %v", ok, err)
+	}
+	if ok, err := b.acquire(now.Add(time.Second)); err != nil || ok {
+		t.Fatalf("b acquire while held: got %t, %v", ok, err)
+	}
+	// Once a's lease expires, b takes it over.
+	if ok, err := b.acquire(now.Add(2 * time.Minute)); err != nil || !ok {
+		t.Fatalf("b acquire after expiry: got %t, %v", ok, err)
+	}
+	var lease models.Lease
+	db.First(&lease, "name = ?", "api")
+	if lease.Holder != "b" {
+		t.Errorf("holder: got %q, want b", lease.Holder)
+	}
+
+	if held, err := Lookup(db, "api", now.Add(2*time.Minute)); err != nil || held == nil || held.Holder != "b" {
+		t.Errorf("lookup while held: got %+v, %v", held, err)
+	}
+	if held, err := Lookup(db, "api", now.Add(4*time.Minute)); err != nil || held != nil {
+		t.Errorf("lookup after expiry: got %+v, %v", held, err)
+	}
+}
+
+func TestRun_FailsOverOnRelease(t *testing.T) {
+	db := setupDB(t)
+	a := New(db, "api", "a", 300*time.Millisecond)
+	b := New(db, "api", "b", 300*time.Millisecond)
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	aDone := make(chan struct{})
+	leadingA := make(chan struct{})
+	go func() {
+		defer close(aDone)
+		a.Run(ctxA, func(ctx context.Context) {
+			close(leadingA)
+			<-ctx.Done()
+		})
+	}()
+	<-leadingA
+
+	var bLed, bStopped bool
+	bDone := make(chan struct{})
+	go func() {
+		defer close(bDone)
+		b.Run(ctxB, func(ctx context.Context) {
+			bLed = true
+			<-ctx.Done()
+			bStopped = true
+		})
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	if b.IsHeld() {
+		t.Fatal("b holds the lease while a does")
+	}
+	if !a.IsHeld() {
+		t.Fatal("a does not hold the lease")
+	}
+
+	cancelA()
+	<-aDone
+	if a.IsHeld() {
+		t.Error("a still holds the lease after Run returned")
+	}
+	waitFor(t, "b to take over", b.IsHeld)
+
+	cancelB()
+	<-bDone
+	if !bLed || !bStopped {
+		t.Errorf("b lead: led %t, stopped %t", bLed, bStopped)
+	}
+}
+
+func TestRun_StepsDownWhenLeaseTaken(t *testing.T) {
+	db := setupDB(t)
+	a := New(db, "api", "a", 300*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopped := make(chan struct{})
+	go a.Run(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		close(stopped)
+	})
+	waitFor(t, "a to lead", a.IsHeld)
+
+	// Another replica took the lease, for example after a's renewals
+	// stalled past its expiry.
+	db.Model(&models.Lease{}).Where("name = ?", "api").
+		Updates(map[string]interface{}{"holder": "b", "expires_at": time.Now().UTC().Add(time.Hour)})
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a did not step down")
+	}
+	waitFor(t, "a to drop the lease", func() bool { return !a.IsHeld() })
+}
@@ -0,0 +1,28 @@
+// Package env reads the settings that the API and the relay worker both
+// take from environment variables, so that they parse them the same way.
+package env
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Int returns the integer value of the environment variable key, or 0 if it
+// is unset or not a number.
+func Int(key string) int {
+	n, _ := strconv.Atoi(os.Getenv(key))
+	return n
+}
+
+// List returns the comma-separated items of the environment variable key,
+// without blanks.
+func List(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
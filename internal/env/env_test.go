@@ -0,0 +1,30 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInt(t *testing.T) {
+	t.Setenv("ENV_TEST_INT", "42")
+	if got := Int("ENV_TEST_INT"); got != 42 {
+		t.Errorf("Int: got %d, want 42", got)
+	}
+	t.Setenv("ENV_TEST_INT", "many")
+	if got := Int("ENV_TEST_INT"); got != 0 {
+		t.Errorf("Int of a non-number: got %d, want 0", got)
+	}
+	if got := Int("ENV_TEST_UNSET"); got != 0 {
+		t.Errorf("Int of an unset variable: got %d, want 0", got)
+	}
+}
+
+func TestList(t *testing.T) {
+	t.Setenv("ENV_TEST_LIST", " docker, ,kubernetes ,")
+	if got, want := List("ENV_TEST_LIST"), []string{"docker", "kubernetes"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List: got %q, want %q", got, want)
+	}
+	if got := List("ENV_TEST_UNSET"); got != nil {
+		t.Errorf("List of an unset variable: got %q, want nil", got)
+	}
+}
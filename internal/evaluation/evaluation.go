@@ -0,0 +1,180 @@
+// Package evaluation checks a team's responses to test prompts against
+// assertions, so that changes to prompts and agent configuration can be
+// regression tested.
+package evaluation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Assertion types.
+const (
+	AssertionRegex    = "regex"
+	AssertionJSONPath = "json_path"
+	AssertionLLM      = "llm"
+)
+
+// Assertion is a check a response must pass.
+type Assertion struct {
+	Type string `json:"type"` // regex, json_path or llm
+
+	// Pattern is the regular expression a regex assertion must find in the
+	// response.
+	Pattern string `json:"pattern,omitempty"`
+
+	// Path locates a value in the JSON of the response, as in
+	// "$.items[0].name". Equals is the value it must have; without it the
+	// path only has to exist.
+	Path   string          `json:"path,omitempty"`
+	Equals json.RawMessage `json:"equals,omitempty"`
+
+	// Criteria is what an llm assertion asks the grader to check, in plain
+	// language.
+	Criteria string `json:"criteria,omitempty"`
+}
+
+// Validate checks that the assertion is complete and well formed.
+func (a Assertion) Validate() error {
+	switch a.Type {
+	case AssertionRegex:
+		if a.Pattern == "" {
+			return errors.New("regex assertion requires a pattern")
+		}
+		if _, err := regexp.Compile(a.Pattern); err != nil {
+			return fmt.Errorf("invalid regex pattern: %w", err)
+		}
+	case AssertionJSONPath:
+		if _, err := parsePath(a.Path); err != nil {
+			return err
+		}
+		if len(a.Equals) > 0 && !json.Valid(a.Equals) {
+			return errors.New("json_path equals must be valid JSON")
+		}
+	case AssertionLLM:
+		if strings.TrimSpace(a.Criteria) == "" {
+			return errors.New("llm assertion requires criteria")
+		}
+	default:
+		return fmt.Errorf("unknown assertion type %q: must be regex, json_path or llm", a.Type)
+	}
+	return nil
+}
+
+// Result is the outcome of one assertion.
+type Result struct {
+	Assertion
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty"`
+}
+
+// Grader judges whether a response meets criteria written in plain language.
+// It backs llm assertions.
+type Grader interface {
+	Grade(ctx context.Context, prompt, response, criteria string) (passed bool, reason string, err error)
+}
+
+// Check runs the assertions against the response to prompt. llm assertions
+// fail when grader is nil or returns an error.
+func Check(ctx context.Context, assertions []Assertion, prompt, response string, grader Grader) []Result {
+	results := make([]Result, 0, len(assertions))
+	for _, a := range assertions {
+		r := Result{Assertion: a}
+		switch a.Type {
+		case AssertionRegex:
+			r.Passed, r.Message = checkRegex(a, response)
+		case AssertionJSONPath:
+			r.Passed, r.Message = checkJSONPath(a, response)
+		case AssertionLLM:
+			if grader == nil {
+				r.Message = "no grader available"
+				break
+			}
+			passed, reason, err := grader.Grade(ctx, prompt, response, a.Criteria)
+			if err != nil {
+				r.Message = "grading failed: " + err.Error()
+				break
+			}
+			r.Passed, r.Message = passed, reason
+		default:
+			r.Message = fmt.Sprintf("unknown assertion type %q", a.Type)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Passed reports whether every result passed.
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if !r.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func checkRegex(a Assertion, response string) (bool, string) {
+	re, err := regexp.Compile(a.Pattern)
+	if err != nil {
+		return false, "invalid regex pattern: " + err.Error()
+	}
+	if !re.MatchString(response) {
+		return false, "pattern not found in response"
+	}
+	return true, ""
+}
+
+func checkJSONPath(a Assertion, response string) (bool, string) {
+	doc, err := extractJSON(response)
+	if err != nil {
+		return false, err.Error()
+	}
+	value, err := Lookup(doc, a.Path)
+	if err != nil {
+		return false, err.Error()
+	}
+	if len(a.Equals) == 0 {
+		return true, ""
+	}
+	var want interface{}
+	if err := json.Unmarshal(a.Equals, &want); err != nil {
+		return false, "invalid equals value: " + err.Error()
+	}
+	gotJSON, _ := json.Marshal(value)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		return false, fmt.Sprintf("%s is %s, want %s", a.Path, gotJSON, wantJSON)
+	}
+	return true, ""
+}
+
+// fencedBlock matches a fenced code block, with or without a language tag.
+var fencedBlock = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n(.*?)```")
+
+// extractJSON parses the JSON in a response: the whole response, else its
+// first fenced code block holding JSON, else the text from the first { or
+// [ to the last } or ].
+func extractJSON(response string) (interface{}, error) {
+	candidates := []string{strings.TrimSpace(response)}
+	for _, m := range fencedBlock.FindAllStringSubmatch(response, -1) {
+		candidates = append(candidates, strings.TrimSpace(m[1]))
+	}
+	if start := strings.IndexAny(response, "{["); start >= 0 {
+		if end := strings.LastIndexAny(response, "}]"); end > start {
+			candidates = append(candidates, response[start:end+1])
+		}
+	}
+	for _, c := range candidates {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(c), &doc); err == nil {
+			return doc, nil
+		}
+	}
+	return nil, errors.New("response does not contain JSON")
+}
@@ -0,0 +1,127 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type fakeGrader struct {
+	passed bool
+	err    error
+	got    string
+}
+
+func (g *fakeGrader) Grade(_ context.Context, _, _, criteria string) (bool, string, error) {
+	g.got = criteria
+	return g.passed, "graded", g.err
+}
+
+func TestAssertion_Validate(t *testing.T) {
+	valid := []Assertion{
+		{Type: AssertionRegex, Pattern: `(?i)done`},
+		{Type: AssertionJSONPath, Path: "$.items[0].name", Equals: json.RawMessage(`"x"`)},
+		{Type: AssertionJSONPath, Path: "$"},
+		{Type: AssertionLLM, Criteria: "mentions the fix"},
+	}
+	for _, a := range valid {
+		if err := a.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error %v", a, err)
+		}
+	}
+	invalid := []Assertion{
+		{Type: AssertionRegex},
+		{Type: AssertionRegex, Pattern: "("},
+		{Type: AssertionJSONPath, Path: "items"},
+		{Type: AssertionJSONPath, Path: "$.items[x]"},
+		{Type: AssertionJSONPath, Path: "$.a", Equals: json.RawMessage(`{`)},
+		{Type: AssertionLLM, Criteria: " "},
+		{Type: "contains"},
+	}
+	for _, a := range invalid {
+		if err := a.Validate(); err == nil {
+			t.Errorf("%+v: expected an error", a)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	response := "Here you go:\n```json\n{\"status\": \"ok\", \"items\": [{\"name\": \"a\", \"count\": 2}]}\n```\nAll done."
+	grader := &fakeGrader{passed: true}
+	results := Check(context.Background(), []Assertion{
+		{Type: AssertionRegex, Pattern: `All done\.$`},
+		{Type: AssertionJSONPath, Path: "$.items[0].count", Equals: json.RawMessage(`2`)},
+		{Type: AssertionJSONPath, Path: "$.status"},
+		{Type: AssertionLLM, Criteria: "lists the items"},
+	}, "list the items", response, grader)
+	for _, r := range results {
+		if !r.Passed {
+			t.Errorf("%s: expected pass, got %q", r.Type, r.Message)
+		}
+	}
+	if !Passed(results) || grader.got != "lists the items" {
+		t.Errorf("Passed = %v, grader criteria %q", Passed(results), grader.got)
+	}
+
+	results = Check(context.Background(), []Assertion{
+		{Type: AssertionRegex, Pattern: `error`},
+		{Type: AssertionJSONPath, Path: "$.items[0].name", Equals: json.RawMessage(`"b"`)},
+		{Type: AssertionJSONPath, Path: "$.items[3]"},
+		{Type: AssertionLLM, Criteria: "is polite"},
+	}, "list the items", response, &fakeGrader{err: errors.New("grader down")})
+	for _, r := range results {
+		if r.Passed || r.Message == "" {
+			t.Errorf("%s: expected a failure with a message, got %+v", r.Type, r)
+		}
+	}
+	if Passed(results) {
+		t.Error("Passed should be false")
+	}
+
+	results = Check(context.Background(), []Assertion{{Type: AssertionLLM, Criteria: "x"}}, "p", "r", nil)
+	if results[0].Passed {
+		t.Error("llm assertion without a grader should fail")
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	for _, response := range []string{
+		`{"a": 1}`,
+		"```\n{\"a\": 1}\n```",
+		`The answer is {"a": 1}, as requested.`,
+	} {
+		doc, err := extractJSON(response)
+		if err != nil {
+			t.Errorf("%q: %v", response, err)
+			continue
+		}
+		if v, err := Lookup(doc, "$.a"); err != nil || v != float64(1) {
+			t.Errorf("%q: $.a = %v, %v", response, v, err)
+		}
+	}
+	if _, err := extractJSON("no json here"); err == nil {
+		t.Error("expected an error for a response without JSON")
+	}
+}
+
+func TestParseVerdict(t *testing.T) {
+	cases := []struct {
+		reply  string
+		passed bool
+		reason string
+	}{
+		{"PASS\nThe response lists every item.", true, "The response lists every item."},
+		{"**FAIL**\nIt misses item b.", false, "It misses item b."},
+		{"pass: looks right", true, "pass: looks right"},
+	}
+	for _, c := range cases {
+		passed, reason, err := ParseVerdict(c.reply)
+		if err != nil || passed != c.passed || reason != c.reason {
+			t.Errorf("%q: got %v, %q, %v", c.reply, passed, reason, err)
+		}
+	}
+	if _, _, err := ParseVerdict("I think it is fine"); err == nil {
+		t.Error("expected an error for a reply without a verdict")
+	}
+}
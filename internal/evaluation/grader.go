@@ -0,0 +1,51 @@
+package evaluation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GradingPrompt asks an agent to grade response, the answer to prompt,
+// against criteria. ParseVerdict reads the reply.
+func GradingPrompt(prompt, response, criteria string) string {
+	return fmt.Sprintf(`You are grading the response of an AI agent for an automated evaluation. Do not use any tools.
+
+<prompt>
+%s
+</prompt>
+
+<response>
+%s
+</response>
+
+<criteria>
+%s
+</criteria>
+
+Does the response meet the criteria? Reply with PASS or FAIL on the first line, followed by a one-sentence reason.`,
+		prompt, response, criteria)
+}
+
+// ParseVerdict reads a grader's reply to GradingPrompt.
+func ParseVerdict(reply string) (passed bool, reason string, err error) {
+	reply = strings.TrimSpace(reply)
+	first, rest, _ := strings.Cut(reply, "\n")
+	verdict := strings.ToUpper(strings.Trim(strings.TrimSpace(first), "*#:. "))
+	reason = strings.TrimSpace(rest)
+	switch {
+	case strings.HasPrefix(verdict, "PASS"):
+		return true, reasonOr(reason, first), nil
+	case strings.HasPrefix(verdict, "FAIL"):
+		return false, reasonOr(reason, first), nil
+	}
+	return false, "", errors.New("grader reply does not start with PASS or FAIL")
+}
+
+// reasonOr returns reason, or the verdict line when the reason is on it.
+func reasonOr(reason, verdictLine string) string {
+	if reason != "" {
+		return reason
+	}
+	return strings.TrimSpace(verdictLine)
+}
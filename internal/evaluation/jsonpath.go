@@ -0,0 +1,87 @@
+package evaluation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathStep is one step of a JSON path: an object key, or an array index
+// when key is empty.
+type pathStep struct {
+	key   string
+	index int
+}
+
+// parsePath parses a JSON path of the form "$.key.list[0].other". Only
+// object keys and array indexes are supported.
+func parsePath(path string) ([]pathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+	var steps []pathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("json path %q has an empty key", path)
+			}
+			steps = append(steps, pathStep{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q has an unclosed [", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("json path %q has an invalid index %q", path, rest[1:end])
+			}
+			steps = append(steps, pathStep{index: index})
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("json path %q is invalid at %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// Lookup returns the value at path in a decoded JSON document.
+func Lookup(doc interface{}, path string) (interface{}, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	value := doc
+	for i, step := range steps {
+		at := path
+		if i+1 < len(steps) {
+			at = "step " + strconv.Itoa(i+1) + " of " + path
+		}
+		if step.key != "" {
+			obj, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: not an object", at)
+			}
+			if value, ok = obj[step.key]; !ok {
+				return nil, fmt.Errorf("%s: key %q not found", at, step.key)
+			}
+			continue
+		}
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: not an array", at)
+		}
+		if step.index >= len(list) {
+			return nil, fmt.Errorf("%s: index %d out of range", at, step.index)
+		}
+		value = list[step.index]
+	}
+	return value, nil
+}
@@ -0,0 +1,171 @@
+// Package events is an in-process bus for domain events. Handlers and the
+// relay publish what happened, such as a finished run, and the modules that
+// react to it, such as post-actions and issue trackers, subscribe to it.
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Event names.
+const (
+	NameTeamDeployed      = "team.deployed"
+	NameRunCompleted      = "run.completed"
+	NamePermissionDenied  = "permission.denied"
+	NameAgentDegraded     = "agent.degraded"
+	NameApprovalRequested = "approval.requested"
+	NameApprovalDecided   = "approval.decided"
+)
+
+// Event is a domain event.
+type Event interface {
+	// EventName identifies the kind of event subscribers receive.
+	EventName() string
+}
+
+// TeamDeployed is published when a team deployment or leader restart
+// finishes, whether or not the leader came up.
+type TeamDeployed struct {
+	OrgID    string
+	TeamID   string
+	TeamName string
+	// Status is the team status the deployment ended in: running or error.
+	Status string
+	// Message is the team's status message, which explains a failure.
+	Message string
+}
+
+// RunCompleted is published when a webhook or schedule run finishes.
+type RunCompleted struct {
+	SourceType  string // "webhook" or "schedule"
+	TriggerID   string
+	TriggerName string
+	RunID       string
+	TeamID      string
+	TeamName    string
+	Status      string // "success", "failed" or "timeout"
+	Response    string
+	Error       string
+	Prompt      string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
+
+// PermissionDenied is published when an agent's permission gate refuses a
+// tool call.
+type PermissionDenied struct {
+	TeamID    string
+	TeamName  string
+	AgentName string
+	Tool      string
+	Command   string
+	Reason    string
+}
+
+// AgentDegraded is published when an agent's periodic self-check finds that
+// its CLI, credentials or disk stopped working.
+type AgentDegraded struct {
+	TeamID    string
+	TeamName  string
+	AgentName string
+	Summary   string
+	// Problems are the messages of the checks that did not pass.
+	Problems []string
+}
+
+// ApprovalRequested is published when a member requests an operation that
+// needs an admin's approval, such as deploying a team that requires it.
+type ApprovalRequested struct {
+	ApprovalID       string
+	OrgID            string
+	TeamID           string
+	TeamName         string
+	Action           string
+	RequestedByEmail string
+	Reason           string
+}
+
+// ApprovalDecided is published when an admin approves or rejects an
+// approval request.
+type ApprovalDecided struct {
+	ApprovalID     string
+	OrgID          string
+	TeamID         string
+	TeamName       string
+	Action         string
+	Status         string // "approved" or "rejected"
+	DecidedByEmail string
+	Note           string
+}
+
+func (TeamDeployed) EventName() string      { return NameTeamDeployed }
+func (RunCompleted) EventName() string      { return NameRunCompleted }
+func (PermissionDenied) EventName() string  { return NamePermissionDenied }
+func (AgentDegraded) EventName() string     { return NameAgentDegraded }
+func (ApprovalRequested) EventName() string { return NameApprovalRequested }
+func (ApprovalDecided) EventName() string   { return NameApprovalDecided }
+
+// Bus delivers published events to the handlers subscribed to them. The zero
+// value is ready to use; a nil *Bus drops every event.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(Event)
+}
+
+// New creates a Bus.
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls fn with every published event of type T.
+func Subscribe[T Event](b *Bus, fn func(T)) {
+	var zero T
+	name := zero.EventName()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string][]func(Event))
+	}
+	b.handlers[name] = append(b.handlers[name], func(ev Event) {
+		if ev, ok := ev.(T); ok {
+			fn(ev)
+		}
+	})
+}
+
+// SubscribeAll calls fn with every published event.
+func (b *Bus) SubscribeAll(fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.handlers == nil {
+		b.handlers = make(map[string][]func(Event))
+	}
+	b.handlers[""] = append(b.handlers[""], fn)
+}
+
+// Publish calls the handlers subscribed to ev in the caller's goroutine, in
+// the order they subscribed. Handlers must return quickly and start their
+// own goroutines for slow work, such as HTTP calls. A handler that panics
+// is logged and does not stop the others.
+func (b *Bus) Publish(ev Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := append(append([]func(Event){}, b.handlers[""]...), b.handlers[ev.EventName()]...)
+	b.mu.RUnlock()
+	for _, fn := range handlers {
+		dispatch(fn, ev)
+	}
+}
+
+func dispatch(fn func(Event), ev Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("event handler panicked", "event", ev.EventName(), "panic", r)
+		}
+	}()
+	fn(ev)
+}
@@ -0,0 +1,38 @@
+package events
+
+import "testing"
+
+func TestBus_DeliversByType(t *testing.T) {
+	bus := New()
+	var deployed []TeamDeployed
+	var all []string
+	Subscribe(bus, func(ev TeamDeployed) { deployed = append(deployed, ev) })
+	bus.SubscribeAll(func(ev Event) { all = append(all, ev.EventName()) })
+
+	bus.Publish(TeamDeployed{TeamID: "t1", Status: "running"})
+	bus.Publish(RunCompleted{RunID: "r1"})
+
+	if len(deployed) != 1 || deployed[0].TeamID != "t1" {
+		t.Errorf("TeamDeployed handler: got %+v", deployed)
+	}
+	if len(all) != 2 || all[0] != NameTeamDeployed || all[1] != NameRunCompleted {
+		t.Errorf("SubscribeAll handler: got %v", all)
+	}
+}
+
+func TestBus_PanickingHandler(t *testing.T) {
+	bus := New()
+	called := false
+	Subscribe(bus, func(RunCompleted) { panic("boom") })
+	Subscribe(bus, func(RunCompleted) { called = true })
+
+	bus.Publish(RunCompleted{})
+	if !called {
+		t.Error("handler after a panicking one was not called")
+	}
+}
+
+func TestBus_Nil(t *testing.T) {
+	var bus *Bus
+	bus.Publish(PermissionDenied{Tool: "Bash"})
+}
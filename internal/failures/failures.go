@@ -0,0 +1,393 @@
+// Package failures turns agent errors and deployment failures into
+// localized, user-readable messages with remediation steps. Agents report
+// machine-readable error codes (such as billing_error) and deployments
+// free-text status messages; both are classified into a failure code whose
+// message is looked up in the requested locale.
+package failures
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Supported locales. Messages missing in a locale fall back to English.
+const (
+	LocaleEnglish = "en"
+	LocaleSpanish = "es"
+
+	DefaultLocale = LocaleEnglish
+)
+
+// Locales lists the supported locales.
+var Locales = []string{LocaleEnglish, LocaleSpanish}
+
+// Failure codes of agent errors.
+const (
+	AgentBilling        = "agent_billing"
+	AgentAuthentication = "agent_authentication"
+	AgentRateLimit      = "agent_rate_limit"
+	AgentOverloaded     = "agent_overloaded"
+	AgentModelNotFound  = "agent_model_not_found"
+	AgentProviderError  = "agent_provider_error"
+	AgentFailed         = "agent_failed"
+)
+
+// Failure codes of deployment failures.
+const (
+	DeployImagePull          = "deploy_image_pull"
+	DeployRuntimeUnavailable = "deploy_runtime_unavailable"
+	DeployPortInUse          = "deploy_port_in_use"
+	DeployResources          = "deploy_resources"
+	DeployWorkspace          = "deploy_workspace"
+	DeployKnowledge          = "deploy_knowledge"
+	DeployOllama             = "deploy_ollama"
+	DeployInfra              = "deploy_infra"
+	DeployTimeout            = "deploy_timeout"
+	DeployInterrupted        = "deploy_interrupted"
+	DeployLeaderGone         = "deploy_leader_gone"
+	DeployFailed             = "deploy_failed"
+)
+
+// Failure is a localized, user-readable failure. Detail is the original,
+// untranslated error.
+type Failure struct {
+	Code        string   `json:"code"`
+	Locale      string   `json:"locale"`
+	Message     string   `json:"message"`
+	Remediation []string `json:"remediation,omitempty"`
+	Detail      string   `json:"detail,omitempty"`
+}
+
+// text is the message and remediation steps of a failure in one locale.
+type text struct {
+	message     string
+	remediation []string
+}
+
+// catalog holds the text of each failure code by locale.
+var catalog = map[string]map[string]text{
+	AgentBilling: {
+		LocaleEnglish: {"The AI provider rejected the request because the API key has insufficient credits.", []string{
+			"Add credits to the provider account.",
+			"Or set another API key in Settings and send the message again.",
+		}},
+		LocaleSpanish: {"El proveedor de IA rechazó la petición porque la clave de API no tiene crédito suficiente.", []string{
+			"Añade crédito a la cuenta del proveedor.",
+			"O configura otra clave de API en Ajustes y vuelve a enviar el mensaje.",
+		}},
+	},
+	AgentAuthentication: {
+		LocaleEnglish: {"The AI provider rejected the API key as invalid or expired.", []string{
+			"Update the API key in Settings.",
+			"Redeploy the team so the agents use the new key.",
+		}},
+		LocaleSpanish: {"El proveedor de IA rechazó la clave de API por no ser válida o haber caducado.", []string{
+			"Actualiza la clave de API en Ajustes.",
+			"Vuelve a desplegar el equipo para que los agentes usen la nueva clave.",
+		}},
+	},
+	AgentRateLimit: {
+		LocaleEnglish: {"The AI provider's rate limit was reached.", []string{
+			"Wait a minute and send the message again.",
+			"Lower the team's max_concurrent_runs or the schedules' frequency if it keeps happening.",
+		}},
+		LocaleSpanish: {"Se alcanzó el límite de peticiones del proveedor de IA.", []string{
+			"Espera un minuto y vuelve a enviar el mensaje.",
+			"Reduce max_concurrent_runs del equipo o la frecuencia de las programaciones si se repite.",
+		}},
+	},
+	AgentOverloaded: {
+		LocaleEnglish: {"The AI provider is overloaded and could not answer.", []string{
+			"Send the message again in a few minutes.",
+		}},
+		LocaleSpanish: {"El proveedor de IA está saturado y no pudo responder.", []string{
+			"Vuelve a enviar el mensaje en unos minutos.",
+		}},
+	},
+	AgentModelNotFound: {
+		LocaleEnglish: {"The AI provider does not know the configured model.", []string{
+			"Check the agents' model names.",
+			"Check that the API key in Settings belongs to the team's provider.",
+		}},
+		LocaleSpanish: {"El proveedor de IA no reconoce el modelo configurado.", []string{
+			"Revisa los nombres de modelo de los agentes.",
+			"Comprueba que la clave de API de Ajustes corresponde al proveedor del equipo.",
+		}},
+	},
+	AgentProviderError: {
+		LocaleEnglish: {"The AI provider returned an error.", []string{
+			"Send the message again.",
+			"Check the API key in Settings if it keeps failing.",
+		}},
+		LocaleSpanish: {"El proveedor de IA devolvió un error.", []string{
+			"Vuelve a enviar el mensaje.",
+			"Revisa la clave de API en Ajustes si sigue fallando.",
+		}},
+	},
+	AgentFailed: {
+		LocaleEnglish: {"The agent could not complete the request.", []string{
+			"Check the team's activity for the agent's last steps.",
+			"Send the message again, or rephrase it.",
+		}},
+		LocaleSpanish: {"El agente no pudo completar la petición.", []string{
+			"Revisa la actividad del equipo para ver los últimos pasos del agente.",
+			"Vuelve a enviar el mensaje o reformúlalo.",
+		}},
+	},
+	DeployImagePull: {
+		LocaleEnglish: {"The agent image could not be pulled.", []string{
+			"Check the team's agent_image name and tag.",
+			"Check that the host or cluster can reach the registry and is logged in to it.",
+		}},
+		LocaleSpanish: {"No se pudo descargar la imagen del agente.", []string{
+			"Revisa el nombre y la etiqueta de agent_image del equipo.",
+			"Comprueba que el host o el clúster llega al registro y tiene sesión iniciada en él.",
+		}},
+	},
+	DeployRuntimeUnavailable: {
+		LocaleEnglish: {"The container runtime could not be reached.", []string{
+			"Check that Docker is running, or that the Kubernetes cluster is reachable.",
+			"Deploy the team again.",
+		}},
+		LocaleSpanish: {"No se pudo conectar con el entorno de contenedores.", []string{
+			"Comprueba que Docker está en marcha o que el clúster de Kubernetes es accesible.",
+			"Vuelve a desplegar el equipo.",
+		}},
+	},
+	DeployPortInUse: {
+		LocaleEnglish: {"A port the team needs is already in use on the host.", []string{
+			"Stop the process or container using the port.",
+			"Deploy the team again.",
+		}},
+		LocaleSpanish: {"Un puerto que necesita el equipo ya está en uso en el host.", []string{
+			"Detén el proceso o contenedor que usa el puerto.",
+			"Vuelve a desplegar el equipo.",
+		}},
+	},
+	DeployResources: {
+		LocaleEnglish: {"There are not enough resources to run the team.", []string{
+			"Pick a smaller resource preset for the team.",
+			"Or free capacity, or raise the namespace quota, and deploy again.",
+		}},
+		LocaleSpanish: {"No hay recursos suficientes para ejecutar el equipo.", []string{
+			"Elige un perfil de recursos más pequeño para el equipo.",
+			"O libera capacidad, o amplía la cuota del namespace, y vuelve a desplegar.",
+		}},
+	},
+	DeployWorkspace: {
+		LocaleEnglish: {"The team's workspace could not be prepared.", []string{
+			"Check that workspace_path exists and the API can write to it.",
+			"Check the team's config_dir_mode; shared mode needs a git repository.",
+		}},
+		LocaleSpanish: {"No se pudo preparar el espacio de trabajo del equipo.", []string{
+			"Comprueba que workspace_path existe y que la API puede escribir en él.",
+			"Revisa config_dir_mode del equipo; el modo shared necesita un repositorio git.",
+		}},
+	},
+	DeployKnowledge: {
+		LocaleEnglish: {"The team's knowledge base could not be started.", []string{
+			"Check the knowledge base documents and the API logs.",
+			"Deploy the team again, or remove its knowledge base.",
+		}},
+		LocaleSpanish: {"No se pudo iniciar la base de conocimiento del equipo.", []string{
+			"Revisa los documentos de la base de conocimiento y los logs de la API.",
+			"Vuelve a desplegar el equipo o elimina su base de conocimiento.",
+		}},
+	},
+	DeployOllama: {
+		LocaleEnglish: {"The team's Ollama model could not be started.", []string{
+			"Check the model name and that the host can download it.",
+			"Check that the host has enough memory for the model.",
+		}},
+		LocaleSpanish: {"No se pudo iniciar el modelo de Ollama del equipo.", []string{
+			"Revisa el nombre del modelo y que el host puede descargarlo.",
+			"Comprueba que el host tiene memoria suficiente para el modelo.",
+		}},
+	},
+	DeployInfra: {
+		LocaleEnglish: {"The team's network and messaging could not be set up.", []string{
+			"Check the runtime's logs for the underlying error.",
+			"Stop the team to clean up, then deploy it again.",
+		}},
+		LocaleSpanish: {"No se pudo preparar la red y la mensajería del equipo.", []string{
+			"Revisa los logs del entorno de ejecución para ver el error de fondo.",
+			"Detén el equipo para limpiar y vuelve a desplegarlo.",
+		}},
+	},
+	DeployTimeout: {
+		LocaleEnglish: {"The deployment took too long and was stopped.", []string{
+			"Deploy the team again; image pulls are faster the second time.",
+			"Check the runtime's load if it keeps timing out.",
+		}},
+		LocaleSpanish: {"El despliegue tardó demasiado y se detuvo.", []string{
+			"Vuelve a desplegar el equipo; la descarga de imágenes es más rápida la segunda vez.",
+			"Revisa la carga del entorno de ejecución si se repite.",
+		}},
+	},
+	DeployInterrupted: {
+		LocaleEnglish: {"The deployment was interrupted by an API restart.", []string{
+			"Stop the team, then deploy it again.",
+		}},
+		LocaleSpanish: {"Un reinicio de la API interrumpió el despliegue.", []string{
+			"Detén el equipo y vuelve a desplegarlo.",
+		}},
+	},
+	DeployLeaderGone: {
+		LocaleEnglish: {"The leader's container no longer exists.", []string{
+			"Stop the team, then deploy it again.",
+		}},
+		LocaleSpanish: {"El contenedor del líder ya no existe.", []string{
+			"Detén el equipo y vuelve a desplegarlo.",
+		}},
+	},
+	DeployFailed: {
+		LocaleEnglish: {"The team could not be deployed.", []string{
+			"Check the error details and the API logs.",
+			"Stop the team to clean up, then deploy it again.",
+		}},
+		LocaleSpanish: {"No se pudo desplegar el equipo.", []string{
+			"Revisa el detalle del error y los logs de la API.",
+			"Detén el equipo para limpiar y vuelve a desplegarlo.",
+		}},
+	},
+}
+
+// Lookup returns the failure code's text in locale, with detail attached.
+// Unknown codes are returned as the generic failure of their kind.
+func Lookup(code, locale, detail string) *Failure {
+	texts, ok := catalog[code]
+	if !ok {
+		if strings.HasPrefix(code, "deploy_") {
+			code = DeployFailed
+		} else {
+			code = AgentFailed
+		}
+		texts = catalog[code]
+	}
+	locale = normalize(locale)
+	t, ok := texts[locale]
+	if !ok {
+		locale = DefaultLocale
+		t = texts[locale]
+	}
+	return &Failure{
+		Code:        code,
+		Locale:      locale,
+		Message:     t.message,
+		Remediation: t.remediation,
+		Detail:      detail,
+	}
+}
+
+// AgentCode classifies an agent's error code, as reported in a leader
+// response, and its error message.
+func AgentCode(errorCode, message string) string {
+	if strings.Contains(strings.ToLower(message), "model not found") {
+		return AgentModelNotFound
+	}
+	switch errorCode {
+	case "billing_error":
+		return AgentBilling
+	case "authentication_error", "permission_error":
+		return AgentAuthentication
+	case "rate_limit", "rate_limit_error":
+		return AgentRateLimit
+	case "overloaded_error":
+		return AgentOverloaded
+	case "not_found_error":
+		return AgentModelNotFound
+	case "APIError", "api_error":
+		return AgentProviderError
+	}
+	return AgentFailed
+}
+
+// deployPatterns map substrings of deployment status messages, matched
+// case-insensitively in order, to failure codes.
+var deployPatterns = []struct {
+	substr string
+	code   string
+}{
+	{"interrupted by an api restart", DeployInterrupted},
+	{"leader container no longer exists", DeployLeaderGone},
+	{"ollama", DeployOllama},
+	{"qdrant", DeployKnowledge},
+	{"rag mcp", DeployKnowledge},
+	{"knowledge base", DeployKnowledge},
+	{"workspace", DeployWorkspace},
+	{"pull access denied", DeployImagePull},
+	{"manifest unknown", DeployImagePull},
+	{"errimagepull", DeployImagePull},
+	{"imagepullbackoff", DeployImagePull},
+	{"pulling image", DeployImagePull},
+	{"no such image", DeployImagePull},
+	{"cannot connect to the docker daemon", DeployRuntimeUnavailable},
+	{"is the docker daemon running", DeployRuntimeUnavailable},
+	{"connection refused", DeployRuntimeUnavailable},
+	{"address already in use", DeployPortInUse},
+	{"port is already allocated", DeployPortInUse},
+	{"exceeded quota", DeployResources},
+	{"insufficient", DeployResources},
+	{"out of memory", DeployResources},
+	{"deadline exceeded", DeployTimeout},
+	{"timed out", DeployTimeout},
+	{"failed to deploy infrastructure", DeployInfra},
+}
+
+// DeployCode classifies a deployment failure by its status message.
+func DeployCode(message string) string {
+	lower := strings.ToLower(message)
+	for _, p := range deployPatterns {
+		if strings.Contains(lower, p.substr) {
+			return p.code
+		}
+	}
+	return DeployFailed
+}
+
+// Agent returns the localized failure of an agent error.
+func Agent(errorCode, message, locale string) *Failure {
+	return Lookup(AgentCode(errorCode, message), locale, message)
+}
+
+// Deploy returns the localized failure of a deployment status message.
+func Deploy(message, locale string) *Failure {
+	return Lookup(DeployCode(message), locale, message)
+}
+
+// Negotiate picks a supported locale: locale if set and supported,
+// otherwise the preferred supported language of an Accept-Language header,
+// otherwise DefaultLocale.
+func Negotiate(locale, acceptLanguage string) string {
+	if l := normalize(locale); supported(l) {
+		return l
+	}
+	best, bestQ := DefaultLocale, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if l := normalize(tag); supported(l) && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+	return best
+}
+
+// normalize reduces a language tag such as es-ES to its language.
+func normalize(tag string) string {
+	lang, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	lang, _, _ = strings.Cut(lang, "_")
+	return strings.ToLower(lang)
+}
+
+func supported(locale string) bool {
+	return slices.Contains(Locales, locale)
+}
@@ -0,0 +1,93 @@
+package failures
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		locale, acceptLanguage, want string
+	}{
+		{"", "", "en"},
+		{"es", "en-US", "es"},
+		{"ES-mx", "", "es"},
+		{"fr", "es-ES,es;q=0.9,en;q=0.8", "es"},
+		{"", "fr-FR, en;q=0.5, es;q=0.7", "es"},
+		{"", "de, fr", "en"},
+		{"", "es;q=bad, en;q=0.1", "en"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.locale, tt.acceptLanguage); got != tt.want {
+			t.Errorf("Negotiate(%q, %q): got %q, want %q", tt.locale, tt.acceptLanguage, got, tt.want)
+		}
+	}
+}
+
+func TestAgentCode(t *testing.T) {
+	tests := []struct {
+		errorCode, message, want string
+	}{
+		{"billing_error", "", AgentBilling},
+		{"authentication_error", "", AgentAuthentication},
+		{"rate_limit", "", AgentRateLimit},
+		{"rate_limit_error", "", AgentRateLimit},
+		{"overloaded_error", "", AgentOverloaded},
+		{"APIError", "Model not found: claude-x", AgentModelNotFound},
+		{"APIError", "boom", AgentProviderError},
+		{"", "the tool crashed", AgentFailed},
+	}
+	for _, tt := range tests {
+		if got := AgentCode(tt.errorCode, tt.message); got != tt.want {
+			t.Errorf("AgentCode(%q, %q): got %q, want %q", tt.errorCode, tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestDeployCode(t *testing.T) {
+	tests := []struct {
+		message, want string
+	}{
+		{"Error response from daemon: pull access denied for ghcr.io/x/agent", DeployImagePull},
+		{"Back-off pulling image: ImagePullBackOff", DeployImagePull},
+		{"Failed to deploy infrastructure: Cannot connect to the Docker daemon at unix:///var/run/docker.sock", DeployRuntimeUnavailable},
+		{"Failed to deploy infrastructure: network create failed", DeployInfra},
+		{"Bind for 0.0.0.0:4222 failed: port is already allocated", DeployPortInUse},
+		{`pods "leader" is forbidden: exceeded quota: team-quota`, DeployResources},
+		{"Failed to prepare shared workspace: not a git repository", DeployWorkspace},
+		{"Failed to pull Ollama model llama3: timed out", DeployOllama},
+		{"Failed to start Qdrant: boom", DeployKnowledge},
+		{"Deployment interrupted by an API restart; stop or redeploy the team", DeployInterrupted},
+		{"Leader container no longer exists; stop or redeploy the team", DeployLeaderGone},
+		{"context deadline exceeded", DeployTimeout},
+		{"something else", DeployFailed},
+	}
+	for _, tt := range tests {
+		if got := DeployCode(tt.message); got != tt.want {
+			t.Errorf("DeployCode(%q): got %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	f := Agent("billing_error", "insufficient credits", "es")
+	if f.Code != AgentBilling || f.Locale != "es" || f.Detail != "insufficient credits" || len(f.Remediation) == 0 {
+		t.Errorf("Agent: got %+v", f)
+	}
+	if f := Lookup(AgentBilling, "fr", ""); f.Locale != DefaultLocale || f.Message == "" {
+		t.Errorf("unsupported locale: got %+v", f)
+	}
+	if f := Lookup("deploy_unknown", "en", ""); f.Code != DeployFailed {
+		t.Errorf("unknown deploy code: got %+v", f)
+	}
+	if f := Lookup("unknown", "en", ""); f.Code != AgentFailed {
+		t.Errorf("unknown code: got %+v", f)
+	}
+}
+
+func TestCatalogIsComplete(t *testing.T) {
+	for code, texts := range catalog {
+		for _, locale := range Locales {
+			if text, ok := texts[locale]; !ok || text.message == "" || len(text.remediation) == 0 {
+				t.Errorf("%s has no %s text", code, locale)
+			}
+		}
+	}
+}
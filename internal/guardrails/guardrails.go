@@ -0,0 +1,130 @@
+// Package guardrails checks chat messages and leader responses against an
+// organization's content policies: deny lists, regular expressions and,
+// optionally, a moderation model.
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Directions a content policy applies to.
+const (
+	DirectionInput  = "input"  // user messages, before they are published
+	DirectionOutput = "output" // leader responses, before they are persisted
+	DirectionBoth   = "both"
+)
+
+// Kinds of rule a violation can come from.
+const (
+	KindDenyTerm   = "deny_term"
+	KindPattern    = "pattern"
+	KindModeration = "moderation"
+)
+
+// Limits on the rules of one policy.
+const (
+	MaxRules       = 200
+	maxRuleLength  = 1024
+	maxPatternSize = 512
+)
+
+// Rules are the checks of one content policy.
+type Rules struct {
+	// DenyTerms match case-insensitively anywhere in the text.
+	DenyTerms []string
+	// Patterns are Go regular expressions.
+	Patterns []string
+	// Moderation sends the text to the moderation model.
+	Moderation bool
+}
+
+// Violation is the rule a text broke.
+type Violation struct {
+	Kind string
+	// Rule is the deny term or pattern that matched, or the categories the
+	// moderation model flagged.
+	Rule string
+}
+
+// Moderator classifies text with a moderation model.
+type Moderator interface {
+	// Moderate returns the categories the text is flagged for, if any.
+	Moderate(ctx context.Context, text string) ([]string, error)
+}
+
+// ValidDirection reports whether d is a known direction.
+func ValidDirection(d string) bool {
+	return d == DirectionInput || d == DirectionOutput || d == DirectionBoth
+}
+
+// Applies reports whether a policy for policyDirection checks text going
+// in direction.
+func Applies(policyDirection, direction string) bool {
+	return policyDirection == DirectionBoth || policyDirection == direction
+}
+
+// Validate checks that the rules are well formed and not empty.
+func (r Rules) Validate() error {
+	if len(r.DenyTerms) == 0 && len(r.Patterns) == 0 && !r.Moderation {
+		return errors.New("a policy needs deny_terms, patterns or moderation")
+	}
+	if len(r.DenyTerms)+len(r.Patterns) > MaxRules {
+		return fmt.Errorf("a policy can have at most %d deny terms and patterns", MaxRules)
+	}
+	for _, term := range r.DenyTerms {
+		if strings.TrimSpace(term) == "" {
+			return errors.New("deny_terms cannot contain empty terms")
+		}
+		if len(term) > maxRuleLength {
+			return fmt.Errorf("deny term %q exceeds %d characters", term[:32], maxRuleLength)
+		}
+	}
+	for _, p := range r.Patterns {
+		if len(p) > maxPatternSize {
+			return fmt.Errorf("pattern exceeds %d characters", maxPatternSize)
+		}
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// Check returns the first rule text breaks, or nil. Deny terms and patterns
+// are checked before the moderation model, which is only called when they
+// all pass. A moderator error is returned as is; without a moderator,
+// moderation rules fail.
+func (r Rules) Check(ctx context.Context, text string, moderator Moderator) (*Violation, error) {
+	lower := strings.ToLower(text)
+	for _, term := range r.DenyTerms {
+		if strings.Contains(lower, strings.ToLower(term)) {
+			return &Violation{Kind: KindDenyTerm, Rule: term}, nil
+		}
+	}
+	for _, p := range r.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %q: %w", p, err)
+		}
+		if re.MatchString(text) {
+			return &Violation{Kind: KindPattern, Rule: p}, nil
+		}
+	}
+	if r.Moderation {
+		if moderator == nil {
+			return nil, errors.New("no moderation model is configured")
+		}
+		categories, err := moderator.Moderate(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		if len(categories) > 0 {
+			return &Violation{Kind: KindModeration, Rule: strings.Join(categories, ", ")}, nil
+		}
+	}
+	return nil, nil
+}
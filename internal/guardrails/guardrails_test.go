@@ -0,0 +1,116 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type stubModerator struct {
+	categories []string
+	err        error
+	calls      int
+}
+
+func (s *stubModerator) Moderate(context.Context, string) ([]string, error) {
+	s.calls++
+	return s.categories, s.err
+}
+
+func TestRules_Validate(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules Rules
+		ok    bool
+	}{
+		{"empty", Rules{}, false},
+		{"deny terms", Rules{DenyTerms: []string{"secret"}}, true},
+		{"blank term", Rules{DenyTerms: []string{" "}}, false},
+		{"pattern", Rules{Patterns: []string{`\d{3}-\d{2}-\d{4}`}}, true},
+		{"bad pattern", Rules{Patterns: []string{"("}}, false},
+		{"moderation only", Rules{Moderation: true}, true},
+	}
+	for _, tc := range cases {
+		if err := tc.rules.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: got error %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestRules_Check(t *testing.T) {
+	ctx := context.Background()
+	rules := Rules{DenyTerms: []string{"Project Falcon"}, Patterns: []string{`\b\d{3}-\d{2}-\d{4}\b`}}
+
+	v, err := rules.Check(ctx, "status of project falcon?", nil)
+	if err != nil || v == nil || v.Kind != KindDenyTerm || v.Rule != "Project Falcon" {
+		t.Errorf("deny term: got %+v, %v", v, err)
+	}
+	v, err = rules.Check(ctx, "SSN is 123-45-6789", nil)
+	if err != nil || v == nil || v.Kind != KindPattern {
+		t.Errorf("pattern: got %+v, %v", v, err)
+	}
+	if v, err := rules.Check(ctx, "deploy the API", nil); err != nil || v != nil {
+		t.Errorf("clean text: got %+v, %v", v, err)
+	}
+
+	mod := &stubModerator{categories: []string{"harassment", "violence"}}
+	rules.Moderation = true
+	v, err = rules.Check(ctx, "something hostile", mod)
+	if err != nil || v == nil || v.Kind != KindModeration || v.Rule != "harassment, violence" {
+		t.Errorf("moderation: got %+v, %v", v, err)
+	}
+	// Local rules short-circuit the moderation call.
+	if _, _ = rules.Check(ctx, "project falcon", mod); mod.calls != 1 {
+		t.Errorf("moderator calls: got %d, want 1", mod.calls)
+	}
+	if _, err := rules.Check(ctx, "text", &stubModerator{err: errors.New("down")}); err == nil {
+		t.Error("expected the moderator error")
+	}
+	if _, err := rules.Check(ctx, "text", nil); err == nil {
+		t.Error("expected an error without a moderator")
+	}
+}
+
+func TestApplies(t *testing.T) {
+	if !Applies(DirectionBoth, DirectionInput) || !Applies(DirectionOutput, DirectionOutput) || Applies(DirectionInput, DirectionOutput) {
+		t.Error("Applies: unexpected result")
+	}
+}
+
+func TestOpenAIModerator(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		var req moderationRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		flagged := req.Input == "bad"
+		json.NewEncoder(w).Encode(map[string]any{"results": []map[string]any{{
+			"flagged":    flagged,
+			"categories": map[string]bool{"violence": flagged, "hate": false, "self-harm": flagged},
+		}}})
+	}))
+	defer srv.Close()
+
+	m := NewOpenAIModerator("sk-test")
+	m.BaseURL = srv.URL
+	got, err := m.Moderate(context.Background(), "bad")
+	if err != nil {
+		t.Fatalf("Moderate: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"self-harm", "violence"}) {
+		t.Errorf("categories: got %v", got)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("Authorization: got %q", gotAuth)
+	}
+	if got, err := m.Moderate(context.Background(), "fine"); err != nil || len(got) != 0 {
+		t.Errorf("clean text: got %v, %v", got, err)
+	}
+	if _, err := NewOpenAIModerator("").Moderate(context.Background(), "x"); err == nil {
+		t.Error("expected an error without an API key")
+	}
+}
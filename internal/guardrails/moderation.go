@@ -0,0 +1,91 @@
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIBaseURL is the API the OpenAI moderator calls by default.
+const DefaultOpenAIBaseURL = "https://api.openai.com"
+
+// OpenAIModerator classifies text with the OpenAI moderation endpoint.
+type OpenAIModerator struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOpenAIModerator returns a moderator that authenticates with apiKey.
+func NewOpenAIModerator(apiKey string) *OpenAIModerator {
+	return &OpenAIModerator{
+		APIKey:  apiKey,
+		BaseURL: DefaultOpenAIBaseURL,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type moderationRequest struct {
+	Input string `json:"input"`
+}
+
+type moderationResponse struct {
+	Results []struct {
+		Flagged    bool            `json:"flagged"`
+		Categories map[string]bool `json:"categories"`
+	} `json:"results"`
+}
+
+// Moderate implements Moderator.
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) ([]string, error) {
+	if m.APIKey == "" {
+		return nil, fmt.Errorf("moderation requires an OPENAI_API_KEY setting")
+	}
+	body, err := json.Marshal(moderationRequest{Input: text})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(m.BaseURL, "/")+"/v1/moderations", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+m.APIKey)
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("moderation request: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result moderationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding moderation response: %w", err)
+	}
+	var categories []string
+	for _, r := range result.Results {
+		if !r.Flagged {
+			continue
+		}
+		for name, flagged := range r.Categories {
+			if flagged {
+				categories = append(categories, name)
+			}
+		}
+		if len(categories) == 0 {
+			categories = append(categories, "flagged")
+		}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
@@ -0,0 +1,201 @@
+// Package alerting opens and resolves PagerDuty/Opsgenie incidents for
+// unhealthy teams, and notifies them of pending approval requests.
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/helmcode/agent-crew/internal/integrations"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Default provider API endpoints.
+const (
+	PagerDutyDefaultURL = "https://events.pagerduty.com"
+	OpsgenieDefaultURL  = "https://api.opsgenie.com"
+)
+
+// maxErrorBody limits how much of a failed provider response is kept in errors.
+const maxErrorBody = 512
+
+// Alert is the provider-neutral content of an incident.
+type Alert struct {
+	DedupKey string
+	Summary  string
+	Source   string
+	Severity string
+	Details  map[string]string
+}
+
+// Alerter opens and resolves incidents identified by a deduplication key.
+// Triggering the same key twice updates the existing incident.
+type Alerter interface {
+	Trigger(ctx context.Context, alert Alert) error
+	Resolve(ctx context.Context, dedupKey, note string) error
+}
+
+// DedupKey returns the deduplication key used for a team's incidents.
+func DedupKey(teamID string) string {
+	return "agentcrew-team-" + teamID
+}
+
+// NewAlerter builds the Alerter for an integration, decrypting its stored
+// credentials.
+func NewAlerter(integration models.AlertIntegration, client *http.Client) (Alerter, error) {
+	creds, err := integrations.DecryptCredentials(integration.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	switch integration.Provider {
+	case models.AlertProviderPagerDuty:
+		if creds["routing_key"] == "" {
+			return nil, fmt.Errorf("pagerduty integration requires 'routing_key' credential")
+		}
+		base := integration.BaseURL
+		if base == "" {
+			base = PagerDutyDefaultURL
+		}
+		return &PagerDuty{BaseURL: base, RoutingKey: creds["routing_key"], Client: client}, nil
+
+	case models.AlertProviderOpsgenie:
+		if creds["api_key"] == "" {
+			return nil, fmt.Errorf("opsgenie integration requires 'api_key' credential")
+		}
+		base := integration.BaseURL
+		if base == "" {
+			base = OpsgenieDefaultURL
+		}
+		return &Opsgenie{BaseURL: base, APIKey: creds["api_key"], Client: client}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported alert provider: %q", integration.Provider)
+	}
+}
+
+// PagerDuty sends events to the PagerDuty Events API v2.
+type PagerDuty struct {
+	BaseURL    string
+	RoutingKey string
+	Client     *http.Client
+}
+
+// Trigger opens or updates a PagerDuty incident.
+func (p *PagerDuty) Trigger(ctx context.Context, alert Alert) error {
+	severity := alert.Severity
+	if severity == "" {
+		severity = models.AlertSeverityCritical
+	}
+	return p.enqueue(ctx, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.DedupKey,
+		"payload": map[string]interface{}{
+			"summary":        alert.Summary,
+			"source":         alert.Source,
+			"severity":       severity,
+			"component":      "agentcrew",
+			"custom_details": alert.Details,
+		},
+	})
+}
+
+// Resolve resolves the PagerDuty incident for dedupKey.
+func (p *PagerDuty) Resolve(ctx context.Context, dedupKey, _ string) error {
+	return p.enqueue(ctx, map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    dedupKey,
+	})
+}
+
+func (p *PagerDuty) enqueue(ctx context.Context, event map[string]interface{}) error {
+	if err := postJSON(ctx, p.Client, strings.TrimRight(p.BaseURL, "/")+"/v2/enqueue", nil, event); err != nil {
+		return fmt.Errorf("pagerduty %s: %w", event["event_action"], err)
+	}
+	return nil
+}
+
+// Opsgenie sends alerts to the Opsgenie Alert API v2.
+type Opsgenie struct {
+	BaseURL string
+	APIKey  string
+	Client  *http.Client
+}
+
+// opsgeniePriority maps alert severities to Opsgenie priorities.
+var opsgeniePriority = map[string]string{
+	models.AlertSeverityCritical: "P1",
+	models.AlertSeverityError:    "P2",
+	models.AlertSeverityWarning:  "P3",
+	models.AlertSeverityInfo:     "P5",
+}
+
+// Trigger creates an Opsgenie alert; Opsgenie deduplicates open alerts by alias.
+func (o *Opsgenie) Trigger(ctx context.Context, alert Alert) error {
+	priority := opsgeniePriority[alert.Severity]
+	if priority == "" {
+		priority = "P1"
+	}
+	body := map[string]interface{}{
+		"message":  integrations.Truncate(alert.Summary, 130, ""),
+		"alias":    alert.DedupKey,
+		"source":   alert.Source,
+		"priority": priority,
+		"details":  alert.Details,
+		"tags":     []string{"agentcrew"},
+	}
+	if err := postJSON(ctx, o.Client, strings.TrimRight(o.BaseURL, "/")+"/v2/alerts", o.headers(), body); err != nil {
+		return fmt.Errorf("opsgenie create alert: %w", err)
+	}
+	return nil
+}
+
+// Resolve closes the Opsgenie alert whose alias is dedupKey.
+func (o *Opsgenie) Resolve(ctx context.Context, dedupKey, note string) error {
+	endpoint := strings.TrimRight(o.BaseURL, "/") + "/v2/alerts/" + url.PathEscape(dedupKey) + "/close?identifierType=alias"
+	body := map[string]interface{}{"source": "agentcrew", "note": note}
+	if err := postJSON(ctx, o.Client, endpoint, o.headers(), body); err != nil {
+		return fmt.Errorf("opsgenie close alert: %w", err)
+	}
+	return nil
+}
+
+func (o *Opsgenie) headers() map[string]string {
+	return map[string]string{"Authorization": "GenieKey " + o.APIKey}
+}
+
+// postJSON sends body as JSON and treats any non-2xx response as an error.
+func postJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
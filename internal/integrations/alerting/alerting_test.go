@@ -0,0 +1,293 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Team{}, &models.Agent{}, &models.AlertIntegration{}, &models.TeamAlert{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+// fakePagerDuty records the event actions it receives.
+func fakePagerDuty(t *testing.T) (*httptest.Server, func() []map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	var events []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/enqueue" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var ev map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]interface{}(nil), events...)
+	}
+}
+
+// fakeStatus reports a fixed container status for every container.
+type fakeStatus struct {
+	mu     sync.Mutex
+	status string
+}
+
+func (f *fakeStatus) set(status string) {
+	f.mu.Lock()
+	f.status = status
+	f.mu.Unlock()
+}
+
+func (f *fakeStatus) GetStatus(_ context.Context, id string) (*runtime.AgentStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &runtime.AgentStatus{ID: id, Status: f.status}, nil
+}
+
+func setupMonitor(t *testing.T) (*Monitor, *gorm.DB, models.Team, *fakeStatus, func() []map[string]interface{}) {
+	t.Helper()
+	db := setupTestDB(t)
+	srv, events := fakePagerDuty(t)
+
+	team := models.Team{ID: "team-1", OrgID: "org-1", Name: "ops", Status: models.TeamStatusRunning}
+	db.Create(&team)
+	db.Create(&models.Agent{ID: "agent-1", TeamID: team.ID, Name: "leader", Role: models.AgentRoleLeader, ContainerID: "c-1"})
+	db.Create(&models.AlertIntegration{
+		ID:                      "alert-1",
+		OrgID:                   "org-1",
+		Name:                    "pd",
+		Provider:                models.AlertProviderPagerDuty,
+		BaseURL:                 srv.URL,
+		Credentials:             models.JSON(`{"routing_key":"rk"}`),
+		Severity:                models.AlertSeverityCritical,
+		AlertOnError:            true,
+		DeployFailureThreshold:  3,
+		HeartbeatTimeoutSeconds: 60,
+		Enabled:                 true,
+	})
+
+	status := &fakeStatus{status: "running"}
+	m := NewMonitor(db, status, time.Minute)
+	m.Client = srv.Client()
+	return m, db, team, status, events
+}
+
+func openAlerts(t *testing.T, db *gorm.DB) []models.TeamAlert {
+	t.Helper()
+	var alerts []models.TeamAlert
+	db.Where("status = ?", models.AlertStatusOpen).Find(&alerts)
+	return alerts
+}
+
+func TestMonitor_TeamErrorOpensAndRecoveryResolves(t *testing.T) {
+	m, db, team, _, events := setupMonitor(t)
+
+	db.Model(&team).Updates(map[string]interface{}{"status": models.TeamStatusError, "status_message": "boom"})
+	m.Check(t.Context())
+	m.Check(t.Context()) // deduplicated: no second trigger
+
+	alerts := openAlerts(t, db)
+	if len(alerts) != 1 || alerts[0].Reason != models.AlertReasonTeamError {
+		t.Fatalf("open alerts: got %+v", alerts)
+	}
+	if evs := events(); len(evs) != 1 || evs[0]["event_action"] != "trigger" || evs[0]["dedup_key"] != DedupKey(team.ID) {
+		t.Fatalf("events after error: got %+v", evs)
+	}
+
+	db.Model(&team).Update("status", models.TeamStatusRunning)
+	m.Check(t.Context())
+
+	if alerts := openAlerts(t, db); len(alerts) != 0 {
+		t.Errorf("expected alert to be resolved, got %+v", alerts)
+	}
+	evs := events()
+	if len(evs) != 2 || evs[1]["event_action"] != "resolve" {
+		t.Errorf("events after recovery: got %+v", evs)
+	}
+}
+
+func TestMonitor_HeartbeatTimeout(t *testing.T) {
+	m, db, _, status, events := setupMonitor(t)
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.Check(t.Context())
+
+	status.set("stopped")
+	now = now.Add(30 * time.Second)
+	m.Check(t.Context())
+	if len(events()) != 0 {
+		t.Fatal("expected no alert before heartbeat timeout")
+	}
+
+	now = now.Add(45 * time.Second)
+	m.Check(t.Context())
+	alerts := openAlerts(t, db)
+	if len(alerts) != 1 || alerts[0].Reason != models.AlertReasonHeartbeat {
+		t.Fatalf("open alerts: got %+v", alerts)
+	}
+
+	status.set("running")
+	now = now.Add(time.Second)
+	m.Check(t.Context())
+	if alerts := openAlerts(t, db); len(alerts) != 0 {
+		t.Errorf("expected heartbeat alert to be resolved, got %+v", alerts)
+	}
+}
+
+func TestMonitor_IgnoresOtherOrgs(t *testing.T) {
+	m, db, _, _, events := setupMonitor(t)
+	db.Create(&models.Team{ID: "team-2", OrgID: "org-2", Name: "other", Status: models.TeamStatusError})
+
+	m.Check(t.Context())
+	if len(events()) != 0 {
+		t.Errorf("expected no alerts for teams in another org, got %+v", events())
+	}
+}
+
+func TestMonitor_ResolveAll(t *testing.T) {
+	m, db, team, _, events := setupMonitor(t)
+	db.Model(&team).Update("status", models.TeamStatusError)
+	m.Check(t.Context())
+
+	var integration models.AlertIntegration
+	db.First(&integration, "id = ?", "alert-1")
+	if err := m.ResolveAll(t.Context(), integration); err != nil {
+		t.Fatalf("ResolveAll: %v", err)
+	}
+	if alerts := openAlerts(t, db); len(alerts) != 0 {
+		t.Errorf("expected all alerts resolved, got %+v", alerts)
+	}
+	if evs := events(); len(evs) != 2 {
+		t.Errorf("events: got %d, want 2", len(evs))
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	integration := models.AlertIntegration{AlertOnError: true, DeployFailureThreshold: 3, HeartbeatTimeoutSeconds: 60}
+
+	tests := []struct {
+		name      string
+		team      models.Team
+		silentFor time.Duration
+		want      string
+	}{
+		{"healthy", models.Team{Status: models.TeamStatusRunning}, 0, ""},
+		{"error", models.Team{Status: models.TeamStatusError, DeployFailures: 1}, 0, models.AlertReasonTeamError},
+		{"repeated deploy failures", models.Team{Status: models.TeamStatusError, DeployFailures: 3}, 0, models.AlertReasonDeployFailure},
+		{"stopped with failures", models.Team{Status: models.TeamStatusStopped, DeployFailures: 5}, 0, ""},
+		{"heartbeat missing", models.Team{Status: models.TeamStatusRunning}, 2 * time.Minute, models.AlertReasonHeartbeat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := Evaluate(integration, tt.team, tt.silentFor); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	integration.AlertOnError = false
+	if got, _ := Evaluate(integration, models.Team{Status: models.TeamStatusError, DeployFailures: 1}, 0); got != "" {
+		t.Errorf("alert_on_error disabled: got %q, want no alert", got)
+	}
+}
+
+func TestOpsgenie_TriggerAndResolve(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey og" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.RequestURI())
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	alerter, err := NewAlerter(models.AlertIntegration{
+		Provider:    models.AlertProviderOpsgenie,
+		BaseURL:     srv.URL,
+		Credentials: models.JSON(`{"api_key":"og"}`),
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewAlerter: %v", err)
+	}
+	if err := alerter.Trigger(t.Context(), Alert{DedupKey: "k1", Summary: "down", Severity: "warning"}); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+	if err := alerter.Resolve(t.Context(), "k1", "ok"); err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if len(paths) != 2 || paths[0] != "/v2/alerts" || paths[1] != "/v2/alerts/k1/close?identifierType=alias" {
+		t.Errorf("paths: got %v", paths)
+	}
+}
+
+func TestNewAlerter_MissingCredentials(t *testing.T) {
+	if _, err := NewAlerter(models.AlertIntegration{Provider: models.AlertProviderPagerDuty}, http.DefaultClient); err == nil {
+		t.Error("expected error for missing routing_key")
+	}
+	if _, err := NewAlerter(models.AlertIntegration{Provider: "slack"}, http.DefaultClient); err == nil {
+		t.Error("expected error for unsupported provider")
+	}
+}
+
+func TestMonitor_ApprovalNotifiedAndResolved(t *testing.T) {
+	m, db, team, _, events := setupMonitor(t)
+	db.Create(&models.AlertIntegration{
+		ID: "alert-other", OrgID: "org-1", Name: "other team", TeamID: "team-2",
+		Provider: models.AlertProviderPagerDuty, Credentials: models.JSON(`{"routing_key":"rk"}`), Enabled: true,
+	})
+	approval := models.Approval{
+		ID: "approval-1", OrgID: team.OrgID, TeamID: team.ID, Action: models.ApprovalActionDeploy,
+		Status: models.ApprovalStatusPending, RequestedByEmail: "dev@example.com",
+	}
+
+	m.NotifyApprovalRequested(t.Context(), approval, team.Name)
+	evs := events()
+	if len(evs) != 1 || evs[0]["event_action"] != "trigger" || evs[0]["dedup_key"] != ApprovalDedupKey(approval.ID) {
+		t.Fatalf("events after request: got %+v", evs)
+	}
+	payload, _ := evs[0]["payload"].(map[string]interface{})
+	if payload["severity"] != models.AlertSeverityInfo {
+		t.Errorf("severity: got %v, want %s", payload["severity"], models.AlertSeverityInfo)
+	}
+	if alerts := openAlerts(t, db); len(alerts) != 0 {
+		t.Errorf("approval notifications must not open team alerts: got %+v", alerts)
+	}
+
+	m.ResolveApproval(t.Context(), approval, "Approved by admin@example.com")
+	if evs := events(); len(evs) != 2 || evs[1]["event_action"] != "resolve" || evs[1]["dedup_key"] != ApprovalDedupKey(approval.ID) {
+		t.Fatalf("events after decision: got %+v", evs)
+	}
+}
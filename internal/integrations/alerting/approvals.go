@@ -0,0 +1,72 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// ApprovalDedupKey returns the deduplication key of the notification of an
+// approval request.
+func ApprovalDedupKey(approvalID string) string {
+	return "agentcrew-approval-" + approvalID
+}
+
+// NotifyApprovalRequested notifies the enabled integrations covering the
+// approval's team of a pending approval request, as an informational
+// incident. ResolveApproval resolves it once the request is decided.
+func (m *Monitor) NotifyApprovalRequested(ctx context.Context, approval models.Approval, teamName string) {
+	summary := fmt.Sprintf("Approval needed: %s of team %q requested by %s", approval.Action, teamName, approval.RequestedByEmail)
+	for _, integration := range m.approvalIntegrations(approval) {
+		alerter, err := NewAlerter(integration, m.Client)
+		if err == nil {
+			callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+			err = alerter.Trigger(callCtx, Alert{
+				DedupKey: ApprovalDedupKey(approval.ID),
+				Summary:  summary,
+				Source:   "agentcrew/" + teamName,
+				Severity: models.AlertSeverityInfo,
+				Details: map[string]string{
+					"approval_id":  approval.ID,
+					"team_id":      approval.TeamID,
+					"team_name":    teamName,
+					"action":       approval.Action,
+					"requested_by": approval.RequestedByEmail,
+					"reason":       approval.Reason,
+				},
+			})
+			cancel()
+		}
+		if err == nil {
+			slog.Info("alerting: approval request notified",
+				"integration_id", integration.ID, "team", teamName, "approval_id", approval.ID)
+		}
+		m.recordResult(integration, err)
+	}
+}
+
+// ResolveApproval resolves the notification of a decided approval request.
+func (m *Monitor) ResolveApproval(ctx context.Context, approval models.Approval, note string) {
+	for _, integration := range m.approvalIntegrations(approval) {
+		alerter, err := NewAlerter(integration, m.Client)
+		if err == nil {
+			callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+			err = alerter.Resolve(callCtx, ApprovalDedupKey(approval.ID), note)
+			cancel()
+		}
+		m.recordResult(integration, err)
+	}
+}
+
+// approvalIntegrations returns the enabled integrations of the approval's
+// organization that cover its team.
+func (m *Monitor) approvalIntegrations(approval models.Approval) []models.AlertIntegration {
+	var integrations []models.AlertIntegration
+	if err := m.DB.Where("org_id = ? AND enabled = ? AND (team_id = '' OR team_id = ?)",
+		approval.OrgID, true, approval.TeamID).Find(&integrations).Error; err != nil {
+		slog.Error("alerting: failed to query integrations", "error", err)
+	}
+	return integrations
+}
@@ -0,0 +1,347 @@
+package alerting
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// DefaultInterval is how often team health is evaluated.
+const DefaultInterval = 30 * time.Second
+
+// requestTimeout bounds a single provider call.
+const requestTimeout = 15 * time.Second
+
+// StatusChecker reports the status of an agent container. It is satisfied by
+// runtime.AgentRuntime.
+type StatusChecker interface {
+	GetStatus(ctx context.Context, id string) (*runtime.AgentStatus, error)
+}
+
+// Monitor periodically evaluates team health against the enabled alert
+// integrations, opening an incident when a team is unhealthy and resolving it
+// once the team is running again or has been stopped.
+//
+// The leader container's runtime status is the team heartbeat: a running team
+// whose leader has not been seen running for the integration's heartbeat
+// timeout is considered unhealthy.
+type Monitor struct {
+	DB       *gorm.DB
+	Runtime  StatusChecker
+	Client   *http.Client
+	Interval time.Duration
+
+	// now is overridable in tests.
+	now func() time.Time
+
+	mu          sync.Mutex
+	lastHealthy map[string]time.Time // team ID → last time the leader was seen running
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMonitor creates a Monitor. The interval defaults to DefaultInterval and
+// can be overridden with the ALERT_CHECK_INTERVAL environment variable.
+func NewMonitor(db *gorm.DB, rt StatusChecker, interval time.Duration) *Monitor {
+	if interval <= 0 {
+		interval = DefaultInterval
+		if env := os.Getenv("ALERT_CHECK_INTERVAL"); env != "" {
+			if d, err := time.ParseDuration(env); err == nil && d > 0 {
+				interval = d
+			}
+		}
+	}
+	return &Monitor{
+		DB:          db,
+		Runtime:     rt,
+		Client:      &http.Client{Timeout: requestTimeout},
+		Interval:    interval,
+		now:         time.Now,
+		lastHealthy: make(map[string]time.Time),
+	}
+}
+
+// Start begins the monitor loop in a background goroutine.
+func (m *Monitor) Start() {
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.wg.Add(1)
+	go m.loop()
+	slog.Info("alert monitor started", "interval", m.Interval.String())
+}
+
+// Stop shuts down the monitor and waits for the loop to exit.
+func (m *Monitor) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	slog.Info("alert monitor stopped")
+}
+
+func (m *Monitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check(m.ctx)
+		}
+	}
+}
+
+// Check evaluates every enabled integration once.
+func (m *Monitor) Check(ctx context.Context) {
+	var integrations []models.AlertIntegration
+	if err := m.DB.Where("enabled = ?", true).Find(&integrations).Error; err != nil {
+		slog.Error("alerting: failed to query integrations", "error", err)
+		return
+	}
+	if len(integrations) == 0 {
+		return
+	}
+
+	var teams []models.Team
+	if err := m.DB.Find(&teams).Error; err != nil {
+		slog.Error("alerting: failed to query teams", "error", err)
+		return
+	}
+	silentFor := m.updateHeartbeats(ctx, teams)
+
+	for _, integration := range integrations {
+		m.checkIntegration(ctx, integration, teams, silentFor)
+	}
+}
+
+// updateHeartbeats checks the leader container of every running team and
+// returns how long each running team's leader has gone unseen.
+func (m *Monitor) updateHeartbeats(ctx context.Context, teams []models.Team) map[string]time.Duration {
+	now := m.now()
+	silentFor := make(map[string]time.Duration)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	running := make(map[string]bool)
+	for _, team := range teams {
+		if team.Status != models.TeamStatusRunning {
+			continue
+		}
+		running[team.ID] = true
+
+		if _, seen := m.lastHealthy[team.ID]; !seen {
+			// Grace period starts when the team is first observed running.
+			m.lastHealthy[team.ID] = now
+		}
+		if m.leaderRunning(ctx, team.ID) {
+			m.lastHealthy[team.ID] = now
+		}
+		silentFor[team.ID] = now.Sub(m.lastHealthy[team.ID])
+	}
+	for id := range m.lastHealthy {
+		if !running[id] {
+			delete(m.lastHealthy, id)
+		}
+	}
+	return silentFor
+}
+
+// leaderRunning reports whether the team's leader container is running.
+func (m *Monitor) leaderRunning(ctx context.Context, teamID string) bool {
+	if m.Runtime == nil {
+		return true
+	}
+	var leader models.Agent
+	if err := m.DB.Where("team_id = ? AND role = ?", teamID, models.AgentRoleLeader).First(&leader).Error; err != nil {
+		return false
+	}
+	if leader.ContainerID == "" {
+		return false
+	}
+	statusCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	status, err := m.Runtime.GetStatus(statusCtx, leader.ContainerID)
+	return err == nil && status.Status == "running"
+}
+
+// checkIntegration reconciles the open alerts of one integration with the
+// current health of the teams it covers.
+func (m *Monitor) checkIntegration(ctx context.Context, integration models.AlertIntegration, teams []models.Team, silentFor map[string]time.Duration) {
+	var open []models.TeamAlert
+	m.DB.Where("integration_id = ? AND status = ?", integration.ID, models.AlertStatusOpen).Find(&open)
+	openByTeam := make(map[string]models.TeamAlert, len(open))
+	for _, a := range open {
+		openByTeam[a.TeamID] = a
+	}
+
+	alerter, err := NewAlerter(integration, m.Client)
+	if err != nil {
+		m.recordResult(integration, err)
+		return
+	}
+
+	var lastErr error
+	covered := make(map[string]bool)
+	for _, team := range teams {
+		if team.OrgID != integration.OrgID || (integration.TeamID != "" && team.ID != integration.TeamID) {
+			continue
+		}
+		covered[team.ID] = true
+
+		reason, summary := Evaluate(integration, team, silentFor[team.ID])
+		alert, isOpen := openByTeam[team.ID]
+
+		switch {
+		case reason != "" && !isOpen:
+			if err := m.open(ctx, alerter, integration, team, reason, summary); err != nil {
+				lastErr = err
+			}
+		case reason == "" && isOpen && recovered(team):
+			if err := m.resolve(ctx, alerter, alert, fmt.Sprintf("Team %q is %s", team.Name, team.Status)); err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	// Resolve alerts for teams that were deleted or are no longer covered.
+	for teamID, alert := range openByTeam {
+		if !covered[teamID] {
+			if err := m.resolve(ctx, alerter, alert, "Team is no longer monitored"); err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	m.recordResult(integration, lastErr)
+}
+
+// Evaluate returns why a team is unhealthy under the integration's rules, or
+// an empty reason when it is healthy.
+func Evaluate(integration models.AlertIntegration, team models.Team, silentFor time.Duration) (reason, summary string) {
+	if team.Status == models.TeamStatusError {
+		if integration.DeployFailureThreshold > 0 && team.DeployFailures >= integration.DeployFailureThreshold {
+			return models.AlertReasonDeployFailure,
+				fmt.Sprintf("Team %q failed to deploy %d times in a row: %s", team.Name, team.DeployFailures, team.StatusMessage)
+		}
+		if integration.AlertOnError {
+			return models.AlertReasonTeamError,
+				fmt.Sprintf("Team %q entered error status: %s", team.Name, team.StatusMessage)
+		}
+	}
+	if team.Status == models.TeamStatusRunning && integration.HeartbeatTimeoutSeconds > 0 {
+		timeout := time.Duration(integration.HeartbeatTimeoutSeconds) * time.Second
+		if silentFor >= timeout {
+			return models.AlertReasonHeartbeat,
+				fmt.Sprintf("Team %q leader heartbeat missing for %s", team.Name, silentFor.Truncate(time.Second))
+		}
+	}
+	return "", ""
+}
+
+// recovered reports whether a team is in a state that resolves its alert:
+// running again, or deliberately stopped.
+func recovered(team models.Team) bool {
+	return team.Status == models.TeamStatusRunning || team.Status == models.TeamStatusStopped
+}
+
+func (m *Monitor) open(ctx context.Context, alerter Alerter, integration models.AlertIntegration, team models.Team, reason, summary string) error {
+	dedupKey := DedupKey(team.ID)
+	callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	err := alerter.Trigger(callCtx, Alert{
+		DedupKey: dedupKey,
+		Summary:  summary,
+		Source:   "agentcrew/" + team.Name,
+		Severity: integration.Severity,
+		Details: map[string]string{
+			"team_id":         team.ID,
+			"team_name":       team.Name,
+			"reason":          reason,
+			"status":          team.Status,
+			"status_message":  team.StatusMessage,
+			"deploy_failures": fmt.Sprint(team.DeployFailures),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	slog.Warn("alerting: incident opened",
+		"integration_id", integration.ID, "team", team.Name, "reason", reason)
+	return m.DB.Create(&models.TeamAlert{
+		ID:            uuid.New().String(),
+		IntegrationID: integration.ID,
+		TeamID:        team.ID,
+		DedupKey:      dedupKey,
+		Reason:        reason,
+		Summary:       summary,
+		Status:        models.AlertStatusOpen,
+		OpenedAt:      m.now(),
+	}).Error
+}
+
+func (m *Monitor) resolve(ctx context.Context, alerter Alerter, alert models.TeamAlert, note string) error {
+	callCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	if err := alerter.Resolve(callCtx, alert.DedupKey, note); err != nil {
+		return err
+	}
+
+	slog.Info("alerting: incident resolved",
+		"integration_id", alert.IntegrationID, "team_id", alert.TeamID, "reason", alert.Reason)
+	now := m.now()
+	return m.DB.Model(&models.TeamAlert{}).Where("id = ?", alert.ID).Updates(map[string]interface{}{
+		"status":      models.AlertStatusResolved,
+		"resolved_at": now,
+	}).Error
+}
+
+// ResolveAll resolves every open alert of an integration, e.g. before it is
+// deleted, so that no incident is left dangling in the provider.
+func (m *Monitor) ResolveAll(ctx context.Context, integration models.AlertIntegration) error {
+	alerter, err := NewAlerter(integration, m.Client)
+	if err != nil {
+		return err
+	}
+	var open []models.TeamAlert
+	m.DB.Where("integration_id = ? AND status = ?", integration.ID, models.AlertStatusOpen).Find(&open)
+	for _, alert := range open {
+		if err := m.resolve(ctx, alerter, alert, "Alert integration removed"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordResult logs a provider failure and stores it on the integration, or
+// clears the previous error on success.
+func (m *Monitor) recordResult(integration models.AlertIntegration, err error) {
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		slog.Error("alerting: provider call failed",
+			"integration_id", integration.ID, "provider", integration.Provider, "error", err)
+	}
+	if lastError == integration.LastError {
+		return
+	}
+	m.DB.Model(&models.AlertIntegration{}).Where("id = ?", integration.ID).Update("last_error", lastError)
+}
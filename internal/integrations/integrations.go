@@ -0,0 +1,40 @@
+// Package integrations holds helpers shared by the third-party integrations
+// in its subpackages.
+package integrations
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/helmcode/agent-crew/internal/crypto"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// DecryptCredentials decodes and decrypts a stored credential map.
+func DecryptCredentials(raw models.JSON) (map[string]string, error) {
+	creds := map[string]string{}
+	if len(raw) == 0 || string(raw) == "null" {
+		return creds, nil
+	}
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("parsing credentials: %w", err)
+	}
+	for k, v := range creds {
+		decrypted, err := crypto.Decrypt(v)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting credential %q: %w", k, err)
+		}
+		creds[k] = decrypted
+	}
+	return creds, nil
+}
+
+// Truncate returns at most maxLen runes of s, followed by marker when s was
+// cut.
+func Truncate(s string, maxLen int, marker string) string {
+	r := []rune(s)
+	if len(r) <= maxLen {
+		return s
+	}
+	return string(r[:maxLen]) + marker
+}
@@ -0,0 +1,21 @@
+package integrations
+
+import "testing"
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly", 7, "exactly"},
+		{"truncated", 5, "trunc…"},
+		{"héllo wörld", 4, "héll…"},
+	}
+	for _, tc := range cases {
+		if got := Truncate(tc.in, tc.max, "…"); got != tc.want {
+			t.Errorf("Truncate(%q, %d): got %q, want %q", tc.in, tc.max, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package issues
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// CommentEvent is a new comment on a tracker issue.
+type CommentEvent struct {
+	IssueKey   string // Jira key or Linear issue ID, usable with Tracker.AddComment
+	IssueRef   string // human-readable reference, e.g. "OPS-12"
+	IssueTitle string
+	Author     string
+	Body       string
+}
+
+// ParseCommentEvent decodes a tracker webhook payload. It returns ok=false for
+// events other than a newly created comment, which callers should ignore.
+func ParseCommentEvent(provider string, body []byte) (CommentEvent, bool, error) {
+	switch provider {
+	case models.IssueProviderJira:
+		var p struct {
+			WebhookEvent string `json:"webhookEvent"`
+			Issue        struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Summary string `json:"summary"`
+				} `json:"fields"`
+			} `json:"issue"`
+			Comment struct {
+				Body   string `json:"body"`
+				Author struct {
+					DisplayName string `json:"displayName"`
+				} `json:"author"`
+			} `json:"comment"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return CommentEvent{}, false, fmt.Errorf("invalid jira payload: %w", err)
+		}
+		if p.WebhookEvent != "comment_created" || p.Issue.Key == "" {
+			return CommentEvent{}, false, nil
+		}
+		return CommentEvent{
+			IssueKey:   p.Issue.Key,
+			IssueRef:   p.Issue.Key,
+			IssueTitle: p.Issue.Fields.Summary,
+			Author:     p.Comment.Author.DisplayName,
+			Body:       p.Comment.Body,
+		}, true, nil
+
+	case models.IssueProviderLinear:
+		var p struct {
+			Action string `json:"action"`
+			Type   string `json:"type"`
+			Data   struct {
+				Body    string `json:"body"`
+				IssueID string `json:"issueId"`
+				Issue   struct {
+					ID         string `json:"id"`
+					Identifier string `json:"identifier"`
+					Title      string `json:"title"`
+				} `json:"issue"`
+				User struct {
+					Name string `json:"name"`
+				} `json:"user"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &p); err != nil {
+			return CommentEvent{}, false, fmt.Errorf("invalid linear payload: %w", err)
+		}
+		if p.Type != "Comment" || p.Action != "create" {
+			return CommentEvent{}, false, nil
+		}
+		key := p.Data.IssueID
+		if key == "" {
+			key = p.Data.Issue.ID
+		}
+		if key == "" {
+			return CommentEvent{}, false, nil
+		}
+		ref := p.Data.Issue.Identifier
+		if ref == "" {
+			ref = key
+		}
+		return CommentEvent{
+			IssueKey:   key,
+			IssueRef:   ref,
+			IssueTitle: p.Data.Issue.Title,
+			Author:     p.Data.User.Name,
+			Body:       p.Data.Body,
+		}, true, nil
+
+	default:
+		return CommentEvent{}, false, fmt.Errorf("unsupported issue provider: %q", provider)
+	}
+}
+
+// Command returns the instruction following prefix at the start of the
+// comment. ok is false when the comment does not start with the prefix or
+// carries no instruction.
+func (e CommentEvent) Command(prefix string) (string, bool) {
+	body := strings.TrimSpace(e.Body)
+	if prefix == "" || !strings.HasPrefix(body, prefix) {
+		return "", false
+	}
+	cmd := strings.TrimSpace(strings.TrimPrefix(body, prefix))
+	return cmd, cmd != ""
+}
+
+// Prompt builds the prompt sent to the team leader for a comment command.
+func (e CommentEvent) Prompt(command string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Request from a comment on ticket %s", e.IssueRef)
+	if e.IssueTitle != "" {
+		fmt.Fprintf(&b, " (%q)", e.IssueTitle)
+	}
+	if e.Author != "" {
+		fmt.Fprintf(&b, " by %s", e.Author)
+	}
+	b.WriteString(":\n\n")
+	b.WriteString(command)
+	return b.String()
+}
@@ -0,0 +1,82 @@
+// Package issues syncs run results to issue trackers (Jira, Linear) and parses
+// tracker comment webhooks that start new runs.
+package issues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/helmcode/agent-crew/internal/integrations"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// Tracker is the minimal issue tracker API needed to report run results.
+type Tracker interface {
+	// CreateIssue opens a ticket and returns the key used to reference it in
+	// later calls (a Jira key such as "OPS-12", or a Linear issue ID).
+	CreateIssue(ctx context.Context, issue Issue) (string, error)
+	// AddComment appends a comment to an existing ticket.
+	AddComment(ctx context.Context, key, body string) error
+}
+
+// Issue is the provider-neutral content of a new ticket.
+type Issue struct {
+	Project     string
+	IssueType   string
+	Title       string
+	Description string
+	Labels      []string
+}
+
+// NewTracker builds the Tracker for an integration, decrypting its stored
+// credentials.
+func NewTracker(integration models.IssueIntegration, client *http.Client) (Tracker, error) {
+	creds, err := integrations.DecryptCredentials(integration.Credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	switch integration.Provider {
+	case models.IssueProviderJira:
+		if integration.BaseURL == "" {
+			return nil, fmt.Errorf("jira integration requires base_url")
+		}
+		if creds["email"] == "" || creds["api_token"] == "" {
+			return nil, fmt.Errorf("jira integration requires 'email' and 'api_token' credentials")
+		}
+		return &Jira{
+			BaseURL:  integration.BaseURL,
+			Email:    creds["email"],
+			APIToken: creds["api_token"],
+			Client:   client,
+		}, nil
+
+	case models.IssueProviderLinear:
+		if creds["api_key"] == "" {
+			return nil, fmt.Errorf("linear integration requires 'api_key' credential")
+		}
+		endpoint := integration.BaseURL
+		if endpoint == "" {
+			endpoint = LinearDefaultEndpoint
+		}
+		return &Linear{
+			Endpoint: endpoint,
+			APIKey:   creds["api_key"],
+			Client:   client,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported issue provider: %q", integration.Provider)
+	}
+}
+
+// Labels decodes an integration's label list.
+func Labels(integration models.IssueIntegration) []string {
+	var labels []string
+	if len(integration.Labels) > 0 {
+		_ = json.Unmarshal(integration.Labels, &labels)
+	}
+	return labels
+}
@@ -0,0 +1,364 @@
+package issues
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.IssueIntegration{}, &models.IssueLink{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+type recordedRequest struct {
+	Path string
+	Body map[string]interface{}
+}
+
+// fakeJira records requests and answers issue creation with OPS-1.
+func fakeJira(t *testing.T) (*httptest.Server, func() []recordedRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "bot@example.com" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]interface{}
+		_ = json.Unmarshal(data, &body)
+		mu.Lock()
+		reqs = append(reqs, recordedRequest{Path: r.URL.Path, Body: body})
+		mu.Unlock()
+
+		if r.URL.Path == "/rest/api/2/issue" {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"10001","key":"OPS-1"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), reqs...)
+	}
+}
+
+func jiraIntegration(baseURL, mode string) models.IssueIntegration {
+	return models.IssueIntegration{
+		ID:          "int-1",
+		TeamID:      "team-1",
+		Provider:    models.IssueProviderJira,
+		BaseURL:     baseURL,
+		Project:     "OPS",
+		IssueType:   "Task",
+		Labels:      models.JSON(`["agentcrew","nightly"]`),
+		Credentials: models.JSON(`{"email":"bot@example.com","api_token":"secret"}`),
+		SyncResults: true,
+		SyncMode:    mode,
+		TriggerOn:   models.PostActionTriggerOnAny,
+		Enabled:     true,
+	}
+}
+
+func testRun() RunResult {
+	return RunResult{
+		SourceType:  models.PostActionTriggerSchedule,
+		TriggerID:   "sched-1",
+		TriggerName: "nightly",
+		RunID:       "run-1",
+		TeamID:      "team-1",
+		TeamName:    "ops",
+		Status:      "success",
+		Response:    "All checks passed.",
+		Prompt:      "Run the checks",
+		StartedAt:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		FinishedAt:  time.Date(2026, 1, 1, 0, 5, 0, 0, time.UTC),
+	}
+}
+
+func TestJira_CreateIssue(t *testing.T) {
+	srv, requests := fakeJira(t)
+	tracker, err := NewTracker(jiraIntegration(srv.URL, models.IssueSyncModeCreate), srv.Client())
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+
+	key, err := tracker.CreateIssue(t.Context(), Issue{Project: "OPS", Title: "t", Description: "d", Labels: []string{"a"}})
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	if key != "OPS-1" {
+		t.Errorf("key: got %q, want OPS-1", key)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("requests: got %d, want 1", len(reqs))
+	}
+	fields := reqs[0].Body["fields"].(map[string]interface{})
+	if fields["project"].(map[string]interface{})["key"] != "OPS" {
+		t.Errorf("project: got %v", fields["project"])
+	}
+	if fields["issuetype"].(map[string]interface{})["name"] != "Task" {
+		t.Errorf("issuetype: got %v", fields["issuetype"])
+	}
+}
+
+func TestLinear_CreateIssueAndComment(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "lin_key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Query string `json:"query"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		queries = append(queries, body.Query)
+		if strings.Contains(body.Query, "issueCreate") {
+			w.Write([]byte(`{"data":{"issueCreate":{"success":true,"issue":{"id":"uuid-1","identifier":"ENG-7"}}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"commentCreate":{"success":true}}}`))
+	}))
+	defer srv.Close()
+
+	tracker, err := NewTracker(models.IssueIntegration{
+		Provider:    models.IssueProviderLinear,
+		BaseURL:     srv.URL,
+		Credentials: models.JSON(`{"api_key":"lin_key"}`),
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewTracker: %v", err)
+	}
+
+	key, err := tracker.CreateIssue(t.Context(), Issue{Project: "team-uuid", Title: "t"})
+	if err != nil {
+		t.Fatalf("CreateIssue: %v", err)
+	}
+	if key != "uuid-1" {
+		t.Errorf("key: got %q, want uuid-1", key)
+	}
+	if err := tracker.AddComment(t.Context(), key, "done"); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if len(queries) != 2 {
+		t.Errorf("queries: got %d, want 2", len(queries))
+	}
+}
+
+func TestLinear_GraphQLError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":[{"message":"team not found"}]}`))
+	}))
+	defer srv.Close()
+
+	tracker := &Linear{Endpoint: srv.URL, APIKey: "k", Client: srv.Client()}
+	_, err := tracker.CreateIssue(t.Context(), Issue{Project: "x", Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "team not found") {
+		t.Errorf("expected graphql error, got %v", err)
+	}
+}
+
+func TestNewTracker_MissingCredentials(t *testing.T) {
+	_, err := NewTracker(models.IssueIntegration{Provider: models.IssueProviderJira, BaseURL: "https://x"}, http.DefaultClient)
+	if err == nil {
+		t.Error("expected error for missing jira credentials")
+	}
+	_, err = NewTracker(models.IssueIntegration{Provider: "github"}, http.DefaultClient)
+	if err == nil {
+		t.Error("expected error for unsupported provider")
+	}
+}
+
+func TestSyncRun_UpdateModeReusesIssue(t *testing.T) {
+	db := setupTestDB(t)
+	srv, requests := fakeJira(t)
+	integration := jiraIntegration(srv.URL, models.IssueSyncModeUpdate)
+	db.Create(&integration)
+
+	n := &Notifier{DB: db, Client: srv.Client()}
+	n.syncRun(integration, testRun())
+	n.syncRun(integration, testRun())
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("requests: got %d, want 2", len(reqs))
+	}
+	if reqs[0].Path != "/rest/api/2/issue" {
+		t.Errorf("first request: got %s, want issue creation", reqs[0].Path)
+	}
+	if reqs[1].Path != "/rest/api/2/issue/OPS-1/comment" {
+		t.Errorf("second request: got %s, want comment on OPS-1", reqs[1].Path)
+	}
+
+	var link models.IssueLink
+	if err := db.First(&link, "integration_id = ?", integration.ID).Error; err != nil {
+		t.Fatalf("expected issue link: %v", err)
+	}
+	if link.IssueKey != "OPS-1" || link.TriggerID != "sched-1" {
+		t.Errorf("link: got %+v", link)
+	}
+}
+
+func TestSyncRun_CreateModeOpensNewIssues(t *testing.T) {
+	db := setupTestDB(t)
+	srv, requests := fakeJira(t)
+	integration := jiraIntegration(srv.URL, models.IssueSyncModeCreate)
+	db.Create(&integration)
+
+	n := &Notifier{DB: db, Client: srv.Client()}
+	n.syncRun(integration, testRun())
+	n.syncRun(integration, testRun())
+
+	for _, r := range requests() {
+		if r.Path != "/rest/api/2/issue" {
+			t.Errorf("unexpected request to %s", r.Path)
+		}
+	}
+	var count int64
+	db.Model(&models.IssueLink{}).Count(&count)
+	if count != 0 {
+		t.Errorf("issue links: got %d, want 0 in create mode", count)
+	}
+}
+
+func TestSyncRun_RecordsLastError(t *testing.T) {
+	db := setupTestDB(t)
+	integration := jiraIntegration("http://127.0.0.1:1", models.IssueSyncModeCreate)
+	integration.Credentials = models.JSON(`{}`)
+	db.Create(&integration)
+
+	n := NewNotifier(db)
+	n.syncRun(integration, testRun())
+
+	var got models.IssueIntegration
+	db.First(&got, "id = ?", integration.ID)
+	if !strings.Contains(got.LastError, "credentials") {
+		t.Errorf("last_error: got %q", got.LastError)
+	}
+}
+
+func TestMatchesTriggerOn(t *testing.T) {
+	tests := []struct {
+		triggerOn, status string
+		want              bool
+	}{
+		{"any", "failed", true},
+		{"success", "success", true},
+		{"success", "failed", false},
+		{"failure", "timeout", true},
+		{"failure", "success", false},
+	}
+	for _, tt := range tests {
+		if got := matchesTriggerOn(tt.triggerOn, tt.status); got != tt.want {
+			t.Errorf("matchesTriggerOn(%q, %q) = %v, want %v", tt.triggerOn, tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommentEvent_Jira(t *testing.T) {
+	body := []byte(`{"webhookEvent":"comment_created","issue":{"key":"OPS-9","fields":{"summary":"Disk full"}},"comment":{"body":"/agentcrew clean up /tmp","author":{"displayName":"Sam"}}}`)
+	ev, ok, err := ParseCommentEvent(models.IssueProviderJira, body)
+	if err != nil || !ok {
+		t.Fatalf("got ok=%v err=%v", ok, err)
+	}
+	if ev.IssueKey != "OPS-9" || ev.IssueTitle != "Disk full" || ev.Author != "Sam" {
+		t.Errorf("event: got %+v", ev)
+	}
+	cmd, ok := ev.Command("/agentcrew")
+	if !ok || cmd != "clean up /tmp" {
+		t.Errorf("command: got %q ok=%v", cmd, ok)
+	}
+	if p := ev.Prompt(cmd); !strings.Contains(p, "OPS-9") || !strings.HasSuffix(p, "clean up /tmp") {
+		t.Errorf("prompt: got %q", p)
+	}
+
+	_, ok, _ = ParseCommentEvent(models.IssueProviderJira, []byte(`{"webhookEvent":"jira:issue_updated","issue":{"key":"OPS-9"}}`))
+	if ok {
+		t.Error("expected non-comment event to be ignored")
+	}
+}
+
+func TestParseCommentEvent_Linear(t *testing.T) {
+	body := []byte(`{"action":"create","type":"Comment","data":{"body":"/agentcrew triage","issueId":"uuid-9","issue":{"id":"uuid-9","identifier":"ENG-9","title":"Bug"},"user":{"name":"Alex"}}}`)
+	ev, ok, err := ParseCommentEvent(models.IssueProviderLinear, body)
+	if err != nil || !ok {
+		t.Fatalf("got ok=%v err=%v", ok, err)
+	}
+	if ev.IssueKey != "uuid-9" || ev.IssueRef != "ENG-9" {
+		t.Errorf("event: got %+v", ev)
+	}
+
+	_, ok, _ = ParseCommentEvent(models.IssueProviderLinear, []byte(`{"action":"update","type":"Comment","data":{"issueId":"x"}}`))
+	if ok {
+		t.Error("expected comment update to be ignored")
+	}
+}
+
+func TestCommentEvent_CommandRequiresPrefix(t *testing.T) {
+	ev := CommentEvent{Body: "looks good to me"}
+	if _, ok := ev.Command("/agentcrew"); ok {
+		t.Error("expected comment without prefix to be ignored")
+	}
+	ev.Body = "/agentcrew   "
+	if _, ok := ev.Command("/agentcrew"); ok {
+		t.Error("expected empty command to be ignored")
+	}
+}
+
+func TestFormatDescription(t *testing.T) {
+	run := testRun()
+	run.Response = strings.Repeat("x", maxSectionLength+10)
+	desc := FormatDescription(run)
+	if !strings.Contains(desc, "Status: success") || !strings.Contains(desc, "(truncated)") {
+		t.Errorf("unexpected description:\n%s", desc[:200])
+	}
+	if title := FormatTitle(run); title != "[AgentCrew] nightly: run success" {
+		t.Errorf("title: got %q", title)
+	}
+}
+
+func TestFormatDescription_ListsArtifacts(t *testing.T) {
+	run := testRun()
+	run.Response = "Done.\n\n```diff\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new\n```\n\n```sh\ngo test ./...\n```\n"
+	desc := FormatDescription(run)
+	for _, want := range []string{"Artifacts:", "- diff: main.go", "- code block: sh"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("expected description to contain %q\n%s", want, desc)
+		}
+	}
+
+	run.Response = "No files changed."
+	if desc := FormatDescription(run); strings.Contains(desc, "Artifacts:") {
+		t.Errorf("description without artifacts should have no artifacts section:\n%s", desc)
+	}
+}
@@ -0,0 +1,89 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxErrorBody limits how much of a failed tracker response is kept in errors.
+const maxErrorBody = 512
+
+// Jira talks to the Jira REST API v2 using basic auth with an API token.
+type Jira struct {
+	BaseURL  string
+	Email    string
+	APIToken string
+	Client   *http.Client
+}
+
+// CreateIssue creates a Jira issue and returns its key.
+func (j *Jira) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+	issueType := issue.IssueType
+	if issueType == "" {
+		issueType = "Task"
+	}
+	fields := map[string]interface{}{
+		"project":     map[string]string{"key": issue.Project},
+		"issuetype":   map[string]string{"name": issueType},
+		"summary":     issue.Title,
+		"description": issue.Description,
+	}
+	if len(issue.Labels) > 0 {
+		fields["labels"] = issue.Labels
+	}
+
+	var resp struct {
+		Key string `json:"key"`
+	}
+	if err := j.do(ctx, "/rest/api/2/issue", map[string]interface{}{"fields": fields}, &resp); err != nil {
+		return "", fmt.Errorf("creating jira issue: %w", err)
+	}
+	if resp.Key == "" {
+		return "", fmt.Errorf("creating jira issue: response has no key")
+	}
+	return resp.Key, nil
+}
+
+// AddComment adds a comment to a Jira issue.
+func (j *Jira) AddComment(ctx context.Context, key, body string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/comment"
+	if err := j.do(ctx, path, map[string]string{"body": body}, nil); err != nil {
+		return fmt.Errorf("commenting on jira issue %s: %w", key, err)
+	}
+	return nil
+}
+
+func (j *Jira) do(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(j.BaseURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(j.Email, j.APIToken)
+
+	resp, err := j.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LinearDefaultEndpoint is the Linear GraphQL API endpoint.
+const LinearDefaultEndpoint = "https://api.linear.app/graphql"
+
+// Linear talks to the Linear GraphQL API using a personal API key. The
+// integration's project is the Linear team ID and labels are label IDs.
+type Linear struct {
+	Endpoint string
+	APIKey   string
+	Client   *http.Client
+}
+
+const linearIssueCreate = `mutation IssueCreate($input: IssueCreateInput!) {
+  issueCreate(input: $input) { success issue { id identifier } }
+}`
+
+const linearCommentCreate = `mutation CommentCreate($input: CommentCreateInput!) {
+  commentCreate(input: $input) { success }
+}`
+
+// CreateIssue creates a Linear issue and returns its ID.
+func (l *Linear) CreateIssue(ctx context.Context, issue Issue) (string, error) {
+	input := map[string]interface{}{
+		"teamId":      issue.Project,
+		"title":       issue.Title,
+		"description": issue.Description,
+	}
+	if len(issue.Labels) > 0 {
+		input["labelIds"] = issue.Labels
+	}
+
+	var data struct {
+		IssueCreate struct {
+			Success bool `json:"success"`
+			Issue   struct {
+				ID         string `json:"id"`
+				Identifier string `json:"identifier"`
+			} `json:"issue"`
+		} `json:"issueCreate"`
+	}
+	if err := l.do(ctx, linearIssueCreate, map[string]interface{}{"input": input}, &data); err != nil {
+		return "", fmt.Errorf("creating linear issue: %w", err)
+	}
+	if !data.IssueCreate.Success || data.IssueCreate.Issue.ID == "" {
+		return "", fmt.Errorf("creating linear issue: request was not successful")
+	}
+	return data.IssueCreate.Issue.ID, nil
+}
+
+// AddComment adds a comment to a Linear issue.
+func (l *Linear) AddComment(ctx context.Context, key, body string) error {
+	var data struct {
+		CommentCreate struct {
+			Success bool `json:"success"`
+		} `json:"commentCreate"`
+	}
+	input := map[string]interface{}{"issueId": key, "body": body}
+	if err := l.do(ctx, linearCommentCreate, map[string]interface{}{"input": input}, &data); err != nil {
+		return fmt.Errorf("commenting on linear issue %s: %w", key, err)
+	}
+	if !data.CommentCreate.Success {
+		return fmt.Errorf("commenting on linear issue %s: request was not successful", key)
+	}
+	return nil
+}
+
+func (l *Linear) do(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", l.APIKey)
+
+	resp, err := l.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("graphql error: %s", result.Errors[0].Message)
+	}
+	return json.Unmarshal(result.Data, out)
+}
@@ -0,0 +1,244 @@
+package issues
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/integrations"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/segments"
+)
+
+// maxSectionLength caps, in runes, the prompt and result text written to a
+// ticket.
+const maxSectionLength = 30000
+
+// truncatedMarker ends a section cut at maxSectionLength.
+const truncatedMarker = "\n… (truncated)"
+
+// requestTimeout bounds all tracker calls for a single notification.
+const requestTimeout = 30 * time.Second
+
+// RunResult describes a finished run to report to a tracker.
+type RunResult struct {
+	SourceType  string // "webhook", "schedule" or "issue"
+	TriggerID   string
+	TriggerName string
+	RunID       string
+	TeamID      string
+	TeamName    string
+	Status      string // "success", "failed" or "timeout"
+	Response    string
+	Error       string
+	Prompt      string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+}
+
+// Notifier writes run results to the issue trackers configured for a team.
+type Notifier struct {
+	DB     *gorm.DB
+	Client *http.Client
+}
+
+// NewNotifier creates a Notifier with sensible defaults.
+func NewNotifier(db *gorm.DB) *Notifier {
+	return &Notifier{
+		DB:     db,
+		Client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// NotifyRunCompleted reports a finished webhook or schedule run to every
+// enabled integration of the run's team that syncs results for its status.
+// It is fire-and-forget: tracker calls run in background goroutines.
+func (n *Notifier) NotifyRunCompleted(run RunResult) {
+	var integrations []models.IssueIntegration
+	if err := n.DB.Where("team_id = ? AND enabled = ? AND sync_results = ?", run.TeamID, true, true).
+		Find(&integrations).Error; err != nil {
+		slog.Error("issues: failed to query integrations", "team_id", run.TeamID, "error", err)
+		return
+	}
+
+	for _, integration := range integrations {
+		if !matchesTriggerOn(integration.TriggerOn, run.Status) {
+			continue
+		}
+		go n.syncRun(integration, run)
+	}
+}
+
+// ReplyToComment posts the result of a comment-triggered run back to the
+// originating issue. It blocks until the comment is posted.
+func (n *Notifier) ReplyToComment(integration models.IssueIntegration, issueKey string, run RunResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	tracker, err := NewTracker(integration, n.Client)
+	if err == nil {
+		err = tracker.AddComment(ctx, issueKey, FormatComment(run))
+	}
+	n.recordResult(integration, err)
+}
+
+// syncRun creates a ticket for the run, or comments on the trigger's existing
+// ticket when the integration is in update mode.
+func (n *Notifier) syncRun(integration models.IssueIntegration, run RunResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	tracker, err := NewTracker(integration, n.Client)
+	if err != nil {
+		n.recordResult(integration, err)
+		return
+	}
+
+	if integration.SyncMode == models.IssueSyncModeUpdate {
+		var link models.IssueLink
+		err := n.DB.Where("integration_id = ? AND trigger_type = ? AND trigger_id = ?",
+			integration.ID, run.SourceType, run.TriggerID).First(&link).Error
+		if err == nil {
+			n.recordResult(integration, tracker.AddComment(ctx, link.IssueKey, FormatComment(run)))
+			return
+		}
+	}
+
+	key, err := tracker.CreateIssue(ctx, Issue{
+		Project:     integration.Project,
+		IssueType:   integration.IssueType,
+		Title:       FormatTitle(run),
+		Description: FormatDescription(run),
+		Labels:      Labels(integration),
+	})
+	n.recordResult(integration, err)
+	if err != nil || integration.SyncMode != models.IssueSyncModeUpdate {
+		return
+	}
+
+	link := models.IssueLink{
+		ID:            uuid.New().String(),
+		IntegrationID: integration.ID,
+		TriggerType:   run.SourceType,
+		TriggerID:     run.TriggerID,
+		IssueKey:      key,
+	}
+	if err := n.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&link).Error; err != nil {
+		slog.Error("issues: failed to store issue link",
+			"integration_id", integration.ID, "issue_key", key, "error", err)
+	}
+}
+
+// recordResult logs a tracker failure and stores it on the integration, or
+// clears the previous error on success.
+func (n *Notifier) recordResult(integration models.IssueIntegration, err error) {
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		slog.Error("issues: tracker sync failed",
+			"integration_id", integration.ID, "provider", integration.Provider, "error", err)
+	}
+	if lastError == integration.LastError {
+		return
+	}
+	n.DB.Model(&models.IssueIntegration{}).Where("id = ?", integration.ID).Update("last_error", lastError)
+}
+
+// matchesTriggerOn checks whether the integration's trigger_on condition
+// matches the run status. It uses the same values as post-action bindings.
+func matchesTriggerOn(triggerOn, status string) bool {
+	switch triggerOn {
+	case "", models.PostActionTriggerOnAny:
+		return true
+	case models.PostActionTriggerOnSuccess:
+		return status == "success"
+	case models.PostActionTriggerOnFailure:
+		return status == "failed" || status == "timeout"
+	default:
+		return false
+	}
+}
+
+// FormatTitle returns the ticket title for a run.
+func FormatTitle(run RunResult) string {
+	name := run.TriggerName
+	if name == "" {
+		name = run.TeamName
+	}
+	return fmt.Sprintf("[AgentCrew] %s: run %s", name, run.Status)
+}
+
+// FormatDescription returns the ticket body for a run.
+func FormatDescription(run RunResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Team: %s\n", run.TeamName)
+	if run.TriggerName != "" {
+		fmt.Fprintf(&b, "Trigger: %s (%s)\n", run.TriggerName, run.SourceType)
+	}
+	fmt.Fprintf(&b, "Run: %s\n", run.RunID)
+	fmt.Fprintf(&b, "Status: %s\n", run.Status)
+	if !run.StartedAt.IsZero() {
+		fmt.Fprintf(&b, "Started: %s\n", run.StartedAt.UTC().Format(time.RFC3339))
+	}
+	if !run.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, "Finished: %s\n", run.FinishedAt.UTC().Format(time.RFC3339))
+	}
+	if run.Prompt != "" {
+		fmt.Fprintf(&b, "\nPrompt:\n%s\n", integrations.Truncate(run.Prompt, maxSectionLength, truncatedMarker))
+	}
+	writeOutcome(&b, run)
+	return b.String()
+}
+
+// FormatComment returns the comment posted on an existing ticket for a run.
+func FormatComment(run RunResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "AgentCrew run %s finished with status %s", run.RunID, run.Status)
+	if !run.FinishedAt.IsZero() {
+		fmt.Fprintf(&b, " at %s", run.FinishedAt.UTC().Format(time.RFC3339))
+	}
+	b.WriteString(".\n")
+	writeOutcome(&b, run)
+	return b.String()
+}
+
+func writeOutcome(b *strings.Builder, run RunResult) {
+	if run.Response != "" {
+		fmt.Fprintf(b, "\nResult:\n%s\n", integrations.Truncate(run.Response, maxSectionLength, truncatedMarker))
+	}
+	if artifacts := Artifacts(run.Response); len(artifacts) > 0 {
+		b.WriteString("\nArtifacts:\n")
+		for _, a := range artifacts {
+			fmt.Fprintf(b, "- %s\n", a)
+		}
+	}
+	if run.Error != "" {
+		fmt.Fprintf(b, "\nError:\n%s\n", run.Error)
+	}
+}
+
+// Artifacts lists the file diffs and code blocks in a run's response, so a
+// ticket names them even when the result text is truncated.
+func Artifacts(response string) []string {
+	var artifacts []string
+	for _, seg := range segments.Parse(response) {
+		switch {
+		case seg.Type == segments.TypeDiff && seg.Path != "":
+			artifacts = append(artifacts, "diff: "+seg.Path)
+		case seg.Type == segments.TypeDiff:
+			artifacts = append(artifacts, "diff")
+		case seg.Type == segments.TypeCode && seg.Language != "":
+			artifacts = append(artifacts, "code block: "+seg.Language)
+		case seg.Type == segments.TypeCode:
+			artifacts = append(artifacts, "code block")
+		}
+	}
+	return artifacts
+}
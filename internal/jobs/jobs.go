@@ -0,0 +1,469 @@
+// Package jobs runs background operations, such as team deployments, from a
+// queue kept in the database. A job outlives the request and the process
+// that enqueued it: every process running a Queue claims jobs from the same
+// table, a job whose worker stops renewing its lock is claimed again, and
+// failed jobs are retried with a growing backoff.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// DefaultWorkers is how many jobs a queue runs at once unless told
+// otherwise.
+const DefaultWorkers = 10
+
+// DefaultMaxAttempts is how many times a job runs before it fails, unless
+// enqueued with another limit.
+const DefaultMaxAttempts = 3
+
+// Retention is how long finished jobs are kept.
+const Retention = 7 * 24 * time.Hour
+
+// Timings, variables so that tests can shorten them.
+var (
+	// pollInterval is how often idle workers look for jobs enqueued by
+	// other processes, or due for a retry.
+	pollInterval = 2 * time.Second
+	// lockTTL is how long a running job stays claimed without its worker
+	// renewing the lock.
+	lockTTL = 30 * time.Second
+	// retryBackoff is the wait before the first retry; it doubles for each
+	// further attempt, up to maxRetryBackoff.
+	retryBackoff    = 5 * time.Second
+	maxRetryBackoff = 5 * time.Minute
+	// pruneInterval is how often finished jobs older than Retention are
+	// deleted.
+	pruneInterval = time.Hour
+)
+
+// ErrDuplicate is returned by Enqueue, along with the job enqueued first,
+// when a job with the same idempotency key exists.
+var ErrDuplicate = errors.New("job already enqueued")
+
+// Handler runs a job. Its context is cancelled when the queue stops or the
+// job's lock is lost to another worker. A returned error fails the attempt,
+// and the job is retried unless the error is Permanent or Skip.
+type Handler func(ctx context.Context, job *models.Job) error
+
+// Options describes a job to enqueue.
+type Options struct {
+	Kind   string
+	OrgID  string
+	TeamID string
+	// IdempotencyKey, when set, enqueues the job only once: enqueueing it
+	// again returns the first job with ErrDuplicate.
+	IdempotencyKey string
+	// Payload is stored as JSON for the handler to Decode.
+	Payload interface{}
+	// MaxAttempts defaults to DefaultMaxAttempts.
+	MaxAttempts int
+}
+
+// Queue enqueues jobs and runs the jobs of the kinds it has handlers for.
+type Queue struct {
+	db     *gorm.DB
+	holder string
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a queue storing its jobs in db. holder names this process in
+// the jobs it runs and must be unique across processes; empty uses the host
+// name, the process ID and a random suffix.
+func New(db *gorm.DB, holder string) *Queue {
+	if holder == "" {
+		host, _ := os.Hostname()
+		holder = fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.New().String()[:8])
+	}
+	return &Queue{
+		db:       db,
+		holder:   holder,
+		handlers: make(map[string]Handler),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler of the jobs of kind. Register handlers before
+// Start.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+}
+
+// Enqueue stores a job and wakes an idle worker to run it.
+func (q *Queue) Enqueue(opts Options) (*models.Job, error) {
+	payload, err := json.Marshal(opts.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding job payload: %w", err)
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	job := &models.Job{
+		ID:             uuid.New().String(),
+		OrgID:          opts.OrgID,
+		TeamID:         opts.TeamID,
+		Kind:           opts.Kind,
+		Status:         models.JobStatusQueued,
+		IdempotencyKey: opts.IdempotencyKey,
+		Payload:        models.JSON(payload),
+		MaxAttempts:    opts.MaxAttempts,
+		RunAfter:       time.Now().UTC(),
+	}
+
+	res := q.db.Clauses(clause.OnConflict{DoNothing: true}).Create(job)
+	if res.Error != nil {
+		return nil, fmt.Errorf("enqueueing job: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		var existing models.Job
+		if err := q.db.First(&existing, "idempotency_key = ?", opts.IdempotencyKey).Error; err != nil {
+			return nil, fmt.Errorf("loading duplicate job: %w", err)
+		}
+		return &existing, ErrDuplicate
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Pending returns the team's oldest job that is queued or running, or nil
+// if there is none.
+func (q *Queue) Pending(teamID string) (*models.Job, error) {
+	var job models.Job
+	res := q.db.Where("team_id = ? AND status IN ?", teamID,
+		[]string{models.JobStatusQueued, models.JobStatusRunning}).
+		Order("created_at").Limit(1).Find(&job)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	return &job, nil
+}
+
+// PendingKey returns the oldest job with one of the idempotency keys that is
+// queued or running, or nil if there is none.
+func (q *Queue) PendingKey(keys ...string) (*models.Job, error) {
+	var job models.Job
+	res := q.db.Where("idempotency_key IN ? AND status IN ?", keys,
+		[]string{models.JobStatusQueued, models.JobStatusRunning}).
+		Order("created_at").Limit(1).Find(&job)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return nil, res.Error
+	}
+	return &job, nil
+}
+
+// CancelQueued cancels the team's jobs that have not started, recording
+// reason. Running jobs are not affected.
+func (q *Queue) CancelQueued(teamID, reason string) (int64, error) {
+	now := time.Now().UTC()
+	res := q.db.Model(&models.Job{}).
+		Where("team_id = ? AND status = ?", teamID, models.JobStatusQueued).
+		Updates(map[string]interface{}{
+			"status":      models.JobStatusCancelled,
+			"error":       reason,
+			"finished_at": now,
+		})
+	return res.RowsAffected, res.Error
+}
+
+// Start starts workers goroutines running jobs, DefaultWorkers if workers
+// is not positive, and the pruning of finished jobs. Stop stops them.
+func (q *Queue) Start(workers int) {
+	if workers < 1 {
+		workers = DefaultWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go func() {
+			defer q.wg.Done()
+			q.work(ctx)
+		}()
+	}
+	q.wg.Add(1)
+	go func() {
+		defer q.wg.Done()
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+		for {
+			q.prune(time.Now().UTC())
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels the running jobs and waits for their handlers to return.
+// Their jobs are queued again, for another process or the next start to
+// run.
+func (q *Queue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+// work runs jobs until ctx is cancelled.
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.claim(time.Now().UTC())
+		if err != nil {
+			slog.Warn("jobs: failed to claim job", "error", err)
+		}
+		if job != nil {
+			q.run(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-q.wake:
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// claimable restricts db to the jobs of kinds a worker may claim at now:
+// queued jobs that are due, and running jobs whose lock expired.
+func claimable(db *gorm.DB, kinds []string, now time.Time) *gorm.DB {
+	return db.Where("kind IN ? AND ((status = ? AND run_after <= ?) OR (status = ? AND locked_until < ?))",
+		kinds, models.JobStatusQueued, now, models.JobStatusRunning, now)
+}
+
+// claim marks the next claimable job as running under this queue's lock and
+// returns it, or nil if there is none. A job interrupted on its last attempt
+// is failed instead.
+func (q *Queue) claim(now time.Time) (*models.Job, error) {
+	q.mu.RLock()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	q.mu.RUnlock()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+
+	for {
+		var job models.Job
+		res := claimable(q.db.Model(&models.Job{}), kinds, now).
+			Order("run_after, created_at").Limit(1).Find(&job)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return nil, res.Error
+		}
+
+		mine := claimable(q.db.Model(&models.Job{}).Where("id = ?", job.ID), kinds, now)
+		if job.Status == models.JobStatusRunning && job.Attempts >= job.MaxAttempts {
+			res = mine.Updates(map[string]interface{}{
+				"status":       models.JobStatusFailed,
+				"error":        "interrupted: its worker stopped",
+				"locked_by":    "",
+				"locked_until": nil,
+				"finished_at":  now,
+			})
+			if res.Error != nil {
+				return nil, res.Error
+			}
+			slog.Warn("jobs: job interrupted on its last attempt", "id", job.ID, "kind", job.Kind)
+			continue
+		}
+
+		until := now.Add(lockTTL)
+		res = mine.Updates(map[string]interface{}{
+			"status":       models.JobStatusRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    q.holder,
+			"locked_until": until,
+			"started_at":   now,
+		})
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			// Another worker claimed it first.
+			continue
+		}
+		job.Status = models.JobStatusRunning
+		job.Attempts++
+		job.LockedBy = q.holder
+		job.LockedUntil = &until
+		job.StartedAt = &now
+		return &job, nil
+	}
+}
+
+// run runs a claimed job, renewing its lock meanwhile, and records the
+// outcome.
+func (q *Queue) run(ctx context.Context, job *models.Job) {
+	q.mu.RLock()
+	h := q.handlers[job.Kind]
+	q.mu.RUnlock()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			res := q.db.Model(&models.Job{}).
+				Where("id = ? AND locked_by = ? AND status = ?", job.ID, q.holder, models.JobStatusRunning).
+				Update("locked_until", time.Now().UTC().Add(lockTTL))
+			if res.Error == nil && res.RowsAffected == 0 {
+				slog.Warn("jobs: lost the lock of a running job", "id", job.ID, "kind", job.Kind)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	slog.Info("jobs: running job", "id", job.ID, "kind", job.Kind, "attempt", job.Attempts)
+	err := call(jobCtx, h, job)
+	close(done)
+	q.finish(ctx, job, err)
+}
+
+// call runs h, turning a panic into a permanent error.
+func call(ctx context.Context, h Handler, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v", r))
+		}
+	}()
+	return h(ctx, job)
+}
+
+// finish records the outcome of a job's attempt, unless the job's lock was
+// lost to another worker.
+func (q *Queue) finish(ctx context.Context, job *models.Job, err error) {
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+	}
+	var skip *skipError
+	var permanent *permanentError
+	switch {
+	case err == nil:
+		updates["status"] = models.JobStatusSucceeded
+		updates["error"] = ""
+		updates["finished_at"] = now
+	case errors.As(err, &skip):
+		updates["status"] = models.JobStatusCancelled
+		updates["error"] = skip.reason
+		updates["finished_at"] = now
+	case ctx.Err() != nil:
+		// Stopped with the queue: hand the attempt back.
+		updates["status"] = models.JobStatusQueued
+		updates["attempts"] = gorm.Expr("attempts - 1")
+		updates["run_after"] = now
+		updates["error"] = err.Error()
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobStatusFailed
+		updates["error"] = err.Error()
+		updates["finished_at"] = now
+	default:
+		updates["status"] = models.JobStatusQueued
+		updates["run_after"] = now.Add(backoff(job.Attempts))
+		updates["error"] = err.Error()
+	}
+
+	res := q.db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ? AND status = ?", job.ID, q.holder, models.JobStatusRunning).
+		Updates(updates)
+	if res.Error != nil {
+		slog.Error("jobs: failed to record job outcome", "id", job.ID, "kind", job.Kind, "error", res.Error)
+		return
+	}
+	switch {
+	case err == nil:
+		slog.Info("jobs: job succeeded", "id", job.ID, "kind", job.Kind)
+	case skip != nil:
+		slog.Info("jobs: job cancelled", "id", job.ID, "kind", job.Kind, "reason", skip.reason)
+	default:
+		slog.Warn("jobs: job attempt failed", "id", job.ID, "kind", job.Kind,
+			"attempt", job.Attempts, "status", updates["status"], "error", err)
+	}
+}
+
+// backoff returns the wait before retrying a job that failed attempts
+// times.
+func backoff(attempts int) time.Duration {
+	d := retryBackoff
+	for i := 1; i < attempts && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
+}
+
+// prune deletes the jobs that finished more than Retention before now.
+func (q *Queue) prune(now time.Time) {
+	res := q.db.Where("status IN ? AND finished_at < ?",
+		[]string{models.JobStatusSucceeded, models.JobStatusFailed, models.JobStatusCancelled},
+		now.Add(-Retention)).Delete(&models.Job{})
+	if res.Error != nil {
+		slog.Warn("jobs: failed to prune finished jobs", "error", res.Error)
+	} else if res.RowsAffected > 0 {
+		slog.Info("jobs: pruned finished jobs", "count", res.RowsAffected)
+	}
+}
+
+// Decode unmarshals the job's payload into v.
+func Decode(job *models.Job, v interface{}) error {
+	if err := json.Unmarshal(job.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("decoding job payload: %w", err))
+	}
+	return nil
+}
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying: the job fails at once.
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return e.reason }
+
+// Skip ends a job that has nothing left to do, such as the deployment of a
+// team stopped in the meantime, as cancelled with reason.
+func Skip(reason string) error {
+	return &skipError{reason: reason}
+}
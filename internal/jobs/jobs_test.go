@@ -0,0 +1,276 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return db
+}
+
+// waitStatus waits for the job to reach status and returns it.
+func waitStatus(t *testing.T, db *gorm.DB, id, status string) models.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job models.Job
+		db.First(&job, "id = ?", id)
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job status: got %q, want %q", job.Status, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func shortenTimings(t *testing.T) {
+	t.Helper()
+	oldPoll, oldBackoff := pollInterval, retryBackoff
+	pollInterval, retryBackoff = 20*time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { pollInterval, retryBackoff = oldPoll, oldBackoff })
+}
+
+func TestQueue_RunsJob(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+	type payload struct {
+		Name string `json:"name"`
+	}
+	var got atomic.Value
+	q.Register("greet", func(ctx context.Context, job *models.Job) error {
+		var p payload
+		if err := Decode(job, &p); err != nil {
+			return err
+		}
+		got.Store(p.Name)
+		return nil
+	})
+	q.Start(1)
+	defer q.Stop()
+
+	job, err := q.Enqueue(Options{Kind: "greet", TeamID: "t1", Payload: payload{Name: "platform"}})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	done := waitStatus(t, db, job.ID, models.JobStatusSucceeded)
+	if got.Load() != "platform" {
+		t.Errorf("payload: got %v, want platform", got.Load())
+	}
+	if done.Attempts != 1 || done.FinishedAt == nil || done.LockedBy != "" {
+		t.Errorf("finished job: attempts %d, finished_at %v, locked_by %q", done.Attempts, done.FinishedAt, done.LockedBy)
+	}
+}
+
+func TestQueue_RetriesFailedJob(t *testing.T) {
+	shortenTimings(t)
+	db := setupDB(t)
+	q := New(db, "test")
+	var calls atomic.Int32
+	q.Register("flaky", func(ctx context.Context, job *models.Job) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	q.Start(1)
+	defer q.Stop()
+
+	job, _ := q.Enqueue(Options{Kind: "flaky", MaxAttempts: 3})
+	done := waitStatus(t, db, job.ID, models.JobStatusSucceeded)
+	if done.Attempts != 3 {
+		t.Errorf("attempts: got %d, want 3", done.Attempts)
+	}
+}
+
+func TestQueue_FailsJob(t *testing.T) {
+	shortenTimings(t)
+	db := setupDB(t)
+	q := New(db, "test")
+	var calls atomic.Int32
+	q.Register("broken", func(ctx context.Context, job *models.Job) error {
+		calls.Add(1)
+		return errors.New("broken")
+	})
+	q.Register("fatal", func(ctx context.Context, job *models.Job) error {
+		return Permanent(errors.New("fatal"))
+	})
+	q.Register("skipped", func(ctx context.Context, job *models.Job) error {
+		return Skip("nothing to do")
+	})
+	q.Start(1)
+	defer q.Stop()
+
+	broken, _ := q.Enqueue(Options{Kind: "broken", MaxAttempts: 2})
+	if job := waitStatus(t, db, broken.ID, models.JobStatusFailed); job.Attempts != 2 || job.Error != "broken" {
+		t.Errorf("broken: attempts %d, error %q", job.Attempts, job.Error)
+	}
+	fatal, _ := q.Enqueue(Options{Kind: "fatal", MaxAttempts: 5})
+	if job := waitStatus(t, db, fatal.ID, models.JobStatusFailed); job.Attempts != 1 {
+		t.Errorf("permanent error retried: %d attempts", job.Attempts)
+	}
+	skipped, _ := q.Enqueue(Options{Kind: "skipped"})
+	if job := waitStatus(t, db, skipped.ID, models.JobStatusCancelled); job.Error != "nothing to do" {
+		t.Errorf("skipped: error %q", job.Error)
+	}
+}
+
+func TestEnqueue_IdempotencyKey(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+
+	first, err := q.Enqueue(Options{Kind: "deploy", IdempotencyKey: "deploy/t1"})
+	if err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	again, err := q.Enqueue(Options{Kind: "deploy", IdempotencyKey: "deploy/t1"})
+	if !errors.Is(err, ErrDuplicate) || again == nil || again.ID != first.ID {
+		t.Fatalf("duplicate: got %v, %v; want the first job and ErrDuplicate", again, err)
+	}
+	// Jobs without a key never collide.
+	for range 2 {
+		if _, err := q.Enqueue(Options{Kind: "deploy"}); err != nil {
+			t.Fatalf("Enqueue without key: %v", err)
+		}
+	}
+	var n int64
+	db.Model(&models.Job{}).Count(&n)
+	if n != 3 {
+		t.Errorf("jobs: got %d, want 3", n)
+	}
+}
+
+func TestClaim_ReclaimsInterruptedJob(t *testing.T) {
+	db := setupDB(t)
+	a, b := New(db, "a"), New(db, "b")
+	for _, q := range []*Queue{a, b} {
+		q.Register("deploy", func(ctx context.Context, job *models.Job) error { return nil })
+	}
+	job, _ := a.Enqueue(Options{Kind: "deploy", MaxAttempts: 2})
+	now := time.Now().UTC()
+	if claimed, err := a.claim(now); err != nil || claimed == nil || claimed.ID != job.ID {
+		t.Fatalf("a claim: got %v, %v", claimed, err)
+	}
+	if claimed, _ := b.claim(now); claimed != nil {
+		t.Fatal("b claimed a job locked by a")
+	}
+
+	// a stopped without finishing: once its lock expires, b takes over.
+	later := now.Add(2 * lockTTL)
+	claimed, err := b.claim(later)
+	if err != nil || claimed == nil || claimed.LockedBy != "b" || claimed.Attempts != 2 {
+		t.Fatalf("b reclaim: got %+v, %v", claimed, err)
+	}
+
+	// Interrupted again on its last attempt, the job fails.
+	if claimed, _ := a.claim(later.Add(2 * lockTTL)); claimed != nil {
+		t.Fatal("job claimed beyond its attempts")
+	}
+	if got := waitStatus(t, db, job.ID, models.JobStatusFailed); got.FinishedAt == nil {
+		t.Error("interrupted job has no finished_at")
+	}
+}
+
+func TestStop_RequeuesRunningJob(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+	started := make(chan struct{})
+	q.Register("long", func(ctx context.Context, job *models.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	q.Start(1)
+
+	job, _ := q.Enqueue(Options{Kind: "long"})
+	<-started
+	q.Stop()
+
+	got := waitStatus(t, db, job.ID, models.JobStatusQueued)
+	if got.Attempts != 0 || got.LockedBy != "" {
+		t.Errorf("requeued job: attempts %d, locked_by %q", got.Attempts, got.LockedBy)
+	}
+}
+
+func TestCancelQueued(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+	job, _ := q.Enqueue(Options{Kind: "deploy", TeamID: "t1"})
+	q.Enqueue(Options{Kind: "deploy", TeamID: "t2"})
+
+	if pending, err := q.Pending("t1"); err != nil || pending == nil || pending.ID != job.ID {
+		t.Fatalf("Pending: got %v, %v", pending, err)
+	}
+	if n, err := q.CancelQueued("t1", "stopped"); err != nil || n != 1 {
+		t.Fatalf("CancelQueued: got %d, %v", n, err)
+	}
+	if pending, _ := q.Pending("t1"); pending != nil {
+		t.Error("cancelled job still pending")
+	}
+	if pending, _ := q.Pending("t2"); pending == nil {
+		t.Error("other team's job cancelled")
+	}
+}
+
+func TestPendingKey(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+	job, _ := q.Enqueue(Options{Kind: "upgrade", IdempotencyKey: "upgrade/u1"})
+	done, _ := q.Enqueue(Options{Kind: "upgrade", IdempotencyKey: "upgrade/u2"})
+	db.Model(done).Update("status", models.JobStatusSucceeded)
+
+	if pending, err := q.PendingKey("rollback/u1", "upgrade/u1"); err != nil || pending == nil || pending.ID != job.ID {
+		t.Fatalf("PendingKey: got %v, %v", pending, err)
+	}
+	if pending, _ := q.PendingKey("upgrade/u2"); pending != nil {
+		t.Error("finished job pending")
+	}
+	if pending, _ := q.PendingKey("upgrade/u3"); pending != nil {
+		t.Error("unknown key pending")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	db := setupDB(t)
+	q := New(db, "test")
+	now := time.Now().UTC()
+	old := now.Add(-Retention - time.Hour)
+	recent := now.Add(-time.Hour)
+	db.Create(&models.Job{ID: "old", Kind: "deploy", Status: models.JobStatusSucceeded, FinishedAt: &old})
+	db.Create(&models.Job{ID: "recent", Kind: "deploy", Status: models.JobStatusFailed, FinishedAt: &recent})
+	db.Create(&models.Job{ID: "queued", Kind: "deploy", Status: models.JobStatusQueued})
+
+	q.prune(now)
+	var ids []string
+	db.Model(&models.Job{}).Order("id").Pluck("id", &ids)
+	if len(ids) != 2 || ids[0] != "queued" || ids[1] != "recent" {
+		t.Errorf("jobs left: got %v, want [queued recent]", ids)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  retryBackoff,
+		2:  2 * retryBackoff,
+		3:  4 * retryBackoff,
+		20: maxRetryBackoff,
+	} {
+		if got := backoff(attempts); got != want {
+			t.Errorf("backoff(%d): got %v, want %v", attempts, got, want)
+		}
+	}
+}
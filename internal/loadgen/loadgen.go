@@ -0,0 +1,568 @@
+// Package loadgen puts load on an AgentCrew installation: it deploys a
+// number of throwaway teams, sends each leader chat messages at a steady
+// rate and reports how the API kept up.
+//
+// With a NATS URL, loadgen also plays the teams' sidecars: it subscribes to
+// each leader channel, answers every user message with a leader response
+// and announces the leaders as ready. It then matches the stored responses
+// to the chat messages, reporting relay lag and dropped messages. Run the
+// API with RUNTIME=chaos against the same NATS server, so no agent
+// containers answer in its place.
+package loadgen
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// responsePrefix starts the result of a synthetic leader response; the ID
+// of the answered user message follows it.
+const responsePrefix = "loadgen "
+
+// teardownTimeout bounds the teardown, which runs even when the run's
+// context is done.
+const teardownTimeout = 5 * time.Minute
+
+// Options configure a load run.
+type Options struct {
+	// Teams is the number of teams, each sent MessagesPerMinute chat
+	// messages for Duration.
+	Teams             int
+	MessagesPerMinute int
+	Duration          time.Duration
+	// Drain is how long to wait for responses after the last message. It
+	// defaults to 30 seconds.
+	Drain time.Duration
+	// NATSURL enables the synthetic sidecar; NATSToken authenticates it.
+	NATSURL   string
+	NATSToken string
+	// Runtime and AgentImage configure the teams; empty keeps the API's
+	// defaults.
+	Runtime    string
+	AgentImage string
+	// PollInterval is how often teams and their responses are checked. It
+	// defaults to 1 second.
+	PollInterval time.Duration
+	// Keep leaves the teams in place instead of removing them.
+	Keep bool
+}
+
+// Latency summarizes a set of durations in milliseconds.
+type Latency struct {
+	Count int     `json:"count"`
+	AvgMs float64 `json:"avg_ms"`
+	P50Ms float64 `json:"p50_ms"`
+	P95Ms float64 `json:"p95_ms"`
+	P99Ms float64 `json:"p99_ms"`
+	MaxMs float64 `json:"max_ms"`
+}
+
+// DBWrites is the TaskLog writer activity during a run.
+type DBWrites struct {
+	Batches    int64   `json:"batches"`
+	Rows       int64   `json:"rows"`
+	Errors     int64   `json:"errors"`
+	AvgBatchMs float64 `json:"avg_batch_ms"`
+	// MaxBatchMs is the longest batch since the API started.
+	MaxBatchMs float64 `json:"max_batch_ms"`
+	Queued     int     `json:"queued"`
+}
+
+// Report is the machine-readable result of a load run. The delivery,
+// lag and drop figures need the synthetic sidecar; without it only the
+// stored leader responses are counted.
+type Report struct {
+	Teams             int       `json:"teams"`
+	MessagesPerMinute int       `json:"messages_per_minute"`
+	Sidecar           bool      `json:"sidecar"`
+	StartedAt         time.Time `json:"started_at"`
+	DurationMs        int64     `json:"duration_ms"`
+
+	ChatsSent          int `json:"chats_sent"`
+	ChatsQueued        int `json:"chats_queued"`
+	ChatErrors         int `json:"chat_errors"`
+	Delivered          int `json:"delivered"`
+	ResponsesPublished int `json:"responses_published"`
+	ResponsesStored    int `json:"responses_stored"`
+	// DroppedChats were accepted by the API but never reached a leader;
+	// DroppedResponses were published by a leader but never stored.
+	DroppedChats     int `json:"dropped_chats"`
+	DroppedResponses int `json:"dropped_responses"`
+
+	// ChatLatency is the time the API took to accept a chat message,
+	// RelayLag the time from a published response to its stored log and
+	// RoundTrip the time from a chat message to its stored response.
+	ChatLatency Latency `json:"chat_latency"`
+	RelayLag    Latency `json:"relay_lag"`
+	RoundTrip   Latency `json:"round_trip"`
+
+	DBWrites *DBWrites `json:"db_writes,omitempty"`
+	Errors   []string  `json:"errors,omitempty"`
+}
+
+// Run deploys the teams, puts the load on them and removes them again.
+// It returns an error if the teams could not be set up; the teams created
+// so far are removed in that case too.
+func Run(ctx context.Context, api *client.Client, opts Options) (*Report, error) {
+	if opts.Teams <= 0 || opts.MessagesPerMinute <= 0 || opts.Duration <= 0 {
+		return nil, fmt.Errorf("teams, messages per minute and duration must be positive")
+	}
+	if opts.Drain <= 0 {
+		opts.Drain = 30 * time.Second
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	r := &runner{api: api, opts: opts, report: &Report{
+		Teams:             opts.Teams,
+		MessagesPerMinute: opts.MessagesPerMinute,
+		Sidecar:           opts.NATSURL != "",
+		StartedAt:         time.Now().UTC(),
+	}}
+	defer r.teardown(ctx)
+
+	before, err := api.DBStats(ctx)
+	if err != nil {
+		r.errorf("database stats: %v", err)
+	}
+	if err := r.setup(ctx, "loadgen-"+randomSuffix()); err != nil {
+		return nil, err
+	}
+	r.load(ctx)
+	r.finish()
+	if before != nil {
+		if after, err := api.DBStats(ctx); err != nil {
+			r.errorf("database stats: %v", err)
+		} else {
+			r.report.DBWrites = dbWrites(before, after)
+		}
+	}
+	r.report.DurationMs = time.Since(r.report.StartedAt).Milliseconds()
+	return r.report, nil
+}
+
+type runner struct {
+	api    *client.Client
+	opts   Options
+	report *Report
+
+	mu          sync.Mutex
+	teams       []*team
+	chatLatency []time.Duration
+	relayLag    []time.Duration
+	roundTrip   []time.Duration
+}
+
+// team tracks one team's chat messages by the ID of their user message.
+type team struct {
+	id, name, slug string
+	nc             *agentNats.Client
+
+	sent      map[string]time.Time // accepted by the API
+	delivered map[string]bool      // received by the synthetic sidecar
+	published map[string]time.Time // answered by the synthetic sidecar
+	stored    map[string]bool      // leader responses stored by the API
+}
+
+func (r *runner) errorf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.report.Errors = append(r.report.Errors, fmt.Sprintf(format, args...))
+}
+
+// setup creates and deploys the teams and, with the synthetic sidecar,
+// subscribes to their leader channels and announces their leaders.
+func (r *runner) setup(ctx context.Context, prefix string) error {
+	for i := range r.opts.Teams {
+		created, err := r.api.CreateTeam(ctx, client.TeamInput{
+			Name:        fmt.Sprintf("%s-%d", prefix, i),
+			Description: "Throwaway team created by loadgen",
+			Runtime:     r.opts.Runtime,
+			AgentImage:  r.opts.AgentImage,
+		})
+		if err != nil {
+			return fmt.Errorf("creating team: %w", err)
+		}
+		t := &team{
+			id: created.ID, name: created.Name, slug: created.Slug,
+			sent:      make(map[string]time.Time),
+			delivered: make(map[string]bool),
+			published: make(map[string]time.Time),
+			stored:    make(map[string]bool),
+		}
+		if t.slug == "" {
+			t.slug = naming.Slug(t.name)
+		}
+		r.teams = append(r.teams, t)
+		if _, err := r.api.CreateAgent(ctx, t.id, client.AgentInput{Name: "leader", Role: models.AgentRoleLeader}); err != nil {
+			return fmt.Errorf("creating leader of %s: %w", t.name, err)
+		}
+		if r.opts.NATSURL != "" {
+			if err := r.connectSidecar(ctx, t); err != nil {
+				return fmt.Errorf("sidecar of %s: %w", t.name, err)
+			}
+		}
+	}
+
+	for _, t := range r.teams {
+		if _, err := r.api.DeployTeam(ctx, t.id); err != nil {
+			return fmt.Errorf("deploying %s: %w", t.name, err)
+		}
+	}
+	for _, t := range r.teams {
+		if err := r.waitRunning(ctx, t); err != nil {
+			return fmt.Errorf("deploying %s: %w", t.name, err)
+		}
+		if t.nc != nil {
+			if err := announceReady(t); err != nil {
+				return fmt.Errorf("announcing leader of %s: %w", t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *runner) waitRunning(ctx context.Context, t *team) error {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		got, err := r.api.GetTeam(ctx, t.id)
+		if err != nil {
+			return err
+		}
+		switch got.Status {
+		case models.TeamStatusRunning:
+			return nil
+		case models.TeamStatusDeploying:
+		default:
+			return fmt.Errorf("team is %s: %s", got.Status, got.StatusMessage)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// connectSidecar subscribes to the team's leader channel and answers each
+// user message with a completed leader response naming it.
+func (r *runner) connectSidecar(ctx context.Context, t *team) error {
+	cfg := agentNats.DefaultConfig(r.opts.NATSURL, "loadgen-"+t.slug)
+	cfg.Token = r.opts.NATSToken
+	nc, err := agentNats.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	t.nc = nc
+	if err := nc.EnsureStream(ctx, t.slug, agentNats.StreamLimits{}); err != nil {
+		return err
+	}
+	subject, err := protocol.TeamLeaderChannel(t.slug)
+	if err != nil {
+		return err
+	}
+	return nc.Subscribe(subject, func(msg *protocol.Message) {
+		if msg.Type != protocol.TypeUserMessage {
+			return
+		}
+		chatID := msg.MessageID
+		r.mu.Lock()
+		seen := t.delivered[chatID]
+		t.delivered[chatID] = true
+		r.mu.Unlock()
+		if seen {
+			return
+		}
+
+		resp, err := protocol.NewMessage("leader", "user", protocol.TypeLeaderResponse, protocol.LeaderResponsePayload{
+			Status: "completed",
+			Result: responsePrefix + chatID,
+		})
+		if err != nil {
+			return
+		}
+		resp.RefMessageID = chatID
+		if err := nc.Publish(subject, resp); err != nil {
+			r.errorf("publishing response in %s: %v", t.name, err)
+			return
+		}
+		r.mu.Lock()
+		t.published[chatID] = resp.Timestamp
+		r.mu.Unlock()
+	})
+}
+
+// announceReady tells the relay the team's leader accepts messages.
+func announceReady(t *team) error {
+	msg, err := protocol.NewMessage("leader", "system", protocol.TypeAgentReady, protocol.AgentReadyPayload{
+		AgentName: "leader",
+		Role:      models.AgentRoleLeader,
+	})
+	if err != nil {
+		return err
+	}
+	subject, err := protocol.TeamActivityChannel(t.slug)
+	if err != nil {
+		return err
+	}
+	return t.nc.Publish(subject, msg)
+}
+
+// load sends every team's messages for the configured duration, then
+// waits for the outstanding responses until the drain ends.
+func (r *runner) load(ctx context.Context) {
+	sendCtx, cancel := context.WithTimeout(ctx, r.opts.Duration)
+	defer cancel()
+	interval := time.Minute / time.Duration(r.opts.MessagesPerMinute)
+
+	var wg sync.WaitGroup
+	for _, t := range r.teams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for seq := 0; ; seq++ {
+				r.sendChat(ctx, t, seq)
+				select {
+				case <-sendCtx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	pollCtx, stopPolling := context.WithCancel(ctx)
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		r.pollResponses(pollCtx)
+	}()
+
+	wg.Wait()
+	r.waitDrained(ctx)
+	stopPolling()
+	<-polled
+	r.collect(ctx)
+}
+
+// waitDrained waits until every accepted chat message was answered and
+// every response stored, or the drain ends. Without the synthetic sidecar
+// nothing is awaited.
+func (r *runner) waitDrained(ctx context.Context) {
+	if r.opts.NATSURL == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Drain)
+	defer cancel()
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for !r.drained() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *runner) sendChat(ctx context.Context, t *team, seq int) {
+	start := time.Now()
+	res, err := r.api.SendChat(ctx, t.id, fmt.Sprintf("loadgen message %d", seq))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil || res.ID == "" || (res.Status != "sent" && res.Status != models.ChatDeliveryQueued) {
+		r.report.ChatErrors++
+		return
+	}
+	r.chatLatency = append(r.chatLatency, time.Since(start))
+	r.report.ChatsSent++
+	if res.Status == models.ChatDeliveryQueued {
+		r.report.ChatsQueued++
+	}
+	t.sent[res.ID] = start
+}
+
+// drained reports whether a response was stored for every accepted chat
+// message.
+func (r *runner) drained() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.teams {
+		if len(t.stored) < len(t.sent) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *runner) pollResponses(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.collect(ctx)
+		}
+	}
+}
+
+// collect records the leader responses stored since the last poll.
+func (r *runner) collect(ctx context.Context) {
+	for _, t := range r.teams {
+		logs, err := r.api.ListMessages(ctx, t.id, string(protocol.TypeLeaderResponse))
+		if err != nil {
+			if ctx.Err() == nil {
+				r.errorf("listing responses of %s: %v", t.name, err)
+			}
+			continue
+		}
+		r.mu.Lock()
+		r.recordResponses(t, logs)
+		r.mu.Unlock()
+	}
+}
+
+// recordResponses records responses not seen before. Synthetic responses
+// are keyed by the user message they answer; other responses, from real
+// leaders, by their own ID. Callers hold r.mu.
+func (r *runner) recordResponses(t *team, logs []models.TaskLog) {
+	for _, l := range logs {
+		if l.MessageType != string(protocol.TypeLeaderResponse) || l.CreatedAt.Before(r.report.StartedAt) {
+			continue
+		}
+		key := l.ID
+		var payload protocol.LeaderResponsePayload
+		if json.Unmarshal(l.Payload, &payload) == nil && strings.HasPrefix(payload.Result, responsePrefix) {
+			key = strings.TrimPrefix(payload.Result, responsePrefix)
+		}
+		if t.stored[key] {
+			continue
+		}
+		t.stored[key] = true
+		if published, ok := t.published[key]; ok {
+			r.relayLag = append(r.relayLag, l.CreatedAt.Sub(published))
+		}
+		if sent, ok := t.sent[key]; ok {
+			r.roundTrip = append(r.roundTrip, l.CreatedAt.Sub(sent))
+		}
+	}
+}
+
+// finish fills in the report's counts and latencies.
+func (r *runner) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep := r.report
+	for _, t := range r.teams {
+		rep.ResponsesStored += len(t.stored)
+		if t.nc == nil {
+			continue
+		}
+		rep.ResponsesPublished += len(t.published)
+		for id := range t.sent {
+			if t.delivered[id] {
+				rep.Delivered++
+			} else {
+				rep.DroppedChats++
+			}
+		}
+		for id := range t.published {
+			if !t.stored[id] {
+				rep.DroppedResponses++
+			}
+		}
+	}
+	rep.ChatLatency = summarize(r.chatLatency)
+	rep.RelayLag = summarize(r.relayLag)
+	rep.RoundTrip = summarize(r.roundTrip)
+}
+
+// teardown closes the synthetic sidecars and removes the teams.
+func (r *runner) teardown(ctx context.Context) {
+	for _, t := range r.teams {
+		if t.nc != nil {
+			t.nc.Close()
+		}
+	}
+	if r.opts.Keep {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), teardownTimeout)
+	defer cancel()
+	for _, t := range r.teams {
+		if err := r.api.DeleteTeam(ctx, t.id, client.DeleteTeamOptions{Force: true}); err != nil {
+			r.errorf("removing %s: %v", t.name, err)
+		}
+	}
+}
+
+// dbWrites returns the TaskLog writer activity between two reports.
+func dbWrites(before, after *client.DBStats) *DBWrites {
+	b, a := before.TaskLogWriter, after.TaskLogWriter
+	w := &DBWrites{
+		Batches:    a.Batches - b.Batches,
+		Rows:       a.Rows - b.Rows,
+		Errors:     a.Errors - b.Errors,
+		MaxBatchMs: a.MaxBatchMs,
+		Queued:     a.Queued,
+	}
+	if w.Batches > 0 {
+		w.AvgBatchMs = round((a.WriteMs - b.WriteMs) / float64(w.Batches))
+	}
+	return w
+}
+
+// summarize returns the nearest-rank percentiles of durations.
+func summarize(durations []time.Duration) Latency {
+	if len(durations) == 0 {
+		return Latency{}
+	}
+	ms := make([]float64, len(durations))
+	var sum float64
+	for i, d := range durations {
+		ms[i] = float64(d) / float64(time.Millisecond)
+		sum += ms[i]
+	}
+	sort.Float64s(ms)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p/100*float64(len(ms)))) - 1
+		return round(ms[max(i, 0)])
+	}
+	return Latency{
+		Count: len(ms),
+		AvgMs: round(sum / float64(len(ms))),
+		P50Ms: rank(50),
+		P95Ms: rank(95),
+		P99Ms: rank(99),
+		MaxMs: round(ms[len(ms)-1]),
+	}
+}
+
+func round(ms float64) float64 {
+	return math.Round(ms*100) / 100
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
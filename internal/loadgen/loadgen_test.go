@@ -0,0 +1,147 @@
+package loadgen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// fakeAPI serves the endpoints a load run calls. Every chat message is
+// answered by a stored leader response right away.
+type fakeAPI struct {
+	mu        sync.Mutex
+	teams     int
+	chats     int
+	responses map[string][]models.TaskLog
+	deleted   []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/teams/"), "/")
+	switch {
+	case r.URL.Path == "/api/admin/db":
+		w.Write([]byte(`{"task_log_writer":{"batches":` + fmt.Sprint(f.chats) + `,"rows":` + fmt.Sprint(2*f.chats) + `,"write_ms":` + fmt.Sprint(3*f.chats) + `}}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/teams":
+		f.teams++
+		json.NewEncoder(w).Encode(map[string]string{"id": fmt.Sprintf("t%d", f.teams), "status": "stopped"})
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "agents":
+		w.Write([]byte(`{"id":"a1","role":"leader"}`))
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "deploy":
+		w.Write([]byte(`{"status":"deploying"}`))
+	case r.Method == http.MethodGet && len(parts) == 1:
+		w.Write([]byte(`{"status":"running"}`))
+	case r.Method == http.MethodPost && len(parts) == 2 && parts[1] == "chat":
+		f.chats++
+		id := fmt.Sprintf("m%d", f.chats)
+		payload, _ := json.Marshal(protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+		f.responses[parts[0]] = append([]models.TaskLog{{
+			ID: "r" + id, MessageType: "leader_response", Payload: payload, CreatedAt: time.Now(),
+		}}, f.responses[parts[0]]...)
+		json.NewEncoder(w).Encode(map[string]string{"status": "sent", "id": id})
+	case r.Method == http.MethodGet && len(parts) == 2 && parts[1] == "messages":
+		json.NewEncoder(w).Encode(map[string]any{"items": f.responses[parts[0]]})
+	case r.Method == http.MethodDelete && len(parts) == 1:
+		f.deleted = append(f.deleted, parts[0])
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	}
+}
+
+func TestRun_WithoutSidecar(t *testing.T) {
+	f := &fakeAPI{responses: make(map[string][]models.TaskLog)}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	report, err := Run(context.Background(), client.New(srv.URL, ""), Options{
+		Teams:             2,
+		MessagesPerMinute: 6000,
+		Duration:          50 * time.Millisecond,
+		PollInterval:      10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if report.ChatsSent == 0 || report.ChatsSent != f.chats || report.ChatErrors != 0 {
+		t.Errorf("chats: sent %d errors %d, want %d sent", report.ChatsSent, report.ChatErrors, f.chats)
+	}
+	if report.ResponsesStored != f.chats {
+		t.Errorf("responses stored: got %d, want %d", report.ResponsesStored, f.chats)
+	}
+	if report.ChatLatency.Count != report.ChatsSent || report.RelayLag.Count != 0 || report.DroppedChats != 0 {
+		t.Errorf("latencies: got %+v", report)
+	}
+	if report.DBWrites == nil || report.DBWrites.Batches != int64(f.chats) || report.DBWrites.AvgBatchMs != 3 {
+		t.Errorf("db writes: got %+v", report.DBWrites)
+	}
+	if len(f.deleted) != 2 {
+		t.Errorf("deleted teams: got %v", f.deleted)
+	}
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	if _, err := Run(context.Background(), client.New("http://localhost", ""), Options{Teams: 1}); err == nil {
+		t.Error("want an error without a rate and duration")
+	}
+}
+
+func TestRecordResponses(t *testing.T) {
+	start := time.Now()
+	r := &runner{report: &Report{StartedAt: start}}
+	tm := &team{
+		sent:      map[string]time.Time{"m1": start, "m2": start},
+		published: map[string]time.Time{"m1": start.Add(100 * time.Millisecond)},
+		stored:    make(map[string]bool),
+	}
+	payload := func(result string) []byte {
+		data, _ := json.Marshal(protocol.LeaderResponsePayload{Status: "completed", Result: result})
+		return data
+	}
+	logs := []models.TaskLog{
+		{ID: "r1", MessageType: "leader_response", Payload: payload("loadgen m1"), CreatedAt: start.Add(150 * time.Millisecond)},
+		{ID: "r0", MessageType: "leader_response", Payload: payload("loadgen m0"), CreatedAt: start.Add(-time.Second)},
+	}
+
+	r.recordResponses(tm, logs)
+	r.recordResponses(tm, logs)
+
+	if len(tm.stored) != 1 || !tm.stored["m1"] {
+		t.Errorf("stored: got %v, want m1 only", tm.stored)
+	}
+	if len(r.relayLag) != 1 || r.relayLag[0] != 50*time.Millisecond {
+		t.Errorf("relay lag: got %v", r.relayLag)
+	}
+	if len(r.roundTrip) != 1 || r.roundTrip[0] != 150*time.Millisecond {
+		t.Errorf("round trip: got %v", r.roundTrip)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	got := summarize(durations)
+	want := Latency{Count: 100, AvgMs: 50.5, P50Ms: 50, P95Ms: 95, P99Ms: 99, MaxMs: 100}
+	if got != want {
+		t.Errorf("summarize: got %+v, want %+v", got, want)
+	}
+	if got := summarize(nil); got != (Latency{}) {
+		t.Errorf("summarize(nil): got %+v", got)
+	}
+}
@@ -3,16 +3,36 @@ package models
 import (
 	"fmt"
 	"log/slog"
+	"strings"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+
+	"github.com/helmcode/agent-crew/internal/permissions"
 )
 
+// SQLiteBusyTimeoutMs is how long a connection waits on a locked database
+// before failing with "database is locked".
+const SQLiteBusyTimeoutMs = 5000
+
+// sqliteDSN adds the connection parameters every pooled connection needs.
+// PRAGMAs run with Exec only reach one connection of the pool, so WAL, the
+// busy timeout and foreign keys are set through the DSN instead. Write
+// transactions take the lock up front (_txlock=immediate) so that concurrent
+// writers wait on busy_timeout rather than failing on a lock upgrade.
+func sqliteDSN(dbPath string) string {
+	if dbPath == ":memory:" || strings.Contains(dbPath, "?") {
+		return dbPath
+	}
+	return fmt.Sprintf("%s?_journal_mode=WAL&_busy_timeout=%d&_synchronous=NORMAL&_foreign_keys=on&_txlock=immediate",
+		dbPath, SQLiteBusyTimeoutMs)
+}
+
 // InitDB opens an SQLite database at dbPath and auto-migrates all models.
 // Pass ":memory:" for an in-memory database (useful for testing).
 func InitDB(dbPath string) (*gorm.DB, error) {
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(dbPath)), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
@@ -24,12 +44,20 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("getting underlying sql.DB: %w", err)
 	}
+	// Every connection to :memory: opens a database of its own, so keep a
+	// single one for background goroutines to see the same data.
+	if dbPath == ":memory:" {
+		sqlDB.SetMaxOpenConns(1)
+	}
 	if _, err := sqlDB.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		slog.Warn("failed to enable WAL mode", "error", err)
 	}
 	if _, err := sqlDB.Exec("PRAGMA foreign_keys=ON"); err != nil {
 		slog.Warn("failed to enable foreign keys", "error", err)
 	}
+	if _, err := sqlDB.Exec(fmt.Sprintf("PRAGMA busy_timeout=%d", SQLiteBusyTimeoutMs)); err != nil {
+		slog.Warn("failed to set busy timeout", "error", err)
+	}
 
 	// Rename claude_md → instructions_md if the old column exists (backward compat migration).
 	if db.Migrator().HasColumn(&Agent{}, "claude_md") {
@@ -50,10 +78,68 @@ func InitDB(dbPath string) (*gorm.DB, error) {
 		slog.Info("settings table migrated")
 	}
 
-	if err := db.AutoMigrate(&Organization{}, &User{}, &Invite{}, &Team{}, &Agent{}, &TaskLog{}, &Settings{}, &Schedule{}, &ScheduleRun{}, &Webhook{}, &WebhookRun{}, &PostAction{}, &PostActionBinding{}, &PostActionRun{}, &SharedInfra{}, &Document{}); err != nil {
+	// Task logs saved before event_type and tool_name were columns carry
+	// them only in the payload; copy them once the columns exist.
+	backfillActivity := db.Migrator().HasTable(&TaskLog{}) && !db.Migrator().HasColumn(&TaskLog{}, "tool_name")
+
+	if err := db.AutoMigrate(&Organization{}, &User{}, &Invite{}, &Team{}, &Agent{}, &TaskLog{}, &Settings{}, &Schedule{}, &ScheduleRun{}, &Webhook{}, &WebhookRun{}, &ShareLink{}, &PostAction{}, &PostActionBinding{}, &PostActionRun{}, &SharedInfra{}, &Document{}, &PromptTemplate{}, &IssueIntegration{}, &IssueLink{}, &AlertIntegration{}, &TeamAlert{}, &DeadLetter{}, &ResourcePolicy{}, &AgentUpgrade{}, &AgentUpgradeResult{}, &CustomTool{}, &SkillCatalogEntry{}, &SkillToolRequirement{}, &UsageRecord{}, &RunPlan{}, &ProposedPatch{}, &Evaluation{}, &EvaluationRun{}, &EvaluationResult{}, &TeamRevision{}, &Deployment{}, &RunReplay{}, &AgentProvenance{}, &TeamMemory{}, &KnowledgeEntry{}, &ContentPolicy{}, &PolicyViolation{}, &Lease{}, &PreviewSession{}, &RateLimitBucket{}, &RateLimitTicket{}, &RelayState{}, &Job{}, &Approval{}, &QuotaEvent{}); err != nil {
 		return nil, fmt.Errorf("auto-migrating models: %w", err)
 	}
 
+	migrateLegacyFilesystemScope(db)
+	if backfillActivity {
+		backfillActivityColumns(db)
+	}
+
+	// idx_tasklog_team_created (team_id, created_at) is superseded by
+	// idx_tasklog_team_created_type, which also covers the id tie-breaker.
+	if db.Migrator().HasIndex(&TaskLog{}, "idx_tasklog_team_created") {
+		if err := db.Migrator().DropIndex(&TaskLog{}, "idx_tasklog_team_created"); err != nil {
+			slog.Warn("failed to drop superseded task log index", "error", err)
+		}
+	}
+
 	slog.Info("database initialized", "path", dbPath)
 	return db, nil
 }
+
+// migrateLegacyFilesystemScope rewrites agent permissions that still store
+// filesystem_scope as a single path string into the {path, mode} list form.
+func migrateLegacyFilesystemScope(db *gorm.DB) {
+	var agents []Agent
+	if err := db.Select("id", "permissions").
+		Where("permissions LIKE ?", `%"filesystem_scope":"%`).Find(&agents).Error; err != nil {
+		slog.Warn("failed to scan agents for legacy filesystem_scope", "error", err)
+		return
+	}
+	for _, a := range agents {
+		normalized, err := permissions.NormalizePermissionsJSON(a.Permissions)
+		if err != nil {
+			slog.Warn("skipping invalid agent permissions", "agent_id", a.ID, "error", err)
+			continue
+		}
+		if err := db.Model(&Agent{}).Where("id = ?", a.ID).
+			Update("permissions", JSON(normalized)).Error; err != nil {
+			slog.Warn("failed to migrate agent filesystem_scope", "agent_id", a.ID, "error", err)
+		}
+	}
+	if len(agents) > 0 {
+		slog.Info("migrated legacy filesystem_scope", "agents", len(agents))
+	}
+}
+
+// backfillActivityColumns sets event_type and tool_name of stored activity
+// events from their payload.
+func backfillActivityColumns(db *gorm.DB) {
+	res := db.Exec(`UPDATE task_logs
+		SET event_type = COALESCE(json_extract(payload, '$.event_type'), ''),
+			tool_name = COALESCE(json_extract(payload, '$.tool_name'), '')
+		WHERE message_type = 'activity_event' AND json_valid(payload)`)
+	if res.Error != nil {
+		slog.Warn("failed to backfill activity event columns", "error", res.Error)
+		return
+	}
+	if res.RowsAffected > 0 {
+		slog.Info("backfilled activity event columns", "task_logs", res.RowsAffected)
+	}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DeploymentAgent is an agent of a Deployment. The container fields are
+// set for the agents deployed in a container of their own: the leader.
+type DeploymentAgent struct {
+	Name        string `json:"name"`
+	Role        string `json:"role"`
+	ContainerID string `json:"container_id,omitempty"`
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"image_digest,omitempty"`
+}
+
+// StartDeployment records the start of a deployment of team and its agents.
+func StartDeployment(db *gorm.DB, team Team, trigger, triggeredBy string) (*Deployment, error) {
+	agents := make([]DeploymentAgent, 0, len(team.Agents))
+	for _, a := range team.Agents {
+		agents = append(agents, DeploymentAgent{Name: a.Name, Role: a.Role})
+	}
+	data, _ := json.Marshal(agents)
+	d := &Deployment{
+		ID:          uuid.New().String(),
+		OrgID:       team.OrgID,
+		TeamID:      team.ID,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Runtime:     team.Runtime,
+		Status:      DeploymentStatusRunning,
+		Agents:      JSON(data),
+		StartedAt:   time.Now(),
+	}
+	if err := db.Create(d).Error; err != nil {
+		return nil, fmt.Errorf("saving deployment: %w", err)
+	}
+	return d, nil
+}
+
+// SetContainer records the container an agent of the deployment was
+// deployed in and the image it runs. Nothing is recorded for a nil
+// deployment, one that could not be saved.
+func (d *Deployment) SetContainer(agentName, containerID, image, digest string) {
+	if d == nil {
+		return
+	}
+	var agents []DeploymentAgent
+	_ = json.Unmarshal(d.Agents, &agents)
+	for i := range agents {
+		if agents[i].Name == agentName {
+			agents[i].ContainerID = containerID
+			agents[i].Image = image
+			agents[i].ImageDigest = digest
+		}
+	}
+	data, _ := json.Marshal(agents)
+	d.Agents = JSON(data)
+}
+
+// FinishDeployment records the outcome of a deployment: succeeded if
+// deployErr is empty, failed with it otherwise. The deployment's
+// TeamRevision and agents are saved with it.
+func FinishDeployment(db *gorm.DB, d *Deployment, deployErr string) error {
+	if d == nil {
+		return nil
+	}
+	now := time.Now()
+	d.Status = DeploymentStatusSucceeded
+	if deployErr != "" {
+		d.Status = DeploymentStatusFailed
+	}
+	d.Error = deployErr
+	d.FinishedAt = &now
+	d.DurationMs = now.Sub(d.StartedAt).Milliseconds()
+	return db.Model(d).Updates(map[string]interface{}{
+		"status":        d.Status,
+		"error":         d.Error,
+		"team_revision": d.TeamRevision,
+		"agents":        d.Agents,
+		"finished_at":   d.FinishedAt,
+		"duration_ms":   d.DurationMs,
+	}).Error
+}
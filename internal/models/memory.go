@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// LatestTeamMemory returns the summary of the latest TeamMemory of a team,
+// or "" if it has none.
+func LatestTeamMemory(db *gorm.DB, teamID string) string {
+	var memory TeamMemory
+	if err := db.Where("team_id = ?", teamID).Order("created_at DESC").
+		Limit(1).Find(&memory).Error; err != nil {
+		return ""
+	}
+	return memory.Summary
+}
@@ -5,7 +5,12 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
+	"regexp"
 	"time"
+
+	"github.com/helmcode/agent-crew/internal/failures"
+	"github.com/helmcode/agent-crew/internal/segments"
 )
 
 // JSON is a custom type that stores JSON data as a string in SQLite.
@@ -48,11 +53,17 @@ func (j *JSON) UnmarshalJSON(data []byte) error {
 
 // Organization represents a tenant in the multi-tenant system.
 type Organization struct {
-	ID        string    `gorm:"primaryKey;size:36" json:"id"`
-	Name      string    `gorm:"not null;size:255" json:"name"`
-	Slug      string    `gorm:"uniqueIndex;not null;size:255" json:"slug"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID   string `gorm:"primaryKey;size:36" json:"id"`
+	Name string `gorm:"not null;size:255" json:"name"`
+	Slug string `gorm:"uniqueIndex;not null;size:255" json:"slug"`
+	// Maintenance blocks new deploys, team operations and runs in the
+	// organization, which are refused with MaintenanceMessage.
+	Maintenance        bool       `json:"maintenance"`
+	MaintenanceMessage string     `gorm:"type:text" json:"maintenance_message,omitempty"`
+	MaintenanceSince   *time.Time `json:"maintenance_since,omitempty"`
+	MaintenanceBy      string     `gorm:"size:255" json:"maintenance_by,omitempty"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
 }
 
 // User represents a user belonging to an organization.
@@ -70,10 +81,12 @@ type User struct {
 	Organization       Organization `gorm:"foreignKey:OrgID;constraint:OnDelete:CASCADE" json:"-"`
 }
 
-// Valid user roles.
+// Valid user roles. Observers have read-only access: they can watch teams,
+// their messages and activity, but cannot chat, deploy or change anything.
 const (
-	UserRoleAdmin  = "admin"
-	UserRoleMember = "member"
+	UserRoleAdmin    = "admin"
+	UserRoleMember   = "member"
+	UserRoleObserver = "observer"
 )
 
 // Invite represents an invitation to join an organization.
@@ -108,14 +121,82 @@ type Team struct {
 	ID            string    `gorm:"primaryKey;size:36" json:"id"`
 	OrgID         string    `gorm:"size:36;uniqueIndex:idx_team_org_name" json:"org_id"`
 	Name          string    `gorm:"not null;size:255;uniqueIndex:idx_team_org_name" json:"name"`
+	// Slug is the sanitized name from which container, network and
+	// namespace names and NATS subjects are derived. It is unique across
+	// organizations because those names share the runtime. Empty only for
+	// legacy teams whose slug collided when slugs were backfilled.
+	Slug          string    `gorm:"size:62;uniqueIndex:idx_team_slug,where:slug <> ''" json:"slug"`
 	Description   string    `gorm:"size:1024" json:"description"`
 	Status        string    `gorm:"not null;size:50;default:stopped" json:"status"`
 	StatusMessage string    `gorm:"type:text" json:"status_message"`
+	// StatusFailure explains a failed deployment in the request's locale.
+	// Not stored.
+	StatusFailure *failures.Failure `gorm:"-" json:"status_failure,omitempty"`
 	Runtime       string    `gorm:"not null;size:50;default:docker" json:"runtime"`
 	Provider      string    `gorm:"type:varchar(50);default:'claude'" json:"provider"`
 	ModelProvider string    `gorm:"size:50" json:"model_provider"`
 	WorkspacePath string    `gorm:"size:512" json:"workspace_path"`
 	AgentImage    string    `gorm:"size:512" json:"agent_image"`
+	ConfigDirMode string    `gorm:"size:20;default:'inline'" json:"config_dir_mode"`
+	// ResourcePreset names the organization's resource preset applied to
+	// the leader container (see ResourcePolicy). Empty means no preset.
+	ResourcePreset string   `gorm:"size:50" json:"resource_preset"`
+	// NamespaceConfig holds the labels, annotations and quota applied to the
+	// team's Kubernetes namespace (see runtime.NamespaceConfig).
+	NamespaceConfig JSON    `gorm:"type:text" json:"namespace_config"`
+	// RestartPolicy is how the runtime restarts the team's containers when
+	// they exit (see runtime.RestartPolicy). Empty never restarts agents.
+	RestartPolicy JSON      `gorm:"type:text" json:"restart_policy"`
+	// HostSelector lists the labels a Docker host needs for the team to be
+	// placed on it, and DockerHost is the host the team was placed on. The
+	// team stays there, with its workspace volume. Multi-host Docker only.
+	HostSelector JSON       `gorm:"type:text" json:"host_selector"`
+	DockerHost   string     `gorm:"size:63" json:"docker_host"`
+	// Labels are free-form key/value pairs (e.g. project, cost center) that
+	// cost reports can group by.
+	Labels JSON             `gorm:"type:text" json:"labels"`
+	// Bootstrap is the environment bootstrap script the leader's sidecar
+	// runs before the agent starts (see protocol.BootstrapConfig).
+	Bootstrap JSON          `gorm:"type:text" json:"bootstrap"`
+	// ExtraWorkspaces lists host directories mounted next to the workspace
+	// (see protocol.ExtraWorkspace).
+	ExtraWorkspaces JSON    `gorm:"type:text" json:"extra_workspaces"`
+	// WorkspaceTemplate names the starting structure scaffolded into the
+	// team's empty workspace on deploy (see runtime.WorkspaceTemplates).
+	WorkspaceTemplate string `gorm:"size:50" json:"workspace_template"`
+	// MaxConcurrentRuns caps the runs the leader's sidecar takes on at once,
+	// running or waiting. Zero means no limit.
+	MaxConcurrentRuns int     `json:"max_concurrent_runs"`
+	// TelemetryLevel selects which activity events the leader's sidecar
+	// publishes, and so how many activity TaskLogs the team writes. Empty
+	// means protocol.DefaultTelemetryLevel.
+	TelemetryLevel string     `gorm:"size:20" json:"telemetry_level"`
+	// PlanApproval makes the leader answer each chat message with a plan,
+	// using read-only tools, and wait for its approval before carrying it out.
+	PlanApproval bool `json:"plan_approval"`
+	// Memory makes the leader summarize what to remember when the team
+	// stops; the latest TeamMemory is given to it on the next deploy.
+	Memory bool `json:"memory"`
+	// KnowledgeBase indexes the leader's completed run results into the
+	// team's own knowledge base, which the leader can search.
+	KnowledgeBase bool `json:"knowledge_base"`
+	// WorkingHours is the window scheduled runs are limited to, and outside
+	// which the team may be suspended (see workhours.Policy). Empty means
+	// no limit.
+	WorkingHours JSON `gorm:"type:text" json:"working_hours"`
+	// RequiresDeployApproval holds deploys, migrations and leader restarts
+	// requested by members for an admin's approval (see Approval).
+	RequiresDeployApproval bool `json:"requires_deploy_approval"`
+	// ConversationID identifies the leader session started by the latest
+	// deploy. Chat and relay TaskLogs are stamped with it.
+	ConversationID string   `gorm:"size:36" json:"conversation_id"`
+	// ChatSequence is the sequence number of the last chat message sent in
+	// the current conversation; reset on deploy.
+	ChatSequence int64      `gorm:"default:0" json:"-"`
+	// DeployFailures counts consecutive failed deployments; reset on success.
+	DeployFailures int      `gorm:"default:0" json:"deploy_failures"`
+	// ConfigRevision is the TeamRevision the leader was last deployed from.
+	ConfigRevision int      `gorm:"default:0" json:"config_revision"`
 	McpServers    JSON      `gorm:"type:text" json:"mcp_servers"`
 	McpStatuses   JSON      `gorm:"type:text" json:"mcp_statuses"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -146,23 +227,76 @@ type Agent struct {
 	SubAgentModel        string `gorm:"size:255;default:inherit" json:"sub_agent_model"`
 	SubAgentSkills       JSON   `gorm:"type:text" json:"sub_agent_skills"`
 
+	// Sub-agent frontmatter flags. By default sub-agents run in the
+	// background, in their own git worktree, with all permissions bypassed.
+	SubAgentBackground     *bool  `gorm:"default:true" json:"sub_agent_background"`
+	SubAgentIsolation      string `gorm:"size:50;default:worktree" json:"sub_agent_isolation"`
+	SubAgentPermissionMode string `gorm:"size:50;default:bypassPermissions" json:"sub_agent_permission_mode"`
+
 	// SkillStatuses stores per-skill installation results reported by the sidecar.
 	SkillStatuses JSON `gorm:"type:text" json:"skill_statuses"`
 
+	// RunAsUID and RunAsGID, when set, are the user the agent process runs as
+	// on a bind-mounted workspace, so files it writes are owned by that host
+	// user. When unset, the owner of the workspace directory is used.
+	RunAsUID *int `gorm:"column:run_as_uid" json:"run_as_uid"`
+	RunAsGID *int `gorm:"column:run_as_gid" json:"run_as_gid"`
+	// SkipWorkspaceChown leaves a root-owned bind-mounted workspace as it is
+	// instead of chowning it to the agent user on start.
+	SkipWorkspaceChown bool `json:"skip_workspace_chown"`
+
+	// ExtraClaudeArgs is a JSON list of claude CLI flags appended to the
+	// leader's invocations (see protocol.ValidateClaudeExtraArgs).
+	ExtraClaudeArgs JSON `gorm:"type:text" json:"extra_claude_args"`
+
+	// Versions holds the tool versions (protocol.ToolVersions) last reported
+	// by the sidecar in the agent's container, at VersionsReportedAt.
+	Versions           JSON       `gorm:"type:text" json:"versions"`
+	VersionsReportedAt *time.Time `json:"versions_reported_at"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // TaskLog records inter-agent messages for auditing and replay.
+//
+// The activity and messages endpoints page through a team's logs by
+// (created_at, id), optionally filtered by message_type.
+// idx_tasklog_team_created_type walks pages in order and checks the type
+// filter without reading rows; idx_tasklog_team_type_created serves the
+// per-type counts and ETags as covering index scans.
 type TaskLog struct {
-	ID          string    `gorm:"primaryKey;size:36" json:"id"`
-	TeamID      string    `gorm:"not null;size:36;index:idx_tasklog_team_created" json:"team_id"`
+	ID          string    `gorm:"primaryKey;size:36;index:idx_tasklog_team_created_type,priority:3;index:idx_tasklog_team_type_created,priority:4" json:"id"`
+	TeamID      string    `gorm:"not null;size:36;index:idx_tasklog_team_created_type,priority:1;index:idx_tasklog_team_type_created,priority:1;index:idx_tasklog_team_agent_created,priority:1;index:idx_tasklog_team_event_created,priority:1;index:idx_tasklog_team_tool_created,priority:1" json:"team_id"`
 	MessageID   string    `gorm:"size:36;index" json:"message_id"`
-	FromAgent   string    `gorm:"size:255" json:"from_agent"`
+	// ConversationID groups the logs of one leader session (see Team.ConversationID).
+	ConversationID string `gorm:"size:36;index" json:"conversation_id"`
+	FromAgent   string    `gorm:"size:255;index:idx_tasklog_team_agent_created,priority:2" json:"from_agent"`
 	ToAgent     string    `gorm:"size:255" json:"to_agent"`
-	MessageType string    `gorm:"size:50" json:"message_type"`
+	MessageType string    `gorm:"size:50;index:idx_tasklog_team_created_type,priority:4;index:idx_tasklog_team_type_created,priority:2" json:"message_type"`
 	Payload     JSON      `gorm:"type:text" json:"payload"`
-	CreatedAt   time.Time `gorm:"index:idx_tasklog_team_created" json:"created_at"`
+	// EventType and ToolName copy the event_type and tool_name of an
+	// activity event's payload so activity can be filtered on them.
+	EventType string `gorm:"size:50;index:idx_tasklog_team_event_created,priority:2" json:"event_type,omitempty"`
+	ToolName  string `gorm:"size:255;index:idx_tasklog_team_tool_created,priority:2" json:"tool_name,omitempty"`
+	// Segments splits a leader response's result into headings, code
+	// blocks and file diffs for rich rendering. Empty for plain text.
+	Segments []segments.Segment `gorm:"serializer:json;type:text" json:"segments,omitempty"`
+	// DeliveryStatus tracks user messages queued while the team was deploying
+	// (queued, sent, failed). Empty for messages delivered immediately.
+	DeliveryStatus string    `gorm:"size:20;index" json:"delivery_status,omitempty"`
+	// Sequence orders user chat messages within a conversation (see Team.ChatSequence).
+	Sequence       int64     `json:"sequence,omitempty"`
+	CreatedAt      time.Time `gorm:"index:idx_tasklog_team_created_type,priority:2;index:idx_tasklog_team_type_created,priority:3;index:idx_tasklog_team_agent_created,priority:3;index:idx_tasklog_team_event_created,priority:3;index:idx_tasklog_team_tool_created,priority:3" json:"created_at"`
+	// UpdatedAt changes with DeliveryStatus, so that cached message lists
+	// are refreshed.
+	UpdatedAt time.Time `json:"-"`
+	// QueuedPosition is the place of a sent user message in the leader's run
+	// queue, starting at 1, while it waits to start. Not stored.
+	QueuedPosition int       `gorm:"-" json:"queued_position,omitempty"`
+	// Failure explains a failed leader response in the request's locale.
+	// Not stored.
+	Failure *failures.Failure `gorm:"-" json:"failure,omitempty"`
 }
 
 // Settings stores application-level key-value configuration.
@@ -187,6 +321,17 @@ type Schedule struct {
 	Enabled        bool       `gorm:"default:true" json:"enabled"`
 	LastRunAt      *time.Time `json:"last_run_at"`
 	NextRunAt      *time.Time `json:"next_run_at"`
+	// PromptTemplateID optionally points at a PromptTemplate. When set, the
+	// prompt is re-rendered from the template and PromptVariables on each run.
+	PromptTemplateID *string `gorm:"size:36;index" json:"prompt_template_id"`
+	PromptVariables  JSON    `gorm:"type:text" json:"prompt_variables"`
+	// WorkingHours overrides what the team's working hours do to the
+	// schedule's runs outside them: workhours.ActionRun, ActionSkip or
+	// ActionDefer. Empty follows the team's policy.
+	WorkingHours string `gorm:"size:20" json:"working_hours"`
+	// DeferredUntil is when a run deferred outside the team's working hours
+	// is due.
+	DeferredUntil *time.Time `json:"deferred_until"`
 	// Status: idle | running | error
 	Status    string    `gorm:"size:20;default:'idle'" json:"status"`
 	CreatedAt time.Time `json:"created_at"`
@@ -195,6 +340,34 @@ type Schedule struct {
 	Runs      []ScheduleRun `gorm:"foreignKey:ScheduleID;constraint:OnDelete:CASCADE" json:"runs,omitempty"`
 }
 
+// PromptTemplate is a reusable prompt with {{variable}} placeholders that can
+// be referenced from chat messages and schedules.
+type PromptTemplate struct {
+	ID          string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID       string    `gorm:"size:36;index" json:"org_id"`
+	Name        string    `gorm:"not null;size:255" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	Content     string    `gorm:"type:text;not null" json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// promptPlaceholder matches a {{key}} placeholder of a PromptTemplate.
+var promptPlaceholder = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// Render replaces every {{key}} placeholder in the template content with the
+// matching value from vars. Unknown placeholders are left untouched. The
+// content is scanned once, so placeholders in the values are not expanded
+// and the result does not depend on the order of vars.
+func (t PromptTemplate) Render(vars map[string]string) string {
+	return promptPlaceholder.ReplaceAllStringFunc(t.Content, func(placeholder string) string {
+		if v, ok := vars[placeholder[2:len(placeholder)-2]]; ok {
+			return v
+		}
+		return placeholder
+	})
+}
+
 // ScheduleRun records a single execution of a schedule.
 type ScheduleRun struct {
 	ID               string     `gorm:"primaryKey;size:36" json:"id"`
@@ -202,11 +375,12 @@ type ScheduleRun struct {
 	TeamDeploymentID string     `gorm:"size:36" json:"team_deployment_id"`
 	StartedAt        time.Time  `json:"started_at"`
 	FinishedAt       *time.Time `json:"finished_at"`
-	// Status: running | success | failed | timeout
+	// Status: running | success | failed | timeout | skipped
 	Status           string `gorm:"size:20;default:'running'" json:"status"`
 	Error            string `gorm:"type:text" json:"error"`
 	PromptSent       string `gorm:"type:text" json:"prompt_sent"`
 	ResponseReceived string `gorm:"type:text" json:"response_received"`
+	TeamRevision     int    `json:"team_revision"`
 	Schedule         Schedule `gorm:"foreignKey:ScheduleID" json:"-"`
 }
 
@@ -218,6 +392,16 @@ const (
 	TeamStatusDeploying = "deploying"
 )
 
+// Valid delivery statuses for queued chat messages.
+const (
+	ChatDeliveryQueued = "queued"
+	ChatDeliverySent   = "sent"
+	ChatDeliveryFailed = "failed"
+	// ChatDeliveryBlocked marks user messages a content policy kept from
+	// the team.
+	ChatDeliveryBlocked = "blocked"
+)
+
 // Valid agent roles.
 const (
 	AgentRoleLeader = "leader"
@@ -229,8 +413,20 @@ const (
 	ContainerStatusStopped = "stopped"
 	ContainerStatusRunning = "running"
 	ContainerStatusError   = "error"
+	// ContainerStatusUnhealthy is a running container whose sidecar
+	// healthcheck is failing.
+	ContainerStatusUnhealthy = "unhealthy"
 )
 
+// ContainerUpStatuses are the container statuses of a container that is up
+// and can be exec'd into, even if its healthcheck is failing.
+var ContainerUpStatuses = []string{ContainerStatusRunning, ContainerStatusUnhealthy}
+
+// ContainerIsUp reports whether status is one of ContainerUpStatuses.
+func ContainerIsUp(status string) bool {
+	return status == ContainerStatusRunning || status == ContainerStatusUnhealthy
+}
+
 // Valid schedule statuses.
 const (
 	ScheduleStatusIdle    = "idle"
@@ -244,6 +440,9 @@ const (
 	ScheduleRunStatusSuccess = "success"
 	ScheduleRunStatusFailed  = "failed"
 	ScheduleRunStatusTimeout = "timeout"
+	// ScheduleRunStatusSkipped records a run skipped outside the team's
+	// working hours.
+	ScheduleRunStatusSkipped = "skipped"
 )
 
 // Webhook represents an HTTP webhook endpoint that triggers a team execution.
@@ -278,6 +477,21 @@ type WebhookRun struct {
 	ResponseReceived string     `gorm:"type:text" json:"response_received"`
 	RequestPayload   string     `gorm:"type:text" json:"request_payload"`
 	CallerIP         string     `gorm:"size:45" json:"caller_ip"`
+	// TeamRevision is the TeamRevision the team ran the prompt with.
+	TeamRevision int `json:"team_revision"`
+}
+
+// ShareLink records a shareable link to a schedule or webhook run. The link's
+// token is signed, and it only opens the run while its record exists: deleting
+// the record revokes it before ExpiresAt.
+type ShareLink struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID     string    `gorm:"size:36;index" json:"org_id"`
+	RunID     string    `gorm:"not null;size:36;index" json:"run_id"`
+	Kind      string    `gorm:"size:20" json:"kind"`
+	CreatedBy string    `gorm:"size:36" json:"created_by"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 // Valid webhook statuses.
@@ -298,6 +512,9 @@ const (
 type Document struct {
 	ID          string    `gorm:"primaryKey;size:36" json:"id"`
 	OrgID       string    `gorm:"not null;size:36;index:idx_doc_org" json:"org_id"`
+	// TeamID is set for documents uploaded to a team's own knowledge base
+	// rather than the organization's.
+	TeamID      string    `gorm:"size:36;index" json:"team_id,omitempty"`
 	Name        string    `gorm:"not null;size:512" json:"name"`
 	FileName    string    `gorm:"not null;size:512" json:"file_name"`
 	FileSize    int64     `gorm:"not null" json:"file_size"`
@@ -324,6 +541,45 @@ const (
 	ProviderOpenCode = "opencode"
 )
 
+// Valid config dir modes. They control where generated agent config (.claude,
+// .opencode, .agents) lives relative to a mounted workspace. In "shared"
+// mode each team works in its own git worktree of the workspace, so several
+// teams can run on it at once.
+const (
+	ConfigDirModeInline    = "inline"
+	ConfigDirModeGitignore = "gitignore"
+	ConfigDirModeSeparate  = "separate"
+	ConfigDirModeShared    = "shared"
+)
+
+// Valid sub-agent isolation values. With "none" the sub-agent works directly
+// in the leader's checkout.
+const (
+	SubAgentIsolationWorktree = "worktree"
+	SubAgentIsolationNone     = "none"
+)
+
+// DefaultSubAgentPermissionMode is the permission mode of sub-agents that do
+// not set one.
+const DefaultSubAgentPermissionMode = "bypassPermissions"
+
+// SubAgentPermissionModes are the Claude Code permission modes a sub-agent
+// can run with.
+var SubAgentPermissionModes = []string{"default", "acceptEdits", "plan", "dontAsk", "bypassPermissions"}
+
+// WorkspaceClashes reports whether teams a and b mount the same host
+// workspace without both using ConfigDirModeShared, in which case running
+// them together would overwrite each other's .claude directory and files.
+func WorkspaceClashes(a, b Team) bool {
+	if a.WorkspacePath == "" || b.WorkspacePath == "" {
+		return false
+	}
+	if filepath.Clean(a.WorkspacePath) != filepath.Clean(b.WorkspacePath) {
+		return false
+	}
+	return a.ConfigDirMode != ConfigDirModeShared || b.ConfigDirMode != ConfigDirModeShared
+}
+
 // Valid model providers for OpenCode teams.
 const (
 	ModelProviderAnthropic = "anthropic"
@@ -417,3 +673,735 @@ const (
 	PostActionRunStatusFailed   = "failed"
 	PostActionRunStatusRetrying = "retrying"
 )
+
+// IssueIntegration connects a team to an issue tracker (Jira or Linear). When
+// SyncResults is set, finished webhook and schedule runs are written to a
+// ticket. Comments on tracker issues that start with CommandPrefix and are
+// delivered to the inbound token URL start new runs on the team.
+type IssueIntegration struct {
+	ID                 string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID              string    `gorm:"size:36;index" json:"org_id"`
+	Name               string    `gorm:"not null;size:255" json:"name"`
+	TeamID             string    `gorm:"not null;size:36;index" json:"team_id"`
+	Provider           string    `gorm:"not null;size:20" json:"provider"`
+	BaseURL            string    `gorm:"size:1024" json:"base_url"`
+	Project            string    `gorm:"not null;size:255" json:"project"`
+	IssueType          string    `gorm:"size:100;default:'Task'" json:"issue_type"`
+	Labels             JSON      `gorm:"type:text" json:"labels"`
+	Credentials        JSON      `gorm:"type:text" json:"-"`
+	SyncResults        bool      `gorm:"default:true" json:"sync_results"`
+	SyncMode           string    `gorm:"size:20;default:'create'" json:"sync_mode"`
+	TriggerOn          string    `gorm:"size:20;default:'any'" json:"trigger_on"`
+	CommandPrefix      string    `gorm:"size:100;default:'/agentcrew'" json:"command_prefix"`
+	InboundTokenHash   string    `gorm:"size:64;uniqueIndex" json:"-"`
+	InboundTokenPrefix string    `gorm:"size:20" json:"inbound_token_prefix"`
+	Enabled            bool      `gorm:"default:true" json:"enabled"`
+	LastError          string    `gorm:"type:text" json:"last_error"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	Team               Team      `gorm:"foreignKey:TeamID" json:"team,omitempty"`
+}
+
+// IssueLink remembers the ticket created for a trigger so that later runs of
+// the same webhook or schedule update it instead of opening a new one.
+type IssueLink struct {
+	ID            string    `gorm:"primaryKey;size:36" json:"id"`
+	IntegrationID string    `gorm:"not null;size:36;uniqueIndex:idx_issue_link_unique" json:"integration_id"`
+	TriggerType   string    `gorm:"not null;size:20;uniqueIndex:idx_issue_link_unique" json:"trigger_type"`
+	TriggerID     string    `gorm:"not null;size:36;uniqueIndex:idx_issue_link_unique" json:"trigger_id"`
+	IssueKey      string    `gorm:"not null;size:255" json:"issue_key"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Valid issue tracker providers.
+const (
+	IssueProviderJira   = "jira"
+	IssueProviderLinear = "linear"
+)
+
+// Valid sync modes for IssueIntegration.
+const (
+	IssueSyncModeCreate = "create" // open a new ticket for every run
+	IssueSyncModeUpdate = "update" // one ticket per trigger, later runs comment on it
+)
+
+// AlertIntegration sends incidents to PagerDuty or Opsgenie when a team enters
+// error status, fails to deploy DeployFailureThreshold times in a row, or its
+// leader container stops responding for HeartbeatTimeoutSeconds. An empty
+// TeamID applies the integration to every team in the organization.
+type AlertIntegration struct {
+	ID                      string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID                   string    `gorm:"size:36;index" json:"org_id"`
+	Name                    string    `gorm:"not null;size:255" json:"name"`
+	TeamID                  string    `gorm:"size:36;index" json:"team_id"`
+	Provider                string    `gorm:"not null;size:20" json:"provider"`
+	BaseURL                 string    `gorm:"size:1024" json:"base_url"`
+	Credentials             JSON      `gorm:"type:text" json:"-"`
+	Severity                string    `gorm:"size:20;default:'critical'" json:"severity"`
+	AlertOnError            bool      `gorm:"default:true" json:"alert_on_error"`
+	DeployFailureThreshold  int       `gorm:"default:3" json:"deploy_failure_threshold"`
+	HeartbeatTimeoutSeconds int       `gorm:"default:300" json:"heartbeat_timeout_seconds"`
+	Enabled                 bool      `gorm:"default:true" json:"enabled"`
+	LastError               string    `gorm:"type:text" json:"last_error"`
+	CreatedAt               time.Time `json:"created_at"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// TeamAlert tracks an incident opened for a team so it can be resolved when
+// the team recovers. At most one alert per integration and team is open.
+type TeamAlert struct {
+	ID            string     `gorm:"primaryKey;size:36" json:"id"`
+	IntegrationID string     `gorm:"not null;size:36;index" json:"integration_id"`
+	TeamID        string     `gorm:"not null;size:36;index" json:"team_id"`
+	DedupKey      string     `gorm:"not null;size:255" json:"dedup_key"`
+	Reason        string     `gorm:"size:50" json:"reason"`
+	Summary       string     `gorm:"type:text" json:"summary"`
+	Status        string     `gorm:"size:20;index" json:"status"`
+	OpenedAt      time.Time  `json:"opened_at"`
+	ResolvedAt    *time.Time `json:"resolved_at"`
+}
+
+// Valid alerting providers.
+const (
+	AlertProviderPagerDuty = "pagerduty"
+	AlertProviderOpsgenie  = "opsgenie"
+)
+
+// Valid alert severities.
+const (
+	AlertSeverityCritical = "critical"
+	AlertSeverityError    = "error"
+	AlertSeverityWarning  = "warning"
+	AlertSeverityInfo     = "info"
+)
+
+// Reasons a team alert was opened.
+const (
+	AlertReasonTeamError     = "team_error"
+	AlertReasonDeployFailure = "deploy_failures"
+	AlertReasonHeartbeat     = "heartbeat"
+)
+
+// Valid statuses for TeamAlert.
+const (
+	AlertStatusOpen     = "open"
+	AlertStatusResolved = "resolved"
+)
+
+// DeadLetter holds a relay message from a team's NATS that could not be
+// persisted, so that transient database errors do not drop agent output.
+// Pending letters are retried with backoff and marked failed after the API's
+// retry limit. Dead letters live in the same database as the messages they
+// stand in for, so they only cover failures of a single write (a lock
+// timeout, a constraint error), not an outage of the database itself.
+type DeadLetter struct {
+	ID            string     `gorm:"primaryKey;size:36" json:"id"`
+	TeamID        string     `gorm:"not null;size:36;index" json:"team_id"`
+	TeamName      string     `gorm:"size:255" json:"team_name"`
+	Subject       string     `gorm:"size:255" json:"subject"`
+	Data          string     `gorm:"type:text" json:"data"`
+	Error         string     `gorm:"type:text" json:"error"`
+	Status        string     `gorm:"size:20;index" json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Valid statuses for DeadLetter. Failed letters are not retried
+// automatically: they are malformed or exhausted their attempts.
+const (
+	DeadLetterStatusPending   = "pending"
+	DeadLetterStatusDelivered = "delivered"
+	DeadLetterStatusFailed    = "failed"
+)
+
+// ResourcePolicy holds an organization's resource presets and the ceiling
+// that presets and per-agent resource overrides may not exceed. Organizations
+// without a row use the built-in presets and no ceiling.
+type ResourcePolicy struct {
+	OrgID string `gorm:"primaryKey;size:36" json:"org_id"`
+	// Presets maps a preset name to its {cpu, memory} limits.
+	Presets       JSON      `gorm:"type:text" json:"presets"`
+	CeilingCPU    string    `gorm:"size:20" json:"ceiling_cpu"`
+	CeilingMemory string    `gorm:"size:20" json:"ceiling_memory"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// AgentUpgrade is a rolling restart of an organization's running team
+// leaders onto a new agent image. Canary teams are upgraded first; if any
+// of them fails the upgrade halts before touching the rest.
+type AgentUpgrade struct {
+	ID            string               `gorm:"primaryKey;size:36" json:"id"`
+	OrgID         string               `gorm:"size:36;index" json:"org_id"`
+	Provider      string               `gorm:"size:50" json:"provider"`
+	Image         string               `gorm:"size:512" json:"image"`
+	Concurrency   int                  `json:"concurrency"`
+	CanaryPercent int                  `json:"canary_percent"`
+	Status        string               `gorm:"size:20;index" json:"status"`
+	Error         string               `gorm:"type:text" json:"error"`
+	FinishedAt    *time.Time           `json:"finished_at"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+	Results       []AgentUpgradeResult `gorm:"foreignKey:UpgradeID;constraint:OnDelete:CASCADE" json:"results,omitempty"`
+}
+
+// Valid statuses for AgentUpgrade.
+const (
+	AgentUpgradeStatusRunning     = "running"
+	AgentUpgradeStatusCompleted   = "completed"
+	AgentUpgradeStatusHalted      = "halted"
+	AgentUpgradeStatusRollingBack = "rolling_back"
+	AgentUpgradeStatusRolledBack  = "rolled_back"
+)
+
+// AgentUpgradeResult records the upgrade of one team's leader. PreviousImage
+// is the team's agent_image before the upgrade (empty for the default
+// image) and PreviousDigest the exact image the leader ran, when the
+// runtime could report it; rollback restores the digest when known.
+type AgentUpgradeResult struct {
+	ID             string     `gorm:"primaryKey;size:36" json:"id"`
+	UpgradeID      string     `gorm:"not null;size:36;index" json:"upgrade_id"`
+	TeamID         string     `gorm:"not null;size:36;index" json:"team_id"`
+	TeamName       string     `gorm:"size:255" json:"team_name"`
+	Canary         bool       `json:"canary"`
+	PreviousImage  string     `gorm:"size:512" json:"previous_image"`
+	PreviousDigest string     `gorm:"size:512" json:"previous_digest"`
+	Status         string     `gorm:"size:20" json:"status"`
+	Error          string     `gorm:"type:text" json:"error"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Valid statuses for AgentUpgradeResult. Skipped teams were not upgraded
+// because a canary failed or the team stopped running in the meantime.
+const (
+	AgentUpgradeResultPending        = "pending"
+	AgentUpgradeResultSucceeded      = "succeeded"
+	AgentUpgradeResultFailed         = "failed"
+	AgentUpgradeResultSkipped        = "skipped"
+	AgentUpgradeResultRolledBack     = "rolled_back"
+	AgentUpgradeResultRollbackFailed = "rollback_failed"
+)
+
+// SkillCatalogEntry is a skill in the organization's skills catalog, which
+// the Team Builder searches to suggest sub_agent_skills. Once the catalog has
+// entries, teams can only deploy skills listed in it.
+type SkillCatalogEntry struct {
+	ID          string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID       string `gorm:"size:36;uniqueIndex:idx_skill_catalog_org_skill" json:"org_id"`
+	RepoURL     string `gorm:"not null;size:512;uniqueIndex:idx_skill_catalog_org_skill" json:"repo_url"`
+	SkillName   string `gorm:"not null;size:255;uniqueIndex:idx_skill_catalog_org_skill" json:"skill_name"`
+	Description string `gorm:"size:1024" json:"description"`
+	// RequiredTools lists the tools the skill needs, such as "Bash" or
+	// "Write", checked against the allowed_tools of the agents using it.
+	RequiredTools JSON      `gorm:"type:text" json:"required_tools"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// SkillToolRequirement is the tools a skill package needs, as declared in
+// the allowed-tools field of its SKILL.md and reported by the agents that
+// installed it. The required_tools of a SkillCatalogEntry take precedence.
+type SkillToolRequirement struct {
+	OrgID     string    `gorm:"primaryKey;size:36" json:"org_id"`
+	RepoURL   string    `gorm:"primaryKey;size:512" json:"repo_url"`
+	SkillName string    `gorm:"primaryKey;size:255" json:"skill_name"`
+	Tools     JSON      `gorm:"type:text" json:"tools"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CustomTool is an HTTP endpoint that an organization exposes to its agents
+// as a tool. Agents never see the endpoint or credentials: invocations are
+// proxied by the API, which applies auth and the per-tool rate limit.
+// AuthConfig uses the same keys as PostAction and is stored encrypted.
+type CustomTool struct {
+	ID          string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID       string `gorm:"size:36;uniqueIndex:idx_custom_tool_org_name" json:"org_id"`
+	Name        string `gorm:"not null;size:64;uniqueIndex:idx_custom_tool_org_name" json:"name"`
+	Description string `gorm:"size:1024" json:"description"`
+	Method      string `gorm:"not null;size:10" json:"method"`
+	URL         string `gorm:"not null;type:text" json:"url"`
+	AuthType    string `gorm:"size:20;default:'none'" json:"auth_type"`
+	AuthConfig  JSON   `gorm:"type:text" json:"-"`
+	// InputSchema is the JSON Schema of the tool's arguments, shown to agents.
+	InputSchema        JSON      `gorm:"type:text" json:"input_schema"`
+	RateLimitPerMinute int       `gorm:"default:60" json:"rate_limit_per_minute"`
+	TimeoutSeconds     int       `gorm:"default:30" json:"timeout_seconds"`
+	Enabled            bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RunPlan is the plan a leader in plan approval mode returned for a chat
+// run. Its ID is the ID of the run's user message.
+type RunPlan struct {
+	ID         string     `gorm:"primaryKey;size:36" json:"id"`
+	TeamID     string     `gorm:"not null;size:36;index" json:"team_id"`
+	Plan       string     `gorm:"type:text" json:"plan"`
+	Status     string     `gorm:"size:20;default:'pending'" json:"status"`
+	ApprovedBy string     `gorm:"size:36" json:"approved_by,omitempty"`
+	ApprovedAt *time.Time `json:"approved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Valid statuses for RunPlan.
+const (
+	RunPlanStatusPending  = "pending"
+	RunPlanStatusApproved = "approved"
+)
+
+// ProposedPatch is a diff a leader response proposed for its team's
+// workspace. It is only applied, with git, when a user approves it. Files
+// holds the paths the diff changes.
+type ProposedPatch struct {
+	ID             string     `gorm:"primaryKey;size:36" json:"id"`
+	TeamID         string     `gorm:"not null;size:36;index;uniqueIndex:idx_patch_team_message,where:message_id <> ''" json:"team_id"`
+	MessageID      string     `gorm:"size:36;uniqueIndex:idx_patch_team_message,where:message_id <> ''" json:"message_id"`
+	TaskLogID      string     `gorm:"size:36;index" json:"task_log_id"`
+	ConversationID string     `gorm:"size:36" json:"conversation_id"`
+	Files          JSON       `gorm:"type:text" json:"files"`
+	Diff           string     `gorm:"type:text" json:"diff"`
+	Status         string     `gorm:"size:20;index;default:'proposed'" json:"status"`
+	Error          string     `gorm:"type:text" json:"error,omitempty"`
+	DecidedBy      string     `gorm:"size:36" json:"decided_by,omitempty"`
+	DecidedAt      *time.Time `json:"decided_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	Team           Team       `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Valid statuses for ProposedPatch. A failed patch may be applied again
+// once the workspace is fixed.
+const (
+	PatchStatusProposed = "proposed"
+	PatchStatusApplying = "applying"
+	PatchStatusApplied  = "applied"
+	PatchStatusFailed   = "failed"
+	PatchStatusRejected = "rejected"
+)
+
+// Evaluation is a test prompt for a team with the assertions its response
+// must pass. Assertions holds a list of evaluation.Assertion.
+type Evaluation struct {
+	ID             string    `gorm:"primaryKey;size:36" json:"id"`
+	TeamID         string    `gorm:"not null;size:36;index" json:"team_id"`
+	Name           string    `gorm:"size:255" json:"name"`
+	Prompt         string    `gorm:"type:text" json:"prompt"`
+	Assertions     JSON      `gorm:"type:text" json:"assertions"`
+	TimeoutSeconds int       `gorm:"default:600" json:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Team           Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// EvaluationRun is one run of a team's evaluations. GraderTeamID is the team
+// that graded llm assertions, when not the evaluated team itself.
+type EvaluationRun struct {
+	ID           string             `gorm:"primaryKey;size:36" json:"id"`
+	TeamID       string             `gorm:"not null;size:36;index" json:"team_id"`
+	GraderTeamID string             `gorm:"size:36" json:"grader_team_id,omitempty"`
+	Status       string             `gorm:"size:20;index" json:"status"`
+	Total        int                `json:"total"`
+	Passed       int                `json:"passed"`
+	Failed       int                `json:"failed"`
+	CreatedBy    string             `gorm:"size:36" json:"created_by"`
+	FinishedAt   *time.Time         `json:"finished_at"`
+	CreatedAt    time.Time          `json:"created_at"`
+	Results      []EvaluationResult `gorm:"foreignKey:RunID;constraint:OnDelete:CASCADE" json:"results,omitempty"`
+	Team         Team               `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Valid statuses for EvaluationRun. A run passes when every evaluation in it
+// passed.
+const (
+	EvaluationRunStatusRunning = "running"
+	EvaluationRunStatusPassed  = "passed"
+	EvaluationRunStatusFailed  = "failed"
+)
+
+// EvaluationResult is the outcome of one evaluation in a run. The evaluation's
+// name, prompt and assertions are copied so the history stays readable after
+// the evaluation is edited or deleted. Assertions holds the
+// evaluation.Result of each assertion once graded.
+type EvaluationResult struct {
+	ID           string     `gorm:"primaryKey;size:36" json:"id"`
+	RunID        string     `gorm:"not null;size:36;index" json:"run_id"`
+	EvaluationID string     `gorm:"size:36;index" json:"evaluation_id"`
+	Name         string     `gorm:"size:255" json:"name"`
+	Prompt       string     `gorm:"type:text" json:"prompt"`
+	Response     string     `gorm:"type:text" json:"response"`
+	Assertions   JSON       `gorm:"type:text" json:"assertions"`
+	Status       string     `gorm:"size:20" json:"status"`
+	Error        string     `gorm:"type:text" json:"error"`
+	DurationMs   int64      `json:"duration_ms"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Valid statuses for EvaluationResult. An error means the prompt got no
+// response, so the assertions were not checked.
+const (
+	EvaluationResultPending = "pending"
+	EvaluationResultPassed  = "passed"
+	EvaluationResultFailed  = "failed"
+	EvaluationResultError   = "error"
+)
+
+// TeamRevision is a numbered snapshot of a team's agent configuration. A
+// revision is recorded when a team is deployed with a configuration that
+// differs from its latest revision. Agents holds a list of AgentSnapshot.
+type TeamRevision struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	TeamID    string    `gorm:"not null;size:36;uniqueIndex:idx_team_revision" json:"team_id"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_team_revision" json:"revision"`
+	Agents    JSON      `gorm:"type:text" json:"agents"`
+	CreatedAt time.Time `json:"created_at"`
+	Team      Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Deployment records one attempt to deploy a team or replace its leader's
+// container. TeamRevision is the TeamRevision a succeeded deployment runs,
+// and Agents holds a list of DeploymentAgent.
+type Deployment struct {
+	ID           string     `gorm:"primaryKey;size:36" json:"id"`
+	OrgID        string     `gorm:"size:36;index" json:"org_id"`
+	TeamID       string     `gorm:"not null;size:36;index" json:"team_id"`
+	Trigger      string     `gorm:"size:20" json:"trigger"`
+	TriggeredBy  string     `gorm:"size:36" json:"triggered_by,omitempty"`
+	Runtime      string     `gorm:"size:50" json:"runtime,omitempty"`
+	Status       string     `gorm:"size:20;index" json:"status"`
+	Error        string     `gorm:"type:text" json:"error,omitempty"`
+	TeamRevision int        `json:"team_revision"`
+	Agents       JSON       `gorm:"type:text" json:"agents"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	DurationMs   int64      `json:"duration_ms"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Team         Team       `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Triggers of a Deployment. Leader restarts, upgrades and rollbacks use the
+// deployment actions of the protocol package.
+const (
+	DeploymentTriggerManual    = "manual"
+	DeploymentTriggerApproval  = "approval"
+	DeploymentTriggerMigration = "migration"
+	DeploymentTriggerSchedule  = "schedule"
+)
+
+// Valid statuses for Deployment.
+const (
+	DeploymentStatusRunning   = "running"
+	DeploymentStatusSucceeded = "succeeded"
+	DeploymentStatusFailed    = "failed"
+)
+
+// RunReplay is a schedule or webhook run whose prompt was sent again to its
+// team, to compare the results of two team configurations. CostUSD is the
+// usage the team recorded while the replay ran.
+type RunReplay struct {
+	ID           string     `gorm:"primaryKey;size:36" json:"id"`
+	OrgID        string     `gorm:"size:36;index" json:"org_id"`
+	TeamID       string     `gorm:"not null;size:36;index" json:"team_id"`
+	SourceKind   string     `gorm:"size:20" json:"source_kind"`
+	SourceRunID  string     `gorm:"not null;size:36;index" json:"source_run_id"`
+	TeamRevision int        `json:"team_revision"`
+	Prompt       string     `gorm:"type:text" json:"prompt"`
+	Status       string     `gorm:"size:20" json:"status"`
+	Response     string     `gorm:"type:text" json:"response"`
+	Error        string     `gorm:"type:text" json:"error"`
+	DurationMs   int64      `json:"duration_ms"`
+	CostUSD      float64    `json:"cost_usd"`
+	CreatedBy    string     `gorm:"size:36" json:"created_by"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Valid statuses for RunReplay, matching those of schedule and webhook runs.
+const (
+	RunReplayStatusRunning = "running"
+	RunReplayStatusSuccess = "success"
+	RunReplayStatusFailed  = "failed"
+	RunReplayStatusTimeout = "timeout"
+)
+
+// AgentProvenance records where an imported agent's definition came from.
+// ContentSHA256 is the hash of the profile as it was downloaded, so a copy
+// can later be compared with its source.
+type AgentProvenance struct {
+	ID            string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID         string    `gorm:"size:36;index" json:"org_id"`
+	AgentID       string    `gorm:"not null;size:36;uniqueIndex" json:"agent_id"`
+	SourceURL     string    `gorm:"size:2048" json:"source_url"`
+	ResolvedURL   string    `gorm:"size:2048" json:"resolved_url"`
+	ContentSHA256 string    `gorm:"size:64" json:"content_sha256"`
+	ImportedBy    string    `gorm:"size:36" json:"imported_by"`
+	CreatedAt     time.Time `json:"created_at"`
+	Agent         Agent     `gorm:"foreignKey:AgentID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// TeamMemory is a summary of the context the leader chose to keep when its
+// team stopped. The latest one is given to the leader on the next deploy of
+// a team with memory enabled.
+type TeamMemory struct {
+	ID             string    `gorm:"primaryKey;size:36" json:"id"`
+	TeamID         string    `gorm:"not null;size:36;index" json:"team_id"`
+	ConversationID string    `gorm:"size:36" json:"conversation_id"`
+	AgentName      string    `gorm:"size:255" json:"agent_name"`
+	Summary        string    `gorm:"type:text" json:"summary"`
+	CreatedAt      time.Time `json:"created_at"`
+	Team           Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// KnowledgeEntry is a completed run result indexed in its team's knowledge
+// base. Status uses the DocStatus values.
+type KnowledgeEntry struct {
+	ID            string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID         string    `gorm:"size:36;index" json:"org_id"`
+	TeamID        string    `gorm:"not null;size:36;index" json:"team_id"`
+	RunID         string    `gorm:"size:36" json:"run_id"`
+	Content       string    `gorm:"type:text" json:"content"`
+	Status        string    `gorm:"size:50;default:'pending'" json:"status"`
+	StatusMessage string    `gorm:"type:text" json:"status_message"`
+	ChunkCount    int       `gorm:"default:0" json:"chunk_count"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Team          Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// ContentPolicy is a set of guardrail rules an organization applies to
+// user chat messages before they are published (direction input), to leader
+// responses before they are persisted (output), or to both. DenyTerms and
+// Patterns are JSON arrays of strings.
+type ContentPolicy struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID      string    `gorm:"size:36;uniqueIndex:idx_content_policy_org_name" json:"org_id"`
+	Name       string    `gorm:"not null;size:255;uniqueIndex:idx_content_policy_org_name" json:"name"`
+	Direction  string    `gorm:"not null;size:10" json:"direction"`
+	DenyTerms  JSON      `gorm:"type:text" json:"deny_terms"`
+	Patterns   JSON      `gorm:"type:text" json:"patterns"`
+	Moderation bool      `json:"moderation"`
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// PolicyViolation records a message or response a content policy blocked.
+// The blocked content itself is not stored. MessageID is the ID of the
+// blocked TaskLog.
+type PolicyViolation struct {
+	ID         string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID      string    `gorm:"size:36;index:idx_policy_violation_org_created" json:"org_id"`
+	TeamID     string    `gorm:"size:36;index" json:"team_id"`
+	PolicyID   string    `gorm:"size:36;index" json:"policy_id"`
+	PolicyName string    `gorm:"size:255" json:"policy_name"`
+	Direction  string    `gorm:"size:10" json:"direction"`
+	Kind       string    `gorm:"size:20" json:"kind"`
+	Rule       string    `gorm:"type:text" json:"rule"`
+	MessageID  string    `gorm:"size:36" json:"message_id"`
+	CreatedAt  time.Time `gorm:"index:idx_policy_violation_org_created" json:"created_at"`
+}
+
+// UsageRecord is the cost and token usage of one agent turn, reported by the
+// leader's sidecar. The team's name and labels are copied at the time of the
+// turn, so cost reports stay accurate after a team is relabeled or deleted.
+type UsageRecord struct {
+	ID               string    `gorm:"primaryKey;size:36" json:"id"`
+	OrgID            string    `gorm:"size:36;index:idx_usage_org_created" json:"org_id"`
+	TeamID           string    `gorm:"size:36;index" json:"team_id"`
+	TeamName         string    `gorm:"size:255" json:"team_name"`
+	TeamLabels       JSON      `gorm:"type:text" json:"team_labels"`
+	ConversationID   string    `gorm:"size:36" json:"conversation_id"`
+	AgentName        string    `gorm:"size:255" json:"agent_name"`
+	CostUSD          float64   `json:"cost_usd"`
+	InputTokens      int64     `json:"input_tokens"`
+	OutputTokens     int64     `json:"output_tokens"`
+	CacheReadTokens  int64     `json:"cache_read_tokens"`
+	CacheWriteTokens int64     `json:"cache_write_tokens"`
+	CreatedAt        time.Time `gorm:"index:idx_usage_org_created" json:"created_at"`
+}
+
+// Lease is a named lock held by one API replica at a time, for the work
+// only one replica may do. A holder keeps the lease by renewing it before
+// ExpiresAt; once it expires, another replica may take it over.
+type Lease struct {
+	Name      string    `gorm:"primaryKey;size:100" json:"name"`
+	Holder    string    `gorm:"size:255" json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Note says what the lease is held for, for whoever finds it taken.
+	Note      string    `gorm:"size:255" json:"note,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PreviewSession is an agent preview session: a short-lived agent without
+// a workspace, started to try a persona out, whose resources are named after
+// Slug. It is kept in the database so that any API replica can continue or
+// end it, and so that the infrastructure garbage collector leaves its
+// resources alone until it expires.
+type PreviewSession struct {
+	ID     string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID  string `gorm:"size:36;index" json:"org_id"`
+	UserID string `gorm:"size:36" json:"user_id"`
+	Slug   string `gorm:"size:63;index" json:"slug"`
+	// The persona the session's agent runs.
+	Name           string `gorm:"size:255" json:"name"`
+	Specialty      string `gorm:"type:text" json:"specialty"`
+	SystemPrompt   string `gorm:"type:text" json:"system_prompt"`
+	InstructionsMD string `gorm:"type:text" json:"instructions_md"`
+	Model          string `gorm:"size:20" json:"model"`
+	// IdleUntil is when the session ends unless it receives a message, and
+	// ExpiresAt when it ends regardless.
+	IdleUntil time.Time `json:"idle_until"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RateLimitBucket is the token bucket of the prompt rate limiter (see the
+// ratelimit package), shared by every process using the database. Tokens
+// is the number of prompts that could be sent at RefilledAt.
+type RateLimitBucket struct {
+	Name        string    `gorm:"primaryKey;size:50" json:"name"`
+	Tokens      float64   `json:"tokens"`
+	RefilledAt  time.Time `json:"refilled_at"`
+	PausedUntil time.Time `json:"paused_until"`
+	// Version is incremented by every token taken, which only succeeds
+	// against the version it read.
+	Version int64 `json:"version"`
+}
+
+// RateLimitTicket is a prompt waiting for the prompt rate limiter. Tickets
+// are served in the order of their ID. The process waiting renews
+// ExpiresAt, so that the tickets of a process that stopped expire.
+type RateLimitTicket struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	RequestID string    `gorm:"size:36" json:"request_id"`
+	OrgID     string    `gorm:"size:36" json:"org_id"`
+	TeamID    string    `gorm:"size:36" json:"team_id"`
+	TeamName  string    `gorm:"size:255" json:"team_name"`
+	Source    string    `gorm:"size:20" json:"source"`
+	QueuedAt  time.Time `json:"queued_at"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+}
+
+// RelayState is the state of a team's relay that chat delivery needs, kept
+// in the database when relays run in relay workers rather than in the API.
+// It is reset when the team's leader is deployed, restarted or stopped.
+type RelayState struct {
+	TeamID      string    `gorm:"primaryKey;size:36" json:"team_id"`
+	LeaderReady bool      `json:"leader_ready"`
+	RunQueue    JSON      `gorm:"type:text" json:"run_queue"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Job is an operation run in the background by the job workers (see the
+// jobs package), such as a team deployment. Jobs are claimed from the
+// database, so any API replica may run them, and failed jobs are retried
+// up to MaxAttempts times.
+type Job struct {
+	ID     string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID  string `gorm:"size:36;index" json:"org_id"`
+	TeamID string `gorm:"size:36;index" json:"team_id,omitempty"`
+	Kind   string `gorm:"size:50;index" json:"kind"`
+	Status string `gorm:"size:20;index" json:"status"`
+	// IdempotencyKey, when set, makes enqueueing the same operation twice
+	// return the first job instead of running it again.
+	IdempotencyKey string     `gorm:"size:255;uniqueIndex:idx_job_idempotency_key,where:idempotency_key <> ''" json:"idempotency_key,omitempty"`
+	Payload        JSON       `gorm:"type:text" json:"payload"`
+	Attempts       int        `json:"attempts"`
+	MaxAttempts    int        `json:"max_attempts"`
+	Error          string     `gorm:"type:text" json:"error"`
+	RunAfter       time.Time  `gorm:"index" json:"run_after"`
+	// LockedBy is the worker running the job, which renews LockedUntil while
+	// it does. A running job whose lock expired was interrupted and is
+	// claimed again.
+	LockedBy    string     `gorm:"size:255" json:"locked_by,omitempty"`
+	LockedUntil *time.Time `json:"-"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Valid statuses for Job.
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusSucceeded = "succeeded"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// Approval is a request for an operation on a team that needs an admin's
+// approval, such as a deploy, migration or leader restart of a team with
+// RequiresDeployApproval. It also
+// records who requested and decided it, when, and the job the approved
+// operation ran as.
+type Approval struct {
+	ID     string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID  string `gorm:"size:36;index" json:"org_id"`
+	TeamID string `gorm:"not null;size:36;index" json:"team_id"`
+	Action string `gorm:"not null;size:20" json:"action"`
+	Status string `gorm:"not null;size:20;index" json:"status"`
+	// Params holds the arguments of the operation, such as the target
+	// runtime of a migration.
+	Params JSON `gorm:"type:text" json:"params,omitempty"`
+	// Reason is the requester's note to the approvers.
+	Reason           string     `gorm:"type:text" json:"reason"`
+	RequestedBy      string     `gorm:"size:36" json:"requested_by"`
+	RequestedByEmail string     `gorm:"size:255" json:"requested_by_email"`
+	DecidedBy        string     `gorm:"size:36" json:"decided_by,omitempty"`
+	DecidedByEmail   string     `gorm:"size:255" json:"decided_by_email,omitempty"`
+	DecidedAt        *time.Time `json:"decided_at"`
+	// Note is the approver's comment on the decision.
+	Note string `gorm:"type:text" json:"note"`
+	// JobID is the background job the approved operation ran as.
+	JobID     string    `gorm:"size:36" json:"job_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Team      Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Operations an Approval can be requested for.
+const (
+	ApprovalActionDeploy  = "deploy"
+	ApprovalActionMigrate = "migrate"
+	ApprovalActionRestart = "restart"
+)
+
+// Valid statuses for Approval.
+const (
+	ApprovalStatusPending  = "pending"
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+)
+
+// QuotaEvent is a change of the AI provider's rate limit state reported by
+// one of a team's agents, or a request the provider rejected for exceeding
+// the rate limit (Status QuotaStatusRejected with an ErrorCode). Events are
+// kept for a week.
+type QuotaEvent struct {
+	ID        string `gorm:"primaryKey;size:36" json:"id"`
+	OrgID     string `gorm:"size:36;index" json:"org_id"`
+	TeamID    string `gorm:"not null;size:36;index:idx_quota_team_created" json:"team_id"`
+	AgentName string `gorm:"size:255" json:"agent_name"`
+	Status    string `gorm:"not null;size:20" json:"status"`
+	// LimitType is the limit the state is about, such as "five_hour"; empty
+	// when the provider did not say.
+	LimitType   string     `gorm:"size:50" json:"limit_type"`
+	Utilization *float64   `json:"utilization"`
+	ResetsAt    *time.Time `json:"resets_at"`
+	// ErrorCode and Message describe a rejected request.
+	ErrorCode string    `gorm:"size:50" json:"error_code,omitempty"`
+	Message   string    `gorm:"type:text" json:"message,omitempty"`
+	CreatedAt time.Time `gorm:"index:idx_quota_team_created" json:"created_at"`
+	Team      Team      `gorm:"foreignKey:TeamID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Rate limit statuses of a QuotaEvent.
+const (
+	QuotaStatusAllowed  = "allowed"
+	QuotaStatusWarning  = "allowed_warning"
+	QuotaStatusRejected = "rejected"
+)
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"context"
 	"encoding/json"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -23,6 +25,53 @@ func TestInitDB_InMemory(t *testing.T) {
 	}
 }
 
+func TestInitDB_FileUsesWALAndBusyTimeout(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+
+	// Hold several connections at once so the settings are checked on
+	// fresh pool connections, not just the one InitDB used.
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		conn, err := sqlDB.Conn(ctx)
+		if err != nil {
+			t.Fatalf("Conn: %v", err)
+		}
+		defer conn.Close()
+
+		var mode string
+		var timeout int
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatalf("journal_mode: %v", err)
+		}
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+			t.Fatalf("busy_timeout: %v", err)
+		}
+		if mode != "wal" {
+			t.Errorf("conn %d journal_mode: got %q, want wal", i, mode)
+		}
+		if timeout != SQLiteBusyTimeoutMs {
+			t.Errorf("conn %d busy_timeout: got %d, want %d", i, timeout, SQLiteBusyTimeoutMs)
+		}
+	}
+}
+
+func TestSqliteDSN(t *testing.T) {
+	if got := sqliteDSN(":memory:"); got != ":memory:" {
+		t.Errorf("memory DSN: got %q", got)
+	}
+	if got := sqliteDSN("/data/db.sqlite?mode=ro"); got != "/data/db.sqlite?mode=ro" {
+		t.Errorf("DSN with explicit params should be kept, got %q", got)
+	}
+	if got := sqliteDSN("/data/db.sqlite"); got == "/data/db.sqlite" {
+		t.Error("file DSN should carry connection params")
+	}
+}
+
 func TestTeam_CRUD(t *testing.T) {
 	db, err := InitDB(":memory:")
 	if err != nil {
@@ -160,6 +209,46 @@ func TestTaskLog_Create(t *testing.T) {
 	}
 }
 
+func TestInitDB_BackfillsActivityColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	// Recreate the schema from before event_type and tool_name were columns.
+	for _, stmt := range []string{
+		"DROP INDEX idx_tasklog_team_event_created",
+		"DROP INDEX idx_tasklog_team_tool_created",
+		"ALTER TABLE task_logs DROP COLUMN event_type",
+		"ALTER TABLE task_logs DROP COLUMN tool_name",
+		`INSERT INTO task_logs (id, team_id, message_type, payload, created_at) VALUES
+			('tool', 't1', 'activity_event', '{"event_type":"tool_use","tool_name":"Bash"}', CURRENT_TIMESTAMP),
+			('text', 't1', 'activity_event', '{"event_type":"assistant"}', CURRENT_TIMESTAMP),
+			('response', 't1', 'leader_response', '{"event_type":"not_an_event"}', CURRENT_TIMESTAMP)`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+
+	db, err = InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB after downgrade: %v", err)
+	}
+	want := map[string][2]string{"tool": {"tool_use", "Bash"}, "text": {"assistant", ""}, "response": {"", ""}}
+	for id, cols := range want {
+		var log TaskLog
+		if err := db.First(&log, "id = ?", id).Error; err != nil {
+			t.Fatalf("loading %s: %v", id, err)
+		}
+		if log.EventType != cols[0] || log.ToolName != cols[1] {
+			t.Errorf("%s: got event_type %q, tool_name %q, want %q, %q", id, log.EventType, log.ToolName, cols[0], cols[1])
+		}
+	}
+}
+
 func TestSettings_UniqueKey(t *testing.T) {
 	db, err := InitDB(":memory:")
 	if err != nil {
@@ -476,3 +565,47 @@ func TestJSON_RoundTrip(t *testing.T) {
 		t.Errorf("expected %q, got %q", input, val)
 	}
 }
+
+func TestMigrateLegacyFilesystemScope(t *testing.T) {
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	db.Create(&Team{ID: "team-fs", Name: "fs-team", Status: TeamStatusStopped, Runtime: "docker"})
+	db.Create(&Agent{
+		ID:          "agent-fs",
+		TeamID:      "team-fs",
+		Name:        "legacy",
+		Role:        AgentRoleWorker,
+		Permissions: JSON(`{"allowed_tools":["Read"],"filesystem_scope":"/workspace"}`),
+	})
+
+	migrateLegacyFilesystemScope(db)
+
+	var found Agent
+	db.First(&found, "id = ?", "agent-fs")
+	want := `{"allowed_tools":["Read"],"filesystem_scope":[{"path":"/workspace","mode":"rw"}]}`
+	if string(found.Permissions) != want {
+		t.Errorf("permissions: got %s, want %s", found.Permissions, want)
+	}
+}
+
+func TestWorkspaceClashes(t *testing.T) {
+	team := func(path, mode string) Team { return Team{WorkspacePath: path, ConfigDirMode: mode} }
+	tests := []struct {
+		a, b Team
+		want bool
+	}{
+		{team("/repo", ConfigDirModeShared), team("/repo/", ConfigDirModeShared), false},
+		{team("/repo", ConfigDirModeShared), team("/repo", ConfigDirModeInline), true},
+		{team("/repo", ConfigDirModeGitignore), team("/repo", ConfigDirModeGitignore), true},
+		{team("/repo", ConfigDirModeInline), team("/other", ConfigDirModeInline), false},
+		{team("", ConfigDirModeInline), team("", ConfigDirModeInline), false},
+	}
+	for _, tt := range tests {
+		if got := WorkspaceClashes(tt.a, tt.b); got != tt.want {
+			t.Errorf("WorkspaceClashes(%+v, %+v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
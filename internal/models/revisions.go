@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AgentSnapshot is the configuration of one agent in a TeamRevision.
+type AgentSnapshot struct {
+	Name                   string `json:"name"`
+	Role                   string `json:"role"`
+	Specialty              string `json:"specialty,omitempty"`
+	SystemPrompt           string `json:"system_prompt,omitempty"`
+	InstructionsMD         string `json:"instructions_md,omitempty"`
+	Skills                 JSON   `json:"skills,omitempty"`
+	Permissions            JSON   `json:"permissions,omitempty"`
+	Resources              JSON   `json:"resources,omitempty"`
+	SubAgentDescription    string `json:"sub_agent_description,omitempty"`
+	SubAgentInstructions   string `json:"sub_agent_instructions,omitempty"`
+	SubAgentModel          string `json:"sub_agent_model,omitempty"`
+	SubAgentSkills         JSON   `json:"sub_agent_skills,omitempty"`
+	SubAgentBackground     *bool  `json:"sub_agent_background,omitempty"`
+	SubAgentIsolation      string `json:"sub_agent_isolation,omitempty"`
+	SubAgentPermissionMode string `json:"sub_agent_permission_mode,omitempty"`
+	ExtraClaudeArgs        JSON   `json:"extra_claude_args,omitempty"`
+}
+
+// RecordTeamRevision snapshots the agents of a team that is being deployed
+// and sets the team's ConfigRevision. A new revision is only created when
+// the configuration differs from the latest one.
+func RecordTeamRevision(db *gorm.DB, teamID string) (int, error) {
+	revision := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var agents []Agent
+		if err := tx.Where("team_id = ?", teamID).Order("created_at ASC, id ASC").Find(&agents).Error; err != nil {
+			return fmt.Errorf("loading agents: %w", err)
+		}
+		snapshots := make([]AgentSnapshot, 0, len(agents))
+		for _, a := range agents {
+			// Agents without extra args leave them out, so that revisions
+			// recorded before the field existed still match.
+			var extraClaudeArgs JSON
+			if string(a.ExtraClaudeArgs) != "null" {
+				extraClaudeArgs = a.ExtraClaudeArgs
+			}
+			snapshots = append(snapshots, AgentSnapshot{
+				Name:                   a.Name,
+				Role:                   a.Role,
+				Specialty:              a.Specialty,
+				SystemPrompt:           a.SystemPrompt,
+				InstructionsMD:         a.InstructionsMD,
+				Skills:                 a.Skills,
+				Permissions:            a.Permissions,
+				Resources:              a.Resources,
+				SubAgentDescription:    a.SubAgentDescription,
+				SubAgentInstructions:   a.SubAgentInstructions,
+				SubAgentModel:          a.SubAgentModel,
+				SubAgentSkills:         a.SubAgentSkills,
+				SubAgentBackground:     a.SubAgentBackground,
+				SubAgentIsolation:      a.SubAgentIsolation,
+				SubAgentPermissionMode: a.SubAgentPermissionMode,
+				ExtraClaudeArgs:        extraClaudeArgs,
+			})
+		}
+		data, err := json.Marshal(snapshots)
+		if err != nil {
+			return fmt.Errorf("encoding agents: %w", err)
+		}
+
+		var latest TeamRevision
+		err = tx.Where("team_id = ?", teamID).Order("revision DESC").First(&latest).Error
+		switch {
+		case err == nil && bytes.Equal(latest.Agents, data):
+			revision = latest.Revision
+		case err == nil || errors.Is(err, gorm.ErrRecordNotFound):
+			revision = latest.Revision + 1
+			if err := tx.Create(&TeamRevision{
+				ID:       uuid.New().String(),
+				TeamID:   teamID,
+				Revision: revision,
+				Agents:   JSON(data),
+			}).Error; err != nil {
+				return fmt.Errorf("saving revision: %w", err)
+			}
+		default:
+			return fmt.Errorf("loading latest revision: %w", err)
+		}
+		return tx.Model(&Team{}).Where("id = ?", teamID).Update("config_revision", revision).Error
+	})
+	return revision, err
+}
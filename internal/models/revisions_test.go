@@ -0,0 +1,48 @@
+package models
+
+import "testing"
+
+func TestRecordTeamRevision(t *testing.T) {
+	db, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	team := Team{ID: "team-1", Name: "revisions"}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatalf("create team: %v", err)
+	}
+	agent := Agent{ID: "agent-1", TeamID: team.ID, Name: "leader", Role: AgentRoleLeader, InstructionsMD: "v1"}
+	if err := db.Create(&agent).Error; err != nil {
+		t.Fatalf("create agent: %v", err)
+	}
+
+	record := func() int {
+		t.Helper()
+		rev, err := RecordTeamRevision(db, team.ID)
+		if err != nil {
+			t.Fatalf("RecordTeamRevision: %v", err)
+		}
+		db.First(&team, "id = ?", team.ID)
+		if team.ConfigRevision != rev {
+			t.Errorf("config_revision: got %d, want %d", team.ConfigRevision, rev)
+		}
+		return rev
+	}
+
+	if rev := record(); rev != 1 {
+		t.Errorf("first deploy: got revision %d, want 1", rev)
+	}
+	if rev := record(); rev != 1 {
+		t.Errorf("unchanged config: got revision %d, want 1", rev)
+	}
+	db.Model(&agent).Update("instructions_md", "v2")
+	if rev := record(); rev != 2 {
+		t.Errorf("changed config: got revision %d, want 2", rev)
+	}
+
+	var count int64
+	db.Model(&TeamRevision{}).Where("team_id = ?", team.ID).Count(&count)
+	if count != 2 {
+		t.Errorf("revisions: got %d, want 2", count)
+	}
+}
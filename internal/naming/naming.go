@@ -0,0 +1,83 @@
+// Package naming derives the slugs from which container, network, volume
+// and namespace names and NATS subjects are built. The API, the runtimes and
+// the scheduler must agree on them, so every caller goes through Slug.
+package naming
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// MaxSlugLength caps a slug. Runtimes add their own prefixes and suffixes;
+// DNSLabel keeps Kubernetes names within their limit.
+const MaxSlugLength = 62
+
+// DefaultSlug is used for names with no usable characters.
+const DefaultSlug = "team"
+
+// maxDNSLabelLength is the RFC 1123 label limit used for Kubernetes
+// namespace and pod names.
+const maxDNSLabelLength = 63
+
+// Slug converts a human-friendly display name into a Docker/K8s/NATS-safe
+// slug. It lowercases the string, replaces spaces with hyphens, strips
+// anything but [a-z0-9_-], collapses consecutive hyphens, trims leading and
+// trailing hyphens, and truncates to MaxSlugLength. Slug is idempotent.
+func Slug(name string) string {
+	s := strings.ToLower(strings.TrimSpace(name))
+	s = strings.ReplaceAll(s, " ", "-")
+	var b strings.Builder
+	for _, r := range s {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	s = b.String()
+	for strings.Contains(s, "--") {
+		s = strings.ReplaceAll(s, "--", "-")
+	}
+	s = strings.Trim(s, "-")
+	if len(s) > MaxSlugLength {
+		s = strings.TrimRight(s[:MaxSlugLength], "-")
+	}
+	if s == "" {
+		s = DefaultSlug
+	}
+	return s
+}
+
+// DNSLabel joins prefix and slug into a valid RFC 1123 label, as required
+// for Kubernetes namespace and pod names. Labels that are already valid are
+// returned unchanged. Otherwise underscores become hyphens and the label is
+// shortened to fit 63 characters, with a hash of the slug appended so that
+// distinct slugs still map to distinct labels.
+func DNSLabel(prefix, slug string) string {
+	label := prefix + slug
+	if isDNSLabel(label) {
+		return label
+	}
+	label = strings.ReplaceAll(label, "_", "-")
+	for strings.Contains(label, "--") {
+		label = strings.ReplaceAll(label, "--", "-")
+	}
+	sum := sha256.Sum256([]byte(slug))
+	suffix := hex.EncodeToString(sum[:4])
+	if keep := maxDNSLabelLength - len(suffix) - 1; len(label) > keep {
+		label = label[:keep]
+	}
+	return strings.Trim(label, "-") + "-" + suffix
+}
+
+// isDNSLabel reports whether s is a valid RFC 1123 label.
+func isDNSLabel(s string) bool {
+	if s == "" || len(s) > maxDNSLabelLength || s[0] == '-' || s[len(s)-1] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
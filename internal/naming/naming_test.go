@@ -0,0 +1,99 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Test", "test"},
+		{"My Team", "my-team"},
+		{"Agent Squad!", "agent-squad"},
+		{"  Hello World  ", "hello-world"},
+		{"UPPERCASE", "uppercase"},
+		{"with---multiple---dashes", "with-multiple-dashes"},
+		{"special@#chars$%", "specialchars"},
+		{"already-valid", "already-valid"},
+		{"under_scores", "under_scores"},
+		{"123numeric", "123numeric"},
+		{"dots.and/slashes", "dotsandslashes"},
+		{"nats>wild*card", "natswildcard"},
+		{"tab\tand\nnewline", "tabandnewline"},
+		{"Équipe Données", "quipe-donnes"},
+		{"日本語チーム", "team"},
+		{"-leading and trailing-", "leading-and-trailing"},
+		{"a - b", "a-b"},
+		{"Hello  World", "hello-world"},
+		{"UPPER-case", "upper-case"},
+		{"   ", "team"},
+		{"@#$%", "team"},
+		{"", "team"},
+	}
+	for _, tt := range tests {
+		if got := Slug(tt.input); got != tt.expected {
+			t.Errorf("Slug(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSlug_Length(t *testing.T) {
+	long := strings.Repeat("a", 100)
+	if got := Slug(long); len(got) != MaxSlugLength {
+		t.Errorf("len(Slug(100 chars)) = %d, want %d", len(got), MaxSlugLength)
+	}
+
+	// A hyphen at the cut is trimmed.
+	name := strings.Repeat("a", MaxSlugLength-1) + " b"
+	if got := Slug(name); strings.HasSuffix(got, "-") || len(got) != MaxSlugLength-1 {
+		t.Errorf("Slug(%q) = %q", name, got)
+	}
+}
+
+func TestSlug_Idempotent(t *testing.T) {
+	for _, name := range []string{"My Team", "under_scores", strings.Repeat("x y ", 40), "@#$", "Équipe"} {
+		once := Slug(name)
+		if twice := Slug(once); twice != once {
+			t.Errorf("Slug(Slug(%q)) = %q, want %q", name, twice, once)
+		}
+	}
+}
+
+func TestSlug_SafeForSubjectsAndContainers(t *testing.T) {
+	for _, name := range []string{"a.b", "a*b", "a>b", "a b", "A/B\\C", "x:y", "\x00"} {
+		got := Slug(name)
+		if strings.ContainsAny(got, ".*> /\\:\x00") {
+			t.Errorf("Slug(%q) = %q contains unsafe characters", name, got)
+		}
+	}
+}
+
+func TestDNSLabel(t *testing.T) {
+	if got := DNSLabel("agentcrew-", "billing"); got != "agentcrew-billing" {
+		t.Errorf("valid label changed: %q", got)
+	}
+
+	a := DNSLabel("agentcrew-", "data_team")
+	b := DNSLabel("agentcrew-", "data-team")
+	if a == b {
+		t.Errorf("distinct slugs map to the same label %q", a)
+	}
+	if !isDNSLabel(a) || !strings.HasPrefix(a, "agentcrew-data-team-") {
+		t.Errorf("DNSLabel(data_team) = %q", a)
+	}
+
+	long := Slug(strings.Repeat("a", 100))
+	got := DNSLabel("agentcrew-", long)
+	if !isDNSLabel(got) || len(got) != maxDNSLabelLength {
+		t.Errorf("DNSLabel(long) = %q (len %d)", got, len(got))
+	}
+	if DNSLabel("agentcrew-", long[:60]) == got {
+		t.Error("distinct long slugs map to the same label")
+	}
+	if DNSLabel("agentcrew-", long) != got {
+		t.Error("DNSLabel is not deterministic")
+	}
+}
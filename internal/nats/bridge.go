@@ -3,11 +3,14 @@ package nats
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/helmcode/agent-crew/internal/claude"
 	"github.com/helmcode/agent-crew/internal/permissions"
@@ -21,6 +24,40 @@ type BridgeConfig struct {
 	TeamName  string
 	Role      string // "leader"
 	Gate      *permissions.Gate
+
+	// MaxConcurrentRuns caps the user messages taken on at once: the run in
+	// flight plus those queued behind it. The inbox consumer delivers no
+	// more than that, so messages over the cap wait in the team stream until
+	// a run finishes. Zero means no cap.
+	MaxConcurrentRuns int
+
+	// TelemetryLevel is the protocol telemetry level that selects which
+	// activity events are published. Empty means
+	// protocol.DefaultTelemetryLevel. A set_telemetry_level command changes
+	// it at runtime.
+	TelemetryLevel string
+
+	// PlanApproval makes chat runs stop at a plan: the agent may only use
+	// read-only tools, and its result is published as a plan awaiting
+	// approval. The user message that approves it runs with full permissions.
+	PlanApproval bool
+
+	// InboxStartTime bounds which user messages are delivered the first time
+	// the durable inbox consumer is created, typically the sidecar start time.
+	// Later restarts resume from the last acknowledged message.
+	InboxStartTime time.Time
+
+	// MemoryContext is the summary the leader wrote when the team last
+	// stopped. It is put before the first message the agent runs.
+	MemoryContext string
+
+	// OnConfigUpdate writes the files of a config_update message into the
+	// agent's workspace. Config updates are ignored when it is nil.
+	OnConfigUpdate func(files []protocol.ConfigFile) error
+
+	// OnRunStart, if set, is called with the message ID of each run before
+	// its input is sent to the agent.
+	OnRunStart func(runID string)
 }
 
 // publisher is the interface used by Bridge to publish protocol messages.
@@ -28,12 +65,22 @@ type BridgeConfig struct {
 type publisher interface {
 	Publish(subject string, msg *protocol.Message) error
 	Subscribe(subject string, handler func(*protocol.Message)) error
+	SubscribeCore(subject string, handler func(*protocol.Message)) error
+	SubscribeDurable(subject, durable string, startTime time.Time, maxAckPending int, handler func(*protocol.Message, *Delivery)) error
 }
 
 // pendingMessage holds a queued user message with its correlation metadata.
 type pendingMessage struct {
+	id             string // Protocol message ID, reported in the run queue.
 	content        string
+	source         string
 	scheduledRunID string
+	delivery       *Delivery // Acked when the agent run for this message starts.
+
+	// approvedPlanRunID is set on the message approving a run's plan.
+	approvedPlanRunID string
+	// overrides change the model or generation settings of this run only.
+	overrides *protocol.RunOverrides
 }
 
 // Bridge connects NATS messaging with an AI agent process.
@@ -49,11 +96,63 @@ type Bridge struct {
 
 	userMsgs chan pendingMessage // Queued user messages for serial processing.
 
+	// Run queue, guarded by mu: waitingIDs are the IDs of the messages in
+	// userMsgs, runningID is set while a run is in flight, and parked holds
+	// the messages that arrived while MaxConcurrentRuns were taken or
+	// userMsgs was full. The inbox consumer keeps it to at most one message;
+	// the rest only arrive without JetStream, which does not redeliver, so
+	// they are never dropped.
+	waitingIDs []string
+	running    bool
+	runningID  string
+	parked     []pendingMessage
+
 	mu              sync.Mutex
 	scheduledRunIDs []string // FIFO queue of correlation IDs from scheduled run requests
 	errorPublished  bool     // Guards against duplicate error leader_responses within one interaction.
 
+	// planRunIDs runs alongside scheduledRunIDs: the run's message ID when
+	// it is planning, so that its result is published as a plan, or "".
+	planRunIDs   []string
+	pendingPlans map[string]bool // Runs whose plan awaits approval.
+
 	mcpStatusPublished bool // Guards against re-publishing MCP status on every system/init event.
+
+	// lastQuota is the rate limit state last published for each limit
+	// type, so that repeated rate_limit_events are only published once.
+	lastQuota map[string]protocol.QuotaPayload
+
+	// throttledUntil is set while the relay has asked for less verbose
+	// activity events (see protocol.CommandThrottle).
+	throttledUntil time.Time
+
+	// telemetryLevel overrides config.TelemetryLevel once a
+	// set_telemetry_level command arrived.
+	telemetryLevel string
+
+	// Inbox dedup state: the highest stream sequence queued and acked, and
+	// the highest per-conversation sequence seen for each conversation.
+	queuedStreamSeq uint64
+	ackedStreamSeq  uint64
+	conversationSeq map[string]int64
+
+	// Crash supervision: restartMu serializes restarts, consecutiveRestarts
+	// drives the backoff, and managerStopped suppresses restarts after an
+	// explicit shutdown command.
+	restartMu           sync.Mutex
+	consecutiveRestarts int
+	managerStopped      bool
+
+	// Shutdown drain: once draining is set no new runs start, and runDone is
+	// closed when the run in flight (if any) finishes.
+	draining bool
+	runDone  chan struct{}
+
+	// Memory: memoryInjected is set once the first run received the
+	// previous deployment's memory, and memoryResult receives the result
+	// of the summary run started by Summarize.
+	memoryInjected bool
+	memoryResult   chan protocol.LeaderResponsePayload
 }
 
 // NewBridge creates a Bridge with the given components.
@@ -62,10 +161,37 @@ func NewBridge(config BridgeConfig, client *Client, manager provider.AgentManage
 		config:   config,
 		client:   client,
 		manager:  manager,
-		userMsgs: make(chan pendingMessage, 16),
+		userMsgs: make(chan pendingMessage, max(config.MaxConcurrentRuns, userMsgBuffer)),
 	}
 }
 
+// drainRedeliveryDelay is how long user messages refused while draining
+// wait before they are redelivered, giving the next sidecar time to start.
+const drainRedeliveryDelay = 10 * time.Second
+
+// userMsgBuffer is the least room of the user message queue; messages
+// that find it full are parked.
+const userMsgBuffer = 16
+
+// runLimit returns the cap on the user messages taken on at once, or 0 if
+// there is none.
+func (b *Bridge) runLimit() int {
+	return b.config.MaxConcurrentRuns
+}
+
+// inboxMaxAckPending returns how many inbox messages the consumer may
+// deliver unacknowledged. A message is acked when its run starts, so these
+// are the messages queued behind the run in flight. Without a cap, one is
+// delivered at a time, and the rest wait in the team stream.
+func (b *Bridge) inboxMaxAckPending() int {
+	return max(b.runLimit()-1, 1)
+}
+
+// inboxDurableName returns the durable consumer name for an agent's inbox.
+func inboxDurableName(agentName string) string {
+	return "inbox-" + agentName
+}
+
 // Start begins listening for NATS messages and forwarding Claude events.
 // It subscribes only to the team leader channel for user↔leader communication.
 func (b *Bridge) Start(ctx context.Context) error {
@@ -76,7 +202,20 @@ func (b *Bridge) Start(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("building leader channel: %w", err)
 	}
-	if err := b.client.Subscribe(leaderSubject, b.handleIncoming); err != nil {
+	// User messages are consumed through a durable consumer so that a burst
+	// of messages is handed to the agent in order and survives restarts.
+	if err := b.client.SubscribeDurable(leaderSubject, inboxDurableName(b.config.AgentName),
+		b.config.InboxStartTime, b.inboxMaxAckPending(), b.handleDelivery); err != nil {
+		return err
+	}
+
+	// System commands, such as interrupt, arrive on their own subject so
+	// that they do not wait behind queued user messages.
+	controlSubject, err := protocol.TeamControlChannel(b.config.TeamName)
+	if err != nil {
+		return fmt.Errorf("building control channel: %w", err)
+	}
+	if err := b.client.SubscribeCore(controlSubject, b.handleControl); err != nil {
 		return err
 	}
 
@@ -107,6 +246,69 @@ func (b *Bridge) Stop() {
 	slog.Info("bridge stopped", "agent", b.config.AgentName)
 }
 
+// handleDelivery processes a message from the durable inbox consumer. Only
+// user messages are acked late (when their run starts); everything else on
+// the leader channel is acked immediately. Redeliveries of messages that are
+// already queued or started, and user messages whose per-conversation
+// sequence was already seen, are not forwarded again.
+func (b *Bridge) handleDelivery(msg *protocol.Message, d *Delivery) {
+	if msg.Type != protocol.TypeUserMessage {
+		d.Ack()
+		b.handleIncoming(msg)
+		return
+	}
+
+	if b.isDraining() {
+		// Hand the message back for the next sidecar to pick up.
+		slog.Info("draining, not accepting user message", "agent", b.config.AgentName)
+		d.NakWithDelay(drainRedeliveryDelay)
+		return
+	}
+
+	if d != nil && d.StreamSeq != 0 {
+		b.mu.Lock()
+		queued, acked := b.queuedStreamSeq, b.ackedStreamSeq
+		b.mu.Unlock()
+		if d.StreamSeq <= acked {
+			d.Ack()
+			return
+		}
+		if d.StreamSeq <= queued {
+			// Still waiting behind the current run; keep it from expiring.
+			d.InProgress()
+			return
+		}
+	}
+
+	payload, err := protocol.ParsePayload[protocol.UserMessagePayload](msg)
+	if err == nil && payload.ConversationID != "" && payload.Sequence > 0 {
+		b.mu.Lock()
+		duplicate := payload.Sequence <= b.conversationSeq[payload.ConversationID]
+		b.mu.Unlock()
+		if duplicate {
+			slog.Info("skipping duplicate user message",
+				"agent", b.config.AgentName,
+				"conversation_id", payload.ConversationID,
+				"sequence", payload.Sequence,
+			)
+			d.Ack()
+			return
+		}
+	}
+
+	b.enqueueUserMessage(msg, d)
+}
+
+// handleControl processes a message from the control channel, which only
+// carries system commands.
+func (b *Bridge) handleControl(msg *protocol.Message) {
+	if msg.Type != protocol.TypeSystemCommand {
+		slog.Warn("ignoring message on control channel", "type", msg.Type, "from", msg.From)
+		return
+	}
+	b.handleIncoming(msg)
+}
+
 // handleIncoming processes an incoming NATS protocol message.
 func (b *Bridge) handleIncoming(msg *protocol.Message) {
 	slog.Info("bridge received message",
@@ -120,6 +322,8 @@ func (b *Bridge) handleIncoming(msg *protocol.Message) {
 		b.handleUserMessage(msg)
 	case protocol.TypeSystemCommand:
 		b.handleSystemCommand(msg)
+	case protocol.TypeConfigUpdate:
+		b.handleConfigUpdate(msg)
 	default:
 		slog.Debug("unhandled message type", "type", msg.Type)
 	}
@@ -129,24 +333,140 @@ func (b *Bridge) handleIncoming(msg *protocol.Message) {
 // This returns immediately so the NATS subscription callback is not blocked
 // while SendInput waits for the Claude process to finish.
 func (b *Bridge) handleUserMessage(msg *protocol.Message) {
+	b.enqueueUserMessage(msg, nil)
+}
+
+// enqueueUserMessage queues a user message together with its durable
+// delivery, if any. When MaxConcurrentRuns messages are already running or
+// queued, the message is parked until a run finishes; a parked delivery
+// stays unacked and is kept alive like a queued one.
+func (b *Bridge) enqueueUserMessage(msg *protocol.Message, d *Delivery) {
 	slog.Info("handling user message", "agent", b.config.AgentName, "from", msg.From)
 
 	payload, err := protocol.ParsePayload[protocol.UserMessagePayload](msg)
 	if err != nil {
 		slog.Error("failed to parse user message", "error", err)
+		d.Ack()
 		return
 	}
 
 	pm := pendingMessage{
+		id:             msg.MessageID,
 		content:        payload.Content,
+		source:         payload.Source,
 		scheduledRunID: payload.ScheduledRunID,
+		delivery:       d,
+
+		approvedPlanRunID: payload.ApprovedPlanRunID,
+		overrides:         payload.Overrides,
 	}
 
-	select {
-	case b.userMsgs <- pm:
+	b.mu.Lock()
+	queued := b.admitLocked(pm)
+	if d != nil && d.StreamSeq > b.queuedStreamSeq {
+		b.queuedStreamSeq = d.StreamSeq
+	}
+	if payload.ConversationID != "" && payload.Sequence > 0 {
+		if b.conversationSeq == nil {
+			b.conversationSeq = make(map[string]int64)
+		}
+		b.conversationSeq[payload.ConversationID] = payload.Sequence
+	}
+	parked := len(b.parked)
+	b.mu.Unlock()
+
+	if queued {
 		slog.Info("user message queued", "agent", b.config.AgentName, "content_length", len(payload.Content))
-	default:
-		slog.Warn("user message queue full, dropping message", "agent", b.config.AgentName)
+	} else {
+		slog.Info("run queue full, parking user message", "agent", b.config.AgentName,
+			"max_concurrent_runs", b.runLimit(), "parked", parked)
+	}
+	b.publishRunQueue()
+}
+
+// admitLocked queues pm for processUserMessages and reports true, or parks
+// it when the run queue is full or earlier messages are already parked.
+// b.mu must be held.
+func (b *Bridge) admitLocked(pm pendingMessage) bool {
+	if len(b.parked) == 0 && !b.runQueueFullLocked() {
+		select {
+		case b.userMsgs <- pm:
+			b.waitingIDs = append(b.waitingIDs, pm.id)
+			return true
+		default:
+		}
+	}
+	b.parked = append(b.parked, pm)
+	return false
+}
+
+// runQueueFullLocked reports whether MaxConcurrentRuns messages are running
+// or queued. b.mu must be held.
+func (b *Bridge) runQueueFullLocked() bool {
+	if b.runLimit() == 0 {
+		return false
+	}
+	inFlight := len(b.waitingIDs)
+	if b.running {
+		inFlight++
+	}
+	return inFlight >= b.runLimit()
+}
+
+// unparkLocked moves parked messages to the run queue, in order, while it
+// has room. b.mu must be held.
+func (b *Bridge) unparkLocked() {
+	for len(b.parked) > 0 && !b.runQueueFullLocked() {
+		select {
+		case b.userMsgs <- b.parked[0]:
+			b.waitingIDs = append(b.waitingIDs, b.parked[0].id)
+			b.parked = b.parked[1:]
+		default:
+			return
+		}
+	}
+}
+
+// dequeuedLocked removes a message taken from userMsgs from waitingIDs.
+// b.mu must be held.
+func (b *Bridge) dequeuedLocked(id string) {
+	for i, w := range b.waitingIDs {
+		if w == id {
+			b.waitingIDs = append(b.waitingIDs[:i], b.waitingIDs[i+1:]...)
+			return
+		}
+	}
+}
+
+// publishRunQueue reports the run queue to the API, which shows queued
+// messages' positions in the chat.
+func (b *Bridge) publishRunQueue() {
+	b.mu.Lock()
+	payload := protocol.RunQueuePayload{
+		AgentName:         b.config.AgentName,
+		MaxConcurrentRuns: b.runLimit(),
+		Running:           b.runningID,
+		Waiting:           make([]string, 0, len(b.waitingIDs)+len(b.parked)),
+		Parked:            len(b.parked),
+	}
+	payload.Waiting = append(payload.Waiting, b.waitingIDs...)
+	for _, pm := range b.parked {
+		payload.Waiting = append(payload.Waiting, pm.id)
+	}
+	b.mu.Unlock()
+
+	msg, err := protocol.NewMessage(b.config.AgentName, "system", protocol.TypeRunQueue, payload)
+	if err != nil {
+		slog.Error("failed to create run queue message", "error", err)
+		return
+	}
+	subject, err := protocol.TeamActivityChannel(b.config.TeamName)
+	if err != nil {
+		slog.Error("failed to build activity channel", "error", err)
+		return
+	}
+	if err := b.client.Publish(subject, msg); err != nil {
+		slog.Debug("failed to publish run queue", "error", err)
 	}
 }
 
@@ -161,18 +481,143 @@ func (b *Bridge) processUserMessages(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case pm := <-b.userMsgs:
-			// Reset error dedup flag for new interaction.
 			b.mu.Lock()
+			b.dequeuedLocked(pm.id)
+			if b.draining {
+				// Handed back, so the message is redelivered after the
+				// restart rather than once its ack wait expires.
+				b.mu.Unlock()
+				pm.delivery.NakWithDelay(drainRedeliveryDelay)
+				continue
+			}
+			b.running = true
+			b.runningID = pm.id
+			// Reset error dedup flag for new interaction.
 			b.errorPublished = false
 			b.scheduledRunIDs = append(b.scheduledRunIDs, pm.scheduledRunID)
+			planRunID := ""
+			if b.needsPlan(pm) {
+				planRunID = pm.id
+			}
+			b.planRunIDs = append(b.planRunIDs, planRunID)
+			if pm.delivery != nil && pm.delivery.StreamSeq > b.ackedStreamSeq {
+				b.ackedStreamSeq = pm.delivery.StreamSeq
+			}
+			done := make(chan struct{})
+			b.runDone = done
 			b.mu.Unlock()
+			b.publishRunQueue()
 
-			slog.Info("forwarding user message to claude", "agent", b.config.AgentName, "content_length", len(pm.content))
-			if err := b.manager.SendInput(pm.content); err != nil {
-				slog.Error("failed to send user message to claude", "error", err)
-			}
+			b.runUserMessage(ctx, pm)
+
+			b.mu.Lock()
+			b.runDone = nil
+			b.running = false
+			b.runningID = ""
+			b.unparkLocked()
+			b.mu.Unlock()
+			close(done)
+			b.publishRunQueue()
+		}
+	}
+}
+
+// runUserMessage forwards one user message to the agent and blocks until the
+// run finishes, restarting the manager if the run crashed.
+func (b *Bridge) runUserMessage(ctx context.Context, pm pendingMessage) {
+	// The run is starting: ack now so the next message is released
+	// and a restarted sidecar does not run this one again.
+	pm.delivery.Ack()
+
+	content, overrides := pm.content, pm.overrides
+	switch {
+	case pm.approvedPlanRunID != "":
+		if !b.takePendingPlan(pm.approvedPlanRunID) {
+			slog.Warn("ignoring approval of unknown plan", "agent", b.config.AgentName, "run_id", pm.approvedPlanRunID)
+			b.publishLeaderResponse("", "failed", "", "No plan is awaiting approval for this run.")
+			return
 		}
+		slog.Info("plan approved, running with full permissions", "agent", b.config.AgentName, "run_id", pm.approvedPlanRunID)
+	case b.needsPlan(pm):
+		content = planPrompt + content
+		readOnly := protocol.RunOverrides{ReadOnly: true}
+		if overrides != nil {
+			readOnly = *overrides
+			readOnly.ReadOnly = true
+		}
+		overrides = &readOnly
+	}
+	content = b.withMemory(content)
+
+	if b.config.OnRunStart != nil {
+		b.config.OnRunStart(pm.id)
+	}
+	slog.Info("forwarding user message to claude", "agent", b.config.AgentName, "content_length", len(content))
+	if err := b.sendInput(content, overrides); err != nil {
+		if errors.Is(err, provider.ErrInterrupted) {
+			// The session survives an interrupt: no restart needed.
+			b.failInterruptedRun()
+			return
+		}
+		slog.Error("failed to send user message to claude", "error", err)
+		b.mu.Lock()
+		stopped := b.managerStopped
+		b.mu.Unlock()
+		if !stopped {
+			b.failCrashedRun()
+			b.restartManager(ctx, "crashed invocation")
+		}
+		return
 	}
+	b.resetRestartBackoff()
+}
+
+// Drain stops the bridge from starting new runs and waits for the run in
+// flight, if any, to finish. Messages not yet started are handed back so
+// they are redelivered to the next sidecar. Returns false if ctx expired first.
+func (b *Bridge) Drain(ctx context.Context) bool {
+	b.mu.Lock()
+	b.draining = true
+	done := b.runDone
+	b.mu.Unlock()
+
+	if done == nil {
+		return true
+	}
+	slog.Info("waiting for in-flight run to finish", "agent", b.config.AgentName)
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// BridgeStatus is a point-in-time view of the bridge's message handling.
+type BridgeStatus struct {
+	QueueDepth          int  `json:"queue_depth"`   // user messages waiting to start
+	RunInFlight         bool `json:"run_in_flight"` // a user message is being processed
+	Draining            bool `json:"draining"`
+	ConsecutiveRestarts int  `json:"consecutive_restarts"`
+}
+
+// Status returns the bridge's current state for the sidecar admin endpoint.
+func (b *Bridge) Status() BridgeStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BridgeStatus{
+		QueueDepth:          len(b.userMsgs),
+		RunInFlight:         b.runDone != nil,
+		Draining:            b.draining,
+		ConsecutiveRestarts: b.consecutiveRestarts,
+	}
+}
+
+// isDraining reports whether Drain has been called.
+func (b *Bridge) isDraining() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.draining
 }
 
 // handleSystemCommand processes system-level commands (shutdown, restart, etc.).
@@ -186,23 +631,133 @@ func (b *Bridge) handleSystemCommand(msg *protocol.Message) {
 	switch payload.Command {
 	case "shutdown":
 		slog.Info("received shutdown command", "from", msg.From)
+		b.mu.Lock()
+		b.managerStopped = true
+		b.mu.Unlock()
 		if err := b.manager.Stop(); err != nil {
 			slog.Error("failed to stop claude process", "error", err)
 		}
 	case "restart":
 		prompt := payload.Args["resume_prompt"]
 		slog.Info("received restart command", "from", msg.From)
+		b.mu.Lock()
+		b.managerStopped = false
+		b.mu.Unlock()
 		if err := b.manager.Restart(prompt); err != nil {
 			slog.Error("failed to restart claude process", "error", err)
 		}
+	case "interrupt":
+		im, ok := b.manager.(provider.Interruptible)
+		if !ok {
+			slog.Warn("agent provider does not support interrupting runs", "from", msg.From)
+			return
+		}
+		if !im.Interrupt() {
+			slog.Info("received interrupt command with no run in progress", "from", msg.From)
+			return
+		}
+		slog.Info("interrupted the run in progress", "from", msg.From)
 	case "compact_context":
 		slog.Info("received compact_context command", "from", msg.From)
 		// Context compaction is handled by the manager internally.
+	case protocol.CommandThrottle:
+		b.setThrottle(payload.Args["level"], payload.Args["ttl_seconds"], msg.From)
+	case protocol.CommandSetTelemetryLevel:
+		b.setTelemetryLevel(payload.Args["level"], msg.From)
 	default:
 		slog.Warn("unknown system command", "command", payload.Command)
 	}
 }
 
+// setThrottle applies a throttle command: level reduced trims activity
+// events for ttlSeconds (protocol.DefaultThrottleTTL if unset), and level
+// off ends it.
+func (b *Bridge) setThrottle(level, ttlSeconds, from string) {
+	var until time.Time
+	switch level {
+	case protocol.ThrottleOff:
+	case protocol.ThrottleReduced:
+		ttl := protocol.DefaultThrottleTTL
+		if n, err := strconv.Atoi(ttlSeconds); err == nil && n > 0 {
+			ttl = time.Duration(n) * time.Second
+		}
+		until = time.Now().Add(ttl)
+	default:
+		slog.Warn("unknown throttle level", "level", level, "from", from)
+		return
+	}
+	b.mu.Lock()
+	wasThrottled := time.Now().Before(b.throttledUntil)
+	b.throttledUntil = until
+	b.mu.Unlock()
+	if throttled := !until.IsZero(); throttled != wasThrottled {
+		slog.Info("activity throttle changed", "agent", b.config.AgentName, "level", level, "from", from)
+	}
+}
+
+// isThrottled reports whether activity events are being trimmed.
+func (b *Bridge) isThrottled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Now().Before(b.throttledUntil)
+}
+
+// setTelemetryLevel applies a set_telemetry_level command.
+func (b *Bridge) setTelemetryLevel(level, from string) {
+	if !protocol.ValidTelemetryLevel(level) {
+		slog.Warn("unknown telemetry level", "level", level, "from", from)
+		return
+	}
+	b.mu.Lock()
+	b.telemetryLevel = level
+	b.mu.Unlock()
+	slog.Info("telemetry level changed", "agent", b.config.AgentName, "level", level, "from", from)
+}
+
+// telemetry returns the telemetry level activity events are published at.
+func (b *Bridge) telemetry() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.telemetryLevel != "":
+		return b.telemetryLevel
+	case b.config.TelemetryLevel != "":
+		return b.config.TelemetryLevel
+	}
+	return protocol.DefaultTelemetryLevel
+}
+
+// telemetryPublishes reports whether events of eventType are published as
+// activity at the given telemetry level. Unknown events are published at
+// every level but minimal, whatever their type.
+func telemetryPublishes(level, eventType string, unknown bool) bool {
+	switch level {
+	case protocol.TelemetryMinimal:
+		return eventType == "tool_use" || eventType == "error"
+	case protocol.TelemetryVerbose:
+		return true
+	}
+	return unknown || eventType != "result"
+}
+
+// handleConfigUpdate writes regenerated config files into the workspace.
+func (b *Bridge) handleConfigUpdate(msg *protocol.Message) {
+	payload, err := protocol.ParsePayload[protocol.ConfigUpdatePayload](msg)
+	if err != nil {
+		slog.Error("failed to parse config update", "error", err)
+		return
+	}
+	if b.config.OnConfigUpdate == nil {
+		slog.Warn("ignoring config update", "agent", b.config.AgentName, "files", len(payload.Files))
+		return
+	}
+	if err := b.config.OnConfigUpdate(payload.Files); err != nil {
+		slog.Error("failed to apply config update", "agent", b.config.AgentName, "error", err)
+		return
+	}
+	slog.Info("applied config update", "agent", b.config.AgentName, "from", msg.From, "files", len(payload.Files))
+}
+
 // forwardEvents reads agent stdout events and publishes significant ones to NATS.
 func (b *Bridge) forwardEvents(ctx context.Context) {
 	defer b.wg.Done()
@@ -230,6 +785,15 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 	// Convert to claude.StreamEvent for operations that need the claude-specific type.
 	claudeEvent := provider.ToClaudeStreamEvent(event)
 
+	// Pass on events the parser did not understand rather than drop them.
+	if event.Unknown {
+		if event.Type == claude.RateLimitEventType {
+			b.publishQuota(event.Raw)
+		}
+		b.publishActivityEvent(claudeEvent, "unknown event: "+event.Type)
+		return
+	}
+
 	switch event.Type {
 	case "tool_use":
 		// Publish activity event for the tool call so the UI can show progress.
@@ -240,15 +804,25 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 		}
 		b.publishActivityEvent(claudeEvent, action)
 
-		// Check permissions before allowing tool execution.
-		if b.config.Gate != nil {
-			decision := b.config.Gate.Evaluate(toolName, command, paths)
+		// Check permissions before allowing tool execution. A run that is
+		// planning may only read.
+		gate := b.config.Gate
+		planning := b.isPlanning()
+		if planning {
+			gate = gate.ReadOnly()
+		}
+		if gate != nil {
+			decision := gate.Evaluate(toolName, command, paths)
+			if !decision.Allowed && planning {
+				decision.Reason += " (only read-only tools may be used until the plan is approved)"
+			}
 			if !decision.Allowed {
 				slog.Warn("tool use denied by permission gate",
 					"tool", toolName,
 					"command", command,
 					"reason", decision.Reason,
 				)
+				b.publishPermissionDenied(toolName, command, decision.Reason)
 				// Send denial result back to the agent.
 				denial := claude.FormatToolResult(
 					"Permission denied: "+decision.Reason,
@@ -284,6 +858,11 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 		}
 
 	case "result":
+		// Report what the turn cost, whether or not it succeeded.
+		b.publishUsage(event)
+		// Only published at the verbose telemetry level.
+		b.publishActivityEvent(claudeEvent, "result")
+
 		// Check if the agent returned an error (billing, auth, etc.).
 		if event.IsError {
 			// Skip if an error was already published for this interaction
@@ -299,7 +878,7 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 				"friendly", friendlyMsg,
 			)
 
-			b.publishLeaderResponse("", "failed", "", friendlyMsg)
+			b.publishFailedResponse(event.ErrorCode, friendlyMsg)
 			b.errorPublished = true
 			*currentResult = ""
 			return
@@ -372,7 +951,7 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 		// with the Settings + Redeploy buttons (same as deploy errors).
 		if event.IsError && !b.errorPublished {
 			friendlyMsg := claudeEvent.FriendlyError()
-			b.publishLeaderResponse("", "failed", "", friendlyMsg)
+			b.publishFailedResponse(event.ErrorCode, friendlyMsg)
 			b.errorPublished = true
 			*currentResult = ""
 		}
@@ -380,10 +959,11 @@ func (b *Bridge) processEvent(event *provider.StreamEvent, currentResult *string
 }
 
 // publishActivityEvent sends an intermediate activity event to the team activity NATS channel.
+// The team's telemetry level decides which events are published and whether
+// they carry the raw event.
 func (b *Bridge) publishActivityEvent(event *claude.StreamEvent, action string) {
-	rawEvent, err := json.Marshal(event)
-	if err != nil {
-		slog.Error("failed to marshal activity event", "error", err)
+	level := b.telemetry()
+	if !telemetryPublishes(level, event.Type, event.Unknown) {
 		return
 	}
 
@@ -392,7 +972,25 @@ func (b *Bridge) publishActivityEvent(event *claude.StreamEvent, action string)
 		AgentName: b.config.AgentName,
 		ToolName:  event.Name,
 		Action:    action,
-		Payload:   rawEvent,
+
+		UnknownEvent: event.Unknown,
+	}
+	switch {
+	case level == protocol.TelemetryMinimal:
+	case event.Unknown:
+		payload.Payload = event.Raw
+	default:
+		rawEvent, err := json.Marshal(event)
+		if err != nil {
+			slog.Error("failed to marshal activity event", "error", err)
+			return
+		}
+		payload.Payload = rawEvent
+	}
+	// While the relay is behind, keep the event but not its bulkiest part.
+	if (event.Type == "tool_result" || event.Type == "reasoning") && b.isThrottled() {
+		payload.Payload = nil
+		payload.Throttled = true
 	}
 
 	msg, err := protocol.NewMessage(
@@ -417,24 +1015,95 @@ func (b *Bridge) publishActivityEvent(event *claude.StreamEvent, action string)
 	}
 }
 
+// publishPermissionDenied reports a tool call refused by the permission gate
+// as an activity event.
+func (b *Bridge) publishPermissionDenied(toolName, command, reason string) {
+	rawPayload, err := json.Marshal(protocol.PermissionDeniedPayload{Command: command, Reason: reason})
+	if err != nil {
+		slog.Error("failed to marshal permission denial", "error", err)
+		return
+	}
+
+	msg, err := protocol.NewMessage(
+		b.config.AgentName,
+		"system",
+		protocol.TypeActivityEvent,
+		protocol.ActivityEventPayload{
+			EventType: protocol.ActivityPermissionDenied,
+			AgentName: b.config.AgentName,
+			ToolName:  toolName,
+			Action:    "Permission denied: " + reason,
+			Payload:   rawPayload,
+		},
+	)
+	if err != nil {
+		slog.Error("failed to create permission denial message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(b.config.TeamName)
+	if err != nil {
+		slog.Error("failed to build activity channel", "error", err)
+		return
+	}
+
+	if err := b.client.Publish(subject, msg); err != nil {
+		slog.Debug("failed to publish permission denial", "error", err)
+	}
+}
+
 // publishLeaderResponse sends a leader response to the team leader NATS channel.
 func (b *Bridge) publishLeaderResponse(refMsgID, status, result, errMsg string) {
+	b.publishLeaderPayload(refMsgID, protocol.LeaderResponsePayload{
+		Status: status,
+		Result: result,
+		Error:  errMsg,
+	})
+}
+
+// publishFailedResponse publishes a failed leader_response carrying the
+// agent's error code, so the API can tell rate limit errors apart.
+func (b *Bridge) publishFailedResponse(errorCode, errMsg string) {
+	b.publishLeaderPayload("", protocol.LeaderResponsePayload{
+		Status:    "failed",
+		Error:     errMsg,
+		ErrorCode: errorCode,
+	})
+}
+
+// publishLeaderPayload tags payload with the next scheduled run ID and
+// publishes it on the leader channel.
+func (b *Bridge) publishLeaderPayload(refMsgID string, payload protocol.LeaderResponsePayload) {
 	// Pop the next scheduled run ID from the FIFO queue.
 	// Order is preserved because Claude processes messages sequentially.
 	b.mu.Lock()
+	// The result of a summary run goes to Summarize, not to the user.
+	if b.takeMemoryResultLocked(payload) {
+		b.mu.Unlock()
+		return
+	}
 	var runID string
 	if len(b.scheduledRunIDs) > 0 {
 		runID = b.scheduledRunIDs[0]
 		b.scheduledRunIDs = b.scheduledRunIDs[1:]
 	}
+	var planRunID string
+	if len(b.planRunIDs) > 0 {
+		planRunID = b.planRunIDs[0]
+		b.planRunIDs = b.planRunIDs[1:]
+	}
+	// A planning run's result is its plan, which waits for approval.
+	if planRunID != "" && payload.Status == "completed" {
+		payload.Status = protocol.StatusAwaitingApproval
+		payload.Plan = &protocol.RunPlan{RunID: planRunID, Text: payload.Result}
+		if b.pendingPlans == nil {
+			b.pendingPlans = make(map[string]bool)
+		}
+		b.pendingPlans[planRunID] = true
+	}
 	b.mu.Unlock()
 
-	payload := protocol.LeaderResponsePayload{
-		Status:         status,
-		Result:         result,
-		Error:          errMsg,
-		ScheduledRunID: runID,
-	}
+	payload.ScheduledRunID = runID
 
 	msg, err := protocol.NewMessage(
 		b.config.AgentName,
@@ -479,6 +1148,96 @@ func mapMcpRuntimeStatus(claudeStatus string) string {
 	}
 }
 
+// publishUsage publishes the cost and token usage of a result event as a
+// TypeUsage message, so the API can record it for cost reports. Events
+// without usage information are ignored.
+func (b *Bridge) publishUsage(event *provider.StreamEvent) {
+	if event.CostUSD == 0 && event.Usage == nil {
+		return
+	}
+	payload := protocol.UsagePayload{
+		AgentName: b.config.AgentName,
+		CostUSD:   event.CostUSD,
+	}
+	if u := event.Usage; u != nil {
+		payload.InputTokens = u.InputTokens
+		payload.OutputTokens = u.OutputTokens
+		payload.CacheReadTokens = u.CacheReadTokens
+		payload.CacheWriteTokens = u.CacheWriteTokens
+	}
+
+	msg, err := protocol.NewMessage(b.config.AgentName, "system", protocol.TypeUsage, payload)
+	if err != nil {
+		slog.Error("failed to create usage message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(b.config.TeamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for usage", "error", err)
+		return
+	}
+
+	if err := b.client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish usage", "error", err)
+	}
+}
+
+// publishQuota publishes the rate limit state of a rate_limit_event to the
+// team's activity channel, unless it is the state last published for that
+// limit.
+func (b *Bridge) publishQuota(raw string) {
+	info, ok := claude.ParseRateLimitEvent([]byte(raw))
+	if !ok {
+		return
+	}
+	payload := protocol.QuotaPayload{
+		AgentName:   b.config.AgentName,
+		Status:      info.Status,
+		LimitType:   info.Type,
+		Utilization: info.Utilization,
+	}
+	if !info.ResetsAt.IsZero() {
+		payload.ResetsAt = &info.ResetsAt
+	}
+	if last, ok := b.lastQuota[info.Type]; ok && sameQuota(last, payload) {
+		return
+	}
+	if b.lastQuota == nil {
+		b.lastQuota = make(map[string]protocol.QuotaPayload)
+	}
+	b.lastQuota[info.Type] = payload
+
+	msg, err := protocol.NewMessage(b.config.AgentName, "system", protocol.TypeQuota, payload)
+	if err != nil {
+		slog.Error("failed to create quota message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(b.config.TeamName)
+	if err != nil {
+		slog.Error("failed to build activity channel for quota", "error", err)
+		return
+	}
+
+	if err := b.client.Publish(subject, msg); err != nil {
+		slog.Error("failed to publish quota", "error", err)
+	}
+}
+
+// sameQuota reports whether two rate limit states are the same, counting
+// utilizations within a percentage point as equal.
+func sameQuota(a, b protocol.QuotaPayload) bool {
+	if a.Status != b.Status || (a.ResetsAt == nil) != (b.ResetsAt == nil) ||
+		(a.ResetsAt != nil && !a.ResetsAt.Equal(*b.ResetsAt)) {
+		return false
+	}
+	if a.Utilization == nil || b.Utilization == nil {
+		return a.Utilization == nil && b.Utilization == nil
+	}
+	return math.Abs(*a.Utilization-*b.Utilization) < 0.01
+}
+
 // publishMcpRuntimeStatus parses MCP server statuses from a system/init event
 // and publishes them as a TypeMcpStatus message via NATS.
 func (b *Bridge) publishMcpRuntimeStatus(rawServers string) {
@@ -1,9 +1,12 @@
 package nats
 
 import (
+	"context"
 	"encoding/json"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/helmcode/agent-crew/internal/claude"
 	"github.com/helmcode/agent-crew/internal/permissions"
@@ -21,6 +24,7 @@ type publishedMsg struct {
 type fakePublisher struct {
 	mu       sync.Mutex
 	messages []publishedMsg
+	core     map[string]func(*protocol.Message) // SubscribeCore handlers by subject
 }
 
 func (f *fakePublisher) Publish(subject string, msg *protocol.Message) error {
@@ -34,6 +38,20 @@ func (f *fakePublisher) Subscribe(_ string, _ func(*protocol.Message)) error {
 	return nil
 }
 
+func (f *fakePublisher) SubscribeCore(subject string, handler func(*protocol.Message)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.core == nil {
+		f.core = make(map[string]func(*protocol.Message))
+	}
+	f.core[subject] = handler
+	return nil
+}
+
+func (f *fakePublisher) SubscribeDurable(_, _ string, _ time.Time, _ int, _ func(*protocol.Message, *Delivery)) error {
+	return nil
+}
+
 func (f *fakePublisher) getMessages() []publishedMsg {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -144,6 +162,10 @@ func toProviderEvent(ce claude.StreamEvent) provider.StreamEvent {
 		Result:    ce.Result,
 		ErrorCode: ce.ErrorCode,
 		SessionID: ce.SessionID,
+		Unknown:   ce.Unknown,
+	}
+	if len(ce.Raw) > 0 {
+		pe.Raw = string(ce.Raw)
 	}
 	if len(ce.Message) > 0 {
 		pe.Message = string(ce.Message)
@@ -479,6 +501,167 @@ func TestProcessEvent_ToolResultPublishesActivityEvent(t *testing.T) {
 	}
 }
 
+func TestProcessEvent_ThrottleTrimsActivityEvents(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "throttleteam", Role: "leader"},
+		client: pub,
+	}
+	throttle := func(level string) {
+		cmd, err := protocol.NewMessage("relay", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{
+			Command: protocol.CommandThrottle,
+			Args:    map[string]string{"level": level, "ttl_seconds": "60"},
+		})
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		bridge.handleSystemCommand(cmd)
+	}
+	publish := func(eventType string) protocol.ActivityEventPayload {
+		t.Helper()
+		event := toProviderEvent(claude.StreamEvent{Type: eventType, Name: "Read", Result: "file contents here"})
+		var currentResult string
+		bridge.processEvent(&event, &currentResult)
+		msgs := pub.getMessages()
+		var payload protocol.ActivityEventPayload
+		if err := json.Unmarshal(msgs[len(msgs)-1].Msg.Payload, &payload); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		return payload
+	}
+
+	throttle(protocol.ThrottleReduced)
+	if p := publish("tool_result"); !p.Throttled || p.Payload != nil || p.Action != "tool result" {
+		t.Errorf("throttled tool_result: got %+v", p)
+	}
+	if p := publish("tool_use"); p.Throttled || p.Payload == nil {
+		t.Errorf("throttled tool_use should keep its payload: got %+v", p)
+	}
+
+	throttle(protocol.ThrottleOff)
+	if p := publish("tool_result"); p.Throttled || p.Payload == nil {
+		t.Errorf("tool_result after throttle off: got %+v", p)
+	}
+}
+
+func TestProcessEvent_TelemetryLevels(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "telemetryteam", Role: "leader", TelemetryLevel: protocol.TelemetryMinimal},
+		client: pub,
+	}
+	// publish processes one event of each type and returns the activity
+	// events it published, by event type.
+	publish := func() map[string]protocol.ActivityEventPayload {
+		t.Helper()
+		before := len(pub.getMessages())
+		for _, eventType := range []string{"tool_use", "tool_result", "reasoning", "assistant", "error", "result"} {
+			event := toProviderEvent(claude.StreamEvent{Type: eventType, Name: "Read", Result: "output"})
+			var currentResult string
+			bridge.processEvent(&event, &currentResult)
+		}
+		events := make(map[string]protocol.ActivityEventPayload)
+		for _, m := range pub.getMessages()[before:] {
+			if m.Msg.Type != protocol.TypeActivityEvent {
+				continue
+			}
+			var payload protocol.ActivityEventPayload
+			if err := json.Unmarshal(m.Msg.Payload, &payload); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			events[payload.EventType] = payload
+		}
+		return events
+	}
+	setLevel := func(level string) {
+		cmd, err := protocol.NewMessage("api", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{
+			Command: protocol.CommandSetTelemetryLevel,
+			Args:    map[string]string{"level": level},
+		})
+		if err != nil {
+			t.Fatalf("NewMessage: %v", err)
+		}
+		bridge.handleSystemCommand(cmd)
+	}
+
+	events := publish()
+	if len(events) != 2 || events["tool_use"].Payload != nil || events["error"].Action != "error" {
+		t.Errorf("minimal: got %+v, want tool_use and error without payload", events)
+	}
+
+	setLevel(protocol.TelemetryNormal)
+	events = publish()
+	if len(events) != 5 || events["tool_result"].Payload == nil {
+		t.Errorf("normal: got %d events, want every type but result", len(events))
+	}
+	if _, ok := events["result"]; ok {
+		t.Error("normal: result should not be published as activity")
+	}
+
+	setLevel(protocol.TelemetryVerbose)
+	if events = publish(); len(events) != 6 {
+		t.Errorf("verbose: got %d events, want 6", len(events))
+	}
+
+	setLevel("loud")
+	if got := bridge.telemetry(); got != protocol.TelemetryVerbose {
+		t.Errorf("unknown level should be ignored: got %q", got)
+	}
+}
+
+func TestProcessEvent_UnknownEventPassedOn(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "unknownteam", Role: "leader"},
+		client: pub,
+	}
+	line := `{"type":"result","is_error":true,"error":{"type":"overloaded_error"}}`
+	ce, err := claude.ParseStreamEvent([]byte(line))
+	if err != nil {
+		t.Fatalf("ParseStreamEvent: %v", err)
+	}
+	event := toProviderEvent(*ce)
+	var currentResult string
+	bridge.processEvent(&event, &currentResult)
+
+	// An undecodable result is not taken for the run's response.
+	msgs := pub.getMessages()
+	if len(msgs) != 1 || msgs[0].Msg.Type != protocol.TypeActivityEvent {
+		t.Fatalf("messages: got %d, want one activity event", len(msgs))
+	}
+	var payload protocol.ActivityEventPayload
+	if err := json.Unmarshal(msgs[0].Msg.Payload, &payload); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !payload.UnknownEvent || payload.EventType != "result" || string(payload.Payload) != line || payload.Action != "unknown event: result" {
+		t.Errorf("payload: got %+v", payload)
+	}
+
+	bridge.setTelemetryLevel(protocol.TelemetryMinimal, "api")
+	bridge.processEvent(&event, &currentResult)
+	if n := len(pub.getMessages()); n != 1 {
+		t.Errorf("minimal: got %d messages, want no new one", n)
+	}
+}
+
+func TestSetThrottle_Expires(t *testing.T) {
+	bridge := &Bridge{}
+	bridge.setThrottle(protocol.ThrottleReduced, "", "relay")
+	if !bridge.isThrottled() {
+		t.Fatal("expected throttle with the default TTL")
+	}
+	bridge.mu.Lock()
+	bridge.throttledUntil = time.Now().Add(-time.Second)
+	bridge.mu.Unlock()
+	if bridge.isThrottled() {
+		t.Error("throttle should end after its TTL")
+	}
+	bridge.setThrottle("bogus", "", "relay")
+	if bridge.isThrottled() {
+		t.Error("unknown level should be ignored")
+	}
+}
+
 func TestProcessEvent_ErrorPublishesActivityEvent(t *testing.T) {
 	pub := &fakePublisher{}
 	bridge := &Bridge{
@@ -674,14 +857,36 @@ func TestProcessEvent_ToolUseDeniedByGate(t *testing.T) {
 	bridge.processEvent(&event, &currentResult)
 
 	msgs := pub.getMessages()
-	// tool_use denied: should publish 1 activity event but NO leader response.
-	// The activity event is published BEFORE the gate check.
-	if len(msgs) != 1 {
-		t.Fatalf("expected 1 message (activity event only), got %d", len(msgs))
+	// tool_use denied: should publish the tool call's activity event and the
+	// denial, but NO leader response.
+	// The tool call's activity event is published BEFORE the gate check.
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages (activity events only), got %d", len(msgs))
 	}
 	if msgs[0].Msg.Type != protocol.TypeActivityEvent {
 		t.Errorf("Type: got %q, want %q", msgs[0].Msg.Type, protocol.TypeActivityEvent)
 	}
+	assertPermissionDenied(t, msgs[1].Msg, "Bash")
+}
+
+// assertPermissionDenied checks that msg reports a denied call to tool.
+func assertPermissionDenied(t *testing.T, msg *protocol.Message, tool string) {
+	t.Helper()
+	if msg.Type != protocol.TypeActivityEvent {
+		t.Fatalf("Type: got %q, want %q", msg.Type, protocol.TypeActivityEvent)
+	}
+	var activity protocol.ActivityEventPayload
+	if err := json.Unmarshal(msg.Payload, &activity); err != nil {
+		t.Fatalf("unmarshal activity event: %v", err)
+	}
+	if activity.EventType != protocol.ActivityPermissionDenied || activity.ToolName != tool {
+		t.Errorf("activity event: got %q for %q, want %q for %q",
+			activity.EventType, activity.ToolName, protocol.ActivityPermissionDenied, tool)
+	}
+	var denied protocol.PermissionDeniedPayload
+	if err := json.Unmarshal(activity.Payload, &denied); err != nil || denied.Reason == "" {
+		t.Errorf("denial payload: got %+v, %v; want a reason", denied, err)
+	}
 }
 
 func TestProcessEvent_ToolUseAllowedByGate(t *testing.T) {
@@ -746,20 +951,22 @@ func TestProcessEvent_ToolUseDeniedByDeniedCommand(t *testing.T) {
 	bridge.processEvent(&event, &currentResult)
 
 	msgs := pub.getMessages()
-	// Denied command: activity event is published before gate check.
-	if len(msgs) != 1 {
-		t.Fatalf("expected 1 message (activity event only), got %d", len(msgs))
+	// Denied command: activity event is published before gate check, then
+	// the denial.
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages (activity events only), got %d", len(msgs))
 	}
 	if msgs[0].Msg.Type != protocol.TypeActivityEvent {
 		t.Errorf("Type: got %q, want %q", msgs[0].Msg.Type, protocol.TypeActivityEvent)
 	}
+	assertPermissionDenied(t, msgs[1].Msg, "Bash")
 }
 
 func TestProcessEvent_FilesystemScopeEnforced(t *testing.T) {
 	pub := &fakePublisher{}
 	gate := permissions.NewGate(permissions.PermissionConfig{
 		AllowedTools:    []string{"Read", "Write"},
-		FilesystemScope: "/workspace",
+		FilesystemScope: permissions.FilesystemScope{{Path: "/workspace", Mode: permissions.ModeReadWrite}},
 	})
 	mgr := provider.NewClaudeManager(claude.NewManager(claude.ProcessConfig{}))
 	bridge := &Bridge{
@@ -784,20 +991,21 @@ func TestProcessEvent_FilesystemScopeEnforced(t *testing.T) {
 	bridge.processEvent(&event, &currentResult)
 
 	msgs := pub.getMessages()
-	// Activity event published before gate check, but no further action.
-	if len(msgs) != 1 {
-		t.Fatalf("expected 1 message (activity event only), got %d", len(msgs))
+	// Activity event published before gate check, then the denial.
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages (activity events only), got %d", len(msgs))
 	}
 	if msgs[0].Msg.Type != protocol.TypeActivityEvent {
 		t.Errorf("Type: got %q, want %q", msgs[0].Msg.Type, protocol.TypeActivityEvent)
 	}
+	assertPermissionDenied(t, msgs[1].Msg, "Read")
 }
 
 func TestProcessEvent_FilesystemScopeAllowed(t *testing.T) {
 	pub := &fakePublisher{}
 	gate := permissions.NewGate(permissions.PermissionConfig{
 		AllowedTools:    []string{"Read"},
-		FilesystemScope: "/workspace",
+		FilesystemScope: permissions.FilesystemScope{{Path: "/workspace", Mode: permissions.ModeReadWrite}},
 	})
 	bridge := &Bridge{
 		config: BridgeConfig{
@@ -1639,3 +1847,397 @@ func TestMapMcpRuntimeStatus(t *testing.T) {
 		}
 	}
 }
+
+// --- handleDelivery: durable inbox dedup ---
+
+func newInboxTestBridge(pub *fakePublisher) *Bridge {
+	return &Bridge{
+		config: BridgeConfig{
+			AgentName: "leader",
+			TeamName:  "inboxteam",
+			Role:      "leader",
+		},
+		client:   pub,
+		userMsgs: make(chan pendingMessage, 16),
+	}
+}
+
+func userMessage(t *testing.T, payload protocol.UserMessagePayload) *protocol.Message {
+	t.Helper()
+	msg, err := protocol.NewMessage("user", "leader", protocol.TypeUserMessage, payload)
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	return msg
+}
+
+func TestHandleDelivery_SkipsRedeliveredStreamSequence(t *testing.T) {
+	bridge := newInboxTestBridge(&fakePublisher{})
+
+	msg := userMessage(t, protocol.UserMessagePayload{Content: "first"})
+	bridge.handleDelivery(msg, &Delivery{StreamSeq: 5})
+	bridge.handleDelivery(msg, &Delivery{StreamSeq: 5})
+
+	if got := len(bridge.userMsgs); got != 1 {
+		t.Fatalf("queued messages: got %d, want 1", got)
+	}
+
+	// A later message in the stream is still queued.
+	bridge.handleDelivery(userMessage(t, protocol.UserMessagePayload{Content: "second"}), &Delivery{StreamSeq: 6})
+	if got := len(bridge.userMsgs); got != 2 {
+		t.Fatalf("queued messages: got %d, want 2", got)
+	}
+	first := <-bridge.userMsgs
+	second := <-bridge.userMsgs
+	if first.content != "first" || second.content != "second" {
+		t.Errorf("order: got %q, %q", first.content, second.content)
+	}
+	if first.delivery == nil || first.delivery.StreamSeq != 5 {
+		t.Error("expected the delivery to travel with the queued message")
+	}
+}
+
+func TestHandleDelivery_SkipsDuplicateConversationSequence(t *testing.T) {
+	bridge := newInboxTestBridge(&fakePublisher{})
+
+	// The same chat message published twice lands at two stream sequences.
+	payload := protocol.UserMessagePayload{Content: "hello", ConversationID: "conv-1", Sequence: 1}
+	bridge.handleDelivery(userMessage(t, payload), &Delivery{StreamSeq: 1})
+	bridge.handleDelivery(userMessage(t, payload), &Delivery{StreamSeq: 2})
+
+	if got := len(bridge.userMsgs); got != 1 {
+		t.Fatalf("queued messages: got %d, want 1", got)
+	}
+
+	// A new conversation starts its sequence again from 1.
+	bridge.handleDelivery(userMessage(t, protocol.UserMessagePayload{
+		Content: "new session", ConversationID: "conv-2", Sequence: 1,
+	}), &Delivery{StreamSeq: 3})
+	if got := len(bridge.userMsgs); got != 2 {
+		t.Fatalf("queued messages: got %d, want 2", got)
+	}
+}
+
+func TestHandleDelivery_IgnoresNonUserMessagesForQueue(t *testing.T) {
+	bridge := newInboxTestBridge(&fakePublisher{})
+
+	resp, err := protocol.NewMessage("leader", "user", protocol.TypeLeaderResponse,
+		protocol.LeaderResponsePayload{Status: "completed", Result: "done"})
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	bridge.handleDelivery(resp, &Delivery{StreamSeq: 1})
+
+	if got := len(bridge.userMsgs); got != 0 {
+		t.Errorf("queued messages: got %d, want 0", got)
+	}
+
+	// Core NATS fallback passes a nil delivery.
+	bridge.handleDelivery(userMessage(t, protocol.UserMessagePayload{Content: "plain"}), nil)
+	if got := len(bridge.userMsgs); got != 1 {
+		t.Errorf("queued messages: got %d, want 1", got)
+	}
+}
+
+// --- handleIncoming: config updates ---
+
+func TestBridge_ConfigUpdate(t *testing.T) {
+	bridge := newInboxTestBridge(&fakePublisher{})
+	var got []protocol.ConfigFile
+	bridge.config.OnConfigUpdate = func(files []protocol.ConfigFile) error {
+		got = files
+		return nil
+	}
+
+	files := []protocol.ConfigFile{{Path: ".claude/agents/backend.md", Content: "# Backend"}}
+	msg, err := protocol.NewMessage("api", "leader", protocol.TypeConfigUpdate, protocol.ConfigUpdatePayload{Files: files})
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	bridge.handleDelivery(msg, &Delivery{StreamSeq: 1})
+
+	if len(got) != 1 || got[0] != files[0] {
+		t.Errorf("OnConfigUpdate got %+v, want %+v", got, files)
+	}
+	if len(bridge.userMsgs) != 0 {
+		t.Errorf("config update queued as a user message")
+	}
+}
+
+func TestProcessEvent_ResultPublishesUsage(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "evtteam", Role: "leader"},
+		client: pub,
+	}
+
+	msgContent, _ := json.Marshal(map[string]string{"type": "text", "text": "done"})
+	event := provider.StreamEvent{
+		Type:    "result",
+		Message: string(msgContent),
+		CostUSD: 0.42,
+		Usage:   &provider.TokenUsage{InputTokens: 1200, OutputTokens: 300, CacheReadTokens: 50},
+	}
+
+	var currentResult string
+	bridge.processEvent(&event, &currentResult)
+
+	msgs := pub.getMessages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected usage and leader_response, got %d messages", len(msgs))
+	}
+	if msgs[0].Msg.Type != protocol.TypeUsage || msgs[0].Subject != "team.evtteam.activity" {
+		t.Fatalf("first message: got %q on %q", msgs[0].Msg.Type, msgs[0].Subject)
+	}
+	var usage protocol.UsagePayload
+	if err := json.Unmarshal(msgs[0].Msg.Payload, &usage); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if usage.AgentName != "leader" || usage.CostUSD != 0.42 || usage.InputTokens != 1200 ||
+		usage.OutputTokens != 300 || usage.CacheReadTokens != 50 {
+		t.Errorf("usage: got %+v", usage)
+	}
+	if msgs[1].Msg.Type != protocol.TypeLeaderResponse {
+		t.Errorf("second message: got %q, want leader_response", msgs[1].Msg.Type)
+	}
+}
+
+func TestProcessEvent_RateLimitEventPublishesQuota(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "evtteam", Role: "leader"},
+		client: pub,
+	}
+
+	var currentResult string
+	warning := `{"type":"rate_limit_event","rate_limit_info":{"status":"allowed_warning","rateLimitType":"five_hour","utilization":0.9,"resetsAt":1760000000}}`
+	for _, raw := range []string{warning, warning} {
+		event := provider.StreamEvent{Type: "rate_limit_event", Unknown: true, Raw: raw}
+		bridge.processEvent(&event, &currentResult)
+	}
+
+	var quotas []protocol.QuotaPayload
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type != protocol.TypeQuota {
+			continue
+		}
+		var q protocol.QuotaPayload
+		if err := json.Unmarshal(m.Msg.Payload, &q); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		quotas = append(quotas, q)
+	}
+	if len(quotas) != 1 {
+		t.Fatalf("expected 1 quota message for a repeated state, got %+v", quotas)
+	}
+	q := quotas[0]
+	if q.AgentName != "leader" || q.Status != protocol.QuotaWarning || q.LimitType != "five_hour" ||
+		q.Utilization == nil || *q.Utilization != 0.9 || q.ResetsAt == nil || q.ResetsAt.Unix() != 1760000000 {
+		t.Errorf("quota: got %+v", q)
+	}
+}
+
+func TestProcessEvent_ErrorResultCarriesErrorCode(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config: BridgeConfig{AgentName: "leader", TeamName: "evtteam", Role: "leader"},
+		client: pub,
+	}
+
+	event := provider.StreamEvent{Type: "result", IsError: true, ErrorCode: "rate_limit_error", Result: "429"}
+	var currentResult string
+	bridge.processEvent(&event, &currentResult)
+
+	msgs := pub.getMessages()
+	if len(msgs) != 1 || msgs[0].Msg.Type != protocol.TypeLeaderResponse {
+		t.Fatalf("expected 1 leader_response, got %+v", msgs)
+	}
+	var payload protocol.LeaderResponsePayload
+	if err := json.Unmarshal(msgs[0].Msg.Payload, &payload); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if payload.Status != "failed" || payload.ErrorCode != "rate_limit_error" || !strings.Contains(payload.Error, "rate limit") {
+		t.Errorf("payload: got %+v", payload)
+	}
+}
+
+// lastRunQueue returns the latest run_queue payload published.
+func lastRunQueue(t *testing.T, pub *fakePublisher) protocol.RunQueuePayload {
+	t.Helper()
+	var queue protocol.RunQueuePayload
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type == protocol.TypeRunQueue {
+			queue = protocol.RunQueuePayload{}
+			if err := json.Unmarshal(m.Msg.Payload, &queue); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+		}
+	}
+	return queue
+}
+
+func TestBridge_ParksMessagesOverMaxConcurrentRuns(t *testing.T) {
+	pub := &fakePublisher{}
+	release := make(chan struct{})
+	started := make(chan string, 4)
+	mgr := &fakeManager{
+		events: make(chan provider.StreamEvent),
+		sendInput: func(input string) error {
+			started <- input
+			<-release
+			return nil
+		},
+	}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "runqueueteam", Role: "leader", MaxConcurrentRuns: 2},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 16),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	msgs := make(map[string]*protocol.Message)
+	for _, content := range []string{"a", "b", "c", "d"} {
+		msgs[content] = userMessage(t, protocol.UserMessagePayload{Content: content})
+	}
+	bridge.handleDelivery(msgs["a"], nil)
+	if got := <-started; got != "a" {
+		t.Fatalf("first run: got %q, want a", got)
+	}
+	for _, content := range []string{"b", "c", "d"} {
+		bridge.handleDelivery(msgs[content], nil)
+	}
+
+	// "a" runs, "b" waits behind it, and "c" and "d" are parked.
+	if got := len(bridge.userMsgs); got != 1 {
+		t.Errorf("queued messages: got %d, want 1", got)
+	}
+	queue := lastRunQueue(t, pub)
+	want := []string{msgs["b"].MessageID, msgs["c"].MessageID, msgs["d"].MessageID}
+	if queue.Running != msgs["a"].MessageID || queue.Parked != 2 || queue.MaxConcurrentRuns != 2 ||
+		strings.Join(queue.Waiting, ",") != strings.Join(want, ",") {
+		t.Errorf("run queue: got %+v", queue)
+	}
+
+	// Parked messages run in order as runs finish.
+	for _, content := range []string{"b", "c", "d"} {
+		release <- struct{}{}
+		if got := <-started; got != content {
+			t.Fatalf("next run: got %q, want %q", got, content)
+		}
+	}
+	release <- struct{}{}
+	waitFor(t, func() bool {
+		q := lastRunQueue(t, pub)
+		return q.Running == "" && len(q.Waiting) == 0
+	})
+}
+
+func TestBridge_InboxMaxAckPending(t *testing.T) {
+	for _, tc := range []struct{ runs, want int }{
+		{0, 1},
+		{1, 1},
+		{2, 1},
+		{5, 4},
+	} {
+		b := &Bridge{config: BridgeConfig{MaxConcurrentRuns: tc.runs}}
+		if got := b.inboxMaxAckPending(); got != tc.want {
+			t.Errorf("max_concurrent_runs %d: got %d, want %d", tc.runs, got, tc.want)
+		}
+	}
+}
+
+func TestBridge_ParksWithoutDropping(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "parkteam", Role: "leader", MaxConcurrentRuns: 1},
+		client:   pub,
+		manager:  &fakeManager{events: make(chan provider.StreamEvent)},
+		userMsgs: make(chan pendingMessage, 16),
+	}
+	bridge.running = true
+
+	// One run is in flight, so every message is parked. Without JetStream
+	// nothing would deliver them again, so none is dropped.
+	for i := 0; i < 3*protocol.MaxConcurrentRunsLimit; i++ {
+		bridge.handleDelivery(userMessage(t, protocol.UserMessagePayload{Content: "parked"}), nil)
+	}
+	last := userMessage(t, protocol.UserMessagePayload{Content: "last", ConversationID: "conv", Sequence: 7})
+	bridge.handleDelivery(last, nil)
+
+	if got := len(bridge.parked); got != 3*protocol.MaxConcurrentRunsLimit+1 {
+		t.Errorf("parked: got %d, want %d", got, 3*protocol.MaxConcurrentRunsLimit+1)
+	}
+	if bridge.parked[len(bridge.parked)-1].id != last.MessageID {
+		t.Error("last message was not parked last")
+	}
+	if seq := bridge.conversationSeq["conv"]; seq != 7 {
+		t.Errorf("conversation sequence: got %d, want 7", seq)
+	}
+}
+
+func TestBridge_NoRunLimit(t *testing.T) {
+	pub := &fakePublisher{}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "nolimit", Role: "leader"},
+		client:   pub,
+		manager:  &fakeManager{events: make(chan provider.StreamEvent)},
+		userMsgs: make(chan pendingMessage, 16),
+	}
+	bridge.running = true
+
+	for i := 0; i < 20; i++ {
+		bridge.handleDelivery(userMessage(t, protocol.UserMessagePayload{Content: "queued"}), nil)
+	}
+	// Messages only wait for room in the queue.
+	if got := len(bridge.userMsgs); got != 16 {
+		t.Errorf("queued messages: got %d, want 16", got)
+	}
+	if got := len(bridge.parked); got != 4 {
+		t.Errorf("parked: got %d, want 4", got)
+	}
+	if queue := lastRunQueue(t, pub); queue.MaxConcurrentRuns != 0 || len(queue.Waiting) != 20 {
+		t.Errorf("run queue: got %+v", queue)
+	}
+}
+
+func TestBridge_OnRunStartBeforeInput(t *testing.T) {
+	pub := &fakePublisher{}
+	var calls []string
+	started := make(chan struct{}, 1)
+	mgr := &fakeManager{
+		events: make(chan provider.StreamEvent),
+		sendInput: func(input string) error {
+			calls = append(calls, "input:"+input)
+			started <- struct{}{}
+			return nil
+		},
+	}
+	bridge := &Bridge{
+		config: BridgeConfig{
+			AgentName:  "leader",
+			TeamName:   "transcriptteam",
+			Role:       "leader",
+			OnRunStart: func(runID string) { calls = append(calls, "run:"+runID) },
+		},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 16),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	msg := userMessage(t, protocol.UserMessagePayload{Content: "hello"})
+	bridge.handleDelivery(msg, nil)
+	<-started
+	if want := "run:" + msg.MessageID + ",input:hello"; strings.Join(calls, ",") != want {
+		t.Errorf("calls: got %q, want %q", strings.Join(calls, ","), want)
+	}
+}
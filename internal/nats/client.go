@@ -4,6 +4,7 @@ package nats
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -90,29 +91,49 @@ func Connect(config ClientConfig) (*Client, error) {
 	return client, nil
 }
 
-// EnsureStream creates or updates a JetStream stream for team message persistence.
-func (c *Client) EnsureStream(ctx context.Context, teamName string) error {
+// EnsureStream creates or updates a JetStream stream for team message
+// persistence, within limits.
+func (c *Client) EnsureStream(ctx context.Context, teamName string, limits StreamLimits) error {
 	if c.js == nil {
 		return fmt.Errorf("jetstream not enabled")
 	}
 
 	streamName := "TEAM_" + teamName
 	subjects := []string{fmt.Sprintf("team.%s.>", teamName)}
+	_, err := c.js.CreateOrUpdateStream(ctx, streamConfig(streamName, subjects, limits))
+	if err != nil {
+		return fmt.Errorf("creating stream %s: %w", streamName, err)
+	}
+
+	slog.Info("jetstream stream ensured", "stream", streamName, "subjects", subjects,
+		"max_age", limits.MaxAge, "max_bytes", limits.MaxBytes, "max_msgs", limits.MaxMsgs)
+	return nil
+}
 
-	_, err := c.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
-		Name:      streamName,
+// streamConfig returns the configuration of a team stream. JetStream reads
+// -1 as no limit.
+func streamConfig(name string, subjects []string, limits StreamLimits) jetstream.StreamConfig {
+	cfg := jetstream.StreamConfig{
+		Name:      name,
 		Subjects:  subjects,
 		Retention: jetstream.LimitsPolicy,
-		MaxAge:    24 * time.Hour,
+		MaxAge:    DefaultStreamMaxAge,
+		MaxBytes:  -1,
+		MaxMsgs:   -1,
+		Discard:   jetstream.DiscardOld,
 		Storage:   jetstream.FileStorage,
 		Replicas:  1,
-	})
-	if err != nil {
-		return fmt.Errorf("creating stream %s: %w", streamName, err)
 	}
-
-	slog.Info("jetstream stream ensured", "stream", streamName, "subjects", subjects)
-	return nil
+	if limits.MaxAge > 0 {
+		cfg.MaxAge = limits.MaxAge
+	}
+	if limits.MaxBytes > 0 {
+		cfg.MaxBytes = limits.MaxBytes
+	}
+	if limits.MaxMsgs > 0 {
+		cfg.MaxMsgs = limits.MaxMsgs
+	}
+	return cfg
 }
 
 // Publish sends a protocol message to the specified NATS subject.
@@ -124,6 +145,25 @@ func (c *Client) Publish(subject string, msg *protocol.Message) error {
 	return c.conn.Publish(subject, data)
 }
 
+// Request sends a protocol message to subject and waits for a single reply
+// until ctx is done. It uses core NATS request/reply, so subject must not be
+// captured by a JetStream stream.
+func (c *Client) Request(ctx context.Context, subject string, msg *protocol.Message) (*protocol.Message, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling message: %w", err)
+	}
+	reply, err := c.conn.RequestWithContext(ctx, subject, data)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", subject, err)
+	}
+	var resp protocol.Message
+	if err := json.Unmarshal(reply.Data, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshaling reply: %w", err)
+	}
+	return &resp, nil
+}
+
 // Subscribe registers a handler for messages on the given subject.
 // When JetStream is enabled, it uses an ordered consumer with DeliverAll policy
 // so that messages published before the subscription are replayed. Falls back to
@@ -135,6 +175,14 @@ func (c *Client) Subscribe(subject string, handler func(*protocol.Message)) erro
 	return c.subscribeCoreNATS(subject, handler)
 }
 
+// SubscribeCore registers a handler for messages on subject over core NATS,
+// even when JetStream is enabled: only messages published while subscribed
+// are delivered, with no acknowledgement. It suits subjects outside the team
+// stream, such as protocol.TeamControlChannel.
+func (c *Client) SubscribeCore(subject string, handler func(*protocol.Message)) error {
+	return c.subscribeCoreNATS(subject, handler)
+}
+
 // subscribeCoreNATS registers a plain NATS subscription (no replay).
 func (c *Client) subscribeCoreNATS(subject string, handler func(*protocol.Message)) error {
 	sub, err := c.conn.Subscribe(subject, func(natsMsg *nats.Msg) {
@@ -198,6 +246,134 @@ func (c *Client) subscribeJetStream(subject string, handler func(*protocol.Messa
 	return nil
 }
 
+// inboxAckWait is how long JetStream waits for an inbox message to be acked
+// before redelivering it. Waiting messages are kept alive with InProgress.
+const inboxAckWait = 5 * time.Minute
+
+// Delivery is a message received from a durable consumer. Until Ack is
+// called JetStream keeps the message pending and redelivers it, including to
+// a restarted sidecar. A nil Delivery (core NATS fallback) is a no-op.
+type Delivery struct {
+	// StreamSeq is the message's sequence number in the team stream.
+	StreamSeq uint64
+
+	msg jetstream.Msg
+}
+
+// Ack acknowledges the message so it is not redelivered.
+func (d *Delivery) Ack() {
+	if d == nil || d.msg == nil {
+		return
+	}
+	if err := d.msg.Ack(); err != nil {
+		slog.Warn("failed to ack jetstream message", "stream_seq", d.StreamSeq, "error", err)
+	}
+}
+
+// InProgress resets the redelivery timer for a message that is still waiting
+// to be processed.
+func (d *Delivery) InProgress() {
+	if d == nil || d.msg == nil {
+		return
+	}
+	if err := d.msg.InProgress(); err != nil {
+		slog.Debug("failed to mark jetstream message in progress", "stream_seq", d.StreamSeq, "error", err)
+	}
+}
+
+// NakWithDelay asks for the message to be redelivered after delay, for a
+// message the subscriber will not process.
+func (d *Delivery) NakWithDelay(delay time.Duration) {
+	if d == nil || d.msg == nil {
+		return
+	}
+	if err := d.msg.NakWithDelay(delay); err != nil {
+		slog.Warn("failed to nak jetstream message", "stream_seq", d.StreamSeq, "error", err)
+	}
+}
+
+// SubscribeDurable registers a handler on a durable JetStream consumer with
+// explicit acks and at most maxAckPending (at least one) unacknowledged
+// messages, so messages are handed over strictly in order, the subscriber
+// is never handed more than it takes on, and messages survive subscriber
+// restarts. The first
+// time the consumer is created it delivers messages published since
+// startTime (or only new messages when startTime is zero); afterwards it
+// resumes from the last acknowledged message. Falls back to core NATS when
+// JetStream is not available.
+func (c *Client) SubscribeDurable(subject, durable string, startTime time.Time, maxAckPending int, handler func(*protocol.Message, *Delivery)) error {
+	if c.js == nil {
+		return c.subscribeCoreNATS(subject, func(msg *protocol.Message) {
+			handler(msg, nil)
+		})
+	}
+
+	streamName, err := streamNameFromSubject(subject)
+	if err != nil {
+		return err
+	}
+	if err := protocol.ValidateSubjectToken(durable); err != nil {
+		return fmt.Errorf("invalid durable name: %w", err)
+	}
+
+	ctx := context.Background()
+
+	maxAckPending = max(maxAckPending, 1)
+	cons, err := c.js.Consumer(ctx, streamName, durable)
+	if errors.Is(err, jetstream.ErrConsumerNotFound) {
+		cons, err = c.js.CreateConsumer(ctx, streamName, durableConsumerConfig(subject, durable, startTime, maxAckPending))
+	} else if err == nil && cons.CachedInfo().Config.MaxAckPending != maxAckPending {
+		// The consumer outlives the sidecar; a changed limit applies to it.
+		cfg := cons.CachedInfo().Config
+		cfg.MaxAckPending = maxAckPending
+		cons, err = c.js.UpdateConsumer(ctx, streamName, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("creating durable consumer %s on stream %s: %w", durable, streamName, err)
+	}
+
+	cc, err := cons.Consume(func(msg jetstream.Msg) {
+		var protoMsg protocol.Message
+		if err := json.Unmarshal(msg.Data(), &protoMsg); err != nil {
+			slog.Warn("failed to unmarshal jetstream message", "subject", subject, "error", err)
+			// Unparseable messages would otherwise block the consumer.
+			_ = msg.Term()
+			return
+		}
+		d := &Delivery{msg: msg}
+		if meta, err := msg.Metadata(); err == nil {
+			d.StreamSeq = meta.Sequence.Stream
+		}
+		handler(&protoMsg, d)
+	})
+	if err != nil {
+		return fmt.Errorf("starting jetstream consume for %s: %w", subject, err)
+	}
+
+	c.consumerContexts = append(c.consumerContexts, cc)
+	slog.Debug("subscribed (jetstream durable consumer)", "subject", subject, "stream", streamName, "durable", durable)
+	return nil
+}
+
+// durableConsumerConfig builds the consumer configuration used by
+// SubscribeDurable. At most maxAckPending messages are delivered without
+// being acknowledged; the rest wait in the stream.
+func durableConsumerConfig(subject, durable string, startTime time.Time, maxAckPending int) jetstream.ConsumerConfig {
+	cfg := jetstream.ConsumerConfig{
+		Durable:       durable,
+		FilterSubject: subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       inboxAckWait,
+		MaxAckPending: maxAckPending,
+		DeliverPolicy: jetstream.DeliverNewPolicy,
+	}
+	if !startTime.IsZero() {
+		cfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		cfg.OptStartTime = &startTime
+	}
+	return cfg
+}
+
 // Flush flushes the connection buffer to the server.
 func (c *Client) Flush() error {
 	return c.conn.Flush()
@@ -0,0 +1,46 @@
+package nats
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStreamMaxAge is how long a team stream keeps messages unless
+// StreamLimits.MaxAge is set.
+const DefaultStreamMaxAge = 24 * time.Hour
+
+// StreamLimits bounds what a team's JetStream stream keeps. The oldest
+// messages are dropped first. Zero fields keep the defaults: messages of
+// the last DefaultStreamMaxAge, with no size or count limit.
+type StreamLimits struct {
+	MaxAge   time.Duration
+	MaxBytes int64
+	MaxMsgs  int64
+}
+
+// sizePattern matches the sizes ParseSize accepts.
+var sizePattern = regexp.MustCompile(`^([0-9]+)\s*([KMGT]?)(I?B)?$`)
+
+// ParseSize parses a byte size such as "512MB", "1G", "2GiB" or "1048576".
+// Units are powers of 1024, as in the NATS server configuration.
+func ParseSize(s string) (int64, error) {
+	m := sizePattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q: use bytes or a unit such as 512MB or 2GB", s)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	shift := map[string]uint{"": 0, "K": 10, "M": 20, "G": 30, "T": 40}[m[2]]
+	if m[3] == "IB" && m[2] == "" {
+		return 0, fmt.Errorf("invalid size %q: use bytes or a unit such as 512MB or 2GB", s)
+	}
+	if n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return n << shift, nil
+}
@@ -0,0 +1,42 @@
+package nats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"1048576", 1 << 20},
+		{"512K", 512 << 10},
+		{"512MB", 512 << 20},
+		{"2gib", 2 << 30},
+		{" 1 TB ", 1 << 40},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+	for _, in := range []string{"", "lots", "-1", "1.5GB", "10PB", "5iB", "99999999999T"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("ParseSize(%q) should fail", in)
+		}
+	}
+}
+
+func TestStreamConfig(t *testing.T) {
+	cfg := streamConfig("TEAM_a", []string{"team.a.>"}, StreamLimits{})
+	if cfg.MaxAge != DefaultStreamMaxAge || cfg.MaxBytes != -1 || cfg.MaxMsgs != -1 {
+		t.Errorf("defaults: got max_age %v, max_bytes %d, max_msgs %d", cfg.MaxAge, cfg.MaxBytes, cfg.MaxMsgs)
+	}
+
+	cfg = streamConfig("TEAM_a", []string{"team.a.>"}, StreamLimits{MaxAge: time.Hour, MaxBytes: 1 << 30, MaxMsgs: 1000})
+	if cfg.MaxAge != time.Hour || cfg.MaxBytes != 1<<30 || cfg.MaxMsgs != 1000 {
+		t.Errorf("limits: got max_age %v, max_bytes %d, max_msgs %d", cfg.MaxAge, cfg.MaxBytes, cfg.MaxMsgs)
+	}
+}
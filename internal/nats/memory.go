@@ -0,0 +1,84 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// memoryPrompt asks the agent, as its team stops, for the context worth
+// keeping for the team's next deployment.
+const memoryPrompt = "The team is shutting down. Write a concise summary of what " +
+	"you would need to know to pick up this work after a restart: the project, " +
+	"decisions made and why, work in progress, open questions, and conventions " +
+	"or pitfalls you discovered. Reply with the summary only."
+
+// memoryPreamble introduces the previous deployment's memory, which is put
+// before the first message the agent runs.
+const memoryPreamble = "Memory from this team's previous deployment, for context:\n\n"
+
+// withMemory puts the previous deployment's memory before content if no
+// run has received it yet.
+func (b *Bridge) withMemory(content string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.config.MemoryContext == "" || b.memoryInjected {
+		return content
+	}
+	b.memoryInjected = true
+	return memoryPreamble + b.config.MemoryContext + "\n\n---\n\n" + content
+}
+
+// Summarize asks the agent for a summary of the context to keep for the
+// team's next deployment and returns it. The result is captured instead of
+// published as a leader response. Call it after Drain, when no run is in
+// flight.
+func (b *Bridge) Summarize(ctx context.Context) (string, error) {
+	result := make(chan protocol.LeaderResponsePayload, 1)
+	b.mu.Lock()
+	if b.runDone != nil {
+		b.mu.Unlock()
+		return "", errors.New("a run is in flight")
+	}
+	b.memoryResult = result
+	b.errorPublished = false
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.memoryResult = nil
+		b.mu.Unlock()
+	}()
+
+	// SendInput may return before or after the result event is processed.
+	sent := make(chan error, 1)
+	go func() { sent <- b.sendInput(memoryPrompt, nil) }()
+	for {
+		select {
+		case p := <-result:
+			if p.Status != "completed" {
+				return "", fmt.Errorf("agent did not summarize: %s", p.Error)
+			}
+			return p.Result, nil
+		case err := <-sent:
+			if err != nil {
+				return "", err
+			}
+			sent = nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// takeMemoryResultLocked hands payload to a pending Summarize call,
+// reporting whether there was one. b.mu must be held.
+func (b *Bridge) takeMemoryResultLocked(payload protocol.LeaderResponsePayload) bool {
+	if b.memoryResult == nil {
+		return false
+	}
+	b.memoryResult <- payload
+	b.memoryResult = nil
+	return true
+}
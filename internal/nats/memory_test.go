@@ -0,0 +1,83 @@
+package nats
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/claude"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+func TestBridge_Memory(t *testing.T) {
+	pub := &fakePublisher{}
+	var mu sync.Mutex
+	var inputs []string
+	var bridge *Bridge
+	mgr := &fakeManager{
+		events: make(chan provider.StreamEvent),
+		sendInput: func(input string) error {
+			mu.Lock()
+			inputs = append(inputs, input)
+			mu.Unlock()
+			// Like the Claude manager, return once the run's result is in.
+			event := toProviderEvent(claude.StreamEvent{Type: "result", Result: "done: " + input[len(input)-5:]})
+			var currentResult string
+			bridge.processEvent(&event, &currentResult)
+			return nil
+		},
+	}
+	bridge = &Bridge{
+		config: BridgeConfig{
+			AgentName:     "leader",
+			TeamName:      "memoryteam",
+			Role:          "leader",
+			MemoryContext: "The API is written in Go.",
+		},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 4),
+	}
+	inputCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(inputs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	// Only the first run receives the previous deployment's memory.
+	bridge.userMsgs <- pendingMessage{id: "run-1", content: "hello"}
+	bridge.userMsgs <- pendingMessage{id: "run-2", content: "again"}
+	waitFor(t, func() bool { return len(leaderResponses(t, pub)) == 2 })
+	mu.Lock()
+	if !strings.HasPrefix(inputs[0], memoryPreamble+"The API is written in Go.") || !strings.HasSuffix(inputs[0], "hello") {
+		t.Errorf("first input: got %q", inputs[0])
+	}
+	if inputs[1] != "again" {
+		t.Errorf("second input: got %q", inputs[1])
+	}
+	mu.Unlock()
+
+	// The summary is returned to the caller, not published to the user.
+	if !bridge.Drain(ctx) {
+		t.Fatal("drain failed")
+	}
+	sumCtx, sumCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer sumCancel()
+	summary, err := bridge.Summarize(sumCtx)
+	if err != nil {
+		t.Fatalf("Summarize: %v", err)
+	}
+	if inputCount() != 3 || summary != "done: "+memoryPrompt[len(memoryPrompt)-5:] {
+		t.Errorf("summary: got %q after %d inputs", summary, inputCount())
+	}
+	if n := len(leaderResponses(t, pub)); n != 2 {
+		t.Errorf("leader responses: got %d, want 2", n)
+	}
+}
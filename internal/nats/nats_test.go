@@ -226,3 +226,39 @@ func TestSubscribeJetStream_InvalidSubject(t *testing.T) {
 		t.Fatal("expected error for invalid subject")
 	}
 }
+
+// --- durable inbox consumer configuration ---
+
+func TestDurableConsumerConfig(t *testing.T) {
+	cfg := durableConsumerConfig("team.myteam.leader", "inbox-leader", time.Time{}, 3)
+	if cfg.Durable != "inbox-leader" {
+		t.Errorf("Durable: got %q", cfg.Durable)
+	}
+	if cfg.FilterSubject != "team.myteam.leader" {
+		t.Errorf("FilterSubject: got %q", cfg.FilterSubject)
+	}
+	if cfg.AckPolicy != jetstream.AckExplicitPolicy {
+		t.Errorf("AckPolicy: got %v, want explicit", cfg.AckPolicy)
+	}
+	if cfg.MaxAckPending != 3 {
+		t.Errorf("MaxAckPending: got %d, want 3", cfg.MaxAckPending)
+	}
+	if cfg.DeliverPolicy != jetstream.DeliverNewPolicy {
+		t.Errorf("DeliverPolicy: got %v, want new", cfg.DeliverPolicy)
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	cfg = durableConsumerConfig("team.myteam.leader", "inbox-leader", start, 1)
+	if cfg.DeliverPolicy != jetstream.DeliverByStartTimePolicy {
+		t.Errorf("DeliverPolicy: got %v, want by start time", cfg.DeliverPolicy)
+	}
+	if cfg.OptStartTime == nil || !cfg.OptStartTime.Equal(start) {
+		t.Errorf("OptStartTime: got %v, want %v", cfg.OptStartTime, start)
+	}
+}
+
+func TestNilDeliveryIsNoop(t *testing.T) {
+	var d *Delivery
+	d.Ack()
+	d.InProgress()
+}
@@ -0,0 +1,38 @@
+package nats
+
+// planPrompt is put before chat messages in plan approval mode. The run is
+// read-only: claude runs in its plan permission mode, and the gate denies
+// write tools of agents that cannot. The prompt keeps the agent from trying.
+const planPrompt = "Plan approval is required before you make any changes. " +
+	"Investigate with read-only tools only, then reply with a step-by-step plan " +
+	"for the request below and stop. You will be told when the plan is approved.\n\n"
+
+// needsPlan reports whether pm starts a run that must stop at a plan: a chat
+// message, in plan approval mode, that does not itself approve a plan.
+// Scheduled and webhook runs are unattended, so they run straight through.
+func (b *Bridge) needsPlan(pm pendingMessage) bool {
+	if !b.config.PlanApproval || pm.approvedPlanRunID != "" {
+		return false
+	}
+	return pm.source == "" || pm.source == "chat"
+}
+
+// isPlanning reports whether the run being answered is planning. Runs are
+// answered in order, so it is the head of planRunIDs.
+func (b *Bridge) isPlanning() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.planRunIDs) > 0 && b.planRunIDs[0] != ""
+}
+
+// takePendingPlan removes the plan of runID from the plans awaiting
+// approval, reporting whether there was one. Each plan is approved once.
+func (b *Bridge) takePendingPlan(runID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.pendingPlans[runID] {
+		return false
+	}
+	delete(b.pendingPlans, runID)
+	return true
+}
@@ -0,0 +1,153 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/claude"
+	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+// leaderResponses returns the payloads of the leader_responses published so far.
+func leaderResponses(t *testing.T, pub *fakePublisher) []protocol.LeaderResponsePayload {
+	t.Helper()
+	var out []protocol.LeaderResponsePayload
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type != protocol.TypeLeaderResponse {
+			continue
+		}
+		p, err := protocol.ParsePayload[protocol.LeaderResponsePayload](m.Msg)
+		if err != nil {
+			t.Fatalf("parse leader response: %v", err)
+		}
+		out = append(out, *p)
+	}
+	return out
+}
+
+func TestBridge_PlanApproval(t *testing.T) {
+	pub := &fakePublisher{}
+	var mu sync.Mutex
+	var inputs []string
+	mgr := &overridableManager{fakeManager: fakeManager{
+		events: make(chan provider.StreamEvent),
+		sendInput: func(input string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			inputs = append(inputs, input)
+			return nil
+		},
+	}}
+	lastInput := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return inputs[len(inputs)-1]
+	}
+	inputCount := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(inputs)
+	}
+	bridge := &Bridge{
+		config: BridgeConfig{
+			AgentName:    "leader",
+			TeamName:     "planteam",
+			Role:         "leader",
+			PlanApproval: true,
+			Gate:         permissions.NewGate(permissions.PermissionConfig{AllowedTools: []string{"Read", "Edit"}}),
+		},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 4),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	edit := toProviderEvent(claude.StreamEvent{
+		Type:  "tool_use",
+		Name:  "Edit",
+		Input: json.RawMessage(`{"file_path":"/workspace/main.go"}`),
+	})
+	result := func(text string) {
+		t.Helper()
+		event := toProviderEvent(claude.StreamEvent{Type: "result", Result: text})
+		var currentResult string
+		bridge.processEvent(&event, &currentResult)
+	}
+
+	// A chat message runs as a plan: it is prompted to plan, may not edit,
+	// and its result is published as a plan awaiting approval.
+	bridge.userMsgs <- pendingMessage{id: "run-1", content: "fix the bug"}
+	waitFor(t, func() bool { return inputCount() == 1 })
+	if got := lastInput(); !strings.HasPrefix(got, planPrompt) || !strings.HasSuffix(got, "fix the bug") {
+		t.Errorf("planning input: got %q", got)
+	}
+	var currentResult string
+	bridge.processEvent(&edit, &currentResult)
+	if got := lastInput(); !strings.Contains(got, "Permission denied") || !strings.Contains(got, "plan is approved") {
+		t.Errorf("edit while planning: expected a denial, got %q", got)
+	}
+	result("1. Fix the bug")
+
+	responses := leaderResponses(t, pub)
+	if len(responses) != 1 || responses[0].Status != protocol.StatusAwaitingApproval ||
+		responses[0].Plan == nil || responses[0].Plan.RunID != "run-1" || responses[0].Plan.Text != "1. Fix the bug" {
+		t.Fatalf("plan response: got %+v", responses)
+	}
+
+	// The approval runs the original request with full permissions.
+	bridge.userMsgs <- pendingMessage{id: "run-2", content: "approved", source: "chat", approvedPlanRunID: "run-1"}
+	waitFor(t, func() bool { return inputCount() == 3 })
+	if got := lastInput(); got != "approved" {
+		t.Errorf("approval input: got %q", got)
+	}
+	bridge.processEvent(&edit, &currentResult)
+	if inputCount() != 3 {
+		t.Errorf("edit after approval should be allowed, got input %q", lastInput())
+	}
+	result("Fixed")
+
+	// A plan is approved once.
+	bridge.userMsgs <- pendingMessage{id: "run-3", content: "approved", source: "chat", approvedPlanRunID: "run-1"}
+	waitFor(t, func() bool { return len(leaderResponses(t, pub)) == 3 })
+	if inputCount() != 3 {
+		t.Errorf("second approval reached the agent: %q", lastInput())
+	}
+
+	// Scheduled runs are not held for approval.
+	bridge.userMsgs <- pendingMessage{id: "run-4", content: "nightly report", source: "scheduler", scheduledRunID: "sched-1"}
+	waitFor(t, func() bool { return inputCount() == 4 })
+	if got := lastInput(); got != "nightly report" {
+		t.Errorf("scheduled input: got %q", got)
+	}
+	result("Report")
+	cancel()
+	bridge.wg.Wait()
+
+	// Only the planning run is read-only for the agent itself.
+	if len(mgr.overrides) != 1 || !mgr.overrides[0].ReadOnly {
+		t.Errorf("overrides: got %+v, want one read-only run", mgr.overrides)
+	}
+
+	responses = leaderResponses(t, pub)
+	if len(responses) != 4 {
+		t.Fatalf("leader responses: got %+v", responses)
+	}
+	if responses[1].Status != "completed" || responses[1].Plan != nil {
+		t.Errorf("approved run response: got %+v", responses[1])
+	}
+	if responses[2].Status != "failed" {
+		t.Errorf("repeated approval response: got %+v", responses[2])
+	}
+	if responses[3].Status != "completed" || responses[3].ScheduledRunID != "sched-1" {
+		t.Errorf("scheduled run response: got %+v", responses[3])
+	}
+}
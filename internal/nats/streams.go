@@ -0,0 +1,205 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Settings that bound a team's JetStream stream. They are read from the
+// organization's settings, which reach the sidecars as environment
+// variables.
+const (
+	SettingStreamMaxAge   = "NATS_STREAM_MAX_AGE"
+	SettingStreamMaxBytes = "NATS_STREAM_MAX_BYTES"
+	SettingStreamMaxMsgs  = "NATS_STREAM_MAX_MSGS"
+)
+
+// ErrStreamNotFound is returned for a stream the NATS server does not have.
+var ErrStreamNotFound = jetstream.ErrStreamNotFound
+
+// StreamLimitsFromEnv reads the stream limits from env, falling back to the
+// process environment.
+func StreamLimitsFromEnv(env map[string]string) (StreamLimits, error) {
+	get := func(key string) string {
+		if v := strings.TrimSpace(env[key]); v != "" {
+			return v
+		}
+		return strings.TrimSpace(os.Getenv(key))
+	}
+	var limits StreamLimits
+	if v := get(SettingStreamMaxAge); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return StreamLimits{}, fmt.Errorf("%s: %w", SettingStreamMaxAge, err)
+		}
+		if d < 0 {
+			return StreamLimits{}, fmt.Errorf("%s: must not be negative", SettingStreamMaxAge)
+		}
+		limits.MaxAge = d
+	}
+	if v := get(SettingStreamMaxBytes); v != "" {
+		n, err := ParseSize(v)
+		if err != nil {
+			return StreamLimits{}, fmt.Errorf("%s: %w", SettingStreamMaxBytes, err)
+		}
+		if n < 0 {
+			return StreamLimits{}, fmt.Errorf("%s: must not be negative", SettingStreamMaxBytes)
+		}
+		limits.MaxBytes = n
+	}
+	if v := get(SettingStreamMaxMsgs); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return StreamLimits{}, fmt.Errorf("%s: %w", SettingStreamMaxMsgs, err)
+		}
+		if n < 0 {
+			return StreamLimits{}, fmt.Errorf("%s: must not be negative", SettingStreamMaxMsgs)
+		}
+		limits.MaxMsgs = n
+	}
+	return limits, nil
+}
+
+// StreamState describes a JetStream stream, its limits and its consumers.
+// A limit of -1 means none.
+type StreamState struct {
+	Name          string          `json:"name"`
+	Subjects      []string        `json:"subjects"`
+	Messages      uint64          `json:"messages"`
+	Bytes         uint64          `json:"bytes"`
+	FirstSeq      uint64          `json:"first_seq"`
+	LastSeq       uint64          `json:"last_seq"`
+	FirstTime     *time.Time      `json:"first_time,omitempty"`
+	LastTime      *time.Time      `json:"last_time,omitempty"`
+	MaxAgeSeconds int64           `json:"max_age_seconds"`
+	MaxBytes      int64           `json:"max_bytes"`
+	MaxMsgs       int64           `json:"max_msgs"`
+	Created       time.Time       `json:"created"`
+	Consumers     []ConsumerState `json:"consumers"`
+}
+
+// ConsumerState describes a consumer of a stream.
+type ConsumerState struct {
+	Name string `json:"name"`
+	// Pending is the number of messages not delivered yet.
+	Pending uint64 `json:"pending"`
+	// AckPending is the number of messages delivered but not acknowledged.
+	AckPending  int        `json:"ack_pending"`
+	Redelivered int        `json:"redelivered"`
+	LastActive  *time.Time `json:"last_active,omitempty"`
+}
+
+// Streams returns the state of the streams of the NATS server.
+func (c *Client) Streams(ctx context.Context) ([]StreamState, error) {
+	if c.js == nil {
+		return nil, fmt.Errorf("jetstream not enabled")
+	}
+	var names []string
+	lister := c.js.StreamNames(ctx)
+	for name := range lister.Name() {
+		names = append(names, name)
+	}
+	if err := lister.Err(); err != nil {
+		return nil, fmt.Errorf("listing streams: %w", err)
+	}
+
+	states := make([]StreamState, 0, len(names))
+	for _, name := range names {
+		state, err := c.StreamState(ctx, name)
+		if errors.Is(err, ErrStreamNotFound) {
+			continue // deleted since it was listed
+		}
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// StreamState returns the state of the named stream.
+func (c *Client) StreamState(ctx context.Context, name string) (StreamState, error) {
+	if c.js == nil {
+		return StreamState{}, fmt.Errorf("jetstream not enabled")
+	}
+	stream, err := c.js.Stream(ctx, name)
+	if err != nil {
+		return StreamState{}, fmt.Errorf("finding stream %s: %w", name, err)
+	}
+	return streamState(ctx, stream)
+}
+
+// PurgeStream removes every message of the named stream, and returns its
+// state after the purge.
+func (c *Client) PurgeStream(ctx context.Context, name string) (StreamState, error) {
+	if c.js == nil {
+		return StreamState{}, fmt.Errorf("jetstream not enabled")
+	}
+	stream, err := c.js.Stream(ctx, name)
+	if err != nil {
+		return StreamState{}, fmt.Errorf("finding stream %s: %w", name, err)
+	}
+	if err := stream.Purge(ctx); err != nil {
+		return StreamState{}, fmt.Errorf("purging stream %s: %w", name, err)
+	}
+	return streamState(ctx, stream)
+}
+
+// streamState reads the state of a stream and of its consumers.
+func streamState(ctx context.Context, stream jetstream.Stream) (StreamState, error) {
+	info, err := stream.Info(ctx)
+	if err != nil {
+		return StreamState{}, fmt.Errorf("reading stream info: %w", err)
+	}
+	state := StreamState{
+		Name:          info.Config.Name,
+		Subjects:      info.Config.Subjects,
+		Messages:      info.State.Msgs,
+		Bytes:         info.State.Bytes,
+		FirstSeq:      info.State.FirstSeq,
+		LastSeq:       info.State.LastSeq,
+		FirstTime:     optionalTime(info.State.FirstTime),
+		LastTime:      optionalTime(info.State.LastTime),
+		MaxAgeSeconds: int64(info.Config.MaxAge / time.Second),
+		MaxBytes:      info.Config.MaxBytes,
+		MaxMsgs:       info.Config.MaxMsgs,
+		Created:       info.Created,
+		Consumers:     []ConsumerState{},
+	}
+	if info.Config.MaxAge == 0 {
+		state.MaxAgeSeconds = -1
+	}
+
+	lister := stream.ListConsumers(ctx)
+	for ci := range lister.Info() {
+		cs := ConsumerState{
+			Name:        ci.Name,
+			Pending:     ci.NumPending,
+			AckPending:  ci.NumAckPending,
+			Redelivered: ci.NumRedelivered,
+		}
+		if ci.Delivered.Last != nil {
+			cs.LastActive = optionalTime(*ci.Delivered.Last)
+		}
+		state.Consumers = append(state.Consumers, cs)
+	}
+	if err := lister.Err(); err != nil {
+		return StreamState{}, fmt.Errorf("listing consumers of %s: %w", state.Name, err)
+	}
+	return state, nil
+}
+
+// optionalTime returns nil for the zero time.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,34 @@
+package nats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamLimitsFromEnv(t *testing.T) {
+	t.Setenv(SettingStreamMaxMsgs, "1000")
+
+	limits, err := StreamLimitsFromEnv(map[string]string{
+		SettingStreamMaxAge:   "6h",
+		SettingStreamMaxBytes: "256MB",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := StreamLimits{MaxAge: 6 * time.Hour, MaxBytes: 256 << 20, MaxMsgs: 1000}
+	if limits != want {
+		t.Errorf("limits: got %+v, want %+v", limits, want)
+	}
+
+	for key, value := range map[string]string{
+		SettingStreamMaxAge:   "-1h",
+		SettingStreamMaxBytes: "lots",
+		SettingStreamMaxMsgs:  "-5",
+	} {
+		_, err := StreamLimitsFromEnv(map[string]string{key: value})
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("%s=%q: got %v, want an error naming the setting", key, value, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+// Backoff bounds between manager restart attempts. Variables so tests can
+// shorten them.
+var (
+	managerRestartBaseDelay = time.Second
+	managerRestartMaxDelay  = 30 * time.Second
+)
+
+// managerRestartedEvent is the activity event type published after the
+// supervisor brings the agent manager back.
+const managerRestartedEvent = "manager_restarted"
+
+// crashedRunMessage is shown in the chat when a run ends without a response
+// because the agent process crashed.
+const crashedRunMessage = "The agent process stopped unexpectedly and was restarted. Please resend your message."
+
+// interruptedRunMessage is shown in the chat when a run is interrupted
+// before it produced a response.
+const interruptedRunMessage = "The run was interrupted. Send a new message to continue."
+
+// sendInput forwards input to the manager, with overrides if set, turning a
+// panic in the invocation into an error so the supervisor can restart the
+// manager. Managers that cannot apply overrides run input with the agent's
+// own settings.
+func (b *Bridge) sendInput(input string, overrides *protocol.RunOverrides) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("agent invocation panicked: %v", r)
+		}
+	}()
+	if overrides != nil && !overrides.IsZero() {
+		o := *overrides
+		if verr := o.Validate(); verr != nil {
+			// A read-only run stays read-only without the invalid settings.
+			slog.Warn("ignoring invalid run overrides", "agent", b.config.AgentName, "error", verr)
+			o = protocol.RunOverrides{ReadOnly: o.ReadOnly}
+		}
+		om, ok := b.manager.(provider.Overridable)
+		switch {
+		case o.IsZero():
+		case !ok:
+			slog.Warn("agent does not support run overrides, using its own settings", "agent", b.config.AgentName)
+		default:
+			return om.SendInputWithOverrides(input, o)
+		}
+	}
+	return b.manager.SendInput(input)
+}
+
+// failCrashedRun publishes a failed leader_response for a run that produced
+// none, keeping the scheduled run ID queue aligned with agent responses.
+func (b *Bridge) failCrashedRun() {
+	b.mu.Lock()
+	published := b.errorPublished
+	b.errorPublished = true
+	b.mu.Unlock()
+
+	if !published {
+		b.publishLeaderResponse("", "failed", "", crashedRunMessage)
+	}
+}
+
+// failInterruptedRun publishes an interrupted leader_response for a run that
+// was stopped before it produced one.
+func (b *Bridge) failInterruptedRun() {
+	b.mu.Lock()
+	published := b.errorPublished
+	b.errorPublished = true
+	b.mu.Unlock()
+
+	if !published {
+		b.publishLeaderResponse("", "interrupted", "", interruptedRunMessage)
+	}
+}
+
+// restartDelay returns the backoff before the given consecutive restart,
+// doubling from managerRestartBaseDelay up to managerRestartMaxDelay.
+func restartDelay(consecutive int) time.Duration {
+	delay := managerRestartBaseDelay
+	for i := 1; i < consecutive && delay < managerRestartMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > managerRestartMaxDelay {
+		delay = managerRestartMaxDelay
+	}
+	return delay
+}
+
+// restartManager brings the agent manager back after a crash, retrying with
+// capped exponential backoff until it succeeds or ctx is cancelled. The
+// backoff keeps growing across crashes until a run completes normally, so a
+// manager that keeps failing is not restarted in a tight loop. Returns false
+// when the bridge is shutting down or the agent was stopped on purpose.
+func (b *Bridge) restartManager(ctx context.Context, reason string) bool {
+	b.restartMu.Lock()
+	defer b.restartMu.Unlock()
+
+	for {
+		b.mu.Lock()
+		stopped := b.managerStopped
+		b.consecutiveRestarts++
+		attempt := b.consecutiveRestarts
+		b.mu.Unlock()
+
+		if stopped {
+			return false
+		}
+
+		delay := restartDelay(attempt)
+		slog.Warn("restarting agent manager",
+			"agent", b.config.AgentName,
+			"reason", reason,
+			"attempt", attempt,
+			"delay", delay,
+		)
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+		}
+
+		if err := b.recoverManager(ctx); err != nil {
+			slog.Error("agent manager restart failed", "agent", b.config.AgentName, "attempt", attempt, "error", err)
+			continue
+		}
+
+		b.publishManagerRestarted(reason, attempt)
+		return true
+	}
+}
+
+// recoverManager restarts the manager, preserving the conversation session
+// when the provider supports it.
+func (b *Bridge) recoverManager(ctx context.Context) error {
+	if r, ok := b.manager.(provider.Recoverable); ok {
+		return r.Recover(ctx)
+	}
+	_ = b.manager.Stop()
+	return b.manager.Start(ctx)
+}
+
+// resetRestartBackoff clears the consecutive restart count after a run
+// completes normally.
+func (b *Bridge) resetRestartBackoff() {
+	b.mu.Lock()
+	b.consecutiveRestarts = 0
+	b.mu.Unlock()
+}
+
+// publishManagerRestarted publishes a manager_restarted activity event so the
+// UI can show that the agent recovered from a crash.
+func (b *Bridge) publishManagerRestarted(reason string, attempt int) {
+	var sessionID string
+	if r, ok := b.manager.(provider.Recoverable); ok {
+		sessionID = r.SessionID()
+	}
+
+	raw, err := json.Marshal(map[string]interface{}{
+		"reason":     reason,
+		"attempt":    attempt,
+		"session_id": sessionID,
+		"resumed":    sessionID != "",
+	})
+	if err != nil {
+		slog.Error("failed to marshal manager_restarted event", "error", err)
+		return
+	}
+
+	msg, err := protocol.NewMessage(
+		b.config.AgentName,
+		"system",
+		protocol.TypeActivityEvent,
+		protocol.ActivityEventPayload{
+			EventType: managerRestartedEvent,
+			AgentName: b.config.AgentName,
+			Action:    "agent restarted after " + reason,
+			Payload:   raw,
+		},
+	)
+	if err != nil {
+		slog.Error("failed to create manager_restarted message", "error", err)
+		return
+	}
+
+	subject, err := protocol.TeamActivityChannel(b.config.TeamName)
+	if err != nil {
+		slog.Error("failed to build activity channel", "error", err)
+		return
+	}
+
+	if err := b.client.Publish(subject, msg); err != nil {
+		slog.Debug("failed to publish manager_restarted event", "error", err)
+	}
+	slog.Info("agent manager restarted", "agent", b.config.AgentName, "attempt", attempt, "session_id", sessionID)
+}
@@ -0,0 +1,431 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/provider"
+)
+
+// fakeManager is a minimal AgentManager whose SendInput behaviour is scripted.
+type fakeManager struct {
+	mu        sync.Mutex
+	sendInput func(string) error
+	events    chan provider.StreamEvent
+	sessionID string
+	recovers  int
+}
+
+func (f *fakeManager) Start(context.Context) error { return nil }
+func (f *fakeManager) SendInput(input string) error {
+	return f.sendInput(input)
+}
+func (f *fakeManager) ReadEvents() <-chan provider.StreamEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.events
+}
+func (f *fakeManager) Restart(string) error { return nil }
+func (f *fakeManager) Stop() error          { return nil }
+func (f *fakeManager) Status() string       { return "running" }
+func (f *fakeManager) IsRunning() bool      { return true }
+
+func (f *fakeManager) Recover(context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.recovers++
+	f.events = make(chan provider.StreamEvent)
+	return nil
+}
+
+func (f *fakeManager) SessionID() string { return f.sessionID }
+
+func (f *fakeManager) recoverCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.recovers
+}
+
+func shortRestartBackoff(t *testing.T) {
+	t.Helper()
+	base, max := managerRestartBaseDelay, managerRestartMaxDelay
+	managerRestartBaseDelay, managerRestartMaxDelay = time.Millisecond, 4*time.Millisecond
+	t.Cleanup(func() { managerRestartBaseDelay, managerRestartMaxDelay = base, max })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met before deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func managerRestartedEvents(t *testing.T, pub *fakePublisher) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type != protocol.TypeActivityEvent {
+			continue
+		}
+		p, err := protocol.ParsePayload[protocol.ActivityEventPayload](m.Msg)
+		if err != nil || p.EventType != managerRestartedEvent {
+			continue
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(p.Payload, &data); err != nil {
+			t.Fatalf("unmarshal manager_restarted payload: %v", err)
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+func TestRestartDelay_Capped(t *testing.T) {
+	shortRestartBackoff(t)
+	for attempt, want := range map[int]time.Duration{
+		1:  time.Millisecond,
+		2:  2 * time.Millisecond,
+		3:  4 * time.Millisecond,
+		10: 4 * time.Millisecond,
+	} {
+		if got := restartDelay(attempt); got != want {
+			t.Errorf("restartDelay(%d): got %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestProcessUserMessages_RestartsAfterPanic(t *testing.T) {
+	shortRestartBackoff(t)
+	pub := &fakePublisher{}
+	mgr := &fakeManager{
+		sessionID: "sess-1",
+		events:    make(chan provider.StreamEvent),
+		sendInput: func(string) error { panic("boom") },
+	}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "crashteam", Role: "leader"},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 1),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	bridge.userMsgs <- pendingMessage{content: "hello", scheduledRunID: "run-1"}
+	waitFor(t, func() bool { return len(managerRestartedEvents(t, pub)) == 1 })
+	cancel()
+	bridge.wg.Wait()
+
+	if mgr.recoverCount() != 1 {
+		t.Errorf("recover count: got %d, want 1", mgr.recoverCount())
+	}
+
+	// The crashed run is reported as failed, consuming its run ID.
+	var failed int
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type != protocol.TypeLeaderResponse {
+			continue
+		}
+		p, _ := protocol.ParsePayload[protocol.LeaderResponsePayload](m.Msg)
+		if p.Status != "failed" || p.ScheduledRunID != "run-1" {
+			t.Errorf("leader response: got status=%q run=%q", p.Status, p.ScheduledRunID)
+		}
+		failed++
+	}
+	if failed != 1 {
+		t.Errorf("failed leader responses: got %d, want 1", failed)
+	}
+
+	ev := managerRestartedEvents(t, pub)[0]
+	if ev["session_id"] != "sess-1" || ev["resumed"] != true {
+		t.Errorf("manager_restarted payload: got %v", ev)
+	}
+}
+
+func TestProcessUserMessages_NoRestartAfterShutdown(t *testing.T) {
+	shortRestartBackoff(t)
+	pub := &fakePublisher{}
+	mgr := &fakeManager{
+		events:    make(chan provider.StreamEvent),
+		sendInput: func(string) error { return errors.New("process is not running") },
+	}
+	bridge := &Bridge{
+		config:         BridgeConfig{AgentName: "leader", TeamName: "crashteam", Role: "leader"},
+		client:         pub,
+		manager:        mgr,
+		userMsgs:       make(chan pendingMessage, 1),
+		managerStopped: true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+	bridge.userMsgs <- pendingMessage{content: "hello"}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	bridge.wg.Wait()
+
+	if mgr.recoverCount() != 0 {
+		t.Errorf("stopped manager must not be restarted, got %d recovers", mgr.recoverCount())
+	}
+}
+
+func TestDrain_WaitsForRunInFlight(t *testing.T) {
+	pub := &fakePublisher{}
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	mgr := &fakeManager{
+		events: make(chan provider.StreamEvent),
+		sendInput: func(string) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "drainteam", Role: "leader"},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 2),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	bridge.userMsgs <- pendingMessage{content: "first"}
+	<-started
+
+	// The grace period expires while the run is still going.
+	short, shortCancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer shortCancel()
+	if bridge.Drain(short) {
+		t.Error("Drain should report false while the run is in flight")
+	}
+
+	// Messages arriving during the drain are not started, but handed back
+	// for redelivery.
+	second := &nakRecorder{naks: make(chan time.Duration, 1)}
+	bridge.userMsgs <- pendingMessage{content: "second", delivery: &Delivery{StreamSeq: 2, msg: second}}
+
+	drained := make(chan bool)
+	go func() { drained <- bridge.Drain(context.Background()) }()
+	close(release)
+	if !<-drained {
+		t.Error("Drain should report true once the run finishes")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-started:
+		t.Error("no new run should start while draining")
+	default:
+	}
+	select {
+	case delay := <-second.naks:
+		if delay != drainRedeliveryDelay {
+			t.Errorf("nak delay: got %v, want %v", delay, drainRedeliveryDelay)
+		}
+	default:
+		t.Error("message refused while draining was not handed back")
+	}
+}
+
+// nakRecorder is a JetStream message that records the delays it is
+// nakked with.
+type nakRecorder struct {
+	jetstream.Msg
+	naks chan time.Duration
+}
+
+func (m *nakRecorder) NakWithDelay(delay time.Duration) error {
+	m.naks <- delay
+	return nil
+}
+
+func TestDrain_IdleReturnsImmediately(t *testing.T) {
+	bridge := &Bridge{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if !bridge.Drain(ctx) {
+		t.Error("idle bridge should drain immediately")
+	}
+	if !bridge.isDraining() {
+		t.Error("bridge should be draining after Drain")
+	}
+}
+
+// interruptibleManager is a fakeManager whose runs block until interrupted.
+type interruptibleManager struct {
+	*fakeManager
+	interrupt chan struct{}
+}
+
+func (m *interruptibleManager) Interrupt() bool {
+	close(m.interrupt)
+	return true
+}
+
+func TestBridge_ControlChannelCommands(t *testing.T) {
+	pub := &fakePublisher{}
+	mgr := &interruptibleManager{interrupt: make(chan struct{})}
+	mgr.fakeManager = &fakeManager{events: make(chan provider.StreamEvent)}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "controlteam", Role: "leader"},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 2),
+	}
+	if err := bridge.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer bridge.Stop()
+
+	handler := pub.core["control.controlteam.leader"]
+	if handler == nil {
+		t.Fatalf("control channel not subscribed: %v", pub.core)
+	}
+
+	// Only system commands are taken from the control channel.
+	handler(userMessage(t, protocol.UserMessagePayload{Content: "sneaked in"}))
+	if len(bridge.userMsgs) != 0 {
+		t.Error("user message on the control channel was queued")
+	}
+
+	cmd, err := protocol.NewMessage("api", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{Command: "interrupt"})
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	handler(cmd)
+	select {
+	case <-mgr.interrupt:
+	default:
+		t.Error("interrupt on the control channel did not reach the agent")
+	}
+}
+
+func TestHandleSystemCommand_InterruptKeepsSession(t *testing.T) {
+	pub := &fakePublisher{}
+	ran := make(chan string, 2)
+	mgr := &interruptibleManager{interrupt: make(chan struct{})}
+	mgr.fakeManager = &fakeManager{
+		sessionID: "sess-1",
+		events:    make(chan provider.StreamEvent),
+		sendInput: func(input string) error {
+			ran <- input
+			if input == "long task" {
+				<-mgr.interrupt
+				return provider.ErrInterrupted
+			}
+			return nil
+		},
+	}
+	bridge := &Bridge{
+		config:   BridgeConfig{AgentName: "leader", TeamName: "interruptteam", Role: "leader"},
+		client:   pub,
+		manager:  mgr,
+		userMsgs: make(chan pendingMessage, 2),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bridge.wg.Add(1)
+	go bridge.processUserMessages(ctx)
+
+	bridge.userMsgs <- pendingMessage{content: "long task", scheduledRunID: "run-1"}
+	bridge.userMsgs <- pendingMessage{content: "next"}
+	<-ran
+
+	cmd, err := protocol.NewMessage("api", "leader", protocol.TypeSystemCommand, protocol.SystemCommandPayload{Command: "interrupt"})
+	if err != nil {
+		t.Fatalf("NewMessage: %v", err)
+	}
+	bridge.handleSystemCommand(cmd)
+
+	// The next message runs on the same session, without a restart.
+	if got := <-ran; got != "next" {
+		t.Errorf("next run: got %q, want next", got)
+	}
+	cancel()
+	bridge.wg.Wait()
+	if mgr.recoverCount() != 0 {
+		t.Errorf("recover count: got %d, want 0", mgr.recoverCount())
+	}
+
+	var interrupted int
+	for _, m := range pub.getMessages() {
+		if m.Msg.Type != protocol.TypeLeaderResponse {
+			continue
+		}
+		p, err := protocol.ParsePayload[protocol.LeaderResponsePayload](m.Msg)
+		if err != nil {
+			t.Fatalf("parse leader response: %v", err)
+		}
+		if p.Status != "interrupted" || p.ScheduledRunID != "run-1" {
+			t.Errorf("leader response: got %+v", p)
+		}
+		interrupted++
+	}
+	if interrupted != 1 {
+		t.Errorf("leader responses: got %d, want 1", interrupted)
+	}
+}
+
+// overridableManager is a fakeManager that records the overrides it runs with.
+type overridableManager struct {
+	fakeManager
+	overrides []protocol.RunOverrides
+}
+
+func (o *overridableManager) SendInputWithOverrides(input string, overrides protocol.RunOverrides) error {
+	o.overrides = append(o.overrides, overrides)
+	return o.sendInput(input)
+}
+
+func TestSendInput_Overrides(t *testing.T) {
+	var inputs []string
+	mgr := &overridableManager{fakeManager: fakeManager{sendInput: func(input string) error {
+		inputs = append(inputs, input)
+		return nil
+	}}}
+	b := &Bridge{config: BridgeConfig{AgentName: "leader"}, manager: mgr}
+
+	b.sendInput("plain", nil)
+	b.sendInput("empty", &protocol.RunOverrides{})
+	b.sendInput("opus", &protocol.RunOverrides{Model: "claude-opus-4-20250514"})
+	b.sendInput("invalid", &protocol.RunOverrides{Model: "--resume"})
+	b.sendInput("invalid plan", &protocol.RunOverrides{Model: "--resume", ReadOnly: true})
+
+	if len(inputs) != 5 {
+		t.Fatalf("inputs: got %q", inputs)
+	}
+	if len(mgr.overrides) != 2 || mgr.overrides[0].Model != "claude-opus-4-20250514" ||
+		mgr.overrides[1] != (protocol.RunOverrides{ReadOnly: true}) {
+		t.Errorf("overrides: got %+v, want the valid one and a read-only run without the invalid model", mgr.overrides)
+	}
+
+	// Managers without override support run the input as is.
+	plain := &fakeManager{sendInput: func(input string) error {
+		inputs = append(inputs, input)
+		return nil
+	}}
+	b.manager = plain
+	if err := b.sendInput("opus", &protocol.RunOverrides{Model: "opus"}); err != nil || len(inputs) != 6 {
+		t.Errorf("without override support: err %v, inputs %q", err, inputs)
+	}
+}
@@ -0,0 +1,96 @@
+package operator
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Controller watches AgentTeam resources and reconciles each change. Every
+// resource is also reconciled each resync period, so that team status
+// changes made outside Kubernetes reach the resource.
+type Controller struct {
+	reconciler *Reconciler
+	informer   cache.SharedIndexInformer
+	factory    dynamicinformer.DynamicSharedInformerFactory
+	queue      workqueue.TypedRateLimitingInterface[string]
+}
+
+// NewController returns a controller for the AgentTeam resources in
+// namespace, or in all namespaces when namespace is empty.
+func NewController(dyn dynamic.Interface, api TeamAPI, namespace string, resync time.Duration) *Controller {
+	factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(dyn, resync, namespace, nil)
+	c := &Controller{
+		reconciler: &Reconciler{Dynamic: dyn, API: api},
+		informer:   factory.ForResource(AgentTeamGVR).Informer(),
+		factory:    factory,
+		queue: workqueue.NewTypedRateLimitingQueue(
+			workqueue.DefaultTypedControllerRateLimiter[string](),
+		),
+	}
+	enqueue := func(obj any) {
+		if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+			c.queue.Add(key)
+		}
+	}
+	c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueue,
+		UpdateFunc: func(_, obj any) { enqueue(obj) },
+		DeleteFunc: enqueue,
+	})
+	return c
+}
+
+// Run reconciles resources with the given number of workers until ctx is
+// cancelled.
+func (c *Controller) Run(ctx context.Context, workers int) {
+	defer c.queue.ShutDown()
+
+	c.factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced) {
+		return
+	}
+	slog.Info("operator watching agent teams", "workers", workers)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.processNext(ctx) {
+			}
+		}()
+	}
+	<-ctx.Done()
+	c.queue.ShutDown()
+	wg.Wait()
+}
+
+// processNext reconciles the next queued resource. Failures are retried
+// with backoff.
+func (c *Controller) processNext(ctx context.Context) bool {
+	key, shutdown := c.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		c.queue.Forget(key)
+		return true
+	}
+	if err := c.reconciler.Reconcile(ctx, namespace, name); err != nil {
+		slog.Warn("failed to reconcile agent team", "resource", key, "error", err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
@@ -0,0 +1,246 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// TeamAPI is the part of the AgentCrew API client the reconciler uses.
+type TeamAPI interface {
+	GetTeam(ctx context.Context, id string) (*models.Team, error)
+	FindTeam(ctx context.Context, slug string) (*models.Team, error)
+	CreateTeam(ctx context.Context, in client.TeamInput) (*models.Team, error)
+	UpdateTeam(ctx context.Context, id string, in client.TeamInput) (*models.Team, error)
+	DeleteTeam(ctx context.Context, id string, opts client.DeleteTeamOptions) error
+	DeployTeam(ctx context.Context, id string) (*models.Team, error)
+	StopTeam(ctx context.Context, id string) (*models.Team, error)
+	CreateAgent(ctx context.Context, teamID string, in client.AgentInput) (*models.Agent, error)
+	UpdateAgent(ctx context.Context, teamID, agentID string, in client.AgentInput) (*models.Agent, error)
+	DeleteAgent(ctx context.Context, teamID, agentID string) error
+}
+
+// Reconciler applies AgentTeam resources to AgentCrew.
+type Reconciler struct {
+	Dynamic dynamic.Interface
+	API     TeamAPI
+}
+
+// Reconcile brings the team of one AgentTeam in line with its spec and
+// writes the team's state to the resource's status. A missing resource is
+// not an error: it was deleted after its finalizer was removed.
+func (r *Reconciler) Reconcile(ctx context.Context, namespace, name string) error {
+	resources := r.Dynamic.Resource(AgentTeamGVR).Namespace(namespace)
+	u, err := resources.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	at, err := fromUnstructured(u)
+	if err != nil {
+		return err
+	}
+
+	if at.DeletionTimestamp != nil {
+		return r.finalize(ctx, u, at)
+	}
+	if !slices.Contains(u.GetFinalizers(), Finalizer) {
+		u.SetFinalizers(append(u.GetFinalizers(), Finalizer))
+		if u, err = resources.Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+
+	status, err := r.apply(ctx, at)
+	if status.TeamID == "" {
+		status.TeamID = at.Status.TeamID
+	}
+	if err != nil {
+		status.Message = err.Error()
+	}
+	if statusErr := r.writeStatus(ctx, u, status); statusErr != nil && err == nil {
+		err = statusErr
+	}
+	return err
+}
+
+// apply creates or updates the team, its agents and its deployment. The
+// returned status is set as far as it got, also when it fails.
+func (r *Reconciler) apply(ctx context.Context, at *AgentTeam) (AgentTeamStatus, error) {
+	status := at.Status
+	status.Message = ""
+
+	var team *models.Team
+	var err error
+	if status.TeamID != "" {
+		team, err = r.API.GetTeam(ctx, status.TeamID)
+		if err != nil && !client.IsNotFound(err) {
+			return status, err
+		}
+	}
+	if team == nil {
+		// The team may have been created by a reconciliation whose status
+		// update failed.
+		if team, err = r.findOwnTeam(ctx, at); err != nil {
+			return status, err
+		}
+		if team != nil {
+			status.TeamID = team.ID
+			status.ObservedGeneration = 0
+		}
+	}
+	if team == nil {
+		created, err := r.API.CreateTeam(ctx, at.teamInput())
+		if err != nil {
+			return status, fmt.Errorf("creating team: %w", err)
+		}
+		status.TeamID = created.ID
+		status.ObservedGeneration = 0
+		team = created
+	}
+
+	// Stop before syncing agents: the API refuses to delete running ones.
+	if !at.Spec.Running && team.Status != models.TeamStatusStopped {
+		if _, err := r.API.StopTeam(ctx, team.ID); err != nil {
+			return status, fmt.Errorf("stopping team: %w", err)
+		}
+	}
+
+	if status.ObservedGeneration != at.Generation {
+		if _, err := r.API.UpdateTeam(ctx, team.ID, at.teamInput()); err != nil {
+			return status, fmt.Errorf("updating team: %w", err)
+		}
+		if err := r.syncAgents(ctx, team, at.Spec.Agents); err != nil {
+			return status, err
+		}
+		status.ObservedGeneration = at.Generation
+	}
+
+	if at.Spec.Running && team.Status == models.TeamStatusStopped {
+		if _, err := r.API.DeployTeam(ctx, team.ID); err != nil {
+			return status, fmt.Errorf("deploying team: %w", err)
+		}
+	}
+
+	team, err = r.API.GetTeam(ctx, team.ID)
+	if err != nil {
+		return status, err
+	}
+	status.Phase = team.Status
+	status.Message = team.StatusMessage
+	status.Agents = len(team.Agents)
+	return status, nil
+}
+
+// findOwnTeam returns the team named after at that at created, or nil if
+// there is none. A team of that name created otherwise is an error: it is
+// not taken over.
+func (r *Reconciler) findOwnTeam(ctx context.Context, at *AgentTeam) (*models.Team, error) {
+	team, err := r.API.FindTeam(ctx, naming.Slug(at.teamName()))
+	if err != nil {
+		return nil, fmt.Errorf("looking up team: %w", err)
+	}
+	if team == nil {
+		return nil, nil
+	}
+	var labels map[string]string
+	if len(team.Labels) > 0 {
+		if err := json.Unmarshal(team.Labels, &labels); err != nil {
+			return nil, fmt.Errorf("decoding labels of team %q: %w", team.Name, err)
+		}
+	}
+	if at.UID == "" || labels[OwnerLabel] != string(at.UID) {
+		return nil, fmt.Errorf("team %q already exists and is not managed by this %s", team.Name, Kind)
+	}
+	return team, nil
+}
+
+// syncAgents creates, updates and deletes the team's agents to match
+// specs, matching them by name.
+func (r *Reconciler) syncAgents(ctx context.Context, team *models.Team, specs []AgentSpec) error {
+	existing := make(map[string]string, len(team.Agents))
+	for _, agent := range team.Agents {
+		existing[agent.Name] = agent.ID
+	}
+	for _, spec := range specs {
+		if id, ok := existing[spec.Name]; ok {
+			if _, err := r.API.UpdateAgent(ctx, team.ID, id, spec.input()); err != nil {
+				return fmt.Errorf("updating agent %q: %w", spec.Name, err)
+			}
+			delete(existing, spec.Name)
+			continue
+		}
+		if _, err := r.API.CreateAgent(ctx, team.ID, spec.input()); err != nil {
+			return fmt.Errorf("creating agent %q: %w", spec.Name, err)
+		}
+	}
+	for name, id := range existing {
+		if err := r.API.DeleteAgent(ctx, team.ID, id); err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("deleting agent %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// finalize deletes the team of a deleted AgentTeam and releases the
+// resource.
+func (r *Reconciler) finalize(ctx context.Context, u *unstructured.Unstructured, at *AgentTeam) error {
+	if !slices.Contains(u.GetFinalizers(), Finalizer) {
+		return nil
+	}
+	if at.Status.TeamID != "" {
+		err := r.API.DeleteTeam(ctx, at.Status.TeamID, client.DeleteTeamOptions{
+			Force:             true,
+			PreserveWorkspace: at.Spec.PreserveWorkspace,
+		})
+		if err != nil && !client.IsNotFound(err) {
+			return fmt.Errorf("deleting team: %w", err)
+		}
+	}
+	u.SetFinalizers(slices.DeleteFunc(u.GetFinalizers(), func(f string) bool { return f == Finalizer }))
+	_, err := r.Dynamic.Resource(AgentTeamGVR).Namespace(u.GetNamespace()).Update(ctx, u, metav1.UpdateOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// writeStatus updates the resource's status subresource when it changed.
+func (r *Reconciler) writeStatus(ctx context.Context, u *unstructured.Unstructured, status AgentTeamStatus) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	var obj map[string]any
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	current, _, _ := unstructured.NestedMap(u.Object, "status")
+	if equalJSON(current, obj) {
+		return nil
+	}
+	u = u.DeepCopy()
+	u.Object["status"] = obj
+	_, err = r.Dynamic.Resource(AgentTeamGVR).Namespace(u.GetNamespace()).UpdateStatus(ctx, u, metav1.UpdateOptions{})
+	return err
+}
+
+// equalJSON compares two JSON objects by their encoding, so that numbers
+// decoded as int64 and float64 compare equal.
+func equalJSON(a, b map[string]any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
@@ -0,0 +1,334 @@
+package operator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// fakeAPI keeps teams in memory and records the calls made to it.
+type fakeAPI struct {
+	teams map[string]*models.Team
+	calls []string
+	next  int
+}
+
+func newFakeAPI() *fakeAPI { return &fakeAPI{teams: map[string]*models.Team{}} }
+
+func (f *fakeAPI) id(prefix string) string {
+	f.next++
+	return fmt.Sprintf("%s%d", prefix, f.next)
+}
+
+func (f *fakeAPI) team(id string) (*models.Team, error) {
+	team, ok := f.teams[id]
+	if !ok {
+		return nil, &client.Error{StatusCode: http.StatusNotFound, Message: "team not found"}
+	}
+	return team, nil
+}
+
+func (f *fakeAPI) GetTeam(_ context.Context, id string) (*models.Team, error) {
+	team, err := f.team(id)
+	if err != nil {
+		return nil, err
+	}
+	cp := *team
+	cp.Agents = append([]models.Agent(nil), team.Agents...)
+	return &cp, nil
+}
+
+func (f *fakeAPI) FindTeam(ctx context.Context, slug string) (*models.Team, error) {
+	for id, team := range f.teams {
+		if naming.Slug(team.Name) == slug {
+			return f.GetTeam(ctx, id)
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeAPI) CreateTeam(_ context.Context, in client.TeamInput) (*models.Team, error) {
+	f.calls = append(f.calls, "create team "+in.Name)
+	labels, _ := json.Marshal(in.Labels)
+	team := &models.Team{ID: f.id("t"), Name: in.Name, Status: models.TeamStatusStopped, Labels: labels}
+	f.teams[team.ID] = team
+	return team, nil
+}
+
+func (f *fakeAPI) UpdateTeam(_ context.Context, id string, in client.TeamInput) (*models.Team, error) {
+	f.calls = append(f.calls, "update team "+in.Name)
+	team, err := f.team(id)
+	if err != nil {
+		return nil, err
+	}
+	team.Name = in.Name
+	team.Description = in.Description
+	return team, nil
+}
+
+func (f *fakeAPI) DeleteTeam(_ context.Context, id string, opts client.DeleteTeamOptions) error {
+	f.calls = append(f.calls, fmt.Sprintf("delete team %s force=%t", id, opts.Force))
+	delete(f.teams, id)
+	return nil
+}
+
+func (f *fakeAPI) DeployTeam(_ context.Context, id string) (*models.Team, error) {
+	f.calls = append(f.calls, "deploy "+id)
+	team, err := f.team(id)
+	if err != nil {
+		return nil, err
+	}
+	team.Status = models.TeamStatusDeploying
+	return team, nil
+}
+
+func (f *fakeAPI) StopTeam(_ context.Context, id string) (*models.Team, error) {
+	f.calls = append(f.calls, "stop "+id)
+	team, err := f.team(id)
+	if err != nil {
+		return nil, err
+	}
+	team.Status = models.TeamStatusStopped
+	return team, nil
+}
+
+func (f *fakeAPI) CreateAgent(_ context.Context, teamID string, in client.AgentInput) (*models.Agent, error) {
+	f.calls = append(f.calls, "create agent "+in.Name)
+	team, err := f.team(teamID)
+	if err != nil {
+		return nil, err
+	}
+	agent := models.Agent{ID: f.id("a"), TeamID: teamID, Name: in.Name, Role: in.Role}
+	team.Agents = append(team.Agents, agent)
+	return &agent, nil
+}
+
+func (f *fakeAPI) UpdateAgent(_ context.Context, teamID, agentID string, in client.AgentInput) (*models.Agent, error) {
+	f.calls = append(f.calls, "update agent "+in.Name)
+	team, err := f.team(teamID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range team.Agents {
+		if team.Agents[i].ID == agentID {
+			team.Agents[i].Specialty = in.Specialty
+			return &team.Agents[i], nil
+		}
+	}
+	return nil, &client.Error{StatusCode: http.StatusNotFound, Message: "agent not found"}
+}
+
+func (f *fakeAPI) DeleteAgent(_ context.Context, teamID, agentID string) error {
+	team, err := f.team(teamID)
+	if err != nil {
+		return err
+	}
+	for i, agent := range team.Agents {
+		if agent.ID == agentID {
+			f.calls = append(f.calls, "delete agent "+agent.Name)
+			team.Agents = append(team.Agents[:i], team.Agents[i+1:]...)
+			return nil
+		}
+	}
+	return &client.Error{StatusCode: http.StatusNotFound, Message: "agent not found"}
+}
+
+func newAgentTeam(spec map[string]any) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": Group + "/" + Version,
+		"kind":       Kind,
+		"metadata":   map[string]any{"name": "platform", "namespace": "crews", "uid": "uid-platform"},
+		"spec":       spec,
+	}}
+	u.SetGeneration(1)
+	return u
+}
+
+func setupReconciler(t *testing.T, objs ...runtime.Object) (*Reconciler, *fakeAPI) {
+	t.Helper()
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{AgentTeamGVR: Kind + "List"}, objs...)
+	api := newFakeAPI()
+	return &Reconciler{Dynamic: dyn, API: api}, api
+}
+
+func getAgentTeam(t *testing.T, r *Reconciler) *AgentTeam {
+	t.Helper()
+	u, err := r.Dynamic.Resource(AgentTeamGVR).Namespace("crews").Get(context.Background(), "platform", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get AgentTeam: %v", err)
+	}
+	at, err := fromUnstructured(u)
+	if err != nil {
+		t.Fatalf("decode AgentTeam: %v", err)
+	}
+	return at
+}
+
+func TestReconcile_CreatesTeamAndAgents(t *testing.T) {
+	ctx := context.Background()
+	r, api := setupReconciler(t, newAgentTeam(map[string]any{
+		"description": "Platform crew",
+		"running":     true,
+		"agents": []any{
+			map[string]any{"name": "lead", "role": "leader", "skills": []any{"docker"}},
+			map[string]any{"name": "dev", "specialty": "go"},
+		},
+	}))
+
+	if err := r.Reconcile(ctx, "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	at := getAgentTeam(t, r)
+	if at.Status.TeamID != "t1" || at.Status.Phase != models.TeamStatusDeploying || at.Status.Agents != 2 {
+		t.Errorf("status: got %+v", at.Status)
+	}
+	if at.Status.ObservedGeneration != 1 {
+		t.Errorf("observedGeneration: got %d, want 1", at.Status.ObservedGeneration)
+	}
+	if len(at.Finalizers) != 1 || at.Finalizers[0] != Finalizer {
+		t.Errorf("finalizers: got %v", at.Finalizers)
+	}
+	want := []string{"create team platform", "update team platform", "create agent lead", "create agent dev", "deploy t1"}
+	if fmt.Sprint(api.calls) != fmt.Sprint(want) {
+		t.Errorf("calls: got %v, want %v", api.calls, want)
+	}
+
+	// Nothing changed: the next pass only refreshes the status.
+	api.calls = nil
+	if err := r.Reconcile(ctx, "crews", "platform"); err != nil {
+		t.Fatalf("second Reconcile: %v", err)
+	}
+	if len(api.calls) != 0 {
+		t.Errorf("second pass calls: got %v, want none", api.calls)
+	}
+}
+
+func TestReconcile_SyncsAgentsAndStops(t *testing.T) {
+	ctx := context.Background()
+	u := newAgentTeam(map[string]any{
+		"agents": []any{map[string]any{"name": "dev", "specialty": "rust"}},
+	})
+	u.SetGeneration(2)
+	unstructured.SetNestedMap(u.Object, map[string]any{"teamID": "t1", "observedGeneration": int64(1)}, "status")
+	r, api := setupReconciler(t, u)
+	api.teams["t1"] = &models.Team{ID: "t1", Name: "platform", Status: models.TeamStatusRunning, Agents: []models.Agent{
+		{ID: "a1", TeamID: "t1", Name: "dev", Specialty: "go"},
+		{ID: "a2", TeamID: "t1", Name: "qa"},
+	}}
+	api.next = 2
+
+	if err := r.Reconcile(ctx, "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	want := []string{"stop t1", "update team platform", "update agent dev", "delete agent qa"}
+	if fmt.Sprint(api.calls) != fmt.Sprint(want) {
+		t.Errorf("calls: got %v, want %v", api.calls, want)
+	}
+	team := api.teams["t1"]
+	if len(team.Agents) != 1 || team.Agents[0].Specialty != "rust" {
+		t.Errorf("agents: got %+v", team.Agents)
+	}
+	at := getAgentTeam(t, r)
+	if at.Status.Phase != models.TeamStatusStopped || at.Status.ObservedGeneration != 2 || at.Status.Agents != 1 {
+		t.Errorf("status: got %+v", at.Status)
+	}
+}
+
+func TestReconcile_RecreatesMissingTeam(t *testing.T) {
+	u := newAgentTeam(map[string]any{"teamName": "renamed"})
+	unstructured.SetNestedMap(u.Object, map[string]any{"teamID": "gone", "observedGeneration": int64(1)}, "status")
+	r, api := setupReconciler(t, u)
+
+	if err := r.Reconcile(context.Background(), "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if at := getAgentTeam(t, r); at.Status.TeamID != "t1" {
+		t.Errorf("teamID: got %q, want t1", at.Status.TeamID)
+	}
+	if len(api.calls) == 0 || api.calls[0] != "create team renamed" {
+		t.Errorf("calls: got %v", api.calls)
+	}
+}
+
+func TestReconcile_FindsTeamWithoutRecordedID(t *testing.T) {
+	ctx := context.Background()
+	r, api := setupReconciler(t, newAgentTeam(map[string]any{}))
+	// A previous pass created the team but failed to record its ID.
+	api.teams["t1"] = &models.Team{ID: "t1", Name: "platform", Status: models.TeamStatusStopped,
+		Labels: models.JSON(`{"` + OwnerLabel + `": "uid-platform"}`)}
+	api.next = 1
+
+	if err := r.Reconcile(ctx, "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if at := getAgentTeam(t, r); at.Status.TeamID != "t1" || at.Status.ObservedGeneration != 1 {
+		t.Errorf("status: got %+v", at.Status)
+	}
+	if want := []string{"update team platform"}; fmt.Sprint(api.calls) != fmt.Sprint(want) {
+		t.Errorf("calls: got %v, want %v", api.calls, want)
+	}
+}
+
+func TestReconcile_RefusesTeamOfAnotherOwner(t *testing.T) {
+	r, api := setupReconciler(t, newAgentTeam(map[string]any{}))
+	api.teams["t1"] = &models.Team{ID: "t1", Name: "platform", Status: models.TeamStatusRunning}
+
+	if err := r.Reconcile(context.Background(), "crews", "platform"); err == nil {
+		t.Fatal("Reconcile took over a team it did not create")
+	}
+	if len(api.calls) != 0 {
+		t.Errorf("calls: got %v, want none", api.calls)
+	}
+	if at := getAgentTeam(t, r); at.Status.TeamID != "" || at.Status.Message == "" {
+		t.Errorf("status: got %+v", at.Status)
+	}
+}
+
+func TestReconcile_DeletionRemovesTeam(t *testing.T) {
+	ctx := context.Background()
+	u := newAgentTeam(map[string]any{})
+	u.SetFinalizers([]string{Finalizer})
+	now := metav1.Now()
+	u.SetDeletionTimestamp(&now)
+	unstructured.SetNestedMap(u.Object, map[string]any{"teamID": "t1"}, "status")
+	r, api := setupReconciler(t, u)
+	api.teams["t1"] = &models.Team{ID: "t1", Status: models.TeamStatusRunning}
+
+	if err := r.Reconcile(ctx, "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if _, ok := api.teams["t1"]; ok {
+		t.Error("team was not deleted")
+	}
+	if want := "delete team t1 force=true"; len(api.calls) != 1 || api.calls[0] != want {
+		t.Errorf("calls: got %v, want [%s]", api.calls, want)
+	}
+	if at := getAgentTeam(t, r); len(at.Finalizers) != 0 {
+		t.Errorf("finalizers: got %v, want none", at.Finalizers)
+	}
+}
+
+func TestReconcile_MissingResource(t *testing.T) {
+	r, api := setupReconciler(t)
+	if err := r.Reconcile(context.Background(), "crews", "platform"); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if len(api.calls) != 0 {
+		t.Errorf("calls: got %v, want none", api.calls)
+	}
+}
@@ -0,0 +1,178 @@
+// Package operator reconciles AgentTeam custom resources into AgentCrew
+// teams through the API, and reports each team's status back on its
+// resource.
+package operator
+
+import (
+	"encoding/json"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/helmcode/agent-crew/internal/client"
+)
+
+// AgentTeam resource identity.
+const (
+	Group    = "agentcrew.helmcode.io"
+	Version  = "v1alpha1"
+	Kind     = "AgentTeam"
+	Resource = "agentteams"
+)
+
+// Finalizer keeps an AgentTeam until its team is deleted from AgentCrew.
+const Finalizer = Group + "/team"
+
+// OwnerLabel is the team label holding the UID of the AgentTeam that
+// created the team, so that a team whose ID was not recorded is found
+// again rather than created twice.
+const OwnerLabel = Group + "/owner"
+
+// AgentTeamGVR is the group, version and resource of AgentTeam.
+var AgentTeamGVR = schema.GroupVersionResource{Group: Group, Version: Version, Resource: Resource}
+
+// AgentTeam is a team managed from Kubernetes.
+type AgentTeam struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              AgentTeamSpec   `json:"spec"`
+	Status            AgentTeamStatus `json:"status,omitempty"`
+}
+
+// AgentTeamSpec is the desired configuration of a team.
+type AgentTeamSpec struct {
+	// TeamName is the team's name in AgentCrew. Defaults to the resource's
+	// name.
+	TeamName          string            `json:"teamName,omitempty"`
+	Description       string            `json:"description,omitempty"`
+	Runtime           string            `json:"runtime,omitempty"`
+	Provider          string            `json:"provider,omitempty"`
+	ModelProvider     string            `json:"modelProvider,omitempty"`
+	WorkspacePath     string            `json:"workspacePath,omitempty"`
+	AgentImage        string            `json:"agentImage,omitempty"`
+	ConfigDirMode     string            `json:"configDirMode,omitempty"`
+	ResourcePreset    string            `json:"resourcePreset,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	MaxConcurrentRuns int               `json:"maxConcurrentRuns,omitempty"`
+	TelemetryLevel    string            `json:"telemetryLevel,omitempty"`
+	PlanApproval      bool              `json:"planApproval,omitempty"`
+	Memory            bool              `json:"memory,omitempty"`
+	KnowledgeBase     bool              `json:"knowledgeBase,omitempty"`
+	// Agents are matched to the team's agents by name.
+	Agents []AgentSpec `json:"agents,omitempty"`
+	// Running deploys the team when true and stops it when false.
+	Running bool `json:"running,omitempty"`
+	// PreserveWorkspace keeps the team's workspace when the resource is
+	// deleted.
+	PreserveWorkspace bool `json:"preserveWorkspace,omitempty"`
+}
+
+// AgentSpec is the desired configuration of one agent. Skills, Permissions,
+// Resources and SubAgentSkills are passed to the API as they are.
+type AgentSpec struct {
+	Name                   string          `json:"name"`
+	Role                   string          `json:"role,omitempty"`
+	Specialty              string          `json:"specialty,omitempty"`
+	SystemPrompt           string          `json:"systemPrompt,omitempty"`
+	InstructionsMD         string          `json:"instructionsMD,omitempty"`
+	Skills                 json.RawMessage `json:"skills,omitempty"`
+	Permissions            json.RawMessage `json:"permissions,omitempty"`
+	Resources              json.RawMessage `json:"resources,omitempty"`
+	SubAgentDescription    string          `json:"subAgentDescription,omitempty"`
+	SubAgentInstructions   string          `json:"subAgentInstructions,omitempty"`
+	SubAgentModel          string          `json:"subAgentModel,omitempty"`
+	SubAgentSkills         json.RawMessage `json:"subAgentSkills,omitempty"`
+	SubAgentBackground     *bool           `json:"subAgentBackground,omitempty"`
+	SubAgentIsolation      string          `json:"subAgentIsolation,omitempty"`
+	SubAgentPermissionMode string          `json:"subAgentPermissionMode,omitempty"`
+	ExtraClaudeArgs        json.RawMessage `json:"extraClaudeArgs,omitempty"`
+}
+
+// AgentTeamStatus is the observed state of a team.
+type AgentTeamStatus struct {
+	// TeamID is the ID of the team in AgentCrew.
+	TeamID string `json:"teamID,omitempty"`
+	// Phase is the team's status: stopped, deploying, running or error.
+	Phase string `json:"phase,omitempty"`
+	// Message explains an error phase or a failed reconciliation.
+	Message string `json:"message,omitempty"`
+	// Agents is the number of agents the team has.
+	Agents int `json:"agents,omitempty"`
+	// ObservedGeneration is the generation of the spec last applied to the
+	// team.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// fromUnstructured decodes an AgentTeam. JSON is used rather than the
+// unstructured converter so that raw agent documents round-trip.
+func fromUnstructured(u *unstructured.Unstructured) (*AgentTeam, error) {
+	data, err := u.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var team AgentTeam
+	if err := json.Unmarshal(data, &team); err != nil {
+		return nil, fmt.Errorf("decoding %s %s/%s: %w", Kind, u.GetNamespace(), u.GetName(), err)
+	}
+	return &team, nil
+}
+
+// teamName returns the team's name in AgentCrew.
+func (t *AgentTeam) teamName() string {
+	if t.Spec.TeamName != "" {
+		return t.Spec.TeamName
+	}
+	return t.Name
+}
+
+// teamInput returns the API configuration of the team.
+func (t *AgentTeam) teamInput() client.TeamInput {
+	labels := make(map[string]string, len(t.Spec.Labels)+1)
+	for k, v := range t.Spec.Labels {
+		labels[k] = v
+	}
+	if t.UID != "" {
+		labels[OwnerLabel] = string(t.UID)
+	}
+	return client.TeamInput{
+		Name:              t.teamName(),
+		Description:       t.Spec.Description,
+		Runtime:           t.Spec.Runtime,
+		Provider:          t.Spec.Provider,
+		ModelProvider:     t.Spec.ModelProvider,
+		WorkspacePath:     t.Spec.WorkspacePath,
+		AgentImage:        t.Spec.AgentImage,
+		ConfigDirMode:     t.Spec.ConfigDirMode,
+		ResourcePreset:    t.Spec.ResourcePreset,
+		Labels:            labels,
+		MaxConcurrentRuns: t.Spec.MaxConcurrentRuns,
+		TelemetryLevel:    t.Spec.TelemetryLevel,
+		PlanApproval:      t.Spec.PlanApproval,
+		Memory:            t.Spec.Memory,
+		KnowledgeBase:     t.Spec.KnowledgeBase,
+	}
+}
+
+// input returns the API configuration of the agent.
+func (a AgentSpec) input() client.AgentInput {
+	return client.AgentInput{
+		Name:                   a.Name,
+		Role:                   a.Role,
+		Specialty:              a.Specialty,
+		SystemPrompt:           a.SystemPrompt,
+		InstructionsMD:         a.InstructionsMD,
+		Skills:                 a.Skills,
+		Permissions:            a.Permissions,
+		Resources:              a.Resources,
+		SubAgentDescription:    a.SubAgentDescription,
+		SubAgentInstructions:   a.SubAgentInstructions,
+		SubAgentModel:          a.SubAgentModel,
+		SubAgentSkills:         a.SubAgentSkills,
+		SubAgentBackground:     a.SubAgentBackground,
+		SubAgentIsolation:      a.SubAgentIsolation,
+		SubAgentPermissionMode: a.SubAgentPermissionMode,
+		ExtraClaudeArgs:        a.ExtraClaudeArgs,
+	}
+}
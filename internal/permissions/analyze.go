@@ -0,0 +1,178 @@
+package permissions
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Kinds of a Finding.
+const (
+	// FindingShadowedCommand is an allowed command pattern whose every match
+	// is denied.
+	FindingShadowedCommand = "shadowed_command"
+	// FindingBashNotAllowed is a list of allowed commands while Bash, which
+	// runs them, is not an allowed tool.
+	FindingBashNotAllowed = "bash_not_allowed"
+	// FindingWorkspaceOutOfScope is a filesystem scope that leaves out the
+	// agent's workspace.
+	FindingWorkspaceOutOfScope = "workspace_out_of_scope"
+	// FindingSkillToolMissing is a tool a skill needs that is not an
+	// allowed tool.
+	FindingSkillToolMissing = "skill_tool_missing"
+)
+
+// Finding is a rule of a PermissionConfig that contradicts another one or
+// what the agent needs.
+type Finding struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Requirements is what an agent needs from its permissions.
+type Requirements struct {
+	// Workspace is the agent's working directory. It is not checked when
+	// empty.
+	Workspace string
+	// SkillTools lists the tools each skill needs, by skill name.
+	SkillTools map[string][]string
+}
+
+// Analyze returns the rules of config that can never take effect or that
+// keep the agent from working: allowed command patterns whose every match
+// is denied, allowed commands without Bash, a filesystem scope without the
+// workspace and skills needing tools that are not allowed.
+func Analyze(config PermissionConfig, req Requirements) []Finding {
+	var findings []Finding
+	gate := NewGate(config)
+
+	for _, allowed := range config.AllowedCommands {
+		for _, denied := range config.DeniedCommands {
+			// A '*' in a pattern is always a wildcard, so a denied pattern
+			// matching the allowed pattern's text matches every command
+			// the allowed pattern does.
+			if MatchPattern(denied, allowed) {
+				findings = append(findings, Finding{
+					Kind:    FindingShadowedCommand,
+					Message: fmt.Sprintf("allowed command %q is always denied by %q", allowed, denied),
+				})
+				break
+			}
+		}
+	}
+	if len(config.AllowedCommands) > 0 && !gate.isToolAllowed("Bash") {
+		findings = append(findings, Finding{
+			Kind:    FindingBashNotAllowed,
+			Message: "allowed_commands have no effect unless Bash is in allowed_tools",
+		})
+	}
+
+	if req.Workspace != "" && len(config.FilesystemScope) > 0 {
+		if _, ok := config.FilesystemScope.Match(req.Workspace); !ok {
+			findings = append(findings, Finding{
+				Kind:    FindingWorkspaceOutOfScope,
+				Message: fmt.Sprintf("filesystem_scope does not include the workspace %s", req.Workspace),
+			})
+		}
+	}
+
+	skills := make([]string, 0, len(req.SkillTools))
+	for skill := range req.SkillTools {
+		skills = append(skills, skill)
+	}
+	sort.Strings(skills)
+	for _, skill := range skills {
+		var missing []string
+		for _, tool := range req.SkillTools[skill] {
+			if name := ToolName(tool); name != "" && !gate.isToolAllowed(name) && !slices.Contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			findings = append(findings, Finding{
+				Kind:    FindingSkillToolMissing,
+				Message: fmt.Sprintf("skill %s needs tools missing from allowed_tools: %s", skill, strings.Join(missing, ", ")),
+			})
+		}
+	}
+	return findings
+}
+
+// ToolName returns the tool of a tool rule, such as "Bash" for
+// "Bash(git:*)".
+func ToolName(rule string) string {
+	rule = strings.TrimSpace(rule)
+	if i := strings.IndexByte(rule, '('); i >= 0 {
+		rule = rule[:i]
+	}
+	return strings.TrimSpace(rule)
+}
+
+// Suggestion is the smallest change to a PermissionConfig that lets an agent
+// use the tools a set of tool rules names.
+type Suggestion struct {
+	// AddTools and AddCommands are the tools and command patterns added.
+	AddTools    []string `json:"add_tools"`
+	AddCommands []string `json:"add_commands"`
+	// Config is the configuration with the additions merged in.
+	Config PermissionConfig `json:"permissions"`
+	// Findings are the problems left in Config, such as a needed command
+	// that a denied pattern blocks.
+	Findings []Finding `json:"findings"`
+}
+
+// Suggest returns the tools and commands to add to config so that it allows
+// every tool rule in rules, such as "Read" or "Bash(git add:*)". A command
+// is only added when config limits the commands Bash may run; denied
+// commands are never removed.
+func Suggest(config PermissionConfig, rules []string) Suggestion {
+	merged := config
+	merged.AllowedTools = slices.Clone(config.AllowedTools)
+	merged.AllowedCommands = slices.Clone(config.AllowedCommands)
+	s := Suggestion{AddTools: []string{}, AddCommands: []string{}}
+
+	for _, rule := range rules {
+		tool := ToolName(rule)
+		if tool == "" {
+			continue
+		}
+		if !slices.Contains(merged.AllowedTools, tool) {
+			merged.AllowedTools = append(merged.AllowedTools, tool)
+			s.AddTools = append(s.AddTools, tool)
+		}
+		command := ruleCommand(rule)
+		if tool != "Bash" || command == "" || len(config.AllowedCommands) == 0 {
+			continue
+		}
+		covered := slices.ContainsFunc(merged.AllowedCommands, func(pattern string) bool {
+			return MatchPattern(pattern, command)
+		})
+		if !covered {
+			merged.AllowedCommands = append(merged.AllowedCommands, command)
+			s.AddCommands = append(s.AddCommands, command)
+		}
+	}
+	s.Config = merged
+	s.Findings = Analyze(merged, Requirements{})
+	if s.Findings == nil {
+		s.Findings = []Finding{}
+	}
+	return s
+}
+
+// ruleCommand returns the command pattern of a tool rule's specifier, such
+// as "git add*" for "Bash(git add:*)", or "" when the rule has none.
+func ruleCommand(rule string) string {
+	rule = strings.TrimSpace(rule)
+	open := strings.IndexByte(rule, '(')
+	if open < 0 || !strings.HasSuffix(rule, ")") {
+		return ""
+	}
+	command := strings.TrimSpace(rule[open+1 : len(rule)-1])
+	// A trailing ":*" is a prefix match.
+	if prefix, ok := strings.CutSuffix(command, ":*"); ok {
+		command = prefix + "*"
+	}
+	return command
+}
@@ -3,10 +3,10 @@ package permissions
 
 // PermissionConfig defines what tools, commands, and paths an agent is allowed to use.
 type PermissionConfig struct {
-	AllowedTools    []string `json:"allowed_tools"`
-	AllowedCommands []string `json:"allowed_commands"`
-	DeniedCommands  []string `json:"denied_commands"`
-	FilesystemScope string   `json:"filesystem_scope"`
+	AllowedTools    []string        `json:"allowed_tools"`
+	AllowedCommands []string        `json:"allowed_commands"`
+	DeniedCommands  []string        `json:"denied_commands"`
+	FilesystemScope FilesystemScope `json:"filesystem_scope"`
 }
 
 // Decision represents the outcome of a permission evaluation.
@@ -35,13 +35,35 @@ func NewGate(config PermissionConfig) *Gate {
 	return &Gate{config: config}
 }
 
+// ReadOnly returns a gate that also denies every tool that is not read-only,
+// used while a plan waits for approval. A nil gate, which checks nothing,
+// yields a gate that allows exactly the read-only tools.
+func (g *Gate) ReadOnly() *Gate {
+	var config PermissionConfig
+	if g != nil {
+		config = g.config
+		config.AllowedTools = nil
+		for _, t := range g.config.AllowedTools {
+			if IsReadOnlyTool(t) {
+				config.AllowedTools = append(config.AllowedTools, t)
+			}
+		}
+	} else {
+		for t := range readOnlyTools {
+			config.AllowedTools = append(config.AllowedTools, t)
+		}
+	}
+	return &Gate{config: config}
+}
+
 // Evaluate checks whether the given tool, command, and filesystem paths are permitted.
 //
 // Evaluation order:
 //  1. Tool must be in AllowedTools.
 //  2. Command must NOT match any DeniedCommands pattern (deny takes precedence).
 //  3. Command must match at least one AllowedCommands pattern (if AllowedCommands is non-empty).
-//  4. All paths must be within FilesystemScope.
+//  4. All paths must be within FilesystemScope, and write tools are denied on
+//     paths whose most specific scope entry is read-only.
 func (g *Gate) Evaluate(toolName string, command string, paths []string) Decision {
 	// Step 1: check tool allowlist.
 	if !g.isToolAllowed(toolName) {
@@ -72,11 +94,15 @@ func (g *Gate) Evaluate(toolName string, command string, paths []string) Decisio
 	}
 
 	// Step 4: check filesystem scope.
-	if g.config.FilesystemScope != "" {
+	if len(g.config.FilesystemScope) > 0 {
 		for _, p := range paths {
-			if !IsPathInScope(p, g.config.FilesystemScope) {
+			entry, ok := g.config.FilesystemScope.Match(p)
+			if !ok {
 				return Deny("path outside allowed scope: " + p)
 			}
+			if entry.Mode == ModeReadOnly && IsWriteTool(toolName) {
+				return Deny("path is read-only: " + p)
+			}
 		}
 	}
 
@@ -1,7 +1,12 @@
 package permissions
 
 import (
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestGate_Evaluate_AllowsPermittedTool(t *testing.T) {
@@ -115,7 +120,7 @@ func TestGate_Evaluate_EmptyCommandSkipsCommandChecks(t *testing.T) {
 func TestGate_Evaluate_FilesystemScope(t *testing.T) {
 	gate := NewGate(PermissionConfig{
 		AllowedTools:    []string{"Read", "Write"},
-		FilesystemScope: "/workspace/terraform",
+		FilesystemScope: FilesystemScope{{Path: "/workspace/terraform", Mode: ModeReadWrite}},
 	})
 
 	tests := []struct {
@@ -143,7 +148,7 @@ func TestGate_Evaluate_FilesystemScope(t *testing.T) {
 func TestGate_Evaluate_PathTraversalAttack(t *testing.T) {
 	gate := NewGate(PermissionConfig{
 		AllowedTools:    []string{"Read"},
-		FilesystemScope: "/workspace",
+		FilesystemScope: FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}},
 	})
 
 	attacks := []string{
@@ -164,7 +169,7 @@ func TestGate_Evaluate_PathTraversalAttack(t *testing.T) {
 func TestGate_Evaluate_MultiplePathsAllMustBeInScope(t *testing.T) {
 	gate := NewGate(PermissionConfig{
 		AllowedTools:    []string{"Write"},
-		FilesystemScope: "/workspace",
+		FilesystemScope: FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}},
 	})
 
 	// One path in scope, one out of scope.
@@ -179,7 +184,7 @@ func TestGate_Evaluate_FullPipeline(t *testing.T) {
 		AllowedTools:    []string{"Bash", "Read", "Write"},
 		AllowedCommands: []string{"terraform *", "kubectl get *"},
 		DeniedCommands:  []string{"terraform destroy *", "kubectl delete *"},
-		FilesystemScope: "/workspace",
+		FilesystemScope: FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}},
 	})
 
 	tests := []struct {
@@ -267,3 +272,234 @@ func TestIsPathInScope(t *testing.T) {
 		}
 	}
 }
+
+func TestGate_Evaluate_ReadOnlyScope(t *testing.T) {
+	gate := NewGate(PermissionConfig{
+		AllowedTools: []string{"Read", "Write", "Edit"},
+		FilesystemScope: FilesystemScope{
+			{Path: "/workspace", Mode: ModeReadWrite},
+			{Path: "/workspace/vendor", Mode: ModeReadOnly},
+			{Path: "/docs", Mode: ModeReadOnly},
+		},
+	})
+
+	tests := []struct {
+		tool    string
+		path    string
+		allowed bool
+	}{
+		{"Write", "/workspace/main.go", true},
+		{"Read", "/workspace/vendor/lib.go", true},
+		{"Edit", "/workspace/vendor/lib.go", false},
+		{"Write", "/workspace/vendor/new.go", false},
+		{"Read", "/docs/guide.md", true},
+		{"Edit", "/docs/guide.md", false},
+		{"Read", "/etc/passwd", false},
+	}
+
+	for _, tt := range tests {
+		d := gate.Evaluate(tt.tool, "", []string{tt.path})
+		if d.Allowed != tt.allowed {
+			t.Errorf("%s %s: expected allowed=%v, got allowed=%v (reason: %s)",
+				tt.tool, tt.path, tt.allowed, d.Allowed, d.Reason)
+		}
+	}
+}
+
+func TestFilesystemScope_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  FilesystemScope
+	}{
+		{"legacy string", `"/workspace"`, FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}}},
+		{"empty string", `""`, nil},
+		{"list", `[{"path":"/workspace","mode":"rw"},{"path":"/data","mode":"ro"}]`,
+			FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}, {Path: "/data", Mode: ModeReadOnly}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg PermissionConfig
+			if err := json.Unmarshal([]byte(`{"filesystem_scope":`+tt.input+`}`), &cfg); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(cfg.FilesystemScope, tt.want) {
+				t.Errorf("got %+v, want %+v", cfg.FilesystemScope, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilesystemScope_UnmarshalYAML(t *testing.T) {
+	var legacy struct {
+		Scope FilesystemScope `yaml:"filesystem_scope"`
+	}
+	if err := yaml.Unmarshal([]byte("filesystem_scope: /workspace\n"), &legacy); err != nil {
+		t.Fatalf("unmarshal legacy: %v", err)
+	}
+	if len(legacy.Scope) != 1 || legacy.Scope[0].Path != "/workspace" || legacy.Scope[0].Mode != ModeReadWrite {
+		t.Errorf("legacy: got %+v", legacy.Scope)
+	}
+
+	var list struct {
+		Scope FilesystemScope `yaml:"filesystem_scope"`
+	}
+	data := "filesystem_scope:\n  - path: /workspace\n    mode: rw\n  - path: /data\n    mode: ro\n"
+	if err := yaml.Unmarshal([]byte(data), &list); err != nil {
+		t.Fatalf("unmarshal list: %v", err)
+	}
+	if len(list.Scope) != 2 || list.Scope[1].Mode != ModeReadOnly {
+		t.Errorf("list: got %+v", list.Scope)
+	}
+}
+
+func TestParseFilesystemScope(t *testing.T) {
+	scope, err := ParseFilesystemScope("/workspace, /data:ro, /cache:rw")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := FilesystemScope{
+		{Path: "/workspace", Mode: ModeReadWrite},
+		{Path: "/data", Mode: ModeReadOnly},
+		{Path: "/cache", Mode: ModeReadWrite},
+	}
+	if !reflect.DeepEqual(scope, want) {
+		t.Errorf("got %+v, want %+v", scope, want)
+	}
+
+	if _, err := ParseFilesystemScope("relative/path"); err == nil {
+		t.Error("expected error for relative path")
+	}
+}
+
+func TestFilesystemScope_Validate(t *testing.T) {
+	if err := (FilesystemScope{{Path: "/workspace", Mode: "rx"}}).Validate(); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+	if err := (FilesystemScope{{Path: "/workspace"}}).Validate(); err != nil {
+		t.Errorf("empty mode should be accepted: %v", err)
+	}
+}
+
+func TestNormalizePermissionsJSON(t *testing.T) {
+	out, err := NormalizePermissionsJSON([]byte(`{"allowed_tools":["Read"],"filesystem_scope":"/workspace"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{"allowed_tools":["Read"],"filesystem_scope":[{"path":"/workspace","mode":"rw"}]}`
+	if string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+
+	if out, err := NormalizePermissionsJSON([]byte("null")); err != nil || string(out) != "null" {
+		t.Errorf("null: got %s, %v", out, err)
+	}
+
+	if _, err := NormalizePermissionsJSON([]byte(`{"filesystem_scope":[{"path":"/w","mode":"x"}]}`)); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
+
+func TestGate_ReadOnly(t *testing.T) {
+	gate := NewGate(PermissionConfig{
+		AllowedTools:    []string{"Bash", "Read", "Edit", "Grep"},
+		FilesystemScope: FilesystemScope{{Path: "/workspace", Mode: ModeReadWrite}},
+	}).ReadOnly()
+
+	for _, tool := range []string{"Bash", "Edit", "Write"} {
+		if d := gate.Evaluate(tool, "", nil); d.Allowed {
+			t.Errorf("%s: expected denied while read-only", tool)
+		}
+	}
+	if d := gate.Evaluate("Read", "", []string{"/workspace/main.go"}); !d.Allowed {
+		t.Errorf("Read: expected allowed, got denied: %s", d.Reason)
+	}
+	// The filesystem scope still applies.
+	if d := gate.Evaluate("Grep", "", []string{"/etc/passwd"}); d.Allowed {
+		t.Error("Grep outside scope: expected denied")
+	}
+	// Tools the agent was never allowed stay denied.
+	if d := gate.Evaluate("Glob", "", nil); d.Allowed {
+		t.Error("Glob: expected denied, it is not in AllowedTools")
+	}
+
+	var none *Gate
+	if d := none.ReadOnly().Evaluate("Glob", "", nil); !d.Allowed {
+		t.Errorf("nil gate Glob: expected allowed, got denied: %s", d.Reason)
+	}
+	if d := none.ReadOnly().Evaluate("Bash", "", nil); d.Allowed {
+		t.Error("nil gate Bash: expected denied")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	config := PermissionConfig{
+		AllowedTools:    []string{"Bash", "Read"},
+		AllowedCommands: []string{"git push *", "rm -rf *", "ls *"},
+		DeniedCommands:  []string{"git push*", "rm *"},
+		FilesystemScope: FilesystemScope{{Path: "/data", Mode: ModeReadWrite}},
+	}
+	got := Analyze(config, Requirements{
+		Workspace: "/workspace",
+		SkillTools: map[string][]string{
+			"lint":   {"Read", "Bash(npm run lint:*)"},
+			"deploy": {"Bash(kubectl:*)", "Write", "Edit", "Write"},
+		},
+	})
+	want := []Finding{
+		{FindingShadowedCommand, `allowed command "git push *" is always denied by "git push*"`},
+		{FindingShadowedCommand, `allowed command "rm -rf *" is always denied by "rm *"`},
+		{FindingWorkspaceOutOfScope, "filesystem_scope does not include the workspace /workspace"},
+		{FindingSkillToolMissing, "skill deploy needs tools missing from allowed_tools: Write, Edit"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings:\n got %+v\nwant %+v", got, want)
+	}
+
+	// The denied pattern only covers some of the allowed commands, and an
+	// empty scope allows every path.
+	config = PermissionConfig{
+		AllowedTools:    []string{"Bash"},
+		AllowedCommands: []string{"git *"},
+		DeniedCommands:  []string{"git push*"},
+	}
+	if got := Analyze(config, Requirements{Workspace: "/workspace"}); len(got) != 0 {
+		t.Errorf("consistent config: got %+v", got)
+	}
+
+	config = PermissionConfig{AllowedTools: []string{"Read"}, AllowedCommands: []string{"ls"}}
+	if got := Analyze(config, Requirements{}); len(got) != 1 || got[0].Kind != FindingBashNotAllowed || !strings.Contains(got[0].Message, "Bash") {
+		t.Errorf("commands without Bash: got %+v", got)
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	config := PermissionConfig{
+		AllowedTools:    []string{"Read"},
+		AllowedCommands: []string{"git *"},
+		DeniedCommands:  []string{"rm *"},
+	}
+	got := Suggest(config, []string{"Read", "Write", "Bash(git add:*)", "Bash(npm run lint:*)", "Bash(rm -rf dist)", "Bash"})
+	if want := []string{"Write", "Bash"}; !reflect.DeepEqual(got.AddTools, want) {
+		t.Errorf("add_tools: got %q, want %q", got.AddTools, want)
+	}
+	if want := []string{"npm run lint*", "rm -rf dist"}; !reflect.DeepEqual(got.AddCommands, want) {
+		t.Errorf("add_commands: got %q, want %q", got.AddCommands, want)
+	}
+	if want := []string{"Read", "Write", "Bash"}; !reflect.DeepEqual(got.Config.AllowedTools, want) {
+		t.Errorf("merged allowed_tools: got %q", got.Config.AllowedTools)
+	}
+	if len(got.Findings) != 1 || got.Findings[0].Kind != FindingShadowedCommand {
+		t.Errorf("findings: got %+v", got.Findings)
+	}
+	if len(config.AllowedTools) != 1 || len(config.AllowedCommands) != 1 {
+		t.Errorf("config was modified: %+v", config)
+	}
+
+	// Without a command allowlist Bash may already run anything.
+	got = Suggest(PermissionConfig{AllowedTools: []string{"Bash"}}, []string{"Bash(git add:*)"})
+	if len(got.AddTools) != 0 || len(got.AddCommands) != 0 || got.Config.AllowedCommands != nil {
+		t.Errorf("no command allowlist: got %+v", got)
+	}
+}
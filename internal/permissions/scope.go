@@ -0,0 +1,193 @@
+package permissions
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Filesystem access modes for a ScopeEntry.
+const (
+	ModeReadOnly  = "ro"
+	ModeReadWrite = "rw"
+)
+
+// writeTools lists the tools that modify files and are therefore blocked on
+// read-only scope entries.
+var writeTools = map[string]bool{
+	"Write":        true,
+	"Edit":         true,
+	"MultiEdit":    true,
+	"NotebookEdit": true,
+}
+
+// IsWriteTool reports whether the tool modifies files.
+func IsWriteTool(toolName string) bool {
+	return writeTools[toolName]
+}
+
+// readOnlyTools lists the tools that only read, which agents may still use
+// while they wait for a plan to be approved.
+var readOnlyTools = map[string]bool{
+	"Read":         true,
+	"Glob":         true,
+	"Grep":         true,
+	"LS":           true,
+	"NotebookRead": true,
+	"WebFetch":     true,
+	"WebSearch":    true,
+	"TodoWrite":    true,
+}
+
+// IsReadOnlyTool reports whether the tool only reads. Bash is not read-only,
+// since its commands can change anything.
+func IsReadOnlyTool(toolName string) bool {
+	return readOnlyTools[toolName]
+}
+
+// ScopeEntry grants access to a directory tree in the given mode.
+type ScopeEntry struct {
+	Path string `json:"path" yaml:"path"`
+	Mode string `json:"mode" yaml:"mode"`
+}
+
+// FilesystemScope is the list of directories an agent may access.
+//
+// For backwards compatibility it also accepts the legacy single-string form
+// (e.g. "/workspace") when decoding JSON or YAML, which is treated as one
+// read-write entry.
+type FilesystemScope []ScopeEntry
+
+// ParseFilesystemScope parses a comma-separated list of "path[:mode]" items,
+// as used by the AGENT_FILESYSTEM_SCOPE environment variable. Items without a
+// mode default to read-write.
+func ParseFilesystemScope(s string) (FilesystemScope, error) {
+	var scope FilesystemScope
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		entry := ScopeEntry{Path: item, Mode: ModeReadWrite}
+		if i := strings.LastIndex(item, ":"); i > 0 {
+			switch item[i+1:] {
+			case ModeReadOnly, ModeReadWrite:
+				entry.Path, entry.Mode = item[:i], item[i+1:]
+			}
+		}
+		scope = append(scope, entry)
+	}
+	return scope, scope.Validate()
+}
+
+// Validate checks that every entry has an absolute path and a known mode.
+// An empty mode is accepted and treated as read-write.
+func (s FilesystemScope) Validate() error {
+	for i, e := range s {
+		if e.Path == "" {
+			return fmt.Errorf("filesystem_scope[%d]: path is required", i)
+		}
+		if !filepath.IsAbs(e.Path) {
+			return fmt.Errorf("filesystem_scope[%d]: path %q must be absolute", i, e.Path)
+		}
+		switch e.Mode {
+		case "", ModeReadOnly, ModeReadWrite:
+		default:
+			return fmt.Errorf("filesystem_scope[%d]: invalid mode %q: must be ro or rw", i, e.Mode)
+		}
+	}
+	return nil
+}
+
+// Match returns the most specific entry whose directory contains path.
+func (s FilesystemScope) Match(path string) (ScopeEntry, bool) {
+	var best ScopeEntry
+	found := false
+	for _, e := range s {
+		if !IsPathInScope(path, e.Path) {
+			continue
+		}
+		if !found || len(filepath.Clean(e.Path)) > len(filepath.Clean(best.Path)) {
+			best = e
+			found = true
+		}
+	}
+	return best, found
+}
+
+// UnmarshalJSON accepts either a list of entries or a legacy single path.
+func (s *FilesystemScope) UnmarshalJSON(data []byte) error {
+	var legacy string
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*s = legacyScope(legacy)
+		return nil
+	}
+	var entries []ScopeEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("filesystem_scope must be a path or a list of {path, mode}: %w", err)
+	}
+	*s = entries
+	return nil
+}
+
+// UnmarshalYAML accepts either a list of entries or a legacy single path.
+func (s *FilesystemScope) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = legacyScope(node.Value)
+		return nil
+	}
+	var entries []ScopeEntry
+	if err := node.Decode(&entries); err != nil {
+		return fmt.Errorf("filesystem_scope must be a path or a list of {path, mode}: %w", err)
+	}
+	*s = entries
+	return nil
+}
+
+func legacyScope(path string) FilesystemScope {
+	if path == "" {
+		return nil
+	}
+	return FilesystemScope{{Path: path, Mode: ModeReadWrite}}
+}
+
+// NormalizePermissionsJSON rewrites the filesystem_scope key of a JSON
+// permissions object into the list form, converting the legacy single-string
+// form, and validates it. Other keys are preserved as-is. Input that is not a
+// JSON object (e.g. "null") is returned unchanged.
+func NormalizePermissionsJSON(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return data, nil
+	}
+	raw, ok := fields["filesystem_scope"]
+	if !ok {
+		return data, nil
+	}
+
+	var scope FilesystemScope
+	if err := json.Unmarshal(raw, &scope); err != nil {
+		return nil, err
+	}
+	if err := scope.Validate(); err != nil {
+		return nil, err
+	}
+	for i := range scope {
+		if scope[i].Mode == "" {
+			scope[i].Mode = ModeReadWrite
+		}
+	}
+	if scope == nil {
+		scope = FilesystemScope{}
+	}
+
+	normalized, err := json.Marshal(scope)
+	if err != nil {
+		return nil, err
+	}
+	fields["filesystem_scope"] = normalized
+	return json.Marshal(fields)
+}
@@ -246,18 +246,25 @@ func (e *Executor) doRequest(action models.PostAction, binding models.PostAction
 
 // applyAuth adds authentication to the request based on the action's auth_type.
 func applyAuth(req *http.Request, action models.PostAction) error {
-	if action.AuthType == "" || action.AuthType == models.PostActionAuthNone {
+	return ApplyAuth(req, action.AuthType, action.AuthConfig)
+}
+
+// ApplyAuth adds authentication to req for the given auth type and its
+// (possibly encrypted) auth_config. It is shared with the custom tool proxy,
+// which stores credentials in the same format.
+func ApplyAuth(req *http.Request, authType string, authConfig models.JSON) error {
+	if authType == "" || authType == models.PostActionAuthNone {
 		return nil
 	}
 
 	var config map[string]string
-	if len(action.AuthConfig) > 0 && string(action.AuthConfig) != "null" {
-		if err := json.Unmarshal(action.AuthConfig, &config); err != nil {
+	if len(authConfig) > 0 && string(authConfig) != "null" {
+		if err := json.Unmarshal(authConfig, &config); err != nil {
 			return fmt.Errorf("parsing auth_config: %w", err)
 		}
 	}
 	if config == nil {
-		return fmt.Errorf("auth_type is %q but auth_config is empty", action.AuthType)
+		return fmt.Errorf("auth_type is %q but auth_config is empty", authType)
 	}
 
 	// Decrypt values that may be encrypted.
@@ -269,7 +276,7 @@ func applyAuth(req *http.Request, action models.PostAction) error {
 		config[k] = decrypted
 	}
 
-	switch action.AuthType {
+	switch authType {
 	case models.PostActionAuthBearer:
 		token := config["token"]
 		if token == "" {
@@ -294,7 +301,7 @@ func applyAuth(req *http.Request, action models.PostAction) error {
 		req.Header.Set(headerName, headerValue)
 
 	default:
-		return fmt.Errorf("unsupported auth_type: %q", action.AuthType)
+		return fmt.Errorf("unsupported auth_type: %q", authType)
 	}
 
 	return nil
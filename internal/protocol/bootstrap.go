@@ -0,0 +1,76 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Bootstrap failure policies. With "block" the agent does not start when
+// the bootstrap script fails; with "warn" it starts and the failure is
+// reported as a validation warning.
+const (
+	BootstrapFailureBlock = "block"
+	BootstrapFailureWarn  = "warn"
+)
+
+// Bootstrap script limits.
+const (
+	DefaultBootstrapTimeout    = 10 * time.Minute
+	MaxBootstrapTimeoutSeconds = 3600
+	MaxBootstrapScriptSize     = 64 * 1024
+)
+
+// BootstrapConfig is a team's environment bootstrap script, run by the
+// leader's sidecar with sh in the workspace before the agent starts, e.g. to
+// install dependencies or toolchains.
+type BootstrapConfig struct {
+	Script string `json:"script" yaml:"script"`
+	// TimeoutSeconds bounds the script run; zero means DefaultBootstrapTimeout.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty" yaml:"timeout_seconds"`
+	// FailurePolicy is BootstrapFailureBlock or BootstrapFailureWarn
+	// (default).
+	FailurePolicy string `json:"failure_policy,omitempty" yaml:"failure_policy"`
+}
+
+// Validate checks the script size, timeout and failure policy.
+func (b BootstrapConfig) Validate() error {
+	if strings.TrimSpace(b.Script) == "" {
+		return fmt.Errorf("script is required")
+	}
+	if len(b.Script) > MaxBootstrapScriptSize {
+		return fmt.Errorf("script exceeds maximum size of %d bytes", MaxBootstrapScriptSize)
+	}
+	if b.TimeoutSeconds < 0 || b.TimeoutSeconds > MaxBootstrapTimeoutSeconds {
+		return fmt.Errorf("timeout_seconds must be between 0 and %d", MaxBootstrapTimeoutSeconds)
+	}
+	switch b.FailurePolicy {
+	case "", BootstrapFailureBlock, BootstrapFailureWarn:
+		return nil
+	}
+	return fmt.Errorf("failure_policy must be one of %s, %s", BootstrapFailureBlock, BootstrapFailureWarn)
+}
+
+// Timeout returns how long the script may run.
+func (b BootstrapConfig) Timeout() time.Duration {
+	if b.TimeoutSeconds == 0 {
+		return DefaultBootstrapTimeout
+	}
+	return time.Duration(b.TimeoutSeconds) * time.Second
+}
+
+// Blocks reports whether a failed script keeps the agent from starting.
+func (b BootstrapConfig) Blocks() bool {
+	return b.FailurePolicy == BootstrapFailureBlock
+}
+
+// BootstrapResult is the payload of the "bootstrap" activity event that
+// reports a bootstrap script run. Output holds the end of the combined
+// stdout and stderr.
+type BootstrapResult struct {
+	ExitCode   int    `json:"exit_code"`
+	TimedOut   bool   `json:"timed_out,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Output     string `json:"output"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
@@ -34,3 +34,26 @@ func TeamActivityChannel(teamName string) (string, error) {
 	}
 	return fmt.Sprintf("team.%s.activity", teamName), nil
 }
+
+// TeamToolsChannel returns the NATS subject on which agents request custom
+// tool invocations from the API. It is outside the team.<name>.> namespace
+// so that the team's JetStream stream does not capture (and acknowledge)
+// the requests.
+func TeamToolsChannel(teamName string) (string, error) {
+	if err := ValidateSubjectToken(teamName); err != nil {
+		return "", fmt.Errorf("invalid team name: %w", err)
+	}
+	return fmt.Sprintf("tools.%s.invoke", teamName), nil
+}
+
+// TeamControlChannel returns the NATS subject on which the API and relays
+// send system commands, such as interrupt and throttle, to the team leader's
+// sidecar. It is outside the team.<name>.> namespace so that commands are
+// delivered at once over core NATS, rather than waiting behind the user
+// messages queued on the leader channel.
+func TeamControlChannel(teamName string) (string, error) {
+	if err := ValidateSubjectToken(teamName); err != nil {
+		return "", fmt.Errorf("invalid team name: %w", err)
+	}
+	return fmt.Sprintf("control.%s.leader", teamName), nil
+}
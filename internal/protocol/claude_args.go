@@ -0,0 +1,113 @@
+package protocol
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// claudeArgValue describes the value a flag allowed in ClaudeExtraArgs takes.
+type claudeArgValue int
+
+const (
+	claudeArgString claudeArgValue = iota
+	claudeArgPositiveInt
+)
+
+// claudeExtraArgs lists the claude CLI flags an agent may add to its
+// invocations. Flags the sidecar sets itself, and flags that change how
+// permissions, sessions or the output format work, are left out.
+var claudeExtraArgs = map[string]claudeArgValue{
+	"--add-dir":             claudeArgString,
+	"--fallback-model":      claudeArgString,
+	"--max-thinking-tokens": claudeArgPositiveInt,
+	"--max-turns":           claudeArgPositiveInt,
+}
+
+// ClaudeExtraArgFlags returns the flags ValidateClaudeExtraArgs accepts, in
+// alphabetical order.
+func ClaudeExtraArgFlags() []string {
+	return slices.Sorted(maps.Keys(claudeExtraArgs))
+}
+
+// ValidateClaudeExtraArgs checks extra arguments for the claude CLI. Each
+// flag must be allowed and be followed by its value, either as the next
+// argument or as --flag=value.
+func ValidateClaudeExtraArgs(args []string) error {
+	for i := 0; i < len(args); i++ {
+		flag, value, inline := strings.Cut(args[i], "=")
+		kind, ok := claudeExtraArgs[flag]
+		if !ok {
+			return fmt.Errorf("claude flag %q is not allowed: must be one of %s",
+				flag, strings.Join(ClaudeExtraArgFlags(), ", "))
+		}
+		if !inline {
+			if i+1 == len(args) {
+				return fmt.Errorf("claude flag %s needs a value", flag)
+			}
+			i++
+			value = args[i]
+		}
+		if value == "" || strings.HasPrefix(value, "-") {
+			return fmt.Errorf("invalid value %q for claude flag %s", value, flag)
+		}
+		if kind == claudeArgPositiveInt {
+			if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+				return fmt.Errorf("invalid value %q for claude flag %s: must be a positive integer", value, flag)
+			}
+		}
+	}
+	return nil
+}
+
+// RunOverrides changes how the agent runs a single user message, without
+// changing the agent. Zero fields keep the agent's own settings.
+type RunOverrides struct {
+	// Model is the claude model for this message, such as "opus" or a full
+	// model ID.
+	Model             string `json:"model,omitempty"`
+	MaxThinkingTokens int    `json:"max_thinking_tokens,omitempty"`
+	// ReadOnly runs the message in claude's plan permission mode, which
+	// lets it read but not edit files or run commands. It is set by the
+	// sidecar for runs that must stop at a plan, never by clients.
+	ReadOnly bool `json:"-"`
+}
+
+// maxOverrideModelLength bounds RunOverrides.Model.
+const maxOverrideModelLength = 100
+
+// IsZero reports whether o overrides nothing.
+func (o RunOverrides) IsZero() bool {
+	return o == RunOverrides{}
+}
+
+// Validate checks that the overrides are safe to pass to the claude CLI.
+func (o RunOverrides) Validate() error {
+	if len(o.Model) > maxOverrideModelLength {
+		return fmt.Errorf("model must be at most %d characters", maxOverrideModelLength)
+	}
+	if strings.HasPrefix(o.Model, "-") || strings.ContainsFunc(o.Model, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-._:[]", r))
+	}) {
+		return fmt.Errorf("invalid model %q", o.Model)
+	}
+	if o.MaxThinkingTokens < 0 {
+		return fmt.Errorf("max_thinking_tokens must not be negative")
+	}
+	return nil
+}
+
+// Args returns the claude CLI flags that apply the overrides, other than
+// the model, which replaces the agent's --model.
+func (o RunOverrides) Args() []string {
+	var args []string
+	if o.MaxThinkingTokens > 0 {
+		args = append(args, "--max-thinking-tokens", strconv.Itoa(o.MaxThinkingTokens))
+	}
+	if o.ReadOnly {
+		args = append(args, "--permission-mode", "plan")
+	}
+	return args
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -34,3 +35,36 @@ func ParsePayload[T any](msg *Message) (*T, error) {
 	}
 	return &result, nil
 }
+
+// ParseSkillConfigs returns the installable skills of an agent's
+// sub_agent_skills, accepting both {repo_url, skill_name} objects and legacy
+// "owner/repo:skill" strings, whose repository defaults to GitHub. Entries
+// missing a repository or skill name are skipped.
+func ParseSkillConfigs(raw json.RawMessage) []SkillConfig {
+	var skills []SkillConfig
+	var configs []SkillConfig
+	if err := json.Unmarshal(raw, &configs); err == nil {
+		for _, s := range configs {
+			if s.RepoURL != "" && s.SkillName != "" {
+				skills = append(skills, s)
+			}
+		}
+		return skills
+	}
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		return nil
+	}
+	for _, s := range names {
+		idx := strings.LastIndex(s, ":")
+		if idx <= 0 || idx == len(s)-1 {
+			continue
+		}
+		repoURL, skillName := s[:idx], s[idx+1:]
+		if !strings.HasPrefix(repoURL, "https://") {
+			repoURL = "https://github.com/" + repoURL
+		}
+		skills = append(skills, SkillConfig{RepoURL: repoURL, SkillName: skillName})
+	}
+	return skills
+}
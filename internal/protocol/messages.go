@@ -3,6 +3,8 @@ package protocol
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -17,6 +19,19 @@ const (
 	TypeContainerValidation  MessageType = "container_validation"
 	TypeSkillStatus          MessageType = "skill_status"
 	TypeMcpStatus            MessageType = "mcp_status"
+	TypeAgentReady           MessageType = "agent_ready"
+	TypeAgentStatus          MessageType = "agent_status"
+	TypeAgentLog             MessageType = "agent_log"
+	TypeDeploymentEvent      MessageType = "deployment_event"
+	TypeToolInvocation       MessageType = "tool_invocation"
+	TypeToolResult           MessageType = "tool_result"
+	TypeConfigUpdate         MessageType = "config_update"
+	TypeUsage                MessageType = "usage"
+	TypeRunQueue             MessageType = "run_queue"
+	TypeMemorySummary        MessageType = "memory_summary"
+	TypeHealthCheck          MessageType = "health_check"
+	TypeQuota                MessageType = "quota"
+	TypeDriftDetected        MessageType = "drift_detected"
 )
 
 // MessageContext carries optional conversation context.
@@ -52,23 +67,95 @@ type UserMessagePayload struct {
 	Source         string    `json:"source,omitempty"`           // "chat", "scheduler", or "webhook"
 	ScheduledRunID string    `json:"scheduled_run_id,omitempty"` // Set when source is "scheduler"
 	WebhookRunID   string    `json:"webhook_run_id,omitempty"`   // Set when source is "webhook"
+	ConversationID string    `json:"conversation_id,omitempty"`  // Conversation the chat message belongs to
+	Sequence       int64     `json:"sequence,omitempty"`         // Per-conversation order of chat messages, starting at 1
+	// ApprovedPlanRunID is set on the message that approves the plan of a
+	// chat run, for teams in plan approval mode (see RunPlan).
+	ApprovedPlanRunID string `json:"approved_plan_run_id,omitempty"`
+	// Overrides, if set, change the model or generation settings for this
+	// message only.
+	Overrides *RunOverrides `json:"overrides,omitempty"`
 }
 
 // LeaderResponsePayload carries the leader's response back to the user.
 type LeaderResponsePayload struct {
-	Status         string `json:"status"` // completed, failed, partial
+	Status         string `json:"status"` // completed, failed, partial, interrupted, awaiting_approval
 	Result         string `json:"result"`
 	Error          string `json:"error,omitempty"`
+	ErrorCode      string `json:"error_code,omitempty"`       // Machine-readable error code from the agent (e.g. "rate_limit_error")
 	ScheduledRunID string `json:"scheduled_run_id,omitempty"` // Correlation ID for scheduled runs
 	WebhookRunID   string `json:"webhook_run_id,omitempty"`   // Correlation ID for webhook runs
+	Plan           *RunPlan `json:"plan,omitempty"`           // Set with status awaiting_approval
+}
+
+// StatusAwaitingApproval is the leader_response status of a chat run whose
+// plan waits for the user's approval before it is carried out.
+const StatusAwaitingApproval = "awaiting_approval"
+
+// RunPlan is the plan a leader in plan approval mode returns for a chat run
+// before it may use tools that make changes.
+type RunPlan struct {
+	RunID string `json:"run_id"` // ID of the user message the plan answers
+	Text  string `json:"text"`
+}
+
+// IsRateLimitError reports whether an agent error code means the AI provider
+// rejected the request for exceeding the account's rate limit.
+func IsRateLimitError(code string) bool {
+	return code == "rate_limit" || code == "rate_limit_error"
 }
 
 // SystemCommandPayload carries a system-level command.
 type SystemCommandPayload struct {
-	Command string            `json:"command"` // shutdown, restart, compact_context
+	Command string            `json:"command"` // shutdown, restart, interrupt, compact_context, throttle, set_telemetry_level
 	Args    map[string]string `json:"args,omitempty"`
 }
 
+// CommandThrottle is the system command a relay sends the leader's sidecar
+// when it falls behind the team's stream. Its "level" arg is a Throttle
+// level, and "ttl_seconds" how long a reduced level lasts unless renewed,
+// so that a sidecar whose relay went away recovers on its own.
+const CommandThrottle = "throttle"
+
+// Throttle levels. At ThrottleReduced the sidecar still publishes every
+// activity event, but without the raw payload of tool results and
+// reasoning, and marks the events it trimmed as throttled.
+const (
+	ThrottleOff     = "off"
+	ThrottleReduced = "reduced"
+)
+
+// DefaultThrottleTTL is how long a reduced throttle level lasts when the
+// command does not set ttl_seconds.
+const DefaultThrottleTTL = time.Minute
+
+// CommandSetTelemetryLevel is the system command that changes which activity
+// events the leader's sidecar publishes while it runs. Its "level" arg is a
+// telemetry level.
+const CommandSetTelemetryLevel = "set_telemetry_level"
+
+// Telemetry levels of a team. At TelemetryMinimal the sidecar only publishes
+// permission denials and, without their raw payload, tool calls and errors.
+// TelemetryNormal publishes every tool call, tool result, reasoning,
+// assistant and system event, and TelemetryVerbose adds the final result of
+// each turn.
+const (
+	TelemetryMinimal = "minimal"
+	TelemetryNormal  = "normal"
+	TelemetryVerbose = "verbose"
+
+	DefaultTelemetryLevel = TelemetryNormal
+)
+
+// ValidTelemetryLevel reports whether level is a known telemetry level.
+func ValidTelemetryLevel(level string) bool {
+	switch level {
+	case TelemetryMinimal, TelemetryNormal, TelemetryVerbose:
+		return true
+	}
+	return false
+}
+
 // ActivityEventPayload carries an intermediate activity event from the Claude
 // Code process (tool calls, assistant messages, sub-agent delegation, etc.).
 type ActivityEventPayload struct {
@@ -77,6 +164,75 @@ type ActivityEventPayload struct {
 	ToolName  string          `json:"tool_name,omitempty"` // Tool name (for tool_use events)
 	Action    string          `json:"action,omitempty"`    // Human-readable action summary
 	Payload   json.RawMessage `json:"payload,omitempty"`   // Raw event data
+	// Throttled is set when the sidecar left out Payload because the relay
+	// asked it to reduce activity (see CommandThrottle).
+	Throttled bool `json:"throttled,omitempty"`
+	// UnknownEvent is set on events of a type the sidecar does not know,
+	// typically added by a newer agent CLI. Payload is the event as the CLI
+	// wrote it.
+	UnknownEvent bool `json:"unknown_event,omitempty"`
+}
+
+// ActivityPermissionDenied is the event type of the activity event an agent
+// publishes when its permission gate refuses a tool call. The payload is a
+// PermissionDeniedPayload.
+const ActivityPermissionDenied = "permission_denied"
+
+// PermissionDeniedPayload describes a tool call refused by the permission
+// gate.
+type PermissionDeniedPayload struct {
+	Command string `json:"command,omitempty"`
+	Reason  string `json:"reason"`
+}
+
+// UsagePayload reports the cost and token usage of one agent turn.
+type UsagePayload struct {
+	AgentName        string  `json:"agent_name"`
+	CostUSD          float64 `json:"cost_usd"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CacheReadTokens  int64   `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int64   `json:"cache_write_tokens,omitempty"`
+}
+
+// Statuses of a QuotaPayload, as reported by the provider.
+const (
+	QuotaAllowed  = "allowed"
+	QuotaWarning  = "allowed_warning"
+	QuotaRejected = "rejected"
+)
+
+// QuotaPayload reports the state of the AI provider's rate limit for the
+// agent's account, as the CLI reads it from the provider's rate-limit
+// headers. Sidecars send it when the state changes.
+type QuotaPayload struct {
+	AgentName string `json:"agent_name"`
+	Status    string `json:"status"`
+	// LimitType is the limit the state is about, such as "five_hour".
+	LimitType string `json:"limit_type,omitempty"`
+	// Utilization is the fraction of the limit used, when reported.
+	Utilization *float64   `json:"utilization,omitempty"`
+	ResetsAt    *time.Time `json:"resets_at,omitempty"`
+}
+
+// MaxConcurrentRunsLimit is the highest max_concurrent_runs of a team. Zero
+// means no limit.
+const MaxConcurrentRunsLimit = 100
+
+// RunQueuePayload reports the leader sidecar's run queue whenever it changes.
+// Runs share the agent's session, so only one runs at a time; the others
+// wait in order. Waiting lists the message IDs of user messages not yet
+// started: first those the sidecar accepted, then the Parked ones that
+// arrived while max_concurrent_runs runs were already in flight. Messages
+// the sidecar has not received yet wait in the team stream and are not
+// listed.
+type RunQueuePayload struct {
+	AgentName string `json:"agent_name"`
+	// MaxConcurrentRuns is the team's limit, or 0 when it has none.
+	MaxConcurrentRuns int      `json:"max_concurrent_runs"`
+	Running           string   `json:"running,omitempty"`
+	Waiting           []string `json:"waiting"`
+	Parked            int      `json:"parked"`
 }
 
 // ValidationCheckStatus represents the result status of a single validation check.
@@ -95,17 +251,160 @@ type ValidationCheck struct {
 	Message string                `json:"message"` // Human-readable description
 }
 
+// ToolVersions reports the versions of the tools installed in an agent
+// container. A field is empty when the tool is missing or its version could
+// not be read.
+type ToolVersions struct {
+	Claude   string `json:"claude,omitempty"`   // Claude Code CLI
+	OpenCode string `json:"opencode,omitempty"` // OpenCode CLI
+	Node     string `json:"node,omitempty"`
+	Skills   string `json:"skills,omitempty"` // skills package manager CLI
+}
+
 // ContainerValidationPayload carries the results of post-setup container validation.
 type ContainerValidationPayload struct {
 	AgentName string            `json:"agent_name"`
 	Checks    []ValidationCheck `json:"checks"`
 	Summary   string            `json:"summary"` // Overall summary (e.g., "3 ok, 1 warning, 0 errors")
+	Versions  *ToolVersions     `json:"versions,omitempty"`
+}
+
+// AgentReadyPayload announces that an agent's bridge is subscribed and the
+// agent can accept user messages.
+type AgentReadyPayload struct {
+	AgentName string `json:"agent_name"`
+	Role      string `json:"role"`
+	Provider  string `json:"provider"`
+}
+
+// AgentStatusShuttingDown is reported by a sidecar that received SIGTERM and
+// has finished draining.
+const AgentStatusShuttingDown = "shutting_down"
+
+// AgentStatusPayload reports a lifecycle change of an agent's sidecar.
+type AgentStatusPayload struct {
+	AgentName string `json:"agent_name"`
+	Status    string `json:"status"`
+	// Drained is false when the grace period elapsed with a run in flight.
+	Drained bool `json:"drained"`
+}
+
+// MemorySummaryPayload carries the context the leader chose to keep when
+// its team stopped, for the team's next deployment.
+type MemorySummaryPayload struct {
+	AgentName string `json:"agent_name"`
+	Summary   string `json:"summary"`
+}
+
+// HealthCheckPayload carries the results of a sidecar's periodic self-check
+// of its CLI, credentials and disk. It is sent when the agent becomes
+// degraded, on every check while it stays so, and once when it recovers.
+type HealthCheckPayload struct {
+	AgentName string            `json:"agent_name"`
+	Checks    []ValidationCheck `json:"checks"`
+	Summary   string            `json:"summary"`
+	Degraded  bool              `json:"degraded"` // Set when any check failed with an error.
+}
+
+// Deployment event actions and statuses.
+const (
+	DeploymentActionRestart  = "restart"
+	DeploymentActionUpgrade  = "upgrade"
+	DeploymentActionRollback = "rollback"
+
+	DeploymentStatusStarted   = "started"
+	DeploymentStatusSucceeded = "succeeded"
+	DeploymentStatusFailed    = "failed"
+)
+
+// DeploymentEventPayload records a step of a container lifecycle operation
+// started through the API, such as restarting the leader.
+type DeploymentEventPayload struct {
+	AgentName   string `json:"agent_name"`
+	Action      string `json:"action"`
+	Status      string `json:"status"`
+	ContainerID string `json:"container_id,omitempty"`
+	Image       string `json:"image,omitempty"`
+	PullImage   bool   `json:"pull_image,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Observed statuses of a drift_detected message besides the runtime's:
+// DriftObservedMissing for a container that no longer exists, and
+// DriftObservedRestartLimit for one restarted more often than its team's
+// restart policy allows.
+const (
+	DriftObservedMissing      = "missing"
+	DriftObservedRestartLimit = "restart_limit"
+)
+
+// DriftDetectedPayload records that an agent's container changed outside
+// the API, such as a leader container removed while its team was running,
+// and the status the team was moved to.
+type DriftDetectedPayload struct {
+	AgentName   string `json:"agent_name"`
+	ContainerID string `json:"container_id"`
+	// Expected is the container status the API recorded and Observed the
+	// one the runtime reports, DriftObservedMissing or
+	// DriftObservedRestartLimit.
+	Expected   string `json:"expected"`
+	Observed   string `json:"observed"`
+	TeamStatus string `json:"team_status"`
+	Message    string `json:"message"`
+	// RestartCount is how many times the runtime restarted the container.
+	RestartCount int `json:"restart_count,omitempty"`
+}
+
+// ConfigFile is a generated agent config file. Path is relative to the
+// agent's workspace, e.g. ".claude/agents/backend.md".
+type ConfigFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// ConfigUpdatePayload carries regenerated config files for the leader's
+// sidecar to write into its workspace, where the running agent picks them up.
+type ConfigUpdatePayload struct {
+	Files []ConfigFile `json:"files"`
+}
+
+// ValidateConfigFilePath checks that path names an instructions file the API
+// may overwrite through a config update: the leader's CLAUDE.md or AGENTS.MD,
+// or a sub-agent file under .claude/agents or .opencode/agents.
+func ValidateConfigFilePath(path string) error {
+	switch path {
+	case ".claude/CLAUDE.md", ".opencode/AGENTS.MD":
+		return nil
+	}
+	for _, dir := range []string{".claude/agents/", ".opencode/agents/"} {
+		name, ok := strings.CutPrefix(path, dir)
+		if ok && len(name) > len(".md") && strings.HasSuffix(name, ".md") && !strings.ContainsAny(name, `/\`) {
+			return nil
+		}
+	}
+	return fmt.Errorf("config file path not allowed: %q", path)
+}
+
+// AgentLogEntry is a single sidecar log record forwarded to the API.
+type AgentLogEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"` // WARN or ERROR
+	Message string                 `json:"message"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// AgentLogPayload carries a batch of sidecar log records. Dropped counts
+// records discarded by the sidecar's rate limit since the previous batch.
+type AgentLogPayload struct {
+	AgentName string          `json:"agent_name"`
+	Entries   []AgentLogEntry `json:"entries"`
+	Dropped   int             `json:"dropped,omitempty"`
 }
 
 // SkillConfig represents a skill to install, with the repository URL and skill name as separate fields.
 type SkillConfig struct {
-	RepoURL   string `json:"repo_url"`
-	SkillName string `json:"skill_name"`
+	RepoURL   string `json:"repo_url" yaml:"repo_url"`
+	SkillName string `json:"skill_name" yaml:"skill_name"`
 }
 
 // SkillInstallResult represents the installation outcome for a single skill package.
@@ -113,6 +412,9 @@ type SkillInstallResult struct {
 	Package string `json:"package"`
 	Status  string `json:"status"` // installed, failed
 	Error   string `json:"error,omitempty"`
+	// RequiredTools lists the tools the skill's SKILL.md declares in its
+	// allowed-tools field.
+	RequiredTools []string `json:"required_tools,omitempty"`
 }
 
 // SkillStatusPayload carries per-skill installation results from the sidecar.
@@ -151,3 +453,40 @@ type McpStatusPayload struct {
 	Servers   []McpServerStatus `json:"servers"`
 	Summary   string            `json:"summary"`
 }
+
+// CustomToolsServerName is the MCP server name under which the sidecar
+// exposes the organization's custom tools, so agents see them as
+// mcp__agentcrew-tools__<tool>.
+const CustomToolsServerName = "agentcrew-tools"
+
+// CustomToolPermission is the tool name operators add to allowed_tools to
+// let an agent call a custom tool. It matches the name the agent CLI gives
+// MCP tools, so the bridge, the shim and the API check the same entry.
+func CustomToolPermission(name string) string {
+	return "mcp__" + CustomToolsServerName + "__" + name
+}
+
+// CustomToolSpec describes a custom tool to the agent. It carries no
+// endpoint or credentials: invocations are proxied by the API.
+type CustomToolSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema,omitempty"` // JSON Schema of the arguments
+}
+
+// ToolInvocationPayload asks the API to call a custom tool on behalf of an
+// agent. It is sent as a NATS request on TeamToolsChannel.
+type ToolInvocationPayload struct {
+	AgentName string          `json:"agent_name"`
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+// ToolResultPayload is the API's reply to a ToolInvocationPayload. IsError
+// is set when the tool could not be called or answered with an HTTP error.
+type ToolResultPayload struct {
+	Tool       string `json:"tool"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Result     string `json:"result"`
+	IsError    bool   `json:"is_error"`
+}
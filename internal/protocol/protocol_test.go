@@ -2,7 +2,10 @@ package protocol
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewMessage(t *testing.T) {
@@ -168,6 +171,22 @@ func TestChannels(t *testing.T) {
 	if got != "team.myteam.activity" {
 		t.Errorf("got %q, want %q", got, "team.myteam.activity")
 	}
+
+	got, err = TeamToolsChannel("myteam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "tools.myteam.invoke" {
+		t.Errorf("got %q, want %q", got, "tools.myteam.invoke")
+	}
+
+	got, err = TeamControlChannel("myteam")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "control.myteam.leader" {
+		t.Errorf("got %q, want %q", got, "control.myteam.leader")
+	}
 }
 
 func TestChannels_InvalidNames(t *testing.T) {
@@ -192,6 +211,14 @@ func TestChannels_InvalidNames(t *testing.T) {
 			if err == nil {
 				t.Error("expected error for invalid team name (activity)")
 			}
+			_, err = TeamToolsChannel(tt.teamName)
+			if err == nil {
+				t.Error("expected error for invalid team name (tools)")
+			}
+			_, err = TeamControlChannel(tt.teamName)
+			if err == nil {
+				t.Error("expected error for invalid team name (control)")
+			}
 		})
 	}
 }
@@ -349,3 +376,183 @@ func TestValidationCheckStatus_Values(t *testing.T) {
 		t.Errorf("ValidationError: got %q, want 'error'", ValidationError)
 	}
 }
+
+func TestValidateConfigFilePath(t *testing.T) {
+	for _, path := range []string{
+		".claude/CLAUDE.md",
+		".opencode/AGENTS.MD",
+		".claude/agents/backend.md",
+		".opencode/agents/qa-bot.md",
+	} {
+		if err := ValidateConfigFilePath(path); err != nil {
+			t.Errorf("ValidateConfigFilePath(%q) = %v, want nil", path, err)
+		}
+	}
+	for _, path := range []string{
+		"",
+		".mcp.json",
+		".claude/settings.json",
+		".claude/agents/.md",
+		".claude/agents/backend.txt",
+		".claude/agents/../../etc/passwd.md",
+		`.claude/agents/a\b.md`,
+		"/workspace/.claude/CLAUDE.md",
+	} {
+		if err := ValidateConfigFilePath(path); err == nil {
+			t.Errorf("ValidateConfigFilePath(%q) = nil, want an error", path)
+		}
+	}
+}
+
+func TestBootstrapConfig_Validate(t *testing.T) {
+	valid := []BootstrapConfig{
+		{Script: "npm ci"},
+		{Script: "make deps", TimeoutSeconds: MaxBootstrapTimeoutSeconds, FailurePolicy: BootstrapFailureBlock},
+		{Script: "pip install -r requirements.txt", FailurePolicy: BootstrapFailureWarn},
+	}
+	for _, b := range valid {
+		if err := b.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v, want nil", b, err)
+		}
+	}
+	invalid := []BootstrapConfig{
+		{Script: "  "},
+		{Script: strings.Repeat("x", MaxBootstrapScriptSize+1)},
+		{Script: "npm ci", TimeoutSeconds: -1},
+		{Script: "npm ci", TimeoutSeconds: MaxBootstrapTimeoutSeconds + 1},
+		{Script: "npm ci", FailurePolicy: "ignore"},
+	}
+	for _, b := range invalid {
+		if err := b.Validate(); err == nil {
+			t.Errorf("Validate(script of %d bytes, timeout %d, policy %q) = nil, want error",
+				len(b.Script), b.TimeoutSeconds, b.FailurePolicy)
+		}
+	}
+
+	if got := (BootstrapConfig{Script: "npm ci"}).Timeout(); got != DefaultBootstrapTimeout {
+		t.Errorf("default timeout = %v, want %v", got, DefaultBootstrapTimeout)
+	}
+	if got := (BootstrapConfig{Script: "npm ci", TimeoutSeconds: 90}).Timeout(); got != 90*time.Second {
+		t.Errorf("timeout = %v, want 90s", got)
+	}
+}
+
+func TestValidateClaudeExtraArgs(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"--max-thinking-tokens", "8000"},
+		{"--add-dir", "/data", "--add-dir=/shared", "--max-turns=20"},
+		{"--fallback-model", "claude-sonnet-4-20250514"},
+	}
+	for _, args := range valid {
+		if err := ValidateClaudeExtraArgs(args); err != nil {
+			t.Errorf("ValidateClaudeExtraArgs(%q) = %v, want nil", args, err)
+		}
+	}
+	invalid := [][]string{
+		{"--dangerously-skip-permissions"},
+		{"--output-format", "json"},
+		{"--add-dir"},
+		{"--add-dir", "--resume", "sess-1"},
+		{"--add-dir="},
+		{"--max-turns", "0"},
+		{"--max-thinking-tokens=lots"},
+		{"8000"},
+	}
+	for _, args := range invalid {
+		if err := ValidateClaudeExtraArgs(args); err == nil {
+			t.Errorf("ValidateClaudeExtraArgs(%q) = nil, want error", args)
+		}
+	}
+}
+
+func TestRunOverrides(t *testing.T) {
+	valid := []RunOverrides{
+		{},
+		{Model: "opus"},
+		{Model: "claude-sonnet-4-5-20250929[1m]", MaxThinkingTokens: 8000},
+	}
+	for _, o := range valid {
+		if err := o.Validate(); err != nil {
+			t.Errorf("%+v: Validate() = %v, want nil", o, err)
+		}
+	}
+	invalid := []RunOverrides{
+		{Model: "--resume"},
+		{Model: "opus --dangerously-skip-permissions"},
+		{Model: strings.Repeat("a", 101)},
+		{MaxThinkingTokens: -1},
+	}
+	for _, o := range invalid {
+		if err := o.Validate(); err == nil {
+			t.Errorf("%+v: Validate() = nil, want error", o)
+		}
+	}
+	if args := (RunOverrides{Model: "opus", MaxThinkingTokens: 8000}).Args(); strings.Join(args, " ") != "--max-thinking-tokens 8000" {
+		t.Errorf("Args: got %q", args)
+	}
+}
+
+func TestValidateExtraWorkspaces(t *testing.T) {
+	valid := []ExtraWorkspace{{Name: "docs", HostPath: "/srv/docs", ReadOnly: true}, {Name: "lib_2", HostPath: "/home/me/lib"}}
+	if err := ValidateExtraWorkspaces(valid); err != nil {
+		t.Errorf("ValidateExtraWorkspaces(%+v) = %v, want nil", valid, err)
+	}
+	if got := ExtraWorkspaceScope(valid); got != "/workspaces/docs:ro,/workspaces/lib_2:rw" {
+		t.Errorf("ExtraWorkspaceScope = %q", got)
+	}
+
+	invalid := [][]ExtraWorkspace{
+		{{Name: "", HostPath: "/srv/docs"}},
+		{{Name: "../etc", HostPath: "/srv/docs"}},
+		{{Name: "Docs", HostPath: "/srv/docs"}},
+		{{Name: "docs", HostPath: "srv/docs"}},
+		{{Name: "docs", HostPath: "/srv/a"}, {Name: "docs", HostPath: "/srv/b"}},
+	}
+	for _, ws := range invalid {
+		if err := ValidateExtraWorkspaces(ws); err == nil {
+			t.Errorf("ValidateExtraWorkspaces(%+v) = nil, want error", ws)
+		}
+	}
+}
+
+func TestWorkspaceScaffold_Validate(t *testing.T) {
+	valid := WorkspaceScaffold{
+		Template: "monorepo",
+		Dirs:     []string{"apps", "packages/shared"},
+		Files:    []ScaffoldFile{{Path: "README.md", Content: "# Team"}, {Path: "docs/index.md"}},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("valid scaffold: %v", err)
+	}
+	for _, p := range []string{"", "/etc/passwd", "../outside", "docs/../../x", "./README.md", ".."} {
+		s := WorkspaceScaffold{Files: []ScaffoldFile{{Path: p}}}
+		if err := s.Validate(); err == nil {
+			t.Errorf("path %q: expected an error", p)
+		}
+	}
+	big := WorkspaceScaffold{Files: []ScaffoldFile{{Path: "big.txt", Content: strings.Repeat("x", MaxWorkspaceScaffoldSize+1)}}}
+	if err := big.Validate(); err == nil {
+		t.Error("oversized scaffold: expected an error")
+	}
+}
+
+func TestParseSkillConfigs(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []SkillConfig
+	}{
+		{`[{"repo_url":"https://github.com/acme/skills","skill_name":"pdf"},{"repo_url":"","skill_name":"x"}]`,
+			[]SkillConfig{{RepoURL: "https://github.com/acme/skills", SkillName: "pdf"}}},
+		{`["acme/skills:pdf","https://git.example.com/tools:lint","broken",":x","y:"]`,
+			[]SkillConfig{{RepoURL: "https://github.com/acme/skills", SkillName: "pdf"}, {RepoURL: "https://git.example.com/tools", SkillName: "lint"}}},
+		{`null`, nil},
+		{`{"not":"a list"}`, nil},
+	}
+	for _, tt := range tests {
+		got := ParseSkillConfigs(json.RawMessage(tt.raw))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSkillConfigs(%s) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package protocol
+
+import "time"
+
+// RestartStateDir is where a sidecar keeps the count of its container's
+// restarts, from which it computes its restart backoff. It outlives the
+// container: Docker keeps a restarted container's filesystem, and the
+// Kubernetes runtime mounts an emptyDir there.
+const RestartStateDir = "/tmp/agentcrew-restarts"
+
+// MaxRestartBackoff caps how long a restarted sidecar waits before
+// starting. It leaves the sidecar most of the runtimes' 5 minute startup
+// window to come up once the wait is over.
+const MaxRestartBackoff = 2 * time.Minute
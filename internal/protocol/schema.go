@@ -0,0 +1,186 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaDialect is the JSON Schema version of the schemas built here.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema needed to describe protocol messages.
+// An empty Schema accepts any value.
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+}
+
+// PayloadSchema documents the payload of one message type.
+type PayloadSchema struct {
+	Type        MessageType `json:"type"`
+	Description string      `json:"description"`
+	Schema      *Schema     `json:"schema"`
+}
+
+// payloadTypes lists the payload of every message type.
+var payloadTypes = []struct {
+	msgType     MessageType
+	payload     interface{}
+	description string
+}{
+	{TypeUserMessage, UserMessagePayload{}, "A chat message from a user, schedule or webhook to the leader."},
+	{TypeLeaderResponse, LeaderResponsePayload{}, "The leader's answer to a user message, sent when the run ends."},
+	{TypeSystemCommand, SystemCommandPayload{}, "A control command for an agent's sidecar."},
+	{TypeActivityEvent, ActivityEventPayload{}, "An intermediate event of a run, such as a tool call or an assistant message."},
+	{TypeContainerValidation, ContainerValidationPayload{}, "The checks an agent's sidecar ran after setting up its container."},
+	{TypeSkillStatus, SkillStatusPayload{}, "The outcome of installing the team's skills."},
+	{TypeMcpStatus, McpStatusPayload{}, "The status of the agent's MCP servers."},
+	{TypeAgentReady, AgentReadyPayload{}, "Sent when an agent can accept user messages."},
+	{TypeAgentStatus, AgentStatusPayload{}, "A lifecycle change of an agent's sidecar."},
+	{TypeAgentLog, AgentLogPayload{}, "Warnings and errors logged by an agent's sidecar."},
+	{TypeDeploymentEvent, DeploymentEventPayload{}, "A step of a container operation started through the API."},
+	{TypeToolInvocation, ToolInvocationPayload{}, "An agent's call of a custom tool."},
+	{TypeToolResult, ToolResultPayload{}, "The API's reply to a tool invocation."},
+	{TypeConfigUpdate, ConfigUpdatePayload{}, "Regenerated instruction files for the leader's workspace."},
+	{TypeUsage, UsagePayload{}, "The cost and token usage of one agent turn."},
+	{TypeRunQueue, RunQueuePayload{}, "The leader's run queue, sent whenever it changes."},
+	{TypeMemorySummary, MemorySummaryPayload{}, "The context the leader kept when its team stopped."},
+	{TypeHealthCheck, HealthCheckPayload{}, "A periodic self-check of an agent's CLI, credentials and disk that found a problem, or its recovery."},
+	{TypeQuota, QuotaPayload{}, "The state of the AI provider's rate limit, sent when it changes."},
+	{TypeDriftDetected, DriftDetectedPayload{}, "An agent container changed outside the API, and the status its team was moved to."},
+}
+
+// schemaEnums lists the values of string types with a fixed set of values.
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(ValidationCheckStatus("")): {string(ValidationOK), string(ValidationWarning), string(ValidationError)},
+}
+
+func init() {
+	types := make([]string, len(payloadTypes))
+	for i, p := range payloadTypes {
+		types[i] = string(p.msgType)
+	}
+	schemaEnums[reflect.TypeOf(MessageType(""))] = types
+}
+
+// MessageSchema returns the schema of the Message envelope. Its payload is
+// described by the PayloadSchemas entry of its type.
+func MessageSchema() *Schema {
+	s := SchemaOf(Message{})
+	s.Schema = SchemaDialect
+	s.Title = "Message"
+	s.Description = "The envelope of every message exchanged with agents. The payload depends on the type."
+	return s
+}
+
+// PayloadSchemas returns the payload schema of every message type.
+func PayloadSchemas() []PayloadSchema {
+	schemas := make([]PayloadSchema, len(payloadTypes))
+	for i, p := range payloadTypes {
+		s := SchemaOf(p.payload)
+		s.Schema = SchemaDialect
+		s.Title = reflect.TypeOf(p.payload).Name()
+		schemas[i] = PayloadSchema{Type: p.msgType, Description: p.description, Schema: s}
+	}
+	return schemas
+}
+
+// SchemaOf returns the JSON schema of v's type as encoding/json marshals
+// it. Fields without omitempty are required.
+func SchemaOf(v interface{}) *Schema {
+	return schemaFor(reflect.TypeOf(v), make(map[reflect.Type]bool))
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaFor builds the schema of t. seen holds the structs being built, so
+// a recursive type is described as any value where it repeats.
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	if values, ok := schemaEnums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaFor(t.Elem(), seen)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaFor(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return &Schema{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFields(s, t, seen)
+		return s
+	}
+	return &Schema{}
+}
+
+// addFields adds the JSON fields of struct t to s, including those of
+// embedded structs, which encoding/json flattens.
+func addFields(s *Schema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft, seen)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = schemaFor(f.Type, seen)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
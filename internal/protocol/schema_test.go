@@ -0,0 +1,105 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPayloadSchemas_CoverEveryMessageType(t *testing.T) {
+	all := []MessageType{
+		TypeUserMessage, TypeLeaderResponse, TypeSystemCommand, TypeActivityEvent,
+		TypeContainerValidation, TypeSkillStatus, TypeMcpStatus, TypeAgentReady,
+		TypeAgentStatus, TypeAgentLog, TypeDeploymentEvent, TypeToolInvocation,
+		TypeToolResult, TypeConfigUpdate, TypeUsage, TypeRunQueue, TypeMemorySummary,
+		TypeHealthCheck, TypeQuota, TypeDriftDetected,
+	}
+	byType := make(map[MessageType]PayloadSchema)
+	for _, s := range PayloadSchemas() {
+		byType[s.Type] = s
+	}
+	for _, mt := range all {
+		s, ok := byType[mt]
+		if !ok {
+			t.Errorf("%s: no payload schema", mt)
+			continue
+		}
+		if s.Description == "" || s.Schema.Type != "object" || s.Schema.Schema != SchemaDialect {
+			t.Errorf("%s: got %+v", mt, s)
+		}
+	}
+	if len(byType) != len(all) {
+		t.Errorf("payload schemas: got %d, want %d", len(byType), len(all))
+	}
+}
+
+// TestPayloadSchemas_MatchMarshaledFields checks that every field a payload
+// marshals to is described by its schema.
+func TestPayloadSchemas_MatchMarshaledFields(t *testing.T) {
+	for _, p := range payloadTypes {
+		// A zero value omits the omitempty fields, so the fields it
+		// marshals are exactly the required ones.
+		data, err := json.Marshal(p.payload)
+		if err != nil {
+			t.Fatalf("%s: %v", p.msgType, err)
+		}
+		var fields map[string]interface{}
+		json.Unmarshal(data, &fields)
+		s := SchemaOf(p.payload)
+		for name := range fields {
+			if s.Properties[name] == nil {
+				t.Errorf("%s: field %q missing from the schema", p.msgType, name)
+			}
+		}
+		if len(s.Required) != len(fields) {
+			t.Errorf("%s: required %v, marshaled %v", p.msgType, s.Required, fields)
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	type embedded struct {
+		Inner string `json:"inner"`
+	}
+	type sample struct {
+		embedded
+		Name     string                `json:"name"`
+		Count    int64                 `json:"count,omitempty"`
+		Ratio    float64               `json:"ratio"`
+		Tags     []string              `json:"tags,omitempty"`
+		Env      map[string]string     `json:"env,omitempty"`
+		Status   ValidationCheckStatus `json:"status"`
+		Raw      json.RawMessage       `json:"raw,omitempty"`
+		Context  *MessageContext       `json:"context,omitempty"`
+		Ignored  string                `json:"-"`
+		internal string
+	}
+
+	s := SchemaOf(sample{})
+	want := map[string]*Schema{
+		"inner":  {Type: "string"},
+		"name":   {Type: "string"},
+		"count":  {Type: "integer"},
+		"ratio":  {Type: "number"},
+		"tags":   {Type: "array", Items: &Schema{Type: "string"}},
+		"env":    {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		"status": {Type: "string", Enum: []string{"ok", "warning", "error"}},
+		"raw":    {},
+		"context": {Type: "object", Properties: map[string]*Schema{
+			"thread_id":    {Type: "string"},
+			"relevant_ids": {Type: "array", Items: &Schema{Type: "string"}},
+		}},
+	}
+	if !reflect.DeepEqual(s.Properties, want) {
+		got, _ := json.Marshal(s.Properties)
+		t.Errorf("properties: got %s", got)
+	}
+	if wantRequired := []string{"inner", "name", "ratio", "status"}; !reflect.DeepEqual(s.Required, wantRequired) {
+		t.Errorf("required: got %v, want %v", s.Required, wantRequired)
+	}
+
+	msg := MessageSchema()
+	if msg.Properties["timestamp"].Format != "date-time" || len(msg.Properties["type"].Enum) != len(payloadTypes) {
+		t.Errorf("message schema: got %+v, %+v", msg.Properties["timestamp"], msg.Properties["type"])
+	}
+}
@@ -0,0 +1,24 @@
+package protocol
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+)
+
+// TranscriptDir is where a sidecar with transcripts enabled writes the raw
+// agent output of each run, relative to the workspace.
+const TranscriptDir = ".agentcrew/transcripts"
+
+// transcriptRunID matches the run IDs a transcript file can be named after.
+var transcriptRunID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// TranscriptFile returns the path of a run's transcript, relative to the
+// workspace. Run IDs are message IDs; anything that could leave
+// TranscriptDir is rejected.
+func TranscriptFile(runID string) (string, error) {
+	if !transcriptRunID.MatchString(runID) {
+		return "", fmt.Errorf("invalid run ID %q", runID)
+	}
+	return path.Join(TranscriptDir, runID+".jsonl"), nil
+}
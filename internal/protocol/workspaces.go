@@ -0,0 +1,122 @@
+package protocol
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ExtraWorkspaceRoot is the directory of agent containers that extra
+// workspaces are mounted under.
+const ExtraWorkspaceRoot = "/workspaces"
+
+// MaxExtraWorkspaces caps the extra workspaces of a team.
+const MaxExtraWorkspaces = 8
+
+// extraWorkspaceName matches the names of extra workspaces, which become
+// directory names.
+var extraWorkspaceName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,62}$`)
+
+// ExtraWorkspace is a host directory mounted into a team's agent containers
+// next to its main workspace, at ExtraWorkspaceRoot/<name>. The Claude CLI
+// is given access to it with --add-dir.
+type ExtraWorkspace struct {
+	Name     string `json:"name" yaml:"name"`
+	HostPath string `json:"host_path" yaml:"host_path"`
+	ReadOnly bool   `json:"read_only,omitempty" yaml:"read_only"`
+}
+
+// MountPath returns where the workspace is mounted in agent containers.
+func (w ExtraWorkspace) MountPath() string {
+	return path.Join(ExtraWorkspaceRoot, w.Name)
+}
+
+// ValidateExtraWorkspaces checks the names and host paths of a team's extra
+// workspaces. Names must be unique.
+func ValidateExtraWorkspaces(workspaces []ExtraWorkspace) error {
+	if len(workspaces) > MaxExtraWorkspaces {
+		return fmt.Errorf("at most %d extra workspaces are allowed", MaxExtraWorkspaces)
+	}
+	seen := make(map[string]bool, len(workspaces))
+	for i, w := range workspaces {
+		if !extraWorkspaceName.MatchString(w.Name) {
+			return fmt.Errorf("extra_workspaces[%d]: invalid name %q: use lowercase letters, digits, - and _", i, w.Name)
+		}
+		if seen[w.Name] {
+			return fmt.Errorf("extra_workspaces[%d]: duplicate name %q", i, w.Name)
+		}
+		seen[w.Name] = true
+		if !strings.HasPrefix(w.HostPath, "/") {
+			return fmt.Errorf("extra_workspaces[%d]: host_path %q must be absolute", i, w.HostPath)
+		}
+	}
+	return nil
+}
+
+// ExtraWorkspaceScope returns the AGENT_EXTRA_WORKSPACES value telling the
+// sidecar where the extra workspaces are mounted: a comma-separated list of
+// "path:mode" items, mode being ro or rw.
+func ExtraWorkspaceScope(workspaces []ExtraWorkspace) string {
+	items := make([]string, 0, len(workspaces))
+	for _, w := range workspaces {
+		mode := "rw"
+		if w.ReadOnly {
+			mode = "ro"
+		}
+		items = append(items, w.MountPath()+":"+mode)
+	}
+	return strings.Join(items, ",")
+}
+
+// MaxWorkspaceScaffoldSize caps the total size of a scaffold's files.
+const MaxWorkspaceScaffoldSize = 64 * 1024
+
+// WorkspaceScaffold is the starting structure of a team's workspace,
+// rendered from its workspace template. The leader's sidecar writes it into
+// the workspace before the agent starts, while the workspace is still
+// empty; existing files are never overwritten.
+type WorkspaceScaffold struct {
+	// Template is the name of the template it was rendered from.
+	Template string         `json:"template" yaml:"template"`
+	Dirs     []string       `json:"dirs,omitempty" yaml:"dirs"`
+	Files    []ScaffoldFile `json:"files,omitempty" yaml:"files"`
+	// GitInit makes the workspace a git repository.
+	GitInit bool `json:"git_init,omitempty" yaml:"git_init"`
+}
+
+// ScaffoldFile is a file of a WorkspaceScaffold. Path is relative to the
+// workspace.
+type ScaffoldFile struct {
+	Path    string `json:"path" yaml:"path"`
+	Content string `json:"content" yaml:"content"`
+}
+
+// Validate checks that every path stays inside the workspace and that the
+// files fit in MaxWorkspaceScaffoldSize.
+func (s WorkspaceScaffold) Validate() error {
+	for i, dir := range s.Dirs {
+		if err := validScaffoldPath(dir); err != nil {
+			return fmt.Errorf("dirs[%d]: %w", i, err)
+		}
+	}
+	size := 0
+	for i, f := range s.Files {
+		if err := validScaffoldPath(f.Path); err != nil {
+			return fmt.Errorf("files[%d]: %w", i, err)
+		}
+		size += len(f.Content)
+	}
+	if size > MaxWorkspaceScaffoldSize {
+		return fmt.Errorf("files exceed maximum size of %d bytes", MaxWorkspaceScaffoldSize)
+	}
+	return nil
+}
+
+// validScaffoldPath checks that p is a clean path relative to the workspace.
+func validScaffoldPath(p string) error {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("path %q must be relative to the workspace", p)
+	}
+	return nil
+}
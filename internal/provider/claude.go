@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 
 	"github.com/helmcode/agent-crew/internal/claude"
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 // ClaudeManager wraps claude.Manager to implement the AgentManager interface.
@@ -35,6 +36,12 @@ func (c *ClaudeManager) SendInput(input string) error {
 	return c.inner.SendInput(input)
 }
 
+// SendInputWithOverrides delegates to the underlying
+// claude.Manager.SendInputWithOverrides.
+func (c *ClaudeManager) SendInputWithOverrides(input string, overrides protocol.RunOverrides) error {
+	return c.inner.SendInputWithOverrides(input, overrides)
+}
+
 // ReadEvents returns a channel of provider.StreamEvent converted from claude events.
 func (c *ClaudeManager) ReadEvents() <-chan StreamEvent {
 	return c.events
@@ -50,6 +57,21 @@ func (c *ClaudeManager) Stop() error {
 	return c.inner.Stop()
 }
 
+// Recover delegates to the underlying claude.Manager.Recover.
+func (c *ClaudeManager) Recover(ctx context.Context) error {
+	return c.inner.Recover(ctx)
+}
+
+// Interrupt delegates to the underlying claude.Manager.Interrupt.
+func (c *ClaudeManager) Interrupt() bool {
+	return c.inner.Interrupt()
+}
+
+// SessionID delegates to the underlying claude.Manager.SessionID.
+func (c *ClaudeManager) SessionID() string {
+	return c.inner.SessionID()
+}
+
 // Status delegates to the underlying claude.Manager.Status.
 func (c *ClaudeManager) Status() string {
 	return c.inner.Status()
@@ -72,6 +94,7 @@ func (c *ClaudeManager) convertEvents() {
 			Result:    ce.Result,
 			ErrorCode: ce.ErrorCode,
 			SessionID: ce.SessionID,
+			Unknown:   ce.Unknown,
 		}
 
 		// Convert json.RawMessage fields to strings.
@@ -84,6 +107,18 @@ func (c *ClaudeManager) convertEvents() {
 		if len(ce.MCPServers) > 0 {
 			pe.MCPServers = string(ce.MCPServers)
 		}
+		if len(ce.Raw) > 0 {
+			pe.Raw = string(ce.Raw)
+		}
+		pe.CostUSD = ce.TotalCostUSD
+		if ce.Usage != nil {
+			pe.Usage = &TokenUsage{
+				InputTokens:      ce.Usage.InputTokens,
+				OutputTokens:     ce.Usage.OutputTokens,
+				CacheReadTokens:  ce.Usage.CacheReadInputTokens,
+				CacheWriteTokens: ce.Usage.CacheCreationInputTokens,
+			}
+		}
 
 		select {
 		case c.events <- pe:
@@ -106,6 +141,7 @@ func ToClaudeStreamEvent(pe *StreamEvent) *claude.StreamEvent {
 		Result:    pe.Result,
 		ErrorCode: pe.ErrorCode,
 		SessionID: pe.SessionID,
+		Unknown:   pe.Unknown,
 	}
 	if pe.Message != "" {
 		ce.Message = json.RawMessage(pe.Message)
@@ -116,5 +152,17 @@ func ToClaudeStreamEvent(pe *StreamEvent) *claude.StreamEvent {
 	if pe.MCPServers != "" {
 		ce.MCPServers = json.RawMessage(pe.MCPServers)
 	}
+	if pe.Raw != "" {
+		ce.Raw = json.RawMessage(pe.Raw)
+	}
+	ce.TotalCostUSD = pe.CostUSD
+	if pe.Usage != nil {
+		ce.Usage = &claude.TokenUsage{
+			InputTokens:              pe.Usage.InputTokens,
+			OutputTokens:             pe.Usage.OutputTokens,
+			CacheCreationInputTokens: pe.Usage.CacheWriteTokens,
+			CacheReadInputTokens:     pe.Usage.CacheReadTokens,
+		}
+	}
 	return ce
 }
@@ -269,3 +269,32 @@ func TestToClaudeStreamEvent_Roundtrip(t *testing.T) {
 		t.Errorf("FriendlyError after roundtrip: got %q, want %q", friendly, expected)
 	}
 }
+
+func TestToClaudeStreamEvent_Usage(t *testing.T) {
+	pe := &StreamEvent{
+		Type:    "result",
+		CostUSD: 0.05,
+		Usage:   &TokenUsage{InputTokens: 10, OutputTokens: 20, CacheReadTokens: 30, CacheWriteTokens: 40},
+	}
+
+	ce := ToClaudeStreamEvent(pe)
+
+	if ce.TotalCostUSD != 0.05 {
+		t.Errorf("TotalCostUSD: got %v, want 0.05", ce.TotalCostUSD)
+	}
+	want := claude.TokenUsage{InputTokens: 10, OutputTokens: 20, CacheReadInputTokens: 30, CacheCreationInputTokens: 40}
+	if ce.Usage == nil || *ce.Usage != want {
+		t.Errorf("Usage: got %+v, want %+v", ce.Usage, want)
+	}
+}
+
+func TestToClaudeStreamEvent_Unknown(t *testing.T) {
+	raw := `{"type":"rate_limit_event","rate_limit":{"status":"allowed"}}`
+	pe := &StreamEvent{Type: "rate_limit_event", Unknown: true, Raw: raw}
+
+	ce := ToClaudeStreamEvent(pe)
+
+	if !ce.Unknown || string(ce.Raw) != raw {
+		t.Errorf("unknown event: got Unknown=%v Raw=%q", ce.Unknown, ce.Raw)
+	}
+}
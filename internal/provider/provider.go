@@ -2,7 +2,12 @@
 // AI agent backends (Claude Code, OpenCode, etc.).
 package provider
 
-import "context"
+import (
+	"context"
+
+	"github.com/helmcode/agent-crew/internal/claude"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
 
 // AgentManager is the interface for managing an AI agent process lifecycle.
 // Implementations bridge the gap between the NATS messaging layer and a
@@ -17,6 +22,30 @@ type AgentManager interface {
 	IsRunning() bool
 }
 
+// Recoverable is implemented by managers that can be brought back after a
+// crashed invocation without losing their conversation session.
+type Recoverable interface {
+	Recover(ctx context.Context) error
+	SessionID() string
+}
+
+// Interruptible is implemented by managers that can stop the run in progress
+// without losing their conversation session.
+type Interruptible interface {
+	// Interrupt stops the run in progress, whose SendInput call then returns
+	// ErrInterrupted. Reports whether a run was in progress.
+	Interrupt() bool
+}
+
+// Overridable is implemented by managers that can run one input with another
+// model or other generation settings than the agent's.
+type Overridable interface {
+	SendInputWithOverrides(input string, overrides protocol.RunOverrides) error
+}
+
+// ErrInterrupted is returned by SendInput when the run was interrupted.
+var ErrInterrupted = claude.ErrInterrupted
+
 // StreamEvent represents a single event from an agent's output stream.
 // This is the provider-agnostic version of claude.StreamEvent.
 type StreamEvent struct {
@@ -29,5 +58,19 @@ type StreamEvent struct {
 	Result     string
 	ErrorCode  string // Machine-readable error code (e.g. "billing_error")
 	SessionID  string
-	MCPServers string // Raw JSON array of MCP server statuses (for system/init events)
+	MCPServers string      // Raw JSON array of MCP server statuses (for system/init events)
+	CostUSD    float64     // Cost of the turn in USD (for result events)
+	Usage      *TokenUsage // Token usage of the turn (for result events)
+	// Unknown marks an event the provider's parser did not understand, such
+	// as a type added by a newer CLI; Raw holds it as the CLI wrote it.
+	Unknown bool
+	Raw     string
+}
+
+// TokenUsage is the number of tokens an agent turn consumed.
+type TokenUsage struct {
+	InputTokens      int64
+	OutputTokens     int64
+	CacheReadTokens  int64
+	CacheWriteTokens int64
 }
@@ -32,6 +32,7 @@ const EmbeddingBatchSize = 50
 
 // ProcessDocument takes a document through the full RAG pipeline:
 // parse → chunk → embed → upsert to Qdrant → update status.
+// Team documents go to the team's collection rather than the org's.
 func (p *Processor) ProcessDocument(ctx context.Context, doc models.Document) error {
 	// 1. Update status to processing.
 	if err := p.db.Model(&doc).Updates(map[string]interface{}{
@@ -68,19 +69,59 @@ func (p *Processor) ProcessDocument(ctx context.Context, doc models.Document) er
 		chunks[i].Metadata["doc_name"] = doc.Name
 		chunks[i].Metadata["org_id"] = doc.OrgID
 		chunks[i].Metadata["file_name"] = doc.FileName
+		if doc.TeamID != "" {
+			chunks[i].Metadata["team_id"] = doc.TeamID
+		}
 	}
 
-	// 4. Ensure Qdrant collection exists for the org.
+	// 4. Generate embeddings and upsert in batches.
 	p.db.Model(&doc).Update("status_message", "Preparing vector store...")
-	if err := p.qdrant.EnsureCollection(ctx, doc.OrgID); err != nil {
-		p.setError(doc.ID, "Failed to ensure Qdrant collection: "+err.Error())
-		return fmt.Errorf("ensuring collection: %w", err)
+	totalPoints, err := p.indexChunks(ctx, DocumentCollection(doc), chunks, func(done int) {
+		p.db.Model(&doc).Update("status_message",
+			fmt.Sprintf("Generating embeddings (%d/%d)...", done, len(chunks)))
+	})
+	if err != nil {
+		p.setError(doc.ID, "Failed to index document: "+err.Error())
+		return err
 	}
 
-	// 5. Generate embeddings and upsert in batches.
-	collection := CollectionName(doc.OrgID)
-	totalPoints := 0
+	// 5. Update status to ready.
+	if err := p.db.Model(&doc).Updates(map[string]interface{}{
+		"status":         models.DocStatusReady,
+		"status_message": "",
+		"chunk_count":    totalPoints,
+	}).Error; err != nil {
+		return fmt.Errorf("updating status to ready: %w", err)
+	}
+
+	slog.Info("document processed successfully", "id", doc.ID, "chunks", totalPoints)
+	return nil
+}
+
+// IndexText chunks text, embeds the chunks and stores them in collection,
+// each with the given metadata. Returns the number of chunks stored.
+func (p *Processor) IndexText(ctx context.Context, collection, text string, metadata map[string]string) (int, error) {
+	chunks := ChunkText(text, DefaultChunkConfig())
+	if len(chunks) == 0 {
+		return 0, fmt.Errorf("no chunks produced")
+	}
+	for i := range chunks {
+		for k, v := range metadata {
+			chunks[i].Metadata[k] = v
+		}
+	}
+	return p.indexChunks(ctx, collection, chunks, nil)
+}
+
+// indexChunks embeds chunks and upserts them into collection in batches of
+// EmbeddingBatchSize, creating the collection if needed. progress, if set,
+// is called with the number of chunks embedded before each batch.
+func (p *Processor) indexChunks(ctx context.Context, collection string, chunks []Chunk, progress func(done int)) (int, error) {
+	if err := p.qdrant.ensureCollection(ctx, collection); err != nil {
+		return 0, fmt.Errorf("ensuring collection: %w", err)
+	}
 
+	totalPoints := 0
 	for batchStart := 0; batchStart < len(chunks); batchStart += EmbeddingBatchSize {
 		batchEnd := batchStart + EmbeddingBatchSize
 		if batchEnd > len(chunks) {
@@ -88,8 +129,9 @@ func (p *Processor) ProcessDocument(ctx context.Context, doc models.Document) er
 		}
 		batch := chunks[batchStart:batchEnd]
 
-		p.db.Model(&doc).Update("status_message",
-			fmt.Sprintf("Generating embeddings (%d/%d)...", batchStart+len(batch), len(chunks)))
+		if progress != nil {
+			progress(batchStart + len(batch))
+		}
 
 		// Extract texts for embedding.
 		texts := make([]string, len(batch))
@@ -99,8 +141,7 @@ func (p *Processor) ProcessDocument(ctx context.Context, doc models.Document) er
 
 		vectors, err := p.embedder.EmbedBatch(ctx, texts)
 		if err != nil {
-			p.setError(doc.ID, "Failed to generate embeddings: "+err.Error())
-			return fmt.Errorf("embedding batch at %d: %w", batchStart, err)
+			return totalPoints, fmt.Errorf("embedding batch at %d: %w", batchStart, err)
 		}
 
 		// Build Qdrant points.
@@ -120,26 +161,22 @@ func (p *Processor) ProcessDocument(ctx context.Context, doc models.Document) er
 			}
 		}
 
-		// Upsert to Qdrant.
 		if err := p.qdrant.UpsertPoints(ctx, collection, points); err != nil {
-			p.setError(doc.ID, "Failed to upsert vectors: "+err.Error())
-			return fmt.Errorf("upserting batch at %d: %w", batchStart, err)
+			return totalPoints, fmt.Errorf("upserting batch at %d: %w", batchStart, err)
 		}
 
 		totalPoints += len(points)
 	}
+	return totalPoints, nil
+}
 
-	// 6. Update status to ready.
-	if err := p.db.Model(&doc).Updates(map[string]interface{}{
-		"status":         models.DocStatusReady,
-		"status_message": "",
-		"chunk_count":    totalPoints,
-	}).Error; err != nil {
-		return fmt.Errorf("updating status to ready: %w", err)
+// DocumentCollection returns the collection a document is indexed in: its
+// team's when it was uploaded for one, its organization's otherwise.
+func DocumentCollection(doc models.Document) string {
+	if doc.TeamID != "" {
+		return TeamCollectionName(doc.TeamID)
 	}
-
-	slog.Info("document processed successfully", "id", doc.ID, "chunks", totalPoints)
-	return nil
+	return CollectionName(doc.OrgID)
 }
 
 // setError updates the document status to error with a message.
@@ -0,0 +1,73 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestProcessor_IndexText(t *testing.T) {
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req embedRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		embs := make([][]float64, len(req.Input))
+		for i := range embs {
+			embs[i] = make([]float64, 768)
+		}
+		json.NewEncoder(w).Encode(embedResponse{Embeddings: embs})
+	}))
+	defer ollama.Close()
+
+	var created string
+	var upserted []Point
+	qdrant := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/points"):
+			var body struct {
+				Points []Point `json:"points"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			upserted = append(upserted, body.Points...)
+		case r.Method == http.MethodPut:
+			created = r.URL.Path
+		}
+		w.Write([]byte(`{"result":true}`))
+	}))
+	defer qdrant.Close()
+
+	p := NewProcessor(nil, NewQdrantClient(qdrant.URL), NewOllamaEmbedder(ollama.URL, "nomic-embed-text"))
+	text := strings.Repeat("The deploy script lives in build/. ", 60)
+	n, err := p.IndexText(context.Background(), TeamCollectionName("t1"), text, map[string]string{"doc_id": "entry-1"})
+	if err != nil {
+		t.Fatalf("IndexText: %v", err)
+	}
+	if created != "/collections/team_t1" {
+		t.Errorf("created collection: got %q", created)
+	}
+	if n < 2 || len(upserted) != n {
+		t.Fatalf("points: got %d stored, %d upserted", n, len(upserted))
+	}
+	if upserted[0].Payload["doc_id"] != "entry-1" || upserted[0].Payload["content"] == "" {
+		t.Errorf("payload: got %v", upserted[0].Payload)
+	}
+
+	if _, err := p.IndexText(context.Background(), "c", "   ", nil); err == nil {
+		t.Error("expected an error for empty text")
+	}
+}
+
+func TestDocumentCollection(t *testing.T) {
+	if got := DocumentCollection(models.Document{OrgID: "o1"}); got != "org_o1" {
+		t.Errorf("org document: got %q", got)
+	}
+	if got := DocumentCollection(models.Document{OrgID: "o1", TeamID: "t1"}); got != "team_t1" {
+		t.Errorf("team document: got %q", got)
+	}
+}
@@ -31,6 +31,12 @@ func CollectionName(orgID string) string {
 	return "org_" + orgID
 }
 
+// TeamCollectionName returns the Qdrant collection name for a team's own
+// knowledge base: its indexed run results and team documents.
+func TeamCollectionName(teamID string) string {
+	return "team_" + teamID
+}
+
 // Point represents a vector point for upsert.
 type Point struct {
 	ID      string                 `json:"id"`
@@ -68,10 +74,14 @@ func (q *QdrantClient) CollectionExists(ctx context.Context, name string) (bool,
 	return false, fmt.Errorf("unexpected status %d checking collection %s", resp.StatusCode, name)
 }
 
-// EnsureCollection creates a collection with 768-dimension cosine vectors if it doesn't exist.
+// EnsureCollection creates an organization's collection with 768-dimension
+// cosine vectors if it doesn't exist.
 func (q *QdrantClient) EnsureCollection(ctx context.Context, orgID string) error {
-	name := CollectionName(orgID)
+	return q.ensureCollection(ctx, CollectionName(orgID))
+}
 
+// ensureCollection creates the named collection if it doesn't exist.
+func (q *QdrantClient) ensureCollection(ctx context.Context, name string) error {
 	exists, err := q.CollectionExists(ctx, name)
 	if err != nil {
 		return err
@@ -0,0 +1,330 @@
+// Package ratelimit spaces out prompts sent to agents across all teams so
+// that together they stay within the account's rate limits with the AI
+// provider.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// DefaultBackoff is how long the governor pauses after an agent reports that
+// the provider rejected a request for exceeding its rate limit.
+const DefaultBackoff = time.Minute
+
+// bucketName names the governor's bucket in the database.
+const bucketName = "prompts"
+
+// ticketTTL is how long a queued request stays in the queue unless the
+// process waiting renews it, every third of it.
+const ticketTTL = 15 * time.Second
+
+// pollInterval is how often the request at the head of the queue checks
+// whether a token is available. Requests further back check less often, in
+// proportion to their place in the queue, up to maxPollInterval.
+var pollInterval = 50 * time.Millisecond
+
+// maxPollInterval caps how long a queued request waits between checks. It
+// stays well below ticketTTL/3 so tickets are renewed in time.
+var maxPollInterval = 2 * time.Second
+
+// Request identifies a prompt waiting for the governor.
+type Request struct {
+	ID       string `json:"id"` // Chat message or run ID
+	OrgID    string `json:"org_id,omitempty"`
+	TeamID   string `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Source   string `json:"source"` // "chat", "scheduler" or "webhook"
+}
+
+// Entry is a queued request and its place in the queue, starting at 1.
+type Entry struct {
+	Request
+	Position int       `json:"position"`
+	QueuedAt time.Time `json:"queued_at"`
+}
+
+// Status describes the governor's configuration and queue.
+type Status struct {
+	Enabled     bool       `json:"enabled"`
+	PerMinute   int        `json:"per_minute"`
+	Burst       int        `json:"burst"`
+	Available   float64    `json:"available"`
+	PausedUntil *time.Time `json:"paused_until,omitempty"`
+	Queue       []Entry    `json:"queue"`
+}
+
+// Governor is a token bucket shared by all teams. Requests that find the
+// bucket empty wait in a single FIFO queue, so a busy team cannot starve
+// the others. The bucket and the queue are kept in the database, so the
+// API replicas, relay workers and scheduler using it share one limit; each
+// refills the bucket at the rate it was given, so they need the same
+// settings. A nil Governor allows everything.
+type Governor struct {
+	db        *gorm.DB
+	perMinute int
+	burst     int
+}
+
+// New returns a governor allowing perMinute prompts per minute, with bursts
+// of up to burst prompts, across all processes using db. It returns nil,
+// which allows everything, when perMinute is not positive. burst defaults
+// to 1.
+func New(db *gorm.DB, perMinute, burst int) *Governor {
+	if perMinute <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = 1
+	}
+	return &Governor{db: db, perMinute: perMinute, burst: burst}
+}
+
+// TryAcquire takes a token if one is available and nobody is queued ahead,
+// without waiting.
+func (g *Governor) TryAcquire() bool {
+	if g == nil {
+		return true
+	}
+	now := time.Now().UTC()
+	if _, ok, err := g.head(now); err != nil || ok {
+		return false
+	}
+	ok, _, err := g.take(now)
+	if err != nil {
+		slog.Warn("rate limit: failed to take a token", "error", err)
+	}
+	return ok
+}
+
+// Wait blocks until req may be sent, in the order requests arrived, or until
+// ctx is done.
+func (g *Governor) Wait(ctx context.Context, req Request) error {
+	if g == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	g.db.Where("expires_at < ?", now).Delete(&models.RateLimitTicket{})
+	t := models.RateLimitTicket{
+		RequestID: req.ID,
+		OrgID:     req.OrgID,
+		TeamID:    req.TeamID,
+		TeamName:  req.TeamName,
+		Source:    req.Source,
+		QueuedAt:  now,
+		ExpiresAt: now.Add(ticketTTL),
+	}
+	if err := g.db.Create(&t).Error; err != nil {
+		return err
+	}
+	defer func() { g.db.Delete(&models.RateLimitTicket{}, t.ID) }()
+
+	renewAt := now.Add(ticketTTL / 3)
+	for {
+		now := time.Now().UTC()
+		if now.After(renewAt) {
+			g.renew(&t, now)
+			renewAt = now.Add(ticketTTL / 3)
+		}
+
+		// At the head of the queue: take a token once any pause is over.
+		// Further back, check again later the further back the request is.
+		wait := pollInterval
+		ahead, err := g.ahead(t.ID, now)
+		if err == nil && ahead > 0 {
+			wait = min(pollInterval*time.Duration(ahead+1), maxPollInterval)
+		}
+		if err == nil && ahead == 0 {
+			var ok bool
+			var next time.Duration
+			ok, next, err = g.take(now)
+			if err == nil && ok {
+				return nil
+			}
+			if err == nil && next < wait {
+				wait = next
+			}
+		}
+		if err != nil {
+			slog.Warn("rate limit: failed to check the queue", "error", err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// renew extends t in the queue. A ticket that expired meanwhile is queued
+// again, at the back.
+func (g *Governor) renew(t *models.RateLimitTicket, now time.Time) {
+	t.ExpiresAt = now.Add(ticketTTL)
+	res := g.db.Model(&models.RateLimitTicket{}).Where("id = ?", t.ID).Update("expires_at", t.ExpiresAt)
+	if res.Error != nil {
+		slog.Warn("rate limit: failed to renew a queued request", "error", res.Error)
+		return
+	}
+	if res.RowsAffected == 0 {
+		t.ID = 0
+		if err := g.db.Create(t).Error; err != nil {
+			slog.Warn("rate limit: failed to requeue a request", "error", err)
+		}
+	}
+}
+
+// head returns the ID of the request at the head of the queue, and whether
+// there is one.
+func (g *Governor) head(now time.Time) (uint64, bool, error) {
+	var ids []uint64
+	err := g.db.Model(&models.RateLimitTicket{}).
+		Where("expires_at >= ?", now).Order("id").Limit(1).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, false, err
+	}
+	return ids[0], true, nil
+}
+
+// ahead returns the number of requests queued ahead of the ticket id.
+func (g *Governor) ahead(id uint64, now time.Time) (int64, error) {
+	var n int64
+	err := g.db.Model(&models.RateLimitTicket{}).
+		Where("id < ? AND expires_at >= ?", id, now).Count(&n).Error
+	return n, err
+}
+
+// load returns the bucket refilled up to now, creating it full.
+func (g *Governor) load(now time.Time) (models.RateLimitBucket, error) {
+	var b models.RateLimitBucket
+	for attempt := 0; ; attempt++ {
+		res := g.db.Where("name = ?", bucketName).Limit(1).Find(&b)
+		if res.Error != nil {
+			return b, res.Error
+		}
+		if res.RowsAffected > 0 || attempt > 0 {
+			break
+		}
+		full := models.RateLimitBucket{Name: bucketName, Tokens: float64(g.burst), RefilledAt: now}
+		if err := g.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&full).Error; err != nil {
+			return b, err
+		}
+	}
+	if elapsed := now.Sub(b.RefilledAt); elapsed > 0 {
+		b.Tokens += elapsed.Minutes() * float64(g.perMinute)
+		b.RefilledAt = now
+	}
+	b.Tokens = min(b.Tokens, float64(g.burst))
+	return b, nil
+}
+
+// take takes a token if one is available and the governor is not paused.
+// Otherwise it returns how long until the next token or the end of the
+// pause.
+func (g *Governor) take(now time.Time) (bool, time.Duration, error) {
+	for {
+		b, err := g.load(now)
+		if err != nil {
+			return false, 0, err
+		}
+		if now.Before(b.PausedUntil) {
+			return false, b.PausedUntil.Sub(now), nil
+		}
+		if b.Tokens < 1 {
+			return false, time.Duration((1 - b.Tokens) / float64(g.perMinute) * float64(time.Minute)), nil
+		}
+		res := g.db.Model(&models.RateLimitBucket{}).
+			Where("name = ? AND version = ?", bucketName, b.Version).
+			Updates(map[string]interface{}{
+				"tokens":      b.Tokens - 1,
+				"refilled_at": b.RefilledAt,
+				"version":     b.Version + 1,
+			})
+		if res.Error != nil {
+			return false, 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return true, 0, nil
+		}
+		// Another process took a token meanwhile.
+	}
+}
+
+// Backoff stops handing out tokens for d, after the provider has rejected a
+// request for exceeding its rate limit. Overlapping backoffs do not add up.
+func (g *Governor) Backoff(d time.Duration) {
+	if g == nil {
+		return
+	}
+	now := time.Now().UTC()
+	if _, err := g.load(now); err != nil {
+		slog.Warn("rate limit: failed to pause", "error", err)
+		return
+	}
+	until := now.Add(d)
+	if err := g.db.Model(&models.RateLimitBucket{}).
+		Where("name = ? AND paused_until < ?", bucketName, until).
+		Update("paused_until", until).Error; err != nil {
+		slog.Warn("rate limit: failed to pause", "error", err)
+	}
+}
+
+// Len returns the number of queued requests.
+func (g *Governor) Len() int {
+	if g == nil {
+		return 0
+	}
+	var n int64
+	if err := g.db.Model(&models.RateLimitTicket{}).
+		Where("expires_at >= ?", time.Now().UTC()).Count(&n).Error; err != nil {
+		slog.Warn("rate limit: failed to count queued requests", "error", err)
+	}
+	return int(n)
+}
+
+// Status returns the governor's configuration and queue. The request at the
+// head of the queue is the one waiting for the next token.
+func (g *Governor) Status() Status {
+	if g == nil {
+		return Status{Queue: []Entry{}}
+	}
+	now := time.Now().UTC()
+	st := Status{Enabled: true, PerMinute: g.perMinute, Burst: g.burst, Queue: []Entry{}}
+	b, err := g.load(now)
+	if err != nil {
+		slog.Warn("rate limit: failed to load the bucket", "error", err)
+		return st
+	}
+	st.Available = max(b.Tokens, 0)
+	if now.Before(b.PausedUntil) {
+		until := b.PausedUntil
+		st.PausedUntil = &until
+	}
+
+	var tickets []models.RateLimitTicket
+	if err := g.db.Where("expires_at >= ?", now).Order("id").Find(&tickets).Error; err != nil {
+		slog.Warn("rate limit: failed to list queued requests", "error", err)
+	}
+	for i, t := range tickets {
+		st.Queue = append(st.Queue, Entry{
+			Request: Request{
+				ID:       t.RequestID,
+				OrgID:    t.OrgID,
+				TeamID:   t.TeamID,
+				TeamName: t.TeamName,
+				Source:   t.Source,
+			},
+			Position: i + 1,
+			QueuedAt: t.QueuedAt,
+		})
+	}
+	return st
+}
@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func setupDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	return db
+}
+
+// waitForQueue polls until g has n queued requests.
+func waitForQueue(t *testing.T, g *Governor, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for g.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue length: got %d, want %d", g.Len(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNilGovernorAllowsEverything(t *testing.T) {
+	g := New(nil, 0, 0)
+	if g != nil {
+		t.Fatal("New(nil, 0, 0) should return nil")
+	}
+	if !g.TryAcquire() {
+		t.Error("TryAcquire on nil governor should succeed")
+	}
+	if err := g.Wait(context.Background(), Request{ID: "a"}); err != nil {
+		t.Errorf("Wait on nil governor: %v", err)
+	}
+	g.Backoff(time.Minute)
+	if st := g.Status(); st.Enabled || st.Queue == nil {
+		t.Errorf("status: got %+v", st)
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	g := New(setupDB(t), 60, 2)
+	if !g.TryAcquire() || !g.TryAcquire() {
+		t.Fatal("the burst should be available")
+	}
+	if g.TryAcquire() {
+		t.Error("TryAcquire should fail once the burst is used")
+	}
+}
+
+func TestWaitIsFIFO(t *testing.T) {
+	// One token every 20ms.
+	g := New(setupDB(t), 3000, 1)
+	if !g.TryAcquire() {
+		t.Fatal("TryAcquire should take the first token")
+	}
+
+	order := make(chan string, 3)
+	for i, id := range []string{"a", "b", "c"} {
+		go func() {
+			if err := g.Wait(context.Background(), Request{ID: id, TeamName: "team-" + id}); err != nil {
+				t.Errorf("Wait(%s): %v", id, err)
+			}
+			order <- id
+		}()
+		waitForQueue(t, g, i+1)
+	}
+
+	st := g.Status()
+	if len(st.Queue) != 3 || st.Queue[0].ID != "a" || st.Queue[2].Position != 3 || st.Queue[2].TeamName != "team-c" {
+		t.Errorf("queue: got %+v", st.Queue)
+	}
+	if g.TryAcquire() {
+		t.Error("TryAcquire should not jump the queue")
+	}
+
+	for _, want := range []string{"a", "b", "c"} {
+		if got := <-order; got != want {
+			t.Errorf("order: got %s, want %s", got, want)
+		}
+	}
+	if g.Len() != 0 {
+		t.Errorf("queue should be empty, got %d", g.Len())
+	}
+}
+
+func TestAheadCountsLiveTickets(t *testing.T) {
+	db := setupDB(t)
+	g := New(db, 60, 1)
+	now := time.Now().UTC()
+	for _, exp := range []time.Time{now.Add(time.Minute), now.Add(-time.Second), now.Add(time.Minute)} {
+		if err := db.Create(&models.RateLimitTicket{RequestID: "r", QueuedAt: now, ExpiresAt: exp}).Error; err != nil {
+			t.Fatalf("create ticket: %v", err)
+		}
+	}
+	n, err := g.ahead(3, now)
+	if err != nil || n != 1 {
+		t.Errorf("ahead: got %d, %v, want 1", n, err)
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	g := New(setupDB(t), 1, 1)
+	g.TryAcquire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- g.Wait(ctx, Request{ID: "a"}) }()
+	waitForQueue(t, g, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Wait: got %v, want context.Canceled", err)
+	}
+	if g.Len() != 0 {
+		t.Errorf("cancelled request should leave the queue, got %d", g.Len())
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	g := New(setupDB(t), 6000, 5)
+	g.Backoff(50 * time.Millisecond)
+	if g.TryAcquire() {
+		t.Error("TryAcquire should fail during a backoff")
+	}
+	if st := g.Status(); st.PausedUntil == nil {
+		t.Error("status should report the pause")
+	}
+
+	start := time.Now()
+	if err := g.Wait(context.Background(), Request{ID: "a"}); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Wait returned after %v, before the backoff ended", elapsed)
+	}
+	if !g.TryAcquire() {
+		t.Error("TryAcquire should succeed after the backoff")
+	}
+}
+
+func TestSharedAcrossProcesses(t *testing.T) {
+	db := setupDB(t)
+	a, b := New(db, 1, 2), New(db, 1, 2)
+	if !a.TryAcquire() || !b.TryAcquire() {
+		t.Fatal("the burst should be available to both")
+	}
+	if a.TryAcquire() || b.TryAcquire() {
+		t.Error("the processes should share one bucket")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Wait(ctx, Request{ID: "queued", TeamName: "team-a"})
+	waitForQueue(t, b, 1)
+	if st := b.Status(); len(st.Queue) != 1 || st.Queue[0].ID != "queued" {
+		t.Errorf("queue seen by the other process: got %+v", st.Queue)
+	}
+
+	a.Backoff(time.Minute)
+	if st := b.Status(); st.PausedUntil == nil {
+		t.Error("the other process should see the pause")
+	}
+}
+
+func TestExpiredTicketsLeaveTheQueue(t *testing.T) {
+	db := setupDB(t)
+	g := New(db, 6000, 1)
+	// The request of a process that stopped while waiting.
+	db.Create(&models.RateLimitTicket{RequestID: "gone", ExpiresAt: time.Now().UTC().Add(-time.Second)})
+	if g.Len() != 0 {
+		t.Errorf("queue length: got %d, want 0", g.Len())
+	}
+	if err := g.Wait(context.Background(), Request{ID: "a"}); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+// Package resources resolves the CPU and memory limits applied to a team's
+// leader container from named presets and per-agent overrides, within an
+// admin-set ceiling.
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+// Limits is a CPU and memory limit pair. CPU is a number of cores ("0.5",
+// "2") and Memory a size with a k/m/g suffix ("512m", "4g"); Kubernetes
+// quantities ("500m", "512Mi") are accepted too. An empty field means
+// unlimited.
+type Limits struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+// DefaultPresets are offered to organizations that have not defined their own.
+var DefaultPresets = map[string]Limits{
+	"small":  {CPU: "1", Memory: "2g"},
+	"medium": {CPU: "2", Memory: "4g"},
+	"large":  {CPU: "4", Memory: "8g"},
+}
+
+// Policy is an organization's presets and ceiling.
+type Policy struct {
+	Presets map[string]Limits `json:"presets"`
+	Ceiling Limits            `json:"ceiling"`
+}
+
+var presetNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// Validate checks that limits parse. Empty fields are allowed.
+func (l Limits) Validate() error {
+	if l.CPU != "" && runtime.NanoCPUs(l.CPU) <= 0 {
+		return fmt.Errorf("invalid cpu %q: use a number of cores such as \"0.5\" or \"500m\"", l.CPU)
+	}
+	if l.Memory != "" && runtime.MemoryBytes(l.Memory) <= 0 {
+		return fmt.Errorf("invalid memory %q: use a size such as \"4g\" or \"512Mi\"", l.Memory)
+	}
+	return nil
+}
+
+// Validate checks preset names and limits, and that every preset fits
+// within the ceiling.
+func (p Policy) Validate() error {
+	if err := p.Ceiling.Validate(); err != nil {
+		return fmt.Errorf("ceiling: %w", err)
+	}
+	for _, name := range p.PresetNames() {
+		if !presetNameRe.MatchString(name) {
+			return fmt.Errorf("invalid preset name %q: use lowercase letters, digits, '-' and '_'", name)
+		}
+		limits := p.Presets[name]
+		if err := limits.Validate(); err != nil {
+			return fmt.Errorf("preset %s: %w", name, err)
+		}
+		if err := p.CheckCeiling(limits); err != nil {
+			return fmt.Errorf("preset %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// PresetNames returns the preset names in sorted order.
+func (p Policy) PresetNames() []string {
+	names := make([]string, 0, len(p.Presets))
+	for name := range p.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckPreset returns an error if name is not one of the policy's presets.
+// The empty name (no preset) is always valid.
+func (p Policy) CheckPreset(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := p.Presets[name]; !ok {
+		return fmt.Errorf("unknown resource_preset %q", name)
+	}
+	return nil
+}
+
+// CheckCeiling returns an error if limits exceed the policy's ceiling.
+func (p Policy) CheckCeiling(l Limits) error {
+	if p.Ceiling.CPU != "" && l.CPU != "" && runtime.NanoCPUs(l.CPU) > runtime.NanoCPUs(p.Ceiling.CPU) {
+		return fmt.Errorf("cpu %s exceeds the ceiling of %s", l.CPU, p.Ceiling.CPU)
+	}
+	if p.Ceiling.Memory != "" && l.Memory != "" && runtime.MemoryBytes(l.Memory) > runtime.MemoryBytes(p.Ceiling.Memory) {
+		return fmt.Errorf("memory %s exceeds the ceiling of %s", l.Memory, p.Ceiling.Memory)
+	}
+	return nil
+}
+
+// CheckOverride validates an agent's "resources" payload: its cpu and memory
+// must parse and stay within the ceiling. A nil payload is valid.
+func (p Policy) CheckOverride(raw interface{}) error {
+	if raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("invalid resources: %w", err)
+	}
+	var res runtime.ResourceConfig
+	if err := json.Unmarshal(data, &res); err != nil {
+		return errors.New("resources must be an object with cpu and memory strings")
+	}
+	limits := Limits{CPU: res.CPU, Memory: res.Memory}
+	if err := limits.Validate(); err != nil {
+		return fmt.Errorf("resources: %w", err)
+	}
+	if err := p.CheckCeiling(limits); err != nil {
+		return fmt.Errorf("resources: %w", err)
+	}
+	return nil
+}
+
+// Resolve returns the resources for a container: the named preset, with any
+// field set in override taking precedence, clamped to the ceiling. Fields
+// still unset default to the ceiling, so a ceiling also bounds containers
+// with no preset. Clamping covers overrides saved before the ceiling was
+// lowered.
+func (p Policy) Resolve(preset string, override runtime.ResourceConfig) runtime.ResourceConfig {
+	res := override
+	if limits, ok := p.Presets[preset]; ok {
+		if res.CPU == "" {
+			res.CPU = limits.CPU
+		}
+		if res.Memory == "" {
+			res.Memory = limits.Memory
+		}
+	}
+	if p.Ceiling.CPU != "" && (res.CPU == "" || runtime.NanoCPUs(res.CPU) > runtime.NanoCPUs(p.Ceiling.CPU)) {
+		res.CPU = p.Ceiling.CPU
+	}
+	if p.Ceiling.Memory != "" && (res.Memory == "" || runtime.MemoryBytes(res.Memory) > runtime.MemoryBytes(p.Ceiling.Memory)) {
+		res.Memory = p.Ceiling.Memory
+	}
+	return res
+}
+
+// Load returns the organization's policy, falling back to DefaultPresets
+// and no ceiling when none has been saved.
+func Load(db *gorm.DB, orgID string) (Policy, error) {
+	policy := Policy{Presets: make(map[string]Limits, len(DefaultPresets))}
+	for name, limits := range DefaultPresets {
+		policy.Presets[name] = limits
+	}
+	var row models.ResourcePolicy
+	err := db.Where("org_id = ?", orgID).Limit(1).Find(&row).Error
+	if err != nil {
+		return policy, err
+	}
+	if row.OrgID == "" {
+		return policy, nil
+	}
+	policy.Ceiling = Limits{CPU: row.CeilingCPU, Memory: row.CeilingMemory}
+	if len(row.Presets) > 0 {
+		var presets map[string]Limits
+		if err := json.Unmarshal(row.Presets, &presets); err != nil {
+			return policy, fmt.Errorf("parsing resource presets: %w", err)
+		}
+		policy.Presets = presets
+	}
+	if policy.Presets == nil {
+		policy.Presets = map[string]Limits{}
+	}
+	return policy, nil
+}
+
+// Save stores the organization's policy. Callers validate it first.
+func Save(db *gorm.DB, orgID string, p Policy) error {
+	presets, err := json.Marshal(p.Presets)
+	if err != nil {
+		return err
+	}
+	row := models.ResourcePolicy{
+		OrgID:         orgID,
+		Presets:       models.JSON(presets),
+		CeilingCPU:    p.Ceiling.CPU,
+		CeilingMemory: p.Ceiling.Memory,
+	}
+	return db.Save(&row).Error
+}
+
+// ForLeader resolves the resources of team's leader container from the
+// team's preset and the leader's own resources. If the policy cannot be
+// loaded, the leader's own resources are used unchanged.
+func ForLeader(db *gorm.DB, team models.Team, leader models.Agent) runtime.ResourceConfig {
+	var override runtime.ResourceConfig
+	if len(leader.Resources) > 0 {
+		_ = json.Unmarshal(leader.Resources, &override)
+	}
+	policy, err := Load(db, team.OrgID)
+	if err != nil {
+		slog.Error("failed to load resource policy", "team", team.Name, "error", err)
+		return override
+	}
+	res := policy.Resolve(team.ResourcePreset, override)
+	if res.CPU != override.CPU || res.Memory != override.Memory {
+		slog.Info("applied resource policy to leader", "team", team.Name, "preset", team.ResourcePreset,
+			"cpu", res.CPU, "memory", res.Memory)
+	}
+	return res
+}
@@ -0,0 +1,128 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr bool
+	}{
+		{"defaults", Policy{Presets: DefaultPresets}, false},
+		{"defaults within ceiling", Policy{Presets: DefaultPresets, Ceiling: Limits{CPU: "4", Memory: "8g"}}, false},
+		{"preset above ceiling", Policy{Presets: DefaultPresets, Ceiling: Limits{CPU: "2"}}, true},
+		{"kubernetes quantities", Policy{Presets: map[string]Limits{"x": {CPU: "500m", Memory: "512Mi"}}, Ceiling: Limits{CPU: "1", Memory: "1g"}}, false},
+		{"kubernetes quantity above ceiling", Policy{Presets: map[string]Limits{"x": {Memory: "2Gi"}}, Ceiling: Limits{Memory: "1g"}}, true},
+		{"invalid cpu", Policy{Presets: map[string]Limits{"x": {CPU: "two"}}}, true},
+		{"invalid memory", Policy{Presets: map[string]Limits{"x": {Memory: "4GiB"}}}, true},
+		{"invalid ceiling", Policy{Ceiling: Limits{Memory: "lots"}}, true},
+		{"invalid name", Policy{Presets: map[string]Limits{"Big Box": {CPU: "1"}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_CheckOverride(t *testing.T) {
+	p := Policy{Presets: DefaultPresets, Ceiling: Limits{CPU: "4", Memory: "8g"}}
+
+	if err := p.CheckOverride(nil); err != nil {
+		t.Errorf("nil override: %v", err)
+	}
+	if err := p.CheckOverride(map[string]string{"cpu": "2", "memory": "8g"}); err != nil {
+		t.Errorf("override within ceiling: %v", err)
+	}
+	if err := p.CheckOverride(map[string]string{"cpu": "1500m", "memory": "512Mi"}); err != nil {
+		t.Errorf("kubernetes quantities within ceiling: %v", err)
+	}
+	if err := p.CheckOverride(map[string]string{"cpu": "4500m"}); err == nil {
+		t.Error("expected a millicore cpu above ceiling to be rejected")
+	}
+	if err := p.CheckOverride(map[string]string{"cpu": "8"}); err == nil {
+		t.Error("expected cpu above ceiling to be rejected")
+	}
+	if err := p.CheckOverride(map[string]string{"memory": "16g"}); err == nil {
+		t.Error("expected memory above ceiling to be rejected")
+	}
+	if err := p.CheckOverride("2 cores"); err == nil {
+		t.Error("expected non-object resources to be rejected")
+	}
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	p := Policy{Presets: DefaultPresets}
+
+	got := p.Resolve("medium", runtime.ResourceConfig{})
+	if got.CPU != "2" || got.Memory != "4g" {
+		t.Errorf("preset only: got %+v", got)
+	}
+
+	got = p.Resolve("medium", runtime.ResourceConfig{Memory: "6g", Timeout: 60})
+	if got.CPU != "2" || got.Memory != "6g" || got.Timeout != 60 {
+		t.Errorf("override wins: got %+v", got)
+	}
+
+	got = p.Resolve("", runtime.ResourceConfig{})
+	if got.CPU != "" || got.Memory != "" {
+		t.Errorf("no preset, no ceiling: got %+v", got)
+	}
+
+	p.Ceiling = Limits{CPU: "1", Memory: "3g"}
+	got = p.Resolve("medium", runtime.ResourceConfig{})
+	if got.CPU != "1" || got.Memory != "3g" {
+		t.Errorf("clamped to ceiling: got %+v", got)
+	}
+	got = p.Resolve("", runtime.ResourceConfig{Memory: "1g"})
+	if got.CPU != "1" || got.Memory != "1g" {
+		t.Errorf("unset field defaults to ceiling: got %+v", got)
+	}
+}
+
+func TestLoadSave(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	p, err := Load(db, "org-1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(p.Presets) != len(DefaultPresets) {
+		t.Errorf("default presets: got %d, want %d", len(p.Presets), len(DefaultPresets))
+	}
+	p.Presets["small"] = Limits{CPU: "0.1"}
+	if DefaultPresets["small"].CPU != "1" {
+		t.Fatal("Load must not share DefaultPresets")
+	}
+
+	custom := Policy{
+		Presets: map[string]Limits{"gpu-box": {CPU: "8", Memory: "32g"}},
+		Ceiling: Limits{CPU: "8", Memory: "32g"},
+	}
+	if err := Save(db, "org-1", custom); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := Load(db, "org-1")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(got.Presets) != 1 || got.Presets["gpu-box"].Memory != "32g" || got.Ceiling.CPU != "8" {
+		t.Errorf("round trip: got %+v", got)
+	}
+
+	other, _ := Load(db, "org-2")
+	if _, ok := other.Presets["gpu-box"]; ok {
+		t.Error("policy leaked across organizations")
+	}
+}
@@ -0,0 +1,332 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Operations a chaos fault can target.
+const (
+	ChaosOpDeployInfra     = "deploy_infra"
+	ChaosOpDeployAgent     = "deploy_agent"
+	ChaosOpStopAgent       = "stop_agent"
+	ChaosOpRemoveAgent     = "remove_agent"
+	ChaosOpStatus          = "status"
+	ChaosOpLogs            = "logs"
+	ChaosOpTeardownInfra   = "teardown_infra"
+	ChaosOpDeleteWorkspace = "delete_workspace"
+	ChaosOpExec            = "exec"
+	ChaosOpFiles           = "files"
+)
+
+var chaosOps = map[string]bool{
+	ChaosOpDeployInfra: true, ChaosOpDeployAgent: true, ChaosOpStopAgent: true,
+	ChaosOpRemoveAgent: true, ChaosOpStatus: true, ChaosOpLogs: true,
+	ChaosOpTeardownInfra: true, ChaosOpDeleteWorkspace: true, ChaosOpExec: true,
+	ChaosOpFiles: true,
+}
+
+// ChaosFault makes the calls of one operation fail, hang or report another
+// status.
+type ChaosFault struct {
+	// Op is the operation the fault applies to, such as deploy_agent.
+	Op string `yaml:"op"`
+	// Match limits the fault to calls whose target contains it: the team
+	// name for infrastructure operations, the container ID, which holds the
+	// team and agent names, for the others. Empty matches every call.
+	Match string `yaml:"match"`
+	// Delay holds each affected call this long before it returns, or until
+	// its context is cancelled.
+	Delay time.Duration `yaml:"delay"`
+	// Error, if set, makes affected calls fail with this message.
+	Error string `yaml:"error"`
+	// Status is reported by affected status calls instead of the
+	// container's status, such as "error" or "unhealthy".
+	Status string `yaml:"status"`
+	// After lets this many matching calls through before the fault applies.
+	After int `yaml:"after"`
+	// Times is the number of calls the fault applies to. Zero means all.
+	Times int `yaml:"times"`
+	// Probability is the chance the fault applies to a matching call, from
+	// 0 to 1. Zero means always.
+	Probability float64 `yaml:"probability"`
+
+	seen    int
+	applied int
+}
+
+// ChaosScenario is the set of faults a ChaosRuntime injects. The first fault
+// that applies to a call is used.
+type ChaosScenario struct {
+	Faults []ChaosFault `yaml:"faults"`
+}
+
+// LoadChaosScenario reads a scenario from a YAML or JSON file.
+func LoadChaosScenario(path string) (*ChaosScenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading chaos scenario: %w", err)
+	}
+	var scenario ChaosScenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("parsing chaos scenario %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("chaos scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// Validate checks that every fault names a known operation and does
+// something.
+func (s *ChaosScenario) Validate() error {
+	for i, f := range s.Faults {
+		switch {
+		case !chaosOps[f.Op]:
+			return fmt.Errorf("fault %d: unknown op %q", i+1, f.Op)
+		case f.Status != "" && f.Op != ChaosOpStatus:
+			return fmt.Errorf("fault %d: status only applies to the %s op", i+1, ChaosOpStatus)
+		case f.Error == "" && f.Delay <= 0 && f.Status == "":
+			return fmt.Errorf("fault %d: set error, delay or status", i+1)
+		case f.Delay < 0 || f.After < 0 || f.Times < 0 || f.Probability < 0 || f.Probability > 1:
+			return fmt.Errorf("fault %d: delay, after, times and probability cannot be negative, and probability is at most 1", i+1)
+		}
+	}
+	return nil
+}
+
+// ChaosRuntime is an in-memory runtime for integration tests and demos. It
+// runs no containers: deployed agents are only recorded, and report running
+// until they are stopped. Calls succeed unless a fault of its scenario
+// makes them fail, hang or report another status.
+type ChaosRuntime struct {
+	// NATSURL is returned by GetNATSConnectURL.
+	NATSURL string
+
+	mu       sync.Mutex
+	scenario *ChaosScenario
+	agents   map[string]*chaosAgent // by container ID
+}
+
+// chaosAgent is an agent deployed on a ChaosRuntime.
+type chaosAgent struct {
+	team   string
+	status AgentStatus
+}
+
+// NewChaosRuntime creates a ChaosRuntime that injects the faults of the
+// scenario file at path. An empty path injects none. The NATS URL reported
+// to the API is CHAOS_NATS_URL, or nats://127.0.0.1:14222.
+func NewChaosRuntime(path string) (*ChaosRuntime, error) {
+	scenario := &ChaosScenario{}
+	if path != "" {
+		var err error
+		if scenario, err = LoadChaosScenario(path); err != nil {
+			return nil, err
+		}
+	}
+	natsURL := os.Getenv("CHAOS_NATS_URL")
+	if natsURL == "" {
+		natsURL = "nats://127.0.0.1:14222"
+	}
+	return &ChaosRuntime{
+		NATSURL:  natsURL,
+		scenario: scenario,
+		agents:   make(map[string]*chaosAgent),
+	}, nil
+}
+
+// SetScenario replaces the faults the runtime injects.
+func (c *ChaosRuntime) SetScenario(scenario *ChaosScenario) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scenario = scenario
+}
+
+// fault returns the first fault of the scenario that applies to this call
+// of op on target, and counts the call.
+func (c *ChaosRuntime) fault(op, target string) *ChaosFault {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.scenario.Faults {
+		f := &c.scenario.Faults[i]
+		if f.Op != op || !strings.Contains(target, f.Match) {
+			continue
+		}
+		f.seen++
+		if f.seen <= f.After || (f.Times > 0 && f.applied >= f.Times) {
+			continue
+		}
+		if f.Probability > 0 && rand.Float64() >= f.Probability {
+			continue
+		}
+		f.applied++
+		copied := *f
+		return &copied
+	}
+	return nil
+}
+
+// inject applies the fault for this call of op on target, if any. It
+// returns the fault, or nil, and the error the call must fail with.
+func (c *ChaosRuntime) inject(ctx context.Context, op, target string) (*ChaosFault, error) {
+	f := c.fault(op, target)
+	if f == nil {
+		return nil, nil
+	}
+	slog.Info("chaos: injecting fault", "op", op, "target", target,
+		"delay", f.Delay, "error", f.Error, "status", f.Status)
+	if f.Delay > 0 {
+		select {
+		case <-time.After(f.Delay):
+		case <-ctx.Done():
+			return f, ctx.Err()
+		}
+	}
+	if f.Error != "" {
+		return f, fmt.Errorf("chaos: %s %s: %s", op, target, f.Error)
+	}
+	return f, nil
+}
+
+// agent returns the recorded agent with the given container ID.
+func (c *ChaosRuntime) agent(id string) (*AgentStatus, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a, ok := c.agents[id]
+	if !ok {
+		return nil, fmt.Errorf("container %s not found", id)
+	}
+	status := a.status
+	return &status, nil
+}
+
+func (c *ChaosRuntime) DeployInfra(ctx context.Context, config InfraConfig) error {
+	_, err := c.inject(ctx, ChaosOpDeployInfra, config.TeamName)
+	return err
+}
+
+func (c *ChaosRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*AgentInstance, error) {
+	id := "chaos-" + config.TeamName + "-" + config.Name
+	if _, err := c.inject(ctx, ChaosOpDeployAgent, id); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.agents[id] = &chaosAgent{
+		team:   config.TeamName,
+		status: AgentStatus{ID: id, Name: config.Name, Status: StatusRunning, StartedAt: time.Now()},
+	}
+	c.mu.Unlock()
+	return &AgentInstance{ID: id, Name: config.Name, Status: StatusRunning}, nil
+}
+
+func (c *ChaosRuntime) StopAgent(ctx context.Context, id string) error {
+	if _, err := c.inject(ctx, ChaosOpStopAgent, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if a, ok := c.agents[id]; ok {
+		a.status.Status = StatusStopped
+	}
+	return nil
+}
+
+func (c *ChaosRuntime) RemoveAgent(ctx context.Context, id string) error {
+	if _, err := c.inject(ctx, ChaosOpRemoveAgent, id); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.agents, id)
+	return nil
+}
+
+func (c *ChaosRuntime) GetStatus(ctx context.Context, id string) (*AgentStatus, error) {
+	f, err := c.inject(ctx, ChaosOpStatus, id)
+	if err != nil {
+		return nil, err
+	}
+	status, err := c.agent(id)
+	if err != nil {
+		return nil, err
+	}
+	if f != nil && f.Status != "" {
+		status.Status = f.Status
+	}
+	return status, nil
+}
+
+func (c *ChaosRuntime) StreamLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	if _, err := c.inject(ctx, ChaosOpLogs, id); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(strings.NewReader("mock log output\n")), nil
+}
+
+// TeardownInfra forgets the team's agents.
+func (c *ChaosRuntime) TeardownInfra(ctx context.Context, teamName string, _ TeardownOptions) error {
+	if _, err := c.inject(ctx, ChaosOpTeardownInfra, teamName); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, a := range c.agents {
+		if a.team == teamName {
+			delete(c.agents, id)
+		}
+	}
+	return nil
+}
+
+func (c *ChaosRuntime) DeleteWorkspace(ctx context.Context, teamName string) error {
+	_, err := c.inject(ctx, ChaosOpDeleteWorkspace, teamName)
+	return err
+}
+
+func (c *ChaosRuntime) GetNATSURL(teamName string) string {
+	return "nats://team-" + teamName + "-nats:4222"
+}
+
+func (c *ChaosRuntime) GetNATSConnectURL(_ context.Context, _ string) (string, error) {
+	return c.NATSURL, nil
+}
+
+func (c *ChaosRuntime) ExecInContainer(ctx context.Context, id string, _ []string) (string, error) {
+	if _, err := c.inject(ctx, ChaosOpExec, id); err != nil {
+		return "", err
+	}
+	return "mock exec output", nil
+}
+
+func (c *ChaosRuntime) ReadFile(ctx context.Context, containerID string, path string) ([]byte, error) {
+	if err := ValidateAgentFilePath(path); err != nil {
+		return nil, err
+	}
+	if _, err := c.inject(ctx, ChaosOpFiles, containerID); err != nil {
+		return nil, err
+	}
+	return []byte("# Mock file content\n"), nil
+}
+
+func (c *ChaosRuntime) WriteFile(ctx context.Context, containerID string, path string, _ []byte) error {
+	if err := ValidateAgentFilePath(path); err != nil {
+		return err
+	}
+	_, err := c.inject(ctx, ChaosOpFiles, containerID)
+	return err
+}
+
+func (c *ChaosRuntime) CopyToContainer(ctx context.Context, containerID string, _ string, _ []byte) error {
+	_, err := c.inject(ctx, ChaosOpFiles, containerID)
+	return err
+}
@@ -0,0 +1,124 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaosRuntime_NoFaults(t *testing.T) {
+	rt, err := NewChaosRuntime("")
+	if err != nil {
+		t.Fatalf("NewChaosRuntime: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := rt.DeployInfra(ctx, InfraConfig{TeamName: "demo"}); err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	inst, err := rt.DeployAgent(ctx, AgentConfig{TeamName: "demo", Name: "leader"})
+	if err != nil {
+		t.Fatalf("DeployAgent: %v", err)
+	}
+	if st, err := rt.GetStatus(ctx, inst.ID); err != nil || st.Status != StatusRunning {
+		t.Fatalf("GetStatus: got %+v, %v; want running", st, err)
+	}
+	if err := rt.StopAgent(ctx, inst.ID); err != nil {
+		t.Fatalf("StopAgent: %v", err)
+	}
+	if st, _ := rt.GetStatus(ctx, inst.ID); st.Status != StatusStopped {
+		t.Errorf("status after stop: got %q, want stopped", st.Status)
+	}
+	if err := rt.TeardownInfra(ctx, "demo", TeardownOptions{}); err != nil {
+		t.Fatalf("TeardownInfra: %v", err)
+	}
+	if _, err := rt.GetStatus(ctx, inst.ID); err == nil {
+		t.Error("agent still known after teardown")
+	}
+}
+
+func TestChaosRuntime_FaultAfterAndTimes(t *testing.T) {
+	rt, _ := NewChaosRuntime("")
+	rt.SetScenario(&ChaosScenario{Faults: []ChaosFault{
+		{Op: ChaosOpDeployAgent, Match: "leader", Error: "image pull failed", After: 1, Times: 1},
+	}})
+	ctx := context.Background()
+
+	deploy := func(team, name string) error {
+		_, err := rt.DeployAgent(ctx, AgentConfig{TeamName: team, Name: name})
+		return err
+	}
+	if err := deploy("a", "leader"); err != nil {
+		t.Fatalf("first deploy: %v, want it let through", err)
+	}
+	if err := deploy("a", "worker"); err != nil {
+		t.Fatalf("unmatched deploy: %v", err)
+	}
+	if err := deploy("b", "leader"); err == nil || !strings.Contains(err.Error(), "image pull failed") {
+		t.Fatalf("second deploy: got %v, want the injected error", err)
+	}
+	if err := deploy("c", "leader"); err != nil {
+		t.Fatalf("third deploy: %v, want the fault used up", err)
+	}
+}
+
+func TestChaosRuntime_DelayHonorsContext(t *testing.T) {
+	rt, _ := NewChaosRuntime("")
+	rt.SetScenario(&ChaosScenario{Faults: []ChaosFault{{Op: ChaosOpDeployInfra, Delay: time.Hour}}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := rt.DeployInfra(ctx, InfraConfig{TeamName: "slow"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DeployInfra: got %v, want deadline exceeded", err)
+	}
+}
+
+func TestChaosRuntime_StatusOverride(t *testing.T) {
+	rt, _ := NewChaosRuntime("")
+	ctx := context.Background()
+	inst, _ := rt.DeployAgent(ctx, AgentConfig{TeamName: "flaky", Name: "leader"})
+	rt.SetScenario(&ChaosScenario{Faults: []ChaosFault{{Op: ChaosOpStatus, Match: "flaky", Status: StatusUnhealthy}}})
+
+	if st, err := rt.GetStatus(ctx, inst.ID); err != nil || st.Status != StatusUnhealthy {
+		t.Errorf("GetStatus: got %+v, %v; want unhealthy", st, err)
+	}
+}
+
+func TestLoadChaosScenario(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "scenario.yaml")
+	os.WriteFile(path, []byte(`faults:
+  - op: deploy_agent
+    match: leader
+    delay: 2s
+    error: image pull failed
+    times: 1
+  - op: status
+    status: error
+    probability: 0.5
+`), 0o644)
+
+	scenario, err := LoadChaosScenario(path)
+	if err != nil {
+		t.Fatalf("LoadChaosScenario: %v", err)
+	}
+	if len(scenario.Faults) != 2 || scenario.Faults[0].Delay != 2*time.Second || scenario.Faults[1].Probability != 0.5 {
+		t.Errorf("scenario: got %+v", scenario.Faults)
+	}
+
+	for _, bad := range []string{
+		"faults: [{op: reboot, error: x}]",
+		"faults: [{op: deploy_agent, status: error}]",
+		"faults: [{op: stop_agent}]",
+		"faults: [{op: exec, error: x, probability: 2}]",
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := LoadChaosScenario(path); err == nil {
+			t.Errorf("LoadChaosScenario(%q): want an error", bad)
+		}
+	}
+}
@@ -14,8 +14,10 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
@@ -24,51 +26,45 @@ import (
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
-)
 
-// sanitizeName converts a display name into a Docker-safe slug using the shared
-// SanitizeName function from the api package. This is a runtime-local wrapper
-// to keep calling code clean.
-func sanitizeName(name string) string {
-	s := strings.ToLower(strings.TrimSpace(name))
-	s = strings.ReplaceAll(s, " ", "-")
-	// Strip anything not [a-z0-9_-].
-	var b strings.Builder
-	for _, r := range s {
-		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
-			b.WriteRune(r)
-		}
-	}
-	s = b.String()
-	// Collapse consecutive hyphens.
-	for strings.Contains(s, "--") {
-		s = strings.ReplaceAll(s, "--", "-")
-	}
-	s = strings.Trim(s, "-")
-	if len(s) > 62 {
-		s = s[:62]
-		s = strings.TrimRight(s, "-")
-	}
-	if s == "" {
-		s = "team"
-	}
-	return s
-}
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
 
 // registryAuth returns the base64-encoded RegistryAuth string for pulling an image.
-// It reads credentials from the Docker config.json ($DOCKER_CONFIG or $HOME/.docker).
 // Returns empty string if no credentials are found (falls back to unauthenticated pull).
 func registryAuth(imageName string) string {
+	_, username, password, ok := RegistryCredentials(imageName)
+	if !ok {
+		return ""
+	}
+	// The Docker API expects base64(JSON{"username","password"}).
+	authJSON, _ := json.Marshal(map[string]string{
+		"username": username,
+		"password": password,
+	})
+	return base64.URLEncoding.EncodeToString(authJSON)
+}
+
+// RegistryCredentials returns the registry host of an image and the
+// credentials stored for it in the Docker config.json ($DOCKER_CONFIG or
+// $HOME/.docker). ok is false when there are none.
+func RegistryCredentials(imageName string) (registry, username, password string, ok bool) {
+	// Extract registry hostname from image name.
+	registry = "docker.io"
+	if parts := strings.SplitN(imageName, "/", 2); len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
+		registry = parts[0]
+	}
+
 	configDir := os.Getenv("DOCKER_CONFIG")
 	if configDir == "" {
 		home, _ := os.UserHomeDir()
 		configDir = filepath.Join(home, ".docker")
 	}
-	configPath := filepath.Join(configDir, "config.json")
-
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
 	if err != nil {
-		return ""
+		return registry, "", "", false
 	}
 
 	var dockerConfig struct {
@@ -77,36 +73,24 @@ func registryAuth(imageName string) string {
 		} `json:"auths"`
 	}
 	if err := json.Unmarshal(data, &dockerConfig); err != nil {
-		return ""
-	}
-
-	// Extract registry hostname from image name.
-	registry := "docker.io"
-	if parts := strings.SplitN(imageName, "/", 2); len(parts) == 2 && strings.ContainsAny(parts[0], ".:") {
-		registry = parts[0]
+		return registry, "", "", false
 	}
 
 	entry, ok := dockerConfig.Auths[registry]
 	if !ok || entry.Auth == "" {
-		return ""
+		return registry, "", "", false
 	}
 
 	// The config.json "auth" field is base64(username:password).
-	// The Docker API expects base64(JSON{"username","password"}).
 	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
 	if err != nil {
-		return ""
+		return registry, "", "", false
 	}
-	parts := strings.SplitN(string(decoded), ":", 2)
-	if len(parts) != 2 {
-		return ""
+	username, password, ok = strings.Cut(string(decoded), ":")
+	if !ok {
+		return registry, "", "", false
 	}
-
-	authJSON, _ := json.Marshal(map[string]string{
-		"username": parts[0],
-		"password": parts[1],
-	})
-	return base64.URLEncoding.EncodeToString(authJSON)
+	return registry, username, password, true
 }
 
 // pullImageIfNeeded pulls an image from the registry when it is missing locally.
@@ -120,7 +104,11 @@ func (d *DockerRuntime) pullImageIfNeeded(ctx context.Context, img string) error
 			return nil
 		}
 	}
+	return d.pullImage(ctx, img)
+}
 
+// pullImage pulls an image from the registry unconditionally.
+func (d *DockerRuntime) pullImage(ctx context.Context, img string) error {
 	slog.Info("pulling image", "image", img)
 	reader, err := d.client.ImagePull(ctx, img, image.PullOptions{
 		RegistryAuth: registryAuth(img),
@@ -129,7 +117,7 @@ func (d *DockerRuntime) pullImageIfNeeded(ctx context.Context, img string) error
 		return fmt.Errorf("pulling image %s: %w", img, err)
 	}
 	defer reader.Close()
-	_, _ = io.Copy(io.Discard, reader)
+	reportPullProgress(ctx, img, reader)
 	return nil
 }
 
@@ -151,7 +139,7 @@ func isLatestTag(img string) bool {
 
 // GetNATSURL returns the NATS URL for a team in Docker runtime (internal container network).
 func (d *DockerRuntime) GetNATSURL(teamName string) string {
-	return "nats://team-" + sanitizeName(teamName) + "-nats:4222"
+	return "nats://team-" + naming.Slug(teamName) + "-nats:4222"
 }
 
 // GetNATSConnectURL returns a host-accessible NATS URL by inspecting the container's
@@ -159,7 +147,7 @@ func (d *DockerRuntime) GetNATSURL(teamName string) string {
 // the Docker network. When the API itself runs inside a Docker container,
 // it uses host.docker.internal instead of 127.0.0.1.
 func (d *DockerRuntime) GetNATSConnectURL(ctx context.Context, teamName string) (string, error) {
-	containerName := natsContainerName(sanitizeName(teamName))
+	containerName := natsContainerName(naming.Slug(teamName))
 	info, err := d.client.ContainerInspect(ctx, containerName)
 	if err != nil {
 		return "", fmt.Errorf("inspecting nats container %s: %w", containerName, err)
@@ -171,7 +159,10 @@ func (d *DockerRuntime) GetNATSConnectURL(ctx context.Context, teamName string)
 	}
 
 	hostPort := bindings[0].HostPort
-	host := natsHostAddress()
+	host := d.remoteHost
+	if host == "" {
+		host = natsHostAddress()
+	}
 	url := "nats://" + host + ":" + hostPort
 	slog.Info("resolved team NATS connect URL", "team", teamName, "container", containerName, "url", url)
 	return url, nil
@@ -246,6 +237,13 @@ func hexToIP(hex string) (string, error) {
 // DockerRuntime implements AgentRuntime using the Docker Engine API.
 type DockerRuntime struct {
 	client *client.Client
+	// remoteHost is the address of a daemon on another machine (see
+	// MultiHostDockerRuntime). Mapped NATS ports are reached there, and host
+	// paths are not checked on the API's machine.
+	remoteHost string
+
+	rootlessOnce sync.Once
+	rootless     bool
 }
 
 // NewDockerRuntime creates a DockerRuntime using the default Docker client from env.
@@ -258,8 +256,31 @@ func NewDockerRuntime() (*DockerRuntime, error) {
 	return &DockerRuntime{client: cli}, nil
 }
 
+// isRootless reports whether the Docker daemon runs rootless. It is asked
+// once; if the daemon cannot be queried, it is assumed to run as root.
+func (d *DockerRuntime) isRootless(ctx context.Context) bool {
+	d.rootlessOnce.Do(func() {
+		info, err := d.client.Info(ctx)
+		if err != nil {
+			slog.Warn("could not query docker daemon info, assuming rootful", "error", err)
+			return
+		}
+		d.rootless = rootlessFromSecurityOptions(info.SecurityOptions)
+		if d.rootless {
+			slog.Info("docker daemon is rootless, agents keep container root on bind-mounted workspaces")
+		}
+	})
+	return d.rootless
+}
+
 func teamNetworkName(teamName string) string { return "team-" + teamName }
 func teamVolumeName(teamName string) string  { return "team-" + teamName + "-workspace" }
+
+// teamConfigVolumeName returns the volume that holds a generated config dir
+// (e.g. ".claude") when the team uses the "separate" config dir mode.
+func teamConfigVolumeName(teamName, dir string) string {
+	return "team-" + teamName + "-config-" + strings.TrimPrefix(dir, ".")
+}
 func natsContainerName(teamName string) string {
 	return "team-" + teamName + "-nats"
 }
@@ -278,13 +299,20 @@ func isAlreadyExistsErr(err error) bool {
 
 // DeployInfra creates the shared Docker network, NATS container, and workspace volume.
 func (d *DockerRuntime) DeployInfra(ctx context.Context, config InfraConfig) error {
-	config.TeamName = sanitizeName(config.TeamName)
+	config.TeamName = naming.Slug(config.TeamName)
 	netName := teamNetworkName(config.TeamName)
 	slog.Info("deploying team infrastructure", "team", config.TeamName, "network", netName)
 
+	// Refuse to share the network of another team with the same slug.
+	if existing, err := d.client.NetworkInspect(ctx, netName, network.InspectOptions{}); err == nil {
+		if err := checkTeamOwner(config.TeamName, config.TeamID, existing.Labels); err != nil {
+			return err
+		}
+	}
+
 	// Create network (idempotent).
 	_, err := d.client.NetworkCreate(ctx, netName, network.CreateOptions{
-		Labels: map[string]string{LabelTeam: config.TeamName},
+		Labels: teamLabels(config),
 	})
 	if err != nil && !isAlreadyExistsErr(err) {
 		return fmt.Errorf("creating network %s: %w", netName, err)
@@ -294,7 +322,7 @@ func (d *DockerRuntime) DeployInfra(ctx context.Context, config InfraConfig) err
 	volName := teamVolumeName(config.TeamName)
 	_, err = d.client.VolumeCreate(ctx, volume.CreateOptions{
 		Name:   volName,
-		Labels: map[string]string{LabelTeam: config.TeamName},
+		Labels: teamLabels(config),
 	})
 	if err != nil && !isAlreadyExistsErr(err) {
 		return fmt.Errorf("creating volume %s: %w", volName, err)
@@ -302,7 +330,7 @@ func (d *DockerRuntime) DeployInfra(ctx context.Context, config InfraConfig) err
 
 	// Start NATS container.
 	if config.NATSEnabled {
-		if err := d.startNATS(ctx, config.TeamName, netName); err != nil {
+		if err := d.startNATS(ctx, config.TeamName, netName, config.NATS, config.Restart.natsRestartPolicy()); err != nil {
 			return fmt.Errorf("starting nats: %w", err)
 		}
 	}
@@ -311,8 +339,22 @@ func (d *DockerRuntime) DeployInfra(ctx context.Context, config InfraConfig) err
 	return nil
 }
 
-func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string) error {
+// updateRestartPolicy applies restart to a running container whose restart
+// policy differs, without recreating it.
+func (d *DockerRuntime) updateRestartPolicy(ctx context.Context, info types.ContainerJSON, restart RestartPolicy) error {
+	policy := restart.dockerRestartPolicy()
+	if info.HostConfig != nil && info.HostConfig.RestartPolicy == policy {
+		return nil
+	}
+	if _, err := d.client.ContainerUpdate(ctx, info.ID, container.UpdateConfig{RestartPolicy: policy}); err != nil {
+		return fmt.Errorf("updating restart policy of %s: %w", info.Name, err)
+	}
+	return nil
+}
+
+func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string, cfg NATSConfig, restart RestartPolicy) error {
 	containerName := natsContainerName(teamName)
+	natsImage := cfg.image()
 
 	// Check if NATS container already exists.
 	info, err := d.client.ContainerInspect(ctx, containerName)
@@ -328,9 +370,12 @@ func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string)
 			if expectedToken != containerToken {
 				slog.Info("nats container auth token mismatch, recreating",
 					"name", containerName)
+			} else if info.Config.Image != natsImage || info.Config.Labels[LabelNATSLimits] != cfg.limitsLabel() {
+				slog.Info("nats container image or limits changed, recreating",
+					"name", containerName, "image", natsImage)
 			} else {
 				slog.Info("nats container already running with port binding", "name", containerName)
-				return nil
+				return d.updateRestartPolicy(ctx, info, restart)
 			}
 		} else if info.State.Running && !hasPortBinding {
 			// Container exists but missing port binding — recreate.
@@ -342,10 +387,11 @@ func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string)
 	}
 
 	// Pull NATS image if not present locally.
-	if err := d.pullImageIfNeeded(ctx, NATSImage); err != nil {
+	if err := d.pullImageIfNeeded(ctx, natsImage); err != nil {
 		return fmt.Errorf("nats image: %w", err)
 	}
 
+	ReportProgress(ctx, "Starting NATS")
 	// Build NATS command with JetStream and auth token.
 	natsCmd := []string{"--jetstream"}
 	if token := os.Getenv("NATS_AUTH_TOKEN"); token != "" {
@@ -353,17 +399,22 @@ func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string)
 	} else {
 		slog.Warn("NATS_AUTH_TOKEN not set, NATS running without authentication")
 	}
+	serverConfig := cfg.serverConfig()
+	if serverConfig != "" {
+		natsCmd = append(natsCmd, "--config", "/"+natsConfigFile)
+	}
 
 	resp, err := d.client.ContainerCreate(ctx,
 		&container.Config{
-			Image: NATSImage,
+			Image: natsImage,
 			Cmd:   natsCmd,
 			ExposedPorts: nat.PortSet{
 				"4222/tcp": struct{}{},
 			},
 			Labels: map[string]string{
-				LabelTeam: teamName,
-				LabelRole: "nats",
+				LabelTeam:       teamName,
+				LabelRole:       "nats",
+				LabelNATSLimits: cfg.limitsLabel(),
 			},
 		},
 		&container.HostConfig{
@@ -372,10 +423,7 @@ func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string)
 					{HostIP: "0.0.0.0", HostPort: "0"}, // all interfaces — API container reaches host via gateway IP
 				},
 			},
-			RestartPolicy: container.RestartPolicy{
-				Name:              "on-failure",
-				MaximumRetryCount: 5,
-			},
+			RestartPolicy: restart.dockerRestartPolicy(),
 		},
 		&network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{
@@ -389,11 +437,24 @@ func (d *DockerRuntime) startNATS(ctx context.Context, teamName, netName string)
 		return fmt.Errorf("creating nats container: %w", err)
 	}
 
+	// The container is not running yet, so the file is copied in as a tar
+	// archive rather than written with exec.
+	if serverConfig != "" {
+		archive, err := singleFileTar(natsConfigFile, serverConfig)
+		if err != nil {
+			return err
+		}
+		if err := d.client.CopyToContainer(ctx, resp.ID, "/", archive, container.CopyToContainerOptions{}); err != nil {
+			return fmt.Errorf("writing nats config: %w", err)
+		}
+	}
+
 	if err := d.client.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		return fmt.Errorf("starting nats container: %w", err)
 	}
 
 	slog.Info("nats container started", "id", resp.ID, "name", containerName)
+	ReportProgress(ctx, "NATS started")
 	return nil
 }
 
@@ -411,8 +472,8 @@ func extractNATSAuthToken(cmd []string) string {
 
 // DeployAgent creates and starts an agent container.
 func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*AgentInstance, error) {
-	config.TeamName = sanitizeName(config.TeamName)
-	config.Name = sanitizeName(config.Name)
+	config.TeamName = naming.Slug(config.TeamName)
+	config.Name = naming.Slug(config.Name)
 	img := config.Image
 	if img == "" {
 		if config.Provider == "opencode" {
@@ -423,7 +484,8 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	}
 
 	// Validate workspace path exists on the host before attempting to mount it.
-	if config.WorkspacePath != "" {
+	// A remote daemon's paths cannot be checked from here.
+	if config.WorkspacePath != "" && d.remoteHost == "" {
 		info, err := os.Stat(config.WorkspacePath)
 		if err != nil {
 			return nil, fmt.Errorf("workspace path %q does not exist: %w", config.WorkspacePath, err)
@@ -431,6 +493,24 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 		if !info.IsDir() {
 			return nil, fmt.Errorf("workspace path %q is not a directory", config.WorkspacePath)
 		}
+		if config.ConfigDirMode == models.ConfigDirModeShared {
+			if _, err := os.Stat(SharedWorktreePath(config.WorkspacePath, config.TeamName)); err != nil {
+				return nil, fmt.Errorf("team worktree in shared workspace %q: %w", config.WorkspacePath, err)
+			}
+		}
+	}
+
+	for _, w := range config.ExtraWorkspaces {
+		if d.remoteHost != "" {
+			break
+		}
+		info, err := os.Stat(w.HostPath)
+		if err != nil {
+			return nil, fmt.Errorf("extra workspace %q: path %q does not exist: %w", w.Name, w.HostPath, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("extra workspace %q: path %q is not a directory", w.Name, w.HostPath)
+		}
 	}
 
 	containerName := agentContainerName(config.TeamName, config.Name)
@@ -442,8 +522,13 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	// Remove any stale container with the same name from a previous failed deploy.
 	_ = d.client.ContainerRemove(ctx, containerName, container.RemoveOptions{Force: true})
 
-	// Pull image if not present locally (IfNotPresent policy).
-	if err := d.pullImageIfNeeded(ctx, img); err != nil {
+	// Pull image if not present locally (IfNotPresent policy), or always
+	// when the caller asks for a newer one.
+	pull := d.pullImageIfNeeded
+	if config.PullImage {
+		pull = d.pullImage
+	}
+	if err := pull(ctx, img); err != nil {
 		return nil, fmt.Errorf("agent image: %w", err)
 	}
 
@@ -461,6 +546,7 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 		"AGENT_ROLE=" + config.Role,
 		"AGENT_PROVIDER=" + config.Provider,
 		"AGENT_PERMISSIONS=" + string(permJSON),
+		"AGENT_ADMIN_LISTEN=" + SidecarAdminListen,
 	}
 
 	if natsToken != "" {
@@ -471,6 +557,18 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	if config.WorkspacePath != "" {
 		env = append(env, "WORKSPACE_PATH=/workspace")
 	}
+	if len(config.ExtraWorkspaces) > 0 {
+		env = append(env, "AGENT_EXTRA_WORKSPACES="+protocol.ExtraWorkspaceScope(config.ExtraWorkspaces))
+	}
+	if backoff := config.Restart.backoffEnv(); backoff != "" {
+		env = append(env, "AGENT_RESTART_BACKOFF="+backoff)
+	}
+	rootless := d.isRootless(ctx)
+	if rootless && config.WorkspacePath != "" && config.RunAsUID != nil {
+		slog.Warn("ignoring run_as_uid on rootless docker, the agent runs as the daemon's user",
+			"agent", config.Name, "run_as_uid", *config.RunAsUID)
+	}
+	env = append(env, workspaceOwnerEnv(config, rootless)...)
 
 	// Pass CLAUDE.md / AGENTS.MD content via env var so the sidecar writes it at startup.
 	if config.ClaudeMD != "" {
@@ -539,31 +637,57 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	// Resource limits.
 	resources := container.Resources{}
 	if config.Resources.Memory != "" {
-		resources.Memory = parseMemoryLimit(config.Resources.Memory)
+		resources.Memory = MemoryBytes(config.Resources.Memory)
 	}
 	if config.Resources.CPU != "" {
-		resources.NanoCPUs = parseCPULimit(config.Resources.CPU)
+		resources.NanoCPUs = NanoCPUs(config.Resources.CPU)
 	}
 
 	// Workspace permissions are handled by the agent container's entrypoint
 	// script (entrypoint.sh), which detects the workspace owner UID/GID and
-	// drops privileges to match. This works correctly on Linux where Docker
-	// runs natively and respects real UID/GID ownership.
+	// drops privileges to match, unless workspaceOwnerEnv chose the user.
 
 	// Determine workspace bind: use host path (bind mount) if provided,
 	// otherwise fall back to the shared Docker volume.
 	binds := []string{}
-	if config.WorkspacePath != "" {
-		binds = append(binds, config.WorkspacePath+":/workspace")
+	if config.WorkspacePath != "" && config.ConfigDirMode == models.ConfigDirModeShared {
+		// In "shared" mode the team works in its own git worktree of the
+		// workspace, which needs the repository's .git directory.
+		binds = append(binds,
+			DockerHostPath(SharedWorktreePath(config.WorkspacePath, config.TeamName))+":/workspace",
+			DockerHostPath(filepath.Join(config.WorkspacePath, ".git"))+":"+sharedGitDirMount)
+	} else if config.WorkspacePath != "" {
+		binds = append(binds, DockerHostPath(config.WorkspacePath)+":/workspace")
+		// In "separate" mode, overlay the generated config dirs with named
+		// volumes so agent infra files never land in the user's repository.
+		if config.ConfigDirMode == models.ConfigDirModeSeparate {
+			for _, dir := range GeneratedConfigDirs {
+				binds = append(binds, teamConfigVolumeName(config.TeamName, dir)+":/workspace/"+dir)
+			}
+		}
 	} else {
 		binds = append(binds, volName+":/workspace")
 	}
+	for _, w := range config.ExtraWorkspaces {
+		bind := DockerHostPath(w.HostPath) + ":" + w.MountPath()
+		if w.ReadOnly {
+			bind += ":ro"
+		}
+		binds = append(binds, bind)
+	}
 
 	resp, err := d.client.ContainerCreate(ctx,
 		&container.Config{
 			Image: img,
 			User:  "0:0", // Start as root so entrypoint.sh can fix workspace permissions and drop privileges via gosu.
 			Env:   env,
+			Healthcheck: &container.HealthConfig{
+				Test:        append([]string{"CMD"}, sidecarHealthCommand()...),
+				Interval:    healthcheckInterval,
+				Timeout:     healthcheckTimeout,
+				StartPeriod: healthcheckStartPeriod,
+				Retries:     healthcheckRetries,
+			},
 			Labels: map[string]string{
 				LabelTeam:  config.TeamName,
 				LabelAgent: config.Name,
@@ -571,8 +695,9 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 			},
 		},
 		&container.HostConfig{
-			Binds:     binds,
-			Resources: resources,
+			Binds:         binds,
+			Resources:     resources,
+			RestartPolicy: config.Restart.dockerRestartPolicy(),
 		},
 		&network.NetworkingConfig{
 			EndpointsConfig: map[string]*network.EndpointSettings{
@@ -591,6 +716,7 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	}
 
 	slog.Info("agent container started", "id", resp.ID, "agent", config.Name)
+	ReportProgress(ctx, "Started container of agent %s", config.Name)
 	return &AgentInstance{
 		ID:     resp.ID,
 		Name:   config.Name,
@@ -598,9 +724,14 @@ func (d *DockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*A
 	}, nil
 }
 
+// agentStopTimeout is how many seconds Docker waits after SIGTERM before
+// killing an agent container. It leaves room for the sidecar's shutdown grace
+// period so an in-flight run can finish.
+const agentStopTimeout = 30
+
 // StopAgent stops a running agent container.
 func (d *DockerRuntime) StopAgent(ctx context.Context, id string) error {
-	timeout := 30
+	timeout := agentStopTimeout
 	return d.client.ContainerStop(ctx, id, container.StopOptions{Timeout: &timeout})
 }
 
@@ -612,24 +743,34 @@ func (d *DockerRuntime) RemoveAgent(ctx context.Context, id string) error {
 // GetStatus inspects a container and returns its status.
 func (d *DockerRuntime) GetStatus(ctx context.Context, id string) (*AgentStatus, error) {
 	info, err := d.client.ContainerInspect(ctx, id)
+	if client.IsErrNotFound(err) {
+		return nil, fmt.Errorf("inspecting container %s: %w", id, ErrAgentNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("inspecting container %s: %w", id, err)
 	}
 
-	status := "stopped"
+	status := StatusStopped
 	if info.State.Running {
-		status = "running"
+		status = StatusRunning
+		if info.State.Health != nil && info.State.Health.Status == types.Unhealthy {
+			status = StatusUnhealthy
+		}
+	} else if info.State.Restarting {
+		// Exited, but its restart policy brings it back.
+		status = StatusUnhealthy
 	} else if info.State.ExitCode != 0 {
-		status = "error"
+		status = StatusError
 	}
 
 	startedAt, _ := time.Parse(time.RFC3339, info.State.StartedAt)
 
 	return &AgentStatus{
-		ID:        id,
-		Name:      info.Name,
-		Status:    status,
-		StartedAt: startedAt,
+		ID:           id,
+		Name:         info.Name,
+		Status:       status,
+		StartedAt:    startedAt,
+		RestartCount: info.RestartCount,
 	}, nil
 }
 
@@ -643,9 +784,9 @@ func (d *DockerRuntime) StreamLogs(ctx context.Context, id string) (io.ReadClose
 }
 
 // TeardownInfra removes all containers, the NATS container, network, and volume
-// for a given team.
-func (d *DockerRuntime) TeardownInfra(ctx context.Context, teamName string) error {
-	teamName = sanitizeName(teamName)
+// for a given team. The workspace volume is kept with PreserveWorkspace.
+func (d *DockerRuntime) TeardownInfra(ctx context.Context, teamName string, opts TeardownOptions) error {
+	teamName = naming.Slug(teamName)
 	slog.Info("tearing down team infrastructure", "team", teamName)
 
 	// Find all containers for this team.
@@ -660,7 +801,7 @@ func (d *DockerRuntime) TeardownInfra(ctx context.Context, teamName string) erro
 	// Stop and remove all team containers.
 	for _, c := range containers {
 		slog.Info("removing container", "id", c.ID[:12], "names", c.Names)
-		timeout := 10
+		timeout := agentStopTimeout
 		_ = d.client.ContainerStop(ctx, c.ID, container.StopOptions{Timeout: &timeout})
 		_ = d.client.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true})
 	}
@@ -672,15 +813,67 @@ func (d *DockerRuntime) TeardownInfra(ctx context.Context, teamName string) erro
 	}
 
 	// Remove volume.
-	volName := teamVolumeName(teamName)
-	if err := d.client.VolumeRemove(ctx, volName, false); err != nil {
-		slog.Warn("failed to remove volume", "volume", volName, "error", err)
+	if !opts.PreserveWorkspace {
+		if err := d.DeleteWorkspace(ctx, teamName); err != nil {
+			slog.Warn("failed to remove volume", "team", teamName, "error", err)
+		}
+	}
+
+	// Remove config volumes created for the "separate" config dir mode.
+	for _, dir := range GeneratedConfigDirs {
+		cfgVol := teamConfigVolumeName(teamName, dir)
+		if err := d.client.VolumeRemove(ctx, cfgVol, false); err != nil && !client.IsErrNotFound(err) {
+			slog.Warn("failed to remove volume", "volume", cfgVol, "error", err)
+		}
 	}
 
 	slog.Info("team infrastructure torn down", "team", teamName)
 	return nil
 }
 
+// DeleteWorkspace removes the team's workspace volume.
+func (d *DockerRuntime) DeleteWorkspace(ctx context.Context, teamName string) error {
+	volName := teamVolumeName(naming.Slug(teamName))
+	if err := d.client.VolumeRemove(ctx, volName, false); err != nil && !client.IsErrNotFound(err) {
+		return fmt.Errorf("removing volume %s: %w", volName, err)
+	}
+	return nil
+}
+
+// ListTeamInfra returns the containers, networks and volumes of every team.
+func (d *DockerRuntime) ListTeamInfra(ctx context.Context) ([]TeamInfra, error) {
+	teamFilter := filters.NewArgs(filters.Arg("label", LabelTeam))
+
+	containers, err := d.client.ContainerList(ctx, container.ListOptions{All: true, Filters: teamFilter})
+	if err != nil {
+		return nil, fmt.Errorf("listing team containers: %w", err)
+	}
+	networks, err := d.client.NetworkList(ctx, network.ListOptions{Filters: teamFilter})
+	if err != nil {
+		return nil, fmt.Errorf("listing team networks: %w", err)
+	}
+	volumes, err := d.client.VolumeList(ctx, volume.ListOptions{Filters: teamFilter})
+	if err != nil {
+		return nil, fmt.Errorf("listing team volumes: %w", err)
+	}
+
+	var resources []InfraResource
+	for _, c := range containers {
+		name := c.ID
+		if len(c.Names) > 0 {
+			name = strings.TrimPrefix(c.Names[0], "/")
+		}
+		resources = append(resources, InfraResource{Kind: ResourceContainer, Name: name, ID: c.ID, labels: c.Labels})
+	}
+	for _, n := range networks {
+		resources = append(resources, InfraResource{Kind: ResourceNetwork, Name: n.Name, labels: n.Labels})
+	}
+	for _, v := range volumes.Volumes {
+		resources = append(resources, InfraResource{Kind: ResourceVolume, Name: v.Name, labels: v.Labels})
+	}
+	return collectTeamInfra(resources), nil
+}
+
 // ExecInContainer runs a command inside a running Docker container and returns
 // the combined stdout+stderr output.
 func (d *DockerRuntime) ExecInContainer(ctx context.Context, id string, cmd []string) (string, error) {
@@ -0,0 +1,556 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// dockerHostNamePattern restricts host names to what can prefix a container
+// ID and be stored on a team.
+var dockerHostNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// DockerHost is a Docker daemon a MultiHostDockerRuntime places teams on.
+type DockerHost struct {
+	// Name identifies the host in container IDs and on the teams placed on
+	// it.
+	Name string `yaml:"name"`
+	// Host is the daemon's address: tcp://host:port or unix:///path.
+	Host string `yaml:"host"`
+	// TLSCACert, TLSCert and TLSKey are the paths of the CA and client
+	// certificates and the client key of a daemon that requires TLS. The
+	// system CAs are used when TLSCACert is empty.
+	TLSCACert string `yaml:"tls_ca_cert"`
+	TLSCert   string `yaml:"tls_cert"`
+	TLSKey    string `yaml:"tls_key"`
+	// Labels describe the host (e.g. zone: eu-west-1, gpu: "true"). A team
+	// with a host selector is only placed on hosts whose labels match it.
+	Labels map[string]string `yaml:"labels"`
+	// MaxTeams is how many teams the host runs at most. Zero means no limit.
+	MaxTeams int `yaml:"max_teams"`
+}
+
+// LoadDockerHosts reads the Docker hosts to place teams on from a YAML or
+// JSON file with a top-level "hosts" list.
+func LoadDockerHosts(path string) ([]DockerHost, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading docker hosts: %w", err)
+	}
+	var file struct {
+		Hosts []DockerHost `yaml:"hosts"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing docker hosts %s: %w", path, err)
+	}
+	if err := ValidateDockerHosts(file.Hosts); err != nil {
+		return nil, fmt.Errorf("docker hosts %s: %w", path, err)
+	}
+	return file.Hosts, nil
+}
+
+// ValidateDockerHosts checks that hosts has at least one host, that names
+// are valid and unique, and that each address and TLS setting is usable.
+func ValidateDockerHosts(hosts []DockerHost) error {
+	if len(hosts) == 0 {
+		return errors.New("no hosts")
+	}
+	seen := map[string]bool{}
+	for i, h := range hosts {
+		switch {
+		case !dockerHostNamePattern.MatchString(h.Name):
+			return fmt.Errorf("host %d: invalid name %q: must be 1-63 lowercase letters, digits or '-'", i+1, h.Name)
+		case seen[h.Name]:
+			return fmt.Errorf("host %d: duplicate name %q", i+1, h.Name)
+		case (h.TLSCert == "") != (h.TLSKey == ""):
+			return fmt.Errorf("host %s: set both tls_cert and tls_key", h.Name)
+		case h.TLSCACert != "" && h.TLSCert == "":
+			return fmt.Errorf("host %s: tls_ca_cert requires tls_cert and tls_key", h.Name)
+		case h.MaxTeams < 0:
+			return fmt.Errorf("host %s: max_teams cannot be negative", h.Name)
+		}
+		u, err := url.Parse(h.Host)
+		if err != nil || (u.Scheme != "tcp" && u.Scheme != "unix") {
+			return fmt.Errorf("host %s: address %q must be tcp://host:port or unix:///path", h.Name, h.Host)
+		}
+		seen[h.Name] = true
+	}
+	return nil
+}
+
+// MultiHostDockerRuntime runs teams on several Docker daemons. Each team is
+// placed on one host, with all its containers, network and workspace
+// volume, and stays there across deploys. Container IDs are prefixed with
+// the host name ("host/id"); IDs without a prefix are on the first host,
+// which also runs Ollama, Qdrant and the RAG MCP server.
+type MultiHostDockerRuntime struct {
+	hosts []*dockerHostRuntime
+
+	mu sync.Mutex
+	// teams maps team slugs to the host their infrastructure is on.
+	teams map[string]*dockerHostRuntime
+}
+
+// dockerHostRuntime is one host of a MultiHostDockerRuntime.
+type dockerHostRuntime struct {
+	config DockerHost
+	rt     *DockerRuntime
+}
+
+// NewMultiHostDockerRuntime creates a runtime that places teams on hosts.
+// The daemons are not contacted until teams are deployed.
+func NewMultiHostDockerRuntime(hosts []DockerHost) (*MultiHostDockerRuntime, error) {
+	if err := ValidateDockerHosts(hosts); err != nil {
+		return nil, err
+	}
+	m := &MultiHostDockerRuntime{teams: map[string]*dockerHostRuntime{}}
+	for _, h := range hosts {
+		opts := []client.Opt{client.WithHost(h.Host), client.WithAPIVersionNegotiation()}
+		if h.TLSCert != "" {
+			opts = append(opts, client.WithTLSClientConfig(h.TLSCACert, h.TLSCert, h.TLSKey))
+		}
+		cli, err := client.NewClientWithOpts(opts...)
+		if err != nil {
+			return nil, fmt.Errorf("creating docker client for host %s: %w", h.Name, err)
+		}
+		rt := &DockerRuntime{client: cli}
+		if u, _ := url.Parse(h.Host); u.Scheme == "tcp" {
+			rt.remoteHost = u.Hostname()
+		}
+		m.hosts = append(m.hosts, &dockerHostRuntime{config: h, rt: rt})
+	}
+	return m, nil
+}
+
+// primary returns the first host.
+func (m *MultiHostDockerRuntime) primary() *dockerHostRuntime {
+	return m.hosts[0]
+}
+
+// host returns the host named name, or nil.
+func (m *MultiHostDockerRuntime) host(name string) *dockerHostRuntime {
+	for _, h := range m.hosts {
+		if h.config.Name == name {
+			return h
+		}
+	}
+	return nil
+}
+
+// containerHost returns the host of a container ID returned by DeployAgent
+// and the daemon's ID of the container.
+func (m *MultiHostDockerRuntime) containerHost(id string) (*dockerHostRuntime, string, error) {
+	name, rawID, ok := strings.Cut(id, "/")
+	if !ok {
+		return m.primary(), id, nil
+	}
+	h := m.host(name)
+	if h == nil {
+		return nil, "", fmt.Errorf("container %s is on unknown docker host %q", id, name)
+	}
+	return h, rawID, nil
+}
+
+// teamHost returns the host with the infrastructure of the team, or the
+// first host if no host has it.
+func (m *MultiHostDockerRuntime) teamHost(ctx context.Context, teamName string) *dockerHostRuntime {
+	if h := m.findTeamHost(ctx, naming.Slug(teamName)); h != nil {
+		return h
+	}
+	return m.primary()
+}
+
+// findTeamHost returns the host with the network or workspace volume of
+// the team with the given slug, or nil.
+func (m *MultiHostDockerRuntime) findTeamHost(ctx context.Context, slug string) *dockerHostRuntime {
+	m.mu.Lock()
+	h := m.teams[slug]
+	m.mu.Unlock()
+	if h != nil {
+		return h
+	}
+	for _, h := range m.hosts {
+		_, netErr := h.rt.client.NetworkInspect(ctx, teamNetworkName(slug), network.InspectOptions{})
+		_, volErr := h.rt.client.VolumeInspect(ctx, teamVolumeName(slug))
+		if netErr == nil || volErr == nil {
+			m.setTeamHost(slug, h)
+			return h
+		}
+	}
+	return nil
+}
+
+// setTeamHost records the host of the team with the given slug; nil
+// forgets it.
+func (m *MultiHostDockerRuntime) setTeamHost(slug string, h *dockerHostRuntime) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h == nil {
+		delete(m.teams, slug)
+	} else {
+		m.teams[slug] = h
+	}
+}
+
+// TeamHost returns the name of the host the team was deployed on, or "" if
+// it has not been deployed since the runtime started.
+func (m *MultiHostDockerRuntime) TeamHost(teamName string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h := m.teams[naming.Slug(teamName)]; h != nil {
+		return h.config.Name
+	}
+	return ""
+}
+
+// dockerHostLoad is a placement candidate: a host and how many teams it
+// runs.
+type dockerHostLoad struct {
+	host  DockerHost
+	teams int
+}
+
+// pickDockerHost chooses the host for a new team by best fit: among hosts
+// matching selector that have room, the one with the fewest free slots, so
+// that hosts fill up one after the other and whole hosts stay free for
+// large teams or maintenance. Hosts without a limit come last, in order.
+// It returns false if no host fits.
+func pickDockerHost(loads []dockerHostLoad, selector map[string]string) (DockerHost, bool) {
+	var fits []dockerHostLoad
+	for _, l := range loads {
+		if !labelsMatch(l.host.Labels, selector) {
+			continue
+		}
+		if l.host.MaxTeams > 0 && l.teams >= l.host.MaxTeams {
+			continue
+		}
+		fits = append(fits, l)
+	}
+	if len(fits) == 0 {
+		return DockerHost{}, false
+	}
+	free := func(l dockerHostLoad) int {
+		if l.host.MaxTeams == 0 {
+			return int(^uint(0) >> 1)
+		}
+		return l.host.MaxTeams - l.teams
+	}
+	sort.SliceStable(fits, func(i, j int) bool { return free(fits[i]) < free(fits[j]) })
+	return fits[0].host, true
+}
+
+// labelsMatch reports whether labels has every key and value of selector.
+func labelsMatch(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// placeTeam returns the host to deploy the infrastructure of config on: the
+// host it was placed on before, the host that already has it, or else the
+// host pickDockerHost chooses.
+func (m *MultiHostDockerRuntime) placeTeam(ctx context.Context, config InfraConfig) (*dockerHostRuntime, error) {
+	if config.DockerHost != "" {
+		h := m.host(config.DockerHost)
+		if h == nil {
+			return nil, fmt.Errorf("the team is placed on docker host %q, which is not registered", config.DockerHost)
+		}
+		return h, nil
+	}
+	if h := m.findTeamHost(ctx, naming.Slug(config.TeamName)); h != nil {
+		return h, nil
+	}
+
+	teamFilter := filters.NewArgs(filters.Arg("label", LabelTeam))
+	var loads []dockerHostLoad
+	for _, h := range m.hosts {
+		if !labelsMatch(h.config.Labels, config.HostSelector) {
+			continue
+		}
+		networks, err := h.rt.client.NetworkList(ctx, network.ListOptions{Filters: teamFilter})
+		if err != nil {
+			slog.Warn("skipping unreachable docker host", "host", h.config.Name, "error", err)
+			continue
+		}
+		loads = append(loads, dockerHostLoad{host: h.config, teams: len(networks)})
+	}
+	picked, ok := pickDockerHost(loads, config.HostSelector)
+	if !ok {
+		if len(config.HostSelector) > 0 {
+			return nil, fmt.Errorf("no reachable docker host with labels %v has room for the team", config.HostSelector)
+		}
+		return nil, errors.New("no reachable docker host has room for the team")
+	}
+	return m.host(picked.Name), nil
+}
+
+// DeployInfra places the team on a host and deploys its infrastructure
+// there.
+func (m *MultiHostDockerRuntime) DeployInfra(ctx context.Context, config InfraConfig) error {
+	h, err := m.placeTeam(ctx, config)
+	if err != nil {
+		return err
+	}
+	slog.Info("placing team on docker host", "team", config.TeamName, "host", h.config.Name)
+	if err := h.rt.DeployInfra(ctx, config); err != nil {
+		return err
+	}
+	m.setTeamHost(naming.Slug(config.TeamName), h)
+	return nil
+}
+
+// DeployAgent starts an agent on its team's host.
+func (m *MultiHostDockerRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*AgentInstance, error) {
+	h := m.teamHost(ctx, config.TeamName)
+	inst, err := h.rt.DeployAgent(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	inst.ID = h.config.Name + "/" + inst.ID
+	return inst, nil
+}
+
+func (m *MultiHostDockerRuntime) StopAgent(ctx context.Context, id string) error {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return err
+	}
+	return h.rt.StopAgent(ctx, rawID)
+}
+
+func (m *MultiHostDockerRuntime) RemoveAgent(ctx context.Context, id string) error {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return err
+	}
+	return h.rt.RemoveAgent(ctx, rawID)
+}
+
+func (m *MultiHostDockerRuntime) GetStatus(ctx context.Context, id string) (*AgentStatus, error) {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return nil, err
+	}
+	status, err := h.rt.GetStatus(ctx, rawID)
+	if err != nil {
+		return nil, err
+	}
+	status.ID = id
+	return status, nil
+}
+
+func (m *MultiHostDockerRuntime) StreamLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return nil, err
+	}
+	return h.rt.StreamLogs(ctx, rawID)
+}
+
+// TeardownInfra tears down the team on its host. The team stays placed
+// there while its workspace volume is kept.
+func (m *MultiHostDockerRuntime) TeardownInfra(ctx context.Context, teamName string, opts TeardownOptions) error {
+	h := m.teamHost(ctx, teamName)
+	if err := h.rt.TeardownInfra(ctx, teamName, opts); err != nil {
+		return err
+	}
+	if !opts.PreserveWorkspace {
+		m.setTeamHost(naming.Slug(teamName), nil)
+	}
+	return nil
+}
+
+func (m *MultiHostDockerRuntime) DeleteWorkspace(ctx context.Context, teamName string) error {
+	h := m.teamHost(ctx, teamName)
+	if err := h.rt.DeleteWorkspace(ctx, teamName); err != nil {
+		return err
+	}
+	m.setTeamHost(naming.Slug(teamName), nil)
+	return nil
+}
+
+func (m *MultiHostDockerRuntime) GetNATSURL(teamName string) string {
+	return m.primary().rt.GetNATSURL(teamName)
+}
+
+func (m *MultiHostDockerRuntime) GetNATSConnectURL(ctx context.Context, teamName string) (string, error) {
+	return m.teamHost(ctx, teamName).rt.GetNATSConnectURL(ctx, teamName)
+}
+
+func (m *MultiHostDockerRuntime) ExecInContainer(ctx context.Context, id string, cmd []string) (string, error) {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return "", err
+	}
+	return h.rt.ExecInContainer(ctx, rawID, cmd)
+}
+
+func (m *MultiHostDockerRuntime) ReadFile(ctx context.Context, containerID string, path string) ([]byte, error) {
+	h, rawID, err := m.containerHost(containerID)
+	if err != nil {
+		return nil, err
+	}
+	return h.rt.ReadFile(ctx, rawID, path)
+}
+
+func (m *MultiHostDockerRuntime) WriteFile(ctx context.Context, containerID string, path string, content []byte) error {
+	h, rawID, err := m.containerHost(containerID)
+	if err != nil {
+		return err
+	}
+	return h.rt.WriteFile(ctx, rawID, path, content)
+}
+
+func (m *MultiHostDockerRuntime) CopyToContainer(ctx context.Context, containerID string, destPath string, content []byte) error {
+	h, rawID, err := m.containerHost(containerID)
+	if err != nil {
+		return err
+	}
+	return h.rt.CopyToContainer(ctx, rawID, destPath, content)
+}
+
+func (m *MultiHostDockerRuntime) ImageDigest(ctx context.Context, id string) (string, error) {
+	h, rawID, err := m.containerHost(id)
+	if err != nil {
+		return "", err
+	}
+	return h.rt.ImageDigest(ctx, rawID)
+}
+
+// ListTeamInfra returns the team infrastructure of every host. It fails if
+// a host cannot be listed, since a partial list would make present
+// containers look missing.
+func (m *MultiHostDockerRuntime) ListTeamInfra(ctx context.Context) ([]TeamInfra, error) {
+	var resources []InfraResource
+	for _, h := range m.hosts {
+		infra, err := h.rt.ListTeamInfra(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("docker host %s: %w", h.config.Name, err)
+		}
+		for _, ti := range infra {
+			for _, r := range ti.Resources {
+				if r.ID != "" {
+					r.ID = h.config.Name + "/" + r.ID
+				}
+				resources = append(resources, r)
+			}
+		}
+	}
+	return collectTeamInfra(resources), nil
+}
+
+// PrePullImages pulls images on every host.
+func (m *MultiHostDockerRuntime) PrePullImages(ctx context.Context, images []string) error {
+	var errs []error
+	for _, h := range m.hosts {
+		if err := h.rt.PrePullImages(ctx, images); err != nil {
+			errs = append(errs, fmt.Errorf("docker host %s: %w", h.config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// onPrimary checks that the network a shared service joins is on the first
+// host, where the shared services run.
+func (m *MultiHostDockerRuntime) onPrimary(ctx context.Context, service, networkName string) error {
+	p := m.primary()
+	if _, err := p.rt.client.NetworkInspect(ctx, networkName, network.InspectOptions{}); err != nil {
+		return fmt.Errorf("%s runs on docker host %s, which does not have network %s; place the team there with a host selector: %w", service, p.config.Name, networkName, err)
+	}
+	return nil
+}
+
+func (m *MultiHostDockerRuntime) EnsureOllama(ctx context.Context) (string, error) {
+	return m.primary().rt.EnsureOllama(ctx)
+}
+
+func (m *MultiHostDockerRuntime) ConnectOllamaToNetwork(ctx context.Context, networkName string) error {
+	if err := m.onPrimary(ctx, "Ollama", networkName); err != nil {
+		return err
+	}
+	return m.primary().rt.ConnectOllamaToNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) DisconnectOllamaFromNetwork(ctx context.Context, networkName string) error {
+	return m.primary().rt.DisconnectOllamaFromNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) PullOllamaModel(ctx context.Context, model string, progressFn func(status string)) error {
+	return m.primary().rt.PullOllamaModel(ctx, model, progressFn)
+}
+
+func (m *MultiHostDockerRuntime) WarmUpOllamaModel(ctx context.Context, model string) error {
+	return m.primary().rt.WarmUpOllamaModel(ctx, model)
+}
+
+func (m *MultiHostDockerRuntime) StopOllama(ctx context.Context) error {
+	return m.primary().rt.StopOllama(ctx)
+}
+
+func (m *MultiHostDockerRuntime) IsOllamaRunning(ctx context.Context) (bool, error) {
+	return m.primary().rt.IsOllamaRunning(ctx)
+}
+
+func (m *MultiHostDockerRuntime) EnsureQdrant(ctx context.Context) (string, error) {
+	return m.primary().rt.EnsureQdrant(ctx)
+}
+
+func (m *MultiHostDockerRuntime) ConnectQdrantToNetwork(ctx context.Context, networkName string) error {
+	if err := m.onPrimary(ctx, "Qdrant", networkName); err != nil {
+		return err
+	}
+	return m.primary().rt.ConnectQdrantToNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) DisconnectQdrantFromNetwork(ctx context.Context, networkName string) error {
+	return m.primary().rt.DisconnectQdrantFromNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) IsQdrantRunning(ctx context.Context) (bool, error) {
+	return m.primary().rt.IsQdrantRunning(ctx)
+}
+
+func (m *MultiHostDockerRuntime) EnsureRagMcp(ctx context.Context) (string, error) {
+	return m.primary().rt.EnsureRagMcp(ctx)
+}
+
+func (m *MultiHostDockerRuntime) ConnectRagMcpToNetwork(ctx context.Context, networkName string) error {
+	if err := m.onPrimary(ctx, "the RAG MCP server", networkName); err != nil {
+		return err
+	}
+	return m.primary().rt.ConnectRagMcpToNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) DisconnectRagMcpFromNetwork(ctx context.Context, networkName string) error {
+	return m.primary().rt.DisconnectRagMcpFromNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) IsRagMcpRunning(ctx context.Context) (bool, error) {
+	return m.primary().rt.IsRagMcpRunning(ctx)
+}
+
+func (m *MultiHostDockerRuntime) EnsureNetwork(ctx context.Context, networkName string) error {
+	return m.primary().rt.EnsureNetwork(ctx, networkName)
+}
+
+func (m *MultiHostDockerRuntime) ConnectSelfToNetwork(ctx context.Context, networkName string) error {
+	return m.primary().rt.ConnectSelfToNetwork(ctx, networkName)
+}
@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadDockerHosts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.yaml")
+	data := `hosts:
+  - name: local
+    host: unix:///var/run/docker.sock
+  - name: gpu-1
+    host: tcp://10.0.0.5:2376
+    tls_ca_cert: /certs/ca.pem
+    tls_cert: /certs/cert.pem
+    tls_key: /certs/key.pem
+    labels: {gpu: "true"}
+    max_teams: 4
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := LoadDockerHosts(path)
+	if err != nil {
+		t.Fatalf("LoadDockerHosts: %v", err)
+	}
+	if len(hosts) != 2 || hosts[1].Labels["gpu"] != "true" || hosts[1].MaxTeams != 4 || hosts[1].TLSKey != "/certs/key.pem" {
+		t.Errorf("hosts: got %+v", hosts)
+	}
+}
+
+func TestValidateDockerHosts(t *testing.T) {
+	local := DockerHost{Name: "local", Host: "unix:///var/run/docker.sock"}
+	tests := []struct {
+		name  string
+		hosts []DockerHost
+		want  string
+	}{
+		{"no hosts", nil, "no hosts"},
+		{"bad name", []DockerHost{{Name: "Host/1", Host: "tcp://a:2376"}}, "invalid name"},
+		{"duplicate", []DockerHost{local, local}, "duplicate"},
+		{"ssh", []DockerHost{{Name: "remote", Host: "ssh://user@a"}}, "must be tcp"},
+		{"cert without key", []DockerHost{{Name: "remote", Host: "tcp://a:2376", TLSCert: "cert.pem"}}, "tls_key"},
+		{"negative limit", []DockerHost{{Name: "remote", Host: "tcp://a:2376", MaxTeams: -1}}, "max_teams"},
+	}
+	for _, tt := range tests {
+		err := ValidateDockerHosts(tt.hosts)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error containing %q", tt.name, err, tt.want)
+		}
+	}
+	if err := ValidateDockerHosts([]DockerHost{local}); err != nil {
+		t.Errorf("valid hosts: %v", err)
+	}
+}
+
+func TestPickDockerHost(t *testing.T) {
+	small := DockerHost{Name: "small", MaxTeams: 2}
+	large := DockerHost{Name: "large", MaxTeams: 10}
+	unlimited := DockerHost{Name: "unlimited"}
+	gpu := DockerHost{Name: "gpu", MaxTeams: 5, Labels: map[string]string{"gpu": "true"}}
+
+	tests := []struct {
+		name     string
+		loads    []dockerHostLoad
+		selector map[string]string
+		want     string
+	}{
+		{"fullest host with room", []dockerHostLoad{{large, 3}, {small, 1}, {unlimited, 0}}, nil, "small"},
+		{"full host skipped", []dockerHostLoad{{small, 2}, {large, 9}, {unlimited, 0}}, nil, "large"},
+		{"unlimited last", []dockerHostLoad{{unlimited, 0}, {small, 2}, {large, 10}}, nil, "unlimited"},
+		{"selector", []dockerHostLoad{{small, 0}, {gpu, 4}}, map[string]string{"gpu": "true"}, "gpu"},
+		{"nothing fits", []dockerHostLoad{{small, 2}, {gpu, 5}}, nil, ""},
+	}
+	for _, tt := range tests {
+		got, ok := pickDockerHost(tt.loads, tt.selector)
+		if got.Name != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: got %q (%v), want %q", tt.name, got.Name, ok, tt.want)
+		}
+	}
+}
+
+func TestMultiHostDockerRuntime_ContainerHost(t *testing.T) {
+	m, err := NewMultiHostDockerRuntime([]DockerHost{
+		{Name: "local", Host: "unix:///var/run/docker.sock"},
+		{Name: "remote", Host: "tcp://10.0.0.5:2375"},
+	})
+	if err != nil {
+		t.Fatalf("NewMultiHostDockerRuntime: %v", err)
+	}
+	if m.host("local").rt.remoteHost != "" || m.host("remote").rt.remoteHost != "10.0.0.5" {
+		t.Errorf("remote hosts: local %q, remote %q", m.host("local").rt.remoteHost, m.host("remote").rt.remoteHost)
+	}
+
+	h, id, err := m.containerHost("remote/abc123")
+	if err != nil || h.config.Name != "remote" || id != "abc123" {
+		t.Errorf("prefixed ID: got %v %q %v", h, id, err)
+	}
+	// IDs from before hosts were registered are on the first host.
+	h, id, err = m.containerHost("abc123")
+	if err != nil || h.config.Name != "local" || id != "abc123" {
+		t.Errorf("bare ID: got %v %q %v", h, id, err)
+	}
+	if _, _, err := m.containerHost("gone/abc123"); err == nil {
+		t.Error("unknown host: expected an error")
+	}
+
+	m.setTeamHost("my-team", m.host("remote"))
+	if got := m.TeamHost("My Team"); got != "remote" {
+		t.Errorf("TeamHost: got %q, want remote", got)
+	}
+}
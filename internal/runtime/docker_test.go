@@ -64,6 +64,7 @@ func TestTeamNamingConventions(t *testing.T) {
 		{"myteam", teamNetworkName, "team-myteam"},
 		{"myteam", teamVolumeName, "team-myteam-workspace"},
 		{"myteam", natsContainerName, "team-myteam-nats"},
+		{"myteam", func(n string) string { return teamConfigVolumeName(n, ".claude") }, "team-myteam-config-claude"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,83 @@
+package runtime
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DockerHostPath converts a workspace path to the form the Docker daemon
+// expects as a bind-mount source. Windows drive paths ("C:\Users\me\repo")
+// become the Unix form Docker Desktop shares ("/c/Users/me/repo"), which
+// also keeps the drive colon out of the "src:dst" bind syntax. Other paths
+// are returned cleaned.
+func DockerHostPath(p string) string {
+	if isWindowsDrivePath(p) {
+		rest := strings.ReplaceAll(p[2:], `\`, "/")
+		return path.Clean("/" + strings.ToLower(p[:1]) + "/" + rest)
+	}
+	if p == "" {
+		return p
+	}
+	return path.Clean(p)
+}
+
+// isWindowsDrivePath reports whether p starts with a drive letter and colon
+// followed by a separator, as in "C:\" or "d:/".
+func isWindowsDrivePath(p string) bool {
+	if len(p) < 3 || p[1] != ':' || (p[2] != '\\' && p[2] != '/') {
+		return false
+	}
+	c := p[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// rootlessFromSecurityOptions reports whether a Docker daemon's security
+// options (from /info) mark it as rootless.
+func rootlessFromSecurityOptions(opts []string) bool {
+	for _, opt := range opts {
+		if strings.Contains(opt, "name=rootless") {
+			return true
+		}
+	}
+	return false
+}
+
+// workspaceOwnerEnv returns the entrypoint.sh variables that choose the user
+// the agent runs as on a bind-mounted workspace, as sorted "KEY=value" pairs.
+// It returns nil when the agent uses a managed volume instead.
+//
+// On rootless Docker, container root is the host user that runs the daemon
+// and host files appear root-owned inside the container, so the agent stays
+// root and nothing is chowned: switching to any other container user would
+// leave files owned by a subordinate UID on the host. IS_SANDBOX lets the
+// Claude CLI bypass permissions as root inside the container.
+func workspaceOwnerEnv(config AgentConfig, rootless bool) []string {
+	if config.WorkspacePath == "" {
+		return nil
+	}
+	env := map[string]string{}
+	switch {
+	case rootless:
+		env["WORKSPACE_UID"] = "0"
+		env["WORKSPACE_GID"] = "0"
+		env["WORKSPACE_CHOWN"] = "false"
+		env["IS_SANDBOX"] = "1"
+	default:
+		if config.RunAsUID != nil {
+			env["WORKSPACE_UID"] = strconv.Itoa(*config.RunAsUID)
+			if config.RunAsGID != nil {
+				env["WORKSPACE_GID"] = strconv.Itoa(*config.RunAsGID)
+			}
+		}
+		if config.SkipWorkspaceChown {
+			env["WORKSPACE_CHOWN"] = "false"
+		}
+	}
+
+	out := make([]string, 0, len(env))
+	for _, k := range sortedKeys(env) {
+		out = append(out, k+"="+env[k])
+	}
+	return out
+}
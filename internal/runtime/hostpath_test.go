@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDockerHostPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"/home/me/repo", "/home/me/repo"},
+		{"/home/me/repo/", "/home/me/repo"},
+		{`C:\Users\me\repo`, "/c/Users/me/repo"},
+		{`d:\work\`, "/d/work"},
+		{"E:/projects/app", "/e/projects/app"},
+		{`C:\`, "/c"},
+		{"C:relative", "C:relative"},
+	}
+	for _, tt := range tests {
+		if got := DockerHostPath(tt.in); got != tt.want {
+			t.Errorf("DockerHostPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRootlessFromSecurityOptions(t *testing.T) {
+	if rootlessFromSecurityOptions([]string{"name=seccomp,profile=builtin", "name=cgroupns"}) {
+		t.Error("rootful daemon reported as rootless")
+	}
+	if !rootlessFromSecurityOptions([]string{"name=seccomp,profile=builtin", "name=rootless", "name=cgroupns"}) {
+		t.Error("rootless daemon not detected")
+	}
+}
+
+func TestWorkspaceOwnerEnv(t *testing.T) {
+	uid, gid := 1000, 1001
+	tests := []struct {
+		name     string
+		config   AgentConfig
+		rootless bool
+		want     []string
+	}{
+		{"no workspace", AgentConfig{RunAsUID: &uid}, true, nil},
+		{"detect owner", AgentConfig{WorkspacePath: "/repo"}, false, []string{}},
+		{"explicit owner", AgentConfig{WorkspacePath: "/repo", RunAsUID: &uid, RunAsGID: &gid, SkipWorkspaceChown: true}, false,
+			[]string{"WORKSPACE_CHOWN=false", "WORKSPACE_GID=1001", "WORKSPACE_UID=1000"}},
+		{"gid without uid", AgentConfig{WorkspacePath: "/repo", RunAsGID: &gid}, false, []string{}},
+		{"rootless", AgentConfig{WorkspacePath: "/repo", RunAsUID: &uid}, true,
+			[]string{"IS_SANDBOX=1", "WORKSPACE_CHOWN=false", "WORKSPACE_GID=0", "WORKSPACE_UID=0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := workspaceOwnerEnv(tt.config, tt.rootless)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("workspaceOwnerEnv() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
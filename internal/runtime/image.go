@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageDigest returns the digest reference of the container's image,
+// preferring the digest from the repository the container was created from.
+func (d *DockerRuntime) ImageDigest(ctx context.Context, id string) (string, error) {
+	info, err := d.client.ContainerInspect(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("inspecting container %s: %w", id, err)
+	}
+	img, _, err := d.client.ImageInspectWithRaw(ctx, info.Image)
+	if err != nil {
+		return "", fmt.Errorf("inspecting image %s: %w", info.Image, err)
+	}
+	ref := ""
+	if info.Config != nil {
+		ref = info.Config.Image
+	}
+	return pickRepoDigest(ref, img.RepoDigests), nil
+}
+
+// ImageDigest returns the digest reference of the image the agent pod's
+// container runs, as reported by the kubelet.
+func (k *K8sRuntime) ImageDigest(ctx context.Context, id string) (string, error) {
+	ns, podName, err := parseAgentID(id)
+	if err != nil {
+		return "", err
+	}
+	pod, err := k.clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("getting pod %s: %w", id, err)
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == "agent" {
+			return digestFromImageID(cs.ImageID), nil
+		}
+	}
+	return "", nil
+}
+
+// pickRepoDigest returns the entry of digests (each repo@sha256:...) whose
+// repository matches ref's, or the first entry if none does.
+func pickRepoDigest(ref string, digests []string) string {
+	if len(digests) == 0 {
+		return ""
+	}
+	repo := imageRepository(ref)
+	for _, d := range digests {
+		if imageRepository(d) == repo {
+			return d
+		}
+	}
+	return digests[0]
+}
+
+// digestFromImageID converts a kubelet ImageID into a digest reference.
+// Docker-based nodes prefix it with "docker-pullable://"; IDs without a
+// repository (a bare "sha256:...") have no pullable digest.
+func digestFromImageID(imageID string) string {
+	imageID = strings.TrimPrefix(imageID, "docker-pullable://")
+	if !strings.Contains(imageID, "@") {
+		return ""
+	}
+	return imageID
+}
+
+// imageRepository strips the tag and digest from an image reference:
+// "host:5000/org/app:1.2@sha256:..." becomes "host:5000/org/app".
+func imageRepository(ref string) string {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return ref
+}
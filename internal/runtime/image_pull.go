@@ -0,0 +1,152 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// pullProgressInterval is how often reportPullProgress reports the bytes
+// downloaded by an image pull.
+var pullProgressInterval = 2 * time.Second
+
+// PullProgress is the state of an image pull.
+type PullProgress struct {
+	Image string `json:"image"`
+	// Layers counts the layers of the image seen so far, and LayersDone
+	// those extracted or already present.
+	Layers     int `json:"layers"`
+	LayersDone int `json:"layers_done"`
+	// DownloadedBytes and SizeBytes add up the layers whose size is known.
+	DownloadedBytes int64 `json:"downloaded_bytes"`
+	SizeBytes       int64 `json:"size_bytes"`
+	// Percent is the share of SizeBytes downloaded, or 100 once every
+	// layer is done.
+	Percent int `json:"percent"`
+}
+
+// pullLayer is the state of a layer in an image pull.
+type pullLayer struct {
+	size       int64
+	downloaded int64
+	done       bool
+}
+
+// pullMessage is a message of the JSON stream of a Docker image pull.
+type pullMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// pullTracker adds up the layer messages of an image pull.
+type pullTracker struct {
+	image  string
+	order  []string
+	layers map[string]*pullLayer
+}
+
+// update applies a message and reports whether it was about a layer.
+func (t *pullTracker) update(msg pullMessage) bool {
+	layer := t.layers[msg.ID]
+	switch msg.Status {
+	case "Pulling fs layer", "Waiting", "Already exists":
+		if layer == nil {
+			layer = &pullLayer{}
+			t.layers[msg.ID] = layer
+			t.order = append(t.order, msg.ID)
+		}
+		layer.done = msg.Status == "Already exists"
+		return true
+	}
+	if layer == nil {
+		return false
+	}
+	switch msg.Status {
+	case "Downloading":
+		layer.size = msg.ProgressDetail.Total
+		layer.downloaded = msg.ProgressDetail.Current
+	case "Download complete":
+		layer.downloaded = layer.size
+	case "Pull complete":
+		layer.downloaded = layer.size
+		layer.done = true
+	}
+	return true
+}
+
+// progress returns the state of the pull.
+func (t *pullTracker) progress() PullProgress {
+	p := PullProgress{Image: t.image, Layers: len(t.order)}
+	for _, id := range t.order {
+		layer := t.layers[id]
+		if layer.done {
+			p.LayersDone++
+		}
+		p.DownloadedBytes += layer.downloaded
+		p.SizeBytes += layer.size
+	}
+	switch {
+	case p.Layers > 0 && p.LayersDone == p.Layers:
+		p.Percent = 100
+	case p.SizeBytes > 0:
+		p.Percent = int(p.DownloadedBytes * 100 / p.SizeBytes)
+	}
+	return p
+}
+
+// reportPull reports the state of a pull to the ProgressFunc of ctx.
+func reportPull(ctx context.Context, p PullProgress) {
+	message := "Pulling image " + p.Image
+	if p.Layers > 0 {
+		message += fmt.Sprintf(": %d/%d layers", p.LayersDone, p.Layers)
+		if p.SizeBytes > 0 {
+			message += fmt.Sprintf(", %d%% of %.1f MB", p.Percent, float64(p.SizeBytes)/1e6)
+		}
+	}
+	reportProgress(ctx, Progress{Message: message, Pull: &p})
+}
+
+// reportPullProgress reads the JSON stream of an image pull to the end and
+// reports its progress to the ProgressFunc of ctx: when the pull starts,
+// when a layer is done, and every pullProgressInterval while layers
+// download. Messages that are not about a layer, such as the digest, are
+// reported as they are.
+func reportPullProgress(ctx context.Context, img string, r io.Reader) {
+	tracker := &pullTracker{image: img, layers: map[string]*pullLayer{}}
+	reportPull(ctx, tracker.progress())
+	last := tracker.progress()
+	lastTime := time.Now()
+
+	dec := json.NewDecoder(r)
+	for {
+		var msg pullMessage
+		if err := dec.Decode(&msg); err != nil {
+			_, _ = io.Copy(io.Discard, r)
+			break
+		}
+		if !tracker.update(msg) {
+			if msg.ID != "" {
+				ReportProgress(ctx, "%s: %s", msg.ID, msg.Status)
+			} else if msg.Status != "" {
+				ReportProgress(ctx, "%s", msg.Status)
+			}
+			continue
+		}
+		p := tracker.progress()
+		if p.LayersDone != last.LayersDone || time.Since(lastTime) >= pullProgressInterval {
+			if p != last {
+				reportPull(ctx, p)
+				last, lastTime = p, time.Now()
+			}
+		}
+	}
+	if p := tracker.progress(); p != last {
+		reportPull(ctx, p)
+	}
+}
@@ -0,0 +1,86 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPullProgress(t *testing.T) {
+	interval := pullProgressInterval
+	pullProgressInterval = time.Hour
+	t.Cleanup(func() { pullProgressInterval = interval })
+
+	var steps []Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { steps = append(steps, p) })
+
+	stream := strings.Join([]string{
+		`{"status":"Pulling from library/nats","id":"2.10"}`,
+		`{"status":"Pulling fs layer","id":"a"}`,
+		`{"status":"Pulling fs layer","id":"b"}`,
+		`{"status":"Already exists","id":"c"}`,
+		`{"status":"Downloading","progressDetail":{"current":1000000,"total":4000000},"id":"a"}`,
+		`{"status":"Downloading","progressDetail":{"current":500000,"total":2000000},"id":"b"}`,
+		`{"status":"Download complete","id":"a"}`,
+		`{"status":"Extracting","progressDetail":{"current":4000000,"total":4000000},"id":"a"}`,
+		`{"status":"Pull complete","id":"a"}`,
+		`{"status":"Downloading","progressDetail":{"current":1500000,"total":2000000},"id":"b"}`,
+		`{"status":"Digest: sha256:0123"}`,
+	}, "\n")
+	reportPullProgress(ctx, "nats:2.10", strings.NewReader(stream))
+
+	var messages []string
+	for _, step := range steps {
+		messages = append(messages, step.Message)
+	}
+	want := []string{
+		"Pulling image nats:2.10",
+		"2.10: Pulling from library/nats",
+		"Pulling image nats:2.10: 1/3 layers",
+		"Pulling image nats:2.10: 2/3 layers, 75% of 6.0 MB",
+		"Digest: sha256:0123",
+		// The end of the stream reports the bytes downloaded since.
+		"Pulling image nats:2.10: 2/3 layers, 91% of 6.0 MB",
+	}
+	if got := strings.Join(messages, "\n"); got != strings.Join(want, "\n") {
+		t.Fatalf("messages:\n%s\nwant:\n%s", got, strings.Join(want, "\n"))
+	}
+
+	last := steps[len(steps)-1].Pull
+	if last == nil {
+		t.Fatal("last step has no pull progress")
+	}
+	wantPull := PullProgress{Image: "nats:2.10", Layers: 3, LayersDone: 2, DownloadedBytes: 5500000, SizeBytes: 6000000, Percent: 91}
+	if *last != wantPull {
+		t.Errorf("pull: got %+v, want %+v", *last, wantPull)
+	}
+	if steps[1].Pull != nil {
+		t.Error("a message that is not about a layer has pull progress")
+	}
+
+	// Without a ProgressFunc the stream is only drained.
+	reportPullProgress(context.Background(), "nats:2.10", strings.NewReader(stream))
+}
+
+func TestReportPullProgress_AllLayersDone(t *testing.T) {
+	var last Progress
+	ctx := WithProgress(context.Background(), func(p Progress) { last = p })
+
+	reportPullProgress(ctx, "busybox", strings.NewReader(
+		`{"status":"Already exists","id":"a"}`+"\n"+`{"status":"Status: Image is up to date for busybox:latest"}`))
+	if last.Message != "Status: Image is up to date for busybox:latest" {
+		t.Errorf("last message: got %q", last.Message)
+	}
+
+	var pulls []PullProgress
+	ctx = WithProgress(context.Background(), func(p Progress) {
+		if p.Pull != nil {
+			pulls = append(pulls, *p.Pull)
+		}
+	})
+	reportPullProgress(ctx, "busybox", strings.NewReader(`{"status":"Already exists","id":"a"}`))
+	if n := len(pulls); n != 2 || pulls[1].Percent != 100 || pulls[1].LayersDone != 1 {
+		t.Errorf("pulls: got %+v, want the start and one done layer at 100%%", pulls)
+	}
+}
@@ -0,0 +1,77 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestImageRepository(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/helmcode/agent_crew_agent:latest": "ghcr.io/helmcode/agent_crew_agent",
+		"ghcr.io/helmcode/agent_crew_agent":        "ghcr.io/helmcode/agent_crew_agent",
+		"registry:5000/org/app:1.2":                "registry:5000/org/app",
+		"registry:5000/org/app":                    "registry:5000/org/app",
+		"ghcr.io/org/app@sha256:abc":               "ghcr.io/org/app",
+		"ghcr.io/org/app:1.0@sha256:abc":           "ghcr.io/org/app",
+	}
+	for in, want := range tests {
+		if got := imageRepository(in); got != want {
+			t.Errorf("imageRepository(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPickRepoDigest(t *testing.T) {
+	digests := []string{"mirror.local/org/app@sha256:111", "ghcr.io/org/app@sha256:222"}
+	if got := pickRepoDigest("ghcr.io/org/app:1.0", digests); got != "ghcr.io/org/app@sha256:222" {
+		t.Errorf("matching repository: got %q", got)
+	}
+	if got := pickRepoDigest("other.io/app:1.0", digests); got != digests[0] {
+		t.Errorf("no matching repository: got %q", got)
+	}
+	if got := pickRepoDigest("ghcr.io/org/app:1.0", nil); got != "" {
+		t.Errorf("no digests: got %q", got)
+	}
+}
+
+func TestDigestFromImageID(t *testing.T) {
+	tests := map[string]string{
+		"docker-pullable://ghcr.io/org/app@sha256:abc": "ghcr.io/org/app@sha256:abc",
+		"ghcr.io/org/app@sha256:abc":                   "ghcr.io/org/app@sha256:abc",
+		"sha256:abc":                                   "",
+		"":                                             "",
+	}
+	for in, want := range tests {
+		if got := digestFromImageID(in); got != want {
+			t.Errorf("digestFromImageID(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestK8sImageDigest(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-leader", Namespace: "agentcrew-team"},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "agent", ImageID: "docker-pullable://ghcr.io/org/app@sha256:abc"},
+		}},
+	}
+	ctx := context.Background()
+	if _, err := clientset.CoreV1().Pods("agentcrew-team").Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating pod: %v", err)
+	}
+
+	got, err := k.ImageDigest(ctx, "agentcrew-team/agent-leader")
+	if err != nil {
+		t.Fatalf("ImageDigest: %v", err)
+	}
+	if got != "ghcr.io/org/app@sha256:abc" {
+		t.Errorf("ImageDigest = %q", got)
+	}
+	if _, err := k.ImageDigest(ctx, "agentcrew-team/missing"); err == nil {
+		t.Error("expected error for a missing pod")
+	}
+}
@@ -23,12 +23,20 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/remotecommand"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/naming"
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 // K8sRuntime implements AgentRuntime using the Kubernetes API.
 type K8sRuntime struct {
 	clientset  kubernetes.Interface
 	restConfig *rest.Config
+
+	// namespaceDefaults are the operator-configured labels, annotations
+	// and quota applied to every team namespace.
+	namespaceDefaults namespaceDefaults
 }
 
 // NewK8sRuntime creates a K8sRuntime, trying in-cluster config first,
@@ -53,12 +61,17 @@ func NewK8sRuntime() (*K8sRuntime, error) {
 		return nil, fmt.Errorf("creating k8s clientset: %w", err)
 	}
 
-	return &K8sRuntime{clientset: clientset, restConfig: config}, nil
+	defaults, err := namespaceDefaultsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &K8sRuntime{clientset: clientset, restConfig: config, namespaceDefaults: defaults}, nil
 }
 
 // Naming conventions for Kubernetes resources.
-func teamNamespaceName(teamName string) string { return "agentcrew-" + teamName }
-func agentPodName(name string) string          { return "agent-" + name }
+func teamNamespaceName(teamName string) string { return naming.DNSLabel("agentcrew-", teamName) }
+func agentPodName(name string) string          { return naming.DNSLabel("agent-", name) }
 func workspacePVCName() string                 { return "workspace" }
 func natsDeploymentName() string               { return "nats" }
 func natsServiceName() string                  { return "nats" }
@@ -75,7 +88,7 @@ func parseAgentID(id string) (namespace, podName string, err error) {
 
 // GetNATSURL returns the NATS URL for a team in Kubernetes runtime using in-cluster DNS.
 func (k *K8sRuntime) GetNATSURL(teamName string) string {
-	return "nats://nats.agentcrew-" + sanitizeName(teamName) + ".svc.cluster.local:4222"
+	return "nats://nats." + teamNamespaceName(naming.Slug(teamName)) + ".svc.cluster.local:4222"
 }
 
 // GetNATSConnectURL returns the in-cluster NATS URL. When the API runs inside the
@@ -86,23 +99,23 @@ func (k *K8sRuntime) GetNATSConnectURL(_ context.Context, teamName string) (stri
 
 // DeployInfra creates the namespace, workspace PVC, and optionally NATS deployment+service.
 func (k *K8sRuntime) DeployInfra(ctx context.Context, config InfraConfig) error {
-	config.TeamName = sanitizeName(config.TeamName)
+	config.TeamName = naming.Slug(config.TeamName)
 	ns := teamNamespaceName(config.TeamName)
 	slog.Info("deploying k8s team infrastructure", "team", config.TeamName, "namespace", ns)
 
 	// Create namespace.
-	_, err := k.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   ns,
-			Labels: map[string]string{LabelTeam: config.TeamName},
-		},
-	}, metav1.CreateOptions{})
-	if err != nil && !errors.IsAlreadyExists(err) {
-		return fmt.Errorf("creating namespace %s: %w", ns, err)
+	if err := k.ensureNamespace(ctx, ns, config); err != nil {
+		return err
+	}
+
+	// Cap the namespace's total compute for chargeback and blast-radius
+	// containment.
+	if err := k.ensureQuota(ctx, ns, config); err != nil {
+		return err
 	}
 
 	// Create workspace PVC.
-	_, err = k.clientset.CoreV1().PersistentVolumeClaims(ns).Create(ctx, &corev1.PersistentVolumeClaim{
+	_, err := k.clientset.CoreV1().PersistentVolumeClaims(ns).Create(ctx, &corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   workspacePVCName(),
 			Labels: map[string]string{LabelTeam: config.TeamName},
@@ -122,7 +135,7 @@ func (k *K8sRuntime) DeployInfra(ctx context.Context, config InfraConfig) error
 
 	// Deploy NATS if enabled.
 	if config.NATSEnabled {
-		if err := k.deployNATS(ctx, config.TeamName, ns); err != nil {
+		if err := k.deployNATS(ctx, config.TeamName, ns, config.NATS); err != nil {
 			return fmt.Errorf("deploying nats: %w", err)
 		}
 	}
@@ -134,6 +147,12 @@ func (k *K8sRuntime) DeployInfra(ctx context.Context, config InfraConfig) error
 // natsAuthSecretName returns the name of the NATS auth token secret.
 func natsAuthSecretName() string { return "nats-auth-token" }
 
+// natsConfigMapName holds the server configuration of a NATS server with
+// JetStream limits, mounted at natsConfigDir.
+func natsConfigMapName() string { return "nats-config" }
+
+const natsConfigDir = "/etc/agentcrew-nats"
+
 // ensureNATSAuthSecret creates a Kubernetes Secret for the NATS auth token
 // if one is configured and it doesn't already exist.
 func (k *K8sRuntime) ensureNATSAuthSecret(ctx context.Context, namespace string) (bool, error) {
@@ -162,7 +181,7 @@ func (k *K8sRuntime) ensureNATSAuthSecret(ctx context.Context, namespace string)
 // deployNATS creates a NATS Deployment and ClusterIP Service, then waits for readiness.
 // The auth token is stored in a Kubernetes Secret and injected via env var to avoid
 // exposing it in the Deployment spec args.
-func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string) error {
+func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string, cfg NATSConfig) error {
 	hasAuth, err := k.ensureNATSAuthSecret(ctx, namespace)
 	if err != nil {
 		return fmt.Errorf("ensuring nats auth secret: %w", err)
@@ -174,7 +193,7 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 	if hasAuth {
 		natsContainer = corev1.Container{
 			Name:    "nats",
-			Image:   NATSImage,
+			Image:   cfg.image(),
 			Command: []string{"sh", "-c", "exec nats-server --jetstream --auth \"$NATS_AUTH_TOKEN\""},
 			Env: []corev1.EnvVar{
 				{
@@ -204,7 +223,7 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 	} else {
 		natsContainer = corev1.Container{
 			Name:  "nats",
-			Image: NATSImage,
+			Image: cfg.image(),
 			Args:  []string{"--jetstream"},
 			Ports: []corev1.ContainerPort{{ContainerPort: 4222, Protocol: corev1.ProtocolTCP}},
 			Resources: corev1.ResourceRequirements{
@@ -220,6 +239,28 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 		}
 	}
 
+	var volumes []corev1.Volume
+	if serverConfig := cfg.serverConfig(); serverConfig != "" {
+		if err := k.applyNATSConfigMap(ctx, namespace, teamName, serverConfig); err != nil {
+			return err
+		}
+		path := natsConfigDir + "/" + natsConfigFile
+		if hasAuth {
+			natsContainer.Command[2] += " --config " + path
+		} else {
+			natsContainer.Args = append(natsContainer.Args, "--config", path)
+		}
+		natsContainer.VolumeMounts = []corev1.VolumeMount{{Name: "nats-config", MountPath: natsConfigDir, ReadOnly: true}}
+		volumes = []corev1.Volume{{
+			Name: "nats-config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: natsConfigMapName()},
+				},
+			},
+		}}
+	}
+
 	replicas := int32(1)
 	dep := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -237,6 +278,7 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 				},
 				Spec: corev1.PodSpec{
 					Containers: []corev1.Container{natsContainer},
+					Volumes:    volumes,
 				},
 			},
 		},
@@ -270,6 +312,7 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 
 	// Wait for NATS deployment to be ready.
 	slog.Info("waiting for nats deployment to be ready", "namespace", namespace)
+	ReportProgress(ctx, "Waiting for NATS to be ready")
 	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, 2*time.Minute, true, func(ctx context.Context) (bool, error) {
 		d, err := k.clientset.AppsV1().Deployments(namespace).Get(ctx, natsDeploymentName(), metav1.GetOptions{})
 		if err != nil {
@@ -282,13 +325,14 @@ func (k *K8sRuntime) deployNATS(ctx context.Context, teamName, namespace string)
 	}
 
 	slog.Info("nats is ready", "namespace", namespace)
+	ReportProgress(ctx, "NATS is ready")
 	return nil
 }
 
 // DeployAgent creates a Pod for the agent in the team's namespace.
 func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*AgentInstance, error) {
-	config.TeamName = sanitizeName(config.TeamName)
-	config.Name = sanitizeName(config.Name)
+	config.TeamName = naming.Slug(config.TeamName)
+	config.Name = naming.Slug(config.Name)
 
 	ns := teamNamespaceName(config.TeamName)
 	podName := agentPodName(config.Name)
@@ -319,11 +363,22 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 		{Name: "AGENT_ROLE", Value: config.Role},
 		{Name: "AGENT_PROVIDER", Value: config.Provider},
 		{Name: "AGENT_PERMISSIONS", Value: string(permJSON)},
+		{Name: "AGENT_ADMIN_LISTEN", Value: SidecarAdminListen},
 	}
 
 	if config.WorkspacePath != "" {
 		env = append(env, corev1.EnvVar{Name: "WORKSPACE_PATH", Value: "/workspace"})
 	}
+	if len(config.ExtraWorkspaces) > 0 {
+		env = append(env, corev1.EnvVar{Name: "AGENT_EXTRA_WORKSPACES", Value: protocol.ExtraWorkspaceScope(config.ExtraWorkspaces)})
+	}
+	if backoff := config.Restart.backoffEnv(); backoff != "" {
+		env = append(env, corev1.EnvVar{Name: "AGENT_RESTART_BACKOFF", Value: backoff})
+	}
+	for _, kv := range workspaceOwnerEnv(config, false) {
+		k, v, _ := strings.Cut(kv, "=")
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
 	if config.ClaudeMD != "" {
 		env = append(env, corev1.EnvVar{Name: "AGENT_CLAUDE_MD", Value: config.ClaudeMD})
 	}
@@ -375,12 +430,18 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 		resources.Requests = corev1.ResourceList{}
 		resources.Limits = corev1.ResourceList{}
 		if config.Resources.Memory != "" {
-			mem := resource.MustParse(config.Resources.Memory)
+			mem, err := memoryQuantity(config.Resources.Memory)
+			if err != nil {
+				return nil, fmt.Errorf("invalid memory limit: %w", err)
+			}
 			resources.Requests[corev1.ResourceMemory] = mem
 			resources.Limits[corev1.ResourceMemory] = mem
 		}
 		if config.Resources.CPU != "" {
-			cpu := resource.MustParse(config.Resources.CPU)
+			cpu, err := resource.ParseQuantity(config.Resources.CPU)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cpu limit %q: %w", config.Resources.CPU, err)
+			}
 			resources.Requests[corev1.ResourceCPU] = cpu
 			resources.Limits[corev1.ResourceCPU] = cpu
 		}
@@ -403,33 +464,68 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 
 	if config.WorkspacePath != "" {
 		hostPathType := corev1.HostPathDirectory
+		hostWorkspace := config.WorkspacePath
+		if config.ConfigDirMode == models.ConfigDirModeShared {
+			// The team works in its own git worktree of the workspace, which
+			// needs the repository's .git directory.
+			hostWorkspace = SharedWorktreePath(config.WorkspacePath, config.TeamName)
+			volumes = append(volumes, corev1.Volume{
+				Name: "git-dir",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: filepath.Join(config.WorkspacePath, ".git"),
+						Type: &hostPathType,
+					},
+				},
+			})
+			volumeMounts = append(volumeMounts, corev1.VolumeMount{
+				Name:      "git-dir",
+				MountPath: sharedGitDirMount,
+			})
+		}
 		workspaceVolume = corev1.Volume{
 			Name: "workspace",
 			VolumeSource: corev1.VolumeSource{
 				HostPath: &corev1.HostPathVolumeSource{
-					Path: config.WorkspacePath,
+					Path: hostWorkspace,
 					Type: &hostPathType,
 				},
 			},
 		}
 
-		// Mount the per-agent .claude directory so Claude Code CLI picks up
-		// the agent-specific CLAUDE.md automatically at /workspace/.claude.
-		agentClaudeDir := AgentClaudeDir(config.WorkspacePath, config.Name)
-		hostPathDirOrCreate := corev1.HostPathDirectoryOrCreate
-		volumes = append(volumes, corev1.Volume{
-			Name: "agent-config",
-			VolumeSource: corev1.VolumeSource{
-				HostPath: &corev1.HostPathVolumeSource{
-					Path: agentClaudeDir,
-					Type: &hostPathDirOrCreate,
+		if config.ConfigDirMode == models.ConfigDirModeSeparate {
+			// Keep generated config dirs on pod-local emptyDir volumes so
+			// nothing is written into the host workspace.
+			for _, dir := range GeneratedConfigDirs {
+				volName := "config-" + strings.TrimPrefix(dir, ".")
+				volumes = append(volumes, corev1.Volume{
+					Name:         volName,
+					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+				})
+				volumeMounts = append(volumeMounts, corev1.VolumeMount{
+					Name:      volName,
+					MountPath: "/workspace/" + dir,
+				})
+			}
+		} else {
+			// Mount the per-agent .claude directory so Claude Code CLI picks up
+			// the agent-specific CLAUDE.md automatically at /workspace/.claude.
+			agentClaudeDir := AgentClaudeDir(hostWorkspace, config.Name)
+			hostPathDirOrCreate := corev1.HostPathDirectoryOrCreate
+			volumes = append(volumes, corev1.Volume{
+				Name: "agent-config",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{
+						Path: agentClaudeDir,
+						Type: &hostPathDirOrCreate,
+					},
 				},
-			},
-		})
-		volumeMounts = append(volumeMounts, corev1.VolumeMount{
-			Name:      "agent-config",
-			MountPath: "/workspace/.claude",
-		})
+			})
+			volumeMounts = append(volumeMounts, corev1.VolumeMount{
+				Name:      "agent-config",
+				MountPath: "/workspace/.claude",
+			})
+		}
 	} else {
 		workspaceVolume = corev1.Volume{
 			Name: "workspace",
@@ -441,6 +537,32 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 		}
 	}
 
+	for i, w := range config.ExtraWorkspaces {
+		hostPathType := corev1.HostPathDirectory
+		volName := fmt.Sprintf("extra-workspace-%d", i)
+		volumes = append(volumes, corev1.Volume{
+			Name: volName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: w.HostPath, Type: &hostPathType},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      volName,
+			MountPath: w.MountPath(),
+			ReadOnly:  w.ReadOnly,
+		})
+	}
+
+	// The restart backoff counts restarts in a directory that outlives the
+	// agent's container.
+	if config.Restart.backoffEnv() != "" {
+		volumes = append(volumes, corev1.Volume{
+			Name:         "restart-state",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{Name: "restart-state", MountPath: protocol.RestartStateDir})
+	}
+
 	// Build final volumes list.
 	allVolumes := []corev1.Volume{workspaceVolume}
 	allVolumes = append(allVolumes, volumes...)
@@ -470,19 +592,24 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 			},
 		},
 		Spec: corev1.PodSpec{
-			RestartPolicy: corev1.RestartPolicyNever,
+			RestartPolicy: config.Restart.podRestartPolicy(),
 			Containers: []corev1.Container{
 				{
-					Name:         "agent",
-					Image:        img,
-					Env:          env,
-					Resources:    resources,
-					VolumeMounts: volumeMounts,
+					Name:           "agent",
+					Image:          img,
+					Env:            env,
+					Resources:      resources,
+					VolumeMounts:   volumeMounts,
+					StartupProbe:   sidecarStartupProbe(),
+					ReadinessProbe: sidecarReadinessProbe(),
 				},
 			},
 			Volumes: allVolumes,
 		},
 	}
+	if config.PullImage {
+		pod.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
+	}
 
 	created, err := k.clientset.CoreV1().Pods(ns).Create(ctx, pod, metav1.CreateOptions{})
 	if err != nil {
@@ -491,6 +618,7 @@ func (k *K8sRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*Agen
 
 	agentID := ns + "/" + created.Name
 	slog.Info("k8s agent pod created", "id", agentID, "agent", config.Name)
+	ReportProgress(ctx, "Created pod of agent %s", config.Name)
 
 	return &AgentInstance{
 		ID:     agentID,
@@ -521,18 +649,31 @@ func (k *K8sRuntime) GetStatus(ctx context.Context, id string) (*AgentStatus, er
 	}
 
 	pod, err := k.clientset.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, fmt.Errorf("getting pod %s: %w", id, ErrAgentNotFound)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("getting pod %s: %w", id, err)
 	}
 
 	status := podPhaseToStatus(pod.Status.Phase)
+	if status == StatusRunning && podUnhealthy(pod) {
+		status = StatusUnhealthy
+	}
 	startedAt := pod.CreationTimestamp.Time
+	var restarts int
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name == "agent" {
+			restarts = int(cs.RestartCount)
+		}
+	}
 
 	return &AgentStatus{
-		ID:        id,
-		Name:      pod.Labels[LabelAgent],
-		Status:    status,
-		StartedAt: startedAt,
+		ID:           id,
+		Name:         pod.Labels[LabelAgent],
+		Status:       status,
+		StartedAt:    startedAt,
+		RestartCount: restarts,
 	}, nil
 }
 
@@ -549,21 +690,115 @@ func (k *K8sRuntime) StreamLogs(ctx context.Context, id string) (io.ReadCloser,
 	return req.Stream(ctx)
 }
 
-// TeardownInfra deletes the entire team namespace, which cascades to all resources within it.
-func (k *K8sRuntime) TeardownInfra(ctx context.Context, teamName string) error {
-	teamName = sanitizeName(teamName)
+// TeardownInfra deletes the entire team namespace, which cascades to all
+// resources within it. With PreserveWorkspace, the namespace and its
+// workspace PVC are kept, and only the pods, NATS and secrets are deleted.
+func (k *K8sRuntime) TeardownInfra(ctx context.Context, teamName string, opts TeardownOptions) error {
+	teamName = naming.Slug(teamName)
 	ns := teamNamespaceName(teamName)
+	if opts.PreserveWorkspace {
+		return k.teardownKeepingWorkspace(ctx, teamName, ns)
+	}
 	slog.Info("tearing down k8s team infrastructure", "team", teamName, "namespace", ns)
 
+	if err := k.DeleteWorkspace(ctx, teamName); err != nil {
+		return err
+	}
+
+	slog.Info("k8s team infrastructure torn down", "team", teamName)
+	return nil
+}
+
+// teardownKeepingWorkspace deletes everything that runs in the team
+// namespace, keeping the namespace, workspace PVC and quota.
+func (k *K8sRuntime) teardownKeepingWorkspace(ctx context.Context, teamName, ns string) error {
+	slog.Info("tearing down k8s team infrastructure, keeping the workspace", "team", teamName, "namespace", ns)
+
+	pods, err := k.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: LabelTeam + "=" + teamName})
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("listing pods in %s: %w", ns, err)
+	}
+	if pods != nil {
+		for _, pod := range pods.Items {
+			if err := k.clientset.CoreV1().Pods(ns).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("deleting pod %s in %s: %w", pod.Name, ns, err)
+			}
+		}
+	}
+	if err := k.clientset.AppsV1().Deployments(ns).Delete(ctx, natsDeploymentName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deleting nats deployment in %s: %w", ns, err)
+	}
+	if err := k.clientset.CoreV1().Services(ns).Delete(ctx, natsServiceName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deleting nats service in %s: %w", ns, err)
+	}
+	for _, name := range []string{natsAuthSecretName(), apiKeySecretName()} {
+		if err := k.clientset.CoreV1().Secrets(ns).Delete(ctx, name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting secret %s in %s: %w", name, ns, err)
+		}
+	}
+	if err := k.clientset.CoreV1().ConfigMaps(ns).Delete(ctx, natsConfigMapName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("deleting nats config in %s: %w", ns, err)
+	}
+
+	slog.Info("k8s team infrastructure torn down, workspace kept", "team", teamName)
+	return nil
+}
+
+// applyNATSConfigMap creates or updates the ConfigMap holding the NATS
+// server configuration.
+func (k *K8sRuntime) applyNATSConfigMap(ctx context.Context, namespace, teamName, serverConfig string) error {
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   natsConfigMapName(),
+			Labels: map[string]string{LabelTeam: teamName, LabelRole: "nats"},
+		},
+		Data: map[string]string{natsConfigFile: serverConfig},
+	}
+	_, err := k.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, cm, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		_, err = k.clientset.CoreV1().ConfigMaps(namespace).Update(ctx, cm, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("applying nats config: %w", err)
+	}
+	return nil
+}
+
+// DeleteWorkspace deletes the team namespace, which holds the workspace
+// PVC.
+func (k *K8sRuntime) DeleteWorkspace(ctx context.Context, teamName string) error {
+	ns := teamNamespaceName(naming.Slug(teamName))
 	err := k.clientset.CoreV1().Namespaces().Delete(ctx, ns, metav1.DeleteOptions{})
 	if err != nil && !errors.IsNotFound(err) {
 		return fmt.Errorf("deleting namespace %s: %w", ns, err)
 	}
-
-	slog.Info("k8s team infrastructure torn down", "team", teamName)
 	return nil
 }
 
+// ListTeamInfra returns the namespace and pods of every team. Other
+// resources live in the team namespace and are removed with it.
+func (k *K8sRuntime) ListTeamInfra(ctx context.Context) ([]TeamInfra, error) {
+	opts := metav1.ListOptions{LabelSelector: LabelTeam}
+	namespaces, err := k.clientset.CoreV1().Namespaces().List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing team namespaces: %w", err)
+	}
+	pods, err := k.clientset.CoreV1().Pods("").List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing team pods: %w", err)
+	}
+
+	var resources []InfraResource
+	for _, ns := range namespaces.Items {
+		resources = append(resources, InfraResource{Kind: ResourceNamespace, Name: ns.Name, labels: ns.Labels})
+	}
+	for _, pod := range pods.Items {
+		id := pod.Namespace + "/" + pod.Name
+		resources = append(resources, InfraResource{Kind: ResourcePod, Name: id, ID: id, labels: pod.Labels})
+	}
+	return collectTeamInfra(resources), nil
+}
+
 // ensureAPIKeySecret creates the Kubernetes Secret holding the Anthropic API key
 // if it doesn't already exist in the given namespace.
 func (k *K8sRuntime) ensureAPIKeySecret(ctx context.Context, namespace string, extraEnv map[string]string) error {
@@ -724,6 +959,45 @@ func (k *K8sRuntime) CopyToContainer(ctx context.Context, id string, destPath st
 	return nil
 }
 
+// sidecarStartupProbe waits for the sidecar health endpoint to come up,
+// allowing for skill installation and the initial Claude prompt.
+func sidecarStartupProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: sidecarHealthCommand()},
+		},
+		PeriodSeconds:    5,
+		TimeoutSeconds:   int32(healthcheckTimeout / time.Second),
+		FailureThreshold: int32(healthcheckStartPeriod / (5 * time.Second)),
+	}
+}
+
+// sidecarReadinessProbe marks the pod unready while the sidecar health
+// endpoint fails. There is no liveness probe: the sidecar restarts a crashed
+// agent manager itself, and killing the pod would lose the in-flight run.
+func sidecarReadinessProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: sidecarHealthCommand()},
+		},
+		PeriodSeconds:    int32(healthcheckInterval / time.Second),
+		TimeoutSeconds:   int32(healthcheckTimeout / time.Second),
+		FailureThreshold: healthcheckRetries,
+	}
+}
+
+// podUnhealthy reports whether the agent container has finished starting
+// but is failing its readiness probe.
+func podUnhealthy(pod *corev1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != "agent" {
+			continue
+		}
+		return cs.State.Running != nil && cs.Started != nil && *cs.Started && !cs.Ready
+	}
+	return false
+}
+
 // podPhaseToStatus converts a Kubernetes PodPhase to the internal status string.
 func podPhaseToStatus(phase corev1.PodPhase) string {
 	switch phase {
@@ -735,3 +1009,18 @@ func podPhaseToStatus(phase corev1.PodPhase) string {
 		return "stopped"
 	}
 }
+
+// memoryQuantity converts a memory limit to a Kubernetes quantity. Limits in
+// the runtime-neutral k/m/g form ("512m", "2g") are binary sizes, so they are
+// converted from bytes rather than parsed as Kubernetes suffixes, where "m"
+// means milli. Native quantities such as "512Mi" are accepted as-is.
+func memoryQuantity(mem string) (resource.Quantity, error) {
+	if bytes := parseMemoryLimit(mem); bytes > 0 {
+		return *resource.NewQuantity(bytes, resource.BinarySI), nil
+	}
+	q, err := resource.ParseQuantity(mem)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("%q: %w", mem, err)
+	}
+	return q, nil
+}
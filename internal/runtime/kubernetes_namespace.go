@@ -0,0 +1,363 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Names of the per-team quota objects managed by DeployInfra.
+const (
+	teamQuotaName      = "agentcrew-quota"
+	teamLimitRangeName = "agentcrew-limits"
+)
+
+// Container limits the LimitRange applies to pods that declare none, so
+// they are admitted under a namespace quota.
+const (
+	defaultContainerCPU    = "1"
+	defaultContainerMemory = "2g"
+)
+
+// namespaceDefaults is the operator configuration applied to every team
+// namespace, read from the environment:
+//
+//	K8S_NAMESPACE_LABELS       comma-separated key=value labels
+//	K8S_NAMESPACE_ANNOTATIONS  comma-separated key=value annotations
+//	K8S_QUOTA_CPU              default namespace CPU quota (e.g. "8")
+//	K8S_QUOTA_MEMORY           default namespace memory quota (e.g. "16g")
+//	K8S_QUOTA_PODS             default namespace pod count quota
+//	K8S_LIMIT_DEFAULT_CPU      container CPU limit when a pod sets none
+//	K8S_LIMIT_DEFAULT_MEMORY   container memory limit when a pod sets none
+type namespaceDefaults struct {
+	labels      map[string]string
+	annotations map[string]string
+	quota       QuotaConfig
+	limitCPU    string
+	limitMemory string
+}
+
+// namespaceDefaultsFromEnv reads and validates the operator namespace
+// configuration.
+func namespaceDefaultsFromEnv() (namespaceDefaults, error) {
+	d := namespaceDefaults{
+		labels:      parseKeyValues(os.Getenv("K8S_NAMESPACE_LABELS")),
+		annotations: parseKeyValues(os.Getenv("K8S_NAMESPACE_ANNOTATIONS")),
+		quota: QuotaConfig{
+			CPU:    os.Getenv("K8S_QUOTA_CPU"),
+			Memory: os.Getenv("K8S_QUOTA_MEMORY"),
+		},
+		limitCPU:    os.Getenv("K8S_LIMIT_DEFAULT_CPU"),
+		limitMemory: os.Getenv("K8S_LIMIT_DEFAULT_MEMORY"),
+	}
+	if v := os.Getenv("K8S_QUOTA_PODS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return d, fmt.Errorf("invalid K8S_QUOTA_PODS %q", v)
+		}
+		d.quota.Pods = n
+	}
+	if d.limitCPU == "" {
+		d.limitCPU = defaultContainerCPU
+	}
+	if d.limitMemory == "" {
+		d.limitMemory = defaultContainerMemory
+	}
+
+	if err := ValidateNamespaceMetadata(d.labels, d.annotations); err != nil {
+		return d, fmt.Errorf("K8S_NAMESPACE_LABELS/ANNOTATIONS: %w", err)
+	}
+	if err := ValidateQuota(d.quota); err != nil {
+		return d, fmt.Errorf("K8S_QUOTA_*: %w", err)
+	}
+	if err := ValidateQuota(QuotaConfig{CPU: d.limitCPU, Memory: d.limitMemory}); err != nil {
+		return d, fmt.Errorf("K8S_LIMIT_DEFAULT_*: %w", err)
+	}
+	return d, nil
+}
+
+// parseKeyValues parses "k1=v1,k2=v2". Entries without "=" are skipped.
+func parseKeyValues(s string) map[string]string {
+	m := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && strings.TrimSpace(k) != "" {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
+
+// ValidateNamespaceMetadata checks labels and annotations against the
+// Kubernetes naming rules. Labels under the agentcrew. prefix are reserved.
+func ValidateNamespaceMetadata(labels, annotations map[string]string) error {
+	for _, k := range sortedKeys(labels) {
+		if strings.HasPrefix(k, "agentcrew.") {
+			return fmt.Errorf("label %q: the agentcrew. prefix is reserved", k)
+		}
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("label key %q: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(labels[k]); len(errs) > 0 {
+			return fmt.Errorf("label %q value %q: %s", k, labels[k], strings.Join(errs, "; "))
+		}
+	}
+	for _, k := range sortedKeys(annotations) {
+		if errs := validation.IsQualifiedName(strings.ToLower(k)); len(errs) > 0 {
+			return fmt.Errorf("annotation key %q: %s", k, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// ValidateQuota checks that quota values parse.
+func ValidateQuota(q QuotaConfig) error {
+	if q.CPU != "" {
+		if _, err := resource.ParseQuantity(q.CPU); err != nil {
+			return fmt.Errorf("invalid cpu %q", q.CPU)
+		}
+	}
+	if q.Memory != "" {
+		if _, err := memoryQuantity(q.Memory); err != nil {
+			return fmt.Errorf("invalid memory %q", q.Memory)
+		}
+	}
+	if q.Pods < 0 {
+		return fmt.Errorf("pods must not be negative")
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// namespaceMeta merges the operator defaults with the team's labels and
+// annotations. Team values win, except for the reserved team labels.
+func (k *K8sRuntime) namespaceMeta(config InfraConfig) (labels, annotations map[string]string) {
+	labels = map[string]string{}
+	annotations = map[string]string{}
+	for key, v := range k.namespaceDefaults.labels {
+		labels[key] = v
+	}
+	for key, v := range config.Namespace.Labels {
+		labels[key] = v
+	}
+	for key, v := range teamLabels(config) {
+		labels[key] = v
+	}
+	for key, v := range k.namespaceDefaults.annotations {
+		annotations[key] = v
+	}
+	for key, v := range config.Namespace.Annotations {
+		annotations[key] = v
+	}
+	return labels, annotations
+}
+
+// ensureNamespace creates the team namespace, or brings the labels and
+// annotations of an existing one up to date.
+func (k *K8sRuntime) ensureNamespace(ctx context.Context, ns string, config InfraConfig) error {
+	labels, annotations := k.namespaceMeta(config)
+	_, err := k.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ns,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+	}, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace %s: %w", ns, err)
+	}
+
+	existing, err := k.clientset.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting namespace %s: %w", ns, err)
+	}
+	if err := checkTeamOwner(config.TeamName, config.TeamID, existing.Labels); err != nil {
+		return err
+	}
+	if existing.Labels == nil {
+		existing.Labels = map[string]string{}
+	}
+	if existing.Annotations == nil {
+		existing.Annotations = map[string]string{}
+	}
+	for key, v := range labels {
+		existing.Labels[key] = v
+	}
+	for key, v := range annotations {
+		existing.Annotations[key] = v
+	}
+	if _, err := k.clientset.CoreV1().Namespaces().Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating namespace %s: %w", ns, err)
+	}
+	return nil
+}
+
+// ensureQuota creates or updates the namespace ResourceQuota and the
+// LimitRange that gives containers without limits a default, so they are
+// admitted under the quota. Both are removed when no quota applies.
+func (k *K8sRuntime) ensureQuota(ctx context.Context, ns string, config InfraConfig) error {
+	quota := k.namespaceDefaults.quota
+	team := config.Namespace.Quota
+	if team.CPU != "" {
+		quota.CPU = team.CPU
+	}
+	if team.Memory != "" {
+		quota.Memory = team.Memory
+	}
+	if team.Pods > 0 {
+		quota.Pods = team.Pods
+	}
+
+	if quota == (QuotaConfig{}) {
+		for _, del := range []func() error{
+			func() error {
+				return k.clientset.CoreV1().ResourceQuotas(ns).Delete(ctx, teamQuotaName, metav1.DeleteOptions{})
+			},
+			func() error {
+				return k.clientset.CoreV1().LimitRanges(ns).Delete(ctx, teamLimitRangeName, metav1.DeleteOptions{})
+			},
+		} {
+			if err := del(); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("removing namespace quota: %w", err)
+			}
+		}
+		return nil
+	}
+
+	hard, err := quotaResourceList(quota)
+	if err != nil {
+		return err
+	}
+	rq := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   teamQuotaName,
+			Labels: map[string]string{LabelTeam: config.TeamName},
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}
+	if err := k.applyResourceQuota(ctx, ns, rq); err != nil {
+		return err
+	}
+
+	if quota.CPU == "" && quota.Memory == "" {
+		err := k.clientset.CoreV1().LimitRanges(ns).Delete(ctx, teamLimitRangeName, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("removing limit range: %w", err)
+		}
+		return nil
+	}
+	defaultCPU, err := resource.ParseQuantity(k.namespaceDefaults.limitCPU)
+	if err != nil {
+		return fmt.Errorf("invalid default container cpu %q: %w", k.namespaceDefaults.limitCPU, err)
+	}
+	defaultMemory, err := memoryQuantity(k.namespaceDefaults.limitMemory)
+	if err != nil {
+		return fmt.Errorf("invalid default container memory: %w", err)
+	}
+	defaults := corev1.ResourceList{
+		corev1.ResourceCPU:    defaultCPU,
+		corev1.ResourceMemory: defaultMemory,
+	}
+	lr := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   teamLimitRangeName,
+			Labels: map[string]string{LabelTeam: config.TeamName},
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				Default:        defaults,
+				DefaultRequest: defaults,
+			}},
+		},
+	}
+	return k.applyLimitRange(ctx, ns, lr)
+}
+
+// quotaResourceList converts a QuotaConfig to a ResourceQuota hard list.
+// CPU and memory bound both requests and limits.
+func quotaResourceList(q QuotaConfig) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	if q.CPU != "" {
+		cpu, err := resource.ParseQuantity(q.CPU)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota cpu %q: %w", q.CPU, err)
+		}
+		list[corev1.ResourceRequestsCPU] = cpu
+		list[corev1.ResourceLimitsCPU] = cpu
+	}
+	if q.Memory != "" {
+		mem, err := memoryQuantity(q.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota memory: %w", err)
+		}
+		list[corev1.ResourceRequestsMemory] = mem
+		list[corev1.ResourceLimitsMemory] = mem
+	}
+	if q.Pods > 0 {
+		list[corev1.ResourcePods] = *resource.NewQuantity(int64(q.Pods), resource.DecimalSI)
+	}
+	return list, nil
+}
+
+// applyResourceQuota creates rq or replaces the spec of the existing one.
+func (k *K8sRuntime) applyResourceQuota(ctx context.Context, ns string, rq *corev1.ResourceQuota) error {
+	client := k.clientset.CoreV1().ResourceQuotas(ns)
+	_, err := client.Create(ctx, rq, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating resource quota: %w", err)
+	}
+	existing, err := client.Get(ctx, rq.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting resource quota: %w", err)
+	}
+	existing.Spec = rq.Spec
+	if _, err := client.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating resource quota: %w", err)
+	}
+	return nil
+}
+
+// applyLimitRange creates lr or replaces the spec of the existing one.
+func (k *K8sRuntime) applyLimitRange(ctx context.Context, ns string, lr *corev1.LimitRange) error {
+	client := k.clientset.CoreV1().LimitRanges(ns)
+	_, err := client.Create(ctx, lr, metav1.CreateOptions{})
+	if err == nil {
+		return nil
+	}
+	if !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating limit range: %w", err)
+	}
+	existing, err := client.Get(ctx, lr.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("getting limit range: %w", err)
+	}
+	existing.Spec = lr.Spec
+	if _, err := client.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating limit range: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+package runtime
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseKeyValues(t *testing.T) {
+	got := parseKeyValues(" cost-center=eng , owner=platform,broken,=x,empty=")
+	want := map[string]string{"cost-center": "eng", "owner": "platform", "empty": ""}
+	if len(got) != len(want) {
+		t.Fatalf("parseKeyValues = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("parseKeyValues[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+	if len(parseKeyValues("")) != 0 {
+		t.Error("parseKeyValues(\"\") should be empty")
+	}
+}
+
+func TestValidateNamespaceMetadata(t *testing.T) {
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{"valid", map[string]string{"cost-center": "eng", "example.com/owner": "platform"}, map[string]string{"example.com/notes": "any value, with spaces"}, false},
+		{"reserved prefix", map[string]string{"agentcrew.team": "x"}, nil, true},
+		{"bad label key", map[string]string{"bad key": "x"}, nil, true},
+		{"bad label value", map[string]string{"owner": "has spaces"}, nil, true},
+		{"bad annotation key", nil, map[string]string{"bad key": "x"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNamespaceMetadata(tt.labels, tt.annotations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNamespaceMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateQuota(t *testing.T) {
+	if err := ValidateQuota(QuotaConfig{CPU: "8", Memory: "16g", Pods: 10}); err != nil {
+		t.Errorf("valid quota: %v", err)
+	}
+	for _, q := range []QuotaConfig{{CPU: "lots"}, {Memory: "big"}, {Pods: -1}} {
+		if err := ValidateQuota(q); err == nil {
+			t.Errorf("ValidateQuota(%+v) should fail", q)
+		}
+	}
+}
+
+func newFakeK8sRuntime(defaults namespaceDefaults) (*K8sRuntime, *fake.Clientset) {
+	if defaults.limitCPU == "" {
+		defaults.limitCPU = defaultContainerCPU
+	}
+	if defaults.limitMemory == "" {
+		defaults.limitMemory = defaultContainerMemory
+	}
+	clientset := fake.NewSimpleClientset()
+	return &K8sRuntime{clientset: clientset, namespaceDefaults: defaults}, clientset
+}
+
+func TestDeployInfra_NamespaceMetadata(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{
+		labels:      map[string]string{"cost-center": "platform", "env": "prod"},
+		annotations: map[string]string{"example.com/owner": "ops"},
+	})
+	ctx := t.Context()
+
+	err := k.DeployInfra(ctx, InfraConfig{
+		TeamName: "billing",
+		Namespace: NamespaceConfig{
+			Labels:      map[string]string{"cost-center": "finance"},
+			Annotations: map[string]string{"example.com/contact": "alice"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+
+	ns, err := clientset.CoreV1().Namespaces().Get(ctx, "agentcrew-billing", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting namespace: %v", err)
+	}
+	wantLabels := map[string]string{"cost-center": "finance", "env": "prod", LabelTeam: "billing"}
+	for k, v := range wantLabels {
+		if ns.Labels[k] != v {
+			t.Errorf("label %s = %q, want %q", k, ns.Labels[k], v)
+		}
+	}
+	if ns.Annotations["example.com/owner"] != "ops" || ns.Annotations["example.com/contact"] != "alice" {
+		t.Errorf("annotations = %v", ns.Annotations)
+	}
+
+	// No quota configured: none is created.
+	if _, err := clientset.CoreV1().ResourceQuotas(ns.Name).Get(ctx, teamQuotaName, metav1.GetOptions{}); err == nil {
+		t.Error("expected no resource quota")
+	}
+
+	// Redeploying updates the existing namespace and keeps foreign labels.
+	ns.Labels["external"] = "kept"
+	if _, err := clientset.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("updating namespace: %v", err)
+	}
+	err = k.DeployInfra(ctx, InfraConfig{
+		TeamName:  "billing",
+		Namespace: NamespaceConfig{Labels: map[string]string{"cost-center": "sales"}},
+	})
+	if err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+	ns, _ = clientset.CoreV1().Namespaces().Get(ctx, "agentcrew-billing", metav1.GetOptions{})
+	if ns.Labels["cost-center"] != "sales" || ns.Labels["external"] != "kept" {
+		t.Errorf("labels after redeploy = %v", ns.Labels)
+	}
+}
+
+func TestDeployInfra_SlugConflict(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "Data Team", TeamID: "team-1"}); err != nil {
+		t.Fatalf("first deploy: %v", err)
+	}
+	ns, _ := clientset.CoreV1().Namespaces().Get(ctx, "agentcrew-data-team", metav1.GetOptions{})
+	if ns.Labels[LabelTeamID] != "team-1" {
+		t.Errorf("team-id label = %q, want team-1", ns.Labels[LabelTeamID])
+	}
+
+	// Redeploying the same team is fine.
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "Data Team", TeamID: "team-1"}); err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+
+	// A different team whose name sanitizes to the same slug is refused.
+	err := k.DeployInfra(ctx, InfraConfig{TeamName: "data-team", TeamID: "team-2"})
+	var conflict *SlugConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected SlugConflictError, got %v", err)
+	}
+	if conflict.Slug != "data-team" || conflict.OwnerTeamID != "team-1" {
+		t.Errorf("conflict = %+v", conflict)
+	}
+}
+
+func TestCheckTeamOwner(t *testing.T) {
+	tests := []struct {
+		name    string
+		teamID  string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"unlabeled resource is adopted", "team-1", map[string]string{LabelTeam: "x"}, false},
+		{"same owner", "team-1", map[string]string{LabelTeamID: "team-1"}, false},
+		{"caller without id", "", map[string]string{LabelTeamID: "team-1"}, false},
+		{"other owner", "team-2", map[string]string{LabelTeamID: "team-1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkTeamOwner("x", tt.teamID, tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("checkTeamOwner() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestListTeamInfra_K8s(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "Billing", TeamID: "team-1"}); err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "Alpha"}); err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-system"},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating namespace: %v", err)
+	}
+	if _, err := clientset.CoreV1().Pods("agentcrew-billing").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "agent-leader",
+			Labels: map[string]string{LabelTeam: "billing", LabelRole: "leader"},
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating pod: %v", err)
+	}
+
+	infra, err := k.ListTeamInfra(ctx)
+	if err != nil {
+		t.Fatalf("ListTeamInfra: %v", err)
+	}
+	if len(infra) != 2 {
+		t.Fatalf("ListTeamInfra = %+v, want alpha and billing", infra)
+	}
+	if infra[0].Slug != "alpha" || infra[0].TeamID != "" || len(infra[0].Resources) != 1 {
+		t.Errorf("infra[0] = %+v", infra[0])
+	}
+	billing := infra[1]
+	if billing.Slug != "billing" || billing.TeamID != "team-1" {
+		t.Errorf("infra[1] = %+v", billing)
+	}
+	if !billing.HasAgent("agentcrew-billing/agent-leader") {
+		t.Errorf("billing resources %+v do not include the leader pod", billing.Resources)
+	}
+	if billing.HasAgent("agentcrew-billing/agent-gone") {
+		t.Error("HasAgent reported a pod that does not exist")
+	}
+}
+
+func TestCollectTeamInfra(t *testing.T) {
+	infra := collectTeamInfra([]InfraResource{
+		{Kind: ResourceContainer, Name: "team-beta-nats", ID: "c1", labels: map[string]string{LabelTeam: "beta", LabelRole: "nats"}},
+		{Kind: ResourceNetwork, Name: "team-beta", labels: map[string]string{LabelTeam: "beta", LabelTeamID: "team-2"}},
+		{Kind: ResourceVolume, Name: "team-beta-workspace", labels: map[string]string{LabelTeam: "beta"}},
+		{Kind: ResourceContainer, Name: "agentcrew-ollama", labels: map[string]string{LabelRole: "ollama"}},
+		{Kind: ResourceNetwork, Name: "team-alpha", labels: map[string]string{LabelTeam: "alpha"}},
+	})
+	if len(infra) != 2 {
+		t.Fatalf("collectTeamInfra = %+v, want alpha and beta", infra)
+	}
+	if infra[0].Slug != "alpha" || infra[0].TeamID != "" || len(infra[0].Resources) != 1 {
+		t.Errorf("infra[0] = %+v", infra[0])
+	}
+	if infra[1].Slug != "beta" || infra[1].TeamID != "team-2" || len(infra[1].Resources) != 3 {
+		t.Errorf("infra[1] = %+v", infra[1])
+	}
+	if !infra[1].HasAgent("c1") || infra[1].HasAgent("team-beta") {
+		t.Error("HasAgent should match containers by ID only")
+	}
+}
+
+func TestDeployInfra_Quota(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{
+		quota: QuotaConfig{CPU: "8", Memory: "16g", Pods: 20},
+	})
+	ctx := t.Context()
+	ns := "agentcrew-quota-team"
+
+	// Team quota overrides the operator default field by field.
+	err := k.DeployInfra(ctx, InfraConfig{
+		TeamName:  "quota-team",
+		Namespace: NamespaceConfig{Quota: QuotaConfig{CPU: "4"}},
+	})
+	if err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	rq, err := clientset.CoreV1().ResourceQuotas(ns).Get(ctx, teamQuotaName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting resource quota: %v", err)
+	}
+	hard := rq.Spec.Hard
+	if q := hard[corev1.ResourceLimitsCPU]; q.String() != "4" {
+		t.Errorf("limits.cpu = %s, want 4", q.String())
+	}
+	if q := hard[corev1.ResourceRequestsMemory]; q.Value() != 16<<30 {
+		t.Errorf("requests.memory = %d, want %d", q.Value(), int64(16<<30))
+	}
+	if q := hard[corev1.ResourcePods]; q.Value() != 20 {
+		t.Errorf("pods = %d, want 20", q.Value())
+	}
+
+	lr, err := clientset.CoreV1().LimitRanges(ns).Get(ctx, teamLimitRangeName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting limit range: %v", err)
+	}
+	if len(lr.Spec.Limits) != 1 {
+		t.Fatalf("limit range items = %d, want 1", len(lr.Spec.Limits))
+	}
+	item := lr.Spec.Limits[0]
+	if q := item.Default[corev1.ResourceCPU]; q.String() != defaultContainerCPU {
+		t.Errorf("default cpu = %s, want %s", q.String(), defaultContainerCPU)
+	}
+	if q := item.DefaultRequest[corev1.ResourceMemory]; q.Value() != 2<<30 {
+		t.Errorf("default request memory = %d, want %d", q.Value(), int64(2<<30))
+	}
+
+	// Redeploying with a new quota updates the existing object.
+	err = k.DeployInfra(ctx, InfraConfig{
+		TeamName:  "quota-team",
+		Namespace: NamespaceConfig{Quota: QuotaConfig{CPU: "2"}},
+	})
+	if err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+	rq, _ = clientset.CoreV1().ResourceQuotas(ns).Get(ctx, teamQuotaName, metav1.GetOptions{})
+	if q := rq.Spec.Hard[corev1.ResourceRequestsCPU]; q.String() != "2" {
+		t.Errorf("requests.cpu after redeploy = %s, want 2", q.String())
+	}
+}
+
+func TestDeployInfra_QuotaRemoved(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+	ns := "agentcrew-shrink"
+
+	if err := k.DeployInfra(ctx, InfraConfig{
+		TeamName:  "shrink",
+		Namespace: NamespaceConfig{Quota: QuotaConfig{Memory: "4g"}},
+	}); err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	if _, err := clientset.CoreV1().LimitRanges(ns).Get(ctx, teamLimitRangeName, metav1.GetOptions{}); err != nil {
+		t.Fatalf("expected limit range: %v", err)
+	}
+
+	// Pods only: the quota stays, the limit range is not needed.
+	if err := k.DeployInfra(ctx, InfraConfig{
+		TeamName:  "shrink",
+		Namespace: NamespaceConfig{Quota: QuotaConfig{Pods: 5}},
+	}); err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+	if _, err := clientset.CoreV1().ResourceQuotas(ns).Get(ctx, teamQuotaName, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected resource quota: %v", err)
+	}
+	if _, err := clientset.CoreV1().LimitRanges(ns).Get(ctx, teamLimitRangeName, metav1.GetOptions{}); err == nil {
+		t.Error("expected limit range to be removed")
+	}
+
+	// No quota at all: both are removed.
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "shrink"}); err != nil {
+		t.Fatalf("redeploy: %v", err)
+	}
+	if _, err := clientset.CoreV1().ResourceQuotas(ns).Get(ctx, teamQuotaName, metav1.GetOptions{}); err == nil {
+		t.Error("expected resource quota to be removed")
+	}
+}
+
+func TestTeardownInfra_PreserveWorkspace(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	if err := k.DeployInfra(ctx, InfraConfig{TeamName: "billing"}); err != nil {
+		t.Fatalf("DeployInfra: %v", err)
+	}
+	if _, err := clientset.CoreV1().Services("agentcrew-billing").Create(ctx, &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: natsServiceName()},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating service: %v", err)
+	}
+	if _, err := clientset.CoreV1().Pods("agentcrew-billing").Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "agent-leader",
+			Labels: map[string]string{LabelTeam: "billing", LabelRole: "leader"},
+		},
+	}, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("creating pod: %v", err)
+	}
+
+	if err := k.TeardownInfra(ctx, "billing", TeardownOptions{PreserveWorkspace: true}); err != nil {
+		t.Fatalf("TeardownInfra: %v", err)
+	}
+	if _, err := clientset.CoreV1().PersistentVolumeClaims("agentcrew-billing").Get(ctx, workspacePVCName(), metav1.GetOptions{}); err != nil {
+		t.Errorf("workspace PVC was removed: %v", err)
+	}
+	if pods, _ := clientset.CoreV1().Pods("agentcrew-billing").List(ctx, metav1.ListOptions{}); len(pods.Items) != 0 {
+		t.Errorf("pods left: %d", len(pods.Items))
+	}
+	if _, err := clientset.CoreV1().Services("agentcrew-billing").Get(ctx, natsServiceName(), metav1.GetOptions{}); err == nil {
+		t.Error("nats service was kept")
+	}
+
+	// Only the workspace is left, which the garbage collector leaves alone.
+	infra, err := k.ListTeamInfra(ctx)
+	if err != nil {
+		t.Fatalf("ListTeamInfra: %v", err)
+	}
+	if len(infra) != 1 || infra[0].HasActiveResources() {
+		t.Errorf("ListTeamInfra = %+v, want only the namespace", infra)
+	}
+
+	if err := k.TeardownInfra(ctx, "billing", TeardownOptions{}); err != nil {
+		t.Fatalf("TeardownInfra: %v", err)
+	}
+	if _, err := clientset.CoreV1().Namespaces().Get(ctx, "agentcrew-billing", metav1.GetOptions{}); err == nil {
+		t.Error("namespace was kept")
+	}
+}
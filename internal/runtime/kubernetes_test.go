@@ -1,9 +1,13 @@
 package runtime
 
 import (
+	"errors"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 func TestTeamNamespaceName(t *testing.T) {
@@ -109,6 +113,46 @@ func TestPodPhaseToStatus(t *testing.T) {
 	}
 }
 
+func TestPodUnhealthy(t *testing.T) {
+	started, notStarted := true, false
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+	tests := []struct {
+		name string
+		cs   corev1.ContainerStatus
+		want bool
+	}{
+		{"ready", corev1.ContainerStatus{Name: "agent", State: running, Started: &started, Ready: true}, false},
+		{"failing readiness", corev1.ContainerStatus{Name: "agent", State: running, Started: &started}, true},
+		{"still starting", corev1.ContainerStatus{Name: "agent", State: running, Started: &notStarted}, false},
+		{"other container", corev1.ContainerStatus{Name: "nats", State: running, Started: &started}, false},
+	}
+	for _, tt := range tests {
+		pod := &corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{tt.cs}}}
+		if got := podUnhealthy(pod); got != tt.want {
+			t.Errorf("%s: podUnhealthy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSidecarProbes(t *testing.T) {
+	for name, probe := range map[string]*corev1.Probe{
+		"startup":   sidecarStartupProbe(),
+		"readiness": sidecarReadinessProbe(),
+	} {
+		if probe.Exec == nil || len(probe.Exec.Command) == 0 {
+			t.Fatalf("%s probe: expected an exec command", name)
+		}
+		cmd := probe.Exec.Command
+		if cmd[0] != "curl" || cmd[len(cmd)-1] != "http://"+SidecarAdminListen+"/healthz" {
+			t.Errorf("%s probe command: got %v", name, cmd)
+		}
+	}
+	// The startup probe tolerates the full start period.
+	if p := sidecarStartupProbe(); p.PeriodSeconds*p.FailureThreshold != 300 {
+		t.Errorf("startup window: got %ds, want 300s", p.PeriodSeconds*p.FailureThreshold)
+	}
+}
+
 func TestGetNATSURL_K8s(t *testing.T) {
 	k := &K8sRuntime{}
 	tests := []struct {
@@ -142,3 +186,164 @@ func TestGetNATSURL_Docker(t *testing.T) {
 		}
 	}
 }
+
+func TestMemoryQuantity(t *testing.T) {
+	tests := []struct {
+		input string
+		bytes int64
+	}{
+		{"512m", 512 * 1024 * 1024},
+		{"2g", 2 * 1024 * 1024 * 1024},
+		{"512Mi", 512 * 1024 * 1024},
+		{"1Gi", 1024 * 1024 * 1024},
+	}
+	for _, tt := range tests {
+		q, err := memoryQuantity(tt.input)
+		if err != nil {
+			t.Errorf("memoryQuantity(%q): %v", tt.input, err)
+			continue
+		}
+		if q.Value() != tt.bytes {
+			t.Errorf("memoryQuantity(%q) = %d bytes, want %d", tt.input, q.Value(), tt.bytes)
+		}
+	}
+
+	if _, err := memoryQuantity("lots"); err == nil {
+		t.Error("memoryQuantity(\"lots\"): expected error")
+	}
+}
+
+func TestDeployAgent_K8sExtraWorkspaces(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	_, err := k.DeployAgent(ctx, AgentConfig{
+		TeamName:      "multi",
+		Name:          "leader",
+		WorkspacePath: "/srv/app",
+		Env:           map[string]string{"ANTHROPIC_API_KEY": "sk-test"},
+		ExtraWorkspaces: []protocol.ExtraWorkspace{
+			{Name: "docs", HostPath: "/srv/docs", ReadOnly: true},
+			{Name: "lib", HostPath: "/srv/lib"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("DeployAgent: %v", err)
+	}
+	pod, err := clientset.CoreV1().Pods(teamNamespaceName("multi")).Get(ctx, agentPodName("leader"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting pod: %v", err)
+	}
+
+	hostPaths := map[string]string{}
+	for _, v := range pod.Spec.Volumes {
+		if v.HostPath != nil {
+			hostPaths[v.Name] = v.HostPath.Path
+		}
+	}
+	mounts := map[string]corev1.VolumeMount{}
+	for _, m := range pod.Spec.Containers[0].VolumeMounts {
+		mounts[m.MountPath] = m
+	}
+	for _, want := range []struct {
+		mountPath, hostPath string
+		readOnly            bool
+	}{{"/workspaces/docs", "/srv/docs", true}, {"/workspaces/lib", "/srv/lib", false}} {
+		m, ok := mounts[want.mountPath]
+		if !ok || hostPaths[m.Name] != want.hostPath || m.ReadOnly != want.readOnly {
+			t.Errorf("mount %s: got %+v from %q", want.mountPath, m, hostPaths[m.Name])
+		}
+	}
+
+	var scope string
+	for _, e := range pod.Spec.Containers[0].Env {
+		if e.Name == "AGENT_EXTRA_WORKSPACES" {
+			scope = e.Value
+		}
+	}
+	if scope != "/workspaces/docs:ro,/workspaces/lib:rw" {
+		t.Errorf("AGENT_EXTRA_WORKSPACES: got %q", scope)
+	}
+}
+
+func TestDeployAgent_K8sRestartPolicy(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	for _, tc := range []struct {
+		name    string
+		restart RestartPolicy
+		policy  corev1.RestartPolicy
+		backoff string
+	}{
+		{"never", RestartPolicy{}, corev1.RestartPolicyNever, ""},
+		{"crashy", RestartPolicy{Mode: RestartOnFailure, MaxRetries: 3, BackoffSeconds: 15}, corev1.RestartPolicyOnFailure, "15s"},
+		{"steady", RestartPolicy{Mode: RestartAlways}, corev1.RestartPolicyAlways, ""},
+	} {
+		if _, err := k.DeployAgent(ctx, AgentConfig{
+			TeamName: tc.name,
+			Name:     "leader",
+			Env:      map[string]string{"ANTHROPIC_API_KEY": "sk-test"},
+			Restart:  tc.restart,
+		}); err != nil {
+			t.Fatalf("%s: DeployAgent: %v", tc.name, err)
+		}
+		pod, err := clientset.CoreV1().Pods(teamNamespaceName(tc.name)).Get(ctx, agentPodName("leader"), metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: getting pod: %v", tc.name, err)
+		}
+		if pod.Spec.RestartPolicy != tc.policy {
+			t.Errorf("%s: restart policy = %q, want %q", tc.name, pod.Spec.RestartPolicy, tc.policy)
+		}
+		var backoff string
+		for _, e := range pod.Spec.Containers[0].Env {
+			if e.Name == "AGENT_RESTART_BACKOFF" {
+				backoff = e.Value
+			}
+		}
+		if backoff != tc.backoff {
+			t.Errorf("%s: AGENT_RESTART_BACKOFF = %q, want %q", tc.name, backoff, tc.backoff)
+		}
+		var stateMounted bool
+		for _, m := range pod.Spec.Containers[0].VolumeMounts {
+			stateMounted = stateMounted || m.MountPath == protocol.RestartStateDir
+		}
+		if stateMounted != (tc.backoff != "") {
+			t.Errorf("%s: restart state mounted = %v", tc.name, stateMounted)
+		}
+	}
+}
+
+func TestK8sGetStatus_RestartCount(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	clientset.CoreV1().Pods("team-a").Create(t.Context(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "leader", Namespace: "team-a"},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "agent", RestartCount: 4}},
+		},
+	}, metav1.CreateOptions{})
+
+	st, err := k.GetStatus(t.Context(), "team-a/leader")
+	if err != nil {
+		t.Fatalf("GetStatus: %v", err)
+	}
+	if st.RestartCount != 4 {
+		t.Errorf("restart count = %d, want 4", st.RestartCount)
+	}
+}
+
+func TestK8sGetStatus_NotFound(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	clientset.CoreV1().Pods("team-a").Create(t.Context(), &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "leader", Namespace: "team-a"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}, metav1.CreateOptions{})
+
+	if st, err := k.GetStatus(t.Context(), "team-a/leader"); err != nil || st.Status != StatusRunning {
+		t.Fatalf("GetStatus = %+v, %v, want running", st, err)
+	}
+	if _, err := k.GetStatus(t.Context(), "team-a/removed"); !errors.Is(err, ErrAgentNotFound) {
+		t.Errorf("GetStatus of a deleted pod = %v, want ErrAgentNotFound", err)
+	}
+}
@@ -0,0 +1,285 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// MultiRuntime runs each team on one of several runtimes, so that teams can
+// be migrated from one runtime to another. A team runs on the runtime named
+// by its runtime field (see SetTeamLookup), or on the default runtime.
+// Container IDs of agents on other runtimes than the default are prefixed
+// with the runtime name and ":".
+//
+// Ollama, Qdrant and the RAG MCP server are shared by teams, and are only
+// run by the default runtime (see Default).
+type MultiRuntime struct {
+	defaultName string
+	runtimes    map[string]AgentRuntime
+
+	mu     sync.RWMutex
+	lookup func(slug string) string
+}
+
+// NewMultiRuntime creates a MultiRuntime over runtimes, keyed by name, with
+// defaultName as the default.
+func NewMultiRuntime(defaultName string, runtimes map[string]AgentRuntime) (*MultiRuntime, error) {
+	if runtimes[defaultName] == nil {
+		return nil, fmt.Errorf("default runtime %q is not one of the runtimes", defaultName)
+	}
+	for name := range runtimes {
+		if name == "" || strings.ContainsAny(name, ":/") {
+			return nil, fmt.Errorf("invalid runtime name %q", name)
+		}
+	}
+	return &MultiRuntime{defaultName: defaultName, runtimes: runtimes}, nil
+}
+
+// SetTeamLookup sets the function returning the runtime name of the team
+// with the given slug. Teams it returns "" or an unknown name for run on
+// the default runtime.
+func (m *MultiRuntime) SetTeamLookup(lookup func(slug string) string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lookup = lookup
+}
+
+// DefaultName returns the name of the default runtime.
+func (m *MultiRuntime) DefaultName() string {
+	return m.defaultName
+}
+
+// Default returns the default runtime, which also runs the shared
+// services.
+func (m *MultiRuntime) Default() AgentRuntime {
+	return m.runtimes[m.defaultName]
+}
+
+// Names returns the names of the runtimes, sorted.
+func (m *MultiRuntime) Names() []string {
+	names := make([]string, 0, len(m.runtimes))
+	for name := range m.runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether a runtime is named name.
+func (m *MultiRuntime) Has(name string) bool {
+	return m.runtimes[name] != nil
+}
+
+// Runtime returns the runtime named name, or nil.
+func (m *MultiRuntime) Runtime(name string) AgentRuntime {
+	return m.runtimes[name]
+}
+
+// team returns the name and runtime of the team.
+func (m *MultiRuntime) team(teamName string) (string, AgentRuntime) {
+	m.mu.RLock()
+	lookup := m.lookup
+	m.mu.RUnlock()
+	if lookup != nil {
+		if name := lookup(naming.Slug(teamName)); m.runtimes[name] != nil {
+			return name, m.runtimes[name]
+		}
+	}
+	return m.defaultName, m.Default()
+}
+
+// agent returns the runtime of an agent ID returned by DeployAgent and the
+// ID that runtime knows it by.
+func (m *MultiRuntime) agent(id string) (AgentRuntime, string, error) {
+	name, rawID, ok := strings.Cut(id, ":")
+	if !ok {
+		return m.Default(), id, nil
+	}
+	rt := m.runtimes[name]
+	if rt == nil {
+		return nil, "", fmt.Errorf("agent %s is on unknown runtime %q", id, name)
+	}
+	return rt, rawID, nil
+}
+
+// agentID returns the ID of an agent of the named runtime.
+func (m *MultiRuntime) agentID(name, rawID string) string {
+	if name == m.defaultName {
+		return rawID
+	}
+	return name + ":" + rawID
+}
+
+func (m *MultiRuntime) DeployInfra(ctx context.Context, config InfraConfig) error {
+	_, rt := m.team(config.TeamName)
+	return rt.DeployInfra(ctx, config)
+}
+
+func (m *MultiRuntime) DeployAgent(ctx context.Context, config AgentConfig) (*AgentInstance, error) {
+	name, rt := m.team(config.TeamName)
+	inst, err := rt.DeployAgent(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	inst.ID = m.agentID(name, inst.ID)
+	return inst, nil
+}
+
+func (m *MultiRuntime) StopAgent(ctx context.Context, id string) error {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return err
+	}
+	return rt.StopAgent(ctx, rawID)
+}
+
+func (m *MultiRuntime) RemoveAgent(ctx context.Context, id string) error {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return err
+	}
+	return rt.RemoveAgent(ctx, rawID)
+}
+
+func (m *MultiRuntime) GetStatus(ctx context.Context, id string) (*AgentStatus, error) {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return nil, err
+	}
+	status, err := rt.GetStatus(ctx, rawID)
+	if err != nil {
+		return nil, err
+	}
+	status.ID = id
+	return status, nil
+}
+
+func (m *MultiRuntime) StreamLogs(ctx context.Context, id string) (io.ReadCloser, error) {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return nil, err
+	}
+	return rt.StreamLogs(ctx, rawID)
+}
+
+func (m *MultiRuntime) TeardownInfra(ctx context.Context, teamName string, opts TeardownOptions) error {
+	_, rt := m.team(teamName)
+	return rt.TeardownInfra(ctx, teamName, opts)
+}
+
+func (m *MultiRuntime) DeleteWorkspace(ctx context.Context, teamName string) error {
+	_, rt := m.team(teamName)
+	return rt.DeleteWorkspace(ctx, teamName)
+}
+
+func (m *MultiRuntime) GetNATSURL(teamName string) string {
+	_, rt := m.team(teamName)
+	return rt.GetNATSURL(teamName)
+}
+
+func (m *MultiRuntime) GetNATSConnectURL(ctx context.Context, teamName string) (string, error) {
+	_, rt := m.team(teamName)
+	return rt.GetNATSConnectURL(ctx, teamName)
+}
+
+func (m *MultiRuntime) ExecInContainer(ctx context.Context, id string, cmd []string) (string, error) {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return "", err
+	}
+	return rt.ExecInContainer(ctx, rawID, cmd)
+}
+
+func (m *MultiRuntime) ReadFile(ctx context.Context, containerID string, path string) ([]byte, error) {
+	rt, rawID, err := m.agent(containerID)
+	if err != nil {
+		return nil, err
+	}
+	return rt.ReadFile(ctx, rawID, path)
+}
+
+func (m *MultiRuntime) WriteFile(ctx context.Context, containerID string, path string, content []byte) error {
+	rt, rawID, err := m.agent(containerID)
+	if err != nil {
+		return err
+	}
+	return rt.WriteFile(ctx, rawID, path, content)
+}
+
+func (m *MultiRuntime) CopyToContainer(ctx context.Context, containerID string, destPath string, content []byte) error {
+	rt, rawID, err := m.agent(containerID)
+	if err != nil {
+		return err
+	}
+	return rt.CopyToContainer(ctx, rawID, destPath, content)
+}
+
+// ImageDigest returns the image digest of the agent, if its runtime can
+// report it.
+func (m *MultiRuntime) ImageDigest(ctx context.Context, id string) (string, error) {
+	rt, rawID, err := m.agent(id)
+	if err != nil {
+		return "", err
+	}
+	digester, ok := rt.(ImageDigester)
+	if !ok {
+		return "", nil
+	}
+	return digester.ImageDigest(ctx, rawID)
+}
+
+// ListTeamInfra returns the team infrastructure of every runtime. It fails
+// if a runtime cannot list it, since a partial list would make present
+// agents look missing.
+func (m *MultiRuntime) ListTeamInfra(ctx context.Context) ([]TeamInfra, error) {
+	var resources []InfraResource
+	for _, name := range m.Names() {
+		lister, ok := m.runtimes[name].(InfraLister)
+		if !ok {
+			return nil, fmt.Errorf("the %s runtime cannot list team infrastructure", name)
+		}
+		infra, err := lister.ListTeamInfra(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s runtime: %w", name, err)
+		}
+		for _, ti := range infra {
+			for _, r := range ti.Resources {
+				if r.ID != "" {
+					r.ID = m.agentID(name, r.ID)
+				}
+				resources = append(resources, r)
+			}
+		}
+	}
+	return collectTeamInfra(resources), nil
+}
+
+// PrePullImages pulls images with every runtime that can pre-pull them.
+func (m *MultiRuntime) PrePullImages(ctx context.Context, images []string) error {
+	var errs []error
+	for _, name := range m.Names() {
+		if puller, ok := m.runtimes[name].(ImagePrePuller); ok {
+			if err := puller.PrePullImages(ctx, images); err != nil {
+				errs = append(errs, fmt.Errorf("%s runtime: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TeamHost returns the host the team was placed on, if its runtime places
+// teams on hosts.
+func (m *MultiRuntime) TeamHost(teamName string) string {
+	_, rt := m.team(teamName)
+	if hp, ok := rt.(HostPlacer); ok {
+		return hp.TeamHost(teamName)
+	}
+	return ""
+}
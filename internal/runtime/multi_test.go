@@ -0,0 +1,62 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMultiRuntime_RoutesTeams(t *testing.T) {
+	docker, _ := NewChaosRuntime("")
+	k8s, _ := NewChaosRuntime("")
+	m, err := NewMultiRuntime(NameDocker, map[string]AgentRuntime{NameDocker: docker, NameKubernetes: k8s})
+	if err != nil {
+		t.Fatalf("NewMultiRuntime: %v", err)
+	}
+	m.SetTeamLookup(func(slug string) string {
+		if slug == "moved-team" {
+			return NameKubernetes
+		}
+		return ""
+	})
+	ctx := context.Background()
+
+	moved, err := m.DeployAgent(ctx, AgentConfig{TeamName: "Moved Team", Name: "leader"})
+	if err != nil {
+		t.Fatalf("DeployAgent: %v", err)
+	}
+	if moved.ID != "kubernetes:chaos-Moved Team-leader" {
+		t.Errorf("agent on kubernetes: got ID %q", moved.ID)
+	}
+	if _, err := k8s.GetStatus(ctx, "chaos-Moved Team-leader"); err != nil {
+		t.Errorf("agent not deployed on kubernetes: %v", err)
+	}
+	status, err := m.GetStatus(ctx, moved.ID)
+	if err != nil || status.ID != moved.ID {
+		t.Errorf("GetStatus: got %+v, %v", status, err)
+	}
+
+	stayed, err := m.DeployAgent(ctx, AgentConfig{TeamName: "other", Name: "leader"})
+	if err != nil {
+		t.Fatalf("DeployAgent: %v", err)
+	}
+	if stayed.ID != "chaos-other-leader" {
+		t.Errorf("agent on the default runtime: got ID %q", stayed.ID)
+	}
+	if _, err := docker.GetStatus(ctx, stayed.ID); err != nil {
+		t.Errorf("agent not deployed on docker: %v", err)
+	}
+
+	if _, err := m.GetStatus(ctx, "gone:abc"); err == nil {
+		t.Error("unknown runtime: expected an error")
+	}
+}
+
+func TestNewMultiRuntime_Invalid(t *testing.T) {
+	docker, _ := NewChaosRuntime("")
+	if _, err := NewMultiRuntime(NameKubernetes, map[string]AgentRuntime{NameDocker: docker}); err == nil {
+		t.Error("missing default: expected an error")
+	}
+	if _, err := NewMultiRuntime(NameDocker, map[string]AgentRuntime{NameDocker: docker, "a:b": docker}); err == nil {
+		t.Error("name with a colon: expected an error")
+	}
+}
@@ -0,0 +1,110 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	agentNats "github.com/helmcode/agent-crew/internal/nats"
+)
+
+// Settings that configure a team's NATS server. They are read from the
+// organization's settings, then from the API's environment.
+const (
+	SettingNATSImage          = "NATS_IMAGE"
+	SettingJetStreamMaxMemory = "NATS_JETSTREAM_MAX_MEMORY"
+	SettingJetStreamMaxStore  = "NATS_JETSTREAM_MAX_STORE"
+)
+
+// natsConfigFile is the name of the server configuration file written for
+// a NATS server with JetStream limits.
+const natsConfigFile = "agentcrew-jetstream.conf"
+
+// LabelNATSLimits records the JetStream limits a NATS container was
+// started with, so that a change is noticed.
+const LabelNATSLimits = "agentcrew.nats-limits"
+
+// NATSConfig configures a team's NATS server.
+type NATSConfig struct {
+	// Image is the NATS server image, NATSImage if empty. Pin a version
+	// with a tag or digest.
+	Image string
+	// MaxMemory and MaxStore bound, in bytes, the memory and disk
+	// JetStream uses. Zero means no limit.
+	MaxMemory int64
+	MaxStore  int64
+}
+
+// NATSConfigFromEnv reads the NATS settings from env, falling back to the
+// process environment.
+func NATSConfigFromEnv(env map[string]string) (NATSConfig, error) {
+	get := func(key string) string {
+		if v := strings.TrimSpace(env[key]); v != "" {
+			return v
+		}
+		return strings.TrimSpace(os.Getenv(key))
+	}
+	cfg := NATSConfig{Image: get(SettingNATSImage)}
+	for key, dst := range map[string]*int64{
+		SettingJetStreamMaxMemory: &cfg.MaxMemory,
+		SettingJetStreamMaxStore:  &cfg.MaxStore,
+	} {
+		if v := get(key); v != "" {
+			n, err := agentNats.ParseSize(v)
+			if err != nil {
+				return NATSConfig{}, fmt.Errorf("%s: %w", key, err)
+			}
+			*dst = n
+		}
+	}
+	return cfg, nil
+}
+
+// image returns the image to run.
+func (c NATSConfig) image() string {
+	if c.Image != "" {
+		return c.Image
+	}
+	return NATSImage
+}
+
+// limitsLabel describes the JetStream limits for LabelNATSLimits.
+func (c NATSConfig) limitsLabel() string {
+	return fmt.Sprintf("memory=%d,store=%d", c.MaxMemory, c.MaxStore)
+}
+
+// serverConfig returns the nats-server configuration file that sets the
+// JetStream limits, or "" without limits.
+func (c NATSConfig) serverConfig() string {
+	if c.MaxMemory == 0 && c.MaxStore == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("jetstream {\n")
+	if c.MaxMemory > 0 {
+		fmt.Fprintf(&b, "  max_memory_store: %d\n", c.MaxMemory)
+	}
+	if c.MaxStore > 0 {
+		fmt.Fprintf(&b, "  max_file_store: %d\n", c.MaxStore)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// singleFileTar returns a tar archive holding one file.
+func singleFileTar(name, content string) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return nil, fmt.Errorf("writing tar header: %w", err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		return nil, fmt.Errorf("writing tar content: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing tar writer: %w", err)
+	}
+	return &buf, nil
+}
@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestNATSConfigFromEnv(t *testing.T) {
+	t.Setenv(SettingNATSImage, "nats:2.10.20-alpine")
+	t.Setenv(SettingJetStreamMaxStore, "1GB")
+
+	cfg, err := NATSConfigFromEnv(map[string]string{SettingJetStreamMaxMemory: "256M"})
+	if err != nil {
+		t.Fatalf("NATSConfigFromEnv: %v", err)
+	}
+	want := NATSConfig{Image: "nats:2.10.20-alpine", MaxMemory: 256 << 20, MaxStore: 1 << 30}
+	if cfg != want {
+		t.Errorf("config: got %+v, want %+v", cfg, want)
+	}
+
+	// Settings override the environment.
+	cfg, _ = NATSConfigFromEnv(map[string]string{SettingNATSImage: "registry.local/nats:2.10"})
+	if cfg.image() != "registry.local/nats:2.10" {
+		t.Errorf("image: got %q", cfg.image())
+	}
+
+	if _, err := NATSConfigFromEnv(map[string]string{SettingJetStreamMaxStore: "lots"}); err == nil ||
+		!strings.Contains(err.Error(), SettingJetStreamMaxStore) {
+		t.Errorf("invalid size: got %v, want an error naming the setting", err)
+	}
+
+	if (NATSConfig{}).image() != NATSImage || (NATSConfig{}).serverConfig() != "" {
+		t.Error("an empty config must run the default image without a config file")
+	}
+}
+
+// readyDeployments makes the fake clientset report every Deployment as
+// ready.
+func readyDeployments(clientset *fake.Clientset) {
+	clientset.PrependReactor("get", "deployments", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := clientset.Tracker().Get(appsv1.SchemeGroupVersion.WithResource("deployments"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		dep := obj.(*appsv1.Deployment).DeepCopy()
+		dep.Status.ReadyReplicas = 1
+		return true, dep, nil
+	})
+}
+
+func TestDeployNATS_ImageAndLimits(t *testing.T) {
+	for _, token := range []string{"", "secret"} {
+		t.Run("token="+token, func(t *testing.T) {
+			t.Setenv("NATS_AUTH_TOKEN", token)
+			k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+			readyDeployments(clientset)
+			ctx := t.Context()
+			ns := "agentcrew-chat"
+			cfg := NATSConfig{Image: "nats:2.10.20-alpine", MaxStore: 1 << 30}
+
+			if err := k.deployNATS(ctx, "chat", ns, cfg); err != nil {
+				t.Fatalf("deployNATS: %v", err)
+			}
+			dep, err := clientset.AppsV1().Deployments(ns).Get(ctx, natsDeploymentName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting deployment: %v", err)
+			}
+			c := dep.Spec.Template.Spec.Containers[0]
+			if c.Image != cfg.Image {
+				t.Errorf("image: got %q, want %q", c.Image, cfg.Image)
+			}
+			args := strings.Join(append(c.Command, c.Args...), " ")
+			if !strings.Contains(args, "--config "+natsConfigDir+"/"+natsConfigFile) {
+				t.Errorf("command %q does not load the config file", args)
+			}
+			if len(c.VolumeMounts) != 1 || c.VolumeMounts[0].MountPath != natsConfigDir {
+				t.Errorf("volume mounts: got %+v", c.VolumeMounts)
+			}
+
+			cm, err := clientset.CoreV1().ConfigMaps(ns).Get(ctx, natsConfigMapName(), metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("getting config map: %v", err)
+			}
+			if got := cm.Data[natsConfigFile]; got != "jetstream {\n  max_file_store: 1073741824\n}\n" {
+				t.Errorf("config: got %q", got)
+			}
+
+			// Stopping the team removes the config with the server.
+			if err := k.teardownKeepingWorkspace(ctx, "chat", ns); err != nil {
+				t.Fatalf("teardown: %v", err)
+			}
+			if _, err := clientset.CoreV1().ConfigMaps(ns).Get(ctx, natsConfigMapName(), metav1.GetOptions{}); err == nil {
+				t.Error("teardown kept the nats config map")
+			}
+		})
+	}
+}
+
+func TestDeployNATS_NoLimits(t *testing.T) {
+	t.Setenv("NATS_AUTH_TOKEN", "")
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	readyDeployments(clientset)
+	ctx := t.Context()
+
+	if err := k.deployNATS(ctx, "chat", "agentcrew-chat", NATSConfig{}); err != nil {
+		t.Fatalf("deployNATS: %v", err)
+	}
+	dep, _ := clientset.AppsV1().Deployments("agentcrew-chat").Get(ctx, natsDeploymentName(), metav1.GetOptions{})
+	c := dep.Spec.Template.Spec.Containers[0]
+	if c.Image != NATSImage || strings.Join(c.Args, " ") != "--jetstream" || len(dep.Spec.Template.Spec.Volumes) != 0 {
+		t.Errorf("container: got image %q, args %v, volumes %v", c.Image, c.Args, dep.Spec.Template.Spec.Volumes)
+	}
+}
@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Names of the runtimes Open creates, as set in RUNTIME and stored on teams.
+const (
+	NameDocker     = "docker"
+	NameKubernetes = "kubernetes"
+	NameChaos      = "chaos"
+)
+
+// Open creates the runtime named name, or the Docker runtime if name is
+// empty. The Docker runtime places teams on the hosts of DOCKER_HOSTS_FILE
+// when it is set, and the chaos runtime injects the faults of
+// CHAOS_SCENARIO.
+func Open(name string) (AgentRuntime, error) {
+	switch name {
+	case NameKubernetes:
+		slog.Info("initializing kubernetes runtime")
+		return NewK8sRuntime()
+	case NameChaos:
+		slog.Info("initializing chaos runtime", "scenario", os.Getenv("CHAOS_SCENARIO"))
+		return NewChaosRuntime(os.Getenv("CHAOS_SCENARIO"))
+	case "", NameDocker:
+		if path := os.Getenv("DOCKER_HOSTS_FILE"); path != "" {
+			slog.Info("initializing multi-host docker runtime", "hosts", path)
+			hosts, err := LoadDockerHosts(path)
+			if err != nil {
+				return nil, err
+			}
+			return NewMultiHostDockerRuntime(hosts)
+		}
+		slog.Info("initializing docker runtime")
+		return NewDockerRuntime()
+	default:
+		return nil, fmt.Errorf("unknown runtime %q: use docker, kubernetes or chaos", name)
+	}
+}
+
+// OpenAll creates the runtime named name and, unless extra is empty, the
+// runtimes it names too, in a MultiRuntime with name as the default.
+func OpenAll(name string, extra []string) (AgentRuntime, error) {
+	switch name {
+	case NameDocker, NameKubernetes, NameChaos:
+	default:
+		if name != "" {
+			slog.Warn("unknown runtime, using docker", "runtime", name)
+		}
+		name = NameDocker
+	}
+	rt, err := Open(name)
+	if err != nil || len(extra) == 0 {
+		return rt, err
+	}
+	runtimes := map[string]AgentRuntime{name: rt}
+	for _, e := range extra {
+		if runtimes[e] != nil {
+			continue
+		}
+		if runtimes[e], err = Open(e); err != nil {
+			return nil, fmt.Errorf("%s runtime: %w", e, err)
+		}
+	}
+	return NewMultiRuntime(name, runtimes)
+}
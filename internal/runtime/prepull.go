@@ -0,0 +1,123 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kubernetes resources that pull agent images on every node.
+const (
+	PrePullNamespace  = "agentcrew-system"
+	PrePullDaemonSet  = "agentcrew-image-prepull"
+	PrePullPauseImage = "registry.k8s.io/pause:3.10"
+	// prePullAnnotation stamps the DaemonSet's pods with the time of the
+	// last pre-pull, so that each pre-pull rolls them and :latest images
+	// are pulled again.
+	prePullAnnotation = "agentcrew.io/prepulled-at"
+)
+
+// PrePullImages pulls the images that are missing from the Docker host, and
+// the :latest ones again.
+func (d *DockerRuntime) PrePullImages(ctx context.Context, images []string) error {
+	var errs []error
+	for _, img := range images {
+		if err := d.pullImageIfNeeded(ctx, img); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// PrePullImages creates or updates a DaemonSet whose pods pull images on
+// every node, each in an init container that exits at once, and then idle in
+// a pause container. It returns once the DaemonSet is updated; the nodes
+// pull the images in the background.
+func (k *K8sRuntime) PrePullImages(ctx context.Context, images []string) error {
+	_, err := k.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   PrePullNamespace,
+			Labels: map[string]string{LabelInfra: "image-prepull"},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return fmt.Errorf("creating namespace %s: %w", PrePullNamespace, err)
+	}
+
+	ds := prePullDaemonSet(images, time.Now())
+	daemonSets := k.clientset.AppsV1().DaemonSets(PrePullNamespace)
+	existing, err := daemonSets.Get(ctx, PrePullDaemonSet, metav1.GetOptions{})
+	switch {
+	case k8serrors.IsNotFound(err):
+		if _, err := daemonSets.Create(ctx, ds, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("creating daemonset %s: %w", PrePullDaemonSet, err)
+		}
+		return nil
+	case err != nil:
+		return fmt.Errorf("getting daemonset %s: %w", PrePullDaemonSet, err)
+	}
+	existing.Spec.Template = ds.Spec.Template
+	if _, err := daemonSets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("updating daemonset %s: %w", PrePullDaemonSet, err)
+	}
+	return nil
+}
+
+// prePullDaemonSet returns the DaemonSet that pulls images on every node.
+func prePullDaemonSet(images []string, now time.Time) *appsv1.DaemonSet {
+	labels := map[string]string{LabelInfra: "image-prepull"}
+	small := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("16Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+	initContainers := make([]corev1.Container, 0, len(images))
+	for i, img := range images {
+		policy := corev1.PullIfNotPresent
+		if isLatestTag(img) {
+			policy = corev1.PullAlways
+		}
+		initContainers = append(initContainers, corev1.Container{
+			Name:            fmt.Sprintf("pull-%d", i),
+			Image:           img,
+			ImagePullPolicy: policy,
+			Command:         []string{"true"},
+			Resources:       small,
+		})
+	}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   PrePullDaemonSet,
+			Labels: labels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{prePullAnnotation: now.UTC().Format(time.RFC3339)},
+				},
+				Spec: corev1.PodSpec{
+					InitContainers: initContainers,
+					Containers: []corev1.Container{{
+						Name:      "pause",
+						Image:     PrePullPauseImage,
+						Resources: small,
+					}},
+				},
+			},
+		},
+	}
+}
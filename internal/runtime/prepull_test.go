@@ -0,0 +1,43 @@
+package runtime
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPrePullImages_K8s(t *testing.T) {
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+
+	if err := k.PrePullImages(ctx, []string{DefaultAgentImage, "registry.example.com/agent:1.2.3"}); err != nil {
+		t.Fatalf("PrePullImages: %v", err)
+	}
+	ds, err := clientset.AppsV1().DaemonSets(PrePullNamespace).Get(ctx, PrePullDaemonSet, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("getting daemonset: %v", err)
+	}
+	init := ds.Spec.Template.Spec.InitContainers
+	if len(init) != 2 {
+		t.Fatalf("init containers: got %d, want 2", len(init))
+	}
+	if init[0].Image != DefaultAgentImage || init[0].ImagePullPolicy != corev1.PullAlways {
+		t.Errorf("latest image: got %s %s, want it pulled always", init[0].Image, init[0].ImagePullPolicy)
+	}
+	if init[1].ImagePullPolicy != corev1.PullIfNotPresent {
+		t.Errorf("pinned image: got %s, want IfNotPresent", init[1].ImagePullPolicy)
+	}
+
+	// A second pre-pull updates the DaemonSet in place.
+	if err := k.PrePullImages(ctx, []string{"registry.example.com/agent:1.2.4"}); err != nil {
+		t.Fatalf("second PrePullImages: %v", err)
+	}
+	ds, _ = clientset.AppsV1().DaemonSets(PrePullNamespace).Get(ctx, PrePullDaemonSet, metav1.GetOptions{})
+	if init := ds.Spec.Template.Spec.InitContainers; len(init) != 1 || init[0].Image != "registry.example.com/agent:1.2.4" {
+		t.Errorf("init containers after update: got %+v", init)
+	}
+	if ds.Spec.Template.Annotations[prePullAnnotation] == "" {
+		t.Error("pod template has no pre-pull timestamp")
+	}
+}
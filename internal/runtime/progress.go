@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+)
+
+// Progress is a step of a long runtime operation, such as a deployment.
+type Progress struct {
+	// Message describes the step for humans.
+	Message string
+	// Pull is set on the steps of an image pull.
+	Pull *PullProgress
+}
+
+// ProgressFunc receives the steps of a long runtime operation.
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context whose runtime calls report their progress
+// to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress sends a step to the ProgressFunc of ctx, if it has one.
+func ReportProgress(ctx context.Context, format string, args ...any) {
+	reportProgress(ctx, Progress{Message: fmt.Sprintf(format, args...)})
+}
+
+func reportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(p)
+	}
+}
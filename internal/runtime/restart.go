@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Restart modes of a RestartPolicy.
+const (
+	RestartNever     = "never"
+	RestartOnFailure = "on-failure"
+	RestartAlways    = "always"
+)
+
+// Defaults of the NATS server's restart policy, which restarts it on
+// failure unless the team sets its own.
+const (
+	defaultNATSRestartMode       = RestartOnFailure
+	defaultNATSRestartMaxRetries = 5
+)
+
+// RestartPolicy is how the runtime restarts a team's containers when they
+// exit, so that transient crashes heal without an operator. The zero value
+// never restarts agents and restarts NATS on failure, up to 5 times.
+type RestartPolicy struct {
+	// Mode is RestartNever (default), RestartOnFailure or RestartAlways.
+	Mode string `json:"mode,omitempty"`
+	// MaxRetries caps the restarts in RestartOnFailure mode; 0 means no cap.
+	// Docker stops restarting on its own. On Kubernetes, the API stops the
+	// agent once its restarts pass the cap.
+	MaxRetries int `json:"max_retries,omitempty"`
+	// BackoffSeconds is how long a restarted agent waits before starting,
+	// doubled on each further restart up to protocol.MaxRestartBackoff.
+	// Zero leaves the runtime's own backoff.
+	BackoffSeconds int `json:"backoff_seconds,omitempty"`
+}
+
+// Validate checks the mode, and that retries and backoff are only set when
+// containers are restarted.
+func (p RestartPolicy) Validate() error {
+	switch p.Mode {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("mode %q must be %s, %s or %s", p.Mode, RestartNever, RestartOnFailure, RestartAlways)
+	}
+	if p.MaxRetries < 0 {
+		return fmt.Errorf("max_retries must not be negative")
+	}
+	if p.MaxRetries > 0 && p.Mode != RestartOnFailure {
+		return fmt.Errorf("max_retries requires mode %s", RestartOnFailure)
+	}
+	if p.BackoffSeconds < 0 || time.Duration(p.BackoffSeconds)*time.Second > protocol.MaxRestartBackoff {
+		return fmt.Errorf("backoff_seconds must be between 0 and %d", int(protocol.MaxRestartBackoff/time.Second))
+	}
+	if p.BackoffSeconds > 0 && !p.Restarts() {
+		return fmt.Errorf("backoff_seconds requires mode %s or %s", RestartOnFailure, RestartAlways)
+	}
+	return nil
+}
+
+// Restarts reports whether containers are restarted at all.
+func (p RestartPolicy) Restarts() bool {
+	return p.Mode == RestartOnFailure || p.Mode == RestartAlways
+}
+
+// RetriesExhausted reports whether a container restarted restartCount
+// times has gone past MaxRetries.
+func (p RestartPolicy) RetriesExhausted(restartCount int) bool {
+	return p.Mode == RestartOnFailure && p.MaxRetries > 0 && restartCount > p.MaxRetries
+}
+
+// backoffEnv returns the AGENT_RESTART_BACKOFF value passed to the sidecar,
+// or "" when it has no backoff to apply.
+func (p RestartPolicy) backoffEnv() string {
+	if !p.Restarts() || p.BackoffSeconds == 0 {
+		return ""
+	}
+	return strconv.Itoa(p.BackoffSeconds) + "s"
+}
+
+// dockerRestartPolicy converts p to a Docker restart policy.
+func (p RestartPolicy) dockerRestartPolicy() container.RestartPolicy {
+	switch p.Mode {
+	case RestartOnFailure:
+		return container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: p.MaxRetries}
+	case RestartAlways:
+		return container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}
+	}
+	return container.RestartPolicy{Name: container.RestartPolicyDisabled}
+}
+
+// natsRestartPolicy is p for the team's NATS server, which is restarted on
+// failure by default.
+func (p RestartPolicy) natsRestartPolicy() RestartPolicy {
+	if p.Mode == "" {
+		return RestartPolicy{Mode: defaultNATSRestartMode, MaxRetries: defaultNATSRestartMaxRetries}
+	}
+	return p
+}
+
+// podRestartPolicy converts p to the restart policy of an agent pod.
+func (p RestartPolicy) podRestartPolicy() corev1.RestartPolicy {
+	switch p.Mode {
+	case RestartOnFailure:
+		return corev1.RestartPolicyOnFailure
+	case RestartAlways:
+		return corev1.RestartPolicyAlways
+	}
+	return corev1.RestartPolicyNever
+}
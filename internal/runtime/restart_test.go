@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestRestartPolicy_Validate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		policy RestartPolicy
+		ok     bool
+	}{
+		{"zero", RestartPolicy{}, true},
+		{"never", RestartPolicy{Mode: RestartNever}, true},
+		{"on failure", RestartPolicy{Mode: RestartOnFailure, MaxRetries: 5, BackoffSeconds: 10}, true},
+		{"always", RestartPolicy{Mode: RestartAlways, BackoffSeconds: 30}, true},
+		{"unknown mode", RestartPolicy{Mode: "sometimes"}, false},
+		{"negative retries", RestartPolicy{Mode: RestartOnFailure, MaxRetries: -1}, false},
+		{"retries when always", RestartPolicy{Mode: RestartAlways, MaxRetries: 3}, false},
+		{"retries without restarts", RestartPolicy{MaxRetries: 3}, false},
+		{"backoff without restarts", RestartPolicy{BackoffSeconds: 10}, false},
+		{"backoff too long", RestartPolicy{Mode: RestartAlways, BackoffSeconds: 121}, false},
+	} {
+		if err := tc.policy.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestRestartPolicy_Docker(t *testing.T) {
+	for _, tc := range []struct {
+		policy RestartPolicy
+		want   container.RestartPolicy
+	}{
+		{RestartPolicy{}, container.RestartPolicy{Name: container.RestartPolicyDisabled}},
+		{RestartPolicy{Mode: RestartOnFailure, MaxRetries: 3}, container.RestartPolicy{Name: container.RestartPolicyOnFailure, MaximumRetryCount: 3}},
+		{RestartPolicy{Mode: RestartAlways}, container.RestartPolicy{Name: container.RestartPolicyUnlessStopped}},
+	} {
+		if got := tc.policy.dockerRestartPolicy(); got != tc.want {
+			t.Errorf("%+v: got %+v, want %+v", tc.policy, got, tc.want)
+		}
+	}
+
+	nats := RestartPolicy{}.natsRestartPolicy().dockerRestartPolicy()
+	if nats.Name != container.RestartPolicyOnFailure || nats.MaximumRetryCount != 5 {
+		t.Errorf("default nats restart policy = %+v, want on-failure up to 5", nats)
+	}
+}
+
+func TestRestartPolicy_RetriesExhausted(t *testing.T) {
+	p := RestartPolicy{Mode: RestartOnFailure, MaxRetries: 3}
+	if p.RetriesExhausted(3) || !p.RetriesExhausted(4) {
+		t.Errorf("RetriesExhausted(3), (4) = %v, %v, want false, true", p.RetriesExhausted(3), p.RetriesExhausted(4))
+	}
+	if (RestartPolicy{Mode: RestartOnFailure}).RetriesExhausted(100) {
+		t.Error("no cap: RetriesExhausted(100) = true")
+	}
+	if (RestartPolicy{Mode: RestartAlways}).RetriesExhausted(100) {
+		t.Error("always: RetriesExhausted(100) = true")
+	}
+}
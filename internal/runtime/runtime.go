@@ -3,13 +3,18 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+
 	"github.com/helmcode/agent-crew/internal/permissions"
+	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
 // AgentConfig holds the configuration needed to deploy a single agent container.
@@ -28,20 +33,161 @@ type AgentConfig struct {
 	AgentConfigYAML string            // serialized agent config to mount into the container
 	SubAgentFiles   map[string]string // filename → content for .claude/agents/*.md, passed via env var to sidecar
 	Env             map[string]string // extra environment variables (e.g. from Settings DB)
+	// ConfigDirMode controls where generated config dirs live: "inline" (default,
+	// inside the workspace), "gitignore" (inside, but git-ignored) or "separate"
+	// (on dedicated mounts so nothing is written into the workspace).
+	ConfigDirMode string
+	// ExtraWorkspaces are host directories mounted next to the workspace,
+	// at their MountPath.
+	ExtraWorkspaces []protocol.ExtraWorkspace
+	// RunAsUID and RunAsGID, when set, are the user the agent runs as on a
+	// bind-mounted workspace, so the files it writes are owned by that user
+	// on the host. When nil, entrypoint.sh uses the owner of the workspace
+	// directory. RunAsGID is ignored without RunAsUID.
+	RunAsUID *int
+	RunAsGID *int
+	// SkipWorkspaceChown stops entrypoint.sh from chowning a root-owned
+	// bind-mounted workspace to the agent user.
+	SkipWorkspaceChown bool
+	// PullImage pulls the image even when a copy is already present, to pick
+	// up a newer build of the same tag.
+	PullImage bool
+	// Restart is how the agent's container is restarted when it exits.
+	Restart RestartPolicy
 }
 
-// ResourceConfig defines compute resource limits for an agent.
+// ResourceConfig defines compute resource limits for an agent. CPU is a
+// number of cores ("0.5", "2") and Memory a size with a k/m/g suffix
+// ("512m", "2g"), as accepted by both runtimes.
 type ResourceConfig struct {
 	CPU     string `json:"cpu"`
 	Memory  string `json:"memory"`
 	Timeout int    `json:"timeout_seconds"`
 }
 
+// MemoryBytes converts a memory limit such as "512m" or "2g", or a
+// Kubernetes quantity such as "512Mi", to bytes. As in memoryQuantity, the
+// k/m/g form is read as a binary size. Returns 0 if the value is empty or
+// invalid.
+func MemoryBytes(mem string) int64 {
+	if bytes := parseMemoryLimit(mem); bytes > 0 {
+		return bytes
+	}
+	q, err := resource.ParseQuantity(mem)
+	if err != nil || q.Sign() <= 0 {
+		return 0
+	}
+	return q.Value()
+}
+
+// NanoCPUs converts a CPU limit such as "0.5" or "2", or a Kubernetes
+// quantity such as "500m", to billionths of a core. Returns 0 if the value
+// is empty or invalid.
+func NanoCPUs(cpu string) int64 {
+	if nanos := parseCPULimit(cpu); nanos > 0 {
+		return nanos
+	}
+	q, err := resource.ParseQuantity(cpu)
+	if err != nil || q.Sign() <= 0 {
+		return 0
+	}
+	return q.ScaledValue(resource.Nano)
+}
+
 // InfraConfig holds the configuration for shared team infrastructure.
 type InfraConfig struct {
-	TeamName      string
-	NATSEnabled   bool
+	TeamName string
+	// TeamID labels the infrastructure. When set, DeployInfra fails with a
+	// *SlugConflictError if the infrastructure for TeamName belongs to a
+	// different team.
+	TeamID      string
+	NATSEnabled bool
+	// NATS sets the image and JetStream limits of the team's NATS server.
+	NATS          NATSConfig
 	WorkspacePath string
+	// Namespace is the team's namespace metadata and quota, applied on top
+	// of the operator defaults. Kubernetes runtime only.
+	Namespace NamespaceConfig
+	// DockerHost is the host a previous deploy placed the team on, where its
+	// workspace volume is. HostSelector lists the labels a host needs to be
+	// chosen for a team not placed yet. Multi-host Docker runtime only.
+	DockerHost   string
+	HostSelector map[string]string
+	// Restart is how the NATS container is restarted when it exits, on
+	// failure up to 5 times when unset. Docker runtime only: on Kubernetes
+	// NATS runs in a Deployment, which always restarts it.
+	Restart RestartPolicy
+}
+
+// TeardownOptions controls what TeardownInfra removes.
+type TeardownOptions struct {
+	// PreserveWorkspace keeps the team's workspace volume (Docker) or
+	// namespace and workspace PVC (Kubernetes), so that the agents' work
+	// survives and is mounted again by the next deploy.
+	PreserveWorkspace bool
+}
+
+// SlugConflictError reports that a team's sanitized name is already used
+// by the infrastructure of another team. Container, network and namespace
+// names and NATS subjects are all derived from the slug, so the two teams
+// cannot run side by side.
+type SlugConflictError struct {
+	Slug        string
+	TeamID      string
+	OwnerTeamID string
+}
+
+func (e *SlugConflictError) Error() string {
+	return fmt.Sprintf("team name %q is already used by the infrastructure of team %s; rename one of the teams", e.Slug, e.OwnerTeamID)
+}
+
+// checkTeamOwner returns a *SlugConflictError if labels mark the
+// infrastructure of slug as owned by a team other than teamID. Resources
+// created before ownership labels existed are adopted.
+func checkTeamOwner(slug, teamID string, labels map[string]string) error {
+	owner := labels[LabelTeamID]
+	if teamID == "" || owner == "" || owner == teamID {
+		return nil
+	}
+	return &SlugConflictError{Slug: slug, TeamID: teamID, OwnerTeamID: owner}
+}
+
+// teamLabels returns the labels for a team's infrastructure resources.
+func teamLabels(config InfraConfig) map[string]string {
+	labels := map[string]string{LabelTeam: config.TeamName}
+	if config.TeamID != "" {
+		labels[LabelTeamID] = config.TeamID
+	}
+	return labels
+}
+
+// NamespaceConfig is per-team metadata for the team's Kubernetes namespace:
+// labels and annotations for chargeback (e.g. cost center, owner) and a
+// quota for blast-radius containment. Zero quota fields fall back to the
+// operator defaults.
+type NamespaceConfig struct {
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Quota       QuotaConfig       `json:"quota,omitempty"`
+}
+
+// Validate checks labels, annotations and quota values.
+func (c NamespaceConfig) Validate() error {
+	if err := ValidateNamespaceMetadata(c.Labels, c.Annotations); err != nil {
+		return err
+	}
+	if err := ValidateQuota(c.Quota); err != nil {
+		return fmt.Errorf("quota: %w", err)
+	}
+	return nil
+}
+
+// QuotaConfig bounds the total resources of a team namespace. CPU and
+// Memory use the same formats as ResourceConfig.
+type QuotaConfig struct {
+	CPU    string `json:"cpu,omitempty"`
+	Memory string `json:"memory,omitempty"`
+	Pods   int    `json:"pods,omitempty"`
 }
 
 // AgentInstance represents a deployed agent container.
@@ -55,8 +201,46 @@ type AgentInstance struct {
 type AgentStatus struct {
 	ID        string
 	Name      string
-	Status    string // running, stopped, error
+	Status    string // running, unhealthy, stopped, error
 	StartedAt time.Time
+	// RestartCount is how many times the runtime restarted the container.
+	RestartCount int
+}
+
+// Agent status values reported by GetStatus. StatusUnhealthy means the
+// container is running but its sidecar healthcheck is failing, or is
+// waiting to be restarted by its restart policy.
+const (
+	StatusRunning   = "running"
+	StatusUnhealthy = "unhealthy"
+	StatusStopped   = "stopped"
+	StatusError     = "error"
+)
+
+// ErrAgentNotFound is returned by GetStatus when the agent's container or
+// pod no longer exists.
+var ErrAgentNotFound = errors.New("agent container not found")
+
+// Sidecar admin endpoint used for container healthchecks. The runtimes pass
+// SidecarAdminListen to the sidecar so the probe and server always agree.
+const (
+	SidecarAdminListen = "127.0.0.1:9091"
+	sidecarHealthURL   = "http://" + SidecarAdminListen + "/healthz"
+)
+
+// Healthcheck timing shared by the Docker HEALTHCHECK and Kubernetes probes.
+// The start period covers skill installation and the initial Claude prompt.
+const (
+	healthcheckInterval    = 15 * time.Second
+	healthcheckTimeout     = 5 * time.Second
+	healthcheckRetries     = 3
+	healthcheckStartPeriod = 5 * time.Minute
+)
+
+// sidecarHealthCommand returns the command that probes the sidecar health
+// endpoint. curl ships in the agent images.
+func sidecarHealthCommand() []string {
+	return []string{"curl", "-fsS", "-o", "/dev/null", "--max-time", "4", sidecarHealthURL}
 }
 
 // Shared constants used by both Docker and Kubernetes runtimes.
@@ -67,6 +251,9 @@ const (
 	LabelTeam                 = "agentcrew.team"
 	LabelAgent                = "agentcrew.agent"
 	LabelRole                 = "agentcrew.role"
+	// LabelTeamID records which team owns infrastructure named after a team
+	// slug, so that a second team with the same slug is detected.
+	LabelTeamID = "agentcrew.team-id"
 )
 
 // AgentRuntime is the interface for managing agent container lifecycles.
@@ -77,7 +264,10 @@ type AgentRuntime interface {
 	RemoveAgent(ctx context.Context, id string) error
 	GetStatus(ctx context.Context, id string) (*AgentStatus, error)
 	StreamLogs(ctx context.Context, id string) (io.ReadCloser, error)
-	TeardownInfra(ctx context.Context, teamName string) error
+	TeardownInfra(ctx context.Context, teamName string, opts TeardownOptions) error
+	// DeleteWorkspace removes the team's workspace storage kept by a
+	// teardown with PreserveWorkspace. The team must be torn down first.
+	DeleteWorkspace(ctx context.Context, teamName string) error
 	GetNATSURL(teamName string) string
 	// GetNATSConnectURL returns a NATS URL reachable from the API server process
 	// (e.g. nats://127.0.0.1:<host-port> for Docker, in-cluster DNS for K8s).
@@ -150,6 +340,134 @@ type RagMcpManager interface {
 	IsRagMcpRunning(ctx context.Context) (bool, error)
 }
 
+// ImageDigester is an optional interface for runtimes that can report the
+// exact image an agent is running, so that an image upgrade can be rolled
+// back to it. Use a type assertion to check:
+//
+//	if id, ok := rt.(ImageDigester); ok { ... }
+type ImageDigester interface {
+	// ImageDigest returns the digest reference (repo@sha256:...) of the
+	// image the agent with the given ID runs, or "" if the image has no
+	// registry digest (e.g. it was built locally).
+	ImageDigest(ctx context.Context, id string) (string, error)
+}
+
+// ImagePrePuller is an optional interface for runtimes that can pull agent
+// images ahead of deployments, so that a deploy does not wait for the
+// download. Use a type assertion to check:
+//
+//	if pp, ok := rt.(ImagePrePuller); ok { ... }
+type ImagePrePuller interface {
+	// PrePullImages makes images available wherever agents may run. Images
+	// tagged :latest are pulled again to pick up new versions.
+	PrePullImages(ctx context.Context, images []string) error
+}
+
+// HostPlacer is an optional interface for runtimes that place each team on
+// one of several hosts. Use a type assertion to check:
+//
+//	if hp, ok := rt.(HostPlacer); ok { ... }
+type HostPlacer interface {
+	// TeamHost returns the host the team's infrastructure was deployed on,
+	// or "" if it is not known.
+	TeamHost(teamName string) string
+}
+
+// TeamInfra identifies the infrastructure of one team found in the runtime.
+type TeamInfra struct {
+	// Slug is the sanitized team name the resources are named after.
+	Slug string
+	// TeamID is the team that owns the resources, or "" if they were
+	// created before ownership labels existed.
+	TeamID string
+	// Resources are the team's containers, networks, volumes, namespaces
+	// and pods.
+	Resources []InfraResource
+}
+
+// Kinds of InfraResource.
+const (
+	ResourceContainer = "container"
+	ResourceNetwork   = "network"
+	ResourceVolume    = "volume"
+	ResourceNamespace = "namespace"
+	ResourcePod       = "pod"
+)
+
+// InfraResource is one runtime resource labeled with a team.
+type InfraResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// ID is the agent ID of containers and pods, as returned by
+	// DeployAgent.
+	ID string `json:"id,omitempty"`
+	// labels are the resource's labels, used to group it by team.
+	labels map[string]string
+}
+
+// HasActiveResources reports whether ti has containers, pods or networks.
+// A team torn down with PreserveWorkspace has only its workspace storage
+// left.
+func (ti TeamInfra) HasActiveResources() bool {
+	for _, r := range ti.Resources {
+		switch r.Kind {
+		case ResourceContainer, ResourcePod, ResourceNetwork:
+			return true
+		}
+	}
+	return false
+}
+
+// HasAgent reports whether ti includes the container or pod with the
+// given agent ID.
+func (ti TeamInfra) HasAgent(id string) bool {
+	for _, r := range ti.Resources {
+		if r.ID == id && (r.Kind == ResourceContainer || r.Kind == ResourcePod) {
+			return true
+		}
+	}
+	return false
+}
+
+// InfraLister is an optional interface for runtimes that can list the team
+// infrastructure they manage, so that resources left behind by interrupted
+// operations can be found. Use a type assertion to check:
+//
+//	if il, ok := rt.(InfraLister); ok { ... }
+type InfraLister interface {
+	ListTeamInfra(ctx context.Context) ([]TeamInfra, error)
+}
+
+// collectTeamInfra groups resources by their team label, sorted by slug.
+// Resources without a team label are ignored.
+func collectTeamInfra(resources []InfraResource) []TeamInfra {
+	bySlug := map[string]*TeamInfra{}
+	var slugs []string
+	for _, r := range resources {
+		slug := r.labels[LabelTeam]
+		if slug == "" {
+			continue
+		}
+		ti := bySlug[slug]
+		if ti == nil {
+			ti = &TeamInfra{Slug: slug}
+			bySlug[slug] = ti
+			slugs = append(slugs, slug)
+		}
+		if id := r.labels[LabelTeamID]; id != "" {
+			ti.TeamID = id
+		}
+		r.labels = nil
+		ti.Resources = append(ti.Resources, r)
+	}
+	sort.Strings(slugs)
+	infra := make([]TeamInfra, 0, len(slugs))
+	for _, slug := range slugs {
+		infra = append(infra, *bySlug[slug])
+	}
+	return infra
+}
+
 // ValidateAgentFilePath checks that the given path is safe for agent file
 // operations. It rejects path traversal attempts and only allows paths under
 // /workspace/.claude/ or /workspace/.opencode/. Specifically:
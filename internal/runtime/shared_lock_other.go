@@ -0,0 +1,21 @@
+//go:build !unix
+
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockSharedWorkspace only creates the shared workspace directory: file
+// locks are not supported on this platform, so concurrent deploys of teams
+// sharing a workspace are not coordinated across API processes.
+func lockSharedWorkspace(_ context.Context, workspacePath string) (func(), error) {
+	dir := filepath.Join(workspacePath, SharedWorkspaceDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+	return func() {}, nil
+}
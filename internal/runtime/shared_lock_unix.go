@@ -0,0 +1,51 @@
+//go:build unix
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// sharedLockPoll is how often lockSharedWorkspace retries a held lock.
+const sharedLockPoll = 100 * time.Millisecond
+
+// lockSharedWorkspace takes an exclusive flock on the lock file of the shared
+// workspace at workspacePath, waiting until it is free or ctx is done. The
+// returned function releases it.
+func lockSharedWorkspace(ctx context.Context, workspacePath string) (func(), error) {
+	dir := filepath.Join(workspacePath, SharedWorkspaceDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, sharedLockFile)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			f.Close()
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("waiting for lock on %s: %w", path, ctx.Err())
+		case <-time.After(sharedLockPoll):
+		}
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,146 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// SharedWorkspaceDir is the directory of a workspace shared by several teams
+// (config dir mode "shared") that holds one directory per team, named after
+// its slug. A team's directory holds its git worktree, which its agents
+// mount as /workspace, so each team writes its generated config, .mcp.json
+// and uploads into its own checkout.
+const SharedWorkspaceDir = ".agentcrew"
+
+// sharedGitDirMount is where the .git directory of a shared workspace is
+// mounted in agent containers. The .git file of a team worktree mounted at
+// /workspace refers to it by the relative path ../../../.git.
+const sharedGitDirMount = "/.git"
+
+// sharedLockFile is the file in SharedWorkspaceDir that serializes changes
+// to the worktrees of a shared workspace across teams and API processes.
+const sharedLockFile = ".lock"
+
+// sharedExcludeEntries are added to the repository's .git/info/exclude, which
+// all worktrees share, so team directories and generated config never show
+// up as untracked files.
+var sharedExcludeEntries = append([]string{SharedWorkspaceDir + "/"}, gitignoreEntries...)
+
+// SharedTeamDir returns the host directory of a team in a shared workspace.
+func SharedTeamDir(workspacePath, teamName string) string {
+	return filepath.Join(workspacePath, SharedWorkspaceDir, naming.Slug(teamName))
+}
+
+// SharedWorktreePath returns the host path of a team's git worktree in a
+// shared workspace.
+func SharedWorktreePath(workspacePath, teamName string) string {
+	return filepath.Join(SharedTeamDir(workspacePath, teamName), "worktree")
+}
+
+// SharedBranchName returns the branch a team's worktree checks out.
+func SharedBranchName(teamName string) string {
+	return "agentcrew/" + naming.Slug(teamName)
+}
+
+// PrepareSharedWorkspace creates the team's git worktree in the shared
+// workspace at workspacePath, on branch SharedBranchName, unless it exists,
+// and returns its host path. The workspace must be the root of a git
+// repository. Concurrent calls for the same workspace, from any process,
+// run one at a time.
+func PrepareSharedWorkspace(ctx context.Context, workspacePath, teamName string) (string, error) {
+	gitDir := filepath.Join(workspacePath, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("shared workspace %s must be the root of a git repository", workspacePath)
+	}
+
+	unlock, err := lockSharedWorkspace(ctx, workspacePath)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	if err := ensureIgnoreBlock(filepath.Join(gitDir, "info", "exclude"), sharedExcludeEntries); err != nil {
+		return "", err
+	}
+
+	worktree := SharedWorktreePath(workspacePath, teamName)
+	if _, err := os.Stat(filepath.Join(worktree, ".git")); os.IsNotExist(err) {
+		if err := addSharedWorktree(ctx, workspacePath, worktree, SharedBranchName(teamName)); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("checking worktree %s: %w", worktree, err)
+	}
+
+	if err := relativizeWorktreeGitFile(worktree); err != nil {
+		return "", err
+	}
+	return worktree, nil
+}
+
+// addSharedWorktree adds a locked worktree at path checking out branch,
+// which is created from HEAD if it does not exist yet. Locking keeps git in
+// agent containers, where the worktree has another path, from pruning it.
+func addSharedWorktree(ctx context.Context, workspacePath, path, branch string) error {
+	// Forget a worktree whose directory was deleted, so that its branch can
+	// be checked out again.
+	_, _ = runGit(ctx, workspacePath, "worktree", "unlock", path)
+	if _, err := runGit(ctx, workspacePath, "worktree", "prune"); err != nil {
+		return err
+	}
+
+	args := []string{"worktree", "add", "--lock", "--reason", "checked out by an agentcrew team"}
+	if _, err := runGit(ctx, workspacePath, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		args = append(args, path, branch)
+	} else {
+		args = append(args, "-b", branch, path)
+	}
+	_, err := runGit(ctx, workspacePath, args...)
+	return err
+}
+
+// relativizeWorktreeGitFile rewrites the absolute path in the .git file of
+// the worktree as a relative one, so that it resolves both on the host and
+// in agent containers, which mount the worktree at /workspace and the
+// repository's .git directory at sharedGitDirMount.
+func relativizeWorktreeGitFile(worktree string) error {
+	path := filepath.Join(worktree, ".git")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+	gitdir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+	if !ok {
+		return fmt.Errorf("%s is not a worktree .git file", path)
+	}
+	if !filepath.IsAbs(gitdir) {
+		return nil
+	}
+	rel, err := filepath.Rel(worktree, gitdir)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", gitdir, err)
+	}
+	if err := os.WriteFile(path, []byte("gitdir: "+filepath.ToSlash(rel)+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
+// runGit runs git in dir and returns its trimmed output.
+func runGit(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
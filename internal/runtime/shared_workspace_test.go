@@ -0,0 +1,120 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// initGitRepo creates a git repository with one commit in a temp dir.
+func initGitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "-q", "-b", "main"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	return dir
+}
+
+func TestPrepareSharedWorkspace(t *testing.T) {
+	repo := initGitRepo(t)
+	ctx := t.Context()
+
+	billing, err := PrepareSharedWorkspace(ctx, repo, "Billing")
+	if err != nil {
+		t.Fatalf("PrepareSharedWorkspace: %v", err)
+	}
+	if billing != filepath.Join(repo, ".agentcrew", "billing", "worktree") {
+		t.Errorf("worktree = %q", billing)
+	}
+	alpha, err := PrepareSharedWorkspace(ctx, repo, "Alpha")
+	if err != nil {
+		t.Fatalf("PrepareSharedWorkspace for a second team: %v", err)
+	}
+
+	for worktree, branch := range map[string]string{billing: "agentcrew/billing", alpha: "agentcrew/alpha"} {
+		got, err := runGit(ctx, worktree, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			t.Fatalf("git in worktree: %v", err)
+		}
+		if got != branch {
+			t.Errorf("%s checks out %q, want %q", worktree, got, branch)
+		}
+		data, _ := os.ReadFile(filepath.Join(worktree, ".git"))
+		if !strings.HasPrefix(string(data), "gitdir: ../../../.git/worktrees/") {
+			t.Errorf("%s/.git = %q, want a relative gitdir", worktree, data)
+		}
+	}
+
+	exclude, _ := os.ReadFile(filepath.Join(repo, ".git", "info", "exclude"))
+	if !strings.Contains(string(exclude), ".agentcrew/\n") {
+		t.Errorf("exclude = %q, want .agentcrew/ ignored", exclude)
+	}
+	if status, _ := runGit(ctx, repo, "status", "--porcelain"); status != "" {
+		t.Errorf("workspace has untracked files: %q", status)
+	}
+
+	// Preparing again keeps the worktree and its files.
+	if err := os.WriteFile(filepath.Join(billing, "notes.txt"), []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PrepareSharedWorkspace(ctx, repo, "Billing"); err != nil {
+		t.Fatalf("PrepareSharedWorkspace again: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(billing, "notes.txt")); err != nil {
+		t.Errorf("work lost on redeploy: %v", err)
+	}
+
+	// A deleted worktree is recreated on the team's existing branch.
+	if err := os.RemoveAll(billing); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PrepareSharedWorkspace(ctx, repo, "Billing"); err != nil {
+		t.Fatalf("PrepareSharedWorkspace after deleting the worktree: %v", err)
+	}
+	if got, _ := runGit(ctx, billing, "rev-parse", "--abbrev-ref", "HEAD"); got != "agentcrew/billing" {
+		t.Errorf("recreated worktree checks out %q", got)
+	}
+}
+
+func TestPrepareSharedWorkspace_NotGitRepo(t *testing.T) {
+	if _, err := PrepareSharedWorkspace(t.Context(), t.TempDir(), "billing"); err == nil {
+		t.Fatal("expected an error for a workspace that is not a git repository")
+	}
+}
+
+func TestLockSharedWorkspace(t *testing.T) {
+	dir := t.TempDir()
+
+	unlock, err := lockSharedWorkspace(t.Context(), dir)
+	if err != nil {
+		t.Fatalf("lockSharedWorkspace: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := lockSharedWorkspace(ctx, dir); err == nil {
+		t.Fatal("expected the second lock to wait until the context expired")
+	}
+
+	unlock()
+	ctx, cancel = context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	unlock, err = lockSharedWorkspace(ctx, dir)
+	if err != nil {
+		t.Fatalf("lockSharedWorkspace after release: %v", err)
+	}
+	unlock()
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
 )
 
@@ -20,6 +21,12 @@ type SubAgentInfo struct {
 	Skills       json.RawMessage
 	GlobalSkills json.RawMessage // Leader skills shared across all agents.
 	ClaudeMD     string          // Legacy body content; if Instructions is set, it takes priority.
+
+	// Frontmatter flags; nil or empty values keep the defaults of an
+	// isolated background run with all permissions bypassed.
+	Background     *bool  // background: true unless set to false.
+	Isolation      string // "worktree" (default) or "none" to omit isolation.
+	PermissionMode string // Defaults to bypassPermissions.
 }
 
 // TeamMemberInfo describes a teammate for inclusion in the leader's CLAUDE.md.
@@ -65,10 +72,68 @@ func SetupAgentWorkspace(workspacePath string, agent AgentWorkspaceInfo) (string
 	return claudeDir, nil
 }
 
+// GeneratedConfigDirs lists the workspace-relative directories that AgentCrew
+// writes agent configuration into (instructions, sub-agents, skills).
+var GeneratedConfigDirs = []string{".claude", ".opencode", ".agents"}
+
+// gitignoreEntries are the paths added to a workspace .gitignore when the team
+// uses the "gitignore" config dir mode.
+var gitignoreEntries = []string{".claude/", ".opencode/", ".agents/", ".mcp.json", "uploads/"}
+
+const (
+	gitignoreBlockStart = "# BEGIN agentcrew (managed, do not edit)"
+	gitignoreBlockEnd   = "# END agentcrew"
+)
+
+// EnsureWorkspaceGitignore adds (or refreshes) a managed block in
+// {workspacePath}/.gitignore that ignores the files AgentCrew generates, so
+// they are never committed to a user repository mounted as the workspace.
+// Content outside the managed block is preserved.
+func EnsureWorkspaceGitignore(workspacePath string) error {
+	return ensureIgnoreBlock(filepath.Join(workspacePath, ".gitignore"), gitignoreEntries)
+}
+
+// ensureIgnoreBlock adds (or refreshes) the managed block listing entries in
+// the ignore file at path, preserving content outside the block.
+func ensureIgnoreBlock(path string, entries []string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	block := gitignoreBlockStart + "\n" + strings.Join(entries, "\n") + "\n" + gitignoreBlockEnd + "\n"
+
+	content := string(existing)
+	if start := strings.Index(content, gitignoreBlockStart); start >= 0 {
+		end := strings.Index(content[start:], gitignoreBlockEnd)
+		if end < 0 {
+			return fmt.Errorf("%s has an unterminated agentcrew block", path)
+		}
+		end += start + len(gitignoreBlockEnd)
+		if end < len(content) && content[end] == '\n' {
+			end++
+		}
+		content = content[:start] + block + content[end:]
+	} else {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += block
+	}
+
+	if content == string(existing) {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return nil
+}
+
 // AgentClaudeDir returns the host path for an agent's .claude directory
 // without creating it. Used by runtimes to compute mount paths.
 func AgentClaudeDir(workspacePath, agentName string) string {
-	return filepath.Join(workspacePath, ".claude", sanitizeName(agentName))
+	return filepath.Join(workspacePath, ".claude", naming.Slug(agentName))
 }
 
 // GenerateClaudeMD produces the CLAUDE.md content for an agent.
@@ -129,7 +194,7 @@ func GenerateClaudeMD(agent AgentWorkspaceInfo) string {
 // SubAgentFileName returns the sanitized filename (without path) for a sub-agent,
 // e.g. "my-agent.md". Use this to compute the key for SubAgentFiles in AgentConfig.
 func SubAgentFileName(name string) string {
-	return sanitizeName(name) + ".md"
+	return naming.Slug(name) + ".md"
 }
 
 // SetupSubAgentFile creates a sub-agent definition file at
@@ -141,7 +206,7 @@ func SetupSubAgentFile(workspacePath string, agent SubAgentInfo) (string, error)
 		return "", fmt.Errorf("creating agents dir %s: %w", agentsDir, err)
 	}
 
-	safeName := sanitizeName(agent.Name)
+	safeName := naming.Slug(agent.Name)
 	filePath := filepath.Join(agentsDir, safeName+".md")
 	content := GenerateSubAgentContent(agent)
 
@@ -153,8 +218,8 @@ func SetupSubAgentFile(workspacePath string, agent SubAgentInfo) (string, error)
 }
 
 // GenerateSubAgentContent produces the YAML frontmatter + body content for a
-// sub-agent file. background, isolation, and permissionMode are always emitted
-// with fixed values so sub-agents run isolated and with full permissions.
+// sub-agent file. Unless the agent overrides them, background, isolation and
+// permissionMode are set so sub-agents run isolated and with full permissions.
 func GenerateSubAgentContent(agent SubAgentInfo) string {
 	var b strings.Builder
 
@@ -168,10 +233,21 @@ func GenerateSubAgentContent(agent SubAgentInfo) string {
 		b.WriteString("model: " + agent.Model + "\n")
 	}
 
-	// Always set these fields for isolated, unrestricted execution.
-	b.WriteString("background: true\n")
-	b.WriteString("isolation: worktree\n")
-	b.WriteString("permissionMode: bypassPermissions\n")
+	if agent.Background == nil || *agent.Background {
+		b.WriteString("background: true\n")
+	}
+	isolation := agent.Isolation
+	if isolation == "" {
+		isolation = "worktree"
+	}
+	if isolation != "none" {
+		b.WriteString("isolation: " + isolation + "\n")
+	}
+	permissionMode := agent.PermissionMode
+	if permissionMode == "" {
+		permissionMode = "bypassPermissions"
+	}
+	b.WriteString("permissionMode: " + permissionMode + "\n")
 
 	// Emit skills list if provided, merging the agent's own skills with global
 	// leader skills so every worker has access to all shared capabilities.
@@ -552,7 +628,7 @@ func SetupOpenCodeWorkspace(workspacePath, teamName string, leader SubAgentInfo,
 
 	// Write per-worker agent files.
 	for _, w := range workers {
-		safeName := sanitizeName(w.Name)
+		safeName := naming.Slug(w.Name)
 		filePath := filepath.Join(agentsDir, safeName+".md")
 		content := GenerateOpenCodeSubAgentContent(w, globalSkills)
 		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
@@ -0,0 +1,260 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/helmcode/agent-crew/internal/naming"
+)
+
+// Directions of a workspace sync.
+const (
+	// WorkspacePush copies the workspace to object storage.
+	WorkspacePush = "push"
+	// WorkspacePull copies object storage into the workspace.
+	WorkspacePull = "pull"
+)
+
+// DefaultWorkspaceSyncImage runs workspace syncs, unless
+// WORKSPACE_SYNC_IMAGE names another image with the aws CLI and sh.
+const DefaultWorkspaceSyncImage = "amazon/aws-cli:2.17.0"
+
+// workspaceSyncName names the Job and Secret of a workspace sync in the
+// team namespace. A team runs one sync at a time.
+const workspaceSyncName = "workspace-sync"
+
+// WorkspaceSyncEnv lists the settings passed to a workspace sync, which
+// hold the object storage credentials and endpoint.
+var WorkspaceSyncEnv = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+	"AWS_ENDPOINT_URL",
+}
+
+// jobPollInterval is how often waitForJob checks a Job.
+var jobPollInterval = 2 * time.Second
+
+// s3URLPattern matches the s3://bucket/prefix URLs a workspace syncs with.
+var s3URLPattern = regexp.MustCompile(`^s3://[a-z0-9][a-z0-9.-]{1,61}[a-z0-9](/[A-Za-z0-9!_.*'()/+=@-]*)?$`)
+
+// WorkspaceSync describes a copy between a team's workspace and an S3
+// prefix.
+type WorkspaceSync struct {
+	// Direction is WorkspacePush or WorkspacePull.
+	Direction string
+	// URL is the S3 prefix, as s3://bucket/prefix.
+	URL string
+	// Delete removes files of the destination that the source lacks.
+	Delete bool
+	// Env holds the credentials, see WorkspaceSyncEnv.
+	Env map[string]string
+}
+
+// ValidateWorkspaceSync checks the direction and URL of a sync.
+func ValidateWorkspaceSync(sync WorkspaceSync) error {
+	if sync.Direction != WorkspacePush && sync.Direction != WorkspacePull {
+		return fmt.Errorf("invalid direction %q: use push or pull", sync.Direction)
+	}
+	if !s3URLPattern.MatchString(sync.URL) {
+		return fmt.Errorf("invalid url %q: use s3://bucket/prefix", sync.URL)
+	}
+	return nil
+}
+
+// WorkspaceSyncer is an optional interface for runtimes that can copy a
+// team's workspace to and from object storage. Use a type assertion to
+// check.
+type WorkspaceSyncer interface {
+	// SyncWorkspace copies files between the team's workspace and
+	// object storage, and returns the end of the copy's output.
+	SyncWorkspace(ctx context.Context, teamName string, sync WorkspaceSync) (string, error)
+}
+
+// SyncWorkspace runs `aws s3 sync` in a Job that mounts the team's
+// workspace PVC, and waits for it. While the team runs, the Job runs on
+// its agents' node, where the ReadWriteOnce PVC is attached. Pulled files
+// are owned by the workspace owner.
+func (k *K8sRuntime) SyncWorkspace(ctx context.Context, teamName string, sync WorkspaceSync) (string, error) {
+	if err := ValidateWorkspaceSync(sync); err != nil {
+		return "", err
+	}
+	ns := teamNamespaceName(naming.Slug(teamName))
+	if _, err := k.clientset.CoreV1().PersistentVolumeClaims(ns).Get(ctx, workspacePVCName(), metav1.GetOptions{}); err != nil {
+		if k8serrors.IsNotFound(err) {
+			return "", fmt.Errorf("team %s has no workspace; deploy it first", teamName)
+		}
+		return "", fmt.Errorf("getting workspace PVC: %w", err)
+	}
+
+	// The Job and Secret of a sync that was interrupted are replaced.
+	k.deleteWorkspaceSync(ctx, ns)
+	defer k.deleteWorkspaceSync(context.WithoutCancel(ctx), ns)
+
+	secret := map[string]string{}
+	for _, key := range WorkspaceSyncEnv {
+		if v := sync.Env[key]; v != "" {
+			secret[key] = v
+		}
+	}
+	_, err := k.clientset.CoreV1().Secrets(ns).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   workspaceSyncName,
+			Labels: map[string]string{LabelRole: workspaceSyncName},
+		},
+		StringData: secret,
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("creating secret %s: %w", workspaceSyncName, err)
+	}
+
+	nodeName, err := k.workspaceNode(ctx, ns)
+	if err != nil {
+		return "", err
+	}
+	image := os.Getenv("WORKSPACE_SYNC_IMAGE")
+	if image == "" {
+		image = DefaultWorkspaceSyncImage
+	}
+	if _, err := k.clientset.BatchV1().Jobs(ns).Create(ctx, workspaceSyncJob(sync, image, nodeName), metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("creating job %s: %w", workspaceSyncName, err)
+	}
+
+	succeeded, err := k.waitForJob(ctx, ns, workspaceSyncName)
+	if err != nil {
+		return "", err
+	}
+	output := k.jobLogs(ctx, ns, workspaceSyncName)
+	if !succeeded {
+		return output, fmt.Errorf("workspace %s failed: %s", sync.Direction, output)
+	}
+	return output, nil
+}
+
+// workspaceSyncJob returns the Job that runs sync with image, on nodeName
+// if it is set.
+func workspaceSyncJob(sync WorkspaceSync, image, nodeName string) *batchv1.Job {
+	flags := "--no-progress"
+	if sync.Delete {
+		flags += " --delete"
+	}
+	script := `aws s3 sync /workspace "$SYNC_URL" ` + flags
+	if sync.Direction == WorkspacePull {
+		script = `aws s3 sync "$SYNC_URL" /workspace ` + flags + ` && chown -R "$(stat -c '%u:%g' /workspace)" /workspace`
+	}
+	labels := map[string]string{LabelRole: workspaceSyncName}
+	backoffLimit := int32(0)
+	ttl := int32(600)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   workspaceSyncName,
+			Labels: labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					NodeName:      nodeName,
+					Containers: []corev1.Container{{
+						Name:    "sync",
+						Image:   image,
+						Command: []string{"sh", "-c", script},
+						Env:     []corev1.EnvVar{{Name: "SYNC_URL", Value: sync.URL}},
+						EnvFrom: []corev1.EnvFromSource{{
+							SecretRef: &corev1.SecretEnvSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: workspaceSyncName},
+							},
+						}},
+						VolumeMounts: []corev1.VolumeMount{{Name: "workspace", MountPath: "/workspace"}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "workspace",
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+								ClaimName: workspacePVCName(),
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// workspaceNode returns the node of a running agent pod of the namespace,
+// or "" if none runs.
+func (k *K8sRuntime) workspaceNode(ctx context.Context, ns string) (string, error) {
+	pods, err := k.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: LabelAgent})
+	if err != nil {
+		return "", fmt.Errorf("listing agent pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Spec.NodeName != "" {
+			return pod.Spec.NodeName, nil
+		}
+	}
+	return "", nil
+}
+
+// waitForJob waits until the Job finishes and reports whether it
+// succeeded.
+func (k *K8sRuntime) waitForJob(ctx context.Context, ns, name string) (bool, error) {
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := k.clientset.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, fmt.Errorf("getting job %s: %w", name, err)
+		}
+		switch {
+		case job.Status.Succeeded > 0:
+			return true, nil
+		case job.Status.Failed > 0:
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("waiting for job %s: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// jobLogs returns the last lines of the output of the Job's pod.
+func (k *K8sRuntime) jobLogs(ctx context.Context, ns, name string) string {
+	pods, err := k.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + name})
+	if err != nil || len(pods.Items) == 0 {
+		return ""
+	}
+	tail := int64(20)
+	stream, err := k.clientset.CoreV1().Pods(ns).GetLogs(pods.Items[0].Name, &corev1.PodLogOptions{TailLines: &tail}).Stream(ctx)
+	if err != nil {
+		return ""
+	}
+	defer stream.Close()
+	out, _ := io.ReadAll(io.LimitReader(stream, 8<<10))
+	return strings.TrimSpace(string(out))
+}
+
+// deleteWorkspaceSync deletes the Job, with its pod, and the Secret of a
+// workspace sync.
+func (k *K8sRuntime) deleteWorkspaceSync(ctx context.Context, ns string) {
+	background := metav1.DeletePropagationBackground
+	_ = k.clientset.BatchV1().Jobs(ns).Delete(ctx, workspaceSyncName, metav1.DeleteOptions{PropagationPolicy: &background})
+	_ = k.clientset.CoreV1().Secrets(ns).Delete(ctx, workspaceSyncName, metav1.DeleteOptions{})
+}
@@ -0,0 +1,102 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateWorkspaceSync(t *testing.T) {
+	tests := []struct {
+		sync WorkspaceSync
+		ok   bool
+	}{
+		{WorkspaceSync{Direction: WorkspacePush, URL: "s3://my-bucket/teams/alpha"}, true},
+		{WorkspaceSync{Direction: WorkspacePull, URL: "s3://my-bucket"}, true},
+		{WorkspaceSync{Direction: "copy", URL: "s3://my-bucket"}, false},
+		{WorkspaceSync{Direction: WorkspacePush, URL: "https://my-bucket/x"}, false},
+		{WorkspaceSync{Direction: WorkspacePush, URL: "s3://my-bucket/x; rm -rf /"}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateWorkspaceSync(tt.sync); (err == nil) != tt.ok {
+			t.Errorf("%+v: got %v, want ok %v", tt.sync, err, tt.ok)
+		}
+	}
+}
+
+func TestSyncWorkspace_K8s(t *testing.T) {
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = 2 * time.Second })
+
+	k, clientset := newFakeK8sRuntime(namespaceDefaults{})
+	ctx := t.Context()
+	sync := WorkspaceSync{
+		Direction: WorkspacePull,
+		URL:       "s3://seed-bucket/alpha",
+		Env:       map[string]string{"AWS_ACCESS_KEY_ID": "AKIA", "ANTHROPIC_API_KEY": "sk-secret"},
+	}
+
+	if _, err := k.SyncWorkspace(ctx, "Alpha", sync); err == nil || !strings.Contains(err.Error(), "deploy it first") {
+		t.Fatalf("no workspace: got %v", err)
+	}
+
+	ns := teamNamespaceName("alpha")
+	clientset.CoreV1().PersistentVolumeClaims(ns).Create(ctx, &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: workspacePVCName()},
+	}, metav1.CreateOptions{})
+	clientset.CoreV1().Pods(ns).Create(ctx, &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "agent-leader", Labels: map[string]string{LabelAgent: "leader"}},
+		Spec:       corev1.PodSpec{NodeName: "node-2"},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	}, metav1.CreateOptions{})
+
+	jobs := make(chan *batchv1.Job, 1)
+	secrets := make(chan *corev1.Secret, 1)
+	go func() {
+		for ctx.Err() == nil {
+			job, err := clientset.BatchV1().Jobs(ns).Get(ctx, workspaceSyncName, metav1.GetOptions{})
+			if err != nil {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			secret, _ := clientset.CoreV1().Secrets(ns).Get(ctx, workspaceSyncName, metav1.GetOptions{})
+			secrets <- secret
+			jobs <- job.DeepCopy()
+			job.Status.Succeeded = 1
+			clientset.BatchV1().Jobs(ns).UpdateStatus(ctx, job, metav1.UpdateOptions{})
+			return
+		}
+	}()
+
+	if _, err := k.SyncWorkspace(ctx, "Alpha", sync); err != nil {
+		t.Fatalf("SyncWorkspace: %v", err)
+	}
+	job := <-jobs
+	spec := job.Spec.Template.Spec
+	if spec.NodeName != "node-2" {
+		t.Errorf("node: got %q, want the agent's node-2", spec.NodeName)
+	}
+	script := spec.Containers[0].Command[2]
+	if !strings.HasPrefix(script, `aws s3 sync "$SYNC_URL" /workspace`) || !strings.Contains(script, "chown -R") {
+		t.Errorf("pull script: got %q", script)
+	}
+	if env := spec.Containers[0].Env; len(env) != 1 || env[0].Value != sync.URL {
+		t.Errorf("env: got %+v", env)
+	}
+	secret := <-secrets
+	if secret.StringData["AWS_ACCESS_KEY_ID"] != "AKIA" || secret.StringData["ANTHROPIC_API_KEY"] != "" {
+		t.Errorf("secret: got %v, want only the AWS settings", secret.StringData)
+	}
+
+	// The Job and Secret are removed once the sync is done.
+	if _, err := clientset.BatchV1().Jobs(ns).Get(ctx, workspaceSyncName, metav1.GetOptions{}); err == nil {
+		t.Error("job was not deleted")
+	}
+	if _, err := clientset.CoreV1().Secrets(ns).Get(ctx, workspaceSyncName, metav1.GetOptions{}); err == nil {
+		t.Error("secret was not deleted")
+	}
+}
@@ -0,0 +1,232 @@
+package runtime
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Workspace templates a team can select. The empty template scaffolds
+// nothing, like no template at all.
+const (
+	WorkspaceTemplateEmpty    = "empty"
+	WorkspaceTemplateGitRepo  = "git-repo"
+	WorkspaceTemplateMonorepo = "monorepo"
+	WorkspaceTemplateDocs     = "docs"
+)
+
+// WorkspaceTemplate is a starting structure for a team's workspace. The
+// contents of its files are text/template templates rendered with a
+// WorkspaceTemplateData.
+type WorkspaceTemplate struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Dirs        []string          `json:"dirs"`
+	Files       map[string]string `json:"-"`
+	// Paths lists the files created, sorted.
+	Paths   []string `json:"files"`
+	GitInit bool     `json:"git_init"`
+}
+
+// WorkspaceTemplateData is what workspace templates are rendered with.
+type WorkspaceTemplateData struct {
+	Team        string
+	Description string
+	Leader      string
+	Agents      []TeamMemberInfo
+}
+
+const readmeTemplate = `# {{.Team}}
+{{if .Description}}
+{{.Description}}
+{{end}}
+This workspace is maintained by the {{.Team}} crew{{if .Leader}}, led by {{.Leader}}{{end}}.
+
+## Agents
+{{range .Agents}}
+- **{{.Name}}** ({{.Role}}){{if .Specialty}}: {{.Specialty}}{{end}}
+{{- end}}
+`
+
+const gitignoreTemplate = `# Dependencies and build output
+node_modules/
+vendor/
+dist/
+build/
+.venv/
+__pycache__/
+
+# Environment
+.env
+.env.*
+
+# Editors and OS files
+.idea/
+.vscode/
+.DS_Store
+`
+
+var workspaceTemplates = []WorkspaceTemplate{
+	{
+		Name:        WorkspaceTemplateEmpty,
+		Description: "An empty workspace.",
+	},
+	{
+		Name:        WorkspaceTemplateGitRepo,
+		Description: "A git repository with a README, a .gitignore and a Makefile.",
+		GitInit:     true,
+		Files: map[string]string{
+			"README.md":  readmeTemplate,
+			".gitignore": gitignoreTemplate,
+			"Makefile": `.PHONY: help build test lint
+
+help: ## List the targets
+	@grep -E '^[a-z-]+:.*## ' $(MAKEFILE_LIST) | awk -F ':.*## ' '{printf "%-10s %s\n", $$1, $$2}'
+
+build: ## Build {{.Team}}
+	@echo "nothing to build yet"
+
+test: ## Run the tests
+	@echo "no tests yet"
+
+lint: ## Run the linters
+	@echo "no linters yet"
+`,
+		},
+	},
+	{
+		Name:        WorkspaceTemplateMonorepo,
+		Description: "A git monorepo with apps, packages, tools and docs directories.",
+		GitInit:     true,
+		Dirs:        []string{"apps", "packages", "tools", "docs"},
+		Files: map[string]string{
+			"README.md": readmeTemplate + `
+## Layout
+
+- ` + "`apps/`" + `: deployable applications, one directory each
+- ` + "`packages/`" + `: libraries shared by the applications
+- ` + "`tools/`" + `: scripts and developer tooling
+- ` + "`docs/`" + `: documentation
+`,
+			".gitignore": gitignoreTemplate,
+			"Makefile": `APPS := $(wildcard apps/*)
+
+.PHONY: help build test $(APPS)
+
+help: ## List the targets
+	@grep -E '^[a-z-]+:.*## ' $(MAKEFILE_LIST) | awk -F ':.*## ' '{printf "%-10s %s\n", $$1, $$2}'
+
+build: $(APPS) ## Build every app of {{.Team}}
+
+$(APPS):
+	@if [ -f $@/Makefile ]; then $(MAKE) -C $@; fi
+
+test: ## Run the tests of every app
+	@for app in $(APPS); do if [ -f $$app/Makefile ]; then $(MAKE) -C $$app test; fi; done
+`,
+			"docs/README.md": "# {{.Team}} documentation\n",
+		},
+	},
+	{
+		Name:        WorkspaceTemplateDocs,
+		Description: "A git repository for a MkDocs documentation site.",
+		GitInit:     true,
+		Dirs:        []string{"docs/guides"},
+		Files: map[string]string{
+			"README.md":  readmeTemplate,
+			".gitignore": gitignoreTemplate + "\n# MkDocs build output\nsite/\n",
+			"mkdocs.yml": `site_name: {{.Team}}
+{{- if .Description}}
+site_description: {{printf "%q" .Description}}
+{{- end}}
+nav:
+  - Home: index.md
+`,
+			"docs/index.md": "# {{.Team}}\n{{if .Description}}\n{{.Description}}\n{{end}}",
+			"Makefile": `.PHONY: help serve build
+
+help: ## List the targets
+	@grep -E '^[a-z-]+:.*## ' $(MAKEFILE_LIST) | awk -F ':.*## ' '{printf "%-10s %s\n", $$1, $$2}'
+
+serve: ## Serve the docs on http://localhost:8000
+	mkdocs serve
+
+build: ## Build the site into site/
+	mkdocs build --strict
+`,
+		},
+	},
+}
+
+func init() {
+	for i := range workspaceTemplates {
+		t := &workspaceTemplates[i]
+		t.Paths = make([]string, 0, len(t.Files))
+		for p := range t.Files {
+			t.Paths = append(t.Paths, p)
+		}
+		slices.Sort(t.Paths)
+		if t.Dirs == nil {
+			t.Dirs = []string{}
+		}
+	}
+}
+
+// WorkspaceTemplates returns the workspace templates teams can select.
+func WorkspaceTemplates() []WorkspaceTemplate {
+	return workspaceTemplates
+}
+
+// ValidateWorkspaceTemplate checks that name is a known workspace template.
+// An empty name, meaning none, is valid.
+func ValidateWorkspaceTemplate(name string) error {
+	if name == "" || findWorkspaceTemplate(name) != nil {
+		return nil
+	}
+	names := make([]string, len(workspaceTemplates))
+	for i, t := range workspaceTemplates {
+		names[i] = t.Name
+	}
+	return fmt.Errorf("invalid workspace_template %q: must be one of %s", name, strings.Join(names, ", "))
+}
+
+func findWorkspaceTemplate(name string) *WorkspaceTemplate {
+	for i := range workspaceTemplates {
+		if workspaceTemplates[i].Name == name {
+			return &workspaceTemplates[i]
+		}
+	}
+	return nil
+}
+
+// RenderWorkspaceTemplate renders the named workspace template with data.
+// It returns nil for no template and for the empty template.
+func RenderWorkspaceTemplate(name string, data WorkspaceTemplateData) (*protocol.WorkspaceScaffold, error) {
+	if name == "" || name == WorkspaceTemplateEmpty {
+		return nil, nil
+	}
+	t := findWorkspaceTemplate(name)
+	if t == nil {
+		return nil, ValidateWorkspaceTemplate(name)
+	}
+	scaffold := &protocol.WorkspaceScaffold{Template: t.Name, Dirs: t.Dirs, GitInit: t.GitInit}
+	for _, p := range t.Paths {
+		tmpl, err := template.New(p).Option("missingkey=error").Parse(t.Files[p])
+		if err != nil {
+			return nil, fmt.Errorf("workspace template %s: %s: %w", t.Name, p, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("workspace template %s: %s: %w", t.Name, p, err)
+		}
+		scaffold.Files = append(scaffold.Files, protocol.ScaffoldFile{Path: p, Content: buf.String()})
+	}
+	if err := scaffold.Validate(); err != nil {
+		return nil, fmt.Errorf("workspace template %s: %w", t.Name, err)
+	}
+	return scaffold, nil
+}
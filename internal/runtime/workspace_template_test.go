@@ -0,0 +1,74 @@
+package runtime
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+func TestRenderWorkspaceTemplate(t *testing.T) {
+	data := WorkspaceTemplateData{
+		Team:        "billing",
+		Description: "Keeps the invoices flowing.",
+		Leader:      "lead",
+		Agents: []TeamMemberInfo{
+			{Name: "lead", Role: "leader"},
+			{Name: "dev", Role: "worker", Specialty: "Go services"},
+		},
+	}
+
+	for _, tmpl := range WorkspaceTemplates() {
+		scaffold, err := RenderWorkspaceTemplate(tmpl.Name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tmpl.Name, err)
+		}
+		if tmpl.Name == WorkspaceTemplateEmpty {
+			if scaffold != nil {
+				t.Errorf("empty: got %+v, want nil", scaffold)
+			}
+			continue
+		}
+		if scaffold.Template != tmpl.Name || len(scaffold.Files) != len(tmpl.Paths) || !scaffold.GitInit {
+			t.Errorf("%s: got %+v", tmpl.Name, scaffold)
+		}
+		readme := scaffoldFile(scaffold, "README.md")
+		for _, want := range []string{"# billing", "Keeps the invoices flowing.", "led by lead", "- **dev** (worker): Go services"} {
+			if !strings.Contains(readme, want) {
+				t.Errorf("%s: README.md missing %q:\n%s", tmpl.Name, want, readme)
+			}
+		}
+		if !strings.Contains(scaffoldFile(scaffold, "Makefile"), "\n\t") {
+			t.Errorf("%s: Makefile recipes must be tab-indented", tmpl.Name)
+		}
+	}
+
+	docs, _ := RenderWorkspaceTemplate(WorkspaceTemplateDocs, data)
+	if got := scaffoldFile(docs, "mkdocs.yml"); !strings.Contains(got, `site_description: "Keeps the invoices flowing."`) {
+		t.Errorf("mkdocs.yml = %q", got)
+	}
+}
+
+func TestRenderWorkspaceTemplate_NoneAndInvalid(t *testing.T) {
+	if scaffold, err := RenderWorkspaceTemplate("", WorkspaceTemplateData{Team: "t"}); scaffold != nil || err != nil {
+		t.Errorf("no template: got %+v, %v", scaffold, err)
+	}
+	if _, err := RenderWorkspaceTemplate("rails-app", WorkspaceTemplateData{Team: "t"}); err == nil {
+		t.Error("unknown template: want error")
+	}
+	if err := ValidateWorkspaceTemplate("monorepo"); err != nil {
+		t.Errorf("monorepo: %v", err)
+	}
+	if err := ValidateWorkspaceTemplate("Monorepo"); err == nil {
+		t.Error("Monorepo: want error")
+	}
+}
+
+func scaffoldFile(s *protocol.WorkspaceScaffold, path string) string {
+	for _, f := range s.Files {
+		if f.Path == path {
+			return f.Content
+		}
+	}
+	return ""
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/helmcode/agent-crew/internal/protocol"
@@ -274,15 +275,15 @@ func TestGenerateSubAgentContent_OmitsEmptyOptionalFields(t *testing.T) {
 	if contains(content, "skills:") {
 		t.Error("empty skills should be omitted")
 	}
-	// background, isolation, permissionMode are always present.
+	// background, isolation, permissionMode default to an isolated background run.
 	if !contains(content, "background: true") {
-		t.Error("background: true should always be present")
+		t.Error("background: true should be present by default")
 	}
 	if !contains(content, "isolation: worktree") {
-		t.Error("isolation: worktree should always be present")
+		t.Error("isolation: worktree should be present by default")
 	}
 	if !contains(content, "permissionMode: bypassPermissions") {
-		t.Error("permissionMode: bypassPermissions should always be present")
+		t.Error("permissionMode: bypassPermissions should be present by default")
 	}
 }
 
@@ -299,6 +300,23 @@ func TestGenerateSubAgentContent_OmitsModelInherit(t *testing.T) {
 	}
 }
 
+func TestGenerateSubAgentContent_FrontmatterOverrides(t *testing.T) {
+	background := false
+	agent := SubAgentInfo{
+		Name:           "careful-agent",
+		Background:     &background,
+		Isolation:      "none",
+		PermissionMode: "acceptEdits",
+	}
+
+	content := GenerateSubAgentContent(agent)
+
+	expected := "---\nname: careful-agent\npermissionMode: acceptEdits\n---\n"
+	if content != expected {
+		t.Errorf("content: got %q, want %q", content, expected)
+	}
+}
+
 func TestGenerateSubAgentContent_WithBody(t *testing.T) {
 	agent := SubAgentInfo{
 		Name:     "body-agent",
@@ -324,7 +342,7 @@ func TestGenerateSubAgentContent_NoBodyWhenEmpty(t *testing.T) {
 
 	content := GenerateSubAgentContent(agent)
 
-	// background, isolation, permissionMode are always emitted.
+	// background, isolation, permissionMode are emitted with their defaults.
 	expected := "---\nname: no-body-agent\nbackground: true\nisolation: worktree\npermissionMode: bypassPermissions\n---\n"
 	if content != expected {
 		t.Errorf("content: got %q, want %q", content, expected)
@@ -1124,3 +1142,48 @@ func indexOf(s, substr string) int {
 	}
 	return -1
 }
+
+func TestEnsureWorkspaceGitignore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(path, []byte("node_modules/"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EnsureWorkspaceGitignore(dir); err != nil {
+		t.Fatalf("EnsureWorkspaceGitignore: %v", err)
+	}
+	first, _ := os.ReadFile(path)
+	content := string(first)
+	if !strings.HasPrefix(content, "node_modules/\n") {
+		t.Errorf("expected existing entries to be preserved, got:\n%s", content)
+	}
+	for _, entry := range []string{".claude/", ".opencode/", ".agents/"} {
+		if !strings.Contains(content, "\n"+entry+"\n") {
+			t.Errorf("expected %q in .gitignore, got:\n%s", entry, content)
+		}
+	}
+
+	// Running again must not duplicate the managed block.
+	if err := EnsureWorkspaceGitignore(dir); err != nil {
+		t.Fatalf("EnsureWorkspaceGitignore (second run): %v", err)
+	}
+	second, _ := os.ReadFile(path)
+	if string(second) != content {
+		t.Errorf("expected idempotent update, got:\n%s", second)
+	}
+}
+
+func TestEnsureWorkspaceGitignore_CreatesFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := EnsureWorkspaceGitignore(dir); err != nil {
+		t.Fatalf("EnsureWorkspaceGitignore: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatalf("expected .gitignore to be created: %v", err)
+	}
+	if !strings.Contains(string(data), gitignoreBlockStart) {
+		t.Errorf("expected managed block, got:\n%s", data)
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,9 +14,12 @@ import (
 	"github.com/nats-io/nats.go"
 	"gorm.io/gorm"
 
+	"github.com/helmcode/agent-crew/internal/events"
 	"github.com/helmcode/agent-crew/internal/models"
-	"github.com/helmcode/agent-crew/internal/postaction"
+	"github.com/helmcode/agent-crew/internal/naming"
 	"github.com/helmcode/agent-crew/internal/protocol"
+	"github.com/helmcode/agent-crew/internal/ratelimit"
+	"github.com/helmcode/agent-crew/internal/resources"
 	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
@@ -50,6 +53,12 @@ type Executor struct {
 	// polling the database.
 	WaitForResponseFunc func(ctx context.Context, teamName string) error
 
+	// LockTeamFunc claims a team while the executor deploys or stops it, so
+	// that it does not interleave with deploys and stops made through the
+	// API. It returns a function that releases the team, or an error if
+	// the team is busy. If nil, teams are not locked.
+	LockTeamFunc func(teamID, operation string) (unlock func(), err error)
+
 	// LoadSettingsEnvFunc loads settings from DB as env vars for agent containers.
 	// Required for deployment. Takes org_id to scope settings to the tenant.
 	LoadSettingsEnvFunc func(orgID string) map[string]string
@@ -58,8 +67,19 @@ type Executor struct {
 	// Defaults to 10 seconds if zero.
 	PollInterval time.Duration
 
-	// PostActionExec fires post-actions after schedule runs complete.
-	PostActionExec *postaction.Executor
+	// Events receives a RunCompleted event for each finished run, to which
+	// post-actions and issue tracker sync subscribe. If nil, nothing is
+	// published.
+	Events *events.Bus
+
+	// Governor spaces out prompts across all teams. If nil, prompts are
+	// sent right away.
+	Governor *ratelimit.Governor
+
+	// CheckPromptFunc checks a prompt against the organization's input
+	// content policies, as chat messages are, and returns an error if it is
+	// blocked or cannot be checked. If nil, prompts are not checked.
+	CheckPromptFunc func(ctx context.Context, team models.Team, runID, prompt string) error
 }
 
 // NewExecutor creates an Executor with the given dependencies.
@@ -71,16 +91,17 @@ func NewExecutor(db *gorm.DB, rt runtime.AgentRuntime) *Executor {
 		}
 	}
 	return &Executor{
-		DB:             db,
-		Runtime:        rt,
-		Timeout:        timeout,
-		PostActionExec: postaction.NewExecutor(db),
+		DB:      db,
+		Runtime: rt,
+		Timeout: timeout,
 	}
 }
 
 // Execute runs a scheduled task: deploy team, send prompt, wait for completion,
 // and tear down. It creates a ScheduleRun record and always cleans up.
 func (e *Executor) Execute(ctx context.Context, schedule models.Schedule) {
+	schedule.Prompt = e.resolvePrompt(schedule)
+
 	// H2 FIX: Validate prompt size before starting execution.
 	if len(schedule.Prompt) > MaxPromptSize {
 		slog.Error("executor: prompt exceeds maximum size",
@@ -151,9 +172,9 @@ func (e *Executor) Execute(ctx context.Context, schedule models.Schedule) {
 			"run_id", runID, "error", dbErr)
 	}
 
-	// Fire post-actions (fire-and-forget).
-	if e.PostActionExec != nil {
-		// Look up team name for the post-action context.
+	// Publish the result for post-actions and issue tracker sync.
+	if e.Events != nil {
+		// Look up team name for the notification context.
 		var team models.Team
 		teamName := ""
 		if dbErr := e.DB.First(&team, "id = ?", schedule.TeamID).Error; dbErr == nil {
@@ -170,18 +191,19 @@ func (e *Executor) Execute(ctx context.Context, schedule models.Schedule) {
 		runStatus, _ := runUpdates["status"].(string)
 		runError, _ := runUpdates["error"].(string)
 
-		e.PostActionExec.ExecutePostActions(postaction.PostActionContext{
-			SourceType:  "schedule",
+		e.Events.Publish(events.RunCompleted{
+			SourceType:  models.PostActionTriggerSchedule,
 			TriggerID:   schedule.ID,
+			TriggerName: schedule.Name,
 			RunID:       runID,
+			TeamID:      schedule.TeamID,
+			TeamName:    teamName,
 			Status:      runStatus,
 			Response:    response,
 			Error:       runError,
-			TriggerName: schedule.Name,
-			TeamName:    teamName,
 			Prompt:      schedule.Prompt,
-			StartedAt:   now.Format(time.RFC3339),
-			FinishedAt:  finished.Format(time.RFC3339),
+			StartedAt:   now,
+			FinishedAt:  finished,
 		})
 	}
 }
@@ -195,6 +217,16 @@ func (e *Executor) executeWithCleanup(ctx context.Context, schedule models.Sched
 		return fmt.Errorf("loading team: %w", err)
 	}
 
+	// Like chat and deploys, scheduled runs are refused in maintenance.
+	if err := e.checkMaintenance(team); err != nil {
+		return err
+	}
+	if e.CheckPromptFunc != nil {
+		if err := e.CheckPromptFunc(ctx, team, runID, schedule.Prompt); err != nil {
+			return err
+		}
+	}
+
 	// Update the run with team deployment info.
 	e.DB.Model(&models.ScheduleRun{}).Where("id = ?", runID).
 		Update("team_deployment_id", team.ID)
@@ -202,6 +234,11 @@ func (e *Executor) executeWithCleanup(ctx context.Context, schedule models.Sched
 	// Only deploy if the team is not already running.
 	needsTeardown := false
 	if team.Status != models.TeamStatusRunning {
+		// A schedule has no admin to approve its deploys, so it only runs
+		// on such a team while the team is up.
+		if team.RequiresDeployApproval {
+			return fmt.Errorf("team requires an admin's approval to deploy; deploy it before the schedule runs")
+		}
 		slog.Info("executor: deploying team", "team_id", team.ID, "team_name", team.Name)
 
 		if err := e.deployTeam(ctx, team); err != nil {
@@ -228,8 +265,15 @@ func (e *Executor) executeWithCleanup(ctx context.Context, schedule models.Sched
 		}
 	}()
 
+	// Wait for the rate limiter shared with chat and webhook prompts.
+	if err := e.Governor.Wait(ctx, ratelimit.Request{
+		ID: runID, OrgID: team.OrgID, TeamID: team.ID, TeamName: team.Name, Source: "scheduler",
+	}); err != nil {
+		return fmt.Errorf("waiting for rate limiter: %w", err)
+	}
+
 	// FIX #1: Sanitize team name for NATS subjects (must match sidecar/bridge naming).
-	sanitizedName := sanitizeTeamName(team.Name)
+	sanitizedName := naming.Slug(team.Name)
 	slog.Info("executor: sending prompt",
 		"team_id", team.ID,
 		"team_name", team.Name,
@@ -237,9 +281,13 @@ func (e *Executor) executeWithCleanup(ctx context.Context, schedule models.Sched
 		"prompt_length", len(schedule.Prompt),
 	)
 
-	// Store prompt in the run record.
-	e.DB.Model(&models.ScheduleRun{}).Where("id = ?", runID).
-		Update("prompt_sent", schedule.Prompt)
+	// Store prompt in the run record, with the team revision it runs on.
+	var revision int
+	e.DB.Model(&models.Team{}).Where("id = ?", team.ID).Pluck("config_revision", &revision)
+	e.DB.Model(&models.ScheduleRun{}).Where("id = ?", runID).Updates(map[string]interface{}{
+		"prompt_sent":   schedule.Prompt,
+		"team_revision": revision,
+	})
 
 	// Send prompt and wait for response, capturing the response text.
 	responseText, err := e.sendPromptAndWait(ctx, sanitizedName, schedule.Prompt, runID)
@@ -256,38 +304,162 @@ func (e *Executor) executeWithCleanup(ctx context.Context, schedule models.Sched
 	return nil
 }
 
+// checkMaintenance returns an error if the organization of team is in
+// maintenance mode.
+func (e *Executor) checkMaintenance(team models.Team) error {
+	var org models.Organization
+	if err := e.DB.Select("id", "maintenance", "maintenance_message").
+		First(&org, "id = ?", team.OrgID).Error; err != nil || !org.Maintenance {
+		return nil
+	}
+	return fmt.Errorf("organization is in maintenance: %s", org.MaintenanceMessage)
+}
+
+// checkWorkspaceSharing returns an error if another running team mounts the
+// host workspace of team and they do not both use the "shared" config dir
+// mode.
+func (e *Executor) checkWorkspaceSharing(team models.Team) error {
+	if team.WorkspacePath == "" {
+		return nil
+	}
+	var others []models.Team
+	if err := e.DB.Select("id", "name", "workspace_path", "config_dir_mode").
+		Where("id <> ? AND workspace_path <> '' AND status IN ?", team.ID,
+			[]string{models.TeamStatusRunning, models.TeamStatusDeploying}).
+		Find(&others).Error; err != nil {
+		return fmt.Errorf("checking workspace: %w", err)
+	}
+	for _, other := range others {
+		if models.WorkspaceClashes(team, other) {
+			return fmt.Errorf("workspace %s is in use by another running team", team.WorkspacePath)
+		}
+	}
+	return nil
+}
+
+// checkSkillsCatalog returns an error if the organization's skills catalog
+// has entries and a skill of the team, in either form, is not one of them.
+func (e *Executor) checkSkillsCatalog(team models.Team) error {
+	var catalog []models.SkillCatalogEntry
+	if err := e.DB.Select("repo_url", "skill_name").Where("org_id = ?", team.OrgID).Find(&catalog).Error; err != nil {
+		return fmt.Errorf("checking skills catalog: %w", err)
+	}
+	if len(catalog) == 0 {
+		return nil
+	}
+	known := make(map[protocol.SkillConfig]bool, len(catalog))
+	for _, entry := range catalog {
+		known[protocol.SkillConfig{RepoURL: entry.RepoURL, SkillName: entry.SkillName}] = true
+	}
+	for _, a := range team.Agents {
+		for _, skill := range protocol.ParseSkillConfigs(json.RawMessage(a.SubAgentSkills)) {
+			if !known[skill] {
+				return fmt.Errorf("skill %s:%s is not in the skills catalog", skill.RepoURL, skill.SkillName)
+			}
+		}
+	}
+	return nil
+}
+
+// lockTeam claims the team for operation through LockTeamFunc.
+func (e *Executor) lockTeam(teamID, operation string) (func(), error) {
+	if e.LockTeamFunc == nil {
+		return func() {}, nil
+	}
+	return e.LockTeamFunc(teamID, operation)
+}
+
 // deployTeam deploys a team using the configured function or default implementation.
 func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
+	unlock, err := e.lockTeam(team.ID, "scheduled deploy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if e.DeployTeamFunc != nil {
 		return e.DeployTeamFunc(ctx, team)
 	}
 
-	// Default: update status to deploying and call runtime.
-	e.DB.Model(&team).Update("status", models.TeamStatusDeploying)
+	if err := e.checkWorkspaceSharing(team); err != nil {
+		return err
+	}
+	if err := e.checkSkillsCatalog(team); err != nil {
+		return err
+	}
+
+	deployment, err := models.StartDeployment(e.DB, team, models.DeploymentTriggerSchedule, "")
+	if err != nil {
+		slog.Error("executor: failed to record deployment", "team_id", team.ID, "error", err)
+	}
+	err = e.deployLeader(ctx, team, deployment)
+	var deployErr string
+	if err != nil {
+		deployErr = err.Error()
+	}
+	if err := models.FinishDeployment(e.DB, deployment, deployErr); err != nil {
+		slog.Error("executor: failed to record deployment outcome", "team_id", team.ID, "error", err)
+	}
+	return err
+}
+
+// deployLeader deploys the team's infrastructure and leader, recording the
+// leader's container and the team revision on deployment.
+func (e *Executor) deployLeader(ctx context.Context, team models.Team, deployment *models.Deployment) error {
+	// Default: update status to deploying, start a new conversation (the
+	// fresh leader container has no prior session), and call runtime.
+	team.ConversationID = uuid.New().String()
+	e.DB.Model(&team).Updates(map[string]interface{}{
+		"status":          models.TeamStatusDeploying,
+		"conversation_id": team.ConversationID,
+		"chat_sequence":   0,
+	})
+
+	// Load settings from DB for environment variables, scoped to the team's org.
+	env := map[string]string{}
+	if e.LoadSettingsEnvFunc != nil {
+		env = e.LoadSettingsEnvFunc(team.OrgID)
+	}
+	natsCfg, err := runtime.NATSConfigFromEnv(env)
+	if err != nil {
+		e.DB.Model(&team).Update("status", models.TeamStatusError)
+		return fmt.Errorf("nats settings: %w", err)
+	}
 
 	// Deploy infrastructure.
 	infraCfg := runtime.InfraConfig{
 		TeamName:      team.Name,
+		TeamID:        team.ID,
 		NATSEnabled:   true,
+		NATS:          natsCfg,
 		WorkspacePath: team.WorkspacePath,
+		DockerHost:    team.DockerHost,
+	}
+	if len(team.NamespaceConfig) > 0 {
+		_ = json.Unmarshal(team.NamespaceConfig, &infraCfg.Namespace)
+	}
+	if len(team.RestartPolicy) > 0 {
+		_ = json.Unmarshal(team.RestartPolicy, &infraCfg.Restart)
+	}
+	if len(team.HostSelector) > 0 {
+		_ = json.Unmarshal(team.HostSelector, &infraCfg.HostSelector)
 	}
 
 	if err := e.Runtime.DeployInfra(ctx, infraCfg); err != nil {
 		e.DB.Model(&team).Update("status", models.TeamStatusError)
 		return fmt.Errorf("deploying infrastructure: %w", err)
 	}
+	if hp, ok := e.Runtime.(runtime.HostPlacer); ok {
+		if host := hp.TeamHost(team.Name); host != "" && host != team.DockerHost {
+			e.DB.Model(&team).Update("docker_host", host)
+		}
+	}
 
 	provider := team.Provider
 	if provider == "" {
 		provider = models.ProviderClaude
 	}
 
-	// Load settings from DB for environment variables, scoped to the team's org.
-	env := map[string]string{}
-	if e.LoadSettingsEnvFunc != nil {
-		env = e.LoadSettingsEnvFunc(team.OrgID)
-	}
-
 	natsURL := e.Runtime.GetNATSURL(team.Name)
 
 	// Find the leader and extract leader skills.
@@ -313,12 +485,31 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 	var teamMembers []runtime.TeamMemberInfo
 	for _, a := range team.Agents {
 		teamMembers = append(teamMembers, runtime.TeamMemberInfo{
-			Name:      sanitizeTeamName(a.Name),
+			Name:      naming.Slug(a.Name),
 			Role:      a.Role,
 			Specialty: a.Specialty,
 		})
 	}
 
+	// In "separate" mode generated config lives on dedicated mounts, so
+	// nothing is written into the host workspace. In "shared" mode it is
+	// written into the team's worktree.
+	writeHostConfig := team.WorkspacePath != "" && team.ConfigDirMode != models.ConfigDirModeSeparate
+	if team.WorkspacePath != "" && team.ConfigDirMode == models.ConfigDirModeGitignore {
+		if err := runtime.EnsureWorkspaceGitignore(team.WorkspacePath); err != nil {
+			slog.Error("executor: failed to update workspace .gitignore", "team", team.Name, "error", err)
+		}
+	}
+	configRoot := team.WorkspacePath
+	if team.WorkspacePath != "" && team.ConfigDirMode == models.ConfigDirModeShared {
+		worktree, err := runtime.PrepareSharedWorkspace(ctx, team.WorkspacePath, team.Name)
+		if err != nil {
+			e.DB.Model(&team).Update("status", models.TeamStatusError)
+			return fmt.Errorf("preparing shared workspace: %w", err)
+		}
+		configRoot = worktree
+	}
+
 	// Generate sub-agent files for workers based on provider.
 	subAgentFiles := map[string]string{}
 	var openCodeWorkers []runtime.SubAgentInfo
@@ -350,13 +541,16 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 				Skills:       json.RawMessage(agent.Skills),
 			}
 			subInfo := runtime.SubAgentInfo{
-				Name:         agent.Name,
-				Description:  agent.SubAgentDescription,
-				Instructions: agent.SubAgentInstructions,
-				Model:        agent.SubAgentModel,
-				Skills:       json.RawMessage(agent.SubAgentSkills),
-				GlobalSkills: leaderSkills,
-				ClaudeMD:     agent.InstructionsMD,
+				Name:           agent.Name,
+				Description:    agent.SubAgentDescription,
+				Instructions:   agent.SubAgentInstructions,
+				Model:          agent.SubAgentModel,
+				Skills:         json.RawMessage(agent.SubAgentSkills),
+				GlobalSkills:   leaderSkills,
+				ClaudeMD:       agent.InstructionsMD,
+				Background:     agent.SubAgentBackground,
+				Isolation:      agent.SubAgentIsolation,
+				PermissionMode: agent.SubAgentPermissionMode,
 			}
 			if subInfo.ClaudeMD == "" {
 				subInfo.ClaudeMD = runtime.GenerateClaudeMD(info)
@@ -364,8 +558,8 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 			filename := runtime.SubAgentFileName(agent.Name)
 			subAgentFiles[filename] = runtime.GenerateSubAgentContent(subInfo)
 
-			if team.WorkspacePath != "" {
-				if _, err := runtime.SetupSubAgentFile(team.WorkspacePath, subInfo); err != nil {
+			if writeHostConfig {
+				if _, err := runtime.SetupSubAgentFile(configRoot, subInfo); err != nil {
 					slog.Error("executor: failed to setup sub-agent file", "agent", agent.Name, "error", err)
 				}
 			}
@@ -373,7 +567,7 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 	}
 
 	// Setup host workspace for the leader based on provider.
-	if team.WorkspacePath != "" {
+	if writeHostConfig {
 		if provider == models.ProviderOpenCode {
 			leaderSub := runtime.SubAgentInfo{
 				Name:        leader.Name,
@@ -381,7 +575,7 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 				Skills:      json.RawMessage(leader.Skills),
 				ClaudeMD:    leader.InstructionsMD,
 			}
-			if err := runtime.SetupOpenCodeWorkspace(team.WorkspacePath, team.Name, leaderSub, openCodeWorkers, leaderSkillConfigs); err != nil {
+			if err := runtime.SetupOpenCodeWorkspace(configRoot, team.Name, leaderSub, openCodeWorkers, leaderSkillConfigs); err != nil {
 				slog.Error("executor: failed to setup opencode workspace", "team", team.Name, "error", err)
 			}
 		} else {
@@ -394,7 +588,7 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 				Skills:       json.RawMessage(leader.Skills),
 				TeamMembers:  teamMembers,
 			}
-			if _, err := runtime.SetupAgentWorkspace(team.WorkspacePath, info); err != nil {
+			if _, err := runtime.SetupAgentWorkspace(configRoot, info); err != nil {
 				slog.Error("executor: failed to setup agent workspace", "agent", leader.Name, "error", err)
 			}
 		}
@@ -422,6 +616,39 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 		skillsJSON, _ := json.Marshal(allSkills)
 		env["AGENT_SKILLS_INSTALL"] = string(skillsJSON)
 	}
+	if len(team.Bootstrap) > 0 {
+		var bootstrap protocol.BootstrapConfig
+		if err := json.Unmarshal(team.Bootstrap, &bootstrap); err == nil && bootstrap.Script != "" {
+			env["AGENT_BOOTSTRAP"] = string(team.Bootstrap)
+		}
+	}
+	scaffold, err := runtime.RenderWorkspaceTemplate(team.WorkspaceTemplate, runtime.WorkspaceTemplateData{
+		Team:        team.Name,
+		Description: team.Description,
+		Leader:      naming.Slug(leader.Name),
+		Agents:      teamMembers,
+	})
+	if err != nil {
+		slog.Error("executor: failed to render workspace template", "team", team.Name, "error", err)
+	} else if scaffold != nil {
+		scaffoldJSON, _ := json.Marshal(scaffold)
+		env["AGENT_WORKSPACE_SCAFFOLD"] = string(scaffoldJSON)
+	}
+	if team.MaxConcurrentRuns > 0 {
+		env["AGENT_MAX_CONCURRENT_RUNS"] = strconv.Itoa(team.MaxConcurrentRuns)
+	}
+	if team.TelemetryLevel != "" {
+		env["AGENT_TELEMETRY_LEVEL"] = team.TelemetryLevel
+	}
+	if team.PlanApproval {
+		env["AGENT_PLAN_APPROVAL"] = "true"
+	}
+	if team.Memory {
+		env["AGENT_MEMORY"] = "true"
+		if memory := models.LatestTeamMemory(e.DB, team.ID); memory != "" {
+			env["AGENT_MEMORY_CONTEXT"] = memory
+		}
+	}
 
 	// Set model env var based on provider.
 	leaderModel := leader.SubAgentModel
@@ -438,6 +665,9 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 			env["OPENCODE_MODEL"] = m
 		}
 	}
+	if provider != models.ProviderOpenCode && len(leader.ExtraClaudeArgs) > 0 && string(leader.ExtraClaudeArgs) != "null" {
+		env["CLAUDE_EXTRA_ARGS"] = string(leader.ExtraClaudeArgs)
+	}
 
 	// Validate OpenCode model credentials before deployment.
 	if provider == models.ProviderOpenCode {
@@ -502,10 +732,21 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 		Provider:      provider,
 		SystemPrompt:  leader.SystemPrompt,
 		ClaudeMD:      instructionsMDContent,
+		Resources:     resources.ForLeader(e.DB, team, *leader),
 		NATSUrl:       natsURL,
 		WorkspacePath: team.WorkspacePath,
 		SubAgentFiles: subAgentFiles,
 		Env:           env,
+		ConfigDirMode: team.ConfigDirMode,
+		RunAsUID:      leader.RunAsUID,
+		RunAsGID:      leader.RunAsGID,
+	}
+	agentCfg.SkipWorkspaceChown = leader.SkipWorkspaceChown
+	if len(team.ExtraWorkspaces) > 0 {
+		_ = json.Unmarshal(team.ExtraWorkspaces, &agentCfg.ExtraWorkspaces)
+	}
+	if len(team.RestartPolicy) > 0 {
+		_ = json.Unmarshal(team.RestartPolicy, &agentCfg.Restart)
 	}
 
 	instance, err := e.Runtime.DeployAgent(ctx, agentCfg)
@@ -518,7 +759,22 @@ func (e *Executor) deployTeam(ctx context.Context, team models.Team) error {
 		"container_id":     instance.ID,
 		"container_status": models.ContainerStatusRunning,
 	})
+	var digest string
+	if digester, ok := e.Runtime.(runtime.ImageDigester); ok {
+		if digest, err = digester.ImageDigest(ctx, instance.ID); err != nil {
+			slog.Warn("executor: failed to read image digest", "team_id", team.ID, "error", err)
+		}
+	}
+	deployment.SetContainer(leader.Name, instance.ID, agentCfg.Image, digest)
+
 	e.DB.Model(&team).Update("status", models.TeamStatusRunning)
+	revision, err := models.RecordTeamRevision(e.DB, team.ID)
+	if err != nil {
+		slog.Error("executor: failed to record team revision", "team_id", team.ID, "error", err)
+	}
+	if deployment != nil {
+		deployment.TeamRevision = revision
+	}
 
 	return nil
 }
@@ -566,11 +822,18 @@ func schedulerValidateOpenCodeCredentials(model string, env map[string]string) e
 
 // stopTeam stops a running team.
 func (e *Executor) stopTeam(ctx context.Context, team models.Team) error {
+	unlock, err := e.lockTeam(team.ID, "scheduled stop")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if e.StopTeamFunc != nil {
 		return e.StopTeamFunc(ctx, team)
 	}
 
-	if err := e.Runtime.TeardownInfra(ctx, team.Name); err != nil {
+	// Keep the workspace, as a stop through the API does.
+	if err := e.Runtime.TeardownInfra(ctx, team.Name, runtime.TeardownOptions{PreserveWorkspace: true}); err != nil {
 		slog.Error("executor: teardown failed", "team", team.Name, "error", err)
 	}
 
@@ -589,6 +852,32 @@ func (e *Executor) stopTeam(ctx context.Context, team models.Team) error {
 	return nil
 }
 
+// resolvePrompt returns the prompt to send for a schedule. Schedules linked to
+// a PromptTemplate are re-rendered so template edits apply to the next run;
+// if the template can no longer be loaded, the stored prompt snapshot is used.
+func (e *Executor) resolvePrompt(schedule models.Schedule) string {
+	if schedule.PromptTemplateID == nil || *schedule.PromptTemplateID == "" {
+		return schedule.Prompt
+	}
+
+	var tmpl models.PromptTemplate
+	if err := e.DB.Where("id = ? AND org_id = ?", *schedule.PromptTemplateID, schedule.OrgID).
+		First(&tmpl).Error; err != nil {
+		slog.Warn("executor: prompt template not found, using stored prompt",
+			"schedule_id", schedule.ID, "template_id", *schedule.PromptTemplateID, "error", err)
+		return schedule.Prompt
+	}
+
+	vars := map[string]string{}
+	if len(schedule.PromptVariables) > 0 {
+		if err := json.Unmarshal(schedule.PromptVariables, &vars); err != nil {
+			slog.Warn("executor: invalid prompt variables, rendering without them",
+				"schedule_id", schedule.ID, "error", err)
+		}
+	}
+	return tmpl.Render(vars)
+}
+
 // sendPromptAndWait connects to the team's NATS, subscribes to the leader
 // channel for a response, sends the prompt, and blocks until a
 // TypeLeaderResponse is received or the context expires.
@@ -769,29 +1058,6 @@ func (e *Executor) markScheduleError(scheduleID, errMsg string) {
 	slog.Error("executor: schedule error", "schedule_id", scheduleID, "error", errMsg)
 }
 
-// invalidSlugChars matches any character that is not lowercase alphanumeric, hyphen, or underscore.
-var invalidSlugChars = regexp.MustCompile(`[^a-z0-9_-]`)
-
-// sanitizeTeamName converts a display name into a Docker/K8s/NATS-safe slug.
-// This must produce the same output as api.SanitizeName to match the sidecar's naming.
-func sanitizeTeamName(name string) string {
-	s := strings.ToLower(strings.TrimSpace(name))
-	s = strings.ReplaceAll(s, " ", "-")
-	s = invalidSlugChars.ReplaceAllString(s, "")
-	for strings.Contains(s, "--") {
-		s = strings.ReplaceAll(s, "--", "-")
-	}
-	s = strings.Trim(s, "-")
-	if len(s) > 62 {
-		s = s[:62]
-		s = strings.TrimRight(s, "-")
-	}
-	if s == "" {
-		s = "team"
-	}
-	return s
-}
-
 // sanitizeError removes sensitive information from error messages before
 // storing them in the database. It redacts tokens, URLs with credentials,
 // and internal paths.
@@ -3,10 +3,12 @@ package scheduler
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/runtime"
 )
 
 func TestExecutor_Execute_Success(t *testing.T) {
@@ -146,6 +148,209 @@ func TestExecutor_Execute_DeployFailure(t *testing.T) {
 	}
 }
 
+func TestExecutor_Execute_TeamBusy(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	db.Create(&models.Team{
+		ID:      "team-busy",
+		Name:    "busy-team",
+		Status:  models.TeamStatusStopped,
+		Runtime: "docker",
+	})
+	schedule := models.Schedule{
+		ID:             "sched-busy",
+		Name:           "busy-exec",
+		TeamID:         "team-busy",
+		Prompt:         "Run it",
+		CronExpression: "* * * * *",
+		Timezone:       "UTC",
+		Enabled:        true,
+		Status:         models.ScheduleStatusRunning,
+	}
+	db.Create(&schedule)
+
+	var locked []string
+	deployed := false
+	executor := &Executor{
+		DB:      db,
+		Timeout: 10 * time.Second,
+		LockTeamFunc: func(teamID, operation string) (func(), error) {
+			locked = append(locked, operation)
+			if operation == "scheduled deploy" {
+				return nil, fmt.Errorf("team is busy: stop in progress")
+			}
+			return func() {}, nil
+		},
+		DeployTeamFunc: func(ctx context.Context, team models.Team) error {
+			deployed = true
+			return nil
+		},
+	}
+
+	executor.Execute(context.Background(), schedule)
+
+	if deployed {
+		t.Error("team deployed while another operation held it")
+	}
+	if len(locked) != 1 {
+		t.Errorf("lock requests = %v, want only the deploy", locked)
+	}
+	var run models.ScheduleRun
+	db.Where("schedule_id = ?", "sched-busy").First(&run)
+	if run.Status != models.ScheduleRunStatusFailed {
+		t.Errorf("run status = %q, want failed", run.Status)
+	}
+}
+
+func TestExecutor_Execute_Maintenance(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	db.Create(&models.Organization{ID: "org-maint", Name: "maint", Slug: "maint", Maintenance: true, MaintenanceMessage: "Upgrading"})
+	db.Create(&models.Team{
+		ID:      "team-maint",
+		OrgID:   "org-maint",
+		Name:    "maint-team",
+		Status:  models.TeamStatusRunning,
+		Runtime: "docker",
+	})
+	schedule := models.Schedule{
+		ID:             "sched-maint",
+		Name:           "maint-exec",
+		TeamID:         "team-maint",
+		Prompt:         "Run it",
+		CronExpression: "* * * * *",
+		Timezone:       "UTC",
+		Enabled:        true,
+		Status:         models.ScheduleStatusRunning,
+	}
+	db.Create(&schedule)
+
+	sent := false
+	executor := &Executor{
+		DB:      db,
+		Timeout: 10 * time.Second,
+		SendPromptFunc: func(ctx context.Context, teamName, message string) error {
+			sent = true
+			return nil
+		},
+	}
+
+	executor.Execute(context.Background(), schedule)
+
+	if sent {
+		t.Error("prompt sent while the organization is in maintenance")
+	}
+	var run models.ScheduleRun
+	db.Where("schedule_id = ?", "sched-maint").First(&run)
+	if run.Status != models.ScheduleRunStatusFailed || !strings.Contains(run.Error, "Upgrading") {
+		t.Errorf("run = %q %q, want failed with the maintenance message", run.Status, run.Error)
+	}
+}
+
+func TestExecutor_Execute_PromptBlocked(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	db.Create(&models.Team{
+		ID:      "team-blocked",
+		Name:    "blocked-team",
+		Status:  models.TeamStatusRunning,
+		Runtime: "docker",
+	})
+	schedule := models.Schedule{
+		ID:             "sched-blocked",
+		Name:           "blocked-exec",
+		TeamID:         "team-blocked",
+		Prompt:         "Report on Project Falcon",
+		CronExpression: "* * * * *",
+		Timezone:       "UTC",
+		Enabled:        true,
+		Status:         models.ScheduleStatusRunning,
+	}
+	db.Create(&schedule)
+
+	sent := false
+	var checked string
+	executor := &Executor{
+		DB:      db,
+		Timeout: 10 * time.Second,
+		SendPromptFunc: func(ctx context.Context, teamName, message string) error {
+			sent = true
+			return nil
+		},
+		CheckPromptFunc: func(ctx context.Context, team models.Team, runID, prompt string) error {
+			checked = prompt
+			return fmt.Errorf("Prompt blocked by content policy %q", "codenames")
+		},
+	}
+
+	executor.Execute(context.Background(), schedule)
+
+	if sent || checked != schedule.Prompt {
+		t.Errorf("sent %v, checked %q: want the prompt checked and not sent", sent, checked)
+	}
+	var run models.ScheduleRun
+	db.Where("schedule_id = ?", "sched-blocked").First(&run)
+	if run.Status != models.ScheduleRunStatusFailed || !strings.Contains(run.Error, "codenames") {
+		t.Errorf("run = %q %q, want failed with the blocking policy", run.Status, run.Error)
+	}
+}
+
+func TestExecutor_Execute_RequiresDeployApproval(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	db.Create(&models.Team{
+		ID:                     "team-approval",
+		Name:                   "approval-team",
+		Status:                 models.TeamStatusStopped,
+		Runtime:                "docker",
+		RequiresDeployApproval: true,
+	})
+	schedule := models.Schedule{
+		ID:             "sched-approval",
+		Name:           "approval-exec",
+		TeamID:         "team-approval",
+		Prompt:         "Run it",
+		CronExpression: "* * * * *",
+		Timezone:       "UTC",
+		Enabled:        true,
+		Status:         models.ScheduleStatusRunning,
+	}
+	db.Create(&schedule)
+
+	deployed := false
+	executor := &Executor{
+		DB:      db,
+		Timeout: 10 * time.Second,
+		DeployTeamFunc: func(ctx context.Context, team models.Team) error {
+			deployed = true
+			return nil
+		},
+	}
+
+	executor.Execute(context.Background(), schedule)
+
+	if deployed {
+		t.Error("schedule deployed a team that requires approval")
+	}
+	var run models.ScheduleRun
+	db.Where("schedule_id = ?", "sched-approval").First(&run)
+	if run.Status != models.ScheduleRunStatusFailed || !strings.Contains(run.Error, "approval") {
+		t.Errorf("run = %q %q, want failed for approval", run.Status, run.Error)
+	}
+}
+
 func TestExecutor_Execute_Timeout(t *testing.T) {
 	db, err := models.InitDB(":memory:")
 	if err != nil {
@@ -541,24 +746,76 @@ func TestExecutor_Execute_SanitizesTeamName(t *testing.T) {
 	}
 }
 
-func TestSanitizeTeamName(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"My Team", "my-team"},
-		{"simple", "simple"},
-		{"Hello  World", "hello-world"},
-		{"test@team!", "testteam"},
-		{"", "team"},
-		{"  spaces  ", "spaces"},
-		{"UPPER-case", "upper-case"},
-	}
-
-	for _, tt := range tests {
-		got := sanitizeTeamName(tt.input)
-		if got != tt.expected {
-			t.Errorf("sanitizeTeamName(%q) = %q, want %q", tt.input, got, tt.expected)
-		}
+func TestExecutor_CheckSkillsCatalog(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	e := &Executor{DB: db}
+	team := models.Team{
+		ID:    "team-skills",
+		OrgID: "org-1",
+		Agents: []models.Agent{{
+			Name:           "writer",
+			SubAgentSkills: models.JSON(`[{"repo_url":"https://github.com/acme/skills","skill_name":"changelog"}]`),
+		}},
+	}
+
+	if err := e.checkSkillsCatalog(team); err != nil {
+		t.Fatalf("empty catalog: %v", err)
+	}
+
+	db.Create(&models.SkillCatalogEntry{ID: "s1", OrgID: "org-1", RepoURL: "https://github.com/acme/skills", SkillName: "pdf-tools"})
+	if err := e.checkSkillsCatalog(team); err == nil {
+		t.Fatal("expected an error for a skill missing from the catalog")
+	}
+
+	db.Create(&models.SkillCatalogEntry{ID: "s2", OrgID: "org-1", RepoURL: "https://github.com/acme/skills", SkillName: "changelog"})
+	if err := e.checkSkillsCatalog(team); err != nil {
+		t.Errorf("skill in catalog: %v", err)
+	}
+
+	// Legacy "owner/repo:skill" strings are checked too.
+	team.Agents[0].SubAgentSkills = models.JSON(`["acme/skills:changelog","acme/skills:secrets-dump"]`)
+	if err := e.checkSkillsCatalog(team); err == nil || !strings.Contains(err.Error(), "secrets-dump") {
+		t.Errorf("legacy skill missing from the catalog: got %v", err)
+	}
+	team.Agents[0].SubAgentSkills = models.JSON(`["acme/skills:changelog"]`)
+	if err := e.checkSkillsCatalog(team); err != nil {
+		t.Errorf("legacy skill in catalog: %v", err)
+	}
+}
+
+func TestExecutor_DeployLeader_StartsNewConversation(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+
+	team := models.Team{
+		ID:             "team-conv",
+		Name:           "conv-team",
+		Status:         models.TeamStatusStopped,
+		Runtime:        "docker",
+		ConversationID: "previous-conversation",
+	}
+	db.Create(&team)
+
+	// Invalid NATS settings stop the deployment right after the team is
+	// marked deploying, before any runtime call.
+	executor := &Executor{
+		DB: db,
+		LoadSettingsEnvFunc: func(string) map[string]string {
+			return map[string]string{runtime.SettingJetStreamMaxMemory: "lots"}
+		},
+	}
+	if err := executor.deployLeader(context.Background(), team, nil); err == nil {
+		t.Fatal("expected invalid NATS settings to fail the deployment")
+	}
+
+	var updated models.Team
+	db.First(&updated, "id = ?", team.ID)
+	if updated.ConversationID == "" || updated.ConversationID == "previous-conversation" {
+		t.Errorf("conversation_id: got %q, want a new conversation", updated.ConversationID)
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
 )
 
 // DefaultMaxConcurrent is the default maximum number of concurrent schedule executions.
@@ -107,7 +108,17 @@ func (s *Scheduler) tick() {
 	}
 
 	for _, sched := range schedules {
-		if !IsDue(sched.CronExpression, sched.Timezone, now) {
+		deferredDue := sched.DeferredUntil != nil && !now.Before(*sched.DeferredUntil)
+		if !deferredDue && !IsDue(sched.CronExpression, sched.Timezone, now) {
+			continue
+		}
+
+		switch action, at := s.workingHoursAction(sched, now); action {
+		case workhours.ActionSkip:
+			s.skipRun(sched, now)
+			continue
+		case workhours.ActionDefer:
+			s.deferRun(sched, at)
 			continue
 		}
 
@@ -118,8 +129,9 @@ func (s *Scheduler) tick() {
 		result := s.db.Model(&models.Schedule{}).
 			Where("id = ? AND status = ?", sched.ID, models.ScheduleStatusIdle).
 			Updates(map[string]interface{}{
-				"status":      models.ScheduleStatusRunning,
-				"last_run_at": now,
+				"status":         models.ScheduleStatusRunning,
+				"last_run_at":    now,
+				"deferred_until": nil,
 			})
 		if result.Error != nil {
 			slog.Error("scheduler: failed to claim schedule", "id", sched.ID, "error", result.Error)
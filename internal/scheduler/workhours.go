@@ -0,0 +1,85 @@
+package scheduler
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
+)
+
+// outsideWorkingHours is the error of a run skipped outside the team's
+// working hours.
+const outsideWorkingHours = "skipped outside the team's working hours"
+
+// workingHoursAction returns what to do with a run of sched due at now:
+// workhours.ActionRun, ActionSkip or ActionDefer, with the time a deferred
+// run is due.
+func (s *Scheduler) workingHoursAction(sched models.Schedule, now time.Time) (string, time.Time) {
+	if sched.WorkingHours == workhours.ActionRun {
+		return workhours.ActionRun, time.Time{}
+	}
+	var team models.Team
+	if err := s.db.Select("id", "working_hours").First(&team, "id = ?", sched.TeamID).Error; err != nil {
+		// The executor reports the missing team.
+		return workhours.ActionRun, time.Time{}
+	}
+	policy, err := workhours.Parse(team.WorkingHours)
+	if err != nil {
+		slog.Warn("scheduler: ignoring invalid working hours", "team_id", team.ID, "error", err)
+		return workhours.ActionRun, time.Time{}
+	}
+	if policy == nil || policy.Contains(now) {
+		return workhours.ActionRun, time.Time{}
+	}
+
+	action := sched.WorkingHours
+	if action == "" {
+		action = policy.OutsideAction()
+	}
+	if action == workhours.ActionDefer {
+		if at := policy.NextStart(now); !at.IsZero() {
+			return workhours.ActionDefer, at
+		}
+	}
+	return workhours.ActionSkip, time.Time{}
+}
+
+// skipRun records a run of sched skipped outside the team's working hours.
+func (s *Scheduler) skipRun(sched models.Schedule, now time.Time) {
+	slog.Info("scheduler: skipping run outside working hours", "id", sched.ID, "name", sched.Name)
+	finished := now
+	run := models.ScheduleRun{
+		ID:         uuid.New().String(),
+		ScheduleID: sched.ID,
+		StartedAt:  now,
+		FinishedAt: &finished,
+		Status:     models.ScheduleRunStatusSkipped,
+		Error:      outsideWorkingHours,
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		slog.Error("scheduler: failed to record skipped run", "id", sched.ID, "error", err)
+	}
+
+	updates := map[string]interface{}{"deferred_until": nil}
+	if next := NextRun(sched.CronExpression, sched.Timezone); !next.IsZero() {
+		updates["next_run_at"] = next.UTC()
+	}
+	s.db.Model(&models.Schedule{}).Where("id = ?", sched.ID).Updates(updates)
+}
+
+// deferRun defers the run of sched to at, when the team's working hours
+// start. Runs deferred to the same time are run once.
+func (s *Scheduler) deferRun(sched models.Schedule, at time.Time) {
+	if sched.DeferredUntil != nil && sched.DeferredUntil.Equal(at) {
+		return
+	}
+	slog.Info("scheduler: deferring run to working hours", "id", sched.ID, "name", sched.Name, "until", at)
+	at = at.UTC()
+	s.db.Model(&models.Schedule{}).Where("id = ?", sched.ID).Updates(map[string]interface{}{
+		"deferred_until": at,
+		"next_run_at":    at,
+	})
+}
@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/workhours"
+)
+
+// closedPolicy returns working hours, every day, that are closed now: they
+// open in two hours.
+func closedPolicy(t *testing.T, outside string) models.JSON {
+	t.Helper()
+	now := time.Now().UTC()
+	data, err := json.Marshal(workhours.Policy{
+		Days:    []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"},
+		Start:   now.Add(2 * time.Hour).Format("15:04"),
+		End:     now.Add(3 * time.Hour).Format("15:04"),
+		Outside: outside,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.JSON(data)
+}
+
+// tickOnce runs one scheduler tick and returns the schedules executed.
+func tickOnce(t *testing.T, s *Scheduler, executed *[]string, mu *sync.Mutex) []string {
+	t.Helper()
+	s.ctx = context.Background()
+	s.tick()
+	s.wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]string(nil), *executed...)
+}
+
+func TestScheduler_WorkingHours(t *testing.T) {
+	db, err := models.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	var (
+		mu       sync.Mutex
+		executed []string
+	)
+	s := New(db, func(_ context.Context, sched models.Schedule) {
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, sched.ID)
+	}, time.Minute)
+
+	db.Create(&models.Team{ID: "team-skip", Name: "skip-team", Status: models.TeamStatusStopped, Runtime: "docker",
+		WorkingHours: closedPolicy(t, "")})
+	db.Create(&models.Team{ID: "team-defer", Name: "defer-team", Status: models.TeamStatusStopped, Runtime: "docker",
+		WorkingHours: closedPolicy(t, workhours.ActionDefer)})
+	for _, sched := range []models.Schedule{
+		{ID: "sched-skip", TeamID: "team-skip"},
+		{ID: "sched-defer", TeamID: "team-defer"},
+		{ID: "sched-override", TeamID: "team-skip", WorkingHours: workhours.ActionRun},
+	} {
+		sched.Name, sched.Prompt, sched.CronExpression, sched.Timezone = sched.ID, "Run", "* * * * *", "UTC"
+		sched.Enabled, sched.Status = true, models.ScheduleStatusIdle
+		db.Create(&sched)
+	}
+
+	if got := tickOnce(t, s, &executed, &mu); len(got) != 1 || got[0] != "sched-override" {
+		t.Fatalf("executed: got %v, want only the schedule overriding the working hours", got)
+	}
+
+	var runs []models.ScheduleRun
+	db.Find(&runs, "schedule_id = ?", "sched-skip")
+	if len(runs) != 1 || runs[0].Status != models.ScheduleRunStatusSkipped {
+		t.Errorf("skipped runs: got %+v, want one skipped run", runs)
+	}
+
+	var deferred models.Schedule
+	db.First(&deferred, "id = ?", "sched-defer")
+	if deferred.DeferredUntil == nil || time.Until(*deferred.DeferredUntil) < 110*time.Minute {
+		t.Fatalf("deferred_until: got %v, want when the working hours start", deferred.DeferredUntil)
+	}
+	until := *deferred.DeferredUntil
+
+	// The run is deferred once, then runs when it is due, even if its cron
+	// expression does not match then.
+	tickOnce(t, s, &executed, &mu)
+	db.First(&deferred, "id = ?", "sched-defer")
+	if !deferred.DeferredUntil.Equal(until) {
+		t.Errorf("deferred_until moved: got %v, want %v", deferred.DeferredUntil, until)
+	}
+	db.Model(&models.Team{}).Where("id = ?", "team-defer").Update("working_hours", models.JSON(nil))
+	db.Model(&deferred).Updates(map[string]interface{}{
+		"cron_expression": "0 0 1 1 *",
+		"deferred_until":  time.Now().Add(-time.Minute),
+	})
+	// Due schedules run in parallel, so the deferred run may not be last.
+	mu.Lock()
+	before := len(executed)
+	mu.Unlock()
+	got := tickOnce(t, s, &executed, &mu)
+	if !slices.Contains(got[before:], "sched-defer") {
+		t.Errorf("executed: got %v, want the deferred run", got)
+	}
+	var ran models.Schedule
+	db.First(&ran, "id = ?", "sched-defer")
+	if ran.DeferredUntil != nil {
+		t.Errorf("deferred_until: got %v, want it cleared", ran.DeferredUntil)
+	}
+}
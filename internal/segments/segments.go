@@ -0,0 +1,176 @@
+// Package segments splits the markdown of a leader response into headings,
+// code blocks, file diffs and the text between them, so clients can render
+// rich output without parsing markdown themselves.
+package segments
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Segment types.
+const (
+	TypeText    = "text"
+	TypeHeading = "heading"
+	TypeCode    = "code"
+	TypeDiff    = "diff"
+)
+
+// Segment is one block of a response. Level is set on headings, Language
+// on code blocks that declare one, and Path on diffs of a single file.
+type Segment struct {
+	Type     string `json:"type"`
+	Content  string `json:"content"`
+	Level    int    `json:"level,omitempty"`
+	Language string `json:"language,omitempty"`
+	Path     string `json:"path,omitempty"`
+}
+
+var (
+	headingRe = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	fenceRe   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^ \t`]*)")
+)
+
+// Parse splits markdown into segments in order. Headings and fenced code
+// blocks become their own segments; code blocks holding a unified diff
+// become one diff segment per file. It returns nil when markdown is only
+// text, which needs no segments to render.
+func Parse(markdown string) []Segment {
+	var (
+		segs     []Segment
+		text     []string
+		rich     bool
+		lines    = strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+		flushTxt = func() {
+			if content := strings.Trim(strings.Join(text, "\n"), "\n"); strings.TrimSpace(content) != "" {
+				segs = append(segs, Segment{Type: TypeText, Content: content})
+			}
+			text = text[:0]
+		}
+	)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			flushTxt()
+			fence, lang := m[1], strings.ToLower(m[2])
+			var body []string
+			for i++; i < len(lines); i++ {
+				if closesFence(lines[i], fence) {
+					break
+				}
+				body = append(body, lines[i])
+			}
+			segs = append(segs, codeSegments(strings.Join(body, "\n"), lang)...)
+			rich = true
+			continue
+		}
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			flushTxt()
+			segs = append(segs, Segment{Type: TypeHeading, Content: strings.TrimSpace(m[2]), Level: len(m[1])})
+			rich = true
+			continue
+		}
+		text = append(text, line)
+	}
+	flushTxt()
+
+	if !rich {
+		return nil
+	}
+	return segs
+}
+
+// closesFence reports whether line closes a code block opened with fence:
+// the same character, at least as many times, and nothing else.
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	trimmed = strings.TrimRight(trimmed, " \t")
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// codeSegments returns the segments of a fenced code block: diffs split by
+// file, or else one code segment.
+func codeSegments(body, lang string) []Segment {
+	if lang != "diff" && lang != "patch" && !isUnifiedDiff(body) {
+		return []Segment{{Type: TypeCode, Content: body, Language: lang}}
+	}
+	var segs []Segment
+	for _, file := range splitDiff(body) {
+		segs = append(segs, Segment{Type: TypeDiff, Content: file, Path: diffPath(file)})
+	}
+	return segs
+}
+
+// isUnifiedDiff reports whether body starts like git diff or diff -u
+// output.
+func isUnifiedDiff(body string) bool {
+	if strings.HasPrefix(body, "diff --git ") {
+		return true
+	}
+	first, rest, _ := strings.Cut(body, "\n")
+	return strings.HasPrefix(first, "--- ") && strings.HasPrefix(rest, "+++ ")
+}
+
+// splitDiff splits a diff of several files at each "diff --git" header.
+func splitDiff(body string) []string {
+	var files, current []string
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "diff --git ") && len(current) > 0 {
+			files = append(files, strings.Join(current, "\n"))
+			current = nil
+		}
+		current = append(current, line)
+	}
+	return append(files, strings.Join(current, "\n"))
+}
+
+// diffPath returns the file a single-file diff changes: its new path, or
+// the old one for deleted files.
+func diffPath(diff string) string {
+	var oldPath, newPath string
+	for _, line := range strings.Split(diff, "\n") {
+		// Hunk lines may start with --- or +++ too.
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		if path, ok := strings.CutPrefix(line, "--- "); ok {
+			oldPath = cleanDiffPath(path, "a/")
+		} else if path, ok := strings.CutPrefix(line, "+++ "); ok {
+			newPath = cleanDiffPath(path, "b/")
+		}
+	}
+	if p := firstPath(newPath, oldPath); p != "" {
+		return p
+	}
+	if header, ok := strings.CutPrefix(diff, "diff --git "); ok {
+		header, _, _ = strings.Cut(header, "\n")
+		if _, b, ok := strings.Cut(header, " b/"); ok {
+			return b
+		}
+	}
+	return ""
+}
+
+// cleanDiffPath strips the a/ or b/ prefix and timestamp of a --- or +++
+// path, returning "" for /dev/null.
+func cleanDiffPath(p, prefix string) string {
+	p, _, _ = strings.Cut(p, "\t")
+	p = strings.TrimSpace(p)
+	if p == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(p, prefix)
+}
+
+func firstPath(paths ...string) string {
+	for _, p := range paths {
+		if p != "" {
+			return p
+		}
+	}
+	return ""
+}
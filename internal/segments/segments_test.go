@@ -0,0 +1,93 @@
+package segments
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	markdown := "## Summary\n" +
+		"I fixed the bug.\n" +
+		"\n" +
+		"```go\n" +
+		"func main() {}\n" +
+		"```\n" +
+		"Changes:\n" +
+		"```diff\n" +
+		"diff --git a/main.go b/main.go\n" +
+		"--- a/main.go\n" +
+		"+++ b/main.go\n" +
+		"@@ -1 +1 @@\n" +
+		"-old\n" +
+		"+new\n" +
+		"diff --git a/old.txt b/old.txt\n" +
+		"deleted file mode 100644\n" +
+		"--- a/old.txt\n" +
+		"+++ /dev/null\n" +
+		"@@ -1 +0,0 @@\n" +
+		"--- removed line\n" +
+		"```\n" +
+		"### Next steps ###\n"
+
+	want := []Segment{
+		{Type: TypeHeading, Content: "Summary", Level: 2},
+		{Type: TypeText, Content: "I fixed the bug."},
+		{Type: TypeCode, Content: "func main() {}", Language: "go"},
+		{Type: TypeText, Content: "Changes:"},
+		{Type: TypeDiff, Path: "main.go", Content: "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-old\n+new"},
+		{Type: TypeDiff, Path: "old.txt", Content: "diff --git a/old.txt b/old.txt\ndeleted file mode 100644\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n--- removed line"},
+		{Type: TypeHeading, Content: "Next steps", Level: 3},
+	}
+	if got := Parse(markdown); !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParse_PlainText(t *testing.T) {
+	for _, markdown := range []string{"", "Done. All tests pass.", "#hashtag is not a heading\n\n- item"} {
+		if got := Parse(markdown); got != nil {
+			t.Errorf("Parse(%q): got %+v, want nil", markdown, got)
+		}
+	}
+}
+
+func TestParse_CodeBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []Segment
+	}{
+		{
+			name:     "unclosed fence runs to the end",
+			markdown: "```sh\nmake test",
+			want:     []Segment{{Type: TypeCode, Content: "make test", Language: "sh"}},
+		},
+		{
+			name:     "shorter fence does not close",
+			markdown: "````md\n```\ninner\n```\n````",
+			want:     []Segment{{Type: TypeCode, Content: "```\ninner\n```", Language: "md"}},
+		},
+		{
+			name:     "tilde fence without language",
+			markdown: "~~~\nplain\n~~~",
+			want:     []Segment{{Type: TypeCode, Content: "plain"}},
+		},
+		{
+			name:     "unlabeled unified diff",
+			markdown: "```\n--- a/x.txt\t2024-01-01\n+++ b/x.txt\t2024-01-02\n@@ -1 +1 @@\n-a\n+b\n```",
+			want:     []Segment{{Type: TypeDiff, Path: "x.txt", Content: "--- a/x.txt\t2024-01-01\n+++ b/x.txt\t2024-01-02\n@@ -1 +1 @@\n-a\n+b"}},
+		},
+		{
+			name:     "CRLF line endings",
+			markdown: "# Title\r\n```js\r\nx()\r\n```\r\n",
+			want:     []Segment{{Type: TypeHeading, Content: "Title", Level: 1}, {Type: TypeCode, Content: "x()", Language: "js"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.markdown); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Package smoketest checks an AgentCrew installation end to end: it creates
+// a throwaway team, deploys it, chats with its leader, checks the leader's
+// container validation and removes the team again.
+package smoketest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Steps of a smoke test, in the order they run.
+const (
+	StepCreateTeam     = "create_team"
+	StepDeploy         = "deploy"
+	StepChat           = "chat"
+	StepLeaderResponse = "leader_response"
+	StepValidation     = "validation"
+	StepTeardown       = "teardown"
+)
+
+// DefaultMessage is the chat message sent to the leader.
+const DefaultMessage = "This is an automated smoke test. Reply with the single word OK."
+
+// teardownTimeout bounds the teardown, which runs even when the smoke
+// test's context is done.
+const teardownTimeout = 2 * time.Minute
+
+// Options configure a smoke test.
+type Options struct {
+	// Message is sent to the leader. It defaults to DefaultMessage.
+	Message string
+	// Runtime and AgentImage configure the team; empty keeps the API's
+	// defaults.
+	Runtime    string
+	AgentImage string
+	// Keep leaves the team in place instead of removing it.
+	Keep bool
+	// PollInterval is how often the team and its messages are checked. It
+	// defaults to 2 seconds.
+	PollInterval time.Duration
+}
+
+// Step is the outcome of one step.
+type Step struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Report is the machine-readable result of a smoke test. OK is true when
+// every step succeeded; steps after a failed one are skipped, except the
+// teardown.
+type Report struct {
+	OK         bool                                  `json:"ok"`
+	TeamID     string                                `json:"team_id,omitempty"`
+	TeamName   string                                `json:"team_name"`
+	StartedAt  time.Time                             `json:"started_at"`
+	DurationMs int64                                 `json:"duration_ms"`
+	Steps      []Step                                `json:"steps"`
+	Response   string                                `json:"response,omitempty"`
+	Validation []protocol.ContainerValidationPayload `json:"validation,omitempty"`
+}
+
+// Run runs a smoke test against the API. Cancelling ctx, for example with
+// a deadline, fails the step in progress; the team is still removed.
+func Run(ctx context.Context, api *client.Client, opts Options) *Report {
+	if opts.Message == "" {
+		opts.Message = DefaultMessage
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	r := &runner{api: api, opts: opts, report: &Report{
+		TeamName:  "smoke-" + randomSuffix(),
+		StartedAt: time.Now().UTC(),
+	}}
+
+	var chatID string
+	ok := r.step(StepCreateTeam, func() error { return r.createTeam(ctx) }) &&
+		r.step(StepDeploy, func() error { return r.waitForDeploy(ctx) }) &&
+		r.step(StepChat, func() (err error) { chatID, err = r.chat(ctx); return err }) &&
+		r.step(StepLeaderResponse, func() error { return r.waitForResponse(ctx, chatID) }) &&
+		r.step(StepValidation, func() error { return r.checkValidation(ctx) })
+
+	if r.report.TeamID != "" && !opts.Keep {
+		ok = r.step(StepTeardown, func() error {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), teardownTimeout)
+			defer cancel()
+			return api.DeleteTeam(ctx, r.report.TeamID, client.DeleteTeamOptions{Force: true})
+		}) && ok
+	}
+	r.report.OK = ok
+	r.report.DurationMs = time.Since(r.report.StartedAt).Milliseconds()
+	return r.report
+}
+
+type runner struct {
+	api    *client.Client
+	opts   Options
+	report *Report
+}
+
+// step runs fn and records its outcome.
+func (r *runner) step(name string, fn func() error) bool {
+	start := time.Now()
+	err := fn()
+	s := Step{Name: name, OK: err == nil, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	r.report.Steps = append(r.report.Steps, s)
+	return err == nil
+}
+
+func (r *runner) createTeam(ctx context.Context) error {
+	team, err := r.api.CreateTeam(ctx, client.TeamInput{
+		Name:        r.report.TeamName,
+		Description: "Throwaway team created by the smoke test",
+		Runtime:     r.opts.Runtime,
+		AgentImage:  r.opts.AgentImage,
+	})
+	if err != nil {
+		return err
+	}
+	r.report.TeamID = team.ID
+	_, err = r.api.CreateAgent(ctx, team.ID, client.AgentInput{Name: "leader", Role: models.AgentRoleLeader})
+	return err
+}
+
+// waitForDeploy deploys the team and waits for it to run.
+func (r *runner) waitForDeploy(ctx context.Context) error {
+	if _, err := r.api.DeployTeam(ctx, r.report.TeamID); err != nil {
+		return err
+	}
+	return r.poll(ctx, func() (bool, error) {
+		team, err := r.api.GetTeam(ctx, r.report.TeamID)
+		if err != nil {
+			return false, err
+		}
+		switch team.Status {
+		case models.TeamStatusRunning:
+			return true, nil
+		case models.TeamStatusDeploying:
+			return false, nil
+		default:
+			return false, fmt.Errorf("team is %s: %s", team.Status, team.StatusMessage)
+		}
+	})
+}
+
+// chat sends the message and returns the ID of the stored user message.
+// A message queued until the leader is ready is fine.
+func (r *runner) chat(ctx context.Context) (string, error) {
+	res, err := r.api.SendChat(ctx, r.report.TeamID, r.opts.Message)
+	if err != nil {
+		return "", err
+	}
+	if res.Status != "sent" && res.Status != models.ChatDeliveryQueued {
+		return "", fmt.Errorf("message %s: %s", res.Status, res.Message)
+	}
+	if res.ID == "" {
+		return "", errors.New("the API did not return the message ID")
+	}
+	return res.ID, nil
+}
+
+// waitForResponse waits for the first leader response stored after the
+// user message chatID.
+func (r *runner) waitForResponse(ctx context.Context, chatID string) error {
+	return r.poll(ctx, func() (bool, error) {
+		logs, err := r.api.ListMessages(ctx, r.report.TeamID)
+		if err != nil {
+			return false, err
+		}
+		// Newest first: responses before the user message come after it.
+		var response *models.TaskLog
+		for i := range logs {
+			if logs[i].ID == chatID {
+				break
+			}
+			if logs[i].MessageType == string(protocol.TypeLeaderResponse) {
+				response = &logs[i]
+			}
+		}
+		if response == nil {
+			return false, nil
+		}
+		var payload protocol.LeaderResponsePayload
+		if err := json.Unmarshal(response.Payload, &payload); err != nil {
+			return false, fmt.Errorf("decoding leader response: %w", err)
+		}
+		r.report.Response = payload.Result
+		if payload.Status != "completed" {
+			msg := payload.Error
+			if msg == "" {
+				msg = payload.Result
+			}
+			return false, fmt.Errorf("leader response %s: %s", payload.Status, msg)
+		}
+		return true, nil
+	})
+}
+
+// checkValidation fails if the team reported no container validation, or a
+// check with an error.
+func (r *runner) checkValidation(ctx context.Context) error {
+	logs, err := r.api.ListMessages(ctx, r.report.TeamID, string(protocol.TypeContainerValidation))
+	if err != nil {
+		return err
+	}
+	if len(logs) == 0 {
+		return errors.New("no container validation reported")
+	}
+	var failed []string
+	for _, l := range logs {
+		var v protocol.ContainerValidationPayload
+		if err := json.Unmarshal(l.Payload, &v); err != nil {
+			return fmt.Errorf("decoding container validation: %w", err)
+		}
+		r.report.Validation = append(r.report.Validation, v)
+		for _, check := range v.Checks {
+			if check.Status == protocol.ValidationError {
+				failed = append(failed, fmt.Sprintf("%s: %s: %s", v.AgentName, check.Name, check.Message))
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("validation failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// poll calls check every poll interval until it is done or fails, or ctx
+// is done.
+func (r *runner) poll(ctx context.Context, check func() (bool, error)) error {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
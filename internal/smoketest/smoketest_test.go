@@ -0,0 +1,140 @@
+package smoketest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// fakeAPI serves the endpoints a smoke test calls. Deployments end in
+// deployStatus on the second status check.
+type fakeAPI struct {
+	mu           sync.Mutex
+	deployStatus string
+	validation   protocol.ValidationCheckStatus
+	statusChecks int
+	deleted      bool
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	logEntry := func(id, typ string, payload any) map[string]any {
+		data, _ := json.Marshal(payload)
+		return map[string]any{"id": id, "message_type": typ, "payload": json.RawMessage(data)}
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/teams":
+		w.Write([]byte(`{"id":"t1","status":"stopped"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/teams/t1/agents":
+		w.Write([]byte(`{"id":"a1","role":"leader"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/api/teams/t1/deploy":
+		w.Write([]byte(`{"id":"t1","status":"deploying"}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/api/teams/t1":
+		f.statusChecks++
+		status := "deploying"
+		if f.statusChecks > 1 {
+			status = f.deployStatus
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id": "t1", "status": status, "status_message": "image pull failed"})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/teams/t1/chat":
+		w.Write([]byte(`{"status":"sent","id":"m2"}`))
+	case r.Method == http.MethodGet && r.URL.Path == "/api/teams/t1/messages":
+		var items []map[string]any
+		if r.URL.Query().Get("types") == string(protocol.TypeContainerValidation) {
+			items = append(items, logEntry("v1", "container_validation", protocol.ContainerValidationPayload{
+				AgentName: "leader",
+				Checks:    []protocol.ValidationCheck{{Name: "claude_md", Status: f.validation, Message: "CLAUDE.md"}},
+			}))
+		} else {
+			// Newest first; the old response predates the smoke test's message.
+			items = append(items,
+				logEntry("r2", "leader_response", protocol.LeaderResponsePayload{Status: "completed", Result: "OK"}),
+				logEntry("m2", "user_message", map[string]string{"content": "hi"}),
+				logEntry("r1", "leader_response", protocol.LeaderResponsePayload{Status: "failed", Error: "old"}),
+			)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"items": items})
+	case r.Method == http.MethodDelete && r.URL.Path == "/api/teams/t1":
+		if r.URL.Query().Get("force") != "true" {
+			http.Error(w, `{"error":"team is running"}`, http.StatusConflict)
+			return
+		}
+		f.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, `{"error":"not found"}`, http.StatusNotFound)
+	}
+}
+
+func runSmokeTest(t *testing.T, f *fakeAPI) *Report {
+	t.Helper()
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return Run(ctx, client.New(srv.URL, ""), Options{PollInterval: time.Millisecond})
+}
+
+func stepNames(steps []Step) string {
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestRun_Passes(t *testing.T) {
+	f := &fakeAPI{deployStatus: "running", validation: protocol.ValidationWarning}
+	report := runSmokeTest(t, f)
+
+	if !report.OK {
+		t.Fatalf("report not OK: %+v", report.Steps)
+	}
+	if got := stepNames(report.Steps); got != "create_team,deploy,chat,leader_response,validation,teardown" {
+		t.Errorf("steps: got %s", got)
+	}
+	if report.TeamID != "t1" || report.Response != "OK" || len(report.Validation) != 1 {
+		t.Errorf("report: got %+v", report)
+	}
+	if !strings.HasPrefix(report.TeamName, "smoke-") || !f.deleted {
+		t.Errorf("team %q deleted: %v", report.TeamName, f.deleted)
+	}
+}
+
+func TestRun_FailedDeployStillTearsDown(t *testing.T) {
+	f := &fakeAPI{deployStatus: "error"}
+	report := runSmokeTest(t, f)
+
+	if report.OK {
+		t.Fatal("report OK after a failed deployment")
+	}
+	if got := stepNames(report.Steps); got != "create_team,deploy,teardown" {
+		t.Errorf("steps: got %s", got)
+	}
+	if s := report.Steps[1]; s.OK || !strings.Contains(s.Error, "image pull failed") {
+		t.Errorf("deploy step: got %+v", s)
+	}
+	if !f.deleted {
+		t.Error("team not deleted")
+	}
+}
+
+func TestRun_ValidationError(t *testing.T) {
+	report := runSmokeTest(t, &fakeAPI{deployStatus: "running", validation: protocol.ValidationError})
+
+	if report.OK {
+		t.Fatal("report OK with a failed validation check")
+	}
+	if s := report.Steps[4]; s.Name != StepValidation || s.OK || !strings.Contains(s.Error, "leader: claude_md") {
+		t.Errorf("validation step: got %+v", s)
+	}
+}
@@ -0,0 +1,228 @@
+package tfprovider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+type agentResource struct {
+	client *client.Client
+}
+
+type agentModel struct {
+	ID                     types.String `tfsdk:"id"`
+	TeamID                 types.String `tfsdk:"team_id"`
+	Name                   types.String `tfsdk:"name"`
+	Role                   types.String `tfsdk:"role"`
+	Specialty              types.String `tfsdk:"specialty"`
+	SystemPrompt           types.String `tfsdk:"system_prompt"`
+	InstructionsMD         types.String `tfsdk:"instructions_md"`
+	Skills                 types.String `tfsdk:"skills"`
+	Permissions            types.String `tfsdk:"permissions"`
+	Resources              types.String `tfsdk:"resources"`
+	SubAgentDescription    types.String `tfsdk:"sub_agent_description"`
+	SubAgentInstructions   types.String `tfsdk:"sub_agent_instructions"`
+	SubAgentModel          types.String `tfsdk:"sub_agent_model"`
+	SubAgentSkills         types.String `tfsdk:"sub_agent_skills"`
+	SubAgentBackground     types.Bool   `tfsdk:"sub_agent_background"`
+	SubAgentIsolation      types.String `tfsdk:"sub_agent_isolation"`
+	SubAgentPermissionMode types.String `tfsdk:"sub_agent_permission_mode"`
+	ExtraClaudeArgs        types.String `tfsdk:"extra_claude_args"`
+}
+
+func newAgentResource() resource.Resource { return &agentResource{} }
+
+func (r *agentResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_agent"
+}
+
+func (r *agentResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	emptyString := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{Description: description, Optional: true, Computed: true, Default: stringdefault.StaticString("")}
+	}
+	apiDefault := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{
+			Description:   description,
+			Optional:      true,
+			Computed:      true,
+			PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+		}
+	}
+	jsonString := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{
+			Description:   description + " A JSON document, usually written with jsonencode().",
+			Optional:      true,
+			Computed:      true,
+			PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+		}
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "An agent of a team. Changes apply the next time the team is deployed.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"team_id": schema.StringAttribute{
+				Description:   "ID of the agent's team. Changing it replaces the agent.",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name":                   schema.StringAttribute{Description: "Agent name, unique in the team.", Required: true},
+			"role":                   apiDefault("Role: leader or worker."),
+			"specialty":              emptyString("Short description of what the agent is good at."),
+			"system_prompt":          emptyString("System prompt of the agent."),
+			"instructions_md":        emptyString("Instructions file (CLAUDE.md or AGENTS.md) of the agent."),
+			"skills":                 jsonString("Skills installed for the agent."),
+			"permissions":            jsonString("Permission settings of the agent."),
+			"resources":              jsonString("Container resources of the agent."),
+			"sub_agent_description":  emptyString("When the leader should delegate to this sub-agent."),
+			"sub_agent_instructions": emptyString("Instructions of the sub-agent."),
+			"sub_agent_model":        apiDefault("Model of the sub-agent, or inherit."),
+			"sub_agent_skills":       jsonString("Skills of the sub-agent."),
+			"sub_agent_background": schema.BoolAttribute{
+				Description:   "Whether the sub-agent runs in the background.",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.UseStateForUnknown()},
+			},
+			"sub_agent_isolation":       apiDefault("Isolation of the sub-agent: worktree or none."),
+			"sub_agent_permission_mode": apiDefault("Permission mode of the sub-agent."),
+			"extra_claude_args":         jsonString("JSON list of claude CLI flags added to the leader's invocations, e.g. [\"--max-thinking-tokens\", \"8000\"]."),
+		},
+	}
+}
+
+func (r *agentResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+// ImportState imports an agent by "<team_id>/<agent_id>".
+func (r *agentResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	teamID, agentID, ok := strings.Cut(req.ID, "/")
+	if !ok || teamID == "" || agentID == "" {
+		resp.Diagnostics.AddError("Invalid import ID", fmt.Sprintf("Expected <team_id>/<agent_id>, got %q.", req.ID))
+		return
+	}
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("team_id"), teamID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), agentID)...)
+}
+
+func (r *agentResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan agentModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	agent, err := r.client.CreateAgent(ctx, plan.TeamID.ValueString(), plan.input())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create agent", apiErrorDetail(err))
+		return
+	}
+	plan.read(agent)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *agentResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state agentModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	agent, err := r.client.GetAgent(ctx, state.TeamID.ValueString(), state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read agent", apiErrorDetail(err))
+		return
+	}
+	state.read(agent)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *agentResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan agentModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	agent, err := r.client.UpdateAgent(ctx, plan.TeamID.ValueString(), plan.ID.ValueString(), plan.input())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update agent", apiErrorDetail(err))
+		return
+	}
+	plan.read(agent)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *agentResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state agentModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	err := r.client.DeleteAgent(ctx, state.TeamID.ValueString(), state.ID.ValueString())
+	if err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete agent", apiErrorDetail(err))
+	}
+}
+
+// input returns the API configuration of the planned agent.
+func (m *agentModel) input() client.AgentInput {
+	return client.AgentInput{
+		Name:                   m.Name.ValueString(),
+		Role:                   m.Role.ValueString(),
+		Specialty:              m.Specialty.ValueString(),
+		SystemPrompt:           m.SystemPrompt.ValueString(),
+		InstructionsMD:         m.InstructionsMD.ValueString(),
+		Skills:                 jsonInput(m.Skills),
+		Permissions:            jsonInput(m.Permissions),
+		Resources:              jsonInput(m.Resources),
+		SubAgentDescription:    m.SubAgentDescription.ValueString(),
+		SubAgentInstructions:   m.SubAgentInstructions.ValueString(),
+		SubAgentModel:          m.SubAgentModel.ValueString(),
+		SubAgentSkills:         jsonInput(m.SubAgentSkills),
+		SubAgentBackground:     optionalBool(m.SubAgentBackground),
+		SubAgentIsolation:      m.SubAgentIsolation.ValueString(),
+		SubAgentPermissionMode: m.SubAgentPermissionMode.ValueString(),
+		ExtraClaudeArgs:        jsonInput(m.ExtraClaudeArgs),
+	}
+}
+
+// read sets the model from the agent the API returned.
+func (m *agentModel) read(agent *models.Agent) {
+	m.ID = types.StringValue(agent.ID)
+	m.TeamID = types.StringValue(agent.TeamID)
+	m.Name = types.StringValue(agent.Name)
+	m.Role = types.StringValue(agent.Role)
+	m.Specialty = types.StringValue(agent.Specialty)
+	m.SystemPrompt = types.StringValue(agent.SystemPrompt)
+	m.InstructionsMD = types.StringValue(agent.InstructionsMD)
+	m.Skills = jsonAttr(m.Skills, agent.Skills)
+	m.Permissions = jsonAttr(m.Permissions, agent.Permissions)
+	m.Resources = jsonAttr(m.Resources, agent.Resources)
+	m.SubAgentDescription = types.StringValue(agent.SubAgentDescription)
+	m.SubAgentInstructions = types.StringValue(agent.SubAgentInstructions)
+	m.SubAgentModel = types.StringValue(agent.SubAgentModel)
+	m.SubAgentSkills = jsonAttr(m.SubAgentSkills, agent.SubAgentSkills)
+	m.SubAgentBackground = types.BoolPointerValue(agent.SubAgentBackground)
+	m.SubAgentIsolation = types.StringValue(agent.SubAgentIsolation)
+	m.SubAgentPermissionMode = types.StringValue(agent.SubAgentPermissionMode)
+	m.ExtraClaudeArgs = jsonAttr(m.ExtraClaudeArgs, agent.ExtraClaudeArgs)
+}
@@ -0,0 +1,110 @@
+package tfprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+// apiErrorDetail describes a failed API call in a diagnostic.
+func apiErrorDetail(err error) string {
+	return "The AgentCrew API returned an error: " + err.Error()
+}
+
+// stringMap converts a map attribute to a Go map. A null map is nil.
+func stringMap(ctx context.Context, m types.Map, diags *diag.Diagnostics) map[string]string {
+	if m.IsNull() || m.IsUnknown() {
+		return nil
+	}
+	out := map[string]string{}
+	diags.Append(m.ElementsAs(ctx, &out, false)...)
+	return out
+}
+
+// mapAttr converts a JSON object of strings from the API to a map
+// attribute. An empty object stays null when prior is null, so leaving the
+// attribute out of the configuration does not show a diff.
+func mapAttr(ctx context.Context, prior types.Map, raw models.JSON, diags *diag.Diagnostics) types.Map {
+	var values map[string]string
+	if len(raw) > 0 {
+		json.Unmarshal(raw, &values)
+	}
+	if len(values) == 0 && (prior.IsNull() || prior.IsUnknown()) {
+		return types.MapNull(types.StringType)
+	}
+	if values == nil {
+		values = map[string]string{}
+	}
+	m, d := types.MapValueFrom(ctx, types.StringType, values)
+	diags.Append(d...)
+	return m
+}
+
+// jsonInput returns the JSON document of a string attribute, or nil when
+// it is not set.
+func jsonInput(s types.String) json.RawMessage {
+	if s.IsNull() || s.IsUnknown() || s.ValueString() == "" {
+		return nil
+	}
+	return json.RawMessage(s.ValueString())
+}
+
+// jsonAttr converts a JSON document from the API to a string attribute.
+// The prior value is kept when it is the same document, so formatting in
+// the configuration does not show a diff. Empty documents ({}, [] or null)
+// are null.
+func jsonAttr(prior types.String, raw models.JSON) types.String {
+	var value any
+	if len(raw) == 0 || json.Unmarshal(raw, &value) != nil || isEmptyJSON(value) {
+		return types.StringNull()
+	}
+	if !prior.IsNull() && !prior.IsUnknown() {
+		var priorValue any
+		if json.Unmarshal([]byte(prior.ValueString()), &priorValue) == nil && reflect.DeepEqual(priorValue, value) {
+			return prior
+		}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return types.StringValue(string(raw))
+	}
+	return types.StringValue(compact.String())
+}
+
+func isEmptyJSON(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// optionalBool returns the value of a bool attribute, or nil when it is
+// not known yet.
+func optionalBool(b types.Bool) *bool {
+	if b.IsNull() || b.IsUnknown() {
+		return nil
+	}
+	v := b.ValueBool()
+	return &v
+}
+
+// optionalInt returns the value of a number attribute, or nil when it is
+// not known yet.
+func optionalInt(n types.Int64) *int {
+	if n.IsNull() || n.IsUnknown() {
+		return nil
+	}
+	v := int(n.ValueInt64())
+	return &v
+}
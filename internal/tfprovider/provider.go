@@ -0,0 +1,115 @@
+// Package tfprovider is the Terraform provider for AgentCrew. It manages
+// teams, agents, schedules and webhooks through the API client, so crews
+// can be kept as code next to the rest of the stack.
+package tfprovider
+
+import (
+	"context"
+	"os"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/client"
+)
+
+// Environment variables used when the provider block leaves a setting out.
+const (
+	EnvEndpoint = "AGENTCREW_ENDPOINT"
+	EnvToken    = "AGENTCREW_TOKEN"
+)
+
+type agentCrewProvider struct {
+	version string
+}
+
+type providerModel struct {
+	Endpoint types.String `tfsdk:"endpoint"`
+	Token    types.String `tfsdk:"token"`
+}
+
+// New returns a constructor of the provider, as providerserver.Serve expects.
+func New(version string) func() provider.Provider {
+	return func() provider.Provider {
+		return &agentCrewProvider{version: version}
+	}
+}
+
+func (p *agentCrewProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
+	resp.TypeName = "agentcrew"
+	resp.Version = p.version
+}
+
+func (p *agentCrewProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Manages AgentCrew teams, agents, schedules and webhooks.",
+		Attributes: map[string]schema.Attribute{
+			"endpoint": schema.StringAttribute{
+				Description: "URL of the AgentCrew API, such as http://localhost:8080. Defaults to " + EnvEndpoint + ".",
+				Optional:    true,
+			},
+			"token": schema.StringAttribute{
+				Description: "Bearer token for the API. Defaults to " + EnvToken + ". Not needed with the noop auth provider.",
+				Optional:    true,
+				Sensitive:   true,
+			},
+		},
+	}
+}
+
+func (p *agentCrewProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	var config providerModel
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	endpoint := config.Endpoint.ValueString()
+	if endpoint == "" {
+		endpoint = os.Getenv(EnvEndpoint)
+	}
+	token := config.Token.ValueString()
+	if token == "" {
+		token = os.Getenv(EnvToken)
+	}
+	if endpoint == "" {
+		resp.Diagnostics.AddError("Missing AgentCrew endpoint",
+			"Set endpoint in the provider block or the "+EnvEndpoint+" environment variable.")
+		return
+	}
+
+	c := client.New(endpoint, token)
+	resp.ResourceData = c
+	resp.DataSourceData = c
+}
+
+func (p *agentCrewProvider) Resources(context.Context) []func() resource.Resource {
+	return []func() resource.Resource{
+		newTeamResource,
+		newAgentResource,
+		newScheduleResource,
+		newWebhookResource,
+	}
+}
+
+func (p *agentCrewProvider) DataSources(context.Context) []func() datasource.DataSource {
+	return nil
+}
+
+// configureClient takes the API client the provider configured. It is nil
+// while Terraform validates a configuration, before the provider is
+// configured.
+func configureClient(req resource.ConfigureRequest, resp *resource.ConfigureResponse) *client.Client {
+	if req.ProviderData == nil {
+		return nil
+	}
+	c, ok := req.ProviderData.(*client.Client)
+	if !ok {
+		resp.Diagnostics.AddError("Unexpected provider data", "The provider did not configure an AgentCrew client.")
+		return nil
+	}
+	return c
+}
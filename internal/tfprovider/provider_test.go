@@ -0,0 +1,137 @@
+package tfprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestProvider_Schemas(t *testing.T) {
+	ctx := context.Background()
+	p := New("test")()
+
+	var schemaResp provider.SchemaResponse
+	p.Schema(ctx, provider.SchemaRequest{}, &schemaResp)
+	if diags := schemaResp.Schema.ValidateImplementation(ctx); diags.HasError() {
+		t.Fatalf("provider schema: %v", diags)
+	}
+
+	names := map[string]bool{}
+	for _, newResource := range p.Resources(ctx) {
+		r := newResource()
+		var meta resource.MetadataResponse
+		r.Metadata(ctx, resource.MetadataRequest{ProviderTypeName: "agentcrew"}, &meta)
+		names[meta.TypeName] = true
+
+		var resp resource.SchemaResponse
+		r.Schema(ctx, resource.SchemaRequest{}, &resp)
+		if diags := resp.Schema.ValidateImplementation(ctx); diags.HasError() {
+			t.Errorf("%s schema: %v", meta.TypeName, diags)
+		}
+	}
+	for _, name := range []string{"agentcrew_team", "agentcrew_agent", "agentcrew_schedule", "agentcrew_webhook"} {
+		if !names[name] {
+			t.Errorf("missing resource %s", name)
+		}
+	}
+}
+
+func TestTeamResource_CreateAndRead(t *testing.T) {
+	ctx := context.Background()
+	var created map[string]any
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		team := models.Team{
+			ID: "t1", Name: "platform", Slug: "platform", Runtime: "docker", Provider: "claude",
+			ConfigDirMode: "inline", Labels: models.JSON(`{"env":"prod"}`), Memory: true,
+		}
+		if r.Method == http.MethodPost {
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+		}
+		json.NewEncoder(w).Encode(team)
+	}))
+	defer api.Close()
+
+	r := &teamResource{client: client.New(api.URL, "")}
+	var schemaResp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &schemaResp)
+	s := schemaResp.Schema
+	null := tftypes.NewValue(s.Type().TerraformType(ctx), nil)
+
+	labels, _ := types.MapValueFrom(ctx, types.StringType, map[string]string{"env": "prod"})
+	plan := tfsdk.Plan{Schema: s, Raw: null}
+	if diags := plan.Set(ctx, &teamModel{
+		ID:                types.StringUnknown(),
+		Name:              types.StringValue("platform"),
+		Slug:              types.StringUnknown(),
+		Description:       types.StringValue(""),
+		Runtime:           types.StringUnknown(),
+		Provider:          types.StringUnknown(),
+		ModelProvider:     types.StringValue(""),
+		WorkspacePath:     types.StringValue(""),
+		AgentImage:        types.StringValue(""),
+		ConfigDirMode:     types.StringUnknown(),
+		ResourcePreset:    types.StringValue(""),
+		Labels:            labels,
+		MaxConcurrentRuns: types.Int64Value(0),
+		PlanApproval:      types.BoolValue(false),
+		Memory:            types.BoolValue(true),
+		KnowledgeBase:     types.BoolValue(false),
+		ForceDestroy:      types.BoolValue(true),
+		PreserveWorkspace: types.BoolValue(false),
+	}); diags.HasError() {
+		t.Fatalf("plan: %v", diags)
+	}
+
+	createResp := resource.CreateResponse{State: tfsdk.State{Schema: s, Raw: null}}
+	r.Create(ctx, resource.CreateRequest{Plan: plan}, &createResp)
+	if createResp.Diagnostics.HasError() {
+		t.Fatalf("Create: %v", createResp.Diagnostics)
+	}
+	if created["name"] != "platform" || created["memory"] != true || created["provider"] != nil {
+		t.Errorf("create request: got %v", created)
+	}
+
+	var state teamModel
+	createResp.State.Get(ctx, &state)
+	if state.ID.ValueString() != "t1" || state.Provider.ValueString() != "claude" || !state.ForceDestroy.ValueBool() {
+		t.Errorf("state: got %+v", state)
+	}
+
+	readResp := resource.ReadResponse{State: createResp.State}
+	r.Read(ctx, resource.ReadRequest{State: createResp.State}, &readResp)
+	if readResp.Diagnostics.HasError() {
+		t.Fatalf("Read: %v", readResp.Diagnostics)
+	}
+	var read teamModel
+	readResp.State.Get(ctx, &read)
+	if !read.Labels.Equal(labels) || read.Slug.ValueString() != "platform" {
+		t.Errorf("read state: got %+v", read)
+	}
+}
+
+func TestJSONAttr(t *testing.T) {
+	prior := types.StringValue(`{ "allow": ["Read"] }`)
+	if got := jsonAttr(prior, models.JSON(`{"allow":["Read"]}`)); !got.Equal(prior) {
+		t.Errorf("same document: got %s", got)
+	}
+	if got := jsonAttr(prior, models.JSON(`{"allow": ["Read", "Bash"]}`)); got.ValueString() != `{"allow":["Read","Bash"]}` {
+		t.Errorf("changed document: got %s", got)
+	}
+	for _, empty := range []string{"", "null", "{}", "[]"} {
+		if got := jsonAttr(types.StringNull(), models.JSON(empty)); !got.IsNull() {
+			t.Errorf("%q: got %s, want null", empty, got)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package tfprovider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+type scheduleResource struct {
+	client *client.Client
+}
+
+type scheduleModel struct {
+	ID               types.String `tfsdk:"id"`
+	Name             types.String `tfsdk:"name"`
+	TeamID           types.String `tfsdk:"team_id"`
+	Prompt           types.String `tfsdk:"prompt"`
+	PromptTemplateID types.String `tfsdk:"prompt_template_id"`
+	PromptVariables  types.Map    `tfsdk:"prompt_variables"`
+	CronExpression   types.String `tfsdk:"cron_expression"`
+	Timezone         types.String `tfsdk:"timezone"`
+	Enabled          types.Bool   `tfsdk:"enabled"`
+}
+
+func newScheduleResource() resource.Resource { return &scheduleResource{} }
+
+func (r *scheduleResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_schedule"
+}
+
+func (r *scheduleResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "A prompt sent to a team on a cron schedule.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name":    schema.StringAttribute{Description: "Schedule name.", Required: true},
+			"team_id": schema.StringAttribute{Description: "ID of the team the prompt is sent to.", Required: true},
+			"prompt": schema.StringAttribute{
+				Description: "Prompt sent on each run. With prompt_template_id, it is rendered from the template.",
+				Optional:    true,
+				Computed:    true,
+			},
+			"prompt_template_id": schema.StringAttribute{
+				Description: "ID of a prompt template to render the prompt from on each run.",
+				Optional:    true,
+			},
+			"prompt_variables": schema.MapAttribute{
+				Description: "Values of the template's variables.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"cron_expression": schema.StringAttribute{Description: "Cron expression of the runs.", Required: true},
+			"timezone": schema.StringAttribute{
+				Description:   "IANA time zone of the cron expression. Defaults to UTC.",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"enabled": schema.BoolAttribute{
+				Description: "Whether the schedule runs.",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+		},
+	}
+}
+
+func (r *scheduleResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+func (r *scheduleResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (r *scheduleResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan scheduleModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	in := plan.input(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	schedule, err := r.client.CreateSchedule(ctx, in)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create schedule", apiErrorDetail(err))
+		return
+	}
+	plan.read(ctx, schedule, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *scheduleResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state scheduleModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	schedule, err := r.client.GetSchedule(ctx, state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read schedule", apiErrorDetail(err))
+		return
+	}
+	state.read(ctx, schedule, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *scheduleResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan scheduleModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	in := plan.input(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if in.PromptTemplateID == nil {
+		// Detach a template removed from the configuration.
+		detach := ""
+		in.PromptTemplateID = &detach
+	}
+	schedule, err := r.client.UpdateSchedule(ctx, plan.ID.ValueString(), in)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update schedule", apiErrorDetail(err))
+		return
+	}
+	plan.read(ctx, schedule, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *scheduleResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state scheduleModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.DeleteSchedule(ctx, state.ID.ValueString()); err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete schedule", apiErrorDetail(err))
+	}
+}
+
+// input returns the API configuration of the planned schedule. The prompt
+// is only sent without a template, which renders it otherwise.
+func (m *scheduleModel) input(ctx context.Context, diags *diag.Diagnostics) client.ScheduleInput {
+	in := client.ScheduleInput{
+		Name:            m.Name.ValueString(),
+		TeamID:          m.TeamID.ValueString(),
+		PromptVariables: stringMap(ctx, m.PromptVariables, diags),
+		CronExpression:  m.CronExpression.ValueString(),
+		Timezone:        m.Timezone.ValueString(),
+		Enabled:         optionalBool(m.Enabled),
+	}
+	if templateID := m.PromptTemplateID.ValueString(); templateID != "" {
+		in.PromptTemplateID = &templateID
+	} else {
+		in.Prompt = m.Prompt.ValueString()
+	}
+	return in
+}
+
+// read sets the model from the schedule the API returned.
+func (m *scheduleModel) read(ctx context.Context, schedule *models.Schedule, diags *diag.Diagnostics) {
+	m.ID = types.StringValue(schedule.ID)
+	m.Name = types.StringValue(schedule.Name)
+	m.TeamID = types.StringValue(schedule.TeamID)
+	m.Prompt = types.StringValue(schedule.Prompt)
+	m.PromptTemplateID = types.StringPointerValue(schedule.PromptTemplateID)
+	m.PromptVariables = mapAttr(ctx, m.PromptVariables, schedule.PromptVariables, diags)
+	m.CronExpression = types.StringValue(schedule.CronExpression)
+	m.Timezone = types.StringValue(schedule.Timezone)
+	m.Enabled = types.BoolValue(schedule.Enabled)
+}
@@ -0,0 +1,241 @@
+package tfprovider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+type teamResource struct {
+	client *client.Client
+}
+
+type teamModel struct {
+	ID                types.String `tfsdk:"id"`
+	Name              types.String `tfsdk:"name"`
+	Slug              types.String `tfsdk:"slug"`
+	Description       types.String `tfsdk:"description"`
+	Runtime           types.String `tfsdk:"runtime"`
+	Provider          types.String `tfsdk:"agent_provider"`
+	ModelProvider     types.String `tfsdk:"model_provider"`
+	WorkspacePath     types.String `tfsdk:"workspace_path"`
+	AgentImage        types.String `tfsdk:"agent_image"`
+	ConfigDirMode     types.String `tfsdk:"config_dir_mode"`
+	ResourcePreset    types.String `tfsdk:"resource_preset"`
+	Labels            types.Map    `tfsdk:"labels"`
+	MaxConcurrentRuns types.Int64  `tfsdk:"max_concurrent_runs"`
+	TelemetryLevel    types.String `tfsdk:"telemetry_level"`
+	PlanApproval      types.Bool   `tfsdk:"plan_approval"`
+	Memory            types.Bool   `tfsdk:"memory"`
+	KnowledgeBase     types.Bool   `tfsdk:"knowledge_base"`
+	ForceDestroy      types.Bool   `tfsdk:"force_destroy"`
+	PreserveWorkspace types.Bool   `tfsdk:"preserve_workspace"`
+}
+
+func newTeamResource() resource.Resource { return &teamResource{} }
+
+func (r *teamResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_team"
+}
+
+func (r *teamResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	emptyString := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{Description: description, Optional: true, Computed: true, Default: stringdefault.StaticString("")}
+	}
+	apiDefault := func(description string) schema.StringAttribute {
+		return schema.StringAttribute{
+			Description:   description,
+			Optional:      true,
+			Computed:      true,
+			PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+		}
+	}
+	disabled := func(description string) schema.BoolAttribute {
+		return schema.BoolAttribute{Description: description, Optional: true, Computed: true, Default: booldefault.StaticBool(false)}
+	}
+
+	runtime := apiDefault("Container runtime: docker or kubernetes. Changing it replaces the team.")
+	runtime.PlanModifiers = append(runtime.PlanModifiers, stringplanmodifier.RequiresReplace())
+
+	resp.Schema = schema.Schema{
+		Description: "A team of agents. Teams are created stopped; deploy them from the API or the UI.",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name":            schema.StringAttribute{Description: "Team name, unique in the organization.", Required: true},
+			"slug":            schema.StringAttribute{Description: "Name used for the team's infrastructure.", Computed: true},
+			"description":     emptyString("Description of the team."),
+			"runtime":         runtime,
+			"agent_provider":  apiDefault("Agent provider: claude or opencode."),
+			"model_provider":  emptyString("Model provider of opencode teams: anthropic, openai, google or ollama."),
+			"workspace_path":  emptyString("Host path mounted as the team's workspace."),
+			"agent_image":     emptyString("Agent container image. Empty uses the provider's default image."),
+			"config_dir_mode": apiDefault("Where agent configuration lives in the workspace: inline, gitignore, separate or shared."),
+			"resource_preset": emptyString("Name of the resource preset applied to the agents."),
+			"labels": schema.MapAttribute{
+				Description: "Labels used to group the team's usage in cost reports.",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"max_concurrent_runs": schema.Int64Attribute{
+				Description: "Runs the leader takes at a time. 0 uses the default.",
+				Optional:    true,
+				Computed:    true,
+				Default:     int64default.StaticInt64(0),
+			},
+			"telemetry_level":    emptyString("Activity events the leader publishes: minimal, normal or verbose. Empty uses normal."),
+			"plan_approval":      disabled("Whether chat runs wait for the user to approve the leader's plan."),
+			"memory":             disabled("Whether the leader keeps a summary of its context between deployments."),
+			"knowledge_base":     disabled("Whether completed run results are indexed in the team's knowledge base."),
+			"force_destroy":      disabled("Stop the team when destroying it while it runs, instead of failing."),
+			"preserve_workspace": disabled("Keep the team's workspace when destroying it."),
+		},
+	}
+}
+
+func (r *teamResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+func (r *teamResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (r *teamResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan teamModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	in := plan.input(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	team, err := r.client.CreateTeam(ctx, in)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create team", apiErrorDetail(err))
+		return
+	}
+	plan.read(ctx, team, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *teamResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state teamModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	team, err := r.client.GetTeam(ctx, state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read team", apiErrorDetail(err))
+		return
+	}
+	state.read(ctx, team, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *teamResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan teamModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	in := plan.input(ctx, &resp.Diagnostics)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if in.Labels == nil {
+		// An empty object removes labels left out of the configuration.
+		in.Labels = map[string]string{}
+	}
+	team, err := r.client.UpdateTeam(ctx, plan.ID.ValueString(), in)
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update team", apiErrorDetail(err))
+		return
+	}
+	plan.read(ctx, team, &resp.Diagnostics)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *teamResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state teamModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	err := r.client.DeleteTeam(ctx, state.ID.ValueString(), client.DeleteTeamOptions{
+		Force:             state.ForceDestroy.ValueBool(),
+		PreserveWorkspace: state.PreserveWorkspace.ValueBool(),
+	})
+	if err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete team", apiErrorDetail(err))
+	}
+}
+
+// input returns the API configuration of the planned team.
+func (m *teamModel) input(ctx context.Context, diags *diag.Diagnostics) client.TeamInput {
+	return client.TeamInput{
+		Name:              m.Name.ValueString(),
+		Description:       m.Description.ValueString(),
+		Runtime:           m.Runtime.ValueString(),
+		Provider:          m.Provider.ValueString(),
+		ModelProvider:     m.ModelProvider.ValueString(),
+		WorkspacePath:     m.WorkspacePath.ValueString(),
+		AgentImage:        m.AgentImage.ValueString(),
+		ConfigDirMode:     m.ConfigDirMode.ValueString(),
+		ResourcePreset:    m.ResourcePreset.ValueString(),
+		Labels:            stringMap(ctx, m.Labels, diags),
+		MaxConcurrentRuns: int(m.MaxConcurrentRuns.ValueInt64()),
+		TelemetryLevel:    m.TelemetryLevel.ValueString(),
+		PlanApproval:      m.PlanApproval.ValueBool(),
+		Memory:            m.Memory.ValueBool(),
+		KnowledgeBase:     m.KnowledgeBase.ValueBool(),
+	}
+}
+
+// read sets the model from the team the API returned. The destroy options
+// are only kept in the state.
+func (m *teamModel) read(ctx context.Context, team *models.Team, diags *diag.Diagnostics) {
+	m.ID = types.StringValue(team.ID)
+	m.Name = types.StringValue(team.Name)
+	m.Slug = types.StringValue(team.Slug)
+	m.Description = types.StringValue(team.Description)
+	m.Runtime = types.StringValue(team.Runtime)
+	m.Provider = types.StringValue(team.Provider)
+	m.ModelProvider = types.StringValue(team.ModelProvider)
+	m.WorkspacePath = types.StringValue(team.WorkspacePath)
+	m.AgentImage = types.StringValue(team.AgentImage)
+	m.ConfigDirMode = types.StringValue(team.ConfigDirMode)
+	m.ResourcePreset = types.StringValue(team.ResourcePreset)
+	m.Labels = mapAttr(ctx, m.Labels, team.Labels, diags)
+	m.MaxConcurrentRuns = types.Int64Value(int64(team.MaxConcurrentRuns))
+	m.TelemetryLevel = types.StringValue(team.TelemetryLevel)
+	m.PlanApproval = types.BoolValue(team.PlanApproval)
+	m.Memory = types.BoolValue(team.Memory)
+	m.KnowledgeBase = types.BoolValue(team.KnowledgeBase)
+	if m.ForceDestroy.IsNull() || m.ForceDestroy.IsUnknown() {
+		m.ForceDestroy = types.BoolValue(false)
+	}
+	if m.PreserveWorkspace.IsNull() || m.PreserveWorkspace.IsUnknown() {
+		m.PreserveWorkspace = types.BoolValue(false)
+	}
+}
@@ -0,0 +1,185 @@
+package tfprovider
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+
+	"github.com/helmcode/agent-crew/internal/client"
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+type webhookResource struct {
+	client *client.Client
+}
+
+type webhookModel struct {
+	ID             types.String `tfsdk:"id"`
+	Name           types.String `tfsdk:"name"`
+	TeamID         types.String `tfsdk:"team_id"`
+	PromptTemplate types.String `tfsdk:"prompt_template"`
+	TimeoutSeconds types.Int64  `tfsdk:"timeout_seconds"`
+	MaxConcurrent  types.Int64  `tfsdk:"max_concurrent"`
+	Enabled        types.Bool   `tfsdk:"enabled"`
+	Token          types.String `tfsdk:"token"`
+	SecretPrefix   types.String `tfsdk:"secret_prefix"`
+}
+
+func newWebhookResource() resource.Resource { return &webhookResource{} }
+
+func (r *webhookResource) Metadata(_ context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_webhook"
+}
+
+func (r *webhookResource) Schema(_ context.Context, _ resource.SchemaRequest, resp *resource.SchemaResponse) {
+	keep := []planmodifier.String{stringplanmodifier.UseStateForUnknown()}
+	resp.Schema = schema.Schema{
+		Description: "A URL that sends a prompt to a team when it is called.",
+		Attributes: map[string]schema.Attribute{
+			"id":   schema.StringAttribute{Computed: true, PlanModifiers: keep},
+			"name": schema.StringAttribute{Description: "Webhook name.", Required: true},
+			"team_id": schema.StringAttribute{
+				Description:   "ID of the team the prompt is sent to. Changing it replaces the webhook.",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"prompt_template": schema.StringAttribute{
+				Description: "Prompt sent on each call, with {{variable}} placeholders filled from the call's variables.",
+				Required:    true,
+			},
+			"timeout_seconds": schema.Int64Attribute{
+				Description:   "How long a call waits for the team's response.",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()},
+			},
+			"max_concurrent": schema.Int64Attribute{
+				Description:   "Calls of the webhook that can run at a time.",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Int64{int64planmodifier.UseStateForUnknown()},
+			},
+			"enabled": schema.BoolAttribute{
+				Description: "Whether calls are accepted.",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"token": schema.StringAttribute{
+				Description:   "Secret token of the trigger URL. The API only returns it on create, so it is empty for imported webhooks.",
+				Computed:      true,
+				Sensitive:     true,
+				PlanModifiers: keep,
+			},
+			"secret_prefix": schema.StringAttribute{
+				Description:   "First characters of the token, to tell tokens apart.",
+				Computed:      true,
+				PlanModifiers: keep,
+			},
+		},
+	}
+}
+
+func (r *webhookResource) Configure(_ context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.client = configureClient(req, resp)
+}
+
+func (r *webhookResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
+func (r *webhookResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var plan webhookModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	created, err := r.client.CreateWebhook(ctx, plan.input())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to create webhook", apiErrorDetail(err))
+		return
+	}
+	plan.read(&created.Webhook)
+	plan.Token = types.StringValue(created.Token)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *webhookResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var state webhookModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	webhook, err := r.client.GetWebhook(ctx, state.ID.ValueString())
+	if client.IsNotFound(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to read webhook", apiErrorDetail(err))
+		return
+	}
+	state.read(webhook)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &state)...)
+}
+
+func (r *webhookResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var plan webhookModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &plan)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	webhook, err := r.client.UpdateWebhook(ctx, plan.ID.ValueString(), plan.input())
+	if err != nil {
+		resp.Diagnostics.AddError("Failed to update webhook", apiErrorDetail(err))
+		return
+	}
+	plan.read(webhook)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &plan)...)
+}
+
+func (r *webhookResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var state webhookModel
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	if err := r.client.DeleteWebhook(ctx, state.ID.ValueString()); err != nil && !client.IsNotFound(err) {
+		resp.Diagnostics.AddError("Failed to delete webhook", apiErrorDetail(err))
+	}
+}
+
+// input returns the API configuration of the planned webhook.
+func (m *webhookModel) input() client.WebhookInput {
+	return client.WebhookInput{
+		Name:           m.Name.ValueString(),
+		TeamID:         m.TeamID.ValueString(),
+		PromptTemplate: m.PromptTemplate.ValueString(),
+		TimeoutSeconds: optionalInt(m.TimeoutSeconds),
+		MaxConcurrent:  optionalInt(m.MaxConcurrent),
+		Enabled:        optionalBool(m.Enabled),
+	}
+}
+
+// read sets the model from the webhook the API returned. The token is
+// only kept in the state.
+func (m *webhookModel) read(webhook *models.Webhook) {
+	m.ID = types.StringValue(webhook.ID)
+	m.Name = types.StringValue(webhook.Name)
+	m.TeamID = types.StringValue(webhook.TeamID)
+	m.PromptTemplate = types.StringValue(webhook.PromptTemplate)
+	m.TimeoutSeconds = types.Int64Value(int64(webhook.TimeoutSeconds))
+	m.MaxConcurrent = types.Int64Value(int64(webhook.MaxConcurrent))
+	m.Enabled = types.BoolValue(webhook.Enabled)
+	m.SecretPrefix = types.StringValue(webhook.SecretPrefix)
+	if m.Token.IsNull() || m.Token.IsUnknown() {
+		m.Token = types.StringValue("")
+	}
+}
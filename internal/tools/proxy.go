@@ -0,0 +1,177 @@
+// Package tools proxies custom tool invocations from agents to the HTTP
+// endpoints registered by operators, applying each tool's auth, timeout and
+// rate limit.
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/postaction"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// MaxResultBytes caps the response body returned to the agent.
+const MaxResultBytes = 64 * 1024
+
+// DefaultRateLimitPerMinute applies to tools saved without a rate limit.
+const DefaultRateLimitPerMinute = 60
+
+// maxTimeoutSeconds caps the per-call timeout as defense-in-depth (the API
+// validates 1-300).
+const maxTimeoutSeconds = 300
+
+// Proxy invokes custom tools. It keeps one token bucket per tool, refilled
+// at the tool's rate_limit_per_minute with a burst of the same size.
+type Proxy struct {
+	Client *http.Client
+
+	mu       sync.Mutex
+	limiters map[string]*toolLimiter
+}
+
+type toolLimiter struct {
+	perMinute int
+	limiter   *rate.Limiter
+}
+
+// NewProxy creates a Proxy. Timeouts are applied per call from the tool's
+// timeout_seconds.
+func NewProxy() *Proxy {
+	return &Proxy{
+		Client:   &http.Client{},
+		limiters: make(map[string]*toolLimiter),
+	}
+}
+
+// Invoke calls tool with the agent's JSON arguments. Failures are reported
+// in the result rather than as an error so they reach the agent.
+func (p *Proxy) Invoke(ctx context.Context, tool models.CustomTool, args json.RawMessage) protocol.ToolResultPayload {
+	result := protocol.ToolResultPayload{Tool: tool.Name}
+	if !p.allow(tool) {
+		result.IsError = true
+		result.Result = fmt.Sprintf("rate limit exceeded: %s allows %d calls per minute", tool.Name, perMinute(tool))
+		return result
+	}
+
+	req, err := buildRequest(ctx, tool, args)
+	if err != nil {
+		result.IsError = true
+		result.Result = err.Error()
+		return result
+	}
+
+	timeout := tool.TimeoutSeconds
+	if timeout <= 0 || timeout > maxTimeoutSeconds {
+		timeout = 30
+	}
+	reqCtx, cancel := context.WithTimeout(req.Context(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	resp, err := p.Client.Do(req.WithContext(reqCtx))
+	if err != nil {
+		result.IsError = true
+		result.Result = fmt.Sprintf("calling %s: %v", tool.Name, err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, MaxResultBytes+1))
+	if len(body) > MaxResultBytes {
+		body = append(body[:MaxResultBytes], "\n[truncated]"...)
+	}
+	result.StatusCode = resp.StatusCode
+	result.Result = string(body)
+	result.IsError = resp.StatusCode >= 400
+	return result
+}
+
+// allow reports whether tool may be called now. A limiter is rebuilt when
+// the tool's rate limit has been changed since it was created.
+func (p *Proxy) allow(tool models.CustomTool) bool {
+	n := perMinute(tool)
+	p.mu.Lock()
+	l, ok := p.limiters[tool.ID]
+	if !ok || l.perMinute != n {
+		l = &toolLimiter{perMinute: n, limiter: rate.NewLimiter(rate.Every(time.Minute/time.Duration(n)), n)}
+		p.limiters[tool.ID] = l
+	}
+	p.mu.Unlock()
+	return l.limiter.Allow()
+}
+
+func perMinute(tool models.CustomTool) int {
+	if tool.RateLimitPerMinute <= 0 {
+		return DefaultRateLimitPerMinute
+	}
+	return tool.RateLimitPerMinute
+}
+
+// buildRequest turns the agent's arguments into an HTTP request. GET and
+// DELETE send them as query parameters; other methods as a JSON body.
+func buildRequest(ctx context.Context, tool models.CustomTool, args json.RawMessage) (*http.Request, error) {
+	var params map[string]interface{}
+	if len(args) > 0 && string(args) != "null" {
+		if err := json.Unmarshal(args, &params); err != nil {
+			return nil, fmt.Errorf("arguments must be a JSON object: %w", err)
+		}
+	}
+
+	target := tool.URL
+	var body io.Reader
+	switch tool.Method {
+	case http.MethodGet, http.MethodDelete:
+		u, err := url.Parse(tool.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tool url: %w", err)
+		}
+		q := u.Query()
+		for k, v := range params {
+			q.Set(k, queryValue(v))
+		}
+		u.RawQuery = q.Encode()
+		target = u.String()
+	default:
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("encoding arguments: %w", err)
+		}
+		if params == nil {
+			data = []byte("{}")
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, tool.Method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := postaction.ApplyAuth(req, tool.AuthType, tool.AuthConfig); err != nil {
+		return nil, fmt.Errorf("applying auth: %w", err)
+	}
+	return req, nil
+}
+
+// queryValue formats an argument for a query string: strings as-is, other
+// values as JSON.
+func queryValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/helmcode/agent-crew/internal/models"
+)
+
+func TestInvoke_GetSendsQueryAndAuth(t *testing.T) {
+	var gotQuery, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		gotAuth = r.Header.Get("Authorization")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	tool := models.CustomTool{
+		ID:         "t1",
+		Name:       "lookup",
+		Method:     http.MethodGet,
+		URL:        srv.URL + "/search?fixed=1",
+		AuthType:   models.PostActionAuthBearer,
+		AuthConfig: models.JSON(`{"token":"secret"}`),
+	}
+	res := NewProxy().Invoke(context.Background(), tool, json.RawMessage(`{"q":"disk full","limit":5}`))
+	if res.IsError {
+		t.Fatalf("unexpected error result: %+v", res)
+	}
+	if res.StatusCode != 200 || res.Result != `{"ok":true}` {
+		t.Errorf("result = %+v", res)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	for _, want := range []string{"fixed=1", "q=disk+full", "limit=5"} {
+		if !strings.Contains(gotQuery, want) {
+			t.Errorf("query %q missing %q", gotQuery, want)
+		}
+	}
+}
+
+func TestInvoke_PostSendsJSONBody(t *testing.T) {
+	var gotBody, gotType string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		gotType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("bad ticket"))
+	}))
+	defer srv.Close()
+
+	tool := models.CustomTool{ID: "t2", Name: "create_ticket", Method: http.MethodPost, URL: srv.URL}
+	res := NewProxy().Invoke(context.Background(), tool, json.RawMessage(`{"title":"x"}`))
+	if gotBody != `{"title":"x"}` || gotType != "application/json" {
+		t.Errorf("body = %q, content-type = %q", gotBody, gotType)
+	}
+	if !res.IsError || res.StatusCode != 400 || res.Result != "bad ticket" {
+		t.Errorf("result = %+v, want HTTP 400 error", res)
+	}
+}
+
+func TestInvoke_RejectsNonObjectArguments(t *testing.T) {
+	tool := models.CustomTool{ID: "t3", Name: "x", Method: http.MethodPost, URL: "http://127.0.0.1:1"}
+	res := NewProxy().Invoke(context.Background(), tool, json.RawMessage(`[1,2]`))
+	if !res.IsError || !strings.Contains(res.Result, "JSON object") {
+		t.Errorf("result = %+v", res)
+	}
+}
+
+func TestInvoke_RateLimit(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer srv.Close()
+
+	p := NewProxy()
+	tool := models.CustomTool{ID: "t4", Name: "limited", Method: http.MethodGet, URL: srv.URL, RateLimitPerMinute: 2}
+	for i := 0; i < 2; i++ {
+		if res := p.Invoke(context.Background(), tool, nil); res.IsError {
+			t.Fatalf("call %d: unexpected error %+v", i, res)
+		}
+	}
+	res := p.Invoke(context.Background(), tool, nil)
+	if !res.IsError || !strings.Contains(res.Result, "rate limit") {
+		t.Errorf("third call = %+v, want rate limit error", res)
+	}
+	if calls != 2 {
+		t.Errorf("endpoint called %d times, want 2", calls)
+	}
+
+	// Raising the limit takes effect immediately.
+	tool.RateLimitPerMinute = 10
+	if res := p.Invoke(context.Background(), tool, nil); res.IsError {
+		t.Errorf("after raising limit: %+v", res)
+	}
+}
+
+func TestInvoke_TruncatesLargeResponses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", MaxResultBytes+100)))
+	}))
+	defer srv.Close()
+
+	tool := models.CustomTool{ID: "t5", Name: "big", Method: http.MethodGet, URL: srv.URL}
+	res := NewProxy().Invoke(context.Background(), tool, nil)
+	if !strings.HasSuffix(res.Result, "[truncated]") || len(res.Result) > MaxResultBytes+20 {
+		t.Errorf("result length %d not truncated", len(res.Result))
+	}
+}
@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+
+	"github.com/helmcode/agent-crew/internal/models"
+	"github.com/helmcode/agent-crew/internal/protocol"
+)
+
+// Specs returns the organization's enabled tools as described to agents,
+// ordered by name.
+func Specs(db *gorm.DB, orgID string) ([]protocol.CustomToolSpec, error) {
+	var rows []models.CustomTool
+	if err := db.Where("org_id = ? AND enabled = ?", orgID, true).Order("name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	specs := make([]protocol.CustomToolSpec, 0, len(rows))
+	for _, t := range rows {
+		spec := protocol.CustomToolSpec{Name: t.Name, Description: t.Description}
+		if len(t.InputSchema) > 0 && string(t.InputSchema) != "null" {
+			spec.InputSchema = json.RawMessage(t.InputSchema)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
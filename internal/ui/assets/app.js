@@ -0,0 +1,257 @@
+// AgentCrew dashboard: lists the organization's teams and shows the chat and
+// activity stream of the selected one. It only uses the public REST and
+// WebSocket API, with the tokens of /api/auth/login kept for the session.
+'use strict';
+
+const $ = (id) => document.getElementById(id);
+
+const state = {
+  auth: 'noop',
+  accessToken: sessionStorage.getItem('agentcrew.access_token') || '',
+  refreshToken: sessionStorage.getItem('agentcrew.refresh_token') || '',
+  team: null,
+  socket: null,
+  seen: new Set(),
+};
+
+function saveTokens(access, refresh) {
+  state.accessToken = access || '';
+  state.refreshToken = refresh || '';
+  sessionStorage.setItem('agentcrew.access_token', state.accessToken);
+  sessionStorage.setItem('agentcrew.refresh_token', state.refreshToken);
+}
+
+// api calls the API, refreshing the access token once if it expired.
+async function api(method, path, body, retried) {
+  const headers = { 'Content-Type': 'application/json' };
+  if (state.accessToken) headers.Authorization = 'Bearer ' + state.accessToken;
+  const resp = await fetch('/api' + path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+  });
+  if (resp.status === 401 && state.auth !== 'noop' && !retried && state.refreshToken) {
+    const refreshed = await fetch('/api/auth/refresh', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ refresh_token: state.refreshToken }),
+    });
+    if (refreshed.ok) {
+      const tokens = await refreshed.json();
+      saveTokens(tokens.access_token, tokens.refresh_token);
+      return api(method, path, body, true);
+    }
+  }
+  if (resp.status === 401 && state.auth !== 'noop') {
+    saveTokens('', '');
+    showLogin();
+    throw new Error('signed out');
+  }
+  const data = resp.status === 204 ? null : await resp.json().catch(() => null);
+  if (!resp.ok) {
+    throw new Error((data && data.error) || resp.statusText);
+  }
+  return data;
+}
+
+function show(id, visible) {
+  $(id).hidden = !visible;
+}
+
+function showLogin() {
+  closeSocket();
+  show('dashboard', false);
+  show('logout', false);
+  show('login', true);
+}
+
+async function showDashboard() {
+  show('login', false);
+  show('dashboard', true);
+  show('logout', state.auth !== 'noop');
+  try {
+    const me = await api('GET', '/auth/me');
+    $('user').textContent = (me.user && (me.user.name || me.user.email)) || '';
+  } catch (e) {
+    // The name is cosmetic.
+  }
+  await loadTeams();
+}
+
+async function loadTeams() {
+  const page = await api('GET', '/teams?limit=500');
+  const list = $('teams');
+  list.replaceChildren();
+  for (const team of page.items || []) {
+    const li = document.createElement('li');
+    li.dataset.id = team.id;
+    if (state.team && state.team.id === team.id) li.classList.add('selected');
+    const name = document.createElement('span');
+    name.textContent = team.name;
+    const status = document.createElement('span');
+    status.className = 'status ' + team.status;
+    status.textContent = team.status;
+    li.append(name, status);
+    li.addEventListener('click', () => selectTeam(team));
+    list.append(li);
+  }
+}
+
+async function selectTeam(team) {
+  state.team = team;
+  state.seen.clear();
+  for (const li of $('teams').children) {
+    li.classList.toggle('selected', li.dataset.id === team.id);
+  }
+  $('team-name').textContent = team.name;
+  $('team-status').textContent = team.status_message || team.status;
+  $('chat-error').textContent = '';
+  $('messages').replaceChildren();
+  $('activity').replaceChildren();
+  show('chat-form', true);
+
+  const [messages, activity] = await Promise.all([
+    api('GET', '/teams/' + team.id + '/messages?limit=100'),
+    api('GET', '/teams/' + team.id + '/activity?limit=50'),
+  ]);
+  // Both lists are newest first.
+  for (const log of (messages.items || []).reverse()) addLog(log);
+  for (const log of (activity.items || []).reverse()) addLog(log);
+  openSocket(team);
+}
+
+function closeSocket() {
+  if (state.socket) {
+    state.socket.onclose = null;
+    state.socket.close();
+    state.socket = null;
+  }
+}
+
+// openSocket streams the team's new task logs, reconnecting after drops.
+function openSocket(team) {
+  closeSocket();
+  const scheme = location.protocol === 'https:' ? 'wss:' : 'ws:';
+  let url = scheme + '//' + location.host + '/ws/teams/' + team.id + '/activity';
+  if (state.auth !== 'noop') url += '?token=' + encodeURIComponent(state.accessToken);
+  const socket = new WebSocket(url);
+  socket.onmessage = (event) => {
+    try {
+      addLog(JSON.parse(event.data));
+    } catch (e) {
+      // Ignore frames that are not task logs.
+    }
+  };
+  socket.onclose = () => {
+    if (state.team && state.team.id === team.id) {
+      setTimeout(() => state.team && state.team.id === team.id && openSocket(team), 3000);
+    }
+  };
+  state.socket = socket;
+}
+
+function payloadOf(log) {
+  if (typeof log.payload === 'string') {
+    try {
+      return JSON.parse(log.payload);
+    } catch (e) {
+      return { content: log.payload };
+    }
+  }
+  return log.payload || {};
+}
+
+function timeOf(log) {
+  const time = document.createElement('time');
+  time.dateTime = log.created_at;
+  time.textContent = new Date(log.created_at).toLocaleString();
+  return time;
+}
+
+// addLog shows a task log in the chat or the activity stream, once.
+function addLog(log) {
+  if (!log || !log.id || state.seen.has(log.id) || !state.team || log.team_id !== state.team.id) return;
+  state.seen.add(log.id);
+  const payload = payloadOf(log);
+  const li = document.createElement('li');
+
+  if (log.message_type === 'user_message' || log.message_type === 'leader_response') {
+    const text = document.createElement('div');
+    if (log.message_type === 'user_message') {
+      li.className = 'user';
+      text.textContent = payload.content || '';
+    } else {
+      if (payload.status === 'failed') li.className = 'failed';
+      text.textContent = payload.result || payload.error || payload.status || '';
+    }
+    li.append(timeOf(log), text);
+    $('messages').append(li);
+    li.scrollIntoView({ block: 'end' });
+    return;
+  }
+
+  const what = payload.action || payload.tool_name || payload.event_type || log.message_type;
+  const text = document.createElement('div');
+  text.textContent = (log.from_agent ? log.from_agent + ': ' : '') + what;
+  li.append(timeOf(log), text);
+  const activity = $('activity');
+  activity.prepend(li);
+  while (activity.children.length > 200) activity.lastChild.remove();
+}
+
+$('chat-form').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  const input = $('message');
+  const button = event.submitter || $('chat-form').querySelector('button');
+  if (!state.team || !input.value.trim()) return;
+  button.disabled = true;
+  $('chat-error').textContent = '';
+  try {
+    await api('POST', '/teams/' + state.team.id + '/chat', { message: input.value, queue: true });
+    input.value = '';
+  } catch (e) {
+    $('chat-error').textContent = e.message;
+  } finally {
+    button.disabled = false;
+  }
+});
+
+$('login-form').addEventListener('submit', async (event) => {
+  event.preventDefault();
+  $('login-error').textContent = '';
+  const resp = await fetch('/api/auth/login', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ email: $('email').value, password: $('password').value }),
+  });
+  const data = await resp.json().catch(() => null);
+  if (!resp.ok) {
+    $('login-error').textContent = (data && data.error) || 'Sign in failed';
+    return;
+  }
+  saveTokens(data.access_token, data.refresh_token);
+  $('password').value = '';
+  showDashboard();
+});
+
+$('logout').addEventListener('click', () => {
+  saveTokens('', '');
+  state.team = null;
+  $('user').textContent = '';
+  showLogin();
+});
+
+// Team statuses change as teams deploy and stop.
+setInterval(() => {
+  if (!$('dashboard').hidden) loadTeams().catch(() => {});
+}, 10000);
+
+(async function start() {
+  const config = await fetch('/api/auth/config').then((r) => r.json());
+  state.auth = config.provider;
+  if (state.auth !== 'noop' && !state.accessToken) {
+    showLogin();
+    return;
+  }
+  showDashboard();
+})();
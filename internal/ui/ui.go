@@ -0,0 +1,21 @@
+// Package ui holds the web dashboard the API serves under /ui: a list of
+// teams, their chat and their activity stream. It talks to the API like any
+// other client, so it needs no server-side state.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed assets
+var assets embed.FS
+
+// Assets returns the dashboard's files, rooted at index.html.
+func Assets() fs.FS {
+	sub, err := fs.Sub(assets, "assets")
+	if err != nil {
+		panic(err) // the directory is embedded above
+	}
+	return sub
+}
@@ -0,0 +1,200 @@
+// Package workhours decides whether a time falls within a team's working
+// hours, for crews whose runs need someone around to approve them. The
+// scheduler skips or defers runs outside the window, and the API can
+// suspend teams left running outside it.
+package workhours
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Actions taken on a scheduled run that falls outside the working hours.
+const (
+	// ActionSkip drops the run.
+	ActionSkip = "skip"
+	// ActionDefer runs it when the working hours start. Runs deferred to
+	// the same start are run once.
+	ActionDefer = "defer"
+	// ActionRun runs it anyway. Only schedules can override their team's
+	// policy with it.
+	ActionRun = "run"
+)
+
+// weekdays maps the day names of Policy.Days to time.Weekday.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// defaultDays are the working days when Policy.Days is empty.
+var defaultDays = []string{"mon", "tue", "wed", "thu", "fri"}
+
+// Policy is a team's working-hours window.
+type Policy struct {
+	// Timezone is the IANA time zone the window is in; empty means UTC.
+	Timezone string `json:"timezone"`
+	// Days lists the days the window opens on: "mon" to "sun". Empty
+	// means Monday to Friday.
+	Days []string `json:"days"`
+	// Start and End are the "HH:MM" times the window opens and closes. An
+	// End before Start closes it the next day; "24:00" is midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Outside is what happens to scheduled runs outside the window:
+	// ActionSkip, the default, or ActionDefer.
+	Outside string `json:"outside"`
+	// AutoSuspend stops the team when it is left running outside the
+	// window.
+	AutoSuspend bool `json:"auto_suspend"`
+}
+
+// Parse decodes a stored policy. It returns nil for an empty one.
+func Parse(data []byte) (*Policy, error) {
+	s := strings.TrimSpace(string(data))
+	if s == "" || s == "null" {
+		return nil, nil
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("decoding working hours: %w", err)
+	}
+	if p.IsZero() {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// IsZero reports whether the policy sets no window, which removes it.
+func (p Policy) IsZero() bool {
+	return p.Start == "" && p.End == "" && p.Timezone == "" && len(p.Days) == 0 &&
+		p.Outside == "" && !p.AutoSuspend
+}
+
+// Validate checks the policy.
+func (p Policy) Validate() error {
+	if _, err := p.location(); err != nil {
+		return err
+	}
+	for _, d := range p.Days {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok {
+			return fmt.Errorf("invalid day %q: use mon, tue, wed, thu, fri, sat or sun", d)
+		}
+	}
+	start, err := parseClock(p.Start)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	end, err := parseClock(p.End)
+	if err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if start == end || (start == 0 && end == 24*60) {
+		return fmt.Errorf("start and end must differ and leave the window closed part of the day")
+	}
+	switch p.Outside {
+	case "", ActionSkip, ActionDefer:
+	default:
+		return fmt.Errorf("invalid outside action %q: use %s or %s", p.Outside, ActionSkip, ActionDefer)
+	}
+	return nil
+}
+
+// OutsideAction returns the action for scheduled runs outside the window.
+func (p Policy) OutsideAction() string {
+	if p.Outside == "" {
+		return ActionSkip
+	}
+	return p.Outside
+}
+
+// Contains reports whether t falls within the window. An invalid policy
+// contains every time, so that it blocks nothing.
+func (p Policy) Contains(t time.Time) bool {
+	start, end, loc, err := p.bounds()
+	if err != nil {
+		return true
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return p.worksOn(local.Weekday()) && minute >= start && minute < end
+	}
+	// The window closes the next day.
+	if minute >= start {
+		return p.worksOn(local.Weekday())
+	}
+	return minute < end && p.worksOn(local.AddDate(0, 0, -1).Weekday())
+}
+
+// NextStart returns t if it falls within the window, or else the time the
+// window next opens. It returns the zero time for an invalid policy.
+func (p Policy) NextStart(t time.Time) time.Time {
+	start, _, loc, err := p.bounds()
+	if err != nil {
+		return time.Time{}
+	}
+	if p.Contains(t) {
+		return t
+	}
+	local := t.In(loc)
+	for day := 0; day <= 7; day++ {
+		d := local.AddDate(0, 0, day)
+		open := time.Date(d.Year(), d.Month(), d.Day(), start/60, start%60, 0, 0, loc)
+		if open.After(t) && p.worksOn(open.Weekday()) {
+			return open
+		}
+	}
+	return time.Time{}
+}
+
+// worksOn reports whether the window opens on day.
+func (p Policy) worksOn(day time.Weekday) bool {
+	days := p.Days
+	if len(days) == 0 {
+		days = defaultDays
+	}
+	for _, d := range days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// bounds returns the minutes of the day the window opens and closes at,
+// and its time zone.
+func (p Policy) bounds() (start, end int, loc *time.Location, err error) {
+	if err := p.Validate(); err != nil {
+		return 0, 0, nil, err
+	}
+	start, _ = parseClock(p.Start)
+	end, _ = parseClock(p.End)
+	loc, _ = p.location()
+	return start, end, loc, nil
+}
+
+func (p Policy) location() (*time.Location, error) {
+	if p.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", p.Timezone)
+	}
+	return loc, nil
+}
+
+// parseClock parses an "HH:MM" time into minutes after midnight.
+func parseClock(s string) (int, error) {
+	if s == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("invalid time %q: use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,96 @@
+package workhours
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicy_Contains(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("no time zone database")
+	}
+	office := Policy{Timezone: "Europe/Madrid", Start: "09:00", End: "18:00"}
+	night := Policy{Days: []string{"fri"}, Start: "22:00", End: "06:00"}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		t      time.Time
+		want   bool
+	}{
+		{"weekday morning", office, time.Date(2026, 10, 14, 9, 0, 0, 0, madrid), true},
+		{"before start", office, time.Date(2026, 10, 14, 8, 59, 0, 0, madrid), false},
+		{"end excluded", office, time.Date(2026, 10, 14, 18, 0, 0, 0, madrid), false},
+		{"other time zone", office, time.Date(2026, 10, 14, 7, 30, 0, 0, time.UTC), true},
+		{"weekend", office, time.Date(2026, 10, 17, 12, 0, 0, 0, madrid), false},
+		{"overnight start", night, time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC), true},
+		{"overnight next day", night, time.Date(2026, 10, 17, 5, 59, 0, 0, time.UTC), true},
+		{"overnight closed", night, time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC), false},
+		{"overnight other day", night, time.Date(2026, 10, 16, 5, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := tt.policy.Contains(tt.t); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_NextStart(t *testing.T) {
+	office := Policy{Start: "09:00", End: "18:00"}
+
+	within := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	if got := office.NextStart(within); !got.Equal(within) {
+		t.Errorf("within: got %v, want %v", got, within)
+	}
+	evening := time.Date(2026, 10, 14, 20, 0, 0, 0, time.UTC) // Wednesday
+	if got, want := office.NextStart(evening), time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("evening: got %v, want %v", got, want)
+	}
+	friday := time.Date(2026, 10, 16, 19, 0, 0, 0, time.UTC)
+	if got, want := office.NextStart(friday), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("weekend: got %v, want %v", got, want)
+	}
+	if got := (Policy{Start: "9am"}).NextStart(friday); !got.IsZero() {
+		t.Errorf("invalid policy: got %v, want the zero time", got)
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	valid := []Policy{
+		{Start: "09:00", End: "17:30"},
+		{Timezone: "America/New_York", Days: []string{"Sat", "sun"}, Start: "22:00", End: "24:00", Outside: ActionDefer},
+	}
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%+v: %v", p, err)
+		}
+	}
+	invalid := []Policy{
+		{Start: "09:00"},
+		{Start: "9:00", End: "17:00"},
+		{Start: "09:00", End: "24:30"},
+		{Start: "09:00", End: "09:00"},
+		{Start: "00:00", End: "24:00"},
+		{Start: "09:00", End: "17:00", Days: []string{"monday"}},
+		{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"},
+		{Start: "09:00", End: "17:00", Outside: ActionRun},
+	}
+	for _, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v: want an error", p)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, data := range []string{"", "null", "{}"} {
+		if p, err := Parse([]byte(data)); p != nil || err != nil {
+			t.Errorf("%q: got %+v, %v, want no policy", data, p, err)
+		}
+	}
+	p, err := Parse([]byte(`{"start":"09:00","end":"18:00","auto_suspend":true}`))
+	if err != nil || p == nil || !p.AutoSuspend || p.OutsideAction() != ActionSkip {
+		t.Errorf("got %+v, %v", p, err)
+	}
+}